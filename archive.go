@@ -0,0 +1,515 @@
+package git
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"errors"
+	"io"
+	"io/fs"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/go-git/go-git/v6/plumbing/filemode"
+	"github.com/go-git/go-git/v6/plumbing/format/gitattributes"
+	"github.com/go-git/go-git/v6/plumbing/object"
+	"github.com/go-git/go-git/v6/utils/ioutil"
+)
+
+const (
+	exportIgnoreAttr = "export-ignore"
+	exportSubstAttr  = "export-subst"
+
+	// archiveUmask is applied to the permissions of the tar entries, as the
+	// default tar.umask of git.
+	archiveUmask = 0o002
+
+	archiveFormatPlaceholder = "$Format:"
+)
+
+// ErrUnsupportedArchiveFormat is returned by Archive for an unknown format.
+var ErrUnsupportedArchiveFormat = errors.New("unsupported archive format")
+
+// Archive writes the files of a tree to w as a tar or zip archive, as
+// `git archive` does. h is a tree, or a commit or tag pointing to one. The
+// files with the export-ignore attribute are left out and, when archiving a
+// commit, the $Format:...$ placeholders of the files with the export-subst
+// attribute are expanded. The attributes are read from the .gitattributes
+// files of the tree.
+//
+// The archive is streamed to w, one file at a time, and w isn't closed.
+func (r *Repository) Archive(h plumbing.Hash, format ArchiveFormat, w io.Writer, opts *ArchiveOptions) (err error) {
+	if opts == nil {
+		opts = &ArchiveOptions{}
+	}
+
+	t, c, err := r.archiveTree(h)
+	if err != nil {
+		return err
+	}
+
+	if err := opts.Validate(c); err != nil {
+		return err
+	}
+
+	attrs, err := treeAttributesMatcher(t)
+	if err != nil {
+		return err
+	}
+
+	var comment string
+	if c != nil {
+		comment = c.Hash.String()
+	}
+
+	var aw archiveWriter
+	switch format {
+	case TarArchive:
+		aw, err = newTarArchiveWriter(w, opts.ModTime, comment)
+	case ZipArchive:
+		aw, err = newZipArchiveWriter(w, opts.ModTime, comment)
+	default:
+		return ErrUnsupportedArchiveFormat
+	}
+
+	if err != nil {
+		return err
+	}
+
+	defer ioutil.CheckClose(aw, &err)
+
+	a := &archiver{r: r, w: aw, commit: c, attrs: attrs, prefix: opts.Prefix}
+	if strings.HasSuffix(opts.Prefix, "/") {
+		if err := aw.writeDir(opts.Prefix); err != nil {
+			return err
+		}
+	}
+
+	return a.writeTree(t, "")
+}
+
+// archiveTree returns the tree to archive for the given tree-ish, and the
+// commit it comes from, if any.
+func (r *Repository) archiveTree(h plumbing.Hash) (*object.Tree, *object.Commit, error) {
+	o, err := r.Object(plumbing.AnyObject, h)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for {
+		switch obj := o.(type) {
+		case *object.Tree:
+			return obj, nil, nil
+		case *object.Commit:
+			t, err := obj.Tree()
+			return t, obj, err
+		case *object.Tag:
+			if o, err = obj.Object(); err != nil {
+				return nil, nil, err
+			}
+		default:
+			return nil, nil, plumbing.ErrObjectNotFound
+		}
+	}
+}
+
+// archiver walks a tree and writes its entries to an archiveWriter.
+type archiver struct {
+	r      *Repository
+	w      archiveWriter
+	commit *object.Commit
+	attrs  gitattributes.Matcher
+	prefix string
+}
+
+// writeTree writes the entries of the tree, found at the given directory of
+// the archived one, each directory being written before its content.
+func (a *archiver) writeTree(t *object.Tree, dir string) error {
+	for _, e := range t.Entries {
+		name := e.Name
+		if dir != "" {
+			name = dir + "/" + e.Name
+		}
+
+		ignore, subst := a.match(name)
+		if ignore {
+			continue
+		}
+
+		var err error
+		switch e.Mode {
+		case filemode.Dir:
+			err = a.writeSubtree(e.Hash, name)
+		case filemode.Submodule:
+			// The content of the submodules isn't archived, only an empty
+			// directory, as git does.
+			err = a.w.writeDir(a.prefix + name + "/")
+		case filemode.Symlink:
+			err = a.writeSymlink(e.Hash, name)
+		default:
+			err = a.writeFile(e.Hash, e.Mode, name, subst)
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (a *archiver) writeSubtree(h plumbing.Hash, name string) error {
+	t, err := object.GetTree(a.r.Storer, h)
+	if err != nil {
+		return err
+	}
+
+	if err := a.w.writeDir(a.prefix + name + "/"); err != nil {
+		return err
+	}
+
+	return a.writeTree(t, name)
+}
+
+func (a *archiver) writeSymlink(h plumbing.Hash, name string) error {
+	b, err := object.GetBlob(a.r.Storer, h)
+	if err != nil {
+		return err
+	}
+
+	target, err := readBlob(b)
+	if err != nil {
+		return err
+	}
+
+	return a.w.writeSymlink(a.prefix+name, string(target))
+}
+
+func (a *archiver) writeFile(h plumbing.Hash, mode filemode.FileMode, name string, subst bool) (err error) {
+	b, err := object.GetBlob(a.r.Storer, h)
+	if err != nil {
+		return err
+	}
+
+	if subst && a.commit != nil {
+		content, err := readBlob(b)
+		if err != nil {
+			return err
+		}
+
+		content = a.substitute(content)
+		return a.w.writeFile(a.prefix+name, mode, int64(len(content)), bytes.NewReader(content))
+	}
+
+	r, err := b.Reader()
+	if err != nil {
+		return err
+	}
+
+	defer ioutil.CheckClose(r, &err)
+	return a.w.writeFile(a.prefix+name, mode, b.Size, r)
+}
+
+// match returns whether the file at the given path has the export-ignore and
+// export-subst attributes set.
+func (a *archiver) match(name string) (ignore, subst bool) {
+	if a.attrs == nil {
+		return false, false
+	}
+
+	attrs, _ := a.attrs.Match(strings.Split(name, "/"),
+		[]string{exportIgnoreAttr, exportSubstAttr})
+	isSet := func(name string) bool {
+		attr, ok := attrs[name]
+		return ok && attr.IsSet()
+	}
+
+	return isSet(exportIgnoreAttr), isSet(exportSubstAttr)
+}
+
+// substitute expands the $Format:...$ placeholders of the content with the
+// archived commit.
+func (a *archiver) substitute(content []byte) []byte {
+	var buf bytes.Buffer
+	for {
+		i := bytes.Index(content, []byte(archiveFormatPlaceholder))
+		if i < 0 {
+			break
+		}
+
+		start := i + len(archiveFormatPlaceholder)
+		j := bytes.IndexByte(content[start:], '$')
+		if j < 0 {
+			break
+		}
+
+		buf.Write(content[:i])
+		buf.WriteString(a.formatCommit(string(content[start : start+j])))
+		content = content[start+j+1:]
+	}
+
+	buf.Write(content)
+	return buf.Bytes()
+}
+
+// formatCommit expands the placeholders of a `git log --pretty=format:`
+// string with the archived commit. The unknown placeholders are kept as they
+// are.
+func (a *archiver) formatCommit(format string) string {
+	c := a.commit
+	subject, body, _ := strings.Cut(strings.TrimLeft(c.Message, "\n"), "\n\n")
+	subject = strings.Join(strings.Fields(subject), " ")
+	body = strings.TrimLeft(body, "\n")
+
+	var sb strings.Builder
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' || i+1 == len(format) {
+			sb.WriteByte(format[i])
+			continue
+		}
+
+		n, s := a.formatPlaceholder(format[i+1:], c, subject, body)
+		if n == 0 {
+			sb.WriteByte(format[i])
+			continue
+		}
+
+		sb.WriteString(s)
+		i += n
+	}
+
+	return sb.String()
+}
+
+// formatPlaceholder expands the placeholder at the start of s, after the %,
+// and returns its length, or 0 if it's unknown.
+func (a *archiver) formatPlaceholder(s string, c *object.Commit, subject, body string) (int, string) {
+	switch s[0] {
+	case '%':
+		return 1, "%"
+	case 'n':
+		return 1, "\n"
+	case 'H':
+		return 1, c.Hash.String()
+	case 'h':
+		return 1, a.r.abbreviateHash(c.Hash, DefaultDescribeAbbrev)
+	case 'T':
+		return 1, c.TreeHash.String()
+	case 't':
+		return 1, a.r.abbreviateHash(c.TreeHash, DefaultDescribeAbbrev)
+	case 'P', 'p':
+		parents := make([]string, len(c.ParentHashes))
+		for i, h := range c.ParentHashes {
+			parents[i] = h.String()
+			if s[0] == 'p' {
+				parents[i] = a.r.abbreviateHash(h, DefaultDescribeAbbrev)
+			}
+		}
+
+		return 1, strings.Join(parents, " ")
+	case 's':
+		return 1, subject
+	case 'b':
+		return 1, body
+	case 'B':
+		return 1, c.Message
+	case 'a', 'c':
+		if len(s) < 2 {
+			return 0, ""
+		}
+
+		sig := c.Author
+		if s[0] == 'c' {
+			sig = c.Committer
+		}
+
+		if v, ok := formatSignature(sig, s[1]); ok {
+			return 2, v
+		}
+	}
+
+	return 0, ""
+}
+
+// formatSignature expands the %a and %c placeholders, the given verb being
+// the letter following them.
+func formatSignature(sig object.Signature, verb byte) (string, bool) {
+	switch verb {
+	case 'n':
+		return sig.Name, true
+	case 'e':
+		return sig.Email, true
+	case 'd':
+		return sig.When.Format("Mon Jan 2 15:04:05 2006 -0700"), true
+	case 'D':
+		return sig.When.Format("Mon, 2 Jan 2006 15:04:05 -0700"), true
+	case 'i':
+		return sig.When.Format("2006-01-02 15:04:05 -0700"), true
+	case 'I':
+		return sig.When.Format(time.RFC3339), true
+	case 't':
+		return strconv.FormatInt(sig.When.Unix(), 10), true
+	}
+
+	return "", false
+}
+
+// readBlob returns the whole content of the blob.
+func readBlob(b *object.Blob) (content []byte, err error) {
+	r, err := b.Reader()
+	if err != nil {
+		return nil, err
+	}
+
+	defer ioutil.CheckClose(r, &err)
+	return io.ReadAll(r)
+}
+
+// archiveWriter writes the entries of an archive in a given format.
+type archiveWriter interface {
+	// writeDir writes a directory, its name ending with a slash.
+	writeDir(name string) error
+	writeFile(name string, mode filemode.FileMode, size int64, r io.Reader) error
+	writeSymlink(name, target string) error
+	// Close writes the end of the archive, without closing the underlying
+	// writer.
+	Close() error
+}
+
+// tarArchiveWriter writes the entries as git does: owned by root, with the
+// permissions masked by the default umask.
+type tarArchiveWriter struct {
+	tw      *tar.Writer
+	modTime time.Time
+}
+
+// newTarArchiveWriter returns a writer of tar archives. The comment, if not
+// empty, is written in a pax global header, as git does with the hash of the
+// archived commit.
+func newTarArchiveWriter(w io.Writer, modTime time.Time, comment string) (*tarArchiveWriter, error) {
+	tw := tar.NewWriter(w)
+	if comment != "" {
+		err := tw.WriteHeader(&tar.Header{
+			Typeflag:   tar.TypeXGlobalHeader,
+			PAXRecords: map[string]string{"comment": comment},
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &tarArchiveWriter{tw: tw, modTime: modTime}, nil
+}
+
+func (w *tarArchiveWriter) header(typ byte, name string, mode int64) *tar.Header {
+	return &tar.Header{
+		Typeflag: typ,
+		Name:     name,
+		Mode:     mode,
+		ModTime:  w.modTime,
+		Uname:    "root",
+		Gname:    "root",
+	}
+}
+
+func (w *tarArchiveWriter) writeDir(name string) error {
+	return w.tw.WriteHeader(w.header(tar.TypeDir, name, 0o777&^archiveUmask))
+}
+
+func (w *tarArchiveWriter) writeFile(name string, mode filemode.FileMode, size int64, r io.Reader) error {
+	perm := int64(0o666)
+	if mode == filemode.Executable {
+		perm = 0o777
+	}
+
+	hdr := w.header(tar.TypeReg, name, perm&^archiveUmask)
+	hdr.Size = size
+	if err := w.tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+
+	_, err := io.Copy(w.tw, r)
+	return err
+}
+
+func (w *tarArchiveWriter) writeSymlink(name, target string) error {
+	hdr := w.header(tar.TypeSymlink, name, 0o777)
+	hdr.Linkname = target
+	return w.tw.WriteHeader(hdr)
+}
+
+func (w *tarArchiveWriter) Close() error {
+	return w.tw.Close()
+}
+
+// zipArchiveWriter writes the entries with their unix permissions, the files
+// being deflated and the symlinks stored with their target as content, as
+// git does.
+type zipArchiveWriter struct {
+	zw      *zip.Writer
+	modTime time.Time
+}
+
+// newZipArchiveWriter returns a writer of zip archives. The comment, if not
+// empty, is written as the comment of the archive, as git does with the hash
+// of the archived commit.
+func newZipArchiveWriter(w io.Writer, modTime time.Time, comment string) (*zipArchiveWriter, error) {
+	zw := zip.NewWriter(w)
+	if err := zw.SetComment(comment); err != nil {
+		return nil, err
+	}
+
+	return &zipArchiveWriter{zw: zw, modTime: modTime}, nil
+}
+
+func (w *zipArchiveWriter) create(name string, mode fs.FileMode, method uint16) (io.Writer, error) {
+	hdr := &zip.FileHeader{
+		Name:     name,
+		Method:   method,
+		Modified: w.modTime,
+	}
+
+	hdr.SetMode(mode)
+	return w.zw.CreateHeader(hdr)
+}
+
+func (w *zipArchiveWriter) writeDir(name string) error {
+	_, err := w.create(name, fs.ModeDir|0o755, zip.Store)
+	return err
+}
+
+func (w *zipArchiveWriter) writeFile(name string, mode filemode.FileMode, size int64, r io.Reader) error {
+	perm := fs.FileMode(0o644)
+	if mode == filemode.Executable {
+		perm = 0o755
+	}
+
+	method := zip.Deflate
+	if size == 0 {
+		method = zip.Store
+	}
+
+	zf, err := w.create(name, perm, method)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(zf, r)
+	return err
+}
+
+func (w *zipArchiveWriter) writeSymlink(name, target string) error {
+	zf, err := w.create(name, fs.ModeSymlink|0o777, zip.Store)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.WriteString(zf, target)
+	return err
+}
+
+func (w *zipArchiveWriter) Close() error {
+	return w.zw.Close()
+}
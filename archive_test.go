@@ -0,0 +1,175 @@
+package git
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/go-git/go-billy/v5/util"
+	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/go-git/go-git/v6/plumbing/object"
+	"github.com/go-git/go-git/v6/storage/memory"
+	"github.com/stretchr/testify/suite"
+)
+
+type ArchiveSuite struct {
+	suite.Suite
+	r *Repository
+
+	commit *object.Commit
+}
+
+func TestArchiveSuite(t *testing.T) {
+	suite.Run(t, new(ArchiveSuite))
+}
+
+func (s *ArchiveSuite) SetupTest() {
+	var err error
+	s.r, err = Init(memory.NewStorage(), WithWorkTree(memfs.New()))
+	s.Require().NoError(err)
+	w, err := s.r.Worktree()
+	s.Require().NoError(err)
+
+	files := map[string]string{
+		".gitattributes": "docs export-ignore\n*.secret export-ignore\nVERSION export-subst\n",
+		"README":         "readme\n",
+		"VERSION":        "$Format:%H %an <%ae>$ $Format:%s%n$\n$Unknown:%H$\n",
+		"docs/index":     "docs\n",
+		"src/main.go":    "package main\n",
+		"src/key.secret": "secret\n",
+	}
+
+	for name, content := range files {
+		s.Require().NoError(util.WriteFile(w.Filesystem, name, []byte(content), 0o644))
+	}
+
+	s.Require().NoError(util.WriteFile(w.Filesystem, "run.sh", []byte("#!/bin/sh\n"), 0o755))
+	s.Require().NoError(w.Filesystem.Symlink("README", "link"))
+	s.Require().NoError(w.AddGlob("."))
+
+	h, err := w.Commit("release\n\nbody", &CommitOptions{Author: defaultSignature()})
+	s.Require().NoError(err)
+	s.commit, err = s.r.CommitObject(h)
+	s.Require().NoError(err)
+}
+
+func (s *ArchiveSuite) archive(h plumbing.Hash, format ArchiveFormat, opts *ArchiveOptions) []byte {
+	var buf bytes.Buffer
+	s.Require().NoError(s.r.Archive(h, format, &buf, opts))
+	return buf.Bytes()
+}
+
+func (s *ArchiveSuite) TestTar() {
+	data := s.archive(s.commit.Hash, TarArchive, &ArchiveOptions{Prefix: "project/"})
+
+	type entry struct {
+		typ     byte
+		mode    int64
+		content string
+	}
+
+	entries := make(map[string]entry)
+	var names []string
+	tr := tar.NewReader(bytes.NewReader(data))
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+
+		s.Require().NoError(err)
+		if hdr.Typeflag == tar.TypeXGlobalHeader {
+			s.Equal(s.commit.Hash.String(), hdr.PAXRecords["comment"])
+			continue
+		}
+
+		s.True(s.commit.Committer.When.Equal(hdr.ModTime))
+
+		content, err := io.ReadAll(tr)
+		s.Require().NoError(err)
+
+		names = append(names, hdr.Name)
+		entries[hdr.Name] = entry{hdr.Typeflag, hdr.Mode, string(content) + hdr.Linkname}
+	}
+
+	s.Equal([]string{
+		"project/",
+		"project/.gitattributes",
+		"project/README",
+		"project/VERSION",
+		"project/link",
+		"project/run.sh",
+		"project/src/",
+		"project/src/main.go",
+	}, names)
+
+	s.Equal(entry{tar.TypeDir, 0o775, ""}, entries["project/src/"])
+	s.Equal(entry{tar.TypeReg, 0o664, "readme\n"}, entries["project/README"])
+	s.Equal(entry{tar.TypeReg, 0o775, "#!/bin/sh\n"}, entries["project/run.sh"])
+	s.Equal(entry{tar.TypeSymlink, 0o777, "README"}, entries["project/link"])
+
+	expected := s.commit.Hash.String() + " foo <foo@foo.foo> release\n\n$Unknown:%H$\n"
+	s.Equal(entry{tar.TypeReg, 0o664, expected}, entries["project/VERSION"])
+}
+
+func (s *ArchiveSuite) TestTarTree() {
+	modTime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	data := s.archive(s.commit.TreeHash, TarArchive, &ArchiveOptions{ModTime: modTime})
+
+	tr := tar.NewReader(bytes.NewReader(data))
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+
+		s.Require().NoError(err)
+		s.NotEqual(tar.TypeXGlobalHeader, hdr.Typeflag)
+		s.True(modTime.Equal(hdr.ModTime))
+
+		if hdr.Name == "VERSION" {
+			content, err := io.ReadAll(tr)
+			s.Require().NoError(err)
+			s.Contains(string(content), "$Format:%H")
+		}
+	}
+}
+
+func (s *ArchiveSuite) TestZip() {
+	data := s.archive(s.commit.Hash, ZipArchive, nil)
+
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	s.Require().NoError(err)
+	s.Equal(s.commit.Hash.String(), zr.Comment)
+
+	modes := make(map[string]string)
+	for _, f := range zr.File {
+		modes[f.Name] = f.Mode().String()
+	}
+
+	s.Equal(map[string]string{
+		".gitattributes": "-rw-r--r--",
+		"README":         "-rw-r--r--",
+		"VERSION":        "-rw-r--r--",
+		"link":           "Lrwxrwxrwx",
+		"run.sh":         "-rwxr-xr-x",
+		"src/":           "drwxr-xr-x",
+		"src/main.go":    "-rw-r--r--",
+	}, modes)
+
+	f, err := zr.Open("link")
+	s.Require().NoError(err)
+	defer f.Close()
+	target, err := io.ReadAll(f)
+	s.Require().NoError(err)
+	s.Equal("README", string(target))
+}
+
+func (s *ArchiveSuite) TestUnsupportedFormat() {
+	err := s.r.Archive(s.commit.Hash, ArchiveFormat(42), io.Discard, nil)
+	s.ErrorIs(err, ErrUnsupportedArchiveFormat)
+}
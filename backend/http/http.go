@@ -3,6 +3,7 @@ package http
 import (
 	"compress/gzip"
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"log"
@@ -55,13 +56,24 @@ type Backend struct {
 	// Prefix is a path prefix that will be stripped from the URL path before
 	// matching the service patterns.
 	Prefix string
+	// Authorize, if set, is called before serving a request to control the
+	// access to the repository. Dumb-HTTP requests are authorized as
+	// git-upload-pack requests, since they give read access to the
+	// repository. When it returns an error, the request is denied with a 401
+	// Unauthorized status if the error is
+	// [transport.ErrAuthenticationRequired], 404 Not Found if it is
+	// [transport.ErrRepositoryNotFound], and 403 Forbidden otherwise.
+	Authorize func(r *http.Request, ep *transport.Endpoint, svc transport.Service) error
 }
 
 // NewBackend returns a Git HTTP handler that serves git repositories over
 // HTTP.
 //
 // It supports serving repositories using both the Smart-HTTP and the Dumb-HTTP
-// protocols. When the Dumb-HTTP protocol is used, the repository store must
+// protocols. Smart-HTTP requests use the protocol version requested by the
+// client in the Git-Protocol header, git-upload-pack supports the versions 0,
+// 1 and 2, while git-receive-pack answers version 2 requests using version 0,
+// as git does. When the Dumb-HTTP protocol is used, the repository store must
 // implement the [storer.FilesystemStorer] interface. Keep in mind that
 // repositories that wish to be server using the Dumb-HTTP protocol must update
 // the server info files. This can be done by using
@@ -95,6 +107,14 @@ func (b *Backend) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 				return
 			}
 
+			if b.Authorize != nil {
+				if err := b.Authorize(r, ep, requestService(s, r)); err != nil {
+					logf(b.ErrorLog, "error authorizing request: %v", err)
+					renderStatusError(w, authorizeStatus(err))
+					return
+				}
+			}
+
 			st, err := b.Loader.Load(ep)
 			if err != nil {
 				logf(b.ErrorLog, "error loading repository: %v", err)
@@ -119,6 +139,33 @@ func (b *Backend) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	renderStatusError(w, http.StatusNotFound)
 }
 
+// requestService returns the service a request is made to. Dumb-HTTP
+// requests are made to git-upload-pack.
+func requestService(s service, r *http.Request) transport.Service {
+	if s.svc != "" {
+		return s.svc
+	}
+
+	if svc := r.URL.Query().Get("service"); svc != "" {
+		return transport.Service(svc)
+	}
+
+	return transport.UploadPackService
+}
+
+// authorizeStatus returns the HTTP status code of a request denied with the
+// given error.
+func authorizeStatus(err error) int {
+	switch {
+	case errors.Is(err, transport.ErrAuthenticationRequired):
+		return http.StatusUnauthorized
+	case errors.Is(err, transport.ErrRepositoryNotFound):
+		return http.StatusNotFound
+	default:
+		return http.StatusForbidden
+	}
+}
+
 // logf logs the given message to the error log if it is set.
 func logf(logger *log.Logger, format string, v ...interface{}) {
 	if logger != nil {
@@ -2,14 +2,24 @@ package http
 
 import (
 	"io"
+	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/go-git/go-billy/v5/util"
 	fixtures "github.com/go-git/go-git-fixtures/v5"
+	"github.com/go-git/go-git/v6"
+	"github.com/go-git/go-git/v6/config"
+	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/go-git/go-git/v6/plumbing/object"
 	"github.com/go-git/go-git/v6/plumbing/protocol/packp/capability"
 	"github.com/go-git/go-git/v6/plumbing/transport"
 	"github.com/go-git/go-git/v6/storage"
 	"github.com/go-git/go-git/v6/storage/filesystem"
+	"github.com/go-git/go-git/v6/storage/memory"
 	"github.com/stretchr/testify/require"
 )
 
@@ -45,7 +55,7 @@ e8d3ffab552895c19b9fcf7aa264d277cde33881	refs/remotes/origin/branch
 6ecf0ef2c2dffb796033e5a02219af86ec6584e5	refs/remotes/origin/master
 `
 	expectedSmart := `001e# service=git-upload-pack
-000000b46ecf0ef2c2dffb796033e5a02219af86ec6584e5 HEAD` + "\x00" + `agent=` + capability.DefaultAgent() + ` ofs-delta side-band-64k multi_ack multi_ack_detailed side-band no-progress shallow symref=HEAD:refs/heads/master
+000000c46ecf0ef2c2dffb796033e5a02219af86ec6584e5 HEAD` + "\x00" + `agent=` + capability.DefaultAgent() + ` ofs-delta side-band-64k multi_ack multi_ack_detailed side-band no-progress shallow deepen-relative symref=HEAD:refs/heads/master
 003fe8d3ffab552895c19b9fcf7aa264d277cde33881 refs/heads/branch
 003f6ecf0ef2c2dffb796033e5a02219af86ec6584e5 refs/heads/master
 00466ecf0ef2c2dffb796033e5a02219af86ec6584e5 refs/remotes/origin/HEAD
@@ -83,3 +93,149 @@ func TestDumbInfoRefs(t *testing.T) {
 func TestSmartInfoRefs(t *testing.T) {
 	testInfoRefs(t, true)
 }
+
+// newMemoryRepository returns the storage of an in-memory repository with a
+// single commit, and a loader serving it at /repo.git.
+func newMemoryRepository(t testing.TB) (*memory.Storage, transport.Loader) {
+	st := memory.NewStorage()
+	fs := memfs.New()
+	r, err := git.Init(st, git.WithWorkTree(fs))
+	require.NoError(t, err)
+
+	require.NoError(t, util.WriteFile(fs, "foo", []byte("foo\n"), 0o644))
+	w, err := r.Worktree()
+	require.NoError(t, err)
+	_, err = w.Add("foo")
+	require.NoError(t, err)
+	_, err = w.Commit("foo", &git.CommitOptions{
+		Author: &object.Signature{Name: "foo", Email: "foo@foo.foo", When: time.Now()},
+	})
+	require.NoError(t, err)
+
+	return st, memoryLoader{"repo.git": st}
+}
+
+// memoryLoader is a transport.Loader looking up the storages by path.
+type memoryLoader map[string]storage.Storer
+
+// Load implements transport.Loader.
+func (l memoryLoader) Load(ep *transport.Endpoint) (storage.Storer, error) {
+	st, ok := l[ep.Path]
+	if !ok {
+		return nil, transport.ErrRepositoryNotFound
+	}
+
+	return st, nil
+}
+
+func TestCloneMemoryStorage(t *testing.T) {
+	st, loader := newMemoryRepository(t)
+	srv := httptest.NewServer(NewBackend(loader))
+	defer srv.Close()
+
+	r, err := git.Clone(memory.NewStorage(), nil, &git.CloneOptions{URL: srv.URL + "/repo.git"})
+	require.NoError(t, err)
+
+	head, err := r.Head()
+	require.NoError(t, err)
+	expected, err := st.Reference(plumbing.NewBranchReferenceName("master"))
+	require.NoError(t, err)
+	require.Equal(t, expected.Hash(), head.Hash())
+
+	err = r.Push(&git.PushOptions{RefSpecs: []config.RefSpec{"refs/heads/master:refs/heads/pushed"}})
+	require.NoError(t, err)
+
+	pushed, err := st.Reference(plumbing.NewBranchReferenceName("pushed"))
+	require.NoError(t, err)
+	require.Equal(t, expected.Hash(), pushed.Hash())
+}
+
+func TestAuthorize(t *testing.T) {
+	st, loader := newMemoryRepository(t)
+
+	var services []transport.Service
+	h := NewBackend(loader)
+	h.Authorize = func(r *http.Request, ep *transport.Endpoint, svc transport.Service) error {
+		require.Equal(t, "repo.git", ep.Path)
+		services = append(services, svc)
+		if svc == transport.ReceivePackService {
+			return transport.ErrAuthorizationFailed
+		}
+
+		return nil
+	}
+
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+
+	r, err := git.Clone(memory.NewStorage(), nil, &git.CloneOptions{URL: srv.URL + "/repo.git"})
+	require.NoError(t, err)
+	require.Equal(t, []transport.Service{transport.UploadPackService, transport.UploadPackService}, services)
+
+	err = r.Push(&git.PushOptions{RefSpecs: []config.RefSpec{"refs/heads/master:refs/heads/pushed"}})
+	require.ErrorIs(t, err, transport.ErrAuthorizationFailed)
+
+	_, err = st.Reference(plumbing.NewBranchReferenceName("pushed"))
+	require.ErrorIs(t, err, plumbing.ErrReferenceNotFound)
+}
+
+func TestAuthorizeStatus(t *testing.T) {
+	for _, tc := range []struct {
+		err  error
+		code int
+	}{
+		{transport.ErrAuthenticationRequired, http.StatusUnauthorized},
+		{transport.ErrAuthorizationFailed, http.StatusForbidden},
+		{transport.ErrRepositoryNotFound, http.StatusNotFound},
+		{io.EOF, http.StatusForbidden},
+	} {
+		h := NewBackend(&fixturesLoader{t})
+		h.Authorize = func(*http.Request, *transport.Endpoint, transport.Service) error {
+			return tc.err
+		}
+
+		req := httptest.NewRequest("GET", "/basic.git/objects/info/packs", nil)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		require.Equal(t, tc.code, w.Result().StatusCode, tc.err)
+	}
+}
+
+func TestSmartInfoRefsVersions(t *testing.T) {
+	h := NewBackend(&fixturesLoader{t})
+
+	for version, prefix := range map[string]string{
+		"":          "001e# service=git-upload-pack\n0000",
+		"version=1": "001e# service=git-upload-pack\n0000000eversion 1\n",
+		"version=2": "000eversion 2\n",
+	} {
+		req := httptest.NewRequest("GET", "/basic.git/info/refs?service=git-upload-pack", nil)
+		req.Header.Set("Git-Protocol", version)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+
+		res := w.Result()
+		require.Equal(t, 200, res.StatusCode)
+		bts, err := io.ReadAll(res.Body)
+		require.NoError(t, err)
+		require.True(t, strings.HasPrefix(string(bts), prefix), "%s: %q", version, bts)
+	}
+}
+
+func TestUploadPackV2LsRefs(t *testing.T) {
+	h := NewBackend(&fixturesLoader{t})
+
+	body := "0014command=ls-refs\n0001001bref-prefix refs/heads/\n0000"
+	req := httptest.NewRequest("POST", "/basic.git/git-upload-pack", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/x-git-upload-pack-request")
+	req.Header.Set("Git-Protocol", "version=2")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+
+	res := w.Result()
+	require.Equal(t, 200, res.StatusCode)
+	bts, err := io.ReadAll(res.Body)
+	require.NoError(t, err)
+	require.Equal(t, "003fe8d3ffab552895c19b9fcf7aa264d277cde33881 refs/heads/branch\n"+
+		"003f6ecf0ef2c2dffb796033e5a02219af86ec6584e5 refs/heads/master\n0000", string(bts))
+}
@@ -0,0 +1,201 @@
+package git
+
+import (
+	"errors"
+	"io"
+	"sort"
+
+	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/go-git/go-git/v6/plumbing/object"
+	"github.com/go-git/go-git/v6/plumbing/storer"
+)
+
+var (
+	// ErrBisectNoBadCommit is returned when a bisection is started without
+	// bad commits.
+	ErrBisectNoBadCommit = errors.New("bisect: at least one bad commit is required")
+	// ErrBisectNoCandidates is returned when no commit is left to be the
+	// first bad one, i.e. the bad commits are reachable from the good ones.
+	ErrBisectNoCandidates = errors.New("bisect: no candidate commits left")
+)
+
+// BisectState holds the state of a bisection, the commits not yet known to be
+// good or bad, where the commit introducing a change is searched.
+type BisectState struct {
+	s    storer.EncodedObjectStorer
+	good []plumbing.Hash
+	bad  []plumbing.Hash
+
+	// candidates are the commits reachable from all the bad commits and not
+	// from any of the good ones, most recent first.
+	candidates []*object.Commit
+}
+
+// Bisect starts a bisection between the given good and bad commits, as `git
+// bisect start <bad> <good>...` does. The returned BisectState yields the
+// commits to test with Next, which are marked with MarkGood and MarkBad until
+// the first bad commit is found.
+func (r *Repository) Bisect(good, bad []plumbing.Hash) (*BisectState, error) {
+	if len(bad) == 0 {
+		return nil, ErrBisectNoBadCommit
+	}
+
+	b := &BisectState{
+		s:    r.Storer,
+		good: append([]plumbing.Hash(nil), good...),
+		bad:  append([]plumbing.Hash(nil), bad...),
+	}
+
+	if err := b.update(); err != nil {
+		return nil, err
+	}
+
+	return b, nil
+}
+
+// Next returns the commit to test next, the one splitting the remaining
+// candidates most evenly. It returns io.EOF once the first bad commit is
+// found, see FirstBad.
+func (b *BisectState) Next() (*object.Commit, error) {
+	if _, ok := b.FirstBad(); ok {
+		return nil, io.EOF
+	}
+
+	// As git does, the best commit is the one maximizing the minimum between
+	// the candidates reachable from it and the ones that aren't, which are
+	// the ones discarded when testing it as bad or good respectively.
+	in := make(map[plumbing.Hash]*object.Commit, len(b.candidates))
+	for _, c := range b.candidates {
+		in[c.Hash] = c
+	}
+
+	var best *object.Commit
+	bestDistance := -1
+	for _, c := range b.candidates {
+		weight := bisectWeight(c, in)
+		distance := min(weight, len(b.candidates)-weight)
+		if distance > bestDistance {
+			best, bestDistance = c, distance
+		}
+	}
+
+	return best, nil
+}
+
+// MarkGood marks the given commit as good, discarding it and its ancestors
+// from the candidates.
+func (b *BisectState) MarkGood(h plumbing.Hash) error {
+	b.good = append(b.good, h)
+	return b.update()
+}
+
+// MarkBad marks the given commit as bad, discarding the candidates not
+// reachable from it.
+func (b *BisectState) MarkBad(h plumbing.Hash) error {
+	b.bad = []plumbing.Hash{h}
+	return b.update()
+}
+
+// FirstBad returns the first bad commit once it's found, that is when the
+// only candidate left is a commit known to be bad.
+func (b *BisectState) FirstBad() (*object.Commit, bool) {
+	if len(b.candidates) != 1 {
+		return nil, false
+	}
+
+	c := b.candidates[0]
+	for _, h := range b.bad {
+		if h == c.Hash {
+			return c, true
+		}
+	}
+
+	return nil, false
+}
+
+// Candidates returns the number of commits that can still be the first bad
+// commit.
+func (b *BisectState) Candidates() int {
+	return len(b.candidates)
+}
+
+// update computes the candidates from the good and bad commits.
+func (b *BisectState) update() error {
+	excluded := make(map[plumbing.Hash]bool)
+	for _, h := range b.good {
+		c, err := object.GetCommit(b.s, h)
+		if err != nil {
+			return err
+		}
+
+		err = object.NewCommitPreorderIter(c, excluded, nil).ForEach(func(c *object.Commit) error {
+			excluded[c.Hash] = true
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	reached := make(map[plumbing.Hash]int)
+	commits := make(map[plumbing.Hash]*object.Commit)
+	for _, h := range b.bad {
+		c, err := object.GetCommit(b.s, h)
+		if err != nil {
+			return err
+		}
+
+		err = object.NewCommitPreorderIter(c, excluded, nil).ForEach(func(c *object.Commit) error {
+			reached[c.Hash]++
+			commits[c.Hash] = c
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	b.candidates = b.candidates[:0]
+	for h, n := range reached {
+		if n == len(b.bad) {
+			b.candidates = append(b.candidates, commits[h])
+		}
+	}
+
+	if len(b.candidates) == 0 {
+		return ErrBisectNoCandidates
+	}
+
+	sort.Slice(b.candidates, func(i, j int) bool {
+		ci, cj := b.candidates[i], b.candidates[j]
+		if !ci.Committer.When.Equal(cj.Committer.When) {
+			return ci.Committer.When.After(cj.Committer.When)
+		}
+
+		return ci.Hash.Compare(cj.Hash.Bytes()) < 0
+	})
+
+	return nil
+}
+
+// bisectWeight returns the number of candidates reachable from the given one,
+// including itself.
+func bisectWeight(c *object.Commit, candidates map[plumbing.Hash]*object.Commit) int {
+	seen := map[plumbing.Hash]bool{c.Hash: true}
+	pending := []*object.Commit{c}
+	for len(pending) > 0 {
+		c := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+		for _, p := range c.ParentHashes {
+			parent, ok := candidates[p]
+			if !ok || seen[p] {
+				continue
+			}
+
+			seen[p] = true
+			pending = append(pending, parent)
+		}
+	}
+
+	return len(seen)
+}
@@ -0,0 +1,136 @@
+package git
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/go-git/go-git/v6/plumbing/object"
+	"github.com/go-git/go-git/v6/storage/memory"
+	"github.com/stretchr/testify/suite"
+)
+
+type BisectSuite struct {
+	BaseSuite
+}
+
+func TestBisectSuite(t *testing.T) {
+	suite.Run(t, new(BisectSuite))
+}
+
+// bisect runs a bisection where the commits are bad when they descend from
+// the given first bad commit, returning the commit found and the number of
+// commits tested.
+func (s *BisectSuite) bisect(r *Repository, good, bad []plumbing.Hash, firstBad plumbing.Hash) (*object.Commit, int) {
+	b, err := r.Bisect(good, bad)
+	s.Require().NoError(err)
+
+	var steps int
+	for {
+		c, err := b.Next()
+		if err == io.EOF {
+			break
+		}
+		s.Require().NoError(err)
+		steps++
+
+		isBad := c.Hash == firstBad
+		if !isBad {
+			first, err := r.CommitObject(firstBad)
+			s.Require().NoError(err)
+			isBad, err = first.IsAncestor(c)
+			s.Require().NoError(err)
+		}
+
+		if isBad {
+			s.Require().NoError(b.MarkBad(c.Hash))
+		} else {
+			s.Require().NoError(b.MarkGood(c.Hash))
+		}
+	}
+
+	c, ok := b.FirstBad()
+	s.Require().True(ok)
+	return c, steps
+}
+
+func (s *BisectSuite) TestBisectLinear() {
+	r, err := Init(memory.NewStorage(), WithWorkTree(memfs.New()))
+	s.Require().NoError(err)
+	w, err := r.Worktree()
+	s.Require().NoError(err)
+
+	const n = 64
+	var hashes []plumbing.Hash
+	when := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < n; i++ {
+		sig := &object.Signature{Name: "foo", Email: "foo@foo.foo", When: when.Add(time.Duration(i) * time.Minute)}
+		h, err := w.Commit(fmt.Sprintf("commit %d", i), &CommitOptions{
+			Author:            sig,
+			AllowEmptyCommits: true,
+		})
+		s.Require().NoError(err)
+		hashes = append(hashes, h)
+	}
+
+	good, bad := hashes[:1], hashes[n-1:]
+	maxSteps := int(math.Ceil(math.Log2(n - 1)))
+	for _, i := range []int{1, 17, 32, 45, n - 1} {
+		c, steps := s.bisect(r, good, bad, hashes[i])
+		s.Equal(hashes[i], c.Hash)
+		s.LessOrEqual(steps, maxSteps)
+	}
+}
+
+func (s *BisectSuite) TestBisectMerges() {
+	good := []plumbing.Hash{plumbing.NewHash("b029517f6300c2da0f4b651b8642506cd6aaf45d")}
+	bad := []plumbing.Hash{plumbing.NewHash("6ecf0ef2c2dffb796033e5a02219af86ec6584e5")}
+
+	b, err := s.Repository.Bisect(good, bad)
+	s.Require().NoError(err)
+	s.Equal(7, b.Candidates())
+
+	for _, first := range []string{
+		"6ecf0ef2c2dffb796033e5a02219af86ec6584e5",
+		"918c48b83bd081e863dbe1b80f8998f058cd8294",
+		"1669dce138d9b841a518c64b10914d88f5e488ea",
+		"a5b8b09e2f8fcb0bb99d3ccb0958157b40890d69",
+		"b8e471f58bcbca63b07bda20e428190409c2db47",
+		"35e85108805c84807bc66a02d91535e1e24b38b9",
+	} {
+		c, steps := s.bisect(s.Repository, good, bad, plumbing.NewHash(first))
+		s.Equal(first, c.Hash.String())
+		s.LessOrEqual(steps, 3, first)
+	}
+}
+
+func (s *BisectSuite) TestBisectMultipleGood() {
+	good := []plumbing.Hash{
+		plumbing.NewHash("35e85108805c84807bc66a02d91535e1e24b38b9"),
+		plumbing.NewHash("b8e471f58bcbca63b07bda20e428190409c2db47"),
+	}
+	bad := []plumbing.Hash{plumbing.NewHash("6ecf0ef2c2dffb796033e5a02219af86ec6584e5")}
+
+	b, err := s.Repository.Bisect(good, bad)
+	s.Require().NoError(err)
+	s.Equal(5, b.Candidates())
+
+	first := plumbing.NewHash("a5b8b09e2f8fcb0bb99d3ccb0958157b40890d69")
+	c, _ := s.bisect(s.Repository, good, bad, first)
+	s.Equal(first, c.Hash)
+}
+
+func (s *BisectSuite) TestBisectErrors() {
+	good := []plumbing.Hash{plumbing.NewHash("6ecf0ef2c2dffb796033e5a02219af86ec6584e5")}
+	bad := []plumbing.Hash{plumbing.NewHash("918c48b83bd081e863dbe1b80f8998f058cd8294")}
+
+	_, err := s.Repository.Bisect(good, nil)
+	s.ErrorIs(err, ErrBisectNoBadCommit)
+
+	_, err = s.Repository.Bisect(good, bad)
+	s.ErrorIs(err, ErrBisectNoCandidates)
+}
@@ -3,6 +3,7 @@ package git
 import (
 	"bytes"
 	"container/heap"
+	"context"
 	"errors"
 	"fmt"
 	"io"
@@ -27,8 +28,24 @@ type BlameResult struct {
 }
 
 // Blame returns a BlameResult with the information about the last author of
-// each line from file `path` at commit `c`.
+// each line from file `path` at commit `c`. Renames are followed using the
+// default rename score.
 func Blame(c *object.Commit, path string) (*BlameResult, error) {
+	return BlameWithOptions(c, path, &BlameOptions{FollowRenames: true})
+}
+
+// BlameWithOptions returns a BlameResult with the information about the last
+// author of each line from file `path` at commit `c`, using the given options.
+// If opts is nil, renames are not followed.
+func BlameWithOptions(c *object.Commit, path string, opts *BlameOptions) (*BlameResult, error) {
+	if opts == nil {
+		opts = &BlameOptions{}
+	}
+
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+
 	// The file to blame is identified by the input arguments:
 	// commit and path. commit is a Commit object obtained from a Repository. Path
 	// represents a path to a specific file contained in the repository.
@@ -48,6 +65,7 @@ func Blame(c *object.Commit, path string) (*BlameResult, error) {
 	b := new(blame)
 	b.fRev = c
 	b.path = path
+	b.opts = opts
 	b.q = new(priorityQueue)
 
 	file, err := b.fRev.File(path)
@@ -107,7 +125,7 @@ func Blame(c *object.Commit, path string) (*BlameResult, error) {
 		b.lineToCommit[i] = needsMap[i].Commit
 	}
 
-	lines := newLines(finalLines, b.lineToCommit)
+	lines := newLines(finalLines, b.lineToCommit, opts.MailMap)
 
 	return &BlameResult{
 		Path:  path,
@@ -140,12 +158,13 @@ func newLine(author, authorName, text string, date time.Time, hash plumbing.Hash
 	}
 }
 
-func newLines(contents []string, commits []*object.Commit) []*Line {
+func newLines(contents []string, commits []*object.Commit, mm *object.MailMap) []*Line {
 	result := make([]*Line, 0, len(contents))
 	for i := range contents {
+		author := commits[i].Author.Canonical(mm)
 		result = append(result, newLine(
-			commits[i].Author.Email, commits[i].Author.Name, contents[i],
-			commits[i].Author.When, commits[i].Hash,
+			author.Email, author.Name, contents[i],
+			author.When, commits[i].Hash,
 		))
 	}
 
@@ -163,6 +182,8 @@ type blame struct {
 	lineToCommit []*object.Commit
 	// queue of commits that need resolving
 	q *priorityQueue
+	// options of the blame operation
+	opts *BlameOptions
 }
 
 type lineMap struct {
@@ -241,7 +262,7 @@ func (b *blame) addBlames(curItems []*queueItem) (bool, error) {
 		curItems = nil // free the memory
 	}
 
-	parents, err := parentsContainingPath(curItem.path, curItem.Commit)
+	parents, err := parentsContainingPath(curItem.path, curItem.Commit, b.opts)
 	if err != nil {
 		return false, err
 	}
@@ -542,7 +563,7 @@ type parentCommit struct {
 	Path   string
 }
 
-func parentsContainingPath(path string, c *object.Commit) ([]parentCommit, error) {
+func parentsContainingPath(path string, c *object.Commit, opts *BlameOptions) ([]parentCommit, error) {
 	// TODO: benchmark this method making git.object.Commit.parent public instead of using
 	// an iterator
 	var result []parentCommit
@@ -557,24 +578,49 @@ func parentsContainingPath(path string, c *object.Commit) ([]parentCommit, error
 		}
 		if _, err := parent.File(path); err == nil {
 			result = append(result, parentCommit{parent, path})
-		} else {
-			// look for renames
-			patch, err := parent.Patch(c)
+		} else if opts.FollowRenames {
+			from, err := renamedFrom(path, parent, c, opts.RenameScore)
 			if err != nil {
 				return nil, err
-			} else if patch != nil {
-				for _, fp := range patch.FilePatches() {
-					from, to := fp.Files()
-					if from != nil && to != nil && to.Path() == path {
-						result = append(result, parentCommit{parent, from.Path()})
-						break
-					}
-				}
+			}
+			if from != "" {
+				result = append(result, parentCommit{parent, from})
 			}
 		}
 	}
 }
 
+// renamedFrom returns the path the file at `path` in commit c had in the
+// given parent, if it was renamed with at least the given similarity score.
+// An empty path is returned if the file was not renamed.
+func renamedFrom(path string, parent, c *object.Commit, score uint) (string, error) {
+	from, err := parent.Tree()
+	if err != nil {
+		return "", err
+	}
+
+	to, err := c.Tree()
+	if err != nil {
+		return "", err
+	}
+
+	changes, err := object.DiffTreeWithOptions(context.Background(), from, to, &object.DiffTreeOptions{
+		DetectRenames: true,
+		RenameScore:   score,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	for _, ch := range changes {
+		if ch.To.Name == path && ch.From.Name != "" && ch.From.Name != path {
+			return ch.From.Name, nil
+		}
+	}
+
+	return "", nil
+}
+
 func blobHash(path string, commit *object.Commit) (plumbing.Hash, error) {
 	file, err := commit.File(path)
 	if err != nil {
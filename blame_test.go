@@ -3,9 +3,13 @@ package git
 import (
 	"fmt"
 	"testing"
+	"time"
 
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/go-git/go-billy/v5/util"
 	"github.com/go-git/go-git/v6/plumbing"
 	"github.com/go-git/go-git/v6/plumbing/object"
+	"github.com/go-git/go-git/v6/storage/memory"
 	"github.com/stretchr/testify/suite"
 
 	fixtures "github.com/go-git/go-git-fixtures/v5"
@@ -24,7 +28,7 @@ func (s *BlameSuite) TestNewLines() {
 	lines := newLines([]string{"foo"}, []*object.Commit{{
 		Hash:    h,
 		Message: "foo",
-	}})
+	}}, nil)
 
 	s.Len(lines, 1)
 	s.Equal("foo", lines[0].Text)
@@ -35,13 +39,29 @@ func (s *BlameSuite) TestNewLinesWithNewLine() {
 	lines := newLines([]string{"foo", ""}, []*object.Commit{
 		{Message: "foo"},
 		{Message: "bar"},
-	})
+	}, nil)
 
 	s.Len(lines, 2)
 	s.Equal("foo", lines[0].Text)
 	s.Equal("", lines[1].Text)
 }
 
+func (s *BlameSuite) TestNewLinesWithMailMap() {
+	mm := object.NewMailMap()
+	mm.Add("Jane Doe", "jane@example.com", "", "jdoe@old.example.com")
+
+	lines := newLines([]string{"foo", "bar"}, []*object.Commit{
+		{Author: object.Signature{Name: "jdoe", Email: "JDoe@old.example.com"}},
+		{Author: object.Signature{Name: "John", Email: "john@example.com"}},
+	}, mm)
+
+	s.Len(lines, 2)
+	s.Equal("Jane Doe", lines[0].AuthorName)
+	s.Equal("jane@example.com", lines[0].Author)
+	s.Equal("John", lines[1].AuthorName)
+	s.Equal("john@example.com", lines[1].Author)
+}
+
 type blameTest struct {
 	repo   string
 	rev    string
@@ -100,6 +120,110 @@ func (s *BlameSuite) mockBlame(t blameTest, r *Repository) (blame *BlameResult)
 	}
 }
 
+// commitRename creates a repository where a file is created, renamed while
+// changing some of its lines, and modified again. It returns the hashes of
+// the three commits.
+func (s *BlameSuite) commitRename() (*Repository, []plumbing.Hash) {
+	fs := memfs.New()
+	r, err := Init(memory.NewStorage(), WithWorkTree(fs))
+	s.Require().NoError(err)
+
+	w, err := r.Worktree()
+	s.Require().NoError(err)
+
+	var hashes []plumbing.Hash
+	commit := func(files map[string]string) {
+		for name, content := range files {
+			if content == "" {
+				_, err := w.Remove(name)
+				s.Require().NoError(err)
+				continue
+			}
+
+			s.Require().NoError(util.WriteFile(fs, name, []byte(content), 0644))
+			_, err := w.Add(name)
+			s.Require().NoError(err)
+		}
+
+		sig := defaultSignature()
+		sig.When = sig.When.Add(time.Duration(len(hashes)) * time.Hour)
+		h, err := w.Commit("commit", &CommitOptions{Author: sig})
+		s.Require().NoError(err)
+		hashes = append(hashes, h)
+	}
+
+	commit(map[string]string{"foo": "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n"})
+	commit(map[string]string{"foo": "", "bar": "1\n2\n3\n4\n5\n6\n7\nb\nc\nd\n"})
+	commit(map[string]string{"bar": "a\n2\n3\n4\n5\n6\n7\nb\nc\nd\n"})
+
+	return r, hashes
+}
+
+func (s *BlameSuite) TestBlameWithOptionsFollowRenames() {
+	r, hashes := s.commitRename()
+
+	head, err := r.CommitObject(hashes[2])
+	s.Require().NoError(err)
+
+	result, err := BlameWithOptions(head, "bar", &BlameOptions{FollowRenames: true})
+	s.Require().NoError(err)
+	s.Require().Len(result.Lines, 10)
+
+	expected := []plumbing.Hash{hashes[2]}
+	expected = append(expected, repeatHash(hashes[0], 6)...)
+	expected = append(expected, repeatHash(hashes[1], 3)...)
+	for i, l := range result.Lines {
+		s.Equal(expected[i], l.Hash, fmt.Sprintf("line %d", i+1))
+	}
+}
+
+func (s *BlameSuite) TestBlameWithOptionsNoFollowRenames() {
+	r, hashes := s.commitRename()
+
+	head, err := r.CommitObject(hashes[2])
+	s.Require().NoError(err)
+
+	for _, opts := range []*BlameOptions{nil, {FollowRenames: true, RenameScore: 90}} {
+		result, err := BlameWithOptions(head, "bar", opts)
+		s.Require().NoError(err)
+		s.Require().Len(result.Lines, 10)
+
+		s.Equal(hashes[2], result.Lines[0].Hash)
+		for _, l := range result.Lines[1:] {
+			s.Equal(hashes[1], l.Hash)
+		}
+	}
+}
+
+func (s *BlameSuite) TestBlameWithOptionsInvalidRenameScore() {
+	r, hashes := s.commitRename()
+
+	head, err := r.CommitObject(hashes[2])
+	s.Require().NoError(err)
+
+	_, err = BlameWithOptions(head, "bar", &BlameOptions{RenameScore: 101})
+	s.ErrorIs(err, ErrInvalidRenameScore)
+}
+
+func (s *BlameSuite) TestBlameOptionsDefaultRenameScore() {
+	opts := &BlameOptions{}
+	s.NoError(opts.Validate())
+	s.Equal(uint(DefaultBlameRenameScore), opts.RenameScore)
+
+	opts = &BlameOptions{RenameScore: 1}
+	s.NoError(opts.Validate())
+	s.Equal(uint(1), opts.RenameScore)
+}
+
+func repeatHash(h plumbing.Hash, n int) []plumbing.Hash {
+	r := make([]plumbing.Hash, n)
+	for i := range r {
+		r[i] = h
+	}
+
+	return r
+}
+
 // utility function to avoid writing so many repeated commits
 func repeat(s string, n int) []string {
 	if n < 0 {
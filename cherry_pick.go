@@ -0,0 +1,286 @@
+package git
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/go-git/go-git/v6/plumbing/object"
+)
+
+// ErrCherryPickInProgress is returned when a cherry-pick is attempted while
+// a previous one, recorded in CHERRY_PICK_HEAD, was not yet committed.
+var ErrCherryPickInProgress = errors.New("a cherry-pick is already in progress")
+
+// CherryPickResult holds the outcome of a cherry-pick.
+type CherryPickResult struct {
+	// Commit is the commit created, it is the zero hash when the cherry-pick
+	// stopped because of conflicts, or NoCommit was given.
+	Commit plumbing.Hash
+	// Conflicts lists the paths that could not be merged automatically.
+	Conflicts []MergeConflict
+}
+
+// HasConflicts returns true if the cherry-pick resulted in any conflict.
+func (r *CherryPickResult) HasConflicts() bool {
+	return len(r.Conflicts) > 0
+}
+
+// CherryPick applies the changes introduced by the given commit on top of
+// the current HEAD, creating a new commit with the same author and message.
+//
+// The changes are the ones between the commit and its first parent, or its
+// whole tree for a root commit, and are applied with a three-way merge. When
+// they conflict with HEAD, the conflicting paths are recorded in the index
+// using the ancestor (1), ours (2) and theirs (3) stages, with conflict
+// markers written into the worktree, and CHERRY_PICK_HEAD is left pointing to
+// the commit. The cherry-pick can then be concluded by calling Commit once
+// the conflicts are resolved, or aborted by resetting the worktree.
+//
+// ErrWorktreeNotClean is returned if the worktree has uncommitted changes,
+// and ErrEmptyCommit if the changes are already in HEAD, unless AllowEmpty is
+// given.
+func (w *Worktree) CherryPick(commit plumbing.Hash, opts *CherryPickOptions) (*CherryPickResult, error) {
+	if opts == nil {
+		opts = &CherryPickOptions{}
+	}
+
+	if err := opts.Validate(w.r); err != nil {
+		return nil, err
+	}
+
+	if err := w.r.checkNoPickInProgress(); err != nil {
+		return nil, err
+	}
+
+	theirs, err := w.r.CommitObject(commit)
+	if err != nil {
+		return nil, err
+	}
+
+	status, err := w.cleanStatus()
+	if err != nil {
+		return nil, err
+	}
+
+	ours, res, tree, err := w.pickCommit(theirs, status, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(res.conflicts) > 0 {
+		err := w.r.Storer.SetReference(plumbing.NewHashReference(plumbing.CherryPickHead, theirs.Hash))
+		if err != nil {
+			return nil, err
+		}
+
+		return &CherryPickResult{Conflicts: res.conflicts}, nil
+	}
+
+	if opts.NoCommit {
+		return &CherryPickResult{}, nil
+	}
+
+	msg := theirs.Message
+	if opts.RecordOrigin {
+		msg = cherryPickOriginMessage(msg, theirs.Hash)
+	}
+
+	h, err := w.commitPick(&theirs.Author, msg, ours.Hash, tree.Hash, opts.Committer, "cherry-pick: ")
+	if err != nil {
+		return nil, err
+	}
+
+	return &CherryPickResult{Commit: h}, nil
+}
+
+// checkNoPickInProgress returns an error if a merge, a cherry-pick or a
+// revert stopped by conflicts is in progress.
+func (r *Repository) checkNoPickInProgress() error {
+	inProgress := []struct {
+		name plumbing.ReferenceName
+		err  error
+	}{
+		{plumbing.MergeHead, ErrMergeInProgress},
+		{plumbing.CherryPickHead, ErrCherryPickInProgress},
+		{plumbing.RevertHead, ErrRevertInProgress},
+	}
+
+	for _, p := range inProgress {
+		_, err := r.Storer.Reference(p.name)
+		if err == nil {
+			return p.err
+		}
+
+		if err != plumbing.ErrReferenceNotFound {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// cleanStatus returns the status of the worktree, or ErrWorktreeNotClean if
+// it has uncommitted changes.
+func (w *Worktree) cleanStatus() (Status, error) {
+	status, err := w.Status()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, fs := range status {
+		if !isUntrackedOrUnmodified(fs.Staging) || !isUntrackedOrUnmodified(fs.Worktree) {
+			return nil, ErrWorktreeNotClean
+		}
+	}
+
+	return status, nil
+}
+
+// pick is a change applied onto HEAD by a cherry-pick or a revert, the
+// differences from the base tree to theirs.
+type pick struct {
+	// base is the tree the changes are computed from, nil for an empty one.
+	base *object.Tree
+	// theirs is the tree the changes lead to.
+	theirs *object.Tree
+	// label names theirs in the conflict markers.
+	label string
+}
+
+// pickCommit merges the changes introduced by the given commit into HEAD,
+// see applyPick.
+func (w *Worktree) pickCommit(theirs *object.Commit, status Status, opts *CherryPickOptions) (
+	ours *object.Commit, res *treeMergeResult, tree *object.Tree, err error,
+) {
+	p := &pick{label: fmt.Sprintf("%s (%s)", theirs.Hash.String()[:7], commitSubject(theirs.Message))}
+	if theirs.NumParents() > 0 {
+		parent, err := theirs.Parent(0)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+
+		if p.base, err = parent.Tree(); err != nil {
+			return nil, nil, nil, err
+		}
+	}
+
+	if p.theirs, err = theirs.Tree(); err != nil {
+		return nil, nil, nil, err
+	}
+
+	return w.applyPick(p, status, opts.NoCommit, opts.AllowEmpty)
+}
+
+// applyPick merges the changes of the pick into HEAD with a three-way merge,
+// updating the index and the worktree, whose status is given. The tree of the
+// result is written, unless it has conflicts or noCommit is given, and
+// ErrEmptyCommit is returned if it is the tree of HEAD and allowEmpty is not
+// given, before changing anything.
+func (w *Worktree) applyPick(p *pick, status Status, noCommit, allowEmpty bool) (
+	ours *object.Commit, res *treeMergeResult, tree *object.Tree, err error,
+) {
+	head, err := w.r.Head()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	ours, err = w.r.CommitObject(head.Hash())
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	attributes, err := w.attributesMatcher()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	m := &treeMerger{
+		s:           w.r.Storer,
+		attributes:  attributes,
+		oursLabel:   plumbing.HEAD.String(),
+		theirsLabel: p.label,
+	}
+
+	oursTree, err := ours.Tree()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	res, err = m.merge(p.base, oursTree, p.theirs)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	if len(res.conflicts) == 0 && !noCommit {
+		if tree, err = m.writeTree(res); err != nil {
+			return nil, nil, nil, err
+		}
+
+		if tree.Hash == oursTree.Hash && !allowEmpty {
+			return nil, nil, nil, ErrEmptyCommit
+		}
+	}
+
+	if err := w.applyMerge(res, status); err != nil {
+		return nil, nil, nil, err
+	}
+
+	return ours, res, tree, nil
+}
+
+// commitPick creates the commit of a pick applied cleanly, with the given
+// author, message and tree, and updates HEAD to it, logging the given action
+// before the subject.
+func (w *Worktree) commitPick(author *object.Signature, msg string, parent, tree plumbing.Hash, committer *object.Signature, action string) (plumbing.Hash, error) {
+	co := &CommitOptions{
+		Author:    author,
+		Committer: committer,
+		Parents:   []plumbing.Hash{parent},
+	}
+
+	h, err := w.buildCommitObject(msg, co, tree)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	if err := w.updateHEAD(h, committer, action+commitSubject(msg)); err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	return h, nil
+}
+
+// cherryPickOriginMessage appends the line recording the commit a message was
+// cherry-picked from, as `git cherry-pick -x` does. The line is separated by
+// a blank line, unless the message already ends with a trailer block.
+func cherryPickOriginMessage(msg string, h plumbing.Hash) string {
+	msg = strings.TrimRight(msg, "\n")
+	origin := fmt.Sprintf("(cherry picked from commit %s)\n", h)
+
+	if i := strings.LastIndex(msg, "\n\n"); i >= 0 && isTrailerBlock(msg[i+2:]) {
+		return msg + "\n" + origin
+	}
+
+	return msg + "\n\n" + origin
+}
+
+// isTrailerBlock returns true if every line of the paragraph is a trailer or
+// records a cherry-pick.
+func isTrailerBlock(paragraph string) bool {
+	for _, line := range strings.Split(paragraph, "\n") {
+		if !isTrailerLine(line) && !strings.HasPrefix(line, "(cherry picked from commit ") {
+			return false
+		}
+	}
+
+	return true
+}
+
+// isTrailerLine returns true if the line looks like a "Key: value" trailer,
+// e.g. a Signed-off-by line.
+func isTrailerLine(line string) bool {
+	key, _, ok := strings.Cut(line, ": ")
+	return ok && key != "" && !strings.ContainsAny(key, " \t")
+}
@@ -0,0 +1,251 @@
+package git
+
+import (
+	"testing"
+
+	"github.com/go-git/go-billy/v5/util"
+	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/go-git/go-git/v6/plumbing/format/index"
+	"github.com/stretchr/testify/assert"
+)
+
+func (s *WorktreeSuite) TestCherryPick() {
+	r, w, fs, other := s.setupMergeBranches(
+		map[string][]byte{"foo": []byte("a\nb\nc\nd\ne\n")},
+		map[string][]byte{"foo": []byte("A\nb\nc\nd\ne\n")},
+		map[string][]byte{"foo": []byte("a\nb\nc\nd\nE\n"), "bar": []byte("bar\n")},
+	)
+
+	head, err := r.Head()
+	s.NoError(err)
+
+	res, err := w.CherryPick(other, &CherryPickOptions{Committer: defaultSignature(), RecordOrigin: true})
+	s.NoError(err)
+	s.False(res.HasConflicts())
+	s.False(res.Commit.IsZero())
+
+	content, err := util.ReadFile(fs, "foo")
+	s.NoError(err)
+	s.Equal("A\nb\nc\nd\nE\n", string(content))
+
+	content, err = util.ReadFile(fs, "bar")
+	s.NoError(err)
+	s.Equal("bar\n", string(content))
+
+	status, err := w.Status()
+	s.NoError(err)
+	s.True(status.IsClean())
+
+	ref, err := r.Head()
+	s.NoError(err)
+	s.Equal(plumbing.Master, ref.Name())
+	s.Equal(res.Commit, ref.Hash())
+
+	original, err := r.CommitObject(other)
+	s.NoError(err)
+
+	commit, err := r.CommitObject(res.Commit)
+	s.NoError(err)
+	s.Equal([]plumbing.Hash{head.Hash()}, commit.ParentHashes)
+	s.Equal(original.Author.Name, commit.Author.Name)
+	s.Equal(original.Author.Email, commit.Author.Email)
+	s.Equal("commit\n\n(cherry picked from commit "+other.String()+")\n", commit.Message)
+
+	_, err = r.Reference(plumbing.CherryPickHead, false)
+	s.ErrorIs(err, plumbing.ErrReferenceNotFound)
+}
+
+func (s *WorktreeSuite) TestCherryPickConflict() {
+	r, w, fs, other := s.setupMergeBranches(
+		map[string][]byte{"foo": []byte("a\nb\nc\n")},
+		map[string][]byte{"foo": []byte("a\nX\nc\n")},
+		map[string][]byte{"foo": []byte("a\nY\nc\n")},
+	)
+
+	head, err := r.Head()
+	s.NoError(err)
+
+	res, err := w.CherryPick(other, &CherryPickOptions{Committer: defaultSignature()})
+	s.NoError(err)
+	s.True(res.Commit.IsZero())
+	s.Require().Len(res.Conflicts, 1)
+	s.Equal("foo", res.Conflicts[0].Path)
+
+	content, err := util.ReadFile(fs, "foo")
+	s.NoError(err)
+	s.Equal("a\n<<<<<<< HEAD\nX\n=======\nY\n>>>>>>> "+other.String()[:7]+" (commit)\nc\n", string(content))
+
+	idx, err := r.Storer.Index()
+	s.NoError(err)
+
+	var stages []index.Stage
+	for _, e := range idx.Entries {
+		if e.Name == "foo" {
+			stages = append(stages, e.Stage)
+		}
+	}
+	s.ElementsMatch([]index.Stage{index.AncestorMode, index.OurMode, index.TheirMode}, stages)
+
+	ref, err := r.Reference(plumbing.CherryPickHead, false)
+	s.NoError(err)
+	s.Equal(other, ref.Hash())
+
+	_, err = w.CherryPick(other, &CherryPickOptions{Committer: defaultSignature()})
+	s.ErrorIs(err, ErrCherryPickInProgress)
+
+	s.NoError(util.WriteFile(fs, "foo", []byte("a\nXY\nc\n"), 0644))
+	_, err = w.Add("foo")
+	s.NoError(err)
+
+	h, err := w.Commit("commit", &CommitOptions{Author: defaultSignature()})
+	s.NoError(err)
+
+	commit, err := r.CommitObject(h)
+	s.NoError(err)
+	s.Equal([]plumbing.Hash{head.Hash()}, commit.ParentHashes)
+
+	_, err = r.Reference(plumbing.CherryPickHead, false)
+	s.ErrorIs(err, plumbing.ErrReferenceNotFound)
+}
+
+func (s *WorktreeSuite) TestCherryPickAbort() {
+	r, w, _, other := s.setupMergeBranches(
+		map[string][]byte{"foo": []byte("a\nb\nc\n")},
+		map[string][]byte{"foo": []byte("a\nX\nc\n")},
+		map[string][]byte{"foo": []byte("a\nY\nc\n")},
+	)
+
+	head, err := r.Head()
+	s.NoError(err)
+
+	res, err := w.CherryPick(other, &CherryPickOptions{Committer: defaultSignature()})
+	s.NoError(err)
+	s.True(res.HasConflicts())
+
+	s.NoError(w.Reset(&ResetOptions{Mode: HardReset, Commit: head.Hash()}))
+
+	_, err = r.Reference(plumbing.CherryPickHead, false)
+	s.ErrorIs(err, plumbing.ErrReferenceNotFound)
+
+	status, err := w.Status()
+	s.NoError(err)
+	s.True(status.IsClean())
+}
+
+func (s *WorktreeSuite) TestCherryPickNoCommit() {
+	r, w, fs, other := s.setupMergeBranches(
+		map[string][]byte{"foo": []byte("foo\n")},
+		map[string][]byte{"qux": []byte("qux\n")},
+		map[string][]byte{"bar": []byte("bar\n")},
+	)
+
+	head, err := r.Head()
+	s.NoError(err)
+
+	res, err := w.CherryPick(other, &CherryPickOptions{NoCommit: true})
+	s.NoError(err)
+	s.True(res.Commit.IsZero())
+	s.False(res.HasConflicts())
+
+	ref, err := r.Head()
+	s.NoError(err)
+	s.Equal(head.Hash(), ref.Hash())
+
+	content, err := util.ReadFile(fs, "bar")
+	s.NoError(err)
+	s.Equal("bar\n", string(content))
+
+	status, err := w.Status()
+	s.NoError(err)
+	s.Equal(Added, status.File("bar").Staging)
+	s.Equal(Unmodified, status.File("bar").Worktree)
+
+	_, err = r.Reference(plumbing.CherryPickHead, false)
+	s.ErrorIs(err, plumbing.ErrReferenceNotFound)
+}
+
+func (s *WorktreeSuite) TestCherryPickRootCommit() {
+	r, w, fs, _ := s.setupMergeBranches(
+		map[string][]byte{"foo": []byte("foo\n")},
+		map[string][]byte{"foo": nil, "bar": []byte("bar\n")},
+		nil,
+	)
+
+	head, err := r.Head()
+	s.NoError(err)
+
+	commit, err := r.CommitObject(head.Hash())
+	s.NoError(err)
+
+	root := commit.ParentHashes[0]
+	res, err := w.CherryPick(root, &CherryPickOptions{Committer: defaultSignature()})
+	s.NoError(err)
+	s.False(res.HasConflicts())
+
+	for name, expected := range map[string]string{"foo": "foo\n", "bar": "bar\n"} {
+		content, err := util.ReadFile(fs, name)
+		s.NoError(err)
+		s.Equal(expected, string(content))
+	}
+
+	commit, err = r.CommitObject(res.Commit)
+	s.NoError(err)
+	s.Equal([]plumbing.Hash{head.Hash()}, commit.ParentHashes)
+}
+
+func (s *WorktreeSuite) TestCherryPickEmpty() {
+	r, w, _, _ := s.setupMergeBranches(
+		map[string][]byte{"foo": []byte("foo\n")},
+		map[string][]byte{"bar": []byte("bar\n")},
+		nil,
+	)
+
+	head, err := r.Head()
+	s.NoError(err)
+
+	_, err = w.CherryPick(head.Hash(), &CherryPickOptions{Committer: defaultSignature()})
+	s.ErrorIs(err, ErrEmptyCommit)
+
+	ref, err := r.Head()
+	s.NoError(err)
+	s.Equal(head.Hash(), ref.Hash())
+
+	res, err := w.CherryPick(head.Hash(), &CherryPickOptions{Committer: defaultSignature(), AllowEmpty: true})
+	s.NoError(err)
+
+	commit, err := r.CommitObject(res.Commit)
+	s.NoError(err)
+	s.Equal([]plumbing.Hash{head.Hash()}, commit.ParentHashes)
+}
+
+func (s *WorktreeSuite) TestCherryPickWorktreeNotClean() {
+	_, w, fs, other := s.setupMergeBranches(
+		map[string][]byte{"foo": []byte("foo\n")},
+		nil,
+		map[string][]byte{"bar": []byte("bar\n")},
+	)
+
+	s.NoError(util.WriteFile(fs, "foo", []byte("changed\n"), 0644))
+
+	_, err := w.CherryPick(other, &CherryPickOptions{Committer: defaultSignature()})
+	s.ErrorIs(err, ErrWorktreeNotClean)
+}
+
+func TestCherryPickOriginMessage(t *testing.T) {
+	t.Parallel()
+
+	h := plumbing.NewHash("6ecf0ef2c2dffb796033e5a02219af86ec6584e5")
+	origin := "(cherry picked from commit " + h.String() + ")\n"
+
+	for _, tc := range []struct {
+		msg, expected string
+	}{
+		{"foo", "foo\n\n" + origin},
+		{"foo\n\nbar\n", "foo\n\nbar\n\n" + origin},
+		{"foo\n\nSigned-off-by: foo <foo@foo.foo>\n", "foo\n\nSigned-off-by: foo <foo@foo.foo>\n" + origin},
+		{"Fix: foo\n", "Fix: foo\n\n" + origin},
+		{"foo\n\nbar: baz qux\nnot a trailer\n", "foo\n\nbar: baz qux\nnot a trailer\n\n" + origin},
+	} {
+		assert.Equal(t, tc.expected, cherryPickOriginMessage(tc.msg, h), tc.msg)
+	}
+}
@@ -0,0 +1,153 @@
+package git
+
+import (
+	"io"
+
+	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/go-git/go-git/v6/plumbing/object"
+	"github.com/go-git/go-git/v6/plumbing/object/commitgraph"
+
+	"github.com/go-git/go-billy/v5"
+)
+
+// MergeBase returns the best common ancestors of the given commits, as
+// `git merge-base --all a b` does. When the repository has a commit-graph
+// file it is used to walk the history, without loading the commit objects
+// other than the ones returned.
+func (r *Repository) MergeBase(a, b plumbing.Hash) ([]*object.Commit, error) {
+	idx, closer, err := r.commitNodeIndex()
+	if err != nil {
+		return nil, err
+	}
+	defer closer.Close()
+
+	na, err := idx.Get(a)
+	if err != nil {
+		return nil, err
+	}
+
+	nb, err := idx.Get(b)
+	if err != nil {
+		return nil, err
+	}
+
+	nodes, err := commitgraph.MergeBase(na, nb)
+	if err != nil {
+		return nil, err
+	}
+
+	commits := make([]*object.Commit, 0, len(nodes))
+	for _, n := range nodes {
+		c, err := n.Commit()
+		if err != nil {
+			return nil, err
+		}
+
+		commits = append(commits, c)
+	}
+
+	return commits, nil
+}
+
+// commitNodeIndex returns the index used to walk the history of the
+// repository, backed by the commit-graph file of the storer if it has one
+// and the history isn't altered by replace references or grafts.
+// The returned closer must be called once the index is no longer used.
+func (r *Repository) commitNodeIndex() (commitgraph.CommitNodeIndex, io.Closer, error) {
+	type fsBased interface {
+		Filesystem() billy.Filesystem
+	}
+
+	s, err := r.objectStorer()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// As git does, the commit-graph file isn't used when the history is
+	// altered by replace references or grafts.
+	fs, ok := r.Storer.(fsBased)
+	if _, replaced := s.(*replaceObjectStorer); replaced || !ok {
+		return commitgraph.NewObjectCommitNodeIndex(s), noopCloser{}, nil
+	}
+
+	return commitgraph.Open(fs.Filesystem(), s)
+}
+
+// logCTime returns the history starting at the given commit in committer
+// time order, walked using the commit-graph file when available.
+func (r *Repository) logCTime(from plumbing.Hash) (object.CommitIter, error) {
+	if from == plumbing.ZeroHash {
+		head, err := r.Head()
+		if err != nil {
+			return nil, err
+		}
+
+		from = head.Hash()
+	}
+
+	c, err := r.CommitObject(from)
+	if err != nil {
+		return nil, err
+	}
+
+	idx, closer, err := r.commitNodeIndex()
+	if err != nil {
+		return nil, err
+	}
+
+	node, err := idx.Get(c.Hash)
+	if err != nil {
+		closer.Close()
+		return nil, err
+	}
+
+	return &commitNodeCommitIter{
+		iter:   commitgraph.NewCommitNodeIterCTime(node, nil, nil),
+		closer: closer,
+	}, nil
+}
+
+// commitNodeCommitIter implements object.CommitIter over a
+// commitgraph.CommitNodeIter, loading the commits as they are iterated.
+type commitNodeCommitIter struct {
+	iter   commitgraph.CommitNodeIter
+	closer io.Closer
+}
+
+func (i *commitNodeCommitIter) Next() (*object.Commit, error) {
+	n, err := i.iter.Next()
+	if err == io.EOF {
+		i.Close()
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return n.Commit()
+}
+
+func (i *commitNodeCommitIter) ForEach(cb func(*object.Commit) error) error {
+	defer i.Close()
+
+	return i.iter.ForEach(func(n commitgraph.CommitNode) error {
+		c, err := n.Commit()
+		if err != nil {
+			return err
+		}
+
+		return cb(c)
+	})
+}
+
+func (i *commitNodeCommitIter) Close() {
+	i.iter.Close()
+	if i.closer != nil {
+		i.closer.Close()
+		i.closer = nil
+	}
+}
+
+type noopCloser struct{}
+
+func (noopCloser) Close() error { return nil }
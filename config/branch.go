@@ -23,6 +23,9 @@ type Branch struct {
 	Remote string
 	// Merge is the local refspec for the branch
 	Merge plumbing.ReferenceName
+	// PushRemote is the name of the remote to push to, when it differs
+	// from Remote.
+	PushRemote string
 	// Rebase instead of merge when pulling. Valid values are
 	// "true" and "interactive".  "false" is undocumented and
 	// typically represented by the non-existence of this field
@@ -76,6 +79,12 @@ func (b *Branch) marshal() *format.Subsection {
 		b.raw.SetOption(mergeKey, string(b.Merge))
 	}
 
+	if b.PushRemote == "" {
+		b.raw.RemoveOption(pushRemoteKey)
+	} else {
+		b.raw.SetOption(pushRemoteKey, b.PushRemote)
+	}
+
 	if b.Rebase == "" {
 		b.raw.RemoveOption(rebaseKey)
 	} else {
@@ -109,6 +118,7 @@ func (b *Branch) unmarshal(s *format.Subsection) error {
 	b.Name = b.raw.Name
 	b.Remote = b.raw.Options.Get(remoteSection)
 	b.Merge = plumbing.ReferenceName(b.raw.Options.Get(mergeKey))
+	b.PushRemote = b.raw.Options.Get(pushRemoteKey)
 	b.Rebase = b.raw.Options.Get(rebaseKey)
 	b.Description = unquoteDescription(b.raw.Options.Get(descriptionKey))
 
@@ -84,3 +84,26 @@ func (b *BranchSuite) TestUnmarshal() {
 	b.Equal(plumbing.ReferenceName("refs/heads/branch-tracking-on-clone"), branch.Merge)
 	b.Equal("interactive", branch.Rebase)
 }
+
+func (b *BranchSuite) TestPushRemote() {
+	input := []byte(`[core]
+	bare = false
+[branch "main"]
+	remote = origin
+	merge = refs/heads/main
+	pushRemote = fork
+`)
+
+	cfg := NewConfig()
+	b.NoError(cfg.Unmarshal(input))
+	b.Equal("fork", cfg.Branches["main"].PushRemote)
+
+	actual, err := cfg.Marshal()
+	b.NoError(err)
+	b.Equal(string(input), string(actual))
+
+	cfg.Branches["main"].PushRemote = ""
+	actual, err = cfg.Marshal()
+	b.NoError(err)
+	b.NotContains(string(actual), "pushRemote")
+}
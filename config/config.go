@@ -37,11 +37,26 @@ type ConfigStorer interface {
 	SetConfig(*Config) error
 }
 
+// ConfigUpdater is a ConfigStorer able to update the config atomically: no
+// other update can happen between the config being read and written back.
+type ConfigUpdater interface {
+	ConfigStorer
+	// UpdateConfig reads the config and gives it to update, then writes it
+	// back, unless update returns an error, which is returned as is.
+	UpdateConfig(update func(*Config) error) error
+}
+
 var (
+	// ErrConfigLocked is returned by ConfigUpdater.UpdateConfig when the
+	// config is being updated by another process.
+	ErrConfigLocked          = errors.New("config is locked by another process")
 	ErrInvalid               = errors.New("config invalid key in remote or branch")
 	ErrRemoteConfigNotFound  = errors.New("remote config not found")
 	ErrRemoteConfigEmptyURL  = errors.New("remote config: empty URL")
 	ErrRemoteConfigEmptyName = errors.New("remote config: empty name")
+	// ErrInvalidCompressionLevel is returned when a compression level is
+	// out of the range of the zlib levels, -1 to 9.
+	ErrInvalidCompressionLevel = errors.New("config: invalid compression level")
 )
 
 // Scope defines the scope of a config file, such as local, global or system.
@@ -68,6 +83,25 @@ type Config struct {
 		CommentChar string
 		// RepositoryFormatVersion identifies the repository format and layout version.
 		RepositoryFormatVersion format.RepositoryFormatVersion
+		// AutoCRLF controls the conversion of the line endings of the files
+		// with no text attribute: "true" converts them to CRLF on checkout
+		// and back to LF when added, "input" only converts them to LF when
+		// added. The files are left untouched if empty or "false".
+		AutoCRLF string
+		// EOL is the line ending used on checkout for the text files when
+		// AutoCRLF is not set, "lf", "crlf" or "native", the default.
+		EOL string
+		// LooseCompression is the zlib compression level of the loose
+		// objects, from -1 (the zlib default) to 9. It's read from
+		// core.looseCompression, falling back to core.compression, and
+		// defaults to DefaultLooseCompression.
+		LooseCompression int
+		// Symlinks, if "false", makes the symbolic links be checked out as
+		// regular files containing the path they point to, for filesystems
+		// without support for them. It's set to "false" on init when the
+		// worktree doesn't support symbolic links. They are checked out as
+		// such when empty or "true".
+		Symlinks string
 	}
 
 	User struct {
@@ -96,6 +130,15 @@ type Config struct {
 		// compression.  The default is 10.  A value of 0 turns off
 		// delta compression entirely.
 		Window uint
+		// Depth controls the maximum length of the delta chains. The
+		// default is 50. A value of 0 turns off delta compression
+		// entirely.
+		Depth uint
+		// Compression is the zlib compression level of the objects in the
+		// packs, from -1 (the zlib default) to 9. It's read from
+		// pack.compression, falling back to core.compression, and defaults
+		// to DefaultPackCompression.
+		Compression int
 	}
 
 	Init struct {
@@ -116,6 +159,28 @@ type Config struct {
 		ObjectFormat format.ObjectFormat
 	}
 
+	Push struct {
+		// Default defines the destination of a push when no refspec is
+		// given, one of nothing, current, upstream, simple or matching. If
+		// empty, simple is assumed.
+		Default string
+	}
+
+	Receive struct {
+		// DenyDeletes rejects the deletion of branches by a push.
+		DenyDeletes bool
+		// DenyNonFastForwards rejects the updates of branches by a push
+		// to a commit not descending from the current one, as forced
+		// pushes do.
+		DenyNonFastForwards bool
+		// DenyCurrentBranch defines how a push updating the branch
+		// checked out in a non-bare repository is handled: refuse,
+		// warn or ignore, "true" and "false" being refuse and ignore.
+		// If empty, refuse is assumed. updateInstead isn't supported
+		// and is handled as refuse.
+		DenyCurrentBranch string
+	}
+
 	Protocol struct {
 		// Version sets the preferred version for the Git wire protocol.
 		// When set, clients will attempt to communicate with a server
@@ -159,6 +224,9 @@ func NewConfig() *Config {
 	}
 
 	config.Pack.Window = DefaultPackWindow
+	config.Pack.Depth = DefaultPackDepth
+	config.Pack.Compression = DefaultPackCompression
+	config.Core.LooseCompression = DefaultLooseCompression
 	config.Protocol.Version = DefaultProtocolVersion
 
 	return config
@@ -257,6 +325,12 @@ func (c *Config) Validate() error {
 		}
 	}
 
+	for _, level := range []int{c.Core.LooseCompression, c.Pack.Compression} {
+		if err := validateCompressionLevel(level); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
@@ -273,6 +347,8 @@ const (
 	urlSection                 = "url"
 	extensionsSection          = "extensions"
 	protocolSection            = "protocol"
+	pushSection                = "push"
+	receiveSection             = "receive"
 	fetchKey                   = "fetch"
 	urlKey                     = "url"
 	pushurlKey                 = "pushurl"
@@ -280,20 +356,45 @@ const (
 	worktreeKey                = "worktree"
 	commentCharKey             = "commentChar"
 	windowKey                  = "window"
+	depthKey                   = "depth"
 	mergeKey                   = "merge"
 	rebaseKey                  = "rebase"
 	nameKey                    = "name"
 	emailKey                   = "email"
 	descriptionKey             = "description"
 	defaultBranchKey           = "defaultBranch"
+	defaultKey                 = "default"
+	pushRemoteKey              = "pushRemote"
 	repositoryFormatVersionKey = "repositoryformatversion"
 	objectFormat               = "objectformat"
 	mirrorKey                  = "mirror"
+	promisorKey                = "promisor"
+	partialCloneFilterKey      = "partialclonefilter"
 	versionKey                 = "version"
+	denyDeletesKey             = "denyDeletes"
+	denyNonFastForwardsKey     = "denyNonFastForwards"
+	denyCurrentBranchKey       = "denyCurrentBranch"
+	autoCRLFKey                = "autocrlf"
+	eolKey                     = "eol"
+	compressionKey             = "compression"
+	looseCompressionKey        = "looseCompression"
+	symlinksKey                = "symlinks"
 
 	// DefaultPackWindow holds the number of previous objects used to
 	// generate deltas. The value 10 is the same used by git command.
 	DefaultPackWindow = uint(10)
+
+	// DefaultPackDepth holds the maximum length of the delta chains. The
+	// value 50 is the same used by git command.
+	DefaultPackDepth = uint(50)
+
+	// DefaultLooseCompression is the zlib compression level of the loose
+	// objects, 1 (best speed) as git uses.
+	DefaultLooseCompression = 1
+
+	// DefaultPackCompression is the zlib compression level of the objects in
+	// the packs, -1 (the zlib default) as git uses.
+	DefaultPackCompression = -1
 )
 
 // Unmarshal parses a git-config file and stores it.
@@ -307,8 +408,14 @@ func (c *Config) Unmarshal(b []byte) error {
 	}
 
 	c.unmarshalCore()
+	if err := c.unmarshalExtensions(); err != nil {
+		return err
+	}
+
 	c.unmarshalUser()
 	c.unmarshalInit()
+	c.unmarshalPush()
+	c.unmarshalReceive()
 	if err := c.unmarshalPack(); err != nil {
 		return err
 	}
@@ -337,6 +444,30 @@ func (c *Config) unmarshalCore() {
 
 	c.Core.Worktree = s.Options.Get(worktreeKey)
 	c.Core.CommentChar = s.Options.Get(commentCharKey)
+	c.Core.RepositoryFormatVersion = format.RepositoryFormatVersion(s.Options.Get(repositoryFormatVersionKey))
+	c.Core.AutoCRLF = s.Options.Get(autoCRLFKey)
+	c.Core.EOL = s.Options.Get(eolKey)
+	c.Core.Symlinks = s.Options.Get(symlinksKey)
+}
+
+func (c *Config) unmarshalExtensions() error {
+	// Extensions are only supported on Version 1, therefore
+	// ignore them otherwise.
+	if c.Core.RepositoryFormatVersion != format.Version_1 {
+		return nil
+	}
+
+	s := c.Raw.Section(extensionsSection)
+	switch of := s.Options.Get(objectFormat); of {
+	case "", format.SHA1.String():
+		c.Extensions.ObjectFormat = format.SHA1
+	case format.SHA256.String():
+		c.Extensions.ObjectFormat = format.SHA256
+	default:
+		return fmt.Errorf("%w: %q", format.ErrInvalidObjectFormat, of)
+	}
+
+	return nil
 }
 
 func (c *Config) unmarshalUser() {
@@ -365,6 +496,66 @@ func (c *Config) unmarshalPack() error {
 		}
 		c.Pack.Window = uint(winUint)
 	}
+
+	depth := s.Options.Get(depthKey)
+	if depth == "" {
+		c.Pack.Depth = DefaultPackDepth
+	} else {
+		depthUint, err := strconv.ParseUint(depth, 10, 32)
+		if err != nil {
+			return err
+		}
+		c.Pack.Depth = uint(depthUint)
+	}
+
+	var err error
+	c.Core.LooseCompression, c.Pack.Compression, err = c.compressionLevels()
+	return err
+}
+
+// compressionLevels returns the compression levels of the loose objects and
+// of the packs set in the raw config, as git reads them: core.compression
+// applies to both unless they are set by core.looseCompression and
+// pack.compression.
+func (c *Config) compressionLevels() (loose, pack int, err error) {
+	loose, pack = DefaultLooseCompression, DefaultPackCompression
+
+	core := c.Raw.Section(coreSection)
+	if v := core.Options.Get(compressionKey); v != "" {
+		if loose, err = parseCompressionLevel(v); err != nil {
+			return
+		}
+
+		pack = loose
+	}
+
+	if v := core.Options.Get(looseCompressionKey); v != "" {
+		if loose, err = parseCompressionLevel(v); err != nil {
+			return
+		}
+	}
+
+	if v := c.Raw.Section(packSection).Options.Get(compressionKey); v != "" {
+		pack, err = parseCompressionLevel(v)
+	}
+
+	return
+}
+
+func parseCompressionLevel(v string) (int, error) {
+	level, err := strconv.Atoi(v)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidCompressionLevel, v)
+	}
+
+	return level, validateCompressionLevel(level)
+}
+
+func validateCompressionLevel(level int) error {
+	if level < -1 || level > 9 {
+		return fmt.Errorf("%w: %d", ErrInvalidCompressionLevel, level)
+	}
+
 	return nil
 }
 
@@ -447,6 +638,18 @@ func (c *Config) unmarshalProtocol() error {
 	return nil
 }
 
+func (c *Config) unmarshalPush() {
+	s := c.Raw.Section(pushSection)
+	c.Push.Default = s.Options.Get(defaultKey)
+}
+
+func (c *Config) unmarshalReceive() {
+	s := c.Raw.Section(receiveSection)
+	c.Receive.DenyDeletes = s.Options.Get(denyDeletesKey) == "true"
+	c.Receive.DenyNonFastForwards = s.Options.Get(denyNonFastForwardsKey) == "true"
+	c.Receive.DenyCurrentBranch = s.Options.Get(denyCurrentBranchKey)
+}
+
 func (c *Config) unmarshalInit() {
 	s := c.Raw.Section(initSection)
 	c.Init.DefaultBranch = s.Options.Get(defaultBranchKey)
@@ -464,6 +667,8 @@ func (c *Config) Marshal() ([]byte, error) {
 	c.marshalURLs()
 	c.marshalProtocol()
 	c.marshalInit()
+	c.marshalPush()
+	c.marshalReceive()
 
 	buf := bytes.NewBuffer(nil)
 	if err := format.NewEncoder(buf).Encode(c.Raw); err != nil {
@@ -483,6 +688,24 @@ func (c *Config) marshalCore() {
 	if c.Core.Worktree != "" {
 		s.SetOption(worktreeKey, c.Core.Worktree)
 	}
+
+	if c.Core.AutoCRLF != "" {
+		s.SetOption(autoCRLFKey, c.Core.AutoCRLF)
+	}
+
+	if c.Core.EOL != "" {
+		s.SetOption(eolKey, c.Core.EOL)
+	}
+
+	if c.Core.Symlinks != "" {
+		s.SetOption(symlinksKey, c.Core.Symlinks)
+	}
+
+	// The level is only written when it isn't the one read from the config
+	// already, so core.compression is kept.
+	if loose, _, _ := c.compressionLevels(); c.Core.LooseCompression != loose {
+		s.SetOption(looseCompressionKey, strconv.Itoa(c.Core.LooseCompression))
+	}
 }
 
 func (c *Config) marshalExtensions() {
@@ -528,6 +751,13 @@ func (c *Config) marshalPack() {
 	if c.Pack.Window != DefaultPackWindow {
 		s.SetOption(windowKey, fmt.Sprintf("%d", c.Pack.Window))
 	}
+	if c.Pack.Depth != DefaultPackDepth {
+		s.SetOption(depthKey, fmt.Sprintf("%d", c.Pack.Depth))
+	}
+
+	if _, pack, _ := c.compressionLevels(); c.Pack.Compression != pack {
+		s.SetOption(compressionKey, strconv.Itoa(c.Pack.Compression))
+	}
 }
 
 func (c *Config) marshalRemotes() {
@@ -621,6 +851,28 @@ func (c *Config) marshalProtocol() {
 	}
 }
 
+func (c *Config) marshalPush() {
+	if c.Push.Default != "" {
+		s := c.Raw.Section(pushSection)
+		s.SetOption(defaultKey, c.Push.Default)
+	}
+}
+
+func (c *Config) marshalReceive() {
+	s := c.Raw.Section(receiveSection)
+	if c.Receive.DenyDeletes || s.HasOption(denyDeletesKey) {
+		s.SetOption(denyDeletesKey, fmt.Sprintf("%t", c.Receive.DenyDeletes))
+	}
+
+	if c.Receive.DenyNonFastForwards || s.HasOption(denyNonFastForwardsKey) {
+		s.SetOption(denyNonFastForwardsKey, fmt.Sprintf("%t", c.Receive.DenyNonFastForwards))
+	}
+
+	if c.Receive.DenyCurrentBranch != "" {
+		s.SetOption(denyCurrentBranchKey, c.Receive.DenyCurrentBranch)
+	}
+}
+
 func (c *Config) marshalInit() {
 	s := c.Raw.Section(initSection)
 	if c.Init.DefaultBranch != "" {
@@ -637,6 +889,12 @@ type RemoteConfig struct {
 	URLs []string
 	// Mirror indicates that the repository is a mirror of remote.
 	Mirror bool
+	// Promisor indicates that the repository is a partial clone of this
+	// remote, the objects omitted when cloning are fetched on demand from it.
+	Promisor bool
+	// PartialCloneFilter is the filter used when fetching from a promisor
+	// remote, e.g. "blob:none".
+	PartialCloneFilter string
 
 	// insteadOfRulesApplied have urls been modified
 	insteadOfRulesApplied bool
@@ -692,6 +950,8 @@ func (c *RemoteConfig) unmarshal(s *format.Subsection) error {
 	c.URLs = append(c.URLs, c.raw.Options.GetAll(pushurlKey)...)
 	c.Fetch = fetch
 	c.Mirror = c.raw.Options.Get(mirrorKey) == "true"
+	c.Promisor = c.raw.Options.Get(promisorKey) == "true"
+	c.PartialCloneFilter = c.raw.Options.Get(partialCloneFilterKey)
 
 	return nil
 }
@@ -728,9 +988,27 @@ func (c *RemoteConfig) marshal() *format.Subsection {
 		c.raw.SetOption(mirrorKey, strconv.FormatBool(c.Mirror))
 	}
 
+	if c.Promisor {
+		c.raw.SetOption(promisorKey, strconv.FormatBool(c.Promisor))
+	}
+
+	if c.PartialCloneFilter != "" {
+		c.raw.SetOption(partialCloneFilterKey, c.PartialCloneFilter)
+	}
+
 	return c.raw
 }
 
+// OriginalURLs returns the URLs of the remote as they are configured, before
+// applying the insteadOf rules of the config.
+func (c *RemoteConfig) OriginalURLs() []string {
+	if c.insteadOfRulesApplied {
+		return c.originalURLs
+	}
+
+	return c.URLs
+}
+
 func (c *RemoteConfig) IsFirstURLLocal() bool {
 	return url.IsLocalEndpoint(c.URLs[0])
 }
@@ -9,6 +9,7 @@ import (
 	"github.com/go-git/go-billy/v5/osfs"
 	"github.com/go-git/go-billy/v5/util"
 	"github.com/go-git/go-git/v6/plumbing"
+	format "github.com/go-git/go-git/v6/plumbing/format/config"
 	"github.com/go-git/go-git/v6/plumbing/protocol"
 	"github.com/stretchr/testify/suite"
 )
@@ -37,6 +38,7 @@ func (s *ConfigSuite) TestUnmarshal() {
 		email = richard@example.com
 [pack]
 		window = 20
+		depth = 30
 [remote "origin"]
 		url = git@github.com:mcuadros/go-git.git
 		fetch = +refs/heads/*:refs/remotes/origin/*
@@ -77,6 +79,7 @@ func (s *ConfigSuite) TestUnmarshal() {
 	s.Equal("Richard Roe", cfg.Committer.Name)
 	s.Equal("richard@example.com", cfg.Committer.Email)
 	s.Equal(uint(20), cfg.Pack.Window)
+	s.Equal(uint(30), cfg.Pack.Depth)
 	s.Len(cfg.Remotes, 4)
 	s.Equal("origin", cfg.Remotes["origin"].Name)
 	s.Equal([]string{"git@github.com:mcuadros/go-git.git"}, cfg.Remotes["origin"].URLs)
@@ -197,6 +200,11 @@ func (s *ConfigSuite) TestUnmarshalMarshal() {
 	url = git@github.com:mcuadros/go-git.git
 	fetch = +refs/heads/*:refs/remotes/origin/*
 	mirror = true
+[remote "partial"]
+	url = https://github.com/git-fixtures/basic.git
+	fetch = +refs/heads/*:refs/remotes/partial/*
+	promisor = true
+	partialclonefilter = blob:none
 [remote "win-local"]
 	url = "X:\\Git\\"
 [branch "master"]
@@ -348,6 +356,9 @@ func (s *ConfigSuite) TestRemoteConfigDefaultValues() {
 	s.Len(config.Submodules, 0)
 	s.NotNil(config.Raw)
 	s.Equal(DefaultPackWindow, config.Pack.Window)
+	s.Equal(DefaultPackDepth, config.Pack.Depth)
+	s.Equal(DefaultPackCompression, config.Pack.Compression)
+	s.Equal(DefaultLooseCompression, config.Core.LooseCompression)
 }
 
 func (s *ConfigSuite) TestLoadConfigLocalScope() {
@@ -420,3 +431,185 @@ func (s *ConfigSuite) TestUnmarshalRemotes() {
 	s.Equal("https://git.sr.ht/~mcepl/go-git", cfg.Remotes["origin"].URLs[0])
 	s.Equal("git@git.sr.ht:~mcepl/go-git.git", cfg.Remotes["origin"].URLs[1])
 }
+
+func (s *ConfigSuite) TestUnmarshalPromisorRemote() {
+	input := []byte(`[remote "origin"]
+	url = https://github.com/git-fixtures/basic.git
+	promisor = true
+	partialclonefilter = blob:limit=1k
+`)
+
+	cfg := NewConfig()
+	err := cfg.Unmarshal(input)
+	s.NoError(err)
+
+	s.True(cfg.Remotes["origin"].Promisor)
+	s.Equal("blob:limit=1k", cfg.Remotes["origin"].PartialCloneFilter)
+}
+
+func (s *ConfigSuite) TestUnmarshalObjectFormat() {
+	input := []byte(`[core]
+	repositoryformatversion = 1
+[extensions]
+	objectformat = sha256
+`)
+
+	cfg := NewConfig()
+	err := cfg.Unmarshal(input)
+	s.NoError(err)
+
+	s.Equal(format.Version_1, string(cfg.Core.RepositoryFormatVersion))
+	s.Equal(format.SHA256, cfg.Extensions.ObjectFormat)
+
+	output, err := cfg.Marshal()
+	s.NoError(err)
+	s.Contains(string(output), "objectformat = sha256")
+}
+
+func (s *ConfigSuite) TestUnmarshalObjectFormatIgnoredOnVersion0() {
+	input := []byte(`[extensions]
+	objectformat = sha256
+`)
+
+	cfg := NewConfig()
+	err := cfg.Unmarshal(input)
+	s.NoError(err)
+	s.Equal(format.SHA1, cfg.Extensions.ObjectFormat)
+}
+
+func (s *ConfigSuite) TestUnmarshalInvalidObjectFormat() {
+	input := []byte(`[core]
+	repositoryformatversion = 1
+[extensions]
+	objectformat = md5
+`)
+
+	cfg := NewConfig()
+	err := cfg.Unmarshal(input)
+	s.ErrorIs(err, format.ErrInvalidObjectFormat)
+}
+
+func (s *ConfigSuite) TestPushDefault() {
+	input := []byte(`[core]
+	bare = false
+[push]
+	default = current
+`)
+
+	cfg := NewConfig()
+	s.NoError(cfg.Unmarshal(input))
+	s.Equal("current", cfg.Push.Default)
+
+	actual, err := cfg.Marshal()
+	s.NoError(err)
+	s.Equal(string(input), string(actual))
+
+	cfg = NewConfig()
+	cfg.Push.Default = "upstream"
+	actual, err = cfg.Marshal()
+	s.NoError(err)
+	s.Contains(string(actual), "[push]\n\tdefault = upstream\n")
+}
+
+func (s *ConfigSuite) TestReceive() {
+	input := []byte(`[core]
+	bare = false
+[receive]
+	denyDeletes = true
+	denyNonFastForwards = false
+	denyCurrentBranch = warn
+`)
+
+	cfg := NewConfig()
+	s.NoError(cfg.Unmarshal(input))
+	s.True(cfg.Receive.DenyDeletes)
+	s.False(cfg.Receive.DenyNonFastForwards)
+	s.Equal("warn", cfg.Receive.DenyCurrentBranch)
+
+	actual, err := cfg.Marshal()
+	s.NoError(err)
+	s.Equal(string(input), string(actual))
+
+	cfg = NewConfig()
+	cfg.Receive.DenyNonFastForwards = true
+	actual, err = cfg.Marshal()
+	s.NoError(err)
+	s.Contains(string(actual), "[receive]\n\tdenyNonFastForwards = true\n")
+	s.NotContains(string(actual), "denyDeletes")
+}
+
+func (s *ConfigSuite) TestCoreLineEndings() {
+	input := []byte(`[core]
+	bare = false
+	autocrlf = input
+	eol = crlf
+	symlinks = false
+`)
+
+	cfg := NewConfig()
+	s.NoError(cfg.Unmarshal(input))
+	s.Equal("input", cfg.Core.AutoCRLF)
+	s.Equal("crlf", cfg.Core.EOL)
+	s.Equal("false", cfg.Core.Symlinks)
+
+	actual, err := cfg.Marshal()
+	s.NoError(err)
+	s.Equal(string(input), string(actual))
+}
+
+func (s *ConfigSuite) TestCompression() {
+	for _, tc := range []struct {
+		input       string
+		loose, pack int
+	}{
+		{"", DefaultLooseCompression, DefaultPackCompression},
+		{"[core]\n\tcompression = 9\n", 9, 9},
+		{"[core]\n\tcompression = 9\n\tlooseCompression = 0\n", 0, 9},
+		{"[core]\n\tcompression = 0\n[pack]\n\tcompression = 5\n", 0, 5},
+		{"[core]\n\tlooseCompression = -1\n", -1, DefaultPackCompression},
+	} {
+		cfg := NewConfig()
+		s.Require().NoError(cfg.Unmarshal([]byte(tc.input)), tc.input)
+		s.Equal(tc.loose, cfg.Core.LooseCompression, tc.input)
+		s.Equal(tc.pack, cfg.Pack.Compression, tc.input)
+	}
+
+	input := []byte(`[core]
+	bare = false
+	compression = 9
+`)
+
+	cfg := NewConfig()
+	s.NoError(cfg.Unmarshal(input))
+
+	actual, err := cfg.Marshal()
+	s.NoError(err)
+	s.Equal(string(input), string(actual))
+
+	cfg.Core.LooseCompression = 1
+	cfg.Pack.Compression = 0
+	actual, err = cfg.Marshal()
+	s.NoError(err)
+	s.Equal(`[core]
+	bare = false
+	compression = 9
+	looseCompression = 1
+[pack]
+	compression = 0
+`, string(actual))
+}
+
+func (s *ConfigSuite) TestInvalidCompression() {
+	for _, input := range []string{
+		"[core]\n\tcompression = 10\n",
+		"[core]\n\tlooseCompression = -2\n",
+		"[pack]\n\tcompression = best\n",
+	} {
+		err := NewConfig().Unmarshal([]byte(input))
+		s.ErrorIs(err, ErrInvalidCompressionLevel, input)
+	}
+
+	cfg := NewConfig()
+	cfg.Pack.Compression = 10
+	s.ErrorIs(cfg.Validate(), ErrInvalidCompressionLevel)
+}
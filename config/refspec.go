@@ -2,6 +2,7 @@ package config
 
 import (
 	"errors"
+	"fmt"
 	"strings"
 
 	"github.com/go-git/go-git/v6/plumbing"
@@ -11,11 +12,13 @@ const (
 	refSpecWildcard  = "*"
 	refSpecForce     = "+"
 	refSpecSeparator = ":"
+	refSpecNegative  = "^"
 )
 
 var (
 	ErrRefSpecMalformedSeparator = errors.New("malformed refspec, separators are wrong")
 	ErrRefSpecMalformedWildcard  = errors.New("malformed refspec, mismatched number of wildcards")
+	ErrRefSpecMalformedNegative  = errors.New("malformed refspec, negative refspecs must be a single pattern")
 )
 
 // RefSpec is a mapping from local branches to remote references.
@@ -25,12 +28,34 @@ var (
 // reference even if it isn’t a fast-forward.
 // eg.: "+refs/heads/*:refs/remotes/origin/*"
 //
+// A refspec starting with ^ is a negative refspec, it has no destination and
+// excludes the references it matches from the ones matched by the other
+// refspecs. eg.: "^refs/heads/wip/*"
+//
 // https://git-scm.com/book/en/v2/Git-Internals-The-Refspec
 type RefSpec string
 
-// Validate validates the RefSpec
+// Validate validates the RefSpec, the errors returned include the RefSpec.
 func (s RefSpec) Validate() error {
+	if err := s.validate(); err != nil {
+		return fmt.Errorf("%w: %q", err, string(s))
+	}
+
+	return nil
+}
+
+func (s RefSpec) validate() error {
 	spec := string(s)
+	if s.IsNegative() {
+		src := spec[1:]
+		if src == "" || strings.HasPrefix(src, refSpecForce) || strings.Contains(src, refSpecSeparator) ||
+			strings.Count(src, refSpecWildcard) > 1 || plumbing.IsHash(src) {
+			return ErrRefSpecMalformedNegative
+		}
+
+		return nil
+	}
+
 	if strings.Count(spec, refSpecSeparator) != 1 {
 		return ErrRefSpecMalformedSeparator
 	}
@@ -49,6 +74,11 @@ func (s RefSpec) Validate() error {
 	return ErrRefSpecMalformedWildcard
 }
 
+// IsNegative returns true if the RefSpec excludes the references it matches.
+func (s RefSpec) IsNegative() bool {
+	return len(s) > 0 && s[0] == refSpecNegative[0]
+}
+
 // IsForceUpdate returns if update is allowed in non fast-forward merges.
 func (s RefSpec) IsForceUpdate() bool {
 	return s[0] == refSpecForce[0]
@@ -68,6 +98,10 @@ func (s RefSpec) IsExactSHA1() bool {
 func (s RefSpec) Src() string {
 	spec := string(s)
 
+	if s.IsNegative() {
+		return spec[1:]
+	}
+
 	var start int
 	if s.IsForceUpdate() {
 		start = 1
@@ -136,20 +170,56 @@ func (s RefSpec) Reverse() RefSpec {
 	spec := string(s)
 	separator := strings.Index(spec, refSpecSeparator)
 
-	return RefSpec(spec[separator+1:] + refSpecSeparator + spec[:separator])
+	var force string
+	if s.IsForceUpdate() {
+		force = refSpecForce
+	}
+
+	return RefSpec(force + spec[separator+1:] + refSpecSeparator + s.Src())
 }
 
 func (s RefSpec) String() string {
 	return string(s)
 }
 
-// MatchAny returns true if any of the RefSpec match with the given ReferenceName.
+// MatchAny returns true if any of the RefSpec match with the given
+// ReferenceName, and it is not excluded by a negative RefSpec.
 func MatchAny(l []RefSpec, n plumbing.ReferenceName) bool {
+	var matched bool
 	for _, r := range l {
 		if r.Match(n) {
+			if r.IsNegative() {
+				return false
+			}
+
+			matched = true
+		}
+	}
+
+	return matched
+}
+
+// IsExcluded returns true if any negative RefSpec of the list matches with
+// the given ReferenceName.
+func IsExcluded(l []RefSpec, n plumbing.ReferenceName) bool {
+	for _, r := range l {
+		if r.IsNegative() && r.Match(n) {
 			return true
 		}
 	}
 
 	return false
 }
+
+// SplitNegative splits the list into its positive and negative RefSpecs.
+func SplitNegative(l []RefSpec) (positive, negative []RefSpec) {
+	for _, r := range l {
+		if r.IsNegative() {
+			negative = append(negative, r)
+		} else {
+			positive = append(positive, r)
+		}
+	}
+
+	return positive, negative
+}
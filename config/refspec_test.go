@@ -46,6 +46,36 @@ func (s *RefSpecSuite) TestRefSpecIsValid() {
 
 	spec = RefSpec("12039e008f9a4e3394f3f94f8ea897785cb09448:refs/heads/*")
 	s.ErrorIs(spec.Validate(), ErrRefSpecMalformedWildcard)
+
+	spec = RefSpec("^refs/heads/wip/*")
+	s.NoError(spec.Validate())
+
+	spec = RefSpec("^refs/heads/love+hate")
+	s.NoError(spec.Validate())
+
+	for _, spec := range []RefSpec{
+		"^refs/heads/*:refs/remotes/origin/*",
+		"^+refs/heads/foo",
+		"^refs/heads/*/*",
+		"^12039e008f9a4e3394f3f94f8ea897785cb09448",
+		"^",
+	} {
+		err := spec.Validate()
+		s.ErrorIs(err, ErrRefSpecMalformedNegative, spec)
+		s.ErrorContains(err, string(spec))
+	}
+}
+
+func (s *RefSpecSuite) TestRefSpecNegative() {
+	spec := RefSpec("^refs/heads/wip/*")
+	s.True(spec.IsNegative())
+	s.False(spec.IsForceUpdate())
+	s.Equal("refs/heads/wip/*", spec.Src())
+	s.True(spec.Match(plumbing.ReferenceName("refs/heads/wip/foo")))
+	s.False(spec.Match(plumbing.ReferenceName("refs/heads/master")))
+
+	spec = RefSpec("+refs/heads/*:refs/remotes/origin/*")
+	s.False(spec.IsNegative())
 }
 
 func (s *RefSpecSuite) TestRefSpecIsForceUpdate() {
@@ -184,6 +214,9 @@ func (s *RefSpecSuite) TestRefSpecDstBlob() {
 func (s *RefSpecSuite) TestRefSpecReverse() {
 	spec := RefSpec("refs/heads/*:refs/remotes/origin/*")
 	s.Equal(RefSpec("refs/remotes/origin/*:refs/heads/*"), spec.Reverse())
+
+	spec = RefSpec("+refs/heads/*:refs/remotes/origin/*")
+	s.Equal(RefSpec("+refs/remotes/origin/*:refs/heads/*"), spec.Reverse())
 }
 
 func (s *RefSpecSuite) TestMatchAny() {
@@ -196,3 +229,21 @@ func (s *RefSpecSuite) TestMatchAny() {
 	s.True(MatchAny(specs, plumbing.ReferenceName("refs/heads/bar")))
 	s.False(MatchAny(specs, plumbing.ReferenceName("refs/heads/master")))
 }
+
+func (s *RefSpecSuite) TestMatchAnyNegative() {
+	specs := []RefSpec{
+		"+refs/heads/*:refs/remotes/origin/*",
+		"^refs/heads/wip/*",
+	}
+
+	s.True(MatchAny(specs, plumbing.ReferenceName("refs/heads/foo")))
+	s.False(MatchAny(specs, plumbing.ReferenceName("refs/heads/wip/foo")))
+	s.False(MatchAny(specs, plumbing.ReferenceName("refs/tags/foo")))
+
+	s.True(IsExcluded(specs, plumbing.ReferenceName("refs/heads/wip/foo")))
+	s.False(IsExcluded(specs, plumbing.ReferenceName("refs/heads/foo")))
+
+	positive, negative := SplitNegative(specs)
+	s.Equal([]RefSpec{"+refs/heads/*:refs/remotes/origin/*"}, positive)
+	s.Equal([]RefSpec{"^refs/heads/wip/*"}, negative)
+}
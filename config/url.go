@@ -18,6 +18,9 @@ type URL struct {
 	// Any URL that starts with this value will be rewritten to start, instead, with <base>.
 	// When more than one insteadOf strings match a given URL, the longest match is used.
 	InsteadOfs []string
+	// PushInsteadOfs are as InsteadOfs, but only rewrite the URLs pushed to,
+	// taking precedence over InsteadOfs.
+	PushInsteadOfs []string
 
 	// raw representation of the subsection, filled by marshal or unmarshal are
 	// called.
@@ -26,7 +29,7 @@ type URL struct {
 
 // Validate validates fields of branch
 func (b *URL) Validate() error {
-	if len(b.InsteadOfs) == 0 {
+	if len(b.InsteadOfs) == 0 && len(b.PushInsteadOfs) == 0 {
 		return errURLEmptyInsteadOf
 	}
 
@@ -34,7 +37,8 @@ func (b *URL) Validate() error {
 }
 
 const (
-	insteadOfKey = "insteadOf"
+	insteadOfKey     = "insteadOf"
+	pushInsteadOfKey = "pushInsteadOf"
 )
 
 func (u *URL) unmarshal(s *format.Subsection) error {
@@ -42,6 +46,7 @@ func (u *URL) unmarshal(s *format.Subsection) error {
 
 	u.Name = s.Name
 	u.InsteadOfs = u.raw.OptionAll(insteadOfKey)
+	u.PushInsteadOfs = u.raw.OptionAll(pushInsteadOfKey)
 	return nil
 }
 
@@ -52,39 +57,92 @@ func (u *URL) marshal() *format.Subsection {
 
 	u.raw.Name = u.Name
 	u.raw.SetOption(insteadOfKey, u.InsteadOfs...)
+	u.raw.SetOption(pushInsteadOfKey, u.PushInsteadOfs...)
 
 	return u.raw
 }
 
 func findLongestInsteadOfMatch(remoteURL string, urls map[string]*URL) *URL {
+	u, _ := findLongestMatch(remoteURL, urls, false)
+	return u
+}
+
+// findLongestMatch returns the rule with the longest insteadOf, or
+// pushInsteadOf if push is set, matching the given URL, along with the prefix
+// matched.
+func findLongestMatch(remoteURL string, urls map[string]*URL, push bool) (*URL, string) {
 	var longestMatch *URL
-	var longestMatchLength int
+	var matched string
 
 	for _, u := range urls {
-		for _, currentInsteadOf := range u.InsteadOfs {
-			if !strings.HasPrefix(remoteURL, currentInsteadOf) {
-				continue
-			}
-
-			lengthCurrentInsteadOf := len(currentInsteadOf)
-
-			// according to spec if there is more than one match, take the longest
-			if longestMatch == nil || longestMatchLength < lengthCurrentInsteadOf {
-				longestMatch = u
-				longestMatchLength = lengthCurrentInsteadOf
-			}
+		prefixes := u.InsteadOfs
+		if push {
+			prefixes = u.PushInsteadOfs
+		}
+
+		prefix := longestPrefix(remoteURL, prefixes)
+		if prefix == "" {
+			continue
+		}
+
+		// according to spec if there is more than one match, take the longest
+		if longestMatch == nil || len(matched) < len(prefix) ||
+			(len(matched) == len(prefix) && u.Name < longestMatch.Name) {
+			longestMatch = u
+			matched = prefix
 		}
 	}
 
-	return longestMatch
+	return longestMatch, matched
 }
 
+// longestPrefix returns the longest of the given prefixes of url, or an empty
+// string if there is none.
+func longestPrefix(url string, prefixes []string) string {
+	var longest string
+	for _, p := range prefixes {
+		if strings.HasPrefix(url, p) && len(p) > len(longest) {
+			longest = p
+		}
+	}
+
+	return longest
+}
+
+// ApplyInsteadOf rewrites the given URL with the longest of the InsteadOfs
+// it starts with, returning it as is if there is none.
 func (u *URL) ApplyInsteadOf(url string) string {
-	for _, j := range u.InsteadOfs {
-		if strings.HasPrefix(url, j) {
-			return u.Name + url[len(j):]
+	if prefix := longestPrefix(url, u.InsteadOfs); prefix != "" {
+		return u.Name + url[len(prefix):]
+	}
+
+	return url
+}
+
+// ApplyPushInsteadOf rewrites the given URL with the longest of the
+// PushInsteadOfs it starts with, returning it as is if there is none.
+func (u *URL) ApplyPushInsteadOf(url string) string {
+	if prefix := longestPrefix(url, u.PushInsteadOfs); prefix != "" {
+		return u.Name + url[len(prefix):]
+	}
+
+	return url
+}
+
+// RewriteURL returns the given URL rewritten by the rule of URLs with the
+// longest insteadOf matching it, as git does. When push is set, the
+// pushInsteadOf rules are tried first. The URL is returned as is if no rule
+// matches.
+func (c *Config) RewriteURL(url string, push bool) string {
+	if push {
+		if u, prefix := findLongestMatch(url, c.URLs, true); u != nil {
+			return u.Name + url[len(prefix):]
 		}
 	}
 
+	if u, prefix := findLongestMatch(url, c.URLs, false); u != nil {
+		return u.Name + url[len(prefix):]
+	}
+
 	return url
 }
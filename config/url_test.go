@@ -139,3 +139,54 @@ func (b *URLSuite) TestFindLongestInsteadOfMatch() {
 
 	b.Equal("ssh://somethingelse.com", longestUrl.Name)
 }
+
+func (b *URLSuite) TestApplyInsteadOfLongestPrefix() {
+	urlRule := URL{
+		Name:       "ssh://github.com",
+		InsteadOfs: []string{"http://github.com", "http://github.com/foo"},
+	}
+
+	b.Equal("ssh://github.com/bar", urlRule.ApplyInsteadOf("http://github.com/foo/bar"))
+}
+
+func (b *URLSuite) TestUnmarshalPushInsteadOf() {
+	input := []byte(`[core]
+	bare = false
+[url "ssh://git@github.com/"]
+	pushInsteadOf = https://github.com/
+`)
+
+	cfg := NewConfig()
+	err := cfg.Unmarshal(input)
+	b.Nil(err)
+	url := cfg.URLs["ssh://git@github.com/"]
+	b.Empty(url.InsteadOfs)
+	b.Equal([]string{"https://github.com/"}, url.PushInsteadOfs)
+	b.Equal("ssh://git@github.com/foo", url.ApplyPushInsteadOf("https://github.com/foo"))
+	b.Equal("https://github.com/foo", url.ApplyInsteadOf("https://github.com/foo"))
+
+	output, err := cfg.Marshal()
+	b.Nil(err)
+	b.Equal(string(input), string(output))
+}
+
+func (b *URLSuite) TestRewriteURL() {
+	cfg := NewConfig()
+	cfg.URLs["ssh://short.com/"] = &URL{
+		Name:       "ssh://short.com/",
+		InsteadOfs: []string{"https://example.com/"},
+	}
+	cfg.URLs["ssh://long.com/"] = &URL{
+		Name:       "ssh://long.com/",
+		InsteadOfs: []string{"https://example.com/org/"},
+	}
+	cfg.URLs["ssh://push.com/"] = &URL{
+		Name:           "ssh://push.com/",
+		PushInsteadOfs: []string{"https://example.com/"},
+	}
+
+	b.Equal("ssh://long.com/repo", cfg.RewriteURL("https://example.com/org/repo", false))
+	b.Equal("ssh://short.com/other/repo", cfg.RewriteURL("https://example.com/other/repo", false))
+	b.Equal("ssh://push.com/org/repo", cfg.RewriteURL("https://example.com/org/repo", true))
+	b.Equal("https://other.com/repo", cfg.RewriteURL("https://other.com/repo", true))
+}
@@ -0,0 +1,369 @@
+package git
+
+import (
+	"errors"
+	"fmt"
+	"path"
+
+	"github.com/emirpasic/gods/trees/binaryheap"
+
+	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/go-git/go-git/v6/plumbing/object"
+	"github.com/go-git/go-git/v6/plumbing/storer"
+)
+
+// ErrDescribeNoTags is returned by Describe when no tag is reachable from the
+// commit and Always is not used.
+var ErrDescribeNoTags = errors.New("describe: no tags can describe the commit")
+
+// describeCandidates is the maximum number of tags considered, as the
+// default of `git describe --candidates`.
+const describeCandidates = 10
+
+// describeName is the tag chosen to name a commit.
+type describeName struct {
+	name      string
+	annotated bool
+	tag       *object.Tag
+}
+
+// describeCandidate is a tag found while walking the history of the commit
+// being described.
+type describeCandidate struct {
+	name *describeName
+	// depth is the number of commits walked not reachable from the tag.
+	depth int
+	// flag marks the commits reachable from the tag.
+	flag uint
+}
+
+// describeItem is a commit queued in the walk, seq keeps the walk stable for
+// commits with the same date.
+type describeItem struct {
+	c   *object.Commit
+	seq int
+}
+
+// Describe returns a name for the commit based on the nearest tag reachable
+// from it, as `git describe` does. The name is the tag itself when it points
+// to the commit, otherwise it is "<tag>-<n>-g<hash>", where n is the number of
+// commits since the tag and hash is the abbreviated hash of the commit.
+//
+// Only annotated tags are used unless Tags is given. The nearest tag is the
+// one with the fewest commits between it and the commit, ties are broken in
+// favour of the tag found first when walking the history from the most recent
+// commit. When no tag is reachable ErrDescribeNoTags is returned, unless
+// Always is given.
+func (r *Repository) Describe(commit plumbing.Hash, opts *DescribeOptions) (string, error) {
+	if opts == nil {
+		opts = &DescribeOptions{}
+	}
+
+	if err := opts.Validate(); err != nil {
+		return "", err
+	}
+
+	c, err := r.CommitObject(commit)
+	if err != nil {
+		return "", err
+	}
+
+	names, err := r.describeNames(opts)
+	if err != nil {
+		return "", err
+	}
+
+	dirty, err := r.describeDirty(opts)
+	if err != nil {
+		return "", err
+	}
+
+	if n, ok := names[c.Hash]; ok {
+		return n.name + dirty, nil
+	}
+
+	best, depth, err := describeWalk(c, names)
+	if err != nil {
+		return "", err
+	}
+
+	abbrev := r.abbreviateHash(c.Hash, opts.Abbrev)
+	if best == nil {
+		if !opts.Always {
+			return "", ErrDescribeNoTags
+		}
+
+		return abbrev + dirty, nil
+	}
+
+	return fmt.Sprintf("%s-%d-g%s%s", best.name.name, depth, abbrev, dirty), nil
+}
+
+// describeNames returns the tags usable by Describe, by the commit they point
+// to. When several tags point to the same commit, the annotated ones are
+// preferred, and among them the most recent one.
+func (r *Repository) describeNames(opts *DescribeOptions) (map[plumbing.Hash]*describeName, error) {
+	refs, err := r.Tags()
+	if err != nil {
+		return nil, err
+	}
+
+	names := make(map[plumbing.Hash]*describeName)
+	err = refs.ForEach(func(ref *plumbing.Reference) error {
+		name := ref.Name().Short()
+		if !describeMatch(name, opts) {
+			return nil
+		}
+
+		n := &describeName{name: name}
+		hash := ref.Hash()
+
+		tag, err := r.TagObject(hash)
+		switch err {
+		case nil:
+			n.annotated, n.tag = true, tag
+			if hash, err = describePeel(r.Storer, tag); err != nil {
+				return err
+			}
+		case plumbing.ErrObjectNotFound:
+			if !opts.Tags {
+				return nil
+			}
+		default:
+			return err
+		}
+
+		if hash.IsZero() {
+			return nil
+		}
+
+		if prev, ok := names[hash]; !ok || n.replaces(prev) {
+			names[hash] = n
+		}
+
+		return nil
+	})
+
+	return names, err
+}
+
+// replaces returns whether n is a better name than prev for their commit.
+func (n *describeName) replaces(prev *describeName) bool {
+	if n.annotated != prev.annotated {
+		return n.annotated
+	}
+
+	if n.annotated && !n.tag.Tagger.When.Equal(prev.tag.Tagger.When) {
+		return n.tag.Tagger.When.After(prev.tag.Tagger.When)
+	}
+
+	// Names are compared only to keep the result stable.
+	return n.name < prev.name
+}
+
+// describePeel returns the commit the tag points to, following the tags of
+// tags, or the zero hash if it doesn't point to a commit.
+func describePeel(s storer.EncodedObjectStorer, tag *object.Tag) (plumbing.Hash, error) {
+	for tag.TargetType == plumbing.TagObject {
+		var err error
+		if tag, err = object.GetTag(s, tag.Target); err != nil {
+			return plumbing.ZeroHash, err
+		}
+	}
+
+	if tag.TargetType != plumbing.CommitObject {
+		return plumbing.ZeroHash, nil
+	}
+
+	return tag.Target, nil
+}
+
+func describeMatch(name string, opts *DescribeOptions) bool {
+	for _, pattern := range opts.Exclude {
+		if ok, _ := path.Match(pattern, name); ok {
+			return false
+		}
+	}
+
+	if len(opts.Match) == 0 {
+		return true
+	}
+
+	for _, pattern := range opts.Match {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// describeWalk finds the nearest tag reachable from c, and the number of
+// commits since it, the same way git does: the history is walked by commit
+// date, keeping up to describeCandidates tags, and each commit walked is
+// counted for every candidate not known to reach it.
+func describeWalk(c *object.Commit, names map[plumbing.Hash]*describeName) (*describeCandidate, int, error) {
+	var seq int
+	queue := binaryheap.NewWith(func(a, b interface{}) int {
+		ia, ib := a.(*describeItem), b.(*describeItem)
+		if !ia.c.Committer.When.Equal(ib.c.Committer.When) {
+			if ia.c.Committer.When.After(ib.c.Committer.When) {
+				return -1
+			}
+
+			return 1
+		}
+
+		return ia.seq - ib.seq
+	})
+
+	push := func(c *object.Commit) {
+		queue.Push(&describeItem{c: c, seq: seq})
+		seq++
+	}
+
+	seen := map[plumbing.Hash]bool{c.Hash: true}
+	flags := map[plumbing.Hash]uint{}
+	push(c)
+
+	var candidates []*describeCandidate
+	var walked int
+	for !queue.Empty() {
+		v, _ := queue.Pop()
+		c := v.(*describeItem).c
+		walked++
+
+		if n, ok := names[c.Hash]; ok {
+			if len(candidates) == describeCandidates {
+				// Put it back, it is walked by describeDepth.
+				push(c)
+				break
+			}
+
+			t := &describeCandidate{name: n, depth: walked - 1, flag: 1 << len(candidates)}
+			candidates = append(candidates, t)
+			flags[c.Hash] |= t.flag
+		}
+
+		for _, t := range candidates {
+			if flags[c.Hash]&t.flag == 0 {
+				t.depth++
+			}
+		}
+
+		if err := describeParents(c, seen, flags, push); err != nil {
+			return nil, 0, err
+		}
+	}
+
+	if len(candidates) == 0 {
+		return nil, 0, nil
+	}
+
+	// The candidates are in the order they were found, so a stable choice of
+	// the minimum depth breaks ties in favour of the first found.
+	best := candidates[0]
+	for _, t := range candidates[1:] {
+		if t.depth < best.depth {
+			best = t
+		}
+	}
+
+	depth, err := describeDepth(queue, seen, flags, best, push)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return best, best.depth + depth, nil
+}
+
+// describeDepth walks the rest of the queue, returning the number of commits
+// not reachable from the best candidate, until every queued commit is.
+func describeDepth(
+	queue *binaryheap.Heap,
+	seen map[plumbing.Hash]bool,
+	flags map[plumbing.Hash]uint,
+	best *describeCandidate,
+	push func(*object.Commit),
+) (int, error) {
+	var depth int
+	for !queue.Empty() {
+		v, _ := queue.Pop()
+		c := v.(*describeItem).c
+
+		if flags[c.Hash]&best.flag != 0 {
+			if describeAllFlagged(queue, flags, best.flag) {
+				break
+			}
+		} else {
+			depth++
+		}
+
+		if err := describeParents(c, seen, flags, push); err != nil {
+			return 0, err
+		}
+	}
+
+	return depth, nil
+}
+
+// describeParents queues the parents of c not seen yet, passing them the
+// flags of c.
+func describeParents(c *object.Commit, seen map[plumbing.Hash]bool, flags map[plumbing.Hash]uint, push func(*object.Commit)) error {
+	return c.Parents().ForEach(func(p *object.Commit) error {
+		if !seen[p.Hash] {
+			seen[p.Hash] = true
+			push(p)
+		}
+
+		flags[p.Hash] |= flags[c.Hash]
+		return nil
+	})
+}
+
+func describeAllFlagged(queue *binaryheap.Heap, flags map[plumbing.Hash]uint, flag uint) bool {
+	for _, v := range queue.Values() {
+		if flags[v.(*describeItem).c.Hash]&flag == 0 {
+			return false
+		}
+	}
+
+	return true
+}
+
+// describeDirty returns the Dirty suffix of the options if the worktree has
+// local changes, or an empty string.
+func (r *Repository) describeDirty(opts *DescribeOptions) (string, error) {
+	if opts.Dirty == "" {
+		return "", nil
+	}
+
+	w, err := r.Worktree()
+	if err != nil {
+		return "", err
+	}
+
+	s, err := w.Status()
+	if err != nil {
+		return "", err
+	}
+
+	if s.IsClean() {
+		return "", nil
+	}
+
+	return opts.Dirty, nil
+}
+
+// abbreviateHash returns the shortest prefix of the hash, of at least size
+// digits, not shared with any other object of the repository.
+func (r *Repository) abbreviateHash(h plumbing.Hash, size int) string {
+	s := h.String()
+	for ; size < len(s); size++ {
+		if len(r.resolveHashPrefix(s[:size])) <= 1 {
+			break
+		}
+	}
+
+	return s[:size]
+}
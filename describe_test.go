@@ -0,0 +1,175 @@
+package git
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/go-git/go-billy/v5/util"
+	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/go-git/go-git/v6/plumbing/object"
+	"github.com/go-git/go-git/v6/storage/memory"
+	"github.com/stretchr/testify/suite"
+)
+
+type DescribeSuite struct {
+	BaseSuite
+	r    *Repository
+	w    *Worktree
+	when time.Time
+}
+
+func TestDescribeSuite(t *testing.T) {
+	suite.Run(t, new(DescribeSuite))
+}
+
+func (s *DescribeSuite) SetupTest() {
+	var err error
+	s.r, err = Init(memory.NewStorage(), WithWorkTree(memfs.New()))
+	s.Require().NoError(err)
+	s.w, err = s.r.Worktree()
+	s.Require().NoError(err)
+	s.when = time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+}
+
+// commit creates an empty commit with the given parents, or on top of HEAD,
+// each one a minute after the previous one.
+func (s *DescribeSuite) commit(parents ...plumbing.Hash) plumbing.Hash {
+	s.when = s.when.Add(time.Minute)
+	h, err := s.w.Commit(fmt.Sprintf("commit at %s", s.when), &CommitOptions{
+		Author:            s.signature(),
+		Parents:           parents,
+		AllowEmptyCommits: true,
+	})
+	s.Require().NoError(err)
+	return h
+}
+
+func (s *DescribeSuite) signature() *object.Signature {
+	return &object.Signature{Name: "foo", Email: "foo@foo.foo", When: s.when}
+}
+
+func (s *DescribeSuite) tag(name string, h plumbing.Hash) {
+	_, err := s.r.CreateTag(name, h, &CreateTagOptions{Tagger: s.signature(), Message: name})
+	s.Require().NoError(err)
+}
+
+func (s *DescribeSuite) lightweightTag(name string, h plumbing.Hash) {
+	_, err := s.r.CreateTag(name, h, nil)
+	s.Require().NoError(err)
+}
+
+func (s *DescribeSuite) describe(h plumbing.Hash, opts *DescribeOptions) string {
+	d, err := s.r.Describe(h, opts)
+	s.Require().NoError(err)
+	return d
+}
+
+func (s *DescribeSuite) TestDescribe() {
+	c0 := s.commit()
+	s.tag("v1.0", c0)
+	s.commit()
+	c2 := s.commit()
+
+	s.Equal("v1.0", s.describe(c0, nil))
+	s.Equal("v1.0-2-g"+c2.String()[:7], s.describe(c2, nil))
+	s.Equal("v1.0-2-g"+c2.String()[:12], s.describe(c2, &DescribeOptions{Abbrev: 12}))
+}
+
+func (s *DescribeSuite) TestDescribeLightweightTags() {
+	c0 := s.commit()
+	s.tag("v1.0", c0)
+	c1 := s.commit()
+	s.lightweightTag("v1.1", c1)
+	c2 := s.commit()
+
+	s.Equal("v1.0-2-g"+c2.String()[:7], s.describe(c2, nil))
+	s.Equal("v1.1-1-g"+c2.String()[:7], s.describe(c2, &DescribeOptions{Tags: true}))
+	s.Equal("v1.1", s.describe(c1, &DescribeOptions{Tags: true}))
+}
+
+func (s *DescribeSuite) TestDescribeSameCommit() {
+	c0 := s.commit()
+	s.lightweightTag("a", c0)
+	s.tag("b", c0)
+	s.when = s.when.Add(time.Minute)
+	s.tag("c", c0)
+
+	// Annotated tags are preferred, the most recent one first.
+	s.Equal("c", s.describe(c0, &DescribeOptions{Tags: true}))
+	s.Equal("b", s.describe(c0, &DescribeOptions{Exclude: []string{"c"}}))
+}
+
+func (s *DescribeSuite) TestDescribeMatch() {
+	c0 := s.commit()
+	s.tag("v1.0", c0)
+	c1 := s.commit()
+	s.tag("release-1", c1)
+	c2 := s.commit()
+
+	hash := c2.String()[:7]
+	s.Equal("release-1-1-g"+hash, s.describe(c2, nil))
+	s.Equal("v1.0-2-g"+hash, s.describe(c2, &DescribeOptions{Match: []string{"v*"}}))
+	s.Equal("v1.0-2-g"+hash, s.describe(c2, &DescribeOptions{Exclude: []string{"release-*"}}))
+
+	_, err := s.r.Describe(c2, &DescribeOptions{Match: []string{"x*"}})
+	s.ErrorIs(err, ErrDescribeNoTags)
+
+	_, err = s.r.Describe(c2, &DescribeOptions{Match: []string{"["}})
+	s.Error(err)
+}
+
+func (s *DescribeSuite) TestDescribeNoTags() {
+	c0 := s.commit()
+	s.lightweightTag("v1.0", c0)
+	c1 := s.commit()
+
+	_, err := s.r.Describe(c1, nil)
+	s.ErrorIs(err, ErrDescribeNoTags)
+
+	s.Equal(c1.String()[:7], s.describe(c1, &DescribeOptions{Always: true}))
+}
+
+func (s *DescribeSuite) TestDescribeDirty() {
+	c0 := s.commit()
+	s.tag("v1.0", c0)
+
+	opts := &DescribeOptions{Dirty: "-dirty"}
+	s.Equal("v1.0", s.describe(c0, opts))
+
+	err := util.WriteFile(s.w.Filesystem, "foo", []byte("foo"), 0o644)
+	s.Require().NoError(err)
+	s.Equal("v1.0-dirty", s.describe(c0, opts))
+}
+
+func (s *DescribeSuite) TestDescribeNearest() {
+	c0 := s.commit()
+	m1 := s.commit(c0)
+	s1 := s.commit(c0)
+	m2 := s.commit(m1)
+	m3 := s.commit(m2)
+	merge := s.commit(m3, s1)
+
+	s.tag("side", s1)
+	s.tag("main", m2)
+
+	// main has 3 commits not reachable from it, merge, m3 and s1, side has 4.
+	s.Equal("main-3-g"+merge.String()[:7], s.describe(merge, nil))
+}
+
+func (s *DescribeSuite) TestDescribeNearestTie() {
+	c0 := s.commit()
+	m1 := s.commit(c0)
+	s1 := s.commit(c0)
+	m2 := s.commit(m1)
+	m3 := s.commit(m2)
+	merge := s.commit(m3, s1)
+
+	s.tag("main", m1)
+	s.tag("side", s1)
+
+	// Both tags have 4 commits not reachable from them, side is found first
+	// as s1 is more recent than m1.
+	s.Equal("side-4-g"+merge.String()[:7], s.describe(merge, nil))
+}
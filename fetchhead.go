@@ -0,0 +1,113 @@
+package git
+
+import (
+	neturl "net/url"
+	"strings"
+
+	"github.com/go-git/go-git/v6/config"
+	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/go-git/go-git/v6/plumbing/format/fetchhead"
+	"github.com/go-git/go-git/v6/plumbing/storer"
+)
+
+// FetchHead returns the references fetched by the last fetch, as recorded in
+// FETCH_HEAD. The entries to be merged into the current branch come first,
+// with NotForMerge unset. No entries are returned when nothing was fetched
+// yet or the storage doesn't record them.
+func (r *Repository) FetchHead() ([]*fetchhead.Entry, error) {
+	fs, ok := r.Storer.(storer.FetchHeadStorer)
+	if !ok {
+		return nil, nil
+	}
+
+	return fs.FetchHead()
+}
+
+// updateFetchHead records the references matched by the refspecs of a fetch
+// in FETCH_HEAD. The branch configured to be merged into the current branch,
+// when it's tracking the remote being fetched, is marked for merge and listed
+// first, as git does.
+func (r *Remote) updateFetchHead(
+	remoteName, remoteURL string,
+	specs []config.RefSpec,
+	specToRefs [][]*plumbing.Reference,
+) error {
+	fs, ok := r.s.(storer.FetchHeadStorer)
+	if !ok {
+		return nil
+	}
+
+	merge, err := r.mergeReferenceName(remoteName)
+	if err != nil {
+		return err
+	}
+
+	remoteURL = fetchHeadURL(remoteURL)
+	seen := make(map[plumbing.ReferenceName]bool)
+	var forMerge, notForMerge []*fetchhead.Entry
+	for i, refs := range specToRefs {
+		for _, ref := range refs {
+			name := ref.Name()
+			if i < len(specs) && specs[i].IsExactSHA1() {
+				name = plumbing.ReferenceName(ref.Hash().String())
+			}
+
+			if seen[name] {
+				continue
+			}
+
+			seen[name] = true
+			e := &fetchhead.Entry{Hash: ref.Hash(), Name: name, URL: remoteURL}
+			if name != merge || merge == "" {
+				e.NotForMerge = true
+				notForMerge = append(notForMerge, e)
+				continue
+			}
+
+			forMerge = append(forMerge, e)
+		}
+	}
+
+	return fs.SetFetchHead(append(forMerge, notForMerge...))
+}
+
+// mergeReferenceName returns the name, in the remote, of the branch configured
+// to be merged into the current branch, if it's tracking the given remote.
+func (r *Remote) mergeReferenceName(remoteName string) (plumbing.ReferenceName, error) {
+	head, err := r.s.Reference(plumbing.HEAD)
+	if err == plumbing.ErrReferenceNotFound {
+		return "", nil
+	}
+
+	if err != nil {
+		return "", err
+	}
+
+	if head.Type() != plumbing.SymbolicReference || !head.Target().IsBranch() {
+		return "", nil
+	}
+
+	cfg, err := r.s.Config()
+	if err != nil {
+		return "", err
+	}
+
+	b, ok := cfg.Branches[head.Target().Short()]
+	if !ok || b.Remote != remoteName {
+		return "", nil
+	}
+
+	return b.Merge, nil
+}
+
+// fetchHeadURL returns the URL of a remote as git writes it in FETCH_HEAD,
+// without credentials, trailing slashes or .git suffix.
+func fetchHeadURL(remoteURL string) string {
+	if u, err := neturl.Parse(remoteURL); err == nil && u.User != nil && u.Scheme != "" {
+		u.User = nil
+		remoteURL = u.String()
+	}
+
+	remoteURL = strings.TrimRight(remoteURL, "/")
+	return strings.TrimSuffix(remoteURL, ".git")
+}
@@ -0,0 +1,147 @@
+package git
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/go-git/go-git/v6/plumbing/format/idxfile"
+	"github.com/go-git/go-git/v6/plumbing/format/packfile"
+	"github.com/go-git/go-git/v6/plumbing/object"
+	"github.com/go-git/go-git/v6/plumbing/storer"
+	"github.com/go-git/go-git/v6/utils/ioutil"
+)
+
+// ErrObjectHashMismatch is reported by Fsck for the loose objects whose
+// content doesn't match their hash.
+var ErrObjectHashMismatch = errors.New("object hash does not match its content")
+
+// FsckProblem is an integrity problem found by Fsck.
+type FsckProblem struct {
+	// Pack is the hash of the packfile holding the object, or the zero hash
+	// for a loose object.
+	Pack plumbing.Hash
+	// Hash is the hash of the object, when known.
+	Hash plumbing.Hash
+	// Err describes the problem. For the packed objects, it is the
+	// *packfile.VerifyProblem found by packfile.Verify.
+	Err error
+}
+
+// Error returns a text representation of the problem.
+func (p *FsckProblem) Error() string {
+	if !p.Pack.IsZero() {
+		return fmt.Sprintf("pack %s: %s", p.Pack, p.Err)
+	}
+
+	return fmt.Sprintf("object %s: %s", p.Hash, p.Err)
+}
+
+// Unwrap returns the error describing the problem.
+func (p *FsckProblem) Unwrap() error {
+	return p.Err
+}
+
+// Fsck checks the integrity of the objects of the repository, returning all
+// the problems found. The loose objects are hashed again to check their
+// content matches their hash, and decoded, and every packfile is checked with
+// packfile.Verify against its index.
+//
+// The connectivity of the objects is not checked, the objects referenced by
+// others may be missing.
+func (r *Repository) Fsck() ([]*FsckProblem, error) {
+	var problems []*FsckProblem
+	if los, ok := r.Storer.(storer.LooseObjectStorer); ok {
+		err := los.ForEachObjectHash(func(h plumbing.Hash) error {
+			err := r.fsckObject(h)
+			if err != nil {
+				problems = append(problems, &FsckProblem{Hash: h, Err: err})
+			}
+
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if _, ok := r.Storer.(storer.PackedObjectStorer); !ok {
+		return problems, nil
+	}
+
+	packs, err := r.Storer.(storer.PackedObjectStorer).ObjectPacks()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, pack := range packs {
+		found, err := r.fsckPack(pack)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, p := range found {
+			problems = append(problems, &FsckProblem{Pack: pack, Hash: p.Hash, Err: p})
+		}
+	}
+
+	return problems, nil
+}
+
+// fsckObject checks the content of the object matches its hash, and that it
+// can be decoded.
+func (r *Repository) fsckObject(h plumbing.Hash) error {
+	obj, err := r.Storer.EncodedObject(plumbing.AnyObject, h)
+	if err != nil {
+		return err
+	}
+
+	rd, err := obj.Reader()
+	if err != nil {
+		return err
+	}
+
+	content, err := io.ReadAll(rd)
+	rd.Close()
+	if err != nil {
+		return fmt.Errorf("%w: %w", packfile.ErrMalformedObject, err)
+	}
+
+	if plumbing.ComputeHash(obj.Type(), content) != h {
+		return ErrObjectHashMismatch
+	}
+
+	if _, err := object.DecodeObject(r.Storer, obj); err != nil {
+		return fmt.Errorf("%w: %w", packfile.ErrMalformedObject, err)
+	}
+
+	return nil
+}
+
+// fsckPack verifies the given pack against its idx file.
+func (r *Repository) fsckPack(pack plumbing.Hash) (_ []*packfile.VerifyProblem, err error) {
+	fs, err := r.objectPacksFilesystem()
+	if err != nil {
+		return nil, err
+	}
+
+	fi, err := fs.Open(objectPackPath(pack, "idx"))
+	if err != nil {
+		return nil, err
+	}
+	defer ioutil.CheckClose(fi, &err)
+
+	idx := idxfile.NewMemoryIndex(pack.Size())
+	if err := idxfile.NewDecoder(fi).Decode(idx); err != nil {
+		return nil, err
+	}
+
+	fp, err := fs.Open(objectPackPath(pack, "pack"))
+	if err != nil {
+		return nil, err
+	}
+	defer ioutil.CheckClose(fp, &err)
+
+	return packfile.Verify(fp, idx)
+}
@@ -0,0 +1,94 @@
+package git
+
+import (
+	"bytes"
+	"compress/zlib"
+	"path"
+	"testing"
+
+	"github.com/go-git/go-billy/v5/util"
+	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/go-git/go-git/v6/plumbing/cache"
+	"github.com/go-git/go-git/v6/plumbing/format/packfile"
+	"github.com/go-git/go-git/v6/plumbing/storer"
+	"github.com/go-git/go-git/v6/storage/filesystem"
+	"github.com/stretchr/testify/suite"
+
+	fixtures "github.com/go-git/go-git-fixtures/v5"
+)
+
+type FsckSuite struct {
+	BaseSuite
+}
+
+func TestFsckSuite(t *testing.T) {
+	suite.Run(t, new(FsckSuite))
+}
+
+func (s *FsckSuite) TestFsck() {
+	for _, f := range []*fixtures.Fixture{
+		fixtures.Basic().One(),
+		fixtures.ByTag("unpacked").One(),
+	} {
+		r, err := Open(filesystem.NewStorage(f.DotGit(), cache.NewObjectLRUDefault()), nil)
+		s.Require().NoError(err)
+
+		problems, err := r.Fsck()
+		s.NoError(err)
+		s.Empty(problems)
+	}
+}
+
+func (s *FsckSuite) TestFsckLooseObject() {
+	fs := fixtures.ByTag("unpacked").One().DotGit()
+	r, err := Open(filesystem.NewStorage(fs, cache.NewObjectLRUDefault()), nil)
+	s.Require().NoError(err)
+
+	// The content of the first loose object is replaced.
+	var h plumbing.Hash
+	err = r.Storer.(storer.LooseObjectStorer).ForEachObjectHash(func(lh plumbing.Hash) error {
+		h = lh
+		return storer.ErrStop
+	})
+	s.Require().NoError(err)
+
+	var buf bytes.Buffer
+	zw := zlib.NewWriter(&buf)
+	_, err = zw.Write([]byte("blob 3\x00foo"))
+	s.Require().NoError(err)
+	s.Require().NoError(zw.Close())
+
+	name := path.Join("objects", h.String()[:2], h.String()[2:])
+	s.Require().NoError(fs.Remove(name))
+	s.Require().NoError(util.WriteFile(fs, name, buf.Bytes(), 0o444))
+
+	problems, err := r.Fsck()
+	s.NoError(err)
+	s.Require().Len(problems, 1)
+	s.Equal(h, problems[0].Hash)
+	s.True(problems[0].Pack.IsZero())
+	s.ErrorIs(problems[0], ErrObjectHashMismatch)
+}
+
+func (s *FsckSuite) TestFsckPack() {
+	f := fixtures.Basic().One()
+	fs := f.DotGit()
+	r, err := Open(filesystem.NewStorage(fs, cache.NewObjectLRUDefault()), nil)
+	s.Require().NoError(err)
+
+	// The last byte of the first hash of the idx file is altered, after its
+	// header and fanout table.
+	pack := plumbing.NewHash(f.PackfileHash)
+	name := objectPackPath(pack, "idx")
+	idx, err := util.ReadFile(fs, name)
+	s.Require().NoError(err)
+	idx[8+256*4+pack.Size()-1] ^= 0xff
+	s.Require().NoError(fs.Remove(name))
+	s.Require().NoError(util.WriteFile(fs, name, idx, 0o444))
+
+	problems, err := r.Fsck()
+	s.NoError(err)
+	s.Require().Len(problems, 1)
+	s.Equal(pack, problems[0].Pack)
+	s.ErrorIs(problems[0], packfile.ErrHashMismatch)
+}
@@ -0,0 +1,317 @@
+package git
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/util"
+	"github.com/go-git/go-git/v6/config"
+	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/go-git/go-git/v6/plumbing/protocol/packp"
+	"github.com/go-git/go-git/v6/storage"
+)
+
+const (
+	preCommitHook = "pre-commit"
+	commitMsgHook = "commit-msg"
+	prePushHook   = "pre-push"
+
+	hooksDir        = "hooks"
+	hooksPathKey    = "hooksPath"
+	commitEditMsg   = "COMMIT_EDITMSG"
+	commonDirFile   = "commondir"
+	gitIndexFileEnv = "GIT_INDEX_FILE"
+)
+
+// ErrHookFailed is returned when a hook run by an operation fails, aborting
+// the operation.
+var ErrHookFailed = errors.New("hook failed")
+
+// Hook is a hook of a repository to be run by a HookExecutor.
+type Hook struct {
+	// Name of the hook, e.g. "pre-commit".
+	Name string
+	// Path of the executable of the hook.
+	Path string
+	// Dir is the directory the hook runs in, the root of the worktree, or the
+	// git directory of a bare repository.
+	Dir string
+	// Args are the arguments given to the hook.
+	Args []string
+	// Env holds the environment variables set for the hook, as "key=value",
+	// on top of the ones of the current process.
+	Env []string
+	// Stdin is the standard input of the hook, if any.
+	Stdin io.Reader
+}
+
+// HookExecutor runs the hooks of a repository, see CommitOptions.RunHooks.
+type HookExecutor interface {
+	// RunHook runs the given hook, returning an error if it fails, as when
+	// exiting with a non-zero status.
+	RunHook(ctx context.Context, h *Hook) error
+}
+
+// HookExecutorFunc is a function implementing HookExecutor.
+type HookExecutorFunc func(ctx context.Context, h *Hook) error
+
+// RunHook implements HookExecutor.
+func (f HookExecutorFunc) RunHook(ctx context.Context, h *Hook) error {
+	return f(ctx, h)
+}
+
+// DefaultHookExecutor is the HookExecutor used when none is given, running
+// each hook as a process. The output of a failed hook is part of the error
+// returned.
+var DefaultHookExecutor HookExecutor = HookExecutorFunc(execHook)
+
+func execHook(ctx context.Context, h *Hook) error {
+	cmd := exec.CommandContext(ctx, h.Path, h.Args...)
+	cmd.Dir = h.Dir
+	cmd.Env = append(os.Environ(), h.Env...)
+	cmd.Stdin = h.Stdin
+
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+	if err := cmd.Run(); err != nil {
+		if out.Len() == 0 {
+			return err
+		}
+
+		return fmt.Errorf("%w\n%s", err, bytes.TrimSpace(out.Bytes()))
+	}
+
+	return nil
+}
+
+// hookRunner finds and runs the hooks of a repository.
+type hookRunner struct {
+	s        storage.Storer
+	dir      string
+	executor HookExecutor
+}
+
+// newHookRunner returns a hookRunner for the repository stored in s, whose
+// hooks run in the given directory, or in the one containing the repository
+// if empty. A nil executor is DefaultHookExecutor.
+func newHookRunner(s storage.Storer, dir string, executor HookExecutor) *hookRunner {
+	if executor == nil {
+		executor = DefaultHookExecutor
+	}
+
+	return &hookRunner{s: s, dir: dir, executor: executor}
+}
+
+// run runs the named hook with the given arguments and standard input, doing
+// nothing if the repository doesn't have it. ErrHookFailed is returned if the
+// hook fails.
+func (r *hookRunner) run(ctx context.Context, name string, stdin io.Reader, env []string, args ...string) error {
+	path, err := r.path(name)
+	if err != nil || path == "" {
+		return err
+	}
+
+	h := &Hook{
+		Name:  name,
+		Path:  path,
+		Dir:   r.workDir(),
+		Args:  args,
+		Env:   env,
+		Stdin: stdin,
+	}
+
+	if err := r.executor.RunHook(ctx, h); err != nil {
+		return fmt.Errorf("%w: %s: %w", ErrHookFailed, name, err)
+	}
+
+	return nil
+}
+
+// path returns the path of the executable of the named hook, found in
+// core.hooksPath or in the hooks directory of the repository, or an empty
+// string if there is none. As git does, the hooks which aren't executable are
+// ignored.
+func (r *hookRunner) path(name string) (string, error) {
+	cfg, err := r.s.Config()
+	if err != nil {
+		return "", err
+	}
+
+	dir := cfg.Raw.Section("core").Option(hooksPathKey)
+	switch {
+	case dir == "":
+		gitDir := r.commonDir()
+		if gitDir == "" {
+			return "", nil
+		}
+
+		dir = filepath.Join(gitDir, hooksDir)
+	case strings.HasPrefix(dir, "~/"):
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+
+		dir = filepath.Join(home, dir[2:])
+	case !filepath.IsAbs(dir):
+		dir = filepath.Join(r.workDir(), dir)
+	}
+
+	path := filepath.Join(dir, name)
+	fi, err := os.Stat(path)
+	if err != nil || fi.IsDir() || !isExecutable(fi) {
+		return "", nil
+	}
+
+	return path, nil
+}
+
+// isExecutable returns whether the given file can be run, any file on
+// Windows, where there are no execution permissions.
+func isExecutable(fi os.FileInfo) bool {
+	return runtime.GOOS == "windows" || fi.Mode()&0o111 != 0
+}
+
+// gitDir returns the path of the git directory of the repository, or an
+// empty string if it isn't stored in the filesystem.
+func (r *hookRunner) gitDir() string {
+	fs := r.filesystem()
+	if fs == nil {
+		return ""
+	}
+
+	return fs.Root()
+}
+
+// commonDir returns the path of the git directory shared by the worktrees of
+// the repository, holding its hooks.
+func (r *hookRunner) commonDir() string {
+	fs := r.filesystem()
+	if fs == nil {
+		return ""
+	}
+
+	content, err := util.ReadFile(fs, commonDirFile)
+	if err != nil {
+		return fs.Root()
+	}
+
+	dir := strings.TrimSpace(string(content))
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(fs.Root(), dir)
+	}
+
+	return dir
+}
+
+func (r *hookRunner) filesystem() billy.Filesystem {
+	s, ok := r.s.(interface{ Filesystem() billy.Filesystem })
+	if !ok || s.Filesystem() == nil {
+		return nil
+	}
+
+	return s.Filesystem()
+}
+
+// workDir returns the directory the hooks run in.
+func (r *hookRunner) workDir() string {
+	if r.dir != "" {
+		return r.dir
+	}
+
+	dir := r.gitDir()
+	if filepath.Base(dir) == GitDirName {
+		return filepath.Dir(dir)
+	}
+
+	return dir
+}
+
+// commitHooks runs the pre-commit and commit-msg hooks, returning the commit
+// message, which commit-msg may have changed.
+func (w *Worktree) commitHooks(msg string, opts *CommitOptions) (string, error) {
+	r := newHookRunner(w.r.Storer, w.Filesystem.Root(), opts.HookExecutor)
+	gitDir := r.gitDir()
+	if gitDir == "" {
+		return msg, nil
+	}
+
+	env := []string{gitIndexFileEnv + "=" + filepath.Join(gitDir, "index")}
+	ctx := context.Background()
+	if err := r.run(ctx, preCommitHook, nil, env); err != nil {
+		return "", err
+	}
+
+	path, err := r.path(commitMsgHook)
+	if err != nil || path == "" {
+		return msg, err
+	}
+
+	fs := r.filesystem()
+	if err := util.WriteFile(fs, commitEditMsg, []byte(msg), 0o644); err != nil {
+		return "", err
+	}
+
+	if err := r.run(ctx, commitMsgHook, nil, env, filepath.Join(gitDir, commitEditMsg)); err != nil {
+		return "", err
+	}
+
+	content, err := util.ReadFile(fs, commitEditMsg)
+	if err != nil {
+		return "", err
+	}
+
+	return string(content), nil
+}
+
+// runPrePushHook runs the pre-push hook for the given reference updates, giving
+// the local references pushed, found in the refspecs, along with the remote
+// ones, as git does.
+func (r *Remote) runPrePushHook(ctx context.Context, cmds []*packp.Command, o *PushOptions) error {
+	var stdin strings.Builder
+	for _, cmd := range cmds {
+		local := cmd.New.String()
+		name := "(delete)"
+		if cmd.Action() != packp.Delete {
+			name = pushedRef(cmd.Name, o).String()
+		}
+
+		fmt.Fprintf(&stdin, "%s %s %s %s\n", name, local, cmd.Name, cmd.Old)
+	}
+
+	name := o.RemoteName
+	if name == "" {
+		name = o.RemoteURL
+	}
+
+	h := newHookRunner(r.s, "", o.HookExecutor)
+	return h.run(ctx, prePushHook, strings.NewReader(stdin.String()), nil, name, o.RemoteURL)
+}
+
+// pushedRef returns the local reference pushed to the given remote one, by
+// the first refspec matching it, or the remote one itself, as for the tags
+// pushed with FollowTags.
+func pushedRef(remote plumbing.ReferenceName, o *PushOptions) plumbing.ReferenceName {
+	for _, rs := range o.RefSpecs {
+		if rs.IsDelete() || rs.IsNegative() {
+			continue
+		}
+
+		reverse := config.RefSpec(strings.TrimPrefix(rs.String(), "+")).Reverse()
+		if reverse.Match(remote) {
+			return reverse.Dst(remote)
+		}
+	}
+
+	return remote
+}
@@ -0,0 +1,196 @@
+package git
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-git/go-billy/v5/util"
+	"github.com/go-git/go-git/v6/config"
+	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/stretchr/testify/suite"
+)
+
+type HooksSuite struct {
+	suite.Suite
+	dir string
+	r   *Repository
+	w   *Worktree
+
+	hooks []*Hook
+	stdin []string
+}
+
+func TestHooksSuite(t *testing.T) {
+	suite.Run(t, new(HooksSuite))
+}
+
+func (s *HooksSuite) SetupTest() {
+	s.dir = s.T().TempDir()
+	s.hooks, s.stdin = nil, nil
+
+	var err error
+	s.r, err = PlainInit(s.dir, false)
+	s.Require().NoError(err)
+
+	s.w, err = s.r.Worktree()
+	s.Require().NoError(err)
+
+	s.Require().NoError(util.WriteFile(s.w.Filesystem, "file", []byte("content"), 0o644))
+	_, err = s.w.Add("file")
+	s.Require().NoError(err)
+}
+
+// writeHook writes a hook which is never run, as the executor of the tests
+// only records it.
+func (s *HooksSuite) writeHook(dir, name string, perm os.FileMode) {
+	s.Require().NoError(os.MkdirAll(dir, 0o755))
+	s.Require().NoError(os.WriteFile(filepath.Join(dir, name), []byte("#!/bin/sh\nexit 1\n"), perm))
+}
+
+// executor records the hooks run, failing the ones in fail. The commit-msg
+// hook rewrites the message.
+func (s *HooksSuite) executor(fail ...string) HookExecutor {
+	return HookExecutorFunc(func(_ context.Context, h *Hook) error {
+		s.hooks = append(s.hooks, h)
+		if h.Stdin != nil {
+			stdin, err := io.ReadAll(h.Stdin)
+			s.Require().NoError(err)
+			s.stdin = append(s.stdin, string(stdin))
+		}
+
+		for _, name := range fail {
+			if name == h.Name {
+				return errors.New("exit status 1")
+			}
+		}
+
+		if h.Name == commitMsgHook {
+			return os.WriteFile(h.Args[0], []byte("rewritten\n"), 0o644)
+		}
+
+		return nil
+	})
+}
+
+func (s *HooksSuite) commit(opts *CommitOptions) (plumbing.Hash, error) {
+	opts.Author = defaultSignature()
+	return s.w.Commit("message\n", opts)
+}
+
+func (s *HooksSuite) TestCommitHooks() {
+	hooks := filepath.Join(s.dir, GitDirName, hooksDir)
+	s.writeHook(hooks, preCommitHook, 0o755)
+	s.writeHook(hooks, commitMsgHook, 0o755)
+
+	h, err := s.commit(&CommitOptions{RunHooks: true, HookExecutor: s.executor()})
+	s.Require().NoError(err)
+
+	s.Require().Len(s.hooks, 2)
+	s.Equal(preCommitHook, s.hooks[0].Name)
+	s.Equal(filepath.Join(hooks, preCommitHook), s.hooks[0].Path)
+	s.Equal(s.dir, s.hooks[0].Dir)
+	s.Empty(s.hooks[0].Args)
+	s.Equal([]string{"GIT_INDEX_FILE=" + filepath.Join(s.dir, GitDirName, "index")}, s.hooks[0].Env)
+
+	s.Equal(commitMsgHook, s.hooks[1].Name)
+	s.Equal([]string{filepath.Join(s.dir, GitDirName, commitEditMsg)}, s.hooks[1].Args)
+
+	c, err := s.r.CommitObject(h)
+	s.Require().NoError(err)
+	s.Equal("rewritten\n", c.Message)
+}
+
+func (s *HooksSuite) TestCommitHooksDisabled() {
+	s.writeHook(filepath.Join(s.dir, GitDirName, hooksDir), preCommitHook, 0o755)
+
+	_, err := s.commit(&CommitOptions{HookExecutor: s.executor(preCommitHook)})
+	s.Require().NoError(err)
+	s.Empty(s.hooks)
+}
+
+func (s *HooksSuite) TestCommitHookFailed() {
+	hooks := filepath.Join(s.dir, GitDirName, hooksDir)
+	s.writeHook(hooks, preCommitHook, 0o755)
+	s.writeHook(hooks, commitMsgHook, 0o755)
+
+	_, err := s.commit(&CommitOptions{RunHooks: true, HookExecutor: s.executor(preCommitHook)})
+	s.ErrorIs(err, ErrHookFailed)
+	s.Len(s.hooks, 1)
+
+	_, err = s.r.Head()
+	s.ErrorIs(err, plumbing.ErrReferenceNotFound)
+
+	s.hooks = nil
+	_, err = s.commit(&CommitOptions{RunHooks: true, HookExecutor: s.executor(commitMsgHook)})
+	s.ErrorIs(err, ErrHookFailed)
+	s.Len(s.hooks, 2)
+
+	_, err = s.r.Head()
+	s.ErrorIs(err, plumbing.ErrReferenceNotFound)
+}
+
+func (s *HooksSuite) TestHooksPath() {
+	s.writeHook(filepath.Join(s.dir, GitDirName, hooksDir), preCommitHook, 0o755)
+	s.writeHook(filepath.Join(s.dir, "ci-hooks"), preCommitHook, 0o755)
+	s.writeHook(filepath.Join(s.dir, "ci-hooks"), commitMsgHook, 0o644)
+
+	cfg, err := s.r.Config()
+	s.Require().NoError(err)
+	cfg.Raw.Section("core").SetOption(hooksPathKey, "ci-hooks")
+	s.Require().NoError(s.r.SetConfig(cfg))
+
+	h, err := s.commit(&CommitOptions{RunHooks: true, HookExecutor: s.executor()})
+	s.Require().NoError(err)
+
+	// The commit-msg hook isn't executable.
+	s.Require().Len(s.hooks, 1)
+	s.Equal(filepath.Join(s.dir, "ci-hooks", preCommitHook), s.hooks[0].Path)
+
+	c, err := s.r.CommitObject(h)
+	s.Require().NoError(err)
+	s.Equal("message\n", c.Message)
+}
+
+func (s *HooksSuite) TestPrePushHook() {
+	h, err := s.commit(&CommitOptions{})
+	s.Require().NoError(err)
+
+	url := s.T().TempDir()
+	server, err := PlainInit(url, true)
+	s.Require().NoError(err)
+
+	_, err = s.r.CreateRemote(&config.RemoteConfig{Name: DefaultRemoteName, URLs: []string{url}})
+	s.Require().NoError(err)
+
+	s.writeHook(filepath.Join(s.dir, GitDirName, hooksDir), prePushHook, 0o755)
+
+	err = s.r.Push(&PushOptions{
+		RefSpecs:     []config.RefSpec{"+refs/heads/master:refs/heads/main"},
+		RunHooks:     true,
+		HookExecutor: s.executor(prePushHook),
+	})
+	s.ErrorIs(err, ErrHookFailed)
+
+	_, err = server.Reference("refs/heads/main", false)
+	s.ErrorIs(err, plumbing.ErrReferenceNotFound)
+
+	s.Require().Len(s.hooks, 1)
+	s.Equal(s.dir, s.hooks[0].Dir)
+	s.Equal([]string{DefaultRemoteName, url}, s.hooks[0].Args)
+	s.Equal([]string{"refs/heads/master " + h.String() + " refs/heads/main " + plumbing.ZeroHash.String() + "\n"}, s.stdin)
+
+	s.Require().NoError(s.r.Push(&PushOptions{
+		RefSpecs:     []config.RefSpec{"refs/heads/*:refs/heads/*"},
+		RunHooks:     true,
+		HookExecutor: s.executor(),
+	}))
+
+	ref, err := server.Reference("refs/heads/master", false)
+	s.Require().NoError(err)
+	s.Equal(h, ref.Hash())
+	s.Equal("refs/heads/master "+h.String()+" refs/heads/master "+plumbing.ZeroHash.String()+"\n", s.stdin[1])
+}
@@ -0,0 +1,204 @@
+// Package mergebase implements the merge base algorithms of git, walking the
+// history in generation order. They are shared by the commit objects and the
+// commit-graph nodes, through the Graph interface.
+package mergebase
+
+import (
+	"sort"
+
+	"github.com/go-git/go-git/v6/plumbing"
+
+	"github.com/emirpasic/gods/trees/binaryheap"
+)
+
+const (
+	reachableFromOne uint8 = 1 << iota
+	reachableFromTwos
+	stale
+	mergeBase
+)
+
+// Graph gives access to a history made of commits of type C.
+type Graph[C any] interface {
+	// ID returns the hash of the commit.
+	ID(c C) plumbing.Hash
+	// ParentHashes returns the hashes of the parents of the commit.
+	ParentHashes(c C) []plumbing.Hash
+	// Parent returns the i-th parent of the commit.
+	Parent(c C, i int) (C, error)
+	// Generation returns the generation number of the commit, a commit is
+	// never reachable from the commits with a lower generation. Zero means
+	// the generation is unknown.
+	Generation(c C) (uint64, error)
+	// Compare orders the commits by generation and then by commit date,
+	// returning a negative value when a is newer than b. It is only called
+	// with commits whose generation was asked before.
+	Compare(a, b C) int
+}
+
+// PaintDownToCommon walks the history of all the commits, marking the
+// commits reachable from one and from the twos, and returns the common
+// ancestors not reachable from other common ancestors found previously in the
+// walk.
+func PaintDownToCommon[C any](g Graph[C], one C, twos []C) ([]C, error) {
+	flags := make(map[plumbing.Hash]uint8)
+	queue := binaryheap.NewWith(func(a, b interface{}) int {
+		return g.Compare(a.(C), b.(C))
+	})
+
+	push := func(c C) error {
+		_, err := g.Generation(c)
+		queue.Push(c)
+		return err
+	}
+
+	flags[g.ID(one)] = reachableFromOne
+	if err := push(one); err != nil {
+		return nil, err
+	}
+
+	for _, two := range twos {
+		if flags[g.ID(two)]&reachableFromTwos != 0 {
+			continue
+		}
+
+		flags[g.ID(two)] |= reachableFromTwos
+		if err := push(two); err != nil {
+			return nil, err
+		}
+	}
+
+	// number of queued commits that were not stale when queued, the walk
+	// ends once every queued commit is stale
+	active := queue.Size()
+
+	var result []C
+	for active > 0 {
+		v, ok := queue.Pop()
+		if !ok {
+			break
+		}
+
+		c := v.(C)
+		id := g.ID(c)
+		f := flags[id]
+		if f&stale == 0 {
+			active--
+		}
+
+		f &= reachableFromOne | reachableFromTwos | stale
+		if f == reachableFromOne|reachableFromTwos {
+			if flags[id]&mergeBase == 0 {
+				flags[id] |= mergeBase
+				result = append(result, c)
+			}
+
+			f |= stale
+		}
+
+		for i, h := range g.ParentHashes(c) {
+			if flags[h]&f == f {
+				continue
+			}
+
+			p, err := g.Parent(c, i)
+			if err != nil {
+				return nil, err
+			}
+
+			flags[h] |= f
+			if err := push(p); err != nil {
+				return nil, err
+			}
+
+			if f&stale == 0 {
+				active++
+			}
+		}
+	}
+
+	return result, nil
+}
+
+// Independents returns the given commits not reachable from the others,
+// sorted from the newest to the oldest.
+func Independents[C any](g Graph[C], commits []C) ([]C, error) {
+	if len(commits) < 2 {
+		return commits, nil
+	}
+
+	var res []C
+	for i, c := range commits {
+		others := make([]C, 0, len(commits)-1)
+		others = append(others, commits[:i]...)
+		others = append(others, commits[i+1:]...)
+
+		redundant, err := Reachable(g, c, others)
+		if err != nil {
+			return nil, err
+		}
+
+		if !redundant {
+			res = append(res, c)
+		}
+	}
+
+	sort.SliceStable(res, func(i, j int) bool {
+		return g.Compare(res[i], res[j]) < 0
+	})
+
+	return res, nil
+}
+
+// Reachable returns true if the target commit can be reached from any of the
+// given commits, or is one of them. The commits with a generation lower than
+// the one of the target are not walked, since the target can't be one of
+// their ancestors.
+func Reachable[C any](g Graph[C], target C, from []C) (bool, error) {
+	minGeneration, err := g.Generation(target)
+	if err != nil {
+		return false, err
+	}
+
+	targetID := g.ID(target)
+	seen := make(map[plumbing.Hash]struct{})
+	pending := append([]C(nil), from...)
+	for len(pending) > 0 {
+		c := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+
+		id := g.ID(c)
+		if id == targetID {
+			return true, nil
+		}
+
+		if _, ok := seen[id]; ok {
+			continue
+		}
+
+		seen[id] = struct{}{}
+		gen, err := g.Generation(c)
+		if err != nil {
+			return false, err
+		}
+
+		if gen != 0 && gen < minGeneration {
+			continue
+		}
+
+		for i, h := range g.ParentHashes(c) {
+			if _, ok := seen[h]; ok {
+				continue
+			}
+
+			p, err := g.Parent(c, i)
+			if err != nil {
+				return false, err
+			}
+
+			pending = append(pending, p)
+		}
+	}
+
+	return false, nil
+}
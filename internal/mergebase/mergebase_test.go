@@ -0,0 +1,127 @@
+package mergebase
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/go-git/go-git/v6/plumbing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type node struct {
+	id      plumbing.Hash
+	gen     uint64
+	parents []*node
+}
+
+type testGraph struct{}
+
+func (testGraph) ID(c *node) plumbing.Hash { return c.id }
+
+func (testGraph) ParentHashes(c *node) []plumbing.Hash {
+	hashes := make([]plumbing.Hash, len(c.parents))
+	for i, p := range c.parents {
+		hashes[i] = p.id
+	}
+
+	return hashes
+}
+
+func (testGraph) Parent(c *node, i int) (*node, error) { return c.parents[i], nil }
+
+func (testGraph) Generation(c *node) (uint64, error) { return c.gen, nil }
+
+func (testGraph) Compare(a, b *node) int {
+	switch {
+	case a.gen > b.gen:
+		return -1
+	case a.gen < b.gen:
+		return 1
+	default:
+		return 0
+	}
+}
+
+type MergeBaseSuite struct {
+	suite.Suite
+	count int
+}
+
+func TestMergeBaseSuite(t *testing.T) {
+	suite.Run(t, new(MergeBaseSuite))
+}
+
+func (s *MergeBaseSuite) commit(parents ...*node) *node {
+	s.count++
+
+	var gen uint64
+	for _, p := range parents {
+		gen = max(gen, p.gen)
+	}
+
+	return &node{
+		id:      plumbing.NewHash(fmt.Sprintf("%040x", s.count)),
+		gen:     gen + 1,
+		parents: parents,
+	}
+}
+
+func (s *MergeBaseSuite) mergeBases(one *node, twos ...*node) []*node {
+	candidates, err := PaintDownToCommon[*node](testGraph{}, one, twos)
+	s.Require().NoError(err)
+
+	bases, err := Independents[*node](testGraph{}, candidates)
+	s.Require().NoError(err)
+	return bases
+}
+
+func (s *MergeBaseSuite) TestForkPoint() {
+	root := s.commit()
+	fork := s.commit(root)
+	one := s.commit(s.commit(fork))
+	two := s.commit(s.commit(fork))
+
+	s.Equal([]*node{fork}, s.mergeBases(one, two))
+	s.Equal([]*node{fork}, s.mergeBases(one, two, fork))
+}
+
+func (s *MergeBaseSuite) TestCrissCross() {
+	root := s.commit()
+	p := s.commit(root)
+	q := s.commit(root)
+	one := s.commit(p, q)
+	two := s.commit(q, p)
+
+	s.ElementsMatch([]*node{p, q}, s.mergeBases(one, two))
+}
+
+func (s *MergeBaseSuite) TestReachable() {
+	root := s.commit()
+	a := s.commit(root)
+	b := s.commit(root)
+
+	ok, err := Reachable[*node](testGraph{}, root, []*node{a})
+	s.NoError(err)
+	s.True(ok)
+
+	ok, err = Reachable[*node](testGraph{}, a, []*node{a})
+	s.NoError(err)
+	s.True(ok)
+
+	ok, err = Reachable[*node](testGraph{}, a, []*node{b})
+	s.NoError(err)
+	s.False(ok)
+}
+
+func (s *MergeBaseSuite) TestIndependents() {
+	root := s.commit()
+	a := s.commit(root)
+	b := s.commit(a)
+	c := s.commit(root)
+
+	res, err := Independents[*node](testGraph{}, []*node{root, a, b, c})
+	s.NoError(err)
+	s.ElementsMatch([]*node{b, c}, res)
+	s.Equal(b, res[0])
+}
@@ -269,6 +269,39 @@ func (s *UploadPackSuite) TestFetchError() {
 	//     different errors if a previous error was found.
 }
 
+func (s *UploadPackSuite) TestObjectInfo() {
+	r, err := s.Client.NewSession(s.Storer, s.Endpoint, s.EmptyAuth)
+	s.Require().NoError(err)
+	conn, err := r.Handshake(context.TODO(), transport.UploadPackService, "version=2")
+	s.Require().NoError(err)
+	defer func() { s.Require().Nil(conn.Close()) }()
+
+	_, err = conn.GetRemoteRefs(context.TODO())
+	s.ErrorIs(err, transport.ErrUnsupportedVersion)
+
+	master := plumbing.NewHash("6ecf0ef2c2dffb796033e5a02219af86ec6584e5")
+	missing := plumbing.NewHash("1111111111111111111111111111111111111111")
+	sizes, err := conn.ObjectInfo(context.TODO(), []plumbing.Hash{master, missing})
+	s.Require().NoError(err)
+	s.Equal([]transport.ObjectSize{
+		{Hash: master, Size: 245},
+		{Hash: missing, Size: -1},
+	}, sizes)
+}
+
+func (s *UploadPackSuite) TestObjectInfoNotSupported() {
+	r, err := s.Client.NewSession(s.Storer, s.Endpoint, s.EmptyAuth)
+	s.Require().NoError(err)
+	conn, err := r.Handshake(context.TODO(), transport.UploadPackService)
+	s.Require().NoError(err)
+	defer func() { s.Require().Nil(conn.Close()) }()
+
+	_, err = conn.ObjectInfo(context.TODO(), []plumbing.Hash{
+		plumbing.NewHash("6ecf0ef2c2dffb796033e5a02219af86ec6584e5"),
+	})
+	s.ErrorIs(err, transport.ErrObjectInfoNotSupported)
+}
+
 func (s *UploadPackSuite) countObjects(st storage.Storer) int {
 	iter, err := st.IterEncodedObjects(plumbing.AnyObject)
 	s.Require().NoError(err)
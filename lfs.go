@@ -0,0 +1,255 @@
+package git
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/go-git/go-git/v6/plumbing/filemode"
+	formatcfg "github.com/go-git/go-git/v6/plumbing/format/config"
+	"github.com/go-git/go-git/v6/plumbing/lfs"
+	"github.com/go-git/go-git/v6/plumbing/object"
+	transporthttp "github.com/go-git/go-git/v6/plumbing/transport/http"
+	"github.com/go-git/go-git/v6/utils/ioutil"
+)
+
+const (
+	lfsConfigFile = ".lfsconfig"
+
+	lfsSection    = "lfs"
+	remoteSection = "remote"
+	lfsURLKey     = "url"
+	lfsRemoteKey  = "lfsurl"
+)
+
+var (
+	// ErrLFSUnsupportedAuth is returned when the auth method given to the
+	// LFS driver is not an HTTP one.
+	ErrLFSUnsupportedAuth = errors.New("unsupported auth method for lfs")
+	// ErrLFSNoEndpoint is returned when the URL of the LFS server can't be
+	// found for a remote.
+	ErrLFSNoEndpoint = errors.New("no lfs endpoint")
+)
+
+// LFSDriver returns the driver of the lfs filter for the repository. The
+// objects are cached in the lfs/objects directory of the repository, or in
+// memory if the repository isn't stored in a filesystem. The LFS server is
+// found by LFSEndpoint, unless the driver is offline.
+//
+// The driver is used by a worktree of the repository once set in its Filters,
+// e.g. w.Filters = map[string]filter.Driver{lfs.FilterName: d}.
+func (r *Repository) LFSDriver(o *LFSOptions) (*lfs.Driver, error) {
+	if o == nil {
+		o = &LFSOptions{}
+	}
+
+	if err := o.Validate(); err != nil {
+		return nil, err
+	}
+
+	d := &lfs.Driver{Storage: lfs.NewMemoryStorage()}
+	if s, ok := r.Storer.(interface{ Filesystem() billy.Filesystem }); ok {
+		d.Storage = lfs.NewFilesystemStorage(s.Filesystem())
+	}
+
+	if o.Offline {
+		return d, nil
+	}
+
+	endpoint, err := r.LFSEndpoint(o.RemoteName)
+	if err != nil {
+		return nil, err
+	}
+
+	d.Client = &lfs.Client{
+		Endpoint:         endpoint,
+		HTTPClient:       o.HTTPClient,
+		CredentialHelper: o.CredentialHelper,
+	}
+
+	if o.Auth != nil {
+		d.Client.Auth = o.Auth.(transporthttp.AuthMethod)
+	}
+
+	return d, nil
+}
+
+// LFSEndpoint returns the URL of the LFS server of the given remote. As in
+// git-lfs, it's the first one found of lfs.url and remote.<name>.lfsurl, in
+// the config of the repository first and then in the .lfsconfig file of the
+// worktree, or of HEAD for bare repositories. Otherwise it's derived from the
+// URL of the remote, see lfs.EndpointFromRemote.
+func (r *Repository) LFSEndpoint(remoteName string) (string, error) {
+	cfg, err := r.Config()
+	if err != nil {
+		return "", err
+	}
+
+	lfsCfg, err := r.lfsConfig()
+	if err != nil {
+		return "", err
+	}
+
+	raws := []*formatcfg.Config{cfg.Raw, lfsCfg}
+	for _, raw := range raws {
+		if raw.HasSection(lfsSection) {
+			if u := raw.Section(lfsSection).Option(lfsURLKey); u != "" {
+				return u, nil
+			}
+		}
+	}
+
+	for _, raw := range raws {
+		if raw.HasSection(remoteSection) && raw.Section(remoteSection).HasSubsection(remoteName) {
+			if u := raw.Section(remoteSection).Subsection(remoteName).Option(lfsRemoteKey); u != "" {
+				return u, nil
+			}
+		}
+	}
+
+	remote, ok := cfg.Remotes[remoteName]
+	if !ok || len(remote.URLs) == 0 {
+		return "", ErrLFSNoEndpoint
+	}
+
+	return lfs.EndpointFromRemote(remote.URLs[0])
+}
+
+// lfsConfig returns the content of the .lfsconfig file, read from the
+// worktree or from HEAD.
+func (r *Repository) lfsConfig() (*formatcfg.Config, error) {
+	cfg := formatcfg.New()
+
+	content, err := r.readLFSConfig()
+	if err != nil || content == nil {
+		return cfg, err
+	}
+
+	if err := formatcfg.NewDecoder(bytes.NewReader(content)).Decode(cfg); err != nil {
+		return nil, err
+	}
+
+	return cfg, nil
+}
+
+func (r *Repository) readLFSConfig() ([]byte, error) {
+	if r.wt != nil {
+		f, err := r.wt.Open(lfsConfigFile)
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		defer f.Close()
+		return io.ReadAll(f)
+	}
+
+	head, err := r.Head()
+	if err == plumbing.ErrReferenceNotFound {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	c, err := r.CommitObject(head.Hash())
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := c.File(lfsConfigFile)
+	if err == object.ErrFileNotFound {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	content, err := f.Contents()
+	return []byte(content), err
+}
+
+// UploadLFSObjects sends to the LFS server of the driver the objects of the
+// LFS pointers found in the trees of the given commits, usually the ones about
+// to be pushed, as the pre-push hook of git-lfs does. Only the objects in the
+// local storage of the driver are sent, the others being already in the
+// server.
+func (r *Repository) UploadLFSObjects(ctx context.Context, d *lfs.Driver, commits ...plumbing.Hash) error {
+	seen := make(map[string]bool)
+	for _, h := range commits {
+		c, err := r.CommitObject(h)
+		if err != nil {
+			return err
+		}
+
+		t, err := c.Tree()
+		if err != nil {
+			return err
+		}
+
+		pointers, err := lfsPointers(t)
+		if err != nil {
+			return err
+		}
+
+		for _, p := range pointers {
+			if seen[p.Oid] {
+				continue
+			}
+
+			seen[p.Oid] = true
+			ok, err := d.Storage.Has(p)
+			if err != nil {
+				return err
+			}
+
+			if !ok {
+				continue
+			}
+
+			if err := d.Upload(ctx, p); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// lfsPointers returns the LFS pointers of the files of the tree.
+func lfsPointers(t *object.Tree) ([]lfs.Pointer, error) {
+	var pointers []lfs.Pointer
+	err := t.Files().ForEach(func(f *object.File) (err error) {
+		if !f.Mode.IsFile() || f.Mode == filemode.Symlink || f.Size > lfs.MaxPointerSize {
+			return nil
+		}
+
+		r, err := f.Reader()
+		if err != nil {
+			return err
+		}
+
+		defer ioutil.CheckClose(r, &err)
+		content, err := io.ReadAll(r)
+		if err != nil {
+			return err
+		}
+
+		if p, err := lfs.DecodePointer(content); err == nil {
+			pointers = append(pointers, p)
+		}
+
+		return nil
+	})
+
+	return pointers, err
+}
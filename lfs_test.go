@@ -0,0 +1,184 @@
+package git
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/go-git/go-billy/v5/util"
+	"github.com/go-git/go-git/v6/config"
+	"github.com/go-git/go-git/v6/plumbing/filter"
+	"github.com/go-git/go-git/v6/plumbing/lfs"
+	"github.com/go-git/go-git/v6/storage/memory"
+)
+
+func (s *RepositorySuite) TestLFSEndpoint() {
+	fs := memfs.New()
+	r, err := Init(memory.NewStorage(), WithWorkTree(fs))
+	s.Require().NoError(err)
+
+	_, err = r.LFSEndpoint(DefaultRemoteName)
+	s.ErrorIs(err, ErrLFSNoEndpoint)
+
+	_, err = r.CreateRemote(&config.RemoteConfig{
+		Name: DefaultRemoteName,
+		URLs: []string{"git@example.com:org/repo.git"},
+	})
+	s.Require().NoError(err)
+
+	ep, err := r.LFSEndpoint(DefaultRemoteName)
+	s.NoError(err)
+	s.Equal("https://example.com/org/repo.git/info/lfs", ep)
+
+	s.Require().NoError(util.WriteFile(fs, ".lfsconfig", []byte(
+		"[remote \"origin\"]\n\tlfsurl = https://remote.example.com\n"), 0o644))
+	ep, err = r.LFSEndpoint(DefaultRemoteName)
+	s.NoError(err)
+	s.Equal("https://remote.example.com", ep)
+
+	s.Require().NoError(util.WriteFile(fs, ".lfsconfig", []byte(
+		"[lfs]\n\turl = https://lfsconfig.example.com\n"), 0o644))
+	ep, err = r.LFSEndpoint(DefaultRemoteName)
+	s.NoError(err)
+	s.Equal("https://lfsconfig.example.com", ep)
+
+	cfg, err := r.Config()
+	s.Require().NoError(err)
+	cfg.Raw.Section("lfs").SetOption("url", "https://config.example.com")
+	s.Require().NoError(r.SetConfig(cfg))
+
+	ep, err = r.LFSEndpoint(DefaultRemoteName)
+	s.NoError(err)
+	s.Equal("https://config.example.com", ep)
+}
+
+func (s *RepositorySuite) TestLFSDriverOffline() {
+	fs := memfs.New()
+	r, err := Init(memory.NewStorage(), WithWorkTree(fs))
+	s.Require().NoError(err)
+
+	d, err := r.LFSDriver(&LFSOptions{Offline: true})
+	s.Require().NoError(err)
+	s.Nil(d.Client)
+
+	w, err := r.Worktree()
+	s.Require().NoError(err)
+	w.Filters = map[string]filter.Driver{lfs.FilterName: d}
+
+	s.Require().NoError(util.WriteFile(fs, ".gitattributes", []byte("*.bin filter=lfs\n"), 0o644))
+	s.Require().NoError(util.WriteFile(fs, "big.bin", []byte("foo"), 0o644))
+	s.Require().NoError(w.AddGlob("*"))
+	_, err = w.Commit("lfs", &CommitOptions{Author: defaultSignature()})
+	s.Require().NoError(err)
+
+	f, err := r.Storer.Index()
+	s.Require().NoError(err)
+	e, err := f.Entry("big.bin")
+	s.Require().NoError(err)
+
+	blob, err := r.BlobObject(e.Hash)
+	s.Require().NoError(err)
+	rd, err := blob.Reader()
+	s.Require().NoError(err)
+	content, err := io.ReadAll(rd)
+	s.NoError(err)
+	s.NoError(rd.Close())
+
+	p, err := lfs.DecodePointer(content)
+	s.Require().NoError(err)
+	s.Equal(int64(3), p.Size)
+
+	status, err := w.Status()
+	s.NoError(err)
+	s.True(status.IsClean(), status.String())
+
+	// Checked out from the local storage.
+	s.Require().NoError(fs.Remove("big.bin"))
+	s.Require().NoError(w.Reset(&ResetOptions{Mode: HardReset}))
+
+	content, err = util.ReadFile(fs, "big.bin")
+	s.NoError(err)
+	s.Equal("foo", string(content))
+}
+
+func (s *RepositorySuite) TestLFSDriverFilesystemStorage() {
+	dir := s.T().TempDir()
+	r, err := PlainInit(dir, false)
+	s.Require().NoError(err)
+
+	d, err := r.LFSDriver(&LFSOptions{Offline: true})
+	s.Require().NoError(err)
+	s.IsType(&lfs.FilesystemStorage{}, d.Storage)
+
+	p, err := lfs.NewPointer(strings.NewReader("foo"))
+	s.Require().NoError(err)
+	s.Require().NoError(d.Storage.Write(p, strings.NewReader("foo")))
+	s.FileExists(filepath.Join(dir, ".git", "lfs", "objects", p.Oid[:2], p.Oid[2:4], p.Oid))
+}
+
+func (s *RepositorySuite) TestUploadLFSObjects() {
+	uploaded := make(map[string]string)
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	mux.HandleFunc("POST /org/repo.git/info/lfs/objects/batch", func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Objects []struct {
+				Oid  string `json:"oid"`
+				Size int64  `json:"size"`
+			} `json:"objects"`
+		}
+		s.NoError(json.NewDecoder(r.Body).Decode(&req))
+
+		var objects []map[string]any
+		for _, o := range req.Objects {
+			objects = append(objects, map[string]any{
+				"oid": o.Oid, "size": o.Size,
+				"actions": map[string]any{"upload": map[string]string{"href": server.URL + "/" + o.Oid}},
+			})
+		}
+
+		s.NoError(json.NewEncoder(w).Encode(map[string]any{"objects": objects}))
+	})
+	mux.HandleFunc("PUT /{oid}", func(w http.ResponseWriter, r *http.Request) {
+		content, err := io.ReadAll(r.Body)
+		s.NoError(err)
+		uploaded[r.PathValue("oid")] = string(content)
+	})
+
+	fs := memfs.New()
+	r, err := Init(memory.NewStorage(), WithWorkTree(fs))
+	s.Require().NoError(err)
+
+	_, err = r.CreateRemote(&config.RemoteConfig{
+		Name: DefaultRemoteName,
+		URLs: []string{server.URL + "/org/repo"},
+	})
+	s.Require().NoError(err)
+
+	d, err := r.LFSDriver(nil)
+	s.Require().NoError(err)
+	s.Equal(server.URL+"/org/repo.git/info/lfs", d.Client.Endpoint)
+
+	w, err := r.Worktree()
+	s.Require().NoError(err)
+	w.Filters = map[string]filter.Driver{lfs.FilterName: d}
+
+	s.Require().NoError(util.WriteFile(fs, ".gitattributes", []byte("*.bin filter=lfs\n"), 0o644))
+	s.Require().NoError(util.WriteFile(fs, "big.bin", []byte("foo"), 0o644))
+	s.Require().NoError(w.AddGlob("*"))
+	h, err := w.Commit("lfs", &CommitOptions{Author: defaultSignature()})
+	s.Require().NoError(err)
+
+	s.NoError(r.UploadLFSObjects(context.Background(), d, h))
+
+	p, err := lfs.NewPointer(strings.NewReader("foo"))
+	s.Require().NoError(err)
+	s.Equal(map[string]string{p.Oid: "foo"}, uploaded)
+}
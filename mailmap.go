@@ -0,0 +1,116 @@
+package git
+
+import (
+	"errors"
+	"io"
+	"os"
+
+	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/go-git/go-git/v6/plumbing/object"
+)
+
+const (
+	mailMapFile = ".mailmap"
+
+	mailMapSection = "mailmap"
+	mailMapFileKey = "file"
+)
+
+// MailMap loads the mailmap of the repository, used to map the authors and
+// committers of the commits to their canonical names and emails, e.g. with
+// LogOptions.MailMap. It reads the .mailmap file at the root of the worktree,
+// or of the HEAD tree in a bare repository, followed by the file given in the
+// options. The files that don't exist are ignored, resulting in an empty
+// MailMap if none does.
+func (r *Repository) MailMap(o *MailMapOptions) (*object.MailMap, error) {
+	if o == nil {
+		o = &MailMapOptions{}
+	}
+
+	if err := o.Validate(r); err != nil {
+		return nil, err
+	}
+
+	mm := object.NewMailMap()
+	if err := r.readRepositoryMailMap(mm); err != nil {
+		return nil, err
+	}
+
+	if o.Path == "" {
+		return mm, nil
+	}
+
+	f, err := os.Open(o.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		return mm, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer f.Close()
+	if err := mm.Read(f); err != nil {
+		return nil, err
+	}
+
+	return mm, nil
+}
+
+// readRepositoryMailMap reads the .mailmap file of the worktree, or of the
+// HEAD tree if the repository is bare, into mm.
+func (r *Repository) readRepositoryMailMap(mm *object.MailMap) error {
+	var (
+		rd  io.ReadCloser
+		err error
+	)
+	if r.wt != nil {
+		rd, err = r.wt.Open(mailMapFile)
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+	} else {
+		rd, err = r.headMailMap()
+	}
+
+	if err != nil || rd == nil {
+		return err
+	}
+
+	defer rd.Close()
+	return mm.Read(rd)
+}
+
+// headMailMap returns a reader of the .mailmap file of the HEAD tree, or nil
+// if there is none.
+func (r *Repository) headMailMap() (io.ReadCloser, error) {
+	head, err := r.Head()
+	if errors.Is(err, plumbing.ErrReferenceNotFound) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	c, err := r.CommitObject(head.Hash())
+	if err != nil {
+		return nil, err
+	}
+
+	tree, err := c.Tree()
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := tree.File(mailMapFile)
+	if errors.Is(err, object.ErrFileNotFound) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return f.Reader()
+}
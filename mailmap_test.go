@@ -0,0 +1,130 @@
+package git
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/go-git/go-billy/v5/util"
+	"github.com/go-git/go-git/v6/storage/memory"
+	"github.com/stretchr/testify/suite"
+)
+
+type MailMapSuite struct {
+	suite.Suite
+	r *Repository
+	w *Worktree
+}
+
+func TestMailMapSuite(t *testing.T) {
+	suite.Run(t, new(MailMapSuite))
+}
+
+func (s *MailMapSuite) SetupTest() {
+	var err error
+	s.r, err = Init(memory.NewStorage(), WithWorkTree(memfs.New()))
+	s.Require().NoError(err)
+	s.w, err = s.r.Worktree()
+	s.Require().NoError(err)
+
+	s.Require().NoError(util.WriteFile(s.w.Filesystem, ".mailmap",
+		[]byte("Foo Bar <foo@example.com> <foo@foo.foo>\n"), 0644))
+	_, err = s.w.Add(".mailmap")
+	s.Require().NoError(err)
+	_, err = s.w.Commit("mailmap", &CommitOptions{Author: defaultSignature()})
+	s.Require().NoError(err)
+}
+
+func (s *MailMapSuite) TestMailMapWorktree() {
+	mm, err := s.r.MailMap(nil)
+	s.Require().NoError(err)
+
+	name, email := mm.Map("foo", "foo@foo.foo")
+	s.Equal("Foo Bar", name)
+	s.Equal("foo@example.com", email)
+}
+
+func (s *MailMapSuite) TestMailMapPath() {
+	path := filepath.Join(s.T().TempDir(), "mailmap")
+	s.Require().NoError(os.WriteFile(path, []byte("Override <foo@foo.foo>\n"), 0644))
+
+	mm, err := s.r.MailMap(&MailMapOptions{Path: path})
+	s.Require().NoError(err)
+
+	name, email := mm.Map("foo", "foo@foo.foo")
+	s.Equal("Override", name)
+	s.Equal("foo@example.com", email)
+
+	// A missing file is ignored.
+	mm, err = s.r.MailMap(&MailMapOptions{Path: path + ".missing"})
+	s.Require().NoError(err)
+	name, _ = mm.Map("foo", "foo@foo.foo")
+	s.Equal("Foo Bar", name)
+}
+
+func (s *MailMapSuite) TestMailMapConfig() {
+	path := filepath.Join(s.T().TempDir(), "mailmap")
+	s.Require().NoError(os.WriteFile(path, []byte("Config <foo@foo.foo>\n"), 0644))
+
+	cfg, err := s.r.Config()
+	s.Require().NoError(err)
+	cfg.Raw.Section("mailmap").SetOption("file", path)
+	s.Require().NoError(s.r.SetConfig(cfg))
+
+	mm, err := s.r.MailMap(nil)
+	s.Require().NoError(err)
+	name, _ := mm.Map("foo", "foo@foo.foo")
+	s.Equal("Config", name)
+}
+
+func (s *MailMapSuite) TestMailMapBare() {
+	bare, err := Open(s.r.Storer, nil)
+	s.Require().NoError(err)
+
+	mm, err := bare.MailMap(nil)
+	s.Require().NoError(err)
+	name, _ := mm.Map("foo", "foo@foo.foo")
+	s.Equal("Foo Bar", name)
+
+	empty, err := Init(memory.NewStorage())
+	s.Require().NoError(err)
+	mm, err = empty.MailMap(nil)
+	s.Require().NoError(err)
+	name, _ = mm.Map("foo", "foo@foo.foo")
+	s.Equal("foo", name)
+}
+
+func (s *MailMapSuite) TestLogMailMap() {
+	mm, err := s.r.MailMap(nil)
+	s.Require().NoError(err)
+
+	it, err := s.r.Log(&LogOptions{MailMap: mm})
+	s.Require().NoError(err)
+	c, err := it.Next()
+	s.Require().NoError(err)
+	s.Equal("Foo Bar", c.Author.Name)
+	s.Equal("foo@example.com", c.Committer.Email)
+
+	it, err = s.r.Log(&LogOptions{})
+	s.Require().NoError(err)
+	c, err = it.Next()
+	s.Require().NoError(err)
+	s.Equal("foo", c.Author.Name)
+}
+
+func (s *MailMapSuite) TestBlameMailMap() {
+	mm, err := s.r.MailMap(nil)
+	s.Require().NoError(err)
+
+	head, err := s.r.Head()
+	s.Require().NoError(err)
+	c, err := s.r.CommitObject(head.Hash())
+	s.Require().NoError(err)
+
+	res, err := BlameWithOptions(c, ".mailmap", &BlameOptions{MailMap: mm})
+	s.Require().NoError(err)
+	s.Require().Len(res.Lines, 1)
+	s.Equal("Foo Bar", res.Lines[0].AuthorName)
+	s.Equal("foo@example.com", res.Lines[0].Author)
+}
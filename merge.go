@@ -0,0 +1,653 @@
+package git
+
+import (
+	"bytes"
+	"io"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/go-git/go-git/v6/plumbing/filemode"
+	"github.com/go-git/go-git/v6/plumbing/format/gitattributes"
+	"github.com/go-git/go-git/v6/plumbing/format/index"
+	"github.com/go-git/go-git/v6/plumbing/object"
+	"github.com/go-git/go-git/v6/storage"
+	"github.com/go-git/go-git/v6/utils/binary"
+	"github.com/go-git/go-git/v6/utils/diff"
+	"github.com/go-git/go-git/v6/utils/ioutil"
+)
+
+// MergeConflict describes a path that could not be merged automatically.
+// The entries are nil when the path does not exist on the given side; e.g. a
+// file deleted on one side and modified on the other has a nil Ours or Theirs.
+type MergeConflict struct {
+	// Path of the conflicting file, relative to the root of the tree.
+	Path string
+	// Ancestor is the entry at the merge base.
+	Ancestor *object.TreeEntry
+	// Ours is the entry at the current HEAD.
+	Ours *object.TreeEntry
+	// Theirs is the entry at the commit being merged.
+	Theirs *object.TreeEntry
+}
+
+// MergeResult holds the outcome of a merge operation.
+type MergeResult struct {
+	// FastForward is true when HEAD was fast-forwarded to the merged commit.
+	FastForward bool
+	// Conflicts lists the paths that could not be merged automatically.
+	Conflicts []MergeConflict
+}
+
+// HasConflicts returns true if the merge resulted in any conflict.
+func (r *MergeResult) HasConflicts() bool {
+	return len(r.Conflicts) > 0
+}
+
+// treeMerger performs three-way merges of trees. Any blob created during a
+// content merge, including the ones containing conflict markers, is written
+// to the storer.
+//
+// The merge attribute of the paths, looked up with the attributes matcher if
+// any, selects how their content is merged: with the attribute unset or set
+// to binary, the files are never merged line by line and conflict keeping
+// ours, while with it set they are merged as text even if they look binary.
+type treeMerger struct {
+	s          storage.Storer
+	attributes gitattributes.Matcher
+
+	oursLabel   string
+	theirsLabel string
+}
+
+const mergeAttr = "merge"
+
+// treeMergeResult is the outcome of merging trees. The entries are keyed by
+// path, with the Name of each entry holding its full path. Conflicting paths
+// hold the entry that is expected to be checked out in the worktree, e.g. a
+// blob with conflict markers.
+//
+// The directories of ours that are kept as they are, because theirs didn't
+// change them, are held in trees instead, without listing their files in
+// entries.
+type treeMergeResult struct {
+	entries   map[string]object.TreeEntry
+	trees     map[string]object.TreeEntry
+	conflicts []MergeConflict
+}
+
+// inKeptTree returns true if the path is inside one of the directories of
+// ours kept by the merge.
+func (r *treeMergeResult) inKeptTree(p string) bool {
+	for dir := path.Dir(p); dir != "."; dir = path.Dir(dir) {
+		if _, ok := r.trees[dir]; ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// baseTree returns the tree to be used as the ancestor when merging the given
+// commits. When there are several merge bases, they are merged together into
+// a virtual ancestor, which is what the recursive strategy of git does.
+func (m *treeMerger) baseTree(a, b *object.Commit) (*object.Tree, error) {
+	bases, err := a.MergeBase(b)
+	if err != nil {
+		return nil, err
+	}
+
+	switch len(bases) {
+	case 0:
+		return nil, nil
+	case 1:
+		return bases[0].Tree()
+	}
+
+	tree, err := bases[0].Tree()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, next := range bases[1:] {
+		ancestor, err := m.baseTree(bases[0], next)
+		if err != nil {
+			return nil, err
+		}
+
+		nextTree, err := next.Tree()
+		if err != nil {
+			return nil, err
+		}
+
+		res, err := m.merge(ancestor, tree, nextTree)
+		if err != nil {
+			return nil, err
+		}
+
+		if tree, err = m.writeTree(res); err != nil {
+			return nil, err
+		}
+	}
+
+	return tree, nil
+}
+
+// merge performs a three-way merge of ours and theirs, using base as their
+// common ancestor. A nil tree is handled as an empty one.
+//
+// The trees are walked one level at a time, comparing the entries by hash,
+// so the directories that didn't change on one side are taken from the other
+// one without reading them. Only the directories changed on both sides are
+// walked further, down to the files changed on both sides.
+func (m *treeMerger) merge(base, ours, theirs *object.Tree) (*treeMergeResult, error) {
+	res := &treeMergeResult{
+		entries: make(map[string]object.TreeEntry),
+		trees:   make(map[string]object.TreeEntry),
+	}
+
+	b := make(map[string]object.TreeEntry)
+	o := make(map[string]object.TreeEntry)
+	t := make(map[string]object.TreeEntry)
+	if err := m.mergeTrees(res, "", base, ours, theirs, b, o, t); err != nil {
+		return nil, err
+	}
+
+	m.resolveDirectoryFileConflicts(res, b, o, t)
+	return res, nil
+}
+
+// mergeTrees merges the entries of the trees found at the given directory.
+// The files of the paths merged one by one are added to b, o and t, by side.
+func (m *treeMerger) mergeTrees(
+	res *treeMergeResult, dir string,
+	base, ours, theirs *object.Tree,
+	b, o, t map[string]object.TreeEntry,
+) error {
+	for _, name := range unionEntryNames(base, ours, theirs) {
+		p := path.Join(dir, name)
+		be, inBase := treeEntry(base, name)
+		oe, inOurs := treeEntry(ours, name)
+		te, inTheirs := treeEntry(theirs, name)
+
+		switch {
+		case sameEntry(oe, inOurs, te, inTheirs), sameEntry(be, inBase, te, inTheirs):
+			if inOurs {
+				res.keep(p, oe)
+			}
+		case sameEntry(be, inBase, oe, inOurs):
+			if inTheirs {
+				if err := m.flattenEntry(res.entries, p, te); err != nil {
+					return err
+				}
+			}
+		case inOurs && inTheirs && oe.Mode == filemode.Dir && te.Mode == filemode.Dir &&
+			(!inBase || be.Mode == filemode.Dir):
+			// Both sides changed the directory, its entries are merged.
+			subtrees := make([]*object.Tree, 3)
+			for i, e := range []*object.TreeEntry{&be, &oe, &te} {
+				if i == 0 && !inBase {
+					continue
+				}
+
+				var err error
+				if subtrees[i], err = object.GetTree(m.s, e.Hash); err != nil {
+					return err
+				}
+			}
+
+			if err := m.mergeTrees(res, p, subtrees[0], subtrees[1], subtrees[2], b, o, t); err != nil {
+				return err
+			}
+		default:
+			if err := m.mergeFiles(res, p, be, inBase, oe, inOurs, te, inTheirs, b, o, t); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// mergeFiles merges one by one the files found at the given path on each
+// side, either the path itself or the files below it for a directory.
+func (m *treeMerger) mergeFiles(
+	res *treeMergeResult, p string,
+	be object.TreeEntry, inBase bool,
+	oe object.TreeEntry, inOurs bool,
+	te object.TreeEntry, inTheirs bool,
+	b, o, t map[string]object.TreeEntry,
+) error {
+	sides := []struct {
+		e   object.TreeEntry
+		in  bool
+		all map[string]object.TreeEntry
+	}{{be, inBase, b}, {oe, inOurs, o}, {te, inTheirs, t}}
+
+	files := make([]map[string]object.TreeEntry, len(sides))
+	for i, side := range sides {
+		files[i] = make(map[string]object.TreeEntry)
+		if !side.in {
+			continue
+		}
+
+		if err := m.flattenEntry(files[i], p, side.e); err != nil {
+			return err
+		}
+
+		for fp, e := range files[i] {
+			side.all[fp] = e
+		}
+	}
+
+	for _, fp := range unionPaths(files...) {
+		if err := m.mergeFile(res, fp, b, o, t); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// mergeFile merges the versions of a file found on each side.
+func (m *treeMerger) mergeFile(res *treeMergeResult, p string, b, o, t map[string]object.TreeEntry) error {
+	be, inBase := b[p]
+	oe, inOurs := o[p]
+	te, inTheirs := t[p]
+
+	switch {
+	case sameEntry(oe, inOurs, te, inTheirs):
+		if inOurs {
+			res.entries[p] = oe
+		}
+	case sameEntry(be, inBase, oe, inOurs):
+		if inTheirs {
+			res.entries[p] = te
+		}
+	case sameEntry(be, inBase, te, inTheirs):
+		if inOurs {
+			res.entries[p] = oe
+		}
+	case !inOurs || !inTheirs:
+		// One side deleted the file while the other one changed it, the
+		// changed version is kept in the worktree.
+		if inOurs {
+			res.entries[p] = oe
+		} else {
+			res.entries[p] = te
+		}
+
+		res.conflicts = append(res.conflicts, newMergeConflict(p, b, o, t))
+	default:
+		e, clean, err := m.mergeEntries(p, be, inBase, oe, te)
+		if err != nil {
+			return err
+		}
+
+		res.entries[p] = e
+		if !clean {
+			res.conflicts = append(res.conflicts, newMergeConflict(p, b, o, t))
+		}
+	}
+
+	return nil
+}
+
+// keep adds the entry of ours at the given path to the result, a directory
+// being kept as a whole.
+func (r *treeMergeResult) keep(p string, e object.TreeEntry) {
+	e.Name = p
+	if e.Mode == filemode.Dir {
+		r.trees[p] = e
+		return
+	}
+
+	r.entries[p] = e
+}
+
+// flattenEntry adds the entry at the given path to entries, or all the files
+// below it if it's a directory, with their Name holding their full path.
+func (m *treeMerger) flattenEntry(entries map[string]object.TreeEntry, p string, e object.TreeEntry) error {
+	if e.Mode != filemode.Dir {
+		e.Name = p
+		entries[p] = e
+		return nil
+	}
+
+	t, err := object.GetTree(m.s, e.Hash)
+	if err != nil {
+		return err
+	}
+
+	files, err := flattenTree(t)
+	if err != nil {
+		return err
+	}
+
+	for name, fe := range files {
+		fe.Name = path.Join(p, name)
+		entries[fe.Name] = fe
+	}
+
+	return nil
+}
+
+// mergeEntries merges two versions of a file changed on both sides. It
+// returns false if the changes could not be merged cleanly.
+func (m *treeMerger) mergeEntries(name string, base object.TreeEntry, inBase bool, ours, theirs object.TreeEntry) (object.TreeEntry, bool, error) {
+	mode, clean := ours.Mode, true
+	switch {
+	case ours.Mode == theirs.Mode:
+	case inBase && base.Mode == ours.Mode:
+		mode = theirs.Mode
+	case inBase && base.Mode == theirs.Mode:
+	default:
+		clean = false
+	}
+
+	merged := object.TreeEntry{Name: name, Mode: mode, Hash: ours.Hash}
+	if ours.Hash == theirs.Hash {
+		return merged, clean, nil
+	}
+
+	driver := m.mergeDriver(name)
+	if driver == binaryMergeDriver || !isMergeableFile(ours.Mode) || !isMergeableFile(theirs.Mode) {
+		return merged, false, nil
+	}
+
+	var baseContent []byte
+	if inBase && isMergeableFile(base.Mode) {
+		var err error
+		if baseContent, err = m.blobContent(base.Hash); err != nil {
+			return merged, false, err
+		}
+	}
+
+	oursContent, err := m.blobContent(ours.Hash)
+	if err != nil {
+		return merged, false, err
+	}
+
+	theirsContent, err := m.blobContent(theirs.Hash)
+	if err != nil {
+		return merged, false, err
+	}
+
+	if driver != textMergeDriver {
+		isBinary, err := anyBinary(baseContent, oursContent, theirsContent)
+		if err != nil {
+			return merged, false, err
+		}
+
+		// Binary files can't be merged line by line, ours is kept.
+		if isBinary {
+			return merged, false, nil
+		}
+	}
+
+	content, conflicts := diff.Merge(
+		string(baseContent), string(oursContent), string(theirsContent),
+		m.oursLabel, m.theirsLabel,
+	)
+
+	if merged.Hash, err = m.writeBlob([]byte(content)); err != nil {
+		return merged, false, err
+	}
+
+	return merged, clean && conflicts == 0, nil
+}
+
+func anyBinary(contents ...[]byte) (bool, error) {
+	for _, content := range contents {
+		isBinary, err := binary.IsBinary(bytes.NewReader(content))
+		if err != nil || isBinary {
+			return isBinary, err
+		}
+	}
+
+	return false, nil
+}
+
+type mergeDriver int8
+
+const (
+	// defaultMergeDriver merges the files line by line, unless they are
+	// binary.
+	defaultMergeDriver mergeDriver = iota
+	// textMergeDriver always merges the files line by line.
+	textMergeDriver
+	// binaryMergeDriver never merges the files, ours is kept.
+	binaryMergeDriver
+)
+
+// mergeDriver returns the driver used to merge the content of the given path,
+// selected by its merge attribute. Custom drivers are not supported, the
+// default one is used for them, as git does for undefined drivers.
+func (m *treeMerger) mergeDriver(name string) mergeDriver {
+	if m.attributes == nil {
+		return defaultMergeDriver
+	}
+
+	results, _ := m.attributes.Match(strings.Split(name, "/"), []string{mergeAttr})
+	attr, ok := results[mergeAttr]
+	switch {
+	case !ok:
+		return defaultMergeDriver
+	case attr.IsUnset(), attr.IsValueSet() && attr.Value() == "binary":
+		return binaryMergeDriver
+	case attr.IsSet(), attr.IsValueSet() && attr.Value() == "text":
+		return textMergeDriver
+	default:
+		return defaultMergeDriver
+	}
+}
+
+// resolveDirectoryFileConflicts looks for paths that ended up being a file
+// and a directory at the same time. The directory is kept, while the file is
+// reported as conflicting.
+func (m *treeMerger) resolveDirectoryFileConflicts(res *treeMergeResult, b, o, t map[string]object.TreeEntry) {
+	conflicting := make(map[string]bool)
+	for _, c := range res.conflicts {
+		conflicting[c.Path] = true
+	}
+
+	for p := range res.entries {
+		for dir := path.Dir(p); dir != "."; dir = path.Dir(dir) {
+			if _, ok := res.entries[dir]; !ok {
+				continue
+			}
+
+			delete(res.entries, dir)
+			if !conflicting[dir] {
+				conflicting[dir] = true
+				res.conflicts = append(res.conflicts, newMergeConflict(dir, b, o, t))
+			}
+		}
+	}
+
+	sort.Slice(res.conflicts, func(i, j int) bool {
+		return res.conflicts[i].Path < res.conflicts[j].Path
+	})
+}
+
+func (m *treeMerger) blobContent(h plumbing.Hash) (content []byte, err error) {
+	blob, err := object.GetBlob(m.s, h)
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := blob.Reader()
+	if err != nil {
+		return nil, err
+	}
+
+	defer ioutil.CheckClose(r, &err)
+	return io.ReadAll(r)
+}
+
+func (m *treeMerger) writeBlob(content []byte) (h plumbing.Hash, err error) {
+	obj := m.s.NewEncodedObject()
+	obj.SetType(plumbing.BlobObject)
+	obj.SetSize(int64(len(content)))
+
+	w, err := obj.Writer()
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	if _, err := w.Write(content); err != nil {
+		_ = w.Close()
+		return plumbing.ZeroHash, err
+	}
+
+	if err := w.Close(); err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	return m.s.SetEncodedObject(obj)
+}
+
+// writeTree stores the merged entries as tree objects, returning the root
+// tree. The directories kept from ours are reused as they are.
+func (m *treeMerger) writeTree(res *treeMergeResult) (*object.Tree, error) {
+	idx := &index.Index{}
+	for _, entries := range []map[string]object.TreeEntry{res.entries, res.trees} {
+		for p, e := range entries {
+			idx.Entries = append(idx.Entries, &index.Entry{
+				Name: p,
+				Hash: e.Hash,
+				Mode: e.Mode,
+			})
+		}
+	}
+
+	sort.Slice(idx.Entries, func(i, j int) bool {
+		return idx.Entries[i].Name < idx.Entries[j].Name
+	})
+
+	h := &buildTreeHelper{s: m.s}
+	hash, err := h.BuildTree(idx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return object.GetTree(m.s, hash)
+}
+
+// flattenTree returns all the non-directory entries of a tree keyed by their
+// full path, which is also used as their Name.
+func flattenTree(t *object.Tree) (map[string]object.TreeEntry, error) {
+	entries := make(map[string]object.TreeEntry)
+	if t == nil {
+		return entries, nil
+	}
+
+	w := object.NewTreeWalker(t, true, nil)
+	defer w.Close()
+
+	for {
+		name, e, err := w.Next()
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		if e.Mode == filemode.Dir {
+			continue
+		}
+
+		e.Name = name
+		entries[name] = e
+	}
+
+	return entries, nil
+}
+
+// unionEntryNames returns the sorted names of the entries of the given trees,
+// which can be nil.
+func unionEntryNames(trees ...*object.Tree) []string {
+	seen := make(map[string]struct{})
+	var names []string
+	for _, t := range trees {
+		if t == nil {
+			continue
+		}
+
+		for _, e := range t.Entries {
+			if _, ok := seen[e.Name]; ok {
+				continue
+			}
+
+			seen[e.Name] = struct{}{}
+			names = append(names, e.Name)
+		}
+	}
+
+	sort.Strings(names)
+	return names
+}
+
+// treeEntry returns the entry with the given name of a tree, which can be nil.
+func treeEntry(t *object.Tree, name string) (object.TreeEntry, bool) {
+	if t == nil {
+		return object.TreeEntry{}, false
+	}
+
+	e, err := t.FindEntry(name)
+	if err != nil {
+		return object.TreeEntry{}, false
+	}
+
+	return *e, true
+}
+
+func unionPaths(entries ...map[string]object.TreeEntry) []string {
+	seen := make(map[string]struct{})
+	var paths []string
+	for _, m := range entries {
+		for p := range m {
+			if _, ok := seen[p]; ok {
+				continue
+			}
+
+			seen[p] = struct{}{}
+			paths = append(paths, p)
+		}
+	}
+
+	sort.Strings(paths)
+	return paths
+}
+
+func sameEntry(a object.TreeEntry, inA bool, b object.TreeEntry, inB bool) bool {
+	if inA != inB {
+		return false
+	}
+
+	return !inA || (a.Hash == b.Hash && a.Mode == b.Mode)
+}
+
+func isMergeableFile(m filemode.FileMode) bool {
+	return m.IsFile() && m != filemode.Symlink
+}
+
+func newMergeConflict(p string, b, o, t map[string]object.TreeEntry) MergeConflict {
+	c := MergeConflict{Path: p}
+	if e, ok := b[p]; ok {
+		c.Ancestor = &e
+	}
+
+	if e, ok := o[p]; ok {
+		c.Ours = &e
+	}
+
+	if e, ok := t[p]; ok {
+		c.Theirs = &e
+	}
+
+	return c
+}
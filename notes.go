@@ -0,0 +1,342 @@
+package git
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/go-git/go-git/v6/plumbing/filemode"
+	"github.com/go-git/go-git/v6/plumbing/object"
+)
+
+const (
+	// DefaultNotesRef is the reference where the notes are stored when no
+	// other is given nor configured in core.notesRef.
+	DefaultNotesRef plumbing.ReferenceName = "refs/notes/commits"
+
+	notesRefPrefix = "refs/notes/"
+	notesRefKey    = "notesRef"
+)
+
+var (
+	// ErrNoteNotFound is returned when an object doesn't have a note.
+	ErrNoteNotFound = errors.New("note not found")
+	// ErrNoteExists is returned when adding a note to an object which
+	// already has one, without NoteOptions.Force.
+	ErrNoteExists = errors.New("object already has a note")
+)
+
+// ReadNotes returns the notes stored in the given notes reference, as a map of
+// the hash of each annotated object to the hash of the blob of its note. If
+// ref is empty, the one configured in core.notesRef is used, or
+// DefaultNotesRef. The reference can be given in its short form, e.g.
+// "review" for refs/notes/review. A missing reference has no notes.
+func (r *Repository) ReadNotes(ref plumbing.ReferenceName) (map[plumbing.Hash]plumbing.Hash, error) {
+	ref, err := r.notesRef(ref)
+	if err != nil {
+		return nil, err
+	}
+
+	commit, err := r.referenceHash(ref)
+	if err != nil {
+		return nil, err
+	}
+
+	notes, _, err := r.readNotesTree(commit)
+	return notes, err
+}
+
+// Note returns the blob of the note of the object with the given hash, in the
+// given notes reference, see ReadNotes. ErrNoteNotFound is returned if the
+// object doesn't have a note.
+func (r *Repository) Note(ref plumbing.ReferenceName, h plumbing.Hash) (*object.Blob, error) {
+	notes, err := r.ReadNotes(ref)
+	if err != nil {
+		return nil, err
+	}
+
+	blob, ok := notes[h]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrNoteNotFound, h)
+	}
+
+	return r.BlobObject(blob)
+}
+
+// AddNote sets the content of the note of the object with the given hash, as
+// `git notes add`. A commit updating the notes tree is added to the notes
+// reference. The content is stored as it is.
+func (r *Repository) AddNote(h plumbing.Hash, content []byte, opts *NoteOptions) error {
+	if opts == nil {
+		opts = &NoteOptions{}
+	}
+
+	if err := opts.Validate(r); err != nil {
+		return err
+	}
+
+	if _, err := r.Storer.EncodedObject(plumbing.AnyObject, h); err != nil {
+		return fmt.Errorf("cannot annotate %s: %w", h, err)
+	}
+
+	return r.updateNotes(opts, "Notes added by 'git notes add'", func(notes map[plumbing.Hash]plumbing.Hash) error {
+		if _, ok := notes[h]; ok && !opts.Force {
+			return fmt.Errorf("%w: %s", ErrNoteExists, h)
+		}
+
+		blob, err := writeBlob(r.Storer, content)
+		if err != nil {
+			return err
+		}
+
+		notes[h] = blob
+		return nil
+	})
+}
+
+// RemoveNote removes the note of the object with the given hash, as
+// `git notes remove`. ErrNoteNotFound is returned if the object doesn't have
+// a note.
+func (r *Repository) RemoveNote(h plumbing.Hash, opts *NoteOptions) error {
+	if opts == nil {
+		opts = &NoteOptions{}
+	}
+
+	if err := opts.Validate(r); err != nil {
+		return err
+	}
+
+	return r.updateNotes(opts, "Notes removed by 'git notes remove'", func(notes map[plumbing.Hash]plumbing.Hash) error {
+		if _, ok := notes[h]; !ok {
+			return fmt.Errorf("%w: %s", ErrNoteNotFound, h)
+		}
+
+		delete(notes, h)
+		return nil
+	})
+}
+
+// updateNotes changes the notes of opts.Ref with the given function, and
+// commits the resulting notes tree on top of the reference.
+func (r *Repository) updateNotes(opts *NoteOptions, msg string, update func(map[plumbing.Hash]plumbing.Hash) error) error {
+	old, err := r.referenceHash(opts.Ref)
+	if err != nil {
+		return err
+	}
+
+	notes, others, err := r.readNotesTree(old)
+	if err != nil {
+		return err
+	}
+
+	if err := update(notes); err != nil {
+		return err
+	}
+
+	tree, err := r.writeNotesTree(notes, others)
+	if err != nil {
+		return err
+	}
+
+	commit := &object.Commit{
+		Author:    *opts.Author,
+		Committer: *opts.Committer,
+		Message:   msg + "\n",
+		TreeHash:  tree,
+	}
+
+	if !old.IsZero() {
+		commit.ParentHashes = []plumbing.Hash{old}
+	}
+
+	obj := r.Storer.NewEncodedObject()
+	if err := commit.Encode(obj); err != nil {
+		return err
+	}
+
+	h, err := r.Storer.SetEncodedObject(obj)
+	if err != nil {
+		return err
+	}
+
+	if err := r.Storer.SetReference(plumbing.NewHashReference(opts.Ref, h)); err != nil {
+		return err
+	}
+
+	return r.logRefUpdate(opts.Ref, old, h, opts.Committer, "notes: "+msg)
+}
+
+// readNotesTree reads the tree of the given notes commit, returning the notes
+// and the entries which aren't notes, keyed by their path, which are kept as
+// they are when the tree is written again.
+func (r *Repository) readNotesTree(commit plumbing.Hash) (map[plumbing.Hash]plumbing.Hash, map[string]object.TreeEntry, error) {
+	notes := make(map[plumbing.Hash]plumbing.Hash)
+	others := make(map[string]object.TreeEntry)
+	if commit.IsZero() {
+		return notes, others, nil
+	}
+
+	objs, err := r.objectStorer()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	c, err := object.GetCommit(objs, commit)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	t, err := c.Tree()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	w := object.NewTreeWalker(t, true, nil)
+	defer w.Close()
+
+	for {
+		name, e, err := w.Next()
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if e.Mode == filemode.Dir {
+			continue
+		}
+
+		if h, ok := notePathHash(name, e.Hash.HexSize()); ok && e.Mode.IsRegular() {
+			notes[h] = e.Hash
+			continue
+		}
+
+		others[name] = e
+	}
+
+	return notes, others, nil
+}
+
+// notePathHash returns the hash of the object annotated by the note at the
+// given path, the hex hash split in directories of two digits.
+func notePathHash(path string, hexSize int) (plumbing.Hash, bool) {
+	dirs := strings.Split(path, "/")
+	for _, d := range dirs[:len(dirs)-1] {
+		if len(d) != 2 {
+			return plumbing.ZeroHash, false
+		}
+	}
+
+	name := strings.Join(dirs, "")
+	if len(name) != hexSize {
+		return plumbing.ZeroHash, false
+	}
+
+	return plumbing.FromHex(name)
+}
+
+// writeNotesTree stores the notes tree holding the given notes and other
+// entries, returning its hash.
+func (r *Repository) writeNotesTree(notes map[plumbing.Hash]plumbing.Hash, others map[string]object.TreeEntry) (plumbing.Hash, error) {
+	keys := make([]string, 0, len(notes))
+	for h := range notes {
+		keys = append(keys, h.String())
+	}
+
+	sort.Strings(keys)
+
+	a := NewStagingArea(r.Storer)
+	for p, e := range others {
+		if err := a.Add(p, e.Mode, e.Hash); err != nil {
+			return plumbing.ZeroHash, err
+		}
+	}
+
+	for _, p := range notePaths(keys, 0, 0, nil) {
+		h, _ := plumbing.FromHex(strings.ReplaceAll(p, "/", ""))
+		if err := a.Add(p, filemode.Regular, notes[h]); err != nil {
+			return plumbing.ZeroHash, err
+		}
+	}
+
+	return a.Write()
+}
+
+// notePaths returns the paths of the notes of the given sorted hex hashes,
+// which share their first n digits, with the same fanout as git. The hashes
+// are split in directories of two digits, one level more each time every one
+// of the 16 possible next digits is shared by two notes or more at a byte
+// boundary.
+func notePaths(keys []string, n, fanout int, paths []string) []string {
+	var groups [][]string
+	for start := 0; start < len(keys); {
+		end := start + 1
+		for end < len(keys) && keys[end][n] == keys[start][n] {
+			end++
+		}
+
+		groups = append(groups, keys[start:end])
+		start = end
+	}
+
+	if n%2 == 0 && n <= 2*fanout && len(groups) == 16 {
+		full := true
+		for _, g := range groups {
+			full = full && len(g) > 1
+		}
+
+		if full {
+			fanout++
+		}
+	}
+
+	for _, g := range groups {
+		if len(g) > 1 {
+			paths = notePaths(g, n+1, fanout, paths)
+			continue
+		}
+
+		var p strings.Builder
+		for i := range fanout {
+			p.WriteString(g[0][2*i : 2*i+2])
+			p.WriteByte('/')
+		}
+
+		p.WriteString(g[0][2*fanout:])
+		paths = append(paths, p.String())
+	}
+
+	return paths
+}
+
+// notesRef returns the full name of the given notes reference, or of the
+// default one if empty.
+func (r *Repository) notesRef(ref plumbing.ReferenceName) (plumbing.ReferenceName, error) {
+	if ref == "" {
+		cfg, err := r.Config()
+		if err != nil {
+			return "", err
+		}
+
+		ref = plumbing.ReferenceName(cfg.Raw.Section("core").Option(notesRefKey))
+		if ref == "" {
+			return DefaultNotesRef, nil
+		}
+	}
+
+	name := ref.String()
+	switch {
+	case strings.HasPrefix(name, notesRefPrefix):
+	case strings.HasPrefix(name, "notes/"):
+		name = "refs/" + name
+	default:
+		name = notesRefPrefix + name
+	}
+
+	return plumbing.ReferenceName(name), nil
+}
@@ -0,0 +1,276 @@
+package git
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/go-git/go-billy/v5/util"
+	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/go-git/go-git/v6/plumbing/filemode"
+	"github.com/go-git/go-git/v6/plumbing/object"
+	"github.com/go-git/go-git/v6/storage/memory"
+	"github.com/stretchr/testify/suite"
+)
+
+type NotesSuite struct {
+	suite.Suite
+	r *Repository
+
+	first, second plumbing.Hash
+}
+
+func TestNotesSuite(t *testing.T) {
+	suite.Run(t, new(NotesSuite))
+}
+
+func (s *NotesSuite) SetupTest() {
+	var err error
+	s.r, err = Init(memory.NewStorage(), WithWorkTree(memfs.New()))
+	s.Require().NoError(err)
+
+	w, err := s.r.Worktree()
+	s.Require().NoError(err)
+
+	for i, h := range []*plumbing.Hash{&s.first, &s.second} {
+		s.Require().NoError(util.WriteFile(w.Filesystem, "file", []byte{byte(i)}, 0644))
+		_, err = w.Add("file")
+		s.Require().NoError(err)
+
+		*h, err = w.Commit(fmt.Sprint(i), &CommitOptions{Author: defaultSignature()})
+		s.Require().NoError(err)
+	}
+}
+
+func (s *NotesSuite) noteContent(ref plumbing.ReferenceName, h plumbing.Hash) string {
+	b, err := s.r.Note(ref, h)
+	s.Require().NoError(err)
+
+	r, err := b.Reader()
+	s.Require().NoError(err)
+	defer r.Close()
+
+	content, err := io.ReadAll(r)
+	s.Require().NoError(err)
+	return string(content)
+}
+
+func (s *NotesSuite) TestAddNote() {
+	notes, err := s.r.ReadNotes("")
+	s.Require().NoError(err)
+	s.Empty(notes)
+
+	opts := &NoteOptions{Author: defaultSignature()}
+	s.Require().NoError(s.r.AddNote(s.first, []byte("first note\n"), opts))
+	s.Equal(DefaultNotesRef, opts.Ref)
+	s.Require().NoError(s.r.AddNote(s.second, []byte("second note\n"), &NoteOptions{Author: defaultSignature()}))
+
+	notes, err = s.r.ReadNotes("")
+	s.Require().NoError(err)
+	s.Len(notes, 2)
+	s.Equal("first note\n", s.noteContent("", s.first))
+	s.Equal("second note\n", s.noteContent(DefaultNotesRef, s.second))
+
+	ref, err := s.r.Reference(DefaultNotesRef, false)
+	s.Require().NoError(err)
+
+	c, err := s.r.CommitObject(ref.Hash())
+	s.Require().NoError(err)
+	s.Equal("Notes added by 'git notes add'\n", c.Message)
+	s.Equal(1, c.NumParents())
+
+	tree, err := c.Tree()
+	s.Require().NoError(err)
+	_, err = tree.FindEntry(s.first.String())
+	s.NoError(err)
+
+	entries, err := s.r.Reflog(DefaultNotesRef)
+	s.Require().NoError(err)
+	s.Len(entries, 2)
+	s.Equal("notes: Notes added by 'git notes add'", entries[0].Message)
+}
+
+func (s *NotesSuite) TestAddNoteExisting() {
+	s.Require().NoError(s.r.AddNote(s.first, []byte("old"), &NoteOptions{Author: defaultSignature()}))
+
+	err := s.r.AddNote(s.first, []byte("new"), &NoteOptions{Author: defaultSignature()})
+	s.ErrorIs(err, ErrNoteExists)
+	s.Equal("old", s.noteContent("", s.first))
+
+	s.Require().NoError(s.r.AddNote(s.first, []byte("new"), &NoteOptions{Author: defaultSignature(), Force: true}))
+	s.Equal("new", s.noteContent("", s.first))
+}
+
+func (s *NotesSuite) TestAddNoteMissingObject() {
+	err := s.r.AddNote(plumbing.NewHash("0000000000000000000000000000000000000001"), []byte("note"), &NoteOptions{Author: defaultSignature()})
+	s.ErrorIs(err, plumbing.ErrObjectNotFound)
+}
+
+func (s *NotesSuite) TestRemoveNote() {
+	s.Require().NoError(s.r.AddNote(s.first, []byte("first"), &NoteOptions{Author: defaultSignature()}))
+	s.Require().NoError(s.r.AddNote(s.second, []byte("second"), &NoteOptions{Author: defaultSignature()}))
+
+	s.Require().NoError(s.r.RemoveNote(s.first, &NoteOptions{Author: defaultSignature()}))
+	s.ErrorIs(s.r.RemoveNote(s.first, &NoteOptions{Author: defaultSignature()}), ErrNoteNotFound)
+
+	_, err := s.r.Note("", s.first)
+	s.ErrorIs(err, ErrNoteNotFound)
+
+	notes, err := s.r.ReadNotes("")
+	s.Require().NoError(err)
+	s.Equal([]plumbing.Hash{s.second}, keys(notes))
+
+	ref, err := s.r.Reference(DefaultNotesRef, false)
+	s.Require().NoError(err)
+
+	c, err := s.r.CommitObject(ref.Hash())
+	s.Require().NoError(err)
+	s.Equal("Notes removed by 'git notes remove'\n", c.Message)
+}
+
+func (s *NotesSuite) TestCustomRef() {
+	s.Require().NoError(s.r.AddNote(s.first, []byte("review"), &NoteOptions{Ref: "review", Author: defaultSignature()}))
+	s.Require().NoError(s.r.AddNote(s.second, []byte("review"), &NoteOptions{Ref: "notes/review", Author: defaultSignature()}))
+
+	_, err := s.r.Reference("refs/notes/review", false)
+	s.Require().NoError(err)
+
+	notes, err := s.r.ReadNotes("refs/notes/review")
+	s.Require().NoError(err)
+	s.Len(notes, 2)
+
+	notes, err = s.r.ReadNotes("")
+	s.Require().NoError(err)
+	s.Empty(notes)
+
+	cfg, err := s.r.Config()
+	s.Require().NoError(err)
+	cfg.Raw.Section("core").SetOption("notesRef", "refs/notes/review")
+	s.Require().NoError(s.r.SetConfig(cfg))
+
+	notes, err = s.r.ReadNotes("")
+	s.Require().NoError(err)
+	s.Len(notes, 2)
+}
+
+func (s *NotesSuite) TestFanout() {
+	// Every one of the 16 first digits is shared by two notes, which makes
+	// git use a first level of directories.
+	var objects []plumbing.Hash
+	digits := make(map[byte]int)
+	for i := 0; len(digits) < 16 || !sharedDigits(digits); i++ {
+		h, err := writeBlob(s.r.Storer, []byte(fmt.Sprint(i)))
+		s.Require().NoError(err)
+		objects = append(objects, h)
+		digits[h.String()[0]]++
+	}
+
+	for _, h := range objects {
+		s.Require().NoError(s.r.AddNote(h, []byte("note"), &NoteOptions{Author: defaultSignature()}))
+	}
+
+	ref, err := s.r.Reference(DefaultNotesRef, false)
+	s.Require().NoError(err)
+
+	c, err := s.r.CommitObject(ref.Hash())
+	s.Require().NoError(err)
+
+	tree, err := c.Tree()
+	s.Require().NoError(err)
+	for _, e := range tree.Entries {
+		s.Len(e.Name, 2)
+		s.Equal(filemode.Dir, e.Mode)
+	}
+
+	for _, h := range objects {
+		name := h.String()
+		_, err := tree.FindEntry(name[:2] + "/" + name[2:])
+		s.NoError(err, name)
+	}
+
+	notes, err := s.r.ReadNotes("")
+	s.Require().NoError(err)
+	s.Len(notes, len(objects))
+}
+
+func (s *NotesSuite) TestNotePaths() {
+	var keys []string
+	for _, d := range "0123456789abcdef" {
+		keys = append(keys,
+			string(d)+"0"+strings.Repeat("1", 38),
+			string(d)+"1"+strings.Repeat("2", 38),
+		)
+	}
+
+	keys = append(keys, "f"+strings.Repeat("3", 39))
+
+	paths := notePaths(keys, 0, 0, nil)
+	s.Len(paths, len(keys))
+	s.Equal("00/"+strings.Repeat("1", 38), paths[0])
+	s.Equal("f3/"+strings.Repeat("3", 38), paths[len(paths)-1])
+
+	s.Equal([]string{keys[0], keys[2]}, notePaths([]string{keys[0], keys[2]}, 0, 0, nil))
+
+	h, ok := notePathHash("00/"+strings.Repeat("1", 38), 40)
+	s.True(ok)
+	s.Equal(keys[0], h.String())
+
+	_, ok = notePathHash("000/"+strings.Repeat("1", 37), 40)
+	s.False(ok)
+	_, ok = notePathHash("README", 40)
+	s.False(ok)
+}
+
+func (s *NotesSuite) TestNonNoteEntriesAreKept() {
+	a := NewStagingArea(s.r.Storer)
+	_, err := a.AddContent("README", filemode.Regular, []byte("notes"))
+	s.Require().NoError(err)
+
+	tree, err := a.Write()
+	s.Require().NoError(err)
+
+	c := &object.Commit{Author: *defaultSignature(), Committer: *defaultSignature(), TreeHash: tree, Message: "notes"}
+	obj := s.r.Storer.NewEncodedObject()
+	s.Require().NoError(c.Encode(obj))
+	h, err := s.r.Storer.SetEncodedObject(obj)
+	s.Require().NoError(err)
+	s.Require().NoError(s.r.Storer.SetReference(plumbing.NewHashReference(DefaultNotesRef, h)))
+
+	s.Require().NoError(s.r.AddNote(s.first, []byte("note"), &NoteOptions{Author: defaultSignature()}))
+
+	ref, err := s.r.Reference(DefaultNotesRef, false)
+	s.Require().NoError(err)
+	c, err = s.r.CommitObject(ref.Hash())
+	s.Require().NoError(err)
+	s.Equal([]plumbing.Hash{h}, c.ParentHashes)
+
+	t, err := c.Tree()
+	s.Require().NoError(err)
+	_, err = t.FindEntry("README")
+	s.NoError(err)
+
+	notes, err := s.r.ReadNotes("")
+	s.Require().NoError(err)
+	s.Len(notes, 1)
+}
+
+func sharedDigits(digits map[byte]int) bool {
+	for _, n := range digits {
+		if n < 2 {
+			return false
+		}
+	}
+
+	return true
+}
+
+func keys(m map[plumbing.Hash]plumbing.Hash) []plumbing.Hash {
+	var result []plumbing.Hash
+	for k := range m {
+		result = append(result, k)
+	}
+
+	return result
+}
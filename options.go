@@ -3,17 +3,22 @@ package git
 import (
 	"errors"
 	"fmt"
+	"net/http"
+	"path"
 	"regexp"
+	"slices"
 	"strings"
 	"time"
 
 	"github.com/ProtonMail/go-crypto/openpgp"
 	"github.com/go-git/go-git/v6/config"
 	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/go-git/go-git/v6/plumbing/cache"
 	"github.com/go-git/go-git/v6/plumbing/object"
 	"github.com/go-git/go-git/v6/plumbing/protocol/packp"
 	"github.com/go-git/go-git/v6/plumbing/protocol/packp/sideband"
 	"github.com/go-git/go-git/v6/plumbing/transport"
+	transporthttp "github.com/go-git/go-git/v6/plumbing/transport/http"
 )
 
 // SubmoduleRecursivity defines how depth will affect any submodule recursive
@@ -56,6 +61,13 @@ type CloneOptions struct {
 	NoCheckout bool
 	// Limit fetching to the specified number of commits.
 	Depth int
+	// ShallowSince, if not zero, limits fetching to the commits more recent
+	// than it, as `git clone --shallow-since`. It can't be used with Depth.
+	ShallowSince time.Time
+	// ShallowExclude limits fetching to the commits not reachable from the
+	// given remote branches or tags, as `git clone --shallow-exclude`. It
+	// can be used with ShallowSince, but not with Depth.
+	ShallowExclude []string
 	// RecurseSubmodules after the clone is created, initialize all submodules
 	// within, using their default settings. This option is ignored if the
 	// cloned repository does not have a worktree.
@@ -66,6 +78,8 @@ type CloneOptions struct {
 	// Progress is where the human readable information sent by the server is
 	// stored, if nil nothing is stored and the capability (if supported)
 	// no-progress, is sent to the server to avoid send this information.
+	// A sideband.StructuredProgress can be used to receive the progress of
+	// each stage as a sideband.ProgressReport instead.
 	Progress sideband.Progress
 	// Tags describe how the tags will be fetched from the remote repository,
 	// by default is AllTags.
@@ -86,6 +100,11 @@ type CloneOptions struct {
 	// [Reference]: https://git-scm.com/docs/git-clone#Documentation/git-clone.txt---shared
 	Shared bool
 	// Filter requests that the server to send only a subset of the objects.
+	// The remote is recorded as a promisor, and the objects omitted are
+	// fetched on demand when read through the repository, e.g. by
+	// Repository.BlobObject or on checkout. The clone
+	// fails with transport.ErrFilterNotSupported if the server doesn't
+	// support filters.
 	// See https://git-scm.com/docs/git-clone#Documentation/git-clone.txt-code--filterltfilter-specgtcode
 	Filter packp.Filter
 	// Bare determines whether the repository will have a worktree (non-bare)
@@ -110,6 +129,13 @@ const (
 	//
 	// This is the default option.
 	FastForwardMerge MergeStrategy = iota
+	// RecursiveMerge represents a three-way merge between the current branch
+	// and the branch being merged, using their merge base as the common
+	// ancestor. When several merge bases exist, they are first merged into a
+	// virtual ancestor. A fast-forward is still performed when possible.
+	//
+	// This strategy is only supported by Worktree.Merge.
+	RecursiveMerge
 )
 
 // Validate validates the fields and sets the default values.
@@ -130,6 +156,10 @@ func (o *CloneOptions) Validate() error {
 		o.Tags = plumbing.AllTags
 	}
 
+	if o.Depth > 0 && (!o.ShallowSince.IsZero() || len(o.ShallowExclude) > 0) {
+		return ErrDepthExclusive
+	}
+
 	return nil
 }
 
@@ -153,6 +183,8 @@ type PullOptions struct {
 	// Progress is where the human readable information sent by the server is
 	// stored, if nil nothing is stored and the capability (if supported)
 	// no-progress, is sent to the server to avoid send this information.
+	// A sideband.StructuredProgress can be used to receive the progress of
+	// each stage as a sideband.ProgressReport instead.
 	Progress sideband.Progress
 	// Force allows the pull to update a local branch even when the remote
 	// branch does not descend from it.
@@ -201,11 +233,26 @@ type FetchOptions struct {
 	// Depth limit fetching to the specified number of commits from the tip of
 	// each remote branch history.
 	Depth int
+	// Deepen fetches the specified number of commits past the current
+	// shallow boundary of a shallow repository, as `git fetch --deepen`.
+	Deepen int
+	// Unshallow fetches the whole history of a shallow repository, making it
+	// a complete one, as `git fetch --unshallow`.
+	Unshallow bool
+	// ShallowSince, if not zero, deepens or shortens the history to the
+	// commits more recent than it, as `git fetch --shallow-since`.
+	ShallowSince time.Time
+	// ShallowExclude deepens or shortens the history to exclude the commits
+	// reachable from the given remote branches or tags, as
+	// `git fetch --shallow-exclude`. It can be used with ShallowSince.
+	ShallowExclude []string
 	// Auth credentials, if required, to use with the remote repository.
 	Auth transport.AuthMethod
 	// Progress is where the human readable information sent by the server is
 	// stored, if nil nothing is stored and the capability (if supported)
 	// no-progress, is sent to the server to avoid send this information.
+	// A sideband.StructuredProgress can be used to receive the progress of
+	// each stage as a sideband.ProgressReport instead.
 	Progress sideband.Progress
 	// Tags describe how the tags will be fetched from the remote repository,
 	// by default is TagFollowing.
@@ -222,17 +269,52 @@ type FetchOptions struct {
 	// Prune specify that local refs that match given RefSpecs and that do
 	// not exist remotely will be removed.
 	Prune bool
+	// PruneTags, along with Prune, also removes the local tags that do not
+	// exist remotely, as if +refs/tags/*:refs/tags/* was given as RefSpec,
+	// as `git fetch --prune --prune-tags` does. It's ignored without Prune.
+	PruneTags bool
+	// Pruned, if not nil, is called for each local reference removed by
+	// Prune, with the hash it had, e.g. to log them.
+	Pruned func(*plumbing.Reference)
 	// Filter requests that the server to send only a subset of the objects.
 	// See https://git-scm.com/docs/git-clone#Documentation/git-clone.txt-code--filterltfilter-specgtcode
 	Filter packp.Filter
+	// NegotiationRounds limits the number of rounds of haves sent to the
+	// server while negotiating the objects to fetch. Each round sends up to
+	// 32 commits reachable from the local references, most recent first.
+	// Defaults to transport.DefaultNegotiationRounds, a negative value means
+	// no limit.
+	NegotiationRounds int
+	// DeltaBaseCache, if not nil, keeps the delta bases resolved while
+	// decoding the received packfile, so that the fetches sharing it don't
+	// resolve them again, e.g. a cache.NewObjectLRU shared by the fetches of
+	// a process. It isn't used by the storers writing the packfile as it is,
+	// such as the filesystem one.
+	DeltaBaseCache cache.Object
 }
 
+// ErrDepthExclusive is returned when more than one of Depth, Deepen,
+// Unshallow and ShallowSince or ShallowExclude is set, as git does.
+var ErrDepthExclusive = errors.New("Depth, Deepen, Unshallow and ShallowSince or ShallowExclude are mutually exclusive")
+
 // Validate validates the fields and sets the default values.
 func (o *FetchOptions) Validate() error {
 	if o.RemoteName == "" {
 		o.RemoteName = DefaultRemoteName
 	}
 
+	var depths int
+	shallow := !o.ShallowSince.IsZero() || len(o.ShallowExclude) > 0
+	for _, set := range []bool{o.Depth > 0, o.Deepen > 0, o.Unshallow, shallow} {
+		if set {
+			depths++
+		}
+	}
+
+	if depths > 1 {
+		return ErrDepthExclusive
+	}
+
 	if o.Tags == plumbing.InvalidTagMode {
 		o.Tags = plumbing.TagFollowing
 	}
@@ -264,7 +346,9 @@ type PushOptions struct {
 	// Auth credentials, if required, to use with the remote repository.
 	Auth transport.AuthMethod
 	// Progress is where the human readable information sent by the server is
-	// stored, if nil nothing is stored.
+	// stored, if nil nothing is stored. A sideband.StructuredProgress can be
+	// used to receive the progress of each stage as a sideband.ProgressReport
+	// instead.
 	Progress sideband.Progress
 	// Prune specify that remote refs that match given RefSpecs and that do
 	// not exist locally will be removed.
@@ -284,12 +368,37 @@ type PushOptions struct {
 	FollowTags bool
 	// ForceWithLease allows a force push as long as the remote ref adheres to a "lease"
 	ForceWithLease *ForceWithLease
-	// PushOptions sets options to be transferred to the server during push.
+	// Options are the push options transferred to the server during push,
+	// consumed by its hooks, as `git push --push-option`. The push fails with
+	// transport.ErrPushOptionsNotSupported if the server doesn't support them.
 	Options []string
-	// Atomic sets option to be an atomic push
+	// Atomic makes the server update all the references or none of them, as
+	// `git push --atomic`. When one update fails, the error returned is the
+	// one of the reference that caused the push to fail. The push fails with
+	// transport.ErrAtomicPushNotSupported if the server doesn't support it.
 	Atomic bool
 	// ProxyOptions provides info required for connecting to a proxy.
 	ProxyOptions transport.ProxyOptions
+	// RunHooks runs the pre-push hook of the repository, as git does, before
+	// sending anything to the remote. The push is aborted with ErrHookFailed
+	// if the hook fails. Hooks aren't run by default.
+	RunHooks bool
+	// HookExecutor runs the hooks when RunHooks is set. If nil,
+	// DefaultHookExecutor is used.
+	HookExecutor HookExecutor
+	// SignKey denotes a key to sign the push with, as `git push --signed`,
+	// sending a push certificate which records the reference updates and the
+	// nonce given by the server. A nil value here means the push isn't
+	// signed. The push fails with transport.ErrPushCertNotSupported if the
+	// server doesn't support signed pushes.
+	SignKey *openpgp.Entity
+	// Signer denotes a cryptographic signer to sign the push certificate
+	// with, as an SSH one. Takes precedence over SignKey.
+	Signer Signer
+	// Pusher is the identity recorded in the push certificate of a signed
+	// push. If nil, the committer's Name and Email are read from the config,
+	// and time.Now it's used as When.
+	Pusher *object.Signature
 }
 
 // ForceWithLease sets fields on the lease
@@ -305,6 +414,9 @@ type ForceWithLease struct {
 	Hash plumbing.Hash
 }
 
+// ErrNegativeRefSpecPush is returned when a negative refspec is given to push.
+var ErrNegativeRefSpecPush = errors.New("negative refspecs are not supported by push")
+
 // Validate validates the fields and sets the default values.
 func (o *PushOptions) Validate() error {
 	if o.RemoteName == "" {
@@ -321,6 +433,10 @@ func (o *PushOptions) Validate() error {
 		if err := r.Validate(); err != nil {
 			return err
 		}
+
+		if r.IsNegative() {
+			return fmt.Errorf("%w: %q", ErrNegativeRefSpecPush, r.String())
+		}
 	}
 
 	return nil
@@ -342,11 +458,20 @@ type SubmoduleUpdateOptions struct {
 	// Depth limit fetching to the specified number of commits from the tip of
 	// each remote branch history.
 	Depth int
+	// Jobs is the number of submodules fetched concurrently by
+	// Submodules.Update, as `git submodule update --jobs`. If empty, they're
+	// fetched one at a time.
+	Jobs int
+	// FailFast makes Submodules.Update stop at the first submodule failing to
+	// update. Otherwise, the other submodules are updated anyway and the
+	// errors are reported in a *SubmoduleUpdateError.
+	FailFast bool
 }
 
 var (
 	ErrBranchHashExclusive  = errors.New("Branch and Hash are mutually exclusive")
 	ErrCreateRequiresBranch = errors.New("Branch is mandatory when Create is used")
+	ErrCreateWithPathspecs  = errors.New("Create and Pathspecs are mutually exclusive")
 )
 
 // CheckoutOptions describes how a checkout operation should be performed.
@@ -369,6 +494,48 @@ type CheckoutOptions struct {
 	Keep bool
 	// SparseCheckoutDirectories
 	SparseCheckoutDirectories []string
+	// SparsePatterns restricts the files checked out to the ones matching
+	// these patterns. The index still records every entry, setting the
+	// skip-worktree bit on the ones not checked out. By default, the patterns
+	// use the full syntax of the sparse-checkout file, the same one as
+	// .gitignore, see SparseCone for the cone mode.
+	SparsePatterns []string
+	// SparseCone makes SparsePatterns be handled as a list of directories, as
+	// the cone mode of git does. Those directories are checked out
+	// recursively, along with the files directly contained by the root and
+	// by any of their parent directories.
+	SparseCone bool
+	// Pathspecs, if not empty, makes the checkout restore only the files
+	// matching these pathspecs, in the index and the working tree, from the
+	// commit given by Hash or Branch, or from HEAD if none is given. HEAD is
+	// not updated, as `git checkout <commit> -- <pathspec>` does. See the
+	// pathspec package for the supported syntax.
+	Pathspecs []string
+	// Progress, if it is a sideband.ProgressReporter, receives the progress
+	// of the files updated in the working tree.
+	Progress sideband.Progress
+	// FileProgress, if not nil, is called for each file updated in the
+	// working tree. Returning an error stops the checkout, which is resumed
+	// by checking out the same commit again: only the files not matching it
+	// yet are then updated.
+	FileProgress func(FileUpdate) error
+	// NoCheckout, if true, updates HEAD and the index without updating the
+	// files of the working tree. The files missing from the working tree are
+	// then reported as deleted by the status, as with `git checkout` after
+	// `git worktree add --no-checkout`. Force and Keep are ignored.
+	NoCheckout bool
+}
+
+// FileUpdate describes a file updated in the working tree by a checkout or a
+// reset.
+type FileUpdate struct {
+	// Path is the path of the file, relative to the root of the working tree.
+	Path string
+	// Removed is true if the file was removed, instead of written.
+	Removed bool
+	// Current is the number of files updated so far, this one included, out
+	// of Total.
+	Current, Total int
 }
 
 // Validate validates the fields and sets the default values.
@@ -381,6 +548,15 @@ func (o *CheckoutOptions) Validate() error {
 		return ErrCreateRequiresBranch
 	}
 
+	if len(o.Pathspecs) > 0 {
+		if o.Create {
+			return ErrCreateWithPathspecs
+		}
+
+		// The files are restored from HEAD by default.
+		return nil
+	}
+
 	if o.Branch == "" {
 		o.Branch = plumbing.Master
 	}
@@ -431,6 +607,19 @@ type ResetOptions struct {
 
 	// SkipSparseDirValidation will skip the validation for SparseDirs.
 	SkipSparseDirValidation bool
+
+	// SparsePatterns specifies which files should be checked out, see
+	// CheckoutOptions.SparsePatterns.
+	SparsePatterns []string
+	// SparseCone makes SparsePatterns be handled as a list of directories,
+	// see CheckoutOptions.SparseCone.
+	SparseCone bool
+	// Progress, if it is a sideband.ProgressReporter, receives the progress
+	// of the files updated in the working tree.
+	Progress sideband.Progress
+	// FileProgress, if not nil, is called for each file updated in the
+	// working tree, see CheckoutOptions.FileProgress.
+	FileProgress func(FileUpdate) error
 }
 
 // Validate validates the fields and sets the default values.
@@ -479,6 +668,16 @@ type LogOptions struct {
 	// set Order=LogOrderBSF for Breadth-first search
 	Order LogOrder
 
+	// CommitterTimeSeenWindow, when set with Order=LogOrderCommitterTime,
+	// walks the history remembering only the last CommitterTimeSeenWindow
+	// commits returned to skip the ones already seen, instead of every commit
+	// returned. The commits queued to be returned are not limited by it, they
+	// grow with the number of branches walked in parallel, not with the length
+	// of the history. A commit may be returned more than once when its
+	// committer time is older than the one of its parents, see
+	// object.NewCommitIterCTimeBounded. It is ignored if All is set.
+	CommitterTimeSeenWindow int
+
 	// Show only those commits in which the specified file was inserted/updated.
 	// It is equivalent to running `git log -- <file-name>`.
 	// this field is kept for compatibility, it can be replaced with PathFilter
@@ -502,9 +701,18 @@ type LogOptions struct {
 	// Show commits older than a specific date.
 	// It is equivalent to running `git log --until <date>` or `git log --before <date>`.
 	Until *time.Time
+
+	// MailMap, if not nil, replaces the author and committer of the commits
+	// by their canonical ones. It is equivalent to running
+	// `git log --use-mailmap`, see Repository.MailMap to load it.
+	MailMap *object.MailMap
 }
 
-var ErrMissingAuthor = errors.New("author field is required")
+var (
+	ErrMissingAuthor   = errors.New("author field is required")
+	ErrDuplicateParent = errors.New("duplicate parent")
+	ErrInvalidParent   = errors.New("parent is not an existing commit")
+)
 
 // AddOptions describes how an `add` operation should be performed
 type AddOptions struct {
@@ -520,10 +728,14 @@ type AddOptions struct {
 	// directory path, all directory contents are added to the index recursively.
 	Glob string
 	// SkipStatus adds the path with no status check. This option is relevant only
-	// when the `Path` option is specified and does not apply when the `All` option is used.
+	// when the `Path` option is specified and can't be used with the `All` option.
 	// Notice that when passing an ignored path it will be added anyway.
 	// When true it can speed up adding files to the worktree in very large repositories.
 	SkipStatus bool
+	// Pathspecs adds the paths matching these pathspecs, see the pathspec
+	// package for the supported syntax. The files deleted from the working
+	// tree are only removed from the index when All is used.
+	Pathspecs []string
 }
 
 // Validate validates the fields and sets the default values.
@@ -532,6 +744,14 @@ func (o *AddOptions) Validate(r *Repository) error {
 		return fmt.Errorf("fields Path and Glob are mutual exclusive")
 	}
 
+	if len(o.Pathspecs) > 0 && (o.Path != "" || o.Glob != "") {
+		return fmt.Errorf("field Pathspecs is mutual exclusive with Path and Glob")
+	}
+
+	if o.SkipStatus && o.All {
+		return fmt.Errorf("fields SkipStatus and All are mutual exclusive")
+	}
+
 	return nil
 }
 
@@ -551,7 +771,10 @@ type CommitOptions struct {
 	// nil the Author signature is used.
 	Committer *object.Signature
 	// Parents are the parents commits for the new commit, by default when
-	// len(Parents) is zero, the hash of HEAD reference is used.
+	// len(Parents) is zero, the hash of HEAD reference is used, followed by
+	// the hash of MERGE_HEAD when a merge is in progress. Any number of
+	// parents can be given, e.g. to create an octopus merge, they must be
+	// distinct existing commits and are written in the given order.
 	Parents []plumbing.Hash
 	// SignKey denotes a key to sign the commit with. A nil value here means the
 	// commit will not be signed. The private key must be present and already
@@ -564,6 +787,14 @@ type CommitOptions struct {
 	// Amend will create a new commit object and replace the commit that HEAD currently
 	// points to. Cannot be used with All nor Parents.
 	Amend bool
+	// RunHooks runs the pre-commit and commit-msg hooks of the repository,
+	// found in core.hooksPath or in its hooks directory, as git does. The
+	// commit is aborted with ErrHookFailed if one of them fails, and its
+	// message is the one left by commit-msg. Hooks aren't run by default.
+	RunHooks bool
+	// HookExecutor runs the hooks when RunHooks is set. If nil,
+	// DefaultHookExecutor is used.
+	HookExecutor HookExecutor
 }
 
 // Validate validates the fields and sets the default values.
@@ -576,6 +807,10 @@ func (o *CommitOptions) Validate(r *Repository) error {
 		return errors.New("parents cannot be used with amend")
 	}
 
+	if err := o.validateParents(r); err != nil {
+		return err
+	}
+
 	if o.Author == nil {
 		if err := o.loadConfigAuthorAndCommitter(r); err != nil {
 			return err
@@ -595,6 +830,34 @@ func (o *CommitOptions) Validate(r *Repository) error {
 		if head != nil {
 			o.Parents = []plumbing.Hash{head.Hash()}
 		}
+
+		if !o.Amend {
+			mergeHead, err := r.Storer.Reference(plumbing.MergeHead)
+			if err != nil && err != plumbing.ErrReferenceNotFound {
+				return err
+			}
+
+			if mergeHead != nil {
+				o.Parents = append(o.Parents, mergeHead.Hash())
+			}
+		}
+	}
+
+	return nil
+}
+
+// validateParents checks the given parents are distinct existing commits.
+func (o *CommitOptions) validateParents(r *Repository) error {
+	seen := make(map[plumbing.Hash]struct{}, len(o.Parents))
+	for _, p := range o.Parents {
+		if _, ok := seen[p]; ok {
+			return fmt.Errorf("%w: %s", ErrDuplicateParent, p)
+		}
+
+		seen[p] = struct{}{}
+		if _, err := r.CommitObject(p); err != nil {
+			return fmt.Errorf("%w: %s: %w", ErrInvalidParent, p, err)
+		}
 	}
 
 	return nil
@@ -637,6 +900,196 @@ func (o *CommitOptions) loadConfigAuthorAndCommitter(r *Repository) error {
 	return nil
 }
 
+// StashOptions describes how a stash should be created.
+type StashOptions struct {
+	// Message describes the stash. If empty, the stash is described by the
+	// commit checked out, as "WIP on <branch>: <commit>" like git does.
+	Message string
+	// IncludeUntracked stashes the untracked files too, in a third parent of
+	// the stash commit, removing them from the worktree. Ignored files are
+	// never stashed.
+	IncludeUntracked bool
+	// Author is the author's signature of the stash commits. If Author is
+	// empty the Name and Email is read from the config, and time.Now it's
+	// used as When.
+	Author *object.Signature
+	// Committer is the committer's signature of the stash commits. If
+	// Committer is nil the Author signature is used.
+	Committer *object.Signature
+}
+
+// Validate validates the fields and sets the default values.
+func (o *StashOptions) Validate(r *Repository) error {
+	if o.Author == nil {
+		co := &CommitOptions{}
+		if err := co.loadConfigAuthorAndCommitter(r); err != nil {
+			return err
+		}
+
+		o.Author = co.Author
+		if o.Committer == nil {
+			o.Committer = co.Committer
+		}
+	}
+
+	if o.Committer == nil {
+		o.Committer = o.Author
+	}
+
+	return nil
+}
+
+// CherryPickOptions describes how a commit is cherry-picked.
+type CherryPickOptions struct {
+	// NoCommit applies the changes of the commit to the index and the
+	// worktree without creating a commit, as `git cherry-pick --no-commit`.
+	NoCommit bool
+	// RecordOrigin appends a "(cherry picked from commit <hash>)" line to the
+	// message of the new commit, as `git cherry-pick -x`.
+	RecordOrigin bool
+	// AllowEmpty creates the commit even when it doesn't change the tree of
+	// HEAD, otherwise ErrEmptyCommit is returned.
+	AllowEmpty bool
+	// Committer is the committer's signature of the new commit. If Committer
+	// is nil the Name and Email is read from the config, and time.Now it's
+	// used as When. The author of the cherry-picked commit is kept.
+	Committer *object.Signature
+}
+
+// Validate validates the fields and sets the default values.
+func (o *CherryPickOptions) Validate(r *Repository) error {
+	if o.Committer != nil || o.NoCommit {
+		return nil
+	}
+
+	co := &CommitOptions{}
+	if err := co.loadConfigAuthorAndCommitter(r); err != nil {
+		return err
+	}
+
+	o.Committer = co.Committer
+	if o.Committer == nil {
+		o.Committer = co.Author
+	}
+
+	return nil
+}
+
+// RevertOptions describes how a commit is reverted.
+type RevertOptions struct {
+	// Mainline is the number, starting from 1, of the parent of a merge
+	// commit the changes are reverted to, as `git revert -m`. It is required
+	// to revert a merge commit, and must not be given otherwise.
+	Mainline int
+	// NoCommit applies the inverse changes of the commit to the index and the
+	// worktree without creating a commit, as `git revert --no-commit`.
+	NoCommit bool
+	// AllowEmpty creates the commit even when it doesn't change the tree of
+	// HEAD, otherwise ErrEmptyCommit is returned.
+	AllowEmpty bool
+	// Author is the author's signature of the new commit. If Author is nil the
+	// Name and Email is read from the config, and time.Now it's used as When.
+	Author *object.Signature
+	// Committer is the committer's signature of the new commit. If Committer
+	// is nil the Author signature is used.
+	Committer *object.Signature
+}
+
+// Validate validates the fields and sets the default values.
+func (o *RevertOptions) Validate(r *Repository) error {
+	if o.NoCommit {
+		return nil
+	}
+
+	if o.Author == nil || o.Committer == nil {
+		co := &CommitOptions{Author: o.Author, Committer: o.Committer}
+		if err := co.loadConfigAuthorAndCommitter(r); err != nil {
+			return err
+		}
+
+		o.Author, o.Committer = co.Author, co.Committer
+	}
+
+	if o.Committer == nil {
+		o.Committer = o.Author
+	}
+
+	return nil
+}
+
+// RewriteOptions describes how the history is rewritten by RewriteHistory.
+type RewriteOptions struct {
+	// Refs are the references whose history is rewritten. If empty, all the
+	// branches are rewritten.
+	Refs []plumbing.ReferenceName
+	// Rewrite returns how each commit is rewritten. If nil, the commits are
+	// kept as they are.
+	Rewrite RewriteFunc
+	// PruneEmpty drops the rewritten commits that don't change the tree of
+	// their only parent, as the commits left empty after removing paths.
+	// Merge commits are never dropped.
+	PruneEmpty bool
+}
+
+// Validate validates the fields and sets the default values.
+func (o *RewriteOptions) Validate(r *Repository) error {
+	if len(o.Refs) > 0 {
+		return nil
+	}
+
+	iter, err := r.Branches()
+	if err != nil {
+		return err
+	}
+
+	return iter.ForEach(func(ref *plumbing.Reference) error {
+		o.Refs = append(o.Refs, ref.Name())
+		return nil
+	})
+}
+
+// RebaseOptions describes how a rebase is performed.
+type RebaseOptions struct {
+	// Upstream is the commit the branch is compared against, only the commits
+	// of the branch not reachable from it are replayed. It is required when
+	// starting a rebase.
+	Upstream plumbing.Hash
+	// Onto is the commit the changes are replayed on top of. If zero,
+	// Upstream is used.
+	Onto plumbing.Hash
+	// Branch is the branch to rebase, checked out before starting the rebase.
+	// If empty, the current HEAD is rebased.
+	Branch plumbing.ReferenceName
+	// Committer is the committer's signature of the replayed commits. If
+	// Committer is nil the Name and Email is read from the config, and
+	// time.Now it's used as When. The authors of the commits are kept.
+	Committer *object.Signature
+}
+
+// Validate validates the fields and sets the default values. The upstream is
+// only required to start a rebase, so it is not validated here.
+func (o *RebaseOptions) Validate(r *Repository) error {
+	if o.Onto.IsZero() {
+		o.Onto = o.Upstream
+	}
+
+	if o.Committer != nil {
+		return nil
+	}
+
+	co := &CommitOptions{}
+	if err := co.loadConfigAuthorAndCommitter(r); err != nil {
+		return err
+	}
+
+	o.Committer = co.Committer
+	if o.Committer == nil {
+		o.Committer = co.Author
+	}
+
+	return nil
+}
+
 var (
 	ErrMissingName    = errors.New("name field is required")
 	ErrMissingTagger  = errors.New("tagger field is required")
@@ -739,7 +1192,85 @@ const (
 
 // CleanOptions describes how a clean should be performed.
 type CleanOptions struct {
+	// Dir removes the untracked directories too, as `git clean -d`. Without
+	// it, only the untracked files of the directories holding tracked files
+	// are removed.
 	Dir bool
+	// IncludeIgnored removes the ignored files too, as `git clean -x`. The
+	// files are ignored according to the .gitignore files and the Excludes
+	// of the worktree.
+	IncludeIgnored bool
+	// IgnoredOnly removes only the ignored files, as `git clean -X`.
+	IgnoredOnly bool
+	// DryRun doesn't remove anything, the paths that would be removed are
+	// only returned by CleanPaths, as `git clean -n`.
+	DryRun bool
+}
+
+// AddWorktreeOptions describes how a linked worktree should be added.
+type AddWorktreeOptions struct {
+	// Name is the name of the administrative directory of the worktree, under
+	// .git/worktrees. By default the base name of the path of the worktree is
+	// used, with a numeric suffix if it is already taken.
+	Name string
+	// Branch to be checked out in the worktree. A branch checked out by
+	// another worktree can't be used unless Force is set. If empty, HEAD is
+	// detached at Hash.
+	Branch plumbing.ReferenceName
+	// Create a new branch named Branch and start it at Hash.
+	Create bool
+	// Hash is the commit to be checked out, if Branch is empty or Create is
+	// set. By default the commit of HEAD is used.
+	Hash plumbing.Hash
+	// Force allows to check out a branch already checked out by another
+	// worktree.
+	Force bool
+	// NoCheckout, if true, sets HEAD and the index of the worktree without
+	// checking out its files, see CheckoutOptions.NoCheckout.
+	NoCheckout bool
+}
+
+// Validate validates the fields and sets the default values.
+func (o *AddWorktreeOptions) Validate(r *Repository) error {
+	if !o.Create && !o.Hash.IsZero() && o.Branch != "" {
+		return ErrBranchHashExclusive
+	}
+
+	if o.Create && o.Branch == "" {
+		return ErrCreateRequiresBranch
+	}
+
+	if o.Branch != "" {
+		ref, err := r.Storer.Reference(o.Branch)
+		switch {
+		case o.Create && err == nil:
+			return fmt.Errorf("%w: %s", ErrBranchExists, o.Branch)
+		case !o.Create && errors.Is(err, plumbing.ErrReferenceNotFound):
+			return fmt.Errorf("%w: %s", ErrBranchNotFound, o.Branch)
+		case !o.Create && err != nil:
+			return err
+		case !o.Create:
+			o.Hash = ref.Hash()
+			return nil
+		}
+	}
+
+	if o.Hash.IsZero() {
+		head, err := r.Head()
+		if err != nil {
+			return err
+		}
+
+		o.Hash = head.Hash()
+	}
+
+	return nil
+}
+
+// RemoveWorktreeOptions describes how a linked worktree should be removed.
+type RemoveWorktreeOptions struct {
+	// Force removes the worktree even if it is locked or has local changes.
+	Force bool
 }
 
 // GrepOptions describes how a grep should be performed.
@@ -754,9 +1285,24 @@ type GrepOptions struct {
 	ReferenceName plumbing.ReferenceName
 	// PathSpecs are compiled Regexp objects of pathspec to use in the matching.
 	PathSpecs []*regexp.Regexp
+	// FixedStrings are strings to be matched literally, along with Patterns.
+	FixedStrings []string
+	// IgnoreCase matches the patterns ignoring case differences.
+	IgnoreCase bool
+	// WordBoundary matches the patterns only at whole words.
+	WordBoundary bool
+	// Worktree searches the files of the worktree tracked in the index,
+	// instead of a tree. It can't be used with CommitHash or ReferenceName.
+	Worktree bool
+	// Concurrency is the maximum number of files searched at once, 1 by
+	// default.
+	Concurrency int
 }
 
-var ErrHashOrReference = errors.New("ambiguous options, only one of CommitHash or ReferenceName can be passed")
+var (
+	ErrHashOrReference  = errors.New("ambiguous options, only one of CommitHash or ReferenceName can be passed")
+	ErrGrepWorktreeTree = errors.New("ambiguous options, Worktree can't be used with CommitHash or ReferenceName")
+)
 
 // Validate validates the fields and sets the default values.
 //
@@ -770,6 +1316,14 @@ func (o *GrepOptions) validate(r *Repository) error {
 		return ErrHashOrReference
 	}
 
+	if o.Worktree {
+		if !o.CommitHash.IsZero() || o.ReferenceName != "" {
+			return ErrGrepWorktreeTree
+		}
+
+		return nil
+	}
+
 	// If none of CommitHash and ReferenceName are provided, set commit hash of
 	// the repository's head.
 	if o.CommitHash.IsZero() && o.ReferenceName == "" {
@@ -783,6 +1337,43 @@ func (o *GrepOptions) validate(r *Repository) error {
 	return nil
 }
 
+// patterns returns the patterns to be matched, the fixed strings included,
+// with IgnoreCase and WordBoundary applied.
+func (o *GrepOptions) patterns() ([]*regexp.Regexp, error) {
+	exprs := make([]string, 0, len(o.Patterns)+len(o.FixedStrings))
+	for _, p := range o.Patterns {
+		exprs = append(exprs, p.String())
+	}
+
+	for _, str := range o.FixedStrings {
+		exprs = append(exprs, regexp.QuoteMeta(str))
+	}
+
+	if !o.IgnoreCase && !o.WordBoundary && len(o.FixedStrings) == 0 {
+		return o.Patterns, nil
+	}
+
+	patterns := make([]*regexp.Regexp, 0, len(exprs))
+	for _, expr := range exprs {
+		if o.WordBoundary {
+			expr = `\b(?:` + expr + `)\b`
+		}
+
+		if o.IgnoreCase {
+			expr = `(?i)` + expr
+		}
+
+		p, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, err
+		}
+
+		patterns = append(patterns, p)
+	}
+
+	return patterns, nil
+}
+
 // PlainOpenOptions describes how opening a plain repository should be
 // performed.
 type PlainOpenOptions struct {
@@ -817,3 +1408,268 @@ func (o *RestoreOptions) Validate() error {
 
 	return nil
 }
+
+// DefaultBlameRenameScore is the default similarity, in percent, between a
+// deleted and an added file required to follow a rename when blaming.
+const DefaultBlameRenameScore = 50
+
+// ErrInvalidRenameScore is returned when a rename score above 100 is given.
+var ErrInvalidRenameScore = errors.New("rename score must be between 1 and 100")
+
+// BlameOptions describes how a blame operation should be performed.
+type BlameOptions struct {
+	// FollowRenames makes the blame continue into the previous path of the
+	// file when it was renamed, attributing the lines to the commits that
+	// introduced them before the rename.
+	FollowRenames bool
+	// RenameScore is the similarity, between 1 and 100, between a deleted and
+	// an added file required to consider them a rename. A rename combined with
+	// heavy edits is only followed if it is above this threshold. Zero is
+	// reserved for the default, DefaultBlameRenameScore; a score of 1 follows
+	// any rename.
+	RenameScore uint
+	// MailMap, if not nil, replaces the authors of the lines by their
+	// canonical ones, see Repository.MailMap to load it.
+	MailMap *object.MailMap
+}
+
+// Validate validates the fields and sets the default values.
+func (o *BlameOptions) Validate() error {
+	if o.RenameScore == 0 {
+		o.RenameScore = DefaultBlameRenameScore
+	}
+
+	if o.RenameScore > 100 {
+		return ErrInvalidRenameScore
+	}
+
+	return nil
+}
+
+// MailMapOptions describes how the mailmap of a repository is loaded.
+type MailMapOptions struct {
+	// Path is a file, read after the .mailmap file of the repository, whose
+	// entries take precedence. If empty, the `mailmap.file` option of the
+	// repository config is used, if any.
+	Path string
+}
+
+// Validate validates the fields and sets the default values.
+func (o *MailMapOptions) Validate(r *Repository) error {
+	if o.Path != "" {
+		return nil
+	}
+
+	cfg, err := r.Config()
+	if err != nil {
+		return err
+	}
+
+	o.Path = cfg.Raw.Section(mailMapSection).Option(mailMapFileKey)
+	return nil
+}
+
+// DefaultDescribeAbbrev is the default number of hexadecimal digits of the
+// abbreviated hashes returned by Describe.
+const DefaultDescribeAbbrev = 7
+
+// DescribeOptions describes how a commit is described.
+type DescribeOptions struct {
+	// Tags makes the lightweight tags usable too, not only the annotated
+	// ones, as `git describe --tags`.
+	Tags bool
+	// Abbrev is the minimum number of hexadecimal digits of the abbreviated
+	// hash, more are used if needed to make it unique. If empty,
+	// DefaultDescribeAbbrev is used.
+	Abbrev int
+	// Dirty, if not empty, is appended to the description when the worktree
+	// has local changes, as `git describe --dirty=<mark>`. It is only
+	// meaningful when describing HEAD.
+	Dirty string
+	// Match only considers the tags matching any of these glob patterns,
+	// without the refs/tags/ prefix.
+	Match []string
+	// Exclude doesn't consider the tags matching any of these glob patterns,
+	// without the refs/tags/ prefix.
+	Exclude []string
+	// Always makes Describe return the abbreviated hash of the commit when no
+	// tag is reachable from it, instead of ErrDescribeNoTags.
+	Always bool
+}
+
+// Validate validates the fields and sets the default values.
+func (o *DescribeOptions) Validate() error {
+	if o.Abbrev <= 0 {
+		o.Abbrev = DefaultDescribeAbbrev
+	}
+
+	// As git, hashes are never abbreviated to less than 4 digits.
+	o.Abbrev = max(o.Abbrev, 4)
+
+	for _, pattern := range slices.Concat(o.Match, o.Exclude) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid describe pattern %q: %w", pattern, err)
+		}
+	}
+
+	return nil
+}
+
+// ShortlogOptions describes how the commits are summarized by Shortlog.
+type ShortlogOptions struct {
+	// From is the commit the history is walked from. If empty, HEAD is used.
+	From plumbing.Hash
+	// Exclude skips the commits reachable from any of these commits, e.g.
+	// Exclude: A and From: B summarize the range `A..B`.
+	Exclude []plumbing.Hash
+	// MailMap, if not nil, groups the commits by the canonical names and
+	// emails, see Repository.MailMap to load it.
+	MailMap *object.MailMap
+	// Committer groups the commits by committer instead of author, as
+	// `git shortlog --committer`.
+	Committer bool
+	// Email groups the commits by name and email, instead of only by name,
+	// as `git shortlog --email`.
+	Email bool
+	// Subjects collects the first line of the message of each commit in
+	// ShortlogEntry.Subjects. If false, only the commits are counted, as
+	// `git shortlog --summary`.
+	Subjects bool
+}
+
+// Validate validates the fields and sets the default values.
+func (o *ShortlogOptions) Validate(r *Repository) error {
+	if !o.From.IsZero() {
+		return nil
+	}
+
+	head, err := r.Head()
+	if err != nil {
+		return err
+	}
+
+	o.From = head.Hash()
+	return nil
+}
+
+// NoteOptions describes how a note is added or removed.
+type NoteOptions struct {
+	// Ref is the notes reference, which can be given in its short form, e.g.
+	// "review" for refs/notes/review. If empty, the one configured in
+	// core.notesRef is used, or DefaultNotesRef.
+	Ref plumbing.ReferenceName
+	// Force overwrites the note of an object which already has one, as
+	// `git notes add --force`. Otherwise, ErrNoteExists is returned.
+	Force bool
+	// Author is the author's signature of the notes commit. If nil, the Name
+	// and Email are read from the config, and time.Now is used as When.
+	Author *object.Signature
+	// Committer is the committer's signature of the notes commit. If nil,
+	// the Author signature is used.
+	Committer *object.Signature
+}
+
+// Validate validates the fields and sets the default values.
+func (o *NoteOptions) Validate(r *Repository) error {
+	ref, err := r.notesRef(o.Ref)
+	if err != nil {
+		return err
+	}
+
+	o.Ref = ref
+	if o.Author == nil {
+		co := &CommitOptions{}
+		if err := co.loadConfigAuthorAndCommitter(r); err != nil {
+			return err
+		}
+
+		if co.Author == nil {
+			return ErrMissingAuthor
+		}
+
+		o.Author = co.Author
+		if o.Committer == nil {
+			o.Committer = co.Committer
+		}
+	}
+
+	if o.Committer == nil {
+		o.Committer = o.Author
+	}
+
+	return nil
+}
+
+// LFSOptions describes how the LFS driver of a repository is built.
+type LFSOptions struct {
+	// RemoteName is the name of the remote whose LFS server is used. If
+	// empty, DefaultRemoteName is used.
+	RemoteName string
+	// Auth credentials, if required, to use with the LFS server, usually the
+	// ones used with the remote. Only HTTP auth methods are supported.
+	Auth transport.AuthMethod
+	// CredentialHelper is queried for credentials when the LFS server
+	// requires authentication and no Auth is given.
+	CredentialHelper transporthttp.CredentialHelper
+	// HTTPClient performs the requests to the LFS server. If nil,
+	// http.DefaultClient is used.
+	HTTPClient *http.Client
+	// Offline disables the LFS server: only the objects found in the local
+	// storage can be checked out.
+	Offline bool
+}
+
+// Validate validates the fields and sets the default values.
+func (o *LFSOptions) Validate() error {
+	if o.RemoteName == "" {
+		o.RemoteName = DefaultRemoteName
+	}
+
+	if o.Auth != nil {
+		if _, ok := o.Auth.(transporthttp.AuthMethod); !ok {
+			return fmt.Errorf("%w: %s", ErrLFSUnsupportedAuth, o.Auth.Name())
+		}
+	}
+
+	return nil
+}
+
+// ArchiveFormat is the format of the archives written by Repository.Archive.
+type ArchiveFormat int8
+
+const (
+	// TarArchive writes a POSIX tar archive, as `git archive --format=tar`.
+	TarArchive ArchiveFormat = iota
+	// ZipArchive writes a zip archive, as `git archive --format=zip`.
+	ZipArchive
+)
+
+// ArchiveOptions describes how an archive is written.
+type ArchiveOptions struct {
+	// Prefix is prepended to the path of every entry, as
+	// `git archive --prefix`. It usually ends with a slash, in which case an
+	// entry for the prefix directory is written first.
+	Prefix string
+	// ModTime is the modification time of every entry. If empty, the
+	// committer time is used when archiving a commit, time.Now otherwise.
+	ModTime time.Time
+}
+
+// Validate validates the fields and sets the default values.
+func (o *ArchiveOptions) Validate(c *object.Commit) error {
+	if strings.HasPrefix(o.Prefix, "/") {
+		return fmt.Errorf("invalid archive prefix %q: must be relative", o.Prefix)
+	}
+
+	if !o.ModTime.IsZero() {
+		return nil
+	}
+
+	if c != nil {
+		o.ModTime = c.Committer.When
+	} else {
+		o.ModTime = time.Now()
+	}
+
+	return nil
+}
@@ -3,6 +3,7 @@ package git
 import (
 	"os"
 	"testing"
+	"time"
 
 	"github.com/go-git/go-billy/v5/util"
 	"github.com/go-git/go-git/v6/config"
@@ -99,6 +100,26 @@ func (s *OptionsSuite) TestCreateTagOptionsLoadGlobal() {
 	s.Equal("foo@foo.com", o.Tagger.Email)
 }
 
+func (s *OptionsSuite) TestFetchOptionsDepthExclusive() {
+	s.NoError((&FetchOptions{Deepen: 1}).Validate())
+	s.ErrorIs((&FetchOptions{Depth: 1, Deepen: 1}).Validate(), ErrDepthExclusive)
+	s.ErrorIs((&FetchOptions{Depth: 1, Unshallow: true}).Validate(), ErrDepthExclusive)
+	s.ErrorIs((&FetchOptions{Deepen: 1, Unshallow: true}).Validate(), ErrDepthExclusive)
+
+	since := time.Date(2015, time.January, 2, 3, 4, 5, 0, time.UTC)
+	s.NoError((&FetchOptions{ShallowSince: since, ShallowExclude: []string{"v1"}}).Validate())
+	s.ErrorIs((&FetchOptions{Depth: 1, ShallowSince: since}).Validate(), ErrDepthExclusive)
+	s.ErrorIs((&FetchOptions{Deepen: 1, ShallowExclude: []string{"v1"}}).Validate(), ErrDepthExclusive)
+	s.ErrorIs((&FetchOptions{Unshallow: true, ShallowSince: since}).Validate(), ErrDepthExclusive)
+}
+
+func (s *OptionsSuite) TestCloneOptionsShallowExclusive() {
+	since := time.Date(2015, time.January, 2, 3, 4, 5, 0, time.UTC)
+	s.NoError((&CloneOptions{URL: "foo", ShallowSince: since, ShallowExclude: []string{"v1"}}).Validate())
+	s.ErrorIs((&CloneOptions{URL: "foo", Depth: 1, ShallowSince: since}).Validate(), ErrDepthExclusive)
+	s.ErrorIs((&CloneOptions{URL: "foo", Depth: 1, ShallowExclude: []string{"v1"}}).Validate(), ErrDepthExclusive)
+}
+
 func (s *OptionsSuite) writeGlobalConfig(cfg *config.Config) func() {
 	fs := s.TemporalFilesystem()
 
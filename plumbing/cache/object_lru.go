@@ -7,17 +7,44 @@ import (
 	"github.com/go-git/go-git/v6/plumbing"
 )
 
-// ObjectLRU implements an object cache with an LRU eviction policy and a
-// maximum size (measured in object size).
+// EvictionPolicy decides which objects are evicted from an ObjectLRU once the
+// objects it holds exceed its maximum size. The methods are called with the
+// lock of the cache held, so implementations don't need to be safe for
+// concurrent use, but an instance must not be shared between caches.
+type EvictionPolicy interface {
+	// Added records an object inserted into the cache, with its size.
+	Added(h plumbing.Hash, size FileSize)
+	// Accessed records a read of an object in the cache, or a new put of it.
+	Accessed(h plumbing.Hash)
+	// Removed forgets an object removed from the cache.
+	Removed(h plumbing.Hash)
+	// Victim returns the object to be evicted next, one of the objects added
+	// and not removed yet, without forgetting it. The second return value is
+	// false if there is no object to evict.
+	Victim() (plumbing.Hash, bool)
+	// Clear forgets every object.
+	Clear()
+}
+
+// ObjectLRU implements an object cache with a maximum size (measured in object
+// size). The least recently used objects are evicted first, unless another
+// EvictionPolicy is given with NewObjectLRUWithPolicy. The size of each object
+// is recorded when it is put, so the accounting stays correct even if the
+// object changes afterwards.
 type ObjectLRU struct {
 	MaxSize FileSize
 
+	policy     EvictionPolicy
 	actualSize FileSize
-	ll         *list.List
-	cache      map[interface{}]*list.Element
+	cache      map[plumbing.Hash]cachedObject
 	mut        sync.Mutex
 }
 
+type cachedObject struct {
+	obj  plumbing.EncodedObject
+	size FileSize
+}
+
 // NewObjectLRU creates a new ObjectLRU with the given maximum size. The maximum
 // size will never be exceeded.
 func NewObjectLRU(maxSize FileSize) *ObjectLRU {
@@ -29,65 +56,91 @@ func NewObjectLRUDefault() *ObjectLRU {
 	return &ObjectLRU{MaxSize: DefaultMaxSize}
 }
 
+// NewObjectLRUBytes creates a new ObjectLRU holding up to maxBytes bytes,
+// measured as the sum of the object sizes.
+func NewObjectLRUBytes(maxBytes FileSize) *ObjectLRU {
+	return NewObjectLRU(maxBytes)
+}
+
+// NewObjectLRUWithPolicy creates a new ObjectLRU with the given maximum size,
+// which evicts the objects chosen by the given policy instead of the least
+// recently used ones. An LRU policy is used if policy is nil.
+func NewObjectLRUWithPolicy(maxSize FileSize, policy EvictionPolicy) *ObjectLRU {
+	return &ObjectLRU{MaxSize: maxSize, policy: policy}
+}
+
 // Put puts an object into the cache. If the object is already in the cache, it
-// will be marked as used. Otherwise, it will be inserted. A single object might
-// be evicted to make room for the new object.
+// is replaced and marked as used. Otherwise, it will be inserted. Objects
+// bigger than the maximum size are never cached. Objects might be evicted to
+// make room for the new object.
 func (c *ObjectLRU) Put(obj plumbing.EncodedObject) {
 	c.mut.Lock()
 	defer c.mut.Unlock()
 
 	if c.cache == nil {
 		c.actualSize = 0
-		c.cache = make(map[interface{}]*list.Element, 1000)
-		c.ll = list.New()
+		c.cache = make(map[plumbing.Hash]cachedObject, 1000)
+		if c.policy == nil {
+			c.policy = NewLRUPolicy()
+		}
 	}
 
-	objSize := FileSize(obj.Size())
-	key := obj.Hash()
-	if ee, ok := c.cache[key]; ok {
-		oldObj := ee.Value.(plumbing.EncodedObject)
-		// in this case objSize is a delta: new size - old size
-		objSize -= FileSize(oldObj.Size())
-		c.ll.MoveToFront(ee)
-		ee.Value = obj
-	} else {
-		if objSize > c.MaxSize {
-			return
+	key, objSize := obj.Hash(), FileSize(obj.Size())
+	old, ok := c.cache[key]
+	if objSize > c.MaxSize {
+		if ok {
+			c.remove(key, old.size)
 		}
-		ee := c.ll.PushFront(obj)
-		c.cache[key] = ee
+
+		return
 	}
 
+	if ok {
+		c.actualSize -= old.size
+		c.policy.Accessed(key)
+	} else {
+		c.policy.Added(key, objSize)
+	}
+
+	c.cache[key] = cachedObject{obj: obj, size: objSize}
 	c.actualSize += objSize
+
 	for c.actualSize > c.MaxSize {
-		last := c.ll.Back()
-		if last == nil {
-			c.actualSize = 0
+		victim, ok := c.policy.Victim()
+		if !ok {
 			break
 		}
 
-		lastObj := last.Value.(plumbing.EncodedObject)
-		lastSize := FileSize(lastObj.Size())
+		e, ok := c.cache[victim]
+		if !ok {
+			// The policy doesn't follow the objects of the cache, evicting
+			// more wouldn't make room.
+			break
+		}
 
-		c.ll.Remove(last)
-		delete(c.cache, lastObj.Hash())
-		c.actualSize -= lastSize
+		c.remove(victim, e.size)
 	}
 }
 
+func (c *ObjectLRU) remove(h plumbing.Hash, size FileSize) {
+	c.policy.Removed(h)
+	delete(c.cache, h)
+	c.actualSize -= size
+}
+
 // Get returns an object by its hash. It marks the object as used. If the object
 // is not in the cache, (nil, false) will be returned.
 func (c *ObjectLRU) Get(k plumbing.Hash) (plumbing.EncodedObject, bool) {
 	c.mut.Lock()
 	defer c.mut.Unlock()
 
-	ee, ok := c.cache[k]
+	e, ok := c.cache[k]
 	if !ok {
 		return nil, false
 	}
 
-	c.ll.MoveToFront(ee)
-	return ee.Value.(plumbing.EncodedObject), true
+	c.policy.Accessed(k)
+	return e.obj, true
 }
 
 // Clear the content of this object cache.
@@ -95,7 +148,72 @@ func (c *ObjectLRU) Clear() {
 	c.mut.Lock()
 	defer c.mut.Unlock()
 
-	c.ll = nil
+	if c.policy != nil {
+		c.policy.Clear()
+	}
+
 	c.cache = nil
 	c.actualSize = 0
 }
+
+// Size returns the number of bytes held by the cache.
+func (c *ObjectLRU) Size() FileSize {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	return c.actualSize
+}
+
+// Len returns the number of objects in the cache.
+func (c *ObjectLRU) Len() int {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	return len(c.cache)
+}
+
+// lruPolicy is an EvictionPolicy evicting the least recently used objects.
+type lruPolicy struct {
+	ll    *list.List
+	elems map[plumbing.Hash]*list.Element
+}
+
+// NewLRUPolicy returns an EvictionPolicy that evicts the least recently used
+// objects first.
+func NewLRUPolicy() EvictionPolicy {
+	return &lruPolicy{
+		ll:    list.New(),
+		elems: make(map[plumbing.Hash]*list.Element),
+	}
+}
+
+func (p *lruPolicy) Added(h plumbing.Hash, _ FileSize) {
+	p.elems[h] = p.ll.PushFront(h)
+}
+
+func (p *lruPolicy) Accessed(h plumbing.Hash) {
+	if e, ok := p.elems[h]; ok {
+		p.ll.MoveToFront(e)
+	}
+}
+
+func (p *lruPolicy) Removed(h plumbing.Hash) {
+	if e, ok := p.elems[h]; ok {
+		p.ll.Remove(e)
+		delete(p.elems, h)
+	}
+}
+
+func (p *lruPolicy) Victim() (plumbing.Hash, bool) {
+	last := p.ll.Back()
+	if last == nil {
+		return plumbing.ZeroHash, false
+	}
+
+	return last.Value.(plumbing.Hash), true
+}
+
+func (p *lruPolicy) Clear() {
+	p.ll.Init()
+	p.elems = make(map[plumbing.Hash]*list.Element)
+}
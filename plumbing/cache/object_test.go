@@ -34,6 +34,7 @@ func (s *ObjectSuite) SetupTest() {
 	s.c = make(map[string]Object)
 	s.c["two_bytes"] = NewObjectLRU(2 * Byte)
 	s.c["default_lru"] = NewObjectLRUDefault()
+	s.c["two_bytes_cache"] = NewObjectLRUBytes(2 * Byte)
 }
 
 func (s *ObjectSuite) TestPutSameObject() {
@@ -56,7 +57,7 @@ func (s *ObjectSuite) TestPutSameObjectWithDifferentSize() {
 
 	s.Equal(7*Byte, cache.MaxSize)
 	s.Equal(7*Byte, cache.actualSize)
-	s.Equal(1, cache.ll.Len())
+	s.Equal(1, cache.Len())
 
 	obj, ok := cache.Get(plumbing.NewHash(hash))
 	s.Equal(plumbing.NewHash(hash), obj.Hash())
@@ -166,6 +167,118 @@ func (s *ObjectSuite) TestObjectUpdateOverflow() {
 	o.Put(b)
 }
 
+func (s *ObjectSuite) TestObjectLRUBytesOverflow() {
+	o := NewObjectLRUBytes(2 * Byte)
+
+	o.Put(s.aObject)
+	o.Put(s.cObject)
+	o.Get(s.aObject.Hash())
+	o.Put(s.dObject)
+
+	_, ok := o.Get(s.cObject.Hash())
+	s.False(ok)
+	_, ok = o.Get(s.aObject.Hash())
+	s.True(ok)
+	_, ok = o.Get(s.dObject.Hash())
+	s.True(ok)
+
+	s.Equal(2*Byte, o.Size())
+	s.Equal(2*Byte, o.MaxSize)
+	s.Equal(2, o.Len())
+
+	o.Put(s.eObject)
+	s.Equal(2*Byte, o.Size())
+	s.Equal(1, o.Len())
+
+	o.Clear()
+	s.Equal(0*Byte, o.Size())
+	s.Equal(0, o.Len())
+}
+
+func (s *ObjectSuite) TestObjectLRUBytesSizeAccounting() {
+	o := NewObjectLRUBytes(9 * Byte)
+
+	a1 := newObject(s.aObject.Hash().String(), 3*Byte)
+	o.Put(a1)
+	s.Equal(3*Byte, o.Size())
+
+	// The size recorded is the one at the time of the put.
+	a1.SetSize(8)
+	o.Put(s.bObject)
+	s.Equal(6*Byte, o.Size())
+
+	o.Put(newObject(s.aObject.Hash().String(), 5*Byte))
+	s.Equal(8*Byte, o.Size())
+	s.Equal(2, o.Len())
+
+	// Replacing an object by one bigger than the cache removes it.
+	o.Put(newObject(s.aObject.Hash().String(), 10*Byte))
+	s.Equal(3*Byte, o.Size())
+	_, ok := o.Get(s.aObject.Hash())
+	s.False(ok)
+}
+
+// largestFirstPolicy evicts the biggest objects first.
+type largestFirstPolicy struct {
+	sizes map[plumbing.Hash]FileSize
+}
+
+func (p *largestFirstPolicy) Added(h plumbing.Hash, size FileSize) { p.sizes[h] = size }
+func (p *largestFirstPolicy) Accessed(plumbing.Hash)               {}
+func (p *largestFirstPolicy) Removed(h plumbing.Hash)              { delete(p.sizes, h) }
+func (p *largestFirstPolicy) Clear()                               { clear(p.sizes) }
+
+func (p *largestFirstPolicy) Victim() (plumbing.Hash, bool) {
+	var victim plumbing.Hash
+	var max FileSize = -1
+	for h, size := range p.sizes {
+		if size > max {
+			victim, max = h, size
+		}
+	}
+
+	return victim, max >= 0
+}
+
+func (s *ObjectSuite) TestEvictionPolicy() {
+	o := NewObjectLRUWithPolicy(4*Byte, &largestFirstPolicy{sizes: make(map[plumbing.Hash]FileSize)})
+
+	o.Put(s.aObject)
+	o.Put(s.bObject)
+	o.Put(s.cObject)
+
+	_, ok := o.Get(s.bObject.Hash())
+	s.False(ok)
+	_, ok = o.Get(s.aObject.Hash())
+	s.True(ok)
+	_, ok = o.Get(s.cObject.Hash())
+	s.True(ok)
+	s.Equal(2*Byte, o.Size())
+}
+
+// unknownVictimPolicy always chooses an object which isn't in the cache.
+type unknownVictimPolicy struct{}
+
+func (unknownVictimPolicy) Added(plumbing.Hash, FileSize) {}
+func (unknownVictimPolicy) Accessed(plumbing.Hash)        {}
+func (unknownVictimPolicy) Removed(plumbing.Hash)         {}
+func (unknownVictimPolicy) Clear()                        {}
+
+func (unknownVictimPolicy) Victim() (plumbing.Hash, bool) {
+	return plumbing.NewHash("ffffffffffffffffffffffffffffffffffffffff"), true
+}
+
+func (s *ObjectSuite) TestEvictionPolicyUnknownVictim() {
+	o := NewObjectLRUWithPolicy(2*Byte, unknownVictimPolicy{})
+
+	o.Put(s.aObject)
+	o.Put(s.cObject)
+	o.Put(s.dObject)
+
+	s.Equal(3, o.Len())
+	s.Equal(3*Byte, o.Size())
+}
+
 type dummyObject struct {
 	hash plumbing.Hash
 	size FileSize
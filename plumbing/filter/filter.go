@@ -0,0 +1,93 @@
+// Package filter implements the registry of the drivers of the filters that
+// gitattributes assign to paths with the filter attribute, e.g. filter=lfs,
+// converting the content of the files when they are added to the repository
+// and when they are checked out.
+package filter
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// ErrDriverNotFound is returned when no driver is registered for a filter.
+var ErrDriverNotFound = errors.New("filter driver not found")
+
+// Driver converts the content of the files assigned to a filter. The path of
+// the file, relative to the root of the worktree, is given to both methods.
+type Driver interface {
+	// Clean converts the content of a file read from the worktree, src, to
+	// the content stored in the repository, written to dst.
+	Clean(path string, dst io.Writer, src io.Reader) error
+	// Smudge converts the content of a blob, src, to the content written in
+	// the worktree, dst.
+	Smudge(path string, dst io.Writer, src io.Reader) error
+}
+
+// Func is the signature of the functions converting the content of a file.
+type Func func(path string, dst io.Writer, src io.Reader) error
+
+// Funcs is a Driver implemented by plain functions. A nil function copies the
+// content as it is.
+type Funcs struct {
+	// CleanFunc is called by Clean.
+	CleanFunc Func
+	// SmudgeFunc is called by Smudge.
+	SmudgeFunc Func
+}
+
+// Clean implements Driver.
+func (f Funcs) Clean(path string, dst io.Writer, src io.Reader) error {
+	return run(f.CleanFunc, path, dst, src)
+}
+
+// Smudge implements Driver.
+func (f Funcs) Smudge(path string, dst io.Writer, src io.Reader) error {
+	return run(f.SmudgeFunc, path, dst, src)
+}
+
+func run(fn Func, path string, dst io.Writer, src io.Reader) error {
+	if fn == nil {
+		_, err := io.Copy(dst, src)
+		return err
+	}
+
+	return fn(path, dst, src)
+}
+
+var (
+	registry = map[string]Driver{}
+	mtx      sync.RWMutex
+)
+
+// Register adds or replaces the driver of the named filter. The registry is
+// shared by all the repositories of the process, so it's meant for stateless
+// drivers: the ones bound to a repository are set in the Filters of its
+// worktree instead.
+func Register(name string, d Driver) {
+	mtx.Lock()
+	registry[name] = d
+	mtx.Unlock()
+}
+
+// Unregister removes the driver of the named filter.
+func Unregister(name string) {
+	mtx.Lock()
+	delete(registry, name)
+	mtx.Unlock()
+}
+
+// Get returns the driver of the named filter, or ErrDriverNotFound if none is
+// registered.
+func Get(name string) (Driver, error) {
+	mtx.RLock()
+	defer mtx.RUnlock()
+
+	d, ok := registry[name]
+	if !ok || d == nil {
+		return nil, fmt.Errorf("%w: %q", ErrDriverNotFound, name)
+	}
+
+	return d, nil
+}
@@ -0,0 +1,66 @@
+package filter
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type FilterSuite struct {
+	suite.Suite
+}
+
+func TestFilterSuite(t *testing.T) {
+	suite.Run(t, new(FilterSuite))
+}
+
+func (s *FilterSuite) TestRegister() {
+	d := Funcs{}
+	Register("foo", d)
+	defer Unregister("foo")
+
+	got, err := Get("foo")
+	s.NoError(err)
+	s.Equal(d, got)
+}
+
+func (s *FilterSuite) TestUnregister() {
+	Register("foo", Funcs{})
+	Unregister("foo")
+
+	_, err := Get("foo")
+	s.ErrorIs(err, ErrDriverNotFound)
+}
+
+func (s *FilterSuite) TestGetNil() {
+	Register("foo", nil)
+	defer Unregister("foo")
+
+	_, err := Get("foo")
+	s.ErrorIs(err, ErrDriverNotFound)
+}
+
+func (s *FilterSuite) TestFuncs() {
+	d := Funcs{
+		CleanFunc: func(path string, dst io.Writer, src io.Reader) error {
+			content, err := io.ReadAll(src)
+			if err != nil {
+				return err
+			}
+
+			_, err = dst.Write([]byte(path + ":" + strings.ToUpper(string(content))))
+			return err
+		},
+	}
+
+	var buf bytes.Buffer
+	s.NoError(d.Clean("foo", &buf, strings.NewReader("bar")))
+	s.Equal("foo:BAR", buf.String())
+
+	buf.Reset()
+	s.NoError(d.Smudge("foo", &buf, strings.NewReader("bar")))
+	s.Equal("bar", buf.String())
+}
@@ -0,0 +1,399 @@
+package bitmap
+
+import (
+	"bufio"
+	"bytes"
+	"cmp"
+	"crypto"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"sort"
+
+	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/go-git/go-git/v6/plumbing/format/idxfile"
+	"github.com/go-git/go-git/v6/plumbing/hash"
+	"github.com/go-git/go-git/v6/utils/binary"
+)
+
+var (
+	// ErrUnsupportedVersion is returned by Decode when the bitmap file
+	// version is not supported.
+	ErrUnsupportedVersion = errors.New("unsupported version")
+	// ErrMalformedFile is returned by Decode when the bitmap file is
+	// corrupted, or doesn't match the packfile index.
+	ErrMalformedFile = errors.New("malformed bitmap file")
+	// ErrPackMismatch is returned by Decode when the bitmap file belongs to
+	// another packfile than the one of the given index.
+	ErrPackMismatch = errors.New("bitmap doesn't belong to the packfile")
+
+	signature = []byte{'B', 'I', 'T', 'M'}
+)
+
+const (
+	// VersionSupported is the only bitmap version supported.
+	VersionSupported = 1
+
+	// FlagFullDAG is set when the bitmaps hold every object reachable from
+	// their commits, it is required.
+	FlagFullDAG uint16 = 0x1
+	// FlagHashCache is set when the file has a name-hash cache.
+	FlagHashCache uint16 = 0x4
+	// FlagLookupTable is set when the file has a lookup table of the entries.
+	FlagLookupTable uint16 = 0x10
+
+	// maxXorOffset is the maximum distance to the entry an entry is XORed
+	// with, as git.
+	maxXorOffset = 160
+
+	szLookupEntry = 4 + 8 + 4
+)
+
+// typeOrder is the order of the type indexes in the file.
+var typeOrder = []plumbing.ObjectType{
+	plumbing.CommitObject,
+	plumbing.TreeObject,
+	plumbing.BlobObject,
+	plumbing.TagObject,
+}
+
+// Index is a decoded pack bitmap file, giving the objects of the packfile
+// reachable from a selection of its commits. The positions of the bitmaps
+// are the positions of the objects in the packfile, in the order they are
+// stored in it.
+type Index struct {
+	// Flags are the flags of the bitmap file.
+	Flags uint16
+	// PackChecksum is the checksum of the packfile.
+	PackChecksum plumbing.Hash
+
+	// objects are the objects of the packfile, in the order they are stored,
+	// and offsets their offsets, in ascending order.
+	objects []plumbing.Hash
+	offsets []uint64
+	idx     idxfile.Index
+
+	types   map[plumbing.ObjectType]*Bitmap
+	entries map[plumbing.Hash]*entry
+	commits []plumbing.Hash
+}
+
+// entry is a commit with a bitmap. The bitmap is kept compressed, and XORed
+// with the one of base if any, until it is looked up.
+type entry struct {
+	bitmap *ewah
+	base   *entry
+}
+
+// Decode reads a bitmap file, of the packfile of the given index. When idx is
+// an *idxfile.MemoryIndex, the checksum of the packfile is checked against
+// the one of the bitmap. The commits with a bitmap are checked to be commits
+// of the packfile.
+func Decode(r io.Reader, idx idxfile.Index) (*Index, error) {
+	byName, err := indexEntries(idx)
+	if err != nil {
+		return nil, err
+	}
+
+	hashSize := crypto.SHA1.Size()
+	if len(byName) > 0 {
+		hashSize = byName[0].Hash.Size()
+	}
+
+	h := hash.New(crypto.SHA1)
+	if hashSize == crypto.SHA256.Size() {
+		h = hash.New(crypto.SHA256)
+	}
+
+	br := bufio.NewReader(r)
+	d := &decoder{r: io.TeeReader(br, h), hashSize: hashSize, count: uint32(len(byName))}
+	i := &Index{idx: idx}
+
+	if err := d.readHeader(i); err != nil {
+		return nil, err
+	}
+
+	if m, ok := idx.(*idxfile.MemoryIndex); ok && !m.PackfileChecksum.IsZero() &&
+		!m.PackfileChecksum.Equal(i.PackChecksum) {
+		return nil, ErrPackMismatch
+	}
+
+	if err := d.readTypes(i); err != nil {
+		return nil, err
+	}
+
+	i.setObjects(byName)
+	if err := d.readEntries(i, byName); err != nil {
+		return nil, err
+	}
+
+	if err := d.skipExtensions(i); err != nil {
+		return nil, err
+	}
+
+	checksum := make([]byte, hashSize)
+	if _, err := io.ReadFull(br, checksum); err != nil {
+		return nil, err
+	}
+
+	if !bytes.Equal(checksum, h.Sum(nil)) {
+		return nil, fmt.Errorf("%w: invalid checksum", ErrMalformedFile)
+	}
+
+	return i, nil
+}
+
+// indexEntries returns the entries of the index, sorted by hash.
+func indexEntries(idx idxfile.Index) ([]*idxfile.Entry, error) {
+	iter, err := idx.Entries()
+	if err != nil {
+		return nil, err
+	}
+
+	defer iter.Close()
+
+	var entries []*idxfile.Entry
+	for {
+		e, err := iter.Next()
+		if errors.Is(err, io.EOF) {
+			return entries, nil
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		entries = append(entries, e)
+	}
+}
+
+// setObjects sets the objects of the packfile, in the order they are stored,
+// from its entries sorted by hash.
+func (i *Index) setObjects(byName []*idxfile.Entry) {
+	byOffset := slices.Clone(byName)
+	slices.SortFunc(byOffset, func(a, b *idxfile.Entry) int {
+		return cmp.Compare(a.Offset, b.Offset)
+	})
+
+	i.objects = make([]plumbing.Hash, len(byOffset))
+	i.offsets = make([]uint64, len(byOffset))
+	for pos, e := range byOffset {
+		i.objects[pos] = e.Hash
+		i.offsets[pos] = e.Offset
+	}
+}
+
+type decoder struct {
+	r        io.Reader
+	hashSize int
+	// count is the number of objects of the packfile, and entries the
+	// number of commits with a bitmap.
+	count, entries uint32
+}
+
+func (d *decoder) readHeader(i *Index) error {
+	sig := make([]byte, len(signature))
+	if _, err := io.ReadFull(d.r, sig); err != nil {
+		return err
+	}
+
+	if !bytes.Equal(sig, signature) {
+		return fmt.Errorf("%w: invalid signature", ErrMalformedFile)
+	}
+
+	version, err := binary.ReadUint16(d.r)
+	if err != nil {
+		return err
+	}
+
+	if version != VersionSupported {
+		return ErrUnsupportedVersion
+	}
+
+	if i.Flags, err = binary.ReadUint16(d.r); err != nil {
+		return err
+	}
+
+	if i.Flags&FlagFullDAG == 0 {
+		return fmt.Errorf("%w: bitmaps are not full closures", ErrMalformedFile)
+	}
+
+	if d.entries, err = binary.ReadUint32(d.r); err != nil {
+		return err
+	}
+
+	if d.entries > d.count {
+		return fmt.Errorf("%w: more bitmaps than objects", ErrMalformedFile)
+	}
+
+	checksum := make([]byte, d.hashSize)
+	if _, err := io.ReadFull(d.r, checksum); err != nil {
+		return err
+	}
+
+	i.PackChecksum, _ = plumbing.FromBytes(checksum)
+	return nil
+}
+
+func (d *decoder) readTypes(i *Index) error {
+	i.types = make(map[plumbing.ObjectType]*Bitmap, len(typeOrder))
+	for _, t := range typeOrder {
+		e, err := readEWAH(d.r, d.count)
+		if err != nil {
+			return err
+		}
+
+		b := e.bitmap()
+		if b.len() > int(d.count) {
+			return fmt.Errorf("%w: %s index past the objects of the packfile", ErrMalformedFile, t)
+		}
+
+		i.types[t] = b
+	}
+
+	return nil
+}
+
+func (d *decoder) readEntries(i *Index, byName []*idxfile.Entry) error {
+	count := d.entries
+	read := make([]*entry, 0, count)
+	i.entries = make(map[plumbing.Hash]*entry, count)
+	i.commits = make([]plumbing.Hash, 0, count)
+	for range count {
+		var (
+			namePos    uint32
+			xor, flags uint8
+		)
+		if err := binary.Read(d.r, &namePos, &xor, &flags); err != nil {
+			return err
+		}
+
+		if namePos >= d.count {
+			return fmt.Errorf("%w: bitmap of object %d past the objects of the packfile", ErrMalformedFile, namePos)
+		}
+
+		commit := byName[namePos].Hash
+		pos, _ := i.Position(commit)
+		if !i.types[plumbing.CommitObject].Get(pos) {
+			return fmt.Errorf("%w: bitmap of %s, which is not a commit", ErrMalformedFile, commit)
+		}
+
+		if _, ok := i.entries[commit]; ok {
+			return fmt.Errorf("%w: duplicated bitmap of %s", ErrMalformedFile, commit)
+		}
+
+		if int(xor) > len(read) || xor > maxXorOffset {
+			return fmt.Errorf("%w: invalid XOR offset of %s", ErrMalformedFile, commit)
+		}
+
+		e := &entry{}
+		if xor > 0 {
+			e.base = read[len(read)-int(xor)]
+		}
+
+		var err error
+		if e.bitmap, err = readEWAH(d.r, d.count); err != nil {
+			return err
+		}
+
+		read = append(read, e)
+		i.entries[commit] = e
+		i.commits = append(i.commits, commit)
+	}
+
+	return nil
+}
+
+// skipExtensions skips the name-hash cache and the lookup table, if any.
+func (d *decoder) skipExtensions(i *Index) error {
+	var size int64
+	if i.Flags&FlagHashCache != 0 {
+		size += int64(d.count) * 4
+	}
+
+	if i.Flags&FlagLookupTable != 0 {
+		size += int64(len(i.commits)) * szLookupEntry
+	}
+
+	_, err := io.CopyN(io.Discard, d.r, size)
+	return err
+}
+
+// Count returns the number of objects of the packfile.
+func (i *Index) Count() int {
+	return len(i.objects)
+}
+
+// Commits returns the commits with a bitmap, in the order they are stored.
+func (i *Index) Commits() []plumbing.Hash {
+	return slices.Clone(i.commits)
+}
+
+// Position returns the position of the given object in the packfile, if it
+// is in it.
+func (i *Index) Position(h plumbing.Hash) (uint32, bool) {
+	offset, err := i.idx.FindOffset(h)
+	if err != nil {
+		return 0, false
+	}
+
+	pos := sort.Search(len(i.offsets), func(n int) bool { return i.offsets[n] >= uint64(offset) })
+	if pos == len(i.offsets) || i.offsets[pos] != uint64(offset) {
+		return 0, false
+	}
+
+	return uint32(pos), true
+}
+
+// Hash returns the hash of the object at the given position of the packfile.
+func (i *Index) Hash(pos uint32) (plumbing.Hash, bool) {
+	if int(pos) >= len(i.objects) {
+		return plumbing.ZeroHash, false
+	}
+
+	return i.objects[pos], true
+}
+
+// Type returns the type of the object at the given position of the packfile,
+// or plumbing.InvalidObject if it is not in it.
+func (i *Index) Type(pos uint32) plumbing.ObjectType {
+	for _, t := range typeOrder {
+		if i.types[t].Get(pos) {
+			return t
+		}
+	}
+
+	return plumbing.InvalidObject
+}
+
+// Lookup returns the bitmap of the objects reachable from the given commit,
+// including itself, if it has one. The bitmap returned can be modified.
+func (i *Index) Lookup(commit plumbing.Hash) (*Bitmap, bool) {
+	e, ok := i.entries[commit]
+	if !ok {
+		return nil, false
+	}
+
+	b := e.bitmap.bitmap()
+	for base := e.base; base != nil; base = base.base {
+		b.Xor(base.bitmap.bitmap())
+	}
+
+	return b, true
+}
+
+// Hashes returns the hashes of the objects at the positions set in the
+// given bitmap, in the order they are stored in the packfile.
+func (i *Index) Hashes(b *Bitmap) []plumbing.Hash {
+	hashes := make([]plumbing.Hash, 0, b.Count())
+	_ = b.ForEach(func(pos uint32) error {
+		if h, ok := i.Hash(pos); ok {
+			hashes = append(hashes, h)
+		}
+
+		return nil
+	})
+
+	return hashes
+}
@@ -0,0 +1,184 @@
+package bitmap_test
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/go-git/go-git/v6/plumbing/cache"
+	. "github.com/go-git/go-git/v6/plumbing/format/bitmap"
+	"github.com/go-git/go-git/v6/plumbing/format/idxfile"
+	"github.com/go-git/go-git/v6/plumbing/revlist"
+	"github.com/go-git/go-git/v6/storage/filesystem"
+	"github.com/stretchr/testify/suite"
+
+	fixtures "github.com/go-git/go-git-fixtures/v5"
+)
+
+const (
+	head      = "6ecf0ef2c2dffb796033e5a02219af86ec6584e5"
+	someCode  = "918c48b83bd081e863dbe1b80f8998f058cd8294"
+	initial   = "b029517f6300c2da0f4b651b8642506cd6aaf45d"
+	changelog = "d3ff53e0564a9f87d8e84b6e28e5060e517008aa"
+)
+
+type BitmapSuite struct {
+	suite.Suite
+	st    *filesystem.Storage
+	idx   *idxfile.MemoryIndex
+	order []plumbing.Hash
+	types map[plumbing.Hash]plumbing.ObjectType
+}
+
+func TestBitmapSuite(t *testing.T) {
+	suite.Run(t, new(BitmapSuite))
+}
+
+func (s *BitmapSuite) SetupSuite() {
+	f := fixtures.Basic().One()
+	s.st = filesystem.NewStorage(f.DotGit(), cache.NewObjectLRUDefault())
+
+	s.idx = new(idxfile.MemoryIndex)
+	s.Require().NoError(idxfile.NewDecoder(f.Idx()).Decode(s.idx))
+
+	var err error
+	s.order, err = PackOrder(s.idx)
+	s.Require().NoError(err)
+
+	s.types = make(map[plumbing.Hash]plumbing.ObjectType)
+	iter, err := s.st.IterEncodedObjects(plumbing.AnyObject)
+	s.Require().NoError(err)
+	s.Require().NoError(iter.ForEach(func(o plumbing.EncodedObject) error {
+		s.types[o.Hash()] = o.Type()
+		return nil
+	}))
+}
+
+// reachable returns the bitmap of the objects reachable from the commit.
+func (s *BitmapSuite) reachable(commit string) *Bitmap {
+	hashes, err := revlist.Objects(s.st, []plumbing.Hash{plumbing.NewHash(commit)}, nil)
+	s.Require().NoError(err)
+
+	b := NewBitmap()
+	for _, h := range hashes {
+		for pos, o := range s.order {
+			if o == h {
+				b.Set(uint32(pos))
+			}
+		}
+	}
+
+	return b
+}
+
+func (s *BitmapSuite) encode(entries ...Entry) []byte {
+	var buf bytes.Buffer
+	s.Require().NoError(NewEncoder(&buf).Encode(s.idx, s.idx.PackfileChecksum, s.types, entries))
+	return buf.Bytes()
+}
+
+func (s *BitmapSuite) TestEncodeDecode() {
+	data := s.encode(
+		Entry{Commit: plumbing.NewHash(head), Bitmap: s.reachable(head)},
+		Entry{Commit: plumbing.NewHash(initial), Bitmap: s.reachable(initial)},
+	)
+
+	i, err := Decode(bytes.NewReader(data), s.idx)
+	s.Require().NoError(err)
+	s.Equal(FlagFullDAG, i.Flags)
+	s.Equal(s.idx.PackfileChecksum, i.PackChecksum)
+	s.Equal(31, i.Count())
+	s.Equal([]plumbing.Hash{plumbing.NewHash(head), plumbing.NewHash(initial)}, i.Commits())
+
+	b, ok := i.Lookup(plumbing.NewHash(head))
+	s.Require().True(ok)
+	s.Equal(28, b.Count())
+	s.Equal(s.reachable(head), b)
+
+	b, ok = i.Lookup(plumbing.NewHash(initial))
+	s.Require().True(ok)
+	s.Len(i.Hashes(b), 4)
+	s.Contains(i.Hashes(b), plumbing.NewHash(initial))
+
+	_, ok = i.Lookup(plumbing.NewHash(someCode))
+	s.False(ok)
+
+	pos, ok := i.Position(plumbing.NewHash(changelog))
+	s.Require().True(ok)
+	s.Equal(plumbing.BlobObject, i.Type(pos))
+	h, ok := i.Hash(pos)
+	s.True(ok)
+	s.Equal(changelog, h.String())
+
+	_, ok = i.Position(plumbing.ZeroHash)
+	s.False(ok)
+	_, ok = i.Hash(31)
+	s.False(ok)
+	s.Equal(plumbing.InvalidObject, i.Type(31))
+}
+
+func (s *BitmapSuite) TestEncodeNotACommit() {
+	err := NewEncoder(io.Discard).Encode(s.idx, s.idx.PackfileChecksum, s.types, []Entry{
+		{Commit: plumbing.NewHash(changelog), Bitmap: NewBitmap()},
+	})
+	s.Error(err)
+}
+
+func (s *BitmapSuite) TestDecodePackMismatch() {
+	var buf bytes.Buffer
+	s.Require().NoError(NewEncoder(&buf).Encode(s.idx, plumbing.NewHash(head), s.types, nil))
+
+	_, err := Decode(&buf, s.idx)
+	s.ErrorIs(err, ErrPackMismatch)
+}
+
+// namePos returns the position of the object in the idx file.
+func (s *BitmapSuite) namePos(h string) uint32 {
+	iter, err := s.idx.Entries()
+	s.Require().NoError(err)
+
+	for pos := uint32(0); ; pos++ {
+		e, err := iter.Next()
+		s.Require().NoError(err)
+		if e.Hash.String() == h {
+			return pos
+		}
+	}
+}
+
+func (s *BitmapSuite) TestDecodeMalformed() {
+	data := s.encode(Entry{Commit: plumbing.NewHash(head), Bitmap: s.reachable(head)})
+	// The entry follows the type indexes, the file without entries only has
+	// the trailer after them.
+	entry := len(s.encode()) - s.idx.PackfileChecksum.Size()
+
+	for _, t := range []struct {
+		name    string
+		corrupt func([]byte)
+		err     string
+	}{
+		{"signature", func(b []byte) { b[0] = 'X' }, "invalid signature"},
+		{"full DAG", func(b []byte) { b[7] = 0 }, "not full closures"},
+		{"entries", func(b []byte) { b[8] = 1 }, "more bitmaps than objects"},
+		{"checksum", func(b []byte) { b[len(b)-1] ^= 0xff }, "invalid checksum"},
+		{"position", func(b []byte) { b[entry+3] = 40 }, "past the objects"},
+		{"type", func(b []byte) { b[entry+3] = byte(s.namePos(changelog)) }, "not a commit"},
+		{"XOR offset", func(b []byte) { b[entry+4] = 1 }, "invalid XOR offset"},
+	} {
+		b := bytes.Clone(data)
+		t.corrupt(b)
+
+		_, err := Decode(bytes.NewReader(b), s.idx)
+		s.ErrorIs(err, ErrMalformedFile, t.name)
+		s.ErrorContains(err, t.err, t.name)
+	}
+
+	b := bytes.Clone(data)
+	b[5] = 2
+	_, err := Decode(bytes.NewReader(b), s.idx)
+	s.ErrorIs(err, ErrUnsupportedVersion)
+
+	_, err = Decode(bytes.NewReader(data[:len(data)/2]), s.idx)
+	s.Error(err)
+}
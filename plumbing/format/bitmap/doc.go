@@ -0,0 +1,73 @@
+// Package bitmap implements encoding and decoding of pack bitmap files.
+//
+// A pack bitmap stores, for a selection of the commits of a packfile, the set
+// of objects reachable from each of them as a bitmap, where each bit is an
+// object of the packfile in the order they are stored in it. This makes the
+// objects reachable from a commit, e.g. to serve a fetch, a bitmap lookup
+// instead of a walk of the history. It's stored next to the packfile as
+// `.git/objects/pack/pack-<hash>.bitmap`.
+//
+// Git pack bitmap format
+// ======================
+//
+// All multi-byte numbers are in network order.
+//
+// HEADER:
+//
+//	4-byte signature:
+//	    The signature is: {'B', 'I', 'T', 'M'}
+//
+//	2-byte version number:
+//	    Git only writes or recognizes version 1.
+//
+//	2-byte flags:
+//	    BITMAP_OPT_FULL_DAG (0x1) REQUIRED: the bitmaps are complete, they
+//	    hold every object reachable from the commit.
+//	    BITMAP_OPT_HASH_CACHE (0x4): a name-hash cache follows the entries.
+//	    BITMAP_OPT_LOOKUP_TABLE (0x10): a lookup table follows the entries
+//	    and the name-hash cache.
+//
+//	4-byte entry count:
+//	    The number of commits with a bitmap.
+//
+//	Pack checksum:
+//	    The checksum of the packfile the bitmap belongs to.
+//
+// TYPE INDEXES:
+//
+//	Four EWAH bitmaps with the objects of the packfile of each type, in
+//	this order: commits, trees, blobs and tags.
+//
+// ENTRIES:
+//
+//	For each commit with a bitmap:
+//	    4-byte object position: the position of the commit in the idx file
+//	    of the packfile, sorted by hash.
+//	    1-byte XOR offset: if not zero, the bitmap is XORed with the one of
+//	    the entry this number of entries before.
+//	    1-byte flags.
+//	    EWAH bitmap of the objects reachable from the commit.
+//
+// NAME-HASH CACHE (optional):
+//
+//	A 4-byte hash of the path of every object of the packfile, in the order
+//	they are stored in it.
+//
+// LOOKUP TABLE (optional):
+//
+//	For each entry, the 4-byte object position, the 8-byte offset of the
+//	entry in the file and the 4-byte position of its XOR base entry.
+//
+// TRAILER:
+//
+//	Checksum of the above contents.
+//
+// EWAH bitmaps are serialized as the 4-byte number of bits, the 4-byte number
+// of 64-bit words, the words, and the 4-byte position of the last run length
+// word. Each run length word holds a bit, in its lowest bit, repeated in the
+// following 32 bits number of words, followed by the number of literal
+// words, in the remaining 31 bits, stored after it.
+//
+// Source:
+// https://git-scm.com/docs/gitformat-pack#_bitmap_format
+package bitmap
@@ -0,0 +1,124 @@
+package bitmap
+
+import (
+	"crypto"
+	"fmt"
+	"io"
+	"slices"
+
+	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/go-git/go-git/v6/plumbing/format/idxfile"
+	"github.com/go-git/go-git/v6/plumbing/hash"
+	"github.com/go-git/go-git/v6/utils/binary"
+)
+
+// Entry is a commit, with the objects reachable from it, to be written in a
+// bitmap file.
+type Entry struct {
+	Commit plumbing.Hash
+	// Bitmap holds the positions in the packfile of the objects reachable
+	// from the commit, including itself.
+	Bitmap *Bitmap
+}
+
+// Encoder writes bitmap files to an output stream.
+type Encoder struct {
+	w io.Writer
+}
+
+// NewEncoder returns a new stream encoder that writes to w.
+func NewEncoder(w io.Writer) *Encoder {
+	return &Encoder{w: w}
+}
+
+// Encode writes the bitmap file of the packfile with the given checksum and
+// index. The types hold the type of every object of the packfile. The
+// bitmaps of the entries are written as they are, without XOR compression,
+// nor name-hash cache or lookup table.
+func (e *Encoder) Encode(
+	idx idxfile.Index,
+	packChecksum plumbing.Hash,
+	types map[plumbing.Hash]plumbing.ObjectType,
+	entries []Entry,
+) error {
+	byName, err := indexEntries(idx)
+	if err != nil {
+		return err
+	}
+
+	i := &Index{idx: idx}
+	i.setObjects(byName)
+
+	typeBitmaps := make(map[plumbing.ObjectType]*Bitmap, len(typeOrder))
+	for _, t := range typeOrder {
+		typeBitmaps[t] = NewBitmap()
+	}
+
+	for pos, h := range i.objects {
+		b, ok := typeBitmaps[types[h]]
+		if !ok {
+			return fmt.Errorf("invalid type of object %s: %s", h, types[h])
+		}
+
+		b.Set(uint32(pos))
+	}
+
+	h := hash.New(crypto.SHA1)
+	if packChecksum.Size() == crypto.SHA256.Size() {
+		h = hash.New(crypto.SHA256)
+	}
+
+	w := io.MultiWriter(e.w, h)
+	count := uint32(len(i.objects))
+	if _, err := w.Write(signature); err != nil {
+		return err
+	}
+
+	if err := binary.Write(w, uint16(VersionSupported), FlagFullDAG, uint32(len(entries))); err != nil {
+		return err
+	}
+
+	if _, err := w.Write(packChecksum.Bytes()); err != nil {
+		return err
+	}
+
+	for _, t := range typeOrder {
+		if err := encodeEWAH(w, typeBitmaps[t], count); err != nil {
+			return err
+		}
+	}
+
+	for _, entry := range entries {
+		namePos, ok := slices.BinarySearchFunc(byName, entry.Commit, func(e *idxfile.Entry, h plumbing.Hash) int {
+			return e.Hash.Compare(h.Bytes())
+		})
+		if !ok || types[entry.Commit] != plumbing.CommitObject {
+			return fmt.Errorf("%s is not a commit of the packfile", entry.Commit)
+		}
+
+		if err := binary.Write(w, uint32(namePos), uint8(0), uint8(0)); err != nil {
+			return err
+		}
+
+		if err := encodeEWAH(w, entry.Bitmap, count); err != nil {
+			return err
+		}
+	}
+
+	_, err = e.w.Write(h.Sum(nil))
+	return err
+}
+
+// PackOrder returns the hashes of the objects of the packfile with the given
+// index, in the order they are stored in it. The position of an object in
+// the returned slice is its position in the bitmaps.
+func PackOrder(idx idxfile.Index) ([]plumbing.Hash, error) {
+	byName, err := indexEntries(idx)
+	if err != nil {
+		return nil, err
+	}
+
+	i := &Index{}
+	i.setObjects(byName)
+	return i.objects, nil
+}
@@ -0,0 +1,240 @@
+package bitmap
+
+import (
+	"fmt"
+	"io"
+	"math/bits"
+
+	"github.com/go-git/go-git/v6/utils/binary"
+)
+
+const (
+	wordSize = 64
+
+	runLenBits     = 32
+	maxRunLen      = 1<<runLenBits - 1
+	literalBits    = 31
+	maxLiteralsLen = 1<<literalBits - 1
+
+	allOnes = ^uint64(0)
+)
+
+// Bitmap is a set of positions, of objects in a packfile, held uncompressed.
+// The zero value is an empty Bitmap.
+type Bitmap struct {
+	words []uint64
+}
+
+// NewBitmap returns an empty Bitmap.
+func NewBitmap() *Bitmap {
+	return &Bitmap{}
+}
+
+// Get returns whether the given position is set.
+func (b *Bitmap) Get(pos uint32) bool {
+	i := int(pos / wordSize)
+	return i < len(b.words) && b.words[i]&(1<<(pos%wordSize)) != 0
+}
+
+// Set sets the given position.
+func (b *Bitmap) Set(pos uint32) {
+	i := int(pos / wordSize)
+	b.grow(i + 1)
+	b.words[i] |= 1 << (pos % wordSize)
+}
+
+// Or sets the positions set in o.
+func (b *Bitmap) Or(o *Bitmap) {
+	b.grow(len(o.words))
+	for i, w := range o.words {
+		b.words[i] |= w
+	}
+}
+
+// AndNot clears the positions set in o.
+func (b *Bitmap) AndNot(o *Bitmap) {
+	for i := range min(len(b.words), len(o.words)) {
+		b.words[i] &^= o.words[i]
+	}
+}
+
+// Xor toggles the positions set in o.
+func (b *Bitmap) Xor(o *Bitmap) {
+	b.grow(len(o.words))
+	for i, w := range o.words {
+		b.words[i] ^= w
+	}
+}
+
+// Count returns the number of positions set.
+func (b *Bitmap) Count() int {
+	var n int
+	for _, w := range b.words {
+		n += bits.OnesCount64(w)
+	}
+
+	return n
+}
+
+// Clone returns a copy of the Bitmap.
+func (b *Bitmap) Clone() *Bitmap {
+	return &Bitmap{words: append([]uint64(nil), b.words...)}
+}
+
+// ForEach calls fn for each position set, in ascending order, until fn
+// returns an error.
+func (b *Bitmap) ForEach(fn func(pos uint32) error) error {
+	for i, w := range b.words {
+		for w != 0 {
+			bit := bits.TrailingZeros64(w)
+			if err := fn(uint32(i*wordSize + bit)); err != nil {
+				return err
+			}
+
+			w &^= 1 << bit
+		}
+	}
+
+	return nil
+}
+
+// len returns the number of bits up to the last position set.
+func (b *Bitmap) len() int {
+	for i := len(b.words) - 1; i >= 0; i-- {
+		if b.words[i] != 0 {
+			return i*wordSize + wordSize - bits.LeadingZeros64(b.words[i])
+		}
+	}
+
+	return 0
+}
+
+func (b *Bitmap) grow(n int) {
+	if n > len(b.words) {
+		b.words = append(b.words, make([]uint64, n-len(b.words))...)
+	}
+}
+
+// ewah is an EWAH compressed bitmap, as read from a bitmap file. It is
+// validated when read, so it can always be decompressed.
+type ewah struct {
+	size  uint32
+	words []uint64
+}
+
+// readEWAH reads an EWAH compressed bitmap of at most maxSize bits, rounded
+// up to whole words as git does.
+func readEWAH(r io.Reader, maxSize uint32) (*ewah, error) {
+	var size, count uint32
+	if err := binary.Read(r, &size, &count); err != nil {
+		return nil, err
+	}
+
+	// Every run length word is followed by at least a literal word, or
+	// covers at least a word, but the last one.
+	maxWords := (uint64(size) + wordSize - 1) / wordSize
+	if maxWords > (uint64(maxSize)+wordSize-1)/wordSize || uint64(count) > 2*maxWords+1 {
+		return nil, fmt.Errorf("%w: EWAH bitmap too big", ErrMalformedFile)
+	}
+
+	e := &ewah{size: size, words: make([]uint64, count)}
+	if err := binary.Read(r, e.words); err != nil {
+		return nil, err
+	}
+
+	// The position of the last run length word is only needed to append to
+	// the bitmap.
+	if _, err := binary.ReadUint32(r); err != nil {
+		return nil, err
+	}
+
+	var total uint64
+	for i := 0; i < len(e.words); {
+		rlw := e.words[i]
+		literals := rlw >> (1 + runLenBits)
+		total += (rlw>>1)&maxRunLen + literals
+		i += 1 + int(literals)
+		if i > len(e.words) || total > maxWords {
+			return nil, fmt.Errorf("%w: EWAH bitmap overflow", ErrMalformedFile)
+		}
+	}
+
+	return e, nil
+}
+
+// bitmap returns the decompressed bitmap.
+func (e *ewah) bitmap() *Bitmap {
+	b := &Bitmap{words: make([]uint64, 0, (e.size+wordSize-1)/wordSize)}
+	for i := 0; i < len(e.words); {
+		rlw := e.words[i]
+		literals := int(rlw >> (1 + runLenBits))
+		i++
+
+		fill := uint64(0)
+		if rlw&1 != 0 {
+			fill = allOnes
+		}
+
+		for range (rlw >> 1) & maxRunLen {
+			b.words = append(b.words, fill)
+		}
+
+		b.words = append(b.words, e.words[i:i+literals]...)
+		i += literals
+	}
+
+	// Clear the positions past the size of the bitmap, set by a run of ones.
+	if rem := e.size % wordSize; rem != 0 && len(b.words) == int(e.size/wordSize)+1 {
+		b.words[len(b.words)-1] &= 1<<rem - 1
+	}
+
+	return b
+}
+
+// encodeEWAH writes the bitmap EWAH compressed, with the given number of bits.
+func encodeEWAH(w io.Writer, b *Bitmap, size uint32) error {
+	words := b.words[:min(len(b.words), int((size+wordSize-1)/wordSize))]
+
+	var (
+		compressed []uint64
+		rlwPos     int
+	)
+	for i := 0; i < len(words) || len(compressed) == 0; {
+		rlwPos = len(compressed)
+		compressed = append(compressed, 0)
+
+		var rlw uint64
+		if i < len(words) && (words[i] == 0 || words[i] == allOnes) {
+			fill := words[i]
+			run := 0
+			for i < len(words) && words[i] == fill && run < maxRunLen {
+				run++
+				i++
+			}
+
+			rlw = uint64(run) << 1
+			if fill == allOnes {
+				rlw |= 1
+			}
+		}
+
+		literals := 0
+		for i < len(words) && words[i] != 0 && words[i] != allOnes && literals < maxLiteralsLen {
+			compressed = append(compressed, words[i])
+			literals++
+			i++
+		}
+
+		compressed[rlwPos] = rlw | uint64(literals)<<(1+runLenBits)
+	}
+
+	if err := binary.Write(w, size, uint32(len(compressed))); err != nil {
+		return err
+	}
+
+	if err := binary.Write(w, compressed); err != nil {
+		return err
+	}
+
+	return binary.WriteUint32(w, uint32(rlwPos))
+}
@@ -0,0 +1,122 @@
+package bitmap
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/go-git/go-git/v6/utils/binary"
+	"github.com/stretchr/testify/suite"
+)
+
+type EWAHSuite struct {
+	suite.Suite
+}
+
+func TestEWAHSuite(t *testing.T) {
+	suite.Run(t, new(EWAHSuite))
+}
+
+func (s *EWAHSuite) TestRoundTrip() {
+	for name, positions := range map[string][]uint32{
+		"empty":    nil,
+		"literals": {0, 3, 64, 127},
+		"runs":     rangePositions(0, 64*5),
+		"mixed":    append(append([]uint32{1, 70}, rangePositions(64*4, 64*10)...), 64*30+5),
+		"trailing": {64*20 + 63},
+	} {
+		b := NewBitmap()
+		for _, p := range positions {
+			b.Set(p)
+		}
+
+		var buf bytes.Buffer
+		s.Require().NoError(encodeEWAH(&buf, b, 64*31))
+
+		e, err := readEWAH(&buf, 64*31)
+		s.Require().NoError(err, name)
+		s.Zero(buf.Len(), name)
+
+		decoded := e.bitmap()
+		s.Equal(len(positions), decoded.Count(), name)
+		for _, p := range positions {
+			s.True(decoded.Get(p), name)
+		}
+	}
+}
+
+func (s *EWAHSuite) TestRunOfOnesIsTrimmedToSize() {
+	// A run of two words of ones, followed by a literal word.
+	var buf bytes.Buffer
+	s.Require().NoError(binary.Write(&buf,
+		uint32(130), uint32(2),
+		uint64(1)<<(1+runLenBits)|2<<1|1, ^uint64(0),
+		uint32(0),
+	))
+
+	e, err := readEWAH(&buf, 130)
+	s.Require().NoError(err)
+
+	b := e.bitmap()
+	s.Equal(130, b.Count())
+	s.Equal(130, b.len())
+}
+
+func (s *EWAHSuite) TestReadOverflow() {
+	for name, words := range map[string][]uint64{
+		"run":      {100 << 1},
+		"literals": {uint64(3) << (1 + runLenBits), 1},
+	} {
+		var buf bytes.Buffer
+		s.Require().NoError(binary.Write(&buf, uint32(64), uint32(len(words)), words, uint32(0)))
+
+		_, err := readEWAH(&buf, 64)
+		s.ErrorIs(err, ErrMalformedFile, name)
+	}
+
+	var buf bytes.Buffer
+	s.Require().NoError(binary.Write(&buf, uint32(64*10), uint32(0), uint32(0)))
+	_, err := readEWAH(&buf, 64)
+	s.ErrorIs(err, ErrMalformedFile)
+}
+
+func (s *EWAHSuite) TestBitmapOperations() {
+	a, b := NewBitmap(), NewBitmap()
+	for _, p := range []uint32{1, 5, 100} {
+		a.Set(p)
+	}
+	for _, p := range []uint32{5, 200} {
+		b.Set(p)
+	}
+
+	or := a.Clone()
+	or.Or(b)
+	s.Equal([]uint32{1, 5, 100, 200}, positionsOf(or))
+
+	xor := a.Clone()
+	xor.Xor(b)
+	s.Equal([]uint32{1, 100, 200}, positionsOf(xor))
+
+	andNot := a.Clone()
+	andNot.AndNot(b)
+	s.Equal([]uint32{1, 100}, positionsOf(andNot))
+	s.Equal([]uint32{1, 5, 100}, positionsOf(a))
+}
+
+func rangePositions(from, to uint32) []uint32 {
+	var positions []uint32
+	for p := from; p < to; p++ {
+		positions = append(positions, p)
+	}
+
+	return positions
+}
+
+func positionsOf(b *Bitmap) []uint32 {
+	var positions []uint32
+	_ = b.ForEach(func(pos uint32) error {
+		positions = append(positions, pos)
+		return nil
+	})
+
+	return positions
+}
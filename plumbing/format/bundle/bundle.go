@@ -0,0 +1,56 @@
+package bundle
+
+import (
+	"errors"
+
+	"github.com/go-git/go-git/v6/plumbing"
+	format "github.com/go-git/go-git/v6/plumbing/format/config"
+)
+
+const (
+	signatureV2 = "# v2 git bundle"
+	signatureV3 = "# v3 git bundle"
+
+	capabilityObjectFormat = "object-format"
+	capabilityFilter       = "filter"
+)
+
+var (
+	// ErrInvalidBundle is returned when the header of a bundle is malformed.
+	ErrInvalidBundle = errors.New("bundle: invalid header")
+	// ErrUnsupportedVersion is returned when reading a bundle of a version
+	// other than 2 or 3.
+	ErrUnsupportedVersion = errors.New("bundle: unsupported version")
+	// ErrUnsupportedCapability is returned when a version 3 bundle requires
+	// an unknown capability.
+	ErrUnsupportedCapability = errors.New("bundle: unsupported capability")
+	// ErrMissingPrerequisite is returned when a prerequisite commit of a
+	// bundle is not found in the destination storer.
+	ErrMissingPrerequisite = errors.New("bundle: missing prerequisite")
+	// ErrEmptyBundle is returned when writing a bundle without references.
+	ErrEmptyBundle = errors.New("bundle: refusing to create an empty bundle")
+)
+
+// Prerequisite is a commit the objects in a bundle depend on, which is not
+// included in it.
+type Prerequisite struct {
+	// Hash is the hash of the commit.
+	Hash plumbing.Hash
+	// Comment is an optional text, usually the subject of the commit.
+	Comment string
+}
+
+// Header is the header of a bundle, describing the packfile that follows it.
+type Header struct {
+	// Version is the version of the bundle format, 2 or 3.
+	Version int
+	// ObjectFormat is the object format of the hashes in the bundle.
+	ObjectFormat format.ObjectFormat
+	// Filter is the object filter used to create the packfile, if any. Only
+	// found in version 3 bundles.
+	Filter string
+	// Prerequisites are the commits required to use the bundle.
+	Prerequisites []Prerequisite
+	// References are the references included in the bundle.
+	References []*plumbing.Reference
+}
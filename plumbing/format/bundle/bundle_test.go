@@ -0,0 +1,155 @@
+package bundle_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/go-git/go-git/v6/plumbing/cache"
+	"github.com/go-git/go-git/v6/plumbing/format/bundle"
+	format "github.com/go-git/go-git/v6/plumbing/format/config"
+	"github.com/go-git/go-git/v6/plumbing/format/packfile"
+	"github.com/go-git/go-git/v6/plumbing/revlist"
+	"github.com/go-git/go-git/v6/storage/filesystem"
+	"github.com/go-git/go-git/v6/storage/memory"
+	"github.com/stretchr/testify/suite"
+
+	fixtures "github.com/go-git/go-git-fixtures/v5"
+)
+
+type BundleSuite struct {
+	suite.Suite
+	storer *filesystem.Storage
+}
+
+func TestBundleSuite(t *testing.T) {
+	suite.Run(t, new(BundleSuite))
+}
+
+func (s *BundleSuite) SetupTest() {
+	f := fixtures.Basic().One()
+	s.storer = filesystem.NewStorage(f.DotGit(), cache.NewObjectLRUDefault())
+}
+
+var (
+	master      = plumbing.NewHash("6ecf0ef2c2dffb796033e5a02219af86ec6584e5")
+	basis       = plumbing.NewHash("918c48b83bd081e863dbe1b80f8998f058cd8294")
+	basisParent = plumbing.NewHash("af2d6a6954d532f8ffb47615169c8fdf9d383a1a")
+)
+
+func (s *BundleSuite) TestWriteAndRead() {
+	refs := []*plumbing.Reference{
+		plumbing.NewHashReference(plumbing.HEAD, master),
+		plumbing.NewHashReference(plumbing.Master, master),
+	}
+
+	var buf bytes.Buffer
+	s.Require().NoError(bundle.NewWriter(&buf, s.storer).Write(refs, nil))
+	s.True(strings.HasPrefix(buf.String(), "# v2 git bundle\n"))
+
+	r, err := bundle.NewReader(&buf)
+	s.Require().NoError(err)
+	s.Equal(2, r.Version)
+	s.Equal(format.SHA1, r.ObjectFormat)
+	s.Empty(r.Prerequisites)
+	s.Equal(refs, r.References)
+
+	st := memory.NewStorage()
+	s.NoError(r.VerifyPrerequisites(st))
+	s.Require().NoError(packfile.UpdateObjectStorage(st, r.Packfile()))
+
+	expected, err := revlist.Objects(s.storer, []plumbing.Hash{master}, nil)
+	s.NoError(err)
+	for _, h := range expected {
+		s.NoError(st.HasEncodedObject(h), h.String())
+	}
+}
+
+func (s *BundleSuite) TestWriteThin() {
+	refs := []*plumbing.Reference{plumbing.NewHashReference(plumbing.Master, master)}
+
+	var buf bytes.Buffer
+	err := bundle.NewWriter(&buf, s.storer).Write(refs, []plumbing.Hash{basis})
+	s.Require().NoError(err)
+
+	r, err := bundle.NewReader(&buf)
+	s.Require().NoError(err)
+	s.Equal([]bundle.Prerequisite{{Hash: basis, Comment: "some code"}}, r.Prerequisites)
+	s.Equal(refs, r.References)
+
+	s.ErrorIs(r.VerifyPrerequisites(memory.NewStorage()), bundle.ErrMissingPrerequisite)
+	s.NoError(r.VerifyPrerequisites(s.storer))
+
+	st := memory.NewStorage()
+	s.Require().NoError(packfile.UpdateObjectStorage(st, r.Packfile()))
+	s.NoError(st.HasEncodedObject(master))
+	s.ErrorIs(st.HasEncodedObject(basis), plumbing.ErrObjectNotFound)
+	s.ErrorIs(st.HasEncodedObject(basisParent), plumbing.ErrObjectNotFound)
+}
+
+func (s *BundleSuite) TestWriteEmpty() {
+	refs := []*plumbing.Reference{plumbing.NewHashReference(plumbing.Master, master)}
+
+	var buf bytes.Buffer
+	err := bundle.NewWriter(&buf, s.storer).Write(refs, []plumbing.Hash{master})
+	s.ErrorIs(err, bundle.ErrEmptyBundle)
+	s.Zero(buf.Len())
+}
+
+func (s *BundleSuite) TestWriteSymbolicReference() {
+	refs := []*plumbing.Reference{plumbing.NewSymbolicReference(plumbing.HEAD, plumbing.Master)}
+
+	var buf bytes.Buffer
+	err := bundle.NewWriter(&buf, s.storer).Write(refs, nil)
+	s.Error(err)
+}
+
+func (s *BundleSuite) TestReadV3() {
+	hash := strings.Repeat("ab", 32)
+	prerequisite := strings.Repeat("cd", 32)
+	header := "# v3 git bundle\n" +
+		"@object-format=sha256\n" +
+		"@filter=blob:none\n" +
+		"-" + prerequisite + " some commit\n" +
+		hash + " refs/heads/main\n" +
+		"\n" +
+		"PACK"
+
+	r, err := bundle.NewReader(strings.NewReader(header))
+	s.Require().NoError(err)
+	s.Equal(3, r.Version)
+	s.Equal(format.SHA256, r.ObjectFormat)
+	s.Equal("blob:none", r.Filter)
+	s.Require().Len(r.Prerequisites, 1)
+	s.Equal(prerequisite, r.Prerequisites[0].Hash.String())
+	s.Equal("some commit", r.Prerequisites[0].Comment)
+	s.Require().Len(r.References, 1)
+	s.Equal(plumbing.ReferenceName("refs/heads/main"), r.References[0].Name())
+	s.Equal(hash, r.References[0].Hash().String())
+
+	buf := make([]byte, 4)
+	_, err = r.Packfile().Read(buf)
+	s.NoError(err)
+	s.Equal("PACK", string(buf))
+}
+
+func (s *BundleSuite) TestReadErrors() {
+	hash := master.String()
+	for _, tc := range []struct {
+		header string
+		err    error
+	}{
+		{"PACK", bundle.ErrInvalidBundle},
+		{"# v4 git bundle\n\n", bundle.ErrUnsupportedVersion},
+		{"# v3 git bundle\n@unknown\n\n", bundle.ErrUnsupportedCapability},
+		{"# v3 git bundle\n@object-format=md5\n\n", format.ErrInvalidObjectFormat},
+		{"# v2 git bundle\n@object-format=sha1\n\n", bundle.ErrInvalidBundle},
+		{"# v2 git bundle\n" + hash + "\n\n", bundle.ErrInvalidBundle},
+		{"# v2 git bundle\n" + hash[:20] + " refs/heads/master\n\n", bundle.ErrInvalidBundle},
+		{"# v2 git bundle\n" + hash + " refs/heads/master\n", bundle.ErrInvalidBundle},
+	} {
+		_, err := bundle.NewReader(strings.NewReader(tc.header))
+		s.ErrorIs(err, tc.err, tc.header)
+	}
+}
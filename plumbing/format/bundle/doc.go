@@ -0,0 +1,22 @@
+// Package bundle implements encoding and decoding of git bundles.
+//
+// A bundle is a file holding a header followed by a packfile, used to move
+// history between repositories without a network connection. The header
+// lists the references included in the bundle and the prerequisites, the
+// commits the destination repository must already have because they are
+// not included in the packfile. A bundle without prerequisites contains the
+// complete history of its references.
+//
+// Versions 2 and 3 of the format are supported:
+//
+//	# v2 git bundle
+//	-<prerequisite> <comment>
+//	<object-id> <refname>
+//	<empty line>
+//	<packfile>
+//
+// Version 3 adds a list of capabilities, as @<key>=<value> lines, between the
+// signature and the prerequisites.
+//
+// See https://git-scm.com/docs/gitformat-bundle
+package bundle
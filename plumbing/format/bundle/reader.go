@@ -0,0 +1,171 @@
+package bundle
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/go-git/go-git/v6/plumbing"
+	format "github.com/go-git/go-git/v6/plumbing/format/config"
+	"github.com/go-git/go-git/v6/plumbing/storer"
+)
+
+// Reader reads a bundle from a provided io.Reader. The header is decoded when
+// the Reader is created, the packfile following it is read using Packfile.
+type Reader struct {
+	Header
+	r *bufio.Reader
+}
+
+// NewReader returns a new Reader reading from r, decoding the header of the
+// bundle.
+func NewReader(r io.Reader) (*Reader, error) {
+	br := bufio.NewReader(r)
+	h, err := decodeHeader(br)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Reader{Header: *h, r: br}, nil
+}
+
+// Packfile returns a reader for the packfile of the bundle.
+func (r *Reader) Packfile() io.Reader {
+	return r.r
+}
+
+// VerifyPrerequisites checks that all the prerequisites of the bundle are
+// found in the given storer, returning ErrMissingPrerequisite otherwise.
+func (r *Reader) VerifyPrerequisites(s storer.EncodedObjectStorer) error {
+	for _, p := range r.Prerequisites {
+		_, err := s.EncodedObject(plumbing.CommitObject, p.Hash)
+		if errors.Is(err, plumbing.ErrObjectNotFound) {
+			return fmt.Errorf("%w: %s", ErrMissingPrerequisite, p.Hash)
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func decodeHeader(r *bufio.Reader) (*Header, error) {
+	line, err := readLine(r)
+	if err != nil {
+		return nil, err
+	}
+
+	h := &Header{}
+	switch line {
+	case signatureV2:
+		h.Version = 2
+	case signatureV3:
+		h.Version = 3
+	default:
+		if strings.HasPrefix(line, "# v") && strings.HasSuffix(line, " git bundle") {
+			return nil, fmt.Errorf("%w: %q", ErrUnsupportedVersion, line)
+		}
+
+		return nil, fmt.Errorf("%w: unknown signature %q", ErrInvalidBundle, line)
+	}
+
+	for {
+		line, err := readLine(r)
+		if err != nil {
+			return nil, err
+		}
+
+		switch {
+		case line == "":
+			return h, nil
+		case strings.HasPrefix(line, "@") && h.Version == 3 &&
+			len(h.Prerequisites) == 0 && len(h.References) == 0:
+			err = h.decodeCapability(line[1:])
+		case strings.HasPrefix(line, "-"):
+			err = h.decodePrerequisite(line[1:])
+		default:
+			err = h.decodeReference(line)
+		}
+
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (h *Header) decodeCapability(line string) error {
+	key, value, _ := strings.Cut(line, "=")
+	switch key {
+	case capabilityObjectFormat:
+		switch value {
+		case format.SHA1.String():
+			h.ObjectFormat = format.SHA1
+		case format.SHA256.String():
+			h.ObjectFormat = format.SHA256
+		default:
+			return fmt.Errorf("%w: %q", format.ErrInvalidObjectFormat, value)
+		}
+	case capabilityFilter:
+		h.Filter = value
+	default:
+		return fmt.Errorf("%w: %q", ErrUnsupportedCapability, key)
+	}
+
+	return nil
+}
+
+func (h *Header) decodePrerequisite(line string) error {
+	hex, comment, _ := strings.Cut(line, " ")
+	hash, err := h.parseHash(hex)
+	if err != nil {
+		return err
+	}
+
+	h.Prerequisites = append(h.Prerequisites, Prerequisite{Hash: hash, Comment: comment})
+	return nil
+}
+
+func (h *Header) decodeReference(line string) error {
+	hex, name, ok := strings.Cut(line, " ")
+	if !ok || name == "" {
+		return fmt.Errorf("%w: malformed reference line %q", ErrInvalidBundle, line)
+	}
+
+	hash, err := h.parseHash(hex)
+	if err != nil {
+		return err
+	}
+
+	h.References = append(h.References, plumbing.NewHashReference(plumbing.ReferenceName(name), hash))
+	return nil
+}
+
+func (h *Header) parseHash(hex string) (plumbing.Hash, error) {
+	if len(hex) != h.ObjectFormat.HexSize() {
+		return plumbing.ZeroHash, fmt.Errorf("%w: invalid object id %q", ErrInvalidBundle, hex)
+	}
+
+	hash, ok := plumbing.FromHex(hex)
+	if !ok {
+		return plumbing.ZeroHash, fmt.Errorf("%w: invalid object id %q", ErrInvalidBundle, hex)
+	}
+
+	return hash, nil
+}
+
+func readLine(r *bufio.Reader) (string, error) {
+	line, err := r.ReadString('\n')
+	if err == io.EOF {
+		return "", fmt.Errorf("%w: unexpected end of header", ErrInvalidBundle)
+	}
+
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSuffix(line, "\n"), nil
+}
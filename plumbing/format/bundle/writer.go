@@ -0,0 +1,172 @@
+package bundle
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/go-git/go-git/v6/plumbing/format/packfile"
+	"github.com/go-git/go-git/v6/plumbing/object"
+	"github.com/go-git/go-git/v6/plumbing/revlist"
+	"github.com/go-git/go-git/v6/plumbing/storer"
+)
+
+// packWindow is the size of the window used for delta compression, the same
+// as the default pack.window of git.
+const packWindow = 10
+
+// Writer writes bundles with the objects of a storer.
+type Writer struct {
+	w io.Writer
+	s storer.EncodedObjectStorer
+}
+
+// NewWriter returns a new Writer writing to w the objects found in s.
+func NewWriter(w io.Writer, s storer.EncodedObjectStorer) *Writer {
+	return &Writer{w: w, s: s}
+}
+
+// Write writes a bundle with the given references, which must be hash
+// references, and the objects reachable from them.
+//
+// The objects reachable from the basis commits are excluded, making a thin
+// bundle. The excluded commits that are parents of the included ones are
+// listed as prerequisites, so the bundle can only be used by repositories
+// already having them. Without basis, the bundle contains the whole history
+// of the references.
+func (w *Writer) Write(refs []*plumbing.Reference, basis []plumbing.Hash) error {
+	tips := make([]plumbing.Hash, 0, len(refs))
+	for _, ref := range refs {
+		if ref.Type() != plumbing.HashReference {
+			return fmt.Errorf("bundle: reference %s is not a hash reference", ref.Name())
+		}
+
+		tips = append(tips, ref.Hash())
+	}
+
+	hashes, err := revlist.Objects(w.s, tips, basis)
+	if err != nil {
+		return err
+	}
+
+	if len(hashes) == 0 {
+		return ErrEmptyBundle
+	}
+
+	prerequisites, err := w.prerequisites(tips, hashes)
+	if err != nil {
+		return err
+	}
+
+	if err := w.writeHeader(prerequisites, refs); err != nil {
+		return err
+	}
+
+	_, err = packfile.NewEncoder(w.w, w.s, false).Encode(hashes, packWindow)
+	return err
+}
+
+func (w *Writer) writeHeader(prerequisites []Prerequisite, refs []*plumbing.Reference) error {
+	var b strings.Builder
+	b.WriteString(signatureV2 + "\n")
+	for _, p := range prerequisites {
+		fmt.Fprintf(&b, "-%s %s\n", p.Hash, p.Comment)
+	}
+
+	for _, ref := range refs {
+		fmt.Fprintf(&b, "%s %s\n", ref.Hash(), ref.Name())
+	}
+
+	b.WriteString("\n")
+
+	_, err := io.WriteString(w.w, b.String())
+	return err
+}
+
+// prerequisites returns the commits not included in the bundle that are
+// parents of the included ones.
+func (w *Writer) prerequisites(tips, included []plumbing.Hash) ([]Prerequisite, error) {
+	in := make(map[plumbing.Hash]bool, len(included))
+	for _, h := range included {
+		in[h] = true
+	}
+
+	var pending []*object.Commit
+	for _, h := range tips {
+		c, err := w.peelToCommit(h)
+		if err != nil {
+			return nil, err
+		}
+
+		if c != nil && in[c.Hash] {
+			pending = append(pending, c)
+		}
+	}
+
+	var res []Prerequisite
+	seen := make(map[plumbing.Hash]bool)
+	for len(pending) > 0 {
+		c := pending[0]
+		pending = pending[1:]
+		if seen[c.Hash] {
+			continue
+		}
+
+		seen[c.Hash] = true
+		for _, p := range c.ParentHashes {
+			if seen[p] {
+				continue
+			}
+
+			if in[p] {
+				parent, err := object.GetCommit(w.s, p)
+				if err != nil {
+					return nil, err
+				}
+
+				pending = append(pending, parent)
+				continue
+			}
+
+			seen[p] = true
+			res = append(res, w.prerequisite(p))
+		}
+	}
+
+	return res, nil
+}
+
+// prerequisite returns the prerequisite for the given commit, commented with
+// its subject when available.
+func (w *Writer) prerequisite(h plumbing.Hash) Prerequisite {
+	p := Prerequisite{Hash: h}
+	if c, err := object.GetCommit(w.s, h); err == nil {
+		p.Comment, _, _ = strings.Cut(strings.TrimSpace(c.Message), "\n")
+	}
+
+	return p
+}
+
+// peelToCommit returns the commit pointed by the given object, following
+// annotated tags, or nil if it doesn't point to a commit.
+func (w *Writer) peelToCommit(h plumbing.Hash) (*object.Commit, error) {
+	obj, err := object.GetObject(w.s, h)
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		switch o := obj.(type) {
+		case *object.Commit:
+			return o, nil
+		case *object.Tag:
+			obj, err = o.Object()
+			if err != nil {
+				return nil, err
+			}
+		default:
+			return nil, nil
+		}
+	}
+}
@@ -2,9 +2,12 @@ package commitgraph_test
 
 import (
 	"os"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/memfs"
 	"github.com/go-git/go-billy/v5/util"
 	"github.com/go-git/go-git/v6/plumbing"
 	"github.com/go-git/go-git/v6/plumbing/cache"
@@ -200,3 +203,52 @@ func (s *CommitgraphSuite) TestReencodeInMemory() {
 		testDecodeHelper(s, tmpIndex)
 	}
 }
+
+func (s *CommitgraphSuite) TestEncodeOctopusMerges() {
+	hash := func(c string) plumbing.Hash {
+		return plumbing.NewHash(strings.Repeat(c, 40))
+	}
+
+	root := hash("1")
+	a, b, c, d := hash("2"), hash("3"), hash("4"), hash("5")
+	merge1, merge2 := hash("6"), hash("7")
+	parents := map[plumbing.Hash][]plumbing.Hash{
+		root:   nil,
+		a:      {root},
+		b:      {root},
+		c:      {root},
+		d:      {root},
+		merge1: {a, b, c},
+		merge2: {merge1, b, c, d},
+	}
+
+	memoryIndex := commitgraph.NewMemoryIndex()
+	for _, h := range []plumbing.Hash{merge2, merge1, d, c, b, a, root} {
+		memoryIndex.Add(h, &commitgraph.CommitData{
+			TreeHash:     h,
+			ParentHashes: parents[h],
+			When:         time.Unix(1700000000, 0),
+		})
+	}
+
+	fs := memfs.New()
+	writer, err := fs.Create("commit-graph")
+	s.Require().NoError(err)
+	s.Require().NoError(commitgraph.NewEncoder(writer).Encode(memoryIndex))
+	s.Require().NoError(writer.Close())
+
+	index := testReadIndex(s, fs, "commit-graph")
+	defer index.Close()
+
+	for h, expected := range parents {
+		i, err := index.GetIndexByHash(h)
+		s.Require().NoError(err)
+		data, err := index.GetCommitDataByIndex(i)
+		s.Require().NoError(err)
+		s.Equal(h, data.TreeHash)
+		s.Equal(len(expected), len(data.ParentHashes), h.String())
+		for j, p := range expected {
+			s.Equal(p, data.ParentHashes[j])
+		}
+	}
+}
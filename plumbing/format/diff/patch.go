@@ -39,6 +39,19 @@ type FilePatch interface {
 	Chunks() []Chunk
 }
 
+// RenameFilePatch is a FilePatch able to tell whether a file was renamed or
+// copied, and how similar the files are. The FilePatches of files with
+// different paths not implementing it are encoded as renames.
+type RenameFilePatch interface {
+	FilePatch
+	// Similarity returns the similarity between the files, from 0 to 100,
+	// or 0 if unknown.
+	Similarity() int
+	// IsCopy returns true if the "to" File is a copy of the "from" File,
+	// instead of a rename.
+	IsCopy() bool
+}
+
 // File contains all the file metadata necessary to print some patch formats.
 type File interface {
 	// Hash returns the File Hash.
@@ -56,3 +69,12 @@ type Chunk interface {
 	// Type contains the Operation to do with this Chunk.
 	Type() Operation
 }
+
+// IgnorableChunk is a Chunk able to tell whether its change is ignored, e.g.
+// the blank lines added or removed in a diff ignoring them. The ignored
+// changes are only shown in the hunks of other changes.
+type IgnorableChunk interface {
+	Chunk
+	// Ignored returns true if the change can be left out of the patch.
+	Ignored() bool
+}
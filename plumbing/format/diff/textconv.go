@@ -0,0 +1,17 @@
+package diff
+
+// TextConv converts the content of a file to the text being diffed, as the
+// textconv program of a git diff driver does, e.g. to diff the text of a PDF
+// document instead of its raw content.
+type TextConv interface {
+	// TextConv returns the text of the given content.
+	TextConv(content []byte) ([]byte, error)
+}
+
+// TextConvFunc is a TextConv implemented by a function.
+type TextConvFunc func(content []byte) ([]byte, error)
+
+// TextConv calls f.
+func (f TextConvFunc) TextConv(content []byte) ([]byte, error) {
+	return f(content)
+}
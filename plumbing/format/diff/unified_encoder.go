@@ -30,7 +30,7 @@ var (
 )
 
 // UnifiedEncoder encodes an unified diff into the provided Writer. It does not
-// support similarity index for renames or sorting hash representations.
+// support sorting hash representations.
 type UnifiedEncoder struct {
 	io.Writer
 
@@ -56,6 +56,14 @@ func NewUnifiedEncoder(w io.Writer, contextLines int) *UnifiedEncoder {
 	}
 }
 
+// SetContextLines sets the count of unchanged lines shown around each change,
+// as `git diff -U<n>` does, and returns e. Changes closer than twice that
+// count are shown in the same hunk.
+func (e *UnifiedEncoder) SetContextLines(n int) *UnifiedEncoder {
+	e.contextLines = n
+	return e
+}
+
 // SetColor sets e's color configuration and returns e.
 func (e *UnifiedEncoder) SetColor(colorConfig ColorConfig) *UnifiedEncoder {
 	e.color = colorConfig
@@ -102,7 +110,6 @@ func (e *UnifiedEncoder) writeFilePatchHeader(sb *strings.Builder, filePatch Fil
 	if from == nil && to == nil {
 		return
 	}
-	isBinary := filePatch.IsBinary()
 
 	var lines []string
 	switch {
@@ -119,10 +126,7 @@ func (e *UnifiedEncoder) writeFilePatchHeader(sb *strings.Builder, filePatch Fil
 			)
 		}
 		if from.Path() != to.Path() {
-			lines = append(lines,
-				fmt.Sprintf("rename from %s", from.Path()),
-				fmt.Sprintf("rename to %s", to.Path()),
-			)
+			lines = appendRenameLines(lines, filePatch, from.Path(), to.Path())
 		}
 		if from.Mode() != to.Mode() && !hashEquals {
 			lines = append(lines,
@@ -134,7 +138,7 @@ func (e *UnifiedEncoder) writeFilePatchHeader(sb *strings.Builder, filePatch Fil
 			)
 		}
 		if !hashEquals {
-			lines = e.appendPathLines(lines, e.srcPrefix+from.Path(), e.dstPrefix+to.Path(), isBinary)
+			lines = e.appendPathLines(lines, e.srcPrefix+from.Path(), e.dstPrefix+to.Path(), filePatch)
 		}
 	case from == nil:
 		lines = append(lines,
@@ -142,14 +146,14 @@ func (e *UnifiedEncoder) writeFilePatchHeader(sb *strings.Builder, filePatch Fil
 			fmt.Sprintf("new file mode %o", to.Mode()),
 			fmt.Sprintf("index %s..%s", plumbing.ZeroHash, to.Hash()),
 		)
-		lines = e.appendPathLines(lines, "/dev/null", e.dstPrefix+to.Path(), isBinary)
+		lines = e.appendPathLines(lines, "/dev/null", e.dstPrefix+to.Path(), filePatch)
 	case to == nil:
 		lines = append(lines,
 			fmt.Sprintf("diff --git %s %s", e.srcPrefix+from.Path(), e.dstPrefix+from.Path()),
 			fmt.Sprintf("deleted file mode %o", from.Mode()),
 			fmt.Sprintf("index %s..%s", from.Hash(), plumbing.ZeroHash),
 		)
-		lines = e.appendPathLines(lines, e.srcPrefix+from.Path(), "/dev/null", isBinary)
+		lines = e.appendPathLines(lines, e.srcPrefix+from.Path(), "/dev/null", filePatch)
 	}
 
 	sb.WriteString(e.color[Meta])
@@ -162,12 +166,39 @@ func (e *UnifiedEncoder) writeFilePatchHeader(sb *strings.Builder, filePatch Fil
 	sb.WriteByte('\n')
 }
 
-func (e *UnifiedEncoder) appendPathLines(lines []string, fromPath, toPath string, isBinary bool) []string {
-	if isBinary {
+// appendRenameLines appends the similarity index, if known, and the paths of
+// the renamed or copied file.
+func appendRenameLines(lines []string, filePatch FilePatch, fromPath, toPath string) []string {
+	op := "rename"
+	if rp, ok := filePatch.(RenameFilePatch); ok {
+		if s := rp.Similarity(); s > 0 {
+			lines = append(lines, fmt.Sprintf("similarity index %d%%", s))
+		}
+
+		if rp.IsCopy() {
+			op = "copy"
+		}
+	}
+
+	return append(lines,
+		fmt.Sprintf("%s from %s", op, fromPath),
+		fmt.Sprintf("%s to %s", op, toPath),
+	)
+}
+
+func (e *UnifiedEncoder) appendPathLines(lines []string, fromPath, toPath string, filePatch FilePatch) []string {
+	if filePatch.IsBinary() {
 		return append(lines,
 			fmt.Sprintf("Binary files %s and %s differ", fromPath, toPath),
 		)
 	}
+
+	// As git does, the paths are omitted if there are no changes to show,
+	// e.g. when adding an empty file.
+	if len(filePatch.Chunks()) == 0 {
+		return lines
+	}
+
 	return append(lines,
 		fmt.Sprintf("--- %s", fromPath),
 		fmt.Sprintf("+++ %s", toPath),
@@ -175,15 +206,24 @@ func (e *UnifiedEncoder) appendPathLines(lines []string, fromPath, toPath string
 }
 
 type hunksGenerator struct {
-	fromLine, toLine            int
-	ctxLines                    int
-	chunks                      []Chunk
-	current                     *hunk
-	hunks                       []*hunk
-	beforeContext, afterContext []string
+	// fromLine and toLine are the count of lines of each side already
+	// processed.
+	fromLine, toLine int
+	ctxLines         int
+	chunks           []Chunk
+	current          *hunk
+	hunks            []*hunk
+	afterContext     []string
+	// beforeContext holds the unchanged lines before the next change, and
+	// the ignored changes among them, see IgnorableChunk.
+	beforeContext []*op
 }
 
 func newHunksGenerator(chunks []Chunk, ctxLines int) *hunksGenerator {
+	if ctxLines < 0 {
+		ctxLines = 0
+	}
+
 	return &hunksGenerator{
 		chunks:   chunks,
 		ctxLines: ctxLines,
@@ -195,26 +235,28 @@ func (g *hunksGenerator) Generate() []*hunk {
 		lines := splitLines(chunk.Content())
 		nLines := len(lines)
 
-		switch chunk.Type() {
-		case Equal:
+		switch {
+		case chunk.Type() == Equal:
+			g.processEqualsLines(lines, i)
 			g.fromLine += nLines
 			g.toLine += nLines
-			g.processEqualsLines(lines, i)
-		case Delete:
-			if nLines != 0 {
-				g.fromLine++
+		case g.current == nil && isIgnored(chunk):
+			// The ignored change is only shown as the context of the next
+			// one.
+			g.addBeforeContext(chunk.Type(), lines)
+			if chunk.Type() == Delete {
+				g.fromLine += nLines
+			} else {
+				g.toLine += nLines
 			}
-
-			g.processHunk(i, chunk.Type())
-			g.fromLine += nLines - 1
+		case chunk.Type() == Delete:
+			g.processHunk()
 			g.current.AddOp(chunk.Type(), lines...)
-		case Add:
-			if nLines != 0 {
-				g.toLine++
-			}
-			g.processHunk(i, chunk.Type())
-			g.toLine += nLines - 1
+			g.fromLine += nLines
+		case chunk.Type() == Add:
+			g.processHunk()
 			g.current.AddOp(chunk.Type(), lines...)
+			g.toLine += nLines
 		}
 
 		if i == len(g.chunks)-1 && g.current != nil {
@@ -225,61 +267,57 @@ func (g *hunksGenerator) Generate() []*hunk {
 	return g.hunks
 }
 
-func (g *hunksGenerator) processHunk(i int, op Operation) {
+// isIgnored returns true if the change of the chunk is ignored, see
+// IgnorableChunk.
+func isIgnored(chunk Chunk) bool {
+	c, ok := chunk.(IgnorableChunk)
+	return ok && c.Ignored()
+}
+
+// processHunk starts a new hunk, with the context before the change, if
+// there isn't a current one.
+func (g *hunksGenerator) processHunk() {
 	if g.current != nil {
 		return
 	}
 
 	var ctxPrefix string
-	linesBefore := len(g.beforeContext)
-	if linesBefore > g.ctxLines {
-		ctxPrefix = g.beforeContext[linesBefore-g.ctxLines-1]
-		g.beforeContext = g.beforeContext[linesBefore-g.ctxLines:]
-		linesBefore = g.ctxLines
-	}
-
-	g.current = &hunk{ctxPrefix: strings.TrimSuffix(ctxPrefix, "\n")}
-	g.current.AddOp(Equal, g.beforeContext...)
+	if n := len(g.beforeContext); n > g.ctxLines {
+		if prefix := g.beforeContext[n-g.ctxLines-1]; prefix.t == Equal {
+			ctxPrefix = prefix.text
+		}
 
-	switch op {
-	case Delete:
-		g.current.fromLine, g.current.toLine =
-			g.addLineNumbers(g.fromLine, g.toLine, linesBefore, i, Add)
-	case Add:
-		g.current.toLine, g.current.fromLine =
-			g.addLineNumbers(g.toLine, g.fromLine, linesBefore, i, Delete)
+		g.beforeContext = g.beforeContext[n-g.ctxLines:]
 	}
 
-	g.beforeContext = nil
-}
+	g.current = &hunk{
+		ctxPrefix: strings.TrimSuffix(ctxPrefix, "\n"),
+		fromLine:  g.fromLine + 1,
+		toLine:    g.toLine + 1,
+	}
 
-// addLineNumbers obtains the line numbers in a new chunk.
-func (g *hunksGenerator) addLineNumbers(la, lb int, linesBefore int, i int, op Operation) (cla, clb int) {
-	cla = la - linesBefore
-	// we need to search for a reference for the next diff
-	switch {
-	case linesBefore != 0 && g.ctxLines != 0:
-		if lb > g.ctxLines {
-			clb = lb - g.ctxLines + 1
-		} else {
-			clb = 1
+	for _, o := range g.beforeContext {
+		if o.t != Add {
+			g.current.fromLine--
 		}
-	case g.ctxLines == 0:
-		clb = lb
-	case i != len(g.chunks)-1:
-		next := g.chunks[i+1]
-		if next.Type() == op || next.Type() == Equal {
-			// this diff will be into this chunk
-			clb = lb + 1
+
+		if o.t != Delete {
+			g.current.toLine--
 		}
+
+		g.current.AddOp(o.t, o.text)
 	}
 
-	return
+	g.beforeContext = nil
 }
 
+// processEqualsLines adds the unchanged lines to the current hunk, if any.
+// Changes separated by up to twice the context lines are kept in the same
+// hunk, otherwise the hunk is closed and the lines kept as the context of
+// the next one.
 func (g *hunksGenerator) processEqualsLines(ls []string, i int) {
 	if g.current == nil {
-		g.beforeContext = append(g.beforeContext, ls...)
+		g.addBeforeContext(Equal, ls)
 		return
 	}
 
@@ -296,11 +334,24 @@ func (g *hunksGenerator) processEqualsLines(ls []string, i int) {
 		g.hunks = append(g.hunks, g.current)
 
 		g.current = nil
-		g.beforeContext = g.afterContext[ctxLines:]
+		g.addBeforeContext(Equal, g.afterContext[ctxLines:])
 		g.afterContext = nil
 	}
 }
 
+// addBeforeContext adds lines to the context before the next change. Only
+// the ones that can be used are kept: the context lines and the one before
+// them, used as the prefix of the hunk header.
+func (g *hunksGenerator) addBeforeContext(t Operation, ls []string) {
+	for _, l := range ls {
+		g.beforeContext = append(g.beforeContext, &op{l, t})
+	}
+
+	if n := len(g.beforeContext); n > g.ctxLines+1 {
+		g.beforeContext = g.beforeContext[n-g.ctxLines-1:]
+	}
+}
+
 func splitLines(s string) []string {
 	out := splitLinesRegexp.FindAllString(s, -1)
 	if out[len(out)-1] == "" {
@@ -323,25 +374,9 @@ type hunk struct {
 func (h *hunk) writeTo(sb *strings.Builder, color ColorConfig) {
 	sb.WriteString(color[Frag])
 	sb.WriteString("@@ -")
-
-	if h.fromCount == 1 {
-		sb.WriteString(strconv.Itoa(h.fromLine))
-	} else {
-		sb.WriteString(strconv.Itoa(h.fromLine))
-		sb.WriteByte(',')
-		sb.WriteString(strconv.Itoa(h.fromCount))
-	}
-
+	writeRange(sb, h.fromLine, h.fromCount)
 	sb.WriteString(" +")
-
-	if h.toCount == 1 {
-		sb.WriteString(strconv.Itoa(h.toLine))
-	} else {
-		sb.WriteString(strconv.Itoa(h.toLine))
-		sb.WriteByte(',')
-		sb.WriteString(strconv.Itoa(h.toCount))
-	}
-
+	writeRange(sb, h.toLine, h.toCount)
 	sb.WriteString(" @@")
 	sb.WriteString(color.Reset(Frag))
 
@@ -359,6 +394,21 @@ func (h *hunk) writeTo(sb *strings.Builder, color ColorConfig) {
 	}
 }
 
+// writeRange writes the range of a side of a hunk header. As git does, the
+// count is omitted when it's one, and an empty range starts at the line
+// before it.
+func writeRange(sb *strings.Builder, line, count int) {
+	if count == 0 {
+		line--
+	}
+
+	sb.WriteString(strconv.Itoa(line))
+	if count != 1 {
+		sb.WriteByte(',')
+		sb.WriteString(strconv.Itoa(count))
+	}
+}
+
 func (h *hunk) AddOp(t Operation, ss ...string) {
 	n := len(ss)
 	switch t {
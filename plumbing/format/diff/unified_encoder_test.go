@@ -2,6 +2,8 @@ package diff
 
 import (
 	"bytes"
+	"fmt"
+	"strings"
 	"testing"
 
 	"github.com/go-git/go-git/v6/plumbing"
@@ -54,6 +56,52 @@ Binary files a/binary and b/binary differ
 		buffer.String())
 }
 
+func (s *UnifiedEncoderTestSuite) TestRenameFilePatch() {
+	fp := testFilePatch{
+		from: &testFile{mode: filemode.Regular, path: "test.txt", seed: "test\n"},
+		to:   &testFile{mode: filemode.Regular, path: "test1.txt", seed: "test1\n"},
+		chunks: []testChunk{
+			{content: "test\n", op: Delete},
+			{content: "test1\n", op: Add},
+		},
+	}
+
+	for _, c := range []struct {
+		patch testRenameFilePatch
+		diff  string
+	}{{
+		patch: testRenameFilePatch{testFilePatch: fp, similarity: 60},
+		diff: "diff --git a/test.txt b/test1.txt\n" +
+			"similarity index 60%\n" +
+			"rename from test.txt\n" +
+			"rename to test1.txt\n",
+	}, {
+		patch: testRenameFilePatch{testFilePatch: fp, similarity: 60, copy: true},
+		diff: "diff --git a/test.txt b/test1.txt\n" +
+			"similarity index 60%\n" +
+			"copy from test.txt\n" +
+			"copy to test1.txt\n",
+	}, {
+		patch: testRenameFilePatch{testFilePatch: fp},
+		diff: "diff --git a/test.txt b/test1.txt\n" +
+			"rename from test.txt\n" +
+			"rename to test1.txt\n",
+	}} {
+		buffer := bytes.NewBuffer(nil)
+		e := NewUnifiedEncoder(buffer, 1)
+		s.NoError(e.Encode(testRenamePatch{c.patch}))
+
+		s.Equal(c.diff+
+			"index 9daeafb9864cf43055ae93beb0afd6c7d144bfa4..a5bce3fd2565d8f458555a0c6f42d0504a848bd5 100644\n"+
+			"--- a/test.txt\n"+
+			"+++ b/test1.txt\n"+
+			"@@ -1 +1 @@\n"+
+			"-test\n"+
+			"+test1\n",
+			buffer.String())
+	}
+}
+
 func (s *UnifiedEncoderTestSuite) TestCustomSrcDstPrefix() {
 	buffer := bytes.NewBuffer(nil)
 	e := NewUnifiedEncoder(buffer, 1).SetSrcPrefix("source/prefix/").SetDstPrefix("dest/prefix/")
@@ -97,6 +145,69 @@ func (s *UnifiedEncoderTestSuite) TestEncode() {
 	}
 }
 
+func (s *UnifiedEncoderTestSuite) TestSetContextLines() {
+	var from, to []string
+	for i := 1; i <= 30; i++ {
+		from = append(from, fmt.Sprintf("L%d\n", i))
+	}
+	to = append(to, from...)
+	to[2], to[14] = "three\n", "fifteen\n"
+
+	chunks := []testChunk{
+		{content: strings.Join(from[:2], ""), op: Equal},
+		{content: from[2], op: Delete},
+		{content: to[2], op: Add},
+		{content: strings.Join(from[3:14], ""), op: Equal},
+		{content: from[14], op: Delete},
+		{content: to[14], op: Add},
+		{content: strings.Join(from[15:], ""), op: Equal},
+	}
+
+	p := testPatch{filePatches: []testFilePatch{{
+		from:   &testFile{mode: filemode.Regular, path: "lines.txt", seed: strings.Join(from, "")},
+		to:     &testFile{mode: filemode.Regular, path: "lines.txt", seed: strings.Join(to, "")},
+		chunks: chunks,
+	}}}
+
+	context := func(from, to int) string {
+		var sb strings.Builder
+		for i := from; i <= to; i++ {
+			fmt.Fprintf(&sb, " L%d\n", i)
+		}
+		return sb.String()
+	}
+
+	for _, f := range []struct {
+		context int
+		hunks   string
+	}{{
+		context: 0,
+		hunks: "@@ -3 +3 @@ L2\n-L3\n+three\n" +
+			"@@ -15 +15 @@ L14\n-L15\n+fifteen\n",
+	}, {
+		// The changes are 11 lines apart, more than twice the context.
+		context: 5,
+		hunks: "@@ -1,8 +1,8 @@\n" + context(1, 2) + "-L3\n+three\n" + context(4, 8) +
+			"@@ -10,11 +10,11 @@ L9\n" + context(10, 14) + "-L15\n+fifteen\n" + context(16, 20),
+	}, {
+		context: 6,
+		hunks: "@@ -1,21 +1,21 @@\n" + context(1, 2) + "-L3\n+three\n" + context(4, 14) +
+			"-L15\n+fifteen\n" + context(16, 21),
+	}, {
+		context: 10,
+		hunks: "@@ -1,25 +1,25 @@\n" + context(1, 2) + "-L3\n+three\n" + context(4, 14) +
+			"-L15\n+fifteen\n" + context(16, 25),
+	}} {
+		buffer := bytes.NewBuffer(nil)
+		e := NewUnifiedEncoder(buffer, DefaultContextLines).SetContextLines(f.context)
+		s.Require().NoError(e.Encode(p))
+
+		_, hunks, ok := strings.Cut(buffer.String(), "+++ b/lines.txt\n")
+		s.Require().True(ok)
+		s.Equal(f.hunks, hunks, "context %d", f.context)
+	}
+}
+
 var oneChunkPatch Patch = testPatch{
 	message: "",
 	filePatches: []testFilePatch{{
@@ -885,7 +996,7 @@ index 0adddcde4fd38042c354518351820eb06c417c82..553ae669c7a9303cf848fcc749a25692
 index 0adddcde4fd38042c354518351820eb06c417c82..d39ae38aad7ba9447b5e7998b2e4714f26c9218d 100644
 --- a/onechunk.txt
 +++ b/onechunk.txt
-@@ -22,2 +21 @@ X
+@@ -22,2 +22 @@ X
 -Y
 -Z
 \ No newline at end of file
@@ -1045,6 +1156,32 @@ func (t testFilePatch) Chunks() []Chunk {
 	return result
 }
 
+type testRenameFilePatch struct {
+	testFilePatch
+	similarity int
+	copy       bool
+}
+
+func (t testRenameFilePatch) Similarity() int {
+	return t.similarity
+}
+
+func (t testRenameFilePatch) IsCopy() bool {
+	return t.copy
+}
+
+type testRenamePatch struct {
+	filePatch testRenameFilePatch
+}
+
+func (t testRenamePatch) FilePatches() []FilePatch {
+	return []FilePatch{t.filePatch}
+}
+
+func (t testRenamePatch) Message() string {
+	return ""
+}
+
 type testFile struct {
 	path string
 	mode filemode.FileMode
@@ -0,0 +1,119 @@
+package fetchhead
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"strings"
+
+	"github.com/go-git/go-git/v6/plumbing"
+)
+
+// ErrMalformedEntry is returned by Decode when a line of FETCH_HEAD can't be
+// parsed.
+var ErrMalformedEntry = errors.New("malformed FETCH_HEAD entry")
+
+// kinds maps the kinds of reference written in the descriptions to the
+// prefix of their full names.
+var kinds = []struct {
+	kind   string
+	prefix string
+}{
+	{"branch ", "refs/heads/"},
+	{"tag ", "refs/tags/"},
+	{"remote-tracking branch ", "refs/remotes/"},
+	{"", ""},
+}
+
+// A Decoder reads and decodes FETCH_HEAD entries from an input stream.
+type Decoder struct {
+	r *bufio.Reader
+}
+
+// NewDecoder returns a new decoder that reads from r.
+func NewDecoder(r io.Reader) *Decoder {
+	return &Decoder{bufio.NewReader(r)}
+}
+
+// Decode reads the next entry from the stream, returning io.EOF when there
+// are no more entries.
+func (d *Decoder) Decode(e *Entry) error {
+	for {
+		line, err := d.r.ReadString('\n')
+		if err != nil && (err != io.EOF || line == "") {
+			return err
+		}
+
+		line = strings.TrimSuffix(line, "\n")
+		if line == "" {
+			continue
+		}
+
+		return decodeEntry(line, e)
+	}
+}
+
+// DecodeAll reads all the entries from the stream, in order.
+func (d *Decoder) DecodeAll() ([]*Entry, error) {
+	var entries []*Entry
+	for {
+		e := &Entry{}
+		err := d.Decode(e)
+		if err == io.EOF {
+			return entries, nil
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		entries = append(entries, e)
+	}
+}
+
+func decodeEntry(line string, e *Entry) error {
+	fields := strings.SplitN(line, "\t", 3)
+	if len(fields) != 3 {
+		return ErrMalformedEntry
+	}
+
+	var ok bool
+	e.Hash, ok = plumbing.FromHex(fields[0])
+	if !ok {
+		return ErrMalformedEntry
+	}
+
+	switch fields[1] {
+	case "":
+		e.NotForMerge = false
+	case NotForMerge:
+		e.NotForMerge = true
+	default:
+		return ErrMalformedEntry
+	}
+
+	return decodeDescription(fields[2], e)
+}
+
+func decodeDescription(desc string, e *Entry) error {
+	for _, k := range kinds {
+		rest, ok := strings.CutPrefix(desc, k.kind+"'")
+		if !ok {
+			continue
+		}
+
+		name, url, ok := strings.Cut(rest, "' of ")
+		if !ok || name == "" {
+			return ErrMalformedEntry
+		}
+
+		e.Name = plumbing.ReferenceName(k.prefix + name)
+		e.URL = url
+		return nil
+	}
+
+	// The remote HEAD is described by the URL alone.
+	e.Name = plumbing.HEAD
+	e.URL = desc
+	return nil
+}
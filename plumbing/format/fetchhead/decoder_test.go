@@ -0,0 +1,65 @@
+package fetchhead
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const fetchHeadFixture = "" +
+	"6ecf0ef2c2dffb796033e5a02219af86ec6584e5\t\tbranch 'master' of https://github.com/git-fixtures/basic\n" +
+	"e8d3ffab552895c19b9fcf7aa264d277cde33881\tnot-for-merge\tbranch 'branch' of https://github.com/git-fixtures/basic\n" +
+	"\n" +
+	"b029517f6300c2da0f4b651b8642506cd6aaf45d\tnot-for-merge\ttag 'v1.0.0' of https://github.com/git-fixtures/basic\n" +
+	"b8e471f58bcbca63b07bda20e428190409c2db47\tnot-for-merge\tremote-tracking branch 'origin/foo' of /tmp/repo\n" +
+	"a5b8b09e2f8fcb0bb99d3ccb0958157b40890d69\tnot-for-merge\t'refs/pull/1/head' of /tmp/repo\n" +
+	"35e85108805c84807bc66a02d91535e1e24b38b9\t\t/tmp/repo"
+
+func TestDecodeAll(t *testing.T) {
+	entries, err := NewDecoder(strings.NewReader(fetchHeadFixture)).DecodeAll()
+	require.NoError(t, err)
+
+	url := "https://github.com/git-fixtures/basic"
+	assert.Equal(t, []*Entry{
+		{plumbing.NewHash("6ecf0ef2c2dffb796033e5a02219af86ec6584e5"), false, "refs/heads/master", url},
+		{plumbing.NewHash("e8d3ffab552895c19b9fcf7aa264d277cde33881"), true, "refs/heads/branch", url},
+		{plumbing.NewHash("b029517f6300c2da0f4b651b8642506cd6aaf45d"), true, "refs/tags/v1.0.0", url},
+		{plumbing.NewHash("b8e471f58bcbca63b07bda20e428190409c2db47"), true, "refs/remotes/origin/foo", "/tmp/repo"},
+		{plumbing.NewHash("a5b8b09e2f8fcb0bb99d3ccb0958157b40890d69"), true, "refs/pull/1/head", "/tmp/repo"},
+		{plumbing.NewHash("35e85108805c84807bc66a02d91535e1e24b38b9"), false, plumbing.HEAD, "/tmp/repo"},
+	}, entries)
+
+	entries, err = NewDecoder(strings.NewReader("")).DecodeAll()
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+}
+
+func TestDecode(t *testing.T) {
+	d := NewDecoder(strings.NewReader(fetchHeadFixture))
+	for range 6 {
+		var e Entry
+		require.NoError(t, d.Decode(&e))
+	}
+
+	var e Entry
+	assert.Equal(t, io.EOF, d.Decode(&e))
+}
+
+func TestDecodeMalformed(t *testing.T) {
+	for _, line := range []string{
+		"foo",
+		"6ecf0ef2c2dffb796033e5a02219af86ec6584e5\tbranch 'master' of /tmp/repo",
+		"foo\t\tbranch 'master' of /tmp/repo",
+		"6ecf0ef2c2dffb796033e5a02219af86ec6584e5\tfor-merge\tbranch 'master' of /tmp/repo",
+		"6ecf0ef2c2dffb796033e5a02219af86ec6584e5\t\tbranch 'master' from /tmp/repo",
+		"6ecf0ef2c2dffb796033e5a02219af86ec6584e5\t\ttag '' of /tmp/repo",
+	} {
+		var e Entry
+		err := NewDecoder(strings.NewReader(line)).Decode(&e)
+		assert.ErrorIs(t, err, ErrMalformedEntry, line)
+	}
+}
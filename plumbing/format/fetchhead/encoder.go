@@ -0,0 +1,38 @@
+package fetchhead
+
+import (
+	"fmt"
+	"io"
+)
+
+// An Encoder writes FETCH_HEAD entries to an output stream.
+type Encoder struct {
+	w io.Writer
+}
+
+// NewEncoder returns a new encoder that writes to w.
+func NewEncoder(w io.Writer) *Encoder {
+	return &Encoder{w}
+}
+
+// Encode writes the entry to the stream of the encoder as a single line.
+func (e *Encoder) Encode(entry *Entry) error {
+	var marker string
+	if entry.NotForMerge {
+		marker = NotForMerge
+	}
+
+	_, err := fmt.Fprintf(e.w, "%s\t%s\t%s\n", entry.Hash, marker, entry.description())
+	return err
+}
+
+// EncodeAll writes all the given entries to the stream, in order.
+func (e *Encoder) EncodeAll(entries []*Entry) error {
+	for _, entry := range entries {
+		if err := e.Encode(entry); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,40 @@
+package fetchhead
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncode(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, NewEncoder(&buf).EncodeAll([]*Entry{
+		{plumbing.NewHash("6ecf0ef2c2dffb796033e5a02219af86ec6584e5"), false, "refs/heads/master", "/tmp/repo"},
+		{plumbing.NewHash("b029517f6300c2da0f4b651b8642506cd6aaf45d"), true, "refs/tags/v1.0.0", "/tmp/repo"},
+		{plumbing.NewHash("35e85108805c84807bc66a02d91535e1e24b38b9"), true, plumbing.HEAD, "/tmp/repo"},
+	}))
+
+	assert.Equal(t, ""+
+		"6ecf0ef2c2dffb796033e5a02219af86ec6584e5\t\tbranch 'master' of /tmp/repo\n"+
+		"b029517f6300c2da0f4b651b8642506cd6aaf45d\tnot-for-merge\ttag 'v1.0.0' of /tmp/repo\n"+
+		"35e85108805c84807bc66a02d91535e1e24b38b9\tnot-for-merge\t/tmp/repo\n",
+		buf.String())
+}
+
+func TestEncodeDecode(t *testing.T) {
+	expected := []*Entry{
+		{plumbing.NewHash("6ecf0ef2c2dffb796033e5a02219af86ec6584e5"), false, "refs/heads/feature/a", "git@github.com:foo/bar"},
+		{plumbing.NewHash("b8e471f58bcbca63b07bda20e428190409c2db47"), true, "refs/remotes/origin/main", "/tmp/repo"},
+		{plumbing.NewHash("a5b8b09e2f8fcb0bb99d3ccb0958157b40890d69"), true, "refs/changes/01/1/1", "/tmp/repo"},
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, NewEncoder(&buf).EncodeAll(expected))
+
+	entries, err := NewDecoder(&buf).DecodeAll()
+	require.NoError(t, err)
+	assert.Equal(t, expected, entries)
+}
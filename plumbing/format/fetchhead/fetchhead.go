@@ -0,0 +1,49 @@
+// Package fetchhead implements encoding and decoding of the FETCH_HEAD file,
+// where git records the references fetched by the last fetch.
+//
+// Each line of the file is an entry, with the format:
+//
+//	<hash> TAB [not-for-merge] TAB <description> LF
+//
+// where the description is the kind and short name of the fetched reference
+// followed by the URL of the remote, e.g. "branch 'main' of <url>". The
+// entries to be merged by a pull come first and have an empty marker.
+package fetchhead
+
+import "github.com/go-git/go-git/v6/plumbing"
+
+// NotForMerge is the marker of the entries not to be merged by a pull.
+const NotForMerge = "not-for-merge"
+
+// Entry is an entry of FETCH_HEAD, a reference fetched from a remote.
+type Entry struct {
+	// Hash is the value of the reference when it was fetched.
+	Hash plumbing.Hash
+	// NotForMerge is true when a pull doesn't merge the reference into the
+	// current branch.
+	NotForMerge bool
+	// Name is the full name of the reference in the remote, e.g.
+	// refs/heads/main, or HEAD.
+	Name plumbing.ReferenceName
+	// URL is the URL of the remote the reference was fetched from.
+	URL string
+}
+
+// description returns the text describing the entry, as git writes it.
+func (e *Entry) description() string {
+	var kind, name string
+	switch {
+	case e.Name == plumbing.HEAD:
+		return e.URL
+	case e.Name.IsBranch():
+		kind, name = "branch ", e.Name.Short()
+	case e.Name.IsTag():
+		kind, name = "tag ", e.Name.Short()
+	case e.Name.IsRemote():
+		kind, name = "remote-tracking branch ", e.Name.Short()
+	default:
+		name = e.Name.String()
+	}
+
+	return kind + "'" + name + "' of " + e.URL
+}
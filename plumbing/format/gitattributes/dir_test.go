@@ -151,8 +151,9 @@ func (s *MatcherSuite) TestDir_ReadPatterns() {
 	results, _ := m.Match([]string{"vendor", "gopkg.in", "file"}, nil)
 	s.Equal("bar", results["foo"].Value())
 
+	// the closest .gitattributes wins, as git check-attr does
 	results, _ = m.Match([]string{"vendor", "github.com", "file"}, nil)
-	s.False(results["foo"].IsUnset())
+	s.True(results["foo"].IsUnset())
 }
 
 func (s *MatcherSuite) TestDir_LoadGlobalPatterns() {
@@ -38,6 +38,9 @@ func (m *matcher) init() {
 // the attributes associated with the path.
 //
 // Specific attributes can be specified otherwise all attributes are returned.
+// When several patterns set the same attribute, the one with the highest
+// priority wins, i.e. the one in the .gitattributes closest to the path, or
+// the last one in the same file.
 //
 // Matched is true if any path was matched to a rule, even if the results map
 // is empty.
@@ -55,19 +58,47 @@ func (m *matcher) Match(path []string, attributes []string) (results map[string]
 			continue
 		}
 
-		if match := pattern.Match(path); match {
-			matched = true
-			for _, attr := range m.stack[i].Attributes {
-				if attr.IsSet() {
-					m.expandMacro(attr.Name(), results)
-				}
-				results[attr.Name()] = attr
+		if match := pattern.Match(path); !match {
+			continue
+		}
+
+		matched = true
+
+		// the attributes of the same line are applied in order, so the
+		// expansion of a macro can be overridden by the attributes after it
+		line := make(map[string]Attribute, len(m.stack[i].Attributes))
+		for _, attr := range m.stack[i].Attributes {
+			if attr.IsSet() {
+				m.expandMacro(attr.Name(), line)
 			}
+			line[attr.Name()] = attr
+		}
+
+		for name, attr := range line {
+			if _, ok := results[name]; ok || !isRequested(name, attributes) {
+				continue
+			}
+
+			results[name] = attr
 		}
 	}
 	return
 }
 
+func isRequested(name string, attributes []string) bool {
+	if len(attributes) == 0 {
+		return true
+	}
+
+	for _, a := range attributes {
+		if a == name {
+			return true
+		}
+	}
+
+	return false
+}
+
 func (m *matcher) expandMacro(name string, results map[string]Attribute) bool {
 	if macro, ok := m.macros[name]; ok {
 		for _, attr := range macro.Attributes {
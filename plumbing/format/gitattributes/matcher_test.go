@@ -25,3 +25,33 @@ func (s *MatcherSuite) TestMatcher_Match() {
 	s.True(results["text"].IsSet())
 	s.Equal("crlf", results["eol"].Value())
 }
+
+func (s *MatcherSuite) TestMatcher_MatchPriority() {
+	root, err := ReadAttributes(strings.NewReader("*.txt -diff merge=binary\n*.txt eol=lf\n"), nil, true)
+	s.NoError(err)
+
+	sub, err := ReadAttributes(strings.NewReader("*.txt diff\n"), []string{"sub"}, false)
+	s.NoError(err)
+
+	m := NewMatcher(append(root, sub...))
+
+	results, matched := m.Match([]string{"a.txt"}, nil)
+	s.True(matched)
+	s.True(results["diff"].IsUnset())
+	s.Equal("binary", results["merge"].Value())
+	s.Equal("lf", results["eol"].Value())
+
+	results, matched = m.Match([]string{"sub", "deep", "a.txt"}, nil)
+	s.True(matched)
+	s.True(results["diff"].IsSet())
+	s.Equal("binary", results["merge"].Value())
+
+	results, matched = m.Match([]string{"sub", "a.txt"}, []string{"diff"})
+	s.True(matched)
+	s.Len(results, 1)
+	s.True(results["diff"].IsSet())
+
+	results, matched = m.Match([]string{"a.go"}, nil)
+	s.False(matched)
+	s.Empty(results)
+}
@@ -4,6 +4,9 @@ package gitignore
 type Matcher interface {
 	// Match matches patterns in the order of priorities. As soon as an inclusion or
 	// exclusion is found, not further matching is performed.
+	//
+	// As git does, a path inside an excluded directory is always excluded, it
+	// can't be included again by a pattern matching the path itself.
 	Match(path []string, isDir bool) bool
 }
 
@@ -20,6 +23,16 @@ type matcher struct {
 }
 
 func (m *matcher) Match(path []string, isDir bool) bool {
+	for i := 1; i < len(path); i++ {
+		if m.match(path[:i], true) {
+			return true
+		}
+	}
+
+	return m.match(path, isDir)
+}
+
+func (m *matcher) match(path []string, isDir bool) bool {
 	n := len(m.patterns)
 	for i := n - 1; i >= 0; i-- {
 		if match := m.patterns[i].Match(path, isDir); match > NoMatch {
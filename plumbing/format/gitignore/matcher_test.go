@@ -1,5 +1,7 @@
 package gitignore
 
+import "strings"
+
 func (s *MatcherSuite) TestMatcher_Match() {
 	ps := []Pattern{
 		ParsePattern("**/middle/v[uo]l?ano", nil),
@@ -10,3 +12,110 @@ func (s *MatcherSuite) TestMatcher_Match() {
 	s.True(m.Match([]string{"head", "middle", "vulkano"}, false))
 	s.False(m.Match([]string{"head", "middle", "volcano"}, false))
 }
+
+func (s *MatcherSuite) TestMatcher_MatchPrecedence() {
+	type check struct {
+		path    string
+		isDir   bool
+		ignored bool
+	}
+
+	for _, c := range []struct {
+		patterns []string
+		checks   []check
+	}{{
+		// A file can't be included again when its directory is excluded.
+		patterns: []string{"build/", "!build/keep.txt"},
+		checks: []check{
+			{"build", true, true},
+			{"build", false, false},
+			{"build/keep.txt", false, true},
+			{"src/build/keep.txt", false, true},
+		},
+	}, {
+		patterns: []string{"logs/*", "!logs/important.log"},
+		checks: []check{
+			{"logs", true, false},
+			{"logs/debug.log", false, true},
+			{"logs/important.log", false, false},
+		},
+	}, {
+		// Example from gitignore(5), excluding everything but foo/bar.
+		patterns: []string{"/*", "!/foo", "/foo/*", "!/foo/bar"},
+		checks: []check{
+			{"foo", true, false},
+			{"foo/bar", false, false},
+			{"foo/bar/baz", false, false},
+			{"foo/baz", false, true},
+			{"qux", false, true},
+			{"qux/foo/bar", false, true},
+		},
+	}, {
+		patterns: []string{"*.log", "!important.log"},
+		checks: []check{
+			{"debug.log", false, true},
+			{"important.log", false, false},
+			{"logs/important.log", false, false},
+		},
+	}, {
+		patterns: []string{"frotz/"},
+		checks: []check{
+			{"frotz", true, true},
+			{"frotz", false, false},
+			{"a/frotz", true, true},
+			{"a/frotz/file", false, true},
+		},
+	}, {
+		patterns: []string{"doc/frotz/"},
+		checks: []check{
+			{"doc/frotz", true, true},
+			{"doc/frotz", false, false},
+			{"a/doc/frotz", true, false},
+		},
+	}, {
+		patterns: []string{"**/foo"},
+		checks: []check{
+			{"foo", false, true},
+			{"a/foo", false, true},
+			{"a/b/foo", true, true},
+			{"foo/bar", false, true},
+			{"foobar", false, false},
+		},
+	}, {
+		patterns: []string{"**/foo/bar"},
+		checks: []check{
+			{"foo/bar", false, true},
+			{"a/foo/bar", false, true},
+			{"a/foo/b/foo/bar", false, true},
+			{"a/foo/b/bar", false, false},
+		},
+	}, {
+		patterns: []string{"foo/**/bar"},
+		checks: []check{
+			{"foo/bar", false, true},
+			{"foo/a/bar", false, true},
+			{"foo/a/b/bar", false, true},
+			{"foo/a/bar/b/bar", false, true},
+			{"a/foo/bar", false, false},
+		},
+	}, {
+		patterns: []string{"abc/**"},
+		checks: []check{
+			{"abc", true, false},
+			{"abc/x", false, true},
+			{"abc/x/y", false, true},
+			{"a/abc/x", false, false},
+		},
+	}} {
+		var ps []Pattern
+		for _, p := range c.patterns {
+			ps = append(ps, ParsePattern(p, nil))
+		}
+
+		m := NewMatcher(ps)
+		for _, check := range c.checks {
+			s.Equal(check.ignored, m.Match(strings.Split(check.path, "/"), check.isDir),
+				"%q with patterns %q", check.path, c.patterns)
+		}
+	}
+}
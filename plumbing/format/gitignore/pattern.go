@@ -105,55 +105,43 @@ func (p *pattern) simpleNameMatch(path []string, isDir bool) bool {
 }
 
 func (p *pattern) globMatch(path []string, isDir bool) bool {
-	matched := false
-	canTraverse := false
-	for i, pattern := range p.pattern {
-		if pattern == "" {
-			canTraverse = false
-			continue
-		}
-		if pattern == zeroToManyDirs {
-			if i == len(p.pattern)-1 {
-				break
-			}
-			canTraverse = true
-			continue
-		}
-		if strings.Contains(pattern, zeroToManyDirs) {
-			return false
-		}
-		if len(path) == 0 {
-			return false
-		}
-		if canTraverse {
-			canTraverse = false
-			for len(path) > 0 {
-				e := path[0]
-				path = path[1:]
-				if match, err := filepath.Match(pattern, e); err != nil {
-					return false
-				} else if match {
-					matched = true
-					break
-				} else if len(path) == 0 {
-					// if nothing left then fail
-					matched = false
-				}
-			}
-		} else {
-			if match, err := filepath.Match(pattern, path[0]); err != nil || !match {
-				return false
-			}
-			matched = true
-			path = path[1:]
-			// files matching dir globs, don't match
-			if len(path) == 0 && i < len(p.pattern)-1 {
-				matched = false
+	return p.globMatchAt(p.pattern, path, isDir)
+}
+
+// globMatchAt returns whether the pattern matches the start of the path. The
+// path under a matching directory matches as well, while the directory-only
+// patterns only match a whole path when it is a directory.
+func (p *pattern) globMatchAt(pattern, path []string, isDir bool) bool {
+	if len(pattern) == 0 {
+		return len(path) > 0 || !p.dirOnly || isDir
+	}
+
+	switch elem := pattern[0]; {
+	case elem == "":
+		return p.globMatchAt(pattern[1:], path, isDir)
+	case elem == zeroToManyDirs && len(pattern) == 1:
+		// A trailing "**" matches everything inside, but not the directory
+		// itself.
+		return len(path) > 1 || len(path) == 1 && (!p.dirOnly || isDir)
+	case elem == zeroToManyDirs:
+		// A "**" matches zero or more directories, trying each of them.
+		for i := 0; i < len(path); i++ {
+			if p.globMatchAt(pattern[1:], path[i:], isDir) {
+				return true
 			}
 		}
+
+		return false
+	case strings.Contains(elem, zeroToManyDirs):
+		return false
+	case len(path) == 0:
+		// The files don't match the directories of a pattern.
+		return false
 	}
-	if matched && p.dirOnly && !isDir && len(path) == 0 {
-		matched = false
+
+	if match, err := filepath.Match(pattern[0], path[0]); err != nil || !match {
+		return false
 	}
-	return matched
+
+	return p.globMatchAt(pattern[1:], path[1:], isDir)
 }
@@ -4,6 +4,7 @@ import (
 	"crypto"
 	"io"
 
+	format "github.com/go-git/go-git/v6/plumbing/format/config"
 	"github.com/go-git/go-git/v6/plumbing/hash"
 	"github.com/go-git/go-git/v6/utils/binary"
 )
@@ -11,19 +12,26 @@ import (
 // Encoder writes MemoryIndex structs to an output stream.
 type Encoder struct {
 	io.Writer
+	w    io.Writer
 	hash hash.Hash
 }
 
 // NewEncoder returns a new stream encoder that writes to w.
 func NewEncoder(w io.Writer) *Encoder {
-	// TODO: Support passing an ObjectFormat (sha256)
-	h := hash.New(crypto.SHA1)
-	mw := io.MultiWriter(w, h)
-	return &Encoder{mw, h}
+	return &Encoder{w: w}
 }
 
-// Encode encodes an MemoryIndex to the encoder writer.
+// Encode encodes an MemoryIndex to the encoder writer. The checksum of the
+// index is computed with the hash algorithm matching the size of its object
+// IDs.
 func (e *Encoder) Encode(idx *MemoryIndex) (int, error) {
+	e.hash = hash.New(crypto.SHA1)
+	if idx.idSize() == format.SHA256Size {
+		e.hash = hash.New(crypto.SHA256)
+	}
+
+	e.Writer = io.MultiWriter(e.w, e.hash)
+
 	flow := []func(*MemoryIndex) (int, error){
 		e.encodeHeader,
 		e.encodeFanout,
@@ -135,6 +143,7 @@ func (e *Encoder) encodeChecksums(idx *MemoryIndex) (int, error) {
 		return 0, err
 	}
 
+	idx.IdxChecksum.ResetBySize(e.hash.Size())
 	if _, err := idx.IdxChecksum.Write(e.hash.Sum(nil)[:e.hash.Size()]); err != nil {
 		return 0, err
 	}
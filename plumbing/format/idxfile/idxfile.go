@@ -222,7 +222,8 @@ func (idx *MemoryIndex) genOffsetHash() error {
 	for firstLevel, fanoutValue := range idx.Fanout {
 		mappedFirstLevel := idx.FanoutMapping[firstLevel]
 		for secondLevel := uint32(0); i < fanoutValue; i++ {
-			hash.Write(idx.Names[mappedFirstLevel][secondLevel*uint32(idx.idSize()):])
+			off := secondLevel * uint32(idx.idSize())
+			hash.Write(idx.Names[mappedFirstLevel][off : off+uint32(idx.idSize())])
 			offset := int64(idx.getOffset(mappedFirstLevel, int(secondLevel)))
 			idx.offsetHash[offset] = hash
 			secondLevel++
@@ -307,7 +308,8 @@ func (i *idxfileEntryIter) Next() (*Entry, error) {
 
 		mappedFirstLevel := i.idx.FanoutMapping[i.firstLevel]
 		entry := new(Entry)
-		entry.Hash.Write(i.idx.Names[mappedFirstLevel][i.secondLevel*i.idx.idSize():])
+		off := i.secondLevel * i.idx.idSize()
+		entry.Hash.Write(i.idx.Names[mappedFirstLevel][off : off+i.idx.idSize()])
 		entry.Offset = i.idx.getOffset(mappedFirstLevel, i.secondLevel)
 		entry.CRC32 = i.idx.getCRC32(mappedFirstLevel, i.secondLevel)
 
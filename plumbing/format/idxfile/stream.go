@@ -0,0 +1,371 @@
+package idxfile
+
+import (
+	"bufio"
+	"bytes"
+	"container/heap"
+	"crypto"
+	"io"
+	"math"
+	"slices"
+
+	encbin "encoding/binary"
+
+	"github.com/go-git/go-git/v6/plumbing"
+	format "github.com/go-git/go-git/v6/plumbing/format/config"
+	"github.com/go-git/go-git/v6/plumbing/hash"
+	"github.com/go-git/go-git/v6/utils/binary"
+)
+
+// DefaultStreamRunSize is the number of entries kept in memory by a
+// StreamWriter before they are written to its temporary file.
+const DefaultStreamRunSize = 1 << 16
+
+// TempFile is the temporary storage used by StreamWriter for the entries of
+// the index, such as an *os.File or a billy.File. It is only written at its
+// end, and read back at the offsets previously written.
+type TempFile interface {
+	io.Writer
+	io.ReaderAt
+}
+
+// StreamWriter implements the packfile Observer interface and generates an
+// index as Writer does, but with a bounded memory usage: the entries are
+// sorted in runs of a fixed size written to a temporary file, and merged in a
+// compact sorted list when the packfile ends. The index is then written from
+// that list, the fanout table being computed while merging.
+type StreamWriter struct {
+	w       io.Writer
+	tmp     TempFile
+	runSize int
+
+	size     int64
+	idSize   int
+	entries  []Entry
+	runs     []streamRun
+	checksum plumbing.Hash
+	finished bool
+}
+
+// streamRun is a sorted list of entries of the temporary file.
+type streamRun struct {
+	offset int64
+	count  int
+}
+
+// NewStreamWriter returns a StreamWriter writing the index to w, using tmp
+// to store the entries. At most runSize entries are kept in memory, or
+// DefaultStreamRunSize when runSize isn't positive.
+func NewStreamWriter(w io.Writer, tmp TempFile, runSize int) *StreamWriter {
+	if runSize <= 0 {
+		runSize = DefaultStreamRunSize
+	}
+
+	return &StreamWriter{w: w, tmp: tmp, runSize: runSize}
+}
+
+// Finished returns whether the index was written.
+func (w *StreamWriter) Finished() bool {
+	return w.finished
+}
+
+// OnHeader implements packfile.Observer interface.
+func (w *StreamWriter) OnHeader(count uint32) error {
+	w.entries = make([]Entry, 0, min(int(count), w.runSize))
+	return nil
+}
+
+// OnInflatedObjectHeader implements packfile.Observer interface.
+func (w *StreamWriter) OnInflatedObjectHeader(t plumbing.ObjectType, objSize int64, pos int64) error {
+	return nil
+}
+
+// OnInflatedObjectContent implements packfile.Observer interface.
+func (w *StreamWriter) OnInflatedObjectContent(h plumbing.Hash, pos int64, crc uint32, _ []byte) error {
+	if w.idSize == 0 {
+		w.idSize = h.Size()
+	}
+
+	w.entries = append(w.entries, Entry{Hash: h, CRC32: crc, Offset: uint64(pos)})
+	if len(w.entries) < w.runSize {
+		return nil
+	}
+
+	return w.flush()
+}
+
+// OnFooter implements packfile.Observer interface, writing the index.
+func (w *StreamWriter) OnFooter(h plumbing.Hash) error {
+	w.checksum = h
+	if w.idSize == 0 {
+		w.idSize = h.Size()
+	}
+
+	if err := w.flush(); err != nil {
+		return err
+	}
+
+	if err := w.encode(); err != nil {
+		return err
+	}
+
+	w.finished = true
+	return nil
+}
+
+// flush sorts the entries in memory and writes them as a new run.
+func (w *StreamWriter) flush() error {
+	if len(w.entries) == 0 {
+		return nil
+	}
+
+	// The sort is stable so that the first entry of a duplicated object is
+	// kept, as Writer does.
+	slices.SortStableFunc(w.entries, func(a, b Entry) int {
+		return a.Hash.Compare(b.Hash.Bytes())
+	})
+
+	run := streamRun{offset: w.size, count: len(w.entries)}
+	buf := bufio.NewWriter(&tempWriter{w: w})
+	for _, e := range w.entries {
+		if err := w.writeEntry(buf, e); err != nil {
+			return err
+		}
+	}
+
+	if err := buf.Flush(); err != nil {
+		return err
+	}
+
+	w.runs = append(w.runs, run)
+	w.entries = w.entries[:0]
+	return nil
+}
+
+func (w *StreamWriter) entrySize() int64 {
+	return int64(w.idSize) + 12
+}
+
+func (w *StreamWriter) writeEntry(bw io.Writer, e Entry) error {
+	if _, err := bw.Write(e.Hash.Bytes()); err != nil {
+		return err
+	}
+
+	if err := binary.WriteUint32(bw, e.CRC32); err != nil {
+		return err
+	}
+
+	return binary.WriteUint64(bw, e.Offset)
+}
+
+func (w *StreamWriter) readEntry(r io.Reader, buf []byte) (Entry, error) {
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return Entry{}, err
+	}
+
+	h, _ := plumbing.FromBytes(buf[:w.idSize])
+	return Entry{
+		Hash:   h,
+		CRC32:  encbin.BigEndian.Uint32(buf[w.idSize:]),
+		Offset: encbin.BigEndian.Uint64(buf[w.idSize+4:]),
+	}, nil
+}
+
+// runReader returns a reader of the entries of the given run.
+func (w *StreamWriter) runReader(run streamRun) io.Reader {
+	return bufio.NewReader(io.NewSectionReader(w.tmp, run.offset, int64(run.count)*w.entrySize()))
+}
+
+// merge merges all the runs in a single one without duplicated objects,
+// returning it along with the fanout table.
+func (w *StreamWriter) merge() (streamRun, [fanout]uint32, error) {
+	var fan [fanout]uint32
+	merged := streamRun{offset: w.size}
+
+	h := make(runHeap, 0, len(w.runs))
+	for i, run := range w.runs {
+		c := &runCursor{r: w.runReader(run), left: run.count, run: i, buf: make([]byte, w.entrySize())}
+		if err := c.next(w); err != nil {
+			return merged, fan, err
+		}
+
+		h = append(h, c)
+	}
+	heap.Init(&h)
+
+	buf := bufio.NewWriter(&tempWriter{w: w})
+	var last plumbing.Hash
+	for len(h) > 0 {
+		c := h[0]
+		e := c.entry
+		if merged.count == 0 || e.Hash != last {
+			if err := w.writeEntry(buf, e); err != nil {
+				return merged, fan, err
+			}
+
+			fan[e.Hash.Bytes()[0]]++
+			merged.count++
+			last = e.Hash
+		}
+
+		if c.left == 0 {
+			heap.Pop(&h)
+			continue
+		}
+
+		if err := c.next(w); err != nil {
+			return merged, fan, err
+		}
+		heap.Fix(&h, 0)
+	}
+
+	if err := buf.Flush(); err != nil {
+		return merged, fan, err
+	}
+
+	for i := 1; i < fanout; i++ {
+		fan[i] += fan[i-1]
+	}
+
+	return merged, fan, nil
+}
+
+// encode writes the index from the merged list of entries, reading it once
+// for each of the tables of the index.
+func (w *StreamWriter) encode() error {
+	merged, fan, err := w.merge()
+	if err != nil {
+		return err
+	}
+
+	hasher := hash.New(crypto.SHA1)
+	if w.idSize == format.SHA256Size {
+		hasher = hash.New(crypto.SHA256)
+	}
+
+	out := bufio.NewWriter(io.MultiWriter(w.w, hasher))
+	if _, err := out.Write(idxHeader); err != nil {
+		return err
+	}
+
+	if err := binary.WriteUint32(out, VersionSupported); err != nil {
+		return err
+	}
+
+	for _, c := range fan {
+		if err := binary.WriteUint32(out, c); err != nil {
+			return err
+		}
+	}
+
+	var offset64 bytes.Buffer
+	var count64 uint32
+	tables := []func(e Entry) error{
+		func(e Entry) error {
+			_, err := out.Write(e.Hash.Bytes())
+			return err
+		},
+		func(e Entry) error {
+			return binary.WriteUint32(out, e.CRC32)
+		},
+		func(e Entry) error {
+			offset := e.Offset
+			if offset > math.MaxInt32 {
+				if err := binary.WriteUint64(&offset64, offset); err != nil {
+					return err
+				}
+
+				offset = uint64(count64 | (1 << 31))
+				count64++
+			}
+
+			return binary.WriteUint32(out, uint32(offset))
+		},
+	}
+
+	buf := make([]byte, w.entrySize())
+	for _, table := range tables {
+		r := w.runReader(merged)
+		for i := 0; i < merged.count; i++ {
+			e, err := w.readEntry(r, buf)
+			if err != nil {
+				return err
+			}
+
+			if err := table(e); err != nil {
+				return err
+			}
+		}
+	}
+
+	if _, err := out.Write(offset64.Bytes()); err != nil {
+		return err
+	}
+
+	if _, err := out.Write(w.checksum.Bytes()); err != nil {
+		return err
+	}
+
+	if err := out.Flush(); err != nil {
+		return err
+	}
+
+	_, err = w.w.Write(hasher.Sum(nil))
+	return err
+}
+
+// tempWriter writes at the end of the temporary file of a StreamWriter.
+type tempWriter struct {
+	w *StreamWriter
+}
+
+func (t *tempWriter) Write(p []byte) (int, error) {
+	n, err := t.w.tmp.Write(p)
+	t.w.size += int64(n)
+	return n, err
+}
+
+// runCursor is the current entry of a run being merged.
+type runCursor struct {
+	r     io.Reader
+	left  int
+	run   int
+	buf   []byte
+	entry Entry
+}
+
+func (c *runCursor) next(w *StreamWriter) error {
+	e, err := w.readEntry(c.r, c.buf)
+	if err != nil {
+		return err
+	}
+
+	c.entry = e
+	c.left--
+	return nil
+}
+
+// runHeap orders the runs by their current entry, and the runs written first
+// for the same object.
+type runHeap []*runCursor
+
+func (h runHeap) Len() int { return len(h) }
+
+func (h runHeap) Less(i, j int) bool {
+	if cmp := h[i].entry.Hash.Compare(h[j].entry.Hash.Bytes()); cmp != 0 {
+		return cmp < 0
+	}
+
+	return h[i].run < h[j].run
+}
+
+func (h runHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+
+func (h *runHeap) Push(x any) { *h = append(*h, x.(*runCursor)) }
+
+func (h *runHeap) Pop() any {
+	old := *h
+	c := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return c
+}
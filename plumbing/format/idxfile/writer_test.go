@@ -2,8 +2,10 @@ package idxfile_test
 
 import (
 	"bytes"
+	"crypto/sha256"
 	"encoding/base64"
 	"io"
+	"os"
 	"testing"
 
 	"github.com/go-git/go-git/v6/plumbing"
@@ -79,6 +81,112 @@ func (s *WriterSuite) TestWriterLarge() {
 	s.Equal(expected, buf.Bytes())
 }
 
+func (s *WriterSuite) TestWriterSHA256() {
+	hashes := []plumbing.Hash{
+		sha256Hash("foo"), sha256Hash("bar"), sha256Hash("qux"),
+	}
+
+	writer := new(idxfile.Writer)
+	s.NoError(writer.OnHeader(uint32(len(hashes))))
+	for i, h := range hashes {
+		s.NoError(writer.OnInflatedObjectContent(h, int64(12+i*100), uint32(i), nil))
+	}
+
+	s.NoError(writer.OnFooter(sha256Hash("pack")))
+
+	idx, err := writer.Index()
+	s.NoError(err)
+
+	buf := new(bytes.Buffer)
+	n, err := idxfile.NewEncoder(buf).Encode(idx)
+	s.NoError(err)
+	s.Equal(8+256*4+len(hashes)*(32+4+4)+2*32, n)
+	s.Equal(32, idx.IdxChecksum.Size())
+
+	decoded := idxfile.NewMemoryIndex(32)
+	s.NoError(idxfile.NewDecoder(buf).Decode(decoded))
+	s.Equal(idx.IdxChecksum, decoded.IdxChecksum)
+
+	for i, h := range hashes {
+		offset, err := decoded.FindOffset(h)
+		s.NoError(err)
+		s.Equal(int64(12+i*100), offset)
+	}
+}
+
+func (s *WriterSuite) tempFile() *os.File {
+	f, err := os.CreateTemp(s.T().TempDir(), "idx")
+	s.Require().NoError(err)
+	s.T().Cleanup(func() { f.Close() })
+	return f
+}
+
+func (s *WriterSuite) TestStreamWriter() {
+	f := fixtures.Basic().One()
+	expected, err := io.ReadAll(f.Idx())
+	s.Require().NoError(err)
+
+	for _, runSize := range []int{1, 5, 0} {
+		buf := new(bytes.Buffer)
+		obs := idxfile.NewStreamWriter(buf, s.tempFile(), runSize)
+		parser := packfile.NewParser(packfile.NewScanner(f.Packfile()), packfile.WithScannerObservers(obs))
+
+		_, err := parser.Parse()
+		s.NoError(err)
+		s.True(obs.Finished())
+		s.Equal(expected, buf.Bytes(), "run size %d", runSize)
+	}
+}
+
+func (s *WriterSuite) TestStreamWriterLarge() {
+	buf := new(bytes.Buffer)
+	writer := idxfile.NewStreamWriter(buf, s.tempFile(), 2)
+	s.NoError(writer.OnHeader(uint32(len(fixture4GbEntries))))
+
+	for _, o := range fixture4GbEntries {
+		err := writer.OnInflatedObjectContent(plumbing.NewHash(o.hash), o.offset, o.crc, nil)
+		s.NoError(err)
+	}
+
+	s.NoError(writer.OnFooter(fixture4GbChecksum))
+
+	expected, err := io.ReadAll(base64.NewDecoder(base64.StdEncoding, bytes.NewBufferString(fixtureLarge4GB)))
+	s.NoError(err)
+	s.Equal(expected, buf.Bytes())
+}
+
+func (s *WriterSuite) TestStreamWriterMatchesWriter() {
+	hashes := []plumbing.Hash{
+		sha256Hash("foo"), sha256Hash("bar"), sha256Hash("qux"), sha256Hash("foo"), sha256Hash("baz"),
+	}
+
+	// The duplicated object lands in another run than its first entry.
+	batch := new(idxfile.Writer)
+	buf := new(bytes.Buffer)
+	stream := idxfile.NewStreamWriter(buf, s.tempFile(), 2)
+	for _, obs := range []packfile.Observer{batch, stream} {
+		s.NoError(obs.OnHeader(uint32(len(hashes))))
+		for i, h := range hashes {
+			s.NoError(obs.OnInflatedObjectContent(h, int64(12+i*100), uint32(i), nil))
+		}
+		s.NoError(obs.OnFooter(sha256Hash("pack")))
+	}
+
+	idx, err := batch.Index()
+	s.Require().NoError(err)
+
+	expected := new(bytes.Buffer)
+	_, err = idxfile.NewEncoder(expected).Encode(idx)
+	s.NoError(err)
+	s.Equal(expected.Bytes(), buf.Bytes())
+}
+
+func sha256Hash(s string) plumbing.Hash {
+	sum := sha256.Sum256([]byte(s))
+	h, _ := plumbing.FromBytes(sum[:])
+	return h
+}
+
 var (
 	fixture4GbChecksum = plumbing.NewHash("afabc2269205cf85da1bf7e2fdff42f73810f29b")
 
@@ -3,7 +3,6 @@ package index
 import (
 	"bufio"
 	"bytes"
-	"crypto"
 	"errors"
 	"io"
 
@@ -22,7 +21,7 @@ var (
 	// ErrMalformedSignature is returned by Decode when the index header file is
 	// malformed
 	ErrMalformedSignature = errors.New("malformed index signature file")
-	// ErrInvalidChecksum is returned by Decode if the SHA1 hash mismatch with
+	// ErrInvalidChecksum is returned by Decode if the hash mismatch with
 	// the read content
 	ErrInvalidChecksum = errors.New("invalid checksum")
 	// ErrUnknownExtension is returned when an index extension is encountered that is considered mandatory
@@ -30,7 +29,9 @@ var (
 )
 
 const (
-	entryHeaderLength = 62
+	// entryHeaderLength is the length of the fixed-size fields of an entry,
+	// not including the object name, whose size depends on the object format.
+	entryHeaderLength = 42
 	entryExtended     = 0x4000
 	entryValid        = 0x8000
 	nameMask          = 0xfff
@@ -44,19 +45,21 @@ type Decoder struct {
 	r         io.Reader
 	hash      hash.Hash
 	lastEntry *Entry
+	validate  bool
 
 	extReader *bufio.Reader
 }
 
 // NewDecoder returns a new decoder that reads from r.
-func NewDecoder(r io.Reader) *Decoder {
-	// TODO: Support passing an ObjectFormat (sha256)
-	h := hash.New(crypto.SHA1)
+func NewDecoder(r io.Reader, opts ...Option) *Decoder {
+	o := newOptions(opts)
+	h := o.newHash()
 	buf := bufio.NewReader(r)
 	return &Decoder{
 		buf:       buf,
 		r:         io.TeeReader(buf, h),
 		hash:      h,
+		validate:  o.validate,
 		extReader: bufio.NewReader(nil),
 	}
 }
@@ -79,7 +82,15 @@ func (d *Decoder) Decode(idx *Index) error {
 		return err
 	}
 
-	return d.readExtensions(idx)
+	if err := d.readExtensions(idx); err != nil {
+		return err
+	}
+
+	if d.validate {
+		return idx.Validate()
+	}
+
+	return nil
 }
 
 func (d *Decoder) readEntries(idx *Index, count int) error {
@@ -117,6 +128,7 @@ func (d *Decoder) readEntry(idx *Index) (*Entry, error) {
 		return nil, err
 	}
 
+	e.Hash.ResetBySize(d.hash.Size())
 	if _, err := e.Hash.ReadFrom(d.r); err != nil {
 		return nil, err
 	}
@@ -125,7 +137,7 @@ func (d *Decoder) readEntry(idx *Index) (*Entry, error) {
 		return nil, err
 	}
 
-	read := entryHeaderLength
+	read := entryHeaderLength + d.hash.Size()
 
 	if sec != 0 || nsec != 0 {
 		e.CreatedAt = time.Unix(int64(sec), int64(nsec))
@@ -263,19 +275,19 @@ func (d *Decoder) readExtension(idx *Index) error {
 	switch {
 	case bytes.Equal(header[:], treeExtSignature):
 		idx.Cache = &Tree{}
-		d := &treeExtensionDecoder{r}
+		d := &treeExtensionDecoder{r, d.hash.Size()}
 		if err := d.Decode(idx.Cache); err != nil {
 			return err
 		}
 	case bytes.Equal(header[:], resolveUndoExtSignature):
 		idx.ResolveUndo = &ResolveUndo{}
-		d := &resolveUndoDecoder{r}
+		d := &resolveUndoDecoder{r, d.hash.Size()}
 		if err := d.Decode(idx.ResolveUndo); err != nil {
 			return err
 		}
 	case bytes.Equal(header[:], endOfIndexEntryExtSignature):
 		idx.EndOfIndexEntry = &EndOfIndexEntry{}
-		d := &endOfIndexEntryDecoder{r}
+		d := &endOfIndexEntryDecoder{r, d.hash.Size()}
 		if err := d.Decode(idx.EndOfIndexEntry); err != nil {
 			return err
 		}
@@ -307,12 +319,17 @@ func (d *Decoder) getExtensionReader() (*bufio.Reader, error) {
 
 func (d *Decoder) readChecksum(expected []byte) error {
 	var h plumbing.Hash
+	h.ResetBySize(d.hash.Size())
 
 	if _, err := h.ReadFrom(d.r); err != nil {
 		return err
 	}
 
 	if h.Compare(expected) != 0 {
+		if d.validate {
+			return &CorruptIndexError{Err: ErrInvalidChecksum}
+		}
+
 		return ErrInvalidChecksum
 	}
 
@@ -342,7 +359,8 @@ func validateHeader(r io.Reader) (version uint32, err error) {
 }
 
 type treeExtensionDecoder struct {
-	r *bufio.Reader
+	r        *bufio.Reader
+	hashSize int
 }
 
 func (d *treeExtensionDecoder) Decode(t *Tree) error {
@@ -384,24 +402,25 @@ func (d *treeExtensionDecoder) readEntry() (*TreeEntry, error) {
 		return nil, err
 	}
 
+	trees, err := binary.ReadUntil(d.r, '\n')
+	if err != nil {
+		return nil, err
+	}
+
 	// An entry can be in an invalidated state and is represented by having a
-	// negative number in the entry_count field.
+	// negative number in the entry_count field, it has no object name.
 	if i == -1 {
 		return nil, nil
 	}
 
 	e.Entries = i
-	trees, err := binary.ReadUntil(d.r, '\n')
-	if err != nil {
-		return nil, err
-	}
-
 	i, err = strconv.Atoi(string(trees))
 	if err != nil {
 		return nil, err
 	}
 
 	e.Trees = i
+	e.Hash.ResetBySize(d.hashSize)
 	_, err = e.Hash.ReadFrom(d.r)
 	if err != nil {
 		return nil, err
@@ -410,7 +429,8 @@ func (d *treeExtensionDecoder) readEntry() (*TreeEntry, error) {
 }
 
 type resolveUndoDecoder struct {
-	r *bufio.Reader
+	r        *bufio.Reader
+	hashSize int
 }
 
 func (d *resolveUndoDecoder) Decode(ru *ResolveUndo) error {
@@ -448,6 +468,7 @@ func (d *resolveUndoDecoder) readEntry() (*ResolveUndoEntry, error) {
 
 	for s := range e.Stages {
 		var h plumbing.Hash
+		h.ResetBySize(d.hashSize)
 		if _, err := h.ReadFrom(d.r); err != nil {
 			return nil, err
 		}
@@ -477,7 +498,8 @@ func (d *resolveUndoDecoder) readStage(e *ResolveUndoEntry, s Stage) error {
 }
 
 type endOfIndexEntryDecoder struct {
-	r *bufio.Reader
+	r        *bufio.Reader
+	hashSize int
 }
 
 func (d *endOfIndexEntryDecoder) Decode(e *EndOfIndexEntry) error {
@@ -487,6 +509,7 @@ func (d *endOfIndexEntryDecoder) Decode(e *EndOfIndexEntry) error {
 		return err
 	}
 
+	e.Hash.ResetBySize(d.hashSize)
 	_, err = e.Hash.ReadFrom(d.r)
 	return err
 }
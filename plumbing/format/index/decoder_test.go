@@ -321,3 +321,63 @@ func (s *IndexSuite) TestDecodeInvalidHash() {
 	err = d.Decode(idx)
 	s.ErrorContains(err, ErrInvalidChecksum.Error())
 }
+
+func (s *IndexSuite) TestDecodeWithValidation() {
+	idx := s.readSimpleIndex()
+	idx.Entries = append(idx.Entries, &Entry{Name: idx.Entries[0].Name})
+
+	buf := bytes.NewBuffer(nil)
+	s.NoError(NewEncoder(buf).Encode(idx))
+	raw := buf.Bytes()
+
+	// Without validation, the duplicated entry is decoded.
+	idx = &Index{}
+	s.NoError(NewDecoder(bytes.NewReader(raw)).Decode(idx))
+	s.Len(idx.Entries, 10)
+
+	idx = &Index{}
+	err := NewDecoder(bytes.NewReader(raw), WithValidation()).Decode(idx)
+	s.ErrorIs(err, ErrDuplicateEntry)
+
+	var cerr *CorruptIndexError
+	s.ErrorAs(err, &cerr)
+	s.Equal(".gitignore", cerr.Name)
+}
+
+func (s *IndexSuite) TestDecodeWithValidationUnsorted() {
+	idx := &Index{
+		Version: 2,
+		Entries: []*Entry{{Name: "foo"}, {Name: "bar"}},
+	}
+
+	// The encoder sorts the entries, so they are written one by one.
+	buf := bytes.NewBuffer(nil)
+	e := NewEncoder(buf)
+	e.version = idx.Version
+	s.NoError(e.encodeHeader(idx))
+	for _, entry := range idx.Entries {
+		s.NoError(e.encodeEntry(entry))
+		s.NoError(e.padEntry(entryHeaderLength + e.hash.Size() + len(entry.Name)))
+	}
+	s.NoError(e.encodeFooter())
+
+	err := NewDecoder(buf, WithValidation()).Decode(&Index{})
+	s.ErrorIs(err, ErrUnsortedEntries)
+}
+
+func (s *IndexSuite) TestDecodeWithValidationInvalidHash() {
+	idx := s.readSimpleIndex()
+
+	buf := bytes.NewBuffer(nil)
+	e := NewEncoder(buf)
+	s.NoError(e.encode(idx, false))
+
+	h := hash.New(crypto.SHA1)
+	s.NoError(binary.Write(e.w, h.Sum(nil)))
+
+	idx = &Index{}
+	err := NewDecoder(buf, WithValidation()).Decode(idx)
+	s.ErrorIs(err, ErrInvalidChecksum)
+	s.ErrorAs(err, new(*CorruptIndexError))
+	s.Len(idx.Entries, 9)
+}
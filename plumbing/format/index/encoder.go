@@ -2,15 +2,15 @@ package index
 
 import (
 	"bytes"
-	"crypto"
 	"errors"
 	"fmt"
 	"io"
-	"path"
 	"sort"
 	"strings"
 	"time"
 
+	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/go-git/go-git/v6/plumbing/filemode"
 	"github.com/go-git/go-git/v6/plumbing/hash"
 	"github.com/go-git/go-git/v6/utils/binary"
 )
@@ -28,28 +28,40 @@ var (
 type Encoder struct {
 	w         io.Writer
 	hash      hash.Hash
+	opts      *options
+	version   uint32
 	lastEntry *Entry
 }
 
 // NewEncoder returns a new encoder that writes to w.
-func NewEncoder(w io.Writer) *Encoder {
-	// TODO: Support passing an ObjectFormat (sha256)
-	h := hash.New(crypto.SHA1)
+func NewEncoder(w io.Writer, opts ...Option) *Encoder {
+	o := newOptions(opts)
+	h := o.newHash()
 	mw := io.MultiWriter(w, h)
-	return &Encoder{mw, h, nil}
+	return &Encoder{w: mw, hash: h, opts: o}
 }
 
-// Encode writes the Index to the stream of the encoder.
+// Encode writes the Index to the stream of the encoder, using the version of
+// the Index unless another one is forced with WithVersion.
+//
+// When the Index has a cached tree extension, it is written rebuilt from the
+// entries, so it never gets stale. The other extensions are not written.
 func (e *Encoder) Encode(idx *Index) error {
 	return e.encode(idx, true)
 }
 
 func (e *Encoder) encode(idx *Index, footer bool) error {
-	// TODO: support extensions
-	if idx.Version > EncodeVersionSupported {
+	e.version = idx.Version
+	if e.opts.version != 0 {
+		e.version = e.opts.version
+	}
+
+	// TODO: support the other extensions
+	if e.version > EncodeVersionSupported {
 		return ErrUnsupportedVersion
 	}
 
+	e.lastEntry = nil
 	if err := e.encodeHeader(idx); err != nil {
 		return err
 	}
@@ -58,6 +70,12 @@ func (e *Encoder) encode(idx *Index, footer bool) error {
 		return err
 	}
 
+	if idx.Cache != nil {
+		if err := e.encodeTreeExtension(idx); err != nil {
+			return err
+		}
+	}
+
 	if footer {
 		return e.encodeFooter()
 	}
@@ -67,7 +85,7 @@ func (e *Encoder) encode(idx *Index, footer bool) error {
 func (e *Encoder) encodeHeader(idx *Index) error {
 	return binary.Write(e.w,
 		indexSignature,
-		idx.Version,
+		e.version,
 		uint32(len(idx.Entries)),
 	)
 }
@@ -76,16 +94,16 @@ func (e *Encoder) encodeEntries(idx *Index) error {
 	sort.Sort(byName(idx.Entries))
 
 	for _, entry := range idx.Entries {
-		if err := e.encodeEntry(idx, entry); err != nil {
+		if err := e.encodeEntry(entry); err != nil {
 			return err
 		}
-		entryLength := entryHeaderLength
+		entryLength := entryHeaderLength + e.hash.Size()
 		if entry.IntentToAdd || entry.SkipWorktree {
 			entryLength += 2
 		}
 
 		wrote := entryLength + len(entry.Name)
-		if err := e.padEntry(idx, wrote); err != nil {
+		if err := e.padEntry(wrote); err != nil {
 			return err
 		}
 	}
@@ -93,7 +111,7 @@ func (e *Encoder) encodeEntries(idx *Index) error {
 	return nil
 }
 
-func (e *Encoder) encodeEntry(idx *Index, entry *Entry) error {
+func (e *Encoder) encodeEntry(entry *Entry) error {
 	sec, nsec, err := e.timeToUint32(&entry.CreatedAt)
 	if err != nil {
 		return err
@@ -144,7 +162,7 @@ func (e *Encoder) encodeEntry(idx *Index, entry *Entry) error {
 		return err
 	}
 
-	switch idx.Version {
+	switch e.version {
 	case 2, 3:
 		err = e.encodeEntryName(entry)
 	case 4:
@@ -160,27 +178,28 @@ func (e *Encoder) encodeEntryName(entry *Entry) error {
 	return binary.Write(e.w, []byte(entry.Name))
 }
 
+// encodeEntryNameV4 writes the name of the entry compressed as git does, as
+// the number of bytes to remove from the end of the previous name, followed
+// by the bytes to append to what remains of it.
 func (e *Encoder) encodeEntryNameV4(entry *Entry) error {
-	name := entry.Name
-	l := 0
+	var previous string
 	if e.lastEntry != nil {
-		dir := path.Dir(e.lastEntry.Name) + "/"
-		if strings.HasPrefix(entry.Name, dir) {
-			l = len(e.lastEntry.Name) - len(dir)
-			name = strings.TrimPrefix(entry.Name, dir)
-		} else {
-			l = len(e.lastEntry.Name)
-		}
+		previous = e.lastEntry.Name
 	}
 
 	e.lastEntry = entry
 
-	err := binary.WriteVariableWidthInt(e.w, int64(l))
+	common := 0
+	for common < len(entry.Name) && common < len(previous) && entry.Name[common] == previous[common] {
+		common++
+	}
+
+	err := binary.WriteVariableWidthInt(e.w, int64(len(previous)-common))
 	if err != nil {
 		return err
 	}
 
-	return binary.Write(e.w, []byte(name+string('\x00')))
+	return binary.Write(e.w, []byte(entry.Name[common:]+string('\x00')))
 }
 
 func (e *Encoder) encodeRawExtension(signature string, data []byte) error {
@@ -206,6 +225,108 @@ func (e *Encoder) encodeRawExtension(signature string, data []byte) error {
 	return nil
 }
 
+// encodeTreeExtension writes the cached tree extension, built from the
+// entries as `git write-tree` does. As git does, the trees holding unmerged
+// or intent-to-add entries are written invalidated, since they can't be
+// written as tree objects.
+func (e *Encoder) encodeTreeExtension(idx *Index) error {
+	hasher, err := plumbing.FromObjectFormat(e.opts.objectFormat)
+	if err != nil {
+		return err
+	}
+
+	b := &cacheTreeBuilder{hasher: hasher}
+	root, _, err := b.build(idx.Entries, "")
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	root.encode(&buf)
+	return e.encodeRawExtension(string(treeExtSignature), buf.Bytes())
+}
+
+// cacheTree is a node of the cached tree extension.
+type cacheTree struct {
+	name string
+	// entries is the number of index entries covered, or -1 when the tree
+	// is invalidated.
+	entries  int
+	hash     plumbing.Hash
+	subtrees []*cacheTree
+}
+
+// encode writes the tree and its subtrees, in pre-order.
+func (t *cacheTree) encode(w *bytes.Buffer) {
+	fmt.Fprintf(w, "%s\x00%d %d\n", t.name, t.entries, len(t.subtrees))
+	if t.entries >= 0 {
+		w.Write(t.hash.Bytes())
+	}
+
+	for _, sub := range t.subtrees {
+		sub.encode(w)
+	}
+}
+
+type cacheTreeBuilder struct {
+	hasher *plumbing.ObjectHasher
+}
+
+// build returns the tree of the directory with the given prefix, made of the
+// first entries, which must be sorted, and the number of entries covered.
+func (b *cacheTreeBuilder) build(entries []*Entry, prefix string) (*cacheTree, int, error) {
+	var content bytes.Buffer
+	t := &cacheTree{}
+	valid := true
+
+	i := 0
+	for i < len(entries) && strings.HasPrefix(entries[i].Name, prefix) {
+		e := entries[i]
+		name := e.Name[len(prefix):]
+		if dir, _, ok := strings.Cut(name, "/"); ok {
+			sub, n, err := b.build(entries[i:], prefix+dir+"/")
+			if err != nil {
+				return nil, 0, err
+			}
+
+			sub.name = dir
+			t.subtrees = append(t.subtrees, sub)
+			valid = valid && sub.entries >= 0
+			fmt.Fprintf(&content, "%o %s\x00", filemode.Dir, dir)
+			content.Write(sub.hash.Bytes())
+			i += n
+			continue
+		}
+
+		valid = valid && e.Stage == Merged && !e.IntentToAdd
+		fmt.Fprintf(&content, "%o %s\x00", e.Mode, name)
+		content.Write(e.Hash.Bytes())
+		i++
+	}
+
+	// The subtrees are sorted by the length of their name first, as git does.
+	sort.SliceStable(t.subtrees, func(a, c int) bool {
+		na, nc := t.subtrees[a].name, t.subtrees[c].name
+		if len(na) != len(nc) {
+			return len(na) < len(nc)
+		}
+
+		return na < nc
+	})
+
+	t.entries = -1
+	if valid {
+		h, err := b.hasher.Compute(plumbing.TreeObject, content.Bytes())
+		if err != nil {
+			return nil, 0, err
+		}
+
+		t.entries, t.hash = i, h
+	}
+
+	return t, i, nil
+}
+
 func (e *Encoder) timeToUint32(t *time.Time) (uint32, uint32, error) {
 	if t.IsZero() {
 		return 0, 0, nil
@@ -218,8 +339,8 @@ func (e *Encoder) timeToUint32(t *time.Time) (uint32, uint32, error) {
 	return uint32(t.Unix()), uint32(t.Nanosecond()), nil
 }
 
-func (e *Encoder) padEntry(idx *Index, wrote int) error {
-	if idx.Version == 4 {
+func (e *Encoder) padEntry(wrote int) error {
+	if e.version == 4 {
 		return nil
 	}
 
@@ -235,6 +356,12 @@ func (e *Encoder) encodeFooter() error {
 
 type byName []*Entry
 
-func (l byName) Len() int           { return len(l) }
-func (l byName) Swap(i, j int)      { l[i], l[j] = l[j], l[i] }
-func (l byName) Less(i, j int) bool { return l[i].Name < l[j].Name }
+func (l byName) Len() int      { return len(l) }
+func (l byName) Swap(i, j int) { l[i], l[j] = l[j], l[i] }
+func (l byName) Less(i, j int) bool {
+	if l[i].Name == l[j].Name {
+		return l[i].Stage < l[j].Stage
+	}
+
+	return l[i].Name < l[j].Name
+}
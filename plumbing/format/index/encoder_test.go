@@ -2,11 +2,14 @@ package index
 
 import (
 	"bytes"
+	"io"
 	"strings"
 	"testing"
 	"time"
 
+	fixtures "github.com/go-git/go-git-fixtures/v5"
 	"github.com/go-git/go-git/v6/plumbing"
+	format "github.com/go-git/go-git/v6/plumbing/format/config"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -56,6 +59,42 @@ func TestEncode(t *testing.T) {
 
 }
 
+func TestEncodeSHA256(t *testing.T) {
+	idx := &Index{
+		Version: 2,
+		Entries: []*Entry{{
+			CreatedAt:  time.Now(),
+			ModifiedAt: time.Now(),
+			Size:       42,
+			Hash:       plumbing.NewHash("1534a3d4bba7f54c8bf5ce2b1462c9c9ba5292e9f9706dd155e6650c679d886a"),
+			Name:       "foo",
+		}, {
+			CreatedAt:    time.Now(),
+			ModifiedAt:   time.Now(),
+			Size:         82,
+			Hash:         plumbing.NewHash("5f46b2a7b8c3b1b5e3c2d6a9e9b8f0d5b4a3c2d1e0f9a8b7c6d5e4f3a2b1c0d9"),
+			Name:         "bar",
+			SkipWorktree: true,
+		}},
+	}
+
+	buf := bytes.NewBuffer(nil)
+	e := NewEncoder(buf, WithObjectFormat(format.SHA256))
+	err := e.Encode(idx)
+	require.NoError(t, err)
+
+	// header, two entries padded to 8 bytes and the checksum
+	assert.Equal(t, 12+80+80+32, buf.Len())
+
+	output := &Index{}
+	d := NewDecoder(buf, WithObjectFormat(format.SHA256))
+	err = d.Decode(output)
+	require.NoError(t, err)
+
+	assert.EqualExportedValues(t, idx, output)
+	assert.Equal(t, format.SHA256Size, output.Entries[0].Hash.Size())
+}
+
 func TestEncodeV4(t *testing.T) {
 	idx := &Index{
 		Version: 4,
@@ -160,3 +199,112 @@ func TestEncodeWithSkipWorktreeUnsupportedVersion(t *testing.T) {
 	assert.EqualExportedValues(t, idx, output)
 	assert.Equal(t, true, output.Entries[0].SkipWorktree)
 }
+
+func readFixtureIndex(t *testing.T, tag string) []byte {
+	f, err := fixtures.Basic().ByTag(tag).One().DotGit().Open("index")
+	require.NoError(t, err)
+	defer func() { require.NoError(t, f.Close()) }()
+
+	content, err := io.ReadAll(f)
+	require.NoError(t, err)
+	return content
+}
+
+func TestEncodeRoundTrip(t *testing.T) {
+	// The indexes are written by git, with a cached tree extension.
+	for _, tag := range []string{"index-v4", "intent-to-add"} {
+		expected := readFixtureIndex(t, tag)
+
+		idx := &Index{}
+		require.NoError(t, NewDecoder(bytes.NewReader(expected)).Decode(idx))
+		require.NotNil(t, idx.Cache)
+
+		buf := bytes.NewBuffer(nil)
+		require.NoError(t, NewEncoder(buf).Encode(idx))
+		assert.Equal(t, expected, buf.Bytes(), tag)
+	}
+}
+
+func TestEncodeV4NameCompression(t *testing.T) {
+	idx := &Index{
+		Version: 4,
+		Entries: []*Entry{
+			{Name: "abc"},
+			{Name: "abd.txt"},
+			{Name: "abd/x"},
+			{Name: "b"},
+		},
+	}
+
+	buf := bytes.NewBuffer(nil)
+	require.NoError(t, NewEncoder(buf).Encode(idx))
+
+	// Each name is the number of bytes to remove from the previous one,
+	// followed by the suffix to append.
+	content := buf.String()
+	for _, name := range []string{"\x00abc\x00", "\x01d.txt\x00", "\x04/x\x00", "\x05b\x00"} {
+		assert.Contains(t, content, name)
+	}
+
+	output := &Index{}
+	require.NoError(t, NewDecoder(buf).Decode(output))
+	assert.EqualExportedValues(t, idx, output)
+}
+
+func TestEncodeWithVersion(t *testing.T) {
+	idx := &Index{}
+	require.NoError(t, NewDecoder(bytes.NewReader(readFixtureIndex(t, ".git"))).Decode(idx))
+	require.Equal(t, uint32(2), idx.Version)
+
+	buf := bytes.NewBuffer(nil)
+	require.NoError(t, NewEncoder(buf, WithVersion(4)).Encode(idx))
+	assert.Equal(t, uint32(2), idx.Version)
+
+	output := &Index{}
+	require.NoError(t, NewDecoder(buf).Decode(output))
+	assert.Equal(t, uint32(4), output.Version)
+	assert.Equal(t, idx.Entries, output.Entries)
+	assert.Equal(t, idx.Cache, output.Cache)
+
+	err := NewEncoder(buf, WithVersion(5)).Encode(idx)
+	assert.ErrorIs(t, err, ErrUnsupportedVersion)
+}
+
+func TestEncodeCacheTreeInvalidated(t *testing.T) {
+	idx := &Index{}
+	require.NoError(t, NewDecoder(bytes.NewReader(readFixtureIndex(t, ".git"))).Decode(idx))
+
+	e, err := idx.Entry("go/example.go")
+	require.NoError(t, err)
+	e.Hash = plumbing.NewHash("e25b29c8946e0e192fae2edc1dabf7be71e8ecf3")
+
+	buf := bytes.NewBuffer(nil)
+	require.NoError(t, NewEncoder(buf).Encode(idx))
+
+	output := &Index{}
+	require.NoError(t, NewDecoder(buf).Decode(output))
+
+	// The trees holding the entry changed are rebuilt, the other ones are
+	// written unchanged.
+	require.NotNil(t, output.Cache)
+	require.Len(t, output.Cache.Entries, 5)
+	assert.NotEqual(t, expectedEntries[0].Hash, output.Cache.Entries[0].Hash)
+	assert.NotEqual(t, expectedEntries[1].Hash, output.Cache.Entries[1].Hash)
+	assert.Equal(t, expectedEntries[2:], output.Cache.Entries[2:])
+
+	// Unmerged entries can't be written as a tree, so the trees holding them
+	// are invalidated, which the decoder skips.
+	idx.Entries = append(idx.Entries, &Entry{Name: "php/crappy.php", Stage: OurMode, Hash: e.Hash})
+	buf.Reset()
+	require.NoError(t, NewEncoder(buf).Encode(idx))
+
+	output = &Index{}
+	require.NoError(t, NewDecoder(buf).Decode(output))
+	require.NotNil(t, output.Cache)
+
+	var paths []string
+	for _, te := range output.Cache.Entries {
+		paths = append(paths, te.Path)
+	}
+	assert.Equal(t, []string{"go", "json", "vendor"}, paths)
+}
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"path/filepath"
+	"sort"
 	"strings"
 	"time"
 
@@ -18,6 +19,12 @@ var (
 	ErrUnsupportedVersion = errors.New("unsupported version")
 	// ErrEntryNotFound is returned by Index.Entry, if an entry is not found.
 	ErrEntryNotFound = errors.New("entry not found")
+	// ErrUnsortedEntries is returned by Index.Validate when the entries are
+	// not sorted by name and stage.
+	ErrUnsortedEntries = errors.New("unsorted entries")
+	// ErrDuplicateEntry is returned by Index.Validate when several entries
+	// have the same name and stage.
+	ErrDuplicateEntry = errors.New("duplicate entry")
 
 	indexSignature              = []byte{'D', 'I', 'R', 'C'}
 	treeExtSignature            = []byte{'T', 'R', 'E', 'E'}
@@ -30,7 +37,7 @@ type Stage int
 
 const (
 	// Merged is the default stage, fully merged
-	Merged Stage = 1
+	Merged Stage = 0
 	// AncestorMode is the base revision
 	AncestorMode Stage = 1
 	// OurMode is the first tree revision, ours
@@ -110,6 +117,45 @@ func (i *Index) Glob(pattern string) (matches []*Entry, err error) {
 	return
 }
 
+// Validate checks that the entries are sorted by name and stage, as git
+// writes them, and that no two entries have the same name and stage. A
+// *CorruptIndexError is returned describing the first problem found.
+//
+// The trailing checksum of the index file is not kept in the Index, it is
+// verified by the Decoder instead.
+func (i *Index) Validate() error {
+	for n := 1; n < len(i.Entries); n++ {
+		prev, e := i.Entries[n-1], i.Entries[n]
+		switch {
+		case prev.Name == e.Name && prev.Stage == e.Stage:
+			return &CorruptIndexError{Err: ErrDuplicateEntry, Name: e.Name, Stage: e.Stage}
+		case byName(i.Entries).Less(n, n-1):
+			return &CorruptIndexError{Err: ErrUnsortedEntries, Name: e.Name, Stage: e.Stage}
+		}
+	}
+
+	return nil
+}
+
+// Normalize sorts the entries by name and stage, and drops the entries that
+// are exact duplicates of another one. Entries with the same name and stage
+// that differ otherwise are all kept, so Validate still reports them.
+func (i *Index) Normalize() {
+	sort.Stable(byName(i.Entries))
+
+	entries := i.Entries[:0]
+	for _, e := range i.Entries {
+		if n := len(entries); n > 0 && entries[n-1].equal(e) {
+			continue
+		}
+
+		entries = append(entries, e)
+	}
+
+	clear(i.Entries[len(entries):])
+	i.Entries = entries
+}
+
 // String is equivalent to `git ls-files --stage --debug`
 func (i *Index) String() string {
 	buf := bytes.NewBuffer(nil)
@@ -120,6 +166,28 @@ func (i *Index) String() string {
 	return buf.String()
 }
 
+// CorruptIndexError is returned by Index.Validate, and by a Decoder created
+// WithValidation, when the index is corrupt. It wraps the problem found,
+// ErrInvalidChecksum, ErrUnsortedEntries or ErrDuplicateEntry.
+type CorruptIndexError struct {
+	Err error
+	// Name and Stage of the offending entry, if any.
+	Name  string
+	Stage Stage
+}
+
+func (e *CorruptIndexError) Error() string {
+	if e.Name == "" {
+		return fmt.Sprintf("corrupt index: %s", e.Err)
+	}
+
+	return fmt.Sprintf("corrupt index: %s: %q (stage %d)", e.Err, e.Name, e.Stage)
+}
+
+func (e *CorruptIndexError) Unwrap() error {
+	return e.Err
+}
+
 // Entry represents a single file (or stage of a file) in the cache. An entry
 // represents exactly one stage of a file. If a file path is unmerged then
 // multiple Entry instances may appear for the same path name.
@@ -151,6 +219,21 @@ type Entry struct {
 	IntentToAdd bool
 }
 
+// equal returns whether both entries are identical.
+func (e *Entry) equal(o *Entry) bool {
+	return e.Hash == o.Hash &&
+		e.Name == o.Name &&
+		e.CreatedAt.Equal(o.CreatedAt) &&
+		e.ModifiedAt.Equal(o.ModifiedAt) &&
+		e.Dev == o.Dev && e.Inode == o.Inode &&
+		e.Mode == o.Mode &&
+		e.UID == o.UID && e.GID == o.GID &&
+		e.Size == o.Size &&
+		e.Stage == o.Stage &&
+		e.SkipWorktree == o.SkipWorktree &&
+		e.IntentToAdd == o.IntentToAdd
+}
+
 func (e Entry) String() string {
 	buf := bytes.NewBuffer(nil)
 
@@ -72,3 +72,55 @@ func (s *IndexSuite) TestIndexGlob() {
 	s.NoError(err)
 	s.Len(m, 1)
 }
+
+func (s *IndexSuite) TestIndexValidate() {
+	idx := &Index{
+		Entries: []*Entry{
+			{Name: "bar"},
+			{Name: "foo", Stage: AncestorMode},
+			{Name: "foo", Stage: OurMode},
+			{Name: "foo/qux"},
+		},
+	}
+	s.NoError(idx.Validate())
+
+	idx.Entries[1], idx.Entries[2] = idx.Entries[2], idx.Entries[1]
+	err := idx.Validate()
+	s.ErrorIs(err, ErrUnsortedEntries)
+
+	var cerr *CorruptIndexError
+	s.ErrorAs(err, &cerr)
+	s.Equal("foo", cerr.Name)
+	s.Equal(AncestorMode, cerr.Stage)
+
+	idx.Entries[1] = &Entry{Name: "foo", Stage: AncestorMode}
+	err = idx.Validate()
+	s.ErrorIs(err, ErrDuplicateEntry)
+	s.EqualError(err, `corrupt index: duplicate entry: "foo" (stage 1)`)
+}
+
+func (s *IndexSuite) TestIndexNormalize() {
+	idx := &Index{
+		Entries: []*Entry{
+			{Name: "foo", Size: 42},
+			{Name: "bar", Stage: TheirMode},
+			{Name: "bar", Stage: OurMode},
+			{Name: "foo", Size: 42},
+			{Name: "qux", Size: 1},
+			{Name: "qux", Size: 2},
+		},
+	}
+
+	idx.Normalize()
+	s.Len(idx.Entries, 5)
+	s.Equal("bar", idx.Entries[0].Name)
+	s.Equal(OurMode, idx.Entries[0].Stage)
+	s.Equal(TheirMode, idx.Entries[1].Stage)
+	s.Equal("foo", idx.Entries[2].Name)
+
+	// Duplicates differing otherwise are kept.
+	s.ErrorIs(idx.Validate(), ErrDuplicateEntry)
+
+	idx.Entries = idx.Entries[:4]
+	s.NoError(idx.Validate())
+}
@@ -0,0 +1,62 @@
+package index
+
+import (
+	"crypto"
+
+	format "github.com/go-git/go-git/v6/plumbing/format/config"
+	"github.com/go-git/go-git/v6/plumbing/hash"
+)
+
+// Option configures an Encoder or a Decoder.
+type Option func(*options)
+
+type options struct {
+	objectFormat format.ObjectFormat
+	version      uint32
+	validate     bool
+}
+
+// WithObjectFormat sets the object format of the repository owning the
+// index, which defines the length of the object names and of the checksum.
+// By default SHA1 is used.
+func WithObjectFormat(f format.ObjectFormat) Option {
+	return func(o *options) {
+		o.objectFormat = f
+	}
+}
+
+// WithVersion forces the version of the index written by an Encoder, instead
+// of the Version of the Index encoded. It is ignored by the Decoder, which
+// always reads the version from the header.
+func WithVersion(v uint32) Option {
+	return func(o *options) {
+		o.version = v
+	}
+}
+
+// WithValidation makes the Decoder validate the decoded index, returning a
+// *CorruptIndexError if Index.Validate fails or the checksum is invalid. The
+// decoded entries are kept, so the index can be repaired with
+// Index.Normalize. It is ignored by the Encoder.
+func WithValidation() Option {
+	return func(o *options) {
+		o.validate = true
+	}
+}
+
+func newOptions(opts []Option) *options {
+	o := &options{objectFormat: format.SHA1}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	return o
+}
+
+func (o *options) newHash() hash.Hash {
+	if o.objectFormat == format.SHA256 {
+		return hash.New(crypto.SHA256)
+	}
+
+	return hash.New(crypto.SHA1)
+}
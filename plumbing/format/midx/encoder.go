@@ -0,0 +1,233 @@
+package midx
+
+import (
+	"crypto"
+	"errors"
+	"io"
+	"math"
+	"sort"
+
+	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/go-git/go-git/v6/plumbing/format/idxfile"
+	"github.com/go-git/go-git/v6/plumbing/hash"
+	"github.com/go-git/go-git/v6/utils/binary"
+)
+
+// Pack is a packfile to be indexed by a multi-pack-index.
+type Pack struct {
+	// Name is the name of the index file of the packfile, as
+	// "pack-<hash>.idx".
+	Name string
+	// Index is the index of the packfile.
+	Index idxfile.Index
+}
+
+// Encoder writes multi-pack-index files to an output stream.
+type Encoder struct {
+	io.Writer
+	hash hash.Hash
+}
+
+// NewEncoder returns a new stream encoder that writes to w.
+func NewEncoder(w io.Writer) *Encoder {
+	// TODO: Support passing an ObjectFormat (sha256)
+	h := hash.New(crypto.SHA1)
+	mw := io.MultiWriter(w, h)
+	return &Encoder{mw, h}
+}
+
+type object struct {
+	hash   plumbing.Hash
+	pack   uint32
+	offset uint64
+}
+
+// Encode writes a multi-pack-index of the given packfiles. As the packfiles
+// are sorted by name, an object found in several of them is indexed in the
+// first one by name.
+func (e *Encoder) Encode(packs []Pack) error {
+	packs = append([]Pack(nil), packs...)
+	sort.Slice(packs, func(i, j int) bool { return packs[i].Name < packs[j].Name })
+
+	objects, err := e.collect(packs)
+	if err != nil {
+		return err
+	}
+
+	var names []byte
+	for _, p := range packs {
+		names = append(names, p.Name...)
+		names = append(names, 0)
+	}
+	for len(names)%szUint32 != 0 {
+		names = append(names, 0)
+	}
+
+	var large int
+	for _, o := range objects {
+		if o.offset > math.MaxInt32 {
+			large++
+		}
+	}
+
+	ids := [][4]byte{chunkPackNames, chunkOIDFanout, chunkOIDLookup, chunkObjectOffset}
+	sizes := []int64{int64(len(names)), lenFanout * szUint32, int64(len(objects) * e.hash.Size()), int64(len(objects) * szOffset)}
+	if large > 0 {
+		ids = append(ids, chunkLargeOffset)
+		sizes = append(sizes, int64(large*szUint64))
+	}
+
+	if err := e.encodeHeader(len(ids), len(packs)); err != nil {
+		return err
+	}
+
+	if err := e.encodeChunkLookup(ids, sizes); err != nil {
+		return err
+	}
+
+	if _, err := e.Write(names); err != nil {
+		return err
+	}
+
+	if err := e.encodeFanout(objects); err != nil {
+		return err
+	}
+
+	for _, o := range objects {
+		if _, err := e.Write(o.hash.Bytes()); err != nil {
+			return err
+		}
+	}
+
+	if err := e.encodeOffsets(objects); err != nil {
+		return err
+	}
+
+	_, err = e.Write(e.hash.Sum(nil))
+	return err
+}
+
+func (e *Encoder) collect(packs []Pack) ([]object, error) {
+	// Hashes are compared by their bytes, as the same hash may come with a
+	// different format from different indexes.
+	seen := make(map[string]struct{})
+	var objects []object
+	for i, p := range packs {
+		iter, err := p.Index.Entries()
+		if err != nil {
+			return nil, err
+		}
+
+		for {
+			entry, err := iter.Next()
+			if errors.Is(err, io.EOF) {
+				break
+			}
+
+			if err != nil {
+				iter.Close()
+				return nil, err
+			}
+
+			key := string(entry.Hash.Bytes())
+			if _, ok := seen[key]; ok {
+				continue
+			}
+
+			seen[key] = struct{}{}
+			objects = append(objects, object{entry.Hash, uint32(i), entry.Offset})
+		}
+
+		if err := iter.Close(); err != nil {
+			return nil, err
+		}
+	}
+
+	sort.Slice(objects, func(i, j int) bool {
+		return objects[i].hash.Compare(objects[j].hash.Bytes()) < 0
+	})
+
+	return objects, nil
+}
+
+func (e *Encoder) encodeHeader(chunks, packs int) error {
+	if _, err := e.Write(signature); err != nil {
+		return err
+	}
+
+	hashVersion := byte(1)
+	if e.hash.Size() == crypto.SHA256.Size() {
+		hashVersion = 2
+	}
+
+	if _, err := e.Write([]byte{VersionSupported, hashVersion, byte(chunks), 0}); err != nil {
+		return err
+	}
+
+	return binary.WriteUint32(e, uint32(packs))
+}
+
+func (e *Encoder) encodeChunkLookup(ids [][4]byte, sizes []int64) error {
+	offset := int64(szHeader + (len(ids)+1)*szChunkEntry)
+	for i, id := range ids {
+		if _, err := e.Write(id[:]); err != nil {
+			return err
+		}
+
+		if err := binary.WriteUint64(e, uint64(offset)); err != nil {
+			return err
+		}
+
+		offset += sizes[i]
+	}
+
+	if _, err := e.Write(make([]byte, szUint32)); err != nil {
+		return err
+	}
+
+	return binary.WriteUint64(e, uint64(offset))
+}
+
+func (e *Encoder) encodeFanout(objects []object) error {
+	var fanout [lenFanout]uint32
+	for _, o := range objects {
+		fanout[o.hash.Bytes()[0]]++
+	}
+
+	var total uint32
+	for i := range fanout {
+		total += fanout[i]
+		if err := binary.WriteUint32(e, total); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (e *Encoder) encodeOffsets(objects []object) error {
+	var large []uint64
+	for _, o := range objects {
+		if err := binary.WriteUint32(e, o.pack); err != nil {
+			return err
+		}
+
+		offset := uint32(o.offset)
+		if o.offset > math.MaxInt32 {
+			offset = largeOffsetFlag | uint32(len(large))
+			large = append(large, o.offset)
+		}
+
+		if err := binary.WriteUint32(e, offset); err != nil {
+			return err
+		}
+	}
+
+	for _, offset := range large {
+		if err := binary.WriteUint64(e, offset); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,379 @@
+package midx
+
+import (
+	"bufio"
+	"bytes"
+	"crypto"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/go-git/go-git/v6/utils/binary"
+)
+
+var (
+	// ErrUnsupportedVersion is returned by Open when the multi-pack-index
+	// file version is not supported.
+	ErrUnsupportedVersion = errors.New("unsupported version")
+	// ErrUnsupportedHash is returned by Open when the multi-pack-index hash
+	// function is not supported.
+	ErrUnsupportedHash = errors.New("unsupported hash algorithm")
+	// ErrMalformedFile is returned by Open when the multi-pack-index file is
+	// corrupted.
+	ErrMalformedFile = errors.New("malformed multi-pack-index file")
+
+	signature = []byte{'M', 'I', 'D', 'X'}
+
+	chunkPackNames    = [4]byte{'P', 'N', 'A', 'M'}
+	chunkOIDFanout    = [4]byte{'O', 'I', 'D', 'F'}
+	chunkOIDLookup    = [4]byte{'O', 'I', 'D', 'L'}
+	chunkObjectOffset = [4]byte{'O', 'O', 'F', 'F'}
+	chunkLargeOffset  = [4]byte{'L', 'O', 'F', 'F'}
+)
+
+const (
+	// VersionSupported is the only multi-pack-index version supported.
+	VersionSupported = 1
+
+	szUint32     = 4
+	szUint64     = 8
+	szHeader     = 12
+	szChunkEntry = 12
+	szOffset     = 2 * szUint32
+
+	lenFanout = 256
+
+	largeOffsetFlag = uint32(1) << 31
+)
+
+// ReaderAtCloser is an interface that combines io.ReaderAt and io.Closer.
+type ReaderAtCloser interface {
+	io.ReaderAt
+	io.Closer
+}
+
+// MultiPackIndex is a multi-pack-index file, read on demand from its reader.
+type MultiPackIndex struct {
+	r        ReaderAtCloser
+	hashSize int
+	packs    []string
+	fanout   [lenFanout]uint32
+
+	oidLookup    int64
+	objectOffset int64
+	largeOffset  int64
+	largeCount   int64
+}
+
+// Open opens a multi-pack-index file in the format described at
+// https://git-scm.com/docs/gitformat-pack#_multi_pack_index_midx_files_have_the_following_format
+// The file is read on demand, so the reader must remain valid until Close.
+func Open(r ReaderAtCloser) (*MultiPackIndex, error) {
+	if r == nil {
+		return nil, io.ErrUnexpectedEOF
+	}
+
+	m := &MultiPackIndex{r: r}
+
+	chunks, numPacks, err := m.readHeader()
+	if err != nil {
+		return nil, err
+	}
+
+	offsets, err := m.readChunkLookup(chunks)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, id := range [][4]byte{chunkPackNames, chunkOIDFanout, chunkOIDLookup, chunkObjectOffset} {
+		if _, ok := offsets[id]; !ok {
+			return nil, fmt.Errorf("%w: missing %s chunk", ErrMalformedFile, id[:])
+		}
+	}
+
+	if err := m.readPackNames(offsets, numPacks); err != nil {
+		return nil, err
+	}
+
+	if err := m.readFanout(offsets[chunkOIDFanout]); err != nil {
+		return nil, err
+	}
+
+	m.oidLookup = offsets[chunkOIDLookup].start
+	m.objectOffset = offsets[chunkObjectOffset].start
+	if c, ok := offsets[chunkLargeOffset]; ok {
+		m.largeOffset = c.start
+		m.largeCount = (c.end - c.start) / szUint64
+	}
+
+	count := int64(m.Count())
+	if offsets[chunkOIDLookup].size() < count*int64(m.hashSize) ||
+		offsets[chunkObjectOffset].size() < count*szOffset {
+		return nil, fmt.Errorf("%w: truncated object chunks", ErrMalformedFile)
+	}
+
+	return m, nil
+}
+
+type chunk struct {
+	start, end int64
+}
+
+func (c chunk) size() int64 {
+	return c.end - c.start
+}
+
+func (m *MultiPackIndex) readHeader() (chunks int, packs uint32, err error) {
+	header := make([]byte, szHeader)
+	if _, err := m.r.ReadAt(header, 0); err != nil {
+		return 0, 0, fmt.Errorf("%w: %w", ErrMalformedFile, err)
+	}
+
+	if !bytes.Equal(header[:4], signature) {
+		return 0, 0, ErrMalformedFile
+	}
+
+	if header[4] != VersionSupported {
+		return 0, 0, ErrUnsupportedVersion
+	}
+
+	switch header[5] {
+	case 1:
+		m.hashSize = crypto.SHA1.Size()
+	case 2:
+		m.hashSize = crypto.SHA256.Size()
+	default:
+		return 0, 0, ErrUnsupportedHash
+	}
+
+	if header[7] != 0 {
+		// Incremental multi-pack-index chains aren't supported.
+		return 0, 0, ErrUnsupportedVersion
+	}
+
+	packs, err = binary.ReadUint32(bytes.NewReader(header[8:]))
+	return int(header[6]), packs, err
+}
+
+func (m *MultiPackIndex) readChunkLookup(chunks int) (map[[4]byte]chunk, error) {
+	table := make([]byte, (chunks+1)*szChunkEntry)
+	if _, err := m.r.ReadAt(table, szHeader); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrMalformedFile, err)
+	}
+
+	type entry struct {
+		id     [4]byte
+		offset int64
+	}
+
+	entries := make([]entry, chunks+1)
+	for i := range entries {
+		e := table[i*szChunkEntry:]
+		copy(entries[i].id[:], e[:4])
+
+		offset, err := binary.ReadUint64(bytes.NewReader(e[4:szChunkEntry]))
+		if err != nil {
+			return nil, err
+		}
+
+		entries[i].offset = int64(offset)
+	}
+
+	if entries[chunks].id != [4]byte{} {
+		return nil, fmt.Errorf("%w: missing chunk table terminator", ErrMalformedFile)
+	}
+
+	offsets := make(map[[4]byte]chunk, chunks)
+	for i := 0; i < chunks; i++ {
+		start, end := entries[i].offset, entries[i+1].offset
+		if start < 0 || end < start {
+			return nil, fmt.Errorf("%w: invalid chunk offsets", ErrMalformedFile)
+		}
+
+		offsets[entries[i].id] = chunk{start, end}
+	}
+
+	// The checksum follows the chunks, so a truncated file is detected
+	// without reading it whole.
+	trailer := make([]byte, m.hashSize)
+	if _, err := m.r.ReadAt(trailer, entries[chunks].offset); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrMalformedFile, err)
+	}
+
+	return offsets, nil
+}
+
+func (m *MultiPackIndex) readPackNames(offsets map[[4]byte]chunk, numPacks uint32) error {
+	c := offsets[chunkPackNames]
+	data := make([]byte, c.size())
+	if _, err := m.r.ReadAt(data, c.start); err != nil {
+		return fmt.Errorf("%w: %w", ErrMalformedFile, err)
+	}
+
+	m.packs = make([]string, 0, numPacks)
+	for len(m.packs) < int(numPacks) {
+		i := bytes.IndexByte(data, 0)
+		if i <= 0 {
+			return fmt.Errorf("%w: invalid packfile names", ErrMalformedFile)
+		}
+
+		m.packs = append(m.packs, string(data[:i]))
+		data = data[i+1:]
+	}
+
+	if !sort.StringsAreSorted(m.packs) {
+		return fmt.Errorf("%w: packfile names out of order", ErrMalformedFile)
+	}
+
+	return nil
+}
+
+func (m *MultiPackIndex) readFanout(c chunk) error {
+	r := io.NewSectionReader(m.r, c.start, lenFanout*szUint32)
+	var prev uint32
+	for i := 0; i < lenFanout; i++ {
+		v, err := binary.ReadUint32(r)
+		if err != nil {
+			return fmt.Errorf("%w: %w", ErrMalformedFile, err)
+		}
+
+		if v < prev {
+			return fmt.Errorf("%w: fanout out of order", ErrMalformedFile)
+		}
+
+		m.fanout[i], prev = v, v
+	}
+
+	return nil
+}
+
+// Close closes the underlying reader.
+func (m *MultiPackIndex) Close() error {
+	return m.r.Close()
+}
+
+// HashSize returns the size of the object ids indexed.
+func (m *MultiPackIndex) HashSize() int {
+	return m.hashSize
+}
+
+// Count returns the number of objects indexed.
+func (m *MultiPackIndex) Count() int {
+	return int(m.fanout[lenFanout-1])
+}
+
+// PackNames returns the names of the index files of the packfiles indexed,
+// e.g. "pack-<hash>.idx", in the order of their pack-int-id.
+func (m *MultiPackIndex) PackNames() []string {
+	return m.packs
+}
+
+// FindOffset returns the pack-int-id of the packfile containing the object
+// with the given hash, its position in PackNames, and the offset of the
+// object in it. plumbing.ErrObjectNotFound is returned if the object isn't
+// indexed.
+func (m *MultiPackIndex) FindOffset(h plumbing.Hash) (pack int, offset int64, err error) {
+	i, err := m.find(h)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return m.objectAt(i)
+}
+
+// ForEachHash calls fn with the hash of every object indexed, in order. The
+// iteration stops at the first error returned by fn.
+func (m *MultiPackIndex) ForEachHash(fn func(plumbing.Hash) error) error {
+	count := m.Count()
+	r := bufio.NewReader(io.NewSectionReader(m.r, m.oidLookup, int64(count)*int64(m.hashSize)))
+	oid := make([]byte, m.hashSize)
+	for i := 0; i < count; i++ {
+		if _, err := io.ReadFull(r, oid); err != nil {
+			return err
+		}
+
+		h, _ := plumbing.FromBytes(oid)
+		if err := fn(h); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Contains checks whether the given hash is indexed.
+func (m *MultiPackIndex) Contains(h plumbing.Hash) (bool, error) {
+	_, err := m.find(h)
+	if errors.Is(err, plumbing.ErrObjectNotFound) {
+		return false, nil
+	}
+
+	return err == nil, err
+}
+
+// find returns the position of the hash in the OID lookup chunk.
+func (m *MultiPackIndex) find(h plumbing.Hash) (int64, error) {
+	if h.Size() != m.hashSize {
+		return 0, plumbing.ErrObjectNotFound
+	}
+
+	want := h.Bytes()
+	var low int64
+	if want[0] > 0 {
+		low = int64(m.fanout[want[0]-1])
+	}
+	high := int64(m.fanout[want[0]])
+
+	oid := make([]byte, m.hashSize)
+	for low < high {
+		mid := (low + high) / 2
+		if _, err := m.r.ReadAt(oid, m.oidLookup+mid*int64(m.hashSize)); err != nil {
+			return 0, err
+		}
+
+		switch cmp := bytes.Compare(want, oid); {
+		case cmp == 0:
+			return mid, nil
+		case cmp < 0:
+			high = mid
+		default:
+			low = mid + 1
+		}
+	}
+
+	return 0, plumbing.ErrObjectNotFound
+}
+
+// objectAt returns the pack-int-id and offset of the object at the given
+// position.
+func (m *MultiPackIndex) objectAt(i int64) (int, int64, error) {
+	buf := make([]byte, szOffset)
+	if _, err := m.r.ReadAt(buf, m.objectOffset+i*szOffset); err != nil {
+		return 0, 0, err
+	}
+
+	r := bytes.NewReader(buf)
+	pack, _ := binary.ReadUint32(r)
+	offset, _ := binary.ReadUint32(r)
+	if int(pack) >= len(m.packs) {
+		return 0, 0, fmt.Errorf("%w: invalid pack-int-id %d", ErrMalformedFile, pack)
+	}
+
+	if offset&largeOffsetFlag == 0 || m.largeOffset == 0 {
+		return int(pack), int64(offset), nil
+	}
+
+	row := int64(offset &^ largeOffsetFlag)
+	if row >= m.largeCount {
+		return 0, 0, fmt.Errorf("%w: invalid large offset %d", ErrMalformedFile, row)
+	}
+
+	large, err := binary.ReadUint64(io.NewSectionReader(m.r, m.largeOffset+row*szUint64, szUint64))
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return int(pack), int64(large), nil
+}
@@ -0,0 +1,196 @@
+package midx_test
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"testing"
+
+	fixtures "github.com/go-git/go-git-fixtures/v5"
+	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/go-git/go-git/v6/plumbing/format/idxfile"
+	"github.com/go-git/go-git/v6/plumbing/format/midx"
+	"github.com/stretchr/testify/suite"
+)
+
+type MidxSuite struct {
+	suite.Suite
+}
+
+func TestMidxSuite(t *testing.T) {
+	suite.Run(t, new(MidxSuite))
+}
+
+type bytesReaderAt struct {
+	*bytes.Reader
+}
+
+func (bytesReaderAt) Close() error { return nil }
+
+func (s *MidxSuite) open(data []byte) (*midx.MultiPackIndex, error) {
+	return midx.Open(bytesReaderAt{bytes.NewReader(data)})
+}
+
+func (s *MidxSuite) encode(packs []midx.Pack) []byte {
+	buf := bytes.NewBuffer(nil)
+	s.Require().NoError(midx.NewEncoder(buf).Encode(packs))
+	return buf.Bytes()
+}
+
+func (s *MidxSuite) fixturePacks() []midx.Pack {
+	var packs []midx.Pack
+	seen := make(map[string]bool)
+	for _, f := range fixtures.ByTag("packfile") {
+		if seen[f.PackfileHash] || len(f.PackfileHash) != 40 {
+			continue
+		}
+		seen[f.PackfileHash] = true
+
+		idx := idxfile.NewMemoryIndex(plumbing.NewHash(f.PackfileHash).Size())
+		s.Require().NoError(idxfile.NewDecoder(f.Idx()).Decode(idx))
+
+		packs = append(packs, midx.Pack{
+			Name:  fmt.Sprintf("pack-%s.idx", f.PackfileHash),
+			Index: idx,
+		})
+
+		if len(packs) == 3 {
+			break
+		}
+	}
+
+	s.Require().Len(packs, 3)
+	return packs
+}
+
+func (s *MidxSuite) TestEncodeAndOpen() {
+	packs := s.fixturePacks()
+	m, err := s.open(s.encode(packs))
+	s.Require().NoError(err)
+	defer m.Close()
+
+	s.Equal(20, m.HashSize())
+	s.Len(m.PackNames(), 3)
+	s.IsIncreasing(m.PackNames())
+
+	names := m.PackNames()
+	total := make(map[string]struct{})
+	for _, p := range packs {
+		iter, err := p.Index.Entries()
+		s.Require().NoError(err)
+
+		for {
+			e, err := iter.Next()
+			if err == io.EOF {
+				break
+			}
+			s.Require().NoError(err)
+			total[e.Hash.String()] = struct{}{}
+
+			pack, offset, err := m.FindOffset(e.Hash)
+			s.Require().NoError(err)
+
+			// Objects in several packs are indexed in the first one by name.
+			if names[pack] == p.Name {
+				s.Equal(int64(e.Offset), offset)
+			} else {
+				s.Less(names[pack], p.Name)
+			}
+
+			ok, err := m.Contains(e.Hash)
+			s.NoError(err)
+			s.True(ok)
+		}
+		iter.Close()
+	}
+
+	s.Equal(len(total), m.Count())
+
+	_, _, err = m.FindOffset(plumbing.NewHash("0000000000000000000000000000000000000001"))
+	s.ErrorIs(err, plumbing.ErrObjectNotFound)
+
+	ok, err := m.Contains(plumbing.NewHash("ffffffffffffffffffffffffffffffffffffffff"))
+	s.NoError(err)
+	s.False(ok)
+}
+
+func (s *MidxSuite) TestForEachHash() {
+	packs := s.fixturePacks()
+	m, err := s.open(s.encode(packs))
+	s.Require().NoError(err)
+	defer m.Close()
+
+	var hashes []plumbing.Hash
+	s.NoError(m.ForEachHash(func(h plumbing.Hash) error {
+		hashes = append(hashes, h)
+		return nil
+	}))
+
+	s.Len(hashes, m.Count())
+	for i, h := range hashes {
+		ok, err := m.Contains(h)
+		s.NoError(err)
+		s.True(ok)
+
+		if i > 0 {
+			s.Negative(hashes[i-1].Compare(h.Bytes()))
+		}
+	}
+
+	var calls int
+	err = m.ForEachHash(func(plumbing.Hash) error {
+		calls++
+		return io.ErrClosedPipe
+	})
+	s.ErrorIs(err, io.ErrClosedPipe)
+	s.Equal(1, calls)
+}
+
+func (s *MidxSuite) TestLargeOffsets() {
+	small := plumbing.NewHash("1111111111111111111111111111111111111111")
+	large := plumbing.NewHash("2222222222222222222222222222222222222222")
+	larger := plumbing.NewHash("3333333333333333333333333333333333333333")
+
+	w := new(idxfile.Writer)
+	w.Add(small, 12, 0)
+	w.Add(large, 1<<32, 0)
+	w.Add(larger, 1<<33+5, 0)
+	s.Require().NoError(w.OnFooter(plumbing.NewHash("4444444444444444444444444444444444444444")))
+	idx, err := w.Index()
+	s.Require().NoError(err)
+
+	m, err := s.open(s.encode([]midx.Pack{{Name: "pack-4444444444444444444444444444444444444444.idx", Index: idx}}))
+	s.Require().NoError(err)
+
+	for h, expected := range map[plumbing.Hash]int64{small: 12, large: 1 << 32, larger: 1<<33 + 5} {
+		pack, offset, err := m.FindOffset(h)
+		s.Require().NoError(err)
+		s.Equal(0, pack)
+		s.Equal(expected, offset)
+	}
+}
+
+func (s *MidxSuite) TestOpenMalformed() {
+	data := s.encode(s.fixturePacks())
+
+	_, err := s.open(data[:8])
+	s.ErrorIs(err, midx.ErrMalformedFile)
+
+	bad := bytes.Clone(data)
+	bad[0] = 'X'
+	_, err = s.open(bad)
+	s.ErrorIs(err, midx.ErrMalformedFile)
+
+	bad = bytes.Clone(data)
+	bad[4] = 2
+	_, err = s.open(bad)
+	s.ErrorIs(err, midx.ErrUnsupportedVersion)
+
+	bad = bytes.Clone(data)
+	bad[5] = 3
+	_, err = s.open(bad)
+	s.ErrorIs(err, midx.ErrUnsupportedHash)
+
+	_, err = s.open(data[:len(data)/2])
+	s.ErrorIs(err, midx.ErrMalformedFile)
+}
@@ -19,8 +19,11 @@ var ErrOverflow = errors.New("objfile: declared data length exceeded (overflow)"
 type Writer struct {
 	raw    io.Writer
 	hasher plumbing.Hasher
+	format format.ObjectFormat
 	multi  io.Writer
 	zlib   *zlib.Writer
+	level  int
+	err    error
 
 	closed  bool
 	pending int64 // number of unwritten bytes
@@ -28,22 +31,53 @@ type Writer struct {
 	closeErr error
 }
 
+// WriterOption configures a Writer.
+type WriterOption func(*Writer)
+
+// WithObjectFormat sets the object format used to compute the hash of the
+// written object. By default SHA1 is used.
+func WithObjectFormat(f format.ObjectFormat) WriterOption {
+	return func(w *Writer) {
+		w.format = f
+	}
+}
+
+// WithCompressionLevel sets the zlib compression level of the written object,
+// from zlib.DefaultCompression (-1) to zlib.BestCompression (9). By default
+// zlib.BestSpeed is used, as git does for loose objects. An invalid level is
+// reported by WriteHeader.
+func WithCompressionLevel(level int) WriterOption {
+	return func(w *Writer) {
+		w.level = level
+	}
+}
+
 // NewWriter returns a new Writer writing to w.
 //
 // The returned Writer implements io.WriteCloser. Close should be called when
 // finished with the Writer. Close will not close the underlying io.Writer.
-func NewWriter(w io.Writer) *Writer {
-	zlib := sync.GetZlibWriter(w)
-	return &Writer{
-		raw:  w,
-		zlib: zlib,
+func NewWriter(w io.Writer, opts ...WriterOption) *Writer {
+	ow := &Writer{
+		raw:   w,
+		level: zlib.BestSpeed,
 	}
+
+	for _, opt := range opts {
+		opt(ow)
+	}
+
+	ow.zlib, ow.err = sync.GetZlibWriterLevel(w, ow.level)
+	return ow
 }
 
 // WriteHeader writes the type and the size and prepares to accept the object's
 // contents. If an invalid t is provided, plumbing.ErrInvalidType is returned. If a
 // negative size is provided, ErrNegativeSize is returned.
 func (w *Writer) WriteHeader(t plumbing.ObjectType, size int64) error {
+	if w.err != nil {
+		return w.err
+	}
+
 	if !t.Valid() {
 		return plumbing.ErrInvalidType
 	}
@@ -65,7 +99,7 @@ func (w *Writer) WriteHeader(t plumbing.ObjectType, size int64) error {
 func (w *Writer) prepareForWrite(t plumbing.ObjectType, size int64) {
 	w.pending = size
 
-	w.hasher = plumbing.NewHasher(format.SHA1, t, size)
+	w.hasher = plumbing.NewHasher(w.format, t, size)
 	w.multi = io.MultiWriter(w.zlib, w.hasher)
 }
 
@@ -110,7 +144,12 @@ func (w *Writer) Close() error {
 		return w.closeErr
 	}
 
-	defer sync.PutZlibWriter(w.zlib)
+	if w.err != nil {
+		w.closed, w.closeErr = true, w.err
+		return w.err
+	}
+
+	defer sync.PutZlibWriterLevel(w.zlib, w.level)
 	if err := w.zlib.Close(); err != nil {
 		w.closeErr = err
 		return err
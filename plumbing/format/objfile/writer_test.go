@@ -2,6 +2,7 @@ package objfile
 
 import (
 	"bytes"
+	"compress/zlib"
 	"encoding/base64"
 	"fmt"
 	"io"
@@ -36,9 +37,33 @@ func (s *SuiteWriter) TestWriteObjfile() {
 	}
 }
 
-func testWriter(t *testing.T, dest io.Writer, hash plumbing.Hash, o plumbing.ObjectType, content []byte) {
+func (s *SuiteWriter) TestWriteObjfileCompressionLevel() {
+	for level := zlib.DefaultCompression; level <= zlib.BestCompression; level++ {
+		for k, fixture := range objfileFixtures {
+			buffer := bytes.NewBuffer(nil)
+
+			com := fmt.Sprintf("level %d, test %d: ", level, k)
+			hash := plumbing.NewHash(fixture.hash)
+			content, _ := base64.StdEncoding.DecodeString(fixture.content)
+
+			testWriter(s.T(), buffer, hash, fixture.t, content, WithCompressionLevel(level))
+			testReader(s.T(), buffer, hash, fixture.t, content, com)
+		}
+	}
+}
+
+func (s *SuiteWriter) TestWriteInvalidCompressionLevel() {
+	buf := bytes.NewBuffer(nil)
+	w := NewWriter(buf, WithCompressionLevel(10))
+
+	err := w.WriteHeader(plumbing.BlobObject, 8)
+	s.Error(err)
+	s.Equal(err, w.Close())
+}
+
+func testWriter(t *testing.T, dest io.Writer, hash plumbing.Hash, o plumbing.ObjectType, content []byte, opts ...WriterOption) {
 	size := int64(len(content))
-	w := NewWriter(dest)
+	w := NewWriter(dest, opts...)
 
 	err := w.WriteHeader(o, size)
 	assert.NoError(t, err)
@@ -25,8 +25,10 @@ const (
 )
 
 // UpdateObjectStorage updates the storer with the objects in the given
-// packfile.
-func UpdateObjectStorage(s storer.Storer, packfile io.Reader) error {
+// packfile. The options are given to the parser decoding the packfile, such
+// as WithDeltaBaseCache, unless the storer is a storer.PackfileWriter, as
+// the packfile is then written as it is.
+func UpdateObjectStorage(s storer.Storer, packfile io.Reader, opts ...ParserOption) error {
 	start := time.Now()
 	defer func() {
 		trace.Performance.Printf("performance: %.9f s: update_obj_storage", time.Since(start).Seconds())
@@ -36,7 +38,7 @@ func UpdateObjectStorage(s storer.Storer, packfile io.Reader) error {
 		return WritePackfileToObjectStorage(pw, packfile)
 	}
 
-	p := NewParser(packfile, WithStorage(s))
+	p := NewParser(packfile, append([]ParserOption{WithStorage(s)}, opts...)...)
 
 	_, err := p.Parse()
 	return err
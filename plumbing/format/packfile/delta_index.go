@@ -1,5 +1,7 @@
 package packfile
 
+import "math/bits"
+
 const blksz = 16
 const maxChainLength = 64
 
@@ -11,6 +13,11 @@ type deltaIndex struct {
 	mask    int
 }
 
+// memory returns the approximate number of bytes used by the index.
+func (idx *deltaIndex) memory() uint64 {
+	return uint64(len(idx.table)+len(idx.entries)) * bits.UintSize / 8
+}
+
 func (idx *deltaIndex) init(buf []byte) {
 	scanner := newDeltaIndexScanner(buf, len(buf))
 	idx.mask = scanner.mask
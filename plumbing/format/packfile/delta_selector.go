@@ -1,6 +1,7 @@
 package packfile
 
 import (
+	"errors"
 	"sort"
 	"sync"
 
@@ -9,9 +10,14 @@ import (
 )
 
 const (
+	// DefaultMaxDeltaDepth is the default maximum length of a delta chain,
 	// deltas based on deltas, how many steps we can do.
-	// 50 is the default value used in JGit
-	maxDepth = int64(50)
+	// 50 is the default value used in git and JGit.
+	DefaultMaxDeltaDepth = 50
+
+	// DefaultWindowMemory is the default maximum amount of memory, in bytes,
+	// used by the objects in the sliding window of the delta compression.
+	DefaultWindowMemory = 256 << 20
 )
 
 // applyDelta is the set of object types that we should apply deltas
@@ -22,10 +28,22 @@ var applyDelta = map[plumbing.ObjectType]bool{
 
 type deltaSelector struct {
 	storer storer.EncodedObjectStorer
+	// maxDepth is the maximum length of the delta chains. No object is
+	// based on another object whose chain already reaches it.
+	maxDepth int
+	// windowMemory is the maximum amount of memory used by the objects in
+	// the window, 0 meaning no limit.
+	windowMemory uint64
+	// bases are the objects used as delta bases without being packed.
+	bases []plumbing.Hash
 }
 
 func newDeltaSelector(s storer.EncodedObjectStorer) *deltaSelector {
-	return &deltaSelector{s}
+	return &deltaSelector{
+		storer:       s,
+		maxDepth:     DefaultMaxDeltaDepth,
+		windowMemory: DefaultWindowMemory,
+	}
 }
 
 // ObjectsToPack creates a list of ObjectToPack from the hashes
@@ -37,7 +55,7 @@ func (dw *deltaSelector) ObjectsToPack(
 	hashes []plumbing.Hash,
 	packWindow uint,
 ) ([]*ObjectToPack, error) {
-	otp, err := dw.objectsToPack(hashes, packWindow)
+	otp, external, err := dw.objectsToPack(hashes, packWindow)
 	if err != nil {
 		return nil, err
 	}
@@ -46,12 +64,13 @@ func (dw *deltaSelector) ObjectsToPack(
 		return otp, nil
 	}
 
-	dw.sort(otp)
+	all := append(otp, external...)
+	dw.sort(all)
 
 	var objectGroups [][]*ObjectToPack
 	var prev *ObjectToPack
 	i := -1
-	for _, obj := range otp {
+	for _, obj := range all {
 		if prev == nil || prev.Type() != obj.Type() {
 			objectGroups = append(objectGroups, []*ObjectToPack{obj})
 			i++
@@ -81,13 +100,22 @@ func (dw *deltaSelector) ObjectsToPack(
 		return nil, err
 	}
 
+	otp = all[:0]
+	for _, obj := range all {
+		if !obj.external {
+			otp = append(otp, obj)
+		}
+	}
+
 	return otp, nil
 }
 
+// objectsToPack returns the objects to pack for the given hashes, along with
+// the external objects used as delta bases when packWindow isn't 0.
 func (dw *deltaSelector) objectsToPack(
 	hashes []plumbing.Hash,
 	packWindow uint,
-) ([]*ObjectToPack, error) {
+) ([]*ObjectToPack, []*ObjectToPack, error) {
 	var objectsToPack []*ObjectToPack
 	for _, h := range hashes {
 		var o plumbing.EncodedObject
@@ -98,7 +126,7 @@ func (dw *deltaSelector) objectsToPack(
 			o, err = dw.encodedDeltaObject(h)
 		}
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 
 		otp := newObjectToPack(o)
@@ -110,14 +138,55 @@ func (dw *deltaSelector) objectsToPack(
 	}
 
 	if packWindow == 0 {
-		return objectsToPack, nil
+		return objectsToPack, nil, nil
 	}
 
-	if err := dw.fixAndBreakChains(objectsToPack); err != nil {
-		return nil, err
+	external, err := dw.externalBases(hashes)
+	if err != nil {
+		return nil, nil, err
 	}
 
-	return objectsToPack, nil
+	if err := dw.fixAndBreakChains(objectsToPack, external); err != nil {
+		return nil, nil, err
+	}
+
+	return objectsToPack, external, nil
+}
+
+// externalBases returns the bases of the selector not included in hashes,
+// ignoring the ones not found in the storer.
+func (dw *deltaSelector) externalBases(hashes []plumbing.Hash) ([]*ObjectToPack, error) {
+	if len(dw.bases) == 0 {
+		return nil, nil
+	}
+
+	packed := make(map[plumbing.Hash]struct{}, len(hashes))
+	for _, h := range hashes {
+		packed[h] = struct{}{}
+	}
+
+	var external []*ObjectToPack
+	for _, h := range dw.bases {
+		if _, ok := packed[h]; ok {
+			continue
+		}
+
+		o, err := dw.encodedObject(h)
+		if errors.Is(err, plumbing.ErrObjectNotFound) {
+			continue
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		otp := newObjectToPack(o)
+		otp.external = true
+		packed[h] = struct{}{}
+		external = append(external, otp)
+	}
+
+	return external, nil
 }
 
 func (dw *deltaSelector) encodedDeltaObject(h plumbing.Hash) (plumbing.EncodedObject, error) {
@@ -133,12 +202,18 @@ func (dw *deltaSelector) encodedObject(h plumbing.Hash) (plumbing.EncodedObject,
 	return dw.storer.EncodedObject(plumbing.AnyObject, h)
 }
 
-func (dw *deltaSelector) fixAndBreakChains(objectsToPack []*ObjectToPack) error {
-	m := make(map[plumbing.Hash]*ObjectToPack, len(objectsToPack))
+func (dw *deltaSelector) fixAndBreakChains(objectsToPack, external []*ObjectToPack) error {
+	m := make(map[plumbing.Hash]*ObjectToPack, len(objectsToPack)+len(external))
 	for _, otp := range objectsToPack {
 		m[otp.Hash()] = otp
 	}
 
+	// Reused deltas based on external objects are kept, as they are
+	// available at the receiver.
+	for _, otp := range external {
+		m[otp.Hash()] = otp
+	}
+
 	for _, otp := range objectsToPack {
 		if err := dw.fixAndBreakChainsOne(m, otp); err != nil {
 			return err
@@ -178,6 +253,11 @@ func (dw *deltaSelector) fixAndBreakChainsOne(objectsToPack map[plumbing.Hash]*O
 		return err
 	}
 
+	// Reusing the delta would make the chain longer than allowed.
+	if base.Depth >= dw.maxDepth {
+		return dw.undeltify(otp)
+	}
+
 	otp.SetDelta(base, otp.Object)
 	return nil
 }
@@ -222,53 +302,97 @@ func (dw *deltaSelector) walk(
 	packWindow uint,
 ) error {
 	indexMap := make(map[plumbing.Hash]*deltaIndex)
-	for i := 0; i < len(objectsToPack); i++ {
-		// Clean up the index map and reconstructed delta objects for anything
-		// outside our pack window, to save memory.
-		if i > int(packWindow) {
-			obj := objectsToPack[i-int(packWindow)]
-
-			delete(indexMap, obj.Hash())
-
-			if obj.IsDelta() {
-				obj.SaveOriginalMetadata()
-				obj.CleanOriginal()
-			}
-		}
-
-		target := objectsToPack[i]
+	w := &deltaWindow{
+		size:    int(packWindow) - 1,
+		memory:  dw.windowMemory,
+		indexes: indexMap,
+	}
 
+	for _, target := range objectsToPack {
 		// If we already have a delta, we don't try to find a new one for this
 		// object. This happens when a delta is set to be reused from an existing
 		// packfile.
-		if target.IsDelta() {
-			continue
+		//
+		// We only want to create deltas from specific types, and the external
+		// objects are only used as bases.
+		if !target.external && !target.IsDelta() && applyDelta[target.Type()] {
+			for j := len(w.objects) - 1; j >= 0; j-- {
+				base := w.objects[j]
+				// Objects must use only the same type as their delta base.
+				// Since objectsToPack is sorted by type and size, once we find
+				// a different type, we know we won't find more of them.
+				if base.Type() != target.Type() {
+					break
+				}
+
+				if err := dw.tryToDeltify(indexMap, base, target); err != nil {
+					return err
+				}
+			}
 		}
 
-		// We only want to create deltas from specific types.
-		if !applyDelta[target.Type()] {
-			continue
-		}
+		w.push(target)
+	}
 
-		for j := i - 1; j >= 0 && i-j < int(packWindow); j-- {
-			base := objectsToPack[j]
-			// Objects must use only the same type as their delta base.
-			// Since objectsToPack is sorted by type and size, once we find
-			// a different type, we know we won't find more of them.
-			if base.Type() != target.Type() {
-				break
-			}
+	return nil
+}
 
-			if err := dw.tryToDeltify(indexMap, base, target); err != nil {
-				return err
-			}
+// deltaWindow holds the objects used as bases to deltify the following ones,
+// the most recent last. Objects are evicted from the window, releasing their
+// delta indexes and reconstructed originals, when it has more objects than
+// its size or they use more memory than allowed.
+type deltaWindow struct {
+	objects []*ObjectToPack
+	size    int
+	// memory is the maximum amount of memory used by the objects of the
+	// window, 0 meaning no limit.
+	memory  uint64
+	indexes map[plumbing.Hash]*deltaIndex
+}
+
+// push adds the given object to the window, evicting the oldest ones until
+// the size and memory limits are met again.
+func (w *deltaWindow) push(o *ObjectToPack) {
+	w.objects = append(w.objects, o)
+	for len(w.objects) > 0 &&
+		(len(w.objects) > w.size || (w.memory > 0 && w.usage() > w.memory)) {
+		w.evict()
+	}
+}
+
+func (w *deltaWindow) evict() {
+	obj := w.objects[0]
+	w.objects[0] = nil
+	w.objects = w.objects[1:]
+
+	delete(w.indexes, obj.Hash())
+	if obj.IsDelta() {
+		obj.SaveOriginalMetadata()
+		obj.CleanOriginal()
+	}
+}
+
+// usage returns an estimation of the memory used by the objects of the
+// window, their uncompressed size plus the size of their delta indexes.
+func (w *deltaWindow) usage() uint64 {
+	var n uint64
+	for _, o := range w.objects {
+		n += uint64(o.Size())
+		if idx, ok := w.indexes[o.Hash()]; ok {
+			n += idx.memory()
 		}
 	}
 
-	return nil
+	return n
 }
 
 func (dw *deltaSelector) tryToDeltify(indexMap map[plumbing.Hash]*deltaIndex, base, target *ObjectToPack) error {
+	// The chain of the base already reaches the limit, a delta on top of it
+	// would be too deep.
+	if base.Depth >= dw.maxDepth {
+		return nil
+	}
+
 	// Original object might not be present if we're reusing a delta, so we
 	// ensure it is restored.
 	if err := dw.restoreOriginal(target); err != nil {
@@ -321,6 +445,11 @@ func (dw *deltaSelector) tryToDeltify(indexMap map[plumbing.Hash]*deltaIndex, ba
 
 func (dw *deltaSelector) deltaSizeLimit(targetSize int64, baseDepth int,
 	targetDepth int, targetDelta bool) int64 {
+	maxDepth := int64(dw.maxDepth)
+	if maxDepth <= 0 {
+		return 0
+	}
+
 	if !targetDelta {
 		// Any delta should be no more than 50% of the original size
 		// (for text files deflate of whole form should shrink 50%).
@@ -365,5 +494,11 @@ func (a byTypeAndSize) Less(i, j int) bool {
 		return true
 	}
 
+	// The external objects are only bases, they go first to be in the
+	// window of the objects of their type.
+	if a[i].external != a[j].external {
+		return a[i].external
+	}
+
 	return a[i].Size() > a[j].Size()
 }
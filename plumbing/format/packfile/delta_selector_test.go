@@ -1,6 +1,7 @@
 package packfile
 
 import (
+	"fmt"
 	"testing"
 
 	"github.com/go-git/go-git/v6/plumbing"
@@ -213,7 +214,7 @@ func (s *DeltaSelectorSuite) TestObjectsToPack() {
 
 	// Don't sort so we can easily check the sliding window without
 	// creating a bunch of new objects.
-	otp, err = s.ds.objectsToPack(hashes, deltaWindowSize)
+	otp, _, err = s.ds.objectsToPack(hashes, deltaWindowSize)
 	s.NoError(err)
 	err = s.ds.walk(otp, deltaWindowSize)
 	s.NoError(err)
@@ -234,7 +235,30 @@ func (s *DeltaSelectorSuite) TestObjectsToPack() {
 	s.Equal(0, otp[1].Depth)
 }
 
+func (s *DeltaSelectorSuite) TestWindowMemory() {
+	indexes := make(map[plumbing.Hash]*deltaIndex)
+	w := &deltaWindow{size: 10, memory: 4000, indexes: indexes}
+
+	for i := 0; i < 20; i++ {
+		content := genBytes([]piece{{times: 100 * (i%5 + 1), val: fmt.Sprint(i % 10)}})
+		o := newObjectToPack(newObject(plumbing.BlobObject, content))
+
+		idx := new(deltaIndex)
+		idx.init(content)
+		indexes[o.Hash()] = idx
+
+		w.push(o)
+		s.LessOrEqual(w.usage(), w.memory)
+		s.LessOrEqual(len(w.objects), w.size)
+		s.Len(indexes, len(w.objects))
+	}
+
+	// An object larger than the limit doesn't stay in the window.
+	w.push(newObjectToPack(newObject(plumbing.BlobObject, make([]byte, 5000))))
+	s.Empty(w.objects)
+}
+
 func (s *DeltaSelectorSuite) TestMaxDepth() {
-	dsl := s.ds.deltaSizeLimit(0, 0, int(maxDepth), true)
+	dsl := s.ds.deltaSizeLimit(0, 0, DefaultMaxDeltaDepth, true)
 	s.Equal(int64(0), dsl)
 }
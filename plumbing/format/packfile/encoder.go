@@ -3,6 +3,7 @@ package packfile
 import (
 	"compress/zlib"
 	"crypto"
+	"errors"
 	"fmt"
 	"io"
 
@@ -13,35 +14,50 @@ import (
 	"github.com/go-git/go-git/v6/utils/ioutil"
 )
 
+// ErrInvalidCompressionLevel is returned by Encode when the compression level
+// set with WithCompressionLevel is out of range.
+var ErrInvalidCompressionLevel = errors.New("invalid compression level")
+
 // Encoder gets the data from the storage and write it into the writer in PACK
 // format
 type Encoder struct {
 	selector *deltaSelector
 	w        *offsetWriter
 	zw       *zlib.Writer
+	level    int
 	hasher   plumbing.Hasher
+	stats    *EncoderStats
 
 	useRefDeltas bool
 }
 
 // NewEncoder creates a new packfile encoder using a specific Writer and
-// EncodedObjectStorer. By default deltas used to generate the packfile will be
-// OFSDeltaObject. To use Reference deltas, set useRefDeltas to true.
-func NewEncoder(w io.Writer, s storer.EncodedObjectStorer, useRefDeltas bool) *Encoder {
+// EncodedObjectStorer. By default the deltas based on an object of the
+// packfile are encoded as OFSDeltaObject, and the ones based on an object out
+// of it, see WithThinPack, as REFDeltaObject. To always use Reference deltas,
+// for receivers not supporting offset deltas, set useRefDeltas to true.
+func NewEncoder(w io.Writer, s storer.EncodedObjectStorer, useRefDeltas bool, opts ...EncoderOption) *Encoder {
 	h := plumbing.Hasher{
 		// TODO: Support passing an ObjectFormat (sha256)
 		Hash: hash.New(crypto.SHA1),
 	}
 	mw := io.MultiWriter(w, h)
 	ow := newOffsetWriter(mw)
-	zw := zlib.NewWriter(mw)
-	return &Encoder{
+	e := &Encoder{
 		selector:     newDeltaSelector(s),
 		w:            ow,
-		zw:           zw,
+		level:        zlib.DefaultCompression,
 		hasher:       h,
 		useRefDeltas: useRefDeltas,
 	}
+
+	for _, opt := range opts {
+		opt(e)
+	}
+
+	// An invalid level is reported by Encode, when the writer is nil.
+	e.zw, _ = zlib.NewWriterLevel(mw, e.level)
+	return e
 }
 
 // Encode creates a packfile containing all the objects referenced in
@@ -60,7 +76,21 @@ func (e *Encoder) Encode(
 	return e.encode(objects)
 }
 
+// Stats returns the statistics of the last packfile encoded, or nil if they
+// aren't collected, see WithStats.
+func (e *Encoder) Stats() *EncoderStats {
+	return e.stats
+}
+
 func (e *Encoder) encode(objects []*ObjectToPack) (plumbing.Hash, error) {
+	if e.zw == nil {
+		return plumbing.ZeroHash, fmt.Errorf("%w: %d", ErrInvalidCompressionLevel, e.level)
+	}
+
+	if e.stats != nil {
+		*e.stats = EncoderStats{}
+	}
+
 	if err := e.head(len(objects)); err != nil {
 		return plumbing.ZeroHash, err
 	}
@@ -121,6 +151,10 @@ func (e *Encoder) entry(o *ObjectToPack) (err error) {
 		}
 	}
 
+	if e.stats != nil {
+		e.stats.add(o)
+	}
+
 	e.zw.Reset(e.w)
 
 	defer ioutil.CheckClose(e.zw, &err)
@@ -137,7 +171,7 @@ func (e *Encoder) entry(o *ObjectToPack) (err error) {
 }
 
 func (e *Encoder) writeBaseIfDelta(o *ObjectToPack) error {
-	if o.IsDelta() && !o.Base.IsWritten() {
+	if o.IsDelta() && !o.Base.external && !o.Base.IsWritten() {
 		// We must write base first
 		return e.entry(o.Base)
 	}
@@ -146,9 +180,10 @@ func (e *Encoder) writeBaseIfDelta(o *ObjectToPack) error {
 }
 
 func (e *Encoder) writeDeltaHeader(o *ObjectToPack) error {
-	// Write offset deltas by default
+	// Write offset deltas by default, a base out of the packfile has no
+	// offset to refer to.
 	t := plumbing.OFSDeltaObject
-	if e.useRefDeltas {
+	if e.useRefDeltas || o.Base.external {
 		t = plumbing.REFDeltaObject
 	}
 
@@ -156,11 +191,11 @@ func (e *Encoder) writeDeltaHeader(o *ObjectToPack) error {
 		return err
 	}
 
-	if e.useRefDeltas {
+	if t == plumbing.REFDeltaObject {
 		return e.writeRefDeltaHeader(o.Base.Hash())
-	} else {
-		return e.writeOfsDeltaHeader(o)
 	}
+
+	return e.writeOfsDeltaHeader(o)
 }
 
 func (e *Encoder) writeRefDeltaHeader(base plumbing.Hash) error {
@@ -205,6 +240,58 @@ func (e *Encoder) footer() (plumbing.Hash, error) {
 	return h, err
 }
 
+// EncoderStats holds the statistics of the delta compression of a packfile
+// written by an Encoder, see WithStats.
+type EncoderStats struct {
+	// Objects is the number of objects written.
+	Objects int
+	// Deltas is the number of objects written as deltas, the others being
+	// written whole.
+	Deltas int
+	// ObjectsByType and DeltasByType are the number of objects and deltas
+	// written for each type of object, the type of a delta being the one of
+	// the object it resolves to.
+	ObjectsByType map[plumbing.ObjectType]int
+	DeltasByType  map[plumbing.ObjectType]int
+	// DeltaSavedBytes is the size of the objects written as deltas minus the
+	// size of their deltas, before compression.
+	DeltaSavedBytes int64
+	// TotalDepth is the sum of the length of the delta chains of the deltas,
+	// and MaxDepth the length of the longest one.
+	TotalDepth int
+	MaxDepth   int
+}
+
+// AverageDepth returns the average length of the delta chains of the deltas
+// written, 0 if there are none.
+func (s *EncoderStats) AverageDepth() float64 {
+	if s.Deltas == 0 {
+		return 0
+	}
+
+	return float64(s.TotalDepth) / float64(s.Deltas)
+}
+
+func (s *EncoderStats) add(o *ObjectToPack) {
+	if s.ObjectsByType == nil {
+		s.ObjectsByType = make(map[plumbing.ObjectType]int)
+		s.DeltasByType = make(map[plumbing.ObjectType]int)
+	}
+
+	t := o.Type()
+	s.Objects++
+	s.ObjectsByType[t]++
+	if !o.IsDelta() {
+		return
+	}
+
+	s.Deltas++
+	s.DeltasByType[t]++
+	s.DeltaSavedBytes += o.Size() - o.Object.Size()
+	s.TotalDepth += o.Depth
+	s.MaxDepth = max(s.MaxDepth, o.Depth)
+}
+
 type offsetWriter struct {
 	w      io.Writer
 	offset int64
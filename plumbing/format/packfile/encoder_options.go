@@ -0,0 +1,67 @@
+package packfile
+
+import "github.com/go-git/go-git/v6/plumbing"
+
+// EncoderOption configures an Encoder.
+type EncoderOption func(*Encoder)
+
+// WithMaxDeltaDepth sets the maximum length of the delta chains within the
+// encoded packfile. Objects are never deltified against a base whose own
+// chain already reaches depth, and reused deltas exceeding it are written
+// as whole objects. A depth of 0 turns off delta compression entirely.
+//
+// When not set, DefaultMaxDeltaDepth is used.
+func WithMaxDeltaDepth(depth uint) EncoderOption {
+	return func(e *Encoder) {
+		e.selector.maxDepth = int(depth)
+	}
+}
+
+// WithWindowMemory sets the maximum amount of memory, in bytes, used by the
+// objects in the sliding window of the delta compression, counting their
+// uncompressed data and delta indexes. When the limit is reached, the oldest
+// objects are removed from the window, even if it holds fewer objects than
+// the pack window size. Each object type is deltified using its own window.
+// A limit of 0 means no limit.
+//
+// When not set, DefaultWindowMemory is used.
+func WithWindowMemory(limit uint64) EncoderOption {
+	return func(e *Encoder) {
+		e.selector.windowMemory = limit
+	}
+}
+
+// WithCompressionLevel sets the zlib compression level of the objects within
+// the encoded packfile, from zlib.DefaultCompression (-1) to
+// zlib.BestCompression (9). Encode fails with ErrInvalidCompressionLevel for
+// a level out of this range.
+//
+// When not set, zlib.DefaultCompression is used, as git does.
+func WithCompressionLevel(level int) EncoderOption {
+	return func(e *Encoder) {
+		e.level = level
+	}
+}
+
+// WithThinPack makes the encoded packfile thin: the given objects, known to
+// exist at the receiver, are used as delta bases without being included in
+// the packfile. The deltas based on them are encoded as REFDeltaObject, and
+// must be resolved against the objects of the receiver when decoding. The
+// bases not found in the storer are ignored.
+//
+// Thin packfiles must only be sent to receivers supporting them, such as a
+// server not advertising the no-thin capability.
+func WithThinPack(bases []plumbing.Hash) EncoderOption {
+	return func(e *Encoder) {
+		e.selector.bases = bases
+	}
+}
+
+// WithStats makes the encoder collect the statistics of the delta compression
+// of the packfiles it writes, returned by Encoder.Stats. They aren't collected
+// by default.
+func WithStats() EncoderOption {
+	return func(e *Encoder) {
+		e.stats = &EncoderStats{}
+	}
+}
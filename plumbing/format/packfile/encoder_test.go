@@ -2,6 +2,8 @@ package packfile
 
 import (
 	"bytes"
+	"compress/zlib"
+	"fmt"
 	"io"
 	"testing"
 
@@ -91,6 +93,43 @@ func (s *EncoderSuite) TestHashNotFound() {
 	s.ErrorIs(err, plumbing.ErrObjectNotFound)
 }
 
+func (s *EncoderSuite) TestCompressionLevel() {
+	objects := []plumbing.EncodedObject{
+		newObject(plumbing.BlobObject, []byte("0")),
+		newObject(plumbing.BlobObject, bytes.Repeat([]byte("foo bar\n"), 1024)),
+		newObject(plumbing.CommitObject, []byte{}),
+	}
+
+	var hashes []plumbing.Hash
+	for _, o := range objects {
+		_, err := s.store.SetEncodedObject(o)
+		s.Require().NoError(err)
+		hashes = append(hashes, o.Hash())
+	}
+
+	for level := zlib.DefaultCompression; level <= zlib.BestCompression; level++ {
+		buf := bytes.NewBuffer(nil)
+		enc := NewEncoder(buf, s.store, false, WithCompressionLevel(level))
+		_, err := enc.Encode(hashes, 0)
+		s.Require().NoError(err, "level %d", level)
+
+		p, cleanup := packfileFromReader(s, buf)
+		for _, o := range objects {
+			dec, err := p.Get(o.Hash())
+			s.Require().NoError(err, "level %d", level)
+			objectsEqual(s, dec, o)
+		}
+
+		cleanup()
+	}
+}
+
+func (s *EncoderSuite) TestInvalidCompressionLevel() {
+	enc := NewEncoder(s.buf, s.store, false, WithCompressionLevel(10))
+	_, err := enc.Encode([]plumbing.Hash{}, 10)
+	s.ErrorIs(err, ErrInvalidCompressionLevel)
+}
+
 func (s *EncoderSuite) TestDecodeEncodeWithDeltaDecodeREF() {
 	s.enc = NewEncoder(s.buf, s.store, true)
 	s.simpleDeltaTest()
@@ -325,3 +364,266 @@ func packfileFromReader(s *EncoderSuite, buf *bytes.Buffer) (*Packfile, func())
 		s.NoError(file.Close())
 	}
 }
+
+func (s *EncoderSuite) TestMaxDeltaDepth() {
+	var hashes []plumbing.Hash
+	content := bytes.Repeat([]byte("line of content\n"), 100)
+	for i := 0; i < 30; i++ {
+		content = append(content, []byte(fmt.Sprintf("line %d\n", i))...)
+		o := s.store.NewEncodedObject()
+		o.SetType(plumbing.BlobObject)
+		o.SetSize(int64(len(content)))
+		w, err := o.Writer()
+		s.NoError(err)
+		_, err = w.Write(content)
+		s.NoError(err)
+		s.NoError(w.Close())
+
+		h, err := s.store.SetEncodedObject(o)
+		s.NoError(err)
+		hashes = append(hashes, h)
+	}
+
+	for _, depth := range []uint{0, 1, 2, 5} {
+		buf := bytes.NewBuffer(nil)
+		enc := NewEncoder(buf, s.store, false, WithMaxDeltaDepth(depth))
+		_, err := enc.Encode(hashes, 10)
+		s.NoError(err)
+
+		depths := make(map[int64]uint)
+		var max uint
+		scanner := NewScanner(bytes.NewReader(buf.Bytes()))
+		for scanner.Scan() {
+			data := scanner.Data()
+			if data.Section != ObjectSection {
+				continue
+			}
+
+			oh := data.Value().(ObjectHeader)
+			if oh.Type == plumbing.OFSDeltaObject {
+				base, ok := depths[oh.OffsetReference]
+				s.True(ok)
+				depths[oh.Offset] = base + 1
+			} else {
+				depths[oh.Offset] = 0
+			}
+
+			max = maxUint(max, depths[oh.Offset])
+		}
+
+		s.NoError(scanner.Error())
+		s.Len(depths, len(hashes))
+		s.LessOrEqual(max, depth)
+		if depth > 0 {
+			s.Equal(depth, max, "deltas are expected to reach the depth limit")
+		}
+	}
+}
+
+func (s *EncoderSuite) TestStats() {
+	var hashes []plumbing.Hash
+	var objectsSize int64
+	content := bytes.Repeat([]byte("line of content\n"), 100)
+	for i := 0; i < 10; i++ {
+		content = append(content, []byte(fmt.Sprintf("line %d\n", i))...)
+		objectsSize += int64(len(content))
+		o := s.store.NewEncodedObject()
+		o.SetType(plumbing.BlobObject)
+		o.SetSize(int64(len(content)))
+		w, err := o.Writer()
+		s.NoError(err)
+		_, err = w.Write(content)
+		s.NoError(err)
+		s.NoError(w.Close())
+
+		h, err := s.store.SetEncodedObject(o)
+		s.NoError(err)
+		hashes = append(hashes, h)
+	}
+
+	_, err := s.enc.Encode(hashes, 10)
+	s.NoError(err)
+	s.Nil(s.enc.Stats())
+
+	buf := bytes.NewBuffer(nil)
+	enc := NewEncoder(buf, s.store, false, WithStats(), WithMaxDeltaDepth(3))
+	_, err = enc.Encode(hashes, 10)
+	s.NoError(err)
+
+	var deltas, total int
+	var deltasSize, wholeSize int64
+	depths := make(map[int64]int)
+	scanner := NewScanner(bytes.NewReader(buf.Bytes()))
+	for scanner.Scan() {
+		data := scanner.Data()
+		if data.Section != ObjectSection {
+			continue
+		}
+
+		oh := data.Value().(ObjectHeader)
+		if oh.Type == plumbing.OFSDeltaObject {
+			deltas++
+			deltasSize += oh.Size
+			depths[oh.Offset] = depths[oh.OffsetReference] + 1
+			total += depths[oh.Offset]
+		} else {
+			wholeSize += oh.Size
+		}
+	}
+
+	s.NoError(scanner.Error())
+	s.NotZero(deltas)
+
+	stats := enc.Stats()
+	s.Equal(len(hashes), stats.Objects)
+	s.Equal(deltas, stats.Deltas)
+	s.Equal(map[plumbing.ObjectType]int{plumbing.BlobObject: len(hashes)}, stats.ObjectsByType)
+	s.Equal(map[plumbing.ObjectType]int{plumbing.BlobObject: deltas}, stats.DeltasByType)
+	s.Equal(total, stats.TotalDepth)
+	s.Equal(3, stats.MaxDepth)
+	s.Equal(float64(total)/float64(deltas), stats.AverageDepth())
+	s.Equal(objectsSize-wholeSize-deltasSize, stats.DeltaSavedBytes)
+}
+
+func maxUint(a, b uint) uint {
+	if a > b {
+		return a
+	}
+
+	return b
+}
+
+func (s *EncoderSuite) TestWindowMemory() {
+	var hashes []plumbing.Hash
+	content := bytes.Repeat([]byte("large line of content\n"), 3000)
+	for i := 0; i < 8; i++ {
+		content = append(content, []byte(fmt.Sprintf("line %d\n", i))...)
+		o := s.store.NewEncodedObject()
+		o.SetType(plumbing.BlobObject)
+		o.SetSize(int64(len(content)))
+		w, err := o.Writer()
+		s.NoError(err)
+		_, err = w.Write(content)
+		s.NoError(err)
+		s.NoError(w.Close())
+
+		h, err := s.store.SetEncodedObject(o)
+		s.NoError(err)
+		hashes = append(hashes, h)
+	}
+
+	for _, tc := range []struct {
+		limit  uint64
+		deltas bool
+	}{
+		{0, true},
+		{uint64(len(content)) * 3, true},
+		{uint64(len(content)) / 2, false},
+	} {
+		buf := bytes.NewBuffer(nil)
+		enc := NewEncoder(buf, s.store, false, WithWindowMemory(tc.limit))
+		_, err := enc.Encode(hashes, 10)
+		s.NoError(err)
+
+		var deltas int
+		scanner := NewScanner(bytes.NewReader(buf.Bytes()))
+		for scanner.Scan() {
+			data := scanner.Data()
+			if data.Section == ObjectSection && data.Value().(ObjectHeader).Type.IsDelta() {
+				deltas++
+			}
+		}
+
+		s.NoError(scanner.Error())
+		s.Equal(tc.deltas, deltas > 0, "limit %d", tc.limit)
+
+		st := memory.NewStorage()
+		s.NoError(UpdateObjectStorage(st, bytes.NewReader(buf.Bytes())))
+		for _, h := range hashes {
+			s.NoError(st.HasEncodedObject(h))
+		}
+	}
+}
+
+func (s *EncoderSuite) TestThinPack() {
+	newBlob := func(st *memory.Storage, content []byte) plumbing.Hash {
+		o := st.NewEncodedObject()
+		o.SetType(plumbing.BlobObject)
+		o.SetSize(int64(len(content)))
+		w, err := o.Writer()
+		s.Require().NoError(err)
+		_, err = w.Write(content)
+		s.Require().NoError(err)
+		s.Require().NoError(w.Close())
+
+		h, err := st.SetEncodedObject(o)
+		s.Require().NoError(err)
+		return h
+	}
+
+	baseContent := bytes.Repeat([]byte("line of content\n"), 100)
+	targetContent := append(append([]byte(nil), baseContent...), "new line\n"...)
+	base := newBlob(s.store, baseContent)
+	target := newBlob(s.store, targetContent)
+
+	for _, tc := range []struct {
+		name         string
+		hashes       []plumbing.Hash
+		useRefDeltas bool
+		deltaType    plumbing.ObjectType
+	}{
+		{"external base", []plumbing.Hash{target}, false, plumbing.REFDeltaObject},
+		{"packed base", []plumbing.Hash{base, target}, false, plumbing.OFSDeltaObject},
+		{"packed base ref deltas", []plumbing.Hash{base, target}, true, plumbing.REFDeltaObject},
+	} {
+		buf := bytes.NewBuffer(nil)
+		enc := NewEncoder(buf, s.store, tc.useRefDeltas, WithThinPack([]plumbing.Hash{base}))
+		_, err := enc.Encode(tc.hashes, 10)
+		s.Require().NoError(err, tc.name)
+
+		var headers []ObjectHeader
+		scanner := NewScanner(bytes.NewReader(buf.Bytes()))
+		for scanner.Scan() {
+			if data := scanner.Data(); data.Section == ObjectSection {
+				headers = append(headers, data.Value().(ObjectHeader))
+			}
+		}
+
+		s.Require().NoError(scanner.Error(), tc.name)
+		s.Require().Len(headers, len(tc.hashes), tc.name)
+
+		var deltas []ObjectHeader
+		for _, oh := range headers {
+			if oh.Type.IsDelta() {
+				deltas = append(deltas, oh)
+			}
+		}
+
+		s.Require().Len(deltas, 1, tc.name)
+		s.Equal(tc.deltaType, deltas[0].Type, tc.name)
+		if len(tc.hashes) == 1 {
+			s.Equal(base, deltas[0].Reference, tc.name)
+		}
+
+		// The thin pack is decoded against a storage holding its bases.
+		st := memory.NewStorage()
+		newBlob(st, baseContent)
+		_, err = NewParser(bytes.NewReader(buf.Bytes()), WithStorage(st)).Parse()
+		s.Require().NoError(err, tc.name)
+
+		o, err := st.EncodedObject(plumbing.BlobObject, target)
+		s.Require().NoError(err, tc.name)
+		r, err := o.Reader()
+		s.Require().NoError(err)
+		content, err := io.ReadAll(r)
+		s.Require().NoError(err)
+		s.Equal(targetContent, content, tc.name)
+	}
+}
+
+func (s *EncoderSuite) TestThinPackMissingBase() {
+	buf := bytes.NewBuffer(nil)
+	enc := NewEncoder(buf, s.store, false, WithThinPack([]plumbing.Hash{plumbing.NewHash("1111111111111111111111111111111111111111")}))
+	_, err := enc.Encode(nil, 10)
+	s.NoError(err)
+}
@@ -1,8 +1,10 @@
 package packfile
 
 import (
+	"compress/zlib"
 	"errors"
 	"io"
+	"math"
 	"os"
 
 	billy "github.com/go-git/go-billy/v5"
@@ -132,3 +134,78 @@ func (o *FSObject) Type() plumbing.ObjectType {
 func (o *FSObject) Writer() (io.WriteCloser, error) {
 	return nil, nil
 }
+
+// deltaFSObject is a deltified object from the packfile on the filesystem,
+// patched while it is read, so it is never kept in memory. The delta is read
+// from its own file descriptor, so it can be read along with its base.
+type deltaFSObject struct {
+	hash     plumbing.Hash
+	typ      plumbing.ObjectType
+	size     int64
+	offset   int64
+	base     plumbing.EncodedObject
+	fs       billy.Filesystem
+	packPath string
+}
+
+// Reader implements the plumbing.EncodedObject interface.
+func (o *deltaFSObject) Reader() (io.ReadCloser, error) {
+	f, err := o.fs.Open(o.packPath)
+	if err != nil {
+		return nil, err
+	}
+
+	zr, err := zlib.NewReader(io.NewSectionReader(f, o.offset, math.MaxInt64-o.offset))
+	if err != nil {
+		_ = f.Close()
+		return nil, ErrZLib.AddDetails("%s", err)
+	}
+
+	r, err := ReaderFromDelta(o.base, zr)
+	if err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+
+	return &deltaReadCloser{ReadCloser: r, f: f}, nil
+}
+
+// deltaReadCloser closes the packfile once the patched object is read. The
+// zlib reader is not closed, as it may still be used by the patching
+// goroutine, and has nothing to release anyway.
+type deltaReadCloser struct {
+	io.ReadCloser
+	f io.Closer
+}
+
+func (r *deltaReadCloser) Close() error {
+	err := r.ReadCloser.Close()
+	if cerr := r.f.Close(); err == nil {
+		err = cerr
+	}
+
+	return err
+}
+
+// SetSize implements the plumbing.EncodedObject interface. This method
+// is a noop.
+func (o *deltaFSObject) SetSize(int64) {}
+
+// SetType implements the plumbing.EncodedObject interface. This method is
+// a noop.
+func (o *deltaFSObject) SetType(plumbing.ObjectType) {}
+
+// Hash implements the plumbing.EncodedObject interface.
+func (o *deltaFSObject) Hash() plumbing.Hash { return o.hash }
+
+// Size implements the plumbing.EncodedObject interface.
+func (o *deltaFSObject) Size() int64 { return o.size }
+
+// Type implements the plumbing.EncodedObject interface.
+func (o *deltaFSObject) Type() plumbing.ObjectType { return o.typ }
+
+// Writer implements the plumbing.EncodedObject interface. This method always
+// returns a nil writer.
+func (o *deltaFSObject) Writer() (io.WriteCloser, error) {
+	return nil, nil
+}
@@ -0,0 +1,191 @@
+package packfile
+
+import (
+	"bytes"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"testing"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/go-git/go-billy/v5/osfs"
+	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/go-git/go-git/v6/plumbing/format/config"
+	"github.com/go-git/go-git/v6/plumbing/format/idxfile"
+	"github.com/go-git/go-git/v6/utils/binary"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const largeDeltaPrefix = "streamed\n"
+
+func TestDeltaFSObject(t *testing.T) {
+	t.Parallel()
+
+	fs := memfs.New()
+	size := int64(4*maxCopySize + 42)
+	idx, h := writeLargeDeltaPack(t, fs, size)
+
+	p := openLargeDeltaPack(t, fs, idx, size)
+
+	obj, err := p.Get(h)
+	require.NoError(t, err)
+	require.IsType(t, &deltaFSObject{}, obj)
+	assert.Equal(t, h, obj.Hash())
+	assert.Equal(t, plumbing.BlobObject, obj.Type())
+	assert.Equal(t, size+int64(len(largeDeltaPrefix)), obj.Size())
+
+	// Read it twice, to check the delta is read from its own descriptor.
+	for i := 0; i < 2; i++ {
+		r, err := obj.Reader()
+		require.NoError(t, err)
+
+		hasher := plumbing.NewHasher(config.SHA1, obj.Type(), obj.Size())
+		n, err := io.Copy(hasher, r)
+		require.NoError(t, err)
+		require.NoError(t, r.Close())
+
+		assert.Equal(t, obj.Size(), n)
+		assert.Equal(t, h, hasher.Sum())
+	}
+
+	// Below the threshold, the object is kept in memory.
+	p = openLargeDeltaPack(t, fs, idx, size*2)
+	obj, err = p.Get(h)
+	require.NoError(t, err)
+	assert.IsType(t, &plumbing.MemoryObject{}, obj)
+}
+
+func BenchmarkDeltaFSObjectReader(b *testing.B) {
+	for _, size := range []int64{16 << 20, 1 << 30} {
+		b.Run(fmt.Sprintf("%dMB", size>>20), func(b *testing.B) {
+			if size > 16<<20 && testing.Short() {
+				b.Skip("skipping large object in short mode")
+			}
+
+			fs := osfs.New(b.TempDir())
+			idx, h := writeLargeDeltaPack(b, fs, size)
+			p := openLargeDeltaPack(b, fs, idx, 1<<20)
+
+			b.SetBytes(size)
+			b.ReportAllocs()
+			b.ResetTimer()
+
+			for i := 0; i < b.N; i++ {
+				obj, err := p.Get(h)
+				if err != nil {
+					b.Fatal(err)
+				}
+
+				r, err := obj.Reader()
+				if err != nil {
+					b.Fatal(err)
+				}
+
+				if _, err := io.Copy(io.Discard, r); err != nil {
+					b.Fatal(err)
+				}
+
+				if err := r.Close(); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+const largeDeltaPack = "pack-large.pack"
+
+// writeLargeDeltaPack writes to fs a packfile with a blob of the given size, and a
+// blob made of a short prefix followed by the first one, stored as an OFS
+// delta of copy instructions. It returns the index of the packfile and the
+// hash of the deltified blob.
+func writeLargeDeltaPack(tb testing.TB, fs billy.Filesystem, size int64) (idxfile.Index, plumbing.Hash) {
+	tb.Helper()
+
+	f, err := fs.Create(largeDeltaPack)
+	require.NoError(tb, err)
+	defer f.Close()
+
+	crc := crc32.NewIEEE()
+	e := NewEncoder(io.MultiWriter(f, crc), nil, false)
+	iw := new(idxfile.Writer)
+
+	require.NoError(tb, iw.OnHeader(2))
+	require.NoError(tb, e.head(2))
+
+	// The base blob repeats a random block, so it compresses well.
+	block := randBytes(4096)
+	content := func() io.Reader {
+		return io.LimitReader(&repeatReader{block: block}, size)
+	}
+
+	baseOffset := e.w.Offset()
+	crc.Reset()
+	require.NoError(tb, e.entryHead(plumbing.BlobObject, size))
+	base := plumbing.NewHasher(config.SHA1, plumbing.BlobObject, size)
+	e.zw.Reset(e.w)
+	_, err = io.Copy(io.MultiWriter(e.zw, base), content())
+	require.NoError(tb, err)
+	require.NoError(tb, e.zw.Close())
+	iw.Add(base.Sum(), uint64(baseOffset), crc.Sum32())
+
+	targetSize := size + int64(len(largeDeltaPrefix))
+	var delta bytes.Buffer
+	delta.Write(deltaEncodeSize(int(size)))
+	delta.Write(deltaEncodeSize(int(targetSize)))
+	delta.WriteByte(byte(len(largeDeltaPrefix)))
+	delta.WriteString(largeDeltaPrefix)
+	for off := int64(0); off < size; off += maxCopySize {
+		delta.Write(encodeCopyOperation(int(off), int(min(maxCopySize, size-off))))
+	}
+
+	deltaOffset := e.w.Offset()
+	crc.Reset()
+	require.NoError(tb, e.entryHead(plumbing.OFSDeltaObject, int64(delta.Len())))
+	require.NoError(tb, binary.WriteVariableWidthInt(e.w, deltaOffset-baseOffset))
+	e.zw.Reset(e.w)
+	_, err = delta.WriteTo(e.zw)
+	require.NoError(tb, err)
+	require.NoError(tb, e.zw.Close())
+
+	target := plumbing.NewHasher(config.SHA1, plumbing.BlobObject, targetSize)
+	_, err = io.Copy(target, io.MultiReader(bytes.NewBufferString(largeDeltaPrefix), content()))
+	require.NoError(tb, err)
+	iw.Add(target.Sum(), uint64(deltaOffset), crc.Sum32())
+
+	checksum, err := e.footer()
+	require.NoError(tb, err)
+	require.NoError(tb, iw.OnFooter(checksum))
+
+	idx, err := iw.Index()
+	require.NoError(tb, err)
+
+	return idx, target.Sum()
+}
+
+// openLargeDeltaPack opens the packfile written by writeLargeDeltaPack.
+func openLargeDeltaPack(tb testing.TB, fs billy.Filesystem, idx idxfile.Index, threshold int64) *Packfile {
+	tb.Helper()
+
+	f, err := fs.Open(largeDeltaPack)
+	require.NoError(tb, err)
+
+	p := NewPackfile(f, WithIdx(idx), WithFs(fs), WithLargeObjectThreshold(threshold))
+	tb.Cleanup(func() { _ = p.Close() })
+
+	return p
+}
+
+// repeatReader endlessly reads the same block.
+type repeatReader struct {
+	block []byte
+	off   int
+}
+
+func (r *repeatReader) Read(p []byte) (int, error) {
+	n := copy(p, r.block[r.off:])
+	r.off = (r.off + n) % len(r.block)
+	return n, nil
+}
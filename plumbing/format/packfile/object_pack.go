@@ -24,6 +24,10 @@ type ObjectToPack struct {
 	// has not been written yet
 	Offset int64
 
+	// external is true for the objects used only as delta bases, which are
+	// not written into the pack file, see WithThinPack.
+	external bool
+
 	// Information from the original object
 	resolvedOriginal bool
 	originalType     plumbing.ObjectType
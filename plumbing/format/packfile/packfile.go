@@ -1,9 +1,12 @@
 package packfile
 
 import (
+	"bufio"
+	"compress/zlib"
 	"crypto"
 	"fmt"
 	"io"
+	"math"
 	"os"
 	"sync"
 
@@ -38,6 +41,8 @@ type Packfile struct {
 	m            sync.Mutex
 	objectIdSize int
 
+	largeObjectThreshold int64
+
 	once    sync.Once
 	onceErr error
 }
@@ -171,7 +176,7 @@ func (p *Packfile) get(h plumbing.Hash) (plumbing.EncodedObject, error) {
 		return nil, err
 	}
 
-	return p.objectFromHeader(oh)
+	return p.objectFromHeader(oh, h)
 }
 
 // getByOffset is not threat-safe, and should only be called within packfile.go.
@@ -190,7 +195,7 @@ func (p *Packfile) getByOffset(offset int64) (plumbing.EncodedObject, error) {
 		return nil, err
 	}
 
-	return p.objectFromHeader(oh)
+	return p.objectFromHeader(oh, h)
 }
 
 func (p *Packfile) init() error {
@@ -264,7 +269,7 @@ func (p *Packfile) Close() error {
 	return closer.Close()
 }
 
-func (p *Packfile) objectFromHeader(oh *ObjectHeader) (plumbing.EncodedObject, error) {
+func (p *Packfile) objectFromHeader(oh *ObjectHeader, h plumbing.Hash) (plumbing.EncodedObject, error) {
 	if oh == nil {
 		return nil, plumbing.ErrObjectNotFound
 	}
@@ -288,9 +293,77 @@ func (p *Packfile) objectFromHeader(oh *ObjectHeader) (plumbing.EncodedObject, e
 		return fs, nil
 	}
 
+	// Big deltified objects are patched while they are read, instead of
+	// being kept in memory.
+	if oh.Type.IsDelta() && p.fs != nil && p.largeObjectThreshold > 0 {
+		size, err := p.deltaTargetSize(oh)
+		if err != nil {
+			return nil, err
+		}
+
+		if size > p.largeObjectThreshold {
+			return p.getDeltaFSObject(oh, h, size)
+		}
+	}
+
 	return p.getMemoryObject(oh)
 }
 
+// deltaBase returns the base object of a deltified object.
+func (p *Packfile) deltaBase(oh *ObjectHeader) (plumbing.EncodedObject, error) {
+	switch oh.Type {
+	case plumbing.REFDeltaObject:
+		if parent, ok := p.cache.Get(oh.Reference); ok {
+			return parent, nil
+		}
+
+		return p.get(oh.Reference)
+	case plumbing.OFSDeltaObject:
+		return p.getByOffset(oh.OffsetReference)
+	default:
+		return nil, ErrInvalidObject.AddDetails("type %q", oh.Type)
+	}
+}
+
+// deltaTargetSize returns the size of the object resulting of a delta,
+// inflating only the header of the delta.
+func (p *Packfile) deltaTargetSize(oh *ObjectHeader) (int64, error) {
+	zr, err := zlib.NewReader(io.NewSectionReader(p.file, oh.ContentOffset, math.MaxInt64-oh.ContentOffset))
+	if err != nil {
+		return 0, ErrZLib.AddDetails("%s", err)
+	}
+	defer zr.Close()
+
+	br := bufio.NewReaderSize(zr, 32)
+	if _, err := decodeLEB128ByteReader(br); err != nil {
+		return 0, ErrInvalidDelta
+	}
+
+	size, err := decodeLEB128ByteReader(br)
+	if err != nil {
+		return 0, ErrInvalidDelta
+	}
+
+	return int64(size), nil
+}
+
+func (p *Packfile) getDeltaFSObject(oh *ObjectHeader, h plumbing.Hash, size int64) (plumbing.EncodedObject, error) {
+	base, err := p.deltaBase(oh)
+	if err != nil {
+		return nil, fmt.Errorf("cannot find base object: %w", err)
+	}
+
+	return &deltaFSObject{
+		hash:     h,
+		typ:      base.Type(),
+		size:     size,
+		offset:   oh.ContentOffset,
+		base:     base,
+		fs:       p.fs,
+		packPath: p.file.Name(),
+	}, nil
+}
+
 func (p *Packfile) getMemoryObject(oh *ObjectHeader) (plumbing.EncodedObject, error) {
 	var obj = new(plumbing.MemoryObject)
 	obj.SetSize(oh.Size)
@@ -308,18 +381,7 @@ func (p *Packfile) getMemoryObject(oh *ObjectHeader) (plumbing.EncodedObject, er
 
 	case plumbing.REFDeltaObject, plumbing.OFSDeltaObject:
 		var parent plumbing.EncodedObject
-
-		switch oh.Type {
-		case plumbing.REFDeltaObject:
-			var ok bool
-			parent, ok = p.cache.Get(oh.Reference)
-			if !ok {
-				parent, err = p.get(oh.Reference)
-			}
-		case plumbing.OFSDeltaObject:
-			parent, err = p.getByOffset(oh.OffsetReference)
-		}
-
+		parent, err = p.deltaBase(oh)
 		if err != nil {
 			return nil, fmt.Errorf("cannot find base object: %w", err)
 		}
@@ -330,7 +392,7 @@ func (p *Packfile) getMemoryObject(oh *ObjectHeader) (plumbing.EncodedObject, er
 		}
 
 		obj.SetType(parent.Type())
-		err = ApplyDelta(obj, parent, oh.content.Bytes()) //nolint:ineffassign
+		err = ApplyDelta(obj, parent, oh.content.Bytes())
 
 	default:
 		err = ErrInvalidObject.AddDetails("type %q", oh.Type)
@@ -37,13 +37,13 @@ func (i *objectIter) next() (plumbing.EncodedObject, error) {
 		}
 
 		if i.typ == plumbing.AnyObject {
-			return i.p.objectFromHeader(oh)
+			return i.p.objectFromHeader(oh, e.Hash)
 		}
 
 		// Current object header type is a delta, get the actual object to
 		// assess the actual type.
 		if oh.Type.IsDelta() {
-			o, err := i.p.objectFromHeader(oh)
+			o, err := i.p.objectFromHeader(oh, e.Hash)
 			if o.Type() == i.typ {
 				return o, err
 			}
@@ -52,7 +52,7 @@ func (i *objectIter) next() (plumbing.EncodedObject, error) {
 		}
 
 		if oh.Type == i.typ {
-			return i.p.objectFromHeader(oh)
+			return i.p.objectFromHeader(oh, e.Hash)
 		}
 
 		continue
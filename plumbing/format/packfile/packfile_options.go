@@ -40,3 +40,15 @@ func WithObjectIDSize(sz int) PackfileOption {
 		p.objectIdSize = sz
 	}
 }
+
+// WithLargeObjectThreshold sets the size in bytes above which the deltified
+// objects are not kept in memory, but patched while they are read, with the
+// delta and its base read from the packfile. The objects that are not
+// deltified are always read from the packfile when a filesystem is set.
+//
+// When no threshold is set, the deltified objects are kept in memory.
+func WithLargeObjectThreshold(threshold int64) PackfileOption {
+	return func(p *Packfile) {
+		p.largeObjectThreshold = threshold
+	}
+}
@@ -1,16 +1,22 @@
 package packfile_test
 
 import (
+	"bytes"
+	"compress/zlib"
 	"crypto"
+	"encoding/binary"
+	"hash/crc32"
 	"io"
 	"math"
 	"testing"
 
+	"github.com/go-git/go-billy/v5/memfs"
 	fixtures "github.com/go-git/go-git-fixtures/v5"
 	"github.com/go-git/go-git/v6/plumbing"
 	"github.com/go-git/go-git/v6/plumbing/cache"
 	"github.com/go-git/go-git/v6/plumbing/format/idxfile"
 	"github.com/go-git/go-git/v6/plumbing/format/packfile"
+	"github.com/go-git/go-git/v6/plumbing/hash"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -62,6 +68,67 @@ func TestGetByOffset(t *testing.T) {
 	assert.ErrorIs(t, err, plumbing.ErrObjectNotFound)
 }
 
+func TestGetInvalidDelta(t *testing.T) {
+	t.Parallel()
+
+	object := func(header, content []byte) []byte {
+		var obj bytes.Buffer
+		obj.Write(header)
+		zw := zlib.NewWriter(&obj)
+		_, err := zw.Write(content)
+		require.NoError(t, err)
+		require.NoError(t, zw.Close())
+		return obj.Bytes()
+	}
+
+	// A blob followed by a delta against it, whose source size doesn't
+	// match the one of the blob.
+	base := object([]byte{byte(plumbing.BlobObject)<<4 | 3}, []byte("foo"))
+	delta := []byte{10, 3, 3, 'b', 'a', 'r'}
+	objects := [][]byte{
+		base,
+		object([]byte{byte(plumbing.OFSDeltaObject)<<4 | byte(len(delta)), byte(len(base))}, delta),
+	}
+
+	var pack bytes.Buffer
+	pack.WriteString("PACK")
+	require.NoError(t, binary.Write(&pack, binary.BigEndian, []uint32{2, 2}))
+	pack.Write(objects[0])
+	pack.Write(objects[1])
+
+	h := hash.New(crypto.SHA1)
+	h.Write(pack.Bytes())
+	pack.Write(h.Sum(nil))
+
+	blob := plumbing.ComputeHash(plumbing.BlobObject, []byte("foo"))
+	target := plumbing.ComputeHash(plumbing.BlobObject, []byte("bar"))
+
+	w := &idxfile.Writer{}
+	require.NoError(t, w.OnHeader(2))
+	w.Add(blob, 12, crc32.ChecksumIEEE(objects[0]))
+	w.Add(target, uint64(12+len(objects[0])), crc32.ChecksumIEEE(objects[1]))
+	require.NoError(t, w.OnFooter(plumbing.ZeroHash))
+	idx, err := w.Index()
+	require.NoError(t, err)
+
+	fs := memfs.New()
+	f, err := fs.Create("pack")
+	require.NoError(t, err)
+	_, err = f.Write(pack.Bytes())
+	require.NoError(t, err)
+	_, err = f.Seek(0, io.SeekStart)
+	require.NoError(t, err)
+
+	p := packfile.NewPackfile(f, packfile.WithIdx(idx))
+
+	_, err = p.Get(target)
+	assert.ErrorIs(t, err, packfile.ErrInvalidDelta)
+
+	// The object isn't cached, so the error is returned again.
+	_, err = p.Get(target)
+	assert.ErrorIs(t, err, packfile.ErrInvalidDelta)
+}
+
 func TestGetAll(t *testing.T) {
 	t.Parallel()
 
@@ -121,6 +188,40 @@ func TestDecode(t *testing.T) {
 	}
 }
 
+func TestDecodeLargeObjectThreshold(t *testing.T) {
+	t.Parallel()
+
+	packfiles := fixtures.Basic().ByTag("packfile")
+	assert.Greater(t, len(packfiles), 0)
+
+	for _, f := range packfiles {
+		index := getIndexFromIdxFile(f.Idx())
+
+		// Every deltified object is above the threshold, so it is patched
+		// while it is read.
+		p := packfile.NewPackfile(f.Packfile(),
+			packfile.WithIdx(index), packfile.WithFs(fixtures.Filesystem),
+			packfile.WithLargeObjectThreshold(1),
+		)
+
+		for _, h := range expectedHashes {
+			obj, err := p.Get(plumbing.NewHash(h))
+			require.NoError(t, err)
+
+			r, err := obj.Reader()
+			require.NoError(t, err)
+			content, err := io.ReadAll(r)
+			require.NoError(t, err)
+			require.NoError(t, r.Close())
+
+			assert.Len(t, content, int(obj.Size()))
+			assert.Equal(t, h, plumbing.ComputeHash(obj.Type(), content).String())
+		}
+
+		assert.NoError(t, p.Close())
+	}
+}
+
 func TestDecodeByTypeRefDelta(t *testing.T) {
 	t.Parallel()
 
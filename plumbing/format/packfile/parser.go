@@ -8,6 +8,7 @@ import (
 	stdsync "sync"
 
 	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/go-git/go-git/v6/plumbing/cache"
 	format "github.com/go-git/go-git/v6/plumbing/format/config"
 	"github.com/go-git/go-git/v6/plumbing/storer"
 	"github.com/go-git/go-git/v6/utils/ioutil"
@@ -29,8 +30,9 @@ var (
 // Parser decodes a packfile and calls any observer associated to it. Is used
 // to generate indexes.
 type Parser struct {
-	storage storer.EncodedObjectStorer
-	cache   *parserCache
+	storage   storer.EncodedObjectStorer
+	cache     *parserCache
+	baseCache cache.Object
 
 	scanner   *Scanner
 	observers []Observer
@@ -219,6 +221,20 @@ func (p *Parser) processDelta(oh *ObjectHeader) error {
 }
 
 func (p *Parser) parentReader(parent *ObjectHeader) (io.ReaderAt, error) {
+	if content, ok := p.cachedBase(parent); ok {
+		return bytes.NewReader(content), nil
+	}
+
+	content, err := p.parentContent(parent)
+	if err != nil {
+		return nil, err
+	}
+
+	p.cacheBase(parent, content)
+	return bytes.NewReader(content), nil
+}
+
+func (p *Parser) parentContent(parent *ObjectHeader) ([]byte, error) {
 	// If parent is a Delta object, the inflated object must come
 	// from either cache or storage, else we would need to inflate
 	// it to then inflate the current object, which could go on
@@ -238,14 +254,14 @@ func (p *Parser) parentReader(parent *ObjectHeader) (io.ReaderAt, error) {
 				r.Close()
 
 				if err == nil {
-					return bytes.NewReader(parentData.Bytes()), nil
+					return parentData.Bytes(), nil
 				}
 			}
 		}
 	}
 
 	if p.cache != nil && parent.content.Len() > 0 {
-		return bytes.NewReader(parent.content.Bytes()), nil
+		return parent.content.Bytes(), nil
 	}
 
 	// If the parent is not an external ref and we don't have the
@@ -265,7 +281,56 @@ func (p *Parser) parentReader(parent *ObjectHeader) (io.ReaderAt, error) {
 	if err != nil {
 		return nil, ErrReferenceDeltaNotFound
 	}
-	return bytes.NewReader(parentData.Bytes()), nil
+	return parentData.Bytes(), nil
+}
+
+// cachedBase returns the content of the parent from the delta base cache,
+// setting its type and size.
+func (p *Parser) cachedBase(parent *ObjectHeader) ([]byte, bool) {
+	if p.baseCache == nil || parent.Hash.IsZero() {
+		return nil, false
+	}
+
+	obj, ok := p.baseCache.Get(parent.Hash)
+	if !ok {
+		return nil, false
+	}
+
+	var content []byte
+	if b, ok := obj.(*deltaBase); ok {
+		content = b.content
+	} else {
+		r, err := obj.Reader()
+		if err != nil {
+			return nil, false
+		}
+
+		content, err = io.ReadAll(r)
+		r.Close()
+		if err != nil {
+			return nil, false
+		}
+	}
+
+	parent.Type = obj.Type()
+	parent.Size = obj.Size()
+	return content, true
+}
+
+// cacheBase adds the content of the parent to the delta base cache, once
+// its type is known.
+func (p *Parser) cacheBase(parent *ObjectHeader, content []byte) {
+	if p.baseCache == nil || parent.Hash.IsZero() ||
+		!parent.Type.Valid() || parent.Type.IsDelta() ||
+		int64(len(content)) != parent.Size {
+		return
+	}
+
+	p.baseCache.Put(&deltaBase{
+		hash:    parent.Hash,
+		typ:     parent.Type,
+		content: bytes.Clone(content),
+	})
 }
 
 func (p *Parser) cacheWriter(oh *ObjectHeader) (io.WriteCloser, error) {
@@ -1,6 +1,9 @@
 package packfile
 
 import (
+	"bytes"
+	"errors"
+	"io"
 	"slices"
 
 	"github.com/go-git/go-git/v6/plumbing"
@@ -40,3 +43,25 @@ func (c *parserCache) Reset(n int) {
 		maps.Clear(c.oiByOffset)
 	}
 }
+
+// deltaBase is the resolved content of a delta base, as kept in the delta
+// base cache. Its hash is known beforehand, so it's never computed again.
+type deltaBase struct {
+	hash    plumbing.Hash
+	typ     plumbing.ObjectType
+	content []byte
+}
+
+func (b *deltaBase) Hash() plumbing.Hash           { return b.hash }
+func (b *deltaBase) Type() plumbing.ObjectType     { return b.typ }
+func (b *deltaBase) SetType(t plumbing.ObjectType) { b.typ = t }
+func (b *deltaBase) Size() int64                   { return int64(len(b.content)) }
+func (b *deltaBase) SetSize(int64)                 {}
+
+func (b *deltaBase) Reader() (io.ReadCloser, error) {
+	return io.NopCloser(bytes.NewReader(b.content)), nil
+}
+
+func (b *deltaBase) Writer() (io.WriteCloser, error) {
+	return nil, errors.New("delta base is read-only")
+}
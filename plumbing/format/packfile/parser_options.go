@@ -1,6 +1,7 @@
 package packfile
 
 import (
+	"github.com/go-git/go-git/v6/plumbing/cache"
 	"github.com/go-git/go-git/v6/plumbing/storer"
 )
 
@@ -25,3 +26,15 @@ func WithScannerObservers(ob ...Observer) ParserOption {
 		p.observers = ob
 	}
 }
+
+// WithDeltaBaseCache sets the cache of the contents of the delta bases,
+// keyed by their hash. The bases resolved while parsing are added to it, and
+// the ones found in it aren't read again from the storage. Sharing the same
+// cache between parsers, such as across the fetches of a process, allows to
+// reuse the bases already resolved by other packfiles, see
+// cache.NewObjectLRU for a size-bounded one.
+func WithDeltaBaseCache(c cache.Object) ParserOption {
+	return func(p *Parser) {
+		p.baseCache = c
+	}
+}
@@ -128,6 +128,47 @@ func TestThinPack(t *testing.T) {
 	assert.NoError(t, err)
 }
 
+// thinPackRepository returns a repository with the objects the thin pack
+// fixture is based on.
+func thinPackRepository(tb testing.TB) *git.Repository {
+	r, err := git.PlainInit(tb.TempDir(), true)
+	assert.NoError(tb, err)
+
+	f := fixtures.ByURL("https://github.com/spinnaker/spinnaker.git").One()
+	w, err := r.Storer.(storer.PackfileWriter).PackfileWriter()
+	assert.NoError(tb, err)
+	_, err = io.Copy(w, f.Packfile())
+	assert.NoError(tb, err)
+	assert.NoError(tb, w.Close())
+
+	return r
+}
+
+func TestParserDeltaBaseCache(t *testing.T) {
+	thinpack := fixtures.ByTag("thinpack").One()
+	c := cache.NewObjectLRUDefault()
+
+	r := thinPackRepository(t)
+	parser := packfile.NewParser(thinpack.Packfile(), packfile.WithStorage(r.Storer),
+		packfile.WithDeltaBaseCache(c))
+	_, err := parser.Parse()
+	assert.NoError(t, err)
+
+	// The bases resolved from the repository are kept in the cache, so the
+	// thin pack can be resolved without them.
+	r, err = git.PlainInit(t.TempDir(), true)
+	assert.NoError(t, err)
+
+	parser = packfile.NewParser(thinpack.Packfile(), packfile.WithStorage(r.Storer),
+		packfile.WithDeltaBaseCache(c))
+	h, err := parser.Parse()
+	assert.NoError(t, err)
+	assert.Equal(t, plumbing.NewHash("1288734cbe0b95892e663221d94b95de1f5d7be8"), h)
+
+	_, err = r.Storer.EncodedObject(plumbing.CommitObject, plumbing.NewHash(thinpack.Head))
+	assert.NoError(t, err)
+}
+
 func TestResolveExternalRefsInThinPack(t *testing.T) {
 	extRefsThinPack := fixtures.ByTag("codecommit").One().Packfile()
 
@@ -203,6 +244,37 @@ func BenchmarkParse(b *testing.B) {
 	}
 }
 
+// BenchmarkParseThinPackDeltaBaseCache parses the same thin pack back to
+// back, as repeated fetches do, with and without a shared delta base cache.
+func BenchmarkParseThinPackDeltaBaseCache(b *testing.B) {
+	thinpack := fixtures.ByTag("thinpack").One()
+	r := thinPackRepository(b)
+
+	// The objects of the storage aren't cached, so that the bases are
+	// resolved from the packfile each time unless the delta base cache is
+	// used.
+	fs := r.Storer.(*filesystem.Storage).Filesystem()
+	st := filesystem.NewStorage(fs, cache.NewObjectLRU(0))
+
+	b.Run("without cache", func(b *testing.B) {
+		benchmarkParseThinPack(b, thinpack, packfile.WithStorage(st))
+	})
+	b.Run("with cache", func(b *testing.B) {
+		benchmarkParseThinPack(b, thinpack, packfile.WithStorage(st),
+			packfile.WithDeltaBaseCache(cache.NewObjectLRUDefault()))
+	})
+}
+
+func benchmarkParseThinPack(b *testing.B, f *fixtures.Fixture, opts ...packfile.ParserOption) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		parser := packfile.NewParser(f.Packfile(), opts...)
+		if _, err := parser.Parse(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
 type observerObject struct {
 	hash   string
 	otype  plumbing.ObjectType
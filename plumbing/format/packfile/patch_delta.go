@@ -143,6 +143,12 @@ func ReaderFromDelta(base plumbing.EncodedObject, deltaRC io.Reader) (io.ReadClo
 		baseBuf := bufio.NewReader(baseRd)
 		basePos := uint(0)
 
+		// The copy buffer is reused across instructions, so copy-heavy
+		// deltas are patched in constant memory.
+		buf := sync.GetByteSlice()
+		defer sync.PutByteSlice(buf)
+		lr := &io.LimitedReader{}
+
 		for {
 			cmd, err := deltaBuf.ReadByte()
 			if err == io.EOF {
@@ -202,7 +208,8 @@ func ReaderFromDelta(base plumbing.EncodedObject, deltaRC io.Reader) (io.ReadClo
 					basePos += uint(n)
 					discard -= uint(n)
 				}
-				if _, err := io.Copy(dstWr, io.LimitReader(baseBuf, int64(sz))); err != nil {
+				lr.R, lr.N = baseBuf, int64(sz)
+				if _, err := io.CopyBuffer(dstWr, lr, *buf); err != nil {
 					_ = dstWr.CloseWithError(err)
 					return
 				}
@@ -215,7 +222,8 @@ func ReaderFromDelta(base plumbing.EncodedObject, deltaRC io.Reader) (io.ReadClo
 					_ = dstWr.CloseWithError(ErrInvalidDelta)
 					return
 				}
-				if _, err := io.Copy(dstWr, io.LimitReader(deltaBuf, int64(sz))); err != nil {
+				lr.R, lr.N = deltaBuf, int64(sz)
+				if _, err := io.CopyBuffer(dstWr, lr, *buf); err != nil {
 					_ = dstWr.CloseWithError(err)
 					return
 				}
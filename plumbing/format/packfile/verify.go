@@ -0,0 +1,511 @@
+package packfile
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/go-git/go-git/v6/plumbing/cache"
+	"github.com/go-git/go-git/v6/plumbing/filemode"
+	"github.com/go-git/go-git/v6/plumbing/format/idxfile"
+)
+
+var (
+	// ErrHashMismatch is reported by Verify when the hash of an object
+	// doesn't match the one stored in the index for its offset.
+	ErrHashMismatch = errors.New("object hash does not match the index")
+	// ErrCRC32Mismatch is reported by Verify when the CRC32 of an object
+	// doesn't match the one stored in the index.
+	ErrCRC32Mismatch = errors.New("object CRC32 does not match the index")
+	// ErrObjectNotIndexed is reported by Verify for the objects of the
+	// packfile missing from the index.
+	ErrObjectNotIndexed = errors.New("object missing from the index")
+	// ErrOffsetOutOfRange is reported by Verify for the index entries with an
+	// offset outside of the packfile.
+	ErrOffsetOutOfRange = errors.New("index offset outside of the packfile")
+	// ErrOffsetNotObject is reported by Verify for the index entries with an
+	// offset not pointing to the start of an object.
+	ErrOffsetNotObject = errors.New("index offset does not point to an object")
+	// ErrObjectCountMismatch is reported by Verify when the index and the
+	// packfile don't have the same number of objects.
+	ErrObjectCountMismatch = errors.New("index and packfile object counts differ")
+	// ErrDeltaBaseNotFound is reported by Verify for the deltas whose base
+	// is not in the packfile, or could not be read.
+	ErrDeltaBaseNotFound = errors.New("delta base not found")
+	// ErrMalformedObject is reported by Verify for the objects that cannot be
+	// read or parsed, like commits without tree or trees with invalid modes.
+	ErrMalformedObject = errors.New("malformed object")
+	// ErrTreeNotSorted is reported by Verify for the trees with entries not
+	// sorted as git does, or with duplicated entries.
+	ErrTreeNotSorted = errors.New("tree entries not sorted")
+)
+
+// VerifyProblem is an integrity problem found by Verify.
+type VerifyProblem struct {
+	// Offset is the offset in the packfile of the object, or the offset
+	// stored in the index for the problems of the index entries. It is -1
+	// for the problems of the whole packfile.
+	Offset int64
+	// Hash is the hash of the object, when known.
+	Hash plumbing.Hash
+	// Err describes the problem.
+	Err error
+}
+
+// Error returns a text representation of the problem.
+func (p *VerifyProblem) Error() string {
+	switch {
+	case p.Offset < 0:
+		return p.Err.Error()
+	case p.Hash.IsZero():
+		return fmt.Sprintf("object at offset %d: %s", p.Offset, p.Err)
+	default:
+		return fmt.Sprintf("object %s at offset %d: %s", p.Hash, p.Offset, p.Err)
+	}
+}
+
+// Unwrap returns the error describing the problem.
+func (p *VerifyProblem) Unwrap() error {
+	return p.Err
+}
+
+// Verify checks the integrity of a packfile and its index, beyond the
+// checksum of the packfile. Every object is inflated, with its deltas
+// resolved, and hashed to check it matches the hash stored in the index for
+// its offset. The delta bases must be in the packfile, the index entries must
+// point to the objects of the packfile, and the commits, trees and tags must
+// be well formed, with the tree entries sorted as git does.
+//
+// All the problems found are returned instead of stopping at the first one.
+// The returned error is only set when the verification could not be done,
+// like when reading the index fails. The packfile is read into memory when r
+// is not an io.ReadSeeker.
+func Verify(r io.Reader, idx idxfile.Index) ([]*VerifyProblem, error) {
+	rs, ok := r.(io.ReadSeeker)
+	if !ok {
+		data, err := io.ReadAll(r)
+		if err != nil {
+			return nil, err
+		}
+
+		rs = bytes.NewReader(data)
+	}
+
+	size, err := rs.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := rs.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	v := &verifier{
+		scanner:  NewScanner(rs),
+		idx:      idx,
+		size:     size,
+		cache:    cache.NewBufferLRUDefault(),
+		byOffset: make(map[int64]*verifyObject),
+		byHash:   make(map[plumbing.Hash]*verifyObject),
+	}
+
+	complete := v.scan()
+	v.resolve()
+
+	if err := v.checkObjects(); err != nil {
+		return nil, err
+	}
+
+	if err := v.checkIndex(complete); err != nil {
+		return nil, err
+	}
+
+	return v.problems, nil
+}
+
+type verifyObject struct {
+	ObjectHeader
+	// diskSize is the size of the object as stored, the size of the delta
+	// for the deltas, as Size becomes the size of the resolved object.
+	diskSize int64
+	// resolved is set once the hash of the object is known, and failed when
+	// it cannot be, the object having a problem.
+	resolved, failed bool
+}
+
+type verifier struct {
+	scanner  *Scanner
+	idx      idxfile.Index
+	size     int64
+	cache    *cache.BufferLRU
+	objects  []*verifyObject
+	byOffset map[int64]*verifyObject
+	byHash   map[plumbing.Hash]*verifyObject
+	problems []*VerifyProblem
+}
+
+func (v *verifier) report(offset int64, h plumbing.Hash, err error) {
+	v.problems = append(v.problems, &VerifyProblem{Offset: offset, Hash: h, Err: err})
+}
+
+// scan reads the object headers of the packfile, returning whether it was
+// read until its end. The hashes of the non-delta objects are known once
+// scanned.
+func (v *verifier) scan() bool {
+	for v.scanner.Scan() {
+		data := v.scanner.Data()
+		if data.Section != ObjectSection {
+			continue
+		}
+
+		oh := data.Value().(ObjectHeader)
+		o := &verifyObject{ObjectHeader: oh, diskSize: oh.Size}
+		v.objects = append(v.objects, o)
+		v.byOffset[o.Offset] = o
+
+		if !o.Type.IsDelta() {
+			o.resolved = true
+			v.byHash[o.Hash] = o
+		}
+	}
+
+	if err := v.scanner.Error(); err != nil {
+		v.report(-1, plumbing.ZeroHash, err)
+		return false
+	}
+
+	return true
+}
+
+// resolve computes the hashes of the deltas. The deltas with a base known by
+// hash only are retried until no more of them can be resolved.
+func (v *verifier) resolve() {
+	pending := v.objects
+	for {
+		var next []*verifyObject
+		for _, o := range pending {
+			if o.resolved || o.failed {
+				continue
+			}
+
+			if o.diskType == plumbing.REFDeltaObject && v.byHash[o.Reference] == nil {
+				next = append(next, o)
+				continue
+			}
+
+			if _, err := v.content(o); err != nil {
+				v.fail(o, err)
+			}
+		}
+
+		if len(next) == len(pending) || len(next) == 0 {
+			pending = next
+			break
+		}
+
+		pending = next
+	}
+
+	for _, o := range pending {
+		v.fail(o, fmt.Errorf("%w: %s", ErrDeltaBaseNotFound, o.Reference))
+	}
+}
+
+func (v *verifier) fail(o *verifyObject, err error) {
+	if o.failed {
+		return
+	}
+
+	o.failed = true
+	v.report(o.Offset, plumbing.ZeroHash, err)
+}
+
+// content returns the inflated content of the object, resolving it, and its
+// bases, if it is a delta.
+func (v *verifier) content(o *verifyObject) ([]byte, error) {
+	if data, ok := v.cache.Get(o.Offset); ok {
+		return data, nil
+	}
+
+	var buf bytes.Buffer
+	if err := v.scanner.inflateContent(o.ContentOffset, &buf); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrMalformedObject, err)
+	}
+
+	if int64(buf.Len()) != o.diskSize {
+		return nil, fmt.Errorf("%w: inflated size %d, expected %d", ErrMalformedObject, buf.Len(), o.diskSize)
+	}
+
+	data := buf.Bytes()
+	if o.diskType.IsDelta() {
+		base, err := v.base(o)
+		if err != nil {
+			return nil, err
+		}
+
+		baseData, err := v.content(base)
+		if err != nil {
+			return nil, err
+		}
+
+		if data, err = PatchDelta(baseData, data); err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrMalformedObject, err)
+		}
+
+		if !o.resolved {
+			o.Type, o.Size = base.Type, int64(len(data))
+			o.Hash = plumbing.ComputeHash(o.Type, data)
+			o.resolved = true
+			v.byHash[o.Hash] = o
+		}
+	}
+
+	v.cache.Put(o.Offset, data)
+	return data, nil
+}
+
+// base returns the resolved base of the delta.
+func (v *verifier) base(o *verifyObject) (*verifyObject, error) {
+	var base *verifyObject
+	switch o.diskType {
+	case plumbing.OFSDeltaObject:
+		base = v.byOffset[o.OffsetReference]
+		if base == nil {
+			return nil, fmt.Errorf("%w: no object at offset %d", ErrDeltaBaseNotFound, o.OffsetReference)
+		}
+	case plumbing.REFDeltaObject:
+		base = v.byHash[o.Reference]
+		if base == nil {
+			return nil, fmt.Errorf("%w: %s", ErrDeltaBaseNotFound, o.Reference)
+		}
+	}
+
+	if base.failed {
+		return nil, fmt.Errorf("%w: base at offset %d is invalid", ErrDeltaBaseNotFound, base.Offset)
+	}
+
+	return base, nil
+}
+
+// checkObjects checks the resolved objects against the index, and parses
+// their contents.
+func (v *verifier) checkObjects() error {
+	for _, o := range v.objects {
+		if !o.resolved || o.failed {
+			continue
+		}
+
+		h, err := v.idx.FindHash(o.Offset)
+		switch {
+		case errors.Is(err, plumbing.ErrObjectNotFound):
+			v.report(o.Offset, o.Hash, ErrObjectNotIndexed)
+		case err != nil:
+			return err
+		case h != o.Hash:
+			v.report(o.Offset, o.Hash, fmt.Errorf("%w: index has %s", ErrHashMismatch, h))
+		default:
+			if crc, err := v.idx.FindCRC32(h); err == nil && crc != o.Crc32 {
+				v.report(o.Offset, o.Hash, ErrCRC32Mismatch)
+			}
+		}
+
+		if o.Type == plumbing.BlobObject {
+			continue
+		}
+
+		data, err := v.content(o)
+		if err != nil {
+			v.report(o.Offset, o.Hash, err)
+			continue
+		}
+
+		if err := verifyContent(o.Type, data, o.Hash.Size()); err != nil {
+			v.report(o.Offset, o.Hash, err)
+		}
+	}
+
+	return nil
+}
+
+// checkIndex checks the offsets of the index entries, and its object count
+// when the whole packfile could be scanned.
+func (v *verifier) checkIndex(complete bool) error {
+	iter, err := v.idx.Entries()
+	if err != nil {
+		return err
+	}
+
+	defer iter.Close()
+
+	// The objects are between the 12 bytes header and the trailing checksum.
+	end := v.size - int64(v.scanner.objectIDSize)
+	for {
+		e, err := iter.Next()
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			return err
+		}
+
+		offset := int64(e.Offset)
+		switch {
+		case offset < 12 || offset >= end:
+			v.report(offset, e.Hash, ErrOffsetOutOfRange)
+		case v.byOffset[offset] == nil && complete:
+			v.report(offset, e.Hash, ErrOffsetNotObject)
+		}
+	}
+
+	count, err := v.idx.Count()
+	if err != nil {
+		return err
+	}
+
+	if complete && count != int64(len(v.objects)) {
+		v.report(-1, plumbing.ZeroHash, fmt.Errorf("%w: %d in the index, %d in the packfile",
+			ErrObjectCountMismatch, count, len(v.objects)))
+	}
+
+	return nil
+}
+
+// verifyContent checks the content of a commit, tree or tag is well formed.
+func verifyContent(t plumbing.ObjectType, data []byte, idSize int) error {
+	var err error
+	switch t {
+	case plumbing.CommitObject:
+		err = verifyHeaders(data, idSize, []string{"tree"}, "parent", []string{"author", "committer"})
+	case plumbing.TagObject:
+		err = verifyHeaders(data, idSize, []string{"object", "type", "tag"}, "", nil)
+	case plumbing.TreeObject:
+		return verifyTree(data, idSize)
+	}
+
+	if err != nil {
+		return fmt.Errorf("%w: %s: %w", ErrMalformedObject, t, err)
+	}
+
+	return nil
+}
+
+// verifyHeaders checks the object starts with the given headers, followed by
+// any number of the repeated one, and then by the trailing ones.
+func verifyHeaders(data []byte, idSize int, leading []string, repeated string, trailing []string) error {
+	var keys, values []string
+	for len(data) > 0 {
+		var line []byte
+		line, data, _ = bytes.Cut(data, []byte("\n"))
+		if len(line) == 0 {
+			break
+		}
+
+		key, value, _ := bytes.Cut(line, []byte(" "))
+		keys, values = append(keys, string(key)), append(values, string(value))
+	}
+
+	var i int
+	expect := func(name string) error {
+		if i >= len(keys) || keys[i] != name {
+			return fmt.Errorf("missing %s header", name)
+		}
+
+		i++
+		return verifyHeader(name, values[i-1], idSize)
+	}
+
+	for _, name := range leading {
+		if err := expect(name); err != nil {
+			return err
+		}
+	}
+
+	for repeated != "" && i < len(keys) && keys[i] == repeated {
+		if err := expect(repeated); err != nil {
+			return err
+		}
+	}
+
+	for _, name := range trailing {
+		if err := expect(name); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func verifyHeader(name, value string, idSize int) error {
+	switch name {
+	case "tree", "parent", "object":
+		if len(value) != idSize*2 || !plumbing.IsHash(value) {
+			return fmt.Errorf("invalid %s %q", name, value)
+		}
+	case "type":
+		if t, err := plumbing.ParseObjectType(value); err != nil || !t.Valid() || t.IsDelta() {
+			return fmt.Errorf("invalid type %q", value)
+		}
+	case "tag":
+		if value == "" {
+			return fmt.Errorf("empty tag name")
+		}
+	}
+
+	return nil
+}
+
+// verifyTree checks the tree entries are well formed and sorted as git does,
+// the directories being compared as if their names ended with a slash.
+func verifyTree(data []byte, idSize int) error {
+	var prev []byte
+	for len(data) > 0 {
+		mode, rest, ok := bytes.Cut(data, []byte(" "))
+		if !ok {
+			return fmt.Errorf("%w: tree: truncated entry", ErrMalformedObject)
+		}
+
+		name, rest, ok := bytes.Cut(rest, []byte{0})
+		if !ok || len(rest) < idSize {
+			return fmt.Errorf("%w: tree: truncated entry", ErrMalformedObject)
+		}
+
+		data = rest[idSize:]
+
+		m, err := strconv.ParseUint(string(mode), 8, 32)
+		if err != nil || !verifyTreeMode(filemode.FileMode(m)) {
+			return fmt.Errorf("%w: tree: invalid mode %q of %q", ErrMalformedObject, mode, name)
+		}
+
+		if len(name) == 0 || string(name) == "." || string(name) == ".." ||
+			bytes.IndexByte(name, '/') >= 0 {
+			return fmt.Errorf("%w: tree: invalid name %q", ErrMalformedObject, name)
+		}
+
+		key := name
+		if filemode.FileMode(m) == filemode.Dir {
+			key = append(name[:len(name):len(name)], '/')
+		}
+
+		if prev != nil && bytes.Compare(prev, key) >= 0 {
+			return fmt.Errorf("%w: %q after %q", ErrTreeNotSorted, key, prev)
+		}
+
+		prev = key
+	}
+
+	return nil
+}
+
+func verifyTreeMode(m filemode.FileMode) bool {
+	switch m {
+	case filemode.Dir, filemode.Regular, filemode.Deprecated,
+		filemode.Executable, filemode.Symlink, filemode.Submodule:
+		return true
+	}
+
+	return false
+}
@@ -0,0 +1,187 @@
+package packfile_test
+
+import (
+	"bytes"
+	"compress/zlib"
+	"crypto"
+	"encoding/binary"
+	"hash/crc32"
+	"io"
+	"testing"
+
+	fixtures "github.com/go-git/go-git-fixtures/v5"
+	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/go-git/go-git/v6/plumbing/format/idxfile"
+	"github.com/go-git/go-git/v6/plumbing/format/packfile"
+	"github.com/go-git/go-git/v6/plumbing/hash"
+	"github.com/go-git/go-git/v6/storage/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// packIndex parses the packfile and returns its index.
+func packIndex(t *testing.T, pack []byte) *idxfile.MemoryIndex {
+	w := &idxfile.Writer{}
+	_, err := packfile.NewParser(bytes.NewReader(pack), packfile.WithScannerObservers(w)).Parse()
+	require.NoError(t, err)
+
+	idx, err := w.Index()
+	require.NoError(t, err)
+	return idx
+}
+
+func TestVerify(t *testing.T) {
+	t.Parallel()
+
+	for _, f := range []*fixtures.Fixture{
+		fixtures.Basic().ByTag("ofs-delta").One(),
+		fixtures.Basic().ByTag("ref-delta").One(),
+	} {
+		pack, err := io.ReadAll(f.Packfile())
+		require.NoError(t, err)
+
+		problems, err := packfile.Verify(bytes.NewReader(pack), packIndex(t, pack))
+		require.NoError(t, err)
+		assert.Empty(t, problems)
+
+		// Also when the packfile is not seekable.
+		problems, err = packfile.Verify(io.MultiReader(bytes.NewReader(pack)), getIndexFromIdxFile(f.Idx()))
+		require.NoError(t, err)
+		assert.Empty(t, problems)
+	}
+}
+
+func TestVerifyIndexProblems(t *testing.T) {
+	t.Parallel()
+
+	f := fixtures.Basic().One()
+	pack, err := io.ReadAll(f.Packfile())
+	require.NoError(t, err)
+
+	idx := getIndexFromIdxFile(f.Idx()).(*idxfile.MemoryIndex)
+
+	// The first entry gets the hash of a missing object, the second one a
+	// CRC32 not matching its object, and the third one an offset outside of
+	// the packfile.
+	require.GreaterOrEqual(t, len(idx.Names), 3)
+
+	wrongHash, _ := plumbing.FromBytes(idx.Names[0][:crypto.SHA1.Size()])
+	idx.Names[0][crypto.SHA1.Size()-1] ^= 0xff
+	idx.CRC32[1][0] ^= 0xff
+	moved := int64(binary.BigEndian.Uint32(idx.Offset32[2]))
+	binary.BigEndian.PutUint32(idx.Offset32[2], uint32(len(pack)+10))
+
+	problems, err := packfile.Verify(bytes.NewReader(pack), idx)
+	require.NoError(t, err)
+	require.Len(t, problems, 4)
+
+	var hashMismatch, crcMismatch, outOfRange, notIndexed bool
+	for _, p := range problems {
+		switch {
+		case assert.ObjectsAreEqual(p.Hash, wrongHash):
+			hashMismatch = assert.ErrorIs(t, p, packfile.ErrHashMismatch)
+		case p.Offset == int64(len(pack)+10):
+			outOfRange = assert.ErrorIs(t, p, packfile.ErrOffsetOutOfRange)
+		case p.Offset == moved:
+			notIndexed = assert.ErrorIs(t, p, packfile.ErrObjectNotIndexed)
+		default:
+			crcMismatch = assert.ErrorIs(t, p, packfile.ErrCRC32Mismatch)
+		}
+	}
+
+	assert.True(t, hashMismatch)
+	assert.True(t, crcMismatch)
+	assert.True(t, outOfRange)
+	assert.True(t, notIndexed)
+}
+
+func TestVerifyMalformedObjects(t *testing.T) {
+	t.Parallel()
+
+	storage := memory.NewStorage()
+	store := func(typ plumbing.ObjectType, content string) plumbing.Hash {
+		obj := storage.NewEncodedObject()
+		obj.SetType(typ)
+		_, err := obj.(*plumbing.MemoryObject).Write([]byte(content))
+		require.NoError(t, err)
+
+		h, err := storage.SetEncodedObject(obj)
+		require.NoError(t, err)
+		return h
+	}
+
+	blob := store(plumbing.BlobObject, "foo")
+	entry := func(mode, name string) string {
+		return mode + " " + name + "\x00" + string(blob.Bytes())
+	}
+
+	hashes := []plumbing.Hash{
+		blob,
+		store(plumbing.TreeObject, entry("100644", "b")+entry("100644", "a")),
+		store(plumbing.TreeObject, entry("40000", "a")+entry("100644", "a.go")),
+		store(plumbing.TreeObject, entry("100644", "a.go")+entry("40000", "a")),
+		store(plumbing.TreeObject, entry("123456", "a")),
+		store(plumbing.CommitObject, "author foo <foo@foo.foo> 0 +0000\n\nfoo"),
+		store(plumbing.CommitObject, "tree "+blob.String()+"\nauthor foo <foo@foo.foo> 0 +0000\n"+
+			"committer foo <foo@foo.foo> 0 +0000\n\nfoo"),
+		store(plumbing.TagObject, "object "+blob.String()+"\ntype foo\ntag v1\n\nfoo"),
+	}
+
+	var buf bytes.Buffer
+	_, err := packfile.NewEncoder(&buf, storage, false).Encode(hashes, 0)
+	require.NoError(t, err)
+
+	problems, err := packfile.Verify(bytes.NewReader(buf.Bytes()), packIndex(t, buf.Bytes()))
+	require.NoError(t, err)
+
+	found := make(map[plumbing.Hash]error)
+	for _, p := range problems {
+		found[p.Hash] = p
+	}
+
+	// Directories are sorted as if their name ended with a slash, so "a.go"
+	// comes before "a".
+	assert.Len(t, found, 5)
+	assert.ErrorIs(t, found[hashes[1]], packfile.ErrTreeNotSorted)
+	assert.ErrorIs(t, found[hashes[2]], packfile.ErrTreeNotSorted)
+	assert.ErrorIs(t, found[hashes[4]], packfile.ErrMalformedObject)
+	assert.ErrorIs(t, found[hashes[5]], packfile.ErrMalformedObject)
+	assert.ErrorIs(t, found[hashes[7]], packfile.ErrMalformedObject)
+}
+
+func TestVerifyMissingDeltaBase(t *testing.T) {
+	t.Parallel()
+
+	// A thin packfile with a single delta, whose base isn't in it.
+	var obj bytes.Buffer
+	base := plumbing.NewHash("6ecf0ef2c2dffb796033e5a02219af86ec6584e5")
+	delta := []byte{3, 3, 3, 'f', 'o', 'o'}
+	obj.WriteByte(byte(plumbing.REFDeltaObject)<<4 | byte(len(delta)))
+	obj.Write(base.Bytes())
+	zw := zlib.NewWriter(&obj)
+	_, err := zw.Write(delta)
+	require.NoError(t, err)
+	require.NoError(t, zw.Close())
+
+	var pack bytes.Buffer
+	pack.WriteString("PACK")
+	require.NoError(t, binary.Write(&pack, binary.BigEndian, []uint32{2, 1}))
+	pack.Write(obj.Bytes())
+
+	h := hash.New(crypto.SHA1)
+	h.Write(pack.Bytes())
+	pack.Write(h.Sum(nil))
+
+	w := &idxfile.Writer{}
+	require.NoError(t, w.OnHeader(1))
+	w.Add(plumbing.ComputeHash(plumbing.BlobObject, []byte("foo")), 12, crc32.ChecksumIEEE(obj.Bytes()))
+	require.NoError(t, w.OnFooter(plumbing.ZeroHash))
+	idx, err := w.Index()
+	require.NoError(t, err)
+
+	problems, err := packfile.Verify(bytes.NewReader(pack.Bytes()), idx)
+	require.NoError(t, err)
+	require.Len(t, problems, 1)
+	assert.ErrorIs(t, problems[0], packfile.ErrDeltaBaseNotFound)
+	assert.Equal(t, int64(12), problems[0].Offset)
+}
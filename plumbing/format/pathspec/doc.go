@@ -0,0 +1,26 @@
+// Package pathspec implements the parsing and matching of git pathspecs, the
+// patterns used by commands like status, add or checkout to limit the paths
+// they work on. See https://git-scm.com/docs/gitglossary#Documentation/gitglossary.txt-aiddefpathspecapathspec
+//
+// A pathspec without magic matches a path when it is equal to it, when it is
+// one of its leading directories, or when it matches it as a shell glob in
+// which the wildcards match slashes too, so "*.go" matches "a/b.go".
+//
+// The supported subset of the magic, in its long form ":(word,...)pattern"
+// and in its short form where it exists, is:
+//
+//   - top, short form ":/": the pattern is relative to the root of the
+//     worktree. As go-git has no concept of current directory, every pattern
+//     is, so it is accepted for compatibility only.
+//   - exclude, short forms ":!" and ":^": the paths matched are removed from
+//     the ones matched by the other pathspecs, or from every path when there
+//     are only excluding pathspecs.
+//   - glob: the pattern is matched as a glob in which the wildcards do not
+//     match slashes, and where "**" matches any number of directories, as in
+//     .gitignore files.
+//   - icase: the pattern is matched ignoring the case.
+//   - literal: the wildcards of the pattern are matched literally.
+//
+// Any other magic word, like attr, results in ErrUnknownMagic, instead of
+// being ignored.
+package pathspec
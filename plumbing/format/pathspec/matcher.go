@@ -0,0 +1,49 @@
+package pathspec
+
+// Matcher matches paths against a list of pathspecs. A path matches when it
+// matches any of the pathspecs, or when there are none, and none of the
+// excluding pathspecs.
+type Matcher struct {
+	include []*Pathspec
+	exclude []*Pathspec
+}
+
+// NewMatcher parses the given pathspecs and returns a Matcher for them. An
+// empty list matches every path.
+func NewMatcher(specs []string) (*Matcher, error) {
+	m := &Matcher{}
+	for _, spec := range specs {
+		p, err := Parse(spec)
+		if err != nil {
+			return nil, err
+		}
+
+		if p.Exclude {
+			m.exclude = append(m.exclude, p)
+		} else {
+			m.include = append(m.include, p)
+		}
+	}
+
+	return m, nil
+}
+
+// Match returns whether the given path, relative to the root of the worktree,
+// is matched by the pathspecs.
+func (m *Matcher) Match(path string) bool {
+	if len(m.include) > 0 && !matchAny(m.include, path) {
+		return false
+	}
+
+	return !matchAny(m.exclude, path)
+}
+
+func matchAny(ps []*Pathspec, path string) bool {
+	for _, p := range ps {
+		if p.Match(path) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,33 @@
+package pathspec
+
+func (s *PathspecSuite) TestMatcher() {
+	m, err := NewMatcher([]string{"src", "*.md", ":(exclude)src/vendor", ":!*.txt"})
+	s.Require().NoError(err)
+
+	s.True(m.Match("src/main.go"))
+	s.True(m.Match("docs/README.md"))
+	s.False(m.Match("src/vendor/lib.go"))
+	s.False(m.Match("src/notes.txt"))
+	s.False(m.Match("main.go"))
+}
+
+func (s *PathspecSuite) TestMatcherExcludeOnly() {
+	m, err := NewMatcher([]string{":(exclude,glob)**/*_test.go"})
+	s.Require().NoError(err)
+
+	s.True(m.Match("main.go"))
+	s.True(m.Match("cmd/main.go"))
+	s.False(m.Match("cmd/main_test.go"))
+}
+
+func (s *PathspecSuite) TestMatcherEmpty() {
+	m, err := NewMatcher(nil)
+	s.Require().NoError(err)
+
+	s.True(m.Match("main.go"))
+}
+
+func (s *PathspecSuite) TestMatcherError() {
+	_, err := NewMatcher([]string{"foo", ":(bar)baz"})
+	s.ErrorIs(err, ErrUnknownMagic)
+}
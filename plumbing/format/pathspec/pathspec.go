@@ -0,0 +1,248 @@
+package pathspec
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+var (
+	// ErrUnknownMagic is returned by Parse when a pathspec uses a magic word
+	// that is not supported.
+	ErrUnknownMagic = errors.New("unknown pathspec magic")
+	// ErrInvalidPathspec is returned by Parse when a pathspec is malformed.
+	ErrInvalidPathspec = errors.New("invalid pathspec")
+)
+
+// Pathspec is a parsed pathspec.
+type Pathspec struct {
+	// Pattern is the pattern of the pathspec, without its magic.
+	Pattern string
+	// Top is set by the "top" magic, ":/".
+	Top bool
+	// Exclude is set by the "exclude" magic, ":!" or ":^".
+	Exclude bool
+	// Glob is set by the "glob" magic.
+	Glob bool
+	// ICase is set by the "icase" magic.
+	ICase bool
+	// Literal is set by the "literal" magic.
+	Literal bool
+
+	re *regexp.Regexp
+}
+
+// Parse parses a pathspec, with its magic if any.
+func Parse(spec string) (*Pathspec, error) {
+	p := &Pathspec{}
+
+	pattern, err := p.parseMagic(spec)
+	if err != nil {
+		return nil, err
+	}
+
+	if p.Glob && p.Literal {
+		return nil, fmt.Errorf("%w: %q: glob and literal are incompatible", ErrInvalidPathspec, spec)
+	}
+
+	p.Pattern = strings.TrimPrefix(filepath.ToSlash(pattern), "./")
+	if p.re, err = p.compile(); err != nil {
+		return nil, fmt.Errorf("%w: %q: %w", ErrInvalidPathspec, spec, err)
+	}
+
+	return p, nil
+}
+
+// parseMagic sets the magic of the pathspec and returns its pattern.
+func (p *Pathspec) parseMagic(spec string) (string, error) {
+	if !strings.HasPrefix(spec, ":") {
+		return spec, nil
+	}
+
+	rest := spec[1:]
+	if strings.HasPrefix(rest, "(") {
+		end := strings.IndexByte(rest, ')')
+		if end < 0 {
+			return "", fmt.Errorf("%w: %q: missing ')'", ErrInvalidPathspec, spec)
+		}
+
+		for _, word := range strings.Split(rest[1:end], ",") {
+			if err := p.setMagic(strings.TrimSpace(word)); err != nil {
+				return "", fmt.Errorf("%w: %q", err, spec)
+			}
+		}
+
+		return rest[end+1:], nil
+	}
+
+	for len(rest) > 0 {
+		switch rest[0] {
+		case '/':
+			p.Top = true
+		case '!', '^':
+			p.Exclude = true
+		case ':':
+			return rest[1:], nil
+		default:
+			return rest, nil
+		}
+
+		rest = rest[1:]
+	}
+
+	return rest, nil
+}
+
+func (p *Pathspec) setMagic(word string) error {
+	switch word {
+	case "top":
+		p.Top = true
+	case "exclude":
+		p.Exclude = true
+	case "glob":
+		p.Glob = true
+	case "icase":
+		p.ICase = true
+	case "literal":
+		p.Literal = true
+	case "":
+	default:
+		return fmt.Errorf("%w %q", ErrUnknownMagic, word)
+	}
+
+	return nil
+}
+
+// Match returns whether the pathspec matches the given path, relative to the
+// root of the worktree. A pathspec matching a directory matches every path in
+// it.
+func (p *Pathspec) Match(path string) bool {
+	return p.re.MatchString(filepath.ToSlash(path))
+}
+
+// compile returns the regular expression matching the paths of the pathspec.
+func (p *Pathspec) compile() (*regexp.Regexp, error) {
+	var b strings.Builder
+	if p.ICase {
+		b.WriteString("(?i)")
+	}
+
+	b.WriteString("^")
+
+	pattern := p.Pattern
+	if pattern == "" || pattern == "." {
+		return regexp.Compile(b.String())
+	}
+
+	// A trailing slash matches the content of a directory only.
+	dir := strings.HasSuffix(pattern, "/")
+	pattern = strings.TrimSuffix(pattern, "/")
+
+	if p.Literal {
+		b.WriteString(regexp.QuoteMeta(pattern))
+	} else {
+		p.writeGlob(&b, pattern)
+	}
+
+	if dir {
+		b.WriteString("/")
+	} else {
+		b.WriteString("(?:$|/)")
+	}
+
+	return regexp.Compile(b.String())
+}
+
+// writeGlob writes the regular expression of a glob pattern.
+func (p *Pathspec) writeGlob(b *strings.Builder, pattern string) {
+	star, question := ".*", "."
+	if p.Glob {
+		star, question = "[^/]*", "[^/]"
+	}
+
+	for i := 0; i < len(pattern); i++ {
+		switch pattern[i] {
+		case '\\':
+			if i+1 < len(pattern) {
+				i++
+			}
+
+			b.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+		case '*':
+			if !p.Glob || !strings.HasPrefix(pattern[i:], "**") ||
+				(i > 0 && pattern[i-1] != '/') {
+				b.WriteString(star)
+				continue
+			}
+
+			// A "**" component matches any number of directories.
+			switch rest := pattern[i+2:]; {
+			case rest == "":
+				b.WriteString(".*")
+				i++
+			case rest[0] == '/':
+				b.WriteString("(?:.*/)?")
+				i += 2
+			default:
+				b.WriteString(star)
+			}
+		case '?':
+			b.WriteString(question)
+		case '[':
+			end := classEnd(pattern, i)
+			if end < 0 {
+				b.WriteString(`\[`)
+				continue
+			}
+
+			writeClass(b, pattern[i+1:end])
+			i = end
+		default:
+			// Written byte by byte, multi-byte characters stay valid.
+			b.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+		}
+	}
+}
+
+// classEnd returns the index of the bracket closing the character class
+// opened at i, or -1 if it is not closed.
+func classEnd(pattern string, i int) int {
+	j := i + 1
+	if j < len(pattern) && (pattern[j] == '!' || pattern[j] == '^') {
+		j++
+	}
+
+	// A bracket right after the opening one is part of the class.
+	if j < len(pattern) && pattern[j] == ']' {
+		j++
+	}
+
+	end := strings.IndexByte(pattern[j:], ']')
+	if end < 0 {
+		return -1
+	}
+
+	return j + end
+}
+
+// writeClass writes a character class, given without its brackets.
+func writeClass(b *strings.Builder, class string) {
+	b.WriteString("[")
+	if len(class) > 0 && (class[0] == '!' || class[0] == '^') {
+		b.WriteString("^")
+		class = class[1:]
+	}
+
+	for i := 0; i < len(class); i++ {
+		if class[i] == '-' {
+			b.WriteByte('-')
+			continue
+		}
+
+		b.WriteString(regexp.QuoteMeta(class[i : i+1]))
+	}
+
+	b.WriteString("]")
+}
@@ -0,0 +1,104 @@
+package pathspec
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type PathspecSuite struct {
+	suite.Suite
+}
+
+func TestPathspecSuite(t *testing.T) {
+	suite.Run(t, new(PathspecSuite))
+}
+
+func (s *PathspecSuite) TestParseMagic() {
+	for spec, expected := range map[string]Pathspec{
+		"foo":                     {Pattern: "foo"},
+		"./foo":                   {Pattern: "foo"},
+		":/foo":                   {Pattern: "foo", Top: true},
+		":!foo":                   {Pattern: "foo", Exclude: true},
+		":^foo":                   {Pattern: "foo", Exclude: true},
+		":/!:foo":                 {Pattern: "foo", Top: true, Exclude: true},
+		":(exclude)foo":           {Pattern: "foo", Exclude: true},
+		":(glob,icase)*.go":       {Pattern: "*.go", Glob: true, ICase: true},
+		":(top, literal)a*":       {Pattern: "a*", Top: true, Literal: true},
+		":(exclude,glob)**/*.md":  {Pattern: "**/*.md", Exclude: true, Glob: true},
+		":/":                      {Pattern: "", Top: true},
+		"::foo":                   {Pattern: "foo"},
+		":(icase)":                {Pattern: "", ICase: true},
+		":(exclude,top,glob)a/b/": {Pattern: "a/b/", Exclude: true, Top: true, Glob: true},
+	} {
+		p, err := Parse(spec)
+		s.Require().NoError(err, spec)
+
+		p.re = nil
+		s.Equal(expected, *p, spec)
+	}
+}
+
+func (s *PathspecSuite) TestParseErrors() {
+	_, err := Parse(":(attr:foo)bar")
+	s.ErrorIs(err, ErrUnknownMagic)
+
+	_, err = Parse(":(exclude,unknown)bar")
+	s.ErrorIs(err, ErrUnknownMagic)
+	s.ErrorContains(err, `"unknown"`)
+
+	_, err = Parse(":(glob")
+	s.ErrorIs(err, ErrInvalidPathspec)
+
+	_, err = Parse(":(glob,literal)foo")
+	s.ErrorIs(err, ErrInvalidPathspec)
+}
+
+func (s *PathspecSuite) TestMatch() {
+	for _, t := range []struct {
+		spec    string
+		path    string
+		matches bool
+	}{
+		{"foo", "foo", true},
+		{"foo", "foo/bar", true},
+		{"foo", "foobar", false},
+		{"foo", "bar/foo", false},
+		{"foo/", "foo/bar", true},
+		{"foo/", "foo", false},
+		{".", "foo/bar", true},
+		{":/", "foo/bar", true},
+		{"*.go", "main.go", true},
+		{"*.go", "cmd/main.go", true},
+		{"cmd/*", "cmd/a/main.go", true},
+		{"f?o", "f/o", true},
+		{"[fb]oo", "boo", true},
+		{"[!fb]oo", "boo", false},
+		{"[!fb]oo", "zoo", true},
+		{`\*`, "*", true},
+		{`\*`, "a", false},
+		{":(glob)*.go", "main.go", true},
+		{":(glob)*.go", "cmd/main.go", false},
+		{":(glob)cmd/*", "cmd/a/main.go", true},
+		{":(glob)cmd/*.go", "cmd/a/main.go", false},
+		{":(glob)**/*.go", "main.go", true},
+		{":(glob)**/*.go", "cmd/a/main.go", true},
+		{":(glob)cmd/**/main.go", "cmd/main.go", true},
+		{":(glob)cmd/**/main.go", "cmd/a/b/main.go", true},
+		{":(glob)cmd/**", "cmd/a/b/main.go", true},
+		{":(glob)cmd/**", "cmd", false},
+		{":(glob)f?o", "f/o", false},
+		{":(icase)README", "readme", true},
+		{":(icase)README", "docs/readme", false},
+		{":(icase,glob)*.MD", "README.md", true},
+		{"README", "readme", false},
+		{":(literal)*.go", "main.go", false},
+		{":(literal)*.go", "*.go", true},
+		{":(literal)a[b]", "a[b]/c", true},
+		{"ño*", "ñoño", true},
+	} {
+		p, err := Parse(t.spec)
+		s.Require().NoError(err, t.spec)
+		s.Equal(t.matches, p.Match(t.path), "%s %s", t.spec, t.path)
+	}
+}
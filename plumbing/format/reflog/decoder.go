@@ -0,0 +1,119 @@
+package reflog
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-git/v6/plumbing"
+)
+
+// ErrMalformedEntry is returned by Decode when a line of the reflog can't be
+// parsed.
+var ErrMalformedEntry = errors.New("malformed reflog entry")
+
+// A Decoder reads and decodes reflog entries from an input stream.
+type Decoder struct {
+	r *bufio.Reader
+}
+
+// NewDecoder returns a new decoder that reads from r.
+func NewDecoder(r io.Reader) *Decoder {
+	return &Decoder{bufio.NewReader(r)}
+}
+
+// Decode reads the next entry from the stream, returning io.EOF when there
+// are no more entries.
+func (d *Decoder) Decode(e *Entry) error {
+	for {
+		line, err := d.r.ReadString('\n')
+		if err != nil && (err != io.EOF || line == "") {
+			return err
+		}
+
+		line = strings.TrimSuffix(line, "\n")
+		if line == "" {
+			continue
+		}
+
+		return decodeEntry(line, e)
+	}
+}
+
+// DecodeAll reads all the entries from the stream, oldest first.
+func (d *Decoder) DecodeAll() ([]*Entry, error) {
+	var entries []*Entry
+	for {
+		e := &Entry{}
+		err := d.Decode(e)
+		if err == io.EOF {
+			return entries, nil
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		entries = append(entries, e)
+	}
+}
+
+func decodeEntry(line string, e *Entry) error {
+	line, msg, _ := strings.Cut(line, "\t")
+
+	old, line, ok := strings.Cut(line, " ")
+	if !ok {
+		return ErrMalformedEntry
+	}
+
+	new, sig, ok := strings.Cut(line, " ")
+	if !ok {
+		return ErrMalformedEntry
+	}
+
+	var okOld, okNew bool
+	e.Old, okOld = plumbing.FromHex(old)
+	e.New, okNew = plumbing.FromHex(new)
+	if !okOld || !okNew {
+		return ErrMalformedEntry
+	}
+
+	if err := decodeSignature(sig, &e.Committer); err != nil {
+		return err
+	}
+
+	e.Message = msg
+	return nil
+}
+
+func decodeSignature(sig string, s *Signature) error {
+	open := strings.LastIndexByte(sig, '<')
+	close := strings.LastIndexByte(sig, '>')
+	if open == -1 || close < open {
+		return ErrMalformedEntry
+	}
+
+	s.Name = strings.TrimSpace(sig[:open])
+	s.Email = sig[open+1 : close]
+
+	fields := strings.Fields(sig[close+1:])
+	if len(fields) != 2 || len(fields[1]) != 5 {
+		return ErrMalformedEntry
+	}
+
+	ts, err := strconv.ParseInt(fields[0], 10, 64)
+	if err != nil {
+		return ErrMalformedEntry
+	}
+
+	tz, err := time.Parse("-0700", fields[1])
+	if err != nil {
+		return ErrMalformedEntry
+	}
+
+	s.When = time.Unix(ts, 0).In(tz.Location())
+	return nil
+}
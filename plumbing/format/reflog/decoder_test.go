@@ -0,0 +1,94 @@
+package reflog
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const reflogFixture = "" +
+	"0000000000000000000000000000000000000000 b029517f6300c2da0f4b651b8642506cd6aaf45d John Doe <john@example.com> 1257894000 +0200\tcommit (initial): first\n" +
+	"\n" +
+	"b029517f6300c2da0f4b651b8642506cd6aaf45d b8e471f58bcbca63b07bda20e428190409c2db47 <> 1257894060 -0530\t\n" +
+	"b8e471f58bcbca63b07bda20e428190409c2db47 6ecf0ef2c2dffb796033e5a02219af86ec6584e5 John Doe <john@example.com> 1257894120 +0000\tcheckout: moving from master to branch"
+
+func TestDecode(t *testing.T) {
+	d := NewDecoder(strings.NewReader(reflogFixture))
+
+	var e Entry
+	require.NoError(t, d.Decode(&e))
+	assert.Equal(t, plumbing.ZeroHash, e.Old)
+	assert.Equal(t, plumbing.NewHash("b029517f6300c2da0f4b651b8642506cd6aaf45d"), e.New)
+	assert.Equal(t, "John Doe", e.Committer.Name)
+	assert.Equal(t, "john@example.com", e.Committer.Email)
+	assert.Equal(t, int64(1257894000), e.Committer.When.Unix())
+	assert.Equal(t, "+0200", e.Committer.When.Format("-0700"))
+	assert.Equal(t, "commit (initial): first", e.Message)
+
+	e = Entry{}
+	require.NoError(t, d.Decode(&e))
+	assert.Equal(t, plumbing.NewHash("b8e471f58bcbca63b07bda20e428190409c2db47"), e.New)
+	assert.Equal(t, "", e.Committer.Name)
+	assert.Equal(t, "-0530", e.Committer.When.Format("-0700"))
+	assert.Equal(t, "", e.Message)
+
+	e = Entry{}
+	require.NoError(t, d.Decode(&e))
+	assert.Equal(t, "checkout: moving from master to branch", e.Message)
+
+	assert.Equal(t, io.EOF, d.Decode(&e))
+}
+
+func TestDecodeAll(t *testing.T) {
+	entries, err := NewDecoder(strings.NewReader(reflogFixture)).DecodeAll()
+	require.NoError(t, err)
+	assert.Len(t, entries, 3)
+
+	entries, err = NewDecoder(strings.NewReader("")).DecodeAll()
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+}
+
+func TestDecodeMalformed(t *testing.T) {
+	for _, line := range []string{
+		"foo",
+		"0000000000000000000000000000000000000000 foo John <john@example.com> 1 +0000\tmsg",
+		"0000000000000000000000000000000000000000 b029517f6300c2da0f4b651b8642506cd6aaf45d John john@example.com 1 +0000\tmsg",
+		"0000000000000000000000000000000000000000 b029517f6300c2da0f4b651b8642506cd6aaf45d John <john@example.com> foo +0000\tmsg",
+		"0000000000000000000000000000000000000000 b029517f6300c2da0f4b651b8642506cd6aaf45d John <john@example.com> 1\tmsg",
+	} {
+		var e Entry
+		err := NewDecoder(strings.NewReader(line)).Decode(&e)
+		assert.ErrorIs(t, err, ErrMalformedEntry, line)
+	}
+}
+
+func TestEncodeDecode(t *testing.T) {
+	expected := &Entry{
+		Old: plumbing.NewHash("b029517f6300c2da0f4b651b8642506cd6aaf45d"),
+		New: plumbing.NewHash("b8e471f58bcbca63b07bda20e428190409c2db47"),
+		Committer: Signature{
+			Name:  "John Doe",
+			Email: "john@example.com",
+			When:  time.Unix(1257894000, 0).In(time.FixedZone("", 3600)),
+		},
+		Message: "merge branch: Fast-forward",
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, NewEncoder(&buf).Encode(expected))
+
+	var e Entry
+	require.NoError(t, NewDecoder(&buf).Decode(&e))
+	assert.Equal(t, expected.Old, e.Old)
+	assert.Equal(t, expected.New, e.New)
+	assert.Equal(t, expected.Message, e.Message)
+	assert.True(t, expected.Committer.When.Equal(e.Committer.When))
+	assert.Equal(t, expected.Committer.String(), e.Committer.String())
+}
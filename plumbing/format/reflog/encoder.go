@@ -0,0 +1,33 @@
+package reflog
+
+import (
+	"fmt"
+	"io"
+)
+
+// An Encoder writes reflog entries to an output stream.
+type Encoder struct {
+	w io.Writer
+}
+
+// NewEncoder returns a new encoder that writes to w.
+func NewEncoder(w io.Writer) *Encoder {
+	return &Encoder{w}
+}
+
+// Encode writes the entry to the stream of the encoder as a single line. The
+// message is written with its whitespace collapsed, so it fits in the line.
+func (e *Encoder) Encode(entry *Entry) error {
+	c := entry.Committer
+	u := c.When.Unix()
+	if u < 0 {
+		u = 0
+	}
+
+	_, err := fmt.Fprintf(e.w, "%s %s %s <%s> %d %s\t%s\n",
+		entry.Old, entry.New, c.Name, c.Email, u, c.When.Format("-0700"),
+		normalizeMessage(entry.Message),
+	)
+
+	return err
+}
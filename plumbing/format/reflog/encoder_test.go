@@ -0,0 +1,44 @@
+package reflog
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncode(t *testing.T) {
+	var buf bytes.Buffer
+	e := NewEncoder(&buf)
+
+	err := e.Encode(&Entry{
+		New: plumbing.NewHash("b029517f6300c2da0f4b651b8642506cd6aaf45d"),
+		Committer: Signature{
+			Name:  "John Doe",
+			Email: "john@example.com",
+			When:  time.Unix(1257894000, 0).In(time.FixedZone("", 2*60*60)),
+		},
+		Message: "commit (initial): first\n\nbody",
+	})
+	require.NoError(t, err)
+
+	err = e.Encode(&Entry{
+		Old: plumbing.NewHash("b029517f6300c2da0f4b651b8642506cd6aaf45d"),
+		New: plumbing.NewHash("b8e471f58bcbca63b07bda20e428190409c2db47"),
+		Committer: Signature{
+			Name:  "John Doe",
+			Email: "john@example.com",
+			When:  time.Unix(1257894060, 0).In(time.FixedZone("", -(5*60+30)*60)),
+		},
+		Message: "reset: moving to b8e471f",
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, ""+
+		"0000000000000000000000000000000000000000 b029517f6300c2da0f4b651b8642506cd6aaf45d John Doe <john@example.com> 1257894000 +0200\tcommit (initial): first body\n"+
+		"b029517f6300c2da0f4b651b8642506cd6aaf45d b8e471f58bcbca63b07bda20e428190409c2db47 John Doe <john@example.com> 1257894060 -0530\treset: moving to b8e471f\n",
+		buf.String())
+}
@@ -0,0 +1,46 @@
+// Package reflog implements encoding and decoding of reflog files, the logs
+// of the updates of the references kept by git under `.git/logs`.
+//
+// Each line of a reflog file is an entry, with the format:
+//
+//	<old hash> SP <new hash> SP <name> SP "<" <email> ">" SP <timestamp> SP <tz> TAB <message> LF
+//
+// The entries are appended to the file, so the oldest entry is the first one.
+package reflog
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-git/v6/plumbing"
+)
+
+// Entry is an entry of a reflog, an update of a reference.
+type Entry struct {
+	// Old is the value of the reference before the update, the zero hash
+	// when the reference is created.
+	Old plumbing.Hash
+	// New is the value of the reference after the update.
+	New plumbing.Hash
+	// Committer is the identity of who updated the reference, and when.
+	Committer Signature
+	// Message describes the update, e.g. "commit: fix typo".
+	Message string
+}
+
+// Signature is the identity of who updated a reference.
+type Signature struct {
+	Name  string
+	Email string
+	When  time.Time
+}
+
+func (s Signature) String() string {
+	return fmt.Sprintf("%s <%s>", s.Name, s.Email)
+}
+
+// normalizeMessage returns the message as a single line, as git does.
+func normalizeMessage(msg string) string {
+	return strings.Join(strings.Fields(msg), " ")
+}
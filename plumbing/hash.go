@@ -18,7 +18,11 @@ var ZeroHash ObjectID
 
 // ComputeHash compute the hash for a given ObjectType and content
 func ComputeHash(t ObjectType, content []byte) Hash {
-	ha, err := newHasher(format.SHA1)
+	return computeHash(format.SHA1, t, content)
+}
+
+func computeHash(f format.ObjectFormat, t ObjectType, content []byte) Hash {
+	ha, err := newHasher(f)
 	if err != nil {
 		return ZeroHash
 	}
@@ -0,0 +1,373 @@
+package lfs
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	transporthttp "github.com/go-git/go-git/v6/plumbing/transport/http"
+	"github.com/go-git/go-git/v6/utils/ioutil"
+)
+
+const (
+	mediaType = "application/vnd.git-lfs+json"
+
+	// maxCredentialFills is the number of times the credential helper is
+	// queried for a single request.
+	maxCredentialFills = 2
+
+	downloadOperation = "download"
+	uploadOperation   = "upload"
+	verifyAction      = "verify"
+	basicTransfer     = "basic"
+)
+
+// ErrAuthenticationRequired is returned when the LFS server refuses the
+// credentials, or requires some and none is available.
+var ErrAuthenticationRequired = errors.New("lfs: authentication required")
+
+// Client transfers LFS objects using the batch API of an LFS server and the
+// basic transfer adapter. It's not safe for concurrent use.
+type Client struct {
+	// Endpoint is the URL of the LFS server, e.g. the one returned by
+	// EndpointFromRemote.
+	Endpoint string
+	// HTTPClient performs the requests. If nil, http.DefaultClient is used.
+	HTTPClient *http.Client
+	// Auth is set on the requests to the server, the same used to fetch
+	// from and push to the remote.
+	Auth transporthttp.AuthMethod
+	// CredentialHelper is queried for credentials when the server requires
+	// authentication and no Auth is given, see
+	// transporthttp.GitCredentialHelper.
+	CredentialHelper transporthttp.CredentialHelper
+	// Header holds extra headers added to the requests to the server, e.g.
+	// the ones of http.extraHeader.
+	Header http.Header
+
+	credential *transporthttp.Credential // the credential filled by the helper
+	approved   bool                      // whether credential was approved already
+}
+
+type batchRequest struct {
+	Operation string        `json:"operation"`
+	Transfers []string      `json:"transfers"`
+	Objects   []batchObject `json:"objects"`
+	HashAlgo  string        `json:"hash_algo,omitempty"`
+}
+
+type batchObject struct {
+	Oid     string            `json:"oid"`
+	Size    int64             `json:"size"`
+	Actions map[string]action `json:"actions,omitempty"`
+	Error   *objectError      `json:"error,omitempty"`
+}
+
+type action struct {
+	Href   string            `json:"href"`
+	Header map[string]string `json:"header,omitempty"`
+}
+
+type objectError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+type batchResponse struct {
+	Transfer string        `json:"transfer"`
+	Objects  []batchObject `json:"objects"`
+	Message  string        `json:"message"`
+}
+
+// Download writes the content of the object to w. The content is not
+// verified, see Storage.Write.
+func (c *Client) Download(ctx context.Context, p Pointer, w io.Writer) (err error) {
+	obj, err := c.batch(ctx, downloadOperation, p)
+	if err != nil {
+		return err
+	}
+
+	a, ok := obj.Actions[downloadOperation]
+	if !ok {
+		return fmt.Errorf("lfs: no download action for object %s", p.Oid)
+	}
+
+	res, err := c.doAction(ctx, http.MethodGet, a, nil, "")
+	if err != nil {
+		return err
+	}
+
+	defer ioutil.CheckClose(res.Body, &err)
+	_, err = io.Copy(w, res.Body)
+	return err
+}
+
+// Upload sends the content of the object, read from r, to the server. Nothing
+// is sent if the server already has the object.
+func (c *Client) Upload(ctx context.Context, p Pointer, r io.Reader) error {
+	obj, err := c.batch(ctx, uploadOperation, p)
+	if err != nil {
+		return err
+	}
+
+	a, ok := obj.Actions[uploadOperation]
+	if !ok {
+		return nil
+	}
+
+	res, err := c.doAction(ctx, http.MethodPut, a, r, "application/octet-stream")
+	if err != nil {
+		return err
+	}
+
+	_ = res.Body.Close()
+
+	v, ok := obj.Actions[verifyAction]
+	if !ok {
+		return nil
+	}
+
+	body, err := json.Marshal(batchObject{Oid: p.Oid, Size: p.Size})
+	if err != nil {
+		return err
+	}
+
+	res, err = c.doAction(ctx, http.MethodPost, v, bytes.NewReader(body), mediaType)
+	if err != nil {
+		return err
+	}
+
+	return res.Body.Close()
+}
+
+// batch requests the given operation on the object to the server, returning
+// the object of the response.
+func (c *Client) batch(ctx context.Context, operation string, p Pointer) (*batchObject, error) {
+	body, err := json.Marshal(batchRequest{
+		Operation: operation,
+		Transfers: []string{basicTransfer},
+		Objects:   []batchObject{{Oid: p.Oid, Size: p.Size}},
+		HashAlgo:  "sha256",
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	endpoint := strings.TrimSuffix(c.Endpoint, "/") + "/objects/batch"
+	res, err := c.do(ctx, func() (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+
+		req.Header.Set("Accept", mediaType)
+		req.Header.Set("Content-Type", mediaType)
+		return req, nil
+	}, true, true)
+	if err != nil {
+		return nil, err
+	}
+
+	defer res.Body.Close()
+
+	var br batchResponse
+	if err := json.NewDecoder(res.Body).Decode(&br); err != nil {
+		return nil, fmt.Errorf("lfs: decoding batch response: %w", err)
+	}
+
+	if br.Transfer != "" && br.Transfer != basicTransfer {
+		return nil, fmt.Errorf("lfs: unsupported transfer adapter %q", br.Transfer)
+	}
+
+	for _, obj := range br.Objects {
+		if obj.Oid != p.Oid {
+			continue
+		}
+
+		if obj.Error != nil {
+			if obj.Error.Code == http.StatusNotFound {
+				return nil, fmt.Errorf("%w: %s: %s", ErrObjectNotFound, p.Oid, obj.Error.Message)
+			}
+
+			return nil, fmt.Errorf("lfs: object %s: %s (%d)", p.Oid, obj.Error.Message, obj.Error.Code)
+		}
+
+		return &obj, nil
+	}
+
+	return nil, fmt.Errorf("lfs: object %s missing in batch response", p.Oid)
+}
+
+// doAction performs the request of an action of the batch response. The
+// credentials of the server are only sent along if the action gives no
+// authorization of its own and is on the same host as the server.
+func (c *Client) doAction(ctx context.Context, method string, a action, body io.Reader, contentType string) (*http.Response, error) {
+	_, hasAuth := a.Header["Authorization"]
+	auth := !hasAuth && c.sameHost(a.Href)
+
+	// A body read by a refused request can't be sent again, so these are
+	// not retried with the credentials of the helper.
+	retry := body == nil
+	return c.do(ctx, func() (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, method, a.Href, body)
+		if err != nil {
+			return nil, err
+		}
+
+		for k, v := range a.Header {
+			req.Header.Set(k, v)
+		}
+
+		if contentType != "" {
+			req.Header.Set("Content-Type", contentType)
+		}
+
+		if contentType == mediaType {
+			req.Header.Set("Accept", mediaType)
+		}
+
+		return req, nil
+	}, auth, retry)
+}
+
+// do performs the request built by newRequest, with the credentials of the
+// client if auth is true. When the server requires authentication and retry
+// is true, the credential helper is queried and the request built again.
+func (c *Client) do(ctx context.Context, newRequest func() (*http.Request, error), auth, retry bool) (*http.Response, error) {
+	for fills := 0; ; fills++ {
+		req, err := newRequest()
+		if err != nil {
+			return nil, err
+		}
+
+		for k, values := range c.Header {
+			for _, v := range values {
+				req.Header.Add(k, v)
+			}
+		}
+
+		if auth {
+			c.setAuth(req)
+		}
+
+		res, err := c.httpClient().Do(req)
+		if err != nil {
+			return nil, err
+		}
+
+		if res.StatusCode >= http.StatusOK && res.StatusCode < http.StatusMultipleChoices {
+			if err := c.approve(ctx); err != nil {
+				_ = res.Body.Close()
+				return nil, err
+			}
+
+			return res, nil
+		}
+
+		err = responseError(res)
+		_ = res.Body.Close()
+
+		if res.StatusCode != http.StatusUnauthorized || !auth || !retry ||
+			c.Auth != nil || c.CredentialHelper == nil || fills == maxCredentialFills {
+			return nil, err
+		}
+
+		if err := c.fill(ctx, req.URL); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (c *Client) setAuth(req *http.Request) {
+	switch {
+	case c.Auth != nil:
+		c.Auth.SetAuth(req)
+	case c.credential != nil:
+		req.SetBasicAuth(c.credential.Username, c.credential.Password)
+	}
+}
+
+// fill rejects the credential refused by the server, if any, and queries the
+// credential helper for a new one.
+func (c *Client) fill(ctx context.Context, u *url.URL) error {
+	if c.credential != nil {
+		cred := c.credential
+		c.credential = nil
+		if err := c.CredentialHelper.Reject(ctx, cred); err != nil {
+			return err
+		}
+	}
+
+	cred, err := c.CredentialHelper.Fill(ctx, &transporthttp.Credential{
+		Protocol: u.Scheme,
+		Host:     u.Host,
+		Path:     strings.TrimPrefix(u.Path, "/"),
+	})
+	if err != nil {
+		return err
+	}
+
+	c.credential, c.approved = cred, false
+	return nil
+}
+
+// approve approves the credential filled by the helper, accepted by the
+// server.
+func (c *Client) approve(ctx context.Context) error {
+	if c.credential == nil || c.approved {
+		return nil
+	}
+
+	c.approved = true
+	return c.CredentialHelper.Approve(ctx, c.credential)
+}
+
+func (c *Client) sameHost(href string) bool {
+	a, err := url.Parse(href)
+	if err != nil {
+		return false
+	}
+
+	b, err := url.Parse(c.Endpoint)
+	if err != nil {
+		return false
+	}
+
+	return a.Host == b.Host
+}
+
+func (c *Client) httpClient() *http.Client {
+	if c.HTTPClient != nil {
+		return c.HTTPClient
+	}
+
+	return http.DefaultClient
+}
+
+func responseError(res *http.Response) error {
+	var body struct {
+		Message string `json:"message"`
+	}
+
+	_ = json.NewDecoder(io.LimitReader(res.Body, 64*1024)).Decode(&body)
+
+	msg := body.Message
+	if msg == "" {
+		msg = res.Status
+	}
+
+	switch res.StatusCode {
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return fmt.Errorf("%w: %s", ErrAuthenticationRequired, msg)
+	case http.StatusNotFound:
+		return fmt.Errorf("%w: %s", ErrObjectNotFound, msg)
+	default:
+		return fmt.Errorf("lfs: %s %s: %s", res.Request.Method, res.Request.URL.Redacted(), msg)
+	}
+}
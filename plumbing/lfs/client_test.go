@@ -0,0 +1,276 @@
+package lfs
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	transporthttp "github.com/go-git/go-git/v6/plumbing/transport/http"
+	"github.com/stretchr/testify/suite"
+)
+
+// testServer is an LFS server implementing the batch API and the basic
+// transfer adapter, requiring basic auth when user is set.
+type testServer struct {
+	*httptest.Server
+
+	user, password string
+
+	mu       sync.Mutex
+	objects  map[string][]byte
+	verified map[string]bool
+}
+
+func newTestServer() *testServer {
+	s := &testServer{objects: make(map[string][]byte), verified: make(map[string]bool)}
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /repo.git/info/lfs/objects/batch", s.batch)
+	mux.HandleFunc("GET /objects/{oid}", s.download)
+	mux.HandleFunc("PUT /objects/{oid}", s.upload)
+	mux.HandleFunc("POST /verify", s.verify)
+	s.Server = httptest.NewServer(s.authenticated(mux))
+	return s
+}
+
+func (s *testServer) endpoint() string {
+	return s.URL + "/repo.git/info/lfs"
+}
+
+func (s *testServer) authenticated(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.user != "" {
+			user, password, ok := r.BasicAuth()
+			if !ok || user != s.user || password != s.password {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+		}
+
+		h.ServeHTTP(w, r)
+	})
+}
+
+func (s *testServer) batch(w http.ResponseWriter, r *http.Request) {
+	var req batchRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	res := batchResponse{Transfer: basicTransfer}
+	for _, obj := range req.Objects {
+		_, ok := s.objects[obj.Oid]
+		out := batchObject{Oid: obj.Oid, Size: obj.Size}
+		href := s.URL + "/objects/" + obj.Oid
+		switch {
+		case req.Operation == downloadOperation && ok:
+			out.Actions = map[string]action{downloadOperation: {Href: href}}
+		case req.Operation == downloadOperation:
+			out.Error = &objectError{Code: http.StatusNotFound, Message: "not found"}
+		case !ok:
+			out.Actions = map[string]action{
+				uploadOperation: {Href: href},
+				verifyAction:    {Href: s.URL + "/verify"},
+			}
+		}
+
+		res.Objects = append(res.Objects, out)
+	}
+
+	w.Header().Set("Content-Type", mediaType)
+	_ = json.NewEncoder(w).Encode(res)
+}
+
+func (s *testServer) download(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	content, ok := s.objects[r.PathValue("oid")]
+	s.mu.Unlock()
+
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	_, _ = w.Write(content)
+}
+
+func (s *testServer) upload(w http.ResponseWriter, r *http.Request) {
+	content, err := io.ReadAll(r.Body)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	s.objects[r.PathValue("oid")] = content
+	s.mu.Unlock()
+}
+
+func (s *testServer) verify(w http.ResponseWriter, r *http.Request) {
+	var obj batchObject
+	if err := json.NewDecoder(r.Body).Decode(&obj); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	s.verified[obj.Oid] = true
+	s.mu.Unlock()
+}
+
+type ClientSuite struct {
+	suite.Suite
+	server *testServer
+}
+
+func TestClientSuite(t *testing.T) {
+	suite.Run(t, new(ClientSuite))
+}
+
+func (s *ClientSuite) SetupTest() {
+	s.server = newTestServer()
+}
+
+func (s *ClientSuite) TearDownTest() {
+	s.server.Close()
+}
+
+func (s *ClientSuite) TestUploadAndDownload() {
+	c := &Client{Endpoint: s.server.endpoint()}
+	p := Pointer{Oid: fooOid, Size: 3}
+
+	var buf bytes.Buffer
+	s.ErrorIs(c.Download(context.Background(), p, &buf), ErrObjectNotFound)
+
+	s.NoError(c.Upload(context.Background(), p, strings.NewReader("foo")))
+	s.Equal("foo", string(s.server.objects[fooOid]))
+	s.True(s.server.verified[fooOid])
+
+	// Already in the server, nothing is sent.
+	s.NoError(c.Upload(context.Background(), p, strings.NewReader("")))
+	s.Equal("foo", string(s.server.objects[fooOid]))
+
+	s.NoError(c.Download(context.Background(), p, &buf))
+	s.Equal("foo", buf.String())
+}
+
+func (s *ClientSuite) TestAuth() {
+	s.server.user, s.server.password = "user", "secret"
+	s.server.objects[fooOid] = []byte("foo")
+	p := Pointer{Oid: fooOid, Size: 3}
+
+	var buf bytes.Buffer
+	c := &Client{Endpoint: s.server.endpoint()}
+	s.ErrorIs(c.Download(context.Background(), p, &buf), ErrAuthenticationRequired)
+
+	c.Auth = &transporthttp.BasicAuth{Username: "user", Password: "secret"}
+	s.NoError(c.Download(context.Background(), p, &buf))
+	s.Equal("foo", buf.String())
+}
+
+type testCredentialHelper struct {
+	fills    []*transporthttp.Credential
+	approved []*transporthttp.Credential
+	rejected []*transporthttp.Credential
+	answers  []*transporthttp.Credential
+}
+
+func (h *testCredentialHelper) Fill(_ context.Context, c *transporthttp.Credential) (*transporthttp.Credential, error) {
+	h.fills = append(h.fills, c)
+	answer := h.answers[0]
+	h.answers = h.answers[1:]
+	return answer, nil
+}
+
+func (h *testCredentialHelper) Approve(_ context.Context, c *transporthttp.Credential) error {
+	h.approved = append(h.approved, c)
+	return nil
+}
+
+func (h *testCredentialHelper) Reject(_ context.Context, c *transporthttp.Credential) error {
+	h.rejected = append(h.rejected, c)
+	return nil
+}
+
+func (s *ClientSuite) TestCredentialHelper() {
+	s.server.user, s.server.password = "user", "secret"
+	s.server.objects[fooOid] = []byte("foo")
+
+	wrong := &transporthttp.Credential{Username: "user", Password: "wrong"}
+	right := &transporthttp.Credential{Username: "user", Password: "secret"}
+	helper := &testCredentialHelper{answers: []*transporthttp.Credential{wrong, right}}
+
+	var buf bytes.Buffer
+	c := &Client{Endpoint: s.server.endpoint(), CredentialHelper: helper}
+	s.NoError(c.Download(context.Background(), Pointer{Oid: fooOid, Size: 3}, &buf))
+	s.Equal("foo", buf.String())
+
+	s.Require().Len(helper.fills, 2)
+	s.Equal("http", helper.fills[0].Protocol)
+	s.Equal(strings.TrimPrefix(s.server.URL, "http://"), helper.fills[0].Host)
+	s.Equal("repo.git/info/lfs/objects/batch", helper.fills[0].Path)
+	s.Equal([]*transporthttp.Credential{wrong}, helper.rejected)
+	s.Equal([]*transporthttp.Credential{right}, helper.approved)
+}
+
+func (s *ClientSuite) TestDriver() {
+	d := &Driver{Storage: NewMemoryStorage(), Client: &Client{Endpoint: s.server.endpoint()}}
+
+	var buf bytes.Buffer
+	s.NoError(d.Clean("foo", &buf, strings.NewReader("foo")))
+	s.Equal(fooPointer, buf.String())
+
+	// Pointers are kept as they are.
+	buf.Reset()
+	s.NoError(d.Clean("foo", &buf, strings.NewReader(fooPointer)))
+	s.Equal(fooPointer, buf.String())
+
+	p := Pointer{Oid: fooOid, Size: 3}
+	s.NoError(d.Upload(context.Background(), p))
+	s.Equal("foo", string(s.server.objects[fooOid]))
+
+	// Downloaded into an empty storage.
+	d.Storage = NewMemoryStorage()
+	buf.Reset()
+	s.NoError(d.Smudge("foo", &buf, strings.NewReader(fooPointer)))
+	s.Equal("foo", buf.String())
+
+	ok, err := d.Storage.Has(p)
+	s.NoError(err)
+	s.True(ok)
+
+	// Offline, from the storage.
+	d.Client = nil
+	buf.Reset()
+	s.NoError(d.Smudge("foo", &buf, strings.NewReader(fooPointer)))
+	s.Equal("foo", buf.String())
+
+	d.Storage = NewMemoryStorage()
+	s.ErrorIs(d.Smudge("foo", &buf, strings.NewReader(fooPointer)), ErrObjectNotFound)
+
+	// Content not being a pointer is kept as it is.
+	buf.Reset()
+	s.NoError(d.Smudge("foo", &buf, strings.NewReader("not a pointer")))
+	s.Equal("not a pointer", buf.String())
+}
+
+func (s *ClientSuite) TestDriverCorruptedDownload() {
+	s.server.objects[fooOid] = []byte("bar")
+	d := &Driver{Storage: NewMemoryStorage(), Client: &Client{Endpoint: s.server.endpoint()}}
+
+	var buf bytes.Buffer
+	s.Error(d.Smudge("foo", &buf, strings.NewReader(fooPointer)))
+
+	ok, err := d.Storage.Has(Pointer{Oid: fooOid, Size: 3})
+	s.NoError(err)
+	s.False(ok)
+}
@@ -0,0 +1,129 @@
+package lfs
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/go-git/go-git/v6/plumbing/filter"
+	"github.com/go-git/go-git/v6/utils/ioutil"
+)
+
+// FilterName is the name of the filter of the files stored in LFS, as set by
+// the filter=lfs attribute.
+const FilterName = "lfs"
+
+// Driver is the driver of the lfs filter: files are replaced by pointers when
+// added, their content being kept in the local storage, and pointers are
+// replaced by the content on checkout, downloaded from the server when not
+// found in the local storage.
+//
+// A Driver holds the storage and the server of one repository, so it's set
+// in the Filters of the worktree of that repository under FilterName, rather
+// than registered with filter.Register.
+type Driver struct {
+	// Storage is the local cache of the objects.
+	Storage Storage
+	// Client downloads the objects missing in Storage on checkout, and
+	// uploads them with Upload. If nil, only the objects in Storage can be
+	// checked out.
+	Client *Client
+}
+
+var _ filter.Driver = (*Driver)(nil)
+
+// Clean implements filter.Driver. The content is stored in the local storage
+// and its pointer written to dst. Content already being a pointer is kept as
+// it is.
+func (d *Driver) Clean(_ string, dst io.Writer, src io.Reader) error {
+	var buf bytes.Buffer
+	if _, err := io.Copy(&buf, src); err != nil {
+		return err
+	}
+
+	if _, err := DecodePointer(buf.Bytes()); err == nil {
+		_, err := dst.Write(buf.Bytes())
+		return err
+	}
+
+	p, err := NewPointer(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		return err
+	}
+
+	ok, err := d.Storage.Has(p)
+	if err != nil {
+		return err
+	}
+
+	if !ok {
+		if err := d.Storage.Write(p, bytes.NewReader(buf.Bytes())); err != nil {
+			return err
+		}
+	}
+
+	return p.Encode(dst)
+}
+
+// Smudge implements filter.Driver. The content of the pointer read from src
+// is written to dst. Content not being a pointer is written as it is.
+func (d *Driver) Smudge(path string, dst io.Writer, src io.Reader) error {
+	content, err := io.ReadAll(io.LimitReader(src, MaxPointerSize+1))
+	if err != nil {
+		return err
+	}
+
+	p, err := DecodePointer(content)
+	if err != nil {
+		if _, err := dst.Write(content); err != nil {
+			return err
+		}
+
+		_, err = io.Copy(dst, src)
+		return err
+	}
+
+	if err := d.Fetch(context.Background(), p); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+
+	return copyObject(d.Storage, p, dst)
+}
+
+// Fetch downloads the object to the local storage, unless it's already there.
+func (d *Driver) Fetch(ctx context.Context, p Pointer) (err error) {
+	ok, err := d.Storage.Has(p)
+	if err != nil || ok {
+		return err
+	}
+
+	if d.Client == nil {
+		return fmt.Errorf("%w: %s", ErrObjectNotFound, p.Oid)
+	}
+
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(d.Client.Download(ctx, p, pw))
+	}()
+
+	defer ioutil.CheckClose(pr, &err)
+	return d.Storage.Write(p, pr)
+}
+
+// Upload sends the object in the local storage to the server, if the server
+// doesn't have it yet.
+func (d *Driver) Upload(ctx context.Context, p Pointer) (err error) {
+	if d.Client == nil {
+		return errors.New("lfs: no client to upload objects")
+	}
+
+	r, err := d.Storage.Open(p)
+	if err != nil {
+		return err
+	}
+
+	defer ioutil.CheckClose(r, &err)
+	return d.Client.Upload(ctx, p, r)
+}
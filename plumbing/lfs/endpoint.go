@@ -0,0 +1,41 @@
+package lfs
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/go-git/go-git/v6/plumbing/transport"
+)
+
+// EndpointFromRemote returns the URL of the LFS server of a remote, derived
+// from the URL of the remote as git-lfs does when lfs.url is not configured:
+// the info/lfs path of the repository, on HTTPS for SSH remotes.
+//
+// For example, both https://host/org/repo and git@host:org/repo.git give
+// https://host/org/repo.git/info/lfs.
+func EndpointFromRemote(url string) (string, error) {
+	ep, err := transport.NewEndpoint(url)
+	if err != nil {
+		return "", err
+	}
+
+	scheme := ep.Protocol
+	host := ep.Host
+	switch scheme {
+	case "http", "https":
+		if ep.Port != 0 {
+			host = fmt.Sprintf("%s:%d", ep.Host, ep.Port)
+		}
+	case "ssh", "git":
+		scheme = "https"
+	default:
+		return "", fmt.Errorf("no lfs endpoint for %s remotes: %s", scheme, url)
+	}
+
+	p := strings.TrimSuffix(strings.TrimPrefix(ep.Path, "/"), "/")
+	if !strings.HasSuffix(p, ".git") {
+		p += ".git"
+	}
+
+	return fmt.Sprintf("%s://%s/%s/info/lfs", scheme, host, p), nil
+}
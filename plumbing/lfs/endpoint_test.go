@@ -0,0 +1,35 @@
+package lfs
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type EndpointSuite struct {
+	suite.Suite
+}
+
+func TestEndpointSuite(t *testing.T) {
+	suite.Run(t, new(EndpointSuite))
+}
+
+func (s *EndpointSuite) TestEndpointFromRemote() {
+	for url, expected := range map[string]string{
+		"https://example.com/org/repo":            "https://example.com/org/repo.git/info/lfs",
+		"https://example.com/org/repo.git":        "https://example.com/org/repo.git/info/lfs",
+		"https://example.com/org/repo/":           "https://example.com/org/repo.git/info/lfs",
+		"http://example.com:8080/repo.git":        "http://example.com:8080/repo.git/info/lfs",
+		"git@example.com:org/repo.git":            "https://example.com/org/repo.git/info/lfs",
+		"ssh://git@example.com:2222/org/repo.git": "https://example.com/org/repo.git/info/lfs",
+	} {
+		ep, err := EndpointFromRemote(url)
+		s.NoError(err, url)
+		s.Equal(expected, ep, url)
+	}
+}
+
+func (s *EndpointSuite) TestEndpointFromRemoteFile() {
+	_, err := EndpointFromRemote("/tmp/repo")
+	s.Error(err)
+}
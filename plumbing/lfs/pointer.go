@@ -0,0 +1,121 @@
+// Package lfs implements Git LFS: the pointers stored in the repository in
+// place of large files, the local cache of their content, the client of the
+// batch API of LFS servers and the filter driver converting the files between
+// pointers and content, see https://github.com/git-lfs/git-lfs/tree/main/docs.
+package lfs
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+const (
+	// Version is the version line of the pointers written by this package.
+	Version = "https://git-lfs.github.com/spec/v1"
+
+	oidPrefix = "sha256:"
+
+	// MaxPointerSize is the max size of a pointer, bigger blobs are never
+	// parsed as pointers.
+	MaxPointerSize = 1024
+)
+
+// ErrInvalidPointer is returned when decoding content that is not a pointer.
+var ErrInvalidPointer = errors.New("invalid lfs pointer")
+
+// Pointer references the content of a file stored in LFS.
+type Pointer struct {
+	// Oid is the hex encoded SHA-256 of the content.
+	Oid string
+	// Size of the content in bytes.
+	Size int64
+}
+
+// NewPointer reads the given content to the end and returns its pointer.
+func NewPointer(r io.Reader) (Pointer, error) {
+	h := sha256.New()
+	n, err := io.Copy(h, r)
+	if err != nil {
+		return Pointer{}, err
+	}
+
+	return Pointer{Oid: hex.EncodeToString(h.Sum(nil)), Size: n}, nil
+}
+
+// Encode writes the pointer as stored in the repository.
+func (p Pointer) Encode(w io.Writer) error {
+	_, err := fmt.Fprintf(w, "version %s\noid %s%s\nsize %d\n", Version, oidPrefix, p.Oid, p.Size)
+	return err
+}
+
+// Bytes returns the pointer as stored in the repository.
+func (p Pointer) Bytes() []byte {
+	var buf bytes.Buffer
+	_ = p.Encode(&buf)
+	return buf.Bytes()
+}
+
+// DecodePointer parses a pointer, returning ErrInvalidPointer if the content
+// is not one.
+func DecodePointer(content []byte) (Pointer, error) {
+	if len(content) > MaxPointerSize {
+		return Pointer{}, ErrInvalidPointer
+	}
+
+	var p Pointer
+	var hasOid, hasSize bool
+	s := bufio.NewScanner(bytes.NewReader(content))
+	for i := 0; s.Scan(); i++ {
+		key, value, ok := strings.Cut(s.Text(), " ")
+		if !ok {
+			return Pointer{}, ErrInvalidPointer
+		}
+
+		switch {
+		case i == 0:
+			if key != "version" || !isKnownVersion(value) {
+				return Pointer{}, ErrInvalidPointer
+			}
+		case key == "oid":
+			oid, ok := strings.CutPrefix(value, oidPrefix)
+			if !ok || !isValidOid(oid) {
+				return Pointer{}, ErrInvalidPointer
+			}
+
+			p.Oid, hasOid = oid, true
+		case key == "size":
+			size, err := strconv.ParseInt(value, 10, 64)
+			if err != nil || size < 0 {
+				return Pointer{}, ErrInvalidPointer
+			}
+
+			p.Size, hasSize = size, true
+		}
+	}
+
+	if !hasOid || !hasSize {
+		return Pointer{}, ErrInvalidPointer
+	}
+
+	return p, nil
+}
+
+func isKnownVersion(v string) bool {
+	return v == Version || v == "https://hawser.github.com/spec/v1"
+}
+
+func isValidOid(oid string) bool {
+	if len(oid) != sha256.Size*2 {
+		return false
+	}
+
+	_, err := hex.DecodeString(oid)
+	return err == nil
+}
@@ -0,0 +1,62 @@
+package lfs
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type PointerSuite struct {
+	suite.Suite
+}
+
+func TestPointerSuite(t *testing.T) {
+	suite.Run(t, new(PointerSuite))
+}
+
+const (
+	fooOid     = "2c26b46b68ffc68ff99b453c1d30413413422d706483bfa0f98a5e886266e7ae"
+	fooPointer = "version https://git-lfs.github.com/spec/v1\n" +
+		"oid sha256:" + fooOid + "\n" +
+		"size 3\n"
+)
+
+func (s *PointerSuite) TestNewPointer() {
+	p, err := NewPointer(strings.NewReader("foo"))
+	s.NoError(err)
+	s.Equal(Pointer{Oid: fooOid, Size: 3}, p)
+	s.Equal(fooPointer, string(p.Bytes()))
+}
+
+func (s *PointerSuite) TestDecodePointer() {
+	p, err := DecodePointer([]byte(fooPointer))
+	s.NoError(err)
+	s.Equal(Pointer{Oid: fooOid, Size: 3}, p)
+}
+
+func (s *PointerSuite) TestDecodePointerExtensions() {
+	p, err := DecodePointer([]byte("version https://git-lfs.github.com/spec/v1\n" +
+		"ext-0-foo sha256:" + fooOid + "\n" +
+		"oid sha256:" + fooOid + "\n" +
+		"size 3\n"))
+	s.NoError(err)
+	s.Equal(Pointer{Oid: fooOid, Size: 3}, p)
+}
+
+func (s *PointerSuite) TestDecodePointerInvalid() {
+	for _, content := range []string{
+		"",
+		"foo",
+		"oid sha256:" + fooOid + "\nsize 3\n",
+		"version https://example.com\noid sha256:" + fooOid + "\nsize 3\n",
+		"version https://git-lfs.github.com/spec/v1\noid sha256:foo\nsize 3\n",
+		"version https://git-lfs.github.com/spec/v1\noid md5:" + fooOid + "\nsize 3\n",
+		"version https://git-lfs.github.com/spec/v1\noid sha256:" + fooOid + "\n",
+		"version https://git-lfs.github.com/spec/v1\noid sha256:" + fooOid + "\nsize -1\n",
+		fooPointer + strings.Repeat("x", MaxPointerSize),
+	} {
+		_, err := DecodePointer([]byte(content))
+		s.ErrorIs(err, ErrInvalidPointer, content)
+	}
+}
@@ -0,0 +1,188 @@
+package lfs
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sync"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-git/v6/utils/ioutil"
+)
+
+// ErrObjectNotFound is returned when an object is not in the storage.
+var ErrObjectNotFound = errors.New("lfs object not found")
+
+// Storage is the local cache of the content of LFS objects.
+type Storage interface {
+	// Has returns true if the object is in the storage.
+	Has(p Pointer) (bool, error)
+	// Open returns the content of the object, or ErrObjectNotFound.
+	Open(p Pointer) (io.ReadCloser, error)
+	// Write stores the content of the object, read to the end, failing if
+	// it doesn't match the pointer.
+	Write(p Pointer, r io.Reader) error
+}
+
+// FilesystemStorage stores the objects as git-lfs does, in the lfs/objects
+// directory of the repository, e.g. lfs/objects/ab/cd/abcd...
+type FilesystemStorage struct {
+	fs billy.Filesystem
+}
+
+var _ Storage = (*FilesystemStorage)(nil)
+
+// NewFilesystemStorage returns a Storage in the given filesystem, the .git
+// directory of the repository.
+func NewFilesystemStorage(fs billy.Filesystem) *FilesystemStorage {
+	return &FilesystemStorage{fs: fs}
+}
+
+func (s *FilesystemStorage) objectPath(p Pointer) string {
+	return path.Join("lfs", "objects", p.Oid[0:2], p.Oid[2:4], p.Oid)
+}
+
+// Has implements Storage.
+func (s *FilesystemStorage) Has(p Pointer) (bool, error) {
+	fi, err := s.fs.Stat(s.objectPath(p))
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+
+	if err != nil {
+		return false, err
+	}
+
+	return fi.Size() == p.Size, nil
+}
+
+// Open implements Storage.
+func (s *FilesystemStorage) Open(p Pointer) (io.ReadCloser, error) {
+	f, err := s.fs.Open(s.objectPath(p))
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("%w: %s", ErrObjectNotFound, p.Oid)
+	}
+
+	return f, err
+}
+
+// Write implements Storage. The content is written to a temporary file first,
+// renamed once verified.
+func (s *FilesystemStorage) Write(p Pointer, r io.Reader) (err error) {
+	dir := path.Join("lfs", "tmp")
+	if err := s.fs.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+
+	tmp, err := s.fs.TempFile(dir, p.Oid)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if err != nil {
+			_ = s.fs.Remove(tmp.Name())
+		}
+	}()
+
+	if err := copyVerified(tmp, r, p); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	target := s.objectPath(p)
+	if err := s.fs.MkdirAll(path.Dir(target), 0o755); err != nil {
+		return err
+	}
+
+	return s.fs.Rename(tmp.Name(), target)
+}
+
+// MemoryStorage keeps the objects in memory.
+type MemoryStorage struct {
+	mu      sync.RWMutex
+	objects map[string][]byte
+}
+
+var _ Storage = (*MemoryStorage)(nil)
+
+// NewMemoryStorage returns an empty MemoryStorage.
+func NewMemoryStorage() *MemoryStorage {
+	return &MemoryStorage{objects: make(map[string][]byte)}
+}
+
+// Has implements Storage.
+func (s *MemoryStorage) Has(p Pointer) (bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	_, ok := s.objects[p.Oid]
+	return ok, nil
+}
+
+// Open implements Storage.
+func (s *MemoryStorage) Open(p Pointer) (io.ReadCloser, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	content, ok := s.objects[p.Oid]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrObjectNotFound, p.Oid)
+	}
+
+	return io.NopCloser(bytes.NewReader(content)), nil
+}
+
+// Write implements Storage.
+func (s *MemoryStorage) Write(p Pointer, r io.Reader) error {
+	var buf bytes.Buffer
+	if err := copyVerified(&buf, r, p); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.objects[p.Oid] = buf.Bytes()
+	s.mu.Unlock()
+	return nil
+}
+
+// copyVerified copies the content to dst, checking that it matches the
+// pointer.
+func copyVerified(dst io.Writer, r io.Reader, p Pointer) error {
+	h := sha256.New()
+	n, err := io.Copy(io.MultiWriter(dst, h), r)
+	if err != nil {
+		return err
+	}
+
+	if n != p.Size {
+		return fmt.Errorf("lfs object %s: expected %d bytes, got %d", p.Oid, p.Size, n)
+	}
+
+	if oid := hex.EncodeToString(h.Sum(nil)); oid != p.Oid {
+		return fmt.Errorf("lfs object %s: content has oid %s", p.Oid, oid)
+	}
+
+	return nil
+}
+
+// copyObject writes the content of the object in the storage to w.
+func copyObject(s Storage, p Pointer, w io.Writer) (err error) {
+	r, err := s.Open(p)
+	if err != nil {
+		return err
+	}
+
+	defer ioutil.CheckClose(r, &err)
+	_, err = io.Copy(w, r)
+	return err
+}
@@ -0,0 +1,67 @@
+package lfs
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/go-git/go-billy/v5/util"
+	"github.com/stretchr/testify/suite"
+)
+
+type StorageSuite struct {
+	suite.Suite
+}
+
+func TestStorageSuite(t *testing.T) {
+	suite.Run(t, new(StorageSuite))
+}
+
+func (s *StorageSuite) testStorage(st Storage) {
+	p := Pointer{Oid: fooOid, Size: 3}
+
+	ok, err := st.Has(p)
+	s.NoError(err)
+	s.False(ok)
+
+	_, err = st.Open(p)
+	s.ErrorIs(err, ErrObjectNotFound)
+
+	s.Error(st.Write(p, strings.NewReader("bar")))
+	s.Error(st.Write(p, strings.NewReader("fooo")))
+
+	ok, err = st.Has(p)
+	s.NoError(err)
+	s.False(ok)
+
+	s.NoError(st.Write(p, strings.NewReader("foo")))
+
+	ok, err = st.Has(p)
+	s.NoError(err)
+	s.True(ok)
+
+	r, err := st.Open(p)
+	s.Require().NoError(err)
+	content, err := io.ReadAll(r)
+	s.NoError(err)
+	s.NoError(r.Close())
+	s.Equal("foo", string(content))
+}
+
+func (s *StorageSuite) TestFilesystemStorage() {
+	fs := memfs.New()
+	s.testStorage(NewFilesystemStorage(fs))
+
+	content, err := util.ReadFile(fs, "lfs/objects/2c/26/"+fooOid)
+	s.NoError(err)
+	s.Equal("foo", string(content))
+
+	tmp, err := fs.ReadDir("lfs/tmp")
+	s.NoError(err)
+	s.Len(tmp, 0)
+}
+
+func (s *StorageSuite) TestMemoryStorage() {
+	s.testStorage(NewMemoryStorage())
+}
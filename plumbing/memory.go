@@ -3,14 +3,23 @@ package plumbing
 import (
 	"bytes"
 	"io"
+
+	format "github.com/go-git/go-git/v6/plumbing/format/config"
 )
 
 // MemoryObject on memory Object implementation
 type MemoryObject struct {
-	t    ObjectType
-	h    Hash
-	cont []byte
-	sz   int64
+	t      ObjectType
+	h      Hash
+	cont   []byte
+	sz     int64
+	format format.ObjectFormat
+}
+
+// NewMemoryObject returns a new MemoryObject whose hash is computed using the
+// given object format. The zero value of MemoryObject uses SHA1.
+func NewMemoryObject(f format.ObjectFormat) *MemoryObject {
+	return &MemoryObject{format: f}
 }
 
 // Hash returns the object Hash, the hash is calculated on-the-fly the first
@@ -19,7 +28,7 @@ type MemoryObject struct {
 // size of the content is exactly the object size.
 func (o *MemoryObject) Hash() Hash {
 	if o.h.IsZero() && int64(len(o.cont)) == o.sz {
-		o.h = ComputeHash(o.t, o.cont)
+		o.h = computeHash(o.format, o.t, o.cont)
 	}
 
 	if o.h.IsZero() {
@@ -16,6 +16,13 @@ import (
 type Change struct {
 	From ChangeEntry
 	To   ChangeEntry
+	// Similarity is the similarity score, between 0 and 100, of the content
+	// of From and To when the change is a detected rename or copy. It's zero
+	// for any other change.
+	Similarity int
+	// Copy is whether the change is a detected copy of From, rather than a
+	// rename.
+	Copy bool
 }
 
 var empty ChangeEntry
@@ -92,7 +99,7 @@ func (c *Change) Patch() (*Patch, error) {
 // If context expires, an non-nil error will be returned
 // Provided context must be non-nil
 func (c *Change) PatchContext(ctx context.Context) (*Patch, error) {
-	return getPatchContext(ctx, "", c)
+	return getPatchContext(ctx, "", nil, c)
 }
 
 func (c *Change) name() string {
@@ -155,5 +162,12 @@ func (c Changes) Patch() (*Patch, error) {
 // If context expires, an non-nil error will be returned
 // Provided context must be non-nil
 func (c Changes) PatchContext(ctx context.Context) (*Patch, error) {
-	return getPatchContext(ctx, "", c...)
+	return getPatchContext(ctx, "", nil, c...)
+}
+
+// PatchWithOptions returns a Patch with all the changes in chunks, generated
+// as described by the given PatchOptions. If context expires, an non-nil
+// error will be returned. Provided context must be non-nil.
+func (c Changes) PatchWithOptions(ctx context.Context, opts *PatchOptions) (*Patch, error) {
+	return getPatchContext(ctx, "", opts, c...)
 }
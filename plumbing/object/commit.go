@@ -9,6 +9,7 @@ import (
 	"strings"
 
 	"github.com/ProtonMail/go-crypto/openpgp"
+	"golang.org/x/crypto/ssh"
 
 	"github.com/go-git/go-git/v6/plumbing"
 	"github.com/go-git/go-git/v6/plumbing/storer"
@@ -98,6 +99,13 @@ func (c *Commit) Tree() (*Tree, error) {
 // NOTE: Since version 5.1.0 the renames are correctly handled, the settings
 // used are the recommended options DefaultDiffTreeOptions.
 func (c *Commit) PatchContext(ctx context.Context, to *Commit) (*Patch, error) {
+	return c.PatchWithOptions(ctx, to, nil)
+}
+
+// PatchWithOptions returns the Patch between the actual commit and the
+// provided one, generated as described by the given PatchOptions. Error will
+// be return if context expires. Provided context must be non-nil.
+func (c *Commit) PatchWithOptions(ctx context.Context, to *Commit, opts *PatchOptions) (*Patch, error) {
 	fromTree, err := c.Tree()
 	if err != nil {
 		return nil, err
@@ -111,7 +119,7 @@ func (c *Commit) PatchContext(ctx context.Context, to *Commit) (*Patch, error) {
 		}
 	}
 
-	return fromTree.PatchContext(ctx, toTree)
+	return fromTree.PatchWithOptions(ctx, toTree, opts)
 }
 
 // Patch returns the Patch between the actual commit and the provided one.
@@ -372,6 +380,14 @@ func (c *Commit) Stats() (FileStats, error) {
 // StatsContext returns the stats of a commit. Error will be return if context
 // expires. Provided context must be non-nil.
 func (c *Commit) StatsContext(ctx context.Context) (FileStats, error) {
+	return c.StatsWithOptions(ctx, nil)
+}
+
+// StatsWithOptions returns the stats of a commit, computed from the patch
+// generated as described by the given PatchOptions, e.g. with a MaxFileSize
+// to skip the huge files. If the context expires, its error is returned
+// instead of partial stats. Provided context must be non-nil.
+func (c *Commit) StatsWithOptions(ctx context.Context, opts *PatchOptions) (FileStats, error) {
 	fromTree, err := c.Tree()
 	if err != nil {
 		return nil, err
@@ -390,8 +406,12 @@ func (c *Commit) StatsContext(ctx context.Context) (FileStats, error) {
 		}
 	}
 
-	patch, err := toTree.PatchContext(ctx, fromTree)
+	patch, err := toTree.PatchWithOptions(ctx, fromTree, opts)
 	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+
 		return nil, err
 	}
 
@@ -415,20 +435,38 @@ func (c *Commit) Verify(armoredKeyRing string) (*openpgp.Entity, error) {
 		return nil, err
 	}
 
-	// Extract signature.
-	signature := strings.NewReader(c.PGPSignature)
+	return c.VerifyKeyRing(keyring)
+}
 
-	encoded := &plumbing.MemoryObject{}
-	// Encode commit components, excluding signature and get a reader object.
-	if err := c.EncodeWithoutSignature(encoded); err != nil {
+// VerifyKeyRing performs PGP verification of the commit with the given keyring
+// and returns openpgp.Entity associated with verifying key on success. If the
+// signature wasn't made by a key of the keyring, or doesn't match the commit,
+// the error returned wraps ErrSignatureMismatch.
+func (c *Commit) VerifyKeyRing(keyring openpgp.KeyRing) (*openpgp.Entity, error) {
+	payload, err := c.signedPayload()
+	if err != nil {
 		return nil, err
 	}
-	er, err := encoded.Reader()
+
+	return verifyPGP(keyring, payload, c.PGPSignature)
+}
+
+// VerifySSH performs SSH signature verification of the commit, as signed with
+// gpg.format=ssh, and returns the key of the allowed ones that made the
+// signature. If the signature wasn't made by an allowed key, or doesn't match
+// the commit, the error returned wraps ErrSignatureMismatch.
+func (c *Commit) VerifySSH(allowed ...ssh.PublicKey) (ssh.PublicKey, error) {
+	payload, err := c.signedPayload()
 	if err != nil {
 		return nil, err
 	}
 
-	return openpgp.CheckArmoredDetachedSignature(keyring, er, signature, nil)
+	return verifySSH(allowed, payload, c.PGPSignature)
+}
+
+// signedPayload returns the content of the commit covered by its signature.
+func (c *Commit) signedPayload() ([]byte, error) {
+	return signedPayload(c.s, plumbing.CommitObject, c.Hash, stripCommitSignature, c.EncodeWithoutSignature)
 }
 
 // Less defines a compare function to determine which commit is 'earlier' by:
@@ -468,6 +468,34 @@ func (s *SuiteCommit) TestStat() {
 	s.Equal(" php/crappy.php | 259 +++++++++++++++++++++++++++++++++++++++++++++++++++++\n", fileStats[1].String())
 }
 
+func (s *SuiteCommit) TestStatsWithOptionsMaxFileSize() {
+	aCommit := s.commit(plumbing.NewHash("918c48b83bd081e863dbe1b80f8998f058cd8294"))
+
+	file, err := aCommit.File("php/crappy.php")
+	s.Require().NoError(err)
+
+	fileStats, err := aCommit.StatsWithOptions(context.Background(), &PatchOptions{
+		MaxFileSize: file.Size - 1,
+	})
+	s.NoError(err)
+
+	s.Equal(FileStats{
+		{Name: "go/example.go", Addition: 142},
+		{Name: "php/crappy.php"},
+	}, fileStats)
+}
+
+func (s *SuiteCommit) TestStatsContextCanceled() {
+	aCommit := s.commit(plumbing.NewHash("918c48b83bd081e863dbe1b80f8998f058cd8294"))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	fileStats, err := aCommit.StatsContext(ctx)
+	s.ErrorIs(err, context.Canceled)
+	s.Nil(fileStats)
+}
+
 func (s *SuiteCommit) TestVerify() {
 	ts := time.Unix(1617402711, 0)
 	loc, _ := time.LoadLocation("UTC")
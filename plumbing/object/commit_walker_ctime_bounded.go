@@ -0,0 +1,138 @@
+package object
+
+import (
+	"io"
+
+	"github.com/emirpasic/gods/trees/binaryheap"
+
+	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/go-git/go-git/v6/plumbing/storer"
+)
+
+// DefaultCTimeSeenWindow is the number of returned commits that the iterator
+// of NewCommitIterCTimeBounded remembers when no window is given.
+const DefaultCTimeSeenWindow = 1024
+
+type commitIteratorByCTimeBounded struct {
+	heap *binaryheap.Heap
+	// queued are the hashes of the commits in the heap.
+	queued map[plumbing.Hash]struct{}
+	// recent are the hashes of the last commits returned, in order in the
+	// ring buffer, oldest at pos.
+	recent map[plumbing.Hash]struct{}
+	ring   []plumbing.Hash
+	pos    int
+}
+
+// NewCommitIterCTimeBounded returns a CommitIter that walks the commit
+// history in committer time order, as NewCommitIterCTime does, with a memory
+// use independent of the length of the history. DefaultCTimeSeenWindow is
+// used if seenWindow isn't positive.
+//
+// Only the hashes of the last seenWindow commits returned are remembered to
+// skip the commits already returned. The commits queued to be returned are
+// not bounded, they grow with the number of branches walked in parallel.
+//
+// Unlike NewCommitIterCTime, the iterator may return a commit more than once:
+// when a commit is older than one of its parents, it may be reached again
+// from another child after it has dropped out of the seenWindow commits
+// remembered, and it is then returned again, as may be its ancestors. This
+// can't happen when the committer time of each commit isn't older than the
+// one of its parents, which is the typical case, and the order is then the
+// same as the one of NewCommitIterCTime. Callers which can't accept
+// duplicates must filter them, or use NewCommitIterCTime.
+func NewCommitIterCTimeBounded(c *Commit, seenWindow int) CommitIter {
+	if seenWindow <= 0 {
+		seenWindow = DefaultCTimeSeenWindow
+	}
+
+	heap := binaryheap.NewWith(func(a, b interface{}) int {
+		if a.(*Commit).Committer.When.Before(b.(*Commit).Committer.When) {
+			return 1
+		}
+		return -1
+	})
+	heap.Push(c)
+
+	return &commitIteratorByCTimeBounded{
+		heap:   heap,
+		queued: map[plumbing.Hash]struct{}{c.Hash: {}},
+		recent: make(map[plumbing.Hash]struct{}, seenWindow),
+		ring:   make([]plumbing.Hash, 0, seenWindow),
+	}
+}
+
+func (w *commitIteratorByCTimeBounded) Next() (*Commit, error) {
+	cIn, ok := w.heap.Pop()
+	if !ok {
+		return nil, io.EOF
+	}
+
+	c := cIn.(*Commit)
+	delete(w.queued, c.Hash)
+	w.remember(c.Hash)
+
+	for _, h := range c.ParentHashes {
+		if w.known(h) {
+			continue
+		}
+
+		pc, err := GetCommit(c.s, h)
+		if err != nil {
+			return nil, err
+		}
+
+		w.queued[h] = struct{}{}
+		w.heap.Push(pc)
+	}
+
+	return c, nil
+}
+
+func (w *commitIteratorByCTimeBounded) known(h plumbing.Hash) bool {
+	if _, ok := w.queued[h]; ok {
+		return true
+	}
+
+	_, ok := w.recent[h]
+	return ok
+}
+
+// remember adds h to the recent commits, forgetting the oldest one once the
+// seen window is full.
+func (w *commitIteratorByCTimeBounded) remember(h plumbing.Hash) {
+	if len(w.ring) < cap(w.ring) {
+		w.ring = append(w.ring, h)
+		w.recent[h] = struct{}{}
+		return
+	}
+
+	delete(w.recent, w.ring[w.pos])
+	w.ring[w.pos] = h
+	w.recent[h] = struct{}{}
+	w.pos = (w.pos + 1) % len(w.ring)
+}
+
+func (w *commitIteratorByCTimeBounded) ForEach(cb func(*Commit) error) error {
+	for {
+		c, err := w.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		err = cb(c)
+		if err == storer.ErrStop {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (w *commitIteratorByCTimeBounded) Close() {}
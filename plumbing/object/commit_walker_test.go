@@ -1,10 +1,14 @@
 package object
 
 import (
+	"fmt"
+	"runtime"
 	"testing"
 	"time"
 
 	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/go-git/go-git/v6/storage/memory"
+	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
 )
 
@@ -287,6 +291,58 @@ func (s *CommitWalkerSuite) TestCommitCTimeIteratorWithIgnore() {
 	}
 }
 
+func (s *CommitWalkerSuite) TestCommitCTimeBoundedIterator() {
+	commit := s.commit(plumbing.NewHash(s.Fixture.Head))
+
+	var expected []plumbing.Hash
+	s.NoError(NewCommitIterCTime(commit, nil, nil).ForEach(func(c *Commit) error {
+		expected = append(expected, c.Hash)
+		return nil
+	}))
+
+	for _, window := range []int{0, 2} {
+		var hashes []plumbing.Hash
+		s.NoError(NewCommitIterCTimeBounded(commit, window).ForEach(func(c *Commit) error {
+			hashes = append(hashes, c.Hash)
+			return nil
+		}))
+
+		s.Equal(expected, hashes)
+	}
+}
+
+func (s *CommitWalkerSuite) TestCommitCTimeBoundedIteratorDuplicates() {
+	sto := memory.NewStorage()
+	base := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	// parent <- older <- merge and parent <- newer <- merge, with older
+	// committed before its parent.
+	parent := storeCommit(s.T(), sto, base.Add(5*time.Hour), nil)
+	older := storeCommit(s.T(), sto, base.Add(time.Hour), []plumbing.Hash{parent})
+	newer := storeCommit(s.T(), sto, base.Add(9*time.Hour), []plumbing.Hash{parent})
+	merge := storeCommit(s.T(), sto, base.Add(10*time.Hour), []plumbing.Hash{older, newer})
+
+	commit, err := GetCommit(sto, merge)
+	s.Require().NoError(err)
+
+	walk := func(it CommitIter) []plumbing.Hash {
+		var hashes []plumbing.Hash
+		s.NoError(it.ForEach(func(c *Commit) error {
+			hashes = append(hashes, c.Hash)
+			return nil
+		}))
+		return hashes
+	}
+
+	expected := []plumbing.Hash{merge, newer, parent, older}
+	s.Equal(expected, walk(NewCommitIterCTime(commit, nil, nil)))
+	s.Equal(expected, walk(NewCommitIterCTimeBounded(commit, 0)))
+
+	// With a window too small to remember it, parent is returned again when
+	// reached from older, which is committed before it.
+	s.Equal(append(expected, parent), walk(NewCommitIterCTimeBounded(commit, 1)))
+}
+
 func (s *CommitWalkerSuite) TestCommitBSFIterator() {
 	commit := s.commit(plumbing.NewHash(s.Fixture.Head))
 
@@ -360,3 +416,102 @@ func (s *CommitWalkerSuite) TestCommitPathIteratorInitialCommit() {
 		s.Equal(expected[i], commit.Hash.String())
 	}
 }
+
+// storeCommit stores a commit with an empty tree, committed at the given time.
+func storeCommit(t testing.TB, sto *memory.Storage, when time.Time, parents []plumbing.Hash) plumbing.Hash {
+	sig := Signature{Name: "foo", Email: "foo@foo.foo", When: when}
+	c := &Commit{
+		Author:       sig,
+		Committer:    sig,
+		Message:      fmt.Sprintf("commit at %s\n", when),
+		TreeHash:     plumbing.NewHash("4b825dc642cb6eb9a060e54bf8d69288fbee4904"),
+		ParentHashes: parents,
+	}
+
+	obj := sto.NewEncodedObject()
+	require.NoError(t, c.Encode(obj))
+	h, err := sto.SetEncodedObject(obj)
+	require.NoError(t, err)
+	return h
+}
+
+// storeLongHistory stores a history of n commits, made of a main line where
+// a topic branch of 5 commits is merged every 20 commits.
+func storeLongHistory(b *testing.B, n int) *Commit {
+	sto := memory.NewStorage()
+	when := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	var head plumbing.Hash
+	for i := 0; i < n; i++ {
+		when = when.Add(time.Minute)
+
+		var parents []plumbing.Hash
+		if !head.IsZero() {
+			parents = append(parents, head)
+		}
+
+		if i%20 == 19 {
+			topic := parents[0]
+			for j := 0; j < 5; j++ {
+				topic = storeCommit(b, sto, when, []plumbing.Hash{topic})
+				when = when.Add(time.Second)
+			}
+			parents = append(parents, topic)
+		}
+
+		head = storeCommit(b, sto, when, parents)
+	}
+
+	c, err := GetCommit(sto, head)
+	require.NoError(b, err)
+	return c
+}
+
+// benchmarkCommitIter walks the history with the iterator returned by iter,
+// reporting the memory still retained by the iterator once the walk ends.
+func benchmarkCommitIter(b *testing.B, iter func(*Commit) CommitIter) {
+	for _, n := range []int{1000, 10000} {
+		b.Run(fmt.Sprint(n), func(b *testing.B) {
+			head := storeLongHistory(b, n)
+
+			b.ReportAllocs()
+			b.ResetTimer()
+
+			var retained uint64
+			for i := 0; i < b.N; i++ {
+				var before, after runtime.MemStats
+				runtime.GC()
+				runtime.ReadMemStats(&before)
+
+				it := iter(head)
+				count := 0
+				require.NoError(b, it.ForEach(func(*Commit) error {
+					count++
+					return nil
+				}))
+
+				runtime.GC()
+				runtime.ReadMemStats(&after)
+				runtime.KeepAlive(it)
+
+				if after.HeapAlloc > before.HeapAlloc {
+					retained += after.HeapAlloc - before.HeapAlloc
+				}
+			}
+
+			b.ReportMetric(float64(retained)/float64(b.N), "retained-B/op")
+		})
+	}
+}
+
+func BenchmarkCommitIterCTime(b *testing.B) {
+	benchmarkCommitIter(b, func(c *Commit) CommitIter {
+		return NewCommitIterCTime(c, nil, nil)
+	})
+}
+
+func BenchmarkCommitIterCTimeBounded(b *testing.B) {
+	benchmarkCommitIter(b, func(c *Commit) CommitIter {
+		return NewCommitIterCTimeBounded(c, 0)
+	})
+}
@@ -1,13 +1,18 @@
 package commitgraph
 
 import (
+	"errors"
 	"fmt"
+	"io"
+	"os"
 	"time"
 
 	"github.com/go-git/go-git/v6/plumbing"
 	commitgraph "github.com/go-git/go-git/v6/plumbing/format/commitgraph"
 	"github.com/go-git/go-git/v6/plumbing/object"
 	"github.com/go-git/go-git/v6/plumbing/storer"
+
+	"github.com/go-git/go-billy/v5"
 )
 
 // graphCommitNode is a reduced representation of Commit as presented in the commit
@@ -40,6 +45,30 @@ func NewGraphCommitNodeIndex(commitGraph commitgraph.Index, s storer.EncodedObje
 	return &graphCommitNodeIndex{commitGraph, s}
 }
 
+// Open returns a CommitNodeIndex backed by the commit-graph file, or chain of
+// files, found in the given .git directory, falling back to the object storage
+// for the commits not included in them. When there is no commit-graph only
+// the object storage is used.
+//
+// The returned io.Closer releases the commit-graph files and must be called
+// once the index is no longer used.
+func Open(fs billy.Filesystem, s storer.EncodedObjectStorer) (CommitNodeIndex, io.Closer, error) {
+	index, err := commitgraph.OpenChainOrFileIndex(fs)
+	if errors.Is(err, os.ErrNotExist) {
+		return NewObjectCommitNodeIndex(s), nopCloser{}, nil
+	}
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return NewGraphCommitNodeIndex(index, s), index, nil
+}
+
+type nopCloser struct{}
+
+func (nopCloser) Close() error { return nil }
+
 func (gci *graphCommitNodeIndex) Get(hash plumbing.Hash) (CommitNode, error) {
 	if gci.commitGraph != nil {
 		// Check the commit graph first
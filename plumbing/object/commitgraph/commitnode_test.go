@@ -4,6 +4,7 @@ import (
 	"path"
 	"testing"
 
+	"github.com/go-git/go-billy/v5/memfs"
 	"github.com/go-git/go-git/v6/plumbing"
 	"github.com/go-git/go-git/v6/plumbing/cache"
 	commitgraph "github.com/go-git/go-git/v6/plumbing/format/commitgraph"
@@ -97,6 +98,57 @@ func testCommitAndTree(s *CommitNodeSuite, nodeIndex CommitNodeIndex) {
 	s.Equal(merge3commit.TreeHash.String(), tree.ID().String())
 }
 
+func testMergeBase(s *CommitNodeSuite, nodeIndex CommitNodeIndex) {
+	get := func(h string) CommitNode {
+		n, err := nodeIndex.Get(plumbing.NewHash(h))
+		s.Require().NoError(err)
+		return n
+	}
+
+	ids := func(nodes []CommitNode) []string {
+		var res []string
+		for _, n := range nodes {
+			res = append(res, n.ID().String())
+		}
+		return res
+	}
+
+	head := get("b9d69064b190e7aedccf84731ca1d917871f8a1c")
+	octopus := get("6f6c5d2be7852c782be1dd13e36496dd7ad39560")
+	merge1 := get("b29328491a0682c259bcce28741eac71f3499f7d")
+	merge2 := get("d2dc5ac04916e156018db4482c40c39b894090e9")
+
+	bases, err := MergeBase(merge1, merge2)
+	s.NoError(err)
+	s.Equal([]string{"03d2c021ff68954cf3ef0a36825e194a4b98f981"}, ids(bases))
+
+	// criss-cross, both parents of merge1 are reachable from the octopus merge
+	bases, err = MergeBase(merge1, octopus)
+	s.NoError(err)
+	s.Equal([]string{
+		"03d2c021ff68954cf3ef0a36825e194a4b98f981",
+		"e713b52d7e13807e87a002e812041f248db3f643",
+	}, ids(bases))
+
+	// the third parent of the octopus merge is stored in the extra edges
+	third := get("a45273fe2d63300e1962a9e26a6b15c276cd7082")
+	bases, err = MergeBase(head, third)
+	s.NoError(err)
+	s.Equal([]string{third.ID().String()}, ids(bases))
+
+	ok, err := IsAncestor(third, head)
+	s.NoError(err)
+	s.True(ok)
+
+	ok, err = IsAncestor(head, third)
+	s.NoError(err)
+	s.False(ok)
+
+	ok, err = IsAncestor(merge2, head)
+	s.NoError(err)
+	s.False(ok)
+}
+
 func (s *CommitNodeSuite) TestObjectGraph() {
 	f := fixtures.ByTag("commit-graph").One()
 	storer := unpackRepository(f)
@@ -105,6 +157,7 @@ func (s *CommitNodeSuite) TestObjectGraph() {
 	testWalker(s, nodeIndex)
 	testParents(s, nodeIndex)
 	testCommitAndTree(s, nodeIndex)
+	testMergeBase(s, nodeIndex)
 }
 
 func (s *CommitNodeSuite) TestCommitGraph() {
@@ -121,6 +174,7 @@ func (s *CommitNodeSuite) TestCommitGraph() {
 	testWalker(s, nodeIndex)
 	testParents(s, nodeIndex)
 	testCommitAndTree(s, nodeIndex)
+	testMergeBase(s, nodeIndex)
 }
 
 func (s *CommitNodeSuite) TestMixedGraph() {
@@ -150,4 +204,28 @@ func (s *CommitNodeSuite) TestMixedGraph() {
 	testWalker(s, nodeIndex)
 	testParents(s, nodeIndex)
 	testCommitAndTree(s, nodeIndex)
+	testMergeBase(s, nodeIndex)
+}
+
+func (s *CommitNodeSuite) TestOpen() {
+	f := fixtures.ByTag("commit-graph").One()
+	storer := unpackRepository(f)
+
+	nodeIndex, closer, err := Open(storer.Filesystem(), storer)
+	s.NoError(err)
+	defer closer.Close()
+
+	s.IsType(&graphCommitNodeIndex{}, nodeIndex)
+	testWalker(s, nodeIndex)
+	testParents(s, nodeIndex)
+	testMergeBase(s, nodeIndex)
+}
+
+func (s *CommitNodeSuite) TestOpenWithoutCommitGraph() {
+	storer := filesystem.NewStorage(memfs.New(), cache.NewObjectLRUDefault())
+
+	nodeIndex, closer, err := Open(storer.Filesystem(), storer)
+	s.NoError(err)
+	s.NoError(closer.Close())
+	s.IsType(&objectCommitNodeIndex{}, nodeIndex)
 }
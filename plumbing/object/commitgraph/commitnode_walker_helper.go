@@ -107,7 +107,7 @@ func generationAndDateOrderComparator(left, right interface{}) int {
 		return -1
 	}
 
-	if rightCommit.GenerationV2() == math.MaxInt64 {
+	if rightCommit.GenerationV2() == math.MaxUint64 {
 		// the right is not in the graph, therefore the left is before the right
 		return 1
 	}
@@ -0,0 +1,57 @@
+package commitgraph
+
+import (
+	"github.com/go-git/go-git/v6/internal/mergebase"
+	"github.com/go-git/go-git/v6/plumbing"
+)
+
+// MergeBase mimics the behavior of `git merge-base a b`, returning the best
+// common ancestors of the given commits. The best common ancestors can not be
+// reached from other common ancestors.
+//
+// The history is walked in generation order, so when the nodes come from a
+// commit-graph the walk stops as soon as the remaining commits can't lead to
+// a better common ancestor, without loading any commit object.
+func MergeBase(a, b CommitNode) ([]CommitNode, error) {
+	if a.ID() == b.ID() {
+		return []CommitNode{a}, nil
+	}
+
+	candidates, err := mergebase.PaintDownToCommon(nodeGraph{}, a, []CommitNode{b})
+	if err != nil {
+		return nil, err
+	}
+
+	return mergebase.Independents(nodeGraph{}, candidates)
+}
+
+// IsAncestor returns true if the ancestor commit is reachable from the given
+// commit. Generation numbers are used to avoid walking the parts of the
+// history that can't contain the ancestor.
+func IsAncestor(ancestor, c CommitNode) (bool, error) {
+	return mergebase.Reachable(nodeGraph{}, ancestor, []CommitNode{c})
+}
+
+// nodeGraph implements mergebase.Graph over the commit nodes, using the
+// generations stored in the commit-graph.
+type nodeGraph struct{}
+
+func (nodeGraph) ID(c CommitNode) plumbing.Hash {
+	return c.ID()
+}
+
+func (nodeGraph) ParentHashes(c CommitNode) []plumbing.Hash {
+	return c.ParentHashes()
+}
+
+func (nodeGraph) Parent(c CommitNode, i int) (CommitNode, error) {
+	return c.ParentNode(i)
+}
+
+func (nodeGraph) Generation(c CommitNode) (uint64, error) {
+	return c.Generation(), nil
+}
+
+func (nodeGraph) Compare(a, b CommitNode) int {
+	return generationAndDateOrderComparator(a, b)
+}
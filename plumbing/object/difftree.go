@@ -37,11 +37,34 @@ type DiffTreeOptions struct {
 	// That means, that if 100 files were deleted and 50 files were added, 5000
 	// file comparisons may be needed. So, if the rename limit is 50, the number
 	// of both deleted and added needs to be equal or less than 50.
-	// A value of 0 means no limit.
+	// A value of 0 means no limit. When the limit is exceeded, only exact
+	// renames are detected, as git does, see DiffTreeWithOptionsReport to
+	// know when it happens. The default is DefaultRenameLimit.
 	RenameLimit uint
 	// OnlyExactRenames performs only detection of exact renames and will not perform
 	// any detection of renames based on file similarity.
 	OnlyExactRenames bool
+	// DetectCopies is whether the diff tree will detect copies, reporting
+	// the added files whose content is similar to any file of the source
+	// tree, even an unchanged one, as a copy of it. The similarity threshold
+	// and limits are the same used for renames.
+	DetectCopies bool
+	// Scorer scores the similarity of the content of the files when
+	// detecting renames and copies. If nil, the files are compared by hashing
+	// their lines, giving a small weight to the similarity of their paths.
+	Scorer SimilarityScorer
+}
+
+// DefaultRenameLimit is the default RenameLimit, the same as the default
+// diff.renameLimit of git.
+const DefaultRenameLimit = 1000
+
+// DiffTreeReport gives details about how a diff tree was performed.
+type DiffTreeReport struct {
+	// RenameLimitExceeded is whether the detection of renames, or copies,
+	// based on the similarity of the files was skipped, as there were more
+	// candidates than RenameLimit. Exact renames are detected anyway.
+	RenameLimitExceeded bool
 }
 
 // DefaultDiffTreeOptions are the default and recommended options for the
@@ -49,7 +72,7 @@ type DiffTreeOptions struct {
 var DefaultDiffTreeOptions = &DiffTreeOptions{
 	DetectRenames:    true,
 	RenameScore:      60,
-	RenameLimit:      0,
+	RenameLimit:      DefaultRenameLimit,
 	OnlyExactRenames: false,
 }
 
@@ -66,6 +89,17 @@ func DiffTreeWithOptions(
 	a, b *Tree,
 	opts *DiffTreeOptions,
 ) (Changes, error) {
+	changes, _, err := DiffTreeWithOptionsReport(ctx, a, b, opts)
+	return changes, err
+}
+
+// DiffTreeWithOptionsReport is like DiffTreeWithOptions, also returning a
+// report telling, e.g., whether rename detection was limited.
+func DiffTreeWithOptionsReport(
+	ctx context.Context,
+	a, b *Tree,
+	opts *DiffTreeOptions,
+) (Changes, *DiffTreeReport, error) {
 	from := NewTreeRootNode(a)
 	to := NewTreeRootNode(b)
 
@@ -76,23 +110,34 @@ func DiffTreeWithOptions(
 	merkletrieChanges, err := merkletrie.DiffTreeContext(ctx, from, to, hashEqual)
 	if err != nil {
 		if err == merkletrie.ErrCanceled {
-			return nil, ErrCanceled
+			return nil, nil, ErrCanceled
 		}
-		return nil, err
+		return nil, nil, err
 	}
 
 	changes, err := newChanges(merkletrieChanges)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	if opts == nil {
 		opts = new(DiffTreeOptions)
 	}
 
+	report := &DiffTreeReport{}
 	if opts.DetectRenames {
-		return DetectRenames(changes, opts)
+		changes, err = detectRenamesWithReport(changes, opts, report)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if opts.DetectCopies {
+		changes, err = detectCopies(changes, a, opts, report)
+		if err != nil {
+			return nil, nil, err
+		}
 	}
 
-	return changes, nil
+	return changes, report, nil
 }
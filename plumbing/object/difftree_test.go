@@ -1,6 +1,7 @@
 package object
 
 import (
+	"context"
 	"fmt"
 	"sort"
 	"testing"
@@ -14,6 +15,7 @@ import (
 	"github.com/go-git/go-git/v6/storage/filesystem"
 	"github.com/go-git/go-git/v6/storage/memory"
 	"github.com/go-git/go-git/v6/utils/merkletrie"
+	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
 )
 
@@ -376,3 +378,125 @@ func (s *DiffTreeSuite) TestIssue279() {
 	}
 	s.NotEqual(bb.Hash(), b.Hash())
 }
+
+// storeTree stores a flat tree with the given files and their content.
+func storeTree(t testing.TB, sto *memory.Storage, files map[string]string) *Tree {
+	t.Helper()
+
+	tree := &Tree{}
+	for name, content := range files {
+		obj := sto.NewEncodedObject()
+		obj.SetType(plumbing.BlobObject)
+		w, err := obj.Writer()
+		require.NoError(t, err)
+		_, err = w.Write([]byte(content))
+		require.NoError(t, err)
+		require.NoError(t, w.Close())
+
+		h, err := sto.SetEncodedObject(obj)
+		require.NoError(t, err)
+
+		tree.Entries = append(tree.Entries, TreeEntry{Name: name, Mode: filemode.Regular, Hash: h})
+	}
+	sort.Slice(tree.Entries, func(i, j int) bool { return tree.Entries[i].Name < tree.Entries[j].Name })
+
+	obj := sto.NewEncodedObject()
+	require.NoError(t, tree.Encode(obj))
+	h, err := sto.SetEncodedObject(obj)
+	require.NoError(t, err)
+
+	tree, err = GetTree(sto, h)
+	require.NoError(t, err)
+	return tree
+}
+
+const (
+	diffTreeContent        = "a\nb\nc\nd\ne\nf\ng\nh\ni\nj\n"
+	diffTreeSimilarContent = "a\nb\nc\nd\ne\nf\ng\nh\ni\nk\n"
+)
+
+func (s *DiffTreeSuite) TestDiffTreeWithOptionsRenameSimilarity() {
+	sto := memory.NewStorage()
+	from := storeTree(s.T(), sto, map[string]string{
+		"exact":   diffTreeContent,
+		"similar": diffTreeContent + "x\n",
+		"kept":    "kept\n",
+	})
+	to := storeTree(s.T(), sto, map[string]string{
+		"exact-renamed":   diffTreeContent,
+		"similar-renamed": diffTreeSimilarContent + "x\n",
+		"kept":            "kept\n",
+	})
+
+	changes, err := DiffTreeWithOptions(context.Background(), from, to, &DiffTreeOptions{
+		DetectRenames: true,
+		RenameScore:   50,
+	})
+	s.Require().NoError(err)
+	s.Require().Len(changes, 2)
+
+	s.Equal("exact", changes[0].From.Name)
+	s.Equal("exact-renamed", changes[0].To.Name)
+	s.Equal(100, changes[0].Similarity)
+	s.False(changes[0].Copy)
+
+	s.Equal("similar", changes[1].From.Name)
+	s.Equal("similar-renamed", changes[1].To.Name)
+	s.Greater(changes[1].Similarity, 50)
+	s.Less(changes[1].Similarity, 100)
+	s.False(changes[1].Copy)
+}
+
+func (s *DiffTreeSuite) TestDiffTreeWithOptionsDetectCopies() {
+	sto := memory.NewStorage()
+	from := storeTree(s.T(), sto, map[string]string{
+		"orig":  diffTreeContent,
+		"other": "other\n",
+	})
+	to := storeTree(s.T(), sto, map[string]string{
+		"orig":      diffTreeContent,
+		"other":     "other\n",
+		"copy":      diffTreeContent,
+		"similar":   diffTreeSimilarContent,
+		"unrelated": "1\n2\n3\n",
+	})
+
+	opts := &DiffTreeOptions{DetectRenames: true, RenameScore: 50}
+	changes, err := DiffTreeWithOptions(context.Background(), from, to, opts)
+	s.Require().NoError(err)
+	s.Require().Len(changes, 3)
+	for _, c := range changes {
+		s.False(c.Copy)
+		s.Zero(c.Similarity)
+	}
+
+	opts.DetectCopies = true
+	changes, err = DiffTreeWithOptions(context.Background(), from, to, opts)
+	s.Require().NoError(err)
+	s.Require().Len(changes, 3)
+
+	s.Equal("orig", changes[0].From.Name)
+	s.Equal("copy", changes[0].To.Name)
+	s.Equal(100, changes[0].Similarity)
+	s.True(changes[0].Copy)
+
+	s.Equal("orig", changes[1].From.Name)
+	s.Equal("similar", changes[1].To.Name)
+	s.Greater(changes[1].Similarity, 50)
+	s.Less(changes[1].Similarity, 100)
+	s.True(changes[1].Copy)
+
+	action, err := changes[2].Action()
+	s.NoError(err)
+	s.Equal(merkletrie.Insert, action)
+	s.Equal("unrelated", changes[2].To.Name)
+	s.False(changes[2].Copy)
+
+	opts.OnlyExactRenames = true
+	changes, err = DiffTreeWithOptions(context.Background(), from, to, opts)
+	s.Require().NoError(err)
+	s.Require().Len(changes, 3)
+	s.True(changes[0].Copy)
+	s.False(changes[1].Copy)
+	s.False(changes[2].Copy)
+}
@@ -0,0 +1,202 @@
+package object
+
+import (
+	"bufio"
+	"io"
+	"strings"
+)
+
+// MailMap maps the names and emails found in commits to canonical ones, as
+// described by a .mailmap file. The emails and names are matched ignoring
+// their case.
+//
+// See https://git-scm.com/docs/gitmailmap
+type MailMap struct {
+	// entries are keyed by the lowercase commit email.
+	entries map[string]*mailMapEntry
+}
+
+type mailMapEntry struct {
+	mailMapInfo
+	// names holds the replacements of the commits with a given name, keyed
+	// by the lowercase commit name.
+	names map[string]*mailMapInfo
+}
+
+// mailMapInfo holds the proper name and email, any of them empty when it is
+// not replaced.
+type mailMapInfo struct {
+	name, email string
+}
+
+// NewMailMap returns an empty MailMap.
+func NewMailMap() *MailMap {
+	return &MailMap{entries: make(map[string]*mailMapEntry)}
+}
+
+// ParseMailMap parses the content of a .mailmap file.
+func ParseMailMap(r io.Reader) (*MailMap, error) {
+	m := NewMailMap()
+	if err := m.Read(r); err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+// Read adds the entries of a .mailmap file to the map, taking precedence
+// over the existing ones. Each line takes one of the forms:
+//
+//	Proper Name <commit@email>
+//	<proper@email> <commit@email>
+//	Proper Name <proper@email> <commit@email>
+//	Proper Name <proper@email> Commit Name <commit@email>
+//
+// Blank lines, lines starting with '#' and malformed lines are ignored, as
+// is anything after the last email.
+func (m *MailMap) Read(r io.Reader) error {
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		line := s.Text()
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		name1, email1, rest, ok := parseMailMapNameAndEmail(line)
+		if !ok {
+			continue
+		}
+
+		if name2, email2, _, ok := parseMailMapNameAndEmail(rest); ok {
+			m.Add(name1, email1, name2, email2)
+		} else {
+			m.Add(name1, "", "", email1)
+		}
+	}
+
+	return s.Err()
+}
+
+// Add maps the commits with the given email, and name if not empty, to the
+// proper name and email. An empty proper name or email keeps the one of the
+// commit.
+func (m *MailMap) Add(properName, properEmail, commitName, commitEmail string) {
+	key := strings.ToLower(commitEmail)
+	e, ok := m.entries[key]
+	if !ok {
+		e = &mailMapEntry{}
+		m.entries[key] = e
+	}
+
+	info := &e.mailMapInfo
+	if commitName != "" {
+		if e.names == nil {
+			e.names = make(map[string]*mailMapInfo)
+		}
+
+		nameKey := strings.ToLower(commitName)
+		if info, ok = e.names[nameKey]; !ok {
+			info = &mailMapInfo{}
+			e.names[nameKey] = info
+		}
+	}
+
+	if properName != "" {
+		info.name = properName
+	}
+
+	if properEmail != "" {
+		info.email = properEmail
+	}
+}
+
+// Map returns the canonical name and email of the given ones. The entries
+// matching both the name and the email take precedence over the ones only
+// matching the email.
+func (m *MailMap) Map(name, email string) (string, string) {
+	if m == nil {
+		return name, email
+	}
+
+	e, ok := m.entries[strings.ToLower(email)]
+	if !ok {
+		return name, email
+	}
+
+	info := &e.mailMapInfo
+	if named, ok := e.names[strings.ToLower(name)]; ok {
+		info = named
+	}
+
+	if info.name != "" {
+		name = info.name
+	}
+
+	if info.email != "" {
+		email = info.email
+	}
+
+	return name, email
+}
+
+// parseMailMapNameAndEmail parses a name followed by an email between angle
+// brackets, returning what follows the email. The name is empty if missing.
+func parseMailMapNameAndEmail(s string) (name, email, rest string, ok bool) {
+	open := strings.IndexByte(s, '<')
+	if open < 0 {
+		return "", "", "", false
+	}
+
+	end := strings.IndexByte(s[open:], '>')
+	if end < 0 {
+		return "", "", "", false
+	}
+
+	end += open
+	return strings.TrimSpace(s[:open]), s[open+1 : end], s[end+1:], true
+}
+
+// Canonical returns the signature with the name and email replaced by the
+// canonical ones of the given MailMap, which can be nil.
+func (s Signature) Canonical(mm *MailMap) Signature {
+	s.Name, s.Email = mm.Map(s.Name, s.Email)
+	return s
+}
+
+type commitMailMapIter struct {
+	sourceIter CommitIter
+	mailMap    *MailMap
+}
+
+// NewCommitMailMapIterFromIter returns a CommitIter returning the commits of
+// the given one with their author and committer replaced by their canonical
+// ones, as `git log --use-mailmap` does. The commits returned are copies, the
+// ones of the source iterator are left untouched.
+func NewCommitMailMapIterFromIter(mm *MailMap, commitIter CommitIter) CommitIter {
+	return &commitMailMapIter{sourceIter: commitIter, mailMap: mm}
+}
+
+func (c *commitMailMapIter) Next() (*Commit, error) {
+	commit, err := c.sourceIter.Next()
+	if commit == nil {
+		return nil, err
+	}
+
+	mapped := *commit
+	mapped.Author = commit.Author.Canonical(c.mailMap)
+	mapped.Committer = commit.Committer.Canonical(c.mailMap)
+	return &mapped, err
+}
+
+func (c *commitMailMapIter) ForEach(cb func(*Commit) error) error {
+	return c.sourceIter.ForEach(func(commit *Commit) error {
+		mapped := *commit
+		mapped.Author = commit.Author.Canonical(c.mailMap)
+		mapped.Committer = commit.Committer.Canonical(c.mailMap)
+		return cb(&mapped)
+	})
+}
+
+func (c *commitMailMapIter) Close() {
+	c.sourceIter.Close()
+}
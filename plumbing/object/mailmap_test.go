@@ -0,0 +1,98 @@
+package object
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/stretchr/testify/suite"
+)
+
+type MailMapSuite struct {
+	suite.Suite
+	BaseObjectsSuite
+}
+
+func (s *MailMapSuite) SetupSuite() {
+	s.BaseObjectsSuite.SetupSuite(s.T())
+}
+
+func TestMailMapSuite(t *testing.T) {
+	suite.Run(t, new(MailMapSuite))
+}
+
+const mailMapFixture = `# Comments and blank lines are ignored
+
+Proper Name <commit@example.com>
+<proper@example.com> <Other@Example.com>
+Both Name <both@example.com> <old-both@example.com>
+Joe Developer <joe@example.com> Joe <bugs@example.com>
+Jane Developer <jane@example.com> jane <BUGS@example.com>
+not an entry
+`
+
+func (s *MailMapSuite) TestParseMailMap() {
+	mm, err := ParseMailMap(strings.NewReader(mailMapFixture))
+	s.Require().NoError(err)
+
+	for _, t := range []struct {
+		name, email       string
+		expName, expEmail string
+	}{
+		// Proper Name <commit@email>
+		{"Commit", "commit@example.com", "Proper Name", "commit@example.com"},
+		{"Commit", "COMMIT@example.com", "Proper Name", "COMMIT@example.com"},
+		// <proper@email> <commit@email>
+		{"Other", "other@example.com", "Other", "proper@example.com"},
+		// Proper Name <proper@email> <commit@email>
+		{"Old", "old-both@example.com", "Both Name", "both@example.com"},
+		// Proper Name <proper@email> Commit Name <commit@email>
+		{"Joe", "bugs@example.com", "Joe Developer", "joe@example.com"},
+		{"JANE", "bugs@example.com", "Jane Developer", "jane@example.com"},
+		{"Someone", "bugs@example.com", "Someone", "bugs@example.com"},
+		// Not mapped.
+		{"Unknown", "unknown@example.com", "Unknown", "unknown@example.com"},
+	} {
+		name, email := mm.Map(t.name, t.email)
+		s.Equal(t.expName, name, t.email)
+		s.Equal(t.expEmail, email, t.email)
+	}
+}
+
+func (s *MailMapSuite) TestReadOverrides() {
+	mm, err := ParseMailMap(strings.NewReader("Old <old@example.com> <foo@example.com>\n"))
+	s.Require().NoError(err)
+	s.Require().NoError(mm.Read(strings.NewReader("New <foo@example.com>\n")))
+
+	name, email := mm.Map("Foo", "foo@example.com")
+	s.Equal("New", name)
+	s.Equal("old@example.com", email)
+}
+
+func (s *MailMapSuite) TestSignatureCanonical() {
+	mm := NewMailMap()
+	mm.Add("Proper", "proper@example.com", "", "commit@example.com")
+
+	sig := Signature{Name: "Commit", Email: "commit@example.com"}
+	s.Equal(Signature{Name: "Proper", Email: "proper@example.com"}, sig.Canonical(mm))
+	s.Equal("Commit", sig.Name)
+	s.Equal(sig, sig.Canonical(nil))
+}
+
+func (s *MailMapSuite) TestCommitMailMapIter() {
+	head := s.commit(plumbing.NewHash("6ecf0ef2c2dffb796033e5a02219af86ec6584e5"))
+
+	mm := NewMailMap()
+	mm.Add("Máximo", "maximo@example.com", "", head.Author.Email)
+
+	var authors []string
+	it := NewCommitMailMapIterFromIter(mm, NewCommitPreorderIter(head, nil, nil))
+	s.Require().NoError(it.ForEach(func(c *Commit) error {
+		authors = append(authors, c.Author.Name+" <"+c.Author.Email+">")
+		return nil
+	}))
+
+	s.Len(authors, 8)
+	s.Equal("Máximo <maximo@example.com>", authors[0])
+	s.Equal("Máximo Cuadros Ortiz", head.Author.Name)
+}
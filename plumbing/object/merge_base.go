@@ -1,185 +1,148 @@
 package object
 
 import (
-	"fmt"
-	"sort"
-
+	"github.com/go-git/go-git/v6/internal/mergebase"
 	"github.com/go-git/go-git/v6/plumbing"
-	"github.com/go-git/go-git/v6/plumbing/storer"
 )
 
-// errIsReachable is thrown when first commit is an ancestor of the second
-var errIsReachable = fmt.Errorf("first is reachable from second")
-
 // MergeBase mimics the behavior of `git merge-base actual other`, returning the
 // best common ancestor between the actual and the passed one.
 // The best common ancestors can not be reached from other common ancestors.
 func (c *Commit) MergeBase(other *Commit) ([]*Commit, error) {
-	// use sortedByCommitDateDesc strategy
-	sorted := sortByCommitDateDesc(c, other)
-	newer := sorted[0]
-	older := sorted[1]
-
-	newerHistory, err := ancestorsIndex(older, newer)
-	if err == errIsReachable {
-		return []*Commit{older}, nil
-	}
-
-	if err != nil {
-		return nil, err
-	}
-
-	var res []*Commit
-	inNewerHistory := isInIndexCommitFilter(newerHistory)
-	resIter := NewFilterCommitIter(older, &inNewerHistory, &inNewerHistory)
-	_ = resIter.ForEach(func(commit *Commit) error {
-		res = append(res, commit)
-		return nil
-	})
-
-	return Independents(res)
+	return MergeBaseMultiple(c, other)
 }
 
 // IsAncestor returns true if the actual commit is ancestor of the passed one.
 // It returns an error if the history is not transversable
 // It mimics the behavior of `git merge --is-ancestor actual other`
 func (c *Commit) IsAncestor(other *Commit) (bool, error) {
-	found := false
-	iter := NewCommitPreorderIter(other, nil, nil)
-	err := iter.ForEach(func(comm *Commit) error {
-		if comm.Hash != c.Hash {
-			return nil
-		}
-
-		found = true
-		return storer.ErrStop
-	})
-
-	return found, err
+	return IsAncestor(c, other)
 }
 
-// ancestorsIndex returns a map with the ancestors of the starting commit if the
-// excluded one is not one of them. It returns errIsReachable if the excluded commit
-// is ancestor of the starting, or another error if the history is not traversable.
-func ancestorsIndex(excluded, starting *Commit) (map[plumbing.Hash]struct{}, error) {
-	if excluded.Hash.String() == starting.Hash.String() {
-		return nil, errIsReachable
-	}
-
-	startingHistory := map[plumbing.Hash]struct{}{}
-	startingIter := NewCommitIterBSF(starting, nil, nil)
-	err := startingIter.ForEach(func(commit *Commit) error {
-		if commit.Hash == excluded.Hash {
-			return errIsReachable
+// MergeBaseMultiple mimics the behavior of `git merge-base --all one twos...`,
+// returning the best common ancestors between one and a hypothetical merge of
+// all the twos. The best common ancestors can not be reached from other common
+// ancestors.
+//
+// The history is walked in generation order, which follows the topology of
+// the history, so the result is right even when the commit dates are skewed.
+func MergeBaseMultiple(one *Commit, twos ...*Commit) ([]*Commit, error) {
+	for _, two := range twos {
+		if one.Hash == two.Hash {
+			return []*Commit{one}, nil
 		}
+	}
 
-		startingHistory[commit.Hash] = struct{}{}
-		return nil
-	})
-
+	gens := make(generations)
+	candidates, err := mergebase.PaintDownToCommon(gens, one, twos)
 	if err != nil {
 		return nil, err
 	}
 
-	return startingHistory, nil
+	return mergebase.Independents(gens, candidates)
+}
+
+// IsAncestor returns true if the ancestor commit is reachable from the given
+// commit, or is the same commit. It mimics the behavior of
+// `git merge-base --is-ancestor ancestor c`.
+//
+// The commits with a generation lower than the one of the ancestor are not
+// walked, since the ancestor can't be reached from them.
+func IsAncestor(ancestor, c *Commit) (bool, error) {
+	return mergebase.Reachable(make(generations), ancestor, []*Commit{c})
 }
 
 // Independents returns a subset of the passed commits, that are not reachable the others
 // It mimics the behavior of `git merge-base --independent commit...`.
 func Independents(commits []*Commit) ([]*Commit, error) {
-	// use sortedByCommitDateDesc strategy
-	candidates := sortByCommitDateDesc(commits...)
-	candidates = removeDuplicated(candidates)
-
-	seen := map[plumbing.Hash]struct{}{}
-	var isLimit CommitFilter = func(commit *Commit) bool {
-		_, ok := seen[commit.Hash]
-		return ok
-	}
-
-	if len(candidates) < 2 {
-		return candidates, nil
-	}
+	return mergebase.Independents(make(generations), removeDuplicated(commits))
+}
 
-	pos := 0
-	for {
-		from := candidates[pos]
-		others := remove(candidates, from)
-		fromHistoryIter := NewFilterCommitIter(from, nil, &isLimit)
-		err := fromHistoryIter.ForEach(func(fromAncestor *Commit) error {
-			for _, other := range others {
-				if fromAncestor.Hash == other.Hash {
-					candidates = remove(candidates, other)
-					others = remove(others, other)
-				}
-			}
+// generations holds the generation numbers of the commits, computed on the
+// fly: the root commits have the generation 1, and any other commit a
+// generation higher than the ones of its parents. Unlike the commit dates,
+// they can't be skewed, a commit is never reachable from the commits with a
+// lower or equal generation. It implements mergebase.Graph over the commit
+// objects.
+type generations map[plumbing.Hash]uint64
+
+// ID returns the hash of the commit.
+func (g generations) ID(c *Commit) plumbing.Hash {
+	return c.Hash
+}
 
-			if len(candidates) == 1 {
-				return storer.ErrStop
-			}
+// ParentHashes returns the hashes of the parents of the commit.
+func (g generations) ParentHashes(c *Commit) []plumbing.Hash {
+	return c.ParentHashes
+}
 
-			seen[fromAncestor.Hash] = struct{}{}
-			return nil
-		})
+// Parent returns the i-th parent of the commit.
+func (g generations) Parent(c *Commit, i int) (*Commit, error) {
+	return GetCommit(c.s, c.ParentHashes[i])
+}
 
-		if err != nil {
-			return nil, err
-		}
+// Generation returns the generation of the commit, computing the ones of its
+// history not computed yet.
+func (g generations) Generation(c *Commit) (uint64, error) {
+	if gen, ok := g[c.Hash]; ok {
+		return gen, nil
+	}
 
-		nextPos := indexOf(candidates, from) + 1
-		if nextPos >= len(candidates) {
-			break
+	pending := []*Commit{c}
+	for len(pending) > 0 {
+		c := pending[len(pending)-1]
+		if _, ok := g[c.Hash]; ok {
+			pending = pending[:len(pending)-1]
+			continue
 		}
 
-		pos = nextPos
-	}
+		var gen uint64
+		var missing plumbing.Hash
+		for _, h := range c.ParentHashes {
+			pg, ok := g[h]
+			if !ok {
+				missing = h
+				break
+			}
 
-	return candidates, nil
-}
+			if pg > gen {
+				gen = pg
+			}
+		}
 
-// sortByCommitDateDesc returns the passed commits, sorted by `committer.When desc`
-//
-// Following this strategy, it is tried to reduce the time needed when walking
-// the history from one commit to reach the others. It is assumed that ancestors
-// use to be committed before its descendant;
-// That way `Independents(A^, A)` will be processed as being `Independents(A, A^)`;
-// so starting by `A` it will be reached `A^` way sooner than walking from `A^`
-// to the initial commit, and then from `A` to `A^`.
-func sortByCommitDateDesc(commits ...*Commit) []*Commit {
-	sorted := make([]*Commit, len(commits))
-	copy(sorted, commits)
-	sort.Slice(sorted, func(i, j int) bool {
-		return sorted[i].Committer.When.After(sorted[j].Committer.When)
-	})
-
-	return sorted
-}
+		if missing.IsZero() {
+			g[c.Hash] = gen + 1
+			pending = pending[:len(pending)-1]
+			continue
+		}
 
-// indexOf returns the first position where target was found in the passed commits
-func indexOf(commits []*Commit, target *Commit) int {
-	for i, commit := range commits {
-		if target.Hash == commit.Hash {
-			return i
+		p, err := GetCommit(c.s, missing)
+		if err != nil {
+			return 0, err
 		}
+
+		pending = append(pending, p)
 	}
 
-	return -1
+	return g[c.Hash], nil
 }
 
-// remove returns the passed commits excluding the commit toDelete
-func remove(commits []*Commit, toDelete *Commit) []*Commit {
-	res := make([]*Commit, len(commits))
-	j := 0
-	for _, commit := range commits {
-		if commit.Hash == toDelete.Hash {
-			continue
-		}
-
-		res[j] = commit
-		j++
+// Compare orders the commits by generation, and then by commit date, the
+// newest first.
+func (g generations) Compare(a, b *Commit) int {
+	ga, gb := g[a.Hash], g[b.Hash]
+	switch {
+	case ga > gb:
+		return -1
+	case ga < gb:
+		return 1
+	case a.Committer.When.After(b.Committer.When):
+		return -1
+	case a.Committer.When.Before(b.Committer.When):
+		return 1
+	default:
+		return 0
 	}
-
-	return res[:j]
 }
 
 // removeDuplicated removes duplicated commits from the passed slice of commits
@@ -199,12 +162,3 @@ func removeDuplicated(commits []*Commit) []*Commit {
 
 	return res[:j]
 }
-
-// isInIndexCommitFilter returns a commitFilter that returns true
-// if the commit is in the passed index.
-func isInIndexCommitFilter(index map[plumbing.Hash]struct{}) CommitFilter {
-	return func(c *Commit) bool {
-		_, ok := index[c.Hash]
-		return ok
-	}
-}
@@ -4,10 +4,12 @@ import (
 	"fmt"
 	"sort"
 	"testing"
+	"time"
 
 	"github.com/go-git/go-git/v6/plumbing"
 	"github.com/go-git/go-git/v6/plumbing/cache"
 	"github.com/go-git/go-git/v6/storage/filesystem"
+	"github.com/go-git/go-git/v6/storage/memory"
 	"github.com/stretchr/testify/suite"
 
 	fixtures "github.com/go-git/go-git-fixtures/v5"
@@ -324,3 +326,86 @@ func (s *mergeBaseSuite) TestAncestorUnrelated() {
 	revs = []string{"N", "M"}
 	s.AssertAncestor(revs, false)
 }
+
+// TestMergeBaseMultiple validates that the merge-base of one commit and
+// several others is the merge-base with a hypothetical merge of the others,
+// as `git merge-base X Y Z` does
+//
+//	root---a---b---X
+//	        \   \
+//	         Y   Z
+func (s *mergeBaseSuite) TestMergeBaseMultiple() {
+	sto := memory.NewStorage()
+	when := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	commit := func(hours int, parents ...plumbing.Hash) *Commit {
+		h := storeCommit(s.T(), sto, when.Add(time.Duration(hours)*time.Hour), parents)
+		c, err := GetCommit(sto, h)
+		s.Require().NoError(err)
+		return c
+	}
+
+	root := commit(0)
+	a := commit(1, root.Hash)
+	b := commit(2, a.Hash)
+	x := commit(3, b.Hash)
+	y := commit(4, a.Hash)
+	z := commit(5, b.Hash)
+
+	bases, err := MergeBaseMultiple(x, y, z)
+	s.NoError(err)
+	s.Equal([]*Commit{b}, bases)
+
+	bases, err = MergeBaseMultiple(x, y)
+	s.NoError(err)
+	s.Equal([]*Commit{a}, bases)
+
+	bases, err = MergeBaseMultiple(x, y, x)
+	s.NoError(err)
+	s.Equal([]*Commit{x}, bases)
+}
+
+// TestMergeBaseClockSkew validates that the history is walked in topological
+// order, even when the parents are committed after their children
+//
+//	base---x---y
+//	    \
+//	     z
+func (s *mergeBaseSuite) TestMergeBaseClockSkew() {
+	sto := memory.NewStorage()
+	when := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	commit := func(hours int, parents ...plumbing.Hash) *Commit {
+		h := storeCommit(s.T(), sto, when.Add(time.Duration(hours)*time.Hour), parents)
+		c, err := GetCommit(sto, h)
+		s.Require().NoError(err)
+		return c
+	}
+
+	base := commit(50)
+	x := commit(-100, base.Hash)
+	y := commit(1, x.Hash)
+	z := commit(-50, base.Hash)
+
+	bases, err := y.MergeBase(z)
+	s.NoError(err)
+	s.Equal([]*Commit{base}, bases)
+
+	bases, err = MergeBaseMultiple(base, y)
+	s.NoError(err)
+	s.Equal([]*Commit{base}, bases)
+
+	ok, err := IsAncestor(base, y)
+	s.NoError(err)
+	s.True(ok)
+
+	ok, err = IsAncestor(y, x)
+	s.NoError(err)
+	s.False(ok)
+
+	ok, err = IsAncestor(z, y)
+	s.NoError(err)
+	s.False(ok)
+
+	independents, err := Independents([]*Commit{x, base, z, y})
+	s.NoError(err)
+	s.Equal([]*Commit{y, z}, independents)
+}
@@ -0,0 +1,412 @@
+package object
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"path"
+	"sort"
+
+	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/go-git/go-git/v6/plumbing/filemode"
+	"github.com/go-git/go-git/v6/plumbing/storer"
+	"github.com/go-git/go-git/v6/utils/binary"
+	"github.com/go-git/go-git/v6/utils/diff"
+	"github.com/go-git/go-git/v6/utils/ioutil"
+)
+
+// ErrMergeTreeMissingSide is returned by MergeTree when ours or theirs is nil.
+var ErrMergeTreeMissingSide = errors.New("ours and theirs trees are required")
+
+const (
+	mergeTreeOursLabel   = "ours"
+	mergeTreeTheirsLabel = "theirs"
+)
+
+// MergeTreeConflict describes a path that could not be merged automatically.
+// The entries are the stages git records in the index for the path, nil when
+// the path does not exist on the given side; e.g. a file deleted on one side
+// and modified on the other has a nil Ours or Theirs.
+type MergeTreeConflict struct {
+	// Path of the conflicting file, relative to the root of the tree.
+	Path string
+	// Base is the entry in the common ancestor, stage 1.
+	Base *TreeEntry
+	// Ours is the entry in ours, stage 2.
+	Ours *TreeEntry
+	// Theirs is the entry in theirs, stage 3.
+	Theirs *TreeEntry
+}
+
+// MergeTreeResult is the outcome of MergeTree.
+type MergeTreeResult struct {
+	// Tree is the hash of the merged tree. When there are conflicts, it
+	// holds the conflicting files as git leaves them in the worktree, e.g.
+	// with conflict markers.
+	Tree plumbing.Hash
+	// Conflicts lists the paths that could not be merged automatically,
+	// sorted by path.
+	Conflicts []MergeTreeConflict
+}
+
+// IsClean returns true if the merge has no conflicts.
+func (r *MergeTreeResult) IsClean() bool {
+	return len(r.Conflicts) == 0
+}
+
+// MergeTree performs a three-way merge of ours and theirs, using base as
+// their common ancestor, as `git merge-tree --write-tree` does. A nil base is
+// handled as an empty tree, for histories without a common ancestor.
+//
+// No worktree or index is involved: the merged blobs and trees are written to
+// the storer of ours. Files changed on both sides are merged line by line,
+// the conflicting regions being surrounded by conflict markers labelled
+// "ours" and "theirs", unless they are binary, in which case ours is kept.
+func MergeTree(base, ours, theirs *Tree) (*MergeTreeResult, error) {
+	if ours == nil || theirs == nil {
+		return nil, ErrMergeTreeMissingSide
+	}
+
+	m := &treeMerger{s: ours.s}
+
+	sides := make([]map[string]TreeEntry, 3)
+	for i, t := range []*Tree{base, ours, theirs} {
+		var err error
+		if sides[i], err = flattenTreeEntries(t); err != nil {
+			return nil, err
+		}
+	}
+
+	b, o, t := sides[0], sides[1], sides[2]
+	entries := make(map[string]TreeEntry)
+	var conflicts []MergeTreeConflict
+	for _, p := range unionTreePaths(b, o, t) {
+		e, ok, clean, err := m.mergePath(p, b, o, t)
+		if err != nil {
+			return nil, err
+		}
+
+		if ok {
+			entries[p] = e
+		}
+
+		if !clean {
+			conflicts = append(conflicts, newMergeTreeConflict(p, b, o, t))
+		}
+	}
+
+	conflicts = resolveDirectoryFileConflicts(entries, conflicts, b, o, t)
+
+	h, err := m.writeTree(entries)
+	if err != nil {
+		return nil, err
+	}
+
+	return &MergeTreeResult{Tree: h, Conflicts: conflicts}, nil
+}
+
+type treeMerger struct {
+	s storer.EncodedObjectStorer
+}
+
+// mergePath merges the versions of the file found at the given path on each
+// side. It returns the merged entry, if the path is kept, and whether the
+// merge was clean.
+func (m *treeMerger) mergePath(
+	p string, b, o, t map[string]TreeEntry,
+) (e TreeEntry, ok, clean bool, err error) {
+	be, inBase := b[p]
+	oe, inOurs := o[p]
+	te, inTheirs := t[p]
+
+	switch {
+	case sameTreeEntry(oe, inOurs, te, inTheirs), sameTreeEntry(be, inBase, te, inTheirs):
+		return oe, inOurs, true, nil
+	case sameTreeEntry(be, inBase, oe, inOurs):
+		return te, inTheirs, true, nil
+	case !inOurs:
+		// Deleted by ours and modified by theirs, the modified version is
+		// kept.
+		return te, true, false, nil
+	case !inTheirs:
+		return oe, true, false, nil
+	}
+
+	e, clean, err = m.mergeEntries(p, be, inBase, oe, te)
+	return e, true, clean, err
+}
+
+// mergeEntries merges two versions of a file changed on both sides. It
+// returns false if the changes could not be merged cleanly.
+func (m *treeMerger) mergeEntries(p string, base TreeEntry, inBase bool, ours, theirs TreeEntry) (TreeEntry, bool, error) {
+	mode, clean := ours.Mode, true
+	switch {
+	case ours.Mode == theirs.Mode:
+	case inBase && base.Mode == ours.Mode:
+		mode = theirs.Mode
+	case inBase && base.Mode == theirs.Mode:
+	default:
+		clean = false
+	}
+
+	merged := TreeEntry{Name: p, Mode: mode, Hash: ours.Hash}
+	if ours.Hash == theirs.Hash {
+		return merged, clean, nil
+	}
+
+	if !isMergeableMode(ours.Mode) || !isMergeableMode(theirs.Mode) {
+		return merged, false, nil
+	}
+
+	var baseContent []byte
+	if inBase && isMergeableMode(base.Mode) {
+		var err error
+		if baseContent, err = m.blobContent(base.Hash); err != nil {
+			return merged, false, err
+		}
+	}
+
+	oursContent, err := m.blobContent(ours.Hash)
+	if err != nil {
+		return merged, false, err
+	}
+
+	theirsContent, err := m.blobContent(theirs.Hash)
+	if err != nil {
+		return merged, false, err
+	}
+
+	for _, content := range [][]byte{baseContent, oursContent, theirsContent} {
+		isBinary, err := binary.IsBinary(bytes.NewReader(content))
+		if err != nil {
+			return merged, false, err
+		}
+
+		// Binary files can't be merged line by line, ours is kept.
+		if isBinary {
+			return merged, false, nil
+		}
+	}
+
+	content, conflicts := diff.Merge(
+		string(baseContent), string(oursContent), string(theirsContent),
+		mergeTreeOursLabel, mergeTreeTheirsLabel,
+	)
+
+	if merged.Hash, err = m.writeBlob([]byte(content)); err != nil {
+		return merged, false, err
+	}
+
+	return merged, clean && conflicts == 0, nil
+}
+
+// resolveDirectoryFileConflicts looks for paths that ended up being a file
+// and a directory at the same time. The directory is kept, while the file is
+// reported as conflicting. The conflicts are returned sorted by path.
+func resolveDirectoryFileConflicts(
+	entries map[string]TreeEntry, conflicts []MergeTreeConflict,
+	b, o, t map[string]TreeEntry,
+) []MergeTreeConflict {
+	conflicting := make(map[string]bool)
+	for _, c := range conflicts {
+		conflicting[c.Path] = true
+	}
+
+	for p := range entries {
+		for dir := path.Dir(p); dir != "."; dir = path.Dir(dir) {
+			if _, ok := entries[dir]; !ok {
+				continue
+			}
+
+			delete(entries, dir)
+			if !conflicting[dir] {
+				conflicting[dir] = true
+				conflicts = append(conflicts, newMergeTreeConflict(dir, b, o, t))
+			}
+		}
+	}
+
+	sort.Slice(conflicts, func(i, j int) bool {
+		return conflicts[i].Path < conflicts[j].Path
+	})
+
+	return conflicts
+}
+
+func (m *treeMerger) blobContent(h plumbing.Hash) (content []byte, err error) {
+	blob, err := GetBlob(m.s, h)
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := blob.Reader()
+	if err != nil {
+		return nil, err
+	}
+
+	defer ioutil.CheckClose(r, &err)
+	return io.ReadAll(r)
+}
+
+func (m *treeMerger) writeBlob(content []byte) (h plumbing.Hash, err error) {
+	obj := m.s.NewEncodedObject()
+	obj.SetType(plumbing.BlobObject)
+	obj.SetSize(int64(len(content)))
+
+	w, err := obj.Writer()
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	if _, err := w.Write(content); err != nil {
+		_ = w.Close()
+		return plumbing.ZeroHash, err
+	}
+
+	if err := w.Close(); err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	return m.s.SetEncodedObject(obj)
+}
+
+// writeTree stores the given files, keyed by their full path, as tree
+// objects, returning the hash of the root tree.
+func (m *treeMerger) writeTree(files map[string]TreeEntry) (plumbing.Hash, error) {
+	entries := make(map[string][]TreeEntry)
+	subdirs := make(map[string][]string)
+	seen := make(map[string]struct{})
+	for p, e := range files {
+		dir := parentDir(p)
+		e.Name = path.Base(p)
+		entries[dir] = append(entries[dir], e)
+
+		for dir != "" {
+			if _, ok := seen[dir]; ok {
+				break
+			}
+
+			seen[dir] = struct{}{}
+			parent := parentDir(dir)
+			subdirs[parent] = append(subdirs[parent], dir)
+			dir = parent
+		}
+	}
+
+	return m.writeDir("", entries, subdirs)
+}
+
+// writeDir stores the tree of the given directory, once the trees of its
+// subdirectories are stored.
+func (m *treeMerger) writeDir(dir string, entries map[string][]TreeEntry, subdirs map[string][]string) (plumbing.Hash, error) {
+	t := &Tree{Entries: entries[dir]}
+	for _, sub := range subdirs[dir] {
+		h, err := m.writeDir(sub, entries, subdirs)
+		if err != nil {
+			return plumbing.ZeroHash, err
+		}
+
+		t.Entries = append(t.Entries, TreeEntry{
+			Name: path.Base(sub),
+			Mode: filemode.Dir,
+			Hash: h,
+		})
+	}
+
+	sort.Sort(TreeEntrySorter(t.Entries))
+
+	obj := m.s.NewEncodedObject()
+	if err := t.Encode(obj); err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	return m.s.SetEncodedObject(obj)
+}
+
+func parentDir(p string) string {
+	dir := path.Dir(p)
+	if dir == "." {
+		return ""
+	}
+
+	return dir
+}
+
+// flattenTreeEntries returns all the non-directory entries of a tree keyed by
+// their full path, which is also used as their Name.
+func flattenTreeEntries(t *Tree) (map[string]TreeEntry, error) {
+	entries := make(map[string]TreeEntry)
+	if t == nil {
+		return entries, nil
+	}
+
+	w := NewTreeWalker(t, true, nil)
+	defer w.Close()
+
+	for {
+		name, e, err := w.Next()
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		if e.Mode == filemode.Dir {
+			continue
+		}
+
+		e.Name = name
+		entries[name] = e
+	}
+
+	return entries, nil
+}
+
+func unionTreePaths(entries ...map[string]TreeEntry) []string {
+	seen := make(map[string]struct{})
+	var paths []string
+	for _, m := range entries {
+		for p := range m {
+			if _, ok := seen[p]; ok {
+				continue
+			}
+
+			seen[p] = struct{}{}
+			paths = append(paths, p)
+		}
+	}
+
+	sort.Strings(paths)
+	return paths
+}
+
+func sameTreeEntry(a TreeEntry, inA bool, b TreeEntry, inB bool) bool {
+	if inA != inB {
+		return false
+	}
+
+	return !inA || (a.Hash == b.Hash && a.Mode == b.Mode)
+}
+
+func isMergeableMode(m filemode.FileMode) bool {
+	return m.IsFile() && m != filemode.Symlink
+}
+
+func newMergeTreeConflict(p string, b, o, t map[string]TreeEntry) MergeTreeConflict {
+	c := MergeTreeConflict{Path: p}
+	if e, ok := b[p]; ok {
+		c.Base = &e
+	}
+
+	if e, ok := o[p]; ok {
+		c.Ours = &e
+	}
+
+	if e, ok := t[p]; ok {
+		c.Theirs = &e
+	}
+
+	return c
+}
@@ -0,0 +1,161 @@
+package object
+
+import (
+	"testing"
+
+	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/go-git/go-git/v6/plumbing/filemode"
+	"github.com/go-git/go-git/v6/storage/memory"
+	"github.com/stretchr/testify/suite"
+)
+
+type MergeTreeSuite struct {
+	suite.Suite
+	s *memory.Storage
+}
+
+func TestMergeTreeSuite(t *testing.T) {
+	suite.Run(t, new(MergeTreeSuite))
+}
+
+func (s *MergeTreeSuite) SetupTest() {
+	s.s = memory.NewStorage()
+}
+
+// tree stores a tree with the given files, keyed by path, and returns it.
+func (s *MergeTreeSuite) tree(files map[string]string) *Tree {
+	m := &treeMerger{s: s.s}
+	entries := make(map[string]TreeEntry)
+	for p, content := range files {
+		h, err := m.writeBlob([]byte(content))
+		s.Require().NoError(err)
+		entries[p] = TreeEntry{Name: p, Mode: filemode.Regular, Hash: h}
+	}
+
+	h, err := m.writeTree(entries)
+	s.Require().NoError(err)
+
+	t, err := GetTree(s.s, h)
+	s.Require().NoError(err)
+	return t
+}
+
+func (s *MergeTreeSuite) files(h plumbing.Hash) map[string]string {
+	t, err := GetTree(s.s, h)
+	s.Require().NoError(err)
+
+	files := make(map[string]string)
+	err = t.Files().ForEach(func(f *File) error {
+		content, err := f.Contents()
+		files[f.Name] = content
+		return err
+	})
+	s.Require().NoError(err)
+
+	return files
+}
+
+func (s *MergeTreeSuite) TestClean() {
+	base := s.tree(map[string]string{
+		"f":        "a\nb\nc\nd\ne\n",
+		"keep":     "x\n",
+		"dir/gone": "x\n",
+	})
+	ours := s.tree(map[string]string{
+		"f":        "a\nB\nc\nd\ne\n",
+		"keep":     "x\n",
+		"dir/gone": "x\n",
+		"dir/new":  "o\n",
+	})
+	theirs := s.tree(map[string]string{
+		"f":    "a\nb\nc\nd\nE\n",
+		"keep": "x\n",
+	})
+
+	res, err := MergeTree(base, ours, theirs)
+	s.Require().NoError(err)
+	s.True(res.IsClean())
+	s.Equal(map[string]string{
+		"f":       "a\nB\nc\nd\nE\n",
+		"keep":    "x\n",
+		"dir/new": "o\n",
+	}, s.files(res.Tree))
+}
+
+func (s *MergeTreeSuite) TestUnchanged() {
+	base := s.tree(map[string]string{"f": "a\n"})
+	ours := s.tree(map[string]string{"f": "b\n", "sub/g": "c\n"})
+
+	res, err := MergeTree(base, ours, base)
+	s.Require().NoError(err)
+	s.True(res.IsClean())
+	s.Equal(ours.Hash, res.Tree)
+}
+
+func (s *MergeTreeSuite) TestContentConflict() {
+	base := s.tree(map[string]string{"f": "a\nb\nc\nd\ne\n"})
+	ours := s.tree(map[string]string{"f": "a\nB\nc\nd\ne\n"})
+	theirs := s.tree(map[string]string{"f": "a\nb2\nc\nd\nE\n"})
+
+	res, err := MergeTree(base, ours, theirs)
+	s.Require().NoError(err)
+	s.False(res.IsClean())
+	s.Require().Len(res.Conflicts, 1)
+
+	c := res.Conflicts[0]
+	s.Equal("f", c.Path)
+	s.Equal(base.Entries[0].Hash, c.Base.Hash)
+	s.Equal(ours.Entries[0].Hash, c.Ours.Hash)
+	s.Equal(theirs.Entries[0].Hash, c.Theirs.Hash)
+
+	s.Equal(map[string]string{
+		"f": "a\n<<<<<<< ours\nB\n=======\nb2\n>>>>>>> theirs\nc\nd\nE\n",
+	}, s.files(res.Tree))
+}
+
+func (s *MergeTreeSuite) TestModifyDeleteConflict() {
+	base := s.tree(map[string]string{"f": "a\n", "g": "b\n"})
+	ours := s.tree(map[string]string{"g": "b\n"})
+	theirs := s.tree(map[string]string{"f": "A\n", "g": "b\n"})
+
+	res, err := MergeTree(base, ours, theirs)
+	s.Require().NoError(err)
+	s.Require().Len(res.Conflicts, 1)
+	s.Equal("f", res.Conflicts[0].Path)
+	s.NotNil(res.Conflicts[0].Base)
+	s.Nil(res.Conflicts[0].Ours)
+	s.NotNil(res.Conflicts[0].Theirs)
+
+	s.Equal(map[string]string{"f": "A\n", "g": "b\n"}, s.files(res.Tree))
+}
+
+func (s *MergeTreeSuite) TestAddAddConflictWithoutBase() {
+	ours := s.tree(map[string]string{"f": "a\n"})
+	theirs := s.tree(map[string]string{"f": "b\n"})
+
+	res, err := MergeTree(nil, ours, theirs)
+	s.Require().NoError(err)
+	s.Require().Len(res.Conflicts, 1)
+	s.Nil(res.Conflicts[0].Base)
+
+	s.Equal(map[string]string{
+		"f": "<<<<<<< ours\na\n=======\nb\n>>>>>>> theirs\n",
+	}, s.files(res.Tree))
+}
+
+func (s *MergeTreeSuite) TestDirectoryFileConflict() {
+	base := s.tree(map[string]string{"g": "a\n"})
+	ours := s.tree(map[string]string{"g": "a\n", "f": "a\n"})
+	theirs := s.tree(map[string]string{"g": "a\n", "f/g": "b\n"})
+
+	res, err := MergeTree(base, ours, theirs)
+	s.Require().NoError(err)
+	s.Require().Len(res.Conflicts, 1)
+	s.Equal("f", res.Conflicts[0].Path)
+	s.Equal(map[string]string{"g": "a\n", "f/g": "b\n"}, s.files(res.Tree))
+}
+
+func (s *MergeTreeSuite) TestMissingSide() {
+	_, err := MergeTree(nil, nil, s.tree(nil))
+	s.ErrorIs(err, ErrMergeTreeMissingSide)
+}
@@ -12,6 +12,7 @@ import (
 	"github.com/go-git/go-git/v6/plumbing"
 	"github.com/go-git/go-git/v6/plumbing/filemode"
 	fdiff "github.com/go-git/go-git/v6/plumbing/format/diff"
+	"github.com/go-git/go-git/v6/plumbing/format/gitattributes"
 	"github.com/go-git/go-git/v6/utils/diff"
 
 	dmp "github.com/sergi/go-diff/diffmatchpatch"
@@ -21,12 +22,57 @@ var (
 	ErrCanceled = errors.New("operation canceled")
 )
 
+const (
+	diffAttr = "diff"
+	textAttr = "text"
+)
+
+// PatchOptions describes how a patch should be generated.
+type PatchOptions struct {
+	// Attributes is used to look up the diff and text attributes of the
+	// changed paths. The changes of paths with any of them unset, e.g.
+	// `*.min.js -diff` or `*.dat -text`, are shown as binary, while the paths
+	// with them set are always diffed as text. The diff attribute takes
+	// precedence. Otherwise, files with a NUL byte are considered binary.
+	Attributes gitattributes.Matcher
+	// DiffTreeOptions are used to find the changes between the trees by
+	// Tree.PatchWithOptions and Commit.PatchWithOptions, DefaultDiffTreeOptions
+	// is used if nil. The detected renames and copies are shown as such.
+	DiffTreeOptions *DiffTreeOptions
+	// TextConv holds the converters of the diff drivers, by driver name. The
+	// content of the paths whose diff attribute names a driver with a
+	// converter, e.g. `*.pdf diff=pdf`, is converted before being diffed as
+	// text, as git does with the textconv program of the driver. The raw
+	// content of the other paths is diffed.
+	TextConv map[string]fdiff.TextConv
+	// IgnoreAllSpace ignores the whitespace when comparing lines, as
+	// `git diff -w`. The lines only differing by whitespace are shown as
+	// unchanged, with their content in the "from" file.
+	IgnoreAllSpace bool
+	// IgnoreSpaceChange ignores the changes in the amount of whitespace, and
+	// the whitespace at the end of the lines, as `git diff -b`.
+	IgnoreSpaceChange bool
+	// IgnoreBlankLines ignores the changes whose lines are all blank, as
+	// `git diff --ignore-blank-lines`. They are only shown in the hunks of
+	// other changes.
+	IgnoreBlankLines bool
+	// MaxFileSize, if not zero, is the size in bytes above which the changes
+	// of a file are shown as binary, without reading its content, to bound
+	// the cost of diffing huge files. They are reported with no added or
+	// deleted lines by the stats.
+	MaxFileSize int64
+}
+
 func getPatch(message string, changes ...*Change) (*Patch, error) {
 	ctx := context.Background()
-	return getPatchContext(ctx, message, changes...)
+	return getPatchContext(ctx, message, nil, changes...)
 }
 
-func getPatchContext(ctx context.Context, message string, changes ...*Change) (*Patch, error) {
+func getPatchContext(ctx context.Context, message string, opts *PatchOptions, changes ...*Change) (*Patch, error) {
+	if opts == nil {
+		opts = &PatchOptions{}
+	}
+
 	var filePatches []fdiff.FilePatch
 	for _, c := range changes {
 		select {
@@ -35,7 +81,8 @@ func getPatchContext(ctx context.Context, message string, changes ...*Change) (*
 		default:
 		}
 
-		fp, err := filePatchWithContext(ctx, c)
+		mode, driver := binaryAttribute(opts.Attributes, c.name())
+		fp, err := filePatchWithContext(ctx, c, mode, opts.TextConv[driver], opts)
 		if err != nil {
 			return nil, err
 		}
@@ -46,28 +93,87 @@ func getPatchContext(ctx context.Context, message string, changes ...*Change) (*
 	return &Patch{message, filePatches}, nil
 }
 
-func filePatchWithContext(ctx context.Context, c *Change) (fdiff.FilePatch, error) {
+// binaryMode tells whether a file is diffed as text or shown as binary.
+type binaryMode int
+
+const (
+	// detectBinary considers the files with a NUL byte as binary.
+	detectBinary binaryMode = iota
+	forceText
+	forceBinary
+)
+
+// binaryAttribute returns the binaryMode of the given path, set by its diff
+// or text attributes, and the name of its diff driver if any.
+func binaryAttribute(m gitattributes.Matcher, name string) (binaryMode, string) {
+	if m == nil {
+		return detectBinary, ""
+	}
+
+	results, _ := m.Match(strings.Split(name, "/"), []string{diffAttr, textAttr})
+	for _, attr := range []string{diffAttr, textAttr} {
+		a := results[attr]
+		switch {
+		case a == nil:
+		case a.IsUnset():
+			return forceBinary, ""
+		case a.IsSet():
+			return forceText, ""
+		case attr == diffAttr && a.IsValueSet():
+			return detectBinary, a.Value()
+		default:
+			// e.g. text=auto, the content is checked.
+			return detectBinary, ""
+		}
+	}
+
+	return detectBinary, ""
+}
+
+func filePatchWithContext(ctx context.Context, c *Change, mode binaryMode, conv fdiff.TextConv, opts *PatchOptions) (fdiff.FilePatch, error) {
+	fp := &textFilePatch{
+		from:       c.From,
+		to:         c.To,
+		similarity: c.Similarity,
+		copy:       c.Copy,
+	}
+
+	if mode == forceBinary {
+		fp.binary = true
+		return fp, nil
+	}
+
 	from, to, err := c.Files()
 	if err != nil {
 		return nil, err
 	}
-	fromContent, fIsBinary, err := fileContent(from)
+
+	if isOversized(from, opts.MaxFileSize) || isOversized(to, opts.MaxFileSize) {
+		fp.binary = true
+		fp.oversized = true
+		return fp, nil
+	}
+
+	fromContent, fIsBinary, err := fileContent(from, mode == forceText, conv)
 	if err != nil {
 		return nil, err
 	}
 
-	toContent, tIsBinary, err := fileContent(to)
+	toContent, tIsBinary, err := fileContent(to, mode == forceText, conv)
 	if err != nil {
 		return nil, err
 	}
 
 	if fIsBinary || tIsBinary {
-		return &textFilePatch{from: c.From, to: c.To}, nil
+		fp.binary = true
+		return fp, nil
 	}
 
-	diffs := diff.Do(fromContent, toContent)
+	diffs := diff.DoWithOptions(fromContent, toContent, diff.Options{
+		IgnoreAllSpace:    opts.IgnoreAllSpace,
+		IgnoreSpaceChange: opts.IgnoreSpaceChange,
+	})
 
-	var chunks []fdiff.Chunk
 	for _, d := range diffs {
 		select {
 		case <-ctx.Done():
@@ -85,25 +191,32 @@ func filePatchWithContext(ctx context.Context, c *Change) (fdiff.FilePatch, erro
 			op = fdiff.Add
 		}
 
-		chunks = append(chunks, &textChunk{d.Text, op})
+		ignored := opts.IgnoreBlankLines && op != fdiff.Equal && strings.TrimSpace(d.Text) == ""
+		fp.chunks = append(fp.chunks, &textChunk{d.Text, op, ignored})
 	}
 
-	return &textFilePatch{
-		chunks: chunks,
-		from:   c.From,
-		to:     c.To,
-	}, nil
+	return fp, nil
+}
 
+func isOversized(f *File, max int64) bool {
+	return f != nil && max > 0 && f.Size > max
 }
 
-func fileContent(f *File) (content string, isBinary bool, err error) {
+func fileContent(f *File, forceText bool, conv fdiff.TextConv) (content string, isBinary bool, err error) {
 	if f == nil {
 		return
 	}
 
-	isBinary, err = f.IsBinary()
-	if err != nil || isBinary {
-		return
+	// The converted content is always diffed as text.
+	if conv != nil {
+		return textConvContent(f, conv)
+	}
+
+	if !forceText {
+		isBinary, err = f.IsBinary()
+		if err != nil || isBinary {
+			return
+		}
 	}
 
 	content, err = f.Contents()
@@ -111,6 +224,26 @@ func fileContent(f *File) (content string, isBinary bool, err error) {
 	return
 }
 
+func textConvContent(f *File, conv fdiff.TextConv) (string, bool, error) {
+	r, err := f.Reader()
+	if err != nil {
+		return "", false, err
+	}
+	defer r.Close()
+
+	raw, err := io.ReadAll(r)
+	if err != nil {
+		return "", false, err
+	}
+
+	text, err := conv.TextConv(raw)
+	if err != nil {
+		return "", false, fmt.Errorf("textconv of %s: %w", f.Name, err)
+	}
+
+	return string(text), false, nil
+}
+
 // Patch is an implementation of fdiff.Patch interface
 type Patch struct {
 	message     string
@@ -173,10 +306,18 @@ func (f *changeEntryWrapper) Empty() bool {
 	return !f.ce.TreeEntry.Mode.IsFile()
 }
 
-// textFilePatch is an implementation of fdiff.FilePatch interface
+// textFilePatch is an implementation of fdiff.FilePatch and
+// fdiff.RenameFilePatch interfaces
 type textFilePatch struct {
 	chunks   []fdiff.Chunk
 	from, to ChangeEntry
+	binary   bool
+	// oversized is whether the content wasn't diffed, being larger than
+	// PatchOptions.MaxFileSize.
+	oversized bool
+
+	similarity int
+	copy       bool
 }
 
 func (tf *textFilePatch) Files() (from fdiff.File, to fdiff.File) {
@@ -195,7 +336,15 @@ func (tf *textFilePatch) Files() (from fdiff.File, to fdiff.File) {
 }
 
 func (tf *textFilePatch) IsBinary() bool {
-	return len(tf.chunks) == 0
+	return tf.binary
+}
+
+func (tf *textFilePatch) Similarity() int {
+	return tf.similarity
+}
+
+func (tf *textFilePatch) IsCopy() bool {
+	return tf.copy
 }
 
 func (tf *textFilePatch) Chunks() []fdiff.Chunk {
@@ -206,6 +355,7 @@ func (tf *textFilePatch) Chunks() []fdiff.Chunk {
 type textChunk struct {
 	content string
 	op      fdiff.Operation
+	ignored bool
 }
 
 func (t *textChunk) Content() string {
@@ -216,6 +366,10 @@ func (t *textChunk) Type() fdiff.Operation {
 	return t.op
 }
 
+func (t *textChunk) Ignored() bool {
+	return t.ignored
+}
+
 // FileStat stores the status of changes in content of a file.
 type FileStat struct {
 	Name     string
@@ -290,8 +444,10 @@ func getFileStatsFromFilePatches(filePatches []fdiff.FilePatch) FileStats {
 	var fileStats FileStats
 
 	for _, fp := range filePatches {
-		// ignore empty patches (binary files, submodule refs updates)
-		if len(fp.Chunks()) == 0 {
+		// ignore empty patches (binary files, submodule refs updates), but
+		// the ones of the files too large to be diffed.
+		tf, ok := fp.(*textFilePatch)
+		if len(fp.Chunks()) == 0 && !(ok && tf.oversized) {
 			continue
 		}
 
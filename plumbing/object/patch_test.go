@@ -1,11 +1,18 @@
 package object
 
 import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
 	"testing"
 
 	"github.com/go-git/go-git/v6/plumbing"
 	"github.com/go-git/go-git/v6/plumbing/cache"
+	fdiff "github.com/go-git/go-git/v6/plumbing/format/diff"
+	"github.com/go-git/go-git/v6/plumbing/format/gitattributes"
 	"github.com/go-git/go-git/v6/storage/filesystem"
+	"github.com/go-git/go-git/v6/storage/memory"
 	"github.com/stretchr/testify/suite"
 
 	fixtures "github.com/go-git/go-git-fixtures/v5"
@@ -51,6 +58,260 @@ func (s *PatchSuite) TestStatsWithSubmodules() {
 	s.NotNil(p)
 }
 
+func (s *PatchSuite) TestPatchWithAttributes() {
+	storer := filesystem.NewStorage(fixtures.Basic().One().DotGit(), cache.NewObjectLRUDefault())
+
+	patch := func(from, to string, attributes string) fdiff.FilePatch {
+		a, err := GetCommit(storer, plumbing.NewHash(from))
+		s.Require().NoError(err)
+		b, err := GetCommit(storer, plumbing.NewHash(to))
+		s.Require().NoError(err)
+
+		ma, err := gitattributes.ReadAttributes(strings.NewReader(attributes), nil, true)
+		s.Require().NoError(err)
+
+		p, err := a.PatchWithOptions(context.Background(), b, &PatchOptions{
+			Attributes: gitattributes.NewMatcher(ma),
+		})
+		s.Require().NoError(err)
+		s.Require().Len(p.FilePatches(), 1)
+		return p.FilePatches()[0]
+	}
+
+	// CHANGELOG is a text file
+	fp := patch("b029517f6300c2da0f4b651b8642506cd6aaf45d", "b8e471f58bcbca63b07bda20e428190409c2db47", "*.go -diff")
+	s.False(fp.IsBinary())
+	s.NotEmpty(fp.Chunks())
+
+	fp = patch("b029517f6300c2da0f4b651b8642506cd6aaf45d", "b8e471f58bcbca63b07bda20e428190409c2db47", "CHANGELOG -diff")
+	s.True(fp.IsBinary())
+	s.Empty(fp.Chunks())
+
+	// binary.jpg is a binary file
+	fp = patch("35e85108805c84807bc66a02d91535e1e24b38b9", "b029517f6300c2da0f4b651b8642506cd6aaf45d", "*.go diff")
+	s.True(fp.IsBinary())
+
+	fp = patch("35e85108805c84807bc66a02d91535e1e24b38b9", "b029517f6300c2da0f4b651b8642506cd6aaf45d", "*.jpg diff")
+	s.False(fp.IsBinary())
+	s.NotEmpty(fp.Chunks())
+}
+
+func (s *PatchSuite) patchTrees(attributes string, diffOpts *DiffTreeOptions) *Patch {
+	sto := memory.NewStorage()
+	from := storeTree(s.T(), sto, map[string]string{
+		"bin":      "a\x00b",
+		"data.txt": "text\n",
+		"similar":  "a\nb\nc\nd\ne\nf\ng\nh\ni\nj\nx\n",
+	})
+	to := storeTree(s.T(), sto, map[string]string{
+		"bin":      "a\x00c",
+		"data.txt": "text2\n",
+		"empty":    "",
+		"renamed":  "a\nb\nc\nd\ne\nf\ng\nh\ni\nk\nx\n",
+	})
+
+	ma, err := gitattributes.ReadAttributes(strings.NewReader(attributes), nil, true)
+	s.Require().NoError(err)
+
+	p, err := from.PatchWithOptions(context.Background(), to, &PatchOptions{
+		Attributes:      gitattributes.NewMatcher(ma),
+		DiffTreeOptions: diffOpts,
+	})
+	s.Require().NoError(err)
+	return p
+}
+
+func (s *PatchSuite) TestPatchTextConv() {
+	sto := memory.NewStorage()
+	from := storeTree(s.T(), sto, map[string]string{"bin": "a\x00b", "data.txt": "text\n"})
+	to := storeTree(s.T(), sto, map[string]string{"bin": "a\x00c", "data.txt": "text2\n"})
+
+	ma, err := gitattributes.ReadAttributes(strings.NewReader("bin diff=nul\n*.txt diff=other\n"), nil, true)
+	s.Require().NoError(err)
+
+	p, err := from.PatchWithOptions(context.Background(), to, &PatchOptions{
+		Attributes: gitattributes.NewMatcher(ma),
+		TextConv: map[string]fdiff.TextConv{
+			"nul": fdiff.TextConvFunc(func(content []byte) ([]byte, error) {
+				return append(bytes.ReplaceAll(content, []byte{0}, []byte("<NUL>")), '\n'), nil
+			}),
+			"unused": fdiff.TextConvFunc(func([]byte) ([]byte, error) {
+				return nil, errors.New("unused")
+			}),
+		},
+	})
+	s.Require().NoError(err)
+
+	s.Equal(""+
+		"diff --git a/bin b/bin\n"+
+		"index 20b5be91886d0b6f26dc98a225c0dac05fe2c86e..88f37001cec36655decf891d4244853aaa51a00a 100644\n"+
+		"--- a/bin\n"+
+		"+++ b/bin\n"+
+		"@@ -1 +1 @@\n"+
+		"-a<NUL>b\n"+
+		"+a<NUL>c\n"+
+		"diff --git a/data.txt b/data.txt\n"+
+		"index 8e27be7d6154a1f68ea9160ef0e18691d20560dc..f483c776c42f8ef2aa00d827805dfeaf7d9ce02b 100644\n"+
+		"--- a/data.txt\n"+
+		"+++ b/data.txt\n"+
+		"@@ -1 +1 @@\n"+
+		"-text\n"+
+		"+text2\n",
+		p.String())
+
+	// The errors of the converters are returned.
+	_, err = from.PatchWithOptions(context.Background(), to, &PatchOptions{
+		Attributes: gitattributes.NewMatcher(ma),
+		TextConv: map[string]fdiff.TextConv{
+			"nul": fdiff.TextConvFunc(func([]byte) ([]byte, error) {
+				return nil, errors.New("foo")
+			}),
+		},
+	})
+	s.ErrorContains(err, "foo")
+}
+
+func (s *PatchSuite) TestPatchIgnoreWhitespace() {
+	sto := memory.NewStorage()
+	from := storeTree(s.T(), sto, map[string]string{
+		"code": "func() {\n\treturn  1\n}\n\na\nb\nc\nd\ne\nf\nend\n",
+	})
+	to := storeTree(s.T(), sto, map[string]string{
+		"code": "func() {\n    return 1 \n}\na\nb\nc\nd\ne\nf\n\nend\nnew\n",
+	})
+
+	patch := func(opts *PatchOptions) string {
+		p, err := from.PatchWithOptions(context.Background(), to, opts)
+		s.Require().NoError(err)
+		return p.String()
+	}
+
+	header := "" +
+		"diff --git a/code b/code\n" +
+		"index d3b2aa7310c5d7656aaa20dd0176284cda2f6388..010a70a4d8c19379936bba8c96e08a73270ec5c2 100644\n" +
+		"--- a/code\n" +
+		"+++ b/code\n"
+
+	s.Equal(header+
+		"@@ -1,11 +1,12 @@\n"+
+		" func() {\n"+
+		"-\treturn  1\n"+
+		"+    return 1 \n"+
+		" }\n"+
+		"-\n"+
+		" a\n"+
+		" b\n"+
+		" c\n"+
+		" d\n"+
+		" e\n"+
+		" f\n"+
+		"+\n"+
+		" end\n"+
+		"+new\n",
+		patch(nil))
+
+	s.Equal(header+
+		"@@ -1,11 +1,12 @@\n"+
+		" func() {\n"+
+		" \treturn  1\n"+
+		" }\n"+
+		"-\n"+
+		" a\n"+
+		" b\n"+
+		" c\n"+
+		" d\n"+
+		" e\n"+
+		" f\n"+
+		"+\n"+
+		" end\n"+
+		"+new\n",
+		patch(&PatchOptions{IgnoreSpaceChange: true}))
+
+	// The blank lines far from the other changes are left out, the ones
+	// close to them are shown.
+	s.Equal(header+
+		"@@ -10,2 +9,4 @@ e\n"+
+		" f\n"+
+		"+\n"+
+		" end\n"+
+		"+new\n",
+		patch(&PatchOptions{IgnoreAllSpace: true, IgnoreBlankLines: true}))
+}
+
+func (s *PatchSuite) TestPatchBinaryAndRenames() {
+	p := s.patchTrees("", nil)
+
+	s.Equal(""+
+		"diff --git a/bin b/bin\n"+
+		"index 20b5be91886d0b6f26dc98a225c0dac05fe2c86e..88f37001cec36655decf891d4244853aaa51a00a 100644\n"+
+		"Binary files a/bin and b/bin differ\n"+
+		"diff --git a/data.txt b/data.txt\n"+
+		"index 8e27be7d6154a1f68ea9160ef0e18691d20560dc..f483c776c42f8ef2aa00d827805dfeaf7d9ce02b 100644\n"+
+		"--- a/data.txt\n"+
+		"+++ b/data.txt\n"+
+		"@@ -1 +1 @@\n"+
+		"-text\n"+
+		"+text2\n"+
+		"diff --git a/empty b/empty\n"+
+		"new file mode 100644\n"+
+		"index 0000000000000000000000000000000000000000..e69de29bb2d1d6434b8b29ae775ad8c2e48c5391\n"+
+		"diff --git a/similar b/renamed\n"+
+		"similarity index 90%\n"+
+		"rename from similar\n"+
+		"rename to renamed\n"+
+		"index 7ee69cdfc6b7c2e1b8ef1320cf6d7a62bdab01fe..00d16bcca7a487341a46f293b5031773812e5840 100644\n"+
+		"--- a/similar\n"+
+		"+++ b/renamed\n"+
+		"@@ -7,5 +7,5 @@ f\n"+
+		" g\n"+
+		" h\n"+
+		" i\n"+
+		"-j\n"+
+		"+k\n"+
+		" x\n",
+		p.String())
+}
+
+func (s *PatchSuite) TestPatchWithoutRenameDetection() {
+	p := s.patchTrees("", &DiffTreeOptions{})
+
+	var names []string
+	for _, fp := range p.FilePatches() {
+		from, to := fp.Files()
+		s.False(from != nil && to != nil && from.Path() != to.Path())
+		if to != nil {
+			names = append(names, to.Path())
+		} else {
+			names = append(names, "-"+from.Path())
+		}
+	}
+	s.ElementsMatch([]string{"bin", "data.txt", "empty", "renamed", "-similar"}, names)
+	s.NotContains(p.String(), "rename from")
+}
+
+func (s *PatchSuite) TestPatchWithTextAttribute() {
+	isBinary := func(p *Patch, name string) bool {
+		for _, fp := range p.FilePatches() {
+			if _, to := fp.Files(); to != nil && to.Path() == name {
+				return fp.IsBinary()
+			}
+		}
+
+		s.FailNow("file not found", name)
+		return false
+	}
+
+	p := s.patchTrees("data.txt -text\nbin text\n", nil)
+	s.True(isBinary(p, "data.txt"))
+	s.False(isBinary(p, "bin"))
+	s.Contains(p.String(), "Binary files a/data.txt and b/data.txt differ\n")
+
+	// The diff attribute takes precedence.
+	p = s.patchTrees("* text\nbin -diff\ndata.txt text=auto\n", nil)
+	s.True(isBinary(p, "bin"))
+	s.False(isBinary(p, "data.txt"))
+}
+
 func (s *PatchSuite) TestFileStatsString() {
 	testCases := []struct {
 		description string
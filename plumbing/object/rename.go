@@ -19,6 +19,14 @@ import (
 func DetectRenames(
 	changes Changes,
 	opts *DiffTreeOptions,
+) (Changes, error) {
+	return detectRenamesWithReport(changes, opts, &DiffTreeReport{})
+}
+
+func detectRenamesWithReport(
+	changes Changes,
+	opts *DiffTreeOptions,
+	report *DiffTreeReport,
 ) (Changes, error) {
 	if opts == nil {
 		opts = DefaultDiffTreeOptions
@@ -28,6 +36,8 @@ func DetectRenames(
 		renameScore: int(opts.RenameScore),
 		renameLimit: int(opts.RenameLimit),
 		onlyExact:   opts.OnlyExactRenames,
+		scorer:      opts.Scorer,
+		report:      report,
 	}
 
 	for _, c := range changes {
@@ -59,6 +69,8 @@ type renameDetector struct {
 	renameScore int
 	renameLimit int
 	onlyExact   bool
+	scorer      SimilarityScorer
+	report      *DiffTreeReport
 }
 
 // detectExactRenames detects matches files that were deleted with files that
@@ -86,7 +98,7 @@ func (d *renameDetector) detectExactRenames() {
 
 		if len(deleted) == 1 {
 			if sameMode(c, deleted[0]) {
-				d.modified = append(d.modified, &Change{From: deleted[0].From, To: c.To})
+				d.modified = append(d.modified, &Change{From: deleted[0].From, To: c.To, Similarity: 100})
 				delete(deletes, hash)
 			} else {
 				addedLeft = append(addedLeft, c)
@@ -94,7 +106,7 @@ func (d *renameDetector) detectExactRenames() {
 		} else if len(deleted) > 1 {
 			bestMatch := bestNameMatch(c, deleted)
 			if bestMatch != nil && sameMode(c, bestMatch) {
-				d.modified = append(d.modified, &Change{From: bestMatch.From, To: c.To})
+				d.modified = append(d.modified, &Change{From: bestMatch.From, To: c.To, Similarity: 100})
 				delete(deletes, hash)
 
 				var newDeletes = make([]*Change, 0, len(deleted)-1)
@@ -118,7 +130,7 @@ func (d *renameDetector) detectExactRenames() {
 			deleted := deleted[0]
 			bestMatch := bestNameMatch(deleted, added)
 			if bestMatch != nil && sameMode(deleted, bestMatch) {
-				d.modified = append(d.modified, &Change{From: deleted.From, To: bestMatch.To})
+				d.modified = append(d.modified, &Change{From: deleted.From, To: bestMatch.To, Similarity: 100})
 				delete(deletes, hash)
 
 				for _, c := range added {
@@ -171,7 +183,7 @@ func (d *renameDetector) detectExactRenames() {
 
 				usedAdds[add] = struct{}{}
 				usedDeletes[del] = struct{}{}
-				d.modified = append(d.modified, &Change{From: del.From, To: add.To})
+				d.modified = append(d.modified, &Change{From: del.From, To: add.To, Similarity: 100})
 				added[matrix[i].added] = nil
 				deleted[matrix[i].deleted] = nil
 			}
@@ -208,11 +220,12 @@ func (d *renameDetector) detectExactRenames() {
 func (d *renameDetector) detectContentRenames() error {
 	cnt := max(len(d.added), len(d.deleted))
 	if d.renameLimit > 0 && cnt > d.renameLimit {
+		d.report.RenameLimitExceeded = true
 		return nil
 	}
 
 	srcs, dsts := d.deleted, d.added
-	matrix, err := buildSimilarityMatrix(srcs, dsts, d.renameScore)
+	matrix, err := buildSimilarityMatrix(srcs, dsts, d.renameScore, d.scorer)
 	if err != nil {
 		return err
 	}
@@ -230,7 +243,7 @@ func (d *renameDetector) detectContentRenames() error {
 			continue
 		}
 
-		renames = append(renames, &Change{From: src.From, To: dst.To})
+		renames = append(renames, &Change{From: src.From, To: dst.To, Similarity: pair.score})
 
 		// Claim destination and source as matched
 		dsts[pair.added] = nil
@@ -265,6 +278,136 @@ func (d *renameDetector) detect() (Changes, error) {
 	return result, nil
 }
 
+// detectCopies detects the insertions in the given changes whose content is
+// the same as, or similar enough to, a file of the source tree and reports
+// them as copies of it. Unlike renames, a file may be the source of several
+// copies.
+func detectCopies(changes Changes, from *Tree, opts *DiffTreeOptions, report *DiffTreeReport) (Changes, error) {
+	if from == nil {
+		return changes, nil
+	}
+
+	result := make(Changes, 0, len(changes))
+	var added []*Change
+	for _, c := range changes {
+		action, err := c.Action()
+		if err != nil {
+			return nil, err
+		}
+
+		if action == merkletrie.Insert {
+			added = append(added, c)
+		} else {
+			result = append(result, c)
+		}
+	}
+
+	if len(added) == 0 {
+		return changes, nil
+	}
+
+	sources, err := copySources(from)
+	if err != nil {
+		return nil, err
+	}
+
+	bySource := groupChangesByHash(sources)
+	var addedLeft []*Change
+	for _, c := range added {
+		src := bestCopySource(c, bySource[changeHash(c)])
+		if src == nil {
+			addedLeft = append(addedLeft, c)
+			continue
+		}
+
+		result = append(result, &Change{From: src.From, To: c.To, Similarity: 100, Copy: true})
+	}
+
+	cnt := max(len(addedLeft), len(sources))
+	limited := opts.RenameLimit > 0 && cnt > int(opts.RenameLimit)
+	if opts.OnlyExactRenames || limited {
+		report.RenameLimitExceeded = report.RenameLimitExceeded || limited
+		result = append(result, addedLeft...)
+		sort.Stable(result)
+		return result, nil
+	}
+
+	matrix, err := buildSimilarityMatrix(sources, addedLeft, int(opts.RenameScore), opts.Scorer)
+	if err != nil {
+		return nil, err
+	}
+
+	// Sources are not claimed, as the same file can be copied many times.
+	for i := len(matrix) - 1; i >= 0; i-- {
+		pair := matrix[i]
+		dst := addedLeft[pair.added]
+		if dst == nil {
+			continue
+		}
+
+		src := sources[pair.deleted]
+		result = append(result, &Change{From: src.From, To: dst.To, Similarity: pair.score, Copy: true})
+		addedLeft[pair.added] = nil
+	}
+
+	result = append(result, compactChanges(addedLeft)...)
+	sort.Stable(result)
+
+	return result, nil
+}
+
+// copySources returns every file of the given tree as a deletion, so they
+// can be matched against insertions as the sources of copies.
+func copySources(t *Tree) ([]*Change, error) {
+	w := NewTreeWalker(t, true, nil)
+	defer w.Close()
+
+	var sources []*Change
+	for {
+		name, entry, err := w.Next()
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		if !entry.Mode.IsFile() {
+			continue
+		}
+
+		sources = append(sources, &Change{From: ChangeEntry{
+			Name:      name,
+			Tree:      w.Tree(),
+			TreeEntry: entry,
+		}})
+	}
+
+	return sources, nil
+}
+
+// bestCopySource returns the source with the same mode as the given change
+// and the most similar path, or nil if there is none.
+func bestCopySource(change *Change, sources []*Change) *Change {
+	var best *Change
+	bestScore := -1
+
+	cname := changeName(change)
+	for _, c := range sources {
+		if !sameMode(change, c) {
+			continue
+		}
+
+		if score := nameSimilarityScore(cname, changeName(c)); score > bestScore {
+			bestScore = score
+			best = c
+		}
+	}
+
+	return best
+}
+
 func bestNameMatch(change *Change, changes []*Change) *Change {
 	var best *Change
 	var bestScore int
@@ -405,7 +548,27 @@ func min(a, b int) int {
 
 const maxMatrixSize = 10000
 
-func buildSimilarityMatrix(srcs, dsts []*Change, renameScore int) (similarityMatrix, error) {
+// SimilarityScorer scores the similarity of the content of two files, to
+// detect renames and copies.
+type SimilarityScorer interface {
+	// Score returns the similarity between the files, from 0 when they have
+	// nothing in common to 100 when they are the same.
+	Score(from, to *File) (int, error)
+}
+
+// SimilarityScorerFunc is a function implementing SimilarityScorer.
+type SimilarityScorerFunc func(from, to *File) (int, error)
+
+// Score implements the SimilarityScorer interface.
+func (f SimilarityScorerFunc) Score(from, to *File) (int, error) {
+	return f(from, to)
+}
+
+func buildSimilarityMatrix(srcs, dsts []*Change, renameScore int, scorer SimilarityScorer) (similarityMatrix, error) {
+	if scorer != nil {
+		return buildScorerMatrix(srcs, dsts, renameScore, scorer)
+	}
+
 	// Allocate for the worst-case scenario where every pair has a score
 	// that we need to consider. We might not need that many.
 	matrixSize := len(srcs) * len(dsts)
@@ -520,6 +683,51 @@ outerLoop:
 	return matrix, nil
 }
 
+// buildScorerMatrix builds the similarity matrix of the given changes with a
+// custom scorer, which is given every pair of regular files.
+func buildScorerMatrix(srcs, dsts []*Change, renameScore int, scorer SimilarityScorer) (similarityMatrix, error) {
+	matrix := make(similarityMatrix, 0, min(len(srcs)*len(dsts), maxMatrixSize))
+	dstFiles := make([]*File, len(dsts))
+
+	for srcIdx, src := range srcs {
+		if changeMode(src) != filemode.Regular {
+			continue
+		}
+
+		from, _, err := src.Files()
+		if err != nil {
+			return nil, err
+		}
+
+		for dstIdx, dst := range dsts {
+			if changeMode(dst) != filemode.Regular {
+				continue
+			}
+
+			if dstFiles[dstIdx] == nil {
+				if _, dstFiles[dstIdx], err = dst.Files(); err != nil {
+					return nil, err
+				}
+			}
+
+			score, err := scorer.Score(from, dstFiles[dstIdx])
+			if err != nil {
+				return nil, err
+			}
+
+			if score < renameScore {
+				continue
+			}
+
+			matrix = append(matrix, similarityPair{added: dstIdx, deleted: srcIdx, score: min(score, 100)})
+		}
+	}
+
+	sort.Stable(matrix)
+
+	return matrix, nil
+}
+
 func compactChanges(changes []*Change) []*Change {
 	var result []*Change
 	for _, c := range changes {
@@ -1,6 +1,7 @@
 package object
 
 import (
+	"errors"
 	"path/filepath"
 	"strings"
 	"testing"
@@ -275,6 +276,75 @@ func (s *RenameSuite) TestRenameLimit() {
 	}
 }
 
+func (s *RenameSuite) TestRenameLimitReport() {
+	exactAdd := makeAdd(s, makeFile(s, "exact/a", filemode.Regular, "exact\n"))
+	exactDelete := makeDelete(s, makeFile(s, "exact/b", filemode.Regular, "exact\n"))
+	changes := Changes{
+		exactAdd,
+		exactDelete,
+		makeAdd(s, makeFile(s, pathA, filemode.Regular, "foo\nbar\nbaz\nblarg\n")),
+		makeDelete(s, makeFile(s, pathB, filemode.Regular, "foo\nbar\nbaz\nblah\n")),
+		makeAdd(s, makeFile(s, pathH, filemode.Regular, "a\nb\nc\nd\n")),
+		makeDelete(s, makeFile(s, pathQ, filemode.Regular, "a\nb\nc\n")),
+	}
+
+	report := &DiffTreeReport{}
+	result, err := detectRenamesWithReport(changes, &DiffTreeOptions{RenameScore: 50, RenameLimit: 1}, report)
+	s.Require().NoError(err)
+	s.True(report.RenameLimitExceeded)
+	s.Len(result, 5)
+	s.Contains(result, &Change{From: exactDelete.From, To: exactAdd.To, Similarity: 100})
+
+	report = &DiffTreeReport{}
+	result, err = detectRenamesWithReport(changes, &DiffTreeOptions{RenameScore: 50, RenameLimit: 2}, report)
+	s.Require().NoError(err)
+	s.False(report.RenameLimitExceeded)
+	s.Len(result, 3)
+}
+
+func (s *RenameSuite) TestRenameScorer() {
+	changes := Changes{
+		makeAdd(s, makeFile(s, pathA, filemode.Regular, "foo\n")),
+		makeDelete(s, makeFile(s, pathB, filemode.Regular, "bar\n")),
+		makeAdd(s, makeFile(s, pathH, filemode.Regular, "a\nb\nc\nd\n")),
+		makeDelete(s, makeFile(s, pathQ, filemode.Regular, "a\nb\nc\nd\ne\n")),
+	}
+
+	var calls int
+	scorer := SimilarityScorerFunc(func(from, to *File) (int, error) {
+		calls++
+		fromContent, err := from.Contents()
+		if err != nil {
+			return 0, err
+		}
+
+		toContent, err := to.Contents()
+		if err != nil {
+			return 0, err
+		}
+
+		if fromContent == "bar\n" && toContent == "foo\n" {
+			return 90, nil
+		}
+
+		return 0, nil
+	})
+
+	result := detectRenames(s, changes, &DiffTreeOptions{RenameScore: 60, Scorer: scorer}, 3)
+	s.Equal(4, calls)
+	s.Contains(result, changes[2])
+	s.Contains(result, changes[3])
+	assertRename(s, changes[1], changes[0], result[0])
+	s.Equal(90, result[0].Similarity)
+
+	_, err := DetectRenames(changes, &DiffTreeOptions{
+		Scorer: SimilarityScorerFunc(func(from, to *File) (int, error) {
+			return 0, errors.New("scorer failed")
+		}),
+	})
+	s.ErrorContains(err, "scorer failed")
+}
+
 func (s *RenameSuite) TestRenameExactManyAddsManyDeletesNoGaps() {
 	content := "a"
 	detector := &renameDetector{
@@ -309,7 +379,10 @@ func detectRenames(s *RenameSuite, changes Changes, opts *DiffTreeOptions, expec
 }
 
 func assertRename(s *RenameSuite, from, to *Change, rename *Change) {
-	s.Equal(rename, &Change{From: from.From, To: to.To})
+	s.Equal(from.From, rename.From)
+	s.Equal(to.To, rename.To)
+	s.Greater(rename.Similarity, 0)
+	s.False(rename.Copy)
 }
 
 type SimilarityIndexSuite struct {
@@ -11,6 +11,7 @@ import (
 	"github.com/go-git/go-git/v6/plumbing/storer"
 	"github.com/go-git/go-git/v6/utils/ioutil"
 	"github.com/go-git/go-git/v6/utils/sync"
+	"golang.org/x/crypto/ssh"
 )
 
 // Tag represents an annotated tag object. It points to a single git object of
@@ -264,20 +265,38 @@ func (t *Tag) Verify(armoredKeyRing string) (*openpgp.Entity, error) {
 		return nil, err
 	}
 
-	// Extract signature.
-	signature := strings.NewReader(t.PGPSignature)
+	return t.VerifyKeyRing(keyring)
+}
 
-	encoded := &plumbing.MemoryObject{}
-	// Encode tag components, excluding signature and get a reader object.
-	if err := t.EncodeWithoutSignature(encoded); err != nil {
+// VerifyKeyRing performs PGP verification of the tag with the given keyring
+// and returns openpgp.Entity associated with verifying key on success. If the
+// signature wasn't made by a key of the keyring, or doesn't match the tag,
+// the error returned wraps ErrSignatureMismatch.
+func (t *Tag) VerifyKeyRing(keyring openpgp.KeyRing) (*openpgp.Entity, error) {
+	payload, err := t.signedPayload()
+	if err != nil {
 		return nil, err
 	}
-	er, err := encoded.Reader()
+
+	return verifyPGP(keyring, payload, t.PGPSignature)
+}
+
+// VerifySSH performs SSH signature verification of the tag, as signed with
+// gpg.format=ssh, and returns the key of the allowed ones that made the
+// signature. If the signature wasn't made by an allowed key, or doesn't match
+// the tag, the error returned wraps ErrSignatureMismatch.
+func (t *Tag) VerifySSH(allowed ...ssh.PublicKey) (ssh.PublicKey, error) {
+	payload, err := t.signedPayload()
 	if err != nil {
 		return nil, err
 	}
 
-	return openpgp.CheckArmoredDetachedSignature(keyring, er, signature, nil)
+	return verifySSH(allowed, payload, t.PGPSignature)
+}
+
+// signedPayload returns the content of the tag covered by its signature.
+func (t *Tag) signedPayload() ([]byte, error) {
+	return signedPayload(t.s, plumbing.TagObject, t.Hash, stripTagSignature, t.EncodeWithoutSignature)
 }
 
 // TagIter provides an iterator for a set of tags.
@@ -199,6 +199,61 @@ func (t *Tree) Files() *FileIter {
 	return NewFileIter(t.s, t)
 }
 
+// TreeWalkFunc is the function called by Tree.Walk for each entry, with its
+// path from the root of the walked tree. For a directory, returning false
+// skips its content. Returning an error stops the walk.
+type TreeWalkFunc func(path string, entry TreeEntry) (descend bool, err error)
+
+// Walk walks the tree in depth-first order, calling fn for each entry, before
+// the entries of the directory it is, if any, in the order git sorts them.
+// The subtrees are only read when fn descends into them, and the blobs are
+// never read, their content can be loaded with GetBlob when needed. Submodules
+// aren't walked into. If fn returns storer.ErrStop the walk stops without
+// error, any other error stops it and is returned.
+func (t *Tree) Walk(fn TreeWalkFunc) error {
+	err := t.walk("", fn, 0)
+	if err == storer.ErrStop {
+		return nil
+	}
+
+	return err
+}
+
+func (t *Tree) walk(base string, fn TreeWalkFunc, depth int) error {
+	if depth > maxTreeDepth {
+		return ErrMaxTreeDepth
+	}
+
+	entries := t.Entries
+	if !sort.IsSorted(TreeEntrySorter(entries)) {
+		entries = append([]TreeEntry(nil), entries...)
+		sort.Sort(TreeEntrySorter(entries))
+	}
+
+	for _, e := range entries {
+		name := simpleJoin(base, e.Name)
+		descend, err := fn(name, e)
+		if err != nil {
+			return err
+		}
+
+		if !descend || e.Mode != filemode.Dir {
+			continue
+		}
+
+		tree, err := GetTree(t.s, e.Hash)
+		if err != nil {
+			return err
+		}
+
+		if err := tree.walk(name, fn, depth+1); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 // ID returns the object ID of the tree. The returned value will always match
 // the current value of Tree.Hash.
 //
@@ -257,6 +312,7 @@ func (t *Tree) Decode(o plumbing.EncodedObject) (err error) {
 		}
 
 		var hash plumbing.Hash
+		hash.ResetBySize(t.Hash.Size())
 		if _, err = hash.ReadFrom(r); err != nil {
 			return err
 		}
@@ -364,12 +420,24 @@ func (t *Tree) Patch(to *Tree) (*Patch, error) {
 // NOTE: Since version 5.1.0 the renames are correctly handled, the settings
 // used are the recommended options DefaultDiffTreeOptions.
 func (t *Tree) PatchContext(ctx context.Context, to *Tree) (*Patch, error) {
-	changes, err := t.DiffContext(ctx, to)
+	return t.PatchWithOptions(ctx, to, nil)
+}
+
+// PatchWithOptions returns the Patch between the trees, generated as
+// described by the given PatchOptions. If context expires, an error will be
+// returned. Provided context must be non-nil.
+func (t *Tree) PatchWithOptions(ctx context.Context, to *Tree, opts *PatchOptions) (*Patch, error) {
+	diffOpts := DefaultDiffTreeOptions
+	if opts != nil && opts.DiffTreeOptions != nil {
+		diffOpts = opts.DiffTreeOptions
+	}
+
+	changes, err := DiffTreeWithOptions(ctx, t, to, diffOpts)
 	if err != nil {
 		return nil, err
 	}
 
-	return changes.PatchContext(ctx)
+	return changes.PatchWithOptions(ctx, opts)
 }
 
 // treeEntryIter facilitates iterating through the TreeEntry objects in a Tree.
@@ -0,0 +1,180 @@
+package object
+
+import (
+	"path"
+	"strings"
+
+	"github.com/go-git/go-git/v6/plumbing/filemode"
+)
+
+const globRecursive = "**"
+
+// Glob returns the entries of the tree, at any depth, whose path matches the
+// given pattern. The pattern is a slash separated path, where each element
+// is matched as path.Match does, and `**` matches zero or more directories.
+//
+// The returned entries are copies having the full path from the tree as Name,
+// in the order the tree is walked. When a pattern matches a subtree, its own
+// entry is returned, the entries beneath it only if they match the pattern as
+// well. Subtrees that can't contain matches, e.g. the ones not matching a
+// literal prefix of the pattern, aren't walked.
+func (t *Tree) Glob(pattern string) ([]*TreeEntry, error) {
+	g, err := newTreeGlob(pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	var matches []*TreeEntry
+	err = g.walk(t, "", g.closure([]int{0}), 0, &matches)
+	return matches, err
+}
+
+type treeGlob struct {
+	parts []string
+}
+
+func newTreeGlob(pattern string) (*treeGlob, error) {
+	pattern = strings.Trim(pattern, "/")
+	if pattern == "" {
+		return nil, path.ErrBadPattern
+	}
+
+	g := &treeGlob{}
+	for _, part := range strings.Split(pattern, "/") {
+		if part == globRecursive {
+			// Consecutive `**` are equivalent to a single one.
+			if n := len(g.parts); n > 0 && g.parts[n-1] == globRecursive {
+				continue
+			}
+		} else if _, err := path.Match(part, ""); err != nil {
+			return nil, err
+		}
+
+		g.parts = append(g.parts, part)
+	}
+
+	return g, nil
+}
+
+// walk looks for the entries of the given tree matching the pattern. The
+// states are the indexes of the pattern parts the entries are matched with.
+func (g *treeGlob) walk(t *Tree, prefix string, states []int, depth int, matches *[]*TreeEntry) error {
+	if depth > maxTreeDepth {
+		return ErrMaxTreeDepth
+	}
+
+	for _, e := range g.candidates(t, states) {
+		next := g.advance(states, e.Name)
+		if len(next) == 0 {
+			continue
+		}
+
+		fullpath := simpleJoin(prefix, e.Name)
+		if next[len(next)-1] == len(g.parts) {
+			*matches = append(*matches, &TreeEntry{Name: fullpath, Mode: e.Mode, Hash: e.Hash})
+			next = next[:len(next)-1]
+		}
+
+		if len(next) == 0 || e.Mode != filemode.Dir {
+			continue
+		}
+
+		subtree, err := GetTree(t.s, e.Hash)
+		if err != nil {
+			return err
+		}
+
+		if err := g.walk(subtree, fullpath, next, depth+1, matches); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// candidates returns the entries of the tree that may match the parts of the
+// pattern at the given states. Literal parts are looked up by name, instead
+// of checking every entry of the tree.
+func (g *treeGlob) candidates(t *Tree, states []int) []*TreeEntry {
+	var names []string
+	for _, i := range states {
+		if i == len(g.parts) {
+			continue
+		}
+
+		part := g.parts[i]
+		if part == globRecursive || strings.ContainsAny(part, `*?[\`) {
+			names = nil
+			break
+		}
+
+		names = append(names, part)
+	}
+
+	var entries []*TreeEntry
+	if names == nil {
+		for i := range t.Entries {
+			entries = append(entries, &t.Entries[i])
+		}
+
+		return entries
+	}
+
+	for _, name := range names {
+		if e, err := t.entry(name); err == nil {
+			entries = append(entries, e)
+		}
+	}
+
+	return entries
+}
+
+// advance returns the states reached after matching the given name, sorted.
+func (g *treeGlob) advance(states []int, name string) []int {
+	var next []int
+	for _, i := range states {
+		if i == len(g.parts) {
+			continue
+		}
+
+		part := g.parts[i]
+		if part == globRecursive {
+			next = append(next, i)
+			if i == len(g.parts)-1 {
+				next = append(next, i+1)
+			}
+
+			continue
+		}
+
+		if ok, _ := path.Match(part, name); ok {
+			next = append(next, i+1)
+		}
+	}
+
+	return g.closure(next)
+}
+
+// closure adds to the given states the ones reachable matching `**` with no
+// directories, returning them sorted and without duplicates. As git does, a
+// trailing `**` matches one or more entries, so `dir/**` doesn't match dir.
+func (g *treeGlob) closure(states []int) []int {
+	seen := make([]bool, len(g.parts)+1)
+	for _, i := range states {
+		for ; !seen[i]; i++ {
+			seen[i] = true
+			if i >= len(g.parts)-1 || g.parts[i] != globRecursive {
+				break
+			}
+		}
+	}
+
+	var res []int
+	for i, ok := range seen {
+		if ok {
+			res = append(res, i)
+		}
+	}
+
+	return res
+}
@@ -0,0 +1,105 @@
+package object
+
+import (
+	"path"
+
+	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/go-git/go-git/v6/plumbing/filemode"
+	"github.com/go-git/go-git/v6/plumbing/storer"
+)
+
+func (s *TreeSuite) TestGlob() {
+	for _, tc := range []struct {
+		pattern  string
+		expected []string
+	}{
+		{"LICENSE", []string{"LICENSE"}},
+		{"*.go", nil},
+		{"*/*.go", []string{"go/example.go", "vendor/foo.go"}},
+		{"**/*.go", []string{"go/example.go", "vendor/foo.go"}},
+		{"json/*", []string{"json/long.json", "json/short.json"}},
+		{"json/?hort.json", []string{"json/short.json"}},
+		{"**/short.json", []string{"json/short.json"}},
+		{"json/**", []string{"json/long.json", "json/short.json"}},
+		{"json/**/long.json", []string{"json/long.json"}},
+		{"/vendor/", []string{"vendor"}},
+		{"[CL]*", []string{"CHANGELOG", "LICENSE"}},
+		{"missing/*", nil},
+		{"**", []string{
+			".gitignore", "CHANGELOG", "LICENSE", "binary.jpg",
+			"go", "go/example.go",
+			"json", "json/long.json", "json/short.json",
+			"php", "php/crappy.php",
+			"vendor", "vendor/foo.go",
+		}},
+	} {
+		entries, err := s.Tree.Glob(tc.pattern)
+		s.NoError(err, tc.pattern)
+
+		var names []string
+		for _, e := range entries {
+			names = append(names, e.Name)
+		}
+
+		s.Equal(tc.expected, names, tc.pattern)
+	}
+}
+
+func (s *TreeSuite) TestGlobEntries() {
+	entries, err := s.Tree.Glob("go")
+	s.NoError(err)
+	s.Equal([]*TreeEntry{{
+		Name: "go",
+		Mode: filemode.Dir,
+		Hash: plumbing.NewHash("a39771a7651f97faf5c72e08224d857fc35133db"),
+	}}, entries)
+
+	entries, err = s.Tree.Glob("go/*")
+	s.NoError(err)
+	s.Len(entries, 1)
+	s.Equal("go/example.go", entries[0].Name)
+	s.Equal(filemode.Regular, entries[0].Mode)
+
+	e, err := s.Tree.FindEntry("go/example.go")
+	s.NoError(err)
+	s.Equal(e.Hash, entries[0].Hash)
+}
+
+func (s *TreeSuite) TestGlobBadPattern() {
+	_, err := s.Tree.Glob("json/[")
+	s.ErrorIs(err, path.ErrBadPattern)
+
+	_, err = s.Tree.Glob("/")
+	s.ErrorIs(err, path.ErrBadPattern)
+}
+
+type countingStorer struct {
+	storer.EncodedObjectStorer
+	trees int
+}
+
+func (s *countingStorer) EncodedObject(t plumbing.ObjectType, h plumbing.Hash) (plumbing.EncodedObject, error) {
+	if t == plumbing.TreeObject {
+		s.trees++
+	}
+
+	return s.EncodedObjectStorer.EncodedObject(t, h)
+}
+
+func (s *TreeSuite) TestGlobSkipsSubtrees() {
+	sto := &countingStorer{EncodedObjectStorer: s.Storer}
+	tree, err := GetTree(sto, s.Tree.Hash)
+	s.Require().NoError(err)
+
+	sto.trees = 0
+	entries, err := tree.Glob("json/*.json")
+	s.NoError(err)
+	s.Len(entries, 2)
+	s.Equal(1, sto.trees)
+
+	sto.trees = 0
+	entries, err = tree.Glob("*/foo.go")
+	s.NoError(err)
+	s.Len(entries, 1)
+	s.Equal(4, sto.trees)
+}
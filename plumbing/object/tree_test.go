@@ -406,6 +406,90 @@ func (s *TreeSuite) TestTreeWalkerNextNonRecursive() {
 	s.Equal(8, count)
 }
 
+func (s *TreeSuite) TestWalk() {
+	commit := s.commit(plumbing.NewHash("6ecf0ef2c2dffb796033e5a02219af86ec6584e5"))
+	tree, err := commit.Tree()
+	s.NoError(err)
+
+	var paths []string
+	err = tree.Walk(func(path string, entry TreeEntry) (bool, error) {
+		paths = append(paths, path)
+		return true, nil
+	})
+	s.NoError(err)
+
+	var expected []string
+	for _, e := range treeWalkerExpects {
+		expected = append(expected, e.Path)
+	}
+
+	s.Equal(expected, paths)
+}
+
+func (s *TreeSuite) TestWalkPrune() {
+	commit := s.commit(plumbing.NewHash("6ecf0ef2c2dffb796033e5a02219af86ec6584e5"))
+	tree, err := commit.Tree()
+	s.NoError(err)
+
+	var paths []string
+	err = tree.Walk(func(path string, entry TreeEntry) (bool, error) {
+		paths = append(paths, path)
+		return path != "json" && path != "vendor", nil
+	})
+	s.NoError(err)
+
+	s.Equal([]string{
+		".gitignore", "CHANGELOG", "LICENSE", "binary.jpg",
+		"go", "go/example.go", "json", "php", "php/crappy.php", "vendor",
+	}, paths)
+}
+
+func (s *TreeSuite) TestWalkStop() {
+	commit := s.commit(plumbing.NewHash("6ecf0ef2c2dffb796033e5a02219af86ec6584e5"))
+	tree, err := commit.Tree()
+	s.NoError(err)
+
+	var paths []string
+	err = tree.Walk(func(path string, entry TreeEntry) (bool, error) {
+		paths = append(paths, path)
+		if path == "go/example.go" {
+			return false, storer.ErrStop
+		}
+
+		return true, nil
+	})
+	s.NoError(err)
+	s.Equal([]string{".gitignore", "CHANGELOG", "LICENSE", "binary.jpg", "go", "go/example.go"}, paths)
+
+	errWalk := errors.New("walk error")
+	paths = nil
+	err = tree.Walk(func(path string, entry TreeEntry) (bool, error) {
+		paths = append(paths, path)
+		return false, errWalk
+	})
+	s.ErrorIs(err, errWalk)
+	s.Equal([]string{".gitignore"}, paths)
+}
+
+func (s *TreeSuite) TestWalkUnsorted() {
+	tree := &Tree{Entries: []TreeEntry{
+		{Name: "foo.txt", Mode: filemode.Regular},
+		{Name: "foo", Mode: filemode.Dir},
+		{Name: "bar", Mode: filemode.Regular},
+	}}
+
+	var paths []string
+	err := tree.Walk(func(path string, entry TreeEntry) (bool, error) {
+		paths = append(paths, path)
+		return false, nil
+	})
+	s.NoError(err)
+
+	// As git sorts them, "foo" is compared as "foo/".
+	s.Equal([]string{"bar", "foo.txt", "foo"}, paths)
+	s.Equal("foo.txt", tree.Entries[0].Name)
+}
+
 func (s *TreeSuite) TestPatchContext_ToNil() {
 	commit := s.commit(plumbing.NewHash("6ecf0ef2c2dffb796033e5a02219af86ec6584e5"))
 	tree, err := commit.Tree()
@@ -0,0 +1,279 @@
+package object
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"strings"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	pgperrors "github.com/ProtonMail/go-crypto/openpgp/errors"
+	"golang.org/x/crypto/ssh"
+
+	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/go-git/go-git/v6/plumbing/storer"
+)
+
+var (
+	// ErrNoSignature is returned when verifying an object that isn't signed.
+	ErrNoSignature = errors.New("object is not signed")
+	// ErrUnsupportedSignature is returned when the signature of an object
+	// isn't of the kind expected by the verification method used.
+	ErrUnsupportedSignature = errors.New("unsupported signature type")
+	// ErrSignatureMismatch is returned when the signature of an object wasn't
+	// made by any of the given keys, or doesn't match the signed content.
+	ErrSignatureMismatch = errors.New("signature mismatch")
+)
+
+const (
+	// sshSigMagic is the preamble of the SSH signatures, see
+	// https://github.com/openssh/openssh-portable/blob/master/PROTOCOL.sshsig
+	sshSigMagic   = "SSHSIG"
+	sshSigVersion = 1
+	sshSigBegin   = "-----BEGIN SSH SIGNATURE-----"
+	sshSigEnd     = "-----END SSH SIGNATURE-----"
+	// sshSigNamespace is the namespace git uses to sign objects.
+	sshSigNamespace = "git"
+)
+
+// signedPayload returns the content covered by the signature of an object. As
+// git does, it's the object as stored with the signature removed, so headers
+// unknown to go-git and their order are kept. The strip function removes the
+// signature from the raw object. When the object isn't in the storer, the
+// encode function is used to rebuild the object without its signature.
+func signedPayload(
+	s storer.EncodedObjectStorer,
+	t plumbing.ObjectType,
+	h plumbing.Hash,
+	strip func([]byte) []byte,
+	encode func(plumbing.EncodedObject) error,
+) ([]byte, error) {
+	if s != nil && !h.IsZero() {
+		if o, err := s.EncodedObject(t, h); err == nil {
+			raw, err := readObject(o)
+			if err != nil {
+				return nil, err
+			}
+
+			return strip(raw), nil
+		}
+	}
+
+	o := &plumbing.MemoryObject{}
+	if err := encode(o); err != nil {
+		return nil, err
+	}
+
+	return readObject(o)
+}
+
+func readObject(o plumbing.EncodedObject) ([]byte, error) {
+	r, err := o.Reader()
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	return io.ReadAll(r)
+}
+
+// stripCommitSignature removes the signature headers, with their continuation
+// lines, from a raw commit.
+func stripCommitSignature(raw []byte) []byte {
+	var out bytes.Buffer
+	var inSig bool
+	for len(raw) > 0 {
+		line := raw
+		if i := bytes.IndexByte(raw, '\n'); i >= 0 {
+			line = raw[:i+1]
+		}
+		raw = raw[len(line):]
+
+		if len(line) == 1 && line[0] == '\n' {
+			out.Write(line)
+			out.Write(raw)
+			break
+		}
+
+		if inSig && line[0] == ' ' {
+			continue
+		}
+
+		inSig = bytes.HasPrefix(line, []byte(headerpgp+" ")) ||
+			bytes.HasPrefix(line, []byte(headerpgp+"-sha256 "))
+		if !inSig {
+			out.Write(line)
+		}
+	}
+
+	return out.Bytes()
+}
+
+// stripTagSignature removes the signature appended to the message of a raw
+// tag.
+func stripTagSignature(raw []byte) []byte {
+	if pos, _ := parseSignedBytes(raw); pos >= 0 {
+		return raw[:pos]
+	}
+
+	return raw
+}
+
+// verifyPGP checks the armored OpenPGP signature of the payload against the
+// keyring, returning the entity of the key that made it.
+func verifyPGP(keyring openpgp.KeyRing, payload []byte, signature string) (*openpgp.Entity, error) {
+	if signature == "" {
+		return nil, ErrNoSignature
+	}
+
+	if t := signatureTypeOf(signature); t != signatureTypeOpenPGP && t != signatureTypeUnknown {
+		return nil, ErrUnsupportedSignature
+	}
+
+	e, err := openpgp.CheckArmoredDetachedSignature(keyring, bytes.NewReader(payload), strings.NewReader(signature), nil)
+	if err != nil {
+		var sigErr pgperrors.SignatureError
+		if errors.As(err, &sigErr) || errors.Is(err, pgperrors.ErrUnknownIssuer) {
+			return nil, fmt.Errorf("%w: %w", ErrSignatureMismatch, err)
+		}
+
+		return nil, err
+	}
+
+	return e, nil
+}
+
+// sshSignature is the blob of an SSH signature, after the magic preamble.
+type sshSignature struct {
+	Version       uint32
+	PublicKey     []byte
+	Namespace     string
+	Reserved      string
+	HashAlgorithm string
+	Signature     []byte
+}
+
+// sshSignedData is the data signed by an SSH signature, after the magic
+// preamble.
+type sshSignedData struct {
+	Namespace     string
+	Reserved      string
+	HashAlgorithm string
+	Hash          []byte
+}
+
+// verifySSH checks the armored SSH signature of the payload, made in the git
+// namespace, against the allowed keys, returning the key that made it.
+func verifySSH(keys []ssh.PublicKey, payload []byte, signature string) (ssh.PublicKey, error) {
+	if signature == "" {
+		return nil, ErrNoSignature
+	}
+
+	if signatureTypeOf(signature) != signatureTypeSSH {
+		return nil, ErrUnsupportedSignature
+	}
+
+	sig, err := decodeSSHSignature(signature)
+	if err != nil {
+		return nil, err
+	}
+
+	if sig.Namespace != sshSigNamespace {
+		return nil, fmt.Errorf("%w: signature namespace is %q", ErrSignatureMismatch, sig.Namespace)
+	}
+
+	pub, err := ssh.ParsePublicKey(sig.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	if !containsKey(keys, pub) {
+		return nil, fmt.Errorf("%w: %s key %s is not allowed", ErrSignatureMismatch, pub.Type(), ssh.FingerprintSHA256(pub))
+	}
+
+	var h hash.Hash
+	switch sig.HashAlgorithm {
+	case "sha256":
+		h = sha256.New()
+	case "sha512":
+		h = sha512.New()
+	default:
+		return nil, fmt.Errorf("%w: hash algorithm %q", ErrUnsupportedSignature, sig.HashAlgorithm)
+	}
+	h.Write(payload)
+
+	signed := append([]byte(sshSigMagic), ssh.Marshal(sshSignedData{
+		Namespace:     sig.Namespace,
+		Reserved:      sig.Reserved,
+		HashAlgorithm: sig.HashAlgorithm,
+		Hash:          h.Sum(nil),
+	})...)
+
+	s := &ssh.Signature{}
+	if err := ssh.Unmarshal(sig.Signature, s); err != nil {
+		return nil, err
+	}
+
+	if err := pub.Verify(signed, s); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrSignatureMismatch, err)
+	}
+
+	return pub, nil
+}
+
+// decodeSSHSignature decodes an armored SSH signature.
+func decodeSSHSignature(armored string) (*sshSignature, error) {
+	body := strings.TrimSpace(armored)
+	body, ok := strings.CutPrefix(body, sshSigBegin)
+	if !ok {
+		return nil, ErrUnsupportedSignature
+	}
+
+	body, ok = strings.CutSuffix(body, sshSigEnd)
+	if !ok {
+		return nil, errors.New("malformed SSH signature: missing end marker")
+	}
+
+	blob, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(body), ""))
+	if err != nil {
+		return nil, fmt.Errorf("malformed SSH signature: %w", err)
+	}
+
+	blob, ok = bytes.CutPrefix(blob, []byte(sshSigMagic))
+	if !ok {
+		return nil, errors.New("malformed SSH signature: invalid preamble")
+	}
+
+	sig := &sshSignature{}
+	if err := ssh.Unmarshal(blob, sig); err != nil {
+		return nil, fmt.Errorf("malformed SSH signature: %w", err)
+	}
+
+	if sig.Version != sshSigVersion {
+		return nil, fmt.Errorf("%w: SSH signature version %d", ErrUnsupportedSignature, sig.Version)
+	}
+
+	return sig, nil
+}
+
+// signatureTypeOf returns the type of the armored signature, ignoring any
+// leading whitespace.
+func signatureTypeOf(signature string) signatureType {
+	return typeForSignature([]byte(strings.TrimSpace(signature)))
+}
+
+func containsKey(keys []ssh.PublicKey, key ssh.PublicKey) bool {
+	b := key.Marshal()
+	for _, k := range keys {
+		if bytes.Equal(k.Marshal(), b) {
+			return true
+		}
+	}
+
+	return false
+}
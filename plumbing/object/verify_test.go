@@ -0,0 +1,238 @@
+package object
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha512"
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/go-git/go-git/v6/storage/memory"
+	"github.com/stretchr/testify/suite"
+	"golang.org/x/crypto/ssh"
+)
+
+type VerifySuite struct {
+	suite.Suite
+	storer *memory.Storage
+}
+
+func TestVerifySuite(t *testing.T) {
+	suite.Run(t, new(VerifySuite))
+}
+
+func (s *VerifySuite) SetupTest() {
+	s.storer = memory.NewStorage()
+}
+
+const verifyCommitPayload = "tree 52a266a58f2c028ad7de4dfd3a72fdf76b0d4e24\n" +
+	"parent e4fbb611cd14149c7a78e9c08425f59f4b736a9a\n" +
+	"author go-git <go-git@example.com> 1617402711 +0000\n" +
+	"committer go-git <go-git@example.com> 1617402711 +0000\n" +
+	"x-unknown-header some value\n" +
+	"\n" +
+	"test\n"
+
+const verifyTagPayload = "object 52a266a58f2c028ad7de4dfd3a72fdf76b0d4e24\n" +
+	"type commit\n" +
+	"tag v1.0.0\n" +
+	"tagger go-git <go-git@example.com> 1617402711 +0000\n" +
+	"\n" +
+	"test\n"
+
+// storeSignedCommit stores the commit payload signed with the given
+// signature, inserting the gpgsig header before the message, and returns the
+// decoded commit.
+func (s *VerifySuite) storeSignedCommit(payload, signature string) *Commit {
+	headers, msg, _ := strings.Cut(payload, "\n\n")
+	sig := strings.ReplaceAll(strings.TrimSuffix(signature, "\n"), "\n", "\n ")
+	raw := headers + "\n" + headerpgp + " " + sig + "\n\n" + msg
+
+	h := s.store(plumbing.CommitObject, raw)
+	c, err := GetCommit(s.storer, h)
+	s.Require().NoError(err)
+	return c
+}
+
+func (s *VerifySuite) storeSignedTag(payload, signature string) *Tag {
+	h := s.store(plumbing.TagObject, payload+signature)
+	t, err := GetTag(s.storer, h)
+	s.Require().NoError(err)
+	return t
+}
+
+func (s *VerifySuite) store(t plumbing.ObjectType, raw string) plumbing.Hash {
+	o := s.storer.NewEncodedObject()
+	o.SetType(t)
+	w, err := o.Writer()
+	s.Require().NoError(err)
+	_, err = w.Write([]byte(raw))
+	s.Require().NoError(err)
+	s.Require().NoError(w.Close())
+
+	h, err := s.storer.SetEncodedObject(o)
+	s.Require().NoError(err)
+	return h
+}
+
+func (s *VerifySuite) pgpSign(e *openpgp.Entity, payload string) string {
+	var buf bytes.Buffer
+	s.Require().NoError(openpgp.ArmoredDetachSign(&buf, e, strings.NewReader(payload), nil))
+	return buf.String() + "\n"
+}
+
+func (s *VerifySuite) sshSigner() ssh.Signer {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	s.Require().NoError(err)
+	signer, err := ssh.NewSignerFromKey(key)
+	s.Require().NoError(err)
+	return signer
+}
+
+// sshSign signs the payload as `ssh-keygen -Y sign -n git` does.
+func (s *VerifySuite) sshSign(signer ssh.Signer, payload string) string {
+	h := sha512.Sum512([]byte(payload))
+	signed := append([]byte(sshSigMagic), ssh.Marshal(sshSignedData{
+		Namespace:     sshSigNamespace,
+		HashAlgorithm: "sha512",
+		Hash:          h[:],
+	})...)
+
+	sig, err := signer.Sign(rand.Reader, signed)
+	s.Require().NoError(err)
+
+	blob := append([]byte(sshSigMagic), ssh.Marshal(sshSignature{
+		Version:       sshSigVersion,
+		PublicKey:     signer.PublicKey().Marshal(),
+		Namespace:     sshSigNamespace,
+		HashAlgorithm: "sha512",
+		Signature:     ssh.Marshal(sig),
+	})...)
+
+	encoded := base64.StdEncoding.EncodeToString(blob)
+	var lines []string
+	for len(encoded) > 70 {
+		lines = append(lines, encoded[:70])
+		encoded = encoded[70:]
+	}
+	lines = append(lines, encoded)
+
+	return sshSigBegin + "\n" + strings.Join(lines, "\n") + "\n" + sshSigEnd + "\n"
+}
+
+func (s *VerifySuite) TestCommitVerifyKeyRing() {
+	e, err := openpgp.NewEntity("go-git", "", "go-git@example.com", nil)
+	s.Require().NoError(err)
+
+	c := s.storeSignedCommit(verifyCommitPayload, s.pgpSign(e, verifyCommitPayload))
+
+	signer, err := c.VerifyKeyRing(openpgp.EntityList{e})
+	s.Require().NoError(err)
+	s.Equal(e.PrimaryKey.KeyId, signer.PrimaryKey.KeyId)
+
+	other, err := openpgp.NewEntity("other", "", "other@example.com", nil)
+	s.Require().NoError(err)
+
+	_, err = c.VerifyKeyRing(openpgp.EntityList{other})
+	s.ErrorIs(err, ErrSignatureMismatch)
+}
+
+func (s *VerifySuite) TestCommitVerifyKeyRingTampered() {
+	e, err := openpgp.NewEntity("go-git", "", "go-git@example.com", nil)
+	s.Require().NoError(err)
+
+	tampered := strings.Replace(verifyCommitPayload, "test\n", "tampered\n", 1)
+	c := s.storeSignedCommit(tampered, s.pgpSign(e, verifyCommitPayload))
+
+	_, err = c.VerifyKeyRing(openpgp.EntityList{e})
+	s.ErrorIs(err, ErrSignatureMismatch)
+}
+
+func (s *VerifySuite) TestCommitVerifySSH() {
+	signer := s.sshSigner()
+	c := s.storeSignedCommit(verifyCommitPayload, s.sshSign(signer, verifyCommitPayload))
+
+	other := s.sshSigner()
+	key, err := c.VerifySSH(other.PublicKey(), signer.PublicKey())
+	s.Require().NoError(err)
+	s.Equal(signer.PublicKey().Marshal(), key.Marshal())
+
+	_, err = c.VerifySSH(other.PublicKey())
+	s.ErrorIs(err, ErrSignatureMismatch)
+
+	_, err = c.VerifyKeyRing(openpgp.EntityList{})
+	s.ErrorIs(err, ErrUnsupportedSignature)
+}
+
+func (s *VerifySuite) TestCommitVerifySSHTampered() {
+	signer := s.sshSigner()
+
+	tampered := strings.Replace(verifyCommitPayload, "test\n", "tampered\n", 1)
+	c := s.storeSignedCommit(tampered, s.sshSign(signer, verifyCommitPayload))
+
+	_, err := c.VerifySSH(signer.PublicKey())
+	s.ErrorIs(err, ErrSignatureMismatch)
+}
+
+func (s *VerifySuite) TestCommitVerifyNotSigned() {
+	h := s.store(plumbing.CommitObject, verifyCommitPayload)
+	c, err := GetCommit(s.storer, h)
+	s.Require().NoError(err)
+
+	_, err = c.VerifySSH(s.sshSigner().PublicKey())
+	s.ErrorIs(err, ErrNoSignature)
+
+	_, err = c.VerifyKeyRing(openpgp.EntityList{})
+	s.ErrorIs(err, ErrNoSignature)
+}
+
+func (s *VerifySuite) TestTagVerifyKeyRing() {
+	e, err := openpgp.NewEntity("go-git", "", "go-git@example.com", nil)
+	s.Require().NoError(err)
+
+	t := s.storeSignedTag(verifyTagPayload, s.pgpSign(e, verifyTagPayload))
+
+	signer, err := t.VerifyKeyRing(openpgp.EntityList{e})
+	s.Require().NoError(err)
+	s.Equal(e.PrimaryKey.KeyId, signer.PrimaryKey.KeyId)
+}
+
+func (s *VerifySuite) TestTagVerifySSH() {
+	signer := s.sshSigner()
+	t := s.storeSignedTag(verifyTagPayload, s.sshSign(signer, verifyTagPayload))
+
+	key, err := t.VerifySSH(signer.PublicKey())
+	s.Require().NoError(err)
+	s.Equal(signer.PublicKey().Marshal(), key.Marshal())
+
+	t.Message = "tampered\n"
+	t.s = nil
+	_, err = t.VerifySSH(signer.PublicKey())
+	s.ErrorIs(err, ErrSignatureMismatch)
+}
+
+func (s *VerifySuite) TestStripCommitSignature() {
+	raw := "tree 52a266a58f2c028ad7de4dfd3a72fdf76b0d4e24\n" +
+		"gpgsig -----BEGIN SSH SIGNATURE-----\n" +
+		" abc\n" +
+		" -----END SSH SIGNATURE-----\n" +
+		"gpgsig-sha256 -----BEGIN SSH SIGNATURE-----\n" +
+		" def\n" +
+		" -----END SSH SIGNATURE-----\n" +
+		"x-header foo\n" +
+		"\n" +
+		"gpgsig in the message\n" +
+		" is kept\n"
+
+	expected := "tree 52a266a58f2c028ad7de4dfd3a72fdf76b0d4e24\n" +
+		"x-header foo\n" +
+		"\n" +
+		"gpgsig in the message\n" +
+		" is kept\n"
+
+	s.Equal(expected, string(stripCommitSignature([]byte(raw))))
+}
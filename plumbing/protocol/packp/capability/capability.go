@@ -248,6 +248,9 @@ const (
 	// Filter if present, fetch-pack may send "filter" commands to request a
 	// partial clone or partial fetch and request that the server omit various objects from the packfile
 	Filter Capability = "filter"
+	// ObjectInfo is a protocol v2 command, advertised by servers that can
+	// report the size of objects without sending their content.
+	ObjectInfo Capability = "object-info"
 )
 
 const userAgent = "go-git/6.x"
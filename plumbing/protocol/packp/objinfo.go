@@ -0,0 +1,145 @@
+package packp
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/go-git/go-git/v6/plumbing/format/pktline"
+	"github.com/go-git/go-git/v6/plumbing/protocol/packp/capability"
+)
+
+// objectInfoSize is the attribute requesting the size of the objects.
+const objectInfoSize = "size"
+
+// DecodeCapabilitiesV2 decodes the capability advertisement of protocol v2,
+// which follows the version line, up to the flush packet. Each line holds a
+// capability, optionally followed by "=" and a list of values separated by
+// spaces.
+//
+// See https://git-scm.com/docs/gitprotocol-v2#_capability_advertisement
+func DecodeCapabilitiesV2(r io.Reader) (*capability.List, error) {
+	caps := capability.NewList()
+	for {
+		l, p, err := pktline.ReadLine(r)
+		if err != nil {
+			return nil, err
+		}
+
+		if l == pktline.Flush {
+			return caps, nil
+		}
+
+		name, value, _ := strings.Cut(strings.TrimSuffix(string(p), "\n"), "=")
+		c := capability.Capability(name)
+		values := strings.Fields(value)
+		if c == capability.Agent {
+			values = []string{value}
+		}
+
+		if err := caps.Add(c, values...); err != nil {
+			return nil, fmt.Errorf("invalid capability %q: %w", name, err)
+		}
+	}
+}
+
+// ObjectSize holds the size of an object, as reported by the object-info
+// command.
+type ObjectSize struct {
+	Hash plumbing.Hash
+	// Size is the size of the object content, or -1 if the server doesn't
+	// have the object.
+	Size int64
+}
+
+// ObjectInfoRequest is a protocol v2 object-info command request, asking
+// the server for the size of the given objects.
+//
+// See https://git-scm.com/docs/gitprotocol-v2#_object_info
+type ObjectInfoRequest struct {
+	// Agent is sent as the agent capability of the request, if not empty.
+	Agent  string
+	Hashes []plumbing.Hash
+}
+
+// Encode encodes the request into the given writer.
+func (req *ObjectInfoRequest) Encode(w io.Writer) error {
+	if _, err := pktline.Writeln(w, "command="+capability.ObjectInfo.String()); err != nil {
+		return err
+	}
+
+	if req.Agent != "" {
+		if _, err := pktline.Writeln(w, capability.Agent.String()+"="+req.Agent); err != nil {
+			return err
+		}
+	}
+
+	if err := pktline.WriteDelim(w); err != nil {
+		return err
+	}
+
+	if _, err := pktline.Writeln(w, objectInfoSize); err != nil {
+		return err
+	}
+
+	for _, h := range req.Hashes {
+		if _, err := pktline.Writeln(w, "oid "+h.String()); err != nil {
+			return err
+		}
+	}
+
+	return pktline.WriteFlush(w)
+}
+
+// ObjectInfoResponse is the response to an object-info command request.
+type ObjectInfoResponse struct {
+	Sizes []ObjectSize
+}
+
+// Decode decodes the response from the given reader. It starts with the
+// attributes requested, followed by a line for each object, up to the flush
+// packet.
+func (res *ObjectInfoResponse) Decode(r io.Reader) error {
+	l, p, err := pktline.ReadLine(r)
+	if err != nil {
+		return err
+	}
+
+	if l == pktline.Flush {
+		return nil
+	}
+
+	if attrs := strings.TrimSuffix(string(p), "\n"); attrs != objectInfoSize {
+		return fmt.Errorf("unexpected object-info attributes: %q", attrs)
+	}
+
+	for {
+		l, p, err := pktline.ReadLine(r)
+		if err != nil {
+			return err
+		}
+
+		if l == pktline.Flush {
+			return nil
+		}
+
+		line := strings.TrimSuffix(string(p), "\n")
+		oid, size, ok := strings.Cut(line, " ")
+		h, valid := plumbing.FromHex(oid)
+		if !ok || !valid {
+			return fmt.Errorf("malformed object-info line: %q", line)
+		}
+
+		info := ObjectSize{Hash: h, Size: -1}
+		if size != "" {
+			info.Size, err = strconv.ParseInt(size, 10, 64)
+			if err != nil {
+				return fmt.Errorf("malformed object-info line: %q", line)
+			}
+		}
+
+		res.Sizes = append(res.Sizes, info)
+	}
+}
@@ -0,0 +1,90 @@
+package packp
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/go-git/go-git/v6/plumbing/format/pktline"
+	"github.com/go-git/go-git/v6/plumbing/protocol/packp/capability"
+	"github.com/stretchr/testify/suite"
+)
+
+type ObjectInfoSuite struct {
+	suite.Suite
+}
+
+func TestObjectInfoSuite(t *testing.T) {
+	suite.Run(t, new(ObjectInfoSuite))
+}
+
+func (s *ObjectInfoSuite) TestDecodeCapabilitiesV2() {
+	input := pktlines(s.T(),
+		"agent=git/2.39.5 extra\n",
+		"ls-refs=unborn\n",
+		"fetch=shallow wait-for-done\n",
+		"object-info\n",
+		"object-format=sha1\n",
+		"",
+	)
+
+	caps, err := DecodeCapabilitiesV2(bytes.NewReader(input))
+	s.Require().NoError(err)
+	s.Equal([]string{"git/2.39.5 extra"}, caps.Get(capability.Agent))
+	s.Equal([]string{"shallow", "wait-for-done"}, caps.Get("fetch"))
+	s.Equal([]string{"sha1"}, caps.Get(capability.ObjectFormat))
+	s.True(caps.Supports(capability.ObjectInfo))
+	s.True(caps.Supports("ls-refs"))
+}
+
+func (s *ObjectInfoSuite) TestObjectInfoRequestEncode() {
+	req := &ObjectInfoRequest{
+		Agent: "go-git/6.x",
+		Hashes: []plumbing.Hash{
+			plumbing.NewHash("6ecf0ef2c2dffb796033e5a02219af86ec6584e5"),
+			plumbing.NewHash("e8d3ffab552895c19b9fcf7aa264d277cde33881"),
+		},
+	}
+
+	var expected bytes.Buffer
+	pktline.WriteString(&expected, "command=object-info\n")
+	pktline.WriteString(&expected, "agent=go-git/6.x\n")
+	pktline.WriteDelim(&expected)
+	pktline.WriteString(&expected, "size\n")
+	pktline.WriteString(&expected, "oid 6ecf0ef2c2dffb796033e5a02219af86ec6584e5\n")
+	pktline.WriteString(&expected, "oid e8d3ffab552895c19b9fcf7aa264d277cde33881\n")
+	pktline.WriteFlush(&expected)
+
+	var buf bytes.Buffer
+	s.Require().NoError(req.Encode(&buf))
+	s.Equal(expected.String(), buf.String())
+}
+
+func (s *ObjectInfoSuite) TestObjectInfoResponseDecode() {
+	input := pktlines(s.T(),
+		"size",
+		"6ecf0ef2c2dffb796033e5a02219af86ec6584e5 245",
+		"e8d3ffab552895c19b9fcf7aa264d277cde33881 ",
+		"",
+	)
+
+	var res ObjectInfoResponse
+	s.Require().NoError(res.Decode(bytes.NewReader(input)))
+	s.Equal([]ObjectSize{
+		{Hash: plumbing.NewHash("6ecf0ef2c2dffb796033e5a02219af86ec6584e5"), Size: 245},
+		{Hash: plumbing.NewHash("e8d3ffab552895c19b9fcf7aa264d277cde33881"), Size: -1},
+	}, res.Sizes)
+}
+
+func (s *ObjectInfoSuite) TestObjectInfoResponseDecodeMalformed() {
+	for _, lines := range [][]string{
+		{"type", ""},
+		{"size", "6ecf0ef2c2dffb796033e5a02219af86ec6584e5", ""},
+		{"size", "6ecf0ef2c2dffb796033e5a02219af86ec6584e5 foo", ""},
+		{"size", "foo 12", ""},
+		{"size", "6ecf0ef2c2dffb796033e5a02219af86ec6584e5 12"},
+	} {
+		var res ObjectInfoResponse
+		s.Error(res.Decode(bytes.NewReader(pktlines(s.T(), lines...))), lines)
+	}
+}
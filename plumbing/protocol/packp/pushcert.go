@@ -0,0 +1,94 @@
+package packp
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+
+	"github.com/go-git/go-git/v6/plumbing/format/pktline"
+	"github.com/go-git/go-git/v6/plumbing/protocol/packp/capability"
+)
+
+const (
+	pushCert        = "push-cert"
+	pushCertEnd     = "push-cert-end\n"
+	pushCertVersion = "certificate version 0.1\n"
+)
+
+// PushCertificate is a signed push certificate, sent in an update-request
+// instead of the command list when the server advertises the push-cert
+// capability. It certifies that the pusher, who signed it, asked for the
+// reference updates it records.
+type PushCertificate struct {
+	// Pusher identifies the signer of the certificate, as an ident with the
+	// time of the push, e.g. "John Doe <john@doe.com> 1257894000 +0100".
+	Pusher string
+	// Pushee is the URL of the repository pushed to, anonymized if it
+	// contains authentication material.
+	Pushee string
+	// Nonce is the nonce given by the server in the push-cert capability, to
+	// prevent replay attacks.
+	Nonce string
+	// Options are the push options sent along with the certificate.
+	Options []string
+	// Commands are the reference updates certified.
+	Commands []*Command
+	// Signature is the detached signature of the payload of the certificate,
+	// see Payload.
+	Signature []byte
+}
+
+// Payload returns the part of the certificate covered by its signature: the
+// header lines, an empty line and a line per command, as git builds it.
+func (c *PushCertificate) Payload() []byte {
+	var b bytes.Buffer
+	b.WriteString(pushCertVersion)
+	fmt.Fprintf(&b, "pusher %s\n", c.Pusher)
+	if c.Pushee != "" {
+		fmt.Fprintf(&b, "pushee %s\n", c.Pushee)
+	}
+
+	fmt.Fprintf(&b, "nonce %s\n", c.Nonce)
+	for _, o := range c.Options {
+		fmt.Fprintf(&b, "push-option %s\n", o)
+	}
+
+	b.WriteString("\n")
+	for _, cmd := range c.Commands {
+		fmt.Fprintf(&b, "%s\n", formatCommand(cmd))
+	}
+
+	return b.Bytes()
+}
+
+// encode writes the certificate, a pkt-line per line of its payload and its
+// signature, followed by a flush-pkt. Every line ends with a LF, as required.
+func (c *PushCertificate) encode(w io.Writer, caps *capability.List) error {
+	if _, err := pktline.Writef(w, "%s\x00%s\n", pushCert, caps.String()); err != nil {
+		return err
+	}
+
+	cert := append(c.Payload(), c.Signature...)
+	if cert[len(cert)-1] != '\n' {
+		cert = append(cert, '\n')
+	}
+
+	for len(cert) > 0 {
+		line := cert
+		if i := bytes.IndexByte(cert, '\n'); i >= 0 {
+			line = cert[:i+1]
+		}
+
+		if _, err := pktline.Write(w, line); err != nil {
+			return err
+		}
+
+		cert = cert[len(line):]
+	}
+
+	if _, err := pktline.WriteString(w, pushCertEnd); err != nil {
+		return err
+	}
+
+	return pktline.WriteFlush(w)
+}
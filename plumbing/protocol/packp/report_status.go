@@ -13,6 +13,13 @@ import (
 
 const (
 	ok = "ok"
+
+	// AtomicPushFailureStatus is the status of the references not updated by
+	// an atomic push because the update of another reference failed.
+	AtomicPushFailureStatus = "atomic push failure"
+	// atomicTransactionFailedStatus is the status used instead by git when
+	// the updates were valid but committing the transaction failed.
+	atomicTransactionFailedStatus = "atomic transaction failed"
 )
 
 // UnpackStatusErr is the error returned when the report status is not ok.
@@ -48,21 +55,33 @@ func NewReportStatus() *ReportStatus {
 	return &ReportStatus{}
 }
 
-// Error returns the first error if any.
+// Error returns the first error if any. When an atomic push failed, every
+// reference is reported as failed, the error returned is then the one of the
+// first reference whose update failed by itself, if any.
 func (s *ReportStatus) Error() error {
 	if s.UnpackStatus != ok {
 		return UnpackStatusErr{s.UnpackStatus}
 	}
 
+	var first error
 	for _, cs := range s.CommandStatuses {
-		if err := cs.Error(); err != nil {
-			// XXX: Here, we only return the first error following canonical
-			// Git behavior.
+		err := cs.Error()
+		if err == nil {
+			continue
+		}
+
+		// XXX: Here, we only return the first error following canonical
+		// Git behavior.
+		if !cs.isAtomicFailure() {
 			return err
 		}
+
+		if first == nil {
+			first = err
+		}
 	}
 
-	return nil
+	return first
 }
 
 // Encode writes the report status to a writer.
@@ -192,6 +211,12 @@ func (s *CommandStatus) Error() error {
 	}
 }
 
+// isAtomicFailure returns true if the reference was only rejected because
+// the update of another one of an atomic push failed.
+func (s *CommandStatus) isAtomicFailure() bool {
+	return s.Status == AtomicPushFailureStatus || s.Status == atomicTransactionFailedStatus
+}
+
 func (s *CommandStatus) encode(w io.Writer) error {
 	if s.Error() == nil {
 		_, err := pktline.Writef(w, "ok %s\n", s.ReferenceName.String())
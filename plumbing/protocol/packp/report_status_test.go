@@ -42,6 +42,25 @@ func (s *ReportStatusSuite) TestError() {
 	s.Regexp(regexp.MustCompile("command error on ref: "), rs.Error())
 }
 
+func (s *ReportStatusSuite) TestErrorAtomicFailure() {
+	rs := NewReportStatus()
+	rs.UnpackStatus = "ok"
+	rs.CommandStatuses = []*CommandStatus{
+		{ReferenceName: "refs/heads/a", Status: AtomicPushFailureStatus},
+		{ReferenceName: "refs/heads/b", Status: "non-fast-forward"},
+		{ReferenceName: "refs/heads/c", Status: AtomicPushFailureStatus},
+	}
+	s.Equal(CommandStatusErr{ReferenceName: "refs/heads/b", Status: "non-fast-forward"}, rs.Error())
+
+	// When committing the transaction fails, every reference has the same
+	// status.
+	rs.CommandStatuses = []*CommandStatus{
+		{ReferenceName: "refs/heads/a", Status: "atomic transaction failed"},
+		{ReferenceName: "refs/heads/b", Status: "atomic transaction failed"},
+	}
+	s.Equal(CommandStatusErr{ReferenceName: "refs/heads/a", Status: "atomic transaction failed"}, rs.Error())
+}
+
 func (s *ReportStatusSuite) testEncodeDecodeOk(rs *ReportStatus, lines ...string) {
 	s.testDecodeOk(rs, lines...)
 	s.testEncodeOk(rs, lines...)
@@ -26,6 +26,11 @@ func NewMuxer(t Type, w io.Writer) *Muxer {
 		max = MaxPackedSize
 	}
 
+	// The packets can't hold more than the maximum payload of a pktline.
+	if max > pktline.MaxPayloadSize {
+		max = pktline.MaxPayloadSize
+	}
+
 	return &Muxer{
 		max: max - chLen,
 		w:   w,
@@ -15,6 +15,17 @@ func (s *SidebandSuite) TestMuxerWrite() {
 	s.Equal(2008, buf.Len())
 }
 
+func (s *SidebandSuite) TestMuxerWrite64k() {
+	buf := bytes.NewBuffer(nil)
+
+	m := NewMuxer(Sideband64k, buf)
+
+	n, err := m.Write(bytes.Repeat([]byte{'F'}, MaxPackedSize64k*2))
+	s.NoError(err)
+	s.Equal(MaxPackedSize64k*2, n)
+	s.Equal(MaxPackedSize64k*2+3*5, buf.Len())
+}
+
 func (s *SidebandSuite) TestMuxerWriteChannelMultipleChannels() {
 	buf := bytes.NewBuffer(nil)
 
@@ -0,0 +1,147 @@
+package sideband
+
+import (
+	"bytes"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Stage is a phase of an operation reported in a ProgressReport, such as
+// "Counting objects". The stages reported by the servers are named after
+// their messages, so other stages than the ones listed here can be found.
+type Stage string
+
+const (
+	// StageNegotiating is reported by the client once the negotiation of a
+	// fetch is done, Current being the number of haves acknowledged as
+	// common by the server and Total the number of haves sent.
+	StageNegotiating Stage = "Negotiating"
+	StageEnumerating Stage = "Enumerating objects"
+	StageCounting    Stage = "Counting objects"
+	StageCompressing Stage = "Compressing objects"
+	StageWriting     Stage = "Writing objects"
+	StageReceiving   Stage = "Receiving objects"
+	StageResolving   Stage = "Resolving deltas"
+	StageCheckingOut Stage = "Updating files"
+)
+
+// ProgressReport is the state of a stage of an operation.
+type ProgressReport struct {
+	// Stage is the phase of the operation being reported.
+	Stage Stage
+	// Current is the number of items processed, objects or files.
+	Current uint64
+	// Total is the number of items to process, 0 if it's not known.
+	Total uint64
+	// Bytes is the number of bytes transferred, if the stage transfers data.
+	Bytes uint64
+	// Done is true for the last report of the stage.
+	Done bool
+}
+
+// ProgressReporter receives the structured progress of clone, fetch, push
+// and checkout operations. A Progress implementing it, such as
+// StructuredProgress, gets the stages performed locally reported too.
+type ProgressReporter interface {
+	ReportProgress(ProgressReport)
+}
+
+// ProgressReporterFunc is a function implementing ProgressReporter.
+type ProgressReporterFunc func(ProgressReport)
+
+// ReportProgress implements the ProgressReporter interface.
+func (f ProgressReporterFunc) ReportProgress(r ProgressReport) {
+	f(r)
+}
+
+// Report reports r if the given progress is a ProgressReporter, doing
+// nothing otherwise.
+func Report(p Progress, r ProgressReport) {
+	if reporter, ok := p.(ProgressReporter); ok {
+		reporter.ReportProgress(r)
+	}
+}
+
+// StructuredProgress is a Progress parsing the human readable messages sent
+// by the server, such as "Counting objects:  50% (5/10)", into reports for
+// its ProgressReporter. It can be used anywhere a Progress is expected,
+// receiving the reports of the local stages too.
+type StructuredProgress struct {
+	// Reporter receives the progress reports.
+	Reporter ProgressReporter
+	// Text, if not nil, receives the messages written as they are.
+	Text Progress
+
+	pending []byte
+}
+
+// NewStructuredProgress returns a StructuredProgress reporting to r.
+func NewStructuredProgress(r ProgressReporter) *StructuredProgress {
+	return &StructuredProgress{Reporter: r}
+}
+
+// Write implements the io.Writer interface. The messages are split in lines
+// ended by '\n' or '\r', the incomplete ones being kept until the next write.
+func (p *StructuredProgress) Write(b []byte) (int, error) {
+	if p.Text != nil {
+		if _, err := p.Text.Write(b); err != nil {
+			return 0, err
+		}
+	}
+
+	p.pending = append(p.pending, b...)
+	for {
+		i := bytes.IndexAny(p.pending, "\r\n")
+		if i < 0 {
+			break
+		}
+
+		if r, ok := ParseProgress(string(p.pending[:i])); ok {
+			p.Reporter.ReportProgress(r)
+		}
+
+		p.pending = p.pending[i+1:]
+	}
+
+	return len(b), nil
+}
+
+// ReportProgress implements the ProgressReporter interface.
+func (p *StructuredProgress) ReportProgress(r ProgressReport) {
+	p.Reporter.ReportProgress(r)
+}
+
+var progressLine = regexp.MustCompile(
+	`^([A-Za-z][A-Za-z ]*?):\s+(?:\d+%\s+\((\d+)/(\d+)\)|(\d+))(.*)$`)
+
+// ParseProgress parses a progress message, such as the ones of git:
+//
+//	Counting objects:  50% (5/10)
+//	Enumerating objects: 12, done.
+//	remote: Resolving deltas: 100% (2/2), done.
+//
+// It returns false if the message doesn't describe the progress of a stage.
+func ParseProgress(line string) (ProgressReport, bool) {
+	line = strings.TrimSpace(line)
+	line = strings.TrimPrefix(line, "remote: ")
+
+	m := progressLine.FindStringSubmatch(line)
+	if m == nil {
+		return ProgressReport{}, false
+	}
+
+	r := ProgressReport{
+		Stage: Stage(m[1]),
+		Done:  strings.Contains(m[5], "done"),
+	}
+
+	if m[4] != "" {
+		r.Current, _ = strconv.ParseUint(m[4], 10, 64)
+		return r, true
+	}
+
+	r.Current, _ = strconv.ParseUint(m[2], 10, 64)
+	r.Total, _ = strconv.ParseUint(m[3], 10, 64)
+	return r, true
+}
@@ -0,0 +1,61 @@
+package sideband
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseProgress(t *testing.T) {
+	for _, tc := range []struct {
+		line     string
+		expected ProgressReport
+		ok       bool
+	}{
+		{"Enumerating objects: 12, done.", ProgressReport{Stage: StageEnumerating, Current: 12, Done: true}, true},
+		{"Counting objects:  50% (5/10)", ProgressReport{Stage: StageCounting, Current: 5, Total: 10}, true},
+		{"Compressing objects: 100% (4/4), done.", ProgressReport{Stage: StageCompressing, Current: 4, Total: 4, Done: true}, true},
+		{"remote: Resolving deltas: 100% (2/2), completed with 1 local object.", ProgressReport{Stage: StageResolving, Current: 2, Total: 2}, true},
+		{"Total 12 (delta 2), reused 0 (delta 0), pack-reused 0", ProgressReport{}, false},
+		{"Receiving...", ProgressReport{}, false},
+		{"", ProgressReport{}, false},
+	} {
+		r, ok := ParseProgress(tc.line)
+		assert.Equal(t, tc.ok, ok, tc.line)
+		assert.Equal(t, tc.expected, r, tc.line)
+	}
+}
+
+func TestStructuredProgress(t *testing.T) {
+	var reports []ProgressReport
+	text := bytes.NewBuffer(nil)
+	p := NewStructuredProgress(ProgressReporterFunc(func(r ProgressReport) {
+		reports = append(reports, r)
+	}))
+	p.Text = text
+
+	for _, msg := range []string{
+		"Counting objects:  50% ",
+		"(1/2)\rCounting objects: 100% (2/2)\r",
+		"Counting objects: 100% (2/2), done.\nTotal 2 (delta 0)\n",
+		"Compressing objects:",
+	} {
+		n, err := p.Write([]byte(msg))
+		assert.NoError(t, err)
+		assert.Equal(t, len(msg), n)
+	}
+
+	assert.Equal(t, []ProgressReport{
+		{Stage: StageCounting, Current: 1, Total: 2},
+		{Stage: StageCounting, Current: 2, Total: 2},
+		{Stage: StageCounting, Current: 2, Total: 2, Done: true},
+	}, reports)
+	assert.Contains(t, text.String(), "Total 2 (delta 0)\n")
+
+	Report(p, ProgressReport{Stage: StageCheckingOut, Current: 1, Total: 1, Done: true})
+	assert.Len(t, reports, 4)
+
+	// Reporting to a Progress not being a ProgressReporter does nothing.
+	Report(text, ProgressReport{Stage: StageCheckingOut})
+}
@@ -17,7 +17,11 @@ type UploadRequest struct {
 	Wants        []plumbing.Hash
 	Shallows     []plumbing.Hash
 	Depth        Depth
-	Filter       Filter
+	// DeepenNot holds the references whose history is excluded besides
+	// Depth, which can then be a DepthSince or a DepthReference, sent as
+	// additional deepen-not lines.
+	DeepenNot []DepthReference
+	Filter    Filter
 }
 
 // Depth values stores the desired depth of the requested packfile: see
@@ -231,17 +231,45 @@ func (d *ulReqDecoder) decodeDeepenSince() stateFn {
 		return nil
 	}
 	t := time.Unix(secs, 0).UTC()
+	if reference, ok := d.data.Depth.(DepthReference); ok {
+		d.data.DeepenNot = append([]DepthReference{reference}, d.data.DeepenNot...)
+	}
+
 	d.data.Depth = DepthSince(t)
 
-	return d.decodeFlush
+	return d.decodeOtherDeepen
 }
 
 func (d *ulReqDecoder) decodeDeepenReference() stateFn {
 	d.line = bytes.TrimPrefix(d.line, deepenReference)
 
-	d.data.Depth = DepthReference(string(d.line))
+	reference := DepthReference(string(d.line))
+	if d.data.Depth.IsZero() {
+		d.data.Depth = reference
+	} else {
+		d.data.DeepenNot = append(d.data.DeepenNot, reference)
+	}
 
-	return d.decodeFlush
+	return d.decodeOtherDeepen
+}
+
+// Expected format: deepen-since <ul> / deepen-not <ref>, along with a
+// previous deepen-since or deepen-not.
+func (d *ulReqDecoder) decodeOtherDeepen() stateFn {
+	if ok := d.nextLine(); !ok {
+		return nil
+	}
+
+	switch {
+	case bytes.HasPrefix(d.line, deepenSince):
+		return d.decodeDeepenSince
+	case bytes.HasPrefix(d.line, deepenReference):
+		return d.decodeDeepenReference
+	case len(d.line) != 0:
+		d.err = fmt.Errorf("unexpected payload while expecting a flush-pkt: %q", d.line)
+	}
+
+	return nil
 }
 
 func (d *ulReqDecoder) decodeFlush() stateFn {
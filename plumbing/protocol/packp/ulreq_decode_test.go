@@ -503,6 +503,36 @@ func (s *UlReqDecodeSuite) TestDeepenReference() {
 	s.Equal(expected, string(reference))
 }
 
+func (s *UlReqDecodeSuite) TestDeepenSinceAndReferences() {
+	payloads := []string{
+		"want 3333333333333333333333333333333333333333 ofs-delta multi_ack",
+		"deepen-not refs/heads/master",
+		"deepen-since 1420167845",
+		"deepen-not refs/tags/v1",
+		"",
+	}
+	ur, _ := s.testDecodeOK(payloads, 0)
+
+	expected := time.Date(2015, time.January, 2, 3, 4, 5, 0, time.UTC)
+	since, ok := ur.Depth.(DepthSince)
+	s.True(ok)
+	s.True(time.Time(since).Equal(expected))
+	s.Equal([]DepthReference{"refs/heads/master", "refs/tags/v1"}, ur.DeepenNot)
+}
+
+func (s *UlReqDecodeSuite) TestDeepenReferences() {
+	payloads := []string{
+		"want 3333333333333333333333333333333333333333 ofs-delta multi_ack",
+		"deepen-not refs/heads/master",
+		"deepen-not refs/tags/v1",
+		"",
+	}
+	ur, _ := s.testDecodeOK(payloads, 0)
+
+	s.Equal(DepthReference("refs/heads/master"), ur.Depth)
+	s.Equal([]DepthReference{"refs/tags/v1"}, ur.DeepenNot)
+}
+
 func (s *UlReqDecodeSuite) TestAll() {
 	payloads := []string{
 		"want 3333333333333333333333333333333333333333 ofs-delta multi_ack\n",
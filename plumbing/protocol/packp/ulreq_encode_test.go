@@ -278,6 +278,23 @@ func (s *UlReqEncodeSuite) TestDepthReference() {
 	testUlReqEncode(s, ur, expected)
 }
 
+func (s *UlReqEncodeSuite) TestDepthSinceAndDeepenNot() {
+	ur := NewUploadRequest()
+	ur.Wants = append(ur.Wants, plumbing.NewHash("1111111111111111111111111111111111111111"))
+	ur.Depth = DepthSince(time.Date(2015, time.January, 2, 3, 4, 5, 0, time.UTC))
+	ur.DeepenNot = []DepthReference{"refs/heads/feature-foo", "refs/tags/v1"}
+
+	expected := []string{
+		"want 1111111111111111111111111111111111111111\n",
+		"deepen-since 1420167845\n",
+		"deepen-not refs/heads/feature-foo\n",
+		"deepen-not refs/tags/v1\n",
+		"",
+	}
+
+	testUlReqEncode(s, ur, expected)
+}
+
 func (s *UlReqEncodeSuite) TestFilter() {
 	ur := NewUploadRequest()
 	ur.Wants = append(ur.Wants, plumbing.NewHash("1111111111111111111111111111111111111111"))
@@ -18,12 +18,13 @@ type UpdateRequests struct {
 	Capabilities *capability.List
 	Commands     []*Command
 	Shallow      *plumbing.Hash
-	// TODO: Support push-cert
+	// Certificate is the signed push certificate sent instead of the command
+	// list, if any. It must record the same commands.
+	Certificate *PushCertificate
 }
 
 // NewUpdateRequests returns a new UpdateRequests.
 func NewUpdateRequests() *UpdateRequests {
-	// TODO: Add support for push-cert
 	return &UpdateRequests{
 		Capabilities: capability.NewList(),
 	}
@@ -19,11 +19,11 @@ func (req *UpdateRequests) Encode(w io.Writer) error {
 		return err
 	}
 
-	if err := req.encodeCommands(w, req.Commands, req.Capabilities); err != nil {
-		return err
+	if req.Certificate != nil {
+		return req.Certificate.encode(w, req.Capabilities)
 	}
 
-	return nil
+	return req.encodeCommands(w, req.Commands, req.Capabilities)
 }
 
 func (req *UpdateRequests) encodeShallow(w io.Writer,
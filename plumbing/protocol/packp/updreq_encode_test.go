@@ -167,3 +167,53 @@ func (s *UpdReqEncodeSuite) TestPushAtomic() {
 
 	s.testEncode(r, expected)
 }
+
+func (s *UpdReqEncodeSuite) TestPushCertificate() {
+	hash1 := plumbing.NewHash("1ecf0ef2c2dffb796033e5a02219af86ec6584e5")
+	hash2 := plumbing.NewHash("2ecf0ef2c2dffb796033e5a02219af86ec6584e5")
+
+	r := NewUpdateRequests()
+	r.Capabilities.Set(capability.ReportStatus)
+	r.Commands = []*Command{
+		{Name: plumbing.ReferenceName("myref1"), Old: hash1, New: hash2},
+		{Name: plumbing.ReferenceName("myref2"), Old: plumbing.ZeroHash, New: hash2},
+	}
+	r.Certificate = &PushCertificate{
+		Pusher:    "John Doe <john@doe.com> 1257894000 +0100",
+		Pushee:    "https://example.com/repo.git",
+		Nonce:     "1257894000-abcdef",
+		Options:   []string{"ci.skip"},
+		Commands:  r.Commands,
+		Signature: []byte("-----BEGIN PGP SIGNATURE-----\n\nsignature\n-----END PGP SIGNATURE-----"),
+	}
+
+	s.Equal("certificate version 0.1\n"+
+		"pusher John Doe <john@doe.com> 1257894000 +0100\n"+
+		"pushee https://example.com/repo.git\n"+
+		"nonce 1257894000-abcdef\n"+
+		"push-option ci.skip\n"+
+		"\n"+
+		"1ecf0ef2c2dffb796033e5a02219af86ec6584e5 2ecf0ef2c2dffb796033e5a02219af86ec6584e5 myref1\n"+
+		"0000000000000000000000000000000000000000 2ecf0ef2c2dffb796033e5a02219af86ec6584e5 myref2\n",
+		string(r.Certificate.Payload()))
+
+	expected := pktlines(s.T(),
+		"push-cert\x00report-status\n",
+		"certificate version 0.1\n",
+		"pusher John Doe <john@doe.com> 1257894000 +0100\n",
+		"pushee https://example.com/repo.git\n",
+		"nonce 1257894000-abcdef\n",
+		"push-option ci.skip\n",
+		"\n",
+		"1ecf0ef2c2dffb796033e5a02219af86ec6584e5 2ecf0ef2c2dffb796033e5a02219af86ec6584e5 myref1\n",
+		"0000000000000000000000000000000000000000 2ecf0ef2c2dffb796033e5a02219af86ec6584e5 myref2\n",
+		"-----BEGIN PGP SIGNATURE-----\n",
+		"\n",
+		"signature\n",
+		"-----END PGP SIGNATURE-----\n",
+		"push-cert-end\n",
+		"",
+	)
+
+	s.testEncode(r, expected)
+}
@@ -219,6 +219,30 @@ const (
 	Main   ReferenceName = "refs/heads/main"
 )
 
+// MergeHead records the commit being merged into HEAD while a merge is in
+// progress.
+const MergeHead ReferenceName = "MERGE_HEAD"
+
+// CherryPickHead records the commit being cherry-picked while the
+// cherry-pick is stopped by conflicts.
+const CherryPickHead ReferenceName = "CHERRY_PICK_HEAD"
+
+// RevertHead records the commit being reverted while the revert is stopped
+// by conflicts.
+const RevertHead ReferenceName = "REVERT_HEAD"
+
+// RebaseHead records the commit being applied while a rebase is stopped by
+// conflicts.
+const RebaseHead ReferenceName = "REBASE_HEAD"
+
+// OrigHead records the previous position of HEAD before a drastic operation,
+// such as a rebase.
+const OrigHead ReferenceName = "ORIG_HEAD"
+
+// Stash is the reference to the most recent stash, the older ones being kept
+// in its reflog.
+const Stash ReferenceName = "refs/stash"
+
 // Reference is a representation of git reference
 type Reference struct {
 	t      ReferenceType
@@ -0,0 +1,118 @@
+package revlist
+
+import (
+	"errors"
+
+	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/go-git/go-git/v6/plumbing/filemode"
+	"github.com/go-git/go-git/v6/plumbing/format/bitmap"
+	"github.com/go-git/go-git/v6/plumbing/object"
+	"github.com/go-git/go-git/v6/plumbing/storer"
+)
+
+// errNotInBitmap is returned when an object reachable from the ones given
+// isn't in the packfile of the bitmap, so the reachable objects can't be
+// computed with it.
+var errNotInBitmap = errors.New("object not in the bitmap packfile")
+
+// ObjectsWithBitmap is the same as Objects, but the reachable objects are
+// computed using the given reachability bitmap of the packfile. The objects
+// reachable from the commits with a bitmap are taken from it, so only the
+// history from the given objects down to these commits is walked. When an
+// object not stored in the packfile is reachable, e.g. a loose object, the
+// history is walked instead, as Objects does without a bitmap.
+func ObjectsWithBitmap(
+	s storer.EncodedObjectStorer,
+	idx *bitmap.Index,
+	objs,
+	ignore []plumbing.Hash,
+) ([]plumbing.Hash, error) {
+	ignored, err := reachableBitmap(s, idx, ignore, true)
+	if errors.Is(err, errNotInBitmap) {
+		return ObjectsWithStorageForIgnores(s, s, objs, ignore)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	wanted, err := reachableBitmap(s, idx, objs, false)
+	if errors.Is(err, errNotInBitmap) {
+		return ObjectsWithStorageForIgnores(s, s, objs, ignore)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	wanted.AndNot(ignored)
+	return idx.Hashes(wanted), nil
+}
+
+// reachableBitmap returns the bitmap of the objects reachable from the given
+// ones. The objects missing from the storer are ignored if allowMissing is
+// set, otherwise errNotInBitmap is returned for any object not in the
+// packfile of the bitmap.
+func reachableBitmap(
+	s storer.EncodedObjectStorer,
+	idx *bitmap.Index,
+	objs []plumbing.Hash,
+	allowMissing bool,
+) (*bitmap.Bitmap, error) {
+	result := bitmap.NewBitmap()
+	pending := append([]plumbing.Hash(nil), objs...)
+	for len(pending) > 0 {
+		h := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+
+		pos, ok := idx.Position(h)
+		if !ok {
+			if allowMissing && errors.Is(s.HasEncodedObject(h), plumbing.ErrObjectNotFound) {
+				continue
+			}
+
+			return nil, errNotInBitmap
+		}
+
+		if result.Get(pos) {
+			continue
+		}
+
+		if reachable, ok := idx.Lookup(h); ok {
+			result.Or(reachable)
+			continue
+		}
+
+		result.Set(pos)
+		switch idx.Type(pos) {
+		case plumbing.CommitObject:
+			c, err := object.GetCommit(s, h)
+			if err != nil {
+				return nil, err
+			}
+
+			pending = append(pending, c.TreeHash)
+			pending = append(pending, c.ParentHashes...)
+		case plumbing.TreeObject:
+			t, err := object.GetTree(s, h)
+			if err != nil {
+				return nil, err
+			}
+
+			for _, e := range t.Entries {
+				if e.Mode != filemode.Submodule {
+					pending = append(pending, e.Hash)
+				}
+			}
+		case plumbing.TagObject:
+			t, err := object.GetTag(s, h)
+			if err != nil {
+				return nil, err
+			}
+
+			pending = append(pending, t.Target)
+		}
+	}
+
+	return result, nil
+}
@@ -0,0 +1,114 @@
+package revlist
+
+import (
+	"bytes"
+
+	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/go-git/go-git/v6/plumbing/format/bitmap"
+	"github.com/go-git/go-git/v6/plumbing/format/idxfile"
+	"github.com/go-git/go-git/v6/plumbing/object"
+
+	fixtures "github.com/go-git/go-git-fixtures/v5"
+)
+
+// bitmapIndex returns a bitmap of the packfile of the basic fixture, with
+// bitmaps for the given commits.
+func (s *RevListSuite) bitmapIndex(commits ...string) *bitmap.Index {
+	idx := new(idxfile.MemoryIndex)
+	s.Require().NoError(idxfile.NewDecoder(fixtures.Basic().One().Idx()).Decode(idx))
+
+	order, err := bitmap.PackOrder(idx)
+	s.Require().NoError(err)
+
+	positions := make(map[plumbing.Hash]uint32, len(order))
+	types := make(map[plumbing.Hash]plumbing.ObjectType, len(order))
+	for pos, h := range order {
+		positions[h] = uint32(pos)
+		o, err := s.Storer.EncodedObject(plumbing.AnyObject, h)
+		s.Require().NoError(err)
+		types[h] = o.Type()
+	}
+
+	var entries []bitmap.Entry
+	for _, c := range commits {
+		hashes, err := ObjectsWithStorageForIgnores(s.Storer, s.Storer, []plumbing.Hash{plumbing.NewHash(c)}, nil)
+		s.Require().NoError(err)
+
+		b := bitmap.NewBitmap()
+		for _, h := range hashes {
+			b.Set(positions[h])
+		}
+
+		entries = append(entries, bitmap.Entry{Commit: plumbing.NewHash(c), Bitmap: b})
+	}
+
+	var buf bytes.Buffer
+	s.Require().NoError(bitmap.NewEncoder(&buf).Encode(idx, idx.PackfileChecksum, types, entries))
+
+	i, err := bitmap.Decode(&buf, idx)
+	s.Require().NoError(err)
+	return i
+}
+
+func (s *RevListSuite) TestObjectsWithBitmap() {
+	idx := s.bitmapIndex(someCommit, initialCommit)
+
+	for _, t := range []struct {
+		objs, ignore []string
+	}{
+		{[]string{someCommitOtherBranch}, nil},
+		{[]string{someCommitOtherBranch}, []string{someCommit}},
+		{[]string{someCommitBranch, someCommitOtherBranch}, []string{secondCommit}},
+		{[]string{someCommit}, []string{someCommitOtherBranch}},
+		// A tree and a blob.
+		{[]string{"a8d315b2b1c615d43042c3a62402b8a54288cf5c", "d3ff53e0564a9f87d8e84b6e28e5060e517008aa"}, nil},
+		// An object missing from the storer is ignored.
+		{[]string{someCommitBranch}, []string{"0000000000000000000000000000000000000001", initialCommit}},
+	} {
+		objs, ignore := hashes(t.objs), hashes(t.ignore)
+
+		expected, err := ObjectsWithStorageForIgnores(s.Storer, s.Storer, objs, ignore)
+		s.Require().NoError(err)
+
+		result, err := ObjectsWithBitmap(s.Storer, idx, objs, ignore)
+		s.Require().NoError(err)
+		s.ElementsMatch(expected, result, "%s ^%s", t.objs, t.ignore)
+	}
+}
+
+func (s *RevListSuite) TestObjectsWithBitmapLooseObjects() {
+	idx := s.bitmapIndex(someCommit)
+
+	parent, err := object.GetCommit(s.Storer, plumbing.NewHash(someCommitOtherBranch))
+	s.Require().NoError(err)
+
+	c := &object.Commit{
+		Author:       parent.Author,
+		Committer:    parent.Committer,
+		Message:      "loose",
+		TreeHash:     parent.TreeHash,
+		ParentHashes: []plumbing.Hash{parent.Hash},
+	}
+
+	obj := s.Storer.NewEncodedObject()
+	s.Require().NoError(c.Encode(obj))
+	loose, err := s.Storer.SetEncodedObject(obj)
+	s.Require().NoError(err)
+
+	result, err := ObjectsWithBitmap(s.Storer, idx, []plumbing.Hash{loose}, hashes([]string{someCommit}))
+	s.Require().NoError(err)
+
+	expected, err := ObjectsWithStorageForIgnores(s.Storer, s.Storer, []plumbing.Hash{loose}, hashes([]string{someCommit}))
+	s.Require().NoError(err)
+	s.ElementsMatch(expected, result)
+	s.Contains(result, loose)
+}
+
+func hashes(hs []string) []plumbing.Hash {
+	var result []plumbing.Hash
+	for _, h := range hs {
+		result = append(result, plumbing.NewHash(h))
+	}
+
+	return result
+}
@@ -6,6 +6,8 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"path"
+	"strings"
 
 	"github.com/go-git/go-git/v6/plumbing"
 	"github.com/go-git/go-git/v6/plumbing/filemode"
@@ -16,12 +18,24 @@ import (
 // Objects applies a complementary set. It gets all the hashes from all
 // the reachable objects from the given objects. Ignore param are object hashes
 // that we want to ignore on the result. All that objects must be accessible
-// from the object storer.
+// from the object storer. If the storer has a reachability bitmap, see
+// storer.BitmapStorer, it is used as ObjectsWithBitmap does.
 func Objects(
 	s storer.EncodedObjectStorer,
 	objs,
 	ignore []plumbing.Hash,
 ) ([]plumbing.Hash, error) {
+	if bs, ok := s.(storer.BitmapStorer); ok {
+		idx, err := bs.ReachabilityBitmap()
+		if err != nil {
+			return nil, err
+		}
+
+		if idx != nil {
+			return ObjectsWithBitmap(s, idx, objs, ignore)
+		}
+	}
+
 	return ObjectsWithStorageForIgnores(s, s, objs, ignore)
 }
 
@@ -252,3 +266,193 @@ func ObjectsWithRef(
 	}
 	return all, nil
 }
+
+// ObjectsWithPathFilter is the same as Objects, but only the trees and blobs
+// reachable through the given paths are returned, along with the trees
+// leading to them. A path matches the file or directory with that name, and
+// everything below it, e.g. "foo" matches "foo/bar" but not "foobar". The
+// commits and tags are returned as Objects does.
+//
+// The subtrees outside the paths are skipped without being read. If no path
+// is given, every object is returned.
+func ObjectsWithPathFilter(
+	s storer.EncodedObjectStorer,
+	objs,
+	ignore []plumbing.Hash,
+	paths []string,
+) ([]plumbing.Hash, error) {
+	ignore, err := objects(s, ignore, nil, true)
+	if err != nil {
+		return nil, err
+	}
+
+	w := &pathFilterWalker{
+		s:      s,
+		paths:  cleanPaths(paths),
+		seen:   hashListToSet(ignore),
+		result: make(map[plumbing.Hash]bool),
+	}
+
+	for _, h := range objs {
+		if err := w.processObject(h); err != nil {
+			return nil, err
+		}
+	}
+
+	return hashSetToList(w.result), nil
+}
+
+func cleanPaths(paths []string) []string {
+	var clean []string
+	for _, p := range paths {
+		p = strings.Trim(path.Clean("/"+p), "/")
+		// The root matches everything.
+		if p == "" {
+			return nil
+		}
+
+		clean = append(clean, p)
+	}
+
+	return clean
+}
+
+// pathFilterWalker collects the objects reachable through a set of paths.
+// The seen objects are the ones ignored, or fully walked, while the trees
+// leading to the paths are added to the result without being seen, since the
+// same tree could be fully walked when found under one of the paths.
+type pathFilterWalker struct {
+	s      storer.EncodedObjectStorer
+	paths  []string
+	seen   map[plumbing.Hash]bool
+	result map[plumbing.Hash]bool
+}
+
+func (w *pathFilterWalker) add(h plumbing.Hash) {
+	if !w.seen[h] {
+		w.result[h] = true
+		w.seen[h] = true
+	}
+}
+
+func (w *pathFilterWalker) processObject(h plumbing.Hash) error {
+	if w.seen[h] {
+		return nil
+	}
+
+	o, err := w.s.EncodedObject(plumbing.AnyObject, h)
+	if err != nil {
+		return fmt.Errorf("getting object: %w", err)
+	}
+
+	do, err := object.DecodeObject(w.s, o)
+	if err != nil {
+		return fmt.Errorf("decoding object: %w", err)
+	}
+
+	switch do := do.(type) {
+	case *object.Commit:
+		return w.processCommits(do)
+	case *object.Tree:
+		return w.walkTree(do, "")
+	case *object.Tag:
+		w.add(do.Hash)
+		return w.processObject(do.Target)
+	case *object.Blob:
+		w.add(do.Hash)
+	default:
+		return fmt.Errorf("object type not valid: %s. "+
+			"Object reference: %s", o.Type(), o.Hash())
+	}
+
+	return nil
+}
+
+func (w *pathFilterWalker) processCommits(commit *object.Commit) error {
+	i := object.NewCommitPreorderIter(commit, w.seen, nil)
+	return i.ForEach(func(c *object.Commit) error {
+		if w.seen[c.Hash] {
+			return nil
+		}
+
+		w.add(c.Hash)
+
+		tree, err := c.Tree()
+		if err != nil {
+			return err
+		}
+
+		return w.walkTree(tree, "")
+	})
+}
+
+// walkTree walks the tree found at the given directory, skipping the entries
+// outside the paths.
+func (w *pathFilterWalker) walkTree(tree *object.Tree, dir string) error {
+	if w.seen[tree.Hash] {
+		return nil
+	}
+
+	if w.matches(dir) {
+		return iterateCommitTrees(w.seen, tree, w.add)
+	}
+
+	w.result[tree.Hash] = true
+	for _, e := range tree.Entries {
+		name := path.Join(dir, e.Name)
+		if e.Mode == filemode.Submodule || w.seen[e.Hash] || !w.leadsToPath(name) {
+			continue
+		}
+
+		if e.Mode != filemode.Dir {
+			if w.matches(name) {
+				w.add(e.Hash)
+			}
+
+			continue
+		}
+
+		subtree, err := object.GetTree(w.s, e.Hash)
+		if err != nil {
+			return err
+		}
+
+		if err := w.walkTree(subtree, name); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// matches returns true if the name is one of the paths, or below one of them.
+func (w *pathFilterWalker) matches(name string) bool {
+	if len(w.paths) == 0 {
+		return true
+	}
+
+	for _, p := range w.paths {
+		if isPathPrefix(p, name) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// leadsToPath returns true if the name matches one of the paths, or is a
+// directory containing one of them.
+func (w *pathFilterWalker) leadsToPath(name string) bool {
+	for _, p := range w.paths {
+		if isPathPrefix(p, name) || isPathPrefix(name, p) {
+			return true
+		}
+	}
+
+	return w.matches(name)
+}
+
+// isPathPrefix returns true if name is prefix, or is below it.
+func isPathPrefix(prefix, name string) bool {
+	return name == prefix || strings.HasPrefix(name, prefix+"/")
+}
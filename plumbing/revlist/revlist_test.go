@@ -1,6 +1,7 @@
 package revlist
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/go-git/go-git/v6/plumbing"
@@ -295,3 +296,83 @@ func (s *RevListSuite) TestReachableObjectsNoRevisit() {
 	}, visited,
 	)
 }
+
+func (s *RevListSuite) TestRevListObjectsWithPathFilter() {
+	head := plumbing.NewHash(someCommitOtherBranch)
+	sto := &readRecorderStorer{EncodedObjectStorer: s.Storer, read: make(map[plumbing.Hash]bool)}
+	revList, err := ObjectsWithPathFilter(sto, []plumbing.Hash{head}, nil, []string{"json/", "vendor/foo.go"})
+	s.NoError(err)
+
+	result := make(map[plumbing.Hash]bool)
+	for _, h := range revList {
+		result[h] = true
+	}
+
+	iter := object.NewCommitPreorderIter(s.commit(head), nil, nil)
+	s.NoError(iter.ForEach(func(c *object.Commit) error {
+		s.True(result[c.Hash], "commit %s", c.Hash)
+
+		tree, err := c.Tree()
+		s.NoError(err)
+		s.True(result[tree.Hash], "tree of %s", c.Hash)
+
+		for _, e := range tree.Entries {
+			switch e.Name {
+			case "json", "vendor":
+				s.True(result[e.Hash], "%s of %s", e.Name, c.Hash)
+			default:
+				s.False(result[e.Hash], "%s of %s", e.Name, c.Hash)
+				// The subtrees outside the paths are never read.
+				s.False(sto.read[e.Hash], "%s of %s", e.Name, c.Hash)
+			}
+		}
+
+		return tree.Files().ForEach(func(f *object.File) error {
+			expected := strings.HasPrefix(f.Name, "json/") || f.Name == "vendor/foo.go"
+			s.Equal(expected, result[f.Hash], "%s of %s", f.Name, c.Hash)
+			return nil
+		})
+	}))
+}
+
+func (s *RevListSuite) TestRevListObjectsWithPathFilterIgnore() {
+	head := plumbing.NewHash(someCommitOtherBranch)
+	ignore := plumbing.NewHash(someCommit)
+
+	all, err := Objects(s.Storer, []plumbing.Hash{head}, []plumbing.Hash{ignore})
+	s.NoError(err)
+
+	revList, err := ObjectsWithPathFilter(s.Storer, []plumbing.Hash{head}, []plumbing.Hash{ignore}, nil)
+	s.NoError(err)
+	s.ElementsMatch(all, revList)
+
+	// vendor/foo.go was added by the last commit, the only one not ignored.
+	revList, err = ObjectsWithPathFilter(s.Storer, []plumbing.Hash{head}, []plumbing.Hash{ignore}, []string{"vendor"})
+	s.NoError(err)
+
+	commit := s.commit(head)
+	tree, err := commit.Tree()
+	s.NoError(err)
+	vendor, err := tree.Tree("vendor")
+	s.NoError(err)
+	file, err := tree.File("vendor/foo.go")
+	s.NoError(err)
+	s.ElementsMatch([]plumbing.Hash{commit.Hash, tree.Hash, vendor.Hash, file.Hash}, revList)
+}
+
+func (s *RevListSuite) commit(h plumbing.Hash) *object.Commit {
+	c, err := object.GetCommit(s.Storer, h)
+	s.Require().NoError(err)
+	return c
+}
+
+// readRecorderStorer records the objects read from a storer.
+type readRecorderStorer struct {
+	storer.EncodedObjectStorer
+	read map[plumbing.Hash]bool
+}
+
+func (s *readRecorderStorer) EncodedObject(t plumbing.ObjectType, h plumbing.Hash) (plumbing.EncodedObject, error) {
+	s.read[h] = true
+	return s.EncodedObjectStorer.EncodedObject(t, h)
+}
@@ -6,6 +6,8 @@ import (
 	"time"
 
 	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/go-git/go-git/v6/plumbing/format/bitmap"
+	format "github.com/go-git/go-git/v6/plumbing/format/config"
 )
 
 var (
@@ -56,6 +58,14 @@ type DeltaObjectStorer interface {
 	DeltaObject(plumbing.ObjectType, plumbing.Hash) (plumbing.EncodedObject, error)
 }
 
+// ObjectFormatSetter is an optional interface for ObjectStorer, it enables
+// the use of an object format other than SHA1 to hash the stored objects.
+type ObjectFormatSetter interface {
+	// SetObjectFormat sets the object format used by the storer. It's meant
+	// to be called before any object is stored.
+	SetObjectFormat(format.ObjectFormat) error
+}
+
 // Transactioner is a optional method for ObjectStorer, it enables transactional read and write
 // operations.
 type Transactioner interface {
@@ -91,6 +101,15 @@ type PackedObjectStorer interface {
 	DeleteOldObjectPackAndIndex(plumbing.Hash, time.Time) error
 }
 
+// BitmapStorer is an optional interface for ObjectStorer, it gives access to
+// the reachability bitmap of the packed objects, used to compute the objects
+// reachable from a commit without walking its history.
+type BitmapStorer interface {
+	// ReachabilityBitmap returns the bitmap of the packfile having one, or
+	// nil if there is none.
+	ReachabilityBitmap() (*bitmap.Index, error)
+}
+
 // PackfileWriter is an optional method for ObjectStorer, it enables directly writing
 // a packfile to storage.
 type PackfileWriter interface {
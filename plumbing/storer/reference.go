@@ -2,9 +2,12 @@ package storer
 
 import (
 	"errors"
+	"fmt"
 	"io"
 
 	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/go-git/go-git/v6/plumbing/format/fetchhead"
+	"github.com/go-git/go-git/v6/plumbing/format/reflog"
 )
 
 const MaxResolveRecursion = 1024
@@ -28,6 +31,39 @@ type ReferenceStorer interface {
 	PackRefs() error
 }
 
+// ReflogStorer is a storage of the logs of the updates of the references.
+// Storages not implementing it don't keep reference logs.
+type ReflogStorer interface {
+	// Reflog returns the entries of the log of the given reference, oldest
+	// first. No entries are returned when the reference has no log.
+	Reflog(plumbing.ReferenceName) ([]*reflog.Entry, error)
+	// AppendReflog appends an entry to the log of the given reference.
+	AppendReflog(plumbing.ReferenceName, *reflog.Entry) error
+	// RemoveReflog removes the log of the given reference, if any.
+	RemoveReflog(plumbing.ReferenceName) error
+}
+
+// FetchHeadStorer is a storage of the references fetched by the last fetch,
+// the FETCH_HEAD file of git. Storages not implementing it don't record them.
+type FetchHeadStorer interface {
+	// FetchHead returns the entries recorded by the last fetch, in order. No
+	// entries are returned when nothing was fetched yet.
+	FetchHead() ([]*fetchhead.Entry, error)
+	// SetFetchHead replaces the recorded entries with the given ones.
+	SetFetchHead([]*fetchhead.Entry) error
+}
+
+// PeeledReferenceStorer is a storage of references able to tell the object
+// a reference peels to without reading the objects, e.g. from the `^` lines
+// of a packed-refs file.
+type PeeledReferenceStorer interface {
+	// PeeledReference returns the hash the given reference peels to: the
+	// object its annotated tag ultimately points to, or the hash of the
+	// reference itself when it doesn't point to a tag. ok is false when it
+	// isn't known, in which case the objects have to be read.
+	PeeledReference(plumbing.ReferenceName) (h plumbing.Hash, ok bool, err error)
+}
+
 // ReferenceIter is a generic closable interface for iterating over references.
 type ReferenceIter interface {
 	Next() (*plumbing.Reference, error)
@@ -212,6 +248,91 @@ func (iter *MultiReferenceIter) Close() {
 	}
 }
 
+// ErrDanglingReference is returned by a ResolvedReferenceIter including the
+// dangling references, for the symbolic references whose target doesn't
+// exist or that are part of a cycle.
+var ErrDanglingReference = errors.New("dangling symbolic reference")
+
+// ResolvedReferenceIter implements ReferenceIter. It yields the references
+// of another ReferenceIter as hash references, the symbolic ones being
+// resolved to the hash of their target, keeping their name, when they are
+// reached. The dangling symbolic references are skipped, or reported as an
+// ErrDanglingReference by Next if asked to, the iteration being resumed by
+// the following call.
+//
+// The ResolvedReferenceIter must be closed with a call to Close() when it is
+// no longer needed.
+type ResolvedReferenceIter struct {
+	s        ReferenceStorer
+	iter     ReferenceIter
+	dangling bool
+}
+
+// NewResolvedReferenceIter returns a reference iterator resolving the
+// references of the given iterator with the given storer. The dangling
+// symbolic references are reported as errors if dangling is true.
+func NewResolvedReferenceIter(s ReferenceStorer, iter ReferenceIter, dangling bool) ReferenceIter {
+	return &ResolvedReferenceIter{s: s, iter: iter, dangling: dangling}
+}
+
+// Next returns the next resolved reference from the iterator. If the iterator
+// has reached the end it will return io.EOF as an error.
+func (iter *ResolvedReferenceIter) Next() (*plumbing.Reference, error) {
+	for {
+		r, err := iter.iter.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		if r.Type() != plumbing.SymbolicReference {
+			return r, nil
+		}
+
+		t, err := resolveSymbolicReference(iter.s, r)
+		switch {
+		case err == nil:
+			return plumbing.NewHashReference(r.Name(), t.Hash()), nil
+		case !errors.Is(err, plumbing.ErrReferenceNotFound) && !errors.Is(err, ErrMaxResolveRecursion):
+			return nil, err
+		case iter.dangling:
+			return nil, fmt.Errorf("%w: %s", ErrDanglingReference, r.Name())
+		}
+	}
+}
+
+// ForEach call the cb function for each reference contained on this iter until
+// an error happens or the end of the iter is reached. If ErrStop is sent
+// the iteration is stop but no error is returned. The iterator is closed.
+func (iter *ResolvedReferenceIter) ForEach(cb func(*plumbing.Reference) error) error {
+	return forEachReferenceIter(iter, cb)
+}
+
+// Close releases any resources used by the iterator.
+func (iter *ResolvedReferenceIter) Close() {
+	iter.iter.Close()
+}
+
+// resolveSymbolicReference resolves the given reference as ResolveReference,
+// returning ErrMaxResolveRecursion as soon as a cycle is found.
+func resolveSymbolicReference(s ReferenceStorer, r *plumbing.Reference) (*plumbing.Reference, error) {
+	seen := make(map[plumbing.ReferenceName]bool)
+	for r.Type() == plumbing.SymbolicReference {
+		if seen[r.Name()] || len(seen) > MaxResolveRecursion {
+			return nil, ErrMaxResolveRecursion
+		}
+
+		seen[r.Name()] = true
+
+		var err error
+		r, err = s.Reference(r.Target())
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return r, nil
+}
+
 // ResolveReference resolves a SymbolicReference to a HashReference.
 func ResolveReference(s ReferenceStorer, n plumbing.ReferenceName) (*plumbing.Reference, error) {
 	r, err := s.Reference(n)
@@ -0,0 +1,142 @@
+package storer
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/go-git/go-git/v6/plumbing"
+)
+
+var (
+	// ErrReferenceTransactionClosed is returned when using a transaction
+	// already committed or rolled back.
+	ErrReferenceTransactionClosed = errors.New("reference transaction already closed")
+	// ErrSymbolicReferenceUpdate is returned when queueing the update of a
+	// symbolic reference in a transaction, only hash references are
+	// supported.
+	ErrSymbolicReferenceUpdate = errors.New("symbolic references can't be updated in a transaction")
+	// ErrReferenceOutsideRefs is returned when queueing the update of a
+	// reference not under refs/, such as HEAD or the pseudo-references,
+	// which are never packed.
+	ErrReferenceOutsideRefs = errors.New("only references under refs/ can be updated in a transaction")
+)
+
+const refsPrefix = "refs/"
+
+// ReferenceTransactionStorer is a storage of references able to update
+// several references atomically.
+type ReferenceTransactionStorer interface {
+	// BeginReferenceTransaction starts a new transaction.
+	BeginReferenceTransaction() ReferenceTransaction
+}
+
+// ReferenceTransaction is a set of updates of references applied atomically:
+// on Commit, either all of them are applied or none is, e.g. when the
+// precondition of one of them doesn't hold.
+type ReferenceTransaction interface {
+	// SetReference queues the update of the reference ref, which must be
+	// under refs/. If old is not nil, the reference must point to the hash
+	// of old when the transaction is committed, a zero hash meaning that it
+	// must not exist.
+	SetReference(ref, old *plumbing.Reference) error
+	// RemoveReference queues the removal of the named reference, with the
+	// same precondition as SetReference.
+	RemoveReference(name plumbing.ReferenceName, old *plumbing.Reference) error
+	// Commit applies the queued updates. If any precondition doesn't hold,
+	// storage.ErrReferenceHasChanged is returned and nothing is updated.
+	Commit() error
+	// Rollback discards the queued updates.
+	Rollback() error
+}
+
+// ReferenceUpdate is an update queued in a ReferenceTransaction.
+type ReferenceUpdate struct {
+	// Name of the reference updated.
+	Name plumbing.ReferenceName
+	// New is the new value of the reference, nil to remove it.
+	New *plumbing.Reference
+	// Old is the value the reference is expected to have, nil if it isn't
+	// checked.
+	Old *plumbing.Reference
+}
+
+// Check returns true if the precondition of the update holds, given the
+// current value of the reference, nil if it doesn't exist.
+func (u *ReferenceUpdate) Check(current *plumbing.Reference) bool {
+	switch {
+	case u.Old == nil:
+		return true
+	case u.Old.Hash().IsZero():
+		return current == nil
+	default:
+		return current != nil && current.Hash() == u.Old.Hash()
+	}
+}
+
+// NewReferenceTransaction returns a ReferenceTransaction queueing the updates
+// until it's committed, when they are given to apply, which must apply all of
+// them atomically.
+func NewReferenceTransaction(apply func([]*ReferenceUpdate) error) ReferenceTransaction {
+	return &referenceTransaction{apply: apply, names: make(map[plumbing.ReferenceName]bool)}
+}
+
+type referenceTransaction struct {
+	apply   func([]*ReferenceUpdate) error
+	updates []*ReferenceUpdate
+	names   map[plumbing.ReferenceName]bool
+	closed  bool
+}
+
+func (tx *referenceTransaction) SetReference(ref, old *plumbing.Reference) error {
+	if ref.Type() != plumbing.HashReference {
+		return ErrSymbolicReferenceUpdate
+	}
+
+	return tx.queue(&ReferenceUpdate{Name: ref.Name(), New: ref, Old: old})
+}
+
+func (tx *referenceTransaction) RemoveReference(name plumbing.ReferenceName, old *plumbing.Reference) error {
+	return tx.queue(&ReferenceUpdate{Name: name, Old: old})
+}
+
+func (tx *referenceTransaction) queue(u *ReferenceUpdate) error {
+	if tx.closed {
+		return ErrReferenceTransactionClosed
+	}
+
+	if !strings.HasPrefix(u.Name.String(), refsPrefix) {
+		return fmt.Errorf("%w: %s", ErrReferenceOutsideRefs, u.Name)
+	}
+
+	if tx.names[u.Name] {
+		return fmt.Errorf("multiple updates for reference %s", u.Name)
+	}
+
+	tx.names[u.Name] = true
+	tx.updates = append(tx.updates, u)
+	return nil
+}
+
+func (tx *referenceTransaction) Commit() error {
+	if tx.closed {
+		return ErrReferenceTransactionClosed
+	}
+
+	tx.closed = true
+	if len(tx.updates) == 0 {
+		return nil
+	}
+
+	return tx.apply(tx.updates)
+}
+
+func (tx *referenceTransaction) Rollback() error {
+	if tx.closed {
+		return ErrReferenceTransactionClosed
+	}
+
+	tx.closed = true
+	tx.updates = nil
+	return nil
+}
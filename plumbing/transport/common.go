@@ -10,8 +10,10 @@ import (
 	"errors"
 	"io"
 	"regexp"
+	"time"
 
 	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/go-git/go-git/v6/plumbing/cache"
 	"github.com/go-git/go-git/v6/plumbing/protocol"
 	"github.com/go-git/go-git/v6/plumbing/protocol/packp"
 	"github.com/go-git/go-git/v6/plumbing/protocol/packp/capability"
@@ -31,6 +33,14 @@ var (
 	ErrUnsupportedService = errors.New("unsupported service")
 	// ErrInvalidResponse is returned when the response is invalid.
 	ErrInvalidResponse = errors.New("invalid response")
+	// ErrInvalidRequest is returned when the request of a client is invalid.
+	ErrInvalidRequest = errors.New("invalid request")
+	// ErrUnsupportedCommand is returned when a protocol v2 client requests a
+	// command the server doesn't support.
+	ErrUnsupportedCommand = errors.New("unsupported command")
+	// ErrObjectInfoNotSupported is returned when the server doesn't support
+	// the object-info command, which requires protocol v2.
+	ErrObjectInfoNotSupported = errors.New("object-info not supported")
 	// ErrTimeoutExceeded is returned when the timeout is exceeded.
 	ErrTimeoutExceeded = errors.New("timeout exceeded")
 	// ErrPackedObjectsNotSupported is returned when the server does not support
@@ -88,6 +98,12 @@ type Connection interface {
 
 	// Push sends a send-pack request to the server.
 	Push(ctx context.Context, req *PushRequest) error
+
+	// ObjectInfo returns the size of the given objects, without fetching
+	// them, using the object-info command of protocol v2. This returns
+	// ErrObjectInfoNotSupported if the connection doesn't use protocol v2,
+	// or the server doesn't advertise the command.
+	ObjectInfo(ctx context.Context, hashes []plumbing.Hash) ([]ObjectSize, error)
 }
 
 var _ io.Closer = Connection(nil)
@@ -103,19 +119,47 @@ type FetchRequest struct {
 	// TODO: Build this slice in the transport package.
 	Wants []plumbing.Hash
 
-	// Haves is the list of references the client already has.
-	// TODO: Build this slice in the transport package.
+	// Haves is the list of references the client already has. The history
+	// of the commits is walked during the negotiation, sending the most
+	// recent commits first.
 	Haves []plumbing.Hash
 
+	// NegotiationRounds is the maximum number of rounds of haves sent to the
+	// server before giving up the negotiation. Defaults to
+	// DefaultNegotiationRounds, a negative value means no limit.
+	NegotiationRounds int
+
 	// Depth is the depth of the fetch.
 	Depth int
 
+	// DeepenRelative makes Depth relative to the current shallow boundary
+	// of the repository, instead of the tips of the wants.
+	DeepenRelative bool
+
+	// DeepenSince, if not zero, limits the history to the commits more
+	// recent than it. It can't be used with Depth.
+	DeepenSince time.Time
+
+	// DeepenNot limits the history to the commits not reachable from the
+	// given references of the remote. It can't be used with Depth.
+	DeepenNot []string
+
 	// Filter holds the filters to be applied when deciding what
 	// objects will be added to the packfile.
 	Filter packp.Filter
 
 	// IncludeTags indicates whether tags should be fetched.
 	IncludeTags bool
+
+	// DeltaBaseCache, if not nil, is the cache of the delta bases resolved
+	// while decoding the packfile, see packfile.WithDeltaBaseCache.
+	DeltaBaseCache cache.Object
+}
+
+// isShallow returns whether the history fetched is limited, by Depth,
+// DeepenSince or DeepenNot, the server then sending the shallow boundary.
+func (r *FetchRequest) isShallow() bool {
+	return r.Depth > 0 || !r.DeepenSince.IsZero() || len(r.DeepenNot) > 0
 }
 
 // PushRequest contains the parameters for a push request.
@@ -131,12 +175,20 @@ type PushRequest struct {
 	Progress sideband.Progress
 
 	// Options is a set of push-options to be sent to the server during push.
+	// ErrPushOptionsNotSupported is returned if the server doesn't support
+	// them.
 	Options []string
 
 	// Atomic indicates an atomic push.
-	// If the server supports atomic push, it will update the refs in one
-	// atomic transaction. Either all refs are updated or none.
+	// The server updates the refs in one atomic transaction, either all refs
+	// are updated or none. ErrAtomicPushNotSupported is returned if the
+	// server doesn't support it.
 	Atomic bool
+
+	// Certificate is the signed push certificate sent instead of the
+	// commands, which it must record. ErrPushCertNotSupported is returned if
+	// the server doesn't support it.
+	Certificate *packp.PushCertificate
 }
 
 // Session is a Git protocol transfer session.
@@ -1,7 +1,9 @@
 package transport
 
 import (
+	"bytes"
 	"context"
+	"encoding/binary"
 	"io"
 
 	"github.com/go-git/go-git/v6/plumbing/format/packfile"
@@ -39,10 +41,35 @@ func FetchPack(
 		reader = demuxer
 	}
 
-	if err := packfile.UpdateObjectStorage(st, reader); err != nil {
+	var header packHeader
+	if req.Progress != nil {
+		reader = io.TeeReader(reader, &header)
+	}
+
+	receiving := &receivingReader{r: reader, header: &header, progress: req.Progress}
+	if _, ok := req.Progress.(sideband.ProgressReporter); ok {
+		reader = receiving
+	}
+
+	var opts []packfile.ParserOption
+	if req.DeltaBaseCache != nil {
+		opts = append(opts, packfile.WithDeltaBaseCache(req.DeltaBaseCache))
+	}
+
+	if err := packfile.UpdateObjectStorage(st, reader, opts...); err != nil {
 		return err
 	}
 
+	if n, ok := header.Objects(); ok {
+		sideband.Report(req.Progress, sideband.ProgressReport{
+			Stage:   sideband.StageReceiving,
+			Current: uint64(n),
+			Total:   uint64(n),
+			Bytes:   receiving.bytes,
+			Done:    true,
+		})
+	}
+
 	if err := packf.Close(); err != nil {
 		return err
 	}
@@ -57,6 +84,52 @@ func FetchPack(
 	return nil
 }
 
+// packHeader records the header of a packfile written to it, discarding the
+// rest of the data.
+type packHeader struct {
+	buf [12]byte
+	n   int
+}
+
+func (h *packHeader) Write(p []byte) (int, error) {
+	h.n += copy(h.buf[h.n:], p)
+	return len(p), nil
+}
+
+// Objects returns the number of objects of the packfile, if a valid header
+// was written.
+func (h *packHeader) Objects() (uint32, bool) {
+	if h.n < len(h.buf) || !bytes.Equal(h.buf[:4], []byte("PACK")) {
+		return 0, false
+	}
+
+	return binary.BigEndian.Uint32(h.buf[8:]), true
+}
+
+// receivingReader reports the bytes of the packfile read from it, along with
+// its number of objects once its header is read.
+type receivingReader struct {
+	r        io.Reader
+	header   *packHeader
+	progress sideband.Progress
+	bytes    uint64
+}
+
+func (r *receivingReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	if n > 0 {
+		r.bytes += uint64(n)
+		total, _ := r.header.Objects()
+		sideband.Report(r.progress, sideband.ProgressReport{
+			Stage: sideband.StageReceiving,
+			Total: uint64(total),
+			Bytes: r.bytes,
+		})
+	}
+
+	return n, err
+}
+
 func updateShallow(st storage.Storer, shallowInfo *packp.ShallowUpdate) error {
 	shallows, err := st.Shallow()
 	if err != nil {
@@ -0,0 +1,161 @@
+package file
+
+import (
+	"bufio"
+	"context"
+	"os"
+	"strings"
+
+	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/go-git/go-git/v6/plumbing/format/bundle"
+	"github.com/go-git/go-git/v6/plumbing/format/packfile"
+	"github.com/go-git/go-git/v6/plumbing/protocol"
+	"github.com/go-git/go-git/v6/plumbing/protocol/packp/capability"
+	"github.com/go-git/go-git/v6/plumbing/transport"
+	"github.com/go-git/go-git/v6/storage"
+	"github.com/go-git/go-git/v6/utils/ioutil"
+)
+
+// fileTransport serves the bundles found at the endpoint path, falling back
+// to the repositories served by the pack transport otherwise.
+type fileTransport struct {
+	transport.Transport
+}
+
+// NewSession returns a new session for an endpoint, which can be either a
+// repository or a bundle file.
+func (t *fileTransport) NewSession(st storage.Storer, ep *transport.Endpoint, auth transport.AuthMethod) (transport.Session, error) {
+	if isBundle(ep.Path) {
+		return &bundleSession{st: st, path: ep.Path}, nil
+	}
+
+	return t.Transport.NewSession(st, ep, auth)
+}
+
+// isBundle returns true if the given path is a file starting with the
+// signature of a bundle.
+func isBundle(path string) bool {
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil || !fi.Mode().IsRegular() {
+		return false
+	}
+
+	line, err := bufio.NewReader(f).ReadString('\n')
+	if err != nil {
+		return false
+	}
+
+	return strings.HasPrefix(line, "# v") && strings.HasSuffix(line, " git bundle\n")
+}
+
+// bundleSession is a session fetching from a bundle file. Only the
+// upload-pack service is supported, since bundles are read-only.
+type bundleSession struct {
+	st   storage.Storer
+	path string
+}
+
+func (s *bundleSession) Handshake(ctx context.Context, service transport.Service, _ ...string) (transport.Connection, error) {
+	if service != transport.UploadPackService {
+		return nil, transport.ErrUnsupportedService
+	}
+
+	f, err := os.Open(s.path)
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := bundle.NewReader(f)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	return &bundleConnection{st: s.st, f: f, r: r}, nil
+}
+
+// bundleConnection implements transport.Connection over a bundle file. The
+// references of the bundle are advertised, and fetching stores its packfile
+// once the prerequisites are found in the storer.
+type bundleConnection struct {
+	st storage.Storer
+	f  *os.File
+	r  *bundle.Reader
+}
+
+func (c *bundleConnection) Close() error {
+	return c.f.Close()
+}
+
+func (c *bundleConnection) Capabilities() *capability.List {
+	return capability.NewList()
+}
+
+func (c *bundleConnection) Version() protocol.Version {
+	return protocol.V0
+}
+
+func (c *bundleConnection) StatelessRPC() bool {
+	return false
+}
+
+// GetRemoteRefs returns the references of the bundle. Bundles record HEAD as
+// a hash, so as git does, it's advertised as pointing to the branch matching
+// its hash, preferring master, to be able to checkout a branch when cloning.
+func (c *bundleConnection) GetRemoteRefs(context.Context) ([]*plumbing.Reference, error) {
+	refs := make([]*plumbing.Reference, 0, len(c.r.References))
+	var head *plumbing.Reference
+	for _, ref := range c.r.References {
+		if ref.Name() == plumbing.HEAD {
+			head = ref
+			continue
+		}
+
+		refs = append(refs, ref)
+	}
+
+	if head == nil {
+		return refs, nil
+	}
+
+	var branch *plumbing.Reference
+	for _, ref := range refs {
+		if !ref.Name().IsBranch() || ref.Hash() != head.Hash() {
+			continue
+		}
+
+		if branch == nil || ref.Name() == plumbing.Master {
+			branch = ref
+		}
+	}
+
+	if branch != nil {
+		head = plumbing.NewSymbolicReference(plumbing.HEAD, branch.Name())
+	}
+
+	return append(refs, head), nil
+}
+
+// Fetch stores the whole packfile of the bundle, regardless of the wants and
+// haves of the request.
+func (c *bundleConnection) Fetch(ctx context.Context, _ *transport.FetchRequest) error {
+	if err := c.r.VerifyPrerequisites(c.st); err != nil {
+		return err
+	}
+
+	return packfile.UpdateObjectStorage(c.st, ioutil.NewContextReader(ctx, c.r.Packfile()))
+}
+
+func (c *bundleConnection) Push(context.Context, *transport.PushRequest) error {
+	return transport.ErrUnsupportedService
+}
+
+func (c *bundleConnection) ObjectInfo(context.Context, []plumbing.Hash) ([]transport.ObjectSize, error) {
+	return nil, transport.ErrObjectInfoNotSupported
+}
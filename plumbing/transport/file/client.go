@@ -22,12 +22,14 @@ type runner struct {
 }
 
 // NewTransport returns a new file transport that users go-git built-in server
-// implementation to serve repositories.
+// implementation to serve repositories. Bundle files are served as well, the
+// references they contain can be fetched as long as the repository fetching
+// already has their prerequisites.
 func NewTransport(loader transport.Loader) transport.Transport {
 	if loader == nil {
 		loader = transport.DefaultLoader
 	}
-	return transport.NewPackTransport(&runner{loader})
+	return &fileTransport{transport.NewPackTransport(&runner{loader})}
 }
 
 func (r *runner) Command(ctx context.Context, cmd string, ep *transport.Endpoint, auth transport.AuthMethod, params ...string) (transport.Command, error) {
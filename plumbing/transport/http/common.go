@@ -7,6 +7,7 @@ import (
 	"context"
 	"crypto/tls"
 	"crypto/x509"
+	"errors"
 	"fmt"
 	"io"
 	"net"
@@ -41,7 +42,15 @@ func applyHeaders(
 	auth AuthMethod,
 	protocol string,
 	useSmart bool,
+	extra http.Header,
 ) {
+	// The extra headers go first, so the ones below are never replaced.
+	for name, values := range extra {
+		for _, v := range values {
+			req.Header.Add(name, v)
+		}
+	}
+
 	// Add headers
 	req.Header.Set("User-Agent", capability.DefaultAgent())
 	req.Header.Set("Host", ep.Host) // host:port
@@ -61,6 +70,29 @@ func applyHeaders(
 	}
 }
 
+// ParseExtraHeader returns the headers given by the values of the
+// http.extraHeader option of git, each one being a "Name: value" header. As in
+// git, an empty value drops the headers given by the previous ones.
+func ParseExtraHeader(values []string) (http.Header, error) {
+	header := make(http.Header)
+	for _, v := range values {
+		if v == "" {
+			header = make(http.Header)
+			continue
+		}
+
+		name, value, ok := strings.Cut(v, ":")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" {
+			return nil, fmt.Errorf("invalid extra header: %q", v)
+		}
+
+		header.Add(name, strings.TrimSpace(value))
+	}
+
+	return header, nil
+}
+
 // doRequest applies the auth and headers, then performs a request to the
 // server and returns the response.
 func doRequest(
@@ -88,6 +120,104 @@ func doRequest(
 	return res, checkError(res)
 }
 
+// do applies the auth of the session to the request and performs it. When
+// the server requires authentication and the session has a credential helper,
+// the credentials are queried to the helper and the request is retried. The
+// credentials refused by the server are rejected, so they aren't used again.
+func (s *HTTPSession) do(req *http.Request) (*http.Response, error) {
+	if s.auth != nil {
+		s.auth.SetAuth(req)
+	}
+
+	res, err := doRequest(s.client, req)
+	for fills := 0; s.credentials != nil && errors.Is(err, transport.ErrAuthenticationRequired); fills++ {
+		if res.Body != nil {
+			drainAndClose(res.Body) // nolint: errcheck
+		}
+
+		if err := s.rejectCredential(req.Context()); err != nil {
+			return nil, err
+		}
+
+		if fills == maxCredentialFills {
+			break
+		}
+
+		// A helper without credentials fails, e.g. `git credential fill`
+		// exits with an error, which is wrapped so the authentication is
+		// still reported as required.
+		cred, ferr := s.credentials.Fill(req.Context(), s.credentialFor(req.URL))
+		if ferr != nil {
+			return nil, fmt.Errorf("%w: credential helper: %w", err, ferr)
+		}
+
+		s.auth = &BasicAuth{cred.Username, cred.Password}
+		s.credential, s.approved = cred, false
+
+		if req, err = retryRequest(req); err != nil {
+			return nil, err
+		}
+
+		s.auth.SetAuth(req)
+		res, err = doRequest(s.client, req)
+	}
+
+	if err == nil && s.credential != nil && !s.approved {
+		if err := s.credentials.Approve(req.Context(), s.credential); err != nil {
+			return nil, err
+		}
+
+		s.approved = true
+	}
+
+	return res, err
+}
+
+// rejectCredential rejects the credential filled by the helper, if any.
+func (s *HTTPSession) rejectCredential(ctx context.Context) error {
+	if s.credential == nil {
+		return nil
+	}
+
+	cred := s.credential
+	s.auth, s.credential = nil, nil
+	return s.credentials.Reject(ctx, cred)
+}
+
+// retryRequest returns a copy of the given request, without authorization and
+// with a fresh body, to be sent again.
+func retryRequest(req *http.Request) (*http.Request, error) {
+	r := req.Clone(req.Context())
+	r.Header.Del("Authorization")
+	if req.Body == nil || req.Body == http.NoBody {
+		return r, nil
+	}
+
+	if req.GetBody == nil {
+		return nil, fmt.Errorf("http: cannot retry request to %s", req.URL)
+	}
+
+	body, err := req.GetBody()
+	if err != nil {
+		return nil, err
+	}
+
+	r.Body = body
+	return r, nil
+}
+
+// maxDrainSize is the maximum number of bytes read from a response body
+// before closing it, to let the connection be reused.
+const maxDrainSize = 64 << 10
+
+// drainAndClose reads what is left of a response body, up to maxDrainSize,
+// and closes it. A body closed before being fully read makes the client drop
+// the connection instead of reusing it for the next request.
+func drainAndClose(body io.ReadCloser) error {
+	_, _ = io.Copy(io.Discard, io.LimitReader(body, maxDrainSize))
+	return body.Close()
+}
+
 // modifyRedirect modifies the endpoint based on the redirect response.
 func modifyRedirect(res *http.Response, ep *transport.Endpoint) {
 	if res.Request == nil {
@@ -122,12 +252,23 @@ type client struct {
 	transports *lru.Cache
 	mutex      sync.RWMutex
 	useDumb    bool // When true, the client will always use the dumb protocol.
+
+	credentials CredentialHelper
+	header      http.Header
 }
 
 // TransportOptions holds user configurable options for the client.
 type TransportOptions struct {
 	// Client is the http client that the transport will use to make requests.
 	// If nil, [http.DefaultTransport] will be used.
+	//
+	// The client is shared by every session of the transport, and used for
+	// all of their requests, so its connections are pooled and reused from
+	// the info/refs request to the upload-pack and receive-pack ones. HTTP/2
+	// is used when the transport of the client supports it, as
+	// [http.DefaultTransport] does. The endpoints requiring a specific TLS or
+	// proxy configuration use a clone of the transport of the client, only
+	// kept across sessions when CacheMaxEntries is set.
 	Client *http.Client
 
 	// CacheMaxEntries is the max no. of entries that the transport objects
@@ -140,6 +281,17 @@ type TransportOptions struct {
 	// UseDumb is a flag that when set to true, the client will always use the
 	// dumb protocol.
 	UseDumb bool
+
+	// CredentialHelper is used to get the credentials when the server requires
+	// authentication and no AuthMethod is given to the session. See
+	// GitCredentialHelper to use the helpers configured in git.
+	CredentialHelper CredentialHelper
+
+	// ExtraHeader holds the headers added to every request, as the
+	// http.extraHeader option of git does, see ParseExtraHeader. A header
+	// can have several values. The headers set by the transport itself, as
+	// Content-Type or Git-Protocol, take precedence over these.
+	ExtraHeader http.Header
 }
 
 var (
@@ -176,8 +328,10 @@ func NewTransport(opts *TransportOptions) transport.Transport {
 	}
 
 	cl := &client{
-		client:  opts.Client,
-		useDumb: opts.UseDumb,
+		client:      opts.Client,
+		useDumb:     opts.UseDumb,
+		credentials: opts.CredentialHelper,
+		header:      opts.ExtraHeader.Clone(),
 	}
 	if opts.CacheMaxEntries > 0 {
 		cl.transports = lru.New(opts.CacheMaxEntries)
@@ -211,6 +365,11 @@ type HTTPSession struct {
 	version     protocol.Version  // the server's protocol version
 	useDumb     bool              // When true, the client will always use the dumb protocol
 	isSmart     bool              // This is true if the session is using the smart protocol
+
+	header      http.Header      // the extra headers of every request
+	credentials CredentialHelper // the helper used when no auth is given
+	credential  *Credential      // the credential filled by the helper
+	approved    bool             // whether credential was approved already
 }
 
 // IsSmart returns true if the session is using the smart protocol.
@@ -323,6 +482,7 @@ func newSession(st storage.Storer, c *client, ep *transport.Endpoint, auth trans
 		client:  httpClient,
 		ep:      ep,
 		useDumb: useDumb,
+		header:  c.header,
 	}
 	if auth != nil {
 		a, ok := auth.(AuthMethod)
@@ -331,6 +491,8 @@ func newSession(st storage.Storer, c *client, ep *transport.Endpoint, auth trans
 		}
 
 		s.auth = a
+	} else {
+		s.credentials = c.credentials
 	}
 
 	return s, nil
@@ -356,8 +518,8 @@ func (s *HTTPSession) Handshake(ctx context.Context, service transport.Service,
 		s.gitProtocol = strings.Join(params, ":")
 	}
 
-	applyHeaders(req, service.String(), s.ep, s.auth, s.gitProtocol, !s.useDumb)
-	res, err := doRequest(s.client, req)
+	applyHeaders(req, service.String(), s.ep, nil, s.gitProtocol, !s.useDumb, s.header)
+	res, err := s.do(req)
 	if err != nil {
 		return nil, err
 	}
@@ -367,7 +529,9 @@ func (s *HTTPSession) Handshake(ctx context.Context, service transport.Service,
 	}
 
 	modifyRedirect(res, s.ep)
-	defer ioutil.CheckClose(res.Body, &err)
+	defer ioutil.CheckClose(ioutil.CloserFunc(func() error {
+		return drainAndClose(res.Body)
+	}), &err)
 
 	rd := bufio.NewReader(res.Body)
 	ar := packp.NewAdvRefs()
@@ -395,7 +559,15 @@ func (s *HTTPSession) Handshake(ctx context.Context, service transport.Service,
 		s.version, _ = transport.DiscoverVersion(rd)
 		switch s.version {
 		case protocol.V2:
-			return nil, transport.ErrUnsupportedVersion
+			// Protocol v2 advertises capabilities only, the references are
+			// listed with the ls-refs command.
+			ar.Capabilities, err = packp.DecodeCapabilitiesV2(rd)
+			if err != nil {
+				return nil, err
+			}
+
+			s.refs = ar
+			return s, nil
 		case protocol.V1:
 			// Read the version line
 			fallthrough
@@ -462,6 +634,10 @@ func (s *HTTPSession) Fetch(ctx context.Context, req *transport.FetchRequest) (e
 		return s.fetchDumb(ctx, req)
 	}
 
+	if s.version == protocol.V2 {
+		return transport.ErrUnsupportedVersion
+	}
+
 	rwc := newRequester(ctx, s, transport.UploadPackService)
 
 	// XXX: packfile will be populated and accessible once rwc.Close() is
@@ -481,6 +657,10 @@ func (s *HTTPSession) Fetch(ctx context.Context, req *transport.FetchRequest) (e
 
 // GetRemoteRefs implements transport.Connection.
 func (s *HTTPSession) GetRemoteRefs(ctx context.Context) ([]*plumbing.Reference, error) {
+	if s.version == protocol.V2 {
+		return nil, transport.ErrUnsupportedVersion
+	}
+
 	if s.refs == nil {
 		return nil, transport.ErrEmptyRemoteRepository
 	}
@@ -501,10 +681,31 @@ func (s *HTTPSession) GetRemoteRefs(ctx context.Context) ([]*plumbing.Reference,
 
 // Push implements transport.Connection.
 func (s *HTTPSession) Push(ctx context.Context, req *transport.PushRequest) (err error) {
+	if s.version == protocol.V2 {
+		return transport.ErrUnsupportedVersion
+	}
+
 	rwc := newRequester(ctx, s, transport.ReceivePackService)
 	return transport.SendPack(ctx, s.st, s, rwc, rwc.BodyCloser(), req)
 }
 
+// ObjectInfo implements transport.Connection.
+func (s *HTTPSession) ObjectInfo(ctx context.Context, hashes []plumbing.Hash) (sizes []transport.ObjectSize, err error) {
+	if !s.IsSmart() {
+		return nil, transport.ErrObjectInfoNotSupported
+	}
+
+	rwc := newRequester(ctx, s, transport.UploadPackService)
+	body := rwc.BodyCloser()
+	defer func() {
+		if rwc.res != nil {
+			ioutil.CheckClose(body, &err)
+		}
+	}()
+
+	return transport.ObjectInfo(ctx, s, body, rwc, hashes)
+}
+
 // Version implements transport.Connection.
 func (s *HTTPSession) Version() protocol.Version {
 	return s.version
@@ -539,7 +740,7 @@ func (r *requester) BodyCloser() io.ReadCloser {
 		if r.res == nil {
 			panic("http: requester.res is accessed before requester.Close")
 		}
-		return r.res.Body.Close()
+		return drainAndClose(r.res.Body)
 	}))
 }
 
@@ -561,8 +762,8 @@ func (r *requester) Close() (err error) {
 		return err
 	}
 
-	applyHeaders(r.req, r.service, r.ep, r.auth, r.gitProtocol, r.IsSmart())
-	r.res, err = doRequest(r.client, r.req)
+	applyHeaders(r.req, r.service, r.ep, nil, r.gitProtocol, r.IsSmart(), r.header)
+	r.res, err = r.do(r.req)
 	if err != nil {
 		return err
 	}
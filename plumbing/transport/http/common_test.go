@@ -1,11 +1,13 @@
 package http
 
 import (
+	"context"
 	"crypto/tls"
 	"fmt"
 	"net"
 	"net/http"
 	"net/http/cgi"
+	"net/http/httptest"
 	"net/url"
 	"os"
 	"os/exec"
@@ -18,6 +20,7 @@ import (
 	"github.com/go-git/go-git/v6/plumbing/transport"
 	"github.com/go-git/go-git/v6/storage"
 	"github.com/go-git/go-git/v6/storage/filesystem"
+	"github.com/go-git/go-git/v6/storage/memory"
 	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
 
@@ -178,6 +181,67 @@ func (s *ClientSuite) TestSetAuthWrongType() {
 	s.Equal(transport.ErrInvalidAuthMethod, err)
 }
 
+func (s *ClientSuite) TestExtraHeader() {
+	var headers []http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		headers = append(headers, r.Header.Clone())
+		w.Header().Set("Content-Type", "application/x-git-upload-pack-advertisement")
+		w.Write([]byte("001e# service=git-upload-pack\n0000"))
+	}))
+	defer server.Close()
+
+	ep, err := transport.NewEndpoint(server.URL + "/repo.git")
+	s.Require().NoError(err)
+
+	extra := http.Header{}
+	extra.Add("X-Forwarded-For", "10.0.0.1")
+	extra.Add("X-Custom", "a")
+	extra.Add("X-Custom", "b")
+	extra.Set("Content-Type", "text/plain")
+	extra.Set("Git-Protocol", "version=0")
+
+	cl := NewTransport(&TransportOptions{ExtraHeader: extra}).(*client)
+	session, err := newSession(memory.NewStorage(), cl, ep, nil, false)
+	s.Require().NoError(err)
+
+	// Only the headers of the request matter, not the empty advertisement.
+	_, err = session.Handshake(context.Background(), transport.UploadPackService, "version=1")
+	s.Error(err)
+
+	session.isSmart = true
+	rwc := newRequester(context.Background(), session, transport.UploadPackService)
+	s.Require().NoError(rwc.Close())
+	s.Require().NoError(rwc.BodyCloser().Close())
+
+	s.Require().Len(headers, 2)
+	for _, h := range headers {
+		s.Equal("10.0.0.1", h.Get("X-Forwarded-For"))
+		s.Equal([]string{"a", "b"}, h.Values("X-Custom"))
+		s.Equal([]string{"version=1"}, h.Values("Git-Protocol"))
+		s.Equal([]string{"application/x-git-upload-pack-request"}, h.Values("Content-Type"))
+	}
+}
+
+func (s *ClientSuite) TestParseExtraHeader() {
+	h, err := ParseExtraHeader([]string{
+		"X-Dropped: value",
+		"",
+		"Authorization: Bearer token",
+		"X-Custom:a",
+		"x-custom: b ",
+	})
+	s.Require().NoError(err)
+	s.Equal(http.Header{
+		"Authorization": {"Bearer token"},
+		"X-Custom":      {"a", "b"},
+	}, h)
+
+	_, err = ParseExtraHeader([]string{"invalid"})
+	s.Error(err)
+	_, err = ParseExtraHeader([]string{": value"})
+	s.Error(err)
+}
+
 func (s *ClientSuite) TestModifyEndpointIfRedirect() {
 	sess := &HTTPSession{ep: nil}
 	u, _ := url.Parse("https://example.com/info/refs")
@@ -0,0 +1,161 @@
+package http
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"os/exec"
+	"strings"
+)
+
+// maxCredentialFills is the number of times the credential helper is queried
+// for a single request, before giving up with an authentication error.
+const maxCredentialFills = 2
+
+// Credential is the description of a credential exchanged with a
+// CredentialHelper, as described in gitcredentials(7).
+type Credential struct {
+	Protocol string
+	Host     string
+	Path     string
+	Username string
+	Password string
+}
+
+// CredentialHelper retrieves and stores the credentials used to authenticate
+// with HTTP servers, as git credential helpers do.
+type CredentialHelper interface {
+	// Fill returns the credential to use for the given description, which
+	// has at least the protocol and host set.
+	Fill(ctx context.Context, c *Credential) (*Credential, error)
+	// Approve is called when the given credential is accepted by the server,
+	// so it can be stored for later use.
+	Approve(ctx context.Context, c *Credential) error
+	// Reject is called when the given credential is refused by the server,
+	// so it's removed from any storage and not returned again.
+	Reject(ctx context.Context, c *Credential) error
+}
+
+// CommandRunner runs the git command with the given arguments, writing stdin
+// to its standard input and returning its standard output.
+type CommandRunner func(ctx context.Context, stdin io.Reader, args ...string) ([]byte, error)
+
+// GitCredentialHelper is a CredentialHelper using `git credential`, so the
+// helpers configured by the user with credential.helper are used.
+type GitCredentialHelper struct {
+	// Run runs the git command. If nil, the git binary found in the PATH is
+	// executed.
+	Run CommandRunner
+}
+
+var _ CredentialHelper = (*GitCredentialHelper)(nil)
+
+// Fill implements CredentialHelper, running `git credential fill`.
+func (h *GitCredentialHelper) Fill(ctx context.Context, c *Credential) (*Credential, error) {
+	out, err := h.run(ctx, "fill", c)
+	if err != nil {
+		return nil, err
+	}
+
+	filled := *c
+	if err := decodeCredential(out, &filled); err != nil {
+		return nil, err
+	}
+
+	return &filled, nil
+}
+
+// Approve implements CredentialHelper, running `git credential approve`.
+func (h *GitCredentialHelper) Approve(ctx context.Context, c *Credential) error {
+	_, err := h.run(ctx, "approve", c)
+	return err
+}
+
+// Reject implements CredentialHelper, running `git credential reject`.
+func (h *GitCredentialHelper) Reject(ctx context.Context, c *Credential) error {
+	_, err := h.run(ctx, "reject", c)
+	return err
+}
+
+func (h *GitCredentialHelper) run(ctx context.Context, action string, c *Credential) ([]byte, error) {
+	run := h.Run
+	if run == nil {
+		run = runGitCommand
+	}
+
+	var in bytes.Buffer
+	encodeCredential(&in, c)
+	out, err := run(ctx, &in, "credential", action)
+	if err != nil {
+		return nil, fmt.Errorf("git credential %s: %w", action, err)
+	}
+
+	return out, nil
+}
+
+func runGitCommand(ctx context.Context, stdin io.Reader, args ...string) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Stdin = stdin
+	// Disable the terminal prompts, the transport may not run interactively.
+	cmd.Env = append(cmd.Environ(), "GIT_TERMINAL_PROMPT=0")
+	return cmd.Output()
+}
+
+func encodeCredential(w io.Writer, c *Credential) {
+	for _, kv := range [][2]string{
+		{"protocol", c.Protocol},
+		{"host", c.Host},
+		{"path", c.Path},
+		{"username", c.Username},
+		{"password", c.Password},
+	} {
+		if kv[1] != "" {
+			fmt.Fprintf(w, "%s=%s\n", kv[0], kv[1])
+		}
+	}
+
+	fmt.Fprintln(w)
+}
+
+func decodeCredential(out []byte, c *Credential) error {
+	s := bufio.NewScanner(bytes.NewReader(out))
+	for s.Scan() {
+		line := s.Text()
+		if line == "" {
+			break
+		}
+
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return fmt.Errorf("invalid credential line: %q", line)
+		}
+
+		switch key {
+		case "protocol":
+			c.Protocol = value
+		case "host":
+			c.Host = value
+		case "path":
+			c.Path = value
+		case "username":
+			c.Username = value
+		case "password":
+			c.Password = value
+		}
+	}
+
+	return s.Err()
+}
+
+// credentialFor returns the description of the credential for the given
+// url, with the username of the endpoint if any.
+func (s *HTTPSession) credentialFor(u *url.URL) *Credential {
+	return &Credential{
+		Protocol: u.Scheme,
+		Host:     u.Host,
+		Username: s.ep.User,
+	}
+}
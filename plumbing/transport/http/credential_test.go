@@ -0,0 +1,218 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-git/go-git/v6/plumbing/transport"
+	"github.com/go-git/go-git/v6/storage/memory"
+	"github.com/stretchr/testify/suite"
+)
+
+type CredentialSuite struct {
+	suite.Suite
+	server *httptest.Server
+	bodies []string
+}
+
+func TestCredentialSuite(t *testing.T) {
+	suite.Run(t, new(CredentialSuite))
+}
+
+func (s *CredentialSuite) SetupTest() {
+	s.bodies = nil
+	s.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		s.bodies = append(s.bodies, string(body))
+
+		user, pass, ok := r.BasicAuth()
+		if !ok || user != "foo" || pass != "good" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+	}))
+}
+
+func (s *CredentialSuite) TearDownTest() {
+	s.server.Close()
+}
+
+type fakeCredentialHelper struct {
+	fillErr   error
+	passwords []string
+	fills     []*Credential
+	approved  []string
+	rejected  []string
+}
+
+func (h *fakeCredentialHelper) Fill(_ context.Context, c *Credential) (*Credential, error) {
+	h.fills = append(h.fills, c)
+	if h.fillErr != nil {
+		return nil, h.fillErr
+	}
+
+	filled := *c
+	filled.Username = "foo"
+	if len(h.passwords) > 0 {
+		filled.Password, h.passwords = h.passwords[0], h.passwords[1:]
+	}
+
+	return &filled, nil
+}
+
+func (h *fakeCredentialHelper) Approve(_ context.Context, c *Credential) error {
+	h.approved = append(h.approved, c.Password)
+	return nil
+}
+
+func (h *fakeCredentialHelper) Reject(_ context.Context, c *Credential) error {
+	h.rejected = append(h.rejected, c.Password)
+	return nil
+}
+
+func (s *CredentialSuite) newSession(helper CredentialHelper, auth transport.AuthMethod) *HTTPSession {
+	ep, err := transport.NewEndpoint(s.server.URL + "/repo.git")
+	s.Require().NoError(err)
+
+	cl := NewTransport(&TransportOptions{CredentialHelper: helper}).(*client)
+	session, err := newSession(memory.NewStorage(), cl, ep, auth, false)
+	s.Require().NoError(err)
+	return session
+}
+
+func (s *CredentialSuite) post(session *HTTPSession, body string) error {
+	req, err := http.NewRequest(http.MethodPost, s.server.URL+"/repo.git/git-upload-pack", strings.NewReader(body))
+	s.Require().NoError(err)
+
+	res, err := session.do(req)
+	if err != nil {
+		return err
+	}
+
+	return res.Body.Close()
+}
+
+func (s *CredentialSuite) TestFillAndApprove() {
+	helper := &fakeCredentialHelper{passwords: []string{"good"}}
+	session := s.newSession(helper, nil)
+
+	s.NoError(s.post(session, "first"))
+	s.NoError(s.post(session, "second"))
+
+	s.Len(helper.fills, 1)
+	s.Equal("http", helper.fills[0].Protocol)
+	s.Equal(strings.TrimPrefix(s.server.URL, "http://"), helper.fills[0].Host)
+	s.Equal([]string{"good"}, helper.approved)
+	s.Empty(helper.rejected)
+
+	// The body is sent again with the credentials, and the credentials are
+	// reused by the following requests.
+	s.Equal([]string{"first", "first", "second"}, s.bodies)
+}
+
+func (s *CredentialSuite) TestRejectStaleCredentials() {
+	helper := &fakeCredentialHelper{passwords: []string{"stale", "good"}}
+	session := s.newSession(helper, nil)
+
+	s.NoError(s.post(session, "foo"))
+
+	s.Len(helper.fills, 2)
+	s.Equal([]string{"stale"}, helper.rejected)
+	s.Equal([]string{"good"}, helper.approved)
+}
+
+func (s *CredentialSuite) TestRejectAfterApprove() {
+	helper := &fakeCredentialHelper{passwords: []string{"good", "good"}}
+	session := s.newSession(helper, nil)
+	s.NoError(s.post(session, "foo"))
+
+	// The credentials are revoked by the server.
+	session.auth = &BasicAuth{"foo", "revoked"}
+	session.credential.Password = "revoked"
+
+	s.NoError(s.post(session, "foo"))
+	s.Equal([]string{"revoked"}, helper.rejected)
+	s.Equal([]string{"good", "good"}, helper.approved)
+}
+
+func (s *CredentialSuite) TestAuthenticationFailed() {
+	helper := &fakeCredentialHelper{passwords: []string{"bad", "worse"}}
+	session := s.newSession(helper, nil)
+
+	s.ErrorIs(s.post(session, "foo"), transport.ErrAuthenticationRequired)
+	s.Len(helper.fills, maxCredentialFills)
+	s.Equal([]string{"bad", "worse"}, helper.rejected)
+	s.Empty(helper.approved)
+	s.Nil(session.auth)
+}
+
+func (s *CredentialSuite) TestFillFailed() {
+	fillErr := errors.New("exit status 128")
+	helper := &fakeCredentialHelper{fillErr: fillErr}
+	session := s.newSession(helper, nil)
+
+	err := s.post(session, "foo")
+	s.ErrorIs(err, transport.ErrAuthenticationRequired)
+	s.ErrorIs(err, fillErr)
+	s.Len(helper.fills, 1)
+	s.Nil(session.auth)
+}
+
+func (s *CredentialSuite) TestExplicitAuth() {
+	helper := &fakeCredentialHelper{passwords: []string{"good"}}
+	session := s.newSession(helper, &BasicAuth{"foo", "bad"})
+
+	s.ErrorIs(s.post(session, "foo"), transport.ErrAuthenticationRequired)
+	s.Empty(helper.fills)
+}
+
+func (s *CredentialSuite) TestGitCredentialHelper() {
+	type call struct {
+		args  []string
+		stdin string
+	}
+
+	var calls []call
+	helper := &GitCredentialHelper{
+		Run: func(_ context.Context, stdin io.Reader, args ...string) ([]byte, error) {
+			in, err := io.ReadAll(stdin)
+			s.Require().NoError(err)
+			calls = append(calls, call{args, string(in)})
+
+			if args[1] == "fill" {
+				return []byte("protocol=https\nhost=example.com\nusername=foo\npassword=bar\n"), nil
+			}
+
+			return nil, nil
+		},
+	}
+
+	cred, err := helper.Fill(context.Background(), &Credential{Protocol: "https", Host: "example.com"})
+	s.NoError(err)
+	s.Equal(&Credential{Protocol: "https", Host: "example.com", Username: "foo", Password: "bar"}, cred)
+
+	s.NoError(helper.Reject(context.Background(), cred))
+	s.NoError(helper.Approve(context.Background(), cred))
+
+	s.Equal([]call{
+		{[]string{"credential", "fill"}, "protocol=https\nhost=example.com\n\n"},
+		{[]string{"credential", "reject"}, "protocol=https\nhost=example.com\nusername=foo\npassword=bar\n\n"},
+		{[]string{"credential", "approve"}, "protocol=https\nhost=example.com\nusername=foo\npassword=bar\n\n"},
+	}, calls)
+}
+
+func (s *CredentialSuite) TestGitCredentialHelperInvalidOutput() {
+	helper := &GitCredentialHelper{
+		Run: func(context.Context, io.Reader, ...string) ([]byte, error) {
+			return []byte("garbage\n"), nil
+		},
+	}
+
+	_, err := helper.Fill(context.Background(), &Credential{Protocol: "https", Host: "example.com"})
+	s.ErrorContains(err, "invalid credential line")
+}
@@ -24,7 +24,7 @@ import (
 )
 
 func (s *HTTPSession) fetchDumb(ctx context.Context, req *transport.FetchRequest) error {
-	if req.Depth != 0 {
+	if req.Depth != 0 || !req.DeepenSince.IsZero() || len(req.DeepenNot) > 0 {
 		return errors.New("dumb http protocol does not support shallow capabilities")
 	}
 
@@ -77,8 +77,8 @@ func (r *fetchWalker) getInfoPacks() ([]string, error) {
 		return nil, err
 	}
 
-	applyHeaders(req, "", r.ep, r.auth, "", false)
-	res, err := doRequest(r.client, req)
+	applyHeaders(req, "", r.ep, nil, "", false, r.header)
+	res, err := r.do(req)
 	if err != nil {
 		return nil, err
 	}
@@ -116,8 +116,8 @@ func (r *fetchWalker) downloadFile(fp string) (rErr error) {
 		return err
 	}
 
-	applyHeaders(req, "", r.ep, r.auth, "", false)
-	res, err := doRequest(r.client, req)
+	applyHeaders(req, "", r.ep, nil, "", false, r.header)
+	res, err := r.do(req)
 	if err != nil {
 		return err
 	}
@@ -173,8 +173,8 @@ func (r *fetchWalker) getHead() (ref *plumbing.Reference, err error) {
 		return nil, err
 	}
 
-	applyHeaders(req, "", r.ep, r.auth, "", false)
-	res, err := doRequest(r.client, req)
+	applyHeaders(req, "", r.ep, nil, "", false, r.header)
+	res, err := r.do(req)
 	if err != nil {
 		return nil, err
 	}
@@ -303,8 +303,8 @@ func (r *fetchWalker) fetchObject(hash plumbing.Hash, obj plumbing.EncodedObject
 		return err
 	}
 
-	applyHeaders(req, "", r.ep, r.auth, "", false)
-	res, err := doRequest(r.client, req)
+	applyHeaders(req, "", r.ep, nil, "", false, r.header)
+	res, err := r.do(req)
 	if errors.Is(err, transport.ErrRepositoryNotFound) {
 		// TODO: better error handling
 		return io.EOF
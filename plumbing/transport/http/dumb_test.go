@@ -69,3 +69,4 @@ func (*DumbSuite) TestUploadPackInvalidReq()                  {}
 func (*DumbSuite) TestUploadPackMulti()                       {}
 func (*DumbSuite) TestUploadPackNoChanges()                   {}
 func (*DumbSuite) TestUploadPackPartial()                     {}
+func (*DumbSuite) TestObjectInfo()                            {}
@@ -2,14 +2,26 @@ package http
 
 import (
 	"context"
+	"fmt"
+	"net"
 	"net/http"
+	"net/http/cgi"
+	"net/http/httptest"
 	"net/url"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 
 	"github.com/go-git/go-git/v6/internal/transport/test"
+	"github.com/go-git/go-git/v6/plumbing"
 	"github.com/go-git/go-git/v6/plumbing/transport"
 	"github.com/go-git/go-git/v6/storage/filesystem"
 	"github.com/go-git/go-git/v6/storage/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
 
 	fixtures "github.com/go-git/go-git-fixtures/v5"
@@ -138,3 +150,74 @@ func (s *UploadPackSuite) TestAdvertisedReferencesContextCanceled() {
 	s.Nil(conn)
 	s.Equal(&url.Error{Op: "Get", URL: "http://github.com/git-fixtures/basic/info/refs?service=git-upload-pack", Err: context.Canceled}, err)
 }
+
+func TestUploadPackSharedClient(t *testing.T) {
+	for _, h2 := range []bool{false, true} {
+		t.Run(fmt.Sprintf("HTTP2=%t", h2), func(t *testing.T) {
+			base := t.TempDir()
+			test.PrepareRepository(t, fixtures.Basic().One(), base, "basic.git")
+
+			cmd := exec.Command("git", "--exec-path")
+			out, err := cmd.CombinedOutput()
+			require.NoError(t, err)
+
+			var mu sync.Mutex
+			var protos, gitProtocols []string
+			backend := &cgi.Handler{
+				Path: filepath.Join(strings.TrimSpace(string(out)), "git-http-backend"),
+				Env:  []string{"GIT_HTTP_EXPORT_ALL=true", fmt.Sprintf("GIT_PROJECT_ROOT=%s", base)},
+			}
+			server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				mu.Lock()
+				protos = append(protos, r.Proto)
+				gitProtocols = append(gitProtocols, r.Header.Get("Git-Protocol"))
+				mu.Unlock()
+
+				backend.ServeHTTP(w, r)
+			}))
+
+			var conns atomic.Int32
+			server.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+				if state == http.StateNew {
+					conns.Add(1)
+				}
+			}
+			server.EnableHTTP2 = h2
+			server.StartTLS()
+			defer server.Close()
+
+			ep, err := transport.NewEndpoint(server.URL + "/basic.git")
+			require.NoError(t, err)
+
+			tr := NewTransport(&TransportOptions{Client: server.Client()})
+
+			// Two fetches, each made of the info/refs and the upload-pack
+			// requests, all over the same connection.
+			for i := 0; i < 2; i++ {
+				st := memory.NewStorage()
+				sess, err := tr.NewSession(st, ep, nil)
+				require.NoError(t, err)
+
+				conn, err := sess.Handshake(context.Background(), transport.UploadPackService, "version=1")
+				require.NoError(t, err)
+
+				req := &transport.FetchRequest{}
+				req.Wants = append(req.Wants, plumbing.NewHash("6ecf0ef2c2dffb796033e5a02219af86ec6584e5"))
+				require.NoError(t, conn.Fetch(context.Background(), req))
+				require.NoError(t, conn.Close())
+
+				_, err = st.EncodedObject(plumbing.CommitObject, plumbing.NewHash("6ecf0ef2c2dffb796033e5a02219af86ec6584e5"))
+				require.NoError(t, err)
+			}
+
+			proto := "HTTP/1.1"
+			if h2 {
+				proto = "HTTP/2.0"
+			}
+
+			assert.Equal(t, []string{proto, proto, proto, proto}, protos)
+			assert.Equal(t, []string{"version=1", "version=1", "version=1", "version=1"}, gitProtocols)
+			assert.Equal(t, int32(1), conns.Load())
+		})
+	}
+}
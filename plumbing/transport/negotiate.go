@@ -10,15 +10,24 @@ import (
 	"github.com/go-git/go-git/v6/plumbing/format/pktline"
 	"github.com/go-git/go-git/v6/plumbing/protocol/packp"
 	"github.com/go-git/go-git/v6/plumbing/protocol/packp/capability"
+	"github.com/go-git/go-git/v6/plumbing/protocol/packp/sideband"
 	"github.com/go-git/go-git/v6/storage"
 	"github.com/go-git/go-git/v6/utils/ioutil"
 )
 
 var (
-	ErrFilterNotSupported  = errors.New("server does not support filters")
-	ErrShallowNotSupported = errors.New("server does not support shallow clients")
+	ErrFilterNotSupported         = errors.New("server does not support filters")
+	ErrShallowNotSupported        = errors.New("server does not support shallow clients")
+	ErrDeepenRelativeNotSupported = errors.New("server does not support deepen-relative")
+	ErrDeepenSinceNotSupported    = errors.New("server does not support deepen-since")
+	ErrDeepenNotNotSupported      = errors.New("server does not support deepen-not")
+	ErrDepthDeepenExclusive       = errors.New("depth can't be used with deepen-since or deepen-not")
 )
 
+// InfiniteDepth is the depth requested to fetch the whole history of a
+// shallow repository, as `git fetch --unshallow` does.
+const InfiniteDepth = 0x7fffffff
+
 // NegotiatePack returns the result of the pack negotiation phase of the fetch operation.
 // See https://git-scm.com/docs/pack-protocol#_packfile_negotiation
 func NegotiatePack(
@@ -83,12 +92,44 @@ func NegotiatePack(
 
 	upreq.Wants = req.Wants
 
-	if req.Depth > 0 {
+	if req.isShallow() {
 		if !caps.Supports(capability.Shallow) {
 			return nil, ErrShallowNotSupported
 		}
 
+		if req.Depth > 0 && (!req.DeepenSince.IsZero() || len(req.DeepenNot) > 0) {
+			return nil, ErrDepthDeepenExclusive
+		}
+
+		if req.DeepenRelative {
+			if !caps.Supports(capability.DeepenRelative) {
+				return nil, ErrDeepenRelativeNotSupported
+			}
+
+			if err := upreq.Capabilities.Set(capability.DeepenRelative); err != nil {
+				return nil, err
+			}
+		}
+
 		upreq.Depth = packp.DepthCommits(req.Depth)
+		if !req.DeepenSince.IsZero() {
+			if !caps.Supports(capability.DeepenSince) {
+				return nil, ErrDeepenSinceNotSupported
+			}
+
+			upreq.Depth = packp.DepthSince(req.DeepenSince)
+		}
+
+		if len(req.DeepenNot) > 0 {
+			if !caps.Supports(capability.DeepenNot) {
+				return nil, ErrDeepenNotNotSupported
+			}
+
+			for _, ref := range req.DeepenNot {
+				upreq.DeepenNot = append(upreq.DeepenNot, packp.DepthReference(ref))
+			}
+		}
+
 		upreq.Shallows, err = st.Shallow()
 		if err != nil {
 			return nil, err
@@ -111,27 +152,36 @@ func NegotiatePack(
 	}
 
 	// Create upload-haves
-	common := map[plumbing.Hash]struct{}{}
+	rounds := req.NegotiationRounds
+	if rounds == 0 {
+		rounds = DefaultNegotiationRounds
+	}
 
+	walker := newHaveWalker(st, req.Haves)
+	var sent, round int
 	var inVein int
 	var done bool
 	var gotContinue bool // whether we got a continue from the server
 	firstRound := true
 	for !done {
-		// Pop the last 32 or depth have commits from the pending list and
-		// insert their parents into the pending list.
-		// TODO: Properly build and implement haves negotiation, and move it
-		// from remote.go to this package.
+		// Send the next most recent commits not known to be common, along
+		// with the common ones when the server doesn't keep the state.
 		var uphav packp.UploadHaves
-		for i := 0; i < 32 && len(req.Haves) > 0; i++ {
-			uphav.Haves = append(uphav.Haves, req.Haves[len(req.Haves)-1])
-			req.Haves = req.Haves[:len(req.Haves)-1]
-			inVein++
+		if conn.StatelessRPC() {
+			uphav.Haves = walker.Common()
 		}
 
+		next := walker.Next(havesPerRound)
+		uphav.Haves = append(uphav.Haves, next...)
+		sent += len(next)
+		inVein += len(next)
+		round++
+
 		// Let the server know we're done
 		const maxInVein = 256
-		done = len(req.Haves) == 0 || (gotContinue && inVein >= maxInVein)
+		done = len(next) < havesPerRound ||
+			(rounds > 0 && round >= rounds) ||
+			(gotContinue && inVein >= maxInVein)
 		uphav.Done = done
 
 		// Note: empty request means haves are a subset of wants, in that case we have
@@ -196,9 +246,7 @@ func NegotiatePack(
 					if !gotContinue && ack.Status > 0 {
 						gotContinue = true
 					}
-					if ack.Status == packp.ACKCommon {
-						common[ack.Hash] = struct{}{}
-					}
+					walker.MarkCommon(ack.Hash)
 				}
 			}
 
@@ -219,6 +267,13 @@ func NegotiatePack(
 		}
 	}
 
+	sideband.Report(req.Progress, sideband.ProgressReport{
+		Stage:   sideband.StageNegotiating,
+		Current: uint64(walker.common),
+		Total:   uint64(sent),
+		Done:    true,
+	})
+
 	return shallowInfo, nil
 }
 
@@ -250,15 +305,14 @@ func readShallows(
 	// Decode shallow-update
 	// If depth is not zero, then we expect a shallow update from the
 	// server.
-	if (firstRound || conn.StatelessRPC()) && req.Depth > 0 {
+	if (firstRound || conn.StatelessRPC()) && req.isShallow() {
 		var shupd packp.ShallowUpdate
 		if err := shupd.Decode(r); err != nil {
 			return fmt.Errorf("decoding shallow-update: %w", err)
 		}
 
 		// Only return the first shallow update
-		if shallowInfo == nil {
-			shallowInfo = new(*packp.ShallowUpdate)
+		if *shallowInfo == nil {
 			*shallowInfo = &shupd
 		}
 	}
@@ -0,0 +1,209 @@
+package transport
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/go-git/go-git/v6/plumbing/format/packfile"
+	"github.com/go-git/go-git/v6/plumbing/format/pktline"
+	"github.com/go-git/go-git/v6/plumbing/object"
+	"github.com/go-git/go-git/v6/plumbing/protocol/packp/capability"
+	"github.com/go-git/go-git/v6/storage"
+	"github.com/go-git/go-git/v6/storage/memory"
+	"github.com/go-git/go-git/v6/utils/ioutil"
+	"github.com/stretchr/testify/suite"
+)
+
+func TestNegotiateSuite(t *testing.T) {
+	suite.Run(t, new(NegotiateSuite))
+}
+
+type NegotiateSuite struct {
+	suite.Suite
+}
+
+// scriptedConnection returns a connection to a server replying with the
+// given script, writing the request sent to it to req.
+func (s *NegotiateSuite) scriptedConnection(script []byte, req *bytes.Buffer, caps ...capability.Capability) *packConnection {
+	list := capability.NewList()
+	for _, c := range caps {
+		s.Require().NoError(list.Set(c))
+	}
+
+	return &packConnection{
+		st:   memory.NewStorage(),
+		w:    ioutil.WriteNopCloser(req),
+		r:    bufio.NewReader(bytes.NewReader(script)),
+		caps: list,
+	}
+}
+
+func (s *NegotiateSuite) TestFetchShallowSinceAndExclude() {
+	server := memory.NewStorage()
+	obj := server.NewEncodedObject()
+	obj.SetType(plumbing.BlobObject)
+	w, err := obj.Writer()
+	s.Require().NoError(err)
+	_, err = w.Write([]byte("foo"))
+	s.Require().NoError(err)
+	s.Require().NoError(w.Close())
+	want, err := server.SetEncodedObject(obj)
+	s.Require().NoError(err)
+
+	boundary := plumbing.NewHash("6ecf0ef2c2dffb796033e5a02219af86ec6584e5")
+
+	var script bytes.Buffer
+	_, err = pktline.Writef(&script, "shallow %s\n", boundary)
+	s.Require().NoError(err)
+	s.Require().NoError(pktline.WriteFlush(&script))
+	_, err = pktline.Writeln(&script, "NAK")
+	s.Require().NoError(err)
+	_, err = packfile.NewEncoder(&script, server, false).Encode([]plumbing.Hash{want}, 10)
+	s.Require().NoError(err)
+
+	var req bytes.Buffer
+	conn := s.scriptedConnection(script.Bytes(), &req,
+		capability.Shallow, capability.DeepenSince, capability.DeepenNot,
+	)
+
+	since := time.Date(2015, time.January, 2, 3, 4, 5, 0, time.UTC)
+	err = conn.Fetch(context.Background(), &FetchRequest{
+		Wants:       []plumbing.Hash{want},
+		DeepenSince: since,
+		DeepenNot:   []string{"refs/tags/v1", "refs/heads/old"},
+	})
+	s.Require().NoError(err)
+
+	sent := req.String()
+	s.Contains(sent, fmt.Sprintf("deepen-since %d\n", since.Unix()))
+	s.Contains(sent, "deepen-not refs/tags/v1\n")
+	s.Contains(sent, "deepen-not refs/heads/old\n")
+	s.Contains(sent, capability.DeepenSince.String())
+	s.NotContains(sent, "deepen ")
+	s.Less(strings.Index(sent, "deepen-since"), strings.Index(sent, "deepen-not"))
+
+	shallows, err := conn.st.Shallow()
+	s.NoError(err)
+	s.Equal([]plumbing.Hash{boundary}, shallows)
+
+	_, err = conn.st.EncodedObject(plumbing.BlobObject, want)
+	s.NoError(err)
+}
+
+func (s *NegotiateSuite) TestFetchShallowSinceErrors() {
+	want := plumbing.NewHash("6ecf0ef2c2dffb796033e5a02219af86ec6584e5")
+	since := time.Date(2015, time.January, 2, 3, 4, 5, 0, time.UTC)
+
+	var req bytes.Buffer
+	conn := s.scriptedConnection(nil, &req,
+		capability.Shallow, capability.DeepenSince, capability.DeepenNot,
+	)
+
+	err := conn.Fetch(context.Background(), &FetchRequest{
+		Wants:       []plumbing.Hash{want},
+		Depth:       1,
+		DeepenSince: since,
+	})
+	s.ErrorIs(err, ErrDepthDeepenExclusive)
+
+	err = conn.Fetch(context.Background(), &FetchRequest{
+		Wants:     []plumbing.Hash{want},
+		Depth:     1,
+		DeepenNot: []string{"refs/tags/v1"},
+	})
+	s.ErrorIs(err, ErrDepthDeepenExclusive)
+
+	conn = s.scriptedConnection(nil, &req, capability.Shallow)
+	err = conn.Fetch(context.Background(), &FetchRequest{
+		Wants:       []plumbing.Hash{want},
+		DeepenSince: since,
+	})
+	s.ErrorIs(err, ErrDeepenSinceNotSupported)
+
+	err = conn.Fetch(context.Background(), &FetchRequest{
+		Wants:     []plumbing.Hash{want},
+		DeepenNot: []string{"refs/tags/v1"},
+	})
+	s.ErrorIs(err, ErrDeepenNotNotSupported)
+
+	s.Empty(req.String())
+}
+
+func (s *NegotiateSuite) TestFetchNegotiationRounds() {
+	server := memory.NewStorage()
+	obj := server.NewEncodedObject()
+	obj.SetType(plumbing.BlobObject)
+	w, err := obj.Writer()
+	s.Require().NoError(err)
+	_, err = w.Write([]byte("foo"))
+	s.Require().NoError(err)
+	s.Require().NoError(w.Close())
+	want, err := server.SetEncodedObject(obj)
+	s.Require().NoError(err)
+
+	fetch := func(rounds, naks int) int {
+		// The server doesn't know any of the haves, the client gives up
+		// once the rounds are exhausted or its history is fully sent.
+		var script bytes.Buffer
+		for i := 0; i < naks; i++ {
+			_, err := pktline.Writeln(&script, "NAK")
+			s.Require().NoError(err)
+		}
+		_, err := packfile.NewEncoder(&script, server, false).Encode([]plumbing.Hash{want}, 10)
+		s.Require().NoError(err)
+
+		var req bytes.Buffer
+		conn := s.scriptedConnection(script.Bytes(), &req)
+		head := storeHistory(s.T(), conn.st, 10*havesPerRound)
+
+		err = conn.Fetch(context.Background(), &FetchRequest{
+			Wants:             []plumbing.Hash{want},
+			Haves:             []plumbing.Hash{head},
+			NegotiationRounds: rounds,
+		})
+		s.Require().NoError(err)
+		return strings.Count(req.String(), "have ")
+	}
+
+	s.Equal(DefaultNegotiationRounds*havesPerRound, fetch(0, DefaultNegotiationRounds))
+	s.Equal(2*havesPerRound, fetch(2, 2))
+	// The last round, without haves, only says done.
+	s.Equal(10*havesPerRound, fetch(-1, 11))
+}
+
+// storeHistory stores a linear history of n commits, returning the most
+// recent one.
+func storeHistory(t *testing.T, st storage.Storer, n int) plumbing.Hash {
+	when := time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC)
+	var parents []plumbing.Hash
+	for i := 0; i < n; i++ {
+		sig := object.Signature{Name: "foo", Email: "foo@foo.foo", When: when.Add(time.Duration(i) * time.Minute)}
+		c := &object.Commit{
+			Author:       sig,
+			Committer:    sig,
+			Message:      fmt.Sprintf("commit %d\n", i),
+			TreeHash:     plumbing.NewHash("4b825dc642cb6eb9a060e54bf8d69288fbee4904"),
+			ParentHashes: parents,
+		}
+
+		obj := st.NewEncodedObject()
+		if err := c.Encode(obj); err != nil {
+			t.Fatal(err)
+		}
+
+		h, err := st.SetEncodedObject(obj)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		parents = []plumbing.Hash{h}
+	}
+
+	return parents[0]
+}
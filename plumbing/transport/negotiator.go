@@ -0,0 +1,164 @@
+package transport
+
+import (
+	"container/heap"
+
+	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/go-git/go-git/v6/plumbing/object"
+	"github.com/go-git/go-git/v6/plumbing/storer"
+)
+
+const (
+	// havesPerRound is the number of haves sent to the server on each round
+	// of the negotiation.
+	havesPerRound = 32
+	// DefaultNegotiationRounds is the maximum number of rounds of haves sent
+	// during the negotiation when not set in the FetchRequest.
+	DefaultNegotiationRounds = 8
+)
+
+// haveWalker walks the history of the local references, returning the commits
+// to be sent as haves most recent first. Once the server acknowledges a
+// commit as common, none of its known ancestors are returned anymore, since
+// the server has them too.
+type haveWalker struct {
+	s       storer.EncodedObjectStorer
+	commits map[plumbing.Hash]*haveCommit
+	pending haveQueue
+	// others are the tips that aren't commits, sent before any commit.
+	others []plumbing.Hash
+	common int
+}
+
+type haveCommit struct {
+	*object.Commit
+	common bool
+	sent   bool
+}
+
+func newHaveWalker(s storer.EncodedObjectStorer, tips []plumbing.Hash) *haveWalker {
+	w := &haveWalker{s: s, commits: make(map[plumbing.Hash]*haveCommit)}
+	for _, h := range tips {
+		if _, ok := w.commits[h]; ok {
+			continue
+		}
+
+		if !w.push(h) {
+			w.others = append(w.others, h)
+		}
+	}
+
+	return w
+}
+
+// push adds the commit with the given hash to the pending list, returning
+// false if it's not a commit found in the storer.
+func (w *haveWalker) push(h plumbing.Hash) bool {
+	if _, ok := w.commits[h]; ok {
+		return true
+	}
+
+	c, err := object.GetCommit(w.s, h)
+	if err != nil {
+		// The parents of a shallow commit are missing, and the tips may be
+		// any other kind of object.
+		return false
+	}
+
+	hc := &haveCommit{Commit: c}
+	w.commits[h] = hc
+	heap.Push(&w.pending, hc)
+	return true
+}
+
+// Next returns up to n haves, most recent first. An empty result means there
+// are no more commits to send.
+func (w *haveWalker) Next(n int) []plumbing.Hash {
+	var haves []plumbing.Hash
+	for len(haves) < n && len(w.others) > 0 {
+		haves = append(haves, w.others[0])
+		w.others = w.others[1:]
+	}
+
+	for len(haves) < n && w.pending.Len() > 0 {
+		c := heap.Pop(&w.pending).(*haveCommit)
+		if c.common {
+			// The ancestors of a common commit are known to be common
+			// too, there is no need to walk them.
+			continue
+		}
+
+		for _, p := range c.ParentHashes {
+			w.push(p)
+		}
+
+		c.sent = true
+		haves = append(haves, c.Hash)
+	}
+
+	return haves
+}
+
+// MarkCommon marks the given commit and its known ancestors as common with
+// the server.
+func (w *haveWalker) MarkCommon(h plumbing.Hash) {
+	c, ok := w.commits[h]
+	if !ok || c.common {
+		return
+	}
+
+	if c.sent {
+		w.common++
+	}
+
+	w.markCommon(h)
+}
+
+func (w *haveWalker) markCommon(h plumbing.Hash) {
+	queue := []plumbing.Hash{h}
+	for len(queue) > 0 {
+		c, ok := w.commits[queue[0]]
+		queue = queue[1:]
+		if !ok || c.common {
+			continue
+		}
+
+		c.common = true
+		queue = append(queue, c.ParentHashes...)
+	}
+}
+
+// Common returns the haves acknowledged as common by the server.
+func (w *haveWalker) Common() []plumbing.Hash {
+	var common []plumbing.Hash
+	for h, c := range w.commits {
+		if c.common && c.sent {
+			common = append(common, h)
+		}
+	}
+
+	return common
+}
+
+// haveQueue is a priority queue of commits ordered by committer time, most
+// recent first.
+type haveQueue []*haveCommit
+
+func (q haveQueue) Len() int { return len(q) }
+
+func (q haveQueue) Less(i, j int) bool {
+	return q[i].Committer.When.After(q[j].Committer.When)
+}
+
+func (q haveQueue) Swap(i, j int) { q[i], q[j] = q[j], q[i] }
+
+func (q *haveQueue) Push(x any) { *q = append(*q, x.(*haveCommit)) }
+
+func (q *haveQueue) Pop() any {
+	old := *q
+	n := len(old)
+	c := old[n-1]
+	old[n-1] = nil
+	*q = old[:n-1]
+	return c
+}
@@ -0,0 +1,85 @@
+package transport
+
+import (
+	"testing"
+
+	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/go-git/go-git/v6/plumbing/cache"
+	"github.com/go-git/go-git/v6/plumbing/object"
+	"github.com/go-git/go-git/v6/storage/filesystem"
+	"github.com/stretchr/testify/suite"
+
+	fixtures "github.com/go-git/go-git-fixtures/v5"
+)
+
+type HaveWalkerSuite struct {
+	suite.Suite
+	st *filesystem.Storage
+}
+
+func TestHaveWalkerSuite(t *testing.T) {
+	suite.Run(t, new(HaveWalkerSuite))
+}
+
+func (s *HaveWalkerSuite) SetupTest() {
+	s.st = filesystem.NewStorage(fixtures.Basic().One().DotGit(), cache.NewObjectLRUDefault())
+}
+
+var (
+	haveMaster = plumbing.NewHash("6ecf0ef2c2dffb796033e5a02219af86ec6584e5")
+	haveBranch = plumbing.NewHash("e8d3ffab552895c19b9fcf7aa264d277cde33881")
+	haveBase   = plumbing.NewHash("918c48b83bd081e863dbe1b80f8998f058cd8294")
+)
+
+func (s *HaveWalkerSuite) TestNextMostRecentFirst() {
+	w := newHaveWalker(s.st, []plumbing.Hash{haveBranch, haveMaster})
+
+	var haves []plumbing.Hash
+	for {
+		next := w.Next(2)
+		s.LessOrEqual(len(next), 2)
+		if len(next) == 0 {
+			break
+		}
+
+		haves = append(haves, next...)
+	}
+
+	s.Equal(haveMaster, haves[0])
+	s.Len(haves, 9)
+
+	for i := 1; i < len(haves); i++ {
+		prev, err := object.GetCommit(s.st, haves[i-1])
+		s.Require().NoError(err)
+		c, err := object.GetCommit(s.st, haves[i])
+		s.Require().NoError(err)
+		s.False(c.Committer.When.After(prev.Committer.When))
+	}
+}
+
+func (s *HaveWalkerSuite) TestMarkCommon() {
+	w := newHaveWalker(s.st, []plumbing.Hash{haveMaster, haveBranch})
+	s.Equal([]plumbing.Hash{haveMaster, haveBranch}, w.Next(2))
+
+	// The parent of both tips is common, so is all its history.
+	w.MarkCommon(haveBranch)
+	s.Equal([]plumbing.Hash{haveBranch}, w.Common())
+	s.Empty(w.Next(havesPerRound))
+}
+
+func (s *HaveWalkerSuite) TestMarkCommonPending() {
+	w := newHaveWalker(s.st, []plumbing.Hash{haveMaster})
+	s.Equal([]plumbing.Hash{haveMaster, haveBase}, w.Next(2))
+
+	w.MarkCommon(haveBase)
+	s.Empty(w.Next(havesPerRound))
+	s.Equal([]plumbing.Hash{haveBase}, w.Common())
+}
+
+func (s *HaveWalkerSuite) TestNonCommitTips() {
+	tree := plumbing.NewHash("a8d315b2b1c615d43042c3a62402b8a54288cf5c")
+	missing := plumbing.NewHash("0000000000000000000000000000000000000001")
+
+	w := newHaveWalker(s.st, []plumbing.Hash{haveMaster, tree, missing})
+	s.Equal([]plumbing.Hash{tree, missing, haveMaster}, w.Next(3))
+}
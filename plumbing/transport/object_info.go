@@ -0,0 +1,59 @@
+package transport
+
+import (
+	"context"
+	"io"
+
+	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/go-git/go-git/v6/plumbing/protocol"
+	"github.com/go-git/go-git/v6/plumbing/protocol/packp"
+	"github.com/go-git/go-git/v6/plumbing/protocol/packp/capability"
+	"github.com/go-git/go-git/v6/utils/ioutil"
+)
+
+// ObjectSize holds the size of an object reported by the server.
+type ObjectSize = packp.ObjectSize
+
+// ObjectInfo sends an object-info command request, asking for the size of
+// the given objects, and reads the response. The writer is closed after the
+// request when the connection is stateless, to send it.
+//
+// ErrObjectInfoNotSupported is returned if the connection doesn't use
+// protocol v2, or the server doesn't advertise the object-info command.
+func ObjectInfo(
+	ctx context.Context,
+	conn Connection,
+	reader io.Reader,
+	writer io.WriteCloser,
+	hashes []plumbing.Hash,
+) ([]ObjectSize, error) {
+	caps := conn.Capabilities()
+	if conn.Version() != protocol.V2 || caps == nil || !caps.Supports(capability.ObjectInfo) {
+		return nil, ErrObjectInfoNotSupported
+	}
+
+	reader = ioutil.NewContextReader(ctx, reader)
+	writer = ioutil.NewContextWriteCloser(ctx, writer)
+
+	req := &packp.ObjectInfoRequest{Hashes: hashes}
+	if caps.Supports(capability.Agent) {
+		req.Agent = capability.DefaultAgent()
+	}
+
+	if err := req.Encode(writer); err != nil {
+		return nil, err
+	}
+
+	if conn.StatelessRPC() {
+		if err := writer.Close(); err != nil {
+			return nil, err
+		}
+	}
+
+	var res packp.ObjectInfoResponse
+	if err := res.Decode(reader); err != nil {
+		return nil, err
+	}
+
+	return res.Sizes, nil
+}
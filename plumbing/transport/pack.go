@@ -105,7 +105,12 @@ func (p *PackSession) Handshake(ctx context.Context, service Service, params ...
 
 	switch c.version {
 	case protocol.V2:
-		return nil, ErrUnsupportedVersion
+		c.caps, err = packp.DecodeCapabilitiesV2(c.r)
+		if err != nil {
+			return nil, err
+		}
+
+		return c, nil
 	case protocol.V1:
 		// Read the version line
 		fallthrough
@@ -162,6 +167,10 @@ func (p *packConnection) Capabilities() *capability.List {
 
 // GetRemoteRefs implements Connection.
 func (p *packConnection) GetRemoteRefs(ctx context.Context) ([]*plumbing.Reference, error) {
+	if p.version == protocol.V2 {
+		return nil, ErrUnsupportedVersion
+	}
+
 	if p.refs == nil {
 		// TODO: return appropriate error
 		return nil, ErrEmptyRemoteRepository
@@ -190,6 +199,10 @@ func (*packConnection) StatelessRPC() bool {
 
 // Fetch implements Connection.
 func (p *packConnection) Fetch(ctx context.Context, req *FetchRequest) (err error) {
+	if p.version == protocol.V2 {
+		return ErrUnsupportedVersion
+	}
+
 	shallows, err := NegotiatePack(ctx, p.st, p, p.r, p.w, req)
 	if err != nil {
 		return err
@@ -200,9 +213,18 @@ func (p *packConnection) Fetch(ctx context.Context, req *FetchRequest) (err erro
 
 // Push implements Connection.
 func (p *packConnection) Push(ctx context.Context, req *PushRequest) (err error) {
+	if p.version == protocol.V2 {
+		return ErrUnsupportedVersion
+	}
+
 	return SendPack(ctx, p.st, p, p.w, io.NopCloser(p.r), req)
 }
 
+// ObjectInfo implements Connection.
+func (p *packConnection) ObjectInfo(ctx context.Context, hashes []plumbing.Hash) ([]ObjectSize, error) {
+	return ObjectInfo(ctx, p, p.r, p.w, hashes)
+}
+
 // checkError checks if the error is not nil updates the pointer with the
 // error.
 func checkError(err error, perr *error) {
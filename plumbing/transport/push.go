@@ -2,6 +2,7 @@ package transport
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 
@@ -12,6 +13,18 @@ import (
 	"github.com/go-git/go-git/v6/utils/ioutil"
 )
 
+var (
+	// ErrAtomicPushNotSupported is returned when an atomic push is requested
+	// but the server doesn't advertise the atomic capability.
+	ErrAtomicPushNotSupported = errors.New("server does not support atomic pushes")
+	// ErrPushOptionsNotSupported is returned when push options are given but
+	// the server doesn't advertise the push-options capability.
+	ErrPushOptionsNotSupported = errors.New("server does not support push options")
+	// ErrPushCertNotSupported is returned when a signed push is requested but
+	// the server doesn't advertise the push-cert capability with a nonce.
+	ErrPushCertNotSupported = errors.New("server does not support signed pushes")
+)
+
 // buildUpdateRequests constructs a new update-requests object for the given
 // connection and push request.
 func buildUpdateRequests(caps *capability.List, req *PushRequest) *packp.UpdateRequests {
@@ -43,6 +56,7 @@ func buildUpdateRequests(caps *capability.List, req *PushRequest) *packp.UpdateR
 	}
 
 	upreq.Commands = req.Commands
+	upreq.Certificate = req.Certificate
 
 	return upreq
 }
@@ -75,8 +89,20 @@ func SendPack(
 	}
 
 	caps := conn.Capabilities()
+	if req.Atomic && !caps.Supports(capability.Atomic) {
+		return ErrAtomicPushNotSupported
+	}
+
+	usePushOptions := len(req.Options) > 0
+	if usePushOptions && !caps.Supports(capability.PushOptions) {
+		return ErrPushOptionsNotSupported
+	}
+
+	if req.Certificate != nil && !caps.Supports(capability.PushCert) {
+		return ErrPushCertNotSupported
+	}
+
 	upreq := buildUpdateRequests(caps, req)
-	usePushOptions := len(req.Options) > 0 && caps.Supports(capability.PushOptions)
 	if usePushOptions {
 		upreq.Capabilities.Set(capability.PushOptions) //nolint:errcheck
 	}
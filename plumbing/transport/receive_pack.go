@@ -3,12 +3,14 @@ package transport
 import (
 	"bufio"
 	"context"
+	"errors"
 	"fmt"
 	"io"
 
 	"github.com/go-git/go-git/v6/plumbing"
 	"github.com/go-git/go-git/v6/plumbing/format/packfile"
 	"github.com/go-git/go-git/v6/plumbing/format/pktline"
+	"github.com/go-git/go-git/v6/plumbing/object"
 	"github.com/go-git/go-git/v6/plumbing/protocol"
 	"github.com/go-git/go-git/v6/plumbing/protocol/packp"
 	"github.com/go-git/go-git/v6/plumbing/protocol/packp/capability"
@@ -45,18 +47,18 @@ func ReceivePack(
 	}
 
 	if opts.AdvertiseRefs || !opts.StatelessRPC {
-		switch version := ProtocolVersion(opts.GitProtocol); version {
-		case protocol.V1:
-			if _, err := pktline.Writef(w, "version %d\n", version); err != nil {
-				return err
-			}
-		// TODO: support version 2
-		case protocol.V0, protocol.V2:
+		version := ProtocolVersion(opts.GitProtocol)
+		switch version {
+		case protocol.V0, protocol.V1:
+		case protocol.V2:
+			// git-receive-pack doesn't support version 2, git answers using
+			// version 0.
+			version = protocol.V0
 		default:
 			return fmt.Errorf("%w: %q", ErrUnsupportedVersion, version)
 		}
 
-		if err := AdvertiseReferences(ctx, st, w, ReceivePackService, opts.StatelessRPC); err != nil {
+		if err := advertiseReferences(ctx, st, w, ReceivePackService, version, opts.StatelessRPC); err != nil {
 			return err
 		}
 	}
@@ -114,6 +116,10 @@ func ReceivePack(
 	var unpackErr error
 	if needPackfile {
 		unpackErr = packfile.UpdateObjectStorage(st, rd)
+		// The packfile has no objects when the server already has them.
+		if errors.Is(unpackErr, packfile.ErrEmptyPackfile) {
+			unpackErr = nil
+		}
 	}
 
 	// Done with the request, now close the reader
@@ -131,14 +137,14 @@ func ReceivePack(
 		useSideband bool
 		writer      io.Writer = w
 	)
-	if !caps.Supports(capability.NoProgress) {
-		if caps.Supports(capability.Sideband64k) {
-			writer = sideband.NewMuxer(sideband.Sideband64k, w)
-			useSideband = true
-		} else if caps.Supports(capability.Sideband) {
-			writer = sideband.NewMuxer(sideband.Sideband, w)
-			useSideband = true
-		}
+	// The report is multiplexed whenever the client asks for it, even with
+	// no-progress, which only disables the progress messages.
+	if caps.Supports(capability.Sideband64k) {
+		writer = sideband.NewMuxer(sideband.Sideband64k, w)
+		useSideband = true
+	} else if caps.Supports(capability.Sideband) {
+		writer = sideband.NewMuxer(sideband.Sideband, w)
+		useSideband = true
 	}
 
 	writeCloser := ioutil.NewWriteCloser(writer, w)
@@ -148,11 +154,16 @@ func ReceivePack(
 		return res
 	}
 
+	rc, err := loadReceiveConfig(st)
+	if err != nil {
+		return err
+	}
+
 	var firstErr error
 	cmdStatus := make(map[plumbing.ReferenceName]error)
-	updateReferences(st, updreq, cmdStatus, &firstErr)
+	updateReferences(st, rc, updreq, cmdStatus, &firstErr)
 
-	if err := sendReportStatus(writeCloser, firstErr, cmdStatus); err != nil {
+	if err := sendReportStatus(writeCloser, nil, cmdStatus); err != nil {
 		return err
 	}
 
@@ -216,41 +227,209 @@ func referenceExists(s storer.ReferenceStorer, n plumbing.ReferenceName) (bool,
 	return err == nil, err
 }
 
-func updateReferences(st storage.Storer, req *packp.UpdateRequests, cmdStatus map[plumbing.ReferenceName]error, firstErr *error) {
+func updateReferences(st storage.Storer, rc *receiveConfig, req *packp.UpdateRequests, cmdStatus map[plumbing.ReferenceName]error, firstErr *error) {
+	if req.Capabilities.Supports(capability.Atomic) {
+		updateReferencesAtomic(st, rc, req.Commands, cmdStatus, firstErr)
+		return
+	}
+
 	for _, cmd := range req.Commands {
-		exists, err := referenceExists(st, cmd.Name)
-		if err != nil {
-			setStatus(cmdStatus, firstErr, cmd.Name, err)
-			continue
+		err := checkCommand(st, rc, cmd)
+		if err == nil {
+			err = applyCommand(st, cmd)
 		}
 
-		switch cmd.Action() {
-		case packp.Create:
-			if exists {
-				setStatus(cmdStatus, firstErr, cmd.Name, ErrUpdateReference)
-				continue
-			}
+		setStatus(cmdStatus, firstErr, cmd.Name, err)
+	}
+}
 
-			ref := plumbing.NewHashReference(cmd.Name, cmd.New)
-			err := st.SetReference(ref)
-			setStatus(cmdStatus, firstErr, cmd.Name, err)
-		case packp.Delete:
-			if !exists {
-				setStatus(cmdStatus, firstErr, cmd.Name, ErrUpdateReference)
-				continue
+// errAtomicPushFailure is the status of the references not updated because
+// the update of another one of an atomic push failed.
+var errAtomicPushFailure = errors.New(packp.AtomicPushFailureStatus)
+
+// updateReferencesAtomic updates all the references or none of them. Every
+// command is checked before updating any reference, and the references
+// already updated are restored if an update fails anyway.
+func updateReferencesAtomic(st storage.Storer, rc *receiveConfig, cmds []*packp.Command, cmdStatus map[plumbing.ReferenceName]error, firstErr *error) {
+	fail := func(failed *packp.Command, err error) {
+		setStatus(cmdStatus, firstErr, failed.Name, err)
+		for _, cmd := range cmds {
+			if cmd != failed {
+				setStatus(cmdStatus, firstErr, cmd.Name, errAtomicPushFailure)
 			}
+		}
+	}
 
-			err := st.RemoveReference(cmd.Name)
-			setStatus(cmdStatus, firstErr, cmd.Name, err)
-		case packp.Update:
-			if !exists {
-				setStatus(cmdStatus, firstErr, cmd.Name, ErrUpdateReference)
-				continue
+	for _, cmd := range cmds {
+		if err := checkCommand(st, rc, cmd); err != nil {
+			fail(cmd, err)
+			return
+		}
+	}
+
+	previous := make([]*plumbing.Reference, 0, len(cmds))
+	for i, cmd := range cmds {
+		old, err := st.Reference(cmd.Name)
+		if err != nil && err != plumbing.ErrReferenceNotFound {
+			fail(cmd, err)
+			return
+		}
+
+		if err := applyCommand(st, cmd); err != nil {
+			for j := i - 1; j >= 0; j-- {
+				_ = restoreReference(st, cmds[j].Name, previous[j])
 			}
 
-			ref := plumbing.NewHashReference(cmd.Name, cmd.New)
-			err := st.SetReference(ref)
-			setStatus(cmdStatus, firstErr, cmd.Name, err)
+			fail(cmd, err)
+			return
 		}
+
+		previous = append(previous, old)
+	}
+
+	for _, cmd := range cmds {
+		setStatus(cmdStatus, firstErr, cmd.Name, nil)
+	}
+}
+
+// checkCommand returns an error if the reference can't be updated by the
+// given command, because it already exists or doesn't exist, or because the
+// receive.* config of the repository denies it.
+func checkCommand(st storage.Storer, rc *receiveConfig, cmd *packp.Command) error {
+	exists, err := referenceExists(st, cmd.Name)
+	if err != nil {
+		return err
+	}
+
+	if exists == (cmd.Action() == packp.Create) {
+		return ErrUpdateReference
+	}
+
+	return rc.check(st, cmd)
+}
+
+var (
+	// ErrDeletionProhibited is the status of the deletion of a branch
+	// rejected because of receive.denyDeletes.
+	ErrDeletionProhibited = errors.New("deletion prohibited")
+	// ErrNonFastForward is the status of the update of a branch to a commit
+	// not descending from the current one, rejected because of
+	// receive.denyNonFastForwards.
+	ErrNonFastForward = errors.New("non-fast-forward")
+	// ErrBranchCheckedOut is the status of the update of the branch checked
+	// out in a non-bare repository, rejected because of
+	// receive.denyCurrentBranch.
+	ErrBranchCheckedOut = errors.New("branch is currently checked out")
+
+	// errBadRef is the status of the update of a branch which can't be
+	// checked to be a fast-forward, as it isn't between commits.
+	errBadRef = errors.New("bad ref")
+)
+
+// receiveConfig holds the receive.* config of the repository the references
+// are pushed to, enforced on every reference update.
+type receiveConfig struct {
+	denyDeletes         bool
+	denyNonFastForwards bool
+	// current is the branch checked out in a non-bare repository whose
+	// updates are denied by receive.denyCurrentBranch, if any.
+	current plumbing.ReferenceName
+}
+
+// loadReceiveConfig reads the receive.* config from the given storage, along
+// with the branch checked out when updating it is denied.
+func loadReceiveConfig(st storage.Storer) (*receiveConfig, error) {
+	cfg, err := st.Config()
+	if err != nil {
+		return nil, err
 	}
+
+	rc := &receiveConfig{
+		denyDeletes:         cfg.Receive.DenyDeletes,
+		denyNonFastForwards: cfg.Receive.DenyNonFastForwards,
+	}
+
+	if cfg.Core.IsBare {
+		return rc, nil
+	}
+
+	switch cfg.Receive.DenyCurrentBranch {
+	case "warn", "ignore", "false":
+		return rc, nil
+	}
+
+	head, err := st.Reference(plumbing.HEAD)
+	if err == plumbing.ErrReferenceNotFound {
+		return rc, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if head.Type() == plumbing.SymbolicReference {
+		rc.current = head.Target()
+	}
+
+	return rc, nil
+}
+
+// check returns the error the update of a reference by the given command is
+// rejected with, if any, as git does.
+func (rc *receiveConfig) check(st storage.Storer, cmd *packp.Command) error {
+	action := cmd.Action()
+	if action != packp.Delete && cmd.Name == rc.current {
+		return ErrBranchCheckedOut
+	}
+
+	if !cmd.Name.IsBranch() {
+		return nil
+	}
+
+	if action == packp.Delete && rc.denyDeletes {
+		return ErrDeletionProhibited
+	}
+
+	if action != packp.Update || !rc.denyNonFastForwards {
+		return nil
+	}
+
+	oldCommit, err := object.GetCommit(st, cmd.Old)
+	if err != nil {
+		return errBadRef
+	}
+
+	newCommit, err := object.GetCommit(st, cmd.New)
+	if err != nil {
+		return errBadRef
+	}
+
+	ff, err := oldCommit.IsAncestor(newCommit)
+	if err != nil {
+		return err
+	}
+
+	if !ff {
+		return ErrNonFastForward
+	}
+
+	return nil
+}
+
+func applyCommand(st storage.Storer, cmd *packp.Command) error {
+	if cmd.Action() == packp.Delete {
+		return st.RemoveReference(cmd.Name)
+	}
+
+	return st.SetReference(plumbing.NewHashReference(cmd.Name, cmd.New))
+}
+
+// restoreReference sets the reference with the given name back to its
+// previous value, removing it if it didn't exist.
+func restoreReference(st storage.Storer, name plumbing.ReferenceName, previous *plumbing.Reference) error {
+	if previous == nil {
+		return st.RemoveReference(name)
+	}
+
+	return st.SetReference(previous)
 }
@@ -1,8 +1,16 @@
 package transport
 
 import (
+	"bytes"
+	"context"
+	"io"
 	"testing"
 
+	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/go-git/go-git/v6/plumbing/object"
+	"github.com/go-git/go-git/v6/plumbing/protocol/packp"
+	"github.com/go-git/go-git/v6/plumbing/protocol/packp/capability"
+	"github.com/go-git/go-git/v6/storage/memory"
 	"github.com/stretchr/testify/suite"
 )
 
@@ -19,11 +27,202 @@ func (s *ReceivePackSuite) TestReceivePackAdvertiseV0() {
 }
 
 func (s *ReceivePackSuite) TestReceivePackAdvertiseV2() {
-	// TODO: support version 2
-	testAdvertise(s.T(), UploadPack, "version=2", false)
+	buf := testAdvertise(s.T(), ReceivePack, "version=2", false)
+	s.NotContains(buf.String(), "version 2", "advertisement should use version 0")
 }
 
 func (s *ReceivePackSuite) TestReceivePackAdvertiseV1() {
 	buf := testAdvertise(s.T(), ReceivePack, "version=1", false)
 	s.Containsf(buf.String(), "version 1", "advertisement should contain version 1")
 }
+
+// receivePack runs ReceivePack with the given commands, deleting the
+// references, and returns the report status sent.
+func (s *ReceivePackSuite) receivePack(st *memory.Storage, atomic bool, cmds ...*packp.Command) *packp.ReportStatus {
+	req := packp.NewUpdateRequests()
+	s.Require().NoError(req.Capabilities.Set(capability.ReportStatus))
+	if atomic {
+		s.Require().NoError(req.Capabilities.Set(capability.Atomic))
+	}
+	req.Commands = cmds
+
+	var in bytes.Buffer
+	s.Require().NoError(req.Encode(&in))
+
+	out := newMockRWC(nil)
+	_ = ReceivePack(context.TODO(), st, io.NopCloser(&in), out, &ReceivePackOptions{StatelessRPC: true})
+
+	report := packp.NewReportStatus()
+	s.Require().NoError(report.Decode(out.writeBuf))
+	return report
+}
+
+func (s *ReceivePackSuite) TestReceivePackAtomic() {
+	h := plumbing.NewHash("6ecf0ef2c2dffb796033e5a02219af86ec6584e5")
+	for _, atomic := range []bool{false, true} {
+		st := memory.NewStorage()
+		s.Require().NoError(st.SetReference(plumbing.NewHashReference("refs/heads/foo", h)))
+
+		report := s.receivePack(st, atomic,
+			&packp.Command{Name: "refs/heads/foo", Old: h, New: plumbing.ZeroHash},
+			&packp.Command{Name: "refs/heads/bar", Old: h, New: plumbing.ZeroHash},
+		)
+
+		var cmdErr packp.CommandStatusErr
+		s.Require().ErrorAs(report.Error(), &cmdErr)
+		s.Equal(plumbing.ReferenceName("refs/heads/bar"), cmdErr.ReferenceName)
+		s.Equal(ErrUpdateReference.Error(), cmdErr.Status)
+
+		_, err := st.Reference("refs/heads/foo")
+		if atomic {
+			s.NoError(err)
+		} else {
+			s.ErrorIs(err, plumbing.ErrReferenceNotFound)
+		}
+	}
+}
+
+func (s *ReceivePackSuite) TestReceivePackAtomicSuccess() {
+	h := plumbing.NewHash("6ecf0ef2c2dffb796033e5a02219af86ec6584e5")
+	st := memory.NewStorage()
+	s.Require().NoError(st.SetReference(plumbing.NewHashReference("refs/heads/foo", h)))
+	s.Require().NoError(st.SetReference(plumbing.NewHashReference("refs/heads/bar", h)))
+
+	report := s.receivePack(st, true,
+		&packp.Command{Name: "refs/heads/foo", Old: h, New: plumbing.ZeroHash},
+		&packp.Command{Name: "refs/heads/bar", Old: h, New: plumbing.ZeroHash},
+	)
+	s.NoError(report.Error())
+	s.Len(report.CommandStatuses, 2)
+
+	_, err := st.Reference("refs/heads/foo")
+	s.ErrorIs(err, plumbing.ErrReferenceNotFound)
+	_, err = st.Reference("refs/heads/bar")
+	s.ErrorIs(err, plumbing.ErrReferenceNotFound)
+}
+
+// storeCommit stores a commit with the given parents and an empty tree.
+func (s *ReceivePackSuite) storeCommit(st *memory.Storage, msg string, parents ...plumbing.Hash) plumbing.Hash {
+	c := &object.Commit{
+		Message:      msg,
+		TreeHash:     plumbing.NewHash("4b825dc642cb6eb9a060e54bf8d69288fbee4904"),
+		ParentHashes: parents,
+	}
+
+	obj := st.NewEncodedObject()
+	s.Require().NoError(c.Encode(obj))
+	h, err := st.SetEncodedObject(obj)
+	s.Require().NoError(err)
+	return h
+}
+
+func (s *ReceivePackSuite) TestReceivePackDenyDeletes() {
+	st := memory.NewStorage()
+	h := s.storeCommit(st, "foo")
+	s.Require().NoError(st.SetReference(plumbing.NewHashReference("refs/heads/foo", h)))
+	s.Require().NoError(st.SetReference(plumbing.NewHashReference("refs/tags/v1", h)))
+
+	cfg, err := st.Config()
+	s.Require().NoError(err)
+	cfg.Receive.DenyDeletes = true
+	s.Require().NoError(st.SetConfig(cfg))
+
+	report := s.receivePack(st, false,
+		&packp.Command{Name: "refs/heads/foo", Old: h, New: plumbing.ZeroHash},
+		&packp.Command{Name: "refs/tags/v1", Old: h, New: plumbing.ZeroHash},
+	)
+
+	var cmdErr packp.CommandStatusErr
+	s.Require().ErrorAs(report.Error(), &cmdErr)
+	s.Equal(plumbing.ReferenceName("refs/heads/foo"), cmdErr.ReferenceName)
+	s.Equal("deletion prohibited", cmdErr.Status)
+
+	_, err = st.Reference("refs/heads/foo")
+	s.NoError(err)
+
+	// Only branches are protected.
+	_, err = st.Reference("refs/tags/v1")
+	s.ErrorIs(err, plumbing.ErrReferenceNotFound)
+}
+
+func (s *ReceivePackSuite) TestReceivePackDenyNonFastForwards() {
+	st := memory.NewStorage()
+	base := s.storeCommit(st, "base")
+	ff := s.storeCommit(st, "ff", base)
+	other := s.storeCommit(st, "other")
+	s.Require().NoError(st.SetReference(plumbing.NewHashReference("refs/heads/ff", base)))
+	s.Require().NoError(st.SetReference(plumbing.NewHashReference("refs/heads/forced", base)))
+
+	cfg, err := st.Config()
+	s.Require().NoError(err)
+	cfg.Receive.DenyNonFastForwards = true
+	s.Require().NoError(st.SetConfig(cfg))
+
+	report := s.receivePack(st, false,
+		&packp.Command{Name: "refs/heads/ff", Old: base, New: ff},
+		&packp.Command{Name: "refs/heads/forced", Old: base, New: other},
+	)
+
+	var cmdErr packp.CommandStatusErr
+	s.Require().ErrorAs(report.Error(), &cmdErr)
+	s.Equal(plumbing.ReferenceName("refs/heads/forced"), cmdErr.ReferenceName)
+	s.Equal("non-fast-forward", cmdErr.Status)
+
+	ref, err := st.Reference("refs/heads/ff")
+	s.Require().NoError(err)
+	s.Equal(ff, ref.Hash())
+
+	ref, err = st.Reference("refs/heads/forced")
+	s.Require().NoError(err)
+	s.Equal(base, ref.Hash())
+}
+
+func (s *ReceivePackSuite) TestReceivePackDenyCurrentBranch() {
+	for _, deny := range []string{"", "refuse", "ignore"} {
+		st := memory.NewStorage()
+		h := s.storeCommit(st, "foo")
+		s.Require().NoError(st.SetReference(plumbing.NewSymbolicReference(plumbing.HEAD, plumbing.Master)))
+
+		cfg, err := st.Config()
+		s.Require().NoError(err)
+		cfg.Receive.DenyCurrentBranch = deny
+		s.Require().NoError(st.SetConfig(cfg))
+
+		report := s.receivePack(st, false,
+			&packp.Command{Name: plumbing.Master, Old: plumbing.ZeroHash, New: h},
+			&packp.Command{Name: "refs/heads/other", Old: plumbing.ZeroHash, New: h},
+		)
+
+		_, err = st.Reference("refs/heads/other")
+		s.NoError(err)
+
+		_, err = st.Reference(plumbing.Master)
+		if deny == "ignore" {
+			s.NoError(report.Error())
+			s.NoError(err)
+			continue
+		}
+
+		var cmdErr packp.CommandStatusErr
+		s.Require().ErrorAs(report.Error(), &cmdErr)
+		s.Equal(plumbing.Master, cmdErr.ReferenceName)
+		s.Equal("branch is currently checked out", cmdErr.Status)
+		s.ErrorIs(err, plumbing.ErrReferenceNotFound)
+	}
+}
+
+func (s *ReceivePackSuite) TestReceivePackDenyCurrentBranchBare() {
+	st := memory.NewStorage()
+	h := s.storeCommit(st, "foo")
+	s.Require().NoError(st.SetReference(plumbing.NewSymbolicReference(plumbing.HEAD, plumbing.Master)))
+
+	cfg, err := st.Config()
+	s.Require().NoError(err)
+	cfg.Core.IsBare = true
+	s.Require().NoError(st.SetConfig(cfg))
+
+	report := s.receivePack(st, false,
+		&packp.Command{Name: plumbing.Master, Old: plumbing.ZeroHash, New: h},
+	)
+	s.NoError(report.Error())
+}
@@ -50,6 +50,10 @@ func (c *mockConnection) Push(ctx context.Context, req *PushRequest) error {
 	return nil
 }
 
+func (c *mockConnection) ObjectInfo(ctx context.Context, hashes []plumbing.Hash) ([]ObjectSize, error) {
+	return nil, ErrObjectInfoNotSupported
+}
+
 // mockReadWriteCloser implements io.ReadWriteCloser for testing
 type mockReadWriteCloser struct {
 	readBuf  *bytes.Buffer
@@ -315,6 +319,32 @@ func TestSendPackErrors(t *testing.T) {
 		assert.Contains(t, err.Error(), "encode error")
 	})
 
+	// Test case: push certificate not supported
+	t.Run("PushCertNotSupported", func(t *testing.T) {
+		writer := newMockRWC(nil)
+		reader := newMockRWC(nil)
+
+		cmds := []*packp.Command{
+			{
+				Name: plumbing.ReferenceName("refs/heads/master"),
+				Old:  plumbing.ZeroHash,
+				New:  plumbing.NewHash("0123456789012345678901234567890123456789"),
+			},
+		}
+
+		var buf bytes.Buffer
+		req := &PushRequest{
+			Commands:    cmds,
+			Packfile:    io.NopCloser(&buf),
+			Certificate: &packp.PushCertificate{Commands: cmds},
+		}
+
+		storer := memory.NewStorage()
+		err := SendPack(context.Background(), storer, conn, writer, reader, req)
+		assert.ErrorIs(t, err, ErrPushCertNotSupported)
+		assert.Zero(t, writer.writeBuf.Len())
+	})
+
 	// Test case: error copying packfile
 	t.Run("PackfileCopyError", func(t *testing.T) {
 		writer := newMockRWC(nil)
@@ -429,3 +459,28 @@ type errorReader struct {
 func (r *errorReader) Read(p []byte) (int, error) {
 	return 0, r.err
 }
+
+func TestSendPackCapabilitiesNotSupported(t *testing.T) {
+	caps := capability.NewList()
+	caps.Add(capability.ReportStatus) //nolint:errcheck
+	conn := &mockConnection{caps: caps}
+
+	cmds := []*packp.Command{{
+		Name: plumbing.ReferenceName("refs/heads/master"),
+		Old:  plumbing.NewHash("0123456789012345678901234567890123456789"),
+		New:  plumbing.ZeroHash,
+	}}
+
+	for _, tc := range []struct {
+		req *PushRequest
+		err error
+	}{
+		{&PushRequest{Commands: cmds, Atomic: true}, ErrAtomicPushNotSupported},
+		{&PushRequest{Commands: cmds, Options: []string{"foo"}}, ErrPushOptionsNotSupported},
+	} {
+		writer := newMockRWC(nil)
+		err := SendPack(context.TODO(), memory.NewStorage(), conn, writer, newMockRWC(nil), tc.req)
+		assert.ErrorIs(t, err, tc.err)
+		assert.Zero(t, writer.writeBuf.Len())
+	}
+}
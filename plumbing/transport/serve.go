@@ -7,7 +7,9 @@ import (
 	"io"
 
 	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/go-git/go-git/v6/plumbing/format/pktline"
 	"github.com/go-git/go-git/v6/plumbing/object"
+	"github.com/go-git/go-git/v6/plumbing/protocol"
 	"github.com/go-git/go-git/v6/plumbing/protocol/packp"
 	"github.com/go-git/go-git/v6/plumbing/protocol/packp/capability"
 	"github.com/go-git/go-git/v6/plumbing/storer"
@@ -40,14 +42,13 @@ func AdvertiseReferences(
 	ar.Capabilities.Set(capability.Sideband64k)                      //nolint:errcheck
 	if forPush {
 		// TODO: support thin-pack
-		ar.Capabilities.Set(capability.NoThin) //nolint:errcheck
-		// TODO: support atomic
+		ar.Capabilities.Set(capability.NoThin)       //nolint:errcheck
+		ar.Capabilities.Set(capability.Atomic)       //nolint:errcheck
 		ar.Capabilities.Set(capability.DeleteRefs)   //nolint:errcheck
 		ar.Capabilities.Set(capability.ReportStatus) //nolint:errcheck
 		ar.Capabilities.Set(capability.PushOptions)  //nolint:errcheck
 	} else {
 		// TODO: support include-tag
-		// TODO: support deepen
 		// TODO: support deepen-since
 		ar.Capabilities.Set(capability.MultiACK)         //nolint:errcheck
 		ar.Capabilities.Set(capability.MultiACKDetailed) //nolint:errcheck
@@ -55,6 +56,7 @@ func AdvertiseReferences(
 		ar.Capabilities.Set(capability.NoProgress)       //nolint:errcheck
 		ar.Capabilities.Set(capability.SymRef)           //nolint:errcheck
 		ar.Capabilities.Set(capability.Shallow)          //nolint:errcheck
+		ar.Capabilities.Set(capability.DeepenRelative)   //nolint:errcheck
 	}
 
 	// Set references
@@ -75,6 +77,38 @@ func AdvertiseReferences(
 	return ar.Encode(w)
 }
 
+// advertiseReferences writes the references advertisement of the given
+// protocol version, 0 or 1. Using Smart-HTTP, the service announcement comes
+// before the version line.
+func advertiseReferences(
+	ctx context.Context,
+	st storage.Storer,
+	w io.Writer,
+	service Service,
+	version protocol.Version,
+	smart bool,
+) error {
+	if version == protocol.V1 {
+		if smart {
+			smartReply := packp.SmartReply{
+				Service: service.String(),
+			}
+
+			if err := smartReply.Encode(w); err != nil {
+				return fmt.Errorf("failed to encode smart reply: %w", err)
+			}
+		}
+
+		if _, err := pktline.Writef(w, "version %d\n", version); err != nil {
+			return err
+		}
+
+		smart = false
+	}
+
+	return AdvertiseReferences(ctx, st, w, service, smart)
+}
+
 func addReferences(st storage.Storer, ar *packp.AdvRefs, addHead bool) error {
 	iter, err := st.IterReferences()
 	if err != nil {
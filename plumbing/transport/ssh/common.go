@@ -5,10 +5,12 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"net"
+	"os"
 	"reflect"
-	"strconv"
 	"strings"
+	"time"
 
 	"github.com/go-git/go-git/v6/plumbing/transport"
 	"github.com/go-git/go-git/v6/utils/trace"
@@ -29,13 +31,43 @@ var DefaultTransport = NewTransport(nil)
 // system's ssh_config files. If nil all the ssh_config are ignored.
 var DefaultSSHConfig sshConfig = ssh_config.DefaultUserSettings
 
-type sshConfig interface {
-	Get(alias, key string) string
-}
-
 // NewTransport creates a new SSH client with an optional *ssh.ClientConfig.
 func NewTransport(config *ssh.ClientConfig) transport.Transport {
-	return transport.NewPackTransport(&runner{config: config})
+	return NewTransportWithOptions(&TransportOptions{ClientConfig: config})
+}
+
+// TransportOptions holds user configurable options for the SSH client.
+type TransportOptions struct {
+	// ClientConfig, if not nil, overrides the ssh.ClientConfig built from the
+	// AuthMethod, as the one given to NewTransport does.
+	ClientConfig *ssh.ClientConfig
+	// ConnectTimeout is the maximum amount of time to establish the
+	// connection, including the SSH handshake. If zero, the Timeout of the
+	// ssh.ClientConfig is used.
+	ConnectTimeout time.Duration
+	// IdleTimeout is the maximum amount of time to wait for data from the
+	// server. When exceeded the connection is closed, and reading the output
+	// of the command fails with an *IdleTimeoutError. Sending data to the
+	// server resets the timeout too. If zero, reads may block forever.
+	IdleTimeout time.Duration
+	// KeepAlive is the interval between the TCP keep-alive probes. If zero,
+	// the default of net.Dialer is used, while a negative value disables
+	// them.
+	KeepAlive time.Duration
+	// SSHConfigPath, if not empty, is the path of the ssh_config file used
+	// instead of DefaultSSHConfig to resolve the host aliases. The HostName,
+	// Port, User and IdentityFile of the alias are applied, while a ProxyJump
+	// results in ErrProxyJumpNotSupported. A missing file is ignored.
+	SSHConfigPath string
+}
+
+// NewTransportWithOptions creates a new SSH client with the given options.
+func NewTransportWithOptions(opts *TransportOptions) transport.Transport {
+	if opts == nil {
+		opts = &TransportOptions{}
+	}
+
+	return transport.NewPackTransport(&runner{config: opts.ClientConfig, opts: *opts})
 }
 
 // DefaultAuthBuilder is the function used to create a default AuthMethod, when
@@ -49,10 +81,20 @@ const DefaultPort = 22
 
 type runner struct {
 	config *ssh.ClientConfig
+	opts   TransportOptions
 }
 
 func (r *runner) Command(ctx context.Context, cmd string, ep *transport.Endpoint, auth transport.AuthMethod, params ...string) (transport.Command, error) {
-	c := &command{command: cmd, endpoint: ep, config: r.config}
+	c := &command{command: cmd, endpoint: ep, config: r.config, opts: r.opts}
+	if r.opts.SSHConfigPath != "" {
+		cfg, err := loadSSHConfig(r.opts.SSHConfigPath)
+		if err != nil {
+			return nil, err
+		}
+
+		c.sshConfig = cfg
+	}
+
 	if auth != nil {
 		if err := c.setAuth(auth); err != nil {
 			return nil, err
@@ -79,6 +121,9 @@ type command struct {
 	client    *ssh.Client
 	auth      AuthMethod
 	config    *ssh.ClientConfig
+	opts      TransportOptions
+	conn      *timeoutConn
+	sshConfig sshConfig
 }
 
 func (c *command) setAuth(auth transport.AuthMethod) error {
@@ -91,6 +136,17 @@ func (c *command) setAuth(auth transport.AuthMethod) error {
 	return nil
 }
 
+// StdoutPipe returns a pipe connected to the standard output of the command.
+// Its reads fail with an *IdleTimeoutError when the IdleTimeout is exceeded.
+func (c *command) StdoutPipe() (io.Reader, error) {
+	r, err := c.Session.StdoutPipe()
+	if err != nil || c.conn == nil || c.conn.timeout <= 0 {
+		return r, err
+	}
+
+	return &idleTimeoutReader{Reader: r, conn: c.conn}, nil
+}
+
 func (c *command) Start() error {
 	cmd := endpointToCommand(c.command, c.endpoint)
 	return c.Session.Start(cmd)
@@ -124,6 +180,10 @@ func (c *command) connect(ctx context.Context) error {
 		return transport.ErrAlreadyConnected
 	}
 
+	if err := c.checkProxyJump(); err != nil {
+		return err
+	}
+
 	if c.auth == nil {
 		if err := c.setAuthFromEndpoint(); err != nil {
 			return err
@@ -162,7 +222,7 @@ func (c *command) connect(ctx context.Context) error {
 
 	overrideConfig(c.config, config)
 
-	c.client, err = dial(ctx, "tcp", hostWithPort, c.endpoint.Proxy, config)
+	c.client, c.conn, err = dial(ctx, "tcp", hostWithPort, c.endpoint.Proxy, config, c.opts)
 	if err != nil {
 		return err
 	}
@@ -177,97 +237,107 @@ func (c *command) connect(ctx context.Context) error {
 	return nil
 }
 
-func dial(ctx context.Context, network, addr string, proxyOpts transport.ProxyOptions, config *ssh.ClientConfig) (*ssh.Client, error) {
+func dial(ctx context.Context, network, addr string, proxyOpts transport.ProxyOptions, config *ssh.ClientConfig, opts TransportOptions) (*ssh.Client, *timeoutConn, error) {
+	timeout := opts.ConnectTimeout
+	if timeout == 0 {
+		timeout = config.Timeout
+	}
+
 	var cancel context.CancelFunc
-	if config.Timeout > 0 {
-		ctx, cancel = context.WithTimeout(ctx, config.Timeout)
+	if timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, timeout)
 	} else {
 		ctx, cancel = context.WithCancel(ctx)
 	}
 	defer cancel()
 
+	dialer := &net.Dialer{KeepAlive: opts.KeepAlive}
+
 	var conn net.Conn
 	var dialErr error
 
 	if proxyOpts.URL != "" {
 		proxyUrl, err := proxyOpts.FullURL()
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 
 		trace.SSH.Printf("ssh: using proxyURL=%s", proxyUrl)
-		dialer, err := proxy.FromURL(proxyUrl, proxy.Direct)
+		dialer, err := proxy.FromURL(proxyUrl, dialer)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 
 		// Try to use a ContextDialer, but fall back to a Dialer if that goes south.
 		ctxDialer, ok := dialer.(proxy.ContextDialer)
 		if !ok {
-			return nil, fmt.Errorf("expected ssh proxy dialer to be of type %s; got %s",
+			return nil, nil, fmt.Errorf("expected ssh proxy dialer to be of type %s; got %s",
 				reflect.TypeOf(ctxDialer), reflect.TypeOf(dialer))
 		}
 		conn, dialErr = ctxDialer.DialContext(ctx, "tcp", addr)
 	} else {
-		conn, dialErr = proxy.Dial(ctx, network, addr)
+		conn, dialErr = dialFromEnvironment(ctx, dialer, network, addr)
 	}
 	if dialErr != nil {
-		return nil, dialErr
+		return nil, nil, dialErr
 	}
 
-	c, chans, reqs, err := ssh.NewClientConn(conn, addr, config)
-	if err != nil {
-		return nil, err
+	// The handshake is bounded by the same deadline than the dial.
+	if deadline, ok := ctx.Deadline(); ok {
+		if err := conn.SetDeadline(deadline); err != nil {
+			_ = conn.Close()
+			return nil, nil, err
+		}
 	}
-	return ssh.NewClient(c, chans, reqs), nil
-}
 
-func (c *command) getHostWithPort() string {
-	if addr, found := c.doGetHostWithPortFromSSHConfig(); found {
-		return addr
-	}
+	tc := &timeoutConn{Conn: conn, timeout: opts.IdleTimeout}
+	c, chans, reqs, err := ssh.NewClientConn(tc, addr, config)
+	if err != nil {
+		_ = conn.Close()
+		if errors.Is(err, os.ErrDeadlineExceeded) {
+			return nil, nil, fmt.Errorf("%w: %w", transport.ErrTimeoutExceeded, err)
+		}
 
-	host := c.endpoint.Host
-	port := c.endpoint.Port
-	if port <= 0 {
-		port = DefaultPort
+		return nil, nil, err
 	}
 
-	return net.JoinHostPort(host, strconv.Itoa(port))
-}
-
-func (c *command) doGetHostWithPortFromSSHConfig() (addr string, found bool) {
-	if DefaultSSHConfig == nil {
-		return
+	if err := conn.SetDeadline(time.Time{}); err != nil {
+		_ = c.Close()
+		return nil, nil, err
 	}
 
-	host := c.endpoint.Host
-	port := c.endpoint.Port
+	tc.start()
+	return ssh.NewClient(c, chans, reqs), tc, nil
+}
 
-	configHost := DefaultSSHConfig.Get(c.endpoint.Host, "Hostname")
-	if configHost != "" {
-		host = configHost
-		found = true
+// dialFromEnvironment dials the given address through the proxy configured
+// in the environment, if any, as proxy.Dial does, but using the given dialer
+// for the direct connections.
+func dialFromEnvironment(ctx context.Context, dialer *net.Dialer, network, addr string) (net.Conn, error) {
+	d := proxy.FromEnvironmentUsing(dialer)
+	if cd, ok := d.(proxy.ContextDialer); ok {
+		return cd.DialContext(ctx, network, addr)
 	}
 
-	if !found {
-		return
-	}
+	return d.Dial(network, addr)
+}
 
-	configPort := DefaultSSHConfig.Get(c.endpoint.Host, "Port")
-	if configPort != "" {
-		if i, err := strconv.Atoi(configPort); err == nil {
-			port = i
+// setAuthFromEndpoint sets the auth method used when the user doesn't provide
+// any, from the IdentityFile of the ssh_config if set, or DefaultAuthBuilder.
+func (c *command) setAuthFromEndpoint() error {
+	user := c.getUser()
+	if file := c.getIdentityFile(); file != "" {
+		auth, err := NewPublicKeysFromFile(user, file, "")
+		if err == nil {
+			c.auth = auth
+			return nil
 		}
-	}
 
-	addr = net.JoinHostPort(host, strconv.Itoa(port))
-	return
-}
+		trace.SSH.Printf("ssh: unable to use IdentityFile %s: %s", file, err)
+	}
 
-func (c *command) setAuthFromEndpoint() error {
 	var err error
-	c.auth, err = DefaultAuthBuilder(c.endpoint.User)
+	c.auth, err = DefaultAuthBuilder(user)
 	return err
 }
 
@@ -2,7 +2,11 @@ package ssh
 
 import (
 	"context"
+	"fmt"
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/go-git/go-git/v6/plumbing/transport"
 	"github.com/go-git/go-git/v6/storage/memory"
@@ -173,6 +177,95 @@ func TestIssue70Suite(t *testing.T) {
 	require.NoError(t, cmd.Close())
 }
 
+func writeSSHConfig(t testing.TB, format string, args ...any) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config")
+	require.NoError(t, os.WriteFile(path, []byte(fmt.Sprintf(format, args...)), 0o600))
+	return path
+}
+
+func TestSSHConfigPath(t *testing.T) {
+	key := filepath.Join(t.TempDir(), "id_rsa")
+	require.NoError(t, os.WriteFile(key, testdata.PEMBytes["rsa"], 0o600))
+
+	// The known_hosts are read before the ClientConfig overrides apply.
+	knownHosts := filepath.Join(t.TempDir(), "known_hosts")
+	require.NoError(t, os.WriteFile(knownHosts, nil, 0o600))
+	t.Setenv("SSH_KNOWN_HOSTS", knownHosts)
+
+	base, port, _ := setupTest(t)
+	r := &runner{
+		config: &stdssh.ClientConfig{HostKeyCallback: stdssh.InsecureIgnoreHostKey()},
+		opts: TransportOptions{SSHConfigPath: writeSSHConfig(t,
+			"Host myalias\n  HostName localhost\n  Port %d\n  User foo\n  IdentityFile %s\n",
+			port, key,
+		)},
+	}
+
+	ep, err := transport.NewEndpoint(fmt.Sprintf("ssh://myalias/%s/endpoint", filepath.ToSlash(base)))
+	require.NoError(t, err)
+
+	var cmd transport.Command
+	require.Eventually(t, func() bool {
+		cmd, err = r.Command(context.TODO(), "command", ep, nil)
+		return err == nil
+	}, 5*time.Second, 10*time.Millisecond)
+	defer cmd.Close()
+
+	c := cmd.(*command)
+	require.Equal(t, fmt.Sprintf("localhost:%d", port), c.getHostWithPort())
+	auth, ok := c.auth.(*PublicKeys)
+	require.True(t, ok)
+	require.Equal(t, "foo", auth.User)
+}
+
+func TestSSHConfigPathExplicitPortAndUser(t *testing.T) {
+	cfg, err := loadSSHConfig(writeSSHConfig(t,
+		"Host myalias\n  HostName %%h.example.com\n  Port 2222\n  User foo\n",
+	))
+	require.NoError(t, err)
+
+	ep, err := transport.NewEndpoint("ssh://bar@myalias:2200/foo/bar.git")
+	require.NoError(t, err)
+
+	c := &command{endpoint: ep, sshConfig: cfg}
+	require.Equal(t, "myalias.example.com:2200", c.getHostWithPort())
+	require.Equal(t, "bar", c.getUser())
+
+	ep, err = transport.NewEndpoint("ssh://myalias/foo/bar.git")
+	require.NoError(t, err)
+
+	c = &command{endpoint: ep, sshConfig: cfg}
+	require.Equal(t, "myalias.example.com:2222", c.getHostWithPort())
+	require.Equal(t, "foo", c.getUser())
+}
+
+func TestSSHConfigPathNotFound(t *testing.T) {
+	cfg, err := loadSSHConfig(filepath.Join(t.TempDir(), "config"))
+	require.NoError(t, err)
+
+	ep, err := transport.NewEndpoint("ssh://myalias/foo/bar.git")
+	require.NoError(t, err)
+
+	c := &command{endpoint: ep, sshConfig: cfg}
+	require.Equal(t, "myalias:22", c.getHostWithPort())
+	require.Equal(t, "", c.getUser())
+	require.Equal(t, "", c.getIdentityFile())
+	require.NoError(t, c.checkProxyJump())
+}
+
+func TestSSHConfigPathProxyJump(t *testing.T) {
+	r := &runner{opts: TransportOptions{SSHConfigPath: writeSSHConfig(t,
+		"Host myalias\n  HostName localhost\n  ProxyJump jump.example.com\n",
+	)}}
+
+	ep, err := transport.NewEndpoint("ssh://myalias/foo/bar.git")
+	require.NoError(t, err)
+
+	_, err = r.Command(context.TODO(), "command", ep, nil)
+	require.ErrorIs(t, err, ErrProxyJumpNotSupported)
+}
+
 func (s *SuiteCommon) TestInvalidSocks5Proxy() {
 	st := memory.NewStorage()
 	ep, err := transport.NewEndpoint("git@github.com:foo/bar.git")
@@ -0,0 +1,186 @@
+package ssh
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/go-git/go-git/v6/utils/trace"
+	"github.com/kevinburke/ssh_config"
+)
+
+// ErrProxyJumpNotSupported is returned when the ssh_config of the host
+// requires connecting through a jump host, which is not supported.
+var ErrProxyJumpNotSupported = errors.New("ssh: ProxyJump is not supported")
+
+type sshConfig interface {
+	Get(alias, key string) string
+}
+
+// fileSSHConfig is an sshConfig read from a single ssh_config file.
+type fileSSHConfig struct {
+	config *ssh_config.Config
+}
+
+// loadSSHConfig reads the ssh_config file at the given path. A missing file
+// results in an empty config, as ssh does.
+func loadSSHConfig(path string) (sshConfig, error) {
+	f, err := os.Open(expandHome(path))
+	if errors.Is(err, os.ErrNotExist) {
+		trace.SSH.Printf("ssh: ssh_config %s not found", path)
+		return &fileSSHConfig{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	cfg, err := ssh_config.Decode(f)
+	if err != nil {
+		return nil, fmt.Errorf("ssh: parsing ssh_config %s: %w", path, err)
+	}
+
+	return &fileSSHConfig{config: cfg}, nil
+}
+
+func (c *fileSSHConfig) Get(alias, key string) string {
+	if c.config == nil {
+		return ""
+	}
+
+	// Errors only come from included files, which are ignored as
+	// ssh_config.UserSettings does.
+	v, _ := c.config.Get(alias, key)
+	return v
+}
+
+// getSSHConfig returns the ssh_config used to resolve the endpoint, either
+// the one read from TransportOptions.SSHConfigPath or DefaultSSHConfig.
+func (c *command) getSSHConfig() sshConfig {
+	if c.sshConfig != nil {
+		return c.sshConfig
+	}
+
+	return DefaultSSHConfig
+}
+
+func (c *command) getHostWithPort() string {
+	if addr, found := c.doGetHostWithPortFromSSHConfig(); found {
+		return addr
+	}
+
+	host := c.endpoint.Host
+	port := c.endpoint.Port
+	if port <= 0 {
+		port = DefaultPort
+	}
+
+	return net.JoinHostPort(host, strconv.Itoa(port))
+}
+
+func (c *command) doGetHostWithPortFromSSHConfig() (addr string, found bool) {
+	cfg := c.getSSHConfig()
+	if cfg == nil {
+		return
+	}
+
+	host := c.endpoint.Host
+	port := c.endpoint.Port
+
+	configHost := cfg.Get(c.endpoint.Host, "Hostname")
+	if configHost != "" {
+		host = strings.ReplaceAll(configHost, "%h", c.endpoint.Host)
+		found = true
+	}
+
+	if !found {
+		return
+	}
+
+	// A port given explicitly in the URL takes precedence over the config.
+	configPort := cfg.Get(c.endpoint.Host, "Port")
+	if configPort != "" && (port <= 0 || port == DefaultPort) {
+		if i, err := strconv.Atoi(configPort); err == nil {
+			port = i
+		}
+	}
+
+	if port <= 0 {
+		port = DefaultPort
+	}
+
+	addr = net.JoinHostPort(host, strconv.Itoa(port))
+	return
+}
+
+// getUser returns the user of the endpoint, or the User from the ssh_config
+// if the endpoint has none.
+func (c *command) getUser() string {
+	if c.endpoint.User != "" {
+		return c.endpoint.User
+	}
+
+	if cfg := c.getSSHConfig(); cfg != nil {
+		return cfg.Get(c.endpoint.Host, "User")
+	}
+
+	return ""
+}
+
+// getIdentityFile returns the path of the IdentityFile set in the ssh_config
+// for the endpoint, if any and it exists. The default IdentityFile is ignored,
+// to use the SSH agent instead.
+func (c *command) getIdentityFile() string {
+	cfg := c.getSSHConfig()
+	if cfg == nil {
+		return ""
+	}
+
+	file := cfg.Get(c.endpoint.Host, "IdentityFile")
+	if file == "" || file == ssh_config.Default("IdentityFile") {
+		return ""
+	}
+
+	file = expandHome(file)
+	if _, err := os.Stat(file); err != nil {
+		trace.SSH.Printf("ssh: ignoring IdentityFile %s: %s", file, err)
+		return ""
+	}
+
+	return file
+}
+
+// checkProxyJump returns ErrProxyJumpNotSupported if the ssh_config sets a
+// ProxyJump for the endpoint.
+func (c *command) checkProxyJump() error {
+	cfg := c.getSSHConfig()
+	if cfg == nil {
+		return nil
+	}
+
+	jump := cfg.Get(c.endpoint.Host, "ProxyJump")
+	if jump == "" || strings.EqualFold(jump, "none") {
+		return nil
+	}
+
+	return fmt.Errorf("%w: %s (host %s)", ErrProxyJumpNotSupported, jump, c.endpoint.Host)
+}
+
+// expandHome replaces a leading ~ in path with the home directory of the
+// user.
+func expandHome(path string) string {
+	if path != "~" && !strings.HasPrefix(path, "~/") {
+		return path
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+
+	return filepath.Join(home, path[1:])
+}
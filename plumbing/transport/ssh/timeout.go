@@ -0,0 +1,98 @@
+package ssh
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-git/go-git/v6/plumbing/transport"
+)
+
+// IdleTimeoutError is returned when no data was received from the server for
+// longer than the IdleTimeout of the TransportOptions. It matches
+// transport.ErrTimeoutExceeded with errors.Is.
+type IdleTimeoutError struct {
+	// Duration is the idle timeout that was exceeded.
+	Duration time.Duration
+}
+
+func (e *IdleTimeoutError) Error() string {
+	return fmt.Sprintf("ssh: no data received from the server in %s", e.Duration)
+}
+
+// Timeout returns true, so os.IsTimeout reports the error as a timeout.
+func (e *IdleTimeoutError) Timeout() bool {
+	return true
+}
+
+// Is reports whether the target is transport.ErrTimeoutExceeded.
+func (e *IdleTimeoutError) Is(target error) bool {
+	return target == transport.ErrTimeoutExceeded
+}
+
+// timeoutConn is a net.Conn whose reads fail once no data is received for
+// longer than the timeout, if any. Writes postpone the deadline as well, so
+// the connection isn't considered idle while data is being sent. The timeout
+// only applies once started, after the handshake.
+type timeoutConn struct {
+	net.Conn
+	timeout time.Duration
+
+	started  atomic.Bool
+	timedOut atomic.Bool
+}
+
+func (c *timeoutConn) start() {
+	if c.timeout <= 0 {
+		return
+	}
+
+	c.started.Store(true)
+	c.extend()
+}
+
+func (c *timeoutConn) extend() {
+	if c.started.Load() {
+		_ = c.Conn.SetReadDeadline(time.Now().Add(c.timeout))
+	}
+}
+
+func (c *timeoutConn) Read(b []byte) (int, error) {
+	c.extend()
+	n, err := c.Conn.Read(b)
+	if c.started.Load() && errors.Is(err, os.ErrDeadlineExceeded) {
+		c.timedOut.Store(true)
+	}
+
+	return n, err
+}
+
+func (c *timeoutConn) Write(b []byte) (int, error) {
+	n, err := c.Conn.Write(b)
+	if n > 0 {
+		c.extend()
+	}
+
+	return n, err
+}
+
+// idleTimeoutReader returns an *IdleTimeoutError from the reads failing
+// after the connection timed out, instead of the EOF returned by the session
+// when the connection is closed.
+type idleTimeoutReader struct {
+	io.Reader
+	conn *timeoutConn
+}
+
+func (r *idleTimeoutReader) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	if err != nil && r.conn.timedOut.Load() {
+		return n, &IdleTimeoutError{Duration: r.conn.timeout}
+	}
+
+	return n, err
+}
@@ -0,0 +1,145 @@
+package ssh
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/gliderlabs/ssh"
+	"github.com/go-git/go-git/v6/internal/transport/test"
+	"github.com/go-git/go-git/v6/plumbing/transport"
+	"github.com/stretchr/testify/suite"
+	stdssh "golang.org/x/crypto/ssh"
+)
+
+type TimeoutSuite struct {
+	suite.Suite
+}
+
+func TestTimeoutSuite(t *testing.T) {
+	if runtime.GOOS == "js" {
+		t.Skip("tcp connections are not available in wasm")
+	}
+	suite.Run(t, new(TimeoutSuite))
+}
+
+func (s *TimeoutSuite) clientConfig() *stdssh.ClientConfig {
+	return &stdssh.ClientConfig{
+		User:            "git",
+		HostKeyCallback: stdssh.InsecureIgnoreHostKey(),
+	}
+}
+
+// command starts a session on a server running the given handler.
+func (s *TimeoutSuite) command(handler ssh.Handler, opts TransportOptions) (*command, io.Reader) {
+	port, err := test.FreePort()
+	s.Require().NoError(err)
+
+	server := &ssh.Server{Addr: fmt.Sprintf("127.0.0.1:%d", port), Handler: handler}
+	go server.ListenAndServe()
+	s.T().Cleanup(func() { server.Close() })
+
+	addr := server.Addr
+	var client *stdssh.Client
+	var conn *timeoutConn
+	s.Require().Eventually(func() bool {
+		client, conn, err = dial(context.Background(), "tcp", addr, transport.ProxyOptions{}, s.clientConfig(), opts)
+		return err == nil
+	}, 5*time.Second, 10*time.Millisecond)
+
+	session, err := client.NewSession()
+	s.Require().NoError(err)
+
+	c := &command{
+		Session:   session,
+		connected: true,
+		command:   "git-upload-pack",
+		endpoint:  &transport.Endpoint{Path: "foo.git"},
+		client:    client,
+		conn:      conn,
+	}
+	s.T().Cleanup(func() { c.Close() })
+
+	stdout, err := c.StdoutPipe()
+	s.Require().NoError(err)
+	s.Require().NoError(c.Start())
+	return c, stdout
+}
+
+func (s *TimeoutSuite) TestIdleTimeout() {
+	done := make(chan struct{})
+	defer close(done)
+
+	_, stdout := s.command(func(sess ssh.Session) {
+		_, _ = io.WriteString(sess, "hello")
+		<-done
+	}, TransportOptions{IdleTimeout: 100 * time.Millisecond})
+
+	buf := make([]byte, 5)
+	_, err := io.ReadFull(stdout, buf)
+	s.Require().NoError(err)
+	s.Equal("hello", string(buf))
+
+	start := time.Now()
+	_, err = stdout.Read(buf)
+	s.Less(time.Since(start), 5*time.Second)
+
+	var timeoutErr *IdleTimeoutError
+	s.Require().ErrorAs(err, &timeoutErr)
+	s.Equal(100*time.Millisecond, timeoutErr.Duration)
+	s.ErrorIs(err, transport.ErrTimeoutExceeded)
+	s.True(os.IsTimeout(err))
+}
+
+func (s *TimeoutSuite) TestIdleTimeoutNotExceeded() {
+	_, stdout := s.command(func(sess ssh.Session) {
+		for i := 0; i < 6; i++ {
+			_, _ = io.WriteString(sess, "ping\n")
+			time.Sleep(50 * time.Millisecond)
+		}
+	}, TransportOptions{IdleTimeout: 200 * time.Millisecond})
+
+	out, err := io.ReadAll(stdout)
+	s.NoError(err)
+	s.Len(out, 30)
+}
+
+func (s *TimeoutSuite) TestConnectTimeout() {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	s.Require().NoError(err)
+	defer l.Close()
+
+	// The server accepts the connection, but never completes the handshake.
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	start := time.Now()
+	_, _, err = dial(context.Background(), "tcp", l.Addr().String(), transport.ProxyOptions{}, s.clientConfig(), TransportOptions{
+		ConnectTimeout: 100 * time.Millisecond,
+		KeepAlive:      time.Second,
+	})
+	s.Less(time.Since(start), 5*time.Second)
+	s.ErrorIs(err, transport.ErrTimeoutExceeded)
+}
+
+func (s *TimeoutSuite) TestNewTransportWithOptions() {
+	config := s.clientConfig()
+	opts := &TransportOptions{ClientConfig: config, IdleTimeout: time.Second, KeepAlive: -1}
+
+	tr := NewTransportWithOptions(opts)
+	s.NotNil(tr)
+	s.NotNil(NewTransportWithOptions(nil))
+	s.NotNil(NewTransport(config))
+}
@@ -5,7 +5,6 @@ import (
 	"context"
 	"fmt"
 	"io"
-	"math"
 
 	"github.com/go-git/go-git/v6/plumbing"
 	"github.com/go-git/go-git/v6/plumbing/format/packfile"
@@ -45,19 +44,19 @@ func UploadPack(
 		opts = &UploadPackOptions{}
 	}
 
+	if ProtocolVersion(opts.GitProtocol) == protocol.V2 {
+		return uploadPackV2(ctx, st, r, w, opts)
+	}
+
 	if opts.AdvertiseRefs || !opts.StatelessRPC {
-		switch version := ProtocolVersion(opts.GitProtocol); version {
-		case protocol.V1:
-			if _, err := pktline.Writef(w, "version %d\n", version); err != nil {
-				return err
-			}
-		// TODO: support version 2
-		case protocol.V0, protocol.V2:
+		version := ProtocolVersion(opts.GitProtocol)
+		switch version {
+		case protocol.V0, protocol.V1:
 		default:
 			return fmt.Errorf("%w: %q", ErrUnsupportedVersion, version)
 		}
 
-		if err := AdvertiseReferences(ctx, st, w, UploadPackService, opts.StatelessRPC); err != nil {
+		if err := advertiseReferences(ctx, st, w, UploadPackService, version, opts.StatelessRPC); err != nil {
 			return fmt.Errorf("advertising references: %w", err)
 		}
 	}
@@ -125,7 +124,14 @@ func UploadPack(
 				if !upreq.Depth.IsZero() {
 					switch depth := upreq.Depth.(type) {
 					case packp.DepthCommits:
-						if err := getShallowCommits(st, wants, int(depth), &shupd); err != nil {
+						heads, n := wants, int(depth)
+						if caps.Supports(capability.DeepenRelative) {
+							// The depth is counted from the current shallow
+							// commits of the client, which are at depth 1.
+							heads, n = upreq.Shallows, n+1
+						}
+
+						if err := getShallowCommits(st, heads, n, upreq.Shallows, &shupd); err != nil {
 							writec <- fmt.Errorf("getting shallow commits: %w", err)
 							return
 						}
@@ -254,14 +260,14 @@ func UploadPack(
 		useSideband bool
 		writer      io.Writer = w
 	)
-	if !caps.Supports(capability.NoProgress) {
-		if caps.Supports(capability.Sideband64k) {
-			writer = sideband.NewMuxer(sideband.Sideband64k, w)
-			useSideband = true
-		} else if caps.Supports(capability.Sideband) {
-			writer = sideband.NewMuxer(sideband.Sideband, w)
-			useSideband = true
-		}
+	// The packfile is multiplexed whenever the client asks for it, even
+	// with no-progress, which only disables the progress messages.
+	if caps.Supports(capability.Sideband64k) {
+		writer = sideband.NewMuxer(sideband.Sideband64k, w)
+		useSideband = true
+	} else if caps.Supports(capability.Sideband) {
+		writer = sideband.NewMuxer(sideband.Sideband, w)
+		useSideband = true
 	}
 
 	// TODO: Support shallow-file
@@ -289,71 +295,64 @@ func objectsToUpload(st storage.Storer, wants, haves []plumbing.Hash) ([]plumbin
 	return revlist.Objects(st, wants, haves)
 }
 
-func getShallowCommits(st storage.Storer, heads []plumbing.Hash, depth int, upd *packp.ShallowUpdate) error {
-	var i, curDepth int
-	var commit *object.Commit
-	depths := map[*object.Commit]int{}
-	stack := []object.Object{}
-
-	for commit != nil || i < len(heads) || len(stack) > 0 {
-		if commit == nil {
-			if i < len(heads) {
-				obj, err := st.EncodedObject(plumbing.CommitObject, heads[i])
-				i++
-				if err != nil {
-					continue
-				}
-
-				commit, err = object.DecodeCommit(st, obj)
-				if err != nil {
-					commit = nil
-					continue
-				}
+// getShallowCommits walks the history of the given heads up to the given
+// depth, the heads being at depth 1. The commits at the limit having parents
+// are the new shallow commits, and the shallow commits of the client walked
+// past are unshallowed.
+func getShallowCommits(
+	st storage.Storer,
+	heads []plumbing.Hash,
+	depth int,
+	clientShallows []plumbing.Hash,
+	upd *packp.ShallowUpdate,
+) error {
+	depths := make(map[plumbing.Hash]int)
+	var queue []*object.Commit
+	for _, h := range heads {
+		if _, ok := depths[h]; ok {
+			continue
+		}
 
-				depths[commit] = 0
-				curDepth = 0
-			} else if len(stack) > 0 {
-				commit = stack[len(stack)-1].(*object.Commit)
-				stack = stack[:len(stack)-1]
-				curDepth = depths[commit]
-			}
+		commit, err := object.GetCommit(st, h)
+		if err != nil {
+			continue
 		}
 
-		curDepth++
+		depths[h] = 1
+		queue = append(queue, commit)
+	}
+
+	shallows := make(map[plumbing.Hash]bool)
+	for len(queue) > 0 {
+		commit := queue[0]
+		queue = queue[1:]
 
-		if depth != math.MaxInt && curDepth >= depth {
+		curDepth := depths[commit.Hash]
+		if curDepth >= depth && commit.NumParents() > 0 {
+			shallows[commit.Hash] = true
 			upd.Shallows = append(upd.Shallows, commit.Hash)
-			commit = nil
 			continue
 		}
 
-		upd.Unshallows = append(upd.Unshallows, commit.Hash)
-
-		parents := commit.Parents()
-		commit = nil
-		for {
-			parent, err := parents.Next()
-			if err == io.EOF {
-				break
+		for _, p := range commit.ParentHashes {
+			if _, ok := depths[p]; ok {
+				continue
 			}
+
+			parent, err := object.GetCommit(st, p)
 			if err != nil {
 				return err
 			}
 
-			if depths[parent] != 0 && curDepth >= depths[parent] {
-				continue
-			}
-
-			depths[parent] = curDepth
-
-			if _, err := parents.Next(); err == nil {
-				stack = append(stack, parent)
-			} else {
-				commit = parent
-				curDepth = depths[commit]
-			}
+			depths[p] = curDepth + 1
+			queue = append(queue, parent)
 		}
+	}
 
+	for _, h := range clientShallows {
+		if _, ok := depths[h]; ok && !shallows[h] {
+			upd.Unshallows = append(upd.Unshallows, h)
+		}
 	}
 
 	return nil
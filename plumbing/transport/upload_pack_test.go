@@ -1,8 +1,24 @@
 package transport
 
 import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"strings"
 	"testing"
 
+	fixtures "github.com/go-git/go-git-fixtures/v5"
+	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/go-git/go-git/v6/plumbing/cache"
+	"github.com/go-git/go-git/v6/plumbing/format/packfile"
+	"github.com/go-git/go-git/v6/plumbing/format/pktline"
+	"github.com/go-git/go-git/v6/plumbing/object"
+	"github.com/go-git/go-git/v6/plumbing/protocol/packp/capability"
+	"github.com/go-git/go-git/v6/plumbing/protocol/packp/sideband"
+	"github.com/go-git/go-git/v6/storage/filesystem"
+	"github.com/go-git/go-git/v6/storage/memory"
+	"github.com/go-git/go-git/v6/utils/ioutil"
 	"github.com/stretchr/testify/suite"
 )
 
@@ -19,11 +35,156 @@ func (s *UploadPackSuite) TestUploadPackAdvertiseV0() {
 }
 
 func (s *UploadPackSuite) TestUploadPackAdvertiseV2() {
-	// TODO: support version 2
-	testAdvertise(s.T(), UploadPack, "version=2", false)
+	buf := testAdvertise(s.T(), UploadPack, "version=2", true)
+	s.Equal("000eversion 2\n"+
+		fmt.Sprintf("%04xagent=%s\n", 4+len("agent=\n")+len(capability.DefaultAgent()), capability.DefaultAgent())+
+		"000cls-refs\n0020fetch=shallow wait-for-done\n0010object-info\n0000", buf.String())
 }
 
 func (s *UploadPackSuite) TestUploadPackAdvertiseV1() {
 	buf := testAdvertise(s.T(), UploadPack, "version=1", false)
 	s.Containsf(buf.String(), "version 1", "advertisement should contain version 1")
 }
+
+func (s *UploadPackSuite) TestUploadPackAdvertiseV1Smart() {
+	buf := testAdvertise(s.T(), UploadPack, "version=1", true)
+	s.True(strings.HasPrefix(buf.String(), "001e# service=git-upload-pack\n0000000eversion 1\n"), buf.String())
+}
+
+// uploadPackV2 runs a protocol v2 command on the basic fixture, with the
+// given arguments, and returns the lines of the response, "0000" and "0001"
+// standing for the flush and delim packets. The response is cut at the
+// packfile section, whose content is returned.
+func (s *UploadPackSuite) uploadPackV2(command string, args ...string) ([]string, io.Reader) {
+	var req bytes.Buffer
+	pktline.Writeln(&req, "command="+command) //nolint:errcheck
+	pktline.WriteDelim(&req)                  //nolint:errcheck
+	for _, a := range args {
+		pktline.Writeln(&req, a) //nolint:errcheck
+	}
+	pktline.WriteFlush(&req) //nolint:errcheck
+
+	dot := fixtures.Basic().One().DotGit(fixtures.WithTargetDir(s.T().TempDir))
+	st := filesystem.NewStorage(dot, cache.NewObjectLRUDefault())
+
+	var out bytes.Buffer
+	err := UploadPack(context.TODO(), st, io.NopCloser(&req), ioutil.WriteNopCloser(&out), &UploadPackOptions{
+		GitProtocol:  "version=2",
+		StatelessRPC: true,
+	})
+	s.Require().NoError(err)
+
+	var lines []string
+	for {
+		l, p, err := pktline.ReadLine(&out)
+		if err == io.EOF {
+			return lines, nil
+		}
+		s.Require().NoError(err)
+
+		switch l {
+		case pktline.Flush, pktline.Delim:
+			lines = append(lines, fmt.Sprintf("%04d", l))
+		default:
+			lines = append(lines, strings.TrimSuffix(string(p), "\n"))
+		}
+
+		if lines[len(lines)-1] == "packfile" {
+			return lines, &out
+		}
+	}
+}
+
+func (s *UploadPackSuite) TestUploadPackV2LsRefs() {
+	lines, _ := s.uploadPackV2("ls-refs", "symrefs", "ref-prefix HEAD", "ref-prefix refs/heads/")
+	s.Equal([]string{
+		"6ecf0ef2c2dffb796033e5a02219af86ec6584e5 HEAD symref-target:refs/heads/master",
+		"e8d3ffab552895c19b9fcf7aa264d277cde33881 refs/heads/branch",
+		"6ecf0ef2c2dffb796033e5a02219af86ec6584e5 refs/heads/master",
+		"0000",
+	}, lines)
+}
+
+func (s *UploadPackSuite) TestUploadPackV2Fetch() {
+	master := plumbing.NewHash("6ecf0ef2c2dffb796033e5a02219af86ec6584e5")
+	lines, pack := s.uploadPackV2("fetch", "want "+master.String(), "ofs-delta", "done")
+	s.Equal([]string{"packfile"}, lines)
+
+	st := memory.NewStorage()
+	s.Require().NoError(packfile.UpdateObjectStorage(st, sideband.NewDemuxer(sideband.Sideband64k, pack)))
+
+	commit, err := object.GetCommit(st, master)
+	s.Require().NoError(err)
+	_, err = commit.Tree()
+	s.NoError(err)
+}
+
+func (s *UploadPackSuite) TestUploadPackV2FetchAcknowledgments() {
+	master := "6ecf0ef2c2dffb796033e5a02219af86ec6584e5"
+	parent := "918c48b83bd081e863dbe1b80f8998f058cd8294"
+	unknown := "1111111111111111111111111111111111111111"
+
+	lines, _ := s.uploadPackV2("fetch", "want "+master, "have "+unknown)
+	s.Equal([]string{"acknowledgments", "NAK", "0000"}, lines)
+
+	lines, pack := s.uploadPackV2("fetch", "want "+master, "have "+unknown, "have "+parent)
+	s.Equal([]string{"acknowledgments", "ACK " + parent, "ready", "0001", "packfile"}, lines)
+	s.NotNil(pack)
+}
+
+func (s *UploadPackSuite) TestUploadPackV2FetchNegotiationRounds() {
+	master := "6ecf0ef2c2dffb796033e5a02219af86ec6584e5"
+	branch := "e8d3ffab552895c19b9fcf7aa264d277cde33881"
+	base := "918c48b83bd081e863dbe1b80f8998f058cd8294"
+	unknown := "1111111111111111111111111111111111111111"
+	wants := []string{"want " + master, "want " + branch}
+
+	lines, _ := s.uploadPackV2("fetch", append(wants, "have "+unknown)...)
+	s.Equal([]string{"acknowledgments", "NAK", "0000"}, lines)
+
+	// The history of branch doesn't have any common commit yet, so the
+	// client is expected to send more haves.
+	lines, _ = s.uploadPackV2("fetch", append(wants, "have "+master)...)
+	s.Equal([]string{"acknowledgments", "ACK " + master, "0000"}, lines)
+
+	lines, pack := s.uploadPackV2("fetch", append(wants, "have "+master, "have "+base)...)
+	s.Equal([]string{"acknowledgments", "ACK " + master, "ACK " + base, "ready", "0001", "packfile"}, lines)
+	s.NotNil(pack)
+}
+
+func (s *UploadPackSuite) TestUploadPackV2FetchWaitForDone() {
+	master := "6ecf0ef2c2dffb796033e5a02219af86ec6584e5"
+	parent := "918c48b83bd081e863dbe1b80f8998f058cd8294"
+
+	lines, _ := s.uploadPackV2("fetch", "want "+master, "have "+parent, "wait-for-done")
+	s.Equal([]string{"acknowledgments", "ACK " + parent, "0000"}, lines)
+
+	lines, pack := s.uploadPackV2("fetch", "want "+master, "have "+parent, "wait-for-done", "done")
+	s.Equal([]string{"packfile"}, lines)
+	s.NotNil(pack)
+}
+
+func (s *UploadPackSuite) TestUploadPackV2ObjectInfo() {
+	lines, _ := s.uploadPackV2("object-info", "size",
+		"oid 6ecf0ef2c2dffb796033e5a02219af86ec6584e5",
+		"oid 32858aad3c383ed1ff0a0f9bdf231d54a00c9e88",
+		"oid 0000000000000000000000000000000000000001",
+	)
+	s.Equal([]string{
+		"size",
+		"6ecf0ef2c2dffb796033e5a02219af86ec6584e5 245",
+		"32858aad3c383ed1ff0a0f9bdf231d54a00c9e88 189",
+		"0000000000000000000000000000000000000001 ",
+		"0000",
+	}, lines)
+}
+
+func (s *UploadPackSuite) TestUploadPackV2UnsupportedCommand() {
+	var req, out bytes.Buffer
+	pktline.Writeln(&req, "command=bundle-uri") //nolint:errcheck
+	pktline.WriteFlush(&req)                    //nolint:errcheck
+
+	err := UploadPack(context.TODO(), memory.NewStorage(), io.NopCloser(&req), ioutil.WriteNopCloser(&out),
+		&UploadPackOptions{GitProtocol: "version=2", StatelessRPC: true})
+	s.ErrorIs(err, ErrUnsupportedCommand)
+}
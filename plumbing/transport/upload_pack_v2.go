@@ -0,0 +1,576 @@
+package transport
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/go-git/go-git/v6/plumbing/format/packfile"
+	"github.com/go-git/go-git/v6/plumbing/format/pktline"
+	"github.com/go-git/go-git/v6/plumbing/object"
+	"github.com/go-git/go-git/v6/plumbing/protocol/packp"
+	"github.com/go-git/go-git/v6/plumbing/protocol/packp/capability"
+	"github.com/go-git/go-git/v6/plumbing/protocol/packp/sideband"
+	"github.com/go-git/go-git/v6/plumbing/storer"
+	"github.com/go-git/go-git/v6/storage"
+	"github.com/go-git/go-git/v6/utils/ioutil"
+)
+
+// commandV2 is a protocol v2 command request.
+type commandV2 struct {
+	name string
+	args []string
+}
+
+// uploadPackV2 serves the upload-pack service using protocol v2. It
+// advertises the capabilities of the server, then runs the commands sent by
+// the client, ls-refs, fetch and object-info. When using stateless RPC, only
+// one command is read.
+func uploadPackV2(
+	ctx context.Context,
+	st storage.Storer,
+	r io.ReadCloser,
+	w io.WriteCloser,
+	opts *UploadPackOptions,
+) error {
+	if opts.AdvertiseRefs || !opts.StatelessRPC {
+		if err := advertiseCapabilitiesV2(w); err != nil {
+			return fmt.Errorf("advertising capabilities: %w", err)
+		}
+	}
+
+	if opts.AdvertiseRefs {
+		// Done, there's nothing else to do
+		return nil
+	}
+
+	if r == nil {
+		return fmt.Errorf("nil reader")
+	}
+
+	r = ioutil.NewContextReadCloser(ctx, r)
+	rd := bufio.NewReader(r)
+	for {
+		cmd, err := readCommandV2(rd)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("reading command: %w", err)
+		}
+
+		// A flush packet alone ends the session.
+		if cmd == nil {
+			break
+		}
+
+		switch cmd.name {
+		case "ls-refs":
+			err = lsRefsV2(st, w, cmd.args)
+		case "fetch":
+			err = fetchV2(st, w, cmd.args)
+		case capability.ObjectInfo.String():
+			err = objectInfoV2(st, w, cmd.args)
+		default:
+			err = fmt.Errorf("%w: %q", ErrUnsupportedCommand, cmd.name)
+			pktline.WriteError(w, err) //nolint:errcheck
+		}
+		if err != nil {
+			return err
+		}
+
+		if opts.StatelessRPC {
+			break
+		}
+	}
+
+	if err := r.Close(); err != nil {
+		return fmt.Errorf("closing reader: %w", err)
+	}
+
+	return w.Close()
+}
+
+// advertiseCapabilitiesV2 writes the capability advertisement of protocol v2.
+func advertiseCapabilitiesV2(w io.Writer) error {
+	lines := []string{
+		"version 2",
+		capability.Agent.String() + "=" + capability.DefaultAgent(),
+		"ls-refs",
+		// TODO: support deepen-since, and deepen-not
+		"fetch=shallow wait-for-done",
+		capability.ObjectInfo.String(),
+	}
+
+	for _, l := range lines {
+		if _, err := pktline.Writeln(w, l); err != nil {
+			return err
+		}
+	}
+
+	return pktline.WriteFlush(w)
+}
+
+// readCommandV2 reads a command request, made of the command and capability
+// lines, then the arguments after a delim packet, ending with a flush packet.
+// It returns a nil command if the request is a lone flush packet.
+func readCommandV2(rd io.Reader) (*commandV2, error) {
+	var cmd *commandV2
+	inArgs := false
+	for {
+		l, p, err := pktline.ReadLine(rd)
+		if err != nil {
+			if cmd != nil && errors.Is(err, io.EOF) {
+				err = io.ErrUnexpectedEOF
+			}
+
+			return nil, err
+		}
+
+		switch l {
+		case pktline.Flush:
+			return cmd, nil
+		case pktline.Delim:
+			inArgs = true
+			continue
+		}
+
+		line := strings.TrimSuffix(string(p), "\n")
+		switch {
+		case cmd == nil:
+			name, ok := strings.CutPrefix(line, "command=")
+			if !ok {
+				return nil, fmt.Errorf("%w: expected command, got %q", ErrInvalidRequest, line)
+			}
+
+			cmd = &commandV2{name: name}
+		case inArgs:
+			cmd.args = append(cmd.args, line)
+		}
+	}
+}
+
+// lsRefsV2 runs the ls-refs command, listing the references of the
+// repository matching the requested prefixes, if any.
+func lsRefsV2(st storage.Storer, w io.Writer, args []string) error {
+	var symrefs, peel bool
+	var prefixes []string
+	for _, arg := range args {
+		switch {
+		case arg == "symrefs":
+			symrefs = true
+		case arg == "peel":
+			peel = true
+		case strings.HasPrefix(arg, "ref-prefix "):
+			prefixes = append(prefixes, arg[len("ref-prefix "):])
+		}
+	}
+
+	iter, err := st.IterReferences()
+	if err != nil {
+		return err
+	}
+
+	var refs []*plumbing.Reference
+	if err := iter.ForEach(func(r *plumbing.Reference) error {
+		refs = append(refs, r)
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	sort.Slice(refs, func(i, j int) bool {
+		// HEAD is listed first, as git does.
+		if refs[i].Name() == plumbing.HEAD || refs[j].Name() == plumbing.HEAD {
+			return refs[i].Name() == plumbing.HEAD
+		}
+
+		return refs[i].Name() < refs[j].Name()
+	})
+
+	for _, r := range refs {
+		name := r.Name().String()
+		if !matchesRefPrefix(name, prefixes) {
+			continue
+		}
+
+		hash := r.Hash()
+		if r.Type() == plumbing.SymbolicReference {
+			ref, err := storer.ResolveReference(st, r.Target())
+			if errors.Is(err, plumbing.ErrReferenceNotFound) {
+				continue
+			}
+			if err != nil {
+				return err
+			}
+
+			hash = ref.Hash()
+		}
+
+		line := hash.String() + " " + name
+		if symrefs && r.Type() == plumbing.SymbolicReference {
+			line += " symref-target:" + r.Target().String()
+		}
+
+		if peel && r.Name().IsTag() {
+			if tag, err := object.GetTag(st, hash); err == nil {
+				line += " peeled:" + tag.Target.String()
+			}
+		}
+
+		if _, err := pktline.Writeln(w, line); err != nil {
+			return err
+		}
+	}
+
+	return pktline.WriteFlush(w)
+}
+
+// matchesRefPrefix returns true if there are no prefixes, or the name starts
+// with one of them.
+func matchesRefPrefix(name string, prefixes []string) bool {
+	if len(prefixes) == 0 {
+		return true
+	}
+
+	for _, p := range prefixes {
+		if strings.HasPrefix(name, p) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// objectInfoV2 runs the object-info command, reporting the size of the
+// requested objects. The size is left empty for the objects missing from the
+// repository, as git does.
+func objectInfoV2(st storage.Storer, w io.Writer, args []string) error {
+	var size bool
+	var hashes []plumbing.Hash
+	for _, arg := range args {
+		switch {
+		case arg == "size":
+			size = true
+		case strings.HasPrefix(arg, "oid "):
+			h, ok := plumbing.FromHex(arg[len("oid "):])
+			if !ok {
+				return fmt.Errorf("%w: invalid object id %q", ErrInvalidRequest, arg)
+			}
+
+			hashes = append(hashes, h)
+		}
+	}
+
+	if size {
+		if _, err := pktline.Writeln(w, "size"); err != nil {
+			return err
+		}
+	}
+
+	for _, h := range hashes {
+		line := h.String()
+		if size {
+			line += " "
+			sz, err := st.EncodedObjectSize(h)
+			switch {
+			case err == nil:
+				line += strconv.FormatInt(sz, 10)
+			case !errors.Is(err, plumbing.ErrObjectNotFound):
+				return err
+			}
+		}
+
+		if _, err := pktline.Writeln(w, line); err != nil {
+			return err
+		}
+	}
+
+	return pktline.WriteFlush(w)
+}
+
+// fetchRequestV2 holds the arguments of a fetch command.
+type fetchRequestV2 struct {
+	wants, haves   []plumbing.Hash
+	shallows       []plumbing.Hash
+	depth          int
+	deepenRelative bool
+	done           bool
+	waitForDone    bool
+	useRefDeltas   bool
+}
+
+func parseFetchRequestV2(args []string) (*fetchRequestV2, error) {
+	req := &fetchRequestV2{useRefDeltas: true}
+	for _, arg := range args {
+		key, value, _ := strings.Cut(arg, " ")
+		switch key {
+		case "want", "have", "shallow":
+			h, ok := plumbing.FromHex(value)
+			if !ok {
+				return nil, fmt.Errorf("%w: invalid %s %q", ErrInvalidRequest, key, value)
+			}
+
+			switch key {
+			case "want":
+				req.wants = append(req.wants, h)
+			case "have":
+				req.haves = append(req.haves, h)
+			default:
+				req.shallows = append(req.shallows, h)
+			}
+		case "deepen":
+			depth, err := strconv.Atoi(value)
+			if err != nil || depth <= 0 {
+				return nil, fmt.Errorf("%w: invalid deepen %q", ErrInvalidRequest, value)
+			}
+
+			req.depth = depth
+		case "deepen-relative":
+			req.deepenRelative = true
+		case "deepen-since", "deepen-not":
+			return nil, fmt.Errorf("%w: %s is not supported", ErrInvalidRequest, key)
+		case "done":
+			req.done = true
+		case "wait-for-done":
+			req.waitForDone = true
+		case "ofs-delta":
+			req.useRefDeltas = false
+		}
+		// Other arguments, e.g. thin-pack or include-tag, are optimizations
+		// that can be ignored, and no progress is sent.
+	}
+
+	if len(req.wants) == 0 {
+		return nil, ErrEmptyUploadPackRequest
+	}
+
+	return req, nil
+}
+
+// fetchV2 runs the fetch command. The haves of the client are acknowledged
+// until it is done, or the server is ready to send the packfile, then the
+// packfile is sent, along with the shallow commits when deepening.
+func fetchV2(st storage.Storer, w io.Writer, args []string) error {
+	req, err := parseFetchRequestV2(args)
+	if err != nil {
+		pktline.WriteError(w, err) //nolint:errcheck
+		return err
+	}
+
+	var common []plumbing.Hash
+	for _, h := range req.haves {
+		if st.HasEncodedObject(h) == nil {
+			common = append(common, h)
+		}
+	}
+
+	if !req.done {
+		if _, err := pktline.Writeln(w, "acknowledgments"); err != nil {
+			return err
+		}
+
+		if len(common) == 0 {
+			if _, err := pktline.Writeln(w, "NAK"); err != nil {
+				return err
+			}
+		}
+
+		for _, h := range common {
+			if _, err := pktline.Writef(w, "ACK %s\n", h); err != nil {
+				return err
+			}
+		}
+
+		ready, err := readyToSendV2(st, req, common)
+		if err != nil {
+			return err
+		}
+
+		// The client sends more haves in another request, or it is done.
+		if !ready {
+			return pktline.WriteFlush(w)
+		}
+
+		if _, err := pktline.Writeln(w, "ready"); err != nil {
+			return err
+		}
+
+		if err := pktline.WriteDelim(w); err != nil {
+			return err
+		}
+	}
+
+	if req.depth > 0 || len(req.shallows) > 0 {
+		if err := sendShallowInfoV2(st, w, req); err != nil {
+			return err
+		}
+	}
+
+	objs, err := objectsToUpload(st, req.wants, common)
+	if err != nil {
+		return fmt.Errorf("getting objects to upload: %w", err)
+	}
+
+	if _, err := pktline.Writeln(w, "packfile"); err != nil {
+		return err
+	}
+
+	// TODO: Support thin-pack
+	e := packfile.NewEncoder(sideband.NewMuxer(sideband.Sideband64k, w), st, req.useRefDeltas)
+	if _, err := e.Encode(objs, 10); err != nil {
+		return fmt.Errorf("encoding packfile: %w", err)
+	}
+
+	return pktline.WriteFlush(w)
+}
+
+// readyToSendV2 returns whether the negotiation can end, as git does, when
+// every wanted commit has a common commit in its history, so the client has
+// nothing to gain from sending more haves. It is never ready when the client
+// asked to wait for its done.
+func readyToSendV2(st storage.Storer, req *fetchRequestV2, common []plumbing.Hash) (bool, error) {
+	if req.waitForDone || len(common) == 0 {
+		return false, nil
+	}
+
+	isCommon := make(map[plumbing.Hash]bool, len(common))
+	var oldest time.Time
+	for _, h := range common {
+		isCommon[h] = true
+		c, err := object.GetCommit(st, h)
+		if err != nil {
+			continue
+		}
+
+		if oldest.IsZero() || c.Committer.When.Before(oldest) {
+			oldest = c.Committer.When
+		}
+	}
+
+	for _, want := range req.wants {
+		c, err := peelToCommit(st, want)
+		if errors.Is(err, plumbing.ErrObjectNotFound) || errors.Is(err, errNotCommit) {
+			continue
+		}
+
+		if err != nil {
+			return false, err
+		}
+
+		found, err := reachesCommon(st, c, isCommon, oldest)
+		if err != nil || !found {
+			return false, err
+		}
+	}
+
+	return true, nil
+}
+
+// errNotCommit is returned by peelToCommit for the objects not pointing to a
+// commit.
+var errNotCommit = errors.New("object is not a commit")
+
+// peelToCommit returns the commit with the given hash, following the tags.
+func peelToCommit(st storage.Storer, h plumbing.Hash) (*object.Commit, error) {
+	obj, err := object.GetObject(st, h)
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		switch o := obj.(type) {
+		case *object.Commit:
+			return o, nil
+		case *object.Tag:
+			if obj, err = o.Object(); err != nil {
+				return nil, err
+			}
+		default:
+			return nil, errNotCommit
+		}
+	}
+}
+
+// reachesCommon returns whether a common commit is reachable from c. The
+// commits older than the oldest common one are not walked further, as git
+// does.
+func reachesCommon(st storage.Storer, c *object.Commit, isCommon map[plumbing.Hash]bool, oldest time.Time) (bool, error) {
+	seen := map[plumbing.Hash]bool{c.Hash: true}
+	pending := []*object.Commit{c}
+	for len(pending) > 0 {
+		c := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+
+		if isCommon[c.Hash] {
+			return true, nil
+		}
+
+		if c.Committer.When.Before(oldest) {
+			continue
+		}
+
+		for _, h := range c.ParentHashes {
+			if seen[h] {
+				continue
+			}
+
+			seen[h] = true
+			p, err := object.GetCommit(st, h)
+			if errors.Is(err, plumbing.ErrObjectNotFound) {
+				// The parents of the shallow commits are missing.
+				continue
+			}
+
+			if err != nil {
+				return false, err
+			}
+
+			pending = append(pending, p)
+		}
+	}
+
+	return false, nil
+}
+
+// sendShallowInfoV2 writes the shallow-info section of a fetch response.
+func sendShallowInfoV2(st storage.Storer, w io.Writer, req *fetchRequestV2) error {
+	var shupd packp.ShallowUpdate
+	if req.depth > 0 {
+		heads, n := req.wants, req.depth
+		if req.deepenRelative {
+			// The depth is counted from the current shallow commits of the
+			// client, which are at depth 1.
+			heads, n = req.shallows, n+1
+		}
+
+		if err := getShallowCommits(st, heads, n, req.shallows, &shupd); err != nil {
+			return fmt.Errorf("getting shallow commits: %w", err)
+		}
+	}
+
+	if _, err := pktline.Writeln(w, "shallow-info"); err != nil {
+		return err
+	}
+
+	for _, h := range shupd.Shallows {
+		if _, err := pktline.Writef(w, "shallow %s\n", h); err != nil {
+			return err
+		}
+	}
+
+	for _, h := range shupd.Unshallows {
+		if _, err := pktline.Writef(w, "unshallow %s\n", h); err != nil {
+			return err
+		}
+	}
+
+	return pktline.WriteDelim(w)
+}
@@ -0,0 +1,124 @@
+package git
+
+import (
+	"context"
+	"io"
+	"sort"
+
+	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/go-git/go-git/v6/plumbing/object"
+	"github.com/go-git/go-git/v6/plumbing/protocol/packp"
+	"github.com/go-git/go-git/v6/storage"
+)
+
+// promisorStorage wraps the storer of a partial clone. The blobs and trees
+// omitted by the filter used when cloning are not stored locally, instead
+// they are promised by the promisor remote. As git does, any of those objects
+// missing from the storer is considered promised and fetched on demand.
+type promisorStorage struct {
+	storage.Storer
+	fetch func(hashes ...plumbing.Hash) error
+}
+
+// EncodedObject honors storer.EncodedObjectStorer, fetching the promised
+// blobs and trees missing from the underlying storer.
+func (s *promisorStorage) EncodedObject(t plumbing.ObjectType, h plumbing.Hash) (plumbing.EncodedObject, error) {
+	obj, err := s.Storer.EncodedObject(t, h)
+	if err != plumbing.ErrObjectNotFound || (t != plumbing.BlobObject && t != plumbing.TreeObject) {
+		return obj, err
+	}
+
+	if err := s.fetch(h); err != nil {
+		return nil, err
+	}
+
+	return s.Storer.EncodedObject(t, h)
+}
+
+// promisorStorer returns the storer used to read the blobs and trees of the
+// repository, fetching the ones missing from a partial clone from its
+// promisor remote. It is the repository storer if there is no promisor
+// remote.
+func (r *Repository) promisorStorer() storage.Storer {
+	if r.fetchPromised == nil {
+		return r.Storer
+	}
+
+	return &promisorStorage{Storer: r.Storer, fetch: r.fetchPromised}
+}
+
+// setupPromisorRemote makes the objects omitted from a partial clone available
+// on demand, fetching them from the promisor remote with the given options.
+// Nothing is fetched on demand if there is no promisor remote.
+func (r *Repository) setupPromisorRemote(o *FetchOptions) error {
+	cfg, err := r.Config()
+	if err != nil {
+		return err
+	}
+
+	names := make([]string, 0, len(cfg.Remotes))
+	for name, c := range cfg.Remotes {
+		if c.Promisor {
+			names = append(names, name)
+		}
+	}
+
+	if len(names) == 0 {
+		return nil
+	}
+
+	sort.Strings(names)
+	c := cfg.Remotes[names[0]]
+	if err := c.Validate(); err != nil {
+		return err
+	}
+
+	remote := NewRemote(r.Storer, c)
+	r.fetchPromised = func(hashes ...plumbing.Hash) error {
+		opts := *o
+		opts.RemoteName = c.Name
+		opts.Filter = packp.Filter(c.PartialCloneFilter)
+		return remote.fetchObjects(context.Background(), &opts, hashes)
+	}
+
+	return nil
+}
+
+// fetchPromisedBlobs fetches in a single request the blobs of the given tree
+// missing from a partial clone, avoiding a round trip per file on checkout.
+func (r *Repository) fetchPromisedBlobs(t *object.Tree) error {
+	if r.fetchPromised == nil {
+		return nil
+	}
+
+	var missing []plumbing.Hash
+	w := object.NewTreeWalker(t, true, nil)
+	defer w.Close()
+
+	for {
+		_, e, err := w.Next()
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if !e.Mode.IsFile() {
+			continue
+		}
+
+		if err := r.Storer.HasEncodedObject(e.Hash); err == plumbing.ErrObjectNotFound {
+			missing = append(missing, e.Hash)
+		} else if err != nil {
+			return err
+		}
+	}
+
+	if len(missing) == 0 {
+		return nil
+	}
+
+	return r.fetchPromised(missing...)
+}
@@ -0,0 +1,119 @@
+package git
+
+import (
+	"bytes"
+	"fmt"
+	neturl "net/url"
+	"strings"
+
+	"github.com/go-git/go-git/v6/internal/url"
+	"github.com/go-git/go-git/v6/plumbing/object"
+	"github.com/go-git/go-git/v6/plumbing/protocol/packp"
+	"github.com/go-git/go-git/v6/plumbing/protocol/packp/capability"
+	"github.com/go-git/go-git/v6/plumbing/transport"
+)
+
+// pushSigner returns the signer of the push certificate, nil if the push
+// isn't signed.
+func (o *PushOptions) pushSigner() Signer {
+	if o.Signer != nil {
+		return o.Signer
+	}
+
+	if o.SignKey != nil {
+		return &gpgSigner{key: o.SignKey}
+	}
+
+	return nil
+}
+
+// pushCertNonce returns the nonce the server asks to be included in the push
+// certificate, advertised in the push-cert capability.
+func pushCertNonce(caps *capability.List) (string, error) {
+	values := caps.Get(capability.PushCert)
+	if len(values) == 0 || values[0] == "" {
+		return "", transport.ErrPushCertNotSupported
+	}
+
+	return values[0], nil
+}
+
+// pushCertificate builds the push certificate for the given commands, signed
+// by signer, as `git push --signed` does.
+func (r *Remote) pushCertificate(
+	caps *capability.List,
+	cmds []*packp.Command,
+	signer Signer,
+	o *PushOptions,
+) (*packp.PushCertificate, error) {
+	nonce, err := pushCertNonce(caps)
+	if err != nil {
+		return nil, err
+	}
+
+	pusher, err := r.pusher(o)
+	if err != nil {
+		return nil, err
+	}
+
+	var ident bytes.Buffer
+	if err := pusher.Encode(&ident); err != nil {
+		return nil, err
+	}
+
+	cert := &packp.PushCertificate{
+		Pusher:   ident.String(),
+		Pushee:   anonymizeURL(o.RemoteURL),
+		Nonce:    nonce,
+		Options:  o.Options,
+		Commands: cmds,
+	}
+
+	sig, err := signer.Sign(bytes.NewReader(cert.Payload()))
+	if err != nil {
+		return nil, fmt.Errorf("signing push certificate: %w", err)
+	}
+
+	cert.Signature = sig
+	return cert, nil
+}
+
+// pusher returns the identity of the pusher, PushOptions.Pusher or the
+// committer from the config.
+func (r *Remote) pusher(o *PushOptions) (*object.Signature, error) {
+	if o.Pusher != nil {
+		return o.Pusher, nil
+	}
+
+	co := &CommitOptions{}
+	if err := co.loadConfigAuthorAndCommitter(newRepository(r.s, nil)); err != nil {
+		return nil, err
+	}
+
+	if co.Committer != nil {
+		return co.Committer, nil
+	}
+
+	return co.Author, nil
+}
+
+// anonymizeURL removes the user and password from the given URL, as git does
+// for the pushee of push certificates.
+func anonymizeURL(u string) string {
+	switch {
+	case url.MatchesScheme(u):
+		parsed, err := neturl.Parse(u)
+		if err != nil || parsed.User == nil {
+			return u
+		}
+
+		parsed.User = nil
+		return parsed.String()
+	case url.MatchesScpLike(u):
+		if i := strings.Index(u, "@"); i >= 0 {
+			return u[i+1:]
+		}
+	}
+
+	return u
+}
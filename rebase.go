@@ -0,0 +1,561 @@
+package git
+
+import (
+	"errors"
+	"io"
+
+	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/go-git/go-git/v6/plumbing/format/index"
+	"github.com/go-git/go-git/v6/plumbing/object"
+)
+
+var (
+	// ErrRebaseInProgress is returned when an operation is attempted while a
+	// rebase is in progress.
+	ErrRebaseInProgress = errors.New("a rebase is already in progress")
+	// ErrNoRebaseInProgress is returned when continuing, skipping or aborting
+	// a rebase while none is in progress.
+	ErrNoRebaseInProgress = errors.New("no rebase in progress")
+	// ErrRebaseMissingUpstream is returned when a rebase is started without
+	// an upstream commit.
+	ErrRebaseMissingUpstream = errors.New("upstream commit is required")
+)
+
+// rebaseHeadName records, while a rebase is in progress, the branch being
+// rebased as a symbolic reference, or the original commit when HEAD was
+// detached, as the head-name and orig-head files of git do. The branch itself
+// is only updated once the rebase is done.
+const rebaseHeadName plumbing.ReferenceName = "REBASE_HEAD_NAME"
+
+// RebaseResult holds the outcome of a rebase.
+type RebaseResult struct {
+	// Head is the commit HEAD points to, the tip of the rebased branch once
+	// done, or the last commit replayed when the rebase stopped.
+	Head plumbing.Hash
+	// Stopped is the commit whose changes conflict with HEAD, the zero hash
+	// when the rebase is done.
+	Stopped plumbing.Hash
+	// Conflicts lists the paths that could not be merged automatically.
+	Conflicts []MergeConflict
+	// Skipped lists the commits not replayed because their changes were
+	// already applied.
+	Skipped []plumbing.Hash
+}
+
+// HasConflicts returns true if the rebase stopped because of conflicts.
+func (r *RebaseResult) HasConflicts() bool {
+	return len(r.Conflicts) > 0
+}
+
+// Rebase replays the commits of the branch not reachable from the upstream on
+// top of the onto commit, one at a time, as CherryPick does, keeping their
+// authors and messages. Merge commits are dropped, and the commits whose
+// changes are already applied are skipped. The branch is updated once every
+// commit is replayed, with HEAD detached meanwhile.
+//
+// When the changes of a commit conflict, the rebase stops, leaving the
+// conflicts in the index and the worktree as CherryPick does, with
+// REBASE_HEAD pointing to the commit. The rebase is then resumed with
+// RebaseContinue once the conflicts are resolved and added to the index,
+// RebaseSkip, or undone with RebaseAbort.
+//
+// ErrWorktreeNotClean is returned if the worktree has uncommitted changes.
+func (w *Worktree) Rebase(opts *RebaseOptions) (*RebaseResult, error) {
+	if opts == nil {
+		opts = &RebaseOptions{}
+	}
+
+	if opts.Upstream.IsZero() {
+		return nil, ErrRebaseMissingUpstream
+	}
+
+	if err := opts.Validate(w.r); err != nil {
+		return nil, err
+	}
+
+	head, tip, err := w.prepareRebase(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	commits, err := w.r.rebaseCommits(tip, opts.Upstream)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := w.beginRebase(head, tip, opts.Onto); err != nil {
+		return nil, err
+	}
+
+	return w.replayCommits(commits, &RebaseResult{}, opts)
+}
+
+// prepareRebase checks that no other operation is in progress and that the
+// worktree is clean, then checks out the branch of the options, if any. HEAD
+// and the commit it points to are returned.
+func (w *Worktree) prepareRebase(opts *RebaseOptions) (*plumbing.Reference, plumbing.Hash, error) {
+	inProgress := []struct {
+		name plumbing.ReferenceName
+		err  error
+	}{
+		{plumbing.MergeHead, ErrMergeInProgress},
+		{plumbing.CherryPickHead, ErrCherryPickInProgress},
+		{plumbing.RevertHead, ErrRevertInProgress},
+		{rebaseHeadName, ErrRebaseInProgress},
+	}
+
+	for _, p := range inProgress {
+		_, err := w.r.Storer.Reference(p.name)
+		if err == nil {
+			return nil, plumbing.ZeroHash, p.err
+		}
+
+		if err != plumbing.ErrReferenceNotFound {
+			return nil, plumbing.ZeroHash, err
+		}
+	}
+
+	if err := w.checkRebaseClean(); err != nil {
+		return nil, plumbing.ZeroHash, err
+	}
+
+	if opts.Branch != "" {
+		if err := w.Checkout(&CheckoutOptions{Branch: opts.Branch}); err != nil {
+			return nil, plumbing.ZeroHash, err
+		}
+	}
+
+	head, err := w.r.Storer.Reference(plumbing.HEAD)
+	if err != nil {
+		return nil, plumbing.ZeroHash, err
+	}
+
+	tip, err := w.r.Head()
+	if err != nil {
+		return nil, plumbing.ZeroHash, err
+	}
+
+	return head, tip.Hash(), nil
+}
+
+// beginRebase records the rebase of the given HEAD, pointing to tip, then
+// detaches HEAD at onto, where the commits are replayed.
+func (w *Worktree) beginRebase(head *plumbing.Reference, tip, onto plumbing.Hash) error {
+	headName := plumbing.NewHashReference(rebaseHeadName, tip)
+	if head.Type() == plumbing.SymbolicReference {
+		headName = plumbing.NewSymbolicReference(rebaseHeadName, head.Target())
+	}
+
+	for _, ref := range []*plumbing.Reference{
+		headName,
+		plumbing.NewHashReference(plumbing.OrigHead, tip),
+		plumbing.NewHashReference(plumbing.HEAD, tip),
+	} {
+		if err := w.r.Storer.SetReference(ref); err != nil {
+			return err
+		}
+	}
+
+	return w.reset(&ResetOptions{Mode: HardReset, Commit: onto}, "rebase (start): checkout "+onto.String())
+}
+
+// RebaseContinue resumes a rebase stopped by conflicts, once they are
+// resolved and added to the index. The stopped commit is replayed with the
+// content of the index, or skipped if it has no changes, as its item
+// requires when a RebasePlan is executed. Only the Committer of the options
+// is used.
+//
+// ErrUnmergedPaths is returned if the index still has conflicts, and
+// ErrWorktreeNotClean if the worktree has changes not added to the index.
+func (w *Worktree) RebaseContinue(opts *RebaseOptions) (*RebaseResult, error) {
+	if opts == nil {
+		opts = &RebaseOptions{}
+	}
+
+	stopped, err := w.r.rebaseStopped()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := opts.Validate(w.r); err != nil {
+		return nil, err
+	}
+
+	idx, err := w.r.Storer.Index()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, e := range idx.Entries {
+		if e.Stage != index.Merged {
+			return nil, ErrUnmergedPaths
+		}
+	}
+
+	status, err := w.Status()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, fs := range status {
+		if !isUntrackedOrUnmodified(fs.Worktree) {
+			return nil, ErrWorktreeNotClean
+		}
+	}
+
+	h := &buildTreeHelper{fs: w.Filesystem, s: w.r.Storer}
+	tree, err := h.BuildTree(idx, &CommitOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	head, err := w.r.Head()
+	if err != nil {
+		return nil, err
+	}
+
+	commit, err := w.r.CommitObject(head.Hash())
+	if err != nil {
+		return nil, err
+	}
+
+	todo, err := w.r.rebaseTodo()
+	if err != nil {
+		return nil, err
+	}
+
+	action, msg := RebasePick, stopped.Message
+	if len(todo) > 0 {
+		action = todo[0].Action
+		if action == RebaseReword {
+			msg = todo[0].Message
+		}
+	}
+
+	res := &RebaseResult{}
+	switch {
+	case action == RebaseSquash || action == RebaseFixup:
+		if err := w.squashCommit(action, stopped, tree, opts.Committer); err != nil {
+			return nil, err
+		}
+	case tree == commit.TreeHash:
+		res.Skipped = append(res.Skipped, stopped.Hash)
+	default:
+		_, err := w.commitPick(&stopped.Author, msg, head.Hash(), tree, opts.Committer, "rebase (continue): ")
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return w.resumeRebase(stopped, res, opts)
+}
+
+// RebaseSkip resumes a rebase stopped by conflicts, discarding the changes of
+// the stopped commit. Only the Committer of the options is used.
+func (w *Worktree) RebaseSkip(opts *RebaseOptions) (*RebaseResult, error) {
+	if opts == nil {
+		opts = &RebaseOptions{}
+	}
+
+	stopped, err := w.r.rebaseStopped()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := opts.Validate(w.r); err != nil {
+		return nil, err
+	}
+
+	head, err := w.r.Head()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := w.reset(&ResetOptions{Mode: HardReset, Commit: head.Hash()}, "rebase (skip)"); err != nil {
+		return nil, err
+	}
+
+	res := &RebaseResult{Skipped: []plumbing.Hash{stopped.Hash}}
+	return w.resumeRebase(stopped, res, opts)
+}
+
+// RebaseAbort undoes a rebase in progress, restoring HEAD, the index and the
+// worktree to the state before the rebase started.
+func (w *Worktree) RebaseAbort() error {
+	headName, err := w.r.Storer.Reference(rebaseHeadName)
+	if err == plumbing.ErrReferenceNotFound {
+		return ErrNoRebaseInProgress
+	}
+	if err != nil {
+		return err
+	}
+
+	orig, err := w.r.rebaseOrigHead(headName)
+	if err != nil {
+		return err
+	}
+
+	head := plumbing.NewHashReference(plumbing.HEAD, orig)
+	if headName.Type() == plumbing.SymbolicReference {
+		head = plumbing.NewSymbolicReference(plumbing.HEAD, headName.Target())
+	}
+
+	if err := w.r.Storer.SetReference(head); err != nil {
+		return err
+	}
+
+	if err := w.reset(&ResetOptions{Mode: HardReset, Commit: orig}, "rebase (abort): returning to "+orig.String()); err != nil {
+		return err
+	}
+
+	return w.r.removeRebaseState()
+}
+
+// checkRebaseClean returns ErrWorktreeNotClean if the index or the worktree
+// have uncommitted changes.
+func (w *Worktree) checkRebaseClean() error {
+	status, err := w.Status()
+	if err != nil {
+		return err
+	}
+
+	for _, fs := range status {
+		if !isUntrackedOrUnmodified(fs.Staging) || !isUntrackedOrUnmodified(fs.Worktree) {
+			return ErrWorktreeNotClean
+		}
+	}
+
+	return nil
+}
+
+// resumeRebase replays the commits of the rebase in progress following the
+// stopped one, or the rest of the plan being executed.
+func (w *Worktree) resumeRebase(stopped *object.Commit, res *RebaseResult, opts *RebaseOptions) (*RebaseResult, error) {
+	if err := w.r.removeReference(plumbing.RebaseHead); err != nil {
+		return nil, err
+	}
+
+	todo, err := w.r.rebaseTodo()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(todo) > 0 {
+		return w.replayPlan(todo[1:], res, opts)
+	}
+
+	headName, err := w.r.Storer.Reference(rebaseHeadName)
+	if err != nil {
+		return nil, err
+	}
+
+	orig, err := w.r.rebaseOrigHead(headName)
+	if err != nil {
+		return nil, err
+	}
+
+	commits, err := w.r.rebaseCommits(orig, stopped.Hash)
+	if err != nil {
+		return nil, err
+	}
+
+	return w.replayCommits(commits, res, opts)
+}
+
+// replayCommits applies the changes of each commit on top of HEAD, stopping
+// on the first conflict, then finishes the rebase.
+func (w *Worktree) replayCommits(commits []*object.Commit, res *RebaseResult, opts *RebaseOptions) (*RebaseResult, error) {
+	for _, c := range commits {
+		head, err := w.r.Head()
+		if err != nil {
+			return nil, err
+		}
+
+		// The commit is already on top of HEAD, so it is kept as is.
+		if c.NumParents() == 1 && c.ParentHashes[0] == head.Hash() {
+			err := w.reset(&ResetOptions{Mode: HardReset, Commit: c.Hash}, "rebase (pick): "+commitSubject(c.Message))
+			if err != nil {
+				return nil, err
+			}
+
+			continue
+		}
+
+		status, err := w.Status()
+		if err != nil {
+			return nil, err
+		}
+
+		_, mr, tree, err := w.pickCommit(c, status, &CherryPickOptions{Committer: opts.Committer})
+		if errors.Is(err, ErrEmptyCommit) {
+			res.Skipped = append(res.Skipped, c.Hash)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if len(mr.conflicts) > 0 {
+			err := w.r.Storer.SetReference(plumbing.NewHashReference(plumbing.RebaseHead, c.Hash))
+			if err != nil {
+				return nil, err
+			}
+
+			res.Head = head.Hash()
+			res.Stopped = c.Hash
+			res.Conflicts = mr.conflicts
+			return res, nil
+		}
+
+		_, err = w.commitPick(&c.Author, c.Message, head.Hash(), tree.Hash, opts.Committer, "rebase (pick): ")
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return w.finishRebase(res, opts)
+}
+
+// finishRebase updates the rebased branch to HEAD, checks it out again, and
+// removes the state of the rebase.
+func (w *Worktree) finishRebase(res *RebaseResult, opts *RebaseOptions) (*RebaseResult, error) {
+	headName, err := w.r.Storer.Reference(rebaseHeadName)
+	if err != nil {
+		return nil, err
+	}
+
+	head, err := w.r.Head()
+	if err != nil {
+		return nil, err
+	}
+
+	if headName.Type() == plumbing.SymbolicReference {
+		branch := headName.Target()
+		old, err := w.r.referenceHash(branch)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, ref := range []*plumbing.Reference{
+			plumbing.NewHashReference(branch, head.Hash()),
+			plumbing.NewSymbolicReference(plumbing.HEAD, branch),
+		} {
+			if err := w.r.Storer.SetReference(ref); err != nil {
+				return nil, err
+			}
+		}
+
+		msg := "rebase (finish): returning to " + branch.String()
+		if err := w.r.logRefUpdate(branch, old, head.Hash(), opts.Committer, msg); err != nil {
+			return nil, err
+		}
+	}
+
+	res.Head = head.Hash()
+	return res, w.r.removeRebaseState()
+}
+
+// rebaseCommits returns the commits reachable from tip and not from upstream,
+// oldest first, without the merge commits.
+func (r *Repository) rebaseCommits(tip, upstream plumbing.Hash) ([]*object.Commit, error) {
+	c, err := r.CommitObject(tip)
+	if err != nil {
+		return nil, err
+	}
+
+	u, err := r.CommitObject(upstream)
+	if err != nil {
+		return nil, err
+	}
+
+	ignore := []plumbing.Hash{upstream}
+	bases, err := c.MergeBase(u)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, b := range bases {
+		ignore = append(ignore, b.Hash)
+	}
+
+	var commits []*object.Commit
+	iter := object.NewCommitPreorderIter(c, nil, ignore)
+	defer iter.Close()
+
+	for {
+		c, err := iter.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if c.NumParents() > 1 {
+			continue
+		}
+
+		commits = append(commits, c)
+	}
+
+	for i, j := 0, len(commits)-1; i < j; i, j = i+1, j-1 {
+		commits[i], commits[j] = commits[j], commits[i]
+	}
+
+	return commits, nil
+}
+
+// rebaseStopped returns the commit the rebase in progress stopped at, or
+// ErrNoRebaseInProgress.
+func (r *Repository) rebaseStopped() (*object.Commit, error) {
+	for _, name := range []plumbing.ReferenceName{rebaseHeadName, plumbing.RebaseHead} {
+		_, err := r.Storer.Reference(name)
+		if err == plumbing.ErrReferenceNotFound {
+			return nil, ErrNoRebaseInProgress
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	ref, err := r.Storer.Reference(plumbing.RebaseHead)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.CommitObject(ref.Hash())
+}
+
+// rebaseOrigHead returns the commit rebased, the tip of the branch recorded
+// in REBASE_HEAD_NAME or the commit itself when HEAD was detached.
+func (r *Repository) rebaseOrigHead(headName *plumbing.Reference) (plumbing.Hash, error) {
+	if headName.Type() == plumbing.HashReference {
+		return headName.Hash(), nil
+	}
+
+	return r.referenceHash(headName.Target())
+}
+
+// removeRebaseState removes the references recording a rebase in progress.
+func (r *Repository) removeRebaseState() error {
+	for _, name := range []plumbing.ReferenceName{plumbing.RebaseHead, rebaseHeadName, rebaseTodoName} {
+		if err := r.removeReference(name); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// removeReference removes the given reference, if it exists.
+func (r *Repository) removeReference(name plumbing.ReferenceName) error {
+	_, err := r.Storer.Reference(name)
+	if err == plumbing.ErrReferenceNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	return r.Storer.RemoveReference(name)
+}
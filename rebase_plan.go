@@ -0,0 +1,392 @@
+package git
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/go-git/go-git/v6/plumbing/object"
+)
+
+var (
+	// ErrInvalidRebaseAction is returned when a RebasePlan holds an unknown
+	// action.
+	ErrInvalidRebaseAction = errors.New("invalid rebase action")
+	// ErrRebaseMissingMessage is returned when a commit of a RebasePlan is
+	// reworded without a message.
+	ErrRebaseMissingMessage = errors.New("reword requires a message")
+	// ErrRebaseSquashWithoutPick is returned when a RebasePlan squashes or
+	// fixes up a commit before picking any, as there's no commit to meld it
+	// into.
+	ErrRebaseSquashWithoutPick = errors.New("cannot squash or fixup without a previous commit")
+)
+
+// rebaseTodoName records, while a RebasePlan stopped by conflicts, the blob
+// holding the items not executed yet, the stopped one first, as the
+// git-rebase-todo file of git does.
+const rebaseTodoName plumbing.ReferenceName = "REBASE_TODO"
+
+// RebaseAction is the action applied to a commit of a RebasePlan.
+type RebaseAction int8
+
+const (
+	// RebasePick replays the commit as is.
+	RebasePick RebaseAction = iota
+	// RebaseReword replays the commit with the message of the item.
+	RebaseReword
+	// RebaseSquash melds the commit into the previous one, concatenating
+	// their messages.
+	RebaseSquash
+	// RebaseFixup melds the commit into the previous one, keeping the
+	// message of the previous one.
+	RebaseFixup
+	// RebaseDrop discards the commit.
+	RebaseDrop
+)
+
+var rebaseActionNames = []string{"pick", "reword", "squash", "fixup", "drop"}
+
+func (a RebaseAction) String() string {
+	if a < 0 || int(a) >= len(rebaseActionNames) {
+		return fmt.Sprintf("RebaseAction(%d)", a)
+	}
+
+	return rebaseActionNames[a]
+}
+
+// RebaseTodo is an item of a RebasePlan, a line of the todo list of
+// `git rebase -i`.
+type RebaseTodo struct {
+	// Action is what is done with the commit.
+	Action RebaseAction
+	// Commit is the commit whose changes are replayed.
+	Commit plumbing.Hash
+	// Message is the new message of the commit, required by RebaseReword
+	// and ignored otherwise.
+	Message string
+}
+
+// RebasePlan lists the commits replayed by Worktree.RebasePlan, in order.
+type RebasePlan []RebaseTodo
+
+// Validate checks that the actions of the plan are valid, that the reworded
+// commits have a message, and that no commit is squashed or fixed up before
+// a commit is picked.
+func (p RebasePlan) Validate() error {
+	picked := false
+	for _, item := range p {
+		switch item.Action {
+		case RebasePick:
+		case RebaseReword:
+			if item.Message == "" {
+				return ErrRebaseMissingMessage
+			}
+		case RebaseSquash, RebaseFixup:
+			if !picked {
+				return ErrRebaseSquashWithoutPick
+			}
+		case RebaseDrop:
+			continue
+		default:
+			return ErrInvalidRebaseAction
+		}
+
+		picked = true
+	}
+
+	return nil
+}
+
+// RebasePlan replays the commits of the plan on top of the Onto commit of
+// the options, or Upstream if zero, as `git rebase -i` does with its todo
+// list, without an editor. The picked commits are replayed as Rebase does,
+// the reworded ones with the message of their item, and the squashed and
+// fixed up ones are melded into the previous commit, keeping its author.
+// The message of a squashed commit is appended to the previous one, while
+// the one of a fixed up commit is discarded. The branch is updated once the
+// whole plan is executed, with HEAD detached meanwhile.
+//
+// When the changes of a commit conflict, the plan stops as Rebase does, the
+// rest of it being executed by RebaseContinue once the conflicts are
+// resolved and added to the index, or RebaseSkip, and discarded by
+// RebaseAbort.
+//
+// ErrWorktreeNotClean is returned if the worktree has uncommitted changes.
+func (w *Worktree) RebasePlan(plan RebasePlan, opts *RebaseOptions) (*RebaseResult, error) {
+	if opts == nil {
+		opts = &RebaseOptions{}
+	}
+
+	if opts.Onto.IsZero() && opts.Upstream.IsZero() {
+		return nil, ErrRebaseMissingUpstream
+	}
+
+	if err := plan.Validate(); err != nil {
+		return nil, err
+	}
+
+	if err := opts.Validate(w.r); err != nil {
+		return nil, err
+	}
+
+	for _, item := range plan {
+		if _, err := w.r.CommitObject(item.Commit); err != nil {
+			return nil, fmt.Errorf("%s %s: %w", item.Action, item.Commit, err)
+		}
+	}
+
+	head, tip, err := w.prepareRebase(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := w.beginRebase(head, tip, opts.Onto); err != nil {
+		return nil, err
+	}
+
+	return w.replayPlan(plan, &RebaseResult{}, opts)
+}
+
+// replayPlan executes each item of the plan on top of HEAD, stopping on the
+// first conflict, then finishes the rebase.
+func (w *Worktree) replayPlan(plan RebasePlan, res *RebaseResult, opts *RebaseOptions) (*RebaseResult, error) {
+	for i, item := range plan {
+		if item.Action == RebaseDrop {
+			continue
+		}
+
+		c, err := w.r.CommitObject(item.Commit)
+		if err != nil {
+			return nil, err
+		}
+
+		head, err := w.r.Head()
+		if err != nil {
+			return nil, err
+		}
+
+		// The commit is already on top of HEAD, so it is kept as is.
+		if item.Action == RebasePick && c.NumParents() == 1 && c.ParentHashes[0] == head.Hash() {
+			err := w.reset(&ResetOptions{Mode: HardReset, Commit: c.Hash}, "rebase (pick): "+commitSubject(c.Message))
+			if err != nil {
+				return nil, err
+			}
+
+			continue
+		}
+
+		status, err := w.Status()
+		if err != nil {
+			return nil, err
+		}
+
+		squash := item.Action == RebaseSquash || item.Action == RebaseFixup
+		_, mr, tree, err := w.pickCommit(c, status, &CherryPickOptions{Committer: opts.Committer})
+		if errors.Is(err, ErrEmptyCommit) {
+			if !squash {
+				res.Skipped = append(res.Skipped, c.Hash)
+				continue
+			}
+
+			// The message of a squashed commit is kept even without changes.
+			if err := w.squashCommit(item.Action, c, plumbing.ZeroHash, opts.Committer); err != nil {
+				return nil, err
+			}
+
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if len(mr.conflicts) > 0 {
+			if err := w.r.setRebaseTodo(plan[i:]); err != nil {
+				return nil, err
+			}
+
+			err := w.r.Storer.SetReference(plumbing.NewHashReference(plumbing.RebaseHead, c.Hash))
+			if err != nil {
+				return nil, err
+			}
+
+			res.Head = head.Hash()
+			res.Stopped = c.Hash
+			res.Conflicts = mr.conflicts
+			return res, nil
+		}
+
+		if squash {
+			err = w.squashCommit(item.Action, c, tree.Hash, opts.Committer)
+		} else {
+			msg := c.Message
+			if item.Action == RebaseReword {
+				msg = item.Message
+			}
+
+			_, err = w.commitPick(&c.Author, msg, head.Hash(), tree.Hash, opts.Committer, "rebase ("+item.Action.String()+"): ")
+		}
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return w.finishRebase(res, opts)
+}
+
+// squashCommit melds the given commit into HEAD, replacing HEAD by a commit
+// with the given tree, or the one of HEAD if zero, keeping its author and
+// parents. The message of the commit is appended to the one of HEAD when
+// squashed, and discarded when fixed up, as git does.
+func (w *Worktree) squashCommit(action RebaseAction, c *object.Commit, tree plumbing.Hash, committer *object.Signature) error {
+	head, err := w.r.Head()
+	if err != nil {
+		return err
+	}
+
+	prev, err := w.r.CommitObject(head.Hash())
+	if err != nil {
+		return err
+	}
+
+	if tree.IsZero() {
+		tree = prev.TreeHash
+	}
+
+	msg := prev.Message
+	if action == RebaseSquash {
+		msg = squashMessage(prev.Message, c.Message)
+	}
+
+	if msg == prev.Message && tree == prev.TreeHash {
+		return nil
+	}
+
+	co := &CommitOptions{
+		Author:    &prev.Author,
+		Committer: committer,
+		Parents:   prev.ParentHashes,
+	}
+
+	h, err := w.buildCommitObject(msg, co, tree)
+	if err != nil {
+		return err
+	}
+
+	return w.updateHEAD(h, committer, "rebase ("+action.String()+"): "+commitSubject(msg))
+}
+
+// squashMessage returns the message of a commit squashed into another, their
+// messages separated by a blank line, as git leaves them once the comments
+// added to the message are removed.
+func squashMessage(prev, msg string) string {
+	return strings.TrimRight(prev, "\n") + "\n\n" + strings.TrimRight(msg, "\n") + "\n"
+}
+
+// setRebaseTodo stores the given items as a blob, in the format of the todo
+// list of git, the messages of the reworded commits quoted, and records it
+// in REBASE_TODO.
+func (r *Repository) setRebaseTodo(plan RebasePlan) error {
+	var b strings.Builder
+	for _, item := range plan {
+		fmt.Fprintf(&b, "%s %s", item.Action, item.Commit)
+		if item.Action == RebaseReword {
+			fmt.Fprintf(&b, " %s", strconv.Quote(item.Message))
+		}
+
+		b.WriteByte('\n')
+	}
+
+	obj := r.Storer.NewEncodedObject()
+	obj.SetType(plumbing.BlobObject)
+
+	wr, err := obj.Writer()
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.WriteString(wr, b.String()); err != nil {
+		return err
+	}
+
+	if err := wr.Close(); err != nil {
+		return err
+	}
+
+	h, err := r.Storer.SetEncodedObject(obj)
+	if err != nil {
+		return err
+	}
+
+	return r.Storer.SetReference(plumbing.NewHashReference(rebaseTodoName, h))
+}
+
+// rebaseTodo returns the items of the RebasePlan in progress not executed
+// yet, the stopped one first, or nil if the rebase in progress doesn't
+// execute a plan.
+func (r *Repository) rebaseTodo() (RebasePlan, error) {
+	ref, err := r.Storer.Reference(rebaseTodoName)
+	if err == plumbing.ErrReferenceNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	blob, err := r.BlobObject(ref.Hash())
+	if err != nil {
+		return nil, err
+	}
+
+	rd, err := blob.Reader()
+	if err != nil {
+		return nil, err
+	}
+	defer rd.Close()
+
+	content, err := io.ReadAll(rd)
+	if err != nil {
+		return nil, err
+	}
+
+	var plan RebasePlan
+	for _, line := range strings.Split(strings.TrimSuffix(string(content), "\n"), "\n") {
+		item, err := parseRebaseTodo(line)
+		if err != nil {
+			return nil, err
+		}
+
+		plan = append(plan, item)
+	}
+
+	return plan, nil
+}
+
+// parseRebaseTodo parses a line written by setRebaseTodo.
+func parseRebaseTodo(line string) (RebaseTodo, error) {
+	name, rest, _ := strings.Cut(line, " ")
+	hash, msg, _ := strings.Cut(rest, " ")
+
+	item := RebaseTodo{Action: -1, Commit: plumbing.NewHash(hash)}
+	for i, n := range rebaseActionNames {
+		if n == name {
+			item.Action = RebaseAction(i)
+		}
+	}
+
+	if item.Action < 0 || !plumbing.IsHash(hash) {
+		return item, fmt.Errorf("%w: %q", ErrInvalidRebaseAction, line)
+	}
+
+	if item.Action == RebaseReword {
+		var err error
+		if item.Message, err = strconv.Unquote(msg); err != nil {
+			return item, fmt.Errorf("%w: %q", ErrRebaseMissingMessage, line)
+		}
+	}
+
+	return item, nil
+}
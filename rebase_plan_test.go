@@ -0,0 +1,206 @@
+package git
+
+import (
+	"os"
+
+	"github.com/go-git/go-billy/v5/util"
+	"github.com/go-git/go-git/v6/plumbing"
+)
+
+func (s *WorktreeSuite) TestRebasePlan() {
+	r, w, fs, feature := s.setupRebaseBranches(
+		map[string][]byte{"foo": []byte("foo\n")},
+		map[string][]byte{"master": []byte("master\n")},
+		map[string][]byte{"a": []byte("a\n")},
+		map[string][]byte{"b": []byte("b\n")},
+		map[string][]byte{"c": []byte("c\n")},
+		map[string][]byte{"d": []byte("d\n")},
+		map[string][]byte{"e": []byte("e\n")},
+	)
+
+	master, err := r.Reference(plumbing.Master, false)
+	s.Require().NoError(err)
+
+	res, err := w.RebasePlan(RebasePlan{
+		{Action: RebasePick, Commit: feature[0]},
+		{Action: RebaseSquash, Commit: feature[1]},
+		{Action: RebaseFixup, Commit: feature[2]},
+		{Action: RebaseDrop, Commit: feature[3]},
+		{Action: RebaseReword, Commit: feature[4], Message: "reworded e\n"},
+	}, &RebaseOptions{Onto: master.Hash(), Committer: rebaseCommitter()})
+	s.Require().NoError(err)
+	s.False(res.HasConflicts())
+
+	ref, err := r.Reference("refs/heads/feature", false)
+	s.NoError(err)
+	s.Equal(res.Head, ref.Hash())
+
+	tip, err := r.CommitObject(res.Head)
+	s.NoError(err)
+	s.Equal("reworded e\n", tip.Message)
+	s.Equal(defaultSignature().Name, tip.Author.Name)
+
+	squashed, err := tip.Parent(0)
+	s.NoError(err)
+	s.Equal("feature a\n\nfeature b\n", squashed.Message)
+	s.Equal(defaultSignature().Name, squashed.Author.Name)
+	s.Equal(rebaseCommitter().Name, squashed.Committer.Name)
+	s.Equal([]plumbing.Hash{master.Hash()}, squashed.ParentHashes)
+
+	for _, name := range []string{"a", "b", "c"} {
+		_, err := squashed.File(name)
+		s.NoError(err, name)
+	}
+
+	for _, name := range []string{"a", "b", "c", "e", "master"} {
+		_, err := fs.Stat(name)
+		s.NoError(err, name)
+	}
+
+	_, err = fs.Stat("d")
+	s.True(os.IsNotExist(err))
+
+	status, err := w.Status()
+	s.NoError(err)
+	s.True(status.IsClean())
+}
+
+func (s *WorktreeSuite) TestRebasePlanConflictContinue() {
+	r, w, fs, feature := s.setupRebaseBranches(
+		map[string][]byte{"foo": []byte("a\nb\nc\n")},
+		map[string][]byte{"foo": []byte("a\nX\nc\n")},
+		map[string][]byte{"bar": []byte("bar\n")},
+		map[string][]byte{"foo": []byte("a\nY\nc\n")},
+		map[string][]byte{"baz": []byte("baz\n")},
+	)
+
+	master, err := r.Reference(plumbing.Master, false)
+	s.Require().NoError(err)
+
+	res, err := w.RebasePlan(RebasePlan{
+		{Action: RebaseReword, Commit: feature[0], Message: "reworded a\n"},
+		{Action: RebaseSquash, Commit: feature[1]},
+		{Action: RebasePick, Commit: feature[2]},
+	}, &RebaseOptions{Upstream: master.Hash(), Committer: rebaseCommitter()})
+	s.Require().NoError(err)
+	s.True(res.HasConflicts())
+	s.Equal(feature[1], res.Stopped)
+
+	_, err = r.Reference(rebaseTodoName, false)
+	s.NoError(err)
+
+	s.NoError(util.WriteFile(fs, "foo", []byte("a\nXY\nc\n"), 0644))
+	_, err = w.Add("foo")
+	s.NoError(err)
+
+	res, err = w.RebaseContinue(&RebaseOptions{Committer: rebaseCommitter()})
+	s.Require().NoError(err)
+	s.False(res.HasConflicts())
+
+	tip, err := r.CommitObject(res.Head)
+	s.NoError(err)
+	s.Equal("feature c", tip.Message)
+
+	squashed, err := tip.Parent(0)
+	s.NoError(err)
+	s.Equal("reworded a\n\nfeature b\n", squashed.Message)
+	s.Equal([]plumbing.Hash{master.Hash()}, squashed.ParentHashes)
+
+	file, err := squashed.File("foo")
+	s.NoError(err)
+	content, err := file.Contents()
+	s.NoError(err)
+	s.Equal("a\nXY\nc\n", content)
+
+	_, err = squashed.File("bar")
+	s.NoError(err)
+
+	for _, name := range []plumbing.ReferenceName{plumbing.RebaseHead, rebaseHeadName, rebaseTodoName} {
+		_, err = r.Storer.Reference(name)
+		s.ErrorIs(err, plumbing.ErrReferenceNotFound)
+	}
+}
+
+func (s *WorktreeSuite) TestRebasePlanConflictSkipAndAbort() {
+	r, w, fs, feature := s.setupRebaseBranches(
+		map[string][]byte{"foo": []byte("a\nb\nc\n")},
+		map[string][]byte{"foo": []byte("a\nX\nc\n")},
+		map[string][]byte{"foo": []byte("a\nY\nc\n")},
+		map[string][]byte{"bar": []byte("bar\n")},
+	)
+
+	master, err := r.Reference(plumbing.Master, false)
+	s.Require().NoError(err)
+
+	plan := RebasePlan{
+		{Action: RebasePick, Commit: feature[0]},
+		{Action: RebaseReword, Commit: feature[1], Message: "reworded b\n"},
+	}
+	opts := &RebaseOptions{Upstream: master.Hash(), Committer: rebaseCommitter()}
+
+	res, err := w.RebasePlan(plan, opts)
+	s.Require().NoError(err)
+	s.Equal(feature[0], res.Stopped)
+
+	res, err = w.RebaseSkip(&RebaseOptions{Committer: rebaseCommitter()})
+	s.Require().NoError(err)
+	s.Equal([]plumbing.Hash{feature[0]}, res.Skipped)
+
+	tip, err := r.CommitObject(res.Head)
+	s.NoError(err)
+	s.Equal("reworded b\n", tip.Message)
+	s.Equal([]plumbing.Hash{master.Hash()}, tip.ParentHashes)
+
+	s.Require().NoError(w.Checkout(&CheckoutOptions{Branch: "refs/heads/feature", Force: true}))
+	s.Require().NoError(w.Reset(&ResetOptions{Mode: HardReset, Commit: feature[1]}))
+
+	res, err = w.RebasePlan(plan, opts)
+	s.Require().NoError(err)
+	s.True(res.HasConflicts())
+
+	s.NoError(w.RebaseAbort())
+
+	ref, err := r.Reference("refs/heads/feature", false)
+	s.NoError(err)
+	s.Equal(feature[1], ref.Hash())
+
+	content, err := util.ReadFile(fs, "foo")
+	s.NoError(err)
+	s.Equal("a\nY\nc\n", string(content))
+
+	_, err = r.Storer.Reference(rebaseTodoName)
+	s.ErrorIs(err, plumbing.ErrReferenceNotFound)
+}
+
+func (s *WorktreeSuite) TestRebasePlanErrors() {
+	r, w, _, feature := s.setupRebaseBranches(
+		map[string][]byte{"foo": []byte("foo\n")},
+		map[string][]byte{"master": []byte("master\n")},
+		map[string][]byte{"a": []byte("a\n")},
+	)
+
+	master, err := r.Reference(plumbing.Master, false)
+	s.Require().NoError(err)
+	opts := &RebaseOptions{Onto: master.Hash(), Committer: rebaseCommitter()}
+
+	_, err = w.RebasePlan(RebasePlan{{Action: RebasePick, Commit: feature[0]}}, nil)
+	s.ErrorIs(err, ErrRebaseMissingUpstream)
+
+	_, err = w.RebasePlan(RebasePlan{{Action: RebaseReword, Commit: feature[0]}}, opts)
+	s.ErrorIs(err, ErrRebaseMissingMessage)
+
+	_, err = w.RebasePlan(RebasePlan{
+		{Action: RebaseDrop, Commit: feature[0]},
+		{Action: RebaseFixup, Commit: feature[0]},
+	}, opts)
+	s.ErrorIs(err, ErrRebaseSquashWithoutPick)
+
+	_, err = w.RebasePlan(RebasePlan{{Action: RebaseAction(42), Commit: feature[0]}}, opts)
+	s.ErrorIs(err, ErrInvalidRebaseAction)
+
+	_, err = w.RebasePlan(RebasePlan{{Action: RebasePick, Commit: plumbing.NewHash("6ecf0ef2c2dffb796033e5a02219af86ec6584e5")}}, opts)
+	s.ErrorIs(err, plumbing.ErrObjectNotFound)
+
+	_, err = r.Storer.Reference(rebaseHeadName)
+	s.ErrorIs(err, plumbing.ErrReferenceNotFound)
+}
@@ -0,0 +1,391 @@
+package git
+
+import (
+	"os"
+	"time"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/go-git/go-billy/v5/util"
+	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/go-git/go-git/v6/plumbing/object"
+	"github.com/go-git/go-git/v6/storage/memory"
+)
+
+// setupRebaseBranches creates a repository where master and feature diverge
+// from a common commit, master applying the given changes on top of base,
+// and feature one commit for each of the given changes. A nil content
+// removes the file. HEAD is left on feature, and the commits of feature are
+// returned oldest first.
+func (s *WorktreeSuite) setupRebaseBranches(base, master map[string][]byte, feature ...map[string][]byte) (*Repository, *Worktree, billy.Filesystem, []plumbing.Hash) {
+	fs := memfs.New()
+	r, err := Init(memory.NewStorage(), WithWorkTree(fs))
+	s.Require().NoError(err)
+
+	w, err := r.Worktree()
+	s.Require().NoError(err)
+
+	commit := func(msg string, files map[string][]byte) plumbing.Hash {
+		for name, content := range files {
+			if content == nil {
+				_, err := w.Remove(name)
+				s.Require().NoError(err)
+				continue
+			}
+
+			s.Require().NoError(util.WriteFile(fs, name, content, 0644))
+			_, err := w.Add(name)
+			s.Require().NoError(err)
+		}
+
+		h, err := w.Commit(msg, &CommitOptions{Author: defaultSignature(), AllowEmptyCommits: true})
+		s.Require().NoError(err)
+		return h
+	}
+
+	commit("base", base)
+	s.Require().NoError(w.Checkout(&CheckoutOptions{Branch: "refs/heads/feature", Create: true}))
+
+	var commits []plumbing.Hash
+	for i, files := range feature {
+		commits = append(commits, commit("feature "+string(rune('a'+i)), files))
+	}
+
+	s.Require().NoError(w.Checkout(&CheckoutOptions{Branch: plumbing.Master}))
+	commit("master", master)
+	s.Require().NoError(w.Checkout(&CheckoutOptions{Branch: "refs/heads/feature"}))
+
+	return r, w, fs, commits
+}
+
+func rebaseCommitter() *object.Signature {
+	return &object.Signature{
+		Name:  "bar",
+		Email: "bar@bar.bar",
+		When:  time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+}
+
+func (s *WorktreeSuite) TestRebase() {
+	r, w, fs, feature := s.setupRebaseBranches(
+		map[string][]byte{"foo": []byte("a\nb\nc\nd\ne\n")},
+		map[string][]byte{"foo": []byte("A\nb\nc\nd\ne\n")},
+		map[string][]byte{"foo": []byte("a\nb\nc\nd\nE\n")},
+		map[string][]byte{"bar": []byte("bar\n")},
+	)
+
+	master, err := r.Reference(plumbing.Master, false)
+	s.Require().NoError(err)
+
+	res, err := w.Rebase(&RebaseOptions{Upstream: master.Hash(), Committer: rebaseCommitter()})
+	s.Require().NoError(err)
+	s.False(res.HasConflicts())
+	s.Empty(res.Skipped)
+
+	head, err := r.Storer.Reference(plumbing.HEAD)
+	s.NoError(err)
+	s.Equal(plumbing.SymbolicReference, head.Type())
+	s.Equal(plumbing.ReferenceName("refs/heads/feature"), head.Target())
+
+	ref, err := r.Reference("refs/heads/feature", false)
+	s.NoError(err)
+	s.Equal(res.Head, ref.Hash())
+
+	origHead, err := r.Reference(plumbing.OrigHead, false)
+	s.NoError(err)
+	s.Equal(feature[1], origHead.Hash())
+
+	content, err := util.ReadFile(fs, "foo")
+	s.NoError(err)
+	s.Equal("A\nb\nc\nd\nE\n", string(content))
+
+	content, err = util.ReadFile(fs, "bar")
+	s.NoError(err)
+	s.Equal("bar\n", string(content))
+
+	tip, err := r.CommitObject(res.Head)
+	s.NoError(err)
+	s.Equal("feature b", tip.Message)
+	s.Equal(defaultSignature().Name, tip.Author.Name)
+	s.Equal(rebaseCommitter().Name, tip.Committer.Name)
+
+	parent, err := tip.Parent(0)
+	s.NoError(err)
+	s.Equal("feature a", parent.Message)
+	s.Equal([]plumbing.Hash{master.Hash()}, parent.ParentHashes)
+
+	status, err := w.Status()
+	s.NoError(err)
+	s.True(status.IsClean())
+
+	for _, name := range []plumbing.ReferenceName{plumbing.RebaseHead, rebaseHeadName} {
+		_, err = r.Storer.Reference(name)
+		s.ErrorIs(err, plumbing.ErrReferenceNotFound)
+	}
+}
+
+func (s *WorktreeSuite) TestRebaseSkipsAppliedCommits() {
+	r, w, _, feature := s.setupRebaseBranches(
+		map[string][]byte{"foo": []byte("foo\n")},
+		map[string][]byte{"foo": []byte("FOO\n")},
+		map[string][]byte{"foo": []byte("FOO\n")},
+		map[string][]byte{"bar": []byte("bar\n")},
+	)
+
+	master, err := r.Reference(plumbing.Master, false)
+	s.Require().NoError(err)
+
+	res, err := w.Rebase(&RebaseOptions{Upstream: master.Hash(), Committer: rebaseCommitter()})
+	s.Require().NoError(err)
+	s.Equal([]plumbing.Hash{feature[0]}, res.Skipped)
+
+	tip, err := r.CommitObject(res.Head)
+	s.NoError(err)
+	s.Equal("feature b", tip.Message)
+	s.Equal([]plumbing.Hash{master.Hash()}, tip.ParentHashes)
+}
+
+func (s *WorktreeSuite) TestRebaseUpToDate() {
+	r, w, _, feature := s.setupRebaseBranches(
+		map[string][]byte{"foo": []byte("foo\n")},
+		map[string][]byte{"qux": []byte("qux\n")},
+		map[string][]byte{"bar": []byte("bar\n")},
+	)
+
+	master, err := r.Reference(plumbing.Master, false)
+	s.Require().NoError(err)
+
+	base, err := r.CommitObject(master.Hash())
+	s.Require().NoError(err)
+
+	res, err := w.Rebase(&RebaseOptions{Upstream: base.ParentHashes[0], Committer: rebaseCommitter()})
+	s.Require().NoError(err)
+	s.Equal(feature[0], res.Head)
+
+	ref, err := r.Reference("refs/heads/feature", false)
+	s.NoError(err)
+	s.Equal(feature[0], ref.Hash())
+}
+
+func (s *WorktreeSuite) TestRebaseOnto() {
+	r, w, fs, _ := s.setupRebaseBranches(
+		map[string][]byte{"foo": []byte("foo\n")},
+		map[string][]byte{"qux": []byte("qux\n")},
+		map[string][]byte{"bar": []byte("bar\n")},
+		map[string][]byte{"baz": []byte("baz\n")},
+	)
+
+	feature, err := r.Reference("refs/heads/feature", false)
+	s.Require().NoError(err)
+
+	tip, err := r.CommitObject(feature.Hash())
+	s.Require().NoError(err)
+
+	master, err := r.Reference(plumbing.Master, false)
+	s.Require().NoError(err)
+
+	res, err := w.Rebase(&RebaseOptions{
+		Upstream:  tip.ParentHashes[0],
+		Onto:      master.Hash(),
+		Committer: rebaseCommitter(),
+	})
+	s.Require().NoError(err)
+
+	commit, err := r.CommitObject(res.Head)
+	s.NoError(err)
+	s.Equal("feature b", commit.Message)
+	s.Equal([]plumbing.Hash{master.Hash()}, commit.ParentHashes)
+
+	_, err = fs.Stat("bar")
+	s.ErrorIs(err, os.ErrNotExist)
+
+	for _, name := range []string{"baz", "qux"} {
+		_, err = fs.Stat(name)
+		s.NoError(err)
+	}
+}
+
+func (s *WorktreeSuite) TestRebaseConflictContinue() {
+	r, w, fs, feature := s.setupRebaseBranches(
+		map[string][]byte{"foo": []byte("a\nb\nc\n")},
+		map[string][]byte{"foo": []byte("a\nX\nc\n")},
+		map[string][]byte{"foo": []byte("a\nY\nc\n")},
+		map[string][]byte{"bar": []byte("bar\n")},
+	)
+
+	master, err := r.Reference(plumbing.Master, false)
+	s.Require().NoError(err)
+
+	res, err := w.Rebase(&RebaseOptions{Upstream: master.Hash(), Committer: rebaseCommitter()})
+	s.Require().NoError(err)
+	s.True(res.HasConflicts())
+	s.Equal(feature[0], res.Stopped)
+	s.Equal(master.Hash(), res.Head)
+	s.Require().Len(res.Conflicts, 1)
+	s.Equal("foo", res.Conflicts[0].Path)
+
+	ref, err := r.Reference(plumbing.RebaseHead, false)
+	s.NoError(err)
+	s.Equal(feature[0], ref.Hash())
+
+	head, err := r.Storer.Reference(plumbing.HEAD)
+	s.NoError(err)
+	s.Equal(plumbing.HashReference, head.Type())
+
+	_, err = w.Rebase(&RebaseOptions{Upstream: master.Hash(), Committer: rebaseCommitter()})
+	s.ErrorIs(err, ErrRebaseInProgress)
+
+	_, err = w.RebaseContinue(&RebaseOptions{Committer: rebaseCommitter()})
+	s.ErrorIs(err, ErrUnmergedPaths)
+
+	s.NoError(util.WriteFile(fs, "foo", []byte("a\nXY\nc\n"), 0644))
+	_, err = w.Add("foo")
+	s.NoError(err)
+
+	res, err = w.RebaseContinue(&RebaseOptions{Committer: rebaseCommitter()})
+	s.Require().NoError(err)
+	s.False(res.HasConflicts())
+
+	tip, err := r.CommitObject(res.Head)
+	s.NoError(err)
+	s.Equal("feature b", tip.Message)
+
+	parent, err := tip.Parent(0)
+	s.NoError(err)
+	s.Equal("feature a", parent.Message)
+	s.Equal(defaultSignature().Name, parent.Author.Name)
+	s.Equal([]plumbing.Hash{master.Hash()}, parent.ParentHashes)
+
+	file, err := parent.File("foo")
+	s.NoError(err)
+	content, err := file.Contents()
+	s.NoError(err)
+	s.Equal("a\nXY\nc\n", content)
+
+	ref, err = r.Reference("refs/heads/feature", false)
+	s.NoError(err)
+	s.Equal(res.Head, ref.Hash())
+
+	_, err = r.Reference(plumbing.RebaseHead, false)
+	s.ErrorIs(err, plumbing.ErrReferenceNotFound)
+}
+
+func (s *WorktreeSuite) TestRebaseSkip() {
+	r, w, fs, feature := s.setupRebaseBranches(
+		map[string][]byte{"foo": []byte("a\nb\nc\n")},
+		map[string][]byte{"foo": []byte("a\nX\nc\n")},
+		map[string][]byte{"foo": []byte("a\nY\nc\n")},
+		map[string][]byte{"bar": []byte("bar\n")},
+	)
+
+	master, err := r.Reference(plumbing.Master, false)
+	s.Require().NoError(err)
+
+	res, err := w.Rebase(&RebaseOptions{Upstream: master.Hash(), Committer: rebaseCommitter()})
+	s.Require().NoError(err)
+	s.True(res.HasConflicts())
+
+	res, err = w.RebaseSkip(&RebaseOptions{Committer: rebaseCommitter()})
+	s.Require().NoError(err)
+	s.Equal([]plumbing.Hash{feature[0]}, res.Skipped)
+
+	tip, err := r.CommitObject(res.Head)
+	s.NoError(err)
+	s.Equal("feature b", tip.Message)
+	s.Equal([]plumbing.Hash{master.Hash()}, tip.ParentHashes)
+
+	content, err := util.ReadFile(fs, "foo")
+	s.NoError(err)
+	s.Equal("a\nX\nc\n", string(content))
+
+	status, err := w.Status()
+	s.NoError(err)
+	s.True(status.IsClean())
+}
+
+func (s *WorktreeSuite) TestRebaseAbort() {
+	r, w, fs, feature := s.setupRebaseBranches(
+		map[string][]byte{"foo": []byte("a\nb\nc\n")},
+		map[string][]byte{"foo": []byte("a\nX\nc\n")},
+		map[string][]byte{"bar": []byte("bar\n")},
+		map[string][]byte{"foo": []byte("a\nY\nc\n")},
+	)
+
+	master, err := r.Reference(plumbing.Master, false)
+	s.Require().NoError(err)
+
+	res, err := w.Rebase(&RebaseOptions{Upstream: master.Hash(), Committer: rebaseCommitter()})
+	s.Require().NoError(err)
+	s.Equal(feature[1], res.Stopped)
+
+	s.NoError(w.RebaseAbort())
+
+	head, err := r.Storer.Reference(plumbing.HEAD)
+	s.NoError(err)
+	s.Equal(plumbing.ReferenceName("refs/heads/feature"), head.Target())
+
+	ref, err := r.Reference("refs/heads/feature", false)
+	s.NoError(err)
+	s.Equal(feature[1], ref.Hash())
+
+	content, err := util.ReadFile(fs, "foo")
+	s.NoError(err)
+	s.Equal("a\nY\nc\n", string(content))
+
+	status, err := w.Status()
+	s.NoError(err)
+	s.True(status.IsClean())
+
+	s.ErrorIs(w.RebaseAbort(), ErrNoRebaseInProgress)
+
+	_, err = w.RebaseContinue(nil)
+	s.ErrorIs(err, ErrNoRebaseInProgress)
+}
+
+func (s *WorktreeSuite) TestRebaseWorktreeNotClean() {
+	r, w, fs, _ := s.setupRebaseBranches(
+		map[string][]byte{"foo": []byte("foo\n")},
+		map[string][]byte{"qux": []byte("qux\n")},
+		map[string][]byte{"bar": []byte("bar\n")},
+	)
+
+	master, err := r.Reference(plumbing.Master, false)
+	s.Require().NoError(err)
+
+	s.NoError(util.WriteFile(fs, "foo", []byte("changed\n"), 0644))
+
+	_, err = w.Rebase(&RebaseOptions{Upstream: master.Hash(), Committer: rebaseCommitter()})
+	s.ErrorIs(err, ErrWorktreeNotClean)
+
+	_, err = w.Rebase(&RebaseOptions{Committer: rebaseCommitter()})
+	s.ErrorIs(err, ErrRebaseMissingUpstream)
+}
+
+func (s *WorktreeSuite) TestRebaseBranch() {
+	r, w, _, _ := s.setupRebaseBranches(
+		map[string][]byte{"foo": []byte("foo\n")},
+		map[string][]byte{"qux": []byte("qux\n")},
+		map[string][]byte{"bar": []byte("bar\n")},
+	)
+
+	s.Require().NoError(w.Checkout(&CheckoutOptions{Branch: plumbing.Master}))
+
+	master, err := r.Reference(plumbing.Master, false)
+	s.Require().NoError(err)
+
+	res, err := w.Rebase(&RebaseOptions{
+		Upstream:  master.Hash(),
+		Branch:    "refs/heads/feature",
+		Committer: rebaseCommitter(),
+	})
+	s.Require().NoError(err)
+
+	head, err := r.Head()
+	s.NoError(err)
+	s.Equal(plumbing.ReferenceName("refs/heads/feature"), head.Name())
+	s.Equal(res.Head, head.Hash())
+
+	ref, err := r.Reference(plumbing.Master, false)
+	s.NoError(err)
+	s.Equal(master.Hash(), ref.Hash())
+}
@@ -0,0 +1,192 @@
+package git
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/go-git/go-git/v6/config"
+	"github.com/go-git/go-git/v6/internal/revision"
+	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/go-git/go-git/v6/plumbing/format/reflog"
+	"github.com/go-git/go-git/v6/plumbing/object"
+	"github.com/go-git/go-git/v6/plumbing/storer"
+)
+
+// Reflog returns the entries of the log of the given reference, the most
+// recent first, so the n-th entry is the one `<name>@{n}` resolves to. No
+// entries are returned when the storage doesn't keep reference logs.
+func (r *Repository) Reflog(name plumbing.ReferenceName) ([]*reflog.Entry, error) {
+	rs, ok := r.Storer.(storer.ReflogStorer)
+	if !ok {
+		return nil, nil
+	}
+
+	entries, err := rs.Reflog(name)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, j := 0, len(entries)-1; i < j; i, j = i+1, j-1 {
+		entries[i], entries[j] = entries[j], entries[i]
+	}
+
+	return entries, nil
+}
+
+// logRefUpdate appends an entry to the log of the given reference and, when
+// the reference is the branch checked out, to the log of HEAD as well. If
+// committer is nil, the identity in the config is used.
+func (r *Repository) logRefUpdate(name plumbing.ReferenceName, old, new plumbing.Hash, committer *object.Signature, msg string) error {
+	rs, ok := r.Storer.(storer.ReflogStorer)
+	if !ok {
+		return nil
+	}
+
+	e := &reflog.Entry{Old: old, New: new, Message: msg}
+	if committer != nil {
+		e.Committer = reflog.Signature{Name: committer.Name, Email: committer.Email, When: committer.When}
+	} else {
+		e.Committer = r.reflogCommitter()
+	}
+
+	if err := rs.AppendReflog(name, e); err != nil {
+		return err
+	}
+
+	if name == plumbing.HEAD {
+		return nil
+	}
+
+	head, err := r.Storer.Reference(plumbing.HEAD)
+	if err == plumbing.ErrReferenceNotFound {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if head.Type() != plumbing.SymbolicReference || head.Target() != name {
+		return nil
+	}
+
+	return rs.AppendReflog(plumbing.HEAD, e)
+}
+
+// reflogCommitter returns the identity used for the reflog entries, the
+// committer or the user in the config.
+func (r *Repository) reflogCommitter() reflog.Signature {
+	s := reflog.Signature{When: time.Now()}
+
+	cfg, err := r.ConfigScoped(config.SystemScope)
+	if err != nil {
+		return s
+	}
+
+	s.Name, s.Email = cfg.User.Name, cfg.User.Email
+	if cfg.Committer.Name != "" && cfg.Committer.Email != "" {
+		s.Name, s.Email = cfg.Committer.Name, cfg.Committer.Email
+	}
+
+	return s
+}
+
+// referenceHash returns the hash the given reference points to, or the zero
+// hash if it doesn't exist.
+func (r *Repository) referenceHash(name plumbing.ReferenceName) (plumbing.Hash, error) {
+	ref, err := storer.ResolveReference(r.Storer, name)
+	if err == plumbing.ErrReferenceNotFound {
+		return plumbing.ZeroHash, nil
+	}
+
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	return ref.Hash(), nil
+}
+
+// resolveReflogRevision resolves the `@{n}` and `@{date}` revisions against
+// the log of the given reference, or of the branch checked out if empty.
+func (r *Repository) resolveReflogRevision(ref string, item revision.Revisioner) (plumbing.Hash, error) {
+	name, err := r.reflogRefName(ref)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	switch item := item.(type) {
+	case revision.AtReflog:
+		return r.resolveReflogIndex(name, item.Depth)
+	case revision.AtDate:
+		return r.resolveReflogDate(name, item.Date)
+	}
+
+	return plumbing.ZeroHash, fmt.Errorf("unsupported reflog revision %T", item)
+}
+
+// reflogRefName returns the name of the reference whose log is used by the
+// `@{...}` revisions, given the reference preceding them, if any. As git
+// does, the branch checked out is used when no reference is given.
+func (r *Repository) reflogRefName(rev string) (plumbing.ReferenceName, error) {
+	if rev == "" {
+		head, err := r.Storer.Reference(plumbing.HEAD)
+		if err != nil {
+			return "", err
+		}
+
+		if head.Type() == plumbing.SymbolicReference {
+			return head.Target(), nil
+		}
+
+		return plumbing.HEAD, nil
+	}
+
+	for _, rule := range plumbing.RefRevParseRules {
+		name := plumbing.ReferenceName(fmt.Sprintf(rule, rev))
+		if _, err := r.Storer.Reference(name); err == nil {
+			return name, nil
+		}
+	}
+
+	return "", plumbing.ErrReferenceNotFound
+}
+
+// resolveReflogIndex returns the value of the reference n updates ago.
+func (r *Repository) resolveReflogIndex(name plumbing.ReferenceName, n int) (plumbing.Hash, error) {
+	entries, err := r.Reflog(name)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	if n >= len(entries) {
+		return plumbing.ZeroHash, fmt.Errorf("log for %q only has %d entries", name.Short(), len(entries))
+	}
+
+	return entries[n].New, nil
+}
+
+// resolveReflogDate returns the value the reference had at the given time.
+func (r *Repository) resolveReflogDate(name plumbing.ReferenceName, t time.Time) (plumbing.Hash, error) {
+	entries, err := r.Reflog(name)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	if len(entries) == 0 {
+		return plumbing.ZeroHash, fmt.Errorf("log for %q is empty", name.Short())
+	}
+
+	for _, e := range entries {
+		if !e.Committer.When.After(t) {
+			return e.New, nil
+		}
+	}
+
+	// As git does, the oldest known value is used for dates before the log.
+	oldest := entries[len(entries)-1]
+	if oldest.Old.IsZero() {
+		return oldest.New, nil
+	}
+
+	return oldest.Old, nil
+}
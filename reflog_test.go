@@ -0,0 +1,198 @@
+package git
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/go-git/go-billy/v5/util"
+	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/go-git/go-git/v6/plumbing/object"
+	"github.com/go-git/go-git/v6/storage/memory"
+	"github.com/stretchr/testify/suite"
+)
+
+type ReflogSuite struct {
+	suite.Suite
+	r       *Repository
+	w       *Worktree
+	commits []plumbing.Hash
+}
+
+func TestReflogSuite(t *testing.T) {
+	suite.Run(t, new(ReflogSuite))
+}
+
+var reflogEpoch = time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func (s *ReflogSuite) SetupTest() {
+	var err error
+	s.r, err = Init(memory.NewStorage(), WithWorkTree(memfs.New()))
+	s.Require().NoError(err)
+	s.w, err = s.r.Worktree()
+	s.Require().NoError(err)
+
+	s.commits = nil
+	for i := 0; i < 3; i++ {
+		s.commits = append(s.commits, s.commit(i))
+	}
+}
+
+func (s *ReflogSuite) commit(i int) plumbing.Hash {
+	name := fmt.Sprintf("file%d", i)
+	s.Require().NoError(util.WriteFile(s.w.Filesystem, name, []byte(name), 0o644))
+	_, err := s.w.Add(name)
+	s.Require().NoError(err)
+
+	sig := &object.Signature{Name: "foo", Email: "foo@foo.foo", When: reflogEpoch.Add(time.Duration(i) * time.Minute)}
+	h, err := s.w.Commit(fmt.Sprintf("commit %d\n\nbody", i), &CommitOptions{Author: sig})
+	s.Require().NoError(err)
+	return h
+}
+
+func (s *ReflogSuite) messages(name plumbing.ReferenceName) []string {
+	entries, err := s.r.Reflog(name)
+	s.Require().NoError(err)
+
+	var msgs []string
+	for _, e := range entries {
+		msgs = append(msgs, e.Message)
+	}
+
+	return msgs
+}
+
+func (s *ReflogSuite) resolve(rev string) plumbing.Hash {
+	h, err := s.r.ResolveRevision(plumbing.Revision(rev))
+	s.Require().NoError(err, rev)
+	return *h
+}
+
+func (s *ReflogSuite) TestCommit() {
+	expected := []string{"commit: commit 2", "commit: commit 1", "commit (initial): commit 0"}
+	s.Equal(expected, s.messages(plumbing.Master))
+	s.Equal(expected, s.messages(plumbing.HEAD))
+
+	entries, err := s.r.Reflog(plumbing.Master)
+	s.Require().NoError(err)
+	s.Equal(s.commits[1], entries[0].Old)
+	s.Equal(s.commits[2], entries[0].New)
+	s.Equal(plumbing.ZeroHash, entries[2].Old)
+	s.Equal("foo", entries[0].Committer.Name)
+	s.True(entries[0].Committer.When.Equal(reflogEpoch.Add(2 * time.Minute)))
+}
+
+func (s *ReflogSuite) TestResolveRevision() {
+	s.Equal(s.commits[2], s.resolve("HEAD@{0}"))
+	s.Equal(s.commits[1], s.resolve("HEAD@{1}"))
+	s.Equal(s.commits[0], s.resolve("master@{2}"))
+	s.Equal(s.commits[1], s.resolve("refs/heads/master@{1}"))
+	s.Equal(s.commits[1], s.resolve("@{1}"))
+
+	_, err := s.r.ResolveRevision("master@{3}")
+	s.ErrorContains(err, "only has 3 entries")
+}
+
+func (s *ReflogSuite) TestResolveRevisionDate() {
+	s.Equal(s.commits[1], s.resolve("master@{2020-01-01T00:01:30Z}"))
+	s.Equal(s.commits[1], s.resolve("master@{2020-01-01T00:01:00Z}"))
+	s.Equal(s.commits[2], s.resolve("@{2021-01-01T00:00:00Z}"))
+	s.Equal(s.commits[0], s.resolve("master@{2019-01-01T00:00:00Z}"))
+}
+
+func (s *ReflogSuite) TestReset() {
+	err := s.w.Reset(&ResetOptions{Mode: HardReset, Commit: s.commits[0]})
+	s.Require().NoError(err)
+
+	msg := fmt.Sprintf("reset: moving to %s", s.commits[0])
+	s.Equal(msg, s.messages(plumbing.Master)[0])
+	s.Equal(msg, s.messages(plumbing.HEAD)[0])
+	s.Equal(s.commits[2], s.resolve("HEAD@{1}"))
+
+	// Resetting to the current commit isn't logged.
+	err = s.w.Reset(&ResetOptions{Mode: HardReset, Commit: s.commits[0]})
+	s.Require().NoError(err)
+	s.Len(s.messages(plumbing.Master), 4)
+}
+
+func (s *ReflogSuite) TestCheckout() {
+	branch := plumbing.NewBranchReferenceName("foo")
+	err := s.w.Checkout(&CheckoutOptions{Branch: branch, Hash: s.commits[1], Create: true})
+	s.Require().NoError(err)
+
+	s.Equal([]string{"branch: Created from " + s.commits[1].String()}, s.messages(branch))
+	s.Equal("checkout: moving from master to foo", s.messages(plumbing.HEAD)[0])
+	s.Len(s.messages(plumbing.Master), 3)
+
+	err = s.w.Checkout(&CheckoutOptions{Hash: s.commits[0]})
+	s.Require().NoError(err)
+	s.Equal("checkout: moving from foo to "+s.commits[0].String(), s.messages(plumbing.HEAD)[0])
+
+	err = s.w.Checkout(&CheckoutOptions{Branch: plumbing.Master})
+	s.Require().NoError(err)
+	s.Equal("checkout: moving from "+s.commits[0].String()+" to master", s.messages(plumbing.HEAD)[0])
+
+	s.Equal(s.commits[0], s.resolve("HEAD@{1}"))
+	s.Equal(s.commits[1], s.resolve("HEAD@{2}"))
+}
+
+func (s *ReflogSuite) TestMerge() {
+	branch := plumbing.NewBranchReferenceName("foo")
+	err := s.w.Checkout(&CheckoutOptions{Branch: branch, Create: true})
+	s.Require().NoError(err)
+	next := s.commit(3)
+
+	err = s.w.Checkout(&CheckoutOptions{Branch: plumbing.Master})
+	s.Require().NoError(err)
+
+	ref, err := s.r.Reference(branch, true)
+	s.Require().NoError(err)
+	s.Require().NoError(s.r.Merge(*ref, MergeOptions{}))
+
+	s.Equal("merge foo: Fast-forward", s.messages(plumbing.Master)[0])
+	s.Equal("merge foo: Fast-forward", s.messages(plumbing.HEAD)[0])
+	s.Equal(next, s.resolve("master@{0}"))
+	s.Equal(s.commits[2], s.resolve("master@{1}"))
+}
+
+func (s *ReflogSuite) TestWorktreeMergeFastForward() {
+	err := s.w.Reset(&ResetOptions{Mode: HardReset, Commit: s.commits[0]})
+	s.Require().NoError(err)
+
+	res, err := s.w.Merge(s.commits[2], nil)
+	s.Require().NoError(err)
+	s.True(res.FastForward)
+
+	s.Equal(fmt.Sprintf("merge %s: Fast-forward", s.commits[2]), s.messages(plumbing.Master)[0])
+}
+
+func (s *ReflogSuite) TestFilesystem() {
+	dir := s.T().TempDir()
+	r, err := PlainInit(dir, false)
+	s.Require().NoError(err)
+	w, err := r.Worktree()
+	s.Require().NoError(err)
+
+	s.Require().NoError(util.WriteFile(w.Filesystem, "foo", []byte("foo"), 0o644))
+	_, err = w.Add("foo")
+	s.Require().NoError(err)
+	sig := &object.Signature{Name: "foo", Email: "foo@foo.foo", When: reflogEpoch}
+	h, err := w.Commit("foo", &CommitOptions{Author: sig})
+	s.Require().NoError(err)
+
+	head, err := r.Storer.Reference(plumbing.HEAD)
+	s.Require().NoError(err)
+
+	for _, name := range []string{"HEAD", head.Target().String()} {
+		content, err := os.ReadFile(filepath.Join(dir, ".git", "logs", filepath.FromSlash(name)))
+		s.Require().NoError(err)
+		s.Equal(fmt.Sprintf("%s %s foo <foo@foo.foo> 1577836800 +0000\tcommit (initial): foo\n", plumbing.ZeroHash, h), string(content))
+	}
+
+	s.Require().NoError(r.Storer.RemoveReference(head.Target()))
+	_, err = os.Stat(filepath.Join(dir, ".git", "logs", filepath.FromSlash(head.Target().String())))
+	s.True(os.IsNotExist(err))
+}
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"path"
 	"strings"
 	"time"
 
@@ -15,6 +16,7 @@ import (
 	"github.com/go-git/go-git/v6/internal/url"
 	"github.com/go-git/go-git/v6/plumbing"
 	"github.com/go-git/go-git/v6/plumbing/cache"
+	"github.com/go-git/go-git/v6/plumbing/filemode"
 	"github.com/go-git/go-git/v6/plumbing/format/packfile"
 	"github.com/go-git/go-git/v6/plumbing/object"
 	"github.com/go-git/go-git/v6/plumbing/protocol/packp"
@@ -36,15 +38,10 @@ var (
 	ErrExactSHA1NotSupported = errors.New("server does not support exact SHA1 refspec")
 	ErrEmptyUrls             = errors.New("URLs cannot be empty")
 	ErrRemoteRefNotFound     = errors.New("couldn't find remote ref")
+	ErrUnshallowComplete     = errors.New("unshallow on a complete repository")
 )
 
 const (
-	// This describes the maximum number of commits to walk when
-	// computing the haves to send to a server, for each ref in the
-	// repo containing this remote, when not using the multi-ack
-	// protocol.  Setting this to 0 means there is no limit.
-	maxHavesToVisitPerRef = 100
-
 	// peeledSuffix is the suffix used to build peeled reference names.
 	peeledSuffix = "^{}"
 )
@@ -99,10 +96,16 @@ func (r *Remote) PushContext(ctx context.Context, o *PushOptions) (err error) {
 	}
 
 	if o.RemoteURL == "" && len(r.c.URLs) > 0 {
-		o.RemoteURL = r.c.URLs[len(r.c.URLs)-1]
+		urls := r.c.OriginalURLs()
+		o.RemoteURL = urls[len(urls)-1]
 	}
 
-	c, ep, err := newClient(o.RemoteURL, o.InsecureSkipTLS, o.CABundle, o.ProxyOptions)
+	remoteURL, err := r.rewriteURL(o.RemoteURL, true)
+	if err != nil {
+		return err
+	}
+
+	c, ep, err := newClient(remoteURL, o.InsecureSkipTLS, o.CABundle, o.ProxyOptions)
 	if err != nil {
 		return err
 	}
@@ -127,7 +130,10 @@ func (r *Remote) PushContext(ctx context.Context, o *PushOptions) (err error) {
 		return err
 	}
 
-	return r.sendPack(ctx, conn, remoteRefs, o)
+	// The rest of the push works with the URL pushed to.
+	po := *o
+	po.RemoteURL = remoteURL
+	return r.sendPack(ctx, conn, remoteRefs, &po)
 }
 
 func (r *Remote) sendPack(ctx context.Context, conn transport.Connection, remoteRefs storer.ReferenceStorer, o *PushOptions) error {
@@ -150,6 +156,21 @@ func (r *Remote) sendPack(ctx context.Context, conn transport.Connection, remote
 		return ErrDeleteRefNotSupported
 	}
 
+	if o.Atomic && !caps.Supports(capability.Atomic) {
+		return transport.ErrAtomicPushNotSupported
+	}
+
+	if len(o.Options) > 0 && !caps.Supports(capability.PushOptions) {
+		return transport.ErrPushOptionsNotSupported
+	}
+
+	signer := o.pushSigner()
+	if signer != nil {
+		if _, err := pushCertNonce(caps); err != nil {
+			return err
+		}
+	}
+
 	if o.Force {
 		for i := 0; i < len(o.RefSpecs); i++ {
 			rs := &o.RefSpecs[i]
@@ -179,6 +200,20 @@ func (r *Remote) sendPack(ctx context.Context, conn transport.Connection, remote
 		return NoErrAlreadyUpToDate
 	}
 
+	if o.RunHooks {
+		if err := r.runPrePushHook(ctx, cmds, o); err != nil {
+			return err
+		}
+	}
+
+	var cert *packp.PushCertificate
+	if signer != nil {
+		cert, err = r.pushCertificate(caps, cmds, signer, o)
+		if err != nil {
+			return err
+		}
+	}
+
 	objects := objectsToPush(cmds)
 	haves, err := referencesToHashes(remoteRefs)
 	if err != nil {
@@ -223,7 +258,7 @@ func (r *Remote) sendPack(ctx context.Context, conn transport.Connection, remote
 		}
 	}
 
-	if err := pushHashes(ctx, conn, r.s, cmds, hashesToPush, allDelete, o); err != nil {
+	if err := pushHashes(ctx, conn, r.s, cmds, cert, hashesToPush, allDelete, o); err != nil {
 		return err
 	}
 
@@ -361,15 +396,26 @@ func (r *Remote) fetch(ctx context.Context, o *FetchOptions) (sto storer.Referen
 		return nil, err
 	}
 
-	if len(o.RefSpecs) == 0 {
-		o.RefSpecs = r.c.Fetch
+	// The negative refspecs alone exclude references from the configured
+	// ones, as git does.
+	if positive, _ := config.SplitNegative(o.RefSpecs); len(positive) == 0 {
+		o.RefSpecs = append(append([]config.RefSpec{}, r.c.Fetch...), o.RefSpecs...)
+	}
+
+	if o.Prune && o.PruneTags {
+		o.RefSpecs = append(append([]config.RefSpec{}, o.RefSpecs...), refspecAllTags)
 	}
 
 	if o.RemoteURL == "" {
-		o.RemoteURL = r.c.URLs[0]
+		o.RemoteURL = r.c.OriginalURLs()[0]
+	}
+
+	remoteURL, err := r.rewriteURL(o.RemoteURL, false)
+	if err != nil {
+		return nil, err
 	}
 
-	c, ep, err := newClient(o.RemoteURL, o.InsecureSkipTLS, o.CABundle, o.ProxyOptions)
+	c, ep, err := newClient(remoteURL, o.InsecureSkipTLS, o.CABundle, o.ProxyOptions)
 	if err != nil {
 		return nil, err
 	}
@@ -398,21 +444,36 @@ func (r *Remote) fetch(ctx context.Context, o *FetchOptions) (sto storer.Referen
 	if err != nil {
 		return nil, err
 	}
-	refs, specToRefs, err := calculateRefs(o.RefSpecs, remoteRefs, o.Tags)
+	specs, negative := config.SplitNegative(o.RefSpecs)
+	refs, specToRefs, err := calculateRefs(specs, negative, remoteRefs, o.Tags)
 	if err != nil {
 		return nil, err
 	}
 
+	depth, relative := o.Depth, false
+	switch {
+	case o.Deepen > 0:
+		depth, relative = o.Deepen, true
+	case o.Unshallow:
+		depth = transport.InfiniteDepth
+	}
+
+	shallowSince := !o.ShallowSince.IsZero() || len(o.ShallowExclude) > 0
+
 	var shallows []plumbing.Hash
-	if o.Depth != 0 {
+	if depth != 0 || shallowSince {
 		shallows, err = r.s.Shallow()
 		if err != nil {
 			return nil, err
 		}
 	}
 
+	if o.Unshallow && len(shallows) == 0 {
+		return nil, ErrUnshallowComplete
+	}
+
 	isWildcard := true
-	for _, s := range o.RefSpecs {
+	for _, s := range specs {
 		if !s.IsWildcard() {
 			isWildcard = false
 			break
@@ -420,20 +481,25 @@ func (r *Remote) fetch(ctx context.Context, o *FetchOptions) (sto storer.Referen
 	}
 
 	var haves []plumbing.Hash
-	wants, _ := getWants(r.s, refs, o.Depth)
+	wants, _ := getWants(r.s, refs, depth, relative)
 	if len(wants) > 0 {
-		haves, err = getHaves(localRefs, remoteRefs, r.s, o.Depth)
+		haves, err = getHaves(localRefs, r.s)
 		if err != nil {
 			return nil, err
 		}
 
 		req := &transport.FetchRequest{
-			Wants:       wants,
-			Haves:       haves,
-			Depth:       o.Depth,
-			Progress:    o.Progress,
-			IncludeTags: isWildcard && o.Tags == plumbing.TagFollowing,
-			Filter:      o.Filter,
+			Wants:             wants,
+			Haves:             haves,
+			NegotiationRounds: o.NegotiationRounds,
+			Depth:             depth,
+			DeepenRelative:    relative,
+			DeepenSince:       o.ShallowSince,
+			DeepenNot:         o.ShallowExclude,
+			Progress:          o.Progress,
+			IncludeTags:       isWildcard && o.Tags == plumbing.TagFollowing,
+			Filter:            o.Filter,
+			DeltaBaseCache:    o.DeltaBaseCache,
 		}
 
 		if err := conn.Fetch(ctx, req); err != nil && !errors.Is(err, transport.ErrNoChange) {
@@ -447,15 +513,27 @@ func (r *Remote) fetch(ctx context.Context, o *FetchOptions) (sto storer.Referen
 		return nil, fmt.Errorf("error closing connection: %w", err)
 	}
 
-	var updatedPrune bool
+	if err := r.updateFetchHead(o.RemoteName, remoteURL, specs, specToRefs); err != nil {
+		return nil, err
+	}
+
+	var pruned []*plumbing.Reference
 	if o.Prune {
-		updatedPrune, err = r.pruneRemotes(o.RefSpecs, localRefs, remoteRefs)
+		pruned, err = r.pruneRemotes(o.RefSpecs, localRefs, remoteRefs)
 		if err != nil {
 			return nil, err
 		}
 	}
 
-	updated, err := r.updateLocalReferenceStorage(o.RefSpecs, refs, remoteRefs, specToRefs, o.Tags, o.Force)
+	if o.Pruned != nil {
+		for _, ref := range pruned {
+			o.Pruned(ref)
+		}
+	}
+
+	updatedPrune := len(pruned) > 0
+
+	updated, err := r.updateLocalReferenceStorage(specs, negative, refs, remoteRefs, specToRefs, o.Tags, o.Force)
 	if err != nil {
 		return nil, err
 	}
@@ -485,6 +563,49 @@ func (r *Remote) fetch(ctx context.Context, o *FetchOptions) (sto storer.Referen
 	return remoteRefs, nil
 }
 
+// fetchObjects fetches the given objects, along with the objects they
+// reference and are not excluded by the filter of the given options. It's
+// used to retrieve on demand the objects omitted from a partial clone.
+func (r *Remote) fetchObjects(ctx context.Context, o *FetchOptions, hashes []plumbing.Hash) (err error) {
+	if o.RemoteURL == "" {
+		o.RemoteURL = r.c.OriginalURLs()[0]
+	}
+
+	remoteURL, err := r.rewriteURL(o.RemoteURL, false)
+	if err != nil {
+		return err
+	}
+
+	c, ep, err := newClient(remoteURL, o.InsecureSkipTLS, o.CABundle, o.ProxyOptions)
+	if err != nil {
+		return err
+	}
+
+	sess, err := c.NewSession(r.s, ep, o.Auth)
+	if err != nil {
+		return err
+	}
+
+	conn, err := sess.Handshake(ctx, transport.UploadPackService)
+	if err != nil {
+		return err
+	}
+
+	defer ioutil.CheckClose(conn, &err)
+
+	req := &transport.FetchRequest{
+		Wants:    hashes,
+		Progress: o.Progress,
+		Filter:   o.Filter,
+	}
+
+	if err := conn.Fetch(ctx, req); err != nil && !errors.Is(err, transport.ErrNoChange) {
+		return err
+	}
+
+	return nil
+}
+
 func referenceStorageFromRefs(refs []*plumbing.Reference, filterPeeled bool) memory.ReferenceStorage {
 	refStore := memory.ReferenceStorage{}
 	for _, ref := range refs {
@@ -519,6 +640,26 @@ func depthChanged(before []plumbing.Hash, s storage.Storer) (bool, error) {
 	return false, nil
 }
 
+// rewriteURL returns the given URL rewritten by the url.<base>.insteadOf
+// rules of the repository and global config, or the pushInsteadOf ones for a
+// push, as git does. The transport is chosen from the rewritten URL, so a
+// rule can change the protocol.
+func (r *Remote) rewriteURL(remoteURL string, push bool) (string, error) {
+	var cfg *config.Config
+	var err error
+	if r.s == nil {
+		cfg, err = config.LoadConfig(config.GlobalScope)
+	} else {
+		cfg, err = newRepository(r.s, nil).ConfigScoped(config.GlobalScope)
+	}
+
+	if err != nil {
+		return "", err
+	}
+
+	return cfg.RewriteURL(remoteURL, push), nil
+}
+
 func newClient(url string, insecure bool, cabundle []byte, proxyOpts transport.ProxyOptions) (transport.Transport, *transport.Endpoint, error) {
 	ep, err := transport.NewEndpoint(url)
 	if err != nil {
@@ -536,25 +677,42 @@ func newClient(url string, insecure bool, cabundle []byte, proxyOpts transport.P
 	return c, ep, err
 }
 
-func (r *Remote) pruneRemotes(specs []config.RefSpec, localRefs []*plumbing.Reference, remoteRefs storer.ReferenceStorer) (bool, error) {
-	var updatedPrune bool
+// pruneRemotes removes the local references matching the destination of
+// the given refspecs whose source doesn't exist in remoteRefs, returning the
+// removed ones. The symbolic references, such as refs/remotes/origin/HEAD,
+// are kept, as git does.
+func (r *Remote) pruneRemotes(specs []config.RefSpec, localRefs []*plumbing.Reference, remoteRefs storer.ReferenceStorer) ([]*plumbing.Reference, error) {
+	var pruned []*plumbing.Reference
+	seen := make(map[plumbing.ReferenceName]bool)
 	for _, spec := range specs {
+		if spec.IsNegative() {
+			continue
+		}
+
 		rev := spec.Reverse()
 		for _, ref := range localRefs {
-			if !rev.Match(ref.Name()) {
+			if seen[ref.Name()] || ref.Type() != plumbing.HashReference || !rev.Match(ref.Name()) {
 				continue
 			}
-			_, err := remoteRefs.Reference(rev.Dst(ref.Name()))
+
+			name := rev.Dst(ref.Name())
+			if config.IsExcluded(specs, name) {
+				continue
+			}
+
+			_, err := remoteRefs.Reference(name)
 			if errors.Is(err, plumbing.ErrReferenceNotFound) {
-				updatedPrune = true
 				err := r.s.RemoveReference(ref.Name())
 				if err != nil {
-					return false, err
+					return nil, err
 				}
+
+				seen[ref.Name()] = true
+				pruned = append(pruned, ref)
 			}
 		}
 	}
-	return updatedPrune, nil
+	return pruned, nil
 }
 
 func (r *Remote) addReferencesToUpdate(
@@ -775,119 +933,44 @@ func (r *Remote) checkForceWithLease(localRef *plumbing.Reference, cmd *packp.Co
 	return nil
 }
 
-func getRemoteRefsFromStorer(remoteRefStorer storer.ReferenceStorer) (
-	map[plumbing.Hash]bool, error,
-) {
-	remoteRefs := map[plumbing.Hash]bool{}
-	iter, err := remoteRefStorer.IterReferences()
-	if err != nil {
-		return nil, err
-	}
-	err = iter.ForEach(func(ref *plumbing.Reference) error {
-		if ref.Type() != plumbing.HashReference {
-			return nil
-		}
-		remoteRefs[ref.Hash()] = true
-		return nil
-	})
-	if err != nil {
-		return nil, err
-	}
-	return remoteRefs, nil
-}
-
-// getHavesFromRef populates the given `haves` map with the given
-// reference, and up to `maxHavesToVisitPerRef` ancestor commits.
-func getHavesFromRef(
-	ref *plumbing.Reference,
-	remoteRefs map[plumbing.Hash]bool,
-	s storage.Storer,
-	haves map[plumbing.Hash]bool,
-	depth int,
-) error {
-	h := ref.Hash()
-	if haves[h] {
-		return nil
-	}
-
-	commit, err := object.GetCommit(s, h)
-	if err != nil {
-		if !errors.Is(err, plumbing.ErrObjectNotFound) {
-			// Ignore the error if this isn't a commit.
-			haves[ref.Hash()] = true
-		}
-		return nil
-	}
-
-	// Until go-git supports proper commit negotiation during an
-	// upload pack request, include up to `maxHavesToVisitPerRef`
-	// commits from the history of each ref.
-	walker := object.NewCommitPreorderIter(commit, haves, nil)
-	toVisit := maxHavesToVisitPerRef
-	// But only need up to the requested depth
-	if depth > 0 && depth < maxHavesToVisitPerRef {
-		toVisit = depth
-	}
-	// It is safe to ignore any error here as we are just trying to find the references that we already have
-	// An example of a legitimate failure is we have a shallow clone and don't have the previous commit(s)
-	_ = walker.ForEach(func(c *object.Commit) error {
-		haves[c.Hash] = true
-		toVisit--
-		// If toVisit starts out at 0 (indicating there is no
-		// max), then it will be negative here and we won't stop
-		// early.
-		if toVisit == 0 || remoteRefs[c.Hash] {
-			return storer.ErrStop
-		}
-		return nil
-	})
-
-	return nil
-}
-
+// getHaves returns the hashes of the local references found in the storer.
+// Their history is walked by the transport during the negotiation, sending
+// the most recent commits first.
 func getHaves(
 	localRefs []*plumbing.Reference,
-	remoteRefStorer storer.ReferenceStorer,
 	s storage.Storer,
-	depth int,
 ) ([]plumbing.Hash, error) {
-	haves := map[plumbing.Hash]bool{}
-
-	// Build a map of all the remote references, to avoid loading too
-	// many parent commits for references we know don't need to be
-	// transferred.
-	remoteRefs, err := getRemoteRefsFromStorer(remoteRefStorer)
-	if err != nil {
-		return nil, err
-	}
-
+	seen := map[plumbing.Hash]bool{}
+	var haves []plumbing.Hash
 	for _, ref := range localRefs {
-		if haves[ref.Hash()] {
+		if ref.Type() != plumbing.HashReference || seen[ref.Hash()] {
 			continue
 		}
 
-		if ref.Type() != plumbing.HashReference {
+		seen[ref.Hash()] = true
+		err := s.HasEncodedObject(ref.Hash())
+		if errors.Is(err, plumbing.ErrObjectNotFound) {
 			continue
 		}
 
-		err = getHavesFromRef(ref, remoteRefs, s, haves, depth)
 		if err != nil {
 			return nil, err
 		}
-	}
 
-	var result []plumbing.Hash
-	for h := range haves {
-		result = append(result, h)
+		haves = append(haves, ref.Hash())
 	}
 
-	return result, nil
+	return haves, nil
 }
 
 const refspecAllTags = "+refs/tags/*:refs/tags/*"
 
+// calculateRefs expands the positive refspecs against the remote references,
+// returning the references to fetch and the ones matched by each refspec, the
+// references matched by a negative refspec being left out. The tags are
+// fetched with an additional refspec when the tag mode is AllTags.
 func calculateRefs(
-	spec []config.RefSpec,
+	spec, negative []config.RefSpec,
 	remoteRefs storer.ReferenceStorer,
 	tagMode plumbing.TagMode,
 ) (memory.ReferenceStorage, [][]*plumbing.Reference, error) {
@@ -900,7 +983,7 @@ func calculateRefs(
 	specToRefs := make([][]*plumbing.Reference, len(spec))
 	for i := range spec {
 		var err error
-		specToRefs[i], err = doCalculateRefs(spec[i], remoteRefs, refs)
+		specToRefs[i], err = doCalculateRefs(spec[i], negative, remoteRefs, refs)
 		if err != nil {
 			return nil, nil, err
 		}
@@ -911,6 +994,7 @@ func calculateRefs(
 
 func doCalculateRefs(
 	s config.RefSpec,
+	negative []config.RefSpec,
 	remoteRefs storer.ReferenceStorer,
 	refs memory.ReferenceStorage,
 ) ([]*plumbing.Reference, error) {
@@ -939,6 +1023,10 @@ func doCalculateRefs(
 		}
 
 		matched = true
+		if config.IsExcluded(negative, ref.Name()) {
+			return nil
+		}
+
 		refList = append(refList, ref)
 		return refs.SetReference(ref)
 	}
@@ -972,11 +1060,12 @@ func doCalculateRefs(
 	return refList, ret
 }
 
-func getWants(localStorer storage.Storer, refs memory.ReferenceStorage, depth int) ([]plumbing.Hash, error) {
-	// If depth is anything other than 1 and the repo has shallow commits then just because we have the commit
-	// at the reference doesn't mean that we don't still need to fetch the parents
+func getWants(localStorer storage.Storer, refs memory.ReferenceStorage, depth int, relative bool) ([]plumbing.Hash, error) {
+	// If depth is anything other than 1, or relative to the shallow boundary, and the repo has shallow commits
+	// then just because we have the commit at the reference doesn't mean that we don't still need to fetch the
+	// parents
 	shallow := false
-	if depth != 1 {
+	if depth != 1 || relative {
 		if s, _ := localStorer.Shallow(); len(s) > 0 {
 			shallow = true
 		}
@@ -1110,7 +1199,7 @@ func buildSidebandIfSupported(l *capability.List, reader io.Reader, p sideband.P
 }
 
 func (r *Remote) updateLocalReferenceStorage(
-	specs []config.RefSpec,
+	specs, negative []config.RefSpec,
 	fetchedRefs, remoteRefs memory.ReferenceStorage,
 	specToRefs [][]*plumbing.Reference,
 	tagMode plumbing.TagMode,
@@ -1170,7 +1259,7 @@ func (r *Remote) updateLocalReferenceStorage(
 	if isWildcard {
 		tags = remoteRefs
 	}
-	tagUpdated, err := r.buildFetchedTags(tags)
+	tagUpdated, err := r.buildFetchedTags(tags, negative)
 	if err != nil {
 		return updated, err
 	}
@@ -1186,9 +1275,9 @@ func (r *Remote) updateLocalReferenceStorage(
 	return
 }
 
-func (r *Remote) buildFetchedTags(refs memory.ReferenceStorage) (updated bool, err error) {
+func (r *Remote) buildFetchedTags(refs memory.ReferenceStorage, negative []config.RefSpec) (updated bool, err error) {
 	for _, ref := range refs {
-		if !ref.Name().IsTag() {
+		if !ref.Name().IsTag() || config.IsExcluded(negative, ref.Name()) {
 			continue
 		}
 
@@ -1241,7 +1330,12 @@ func (r *Remote) list(ctx context.Context, o *ListOptions) (rfs []*plumbing.Refe
 		return nil, ErrEmptyUrls
 	}
 
-	c, ep, err := newClient(r.c.URLs[0], o.InsecureSkipTLS, o.CABundle, o.ProxyOptions)
+	remoteURL, err := r.rewriteURL(r.c.OriginalURLs()[0], false)
+	if err != nil {
+		return nil, err
+	}
+
+	c, ep, err := newClient(remoteURL, o.InsecureSkipTLS, o.CABundle, o.ProxyOptions)
 	if err != nil {
 		return nil, err
 	}
@@ -1294,6 +1388,108 @@ func objectsToPush(commands []*packp.Command) []plumbing.Hash {
 	return objects
 }
 
+// writingWriter reports the bytes of the packfile written to it.
+type writingWriter struct {
+	w        io.Writer
+	progress sideband.Progress
+	total    uint64
+	bytes    uint64
+}
+
+func (w *writingWriter) Write(p []byte) (int, error) {
+	n, err := w.w.Write(p)
+	w.bytes += uint64(n)
+	sideband.Report(w.progress, sideband.ProgressReport{
+		Stage: sideband.StageWriting,
+		Total: w.total,
+		Bytes: w.bytes,
+	})
+
+	return n, err
+}
+
+// thinPackBases returns the objects of the commits the server has on the
+// references being updated, to be used as delta bases of a thin packfile. As
+// git does with its preferred bases, only the objects at the paths modified
+// by the update are returned: the blobs replaced and the trees containing
+// them, the ones the pushed objects are likely to be similar to. The commits
+// not found locally are ignored.
+func thinPackBases(s storer.EncodedObjectStorer, cmds []*packp.Command) ([]plumbing.Hash, error) {
+	seen := make(map[plumbing.Hash]bool)
+	var bases []plumbing.Hash
+	add := func(h plumbing.Hash) {
+		if !seen[h] {
+			seen[h] = true
+			bases = append(bases, h)
+		}
+	}
+
+	for _, cmd := range cmds {
+		if cmd.Old.IsZero() || cmd.New.IsZero() {
+			continue
+		}
+
+		old, err := object.GetCommit(s, cmd.Old)
+		if errors.Is(err, plumbing.ErrObjectNotFound) {
+			continue
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		c, err := object.GetCommit(s, cmd.New)
+		if err != nil {
+			return nil, err
+		}
+
+		from, err := old.Tree()
+		if err != nil {
+			return nil, err
+		}
+
+		to, err := c.Tree()
+		if err != nil {
+			return nil, err
+		}
+
+		if from.Hash == to.Hash {
+			continue
+		}
+
+		changes, err := object.DiffTree(from, to)
+		if err != nil {
+			return nil, err
+		}
+
+		add(from.Hash)
+		dirs := make(map[string]bool)
+		for _, ch := range changes {
+			// Only the paths found in both trees have a base.
+			if ch.From.Name == "" || ch.To.Name == "" {
+				continue
+			}
+
+			if ch.From.TreeEntry.Mode == filemode.Submodule {
+				continue
+			}
+
+			add(ch.From.TreeEntry.Hash)
+			for dir := path.Dir(ch.From.Name); dir != "." && !dirs[dir]; dir = path.Dir(dir) {
+				dirs[dir] = true
+				e, err := from.FindEntry(dir)
+				if err != nil {
+					return nil, err
+				}
+
+				add(e.Hash)
+			}
+		}
+	}
+
+	return bases, nil
+}
+
 func referencesToHashes(refs storer.ReferenceStorer) ([]plumbing.Hash, error) {
 	iter, err := refs.IterReferences()
 	if err != nil {
@@ -1321,6 +1517,7 @@ func pushHashes(
 	conn transport.Connection,
 	s storage.Storer,
 	cmds []*packp.Command,
+	cert *packp.PushCertificate,
 	hs []plumbing.Hash,
 	allDelete bool,
 	o *PushOptions,
@@ -1333,26 +1530,54 @@ func pushHashes(
 		return err
 	}
 
+	opts := []packfile.EncoderOption{
+		packfile.WithMaxDeltaDepth(config.Pack.Depth),
+		packfile.WithCompressionLevel(config.Pack.Compression),
+	}
+	if !allDelete && !conn.Capabilities().Supports(capability.NoThin) {
+		bases, err := thinPackBases(s, cmds)
+		if err != nil {
+			return err
+		}
+
+		opts = append(opts, packfile.WithThinPack(bases))
+	}
+
 	// Set buffer size to 1 so the error message can be written when
 	// ReceivePack fails. Otherwise the goroutine will be blocked writing
 	// to the channel.
 	done := make(chan error, 1)
 	req := &transport.PushRequest{
-		Commands: cmds,
-		Progress: o.Progress,
-		Options:  o.Options,
-		Atomic:   o.Atomic,
+		Commands:    cmds,
+		Progress:    o.Progress,
+		Options:     o.Options,
+		Atomic:      o.Atomic,
+		Certificate: cert,
 	}
 
 	if !allDelete {
 		req.Packfile = rd
 		go func() {
-			e := packfile.NewEncoder(wr, s, useRefDeltas)
+			writing := &writingWriter{w: wr, progress: o.Progress, total: uint64(len(hs))}
+			var w io.Writer = wr
+			if _, ok := o.Progress.(sideband.ProgressReporter); ok {
+				w = writing
+			}
+
+			e := packfile.NewEncoder(w, s, useRefDeltas, opts...)
 			if _, err := e.Encode(hs, config.Pack.Window); err != nil {
 				done <- wr.CloseWithError(err)
 				return
 			}
 
+			sideband.Report(o.Progress, sideband.ProgressReport{
+				Stage:   sideband.StageWriting,
+				Current: writing.total,
+				Total:   writing.total,
+				Bytes:   writing.bytes,
+				Done:    true,
+			})
+
 			done <- wr.Close()
 		}()
 	} else {
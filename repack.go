@@ -0,0 +1,291 @@
+package git
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/go-git/go-git/v6/plumbing/format/idxfile"
+	"github.com/go-git/go-git/v6/plumbing/storer"
+	"github.com/go-git/go-git/v6/storage/filesystem/dotgit"
+	"github.com/go-git/go-git/v6/utils/ioutil"
+)
+
+// repackLooseObjects packs the loose objects, along with the objects of the
+// small packs when consolidating them, into a new pack. The loose objects and
+// the small packs are only deleted once the new pack is written, so the
+// objects can be read during the whole repack.
+func (r *Repository) repackLooseObjects(cfg *RepackConfig) error {
+	los, ok := r.Storer.(storer.LooseObjectStorer)
+	if !ok {
+		return ErrLooseObjectsNotSupported
+	}
+
+	seen := make(map[plumbing.Hash]struct{})
+	var objs []plumbing.Hash
+	add := func(h plumbing.Hash) {
+		if _, ok := seen[h]; !ok {
+			seen[h] = struct{}{}
+			objs = append(objs, h)
+		}
+	}
+
+	var loose []plumbing.Hash
+	err := los.ForEachObjectHash(func(h plumbing.Hash) error {
+		loose = append(loose, h)
+		add(h)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	var small []plumbing.Hash
+	if cfg.ConsolidatePacks {
+		if small, err = r.smallObjectPacks(cfg.SmallPackSize); err != nil {
+			return err
+		}
+
+		// Repacking a single pack alone is pointless.
+		if len(loose) == 0 && len(small) < 2 {
+			small = nil
+		}
+
+		for _, pack := range small {
+			hashes, err := r.objectPackHashes(pack)
+			if err != nil {
+				return err
+			}
+
+			for _, h := range hashes {
+				add(h)
+			}
+		}
+	}
+
+	if len(objs) == 0 {
+		return nil
+	}
+
+	nh, err := r.writeObjectPack(cfg, objs)
+	if err != nil {
+		return err
+	}
+
+	for _, h := range loose {
+		if err := los.DeleteLooseObject(h); err != nil {
+			return err
+		}
+	}
+
+	if len(small) == 0 {
+		return nil
+	}
+
+	pos := r.Storer.(storer.PackedObjectStorer)
+	for _, h := range small {
+		if h == nh {
+			continue
+		}
+
+		if err := pos.DeleteOldObjectPackAndIndex(h, cfg.OnlyDeletePacksOlderThan); err != nil {
+			return err
+		}
+	}
+
+	// Forget the indexes of the deleted packs.
+	if ri, ok := r.Storer.(interface{ Reindex() }); ok {
+		ri.Reindex()
+	}
+
+	return nil
+}
+
+// KeepPack creates the .keep file of the given pack, so it's never deleted
+// nor consolidated by RepackObjects, as git does. Its objects aren't copied
+// into the new packs either. It fails with dotgit.ErrPackfileNotFound if the
+// pack doesn't exist.
+func (r *Repository) KeepPack(pack plumbing.Hash) (err error) {
+	fs, err := r.objectPacksFilesystem()
+	if err != nil {
+		return err
+	}
+
+	if _, err := fs.Stat(objectPackPath(pack, "pack")); os.IsNotExist(err) {
+		return fmt.Errorf("%w: %s", dotgit.ErrPackfileNotFound, pack)
+	} else if err != nil {
+		return err
+	}
+
+	f, err := fs.Create(objectPackPath(pack, "keep"))
+	if err != nil {
+		return err
+	}
+
+	return f.Close()
+}
+
+// UnkeepPack removes the .keep file of the given pack, if any, so it can be
+// repacked again.
+func (r *Repository) UnkeepPack(pack plumbing.Hash) error {
+	fs, err := r.objectPacksFilesystem()
+	if err != nil {
+		return err
+	}
+
+	err = fs.Remove(objectPackPath(pack, "keep"))
+	if os.IsNotExist(err) {
+		return nil
+	}
+
+	return err
+}
+
+// keptObjectPacks returns the given packs having a .keep file. None is kept
+// when the storer doesn't hold its packs in a filesystem.
+func (r *Repository) keptObjectPacks(packs []plumbing.Hash) (map[plumbing.Hash]bool, error) {
+	fs, err := r.objectPacksFilesystem()
+	if errors.Is(err, ErrPackedObjectsNotSupported) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	kept := make(map[plumbing.Hash]bool)
+	for _, h := range packs {
+		_, err := fs.Stat(objectPackPath(h, "keep"))
+		if os.IsNotExist(err) {
+			continue
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		kept[h] = true
+	}
+
+	return kept, nil
+}
+
+// keptObjects returns the objects of the given packs, excluded from the
+// packs written by RepackObjects.
+func (r *Repository) keptObjects(kept map[plumbing.Hash]bool) (map[plumbing.Hash]bool, error) {
+	objs := make(map[plumbing.Hash]bool)
+	for pack := range kept {
+		hashes, err := r.objectPackHashes(pack)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, h := range hashes {
+			objs[h] = true
+		}
+	}
+
+	return objs, nil
+}
+
+// objectPacksFilesystem returns the filesystem of the repository storer,
+// holding the packs in objects/pack.
+func (r *Repository) objectPacksFilesystem() (billy.Filesystem, error) {
+	type fsBased interface {
+		Filesystem() billy.Filesystem
+	}
+
+	fs, ok := r.Storer.(fsBased)
+	if !ok {
+		return nil, ErrPackedObjectsNotSupported
+	}
+
+	if _, ok := r.Storer.(storer.PackedObjectStorer); !ok {
+		return nil, ErrPackedObjectsNotSupported
+	}
+
+	return fs.Filesystem(), nil
+}
+
+func objectPackPath(h plumbing.Hash, ext string) string {
+	return path.Join("objects", "pack", "pack-"+h.String()+"."+ext)
+}
+
+// smallObjectPacks returns the packs smaller than the given size in bytes, or
+// every pack if the size is zero, leaving out the kept ones.
+func (r *Repository) smallObjectPacks(size int64) ([]plumbing.Hash, error) {
+	fs, err := r.objectPacksFilesystem()
+	if err != nil {
+		return nil, err
+	}
+
+	packs, err := r.Storer.(storer.PackedObjectStorer).ObjectPacks()
+	if err != nil {
+		return nil, err
+	}
+
+	kept, err := r.keptObjectPacks(packs)
+	if err != nil {
+		return nil, err
+	}
+
+	var small []plumbing.Hash
+	for _, h := range packs {
+		if kept[h] {
+			continue
+		}
+
+		fi, err := fs.Stat(objectPackPath(h, "pack"))
+		if err != nil {
+			return nil, err
+		}
+
+		if size == 0 || fi.Size() < size {
+			small = append(small, h)
+		}
+	}
+
+	return small, nil
+}
+
+// objectPackHashes returns the hashes of the objects in the given pack, read
+// from its idx file.
+func (r *Repository) objectPackHashes(pack plumbing.Hash) (hashes []plumbing.Hash, err error) {
+	fs, err := r.objectPacksFilesystem()
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := fs.Open(objectPackPath(pack, "idx"))
+	if err != nil {
+		return nil, err
+	}
+	defer ioutil.CheckClose(f, &err)
+
+	idx := idxfile.NewMemoryIndex(pack.Size())
+	if err := idxfile.NewDecoder(f).Decode(idx); err != nil {
+		return nil, err
+	}
+
+	iter, err := idx.Entries()
+	if err != nil {
+		return nil, err
+	}
+	defer ioutil.CheckClose(iter, &err)
+
+	for {
+		e, err := iter.Next()
+		if errors.Is(err, io.EOF) {
+			return hashes, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		hashes = append(hashes, e.Hash)
+	}
+}
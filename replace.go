@@ -0,0 +1,455 @@
+package git
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/go-git/go-git/v6/plumbing/object"
+	"github.com/go-git/go-git/v6/storage"
+	"github.com/go-git/go-git/v6/utils/ioutil"
+)
+
+const (
+	replaceRefPrefix = "refs/replace/"
+	packedRefsPath   = "packed-refs"
+	graftsPath       = "info/grafts"
+
+	// maxReplaceDepth is the maximum number of replacements followed for an
+	// object, as git does.
+	maxReplaceDepth = 5
+)
+
+var (
+	// ErrReplaceDepthExceeded is returned when reading an object replaced by
+	// a chain of more than 5 replace references, e.g. because they form a
+	// loop.
+	ErrReplaceDepthExceeded = errors.New("replace depth too high")
+	// ErrReplaceRefExists is returned by CreateGraftReplacement when the
+	// commit is already replaced.
+	ErrReplaceRefExists = errors.New("replace reference already exists")
+	// ErrGraftUnchanged is returned by CreateGraftReplacement when the new
+	// parents are the ones of the commit.
+	ErrGraftUnchanged = errors.New("new commit is the same as the old one")
+)
+
+// SetReplaceObjects sets whether the replace references, `refs/replace/<oid>`,
+// and the `info/grafts` file are applied when reading objects through the
+// repository, e.g. by CommitObject or Log. They are applied by default, it
+// can be disabled to read the raw objects, as `git --no-replace-objects`
+// does.
+func (r *Repository) SetReplaceObjects(enabled bool) {
+	r.noReplaceObjects = !enabled
+}
+
+// CreateGraftReplacement replaces the given commit with a copy having the
+// given parents, as `git replace --graft` does: the copy keeps the tree, the
+// author, the committer and the message of the commit, and is written along
+// with the `refs/replace/<oid>` reference, named after the full hash of the
+// commit. The history is so grafted without rewriting the descendants of the
+// commit. The signature of the commit, no longer valid, isn't copied.
+//
+// It fails with ErrReplaceRefExists if the commit is already replaced, and
+// with ErrGraftUnchanged if the parents are the ones of the commit. The hash
+// of the replacement commit is returned.
+func (r *Repository) CreateGraftReplacement(commit plumbing.Hash, newParents []plumbing.Hash) (plumbing.Hash, error) {
+	name := plumbing.ReferenceName(replaceRefPrefix + commit.String())
+	if _, err := r.Storer.Reference(name); err == nil {
+		return plumbing.ZeroHash, fmt.Errorf("%w: %s", ErrReplaceRefExists, name)
+	} else if err != plumbing.ErrReferenceNotFound {
+		return plumbing.ZeroHash, err
+	}
+
+	objs, err := r.objectStorer()
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	c, err := object.GetCommit(objs, commit)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	if slices.Equal(c.ParentHashes, newParents) {
+		return plumbing.ZeroHash, ErrGraftUnchanged
+	}
+
+	for _, p := range newParents {
+		if _, err := object.GetCommit(objs, p); err != nil {
+			return plumbing.ZeroHash, fmt.Errorf("invalid parent %s: %w", p, err)
+		}
+	}
+
+	obj, err := objs.EncodedObject(plumbing.CommitObject, commit)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	content, err := readObject(obj)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	grafted := r.Storer.NewEncodedObject()
+	grafted.SetType(plumbing.CommitObject)
+	if err := writeObject(grafted, rewriteParents(content, newParents, true)); err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	h, err := r.Storer.SetEncodedObject(grafted)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	// The modification time of the reference may not change on file systems
+	// with a coarse time resolution.
+	defer r.invalidateReplaceObjects()
+	return h, r.Storer.SetReference(plumbing.NewHashReference(name, h))
+}
+
+// objectStorer returns the storer used to read the objects of the
+// repository, applying the replace references and grafts if any, on top of
+// the one fetching the objects promised to a partial clone.
+func (r *Repository) objectStorer() (storage.Storer, error) {
+	s := r.promisorStorer()
+	if r.noReplaceObjects {
+		return s, nil
+	}
+
+	objs, err := r.loadReplaceObjects()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(objs.replace) == 0 && len(objs.grafts) == 0 {
+		return s, nil
+	}
+
+	return &replaceObjectStorer{
+		Storer:  s,
+		replace: objs.replace,
+		grafts:  objs.grafts,
+	}, nil
+}
+
+// replaceObjects are the replace references and grafts of a repository, with
+// the stamp of the files they were read from, used to detect changes.
+type replaceObjects struct {
+	stamp   []fileStamp
+	replace map[plumbing.Hash]plumbing.Hash
+	grafts  map[plumbing.Hash][]plumbing.Hash
+}
+
+// fileStamp is the modification time and size of a file.
+type fileStamp struct {
+	name    string
+	modTime time.Time
+	size    int64
+}
+
+func newFileStamp(name string, fi os.FileInfo) fileStamp {
+	return fileStamp{name: name, modTime: fi.ModTime(), size: fi.Size()}
+}
+
+func (s fileStamp) equal(o fileStamp) bool {
+	return s.name == o.name && s.modTime.Equal(o.modTime) && s.size == o.size
+}
+
+// loadReplaceObjects returns the replace references and grafts. With
+// filesystem based storers, they are only read again when the files they
+// come from change since they were last read. Other storers don't allow to
+// detect changes, so they are read on every call.
+func (r *Repository) loadReplaceObjects() (*replaceObjects, error) {
+	stamp, ok, err := r.replaceStamp()
+	if err != nil {
+		return nil, err
+	}
+
+	r.replaceMu.Lock()
+	defer r.replaceMu.Unlock()
+
+	if c := r.replaceObjs; ok && c != nil && slices.EqualFunc(c.stamp, stamp, fileStamp.equal) {
+		return c, nil
+	}
+
+	replace, err := r.replaceRefs()
+	if err != nil {
+		return nil, err
+	}
+
+	grafts, err := r.grafts()
+	if err != nil {
+		return nil, err
+	}
+
+	// The files may change between the stamp and the reads, in that case the
+	// stamp kept is outdated and they are read again on the next call.
+	objs := &replaceObjects{stamp: stamp, replace: replace, grafts: grafts}
+	if ok {
+		r.replaceObjs = objs
+	}
+
+	return objs, nil
+}
+
+// invalidateReplaceObjects drops the replace references and grafts loaded,
+// so they are read again on the next access.
+func (r *Repository) invalidateReplaceObjects() {
+	r.replaceMu.Lock()
+	r.replaceObjs = nil
+	r.replaceMu.Unlock()
+}
+
+// replaceStamp returns the stamp of the files the replace references and
+// grafts are read from: the loose replace references, the packed-refs file
+// and the info/grafts file. The second return value is false if the storer
+// isn't filesystem based.
+func (r *Repository) replaceStamp() ([]fileStamp, bool, error) {
+	type fsBased interface {
+		Filesystem() billy.Filesystem
+	}
+
+	sfs, ok := r.Storer.(fsBased)
+	if !ok {
+		return nil, false, nil
+	}
+
+	fs := sfs.Filesystem()
+	entries, err := fs.ReadDir(replaceRefPrefix)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, false, err
+	}
+
+	stamp := make([]fileStamp, 0, len(entries)+2)
+	for _, fi := range entries {
+		stamp = append(stamp, newFileStamp(path.Join(replaceRefPrefix, fi.Name()), fi))
+	}
+
+	for _, name := range []string{packedRefsPath, graftsPath} {
+		fi, err := fs.Stat(name)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, false, err
+		}
+
+		stamp = append(stamp, newFileStamp(name, fi))
+	}
+
+	return stamp, true, nil
+}
+
+// replaceRefs returns the replaced objects, with their replacement.
+func (r *Repository) replaceRefs() (map[plumbing.Hash]plumbing.Hash, error) {
+	iter, err := r.Storer.IterReferences()
+	if err != nil {
+		return nil, err
+	}
+	defer iter.Close()
+
+	replace := make(map[plumbing.Hash]plumbing.Hash)
+	err = iter.ForEach(func(ref *plumbing.Reference) error {
+		name := ref.Name().String()
+		if ref.Type() != plumbing.HashReference || !strings.HasPrefix(name, replaceRefPrefix) {
+			return nil
+		}
+
+		if h, ok := plumbing.FromHex(name[len(replaceRefPrefix):]); ok {
+			replace[h] = ref.Hash()
+		}
+
+		return nil
+	})
+
+	return replace, err
+}
+
+// grafts returns the parents of the commits in the `info/grafts` file of
+// filesystem based storers.
+func (r *Repository) grafts() (grafts map[plumbing.Hash][]plumbing.Hash, err error) {
+	type fsBased interface {
+		Filesystem() billy.Filesystem
+	}
+
+	fs, ok := r.Storer.(fsBased)
+	if !ok {
+		return nil, nil
+	}
+
+	f, err := fs.Filesystem().Open(graftsPath)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer ioutil.CheckClose(f, &err)
+
+	return readGrafts(f)
+}
+
+// readGrafts parses a grafts file, made of lines with the hash of a commit
+// followed by the ones of its parents, if any, separated by spaces.
+func readGrafts(r io.Reader) (map[plumbing.Hash][]plumbing.Hash, error) {
+	grafts := make(map[plumbing.Hash][]plumbing.Hash)
+
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		fields := strings.Fields(s.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+
+		hashes := make([]plumbing.Hash, 0, len(fields))
+		for _, f := range fields {
+			if h, ok := plumbing.FromHex(f); ok && len(f) == h.HexSize() {
+				hashes = append(hashes, h)
+			}
+		}
+
+		// Lines with malformed hashes are ignored, as git does.
+		if len(hashes) == len(fields) {
+			grafts[hashes[0]] = hashes[1:]
+		}
+	}
+
+	return grafts, s.Err()
+}
+
+// replaceObjectStorer is a storage.Storer returning the content of the
+// replacement of the objects with a replace reference, and the commits with
+// the parents given by the grafts. The objects returned keep the hash they
+// are requested with, as git does.
+type replaceObjectStorer struct {
+	storage.Storer
+	replace map[plumbing.Hash]plumbing.Hash
+	grafts  map[plumbing.Hash][]plumbing.Hash
+}
+
+func (s *replaceObjectStorer) EncodedObject(t plumbing.ObjectType, h plumbing.Hash) (plumbing.EncodedObject, error) {
+	target := h
+	for depth := 0; ; depth++ {
+		next, ok := s.replace[target]
+		if !ok {
+			break
+		}
+
+		if depth == maxReplaceDepth {
+			return nil, ErrReplaceDepthExceeded
+		}
+
+		target = next
+	}
+
+	obj, err := s.Storer.EncodedObject(t, target)
+	if err != nil {
+		return nil, err
+	}
+
+	if parents, ok := s.grafts[h]; ok && obj.Type() == plumbing.CommitObject {
+		obj, err = graftCommit(obj, parents)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if target == h && obj.Hash() == h {
+		return obj, nil
+	}
+
+	return &replacedObject{EncodedObject: obj, hash: h}, nil
+}
+
+// graftCommit returns a copy of the given commit object with its parents
+// replaced.
+func graftCommit(obj plumbing.EncodedObject, parents []plumbing.Hash) (plumbing.EncodedObject, error) {
+	content, err := readObject(obj)
+	if err != nil {
+		return nil, err
+	}
+
+	// The hash of the copy isn't used, it keeps the one of the original.
+	grafted := &plumbing.MemoryObject{}
+	grafted.SetType(plumbing.CommitObject)
+	if _, err := grafted.Write(rewriteParents(content, parents, false)); err != nil {
+		return nil, err
+	}
+
+	return grafted, nil
+}
+
+// rewriteParents returns the content of a commit object with its parents
+// replaced, and without its signature headers if dropSignature is set.
+func rewriteParents(content []byte, parents []plumbing.Hash, dropSignature bool) []byte {
+	header, message := content, []byte(nil)
+	if i := bytes.Index(content, []byte("\n\n")); i >= 0 {
+		header, message = content[:i+1], content[i+1:]
+	}
+
+	var buf bytes.Buffer
+	var skipping bool
+	for _, line := range bytes.SplitAfter(header, []byte("\n")) {
+		// The values of the headers spanning several lines, such as the
+		// signatures, continue on the lines starting with a space.
+		if skipping && bytes.HasPrefix(line, []byte(" ")) {
+			continue
+		}
+
+		skipping = dropSignature && (bytes.HasPrefix(line, []byte("gpgsig ")) ||
+			bytes.HasPrefix(line, []byte("gpgsig-sha256 ")))
+		if skipping || bytes.HasPrefix(line, []byte("parent ")) {
+			continue
+		}
+
+		buf.Write(line)
+		if bytes.HasPrefix(line, []byte("tree ")) {
+			for _, p := range parents {
+				buf.WriteString("parent " + p.String() + "\n")
+			}
+		}
+	}
+	buf.Write(message)
+
+	return buf.Bytes()
+}
+
+func readObject(obj plumbing.EncodedObject) (content []byte, err error) {
+	r, err := obj.Reader()
+	if err != nil {
+		return nil, err
+	}
+	defer ioutil.CheckClose(r, &err)
+
+	return io.ReadAll(r)
+}
+
+func writeObject(obj plumbing.EncodedObject, content []byte) (err error) {
+	w, err := obj.Writer()
+	if err != nil {
+		return err
+	}
+	defer ioutil.CheckClose(w, &err)
+
+	_, err = w.Write(content)
+	return err
+}
+
+// replacedObject is an EncodedObject with the content of another one.
+type replacedObject struct {
+	plumbing.EncodedObject
+	hash plumbing.Hash
+}
+
+func (o *replacedObject) Hash() plumbing.Hash {
+	return o.hash
+}
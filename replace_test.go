@@ -0,0 +1,236 @@
+package git
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/go-git/go-billy/v5/util"
+	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/go-git/go-git/v6/plumbing/cache"
+	"github.com/go-git/go-git/v6/plumbing/object"
+	"github.com/go-git/go-git/v6/storage/filesystem"
+	"github.com/go-git/go-git/v6/storage/memory"
+	"github.com/stretchr/testify/suite"
+)
+
+type ReplaceSuite struct {
+	suite.Suite
+	r *Repository
+
+	// root <- a <- head and root <- side
+	root, a, head, side plumbing.Hash
+}
+
+func TestReplaceSuite(t *testing.T) {
+	suite.Run(t, new(ReplaceSuite))
+}
+
+func (s *ReplaceSuite) SetupTest() {
+	var err error
+	s.r, err = Init(filesystem.NewStorage(memfs.New(), cache.NewObjectLRUDefault()))
+	s.Require().NoError(err)
+
+	s.root = s.commit("root", nil)
+	s.a = s.commit("a", []plumbing.Hash{s.root})
+	s.head = s.commit("head", []plumbing.Hash{s.a})
+	s.side = s.commit("side", []plumbing.Hash{s.root})
+}
+
+func (s *ReplaceSuite) commit(msg string, parents []plumbing.Hash) plumbing.Hash {
+	c := &object.Commit{
+		Author:       *defaultSignature(),
+		Committer:    *defaultSignature(),
+		Message:      msg + "\n",
+		TreeHash:     plumbing.NewHash("4b825dc642cb6eb9a060e54bf8d69288fbee4904"),
+		ParentHashes: parents,
+	}
+
+	obj := s.r.Storer.NewEncodedObject()
+	s.Require().NoError(c.Encode(obj))
+	h, err := s.r.Storer.SetEncodedObject(obj)
+	s.Require().NoError(err)
+	return h
+}
+
+func (s *ReplaceSuite) replace(from, to plumbing.Hash) {
+	name := plumbing.ReferenceName("refs/replace/" + from.String())
+	s.Require().NoError(s.r.Storer.SetReference(plumbing.NewHashReference(name, to)))
+}
+
+func (s *ReplaceSuite) log() []string {
+	iter, err := s.r.Log(&LogOptions{From: s.head})
+	s.Require().NoError(err)
+
+	var messages []string
+	s.Require().NoError(iter.ForEach(func(c *object.Commit) error {
+		messages = append(messages, strings.TrimSpace(c.Message))
+		return nil
+	}))
+	return messages
+}
+
+func (s *ReplaceSuite) TestReplaceRef() {
+	// a is replaced by a commit on top of side.
+	s.replace(s.a, s.commit("replaced a", []plumbing.Hash{s.side}))
+
+	c, err := s.r.CommitObject(s.a)
+	s.Require().NoError(err)
+	s.Equal(s.a, c.Hash)
+	s.Equal("replaced a\n", c.Message)
+	s.Equal([]plumbing.Hash{s.side}, c.ParentHashes)
+
+	s.Equal([]string{"head", "replaced a", "side", "root"}, s.log())
+
+	s.r.SetReplaceObjects(false)
+	s.Equal([]string{"head", "a", "root"}, s.log())
+
+	c, err = s.r.CommitObject(s.a)
+	s.Require().NoError(err)
+	s.Equal("a\n", c.Message)
+}
+
+func (s *ReplaceSuite) TestReplaceRefLogCommitterTime() {
+	s.replace(s.a, s.commit("replaced a", []plumbing.Hash{s.side}))
+
+	iter, err := s.r.Log(&LogOptions{From: s.head, Order: LogOrderCommitterTime})
+	s.Require().NoError(err)
+
+	var hashes []plumbing.Hash
+	s.Require().NoError(iter.ForEach(func(c *object.Commit) error {
+		hashes = append(hashes, c.Hash)
+		return nil
+	}))
+	s.Equal([]plumbing.Hash{s.head, s.a, s.side, s.root}, hashes)
+}
+
+func (s *ReplaceSuite) TestReplaceRefLoop() {
+	replacement := s.commit("replaced a", []plumbing.Hash{s.root})
+	s.replace(s.a, replacement)
+	s.replace(replacement, s.a)
+
+	_, err := s.r.CommitObject(s.a)
+	s.ErrorIs(err, ErrReplaceDepthExceeded)
+}
+
+func (s *ReplaceSuite) TestGrafts() {
+	fs := s.r.Storer.(*filesystem.Storage).Filesystem()
+	grafts := "# comment\n" +
+		s.head.String() + " " + s.side.String() + "\n" +
+		"malformed line\n" +
+		s.side.String() + "\n"
+	s.Require().NoError(util.WriteFile(fs, "info/grafts", []byte(grafts), 0644))
+
+	c, err := s.r.CommitObject(s.head)
+	s.Require().NoError(err)
+	s.Equal(s.head, c.Hash)
+	s.Equal("head\n", c.Message)
+	s.Equal([]plumbing.Hash{s.side}, c.ParentHashes)
+
+	// side is grafted as a root commit.
+	s.Equal([]string{"head", "side"}, s.log())
+
+	s.r.SetReplaceObjects(false)
+	s.Equal([]string{"head", "a", "root"}, s.log())
+}
+
+func (s *ReplaceSuite) TestCreateGraftReplacement() {
+	c := &object.Commit{
+		Author:       object.Signature{Name: "bar", Email: "bar@bar.bar", When: time.Unix(1257894000, 0)},
+		Committer:    *defaultSignature(),
+		PGPSignature: "-----BEGIN PGP SIGNATURE-----\n\nfoo\n-----END PGP SIGNATURE-----\n",
+		Message:      "signed\n\nbody\n",
+		TreeHash:     plumbing.NewHash("4b825dc642cb6eb9a060e54bf8d69288fbee4904"),
+		ParentHashes: []plumbing.Hash{s.head},
+	}
+
+	obj := s.r.Storer.NewEncodedObject()
+	s.Require().NoError(c.Encode(obj))
+	signed, err := s.r.Storer.SetEncodedObject(obj)
+	s.Require().NoError(err)
+
+	h, err := s.r.CreateGraftReplacement(signed, []plumbing.Hash{s.side, s.a})
+	s.Require().NoError(err)
+
+	ref, err := s.r.Reference(plumbing.ReferenceName("refs/replace/"+signed.String()), false)
+	s.Require().NoError(err)
+	s.Equal(h, ref.Hash())
+
+	s.r.SetReplaceObjects(false)
+	replacement, err := s.r.CommitObject(h)
+	s.Require().NoError(err)
+	s.Equal(c.TreeHash, replacement.TreeHash)
+	s.Equal(c.Author.String(), replacement.Author.String())
+	s.True(c.Author.When.Equal(replacement.Author.When))
+	s.Equal(c.Committer.String(), replacement.Committer.String())
+	s.Equal(c.Message, replacement.Message)
+	s.Equal([]plumbing.Hash{s.side, s.a}, replacement.ParentHashes)
+	s.Empty(replacement.PGPSignature)
+
+	s.r.SetReplaceObjects(true)
+	grafted, err := s.r.CommitObject(signed)
+	s.Require().NoError(err)
+	s.Equal(signed, grafted.Hash)
+	s.Equal([]plumbing.Hash{s.side, s.a}, grafted.ParentHashes)
+}
+
+func (s *ReplaceSuite) TestCreateGraftReplacementRoot() {
+	_, err := s.r.CreateGraftReplacement(s.a, nil)
+	s.Require().NoError(err)
+
+	s.Equal([]string{"head", "a"}, s.log())
+}
+
+func (s *ReplaceSuite) TestCreateGraftReplacementErrors() {
+	_, err := s.r.CreateGraftReplacement(s.a, []plumbing.Hash{s.root})
+	s.ErrorIs(err, ErrGraftUnchanged)
+
+	_, err = s.r.CreateGraftReplacement(s.a, []plumbing.Hash{plumbing.NewHash("4b825dc642cb6eb9a060e54bf8d69288fbee4904")})
+	s.ErrorIs(err, plumbing.ErrObjectNotFound)
+
+	_, err = s.r.CreateGraftReplacement(plumbing.NewHash("4b825dc642cb6eb9a060e54bf8d69288fbee4904"), nil)
+	s.ErrorIs(err, plumbing.ErrObjectNotFound)
+
+	_, err = s.r.CreateGraftReplacement(s.a, []plumbing.Hash{s.side})
+	s.Require().NoError(err)
+
+	_, err = s.r.CreateGraftReplacement(s.a, []plumbing.Hash{s.head})
+	s.ErrorIs(err, ErrReplaceRefExists)
+}
+
+func (s *ReplaceSuite) TestNoReplacements() {
+	sto := memory.NewStorage()
+	r, err := Init(sto)
+	s.Require().NoError(err)
+
+	objects, err := r.objectStorer()
+	s.Require().NoError(err)
+	s.Equal(sto, objects)
+}
+
+func (s *ReplaceSuite) TestReplaceObjectsCached() {
+	s.Equal([]string{"head", "a", "root"}, s.log())
+	objs, err := s.r.loadReplaceObjects()
+	s.Require().NoError(err)
+	s.Same(s.r.replaceObjs, objs)
+
+	again, err := s.r.loadReplaceObjects()
+	s.Require().NoError(err)
+	s.Same(objs, again)
+
+	// Adding a replace reference or grafts is noticed.
+	s.replace(s.a, s.commit("replaced a", []plumbing.Hash{s.side}))
+	s.Equal([]string{"head", "replaced a", "side", "root"}, s.log())
+
+	fs := s.r.Storer.(*filesystem.Storage).Filesystem()
+	s.Require().NoError(util.WriteFile(fs, "info/grafts", []byte(s.side.String()+"\n"), 0644))
+	s.Equal([]string{"head", "replaced a", "side"}, s.log())
+
+	// And so is packing them.
+	s.Require().NoError(s.r.Storer.(*filesystem.Storage).PackRefs())
+	s.Equal([]string{"head", "replaced a", "side"}, s.log())
+
+	s.Require().NoError(s.r.Storer.RemoveReference(plumbing.ReferenceName("refs/replace/" + s.a.String())))
+	s.Equal([]string{"head", "a", "root"}, s.log())
+}
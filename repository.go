@@ -11,6 +11,7 @@ import (
 	"path"
 	"path/filepath"
 	"strings"
+	"sync"
 	"time"
 
 	"dario.cat/mergo"
@@ -28,6 +29,7 @@ import (
 	"github.com/go-git/go-git/v6/plumbing/format/packfile"
 	"github.com/go-git/go-git/v6/plumbing/object"
 	"github.com/go-git/go-git/v6/plumbing/storer"
+	"github.com/go-git/go-git/v6/plumbing/transport"
 	"github.com/go-git/go-git/v6/storage"
 	"github.com/go-git/go-git/v6/storage/filesystem"
 	"github.com/go-git/go-git/v6/storage/filesystem/dotgit"
@@ -43,6 +45,9 @@ var (
 	ErrBranchExists = errors.New("branch already exists")
 	// ErrBranchNotFound an error stating the specified branch does not exist
 	ErrBranchNotFound = errors.New("branch not found")
+	// ErrInvalidUpstream is returned by SetBranchUpstream when the remote or
+	// the branch merge of the upstream is missing
+	ErrInvalidUpstream = errors.New("upstream requires a remote and a branch")
 	// ErrTagExists an error stating the specified tag already exists
 	ErrTagExists = errors.New("tag already exists")
 	// ErrTagNotFound an error stating the specified tag does not exist
@@ -62,9 +67,13 @@ var (
 	ErrUnableToResolveCommit       = errors.New("unable to resolve commit")
 	ErrPackedObjectsNotSupported   = errors.New("packed objects not supported")
 	ErrSHA256NotSupported          = errors.New("go-git was not compiled with SHA256 support")
+	ErrObjectFormatNotSupported    = errors.New("object format not supported by the storer")
 	ErrAlternatePathNotSupported   = errors.New("alternate path must use the file scheme")
 	ErrUnsupportedMergeStrategy    = errors.New("unsupported merge strategy")
 	ErrFastForwardMergeNotPossible = errors.New("not possible to fast-forward merge changes")
+	// ErrReferenceTransactionNotSupported is returned by ReferenceTransaction
+	// when the storer can't update references atomically.
+	ErrReferenceTransactionNotSupported = errors.New("reference transactions not supported by the storer")
 )
 
 // Repository represents a git repository
@@ -73,6 +82,15 @@ type Repository struct {
 
 	r  map[string]*Remote
 	wt billy.Filesystem
+
+	noReplaceObjects bool
+	// fetchPromised fetches the objects omitted from a partial clone from
+	// its promisor remote, it is nil if there is none.
+	fetchPromised func(hashes ...plumbing.Hash) error
+	// replaceObjs caches the replace references and grafts, see
+	// loadReplaceObjects.
+	replaceMu   sync.Mutex
+	replaceObjs *replaceObjects
 }
 
 type initOptions struct {
@@ -115,7 +133,11 @@ func WithObjectFormat(of formatcfg.ObjectFormat) InitOption {
 
 // Init creates an empty git repository, based on the given Storer and worktree.
 // The worktree Filesystem is optional, if nil a bare repository is created. If
-// the given storer is not empty ErrRepositoryAlreadyExists is returned
+// the given storer is not empty ErrRepositoryAlreadyExists is returned.
+//
+// When an object format other than SHA1 is requested, it's recorded in the
+// repository config and the Storer must implement storer.ObjectFormatSetter,
+// otherwise ErrObjectFormatNotSupported is returned.
 func Init(s storage.Storer, opts ...InitOption) (*Repository, error) {
 	options := newInitOptions()
 	for _, oFn := range opts {
@@ -142,6 +164,10 @@ func Init(s storage.Storer, opts ...InitOption) (*Repository, error) {
 		return nil, err
 	}
 
+	if err := initObjectFormat(s, options.objectFormat); err != nil {
+		return nil, err
+	}
+
 	h := plumbing.NewSymbolicReference(plumbing.HEAD, options.defaultBranch)
 	if err := s.SetReference(h); err != nil {
 		return nil, err
@@ -152,7 +178,42 @@ func Init(s storage.Storer, opts ...InitOption) (*Repository, error) {
 		return r, nil
 	}
 
-	return r, setWorktreeAndStoragePaths(r, options.workTree)
+	if err := setWorktreeAndStoragePaths(r, options.workTree); err != nil {
+		return nil, err
+	}
+
+	return r, initSymlinks(r, options.workTree)
+}
+
+// initSymlinks sets core.symlinks to false when the worktree doesn't support
+// symbolic links, as git does on init, so they are checked out as regular
+// files containing their target.
+func initSymlinks(r *Repository, worktree billy.Filesystem) error {
+	if supportsSymlinks(worktree) {
+		return nil
+	}
+
+	cfg, err := r.Config()
+	if err != nil {
+		return err
+	}
+
+	cfg.Core.Symlinks = "false"
+	return r.Storer.SetConfig(cfg)
+}
+
+// supportsSymlinks probes whether a symbolic link can be created in the given
+// filesystem, within a temporary directory removed afterwards. It's assumed to
+// be supported when the directory can't be created.
+func supportsSymlinks(fs billy.Filesystem) bool {
+	dir, err := util.TempDir(fs, ".", ".symlink-probe-")
+	if err != nil {
+		return true
+	}
+
+	defer func() { _ = util.RemoveAll(fs, dir) }()
+
+	return fs.Symlink("target", fs.Join(dir, "link")) == nil
 }
 
 func initStorer(s storer.Storer) error {
@@ -164,6 +225,41 @@ func initStorer(s storer.Storer) error {
 	return i.Init()
 }
 
+// initObjectFormat sets the object format of a new repository, recording it in
+// the config when it isn't the default one.
+func initObjectFormat(s storage.Storer, f formatcfg.ObjectFormat) error {
+	if f == formatcfg.SHA1 {
+		return nil
+	}
+
+	if err := setObjectFormat(s, f); err != nil {
+		return err
+	}
+
+	cfg, err := s.Config()
+	if err != nil {
+		return err
+	}
+
+	cfg.Core.RepositoryFormatVersion = formatcfg.Version_1
+	cfg.Extensions.ObjectFormat = f
+	return s.SetConfig(cfg)
+}
+
+// setObjectFormat makes the storer hash the objects using the given format.
+func setObjectFormat(s storer.Storer, f formatcfg.ObjectFormat) error {
+	if f == formatcfg.SHA1 {
+		return nil
+	}
+
+	setter, ok := s.(storer.ObjectFormatSetter)
+	if !ok {
+		return ErrObjectFormatNotSupported
+	}
+
+	return setter.SetObjectFormat(f)
+}
+
 func setWorktreeAndStoragePaths(r *Repository, worktree billy.Filesystem) error {
 	type fsBased interface {
 		Filesystem() billy.Filesystem
@@ -239,7 +335,21 @@ func Open(s storage.Storer, worktree billy.Filesystem) (*Repository, error) {
 		return nil, err
 	}
 
-	return newRepository(s, worktree), nil
+	cfg, err := s.Config()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := setObjectFormat(s, cfg.Extensions.ObjectFormat); err != nil {
+		return nil, err
+	}
+
+	r := newRepository(s, worktree)
+	if err := r.setupPromisorRemote(&FetchOptions{}); err != nil {
+		return nil, err
+	}
+
+	return r, nil
 }
 
 // Clone a repository into the given Storer and worktree Filesystem with the
@@ -285,11 +395,6 @@ func PlainInit(path string, isBare bool, options ...InitOption) (*Repository, er
 	var wt, dot billy.Filesystem
 	var initFn func(s *filesystem.Storage) (*Repository, error)
 
-	o := newInitOptions()
-	for _, oFn := range options {
-		oFn(&o)
-	}
-
 	if isBare {
 		dot = osfs.New(path, osfs.WithBoundOS())
 		initFn = func(s *filesystem.Storage) (*Repository, error) {
@@ -316,11 +421,6 @@ func PlainInit(path string, isBare bool, options ...InitOption) (*Repository, er
 		return nil, err
 	}
 
-	if o.objectFormat != formatcfg.SHA1 {
-		cfg.Core.RepositoryFormatVersion = formatcfg.Version_1
-		cfg.Extensions.ObjectFormat = o.objectFormat
-	}
-
 	err = r.Storer.SetConfig(cfg)
 	if err != nil {
 		return nil, err
@@ -680,17 +780,14 @@ func (r *Repository) CreateRemote(c *config.RemoteConfig) (*Remote, error) {
 
 	remote := NewRemote(r.Storer, c)
 
-	cfg, err := r.Config()
-	if err != nil {
-		return nil, err
-	}
-
-	if _, ok := cfg.Remotes[c.Name]; ok {
-		return nil, ErrRemoteExists
-	}
+	return remote, r.updateConfig(func(cfg *config.Config) error {
+		if _, ok := cfg.Remotes[c.Name]; ok {
+			return ErrRemoteExists
+		}
 
-	cfg.Remotes[c.Name] = c
-	return remote, r.Storer.SetConfig(cfg)
+		cfg.Remotes[c.Name] = c
+		return nil
+	})
 }
 
 // CreateRemoteAnonymous creates a new anonymous remote. c.Name must be "anonymous".
@@ -711,17 +808,132 @@ func (r *Repository) CreateRemoteAnonymous(c *config.RemoteConfig) (*Remote, err
 
 // DeleteRemote delete a remote from the repository and delete the config
 func (r *Repository) DeleteRemote(name string) error {
-	cfg, err := r.Config()
+	return r.updateConfig(func(cfg *config.Config) error {
+		if _, ok := cfg.Remotes[name]; !ok {
+			return ErrRemoteNotFound
+		}
+
+		delete(cfg.Remotes, name)
+		return nil
+	})
+}
+
+// RenameRemote renames a remote, as `git remote rename`: the fetch refspecs
+// of the remote and the branches tracking it are updated, and so are its
+// remote-tracking references.
+func (r *Repository) RenameRemote(oldName, newName string) error {
+	if err := plumbing.NewRemoteHEADReferenceName(newName).Validate(); err != nil {
+		return err
+	}
+
+	oldPrefix := plumbing.NewRemoteReferenceName(oldName, "").String()
+	newPrefix := plumbing.NewRemoteReferenceName(newName, "").String()
+	err := r.updateConfig(func(cfg *config.Config) error {
+		c, ok := cfg.Remotes[oldName]
+		if !ok {
+			return ErrRemoteNotFound
+		}
+
+		if _, ok := cfg.Remotes[newName]; ok {
+			return ErrRemoteExists
+		}
+
+		for i, spec := range c.Fetch {
+			src, dst, _ := strings.Cut(string(spec), ":")
+			if strings.HasPrefix(dst, oldPrefix) {
+				c.Fetch[i] = config.RefSpec(src + ":" + newPrefix + strings.TrimPrefix(dst, oldPrefix))
+			}
+		}
+
+		for _, b := range cfg.Branches {
+			if b.Remote == oldName {
+				b.Remote = newName
+			}
+
+			if b.PushRemote == oldName {
+				b.PushRemote = newName
+			}
+		}
+
+		c.Name = newName
+		delete(cfg.Remotes, oldName)
+		cfg.Remotes[newName] = c
+		return nil
+	})
 	if err != nil {
 		return err
 	}
 
-	if _, ok := cfg.Remotes[name]; !ok {
-		return ErrRemoteNotFound
+	return r.renameReferences(oldPrefix, newPrefix)
+}
+
+// renameReferences moves the references starting with oldPrefix under
+// newPrefix, the symbolic ones pointing to them being updated too.
+func (r *Repository) renameReferences(oldPrefix, newPrefix string) error {
+	iter, err := r.Storer.IterReferences()
+	if err != nil {
+		return err
 	}
 
-	delete(cfg.Remotes, name)
-	return r.Storer.SetConfig(cfg)
+	var refs []*plumbing.Reference
+	err = iter.ForEach(func(ref *plumbing.Reference) error {
+		if strings.HasPrefix(ref.Name().String(), oldPrefix) {
+			refs = append(refs, ref)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	rename := func(name plumbing.ReferenceName) plumbing.ReferenceName {
+		if !strings.HasPrefix(name.String(), oldPrefix) {
+			return name
+		}
+
+		return plumbing.ReferenceName(newPrefix + strings.TrimPrefix(name.String(), oldPrefix))
+	}
+
+	for _, ref := range refs {
+		renamed := plumbing.NewHashReference(rename(ref.Name()), ref.Hash())
+		if ref.Type() == plumbing.SymbolicReference {
+			renamed = plumbing.NewSymbolicReference(rename(ref.Name()), rename(ref.Target()))
+		}
+
+		if err := r.Storer.SetReference(renamed); err != nil {
+			return err
+		}
+
+		if err := r.Storer.RemoveReference(ref.Name()); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// SetRemoteURL sets the URL of a remote, as `git remote set-url`. Only the
+// first URL of the remote is replaced, the other ones are kept.
+func (r *Repository) SetRemoteURL(name, remoteURL string) error {
+	if _, err := transport.NewEndpoint(remoteURL); err != nil {
+		return err
+	}
+
+	return r.updateConfig(func(cfg *config.Config) error {
+		c, ok := cfg.Remotes[name]
+		if !ok {
+			return ErrRemoteNotFound
+		}
+
+		if len(c.URLs) == 0 {
+			c.URLs = []string{remoteURL}
+		} else {
+			c.URLs[0] = remoteURL
+		}
+
+		return nil
+	})
 }
 
 // Branch return a Branch if exists
@@ -745,31 +957,73 @@ func (r *Repository) CreateBranch(c *config.Branch) error {
 		return err
 	}
 
-	cfg, err := r.Config()
-	if err != nil {
+	return r.updateConfig(func(cfg *config.Config) error {
+		if _, ok := cfg.Branches[c.Name]; ok {
+			return ErrBranchExists
+		}
+
+		cfg.Branches[c.Name] = c
+		return nil
+	})
+}
+
+// SetBranchUpstream sets the upstream of a branch, as
+// `git branch --set-upstream-to`: the branch merge of the given remote, or
+// of the repository itself if remote is ".". The config of the branch is
+// created if needed.
+func (r *Repository) SetBranchUpstream(branch, remote string, merge plumbing.ReferenceName) error {
+	b := &config.Branch{Name: branch, Remote: remote, Merge: merge}
+	if err := b.Validate(); err != nil {
 		return err
 	}
 
-	if _, ok := cfg.Branches[c.Name]; ok {
-		return ErrBranchExists
+	if remote == "" || merge == "" {
+		return ErrInvalidUpstream
 	}
 
-	cfg.Branches[c.Name] = c
-	return r.Storer.SetConfig(cfg)
+	return r.updateConfig(func(cfg *config.Config) error {
+		if _, ok := cfg.Remotes[remote]; !ok && remote != "." {
+			return ErrRemoteNotFound
+		}
+
+		if existing, ok := cfg.Branches[branch]; ok {
+			existing.Remote, existing.Merge = remote, merge
+			return nil
+		}
+
+		cfg.Branches[branch] = b
+		return nil
+	})
 }
 
 // DeleteBranch delete a Branch from the repository and delete the config
 func (r *Repository) DeleteBranch(name string) error {
+	return r.updateConfig(func(cfg *config.Config) error {
+		if _, ok := cfg.Branches[name]; !ok {
+			return ErrBranchNotFound
+		}
+
+		delete(cfg.Branches, name)
+		return nil
+	})
+}
+
+// updateConfig reads the config of the repository, gives it to update and
+// writes it back, atomically if the storage is a config.ConfigUpdater.
+func (r *Repository) updateConfig(update func(*config.Config) error) error {
+	if u, ok := r.Storer.(config.ConfigUpdater); ok {
+		return u.UpdateConfig(update)
+	}
+
 	cfg, err := r.Config()
 	if err != nil {
 		return err
 	}
 
-	if _, ok := cfg.Branches[name]; !ok {
-		return ErrBranchNotFound
+	if err := update(cfg); err != nil {
+		return err
 	}
 
-	delete(cfg.Branches, name)
 	return r.Storer.SetConfig(cfg)
 }
 
@@ -940,10 +1194,27 @@ func (r *Repository) clone(ctx context.Context, o *CloneOptions) error {
 		Mirror: o.Mirror,
 	}
 
+	if o.Filter != "" {
+		c.Promisor = true
+		c.PartialCloneFilter = string(o.Filter)
+	}
+
 	if _, err := r.CreateRemote(c); err != nil {
 		return err
 	}
 
+	if o.Filter != "" {
+		err := r.setupPromisorRemote(&FetchOptions{
+			Auth:            o.Auth,
+			InsecureSkipTLS: o.InsecureSkipTLS,
+			CABundle:        o.CABundle,
+			ProxyOptions:    o.ProxyOptions,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
 	// When the repository to clone is on the local machine,
 	// instead of using hard links, automatically setup .git/objects/info/alternates
 	// to share the objects with the source repository
@@ -971,6 +1242,8 @@ func (r *Repository) clone(ctx context.Context, o *CloneOptions) error {
 	ref, err := r.fetchAndUpdateReferences(ctx, &FetchOptions{
 		RefSpecs:        c.Fetch,
 		Depth:           o.Depth,
+		ShallowSince:    o.ShallowSince,
+		ShallowExclude:  o.ShallowExclude,
 		Auth:            o.Auth,
 		Progress:        o.Progress,
 		Tags:            o.Tags,
@@ -995,10 +1268,27 @@ func (r *Repository) clone(ctx context.Context, o *CloneOptions) error {
 			return err
 		}
 
-		if err := w.Reset(&ResetOptions{
-			Mode:   MergeReset,
-			Commit: head.Hash(),
-		}); err != nil {
+		if o.Filter != "" {
+			commit, err := r.CommitObject(head.Hash())
+			if err != nil {
+				return err
+			}
+
+			tree, err := commit.Tree()
+			if err != nil {
+				return err
+			}
+
+			if err := r.fetchPromisedBlobs(tree); err != nil {
+				return err
+			}
+		}
+
+		if err := w.reset(&ResetOptions{
+			Mode:     MergeReset,
+			Commit:   head.Hash(),
+			Progress: o.Progress,
+		}, ""); err != nil {
 			return err
 		}
 
@@ -1294,9 +1584,16 @@ func (r *Repository) Log(o *LogOptions) (object.CommitIter, error) {
 		it  object.CommitIter
 		err error
 	)
-	if o.All {
+	switch {
+	case o.All:
 		it, err = r.logAll(fn)
-	} else {
+	case o.Order == LogOrderCommitterTime && o.CommitterTimeSeenWindow > 0:
+		it, err = r.log(o.From, func(c *object.Commit) object.CommitIter {
+			return object.NewCommitIterCTimeBounded(c, o.CommitterTimeSeenWindow)
+		})
+	case o.Order == LogOrderCommitterTime:
+		it, err = r.logCTime(o.From)
+	default:
 		it, err = r.log(o.From, fn)
 	}
 
@@ -1317,6 +1614,10 @@ func (r *Repository) Log(o *LogOptions) (object.CommitIter, error) {
 		it = r.logWithLimit(it, limitOptions)
 	}
 
+	if o.MailMap != nil {
+		it = object.NewCommitMailMapIterFromIter(o.MailMap, it)
+	}
+
 	return it, nil
 }
 
@@ -1339,7 +1640,12 @@ func (r *Repository) log(from plumbing.Hash, commitIterFunc func(*object.Commit)
 }
 
 func (r *Repository) logAll(commitIterFunc func(*object.Commit) object.CommitIter) (object.CommitIter, error) {
-	return object.NewCommitAllIter(r.Storer, commitIterFunc)
+	s, err := r.objectStorer()
+	if err != nil {
+		return nil, err
+	}
+
+	return object.NewCommitAllIter(s, commitIterFunc)
 }
 
 func (*Repository) logWithFile(fileName string, commitIter object.CommitIter, checkParent bool) object.CommitIter {
@@ -1460,7 +1766,12 @@ func (r *Repository) Notes() (storer.ReferenceIter, error) {
 // TreeObject return a Tree with the given hash. If not found
 // plumbing.ErrObjectNotFound is returned
 func (r *Repository) TreeObject(h plumbing.Hash) (*object.Tree, error) {
-	return object.GetTree(r.Storer, h)
+	s, err := r.objectStorer()
+	if err != nil {
+		return nil, err
+	}
+
+	return object.GetTree(s, h)
 }
 
 // TreeObjects returns an unsorted TreeIter with all the trees in the repository
@@ -1476,7 +1787,12 @@ func (r *Repository) TreeObjects() (*object.TreeIter, error) {
 // CommitObject return a Commit with the given hash. If not found
 // plumbing.ErrObjectNotFound is returned.
 func (r *Repository) CommitObject(h plumbing.Hash) (*object.Commit, error) {
-	return object.GetCommit(r.Storer, h)
+	s, err := r.objectStorer()
+	if err != nil {
+		return nil, err
+	}
+
+	return object.GetCommit(s, h)
 }
 
 // CommitObjects returns an unsorted CommitIter with all the commits in the repository.
@@ -1492,7 +1808,12 @@ func (r *Repository) CommitObjects() (object.CommitIter, error) {
 // BlobObject returns a Blob with the given hash. If not found
 // plumbing.ErrObjectNotFound is returned.
 func (r *Repository) BlobObject(h plumbing.Hash) (*object.Blob, error) {
-	return object.GetBlob(r.Storer, h)
+	s, err := r.objectStorer()
+	if err != nil {
+		return nil, err
+	}
+
+	return object.GetBlob(s, h)
 }
 
 // BlobObjects returns an unsorted BlobIter with all the blobs in the repository.
@@ -1509,7 +1830,12 @@ func (r *Repository) BlobObjects() (*object.BlobIter, error) {
 // plumbing.ErrObjectNotFound is returned. This method only returns
 // annotated Tags, no lightweight Tags.
 func (r *Repository) TagObject(h plumbing.Hash) (*object.Tag, error) {
-	return object.GetTag(r.Storer, h)
+	s, err := r.objectStorer()
+	if err != nil {
+		return nil, err
+	}
+
+	return object.GetTag(s, h)
 }
 
 // TagObjects returns a unsorted TagIter that can step through all of the annotated
@@ -1526,12 +1852,17 @@ func (r *Repository) TagObjects() (*object.TagIter, error) {
 // Object returns an Object with the given hash. If not found
 // plumbing.ErrObjectNotFound is returned.
 func (r *Repository) Object(t plumbing.ObjectType, h plumbing.Hash) (object.Object, error) {
-	obj, err := r.Storer.EncodedObject(t, h)
+	s, err := r.objectStorer()
 	if err != nil {
 		return nil, err
 	}
 
-	return object.DecodeObject(r.Storer, obj)
+	obj, err := s.EncodedObject(t, h)
+	if err != nil {
+		return nil, err
+	}
+
+	return object.DecodeObject(s, obj)
 }
 
 // Objects returns an unsorted ObjectIter with all the objects in the repository.
@@ -1549,6 +1880,21 @@ func (r *Repository) Head() (*plumbing.Reference, error) {
 	return storer.ResolveReference(r.Storer, plumbing.HEAD)
 }
 
+// setOrigHead records the commit HEAD points to in ORIG_HEAD, as git does
+// before an operation moving HEAD, such as a reset or a merge, so it can be
+// recovered. Nothing is recorded when HEAD is unborn.
+func (r *Repository) setOrigHead() error {
+	head, err := r.Head()
+	if err == plumbing.ErrReferenceNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	return r.Storer.SetReference(plumbing.NewHashReference(plumbing.OrigHead, head.Hash()))
+}
+
 // Reference returns the reference for a given reference name. If resolved is
 // true, any symbolic reference will be resolved.
 func (r *Repository) Reference(name plumbing.ReferenceName, resolved bool) (
@@ -1566,6 +1912,88 @@ func (r *Repository) References() (storer.ReferenceIter, error) {
 	return r.Storer.IterReferences()
 }
 
+// ResolvedReferences returns an unsorted ReferenceIter for all references,
+// yielding the symbolic ones, such as HEAD, as hash references to the hash of
+// their target, resolved lazily while iterating. The dangling symbolic
+// references are skipped, or reported as storer.ErrDanglingReference if
+// includeDangling is true.
+func (r *Repository) ResolvedReferences(includeDangling bool) (storer.ReferenceIter, error) {
+	iter, err := r.Storer.IterReferences()
+	if err != nil {
+		return nil, err
+	}
+
+	return storer.NewResolvedReferenceIter(r.Storer, iter, includeDangling), nil
+}
+
+// ReferenceTransaction starts a transaction updating several references
+// atomically: the updates queued are all applied when it's committed, or none
+// of them if the expected value of any reference doesn't match. See
+// storer.ReferenceTransaction. Once committed, an entry with the given message
+// is appended to the reflog of each reference updated, as
+// `git update-ref --stdin -m <msg>` does.
+func (r *Repository) ReferenceTransaction(msg string) (storer.ReferenceTransaction, error) {
+	ts, ok := r.Storer.(storer.ReferenceTransactionStorer)
+	if !ok {
+		return nil, ErrReferenceTransactionNotSupported
+	}
+
+	return &referenceTransaction{
+		ReferenceTransaction: ts.BeginReferenceTransaction(),
+		r:                    r,
+		msg:                  msg,
+	}, nil
+}
+
+// referenceTransaction logs the updates of a storer.ReferenceTransaction to
+// the reflog of the references once it's committed.
+type referenceTransaction struct {
+	storer.ReferenceTransaction
+	r       *Repository
+	msg     string
+	updates []*storer.ReferenceUpdate
+}
+
+func (tx *referenceTransaction) SetReference(ref, old *plumbing.Reference) error {
+	if err := tx.ReferenceTransaction.SetReference(ref, old); err != nil {
+		return err
+	}
+
+	tx.updates = append(tx.updates, &storer.ReferenceUpdate{Name: ref.Name(), New: ref, Old: old})
+	return nil
+}
+
+func (tx *referenceTransaction) Commit() error {
+	// The old value of the references not checked is read before the
+	// commit, the others are known once it succeeds.
+	olds := make([]plumbing.Hash, len(tx.updates))
+	for i, u := range tx.updates {
+		if u.Old != nil {
+			olds[i] = u.Old.Hash()
+			continue
+		}
+
+		ref, err := tx.r.Storer.Reference(u.Name)
+		if err == nil {
+			olds[i] = ref.Hash()
+		} else if err != plumbing.ErrReferenceNotFound {
+			return err
+		}
+	}
+
+	if err := tx.ReferenceTransaction.Commit(); err != nil {
+		return err
+	}
+
+	for i, u := range tx.updates {
+		if err := tx.r.logRefUpdate(u.Name, olds[i], u.New.Hash(), nil, tx.msg); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 // Worktree returns a worktree based on the given fs, if nil the default
 // worktree will be used.
 func (r *Repository) Worktree() (*Worktree, error) {
@@ -1597,7 +2025,9 @@ func expand_ref(s storer.ReferenceStorer, ref plumbing.ReferenceName) (*plumbing
 // resolve to a commit hash, not a tree or annotated tag.
 //
 // Implemented resolvers : HEAD, branch, tag, heads/branch, refs/heads/branch,
-// refs/tags/tag, refs/remotes/origin/branch, refs/remotes/origin/HEAD, tilde and caret (HEAD~1, master~^, tag~2, ref/heads/master~1, ...), selection by text (HEAD^{/fix nasty bug}), hash (prefix and full)
+// refs/tags/tag, refs/remotes/origin/branch, refs/remotes/origin/HEAD, tilde and caret (HEAD~1, master~^, tag~2, ref/heads/master~1, ...), selection by text (HEAD^{/fix nasty bug}), hash (prefix and full),
+// reflog entries (HEAD@{1}, master@{2}, @{1}) and dates (master@{2006-01-02T15:04:05Z}) when the storage keeps reference logs,
+// upstream and push branches (master@{upstream}, @{u}, master@{push}) as configured for the branch
 func (r *Repository) ResolveRevision(in plumbing.Revision) (*plumbing.Hash, error) {
 	rev := in.String()
 	if rev == "" {
@@ -1611,11 +2041,13 @@ func (r *Repository) ResolveRevision(in plumbing.Revision) (*plumbing.Hash, erro
 	}
 
 	var commit *object.Commit
+	var refRev string
 
 	for _, item := range items {
 		switch item := item.(type) {
 		case revision.Ref:
 			revisionRef := item
+			refRev = string(revisionRef)
 
 			var tryHashes []plumbing.Hash
 
@@ -1623,6 +2055,15 @@ func (r *Repository) ResolveRevision(in plumbing.Revision) (*plumbing.Hash, erro
 
 			ref, err := expand_ref(r.Storer, plumbing.ReferenceName(revisionRef))
 			if err == nil {
+				// Prefer the target of an annotated tag recorded by the
+				// storage, so the tag object doesn't need to be read.
+				if ps, ok := r.Storer.(storer.PeeledReferenceStorer); ok {
+					peeled, ok, err := ps.PeeledReference(ref.Name())
+					if err == nil && ok && peeled != ref.Hash() {
+						tryHashes = append(tryHashes, peeled)
+					}
+				}
+
 				tryHashes = append(tryHashes, ref.Hash())
 			}
 
@@ -1724,6 +2165,31 @@ func (r *Repository) ResolveRevision(in plumbing.Revision) (*plumbing.Hash, erro
 			}
 
 			commit = c
+		case revision.AtReflog, revision.AtDate:
+			h, err := r.resolveReflogRevision(refRev, item)
+			if err != nil {
+				return &plumbing.ZeroHash, err
+			}
+
+			commit, err = r.CommitObject(h)
+			if err != nil {
+				return &plumbing.ZeroHash, err
+			}
+		case revision.AtUpstream, revision.AtPush:
+			name, err := r.resolveUpstreamRevision(refRev, item)
+			if err != nil {
+				return &plumbing.ZeroHash, err
+			}
+
+			ref, err := storer.ResolveReference(r.Storer, name)
+			if err != nil {
+				return &plumbing.ZeroHash, err
+			}
+
+			commit, err = r.CommitObject(ref.Hash())
+			if err != nil {
+				return &plumbing.ZeroHash, err
+			}
 		}
 	}
 
@@ -1778,33 +2244,67 @@ type RepackConfig struct {
 	// UseRefDeltas configures whether packfile encoder will use reference deltas.
 	// By default OFSDeltaObject is used.
 	UseRefDeltas bool
+	// Window overrides the size of the sliding window used to find delta
+	// candidates, a larger window trades CPU time for a smaller pack. When
+	// zero, the pack.window setting of the repository is used.
+	Window uint
+	// Depth overrides the maximum length of the delta chains. When zero,
+	// the pack.depth setting of the repository is used.
+	Depth uint
 	// OnlyDeletePacksOlderThan if set to non-zero value
 	// selects only objects older than the time provided.
 	OnlyDeletePacksOlderThan time.Time
-}
-
+	// LooseObjectsOnly packs the loose objects, reachable or not, into a new
+	// pack and deletes them, keeping the existing packs, as the loose-objects
+	// task of git maintenance does. This is cheaper than repacking every
+	// reachable object, since the history isn't walked.
+	LooseObjectsOnly bool
+	// ConsolidatePacks also packs the objects of the existing packs smaller
+	// than SmallPackSize into the new pack, and deletes them. It only applies
+	// with LooseObjectsOnly.
+	ConsolidatePacks bool
+	// SmallPackSize is the size in bytes under which a pack is consolidated.
+	// When zero, every pack is.
+	SmallPackSize int64
+}
+
+// RepackObjects packs the objects reachable from the references into a new
+// pack, deleting the existing packs and the packed loose objects, or only
+// packs the loose objects with LooseObjectsOnly. The new pack is written
+// before anything is deleted, so the objects can be read during the whole
+// repack. The packs with a .keep file, see KeepPack, are left untouched and
+// their objects aren't copied into the new pack.
 func (r *Repository) RepackObjects(cfg *RepackConfig) (err error) {
 	pos, ok := r.Storer.(storer.PackedObjectStorer)
 	if !ok {
 		return ErrPackedObjectsNotSupported
 	}
 
+	if cfg.LooseObjectsOnly {
+		return r.repackLooseObjects(cfg)
+	}
+
 	// Get the existing object packs.
 	hs, err := pos.ObjectPacks()
 	if err != nil {
 		return err
 	}
 
+	kept, err := r.keptObjectPacks(hs)
+	if err != nil {
+		return err
+	}
+
 	// Create a new pack.
-	nh, err := r.createNewObjectPack(cfg)
+	nh, err := r.createNewObjectPack(cfg, kept)
 	if err != nil {
 		return err
 	}
 
 	// Delete old packs.
 	for _, h := range hs {
-		// Skip if new hash is the same as an old one.
-		if h == nh {
+		// Skip if new hash is the same as an old one, or the pack is kept.
+		if h == nh || kept[h] {
 			continue
 		}
 		err = pos.DeleteOldObjectPackAndIndex(h, cfg.OnlyDeletePacksOlderThan)
@@ -1813,6 +2313,11 @@ func (r *Repository) RepackObjects(cfg *RepackConfig) (err error) {
 		}
 	}
 
+	// Forget the indexes of the deleted packs.
+	if ri, ok := r.Storer.(interface{ Reindex() }); ok {
+		ri.Reindex()
+	}
+
 	return nil
 }
 
@@ -1848,37 +2353,37 @@ func (r *Repository) Merge(ref plumbing.Reference, opts MergeOptions) error {
 		return ErrFastForwardMergeNotPossible
 	}
 
-	return r.Storer.SetReference(plumbing.NewHashReference(head.Name(), ref.Hash()))
+	if err := r.Storer.SetReference(plumbing.NewHashReference(head.Name(), ref.Hash())); err != nil {
+		return err
+	}
+
+	msg := fmt.Sprintf("merge %s: Fast-forward", ref.Name().Short())
+	return r.logRefUpdate(head.Name(), head.Hash(), ref.Hash(), nil, msg)
 }
 
 // createNewObjectPack is a helper for RepackObjects taking care
-// of creating a new pack. It is used so the PackfileWriter
-// deferred close has the right scope.
-func (r *Repository) createNewObjectPack(cfg *RepackConfig) (h plumbing.Hash, err error) {
+// of creating a new pack with the objects reachable from the references,
+// but the ones of the kept packs, and deleting the packed loose objects.
+func (r *Repository) createNewObjectPack(cfg *RepackConfig, kept map[plumbing.Hash]bool) (h plumbing.Hash, err error) {
 	ow := newObjectWalker(r.Storer)
 	err = ow.walkAllRefs()
 	if err != nil {
 		return h, err
 	}
-	objs := make([]plumbing.Hash, 0, len(ow.seen))
-	for h := range ow.seen {
-		objs = append(objs, h)
-	}
-	pfw, ok := r.Storer.(storer.PackfileWriter)
-	if !ok {
-		return h, fmt.Errorf("Repository storer is not a storer.PackfileWriter")
-	}
-	wc, err := pfw.PackfileWriter()
+
+	keptObjs, err := r.keptObjects(kept)
 	if err != nil {
 		return h, err
 	}
-	defer ioutil.CheckClose(wc, &err)
-	scfg, err := r.Config()
-	if err != nil {
-		return h, err
+
+	objs := make([]plumbing.Hash, 0, len(ow.seen))
+	for h := range ow.seen {
+		if !keptObjs[h] {
+			objs = append(objs, h)
+		}
 	}
-	enc := packfile.NewEncoder(wc, r.Storer, cfg.UseRefDeltas)
-	h, err = enc.Encode(objs, scfg.Pack.Window)
+
+	h, err = r.writeObjectPack(cfg, objs)
 	if err != nil {
 		return h, err
 	}
@@ -1902,6 +2407,40 @@ func (r *Repository) createNewObjectPack(cfg *RepackConfig) (h plumbing.Hash, er
 	return h, err
 }
 
+// writeObjectPack writes the given objects into a new pack. It is used so the
+// PackfileWriter deferred close has the right scope: the pack can be read
+// once it returns.
+func (r *Repository) writeObjectPack(cfg *RepackConfig, objs []plumbing.Hash) (h plumbing.Hash, err error) {
+	pfw, ok := r.Storer.(storer.PackfileWriter)
+	if !ok {
+		return h, fmt.Errorf("Repository storer is not a storer.PackfileWriter")
+	}
+	scfg, err := r.Config()
+	if err != nil {
+		return h, err
+	}
+	window, depth := scfg.Pack.Window, scfg.Pack.Depth
+	if cfg.Window > 0 {
+		window = cfg.Window
+	}
+
+	if cfg.Depth > 0 {
+		depth = cfg.Depth
+	}
+
+	wc, err := pfw.PackfileWriter()
+	if err != nil {
+		return h, err
+	}
+	defer ioutil.CheckClose(wc, &err)
+
+	enc := packfile.NewEncoder(wc, r.Storer, cfg.UseRefDeltas,
+		packfile.WithMaxDeltaDepth(depth),
+		packfile.WithCompressionLevel(scfg.Pack.Compression),
+	)
+	return enc.Encode(objs, window)
+}
+
 func expandPartialHash(st storer.EncodedObjectStorer, prefix []byte) (hashes []plumbing.Hash) {
 	// The fast version is implemented by storage/filesystem.ObjectStorage.
 	type fastIter interface {
@@ -0,0 +1,152 @@
+package git
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/go-git/go-git/v6/plumbing/object"
+)
+
+var (
+	// ErrRevertInProgress is returned when a revert is attempted while a
+	// previous one, recorded in REVERT_HEAD, was not yet committed.
+	ErrRevertInProgress = errors.New("a revert is already in progress")
+	// ErrRevertMergeNoMainline is returned when reverting a merge commit
+	// without specifying the parent to revert to.
+	ErrRevertMergeNoMainline = errors.New("commit is a merge but no mainline was given")
+	// ErrRevertMainlineNotMerge is returned when a mainline is given to revert
+	// a commit which is not a merge.
+	ErrRevertMainlineNotMerge = errors.New("mainline was specified but commit is not a merge")
+	// ErrRevertInvalidMainline is returned when the mainline is not the number
+	// of a parent of the reverted commit.
+	ErrRevertInvalidMainline = errors.New("commit does not have the given mainline parent")
+)
+
+// RevertResult holds the outcome of a revert.
+type RevertResult struct {
+	// Commit is the commit created, it is the zero hash when the revert
+	// stopped because of conflicts, or NoCommit was given.
+	Commit plumbing.Hash
+	// Conflicts lists the paths that could not be merged automatically.
+	Conflicts []MergeConflict
+}
+
+// HasConflicts returns true if the revert resulted in any conflict.
+func (r *RevertResult) HasConflicts() bool {
+	return len(r.Conflicts) > 0
+}
+
+// Revert undoes the changes introduced by the given commit on top of the
+// current HEAD, creating a new commit with a message like the one of
+// `git revert`.
+//
+// The inverse of the changes between the commit and its parent are applied
+// with a three-way merge, the same way CherryPick applies them. A merge
+// commit can only be reverted by giving the Mainline parent the changes are
+// computed against. When they conflict with HEAD, the conflicting paths are
+// recorded in the index, with conflict markers written into the worktree, and
+// REVERT_HEAD is left pointing to the commit. The revert can then be
+// concluded by calling Commit once the conflicts are resolved, or aborted by
+// resetting the worktree.
+//
+// ErrWorktreeNotClean is returned if the worktree has uncommitted changes,
+// and ErrEmptyCommit if the changes are already undone in HEAD, unless
+// AllowEmpty is given.
+func (w *Worktree) Revert(commit plumbing.Hash, opts *RevertOptions) (*RevertResult, error) {
+	if opts == nil {
+		opts = &RevertOptions{}
+	}
+
+	if err := opts.Validate(w.r); err != nil {
+		return nil, err
+	}
+
+	if err := w.r.checkNoPickInProgress(); err != nil {
+		return nil, err
+	}
+
+	reverted, err := w.r.CommitObject(commit)
+	if err != nil {
+		return nil, err
+	}
+
+	parent, err := revertParent(reverted, opts.Mainline)
+	if err != nil {
+		return nil, err
+	}
+
+	status, err := w.cleanStatus()
+	if err != nil {
+		return nil, err
+	}
+
+	subject := commitSubject(reverted.Message)
+	p := &pick{label: fmt.Sprintf("parent of %s (%s)", reverted.Hash.String()[:7], subject)}
+	if p.base, err = reverted.Tree(); err != nil {
+		return nil, err
+	}
+
+	if parent != nil {
+		if p.theirs, err = parent.Tree(); err != nil {
+			return nil, err
+		}
+	}
+
+	ours, res, tree, err := w.applyPick(p, status, opts.NoCommit, opts.AllowEmpty)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(res.conflicts) > 0 {
+		err := w.r.Storer.SetReference(plumbing.NewHashReference(plumbing.RevertHead, reverted.Hash))
+		if err != nil {
+			return nil, err
+		}
+
+		return &RevertResult{Conflicts: res.conflicts}, nil
+	}
+
+	if opts.NoCommit {
+		return &RevertResult{}, nil
+	}
+
+	msg := revertMessage(reverted, parent, subject)
+	h, err := w.commitPick(opts.Author, msg, ours.Hash, tree.Hash, opts.Committer, "revert: ")
+	if err != nil {
+		return nil, err
+	}
+
+	return &RevertResult{Commit: h}, nil
+}
+
+// revertParent returns the parent of the commit the changes to revert are
+// computed against, the mainline one for a merge, or nil for a root commit.
+func revertParent(c *object.Commit, mainline int) (*object.Commit, error) {
+	n := c.NumParents()
+	switch {
+	case n > 1 && mainline == 0:
+		return nil, fmt.Errorf("%w: %s", ErrRevertMergeNoMainline, c.Hash)
+	case n <= 1 && mainline != 0:
+		return nil, fmt.Errorf("%w: %s", ErrRevertMainlineNotMerge, c.Hash)
+	case mainline < 0 || mainline > n:
+		return nil, fmt.Errorf("%w: %d", ErrRevertInvalidMainline, mainline)
+	case n == 0:
+		return nil, nil
+	case mainline == 0:
+		mainline = 1
+	}
+
+	return c.Parent(mainline - 1)
+}
+
+// revertMessage returns the message of the commit reverting c to the given
+// parent, as generated by `git revert`.
+func revertMessage(c, parent *object.Commit, subject string) string {
+	msg := fmt.Sprintf("Revert \"%s\"\n\nThis reverts commit %s", subject, c.Hash)
+	if c.NumParents() > 1 {
+		msg += fmt.Sprintf(", reversing\nchanges made to %s", parent.Hash)
+	}
+
+	return msg + ".\n"
+}
@@ -0,0 +1,176 @@
+package git
+
+import (
+	"github.com/go-git/go-billy/v5/util"
+	"github.com/go-git/go-git/v6/plumbing"
+)
+
+func (s *WorktreeSuite) TestRevert() {
+	r, w, fs, _ := s.setupMergeBranches(
+		map[string][]byte{"foo": []byte("a\nb\nc\nd\ne\n")},
+		map[string][]byte{"foo": []byte("A\nb\nc\nd\ne\n"), "bar": []byte("bar\n")},
+		nil,
+	)
+
+	reverted, err := r.Head()
+	s.NoError(err)
+
+	s.NoError(util.WriteFile(fs, "foo", []byte("A\nb\nc\nd\nE\n"), 0644))
+	_, err = w.Add("foo")
+	s.NoError(err)
+	head, err := w.Commit("change e", &CommitOptions{Author: defaultSignature()})
+	s.NoError(err)
+
+	res, err := w.Revert(reverted.Hash(), &RevertOptions{Author: defaultSignature()})
+	s.NoError(err)
+	s.False(res.HasConflicts())
+	s.False(res.Commit.IsZero())
+
+	content, err := util.ReadFile(fs, "foo")
+	s.NoError(err)
+	s.Equal("a\nb\nc\nd\nE\n", string(content))
+
+	_, err = fs.Stat("bar")
+	s.Error(err)
+
+	status, err := w.Status()
+	s.NoError(err)
+	s.True(status.IsClean())
+
+	ref, err := r.Head()
+	s.NoError(err)
+	s.Equal(plumbing.Master, ref.Name())
+	s.Equal(res.Commit, ref.Hash())
+
+	commit, err := r.CommitObject(res.Commit)
+	s.NoError(err)
+	s.Equal([]plumbing.Hash{head}, commit.ParentHashes)
+	s.Equal(defaultSignature().Name, commit.Author.Name)
+	s.Equal("Revert \"commit\"\n\nThis reverts commit "+reverted.Hash().String()+".\n", commit.Message)
+
+	_, err = r.Reference(plumbing.RevertHead, false)
+	s.ErrorIs(err, plumbing.ErrReferenceNotFound)
+}
+
+func (s *WorktreeSuite) TestRevertConflict() {
+	r, w, fs, _ := s.setupMergeBranches(
+		map[string][]byte{"foo": []byte("a\nb\nc\n")},
+		map[string][]byte{"foo": []byte("a\nX\nc\n")},
+		nil,
+	)
+
+	reverted, err := r.Head()
+	s.NoError(err)
+
+	s.NoError(util.WriteFile(fs, "foo", []byte("a\nY\nc\n"), 0644))
+	_, err = w.Add("foo")
+	s.NoError(err)
+	head, err := w.Commit("change again", &CommitOptions{Author: defaultSignature()})
+	s.NoError(err)
+
+	res, err := w.Revert(reverted.Hash(), &RevertOptions{Author: defaultSignature()})
+	s.NoError(err)
+	s.True(res.Commit.IsZero())
+	s.Require().Len(res.Conflicts, 1)
+	s.Equal("foo", res.Conflicts[0].Path)
+
+	content, err := util.ReadFile(fs, "foo")
+	s.NoError(err)
+	s.Equal("a\n<<<<<<< HEAD\nY\n=======\nb\n>>>>>>> parent of "+reverted.Hash().String()[:7]+" (commit)\nc\n", string(content))
+
+	ref, err := r.Reference(plumbing.RevertHead, false)
+	s.NoError(err)
+	s.Equal(reverted.Hash(), ref.Hash())
+
+	_, err = w.Revert(reverted.Hash(), &RevertOptions{Author: defaultSignature()})
+	s.ErrorIs(err, ErrRevertInProgress)
+
+	_, err = w.CherryPick(reverted.Hash(), &CherryPickOptions{Committer: defaultSignature()})
+	s.ErrorIs(err, ErrRevertInProgress)
+
+	s.NoError(util.WriteFile(fs, "foo", []byte("a\nb\nc\n"), 0644))
+	_, err = w.Add("foo")
+	s.NoError(err)
+
+	h, err := w.Commit("revert", &CommitOptions{Author: defaultSignature()})
+	s.NoError(err)
+
+	commit, err := r.CommitObject(h)
+	s.NoError(err)
+	s.Equal([]plumbing.Hash{head}, commit.ParentHashes)
+
+	_, err = r.Reference(plumbing.RevertHead, false)
+	s.ErrorIs(err, plumbing.ErrReferenceNotFound)
+}
+
+func (s *WorktreeSuite) TestRevertNoCommit() {
+	r, w, fs, _ := s.setupMergeBranches(
+		map[string][]byte{"foo": []byte("foo\n")},
+		map[string][]byte{"bar": []byte("bar\n")},
+		nil,
+	)
+
+	head, err := r.Head()
+	s.NoError(err)
+
+	res, err := w.Revert(head.Hash(), &RevertOptions{NoCommit: true})
+	s.NoError(err)
+	s.True(res.Commit.IsZero())
+	s.False(res.HasConflicts())
+
+	ref, err := r.Head()
+	s.NoError(err)
+	s.Equal(head.Hash(), ref.Hash())
+
+	_, err = fs.Stat("bar")
+	s.Error(err)
+
+	status, err := w.Status()
+	s.NoError(err)
+	s.Equal(Deleted, status.File("bar").Staging)
+
+	_, err = r.Reference(plumbing.RevertHead, false)
+	s.ErrorIs(err, plumbing.ErrReferenceNotFound)
+}
+
+func (s *WorktreeSuite) TestRevertMerge() {
+	r, w, fs, other := s.setupMergeBranches(
+		map[string][]byte{"foo": []byte("a\nb\nc\nd\ne\n")},
+		map[string][]byte{"foo": []byte("A\nb\nc\nd\ne\n")},
+		map[string][]byte{"foo": []byte("a\nb\nc\nd\nE\n"), "qux": []byte("qux\n")},
+	)
+
+	head, err := r.Head()
+	s.NoError(err)
+
+	_, err = w.Revert(head.Hash(), &RevertOptions{Author: defaultSignature(), Mainline: 1})
+	s.ErrorIs(err, ErrRevertMainlineNotMerge)
+
+	_, err = w.Merge(other, &MergeOptions{Strategy: RecursiveMerge})
+	s.NoError(err)
+	merge, err := w.Commit("merge", &CommitOptions{Author: defaultSignature()})
+	s.NoError(err)
+
+	_, err = w.Revert(merge, &RevertOptions{Author: defaultSignature()})
+	s.ErrorIs(err, ErrRevertMergeNoMainline)
+
+	_, err = w.Revert(merge, &RevertOptions{Author: defaultSignature(), Mainline: 3})
+	s.ErrorIs(err, ErrRevertInvalidMainline)
+
+	res, err := w.Revert(merge, &RevertOptions{Author: defaultSignature(), Mainline: 1})
+	s.NoError(err)
+	s.False(res.HasConflicts())
+
+	content, err := util.ReadFile(fs, "foo")
+	s.NoError(err)
+	s.Equal("A\nb\nc\nd\ne\n", string(content))
+
+	_, err = fs.Stat("qux")
+	s.Error(err)
+
+	commit, err := r.CommitObject(res.Commit)
+	s.NoError(err)
+	s.Equal([]plumbing.Hash{merge}, commit.ParentHashes)
+	s.Equal("Revert \"merge\"\n\nThis reverts commit "+merge.String()+", reversing\n"+
+		"changes made to "+head.Hash().String()+".\n", commit.Message)
+}
@@ -0,0 +1,462 @@
+package git
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/go-git/go-git/v6/plumbing/filemode"
+	"github.com/go-git/go-git/v6/plumbing/object"
+	"github.com/go-git/go-git/v6/storage"
+)
+
+// CommitRewrite describes how a commit is rewritten by RewriteHistory. The
+// zero value keeps the commit as it is, only updating its parents when they
+// were rewritten.
+type CommitRewrite struct {
+	// Drop removes the commit from the history, its children taking its
+	// parents as their own.
+	Drop bool
+	// Tree is the hash of the tree of the rewritten commit. If zero, the tree
+	// of the commit is kept.
+	Tree plumbing.Hash
+	// RemovePaths lists the files or directories removed from the tree of
+	// the rewritten commit, relative to its root. Paths that don't exist are
+	// ignored.
+	RemovePaths []string
+	// Message is the message of the rewritten commit. If empty, the message
+	// of the commit is kept.
+	Message string
+	// Author is the author of the rewritten commit. If nil, the author of the
+	// commit is kept.
+	Author *object.Signature
+	// Committer is the committer of the rewritten commit. If nil, the
+	// committer of the commit is kept.
+	Committer *object.Signature
+}
+
+// RewriteFunc returns how the given commit is rewritten. A nil rewrite keeps
+// the commit as it is.
+type RewriteFunc func(*object.Commit) (*CommitRewrite, error)
+
+// RewriteResult holds the outcome of a history rewrite.
+type RewriteResult struct {
+	// Commits maps the hash of every commit walked to the hash of the commit
+	// replacing it: the rewritten commit, the same one if nothing changed, or
+	// for a dropped commit the first of its rewritten parents, the zero hash
+	// if it had none.
+	Commits map[plumbing.Hash]plumbing.Hash
+	// Refs holds the new target of the references updated. A reference
+	// whose commits were all dropped is removed, and holds the zero hash.
+	Refs map[plumbing.ReferenceName]plumbing.Hash
+}
+
+// WriteCommitMap writes the mapping of the commits rewritten, one line with
+// the old and new hash of each commit that changed, sorted by the old hash.
+func (r *RewriteResult) WriteCommitMap(w io.Writer) error {
+	olds := make([]plumbing.Hash, 0, len(r.Commits))
+	for old, h := range r.Commits {
+		if old != h {
+			olds = append(olds, old)
+		}
+	}
+
+	sort.Slice(olds, func(i, j int) bool {
+		return olds[i].Compare(olds[j].Bytes()) < 0
+	})
+
+	for _, old := range olds {
+		if _, err := fmt.Fprintf(w, "%s %s\n", old, r.Commits[old]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// RewriteHistory rewrites the commits reachable from the given references,
+// as `git filter-branch` does. The commits are rewritten parents first, each
+// one as returned by the Rewrite function, with the parents replaced by the
+// rewritten ones. The children of a dropped commit take its parents, so the
+// history stays connected. The commits that don't change, nor their parents,
+// keep their hash, while the PGP signature of the rewritten ones is removed.
+//
+// The references are updated to point to the rewritten commits, annotated
+// tags being rewritten to point to them. The index and the worktree are left
+// untouched, even when the branch checked out is rewritten.
+func (r *Repository) RewriteHistory(opts *RewriteOptions) (*RewriteResult, error) {
+	if opts == nil {
+		opts = &RewriteOptions{}
+	}
+
+	if err := opts.Validate(r); err != nil {
+		return nil, err
+	}
+
+	objs, err := r.objectStorer()
+	if err != nil {
+		return nil, err
+	}
+
+	rw := &historyRewriter{
+		r:       r,
+		s:       objs,
+		opts:    opts,
+		parents: make(map[plumbing.Hash][]plumbing.Hash),
+		trees:   make(map[plumbing.Hash]plumbing.Hash),
+		removed: make(map[string]removedPaths),
+		res: &RewriteResult{
+			Commits: make(map[plumbing.Hash]plumbing.Hash),
+			Refs:    make(map[plumbing.ReferenceName]plumbing.Hash),
+		},
+	}
+
+	refs := make([]*plumbing.Reference, 0, len(opts.Refs))
+	for _, name := range opts.Refs {
+		ref, err := r.Storer.Reference(name)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", err, name)
+		}
+
+		// Symbolic references follow their target.
+		if ref.Type() != plumbing.HashReference {
+			continue
+		}
+
+		refs = append(refs, ref)
+	}
+
+	for _, ref := range refs {
+		h, err := rw.rewriteObject(ref.Hash())
+		if err != nil {
+			return nil, err
+		}
+
+		if h == ref.Hash() {
+			continue
+		}
+
+		rw.res.Refs[ref.Name()] = h
+		if h.IsZero() {
+			err = r.Storer.RemoveReference(ref.Name())
+		} else {
+			err = r.Storer.SetReference(plumbing.NewHashReference(ref.Name(), h))
+		}
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return rw.res, nil
+}
+
+// historyRewriter holds the state of a history rewrite.
+type historyRewriter struct {
+	r *Repository
+	// s reads the objects rewritten, see Repository.objectStorer.
+	s    storage.Storer
+	opts *RewriteOptions
+	res  *RewriteResult
+
+	// parents holds, for each commit walked, the commits its children take
+	// as parents: the rewritten commit, or the parents of a dropped commit.
+	parents map[plumbing.Hash][]plumbing.Hash
+	// trees holds the tree of each commit rewritten.
+	trees map[plumbing.Hash]plumbing.Hash
+	// removed caches the trees written when removing paths, keyed by the
+	// original tree and the paths removed.
+	removed map[string]removedPaths
+}
+
+type removedPaths struct {
+	hash  plumbing.Hash
+	empty bool
+}
+
+// rewriteObject rewrites the object a reference points to, returning its new
+// hash. Annotated tags are rewritten when their target changes.
+func (rw *historyRewriter) rewriteObject(h plumbing.Hash) (plumbing.Hash, error) {
+	obj, err := rw.s.EncodedObject(plumbing.AnyObject, h)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	switch obj.Type() {
+	case plumbing.CommitObject:
+		if err := rw.walk(h); err != nil {
+			return plumbing.ZeroHash, err
+		}
+
+		return rw.res.Commits[h], nil
+	case plumbing.TagObject:
+		tag, err := object.DecodeTag(rw.s, obj)
+		if err != nil {
+			return plumbing.ZeroHash, err
+		}
+
+		target, err := rw.rewriteObject(tag.Target)
+		if err != nil || target == tag.Target || target.IsZero() {
+			return target, err
+		}
+
+		tag.Target = target
+		tag.PGPSignature = ""
+		return rw.store(tag)
+	default:
+		return h, nil
+	}
+}
+
+// walk rewrites the given commit and its ancestors not rewritten yet, parents
+// first.
+func (rw *historyRewriter) walk(h plumbing.Hash) error {
+	if _, ok := rw.parents[h]; ok {
+		return nil
+	}
+
+	c, err := object.GetCommit(rw.s, h)
+	if err != nil {
+		return err
+	}
+
+	type frame struct {
+		c    *object.Commit
+		next int
+	}
+
+	stack := []*frame{{c: c}}
+	for len(stack) > 0 {
+		f := stack[len(stack)-1]
+		if f.next < len(f.c.ParentHashes) {
+			p := f.c.ParentHashes[f.next]
+			f.next++
+			if _, ok := rw.parents[p]; ok {
+				continue
+			}
+
+			pc, err := object.GetCommit(rw.s, p)
+			if errors.Is(err, plumbing.ErrObjectNotFound) {
+				// The parents missing from a shallow repository are kept.
+				rw.parents[p] = []plumbing.Hash{p}
+				continue
+			}
+
+			if err != nil {
+				return err
+			}
+
+			stack = append(stack, &frame{c: pc})
+			continue
+		}
+
+		stack = stack[:len(stack)-1]
+		if _, ok := rw.parents[f.c.Hash]; ok {
+			continue
+		}
+
+		if err := rw.rewriteCommit(f.c); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// rewriteCommit rewrites a commit whose parents were already rewritten.
+func (rw *historyRewriter) rewriteCommit(c *object.Commit) error {
+	var parents []plumbing.Hash
+	for _, p := range c.ParentHashes {
+		for _, np := range rw.parents[p] {
+			if !slices.Contains(parents, np) {
+				parents = append(parents, np)
+			}
+		}
+	}
+
+	var rewrite *CommitRewrite
+	if rw.opts.Rewrite != nil {
+		var err error
+		if rewrite, err = rw.opts.Rewrite(c); err != nil {
+			return err
+		}
+	}
+
+	if rewrite == nil {
+		rewrite = &CommitRewrite{}
+	}
+
+	if rewrite.Drop {
+		rw.drop(c, parents)
+		return nil
+	}
+
+	nc := *c
+	nc.ParentHashes = parents
+	changed := !slices.Equal(parents, c.ParentHashes)
+	if !rewrite.Tree.IsZero() {
+		nc.TreeHash = rewrite.Tree
+	}
+
+	if len(rewrite.RemovePaths) > 0 {
+		paths := make([]string, 0, len(rewrite.RemovePaths))
+		for _, p := range rewrite.RemovePaths {
+			if p = strings.Trim(p, "/"); p != "" {
+				paths = append(paths, p)
+			}
+		}
+
+		sort.Strings(paths)
+		removed, err := rw.removePaths(nc.TreeHash, paths)
+		if err != nil {
+			return err
+		}
+
+		nc.TreeHash = removed.hash
+	}
+
+	if rewrite.Message != "" {
+		nc.Message = rewrite.Message
+	}
+
+	if rewrite.Author != nil {
+		nc.Author = *rewrite.Author
+		changed = true
+	}
+
+	if rewrite.Committer != nil {
+		nc.Committer = *rewrite.Committer
+		changed = true
+	}
+
+	changed = changed || nc.TreeHash != c.TreeHash || nc.Message != c.Message
+	if rw.opts.PruneEmpty && rw.isEmpty(&nc) {
+		rw.drop(c, parents)
+		return nil
+	}
+
+	h := c.Hash
+	if changed {
+		nc.PGPSignature = ""
+		var err error
+		if h, err = rw.store(&nc); err != nil {
+			return err
+		}
+	}
+
+	rw.parents[c.Hash] = []plumbing.Hash{h}
+	rw.trees[h] = nc.TreeHash
+	rw.res.Commits[c.Hash] = h
+	return nil
+}
+
+func (rw *historyRewriter) drop(c *object.Commit, parents []plumbing.Hash) {
+	rw.parents[c.Hash] = parents
+	rw.res.Commits[c.Hash] = plumbing.ZeroHash
+	if len(parents) > 0 {
+		rw.res.Commits[c.Hash] = parents[0]
+	}
+}
+
+// isEmpty returns true if the commit doesn't change the tree of its only
+// parent, or has no parents and an empty tree.
+func (rw *historyRewriter) isEmpty(c *object.Commit) bool {
+	switch len(c.ParentHashes) {
+	case 0:
+		tree, err := object.GetTree(rw.s, c.TreeHash)
+		return err == nil && len(tree.Entries) == 0
+	case 1:
+		tree, ok := rw.trees[c.ParentHashes[0]]
+		if !ok {
+			parent, err := object.GetCommit(rw.s, c.ParentHashes[0])
+			if err != nil {
+				return false
+			}
+
+			tree = parent.TreeHash
+		}
+
+		return tree == c.TreeHash
+	default:
+		return false
+	}
+}
+
+// removePaths returns the tree without the given paths, relative to it,
+// storing the trees that changed. Directories left empty are removed.
+func (rw *historyRewriter) removePaths(h plumbing.Hash, paths []string) (removedPaths, error) {
+	key := h.String() + "\x00" + strings.Join(paths, "\x00")
+	if removed, ok := rw.removed[key]; ok {
+		return removed, nil
+	}
+
+	t, err := object.GetTree(rw.s, h)
+	if err != nil {
+		return removedPaths{}, err
+	}
+
+	remove := make(map[string]bool)
+	below := make(map[string][]string)
+	for _, p := range paths {
+		name, rest, ok := strings.Cut(p, "/")
+		if !ok {
+			remove[name] = true
+			continue
+		}
+
+		below[name] = append(below[name], rest)
+	}
+
+	nt := &object.Tree{}
+	changed := false
+	for _, e := range t.Entries {
+		if remove[e.Name] {
+			changed = true
+			continue
+		}
+
+		if sub, ok := below[e.Name]; ok && e.Mode == filemode.Dir {
+			removed, err := rw.removePaths(e.Hash, sub)
+			if err != nil {
+				return removedPaths{}, err
+			}
+
+			if removed.hash != e.Hash {
+				changed = true
+			}
+
+			if removed.empty {
+				continue
+			}
+
+			e.Hash = removed.hash
+		}
+
+		nt.Entries = append(nt.Entries, e)
+	}
+
+	removed := removedPaths{hash: h, empty: len(nt.Entries) == 0}
+	if changed {
+		if removed.hash, err = rw.store(nt); err != nil {
+			return removedPaths{}, err
+		}
+	}
+
+	rw.removed[key] = removed
+	return removed, nil
+}
+
+func (rw *historyRewriter) store(o interface {
+	Encode(plumbing.EncodedObject) error
+}) (plumbing.Hash, error) {
+	obj := rw.r.Storer.NewEncodedObject()
+	if err := o.Encode(obj); err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	return rw.r.Storer.SetEncodedObject(obj)
+}
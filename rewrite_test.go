@@ -0,0 +1,227 @@
+package git
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/go-git/go-billy/v5/util"
+	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/go-git/go-git/v6/plumbing/object"
+	"github.com/go-git/go-git/v6/storage/memory"
+	"github.com/stretchr/testify/suite"
+)
+
+type RewriteSuite struct {
+	suite.Suite
+	r *Repository
+	w *Worktree
+}
+
+func TestRewriteSuite(t *testing.T) {
+	suite.Run(t, new(RewriteSuite))
+}
+
+func (s *RewriteSuite) SetupTest() {
+	var err error
+	s.r, err = Init(memory.NewStorage(), WithWorkTree(memfs.New()))
+	s.Require().NoError(err)
+	s.w, err = s.r.Worktree()
+	s.Require().NoError(err)
+}
+
+// commit commits the given changes on top of HEAD, a nil content removing
+// the file.
+func (s *RewriteSuite) commit(msg string, files map[string][]byte) plumbing.Hash {
+	for name, content := range files {
+		if content == nil {
+			_, err := s.w.Remove(name)
+			s.Require().NoError(err)
+			continue
+		}
+
+		s.Require().NoError(util.WriteFile(s.w.Filesystem, name, content, 0644))
+		_, err := s.w.Add(name)
+		s.Require().NoError(err)
+	}
+
+	h, err := s.w.Commit(msg, &CommitOptions{Author: defaultSignature(), AllowEmptyCommits: true})
+	s.Require().NoError(err)
+	return h
+}
+
+// mergeCommit stores a commit with the given parents, and the tree of the
+// first one.
+func (s *RewriteSuite) mergeCommit(msg string, parents ...plumbing.Hash) plumbing.Hash {
+	first, err := s.r.CommitObject(parents[0])
+	s.Require().NoError(err)
+
+	c := &object.Commit{
+		Author:       *defaultSignature(),
+		Committer:    *defaultSignature(),
+		Message:      msg,
+		TreeHash:     first.TreeHash,
+		ParentHashes: parents,
+	}
+
+	obj := s.r.Storer.NewEncodedObject()
+	s.Require().NoError(c.Encode(obj))
+	h, err := s.r.Storer.SetEncodedObject(obj)
+	s.Require().NoError(err)
+	return h
+}
+
+func (s *RewriteSuite) head() *object.Commit {
+	ref, err := s.r.Reference(plumbing.Master, true)
+	s.Require().NoError(err)
+	c, err := s.r.CommitObject(ref.Hash())
+	s.Require().NoError(err)
+	return c
+}
+
+func (s *RewriteSuite) TestRewriteHistoryRemovePaths() {
+	first := s.commit("first", map[string][]byte{
+		"README": []byte("readme\n"), "big.bin": []byte("big\n"),
+		"a/keep": []byte("keep\n"), "a/big": []byte("big\n"), "b/big": []byte("big\n"),
+	})
+	second := s.commit("second", map[string][]byte{"README": []byte("changed\n")})
+	third := s.commit("third", map[string][]byte{"big.bin": []byte("bigger\n")})
+
+	res, err := s.r.RewriteHistory(&RewriteOptions{
+		Rewrite: func(*object.Commit) (*CommitRewrite, error) {
+			return &CommitRewrite{RemovePaths: []string{"big.bin", "a/big", "/b/", "missing/file"}}, nil
+		},
+		PruneEmpty: true,
+	})
+	s.Require().NoError(err)
+
+	head := s.head()
+	s.Equal(res.Commits[second], head.Hash)
+	s.Equal(res.Commits[second], res.Commits[third])
+	s.Equal(map[plumbing.ReferenceName]plumbing.Hash{plumbing.Master: head.Hash}, res.Refs)
+	s.Equal("second", head.Message)
+
+	parent, err := head.Parent(0)
+	s.Require().NoError(err)
+	s.Equal(res.Commits[first], parent.Hash)
+	s.Equal(0, parent.NumParents())
+
+	for _, c := range []*object.Commit{head, parent} {
+		tree, err := c.Tree()
+		s.Require().NoError(err)
+
+		var names []string
+		s.Require().NoError(tree.Files().ForEach(func(f *object.File) error {
+			names = append(names, f.Name)
+			return nil
+		}))
+		s.Equal([]string{"README", "a/keep"}, names)
+	}
+}
+
+func (s *RewriteSuite) TestRewriteHistoryDrop() {
+	root := s.commit("root", map[string][]byte{"foo": []byte("foo\n")})
+	dropped := s.commit("drop me", map[string][]byte{"bar": []byte("bar\n")})
+	child := s.commit("child", map[string][]byte{"qux": []byte("qux\n")})
+
+	s.Require().NoError(s.w.Checkout(&CheckoutOptions{Hash: root, Branch: "refs/heads/side", Create: true}))
+	side := s.commit("side", map[string][]byte{"side": []byte("side\n")})
+	merge := s.mergeCommit("merge\n", child, dropped, side)
+	s.Require().NoError(s.r.Storer.SetReference(plumbing.NewHashReference(plumbing.Master, merge)))
+
+	res, err := s.r.RewriteHistory(&RewriteOptions{
+		Refs: []plumbing.ReferenceName{plumbing.Master},
+		Rewrite: func(c *object.Commit) (*CommitRewrite, error) {
+			if c.Hash == dropped {
+				return &CommitRewrite{Drop: true}, nil
+			}
+
+			return nil, nil
+		},
+	})
+	s.Require().NoError(err)
+
+	// The commits before the dropped one, or not descending from it, keep
+	// their hash.
+	s.Equal(root, res.Commits[root])
+	s.Equal(side, res.Commits[side])
+	s.Equal(root, res.Commits[dropped])
+
+	newChild, err := s.r.CommitObject(res.Commits[child])
+	s.Require().NoError(err)
+	s.Equal([]plumbing.Hash{root}, newChild.ParentHashes)
+	s.Equal("child", newChild.Message)
+
+	// The parents of the dropped commit replace it in the merge, once.
+	head := s.head()
+	s.Equal(res.Commits[merge], head.Hash)
+	s.Equal([]plumbing.Hash{newChild.Hash, root, side}, head.ParentHashes)
+
+	ref, err := s.r.Reference("refs/heads/side", false)
+	s.Require().NoError(err)
+	s.Equal(side, ref.Hash())
+}
+
+func (s *RewriteSuite) TestRewriteHistoryUnchanged() {
+	s.commit("first", map[string][]byte{"foo": []byte("foo\n")})
+	head := s.commit("second", map[string][]byte{"foo": []byte("bar\n")})
+
+	res, err := s.r.RewriteHistory(nil)
+	s.Require().NoError(err)
+	s.Empty(res.Refs)
+	s.Len(res.Commits, 2)
+	s.Equal(head, res.Commits[head])
+	s.Equal(head, s.head().Hash)
+
+	var buf bytes.Buffer
+	s.NoError(res.WriteCommitMap(&buf))
+	s.Empty(buf.String())
+}
+
+func (s *RewriteSuite) TestRewriteHistoryMessageAndAuthor() {
+	first := s.commit("first", map[string][]byte{"foo": []byte("foo\n")})
+	second := s.commit("second", map[string][]byte{"foo": []byte("bar\n")})
+	tag, err := s.r.CreateTag("v1", second, &CreateTagOptions{Tagger: defaultSignature(), Message: "v1"})
+	s.Require().NoError(err)
+
+	author := &object.Signature{Name: "New", Email: "new@example.com", When: defaultSignature().When}
+	res, err := s.r.RewriteHistory(&RewriteOptions{
+		Refs: []plumbing.ReferenceName{plumbing.Master, tag.Name()},
+		Rewrite: func(c *object.Commit) (*CommitRewrite, error) {
+			return &CommitRewrite{
+				Message: strings.ToUpper(c.Message),
+				Author:  author,
+			}, nil
+		},
+	})
+	s.Require().NoError(err)
+
+	head := s.head()
+	s.Equal("SECOND", head.Message)
+	s.Equal("New", head.Author.Name)
+	s.Equal(defaultSignature().Name, head.Committer.Name)
+
+	parent, err := head.Parent(0)
+	s.Require().NoError(err)
+	s.Equal("FIRST", parent.Message)
+
+	// The annotated tag is rewritten to point to the rewritten commit.
+	ref, err := s.r.Tag("v1")
+	s.Require().NoError(err)
+	s.NotEqual(tag.Hash(), ref.Hash())
+	s.Equal(res.Refs[tag.Name()], ref.Hash())
+
+	tagObj, err := s.r.TagObject(ref.Hash())
+	s.Require().NoError(err)
+	s.Equal(head.Hash, tagObj.Target)
+	s.Equal("v1\n", tagObj.Message)
+
+	var buf bytes.Buffer
+	s.NoError(res.WriteCommitMap(&buf))
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	s.ElementsMatch([]string{
+		first.String() + " " + parent.Hash.String(),
+		second.String() + " " + head.Hash.String(),
+	}, lines)
+}
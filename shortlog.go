@@ -0,0 +1,132 @@
+package git
+
+import (
+	"cmp"
+	"slices"
+	"strings"
+
+	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/go-git/go-git/v6/plumbing/object"
+)
+
+// ShortlogEntry holds the commits of an author, or committer, summarized by
+// Shortlog.
+type ShortlogEntry struct {
+	Name string
+	// Email is only set when the commits are grouped by email.
+	Email string
+	// Count is the number of commits.
+	Count int
+	// Subjects are the first lines of the messages of the commits, in the
+	// reverse order of the walk, oldest first. It is only set when requested.
+	Subjects []string
+}
+
+// String returns the name of the entry, followed by the email between angle
+// brackets if set, as printed by `git shortlog`.
+func (e *ShortlogEntry) String() string {
+	if e.Email == "" {
+		return e.Name
+	}
+
+	return e.Name + " <" + e.Email + ">"
+}
+
+// Shortlog summarizes the commits reachable from opts.From, and not from
+// opts.Exclude, by author, as `git shortlog -sn` does. The history is walked
+// once and only the summary is kept in memory, not the commits. The entries
+// are sorted by number of commits, in descending order, and then by name.
+func (r *Repository) Shortlog(opts *ShortlogOptions) ([]*ShortlogEntry, error) {
+	if opts == nil {
+		opts = &ShortlogOptions{}
+	}
+
+	if err := opts.Validate(r); err != nil {
+		return nil, err
+	}
+
+	excluded, err := r.shortlogExcluded(opts.Exclude)
+	if err != nil {
+		return nil, err
+	}
+
+	from, err := r.CommitObject(opts.From)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make(map[string]*ShortlogEntry)
+	iter := object.NewCommitPreorderIter(from, excluded, nil)
+	err = iter.ForEach(func(c *object.Commit) error {
+		sig := c.Author
+		if opts.Committer {
+			sig = c.Committer
+		}
+
+		sig = sig.Canonical(opts.MailMap)
+		key := sig.Name
+		if opts.Email {
+			key += " <" + sig.Email + ">"
+		}
+
+		e, ok := entries[key]
+		if !ok {
+			e = &ShortlogEntry{Name: sig.Name}
+			if opts.Email {
+				e.Email = sig.Email
+			}
+
+			entries[key] = e
+		}
+
+		e.Count++
+		if opts.Subjects {
+			subject, _, _ := strings.Cut(strings.TrimSpace(c.Message), "\n")
+			e.Subjects = append(e.Subjects, strings.TrimSpace(subject))
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]*ShortlogEntry, 0, len(entries))
+	for _, e := range entries {
+		slices.Reverse(e.Subjects)
+		result = append(result, e)
+	}
+
+	slices.SortFunc(result, func(a, b *ShortlogEntry) int {
+		return cmp.Or(
+			cmp.Compare(b.Count, a.Count),
+			cmp.Compare(a.Name, b.Name),
+			cmp.Compare(a.Email, b.Email),
+		)
+	})
+
+	return result, nil
+}
+
+// shortlogExcluded returns the hashes of the commits reachable from any of
+// the given ones.
+func (r *Repository) shortlogExcluded(hashes []plumbing.Hash) (map[plumbing.Hash]bool, error) {
+	excluded := make(map[plumbing.Hash]bool)
+	for _, h := range hashes {
+		c, err := r.CommitObject(h)
+		if err != nil {
+			return nil, err
+		}
+
+		// The commits already excluded are skipped, with their history.
+		err = object.NewCommitPreorderIter(c, excluded, nil).ForEach(func(c *object.Commit) error {
+			excluded[c.Hash] = true
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return excluded, nil
+}
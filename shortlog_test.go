@@ -0,0 +1,93 @@
+package git
+
+import (
+	"testing"
+
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/go-git/go-billy/v5/util"
+	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/go-git/go-git/v6/plumbing/object"
+	"github.com/go-git/go-git/v6/storage/memory"
+	"github.com/stretchr/testify/suite"
+)
+
+type ShortlogSuite struct {
+	suite.Suite
+	r *Repository
+	w *Worktree
+
+	first, last plumbing.Hash
+}
+
+func TestShortlogSuite(t *testing.T) {
+	suite.Run(t, new(ShortlogSuite))
+}
+
+func (s *ShortlogSuite) SetupTest() {
+	var err error
+	s.r, err = Init(memory.NewStorage(), WithWorkTree(memfs.New()))
+	s.Require().NoError(err)
+	s.w, err = s.r.Worktree()
+	s.Require().NoError(err)
+
+	s.first = s.commit("first\n\nbody", "Alice", "alice@example.com")
+	s.commit("second", "Bob", "bob@example.com")
+	s.commit("third", "alice", "alice@old.example.com")
+	s.commit("fourth", "Alice", "alice@example.com")
+	s.last = s.commit("fifth", "Bob", "bob@example.com")
+}
+
+func (s *ShortlogSuite) commit(msg, name, email string) plumbing.Hash {
+	s.Require().NoError(util.WriteFile(s.w.Filesystem, "file", []byte(msg), 0644))
+	_, err := s.w.Add("file")
+	s.Require().NoError(err)
+
+	author := defaultSignature()
+	author.Name, author.Email = name, email
+	h, err := s.w.Commit(msg, &CommitOptions{Author: author, Committer: defaultSignature()})
+	s.Require().NoError(err)
+	return h
+}
+
+func (s *ShortlogSuite) TestShortlog() {
+	entries, err := s.r.Shortlog(nil)
+	s.Require().NoError(err)
+
+	s.Equal([]*ShortlogEntry{
+		{Name: "Alice", Count: 2},
+		{Name: "Bob", Count: 2},
+		{Name: "alice", Count: 1},
+	}, entries)
+}
+
+func (s *ShortlogSuite) TestShortlogMailMapAndSubjects() {
+	mm := object.NewMailMap()
+	mm.Add("Alice", "alice@example.com", "", "alice@old.example.com")
+
+	entries, err := s.r.Shortlog(&ShortlogOptions{MailMap: mm, Email: true, Subjects: true})
+	s.Require().NoError(err)
+
+	s.Equal([]*ShortlogEntry{
+		{Name: "Alice", Email: "alice@example.com", Count: 3, Subjects: []string{"first", "third", "fourth"}},
+		{Name: "Bob", Email: "bob@example.com", Count: 2, Subjects: []string{"second", "fifth"}},
+	}, entries)
+	s.Equal("Alice <alice@example.com>", entries[0].String())
+}
+
+func (s *ShortlogSuite) TestShortlogRange() {
+	entries, err := s.r.Shortlog(&ShortlogOptions{From: s.last, Exclude: []plumbing.Hash{s.first}, Email: true})
+	s.Require().NoError(err)
+
+	s.Equal([]*ShortlogEntry{
+		{Name: "Bob", Email: "bob@example.com", Count: 2},
+		{Name: "Alice", Email: "alice@example.com", Count: 1},
+		{Name: "alice", Email: "alice@old.example.com", Count: 1},
+	}, entries)
+}
+
+func (s *ShortlogSuite) TestShortlogCommitter() {
+	entries, err := s.r.Shortlog(&ShortlogOptions{Committer: true})
+	s.Require().NoError(err)
+
+	s.Equal([]*ShortlogEntry{{Name: defaultSignature().Name, Count: 5}}, entries)
+}
@@ -0,0 +1,270 @@
+package git
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/go-git/go-git/v6/plumbing/filemode"
+	"github.com/go-git/go-git/v6/plumbing/format/index"
+	"github.com/go-git/go-git/v6/plumbing/object"
+	"github.com/go-git/go-git/v6/plumbing/storer"
+	"github.com/go-git/go-git/v6/storage"
+)
+
+// StagingArea is an in-memory staging area, building tree objects from
+// entries given by hash or content, without an index file or a worktree. It
+// is the equivalent of `git update-index --cacheinfo` followed by
+// `git write-tree`.
+//
+// Adding an entry replaces any entry at the same path, including a file
+// found where a directory is needed, or a directory where the entry goes.
+type StagingArea struct {
+	s    storage.Storer
+	root *stagingNode
+}
+
+// stagingNode is a file or a directory of the staging area. The entries of a
+// directory added by the hash of its tree are only read when one of them
+// changes, so unchanged trees are written as they are.
+type stagingNode struct {
+	mode     filemode.FileMode
+	hash     plumbing.Hash
+	children map[string]*stagingNode
+}
+
+// NewStagingArea returns an empty staging area, writing its objects to the
+// given storer.
+func NewStagingArea(s storage.Storer) *StagingArea {
+	return &StagingArea{
+		s:    s,
+		root: &stagingNode{mode: filemode.Dir, children: map[string]*stagingNode{}},
+	}
+}
+
+// Add stages the object with the given hash and mode at path. A filemode.Dir
+// mode adds the tree with the given hash, the same as AddTree.
+func (a *StagingArea) Add(path string, mode filemode.FileMode, h plumbing.Hash) error {
+	if mode.IsMalformed() || mode == filemode.Deprecated {
+		return fmt.Errorf("invalid mode %s for %q", mode, path)
+	}
+
+	if h.IsZero() {
+		return fmt.Errorf("invalid zero hash for %q", path)
+	}
+
+	parts, err := stagingPath(path)
+	if err != nil {
+		return err
+	}
+
+	if mode == filemode.Dir {
+		if _, err := object.GetTree(a.s, h); err != nil {
+			return err
+		}
+	}
+
+	parent, err := a.dir(parts[:len(parts)-1], true)
+	if err != nil {
+		return err
+	}
+
+	parent.children[parts[len(parts)-1]] = &stagingNode{mode: mode, hash: h}
+	return nil
+}
+
+// AddContent stores content as a blob and stages it at path with the given
+// mode, returning the hash of the blob.
+func (a *StagingArea) AddContent(path string, mode filemode.FileMode, content []byte) (plumbing.Hash, error) {
+	if !mode.IsFile() || mode == filemode.Deprecated {
+		return plumbing.ZeroHash, fmt.Errorf("invalid mode %s for %q", mode, path)
+	}
+
+	if _, err := stagingPath(path); err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	h, err := writeBlob(a.s, content)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	return h, a.Add(path, mode, h)
+}
+
+// AddTree stages the entries of the tree with the given hash under path. An
+// empty path replaces all the staged entries with the ones of the tree, like
+// `git read-tree`.
+func (a *StagingArea) AddTree(path string, h plumbing.Hash) error {
+	if path != "" {
+		return a.Add(path, filemode.Dir, h)
+	}
+
+	if _, err := object.GetTree(a.s, h); err != nil {
+		return err
+	}
+
+	a.root = &stagingNode{mode: filemode.Dir, hash: h}
+	return nil
+}
+
+// Remove unstages the entry at path. When path is a directory, all the
+// entries under it are removed. If there is no such entry,
+// index.ErrEntryNotFound is returned.
+func (a *StagingArea) Remove(path string) error {
+	parts, err := stagingPath(path)
+	if err != nil {
+		return err
+	}
+
+	parent, err := a.dir(parts[:len(parts)-1], false)
+	if err != nil {
+		return err
+	}
+
+	name := parts[len(parts)-1]
+	if parent == nil || parent.children[name] == nil {
+		return fmt.Errorf("%w: %q", index.ErrEntryNotFound, path)
+	}
+
+	delete(parent.children, name)
+	return nil
+}
+
+// Write stores the staged entries as tree objects, returning the hash of the
+// root tree. Directories left without entries are not written.
+func (a *StagingArea) Write() (plumbing.Hash, error) {
+	return a.writeTree(a.root)
+}
+
+// dir returns the directory at the given path, reading the trees on the way.
+// When create is true, missing directories are created and files on the way
+// are replaced, otherwise a nil node is returned if there is no directory.
+func (a *StagingArea) dir(parts []string, create bool) (*stagingNode, error) {
+	n := a.root
+	for _, part := range parts {
+		if err := a.load(n); err != nil {
+			return nil, err
+		}
+
+		child := n.children[part]
+		if child == nil || child.mode != filemode.Dir {
+			if !create {
+				return nil, nil
+			}
+
+			child = &stagingNode{mode: filemode.Dir, children: map[string]*stagingNode{}}
+			n.children[part] = child
+		}
+
+		n = child
+	}
+
+	return n, a.load(n)
+}
+
+// load reads the entries of a directory added by the hash of its tree. The
+// hash is dropped, as the entries may change.
+func (a *StagingArea) load(n *stagingNode) error {
+	if n.children != nil {
+		return nil
+	}
+
+	t, err := object.GetTree(a.s, n.hash)
+	if err != nil {
+		return err
+	}
+
+	n.children = make(map[string]*stagingNode, len(t.Entries))
+	for _, e := range t.Entries {
+		n.children[e.Name] = &stagingNode{mode: e.Mode, hash: e.Hash}
+	}
+
+	n.hash = plumbing.ZeroHash
+	return nil
+}
+
+func (a *StagingArea) writeTree(n *stagingNode) (plumbing.Hash, error) {
+	if n.children == nil {
+		return n.hash, nil
+	}
+
+	t := &object.Tree{}
+	for name, child := range n.children {
+		h := child.hash
+		if child.mode == filemode.Dir {
+			var err error
+			if h, err = a.writeTree(child); err != nil {
+				return plumbing.ZeroHash, err
+			}
+
+			if h.IsZero() {
+				continue
+			}
+		}
+
+		t.Entries = append(t.Entries, object.TreeEntry{Name: name, Mode: child.mode, Hash: h})
+	}
+
+	// Directories whose entries were all removed aren't written, except the
+	// root one.
+	if len(t.Entries) == 0 && n != a.root {
+		return plumbing.ZeroHash, nil
+	}
+
+	sort.Sort(sortableEntries(t.Entries))
+
+	o := a.s.NewEncodedObject()
+	if err := t.Encode(o); err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	h := o.Hash()
+	if a.s.HasEncodedObject(h) == nil {
+		return h, nil
+	}
+
+	return a.s.SetEncodedObject(o)
+}
+
+// stagingPath splits a path of the staging area into its parts, checking it
+// is valid.
+func stagingPath(path string) ([]string, error) {
+	if err := validPath(path); err != nil {
+		return nil, err
+	}
+
+	parts := strings.Split(path, "/")
+	for _, part := range parts {
+		if part == "" || part == "." {
+			return nil, fmt.Errorf("invalid path: %q", path)
+		}
+	}
+
+	return parts, nil
+}
+
+// writeBlob stores the given content as a blob, returning its hash.
+func writeBlob(s storer.EncodedObjectStorer, content []byte) (plumbing.Hash, error) {
+	obj := s.NewEncodedObject()
+	obj.SetType(plumbing.BlobObject)
+	obj.SetSize(int64(len(content)))
+
+	w, err := obj.Writer()
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	if _, err := io.Copy(w, bytes.NewReader(content)); err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	if err := w.Close(); err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	return s.SetEncodedObject(obj)
+}
@@ -0,0 +1,221 @@
+package git
+
+import (
+	"testing"
+
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/go-git/go-billy/v5/util"
+	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/go-git/go-git/v6/plumbing/filemode"
+	"github.com/go-git/go-git/v6/plumbing/format/index"
+	"github.com/go-git/go-git/v6/plumbing/object"
+	"github.com/go-git/go-git/v6/storage/memory"
+	"github.com/stretchr/testify/suite"
+)
+
+type StagingAreaSuite struct {
+	suite.Suite
+	r *Repository
+}
+
+func TestStagingAreaSuite(t *testing.T) {
+	suite.Run(t, new(StagingAreaSuite))
+}
+
+func (s *StagingAreaSuite) SetupTest() {
+	var err error
+	s.r, err = Init(memory.NewStorage(), WithWorkTree(memfs.New()))
+	s.Require().NoError(err)
+}
+
+// worktreeTree commits the given files with the worktree, returning the hash
+// of the resulting tree.
+func (s *StagingAreaSuite) worktreeTree(files map[string]string) plumbing.Hash {
+	w, err := s.r.Worktree()
+	s.Require().NoError(err)
+
+	for name, content := range files {
+		s.Require().NoError(util.WriteFile(w.Filesystem, name, []byte(content), 0o644))
+		_, err := w.Add(name)
+		s.Require().NoError(err)
+	}
+
+	h, err := w.Commit("files", &CommitOptions{Author: defaultSignature()})
+	s.Require().NoError(err)
+
+	c, err := s.r.CommitObject(h)
+	s.Require().NoError(err)
+	return c.TreeHash
+}
+
+func (s *StagingAreaSuite) TestWrite() {
+	files := map[string]string{
+		"README":     "readme",
+		"dir.txt":    "txt",
+		"dir/a.go":   "package a",
+		"dir/sub/b":  "b",
+		"dir-z/file": "z",
+	}
+
+	a := NewStagingArea(s.r.Storer)
+	for name, content := range files {
+		_, err := a.AddContent(name, filemode.Regular, []byte(content))
+		s.Require().NoError(err)
+	}
+
+	h, err := a.Write()
+	s.Require().NoError(err)
+	s.Equal(s.worktreeTree(files), h)
+
+	t, err := object.GetTree(s.r.Storer, h)
+	s.Require().NoError(err)
+
+	var names []string
+	for _, e := range t.Entries {
+		names = append(names, e.Name)
+	}
+	s.Equal([]string{"README", "dir-z", "dir.txt", "dir"}, names)
+}
+
+func (s *StagingAreaSuite) TestWriteEmpty() {
+	h, err := NewStagingArea(s.r.Storer).Write()
+	s.Require().NoError(err)
+
+	t, err := object.GetTree(s.r.Storer, h)
+	s.Require().NoError(err)
+	s.Empty(t.Entries)
+}
+
+func (s *StagingAreaSuite) TestAddByHash() {
+	a := NewStagingArea(s.r.Storer)
+	blob, err := a.AddContent("a", filemode.Regular, []byte("content"))
+	s.Require().NoError(err)
+
+	s.Require().NoError(a.Add("bin/run", filemode.Executable, blob))
+	s.Require().NoError(a.Add("link", filemode.Symlink, blob))
+
+	h, err := a.Write()
+	s.Require().NoError(err)
+
+	t, err := object.GetTree(s.r.Storer, h)
+	s.Require().NoError(err)
+
+	f, err := t.FindEntry("bin/run")
+	s.Require().NoError(err)
+	s.Equal(filemode.Executable, f.Mode)
+	s.Equal(blob, f.Hash)
+
+	f, err = t.FindEntry("link")
+	s.Require().NoError(err)
+	s.Equal(filemode.Symlink, f.Mode)
+}
+
+func (s *StagingAreaSuite) TestOverwrite() {
+	a := NewStagingArea(s.r.Storer)
+	for _, name := range []string{"file", "dir/a", "dir/b", "other"} {
+		_, err := a.AddContent(name, filemode.Regular, []byte("old"))
+		s.Require().NoError(err)
+	}
+
+	// An entry, a directory replaced by a file and a file replaced by a
+	// directory.
+	for _, name := range []string{"other", "dir", "file/nested"} {
+		_, err := a.AddContent(name, filemode.Regular, []byte(name))
+		s.Require().NoError(err)
+	}
+
+	h, err := a.Write()
+	s.Require().NoError(err)
+
+	s.Equal(s.worktreeTree(map[string]string{
+		"other":       "other",
+		"dir":         "dir",
+		"file/nested": "file/nested",
+	}), h)
+}
+
+func (s *StagingAreaSuite) TestRemove() {
+	a := NewStagingArea(s.r.Storer)
+	for _, name := range []string{"a", "b", "dir/c", "dir/sub/d", "gone/e"} {
+		_, err := a.AddContent(name, filemode.Regular, []byte(name))
+		s.Require().NoError(err)
+	}
+
+	s.NoError(a.Remove("a"))
+	s.NoError(a.Remove("dir/sub"))
+	s.NoError(a.Remove("gone/e"))
+
+	s.ErrorIs(a.Remove("a"), index.ErrEntryNotFound)
+	s.ErrorIs(a.Remove("missing/a"), index.ErrEntryNotFound)
+	s.ErrorIs(a.Remove("b/c"), index.ErrEntryNotFound)
+
+	h, err := a.Write()
+	s.Require().NoError(err)
+	s.Equal(s.worktreeTree(map[string]string{
+		"b":     "b",
+		"dir/c": "dir/c",
+	}), h)
+}
+
+func (s *StagingAreaSuite) TestAddTree() {
+	base := s.worktreeTree(map[string]string{
+		"README":        "readme",
+		"lib/a.go":      "a",
+		"lib/b.go":      "b",
+		"lib/sub/c.go":  "c",
+		"docs/index.md": "index",
+	})
+
+	a := NewStagingArea(s.r.Storer)
+	s.Require().NoError(a.AddTree("", base))
+
+	h, err := a.Write()
+	s.Require().NoError(err)
+	s.Equal(base, h)
+
+	_, err = a.AddContent("lib/sub/d.go", filemode.Regular, []byte("d"))
+	s.Require().NoError(err)
+	s.Require().NoError(a.Remove("lib/a.go"))
+
+	baseTree, err := object.GetTree(s.r.Storer, base)
+	s.Require().NoError(err)
+	docs, err := baseTree.FindEntry("docs")
+	s.Require().NoError(err)
+	s.Require().NoError(a.AddTree("vendor/docs", docs.Hash))
+
+	h, err = a.Write()
+	s.Require().NoError(err)
+
+	w, err := s.r.Worktree()
+	s.Require().NoError(err)
+	s.Require().NoError(util.RemoveAll(w.Filesystem, "lib/a.go"))
+	_, err = w.Remove("lib/a.go")
+	s.Require().NoError(err)
+
+	s.Equal(s.worktreeTree(map[string]string{
+		"lib/sub/d.go":         "d",
+		"vendor/docs/index.md": "index",
+	}), h)
+}
+
+func (s *StagingAreaSuite) TestInvalid() {
+	a := NewStagingArea(s.r.Storer)
+	blob, err := a.AddContent("a", filemode.Regular, []byte("a"))
+	s.Require().NoError(err)
+
+	for _, name := range []string{"", "/a", "a//b", "a/", "./a", "a/../b", ".git/config"} {
+		s.Error(a.Add(name, filemode.Regular, blob), name)
+	}
+
+	s.Error(a.Add("b", filemode.Empty, blob))
+	s.Error(a.Add("b", filemode.Regular, plumbing.ZeroHash))
+	s.Error(a.Add("b", filemode.Dir, blob))
+	s.Error(a.AddTree("", blob))
+
+	_, err = a.AddContent("b", filemode.Dir, []byte("b"))
+	s.Error(err)
+	_, err = a.AddContent("..", filemode.Regular, []byte("b"))
+	s.Error(err)
+
+	s.ErrorIs(a.Remove("b"), index.ErrEntryNotFound)
+}
@@ -0,0 +1,581 @@
+package git
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/go-git/go-git/v6/plumbing/format/index"
+	"github.com/go-git/go-git/v6/plumbing/object"
+	"github.com/go-git/go-git/v6/plumbing/storer"
+)
+
+var (
+	// ErrNoLocalChanges is returned by Stash when there are no changes to
+	// be stashed.
+	ErrNoLocalChanges = errors.New("no local changes to save")
+	// ErrStashNotFound is returned when the requested stash entry doesn't
+	// exist.
+	ErrStashNotFound = errors.New("stash entry not found")
+)
+
+const (
+	stashOursLabel   = "Updated upstream"
+	stashTheirsLabel = "Stashed changes"
+)
+
+// StashEntry is an entry of the stash list.
+type StashEntry struct {
+	// Index is the position of the entry in the list, the most recent stash
+	// being 0, as in `stash@{<index>}`.
+	Index int
+	// Hash of the stash commit.
+	Hash plumbing.Hash
+	// Branch is the short name of the branch the stash was created on, or
+	// "(no branch)" if HEAD was detached.
+	Branch string
+	// Message describes the stash, e.g. "WIP on master: 6ecf0ef vendor stuff",
+	// or "On master: my changes" if a message was given to Stash.
+	Message string
+}
+
+// newStashEntry returns the n-th entry of the stash list, with the given
+// commit and message, the branch being read from the message.
+func newStashEntry(n int, h plumbing.Hash, msg string) StashEntry {
+	e := StashEntry{Index: n, Hash: h, Message: msg}
+	for _, prefix := range []string{"WIP on ", "On "} {
+		if rest, ok := strings.CutPrefix(msg, prefix); ok {
+			e.Branch, _, _ = strings.Cut(rest, ": ")
+			break
+		}
+	}
+
+	return e
+}
+
+// Name returns the name of the entry, as "stash@{<index>}".
+func (e StashEntry) Name() string {
+	return fmt.Sprintf("stash@{%d}", e.Index)
+}
+
+// String returns the entry as listed by `git stash list`, e.g.
+// "stash@{0}: WIP on master: 6ecf0ef vendor stuff".
+func (e StashEntry) String() string {
+	return e.Name() + ": " + e.Message
+}
+
+// ParseStashIndex returns the index of the stash entry with the given name,
+// either "stash@{<index>}" or just the index, as accepted by `git stash`. An
+// empty name is the most recent entry, 0.
+func ParseStashIndex(name string) (int, error) {
+	if name == "" {
+		return 0, nil
+	}
+
+	s := name
+	if rest, ok := strings.CutPrefix(s, "stash@{"); ok {
+		s, ok = strings.CutSuffix(rest, "}")
+		if !ok {
+			return 0, fmt.Errorf("%w: %s", ErrStashNotFound, name)
+		}
+	}
+
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("%w: %s", ErrStashNotFound, name)
+	}
+
+	return n, nil
+}
+
+// Stash saves the local changes away and reverts the worktree to HEAD, as
+// `git stash push` does. The changes are stored in a commit, whose parents
+// are HEAD and a commit holding the state of the index, followed by a commit
+// holding the untracked files when IncludeUntracked is set. The stash commit
+// is referenced by refs/stash, with the previous stashes kept in its reflog,
+// so the stash can be used by git as well.
+//
+// ErrNoLocalChanges is returned if there is nothing to stash.
+func (w *Worktree) Stash(opts *StashOptions) (plumbing.Hash, error) {
+	if opts == nil {
+		opts = &StashOptions{}
+	}
+
+	if err := opts.Validate(w.r); err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	head, err := w.r.Head()
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	headCommit, err := w.r.CommitObject(head.Hash())
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	idx, err := w.r.Storer.Index()
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	for _, e := range idx.Entries {
+		if e.Stage != index.Merged {
+			return plumbing.ZeroHash, ErrUnmergedPaths
+		}
+	}
+
+	status, err := w.Status()
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	var changed, modified, untracked []string
+	for p, fs := range status {
+		switch {
+		case fs.Worktree == Untracked:
+			if opts.IncludeUntracked {
+				untracked = append(untracked, p)
+			}
+		case fs.Worktree != Unmodified:
+			changed = append(changed, p)
+			modified = append(modified, p)
+		case fs.Staging != Unmodified:
+			changed = append(changed, p)
+		}
+	}
+
+	if len(changed) == 0 && len(untracked) == 0 {
+		return plumbing.ZeroHash, ErrNoLocalChanges
+	}
+
+	subject := fmt.Sprintf("%s: %s %s", w.stashBranchName(), head.Hash().String()[:7], commitSubject(headCommit.Message))
+
+	indexCommit, err := w.stashCommit(idx, "index on "+subject, opts, head.Hash())
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	filter, err := w.worktreeContentFilter(idx)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	// The worktree commit holds the index with the changes of the tracked
+	// files in the worktree added on top.
+	worktreeIdx := copyIndex(idx)
+	for _, p := range modified {
+		if _, _, err := w.doAddFile(worktreeIdx, filter, nil, p, nil); err != nil {
+			return plumbing.ZeroHash, err
+		}
+	}
+
+	parents := []plumbing.Hash{head.Hash(), indexCommit}
+	if len(untracked) > 0 {
+		untrackedIdx := &index.Index{Version: idx.Version}
+		for _, p := range untracked {
+			if _, _, err := w.doAddFile(untrackedIdx, filter, nil, p, nil); err != nil {
+				return plumbing.ZeroHash, err
+			}
+		}
+
+		untrackedCommit, err := w.stashCommit(untrackedIdx, "untracked files on "+subject, opts)
+		if err != nil {
+			return plumbing.ZeroHash, err
+		}
+
+		parents = append(parents, untrackedCommit)
+	}
+
+	msg := "WIP on " + subject
+	if opts.Message != "" {
+		msg = fmt.Sprintf("On %s: %s", w.stashBranchName(), opts.Message)
+	}
+
+	stash, err := w.stashCommit(worktreeIdx, msg, opts, parents...)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	old, err := w.r.referenceHash(plumbing.Stash)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	if err := w.r.Storer.SetReference(plumbing.NewHashReference(plumbing.Stash, stash)); err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	if err := w.r.logRefUpdate(plumbing.Stash, old, stash, opts.Committer, msg); err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	// Only the changed paths are reset, as a hard reset of the whole
+	// worktree would remove the untracked files.
+	if len(changed) > 0 {
+		err := w.reset(&ResetOptions{Mode: HardReset, Commit: head.Hash(), Files: changed}, "reset: moving to HEAD")
+		if err != nil {
+			return plumbing.ZeroHash, err
+		}
+	}
+
+	for _, p := range untracked {
+		if err := rmFileAndDirsIfEmpty(w.Filesystem, p); err != nil {
+			return plumbing.ZeroHash, err
+		}
+	}
+
+	return stash, nil
+}
+
+// stashBranchName returns the short name of the branch checked out, as used
+// in the stash messages.
+func (w *Worktree) stashBranchName() string {
+	ref, err := w.r.Storer.Reference(plumbing.HEAD)
+	if err != nil || ref.Type() != plumbing.SymbolicReference {
+		return "(no branch)"
+	}
+
+	return ref.Target().Short()
+}
+
+// stashCommit stores a commit of the tree of the given index.
+func (w *Worktree) stashCommit(idx *index.Index, msg string, opts *StashOptions, parents ...plumbing.Hash) (plumbing.Hash, error) {
+	h := &buildTreeHelper{fs: w.Filesystem, s: w.r.Storer}
+	tree, err := h.BuildTree(idx, nil)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	return w.buildCommitObject(msg+"\n", &CommitOptions{
+		Author:    opts.Author,
+		Committer: opts.Committer,
+		Parents:   parents,
+	}, tree)
+}
+
+func copyIndex(idx *index.Index) *index.Index {
+	cp := &index.Index{Version: idx.Version}
+	for _, e := range idx.Entries {
+		e := *e
+		cp.Entries = append(cp.Entries, &e)
+	}
+
+	return cp
+}
+
+// StashList returns the stash entries, the most recent first.
+func (w *Worktree) StashList() ([]StashEntry, error) {
+	ref, err := w.r.Storer.Reference(plumbing.Stash)
+	if err == plumbing.ErrReferenceNotFound {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	logs, err := w.r.Reflog(plumbing.Stash)
+	if err != nil {
+		return nil, err
+	}
+
+	// Without a reflog only the most recent stash is known.
+	if len(logs) == 0 {
+		c, err := w.r.CommitObject(ref.Hash())
+		if err != nil {
+			return nil, err
+		}
+
+		return []StashEntry{newStashEntry(0, c.Hash, commitSubject(c.Message))}, nil
+	}
+
+	entries := make([]StashEntry, len(logs))
+	for i, e := range logs {
+		entries[i] = newStashEntry(i, e.New, e.Message)
+	}
+
+	return entries, nil
+}
+
+// StashApply applies the changes of the n-th stash entry on top of the
+// current worktree, as `git stash apply stash@{<n>}` does. The stashed
+// changes are merged with the ones in the worktree using a three-way merge,
+// with the commit the stash was created on as the ancestor. Conflicting paths
+// are recorded in the index and returned in the MergeResult, as Merge does.
+//
+// When the changes apply cleanly they are left unstaged, except for the new
+// files, as git does. The stashed untracked files, if any, are restored
+// without being added. ErrWorktreeNotClean is returned if the worktree has
+// uncommitted changes in the paths the stash changes, or untracked files in
+// the way.
+func (w *Worktree) StashApply(n int) (*MergeResult, error) {
+	entry, err := w.stashEntry(n)
+	if err != nil {
+		return nil, err
+	}
+
+	stash, err := w.r.CommitObject(entry.Hash)
+	if err != nil {
+		return nil, err
+	}
+
+	if stash.NumParents() < 2 {
+		return nil, fmt.Errorf("%s is not a stash commit", stash.Hash)
+	}
+
+	idx, err := w.r.Storer.Index()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, e := range idx.Entries {
+		if e.Stage != index.Merged {
+			return nil, ErrUnmergedPaths
+		}
+	}
+
+	original := copyIndex(idx)
+	h := &buildTreeHelper{fs: w.Filesystem, s: w.r.Storer}
+	oursHash, err := h.BuildTree(idx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	ours, err := object.GetTree(w.r.Storer, oursHash)
+	if err != nil {
+		return nil, err
+	}
+
+	baseCommit, err := stash.Parent(0)
+	if err != nil {
+		return nil, err
+	}
+
+	base, err := baseCommit.Tree()
+	if err != nil {
+		return nil, err
+	}
+
+	theirs, err := stash.Tree()
+	if err != nil {
+		return nil, err
+	}
+
+	untracked := make(map[string]object.TreeEntry)
+	if stash.NumParents() > 2 {
+		c, err := stash.Parent(2)
+		if err != nil {
+			return nil, err
+		}
+
+		t, err := c.Tree()
+		if err != nil {
+			return nil, err
+		}
+
+		if untracked, err = flattenTree(t); err != nil {
+			return nil, err
+		}
+	}
+
+	for p := range untracked {
+		if _, err := w.Filesystem.Lstat(p); err == nil {
+			return nil, fmt.Errorf("%w: %s already exists", ErrWorktreeNotClean, p)
+		}
+	}
+
+	attributes, err := w.attributesMatcher()
+	if err != nil {
+		return nil, err
+	}
+
+	m := &treeMerger{
+		s:           w.r.Storer,
+		attributes:  attributes,
+		oursLabel:   stashOursLabel,
+		theirsLabel: stashTheirsLabel,
+	}
+
+	res, err := m.merge(base, ours, theirs)
+	if err != nil {
+		return nil, err
+	}
+
+	status, err := w.Status()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkStashOverwrites(res, ours, status); err != nil {
+		return nil, err
+	}
+
+	if err := w.applyMerge(res, status); err != nil {
+		return nil, err
+	}
+
+	if len(res.conflicts) == 0 {
+		if err := w.unstageStashChanges(original); err != nil {
+			return nil, err
+		}
+	}
+
+	filter, err := w.worktreeContentFilter(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	symlinks, err := w.symlinksEnabled()
+	if err != nil {
+		return nil, err
+	}
+
+	for p, e := range untracked {
+		blob, err := object.GetBlob(w.r.Storer, e.Hash)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := w.checkoutFile(object.NewFile(p, e.Mode, blob), filter, symlinks); err != nil {
+			return nil, err
+		}
+	}
+
+	return &MergeResult{Conflicts: res.conflicts}, nil
+}
+
+// checkStashOverwrites returns ErrWorktreeNotClean if applying the merge
+// would overwrite uncommitted changes in the worktree.
+func checkStashOverwrites(res *treeMergeResult, ours *object.Tree, status Status) error {
+	current, err := flattenTree(ours)
+	if err != nil {
+		return err
+	}
+
+	for _, p := range unionPaths(current, res.entries) {
+		if res.inKeptTree(p) {
+			continue
+		}
+
+		c, inCurrent := current[p]
+		e, inResult := res.entries[p]
+		if sameEntry(c, inCurrent, e, inResult) {
+			continue
+		}
+
+		fs, ok := status[p]
+		if ok && fs.Worktree != Unmodified && fs.Worktree != Untracked {
+			return fmt.Errorf("%w: local changes to %s would be overwritten", ErrWorktreeNotClean, p)
+		}
+	}
+
+	return nil
+}
+
+// unstageStashChanges resets the index to the given one, keeping the files
+// added by the stash.
+func (w *Worktree) unstageStashChanges(original *index.Index) error {
+	idx, err := w.r.Storer.Index()
+	if err != nil {
+		return err
+	}
+
+	b := newIndexBuilder(original)
+	for _, e := range idx.Entries {
+		if _, err := original.Entry(e.Name); err == index.ErrEntryNotFound {
+			b.Add(e)
+		}
+	}
+
+	b.Write(idx)
+	return w.r.Storer.SetIndex(idx)
+}
+
+// StashPop applies the n-th stash entry, as StashApply does, and drops it
+// from the stash list when it applied without conflicts.
+func (w *Worktree) StashPop(n int) (*MergeResult, error) {
+	res, err := w.StashApply(n)
+	if err != nil || res.HasConflicts() {
+		return res, err
+	}
+
+	return res, w.StashDrop(n)
+}
+
+// StashDrop removes the n-th entry from the stash list, rewriting the reflog
+// of refs/stash as `git stash drop` does. refs/stash is removed along with the
+// last entry.
+func (w *Worktree) StashDrop(n int) error {
+	if _, err := w.stashEntry(n); err != nil {
+		return err
+	}
+
+	rs, ok := w.r.Storer.(storer.ReflogStorer)
+	if !ok {
+		return w.r.Storer.RemoveReference(plumbing.Stash)
+	}
+
+	logs, err := rs.Reflog(plumbing.Stash)
+	if err != nil {
+		return err
+	}
+
+	if len(logs) == 0 {
+		return w.r.Storer.RemoveReference(plumbing.Stash)
+	}
+
+	// The reflog is stored oldest first, while the entries are numbered from
+	// the most recent one.
+	i := len(logs) - 1 - n
+	if i+1 < len(logs) {
+		logs[i+1].Old = logs[i].Old
+	}
+	logs = append(logs[:i], logs[i+1:]...)
+
+	if len(logs) == 0 {
+		return w.r.Storer.RemoveReference(plumbing.Stash)
+	}
+
+	if err := rs.RemoveReflog(plumbing.Stash); err != nil {
+		return err
+	}
+
+	for _, e := range logs {
+		if err := rs.AppendReflog(plumbing.Stash, e); err != nil {
+			return err
+		}
+	}
+
+	latest := logs[len(logs)-1].New
+	return w.r.Storer.SetReference(plumbing.NewHashReference(plumbing.Stash, latest))
+}
+
+// StashEntry returns the entry of the stash list with the given name, see
+// ParseStashIndex. Its index can be given to StashApply, StashPop and
+// StashDrop. ErrStashNotFound is returned if there is no such entry.
+func (w *Worktree) StashEntry(name string) (StashEntry, error) {
+	n, err := ParseStashIndex(name)
+	if err != nil {
+		return StashEntry{}, err
+	}
+
+	return w.stashEntry(n)
+}
+
+// stashEntry returns the n-th entry of the stash list.
+func (w *Worktree) stashEntry(n int) (StashEntry, error) {
+	entries, err := w.StashList()
+	if err != nil {
+		return StashEntry{}, err
+	}
+
+	if n < 0 || n >= len(entries) {
+		return StashEntry{}, fmt.Errorf("%w: stash@{%d}", ErrStashNotFound, n)
+	}
+
+	return entries[n], nil
+}
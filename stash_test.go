@@ -0,0 +1,402 @@
+package git
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/go-git/go-billy/v5/util"
+	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/go-git/go-git/v6/plumbing/object"
+	"github.com/go-git/go-git/v6/storage/memory"
+	"github.com/stretchr/testify/suite"
+)
+
+type StashSuite struct {
+	suite.Suite
+	r  *Repository
+	w  *Worktree
+	fs billy.Filesystem
+}
+
+func TestStashSuite(t *testing.T) {
+	suite.Run(t, new(StashSuite))
+}
+
+func (s *StashSuite) SetupTest() {
+	s.fs = memfs.New()
+
+	var err error
+	s.r, err = Init(memory.NewStorage(), WithWorkTree(s.fs))
+	s.Require().NoError(err)
+
+	s.w, err = s.r.Worktree()
+	s.Require().NoError(err)
+
+	s.commit(map[string]string{
+		"foo": "a\nb\nc\nd\ne\n",
+		"bar": "bar\n",
+	})
+}
+
+func (s *StashSuite) commit(files map[string]string) plumbing.Hash {
+	for name, content := range files {
+		s.write(name, content)
+		_, err := s.w.Add(name)
+		s.Require().NoError(err)
+	}
+
+	h, err := s.w.Commit("commit\n\nbody", &CommitOptions{Author: defaultSignature()})
+	s.Require().NoError(err)
+	return h
+}
+
+func (s *StashSuite) write(name, content string) {
+	s.Require().NoError(util.WriteFile(s.fs, name, []byte(content), 0644))
+}
+
+func (s *StashSuite) read(name string) string {
+	content, err := util.ReadFile(s.fs, name)
+	s.Require().NoError(err)
+	return string(content)
+}
+
+func (s *StashSuite) stash(opts *StashOptions) plumbing.Hash {
+	if opts == nil {
+		opts = &StashOptions{}
+	}
+	opts.Author = defaultSignature()
+
+	h, err := s.w.Stash(opts)
+	s.Require().NoError(err)
+	return h
+}
+
+func (s *StashSuite) fileContent(t *object.Tree, name string) string {
+	f, err := t.File(name)
+	s.Require().NoError(err)
+	content, err := f.Contents()
+	s.Require().NoError(err)
+	return content
+}
+
+func (s *StashSuite) TestStash() {
+	head, err := s.r.Head()
+	s.Require().NoError(err)
+
+	s.write("bar", "staged\n")
+	_, err = s.w.Add("bar")
+	s.Require().NoError(err)
+	s.write("bar", "staged and modified\n")
+	s.write("foo", "modified\n")
+	s.write("new", "new\n")
+	_, err = s.w.Add("new")
+	s.Require().NoError(err)
+	s.write("untracked", "untracked\n")
+
+	h := s.stash(nil)
+
+	status, err := s.w.Status()
+	s.Require().NoError(err)
+	s.Len(status, 1)
+	s.True(status.IsUntracked("untracked"))
+	s.Equal("a\nb\nc\nd\ne\n", s.read("foo"))
+	s.Equal("bar\n", s.read("bar"))
+	_, err = s.fs.Stat("new")
+	s.True(err != nil)
+
+	stash, err := s.r.CommitObject(h)
+	s.Require().NoError(err)
+	s.Len(stash.ParentHashes, 2)
+	s.Equal(head.Hash(), stash.ParentHashes[0])
+
+	short := head.Hash().String()[:7]
+	s.Equal(fmt.Sprintf("WIP on master: %s commit\n", short), stash.Message)
+
+	tree, err := stash.Tree()
+	s.Require().NoError(err)
+	s.Equal("modified\n", s.fileContent(tree, "foo"))
+	s.Equal("staged and modified\n", s.fileContent(tree, "bar"))
+	s.Equal("new\n", s.fileContent(tree, "new"))
+	_, err = tree.File("untracked")
+	s.ErrorIs(err, object.ErrFileNotFound)
+
+	indexCommit, err := stash.Parent(1)
+	s.Require().NoError(err)
+	s.Equal(fmt.Sprintf("index on master: %s commit\n", short), indexCommit.Message)
+	s.Equal([]plumbing.Hash{head.Hash()}, indexCommit.ParentHashes)
+
+	indexTree, err := indexCommit.Tree()
+	s.Require().NoError(err)
+	s.Equal("a\nb\nc\nd\ne\n", s.fileContent(indexTree, "foo"))
+	s.Equal("staged\n", s.fileContent(indexTree, "bar"))
+
+	ref, err := s.r.Reference(plumbing.Stash, false)
+	s.Require().NoError(err)
+	s.Equal(h, ref.Hash())
+
+	logs, err := s.r.Reflog(plumbing.Stash)
+	s.Require().NoError(err)
+	s.Require().Len(logs, 1)
+	s.True(logs[0].Old.IsZero())
+	s.Equal(h, logs[0].New)
+	s.Equal(fmt.Sprintf("WIP on master: %s commit", short), logs[0].Message)
+
+	list, err := s.w.StashList()
+	s.Require().NoError(err)
+	s.Equal([]StashEntry{{Index: 0, Hash: h, Branch: "master", Message: logs[0].Message}}, list)
+}
+
+func (s *StashSuite) TestStashList() {
+	head, err := s.r.Head()
+	s.Require().NoError(err)
+
+	s.write("foo", "wip\n")
+	wip := s.stash(nil)
+
+	s.write("foo", "message\n")
+	msg := s.stash(&StashOptions{Message: "my changes"})
+
+	s.Require().NoError(s.w.Checkout(&CheckoutOptions{Hash: head.Hash()}))
+	s.write("foo", "detached\n")
+	detached := s.stash(nil)
+
+	list, err := s.w.StashList()
+	s.Require().NoError(err)
+	s.Require().Len(list, 3)
+
+	short := head.Hash().String()[:7]
+	s.Equal(StashEntry{Index: 0, Hash: detached, Branch: "(no branch)", Message: "WIP on (no branch): " + short + " commit"}, list[0])
+	s.Equal("stash@{1}: On master: my changes", list[1].String())
+	s.Equal("master", list[1].Branch)
+	s.Equal(msg, list[1].Hash)
+	s.Equal("stash@{2}: WIP on master: "+short+" commit", list[2].String())
+	s.Equal(wip, list[2].Hash)
+
+	for _, name := range []string{"stash@{1}", "1"} {
+		e, err := s.w.StashEntry(name)
+		s.Require().NoError(err)
+		s.Equal(list[1], e)
+	}
+
+	e, err := s.w.StashEntry("")
+	s.Require().NoError(err)
+	s.Equal(list[0], e)
+
+	_, err = s.w.StashEntry("stash@{3}")
+	s.ErrorIs(err, ErrStashNotFound)
+}
+
+func (s *StashSuite) TestParseStashIndex() {
+	for name, n := range map[string]int{"": 0, "stash@{0}": 0, "stash@{12}": 12, "3": 3} {
+		i, err := ParseStashIndex(name)
+		s.NoError(err, name)
+		s.Equal(n, i, name)
+	}
+
+	for _, name := range []string{"stash@{", "stash@{1", "stash@{-1}", "stash@{a}", "-1", "stash", "refs/stash"} {
+		_, err := ParseStashIndex(name)
+		s.ErrorIs(err, ErrStashNotFound, name)
+	}
+}
+
+func (s *StashSuite) TestStashMessage() {
+	s.write("foo", "modified\n")
+	h := s.stash(&StashOptions{Message: "my changes"})
+
+	stash, err := s.r.CommitObject(h)
+	s.Require().NoError(err)
+	s.Equal("On master: my changes\n", stash.Message)
+}
+
+func (s *StashSuite) TestStashNoLocalChanges() {
+	s.write("untracked", "untracked\n")
+
+	_, err := s.w.Stash(&StashOptions{Author: defaultSignature()})
+	s.ErrorIs(err, ErrNoLocalChanges)
+
+	_, err = s.r.Reference(plumbing.Stash, false)
+	s.ErrorIs(err, plumbing.ErrReferenceNotFound)
+}
+
+func (s *StashSuite) TestStashIncludeUntracked() {
+	s.write("untracked", "untracked\n")
+	s.write("dir/untracked", "nested\n")
+
+	h := s.stash(&StashOptions{IncludeUntracked: true})
+
+	status, err := s.w.Status()
+	s.Require().NoError(err)
+	s.True(status.IsClean())
+	_, err = s.fs.Stat("dir")
+	s.True(err != nil)
+
+	stash, err := s.r.CommitObject(h)
+	s.Require().NoError(err)
+	s.Require().Len(stash.ParentHashes, 3)
+
+	head, err := s.r.Head()
+	s.Require().NoError(err)
+	s.Equal(head.Hash(), stash.ParentHashes[0])
+
+	untracked, err := stash.Parent(2)
+	s.Require().NoError(err)
+	s.Empty(untracked.ParentHashes)
+	s.Contains(untracked.Message, "untracked files on master: ")
+
+	tree, err := untracked.Tree()
+	s.Require().NoError(err)
+	s.Equal("untracked\n", s.fileContent(tree, "untracked"))
+	s.Equal("nested\n", s.fileContent(tree, "dir/untracked"))
+	_, err = tree.File("foo")
+	s.ErrorIs(err, object.ErrFileNotFound)
+
+	res, err := s.w.StashApply(0)
+	s.Require().NoError(err)
+	s.False(res.HasConflicts())
+	s.Equal("untracked\n", s.read("untracked"))
+	s.Equal("nested\n", s.read("dir/untracked"))
+
+	status, err = s.w.Status()
+	s.Require().NoError(err)
+	s.True(status.IsUntracked("untracked"))
+	s.True(status.IsUntracked("dir/untracked"))
+}
+
+func (s *StashSuite) TestStashApplyUntrackedInTheWay() {
+	s.write("untracked", "untracked\n")
+	s.stash(&StashOptions{IncludeUntracked: true})
+
+	s.write("untracked", "other\n")
+	_, err := s.w.StashApply(0)
+	s.ErrorIs(err, ErrWorktreeNotClean)
+	s.Equal("other\n", s.read("untracked"))
+}
+
+func (s *StashSuite) TestStashPop() {
+	s.write("foo", "A\nb\nc\nd\ne\n")
+	s.write("new", "new\n")
+	_, err := s.w.Add("new")
+	s.Require().NoError(err)
+	s.write("bar", "")
+	_, err = s.w.Remove("bar")
+	s.Require().NoError(err)
+	s.stash(nil)
+
+	s.commit(map[string]string{"foo": "a\nb\nc\nd\nE\n"})
+
+	res, err := s.w.StashPop(0)
+	s.Require().NoError(err)
+	s.False(res.HasConflicts())
+
+	s.Equal("A\nb\nc\nd\nE\n", s.read("foo"))
+	s.Equal("new\n", s.read("new"))
+	_, err = s.fs.Stat("bar")
+	s.True(err != nil)
+
+	// The changes are left unstaged, but for the new files.
+	status, err := s.w.Status()
+	s.Require().NoError(err)
+	s.Equal(Unmodified, status.File("foo").Staging)
+	s.Equal(Modified, status.File("foo").Worktree)
+	s.Equal(Added, status.File("new").Staging)
+	s.Equal(Unmodified, status.File("bar").Staging)
+	s.Equal(Deleted, status.File("bar").Worktree)
+
+	list, err := s.w.StashList()
+	s.Require().NoError(err)
+	s.Empty(list)
+
+	_, err = s.r.Reference(plumbing.Stash, false)
+	s.ErrorIs(err, plumbing.ErrReferenceNotFound)
+}
+
+func (s *StashSuite) TestStashPopConflict() {
+	s.write("foo", "A\nb\nc\nd\ne\n")
+	h := s.stash(nil)
+
+	s.commit(map[string]string{"foo": "B\nb\nc\nd\ne\n"})
+
+	res, err := s.w.StashPop(0)
+	s.Require().NoError(err)
+	s.Require().True(res.HasConflicts())
+	s.Equal("foo", res.Conflicts[0].Path)
+
+	s.Equal("<<<<<<< Updated upstream\nB\n=======\nA\n>>>>>>> Stashed changes\nb\nc\nd\ne\n", s.read("foo"))
+
+	status, err := s.w.Status()
+	s.Require().NoError(err)
+	s.Equal(UpdatedButUnmerged, status.File("foo").Staging)
+
+	// The stash is kept when it doesn't apply cleanly.
+	list, err := s.w.StashList()
+	s.Require().NoError(err)
+	s.Require().Len(list, 1)
+	s.Equal(h, list[0].Hash)
+}
+
+func (s *StashSuite) TestStashApplyLocalChanges() {
+	s.write("foo", "A\nb\nc\nd\ne\n")
+	s.stash(nil)
+
+	s.write("foo", "a\nb\nc\nd\nE\n")
+	_, err := s.w.StashApply(0)
+	s.ErrorIs(err, ErrWorktreeNotClean)
+	s.Equal("a\nb\nc\nd\nE\n", s.read("foo"))
+
+	// Local changes in other paths don't get in the way.
+	s.write("foo", "a\nb\nc\nd\ne\n")
+	s.write("bar", "local\n")
+	res, err := s.w.StashApply(0)
+	s.Require().NoError(err)
+	s.False(res.HasConflicts())
+	s.Equal("A\nb\nc\nd\ne\n", s.read("foo"))
+	s.Equal("local\n", s.read("bar"))
+}
+
+func (s *StashSuite) TestStashDrop() {
+	var hashes []plumbing.Hash
+	for i := 0; i < 3; i++ {
+		s.write("foo", fmt.Sprintf("change %d\n", i))
+		hashes = append(hashes, s.stash(&StashOptions{Message: fmt.Sprint(i)}))
+	}
+
+	list, err := s.w.StashList()
+	s.Require().NoError(err)
+	s.Require().Len(list, 3)
+	for i, e := range list {
+		s.Equal(i, e.Index)
+		s.Equal(hashes[2-i], e.Hash)
+		s.Equal(fmt.Sprintf("On master: %d", 2-i), e.Message)
+	}
+
+	s.NoError(s.w.StashDrop(1))
+
+	list, err = s.w.StashList()
+	s.Require().NoError(err)
+	s.Require().Len(list, 2)
+	s.Equal(hashes[2], list[0].Hash)
+	s.Equal(hashes[0], list[1].Hash)
+
+	// The reflog is rewritten so each entry follows the previous one.
+	logs, err := s.r.Reflog(plumbing.Stash)
+	s.Require().NoError(err)
+	s.Equal(hashes[0], logs[0].Old)
+	s.True(logs[1].Old.IsZero())
+
+	s.NoError(s.w.StashDrop(0))
+
+	ref, err := s.r.Reference(plumbing.Stash, false)
+	s.Require().NoError(err)
+	s.Equal(hashes[0], ref.Hash())
+
+	s.ErrorIs(s.w.StashDrop(1), ErrStashNotFound)
+	s.NoError(s.w.StashDrop(0))
+
+	_, err = s.r.Reference(plumbing.Stash, false)
+	s.ErrorIs(err, plumbing.ErrReferenceNotFound)
+
+	_, err = s.w.StashApply(0)
+	s.ErrorIs(err, ErrStashNotFound)
+}
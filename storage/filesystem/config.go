@@ -1,15 +1,27 @@
 package filesystem
 
 import (
+	"io"
 	"os"
+	"sync"
 
 	"github.com/go-git/go-git/v6/config"
 	"github.com/go-git/go-git/v6/storage/filesystem/dotgit"
 	"github.com/go-git/go-git/v6/utils/ioutil"
 )
 
+const (
+	configFilePath = "config"
+	// configLockPath is the lock file of the config, created while the
+	// config is updated, as git does.
+	configLockPath = configFilePath + ".lock"
+)
+
 type ConfigStorage struct {
 	dir *dotgit.DotGit
+	// mu serializes the updates of the config in this process, the lock
+	// file only protecting them from other processes.
+	mu sync.Mutex
 }
 
 func (c *ConfigStorage) Config() (conf *config.Config, err error) {
@@ -46,3 +58,58 @@ func (c *ConfigStorage) SetConfig(cfg *config.Config) (err error) {
 	_, err = f.Write(b)
 	return err
 }
+
+// UpdateConfig honors the config.ConfigUpdater interface. The config is locked
+// as git does: the config.lock file is created, failing with
+// config.ErrConfigLocked if it exists, and the new config is written to it
+// before it's renamed to config.
+func (c *ConfigStorage) UpdateConfig(update func(*config.Config) error) (err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	fs := c.dir.Fs()
+	f, err := fs.OpenFile(configLockPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o666)
+	if err != nil {
+		if os.IsExist(err) {
+			return config.ErrConfigLocked
+		}
+
+		return err
+	}
+
+	err = c.writeUpdatedConfig(f, update)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err != nil {
+		_ = fs.Remove(configLockPath)
+		return err
+	}
+
+	return fs.Rename(configLockPath, configFilePath)
+}
+
+// writeUpdatedConfig writes to w the config, once updated.
+func (c *ConfigStorage) writeUpdatedConfig(w io.Writer, update func(*config.Config) error) error {
+	cfg, err := c.Config()
+	if err != nil {
+		return err
+	}
+
+	if err := update(cfg); err != nil {
+		return err
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+
+	b, err := cfg.Marshal()
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(b)
+	return err
+}
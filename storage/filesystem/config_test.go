@@ -1,7 +1,9 @@
 package filesystem
 
 import (
+	"errors"
 	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/go-git/go-billy/v5/osfs"
@@ -33,7 +35,7 @@ func (s *ConfigSuite) SetupTest() {
 
 func (s *ConfigSuite) TestRemotes() {
 	dir := dotgit.New(fixtures.Basic().ByTag(".git").One().DotGit())
-	storer := &ConfigStorage{dir}
+	storer := &ConfigStorage{dir: dir}
 
 	cfg, err := storer.Config()
 	s.NoError(err)
@@ -49,3 +51,45 @@ func (s *ConfigSuite) TestRemotes() {
 func (s *ConfigSuite) TearDownTest() {
 	defer os.RemoveAll(s.path)
 }
+
+func (s *ConfigSuite) TestUpdateConfig() {
+	storer := &ConfigStorage{dir: s.dir}
+
+	err := storer.UpdateConfig(func(cfg *config.Config) error {
+		cfg.Remotes["origin"] = &config.RemoteConfig{Name: "origin", URLs: []string{"https://example.com/repo"}}
+		return nil
+	})
+	s.Require().NoError(err)
+
+	cfg, err := storer.Config()
+	s.Require().NoError(err)
+	s.Equal([]string{"https://example.com/repo"}, cfg.Remotes["origin"].URLs)
+
+	_, err = os.Stat(filepath.Join(s.path, "config.lock"))
+	s.True(os.IsNotExist(err))
+
+	// A failed update leaves the config untouched.
+	err = storer.UpdateConfig(func(cfg *config.Config) error {
+		delete(cfg.Remotes, "origin")
+		return errors.New("foo")
+	})
+	s.ErrorContains(err, "foo")
+
+	cfg, err = storer.Config()
+	s.Require().NoError(err)
+	s.Contains(cfg.Remotes, "origin")
+
+	_, err = os.Stat(filepath.Join(s.path, "config.lock"))
+	s.True(os.IsNotExist(err))
+}
+
+func (s *ConfigSuite) TestUpdateConfigLocked() {
+	storer := &ConfigStorage{dir: s.dir}
+	s.Require().NoError(os.WriteFile(filepath.Join(s.path, "config.lock"), nil, 0o666))
+
+	err := storer.UpdateConfig(func(*config.Config) error {
+		s.Fail("the config shouldn't be updated")
+		return nil
+	})
+	s.ErrorIs(err, config.ErrConfigLocked)
+}
@@ -14,9 +14,13 @@ import (
 	"runtime"
 	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/go-git/go-git/v6/plumbing"
+	format "github.com/go-git/go-git/v6/plumbing/format/config"
+	"github.com/go-git/go-git/v6/plumbing/format/objfile"
+	"github.com/go-git/go-git/v6/plumbing/storer"
 	"github.com/go-git/go-git/v6/storage"
 	"github.com/go-git/go-git/v6/utils/ioutil"
 
@@ -30,6 +34,7 @@ const (
 	configPath     = "config"
 	indexPath      = "index"
 	shallowPath    = "shallow"
+	fetchHeadPath  = "FETCH_HEAD"
 	modulePath     = "modules"
 	objectsPath    = "objects"
 	packPath       = "pack"
@@ -41,9 +46,14 @@ const (
 	logsPath       = "logs"
 	worktreesPath  = "worktrees"
 	alternatesPath = "alternates"
+	midxPath       = "multi-pack-index"
 
 	tmpPackedRefsPrefix = "._packed-refs"
 
+	// lockSuffix is the suffix of the lock file of a reference, created
+	// while the reference is updated, as git does.
+	lockSuffix = ".lock"
+
 	packPrefix = "pack-"
 	packExt    = ".pack"
 	idxExt     = ".idx"
@@ -74,6 +84,10 @@ var (
 	// ErrEmptyRefFile is returned when a reference file is attempted to be read,
 	// but the file is empty
 	ErrEmptyRefFile = errors.New("ref file is empty")
+	// ErrReferenceLocked is returned by UpdateRefs when the lock file of a
+	// reference already exists, the reference being updated by another
+	// process.
+	ErrReferenceLocked = errors.New("reference is locked")
 )
 
 // Options holds configuration for the storage.
@@ -88,6 +102,9 @@ type Options struct {
 	// If none is provided, it falls back to using the underlying instance used for
 	// DotGit.
 	AlternatesFS billy.Filesystem
+	// ObjectFormat is the format used to hash the objects written, SHA1 is
+	// used by default.
+	ObjectFormat format.ObjectFormat
 }
 
 // The DotGit type represents a local git repository on disk. This
@@ -106,6 +123,10 @@ type DotGit struct {
 	packMap    map[plumbing.Hash]struct{}
 
 	files map[plumbing.Hash]billy.File
+
+	// packedRefs caches the parsed packed-refs file.
+	packedRefsMu sync.Mutex
+	packedRefs   *packedRefs
 }
 
 // New returns a DotGit value ready to be used. The path argument must
@@ -124,6 +145,11 @@ func NewWithOptions(fs billy.Filesystem, o Options) *DotGit {
 	}
 }
 
+// SetObjectFormat sets the format used to hash the objects written.
+func (d *DotGit) SetObjectFormat(f format.ObjectFormat) {
+	d.options.ObjectFormat = f
+}
+
 // Initialize creates all the folder scaffolding.
 func (d *DotGit) Initialize() error {
 	mustExists := []string{
@@ -212,6 +238,71 @@ func (d *DotGit) Shallow() (billy.File, error) {
 	return f, nil
 }
 
+// RemoveShallow removes the shallow file, if any.
+func (d *DotGit) RemoveShallow() error {
+	err := d.fs.Remove(shallowPath)
+	if os.IsNotExist(err) {
+		return nil
+	}
+
+	return err
+}
+
+// FetchHeadWriter returns a file pointer for write to the FETCH_HEAD file.
+func (d *DotGit) FetchHeadWriter() (billy.File, error) {
+	return d.fs.Create(fetchHeadPath)
+}
+
+// FetchHead returns a file pointer for read to the FETCH_HEAD file, nil if
+// the file doesn't exist.
+func (d *DotGit) FetchHead() (billy.File, error) {
+	f, err := d.fs.Open(fetchHeadPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	return f, nil
+}
+
+// ReflogWriter returns a file pointer for appending to the log of the given
+// reference.
+func (d *DotGit) ReflogWriter(name plumbing.ReferenceName) (billy.File, error) {
+	return d.fs.OpenFile(d.reflogPath(name), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o666)
+}
+
+// Reflog returns a file pointer for read to the log of the given reference,
+// nil if the reference has no log.
+func (d *DotGit) Reflog(name plumbing.ReferenceName) (billy.File, error) {
+	f, err := d.fs.Open(d.reflogPath(name))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	return f, nil
+}
+
+// RemoveReflog removes the log of the given reference, if any.
+func (d *DotGit) RemoveReflog(name plumbing.ReferenceName) error {
+	err := d.fs.Remove(d.reflogPath(name))
+	if os.IsNotExist(err) {
+		return nil
+	}
+
+	return err
+}
+
+func (d *DotGit) reflogPath(name plumbing.ReferenceName) string {
+	return d.fs.Join(logsPath, name.String())
+}
+
 // NewObjectPack return a writer for a new packfile, it saves the packfile to
 // disk and also generates and save the index for the given packfile.
 func (d *DotGit) NewObjectPack() (*PackWriter, error) {
@@ -320,6 +411,23 @@ func (d *DotGit) ObjectPackIdx(hash plumbing.Hash) (billy.File, error) {
 	return d.objectPackOpen(hash, `idx`)
 }
 
+// ObjectPackBitmap returns a fs.File of the reachability bitmap of a given
+// packfile, or ErrPackfileNotFound if it doesn't have one.
+func (d *DotGit) ObjectPackBitmap(hash plumbing.Hash) (billy.File, error) {
+	err := d.hasPack(hash)
+	if err != nil {
+		return nil, err
+	}
+
+	return d.objectPackOpen(hash, `bitmap`)
+}
+
+// ObjectPackMultiIndex returns a fs.File of the multi-pack-index, indexing
+// the objects of several packfiles, or os.ErrNotExist if there isn't one.
+func (d *DotGit) ObjectPackMultiIndex() (billy.File, error) {
+	return d.fs.Open(d.fs.Join(objectsPath, packPath, midxPath))
+}
+
 func (d *DotGit) DeleteOldObjectPackAndIndex(hash plumbing.Hash, t time.Time) error {
 	d.cleanPackList()
 
@@ -338,14 +446,21 @@ func (d *DotGit) DeleteOldObjectPackAndIndex(hash plumbing.Hash, t time.Time) er
 	if err != nil {
 		return err
 	}
+
+	err = d.fs.Remove(d.objectPackPath(hash, `bitmap`))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
 	return d.fs.Remove(d.objectPackPath(hash, `idx`))
 }
 
-// NewObject return a writer for a new object file.
-func (d *DotGit) NewObject() (*ObjectWriter, error) {
+// NewObject return a writer for a new object file, configured with the given
+// options, such as objfile.WithCompressionLevel.
+func (d *DotGit) NewObject(opts ...objfile.WriterOption) (*ObjectWriter, error) {
 	d.cleanObjectList()
 
-	return newObjectWriter(d.fs)
+	return newObjectWriter(d.fs, d.options.ObjectFormat, opts...)
 }
 
 // ObjectsWithPrefix returns the hashes of objects that have the given prefix.
@@ -744,56 +859,16 @@ func (d *DotGit) Ref(name plumbing.ReferenceName) (*plumbing.Reference, error) {
 	return d.packedRef(name)
 }
 
-func (d *DotGit) findPackedRefsInFile(f billy.File, recv refsRecv) error {
-	s := bufio.NewScanner(f)
-	for s.Scan() {
-		ref, err := d.processLine(s.Text())
-		if err != nil {
-			return err
-		}
-
-		if !recv(ref) {
-			// skip parse
-			return nil
-		}
-	}
-	if err := s.Err(); err != nil {
-		return err
-	}
-	return nil
-}
-
-// refsRecv: returning true means that the reference continues to be resolved, otherwise it is stopped, which will speed up the lookup of a single reference.
-type refsRecv func(*plumbing.Reference) bool
-
-func (d *DotGit) findPackedRefs(recv refsRecv) error {
-	f, err := d.fs.Open(packedRefsPath)
-	if err != nil {
-		if os.IsNotExist(err) {
-			return nil
-		}
-		return err
-	}
-
-	defer ioutil.CheckClose(f, &err)
-	return d.findPackedRefsInFile(f, recv)
-}
-
 func (d *DotGit) packedRef(name plumbing.ReferenceName) (*plumbing.Reference, error) {
-	var ref *plumbing.Reference
-	if err := d.findPackedRefs(func(r *plumbing.Reference) bool {
-		if r != nil && r.Name() == name {
-			ref = r
-			// ref found
-			return false
-		}
-		return true
-	}); err != nil {
+	p, err := d.loadPackedRefs()
+	if err != nil {
 		return nil, err
 	}
-	if ref != nil {
+
+	if ref := p.ref(name); ref != nil {
 		return ref, nil
 	}
+
 	return nil, plumbing.ErrReferenceNotFound
 }
 
@@ -813,22 +888,23 @@ func (d *DotGit) RemoveRef(name plumbing.ReferenceName) error {
 	return d.rewritePackedRefsWithoutRef(name)
 }
 
-func refsRecvFunc(refs *[]*plumbing.Reference, seen map[plumbing.ReferenceName]bool) refsRecv {
-	return func(r *plumbing.Reference) bool {
-		if r != nil && !seen[r.Name()] {
-			*refs = append(*refs, r)
-			seen[r.Name()] = true
-		}
-		return true
+func (d *DotGit) addRefsFromPackedRefs(refs *[]*plumbing.Reference, seen map[plumbing.ReferenceName]bool) error {
+	p, err := d.loadPackedRefs()
+	if err != nil || p == nil {
+		return err
 	}
-}
 
-func (d *DotGit) addRefsFromPackedRefs(refs *[]*plumbing.Reference, seen map[plumbing.ReferenceName]bool) (err error) {
-	return d.findPackedRefs(refsRecvFunc(refs, seen))
+	addPackedRefs(refs, p, seen)
+	return nil
 }
 
-func (d *DotGit) addRefsFromPackedRefsFile(refs *[]*plumbing.Reference, f billy.File, seen map[plumbing.ReferenceName]bool) (err error) {
-	return d.findPackedRefsInFile(f, refsRecvFunc(refs, seen))
+func addPackedRefs(refs *[]*plumbing.Reference, p *packedRefs, seen map[plumbing.ReferenceName]bool) {
+	for _, r := range p.refs {
+		if !seen[r.Name()] {
+			*refs = append(*refs, r)
+			seen[r.Name()] = true
+		}
+	}
 }
 
 func (d *DotGit) openAndLockPackedRefs(doCreate bool) (
@@ -905,7 +981,7 @@ func (d *DotGit) rewritePackedRefsWithoutRef(name plumbing.ReferenceName) (err e
 
 	// Creating the temp file in the same directory as the target file
 	// improves our chances for rename operation to be atomic.
-	tmp, err := d.fs.TempFile("", tmpPackedRefsPrefix)
+	tmp, err := d.fs.TempFile(".", tmpPackedRefsPrefix)
 	if err != nil {
 		return err
 	}
@@ -916,16 +992,22 @@ func (d *DotGit) rewritePackedRefsWithoutRef(name plumbing.ReferenceName) (err e
 	}()
 
 	s := bufio.NewScanner(pr)
-	found := false
+	found, skipPeeled := false, false
 	for s.Scan() {
 		line := s.Text()
+		if skipPeeled && strings.HasPrefix(line, "^") {
+			// The peeled target of the removed reference.
+			continue
+		}
+
 		ref, err := d.processLine(line)
 		if err != nil {
 			return err
 		}
 
+		skipPeeled = false
 		if ref != nil && ref.Name() == name {
-			found = true
+			found, skipPeeled = true, true
 			continue
 		}
 
@@ -982,6 +1064,10 @@ func (d *DotGit) walkReferencesTree(refs *[]*plumbing.Reference, relPath []strin
 	}
 
 	for _, f := range files {
+		if strings.HasSuffix(f.Name(), lockSuffix) {
+			continue
+		}
+
 		newRelPath := append(append([]string(nil), relPath...), f.Name())
 		if f.IsDir() {
 			if err = d.walkReferencesTree(refs, newRelPath, seen); err != nil {
@@ -1075,7 +1161,7 @@ func (d *DotGit) PackRefs() (err error) {
 	}
 	defer ioutil.CheckClose(f, &err)
 
-	// Gather all refs using addRefsFromRefDir and addRefsFromPackedRefs.
+	// Gather all refs from the refs directory and the packed-refs file.
 	var refs []*plumbing.Reference
 	seen := make(map[plumbing.ReferenceName]bool)
 	if err = d.addRefsFromRefDir(&refs, seen); err != nil {
@@ -1086,12 +1172,14 @@ func (d *DotGit) PackRefs() (err error) {
 		return nil
 	}
 	numLooseRefs := len(refs)
-	if err = d.addRefsFromPackedRefsFile(&refs, f, seen); err != nil {
+	packed, err := d.parsePackedRefs(f)
+	if err != nil {
 		return err
 	}
+	addPackedRefs(&refs, packed, seen)
 
 	// Write them all to a new temp packed-refs file.
-	tmp, err := d.fs.TempFile("", tmpPackedRefsPrefix)
+	tmp, err := d.fs.TempFile(".", tmpPackedRefsPrefix)
 	if err != nil {
 		return err
 	}
@@ -1101,15 +1189,7 @@ func (d *DotGit) PackRefs() (err error) {
 		_ = d.fs.Remove(tmpName) // don't check err, we might have renamed it
 	}()
 
-	w := bufio.NewWriter(tmp)
-	for _, ref := range refs {
-		_, err = w.WriteString(ref.String() + "\n")
-		if err != nil {
-			return err
-		}
-	}
-	err = w.Flush()
-	if err != nil {
+	if err = writePackedRefs(tmp, refs, packed); err != nil {
 		return err
 	}
 
@@ -1132,6 +1212,150 @@ func (d *DotGit) PackRefs() (err error) {
 	return nil
 }
 
+// UpdateRefs applies the given updates of references atomically. As git does,
+// each reference is locked with its lock file, failing with
+// ErrReferenceLocked if it's already locked, and then the packed-refs file.
+// While they are locked, the preconditions of the updates are checked and the
+// packed-refs file is rewritten with the new values of the references, to a
+// temporary file renamed over it. The loose files of the references are
+// removed last, still holding the locks.
+func (d *DotGit) UpdateRefs(updates []*storer.ReferenceUpdate) (err error) {
+	for _, u := range updates {
+		unlock, err := d.lockRef(u.Name)
+		if err != nil {
+			return err
+		}
+
+		defer unlock()
+	}
+
+	f, err := d.openAndLockPackedRefs(true)
+	if err != nil {
+		return err
+	}
+	defer ioutil.CheckClose(f, &err)
+
+	packed, err := d.parsePackedRefs(f)
+	if err != nil {
+		return err
+	}
+
+	updated := make(map[plumbing.ReferenceName]bool, len(updates))
+	for _, u := range updates {
+		current, err := d.readReferenceFile(".", u.Name.String())
+		if os.IsNotExist(err) {
+			current, err = packed.ref(u.Name), nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if !u.Check(current) {
+			return storage.ErrReferenceHasChanged
+		}
+
+		updated[u.Name] = true
+	}
+
+	var refs []*plumbing.Reference
+	for _, ref := range packed.refs {
+		if !updated[ref.Name()] {
+			refs = append(refs, ref)
+		}
+	}
+
+	for _, u := range updates {
+		if u.New != nil {
+			refs = append(refs, u.New)
+		}
+	}
+
+	tmp, err := d.fs.TempFile(".", tmpPackedRefsPrefix)
+	if err != nil {
+		return err
+	}
+	tmpName := tmp.Name()
+	defer func() {
+		ioutil.CheckClose(tmp, &err)
+		_ = d.fs.Remove(tmpName) // don't check err, we might have renamed it
+	}()
+
+	if err = writePackedRefs(tmp, refs, packed); err != nil {
+		return err
+	}
+
+	if err = d.rewritePackedRefsWhileLocked(tmp, f); err != nil {
+		return err
+	}
+
+	for _, u := range updates {
+		err = d.fs.Remove(d.fs.Join(".", u.Name.String()))
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+
+		if u.New == nil {
+			if err = d.RemoveReflog(u.Name); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// lockRef creates the lock file of the named reference, failing with
+// ErrReferenceLocked if it exists. The returned function removes it.
+func (d *DotGit) lockRef(name plumbing.ReferenceName) (func(), error) {
+	path := d.fs.Join(".", name.String()) + lockSuffix
+	f, err := d.fs.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o666)
+	if err != nil {
+		if os.IsExist(err) {
+			return nil, fmt.Errorf("%w: %s", ErrReferenceLocked, name)
+		}
+
+		return nil, err
+	}
+
+	if err := f.Close(); err != nil {
+		_ = d.fs.Remove(path)
+		return nil, err
+	}
+
+	return func() { _ = d.fs.Remove(path) }, nil
+}
+
+// writePackedRefs writes the given references as a packed-refs file. The
+// references are written sorted. The peeled targets of the ones already in
+// packed are kept, but the ones of the others aren't known, so the file isn't
+// declared as peeled.
+func writePackedRefs(wr io.Writer, refs []*plumbing.Reference, packed *packedRefs) error {
+	sorted := append([]*plumbing.Reference(nil), refs...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Name() < sorted[j].Name()
+	})
+
+	w := bufio.NewWriter(wr)
+	if _, err := w.WriteString(packedRefsHeader + " sorted\n"); err != nil {
+		return err
+	}
+
+	for _, ref := range sorted {
+		if _, err := w.WriteString(ref.String() + "\n"); err != nil {
+			return err
+		}
+
+		if h, ok := packed.peeled[ref.Name()]; ok && packed.ref(ref.Name()) == ref {
+			if _, err := w.WriteString("^" + h.String() + "\n"); err != nil {
+				return err
+			}
+		}
+	}
+
+	return w.Flush()
+}
+
 // Module return a billy.Filesystem pointing to the module folder
 func (d *DotGit) Module(name string) (billy.Filesystem, error) {
 	return d.fs.Chroot(d.fs.Join(modulePath, name))
@@ -1205,6 +1429,8 @@ func (d *DotGit) Alternates() ([]*DotGit, error) {
 					return nil, fmt.Errorf("cannot make path %q relative: %w", path, err)
 				}
 			}
+		} else if rel, ok := d.relativeAlternate(fs, path); ok {
+			path = rel
 		} else {
 			// By Git conventions, relative paths should be based on the object database (.git/objects/info)
 			// location as per: https://www.kernel.org/pub/software/scm/git/docs/gitrepository-layout.html
@@ -1223,7 +1449,13 @@ func (d *DotGit) Alternates() ([]*DotGit, error) {
 		if err != nil {
 			return nil, fmt.Errorf("cannot chroot %q: %w", path, err)
 		}
-		alternates = append(alternates, New(afs))
+
+		// The alternates of the alternate object directories are read from
+		// the same filesystem.
+		alternates = append(alternates, NewWithOptions(afs, Options{
+			AlternatesFS: fs,
+			ObjectFormat: d.options.ObjectFormat,
+		}))
 	}
 
 	if err = scanner.Err(); err != nil {
@@ -1233,6 +1465,23 @@ func (d *DotGit) Alternates() ([]*DotGit, error) {
 	return alternates, nil
 }
 
+// relativeAlternate resolves a relative alternate path from the object
+// directory, as git does, when the resulting path is inside the alternates
+// filesystem.
+func (d *DotGit) relativeAlternate(fs billy.Filesystem, path string) (string, bool) {
+	base, err := filepath.Rel(fs.Root(), d.fs.Root())
+	if err != nil {
+		return "", false
+	}
+
+	path = filepath.Join(base, objectsPath, path)
+	if path == ".." || strings.HasPrefix(path, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+
+	return path, true
+}
+
 // Fs returns the underlying filesystem of the DotGit folder.
 func (d *DotGit) Fs() billy.Filesystem {
 	return d.fs
@@ -19,6 +19,8 @@ func (d *DotGit) openAndLockPackedRefsMode() int {
 
 func (d *DotGit) rewritePackedRefsWhileLocked(
 	tmp billy.File, pr billy.File) error {
+	defer d.invalidatePackedRefs()
+
 	// Try plain rename. If we aren't using the bare Windows filesystem as the
 	// storage layer, we might be able to get away with a rename over a locked
 	// file.
@@ -9,6 +9,7 @@ import (
 	"regexp"
 	"runtime"
 	"strings"
+	"sync"
 	"testing"
 
 	"github.com/go-git/go-billy/v5"
@@ -17,8 +18,10 @@ import (
 	"github.com/go-git/go-billy/v5/util"
 	fixtures "github.com/go-git/go-git-fixtures/v5"
 	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/go-git/go-git/v6/plumbing/storer"
 	"github.com/go-git/go-git/v6/storage"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
 )
 
@@ -331,6 +334,302 @@ func (s *SuiteDotGit) TestRemoveRefInvalidPackedRefs2() {
 	s.Equal(string(after), brokenContent)
 }
 
+const (
+	packedTagHash    = "b742a2a9fa0afcfa9a6fad080980fbc26b007c69"
+	packedCommitHash = "f7b877701fbf855b44c0a9e86f3fdce2c298b07f"
+	packedBranchHash = "6ecf0ef2c2dffb796033e5a02219af86ec6584e5"
+)
+
+func (s *SuiteDotGit) writePackedRefs(fs billy.Filesystem, header string) {
+	content := packedBranchHash + " refs/heads/master\n" +
+		packedTagHash + " refs/tags/annotated\n" +
+		"^" + packedCommitHash + "\n" +
+		packedBranchHash + " refs/tags/lightweight\n"
+	if header != "" {
+		content = header + "\n" + content
+	}
+
+	s.Require().NoError(util.WriteFile(fs, packedRefsPath, []byte(content), 0644))
+}
+
+func (s *SuiteDotGit) TestPeeledRef() {
+	fs := s.EmptyFS()
+	dir := New(fs)
+	s.writePackedRefs(fs, "# pack-refs with: peeled fully-peeled sorted ")
+
+	h, ok, err := dir.PeeledRef("refs/tags/annotated")
+	s.NoError(err)
+	s.True(ok)
+	s.Equal(packedCommitHash, h.String())
+
+	// With fully-peeled, the references without a peeled line don't point
+	// to a tag.
+	h, ok, err = dir.PeeledRef("refs/heads/master")
+	s.NoError(err)
+	s.True(ok)
+	s.Equal(packedBranchHash, h.String())
+
+	_, ok, err = dir.PeeledRef("refs/heads/missing")
+	s.NoError(err)
+	s.False(ok)
+
+	// An updated loose reference shadows the packed one.
+	s.NoError(dir.SetRef(plumbing.NewReferenceFromStrings(
+		"refs/tags/annotated", packedBranchHash,
+	), nil))
+	_, ok, err = dir.PeeledRef("refs/tags/annotated")
+	s.NoError(err)
+	s.False(ok)
+}
+
+func (s *SuiteDotGit) TestPeeledRefTraits() {
+	for _, c := range []struct {
+		header              string
+		branch, lightweight bool
+	}{
+		{"# pack-refs with: peeled fully-peeled sorted ", true, true},
+		{"# pack-refs with: peeled ", false, true},
+		{"", false, false},
+	} {
+		fs := s.EmptyFS()
+		dir := New(fs)
+		s.writePackedRefs(fs, c.header)
+
+		h, ok, err := dir.PeeledRef("refs/tags/annotated")
+		s.NoError(err)
+		s.True(ok, c.header)
+		s.Equal(packedCommitHash, h.String())
+
+		_, ok, err = dir.PeeledRef("refs/heads/master")
+		s.NoError(err)
+		s.Equal(c.branch, ok, c.header)
+
+		_, ok, err = dir.PeeledRef("refs/tags/lightweight")
+		s.NoError(err)
+		s.Equal(c.lightweight, ok, c.header)
+	}
+}
+
+func (s *SuiteDotGit) TestPackedRefsUnsorted() {
+	fs := s.EmptyFS()
+	dir := New(fs)
+
+	content := packedBranchHash + " refs/tags/v1\n" +
+		packedTagHash + " refs/heads/master\n"
+	s.Require().NoError(util.WriteFile(fs, packedRefsPath, []byte(content), 0644))
+
+	for name, hash := range map[string]string{
+		"refs/tags/v1":      packedBranchHash,
+		"refs/heads/master": packedTagHash,
+	} {
+		ref, err := dir.Ref(plumbing.ReferenceName(name))
+		s.NoError(err)
+		s.Equal(hash, ref.Hash().String())
+	}
+
+	refs, err := dir.Refs()
+	s.NoError(err)
+	s.Len(refs, 2)
+	s.Equal(plumbing.ReferenceName("refs/tags/v1"), refs[0].Name())
+}
+
+func (s *SuiteDotGit) TestPackedRefsBadPeeledLine() {
+	fs := s.EmptyFS()
+	dir := New(fs)
+
+	content := "# pack-refs with: peeled \n^" + packedCommitHash + "\n"
+	s.Require().NoError(util.WriteFile(fs, packedRefsPath, []byte(content), 0644))
+
+	_, err := dir.Ref("refs/heads/master")
+	s.ErrorIs(err, ErrPackedRefsBadFormat)
+}
+
+func (s *SuiteDotGit) TestPackedRefsReloaded() {
+	fs := s.EmptyFS()
+	dir := New(fs)
+	s.writePackedRefs(fs, "")
+
+	ref, err := dir.Ref("refs/heads/master")
+	s.NoError(err)
+	s.Equal(packedBranchHash, ref.Hash().String())
+
+	// The file is parsed again when modified externally.
+	content := packedTagHash + " refs/heads/master\n"
+	s.Require().NoError(util.WriteFile(fs, packedRefsPath, []byte(content), 0644))
+
+	ref, err = dir.Ref("refs/heads/master")
+	s.NoError(err)
+	s.Equal(packedTagHash, ref.Hash().String())
+
+	_, err = dir.Ref("refs/tags/annotated")
+	s.ErrorIs(err, plumbing.ErrReferenceNotFound)
+
+	s.NoError(fs.Remove(packedRefsPath))
+	_, err = dir.Ref("refs/heads/master")
+	s.ErrorIs(err, plumbing.ErrReferenceNotFound)
+}
+
+func (s *SuiteDotGit) TestPackedRefsConcurrentReads() {
+	fs := fixtures.Basic().ByTag(".git").One().DotGit()
+	dir := New(fs)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 10; j++ {
+				ref, err := dir.Ref("refs/remotes/origin/branch")
+				assert.NoError(s.T(), err)
+				assert.Equal(s.T(), "e8d3ffab552895c19b9fcf7aa264d277cde33881", ref.Hash().String())
+
+				_, err = dir.Refs()
+				assert.NoError(s.T(), err)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func (s *SuiteDotGit) TestRemoveRefFromPackedRefsWithPeeled() {
+	fs := s.EmptyFS()
+	dir := New(fs)
+	s.writePackedRefs(fs, "# pack-refs with: peeled fully-peeled sorted ")
+
+	s.NoError(dir.RemoveRef("refs/tags/annotated"))
+
+	b, err := util.ReadFile(fs, packedRefsPath)
+	s.NoError(err)
+	s.Equal(""+
+		"# pack-refs with: peeled fully-peeled sorted \n"+
+		packedBranchHash+" refs/heads/master\n"+
+		packedBranchHash+" refs/tags/lightweight\n",
+		string(b))
+}
+
+func (s *SuiteDotGit) TestPackRefsKeepsPeeled() {
+	fs := s.EmptyFS()
+	dir := New(fs)
+	s.writePackedRefs(fs, "# pack-refs with: peeled fully-peeled sorted ")
+
+	s.NoError(dir.SetRef(plumbing.NewReferenceFromStrings(
+		"refs/heads/feature", packedBranchHash,
+	), nil))
+	s.NoError(dir.PackRefs())
+
+	b, err := util.ReadFile(fs, packedRefsPath)
+	s.NoError(err)
+	s.Equal(""+
+		"# pack-refs with: sorted\n"+
+		packedBranchHash+" refs/heads/feature\n"+
+		packedBranchHash+" refs/heads/master\n"+
+		packedTagHash+" refs/tags/annotated\n"+
+		"^"+packedCommitHash+"\n"+
+		packedBranchHash+" refs/tags/lightweight\n",
+		string(b))
+
+	h, ok, err := dir.PeeledRef("refs/tags/annotated")
+	s.NoError(err)
+	s.True(ok)
+	s.Equal(packedCommitHash, h.String())
+
+	_, ok, err = dir.PeeledRef("refs/heads/feature")
+	s.NoError(err)
+	s.False(ok)
+}
+
+func (s *SuiteDotGit) TestUpdateRefs() {
+	fs := s.EmptyFS()
+	dir := New(fs)
+	s.writePackedRefs(fs, "# pack-refs with: peeled fully-peeled sorted ")
+
+	s.NoError(dir.SetRef(plumbing.NewReferenceFromStrings(
+		"refs/heads/feature", packedBranchHash,
+	), nil))
+
+	master := plumbing.NewReferenceFromStrings("refs/heads/master", packedBranchHash)
+	err := dir.UpdateRefs([]*storer.ReferenceUpdate{{
+		Name: "refs/heads/feature",
+		New:  plumbing.NewReferenceFromStrings("refs/heads/feature", packedCommitHash),
+		Old:  plumbing.NewReferenceFromStrings("refs/heads/feature", packedBranchHash),
+	}, {
+		Name: master.Name(),
+		Old:  master,
+	}})
+	s.Require().NoError(err)
+
+	b, err := util.ReadFile(fs, packedRefsPath)
+	s.NoError(err)
+	s.Equal(""+
+		"# pack-refs with: sorted\n"+
+		packedCommitHash+" refs/heads/feature\n"+
+		packedTagHash+" refs/tags/annotated\n"+
+		"^"+packedCommitHash+"\n"+
+		packedBranchHash+" refs/tags/lightweight\n",
+		string(b))
+
+	looseCount, err := dir.CountLooseRefs()
+	s.NoError(err)
+	s.Equal(0, looseCount)
+}
+
+func (s *SuiteDotGit) TestUpdateRefsPreconditionFails() {
+	fs := s.EmptyFS()
+	dir := New(fs)
+	s.writePackedRefs(fs, "# pack-refs with: peeled fully-peeled sorted ")
+
+	before, err := util.ReadFile(fs, packedRefsPath)
+	s.NoError(err)
+
+	err = dir.UpdateRefs([]*storer.ReferenceUpdate{{
+		Name: "refs/heads/feature",
+		New:  plumbing.NewReferenceFromStrings("refs/heads/feature", packedCommitHash),
+	}, {
+		Name: "refs/heads/master",
+		Old:  plumbing.NewReferenceFromStrings("refs/heads/master", packedCommitHash),
+	}})
+	s.ErrorIs(err, storage.ErrReferenceHasChanged)
+
+	after, err := util.ReadFile(fs, packedRefsPath)
+	s.NoError(err)
+	s.Equal(string(before), string(after))
+}
+
+func (s *SuiteDotGit) TestUpdateRefsLocked() {
+	fs := s.EmptyFS()
+	dir := New(fs)
+	s.writePackedRefs(fs, "# pack-refs with: peeled fully-peeled sorted ")
+
+	before, err := util.ReadFile(fs, packedRefsPath)
+	s.NoError(err)
+
+	s.Require().NoError(util.WriteFile(fs, "refs/heads/master.lock", nil, 0o666))
+
+	err = dir.UpdateRefs([]*storer.ReferenceUpdate{{
+		Name: "refs/heads/feature",
+		New:  plumbing.NewReferenceFromStrings("refs/heads/feature", packedCommitHash),
+	}, {
+		Name: "refs/heads/master",
+		New:  plumbing.NewReferenceFromStrings("refs/heads/master", packedCommitHash),
+	}})
+	s.ErrorIs(err, ErrReferenceLocked)
+
+	after, err := util.ReadFile(fs, packedRefsPath)
+	s.NoError(err)
+	s.Equal(string(before), string(after))
+
+	// The lock files of the transaction are removed, not the other ones.
+	_, err = fs.Stat("refs/heads/feature.lock")
+	s.True(os.IsNotExist(err))
+	_, err = fs.Stat("refs/heads/master.lock")
+	s.NoError(err)
+
+	// Nor are they listed as references.
+	refs, err := dir.Refs()
+	s.NoError(err)
+	s.Nil(findReference(refs, "refs/heads/master.lock"))
+}
+
 func (s *SuiteDotGit) TestRefsFromHEADFile() {
 	fs := fixtures.Basic().ByTag(".git").One().DotGit()
 	dir := New(fs)
@@ -931,6 +1230,24 @@ func TestAlternatesDupes(t *testing.T) {
 	assert.Len(t, dotgits, 1)
 }
 
+func TestAlternatesRelativeToObjects(t *testing.T) {
+	altFS := osfs.New(t.TempDir())
+	dotFS, _ := altFS.Chroot(filepath.Join("a", ".git"))
+
+	dir := NewWithOptions(dotFS, Options{AlternatesFS: altFS})
+	require.NoError(t, dir.Initialize())
+	require.NoError(t, altFS.MkdirAll(filepath.Join("a", "b", ".git", "objects"), 0o700))
+
+	// The relative paths are based on the object directory, a/.git/objects.
+	altpath := dotFS.Join("objects", "info", "alternates")
+	require.NoError(t, util.WriteFile(dotFS, altpath, []byte(filepath.Join("..", "..", "b", ".git", "objects")), 0o644))
+
+	dotgits, err := dir.Alternates()
+	require.NoError(t, err)
+	require.Len(t, dotgits, 1)
+	assert.Equal(t, filepath.Join(altFS.Root(), "a", "b", ".git"), dotgits[0].fs.Root())
+}
+
 type norwfs struct {
 	billy.Filesystem
 }
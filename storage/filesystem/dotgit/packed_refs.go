@@ -0,0 +1,227 @@
+package dotgit
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/go-git/go-git/v6/utils/ioutil"
+)
+
+const packedRefsHeader = "# pack-refs with:"
+
+// packedRefs is a parsed packed-refs file. It is never modified once parsed,
+// so it can be shared between concurrent readers.
+type packedRefs struct {
+	// refs are the references, in the order found in the file.
+	refs []*plumbing.Reference
+	// index holds the position of each reference in refs, it is only built
+	// when the file isn't sorted.
+	index map[plumbing.ReferenceName]int
+	// peeled holds the targets of the `^` lines following a reference.
+	peeled map[plumbing.ReferenceName]plumbing.Hash
+
+	// The traits from the `# pack-refs with:` header. With peeled, the
+	// references under refs/tags/ without a `^` line are known not to point
+	// to a tag. With fullyPeeled, the same applies to every reference. With
+	// sorted, the references are sorted by name.
+	traitPeeled      bool
+	traitFullyPeeled bool
+	traitSorted      bool
+
+	// modTime and size of the file parsed, used to detect changes.
+	modTime time.Time
+	size    int64
+}
+
+func (d *DotGit) parsePackedRefs(r io.Reader) (*packedRefs, error) {
+	p := &packedRefs{peeled: make(map[plumbing.ReferenceName]plumbing.Hash)}
+
+	var last *plumbing.Reference
+	s := bufio.NewScanner(r)
+	for first := true; s.Scan(); first = false {
+		line := s.Text()
+		if first && strings.HasPrefix(line, packedRefsHeader) {
+			p.parseTraits(line[len(packedRefsHeader):])
+			continue
+		}
+
+		if strings.HasPrefix(line, "^") {
+			// The target of the annotated tag in the previous line.
+			if last == nil {
+				return nil, ErrPackedRefsBadFormat
+			}
+
+			h, ok := plumbing.FromHex(line[1:])
+			if !ok {
+				return nil, ErrPackedRefsBadFormat
+			}
+
+			p.peeled[last.Name()] = h
+			last = nil
+			continue
+		}
+
+		ref, err := d.processLine(line)
+		if err != nil {
+			return nil, err
+		}
+
+		last = ref
+		if ref != nil {
+			p.refs = append(p.refs, ref)
+		}
+	}
+
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+
+	if !p.traitSorted {
+		p.index = make(map[plumbing.ReferenceName]int, len(p.refs))
+		for i := len(p.refs) - 1; i >= 0; i-- {
+			p.index[p.refs[i].Name()] = i
+		}
+	}
+
+	return p, nil
+}
+
+func (p *packedRefs) parseTraits(traits string) {
+	for _, t := range strings.Fields(traits) {
+		switch t {
+		case "peeled":
+			p.traitPeeled = true
+		case "fully-peeled":
+			p.traitFullyPeeled = true
+		case "sorted":
+			p.traitSorted = true
+		}
+	}
+}
+
+// ref returns the reference with the given name, or nil if not found.
+func (p *packedRefs) ref(name plumbing.ReferenceName) *plumbing.Reference {
+	if p == nil {
+		return nil
+	}
+
+	if !p.traitSorted {
+		if i, ok := p.index[name]; ok {
+			return p.refs[i]
+		}
+
+		return nil
+	}
+
+	i := sort.Search(len(p.refs), func(i int) bool {
+		return p.refs[i].Name() >= name
+	})
+	if i < len(p.refs) && p.refs[i].Name() == name {
+		return p.refs[i]
+	}
+
+	return nil
+}
+
+// peeledRef returns the hash the given packed reference peels to, that is
+// the target of the annotated tag it points to, or the hash of the
+// reference itself when it is known not to point to a tag. ok is false if
+// the reference isn't packed or it isn't known whether it points to a tag.
+func (p *packedRefs) peeledRef(name plumbing.ReferenceName) (h plumbing.Hash, ok bool) {
+	ref := p.ref(name)
+	if ref == nil || ref.Type() != plumbing.HashReference {
+		return plumbing.ZeroHash, false
+	}
+
+	if h, ok := p.peeled[name]; ok {
+		return h, true
+	}
+
+	if p.traitFullyPeeled || (p.traitPeeled && name.IsTag()) {
+		return ref.Hash(), true
+	}
+
+	return plumbing.ZeroHash, false
+}
+
+// loadPackedRefs returns the parsed packed-refs file, or nil if there is
+// none. The file is only parsed again when its modification time or size
+// change since it was last read.
+func (d *DotGit) loadPackedRefs() (p *packedRefs, err error) {
+	fi, err := d.fs.Stat(packedRefsPath)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	d.packedRefsMu.Lock()
+	defer d.packedRefsMu.Unlock()
+
+	if c := d.packedRefs; c != nil && c.modTime.Equal(fi.ModTime()) && c.size == fi.Size() {
+		return c, nil
+	}
+
+	f, err := d.fs.Open(packedRefsPath)
+	if os.IsNotExist(err) {
+		d.packedRefs = nil
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer ioutil.CheckClose(f, &err)
+
+	p, err = d.parsePackedRefs(f)
+	if err != nil {
+		return nil, err
+	}
+
+	// The file may change between the Stat and the Open, in that case the
+	// modification time kept is outdated and the file is parsed again on
+	// the next call.
+	p.modTime, p.size = fi.ModTime(), fi.Size()
+	d.packedRefs = p
+	return p, nil
+}
+
+// invalidatePackedRefs drops the parsed packed-refs file, so it is read
+// again on the next access. It must be called after rewriting it, as the
+// modification time may not change on file systems with a coarse time
+// resolution.
+func (d *DotGit) invalidatePackedRefs() {
+	d.packedRefsMu.Lock()
+	d.packedRefs = nil
+	d.packedRefsMu.Unlock()
+}
+
+// PeeledRef returns the hash the given reference peels to, as recorded in the
+// packed-refs file: the object an annotated tag ultimately points to, or the
+// hash of the reference itself when it doesn't point to a tag. ok is false
+// when this isn't known without reading the objects, e.g. because the
+// reference isn't packed, or it was updated since it was packed.
+func (d *DotGit) PeeledRef(name plumbing.ReferenceName) (h plumbing.Hash, ok bool, err error) {
+	p, err := d.loadPackedRefs()
+	if err != nil {
+		return plumbing.ZeroHash, false, err
+	}
+
+	h, ok = p.peeledRef(name)
+	if !ok {
+		return plumbing.ZeroHash, false, nil
+	}
+
+	// A loose reference takes precedence over the packed one.
+	loose, err := d.readReferenceFile(".", name.String())
+	if err == nil && (loose.Type() != plumbing.HashReference || loose.Hash() != p.ref(name).Hash()) {
+		return plumbing.ZeroHash, false, nil
+	}
+
+	return h, true, nil
+}
@@ -29,6 +29,16 @@ func (fs *RepositoryFilesystem) mapToRepositoryFsByPath(path string) billy.Files
 		return fs.dotGitFs
 	}
 
+	// Absolute paths, such as the names of the temporary files, belong to the
+	// filesystem holding them.
+	if filepath.IsAbs(path) {
+		if isWithin(fs.dotGitFs.Root(), path) || !isWithin(fs.commonDotGitFs.Root(), path) {
+			return fs.dotGitFs
+		}
+
+		return fs.commonDotGitFs
+	}
+
 	cleanPath := filepath.Clean(path)
 
 	// Check exceptions for commondir (https://git-scm.com/docs/gitrepository-layout#Documentation/gitrepository-layout.txt)
@@ -50,6 +60,11 @@ func (fs *RepositoryFilesystem) mapToRepositoryFsByPath(path string) billy.Files
 	}
 }
 
+func isWithin(root, path string) bool {
+	rel, err := filepath.Rel(root, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
 func (fs *RepositoryFilesystem) Create(filename string) (billy.File, error) {
 	return fs.mapToRepositoryFsByPath(filename).Create(filename)
 }
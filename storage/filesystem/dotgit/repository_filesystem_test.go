@@ -2,6 +2,8 @@ package dotgit
 
 import (
 	"os"
+
+	"github.com/go-git/go-billy/v5/osfs"
 )
 
 func (s *SuiteDotGit) TestRepositoryFilesystem() {
@@ -110,3 +112,26 @@ func (s *SuiteDotGit) TestRepositoryFilesystem() {
 	_, err = dotGitFs.Stat("a/b/c")
 	s.NoError(err)
 }
+
+func (s *SuiteDotGit) TestRepositoryFilesystemTempFileRename() {
+	fs := osfs.New(s.T().TempDir(), osfs.WithBoundOS())
+
+	s.Require().NoError(fs.MkdirAll("worktrees/foo", 0o777))
+	dotGitFs, err := fs.Chroot("worktrees/foo")
+	s.Require().NoError(err)
+
+	repositoryFs := NewRepositoryFilesystem(dotGitFs, fs)
+	s.Require().NoError(repositoryFs.MkdirAll("objects/pack", 0o777))
+
+	f, err := repositoryFs.TempFile("objects/pack", "tmp_obj_")
+	s.Require().NoError(err)
+	s.Require().NoError(f.Close())
+
+	s.Require().NoError(repositoryFs.MkdirAll("objects/ab", 0o777))
+	s.Require().NoError(repositoryFs.Rename(f.Name(), "objects/ab/cdef"))
+
+	_, err = fs.Stat("objects/ab/cdef")
+	s.NoError(err)
+	_, err = dotGitFs.Stat("objects/ab/cdef")
+	s.True(os.IsNotExist(err))
+}
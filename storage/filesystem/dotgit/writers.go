@@ -6,6 +6,7 @@ import (
 	"sync/atomic"
 
 	"github.com/go-git/go-git/v6/plumbing"
+	format "github.com/go-git/go-git/v6/plumbing/format/config"
 	"github.com/go-git/go-git/v6/plumbing/format/idxfile"
 	"github.com/go-git/go-git/v6/plumbing/format/objfile"
 	"github.com/go-git/go-git/v6/plumbing/format/packfile"
@@ -19,20 +20,28 @@ import (
 // The packfile is written in a temp file, when Close is called this file
 // is renamed/moved (depends on the Filesystem implementation) to the final
 // location, if the PackWriter is not used, nothing is written.
+// The index is written to a temp file too while the packfile is decoded,
+// with an idxfile.StreamWriter, so its entries aren't all kept in memory.
 type PackWriter struct {
-	Notify func(plumbing.Hash, *idxfile.Writer)
+	// Notify is called with the checksum of the packfile once it's saved
+	// along with its index.
+	Notify func(plumbing.Hash)
 
 	fs       billy.Filesystem
 	fr, fw   billy.File
 	synced   *syncedReader
 	checksum plumbing.Hash
 	parser   *packfile.Parser
-	writer   *idxfile.Writer
-	result   chan error
+	writer   *idxfile.StreamWriter
+	// idx is the temp file the index is written to, and runs the one
+	// holding the entries sorted by the writer.
+	idx, runs billy.File
+	result    chan error
 }
 
 func newPackWrite(fs billy.Filesystem) (*PackWriter, error) {
-	fw, err := fs.TempFile(fs.Join(objectsPath, packPath), "tmp_pack_")
+	dir := fs.Join(objectsPath, packPath)
+	fw, err := fs.TempFile(dir, "tmp_pack_")
 	if err != nil {
 		return nil, err
 	}
@@ -42,11 +51,24 @@ func newPackWrite(fs billy.Filesystem) (*PackWriter, error) {
 		return nil, err
 	}
 
+	idx, err := fs.TempFile(dir, "tmp_idx_")
+	if err != nil {
+		return nil, err
+	}
+
+	runs, err := fs.TempFile(dir, "tmp_idx_runs_")
+	if err != nil {
+		return nil, err
+	}
+
 	writer := &PackWriter{
 		fs:     fs,
 		fw:     fw,
 		fr:     fr,
 		synced: newSyncedReader(fw, fr),
+		writer: idxfile.NewStreamWriter(idx, runs, 0),
+		idx:    idx,
+		runs:   runs,
 		result: make(chan error),
 	}
 
@@ -55,9 +77,6 @@ func newPackWrite(fs billy.Filesystem) (*PackWriter, error) {
 }
 
 func (w *PackWriter) buildIndex() {
-	w.writer = new(idxfile.Writer)
-	var err error
-
 	w.parser = packfile.NewParser(w.synced, packfile.WithScannerObservers(w.writer))
 
 	h, err := w.parser.Parse()
@@ -89,13 +108,7 @@ func (w *PackWriter) Write(p []byte) (int, error) {
 // Close closes all the file descriptors and save the final packfile, if nothing
 // was written, the tempfiles are deleted without writing a packfile.
 func (w *PackWriter) Close() error {
-	defer func() {
-		if w.Notify != nil && w.writer != nil && w.writer.Finished() {
-			w.Notify(w.checksum, w.writer)
-		}
-
-		close(w.result)
-	}()
+	defer close(w.result)
 
 	if err := w.synced.Close(); err != nil {
 		return err
@@ -113,44 +126,54 @@ func (w *PackWriter) Close() error {
 		return err
 	}
 
-	if w.writer == nil || !w.writer.Finished() {
+	if err := w.closeIdx(); err != nil {
+		return err
+	}
+
+	if !w.writer.Finished() {
 		return w.clean()
 	}
 
-	return w.save()
-}
+	if err := w.save(); err != nil {
+		return err
+	}
 
-func (w *PackWriter) clean() error {
-	return w.fs.Remove(w.fw.Name())
+	if w.Notify != nil {
+		w.Notify(w.checksum)
+	}
+
+	return nil
 }
 
-func (w *PackWriter) save() error {
-	base := w.fs.Join(objectsPath, packPath, fmt.Sprintf("pack-%s", w.checksum))
-	idx, err := w.fs.Create(fmt.Sprintf("%s.idx", base))
-	if err != nil {
+// closeIdx closes the temp files of the index, removing the one of the
+// sorted entries, no longer needed.
+func (w *PackWriter) closeIdx() error {
+	if err := w.idx.Close(); err != nil {
 		return err
 	}
 
-	if err := w.encodeIdx(idx); err != nil {
+	if err := w.runs.Close(); err != nil {
 		return err
 	}
 
-	if err := idx.Close(); err != nil {
+	return w.fs.Remove(w.runs.Name())
+}
+
+func (w *PackWriter) clean() error {
+	if err := w.fs.Remove(w.idx.Name()); err != nil {
 		return err
 	}
 
-	return w.fs.Rename(w.fw.Name(), fmt.Sprintf("%s.pack", base))
+	return w.fs.Remove(w.fw.Name())
 }
 
-func (w *PackWriter) encodeIdx(writer io.Writer) error {
-	idx, err := w.writer.Index()
-	if err != nil {
+func (w *PackWriter) save() error {
+	base := w.fs.Join(objectsPath, packPath, fmt.Sprintf("pack-%s", w.checksum))
+	if err := w.fs.Rename(w.idx.Name(), fmt.Sprintf("%s.idx", base)); err != nil {
 		return err
 	}
 
-	e := idxfile.NewEncoder(writer)
-	_, err = e.Encode(idx)
-	return err
+	return w.fs.Rename(w.fw.Name(), fmt.Sprintf("%s.pack", base))
 }
 
 type syncedReader struct {
@@ -247,14 +270,14 @@ type ObjectWriter struct {
 	f  billy.File
 }
 
-func newObjectWriter(fs billy.Filesystem) (*ObjectWriter, error) {
+func newObjectWriter(fs billy.Filesystem, of format.ObjectFormat, opts ...objfile.WriterOption) (*ObjectWriter, error) {
 	f, err := fs.TempFile(fs.Join(objectsPath, packPath), "tmp_obj_")
 	if err != nil {
 		return nil, err
 	}
 
 	return &ObjectWriter{
-		Writer: (*objfile.NewWriter(f)),
+		Writer: (*objfile.NewWriter(f, append([]objfile.WriterOption{objfile.WithObjectFormat(of)}, opts...)...)),
 		fs:     fs,
 		f:      f,
 	}, nil
@@ -10,7 +10,6 @@ import (
 	"github.com/go-git/go-billy/v5/osfs"
 	"github.com/go-git/go-billy/v5/util"
 	"github.com/go-git/go-git/v6/plumbing"
-	"github.com/go-git/go-git/v6/plumbing/format/idxfile"
 	"github.com/go-git/go-git/v6/plumbing/format/packfile"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -60,6 +59,11 @@ func TestNewObjectPack(t *testing.T) {
 	require.NoError(t, err)
 	assert.Equal(t, int64(1940), stat.Size())
 
+	// The temp files of the index are removed.
+	info, err := fs.ReadDir("objects/pack")
+	require.NoError(t, err)
+	assert.Len(t, info, 2)
+
 	pf, err := fs.Open(pfPath)
 	assert.NoError(t, err)
 
@@ -154,7 +158,7 @@ func TestPackWriterUnusedNotify(t *testing.T) {
 	w, err := newPackWrite(fs)
 	require.NoError(t, err)
 
-	w.Notify = func(h plumbing.Hash, idx *idxfile.Writer) {
+	w.Notify = func(h plumbing.Hash) {
 		t.Fatal("unexpected call to PackWriter.Notify")
 	}
 
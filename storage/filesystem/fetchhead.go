@@ -0,0 +1,36 @@
+package filesystem
+
+import (
+	"github.com/go-git/go-git/v6/plumbing/format/fetchhead"
+	"github.com/go-git/go-git/v6/storage/filesystem/dotgit"
+	"github.com/go-git/go-git/v6/utils/ioutil"
+)
+
+// FetchHeadStorage stores the references fetched by the last fetch in the
+// FETCH_HEAD file of .git, as git does.
+type FetchHeadStorage struct {
+	dir *dotgit.DotGit
+}
+
+// SetFetchHead writes the given entries to the FETCH_HEAD file, replacing the
+// ones of the previous fetch.
+func (s *FetchHeadStorage) SetFetchHead(entries []*fetchhead.Entry) (err error) {
+	f, err := s.dir.FetchHeadWriter()
+	if err != nil {
+		return err
+	}
+
+	defer ioutil.CheckClose(f, &err)
+	return fetchhead.NewEncoder(f).EncodeAll(entries)
+}
+
+// FetchHead returns the entries of the FETCH_HEAD file, in order.
+func (s *FetchHeadStorage) FetchHead() (entries []*fetchhead.Entry, err error) {
+	f, err := s.dir.FetchHead()
+	if f == nil || err != nil {
+		return nil, err
+	}
+
+	defer ioutil.CheckClose(f, &err)
+	return fetchhead.NewDecoder(f).DecodeAll()
+}
@@ -4,13 +4,15 @@ import (
 	"bufio"
 	"os"
 
+	format "github.com/go-git/go-git/v6/plumbing/format/config"
 	"github.com/go-git/go-git/v6/plumbing/format/index"
 	"github.com/go-git/go-git/v6/storage/filesystem/dotgit"
 	"github.com/go-git/go-git/v6/utils/ioutil"
 )
 
 type IndexStorage struct {
-	dir *dotgit.DotGit
+	dir          *dotgit.DotGit
+	objectFormat format.ObjectFormat
 }
 
 func (s *IndexStorage) SetIndex(idx *index.Index) (err error) {
@@ -27,7 +29,7 @@ func (s *IndexStorage) SetIndex(idx *index.Index) (err error) {
 		}
 	}()
 
-	e := index.NewEncoder(bw)
+	e := index.NewEncoder(bw, index.WithObjectFormat(s.objectFormat))
 	err = e.Encode(idx)
 	return err
 }
@@ -48,7 +50,7 @@ func (s *IndexStorage) Index() (i *index.Index, err error) {
 
 	defer ioutil.CheckClose(f, &err)
 
-	d := index.NewDecoder(f)
+	d := index.NewDecoder(f, index.WithObjectFormat(s.objectFormat))
 	err = d.Decode(idx)
 	return idx, err
 }
@@ -1,17 +1,20 @@
 package filesystem
 
 import (
-	"crypto"
 	"errors"
 	"fmt"
 	"io"
 	"os"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/go-git/go-git/v6/plumbing"
 	"github.com/go-git/go-git/v6/plumbing/cache"
+	"github.com/go-git/go-git/v6/plumbing/format/bitmap"
 	"github.com/go-git/go-git/v6/plumbing/format/idxfile"
+	"github.com/go-git/go-git/v6/plumbing/format/midx"
 	"github.com/go-git/go-git/v6/plumbing/format/objfile"
 	"github.com/go-git/go-git/v6/plumbing/format/packfile"
 	"github.com/go-git/go-git/v6/plumbing/storer"
@@ -29,11 +32,39 @@ type ObjectStorage struct {
 	dir   *dotgit.DotGit
 	index map[plumbing.Hash]idxfile.Index
 
+	// midx is the multi-pack-index of the repository, if any, and midxPacks
+	// the packfiles it indexes by pack-int-id. The idx files of these
+	// packfiles are only loaded when needed.
+	midx      *midx.MultiPackIndex
+	midxPacks []plumbing.Hash
+
+	// filter is the bloom filter of the packed objects, built on the first
+	// lookup of a packed object when Options.ObjectFilter is set.
+	filter *objectFilter
+
+	// bitmap is the reachability bitmap of the packfile having one, loaded
+	// on the first call to ReachabilityBitmap.
+	bitmap       *bitmap.Index
+	bitmapLoaded bool
+	muB          sync.Mutex
+
 	packList    []plumbing.Hash
 	packListIdx int
 	packfiles   map[plumbing.Hash]*packfile.Packfile
 	muI         sync.RWMutex
 	muP         sync.RWMutex
+
+	// alts are the storages of the alternate object directories, loaded
+	// when an object isn't found in the repository.
+	alts       []*ObjectStorage
+	altsLoaded bool
+	muA        sync.Mutex
+
+	// looseCompression is the zlib level of the loose objects written, read
+	// from the config on the first write, and again after the config is set.
+	looseCompression       int
+	looseCompressionLoaded bool
+	muC                    sync.Mutex
 }
 
 // NewObjectStorage creates a new ObjectStorage with the given .git directory and cache.
@@ -61,7 +92,13 @@ func (s *ObjectStorage) requireIndex() error {
 		return err
 	}
 
+	s.loadMultiPackIndex(packs)
+	covered := hashListAsMap(s.midxPacks)
 	for _, h := range packs {
+		if _, ok := covered[h]; ok {
+			continue
+		}
+
 		if err := s.loadIdxFile(h); err != nil {
 			return err
 		}
@@ -73,6 +110,177 @@ func (s *ObjectStorage) requireIndex() error {
 // Reindex indexes again all packfiles. Useful if git changed packfiles externally
 func (s *ObjectStorage) Reindex() {
 	s.index = nil
+	s.filter = nil
+	s.resetBitmap()
+	_ = s.closeMultiPackIndex()
+	_ = s.resetAlternates()
+}
+
+// alternates returns the storages of the alternate object directories of the
+// repository, read from the objects/info/alternates file and recursively from
+// the ones of the alternate object directories. An object directory is only
+// returned once, even when the alternates form a cycle. The object
+// directories that don't exist are ignored, as git does.
+//
+// The alternate object directories are only read, the objects are always
+// written to the object directory of the repository.
+func (s *ObjectStorage) alternates() ([]*ObjectStorage, error) {
+	s.muA.Lock()
+	defer s.muA.Unlock()
+
+	if s.altsLoaded {
+		return s.alts, nil
+	}
+
+	seen := map[string]struct{}{s.dir.Fs().Root(): {}}
+	pending := []*dotgit.DotGit{s.dir}
+	var alts []*ObjectStorage
+	for len(pending) > 0 {
+		dir := pending[0]
+		pending = pending[1:]
+
+		dirs, err := dir.Alternates()
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		for _, d := range dirs {
+			if _, ok := seen[d.Fs().Root()]; ok {
+				continue
+			}
+
+			seen[d.Fs().Root()] = struct{}{}
+			alts = append(alts, NewObjectStorageWithOptions(d, s.objectCache, s.options))
+			pending = append(pending, d)
+		}
+	}
+
+	s.alts = alts
+	s.altsLoaded = true
+	return alts, nil
+}
+
+// resetAlternates closes the storages of the alternate object directories, so
+// they are read again on the next lookup.
+func (s *ObjectStorage) resetAlternates() error {
+	s.muA.Lock()
+	defer s.muA.Unlock()
+
+	var firstError error
+	for _, alt := range s.alts {
+		if err := alt.Close(); firstError == nil && err != nil {
+			firstError = err
+		}
+	}
+
+	s.alts = nil
+	s.altsLoaded = false
+	return firstError
+}
+
+// loadMultiPackIndex opens the multi-pack-index of the repository, if any.
+// A multi-pack-index that can't be read, or that is stale because it indexes
+// packfiles that aren't present anymore, is ignored, so the idx file of
+// every packfile is used instead. Packfiles not indexed by it, e.g. added
+// after it was written, are still indexed by their own idx file.
+func (s *ObjectStorage) loadMultiPackIndex(packs []plumbing.Hash) {
+	if err := s.closeMultiPackIndex(); err != nil {
+		return
+	}
+
+	f, err := s.dir.ObjectPackMultiIndex()
+	if err != nil {
+		return
+	}
+
+	m, err := midx.Open(f)
+	if err != nil {
+		_ = f.Close()
+		return
+	}
+
+	if m.HashSize() != s.options.ObjectFormat.Size() {
+		_ = m.Close()
+		return
+	}
+
+	present := hashListAsMap(packs)
+	midxPacks := make([]plumbing.Hash, 0, len(m.PackNames()))
+	for _, name := range m.PackNames() {
+		h, ok := midxPackHash(name)
+		if _, exists := present[h]; !ok || !exists {
+			_ = m.Close()
+			return
+		}
+
+		midxPacks = append(midxPacks, h)
+	}
+
+	s.midx, s.midxPacks = m, midxPacks
+}
+
+// midxPackHash returns the hash of a packfile from the name of its idx file
+// as stored in a multi-pack-index, "pack-<hash>.idx".
+func midxPackHash(name string) (plumbing.Hash, bool) {
+	name, ok := strings.CutPrefix(name, "pack-")
+	if !ok {
+		return plumbing.ZeroHash, false
+	}
+
+	name, ok = strings.CutSuffix(name, ".idx")
+	if !ok {
+		return plumbing.ZeroHash, false
+	}
+
+	return plumbing.FromHex(name)
+}
+
+func (s *ObjectStorage) closeMultiPackIndex() error {
+	if s.midx == nil {
+		return nil
+	}
+
+	m := s.midx
+	s.midx, s.midxPacks = nil, nil
+	return m.Close()
+}
+
+// packIndex returns the index of the given packfile, loading its idx file if
+// the packfile is indexed by the multi-pack-index and it wasn't loaded yet.
+func (s *ObjectStorage) packIndex(pack plumbing.Hash) (idxfile.Index, error) {
+	s.muI.Lock()
+	defer s.muI.Unlock()
+
+	if idx, ok := s.index[pack]; ok {
+		return idx, nil
+	}
+
+	if err := s.loadIdxFile(pack); err != nil {
+		return nil, err
+	}
+
+	return s.index[pack], nil
+}
+
+// requireAllIndexes loads the idx file of every packfile, including the ones
+// indexed by the multi-pack-index, for the operations going through all the
+// packed objects.
+func (s *ObjectStorage) requireAllIndexes() error {
+	if err := s.requireIndex(); err != nil {
+		return err
+	}
+
+	for _, h := range s.midxPacks {
+		if _, err := s.packIndex(h); err != nil {
+			return err
+		}
+	}
+
+	return nil
 }
 
 func (s *ObjectStorage) loadIdxFile(h plumbing.Hash) (err error) {
@@ -94,7 +302,7 @@ func (s *ObjectStorage) loadIdxFile(h plumbing.Hash) (err error) {
 }
 
 func (s *ObjectStorage) RawObjectWriter(typ plumbing.ObjectType, sz int64) (w io.WriteCloser, err error) {
-	ow, err := s.dir.NewObject()
+	ow, err := s.newObject()
 	if err != nil {
 		return nil, err
 	}
@@ -108,7 +316,7 @@ func (s *ObjectStorage) RawObjectWriter(typ plumbing.ObjectType, sz int64) (w io
 }
 
 func (s *ObjectStorage) NewEncodedObject() plumbing.EncodedObject {
-	return &plumbing.MemoryObject{}
+	return plumbing.NewMemoryObject(s.options.ObjectFormat)
 }
 
 func (s *ObjectStorage) PackfileWriter() (io.WriteCloser, error) {
@@ -121,11 +329,15 @@ func (s *ObjectStorage) PackfileWriter() (io.WriteCloser, error) {
 		return nil, err
 	}
 
-	w.Notify = func(h plumbing.Hash, writer *idxfile.Writer) {
-		index, err := writer.Index()
-		if err == nil {
-			s.index[h] = index
+	w.Notify = func(h plumbing.Hash) {
+		s.muI.Lock()
+		defer s.muI.Unlock()
+
+		if err := s.loadIdxFile(h); err != nil {
+			return
 		}
+
+		s.addToObjectFilter(s.index[h])
 	}
 
 	return w, nil
@@ -137,7 +349,7 @@ func (s *ObjectStorage) SetEncodedObject(o plumbing.EncodedObject) (h plumbing.H
 		return plumbing.ZeroHash, plumbing.ErrInvalidType
 	}
 
-	ow, err := s.dir.NewObject()
+	ow, err := s.newObject()
 	if err != nil {
 		return plumbing.ZeroHash, err
 	}
@@ -167,7 +379,7 @@ func (s *ObjectStorage) SetEncodedObject(o plumbing.EncodedObject) (h plumbing.H
 // that the object contents can be written later, without the need to
 // create a MemoryObject and buffering its entire contents into memory.
 func (s *ObjectStorage) LazyWriter() (w io.WriteCloser, wh func(typ plumbing.ObjectType, sz int64) error, err error) {
-	ow, err := s.dir.NewObject()
+	ow, err := s.newObject()
 	if err != nil {
 		return nil, nil, err
 	}
@@ -175,9 +387,61 @@ func (s *ObjectStorage) LazyWriter() (w io.WriteCloser, wh func(typ plumbing.Obj
 	return ow, ow.WriteHeader, nil
 }
 
+// newObject returns a writer for a new loose object, compressed with the
+// core.looseCompression level of the config.
+func (s *ObjectStorage) newObject() (*dotgit.ObjectWriter, error) {
+	s.muC.Lock()
+	if !s.looseCompressionLoaded {
+		cfg, err := (&ConfigStorage{dir: s.dir}).Config()
+		if err != nil {
+			s.muC.Unlock()
+			return nil, err
+		}
+
+		s.looseCompression, s.looseCompressionLoaded = cfg.Core.LooseCompression, true
+	}
+
+	level := s.looseCompression
+	s.muC.Unlock()
+
+	return s.dir.NewObject(objfile.WithCompressionLevel(level))
+}
+
+// resetLooseCompression makes the compression level of the loose objects be
+// read from the config again on the next write.
+func (s *ObjectStorage) resetLooseCompression() {
+	s.muC.Lock()
+	defer s.muC.Unlock()
+
+	s.looseCompressionLoaded = false
+}
+
 // HasEncodedObject returns nil if the object exists, without actually
-// reading the object data from storage.
-func (s *ObjectStorage) HasEncodedObject(h plumbing.Hash) (err error) {
+// reading the object data from storage. The alternate object directories are
+// also checked.
+func (s *ObjectStorage) HasEncodedObject(h plumbing.Hash) error {
+	err := s.hasEncodedObject(h)
+	if !errors.Is(err, plumbing.ErrObjectNotFound) {
+		return err
+	}
+
+	alts, err := s.alternates()
+	if err != nil {
+		return err
+	}
+
+	for _, alt := range alts {
+		if err := alt.hasEncodedObject(h); !errors.Is(err, plumbing.ErrObjectNotFound) {
+			return err
+		}
+	}
+
+	return plumbing.ErrObjectNotFound
+}
+
+// hasEncodedObject checks whether the object exists in the object directory
+// of the repository.
+func (s *ObjectStorage) hasEncodedObject(h plumbing.Hash) (err error) {
 	// Check unpacked objects
 	f, err := s.dir.Object(h)
 	if err != nil {
@@ -236,6 +500,7 @@ func (s *ObjectStorage) packfile(idx idxfile.Index, pack plumbing.Hash) (*packfi
 		packfile.WithFs(s.dir.Fs()),
 		packfile.WithCache(s.objectCache),
 		packfile.WithObjectIDSize(pack.Size()),
+		packfile.WithLargeObjectThreshold(s.options.LargeObjectThreshold),
 	)
 	return p, s.storePackfileInCache(pack, p)
 }
@@ -303,7 +568,11 @@ func (s *ObjectStorage) encodedObjectSizeFromPackfile(h plumbing.Hash) (size int
 		return 0, plumbing.ErrObjectNotFound
 	}
 
-	idx := s.index[pack]
+	idx, err := s.packIndex(pack)
+	if err != nil {
+		return 0, err
+	}
+
 	hash, err := idx.FindHash(offset)
 	if err == nil {
 		obj, ok := s.objectCache.Get(hash)
@@ -328,7 +597,28 @@ func (s *ObjectStorage) encodedObjectSizeFromPackfile(h plumbing.Hash) (size int
 
 // EncodedObjectSize returns the plaintext size of the given object,
 // without actually reading the full object data from storage.
-func (s *ObjectStorage) EncodedObjectSize(h plumbing.Hash) (size int64, err error) {
+func (s *ObjectStorage) EncodedObjectSize(h plumbing.Hash) (int64, error) {
+	size, err := s.encodedObjectSize(h)
+	if !errors.Is(err, plumbing.ErrObjectNotFound) {
+		return size, err
+	}
+
+	alts, err := s.alternates()
+	if err != nil {
+		return 0, err
+	}
+
+	for _, alt := range alts {
+		size, err := alt.encodedObjectSize(h)
+		if !errors.Is(err, plumbing.ErrObjectNotFound) {
+			return size, err
+		}
+	}
+
+	return 0, plumbing.ErrObjectNotFound
+}
+
+func (s *ObjectStorage) encodedObjectSize(h plumbing.Hash) (size int64, err error) {
 	size, err = s.encodedObjectSizeFromUnpacked(h)
 	if err != nil && !errors.Is(err, plumbing.ErrObjectNotFound) {
 		return 0, err
@@ -340,8 +630,32 @@ func (s *ObjectStorage) EncodedObjectSize(h plumbing.Hash) (size int64, err erro
 }
 
 // EncodedObject returns the object with the given hash, by searching for it in
-// the packfile and the git object directories.
+// the packfile and the git object directories, and then in the alternate
+// object directories.
 func (s *ObjectStorage) EncodedObject(t plumbing.ObjectType, h plumbing.Hash) (plumbing.EncodedObject, error) {
+	obj, err := s.encodedObject(t, h)
+	if !errors.Is(err, plumbing.ErrObjectNotFound) {
+		return obj, err
+	}
+
+	alts, err := s.alternates()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, alt := range alts {
+		obj, err := alt.encodedObject(t, h)
+		if !errors.Is(err, plumbing.ErrObjectNotFound) {
+			return obj, err
+		}
+	}
+
+	return nil, plumbing.ErrObjectNotFound
+}
+
+// encodedObject returns the object with the given hash from the object
+// directory of the repository.
+func (s *ObjectStorage) encodedObject(t plumbing.ObjectType, h plumbing.Hash) (plumbing.EncodedObject, error) {
 	var obj plumbing.EncodedObject
 	var err error
 
@@ -357,24 +671,6 @@ func (s *ObjectStorage) EncodedObject(t plumbing.ObjectType, h plumbing.Hash) (p
 		}
 	}
 
-	// If the error is still object not found, check if it's a shared object
-	// repository.
-	if errors.Is(err, plumbing.ErrObjectNotFound) {
-		dotgits, e := s.dir.Alternates()
-		if e == nil {
-			// Create a new object storage with the DotGit(s) and check for the
-			// required hash object. Skip when not found.
-			for _, dg := range dotgits {
-				o := NewObjectStorage(dg, s.objectCache)
-				enobj, enerr := o.EncodedObject(t, h)
-				if enerr != nil {
-					continue
-				}
-				return enobj, nil
-			}
-		}
-	}
-
 	if err != nil {
 		return nil, err
 	}
@@ -386,6 +682,95 @@ func (s *ObjectStorage) EncodedObject(t plumbing.ObjectType, h plumbing.Hash) (p
 	return obj, nil
 }
 
+// BatchRead returns the objects with the given hashes, in the same order.
+// Unlike calling EncodedObject for each of them, the packed objects are read
+// grouping them by packfile, opened once, and sorting them by offset, so each
+// packfile is read in a single forward pass, with the delta bases kept in the
+// object cache. The objects not found in any packfile are searched as
+// EncodedObject does. ErrObjectNotFound is returned if any object is missing.
+func (s *ObjectStorage) BatchRead(hashes []plumbing.Hash) ([]plumbing.EncodedObject, error) {
+	if err := s.requireIndex(); err != nil {
+		return nil, err
+	}
+
+	type packedObject struct {
+		pos    int
+		offset int64
+	}
+
+	packs := make(map[plumbing.Hash][]packedObject)
+	var others []int
+	for i, h := range hashes {
+		pack, _, offset := s.findObjectInPackfile(h)
+		if offset == -1 {
+			others = append(others, i)
+			continue
+		}
+
+		packs[pack] = append(packs[pack], packedObject{pos: i, offset: offset})
+	}
+
+	objs := make([]plumbing.EncodedObject, len(hashes))
+	for pack, entries := range packs {
+		sort.Slice(entries, func(i, j int) bool {
+			return entries[i].offset < entries[j].offset
+		})
+
+		offsets := make([]int64, len(entries))
+		for i, e := range entries {
+			offsets[i] = e.offset
+		}
+
+		packed, err := s.readFromPackfile(pack, offsets)
+		if err != nil {
+			return nil, err
+		}
+
+		for i, e := range entries {
+			objs[e.pos] = packed[i]
+		}
+	}
+
+	for _, i := range others {
+		obj, err := s.EncodedObject(plumbing.AnyObject, hashes[i])
+		if err != nil {
+			return nil, err
+		}
+
+		objs[i] = obj
+	}
+
+	return objs, nil
+}
+
+// readFromPackfile returns the objects at the given offsets of a packfile,
+// opening it only once.
+func (s *ObjectStorage) readFromPackfile(pack plumbing.Hash, offsets []int64) (objs []plumbing.EncodedObject, err error) {
+	idx, err := s.packIndex(pack)
+	if err != nil {
+		return nil, err
+	}
+
+	p, err := s.packfile(idx, pack)
+	if err != nil {
+		return nil, err
+	}
+
+	if !s.options.KeepDescriptors && s.options.MaxOpenDescriptors == 0 {
+		defer ioutil.CheckClose(p, &err)
+	}
+
+	objs = make([]plumbing.EncodedObject, len(offsets))
+	for i, offset := range offsets {
+		objs[i], err = p.GetByOffset(offset)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return objs, nil
+}
+
 // DeltaObject returns the object with the given hash, by searching for
 // it in the packfile and the git object directories.
 func (s *ObjectStorage) DeltaObject(t plumbing.ObjectType, h plumbing.Hash) (plumbing.EncodedObject, error) {
@@ -477,9 +862,10 @@ func (s *ObjectStorage) getFromPackfile(h plumbing.Hash, canBeDelta bool) (plumb
 		return nil, plumbing.ErrObjectNotFound
 	}
 
-	s.muI.RLock()
-	idx := s.index[pack]
-	s.muI.RUnlock()
+	idx, err := s.packIndex(pack)
+	if err != nil {
+		return nil, err
+	}
 
 	p, err := s.packfile(idx, pack)
 	if err != nil {
@@ -532,7 +918,7 @@ func (s *ObjectStorage) decodeDeltaObjectAt(
 		return p.GetByOffset(offset)
 	}
 
-	obj := &plumbing.MemoryObject{}
+	obj := s.NewEncodedObject()
 	obj.SetType(header.Type)
 	w, err := obj.Writer()
 	if err != nil {
@@ -550,6 +936,18 @@ func (s *ObjectStorage) findObjectInPackfile(h plumbing.Hash) (plumbing.Hash, pl
 	defer s.muI.Unlock()
 	s.muI.Lock()
 
+	if s.options.ObjectFilter && s.index != nil {
+		if f, err := s.objectFilter(); err == nil && !f.MayContain(h) {
+			return plumbing.ZeroHash, plumbing.ZeroHash, -1
+		}
+	}
+
+	if s.midx != nil {
+		if i, offset, err := s.midx.FindOffset(h); err == nil {
+			return s.midxPacks[i], h, offset
+		}
+	}
+
 	for packfile, index := range s.index {
 		offset, err := index.FindOffset(h)
 		if err == nil {
@@ -560,9 +958,111 @@ func (s *ObjectStorage) findObjectInPackfile(h plumbing.Hash) (plumbing.Hash, pl
 	return plumbing.ZeroHash, plumbing.ZeroHash, -1
 }
 
+// objectFilter returns the bloom filter of the packed objects, building it
+// from the multi-pack-index and the indexes of the packfiles it doesn't
+// cover. It must be called with muI held.
+func (s *ObjectStorage) objectFilter() (*objectFilter, error) {
+	if s.filter != nil {
+		return s.filter, nil
+	}
+
+	covered := hashListAsMap(s.midxPacks)
+	var count int64
+	if s.midx != nil {
+		count = int64(s.midx.Count())
+	}
+
+	for pack, idx := range s.index {
+		if _, ok := covered[pack]; ok {
+			continue
+		}
+
+		n, err := idx.Count()
+		if err != nil {
+			return nil, err
+		}
+
+		count += n
+	}
+
+	f := newObjectFilter(int(count))
+	if s.midx != nil {
+		err := s.midx.ForEachHash(func(h plumbing.Hash) error {
+			f.Add(h)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for pack, idx := range s.index {
+		if _, ok := covered[pack]; ok {
+			continue
+		}
+
+		if err := f.AddIndex(idx); err != nil {
+			return nil, err
+		}
+	}
+
+	s.filter = f
+	return f, nil
+}
+
+// addToObjectFilter adds the objects of a new packfile to the bloom filter,
+// if it was built. The filter is dropped, to be built again with a larger
+// size, when it can't hold them. It must be called with muI held.
+func (s *ObjectStorage) addToObjectFilter(idx idxfile.Index) {
+	if s.filter == nil {
+		return
+	}
+
+	n, err := idx.Count()
+	if err != nil || !s.filter.Fits(int(n)) {
+		s.filter = nil
+		return
+	}
+
+	if err := s.filter.AddIndex(idx); err != nil {
+		s.filter = nil
+	}
+}
+
 // HashesWithPrefix returns all objects with a hash that starts with a prefix by searching for
-// them in the packfile and the git object directories.
+// them in the packfile and the git object directories, including the alternate ones.
 func (s *ObjectStorage) HashesWithPrefix(prefix []byte) ([]plumbing.Hash, error) {
+	hashes, err := s.hashesWithPrefix(prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	alts, err := s.alternates()
+	if err != nil {
+		return nil, err
+	}
+
+	seen := hashListAsMap(hashes)
+	for _, alt := range alts {
+		found, err := alt.hashesWithPrefix(prefix)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, h := range found {
+			if _, ok := seen[h]; ok {
+				continue
+			}
+
+			seen[h] = struct{}{}
+			hashes = append(hashes, h)
+		}
+	}
+
+	return hashes, nil
+}
+
+func (s *ObjectStorage) hashesWithPrefix(prefix []byte) ([]plumbing.Hash, error) {
 	hashes, err := s.dir.ObjectsWithPrefix(prefix)
 	if err != nil {
 		return nil, err
@@ -572,7 +1072,7 @@ func (s *ObjectStorage) HashesWithPrefix(prefix []byte) ([]plumbing.Hash, error)
 
 	// TODO: This could be faster with some idxfile changes,
 	// or diving into the packfile.
-	if err := s.requireIndex(); err != nil {
+	if err := s.requireAllIndexes(); err != nil {
 		return nil, err
 	}
 	for _, index := range s.index {
@@ -601,8 +1101,49 @@ func (s *ObjectStorage) HashesWithPrefix(prefix []byte) ([]plumbing.Hash, error)
 }
 
 // IterEncodedObjects returns an iterator for all the objects in the packfile
-// with the given type.
+// with the given type. The objects of the alternate object directories are
+// returned after the ones of the repository, each object only once.
 func (s *ObjectStorage) IterEncodedObjects(t plumbing.ObjectType) (storer.EncodedObjectIter, error) {
+	iter, err := s.iterEncodedObjects(t)
+	if err != nil {
+		return nil, err
+	}
+
+	alts, err := s.alternates()
+	if err != nil {
+		iter.Close()
+		return nil, err
+	}
+
+	if len(alts) == 0 {
+		return iter, nil
+	}
+
+	iters := []storer.EncodedObjectIter{iter}
+	for i, alt := range alts {
+		altIter, err := alt.iterEncodedObjects(t)
+		if err != nil {
+			storer.NewMultiEncodedObjectIter(iters).Close()
+			return nil, err
+		}
+
+		// The objects are skipped when found in a previous object directory.
+		previous := append([]*ObjectStorage{s}, alts[:i]...)
+		iters = append(iters, &alternateObjectsIter{iter: altIter, skip: func(h plumbing.Hash) bool {
+			for _, p := range previous {
+				if p.hasEncodedObject(h) == nil {
+					return true
+				}
+			}
+
+			return false
+		}})
+	}
+
+	return storer.NewMultiEncodedObjectIter(iters), nil
+}
+
+func (s *ObjectStorage) iterEncodedObjects(t plumbing.ObjectType) (storer.EncodedObjectIter, error) {
 	objects, err := s.dir.Objects()
 	if err != nil {
 		return nil, err
@@ -628,7 +1169,7 @@ func (s *ObjectStorage) buildPackfileIters(
 	t plumbing.ObjectType,
 	seen map[plumbing.Hash]struct{},
 ) (storer.EncodedObjectIter, error) {
-	if err := s.requireIndex(); err != nil {
+	if err := s.requireAllIndexes(); err != nil {
 		return nil, err
 	}
 
@@ -645,7 +1186,8 @@ func (s *ObjectStorage) buildPackfileIters(
 			}
 			return newPackfileIter(
 				s.dir.Fs(), pack, t, seen, s.index[h],
-				s.objectCache, s.options.KeepDescriptors, crypto.SHA1.Size(),
+				s.objectCache, s.options.KeepDescriptors, s.options.LargeObjectThreshold,
+				s.options.ObjectFormat.Size(),
 			)
 		},
 	}, nil
@@ -668,6 +1210,14 @@ func (s *ObjectStorage) Close() error {
 	}
 
 	s.packfiles = nil
+	if err := s.closeMultiPackIndex(); firstError == nil && err != nil {
+		firstError = err
+	}
+
+	if err := s.resetAlternates(); firstError == nil && err != nil {
+		firstError = err
+	}
+
 	s.dir.Close()
 
 	return firstError
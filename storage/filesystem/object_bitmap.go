@@ -0,0 +1,84 @@
+package filesystem
+
+import (
+	"errors"
+
+	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/go-git/go-git/v6/plumbing/format/bitmap"
+	"github.com/go-git/go-git/v6/storage/filesystem/dotgit"
+	"github.com/go-git/go-git/v6/utils/ioutil"
+)
+
+// ReachabilityBitmap implements the storer.BitmapStorer interface. It returns
+// the bitmap of the first packfile having one, as git only writes a bitmap
+// for a single packfile. A bitmap that can't be read, or that doesn't match
+// its packfile, is ignored.
+func (s *ObjectStorage) ReachabilityBitmap() (*bitmap.Index, error) {
+	s.muB.Lock()
+	defer s.muB.Unlock()
+
+	if s.bitmapLoaded {
+		return s.bitmap, nil
+	}
+
+	packs, err := s.dir.ObjectPacks()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.requireIndex(); err != nil {
+		return nil, err
+	}
+
+	for _, h := range packs {
+		b, err := s.loadBitmap(h)
+		if err != nil {
+			return nil, err
+		}
+
+		if b != nil {
+			s.bitmap = b
+			break
+		}
+	}
+
+	s.bitmapLoaded = true
+	return s.bitmap, nil
+}
+
+// loadBitmap returns the bitmap of the given packfile, or nil if it doesn't
+// have one or it can't be read.
+func (s *ObjectStorage) loadBitmap(pack plumbing.Hash) (_ *bitmap.Index, err error) {
+	f, err := s.dir.ObjectPackBitmap(pack)
+	if errors.Is(err, dotgit.ErrPackfileNotFound) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer ioutil.CheckClose(f, &err)
+
+	idx, err := s.packIndex(pack)
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := bitmap.Decode(f, idx)
+	if err != nil {
+		return nil, nil
+	}
+
+	return b, nil
+}
+
+// resetBitmap drops the reachability bitmap, so it is loaded again on the
+// next call to ReachabilityBitmap.
+func (s *ObjectStorage) resetBitmap() {
+	s.muB.Lock()
+	defer s.muB.Unlock()
+
+	s.bitmap = nil
+	s.bitmapLoaded = false
+}
@@ -0,0 +1,110 @@
+package filesystem
+
+import (
+	"os"
+	"time"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/util"
+	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/go-git/go-git/v6/plumbing/cache"
+	"github.com/go-git/go-git/v6/plumbing/format/bitmap"
+	"github.com/go-git/go-git/v6/plumbing/revlist"
+
+	fixtures "github.com/go-git/go-git-fixtures/v5"
+)
+
+// writeTestBitmap writes a bitmap of the packfile of the basic fixture, with
+// the bitmap of its HEAD, and returns its path.
+func (s *FsSuite) writeTestBitmap(fs billy.Filesystem, f *fixtures.Fixture) string {
+	o := NewStorage(fs, cache.NewObjectLRUDefault())
+	defer o.Close()
+
+	s.Require().NoError(o.requireIndex())
+	idx, err := o.packIndex(plumbing.NewHash(f.PackfileHash))
+	s.Require().NoError(err)
+
+	order, err := bitmap.PackOrder(idx)
+	s.Require().NoError(err)
+
+	types := make(map[plumbing.Hash]plumbing.ObjectType)
+	positions := make(map[plumbing.Hash]uint32)
+	for pos, h := range order {
+		obj, err := o.EncodedObject(plumbing.AnyObject, h)
+		s.Require().NoError(err)
+		types[h] = obj.Type()
+		positions[h] = uint32(pos)
+	}
+
+	head := plumbing.NewHash(f.Head)
+	hashes, err := revlist.Objects(o, []plumbing.Hash{head}, nil)
+	s.Require().NoError(err)
+
+	b := bitmap.NewBitmap()
+	for _, h := range hashes {
+		b.Set(positions[h])
+	}
+
+	path := fs.Join("objects", "pack", "pack-"+f.PackfileHash+".bitmap")
+	w, err := fs.Create(path)
+	s.Require().NoError(err)
+	s.Require().NoError(bitmap.NewEncoder(w).Encode(idx, plumbing.NewHash(f.PackfileHash), types, []bitmap.Entry{
+		{Commit: head, Bitmap: b},
+	}))
+	s.Require().NoError(w.Close())
+	return path
+}
+
+func (s *FsSuite) TestReachabilityBitmap() {
+	f := fixtures.Basic().One()
+	fs := f.DotGit()
+	o := NewStorage(fs, cache.NewObjectLRUDefault())
+	defer o.Close()
+
+	b, err := o.ReachabilityBitmap()
+	s.Require().NoError(err)
+	s.Nil(b)
+
+	expected, err := revlist.Objects(o, []plumbing.Hash{plumbing.NewHash(f.Head)}, nil)
+	s.Require().NoError(err)
+
+	s.writeTestBitmap(fs, f)
+	o.Reindex()
+
+	b, err = o.ReachabilityBitmap()
+	s.Require().NoError(err)
+	s.Require().NotNil(b)
+	s.Equal([]plumbing.Hash{plumbing.NewHash(f.Head)}, b.Commits())
+
+	result, err := revlist.Objects(o, []plumbing.Hash{plumbing.NewHash(f.Head)}, nil)
+	s.Require().NoError(err)
+	s.ElementsMatch(expected, result)
+}
+
+func (s *FsSuite) TestReachabilityBitmapMalformed() {
+	f := fixtures.Basic().One()
+	fs := f.DotGit()
+
+	path := s.writeTestBitmap(fs, f)
+	s.Require().NoError(util.WriteFile(fs, path, []byte("BITM"), 0o644))
+
+	o := NewStorage(fs, cache.NewObjectLRUDefault())
+	defer o.Close()
+
+	b, err := o.ReachabilityBitmap()
+	s.NoError(err)
+	s.Nil(b)
+}
+
+func (s *FsSuite) TestDeleteOldObjectPackAndIndexRemovesBitmap() {
+	f := fixtures.Basic().One()
+	fs := f.DotGit()
+	path := s.writeTestBitmap(fs, f)
+
+	o := NewStorage(fs, cache.NewObjectLRUDefault())
+	defer o.Close()
+
+	s.Require().NoError(o.DeleteOldObjectPackAndIndex(plumbing.NewHash(f.PackfileHash), time.Time{}))
+	_, err := fs.Stat(path)
+	s.ErrorIs(err, os.ErrNotExist)
+}
@@ -0,0 +1,105 @@
+package filesystem
+
+import (
+	"encoding/binary"
+	"io"
+
+	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/go-git/go-git/v6/plumbing/format/idxfile"
+)
+
+const (
+	// objectFilterBitsPerObject and objectFilterHashes give a false positive
+	// rate of about 1% while the filter holds no more objects than its
+	// capacity.
+	objectFilterBitsPerObject = 10
+	objectFilterHashes        = 7
+	objectFilterMinBits       = 1 << 12
+)
+
+// objectFilter is a bloom filter of the hashes of the packed objects of a
+// repository. It answers whether an object may be in any of the packfiles
+// without looking it up in every index: a negative answer is definitive,
+// while a positive one must be confirmed by the lookup.
+type objectFilter struct {
+	bits     []uint64
+	mask     uint64
+	count    int
+	capacity int
+}
+
+// newObjectFilter returns an empty filter sized to hold the given number of
+// objects.
+func newObjectFilter(capacity int) *objectFilter {
+	n := uint64(objectFilterMinBits)
+	for n < uint64(capacity)*objectFilterBitsPerObject {
+		n <<= 1
+	}
+
+	return &objectFilter{
+		bits:     make([]uint64, n/64),
+		mask:     n - 1,
+		capacity: int(n / objectFilterBitsPerObject),
+	}
+}
+
+// Add adds the hash to the filter.
+func (f *objectFilter) Add(h plumbing.Hash) {
+	h1, h2 := objectFilterHashPair(h)
+	for i := uint64(0); i < objectFilterHashes; i++ {
+		bit := (h1 + i*h2) & f.mask
+		f.bits[bit/64] |= 1 << (bit % 64)
+	}
+
+	f.count++
+}
+
+// MayContain returns false if the hash was never added to the filter.
+func (f *objectFilter) MayContain(h plumbing.Hash) bool {
+	h1, h2 := objectFilterHashPair(h)
+	for i := uint64(0); i < objectFilterHashes; i++ {
+		bit := (h1 + i*h2) & f.mask
+		if f.bits[bit/64]&(1<<(bit%64)) == 0 {
+			return false
+		}
+	}
+
+	return true
+}
+
+// AddIndex adds the hashes of all the objects of a packfile index.
+func (f *objectFilter) AddIndex(idx idxfile.Index) error {
+	iter, err := idx.Entries()
+	if err != nil {
+		return err
+	}
+
+	defer iter.Close()
+	for {
+		e, err := iter.Next()
+		if err == io.EOF {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		f.Add(e.Hash)
+	}
+}
+
+// Fits returns true if n more objects can be added without exceeding the
+// capacity of the filter.
+func (f *objectFilter) Fits(n int) bool {
+	return f.count+n <= f.capacity
+}
+
+// objectFilterHashPair derives the two hashes used to compute the bits of an
+// object from its hash, which is already uniformly distributed. The second
+// one is odd so the bits computed don't repeat.
+func objectFilterHashPair(h plumbing.Hash) (uint64, uint64) {
+	var b [16]byte
+	copy(b[:], h.Bytes())
+	return binary.LittleEndian.Uint64(b[:8]), binary.LittleEndian.Uint64(b[8:]) | 1
+}
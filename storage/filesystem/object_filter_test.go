@@ -0,0 +1,156 @@
+package filesystem
+
+import (
+	"crypto/sha1"
+	"fmt"
+	"testing"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/osfs"
+	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/go-git/go-git/v6/plumbing/cache"
+	"github.com/go-git/go-git/v6/plumbing/format/packfile"
+	"github.com/go-git/go-git/v6/storage/filesystem/dotgit"
+	"github.com/go-git/go-git/v6/storage/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	fixtures "github.com/go-git/go-git-fixtures/v5"
+)
+
+func testFilterHash(i int) plumbing.Hash {
+	sum := sha1.Sum([]byte(fmt.Sprintf("object %d", i)))
+	h, _ := plumbing.FromBytes(sum[:])
+	return h
+}
+
+func TestObjectFilter(t *testing.T) {
+	t.Parallel()
+
+	const n = 1000
+	f := newObjectFilter(n)
+	for i := 0; i < n; i++ {
+		f.Add(testFilterHash(i))
+	}
+
+	for i := 0; i < n; i++ {
+		assert.True(t, f.MayContain(testFilterHash(i)))
+	}
+
+	var positives int
+	for i := n; i < 2*n; i++ {
+		if f.MayContain(testFilterHash(i)) {
+			positives++
+		}
+	}
+
+	assert.Less(t, positives, n/20)
+	assert.True(t, f.Fits(f.capacity-n))
+	assert.False(t, f.Fits(f.capacity-n+1))
+}
+
+// writeTestPacks writes the given number of packfiles to the storage, each
+// one with its own blobs, and returns the hashes of the blobs.
+func writeTestPacks(tb testing.TB, o *ObjectStorage, packs, objects int) []plumbing.Hash {
+	var all []plumbing.Hash
+	for p := 0; p < packs; p++ {
+		mem := memory.NewStorage()
+		hashes := make([]plumbing.Hash, 0, objects)
+		for i := 0; i < objects; i++ {
+			obj := mem.NewEncodedObject()
+			obj.SetType(plumbing.BlobObject)
+			w, err := obj.Writer()
+			require.NoError(tb, err)
+			_, err = fmt.Fprintf(w, "pack %d object %d\n", p, i)
+			require.NoError(tb, err)
+			require.NoError(tb, w.Close())
+
+			h, err := mem.SetEncodedObject(obj)
+			require.NoError(tb, err)
+			hashes = append(hashes, h)
+		}
+
+		w, err := o.PackfileWriter()
+		require.NoError(tb, err)
+		_, err = packfile.NewEncoder(w, mem, false).Encode(hashes, 0)
+		require.NoError(tb, err)
+		require.NoError(tb, w.Close())
+
+		all = append(all, hashes...)
+	}
+
+	return all
+}
+
+func newTestObjectStorage(fs billy.Filesystem, filter bool) *ObjectStorage {
+	return NewObjectStorageWithOptions(dotgit.New(fs), cache.NewObjectLRUDefault(), Options{ObjectFilter: filter})
+}
+
+func (s *FsSuite) TestObjectFilter() {
+	fs := fixtures.Basic().One().DotGit()
+	o := newTestObjectStorage(fs, true)
+
+	s.NoError(o.HasEncodedObject(plumbing.NewHash("6ecf0ef2c2dffb796033e5a02219af86ec6584e5")))
+	s.NotNil(o.filter)
+
+	missing := plumbing.NewHash("0000000000000000000000000000000000000001")
+	s.ErrorIs(o.HasEncodedObject(missing), plumbing.ErrObjectNotFound)
+
+	_, err := o.EncodedObject(plumbing.AnyObject, missing)
+	s.ErrorIs(err, plumbing.ErrObjectNotFound)
+
+	// The objects of a packfile written are added to the filter.
+	written := writeTestPacks(s.T(), o, 2, 10)
+	s.NotNil(o.filter)
+	for _, h := range written {
+		s.NoError(o.HasEncodedObject(h))
+	}
+
+	o.Reindex()
+	s.Nil(o.filter)
+	for _, h := range written {
+		obj, err := o.EncodedObject(plumbing.BlobObject, h)
+		s.Require().NoError(err)
+		s.Equal(h, obj.Hash())
+	}
+
+	s.NotNil(o.filter)
+}
+
+func (s *FsSuite) TestObjectFilterGrows() {
+	o := newTestObjectStorage(osfs.New(s.T().TempDir()), true)
+	written := writeTestPacks(s.T(), o, 1, 10)
+	s.NoError(o.HasEncodedObject(written[0]))
+	s.Require().NotNil(o.filter)
+
+	// A packfile which doesn't fit drops the filter, so it's built again
+	// with a larger size.
+	written = append(written, writeTestPacks(s.T(), o, 1, o.filter.capacity)...)
+	s.Nil(o.filter)
+
+	for _, h := range written {
+		s.NoError(o.HasEncodedObject(h))
+	}
+
+	s.GreaterOrEqual(o.filter.capacity, len(written))
+}
+
+func BenchmarkHasEncodedObjectManyPacks(b *testing.B) {
+	fs := osfs.New(b.TempDir())
+	writeTestPacks(b, newTestObjectStorage(fs, false), 200, 50)
+
+	for _, filter := range []bool{false, true} {
+		b.Run(fmt.Sprintf("filter=%t", filter), func(b *testing.B) {
+			o := newTestObjectStorage(fs, filter)
+			defer o.Close()
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				err := o.HasEncodedObject(testFilterHash(i))
+				if err != plumbing.ErrObjectNotFound {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -90,7 +90,7 @@ func NewPackfileIter(
 	}
 
 	seen := make(map[plumbing.Hash]struct{})
-	return newPackfileIter(fs, f, t, seen, idx, nil, keepPack, objectIDSize)
+	return newPackfileIter(fs, f, t, seen, idx, nil, keepPack, largeObjectThreshold, objectIDSize)
 }
 
 func newPackfileIter(
@@ -101,6 +101,7 @@ func newPackfileIter(
 	index idxfile.Index,
 	cache cache.Object,
 	keepPack bool,
+	largeObjectThreshold int64,
 	objectIDSize int,
 ) (storer.EncodedObjectIter, error) {
 	p := packfile.NewPackfile(f,
@@ -108,6 +109,7 @@ func newPackfileIter(
 		packfile.WithCache(cache),
 		packfile.WithIdx(index),
 		packfile.WithObjectIDSize(objectIDSize),
+		packfile.WithLargeObjectThreshold(largeObjectThreshold),
 	)
 
 	iter, err := p.GetByType(t)
@@ -206,3 +208,31 @@ func (iter *objectsIter) ForEach(cb func(plumbing.EncodedObject) error) error {
 func (iter *objectsIter) Close() {
 	iter.h = []plumbing.Hash{}
 }
+
+// alternateObjectsIter iterates the objects of an alternate object directory,
+// skipping the ones already returned from another object directory.
+type alternateObjectsIter struct {
+	iter storer.EncodedObjectIter
+	skip func(plumbing.Hash) bool
+}
+
+func (iter *alternateObjectsIter) Next() (plumbing.EncodedObject, error) {
+	for {
+		obj, err := iter.iter.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		if !iter.skip(obj.Hash()) {
+			return obj, nil
+		}
+	}
+}
+
+func (iter *alternateObjectsIter) ForEach(cb func(plumbing.EncodedObject) error) error {
+	return storer.ForEachIterator(iter, cb)
+}
+
+func (iter *alternateObjectsIter) Close() {
+	iter.iter.Close()
+}
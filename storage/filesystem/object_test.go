@@ -1,17 +1,25 @@
 package filesystem
 
 import (
+	"bytes"
 	"crypto"
 	"encoding/hex"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
+	"slices"
 	"testing"
 
 	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/go-git/go-billy/v5/osfs"
+	"github.com/go-git/go-billy/v5/util"
+	"github.com/go-git/go-git/v6/config"
 	"github.com/go-git/go-git/v6/plumbing"
 	"github.com/go-git/go-git/v6/plumbing/cache"
+	"github.com/go-git/go-git/v6/plumbing/format/idxfile"
+	"github.com/go-git/go-git/v6/plumbing/format/midx"
 	"github.com/go-git/go-git/v6/storage/filesystem/dotgit"
 	"github.com/stretchr/testify/suite"
 
@@ -197,6 +205,55 @@ func (s *FsSuite) TestGetFromPackfileMultiplePackfilesLargeObjectThreshold() {
 	s.Equal(expected, obj.Hash())
 }
 
+func (s *FsSuite) TestBatchRead() {
+	fs := fixtures.ByTag(".git").ByTag("multi-packfile").One().DotGit()
+	o := NewObjectStorage(dotgit.New(fs), cache.NewObjectLRUDefault())
+
+	hashes := batchReadHashes(s.T(), o)
+	s.Require().NotEmpty(hashes)
+
+	objs, err := o.BatchRead(hashes)
+	s.Require().NoError(err)
+	s.Require().Len(objs, len(hashes))
+
+	for i, h := range hashes {
+		expected, err := o.EncodedObject(plumbing.AnyObject, h)
+		s.Require().NoError(err)
+
+		s.Equal(h, objs[i].Hash())
+		s.Equal(expected.Type(), objs[i].Type())
+		s.Equal(expected.Size(), objs[i].Size())
+	}
+}
+
+func (s *FsSuite) TestBatchReadNotFound() {
+	fs := fixtures.ByTag(".git").ByTag("multi-packfile").One().DotGit()
+	o := NewObjectStorage(dotgit.New(fs), cache.NewObjectLRUDefault())
+
+	objs, err := o.BatchRead([]plumbing.Hash{
+		plumbing.NewHash("8d45a34641d73851e01d3754320b33bb5be3c4d3"),
+		plumbing.NewHash("0000000000000000000000000000000000000001"),
+	})
+	s.ErrorIs(err, plumbing.ErrObjectNotFound)
+	s.Nil(objs)
+}
+
+// batchReadHashes returns the hashes of all the objects of the storage, in
+// reverse order, so they aren't sorted by packfile nor offset.
+func batchReadHashes(t testing.TB, o *ObjectStorage) []plumbing.Hash {
+	var hashes []plumbing.Hash
+	err := o.ForEachObjectHash(func(h plumbing.Hash) error {
+		hashes = append(hashes, h)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	slices.Reverse(hashes)
+	return hashes
+}
+
 func (s *FsSuite) TestIter() {
 	for _, f := range fixtures.ByTag(".git").ByTag("packfile") {
 		fs := f.DotGit()
@@ -554,6 +611,31 @@ func BenchmarkGetObjectFromPackfile(b *testing.B) {
 	}
 }
 
+func BenchmarkBatchRead(b *testing.B) {
+	fs := fixtures.ByTag(".git").ByTag("multi-packfile").One().DotGit()
+	hashes := batchReadHashes(b, NewObjectStorage(dotgit.New(fs), cache.NewObjectLRUDefault()))
+
+	b.Run("EncodedObject", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			o := NewObjectStorage(dotgit.New(fs), cache.NewObjectLRUDefault())
+			for _, h := range hashes {
+				if _, err := o.EncodedObject(plumbing.AnyObject, h); err != nil {
+					b.Fatal(err)
+				}
+			}
+		}
+	})
+
+	b.Run("BatchRead", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			o := NewObjectStorage(dotgit.New(fs), cache.NewObjectLRUDefault())
+			if _, err := o.BatchRead(hashes); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
 func (s *FsSuite) TestGetFromUnpackedCachesObjects() {
 	fs := fixtures.ByTag(".git").ByTag("unpacked").One().DotGit()
 	objectCache := cache.NewObjectLRUDefault()
@@ -614,3 +696,244 @@ func (s *FsSuite) TestGetFromUnpackedDoesNotCacheLargeObjects() {
 	_, ok = objectCache.Get(hash)
 	s.False(ok)
 }
+
+// writeMultiPackIndex writes a multi-pack-index of the given packfiles, and
+// of the extra packfile names, to the repository.
+func (s *FsSuite) writeMultiPackIndex(fs billy.Filesystem, packs []plumbing.Hash, extra ...string) {
+	dir := dotgit.New(fs)
+
+	var mp []midx.Pack
+	for _, h := range packs {
+		f, err := dir.ObjectPackIdx(h)
+		s.Require().NoError(err)
+
+		idx := idxfile.NewMemoryIndex(h.Size())
+		s.Require().NoError(idxfile.NewDecoder(f).Decode(idx))
+		s.Require().NoError(f.Close())
+
+		mp = append(mp, midx.Pack{Name: fmt.Sprintf("pack-%s.idx", h), Index: idx})
+	}
+
+	for _, name := range extra {
+		mp = append(mp, midx.Pack{Name: name, Index: idxfile.NewMemoryIndex(crypto.SHA1.Size())})
+	}
+
+	f, err := fs.Create(filepath.Join("objects", "pack", "multi-pack-index"))
+	s.Require().NoError(err)
+	s.Require().NoError(midx.NewEncoder(f).Encode(mp))
+	s.Require().NoError(f.Close())
+}
+
+func (s *FsSuite) TestGetFromPackfileMultiPackIndex() {
+	fs := fixtures.ByTag(".git").ByTag("multi-packfile").One().DotGit()
+	packs, err := dotgit.New(fs).ObjectPacks()
+	s.Require().NoError(err)
+	s.Require().Greater(len(packs), 1)
+	s.writeMultiPackIndex(fs, packs)
+
+	o := NewObjectStorage(dotgit.New(fs), cache.NewObjectLRUDefault())
+	s.Require().NoError(o.requireIndex())
+	s.NotNil(o.midx)
+	s.Len(o.midxPacks, len(packs))
+	s.Empty(o.index)
+
+	expected := plumbing.NewHash("8d45a34641d73851e01d3754320b33bb5be3c4d3")
+	obj, err := o.getFromPackfile(expected, false)
+	s.NoError(err)
+	s.Equal(expected, obj.Hash())
+	s.Len(o.index, 1)
+
+	expected = plumbing.NewHash("e9cfa4c9ca160546efd7e8582ec77952a27b17db")
+	obj, err = o.getFromPackfile(expected, false)
+	s.NoError(err)
+	s.Equal(expected, obj.Hash())
+
+	size, err := o.EncodedObjectSize(expected)
+	s.NoError(err)
+	s.Equal(obj.Size(), size)
+
+	hashes, err := o.HashesWithPrefix(expected.Bytes()[:2])
+	s.NoError(err)
+	s.Contains(hashes, expected)
+	s.Len(o.index, len(packs))
+
+	s.NoError(o.Close())
+	s.Nil(o.midx)
+}
+
+func (s *FsSuite) TestGetFromPackfileMultiPackIndexPartial() {
+	fs := fixtures.ByTag(".git").ByTag("multi-packfile").One().DotGit()
+	packs, err := dotgit.New(fs).ObjectPacks()
+	s.Require().NoError(err)
+
+	// Packfiles added after the multi-pack-index was written are indexed by
+	// their own idx file.
+	s.writeMultiPackIndex(fs, packs[:1])
+
+	o := NewObjectStorage(dotgit.New(fs), cache.NewObjectLRUDefault())
+	s.Require().NoError(o.requireIndex())
+	s.NotNil(o.midx)
+	s.Len(o.index, len(packs)-1)
+
+	for _, h := range []string{"8d45a34641d73851e01d3754320b33bb5be3c4d3", "e9cfa4c9ca160546efd7e8582ec77952a27b17db"} {
+		obj, err := o.EncodedObject(plumbing.AnyObject, plumbing.NewHash(h))
+		s.NoError(err)
+		s.Equal(h, obj.Hash().String())
+	}
+}
+
+func (s *FsSuite) TestGetFromPackfileMultiPackIndexStale() {
+	fs := fixtures.ByTag(".git").ByTag("multi-packfile").One().DotGit()
+	packs, err := dotgit.New(fs).ObjectPacks()
+	s.Require().NoError(err)
+
+	// The multi-pack-index references a packfile removed by a repack.
+	s.writeMultiPackIndex(fs, packs, "pack-0000000000000000000000000000000000000001.idx")
+
+	o := NewObjectStorage(dotgit.New(fs), cache.NewObjectLRUDefault())
+	s.Require().NoError(o.requireIndex())
+	s.Nil(o.midx)
+	s.Len(o.index, len(packs))
+
+	expected := plumbing.NewHash("8d45a34641d73851e01d3754320b33bb5be3c4d3")
+	obj, err := o.EncodedObject(plumbing.AnyObject, expected)
+	s.NoError(err)
+	s.Equal(expected, obj.Hash())
+}
+
+func (s *FsSuite) TestGetFromPackfileMultiPackIndexMalformed() {
+	fs := fixtures.Basic().ByTag(".git").One().DotGit()
+	s.Require().NoError(util.WriteFile(fs, filepath.Join("objects", "pack", "multi-pack-index"), []byte("MIDX garbage"), 0o644))
+
+	o := NewObjectStorage(dotgit.New(fs), cache.NewObjectLRUDefault())
+
+	expected := plumbing.NewHash("6ecf0ef2c2dffb796033e5a02219af86ec6584e5")
+	obj, err := o.EncodedObject(plumbing.AnyObject, expected)
+	s.NoError(err)
+	s.Equal(expected, obj.Hash())
+	s.Nil(o.midx)
+}
+
+func (s *FsSuite) TestAlternates() {
+	root := osfs.New(s.T().TempDir())
+	storage := func(name string) *Storage {
+		fs, err := root.Chroot(name)
+		s.Require().NoError(err)
+
+		st := NewStorageWithOptions(fs, cache.NewObjectLRUDefault(), Options{AlternatesFS: root})
+		s.Require().NoError(st.Init())
+		return st
+	}
+
+	blob := func(st *Storage, content string) plumbing.Hash {
+		obj := st.NewEncodedObject()
+		obj.SetType(plumbing.BlobObject)
+		_, err := obj.(*plumbing.MemoryObject).Write([]byte(content))
+		s.Require().NoError(err)
+
+		h, err := st.SetEncodedObject(obj)
+		s.Require().NoError(err)
+		return h
+	}
+
+	a, b, c := storage("a"), storage("b"), storage("c")
+	inA, inB, inC := blob(a, "a"), blob(b, "b"), blob(c, "c")
+
+	// a borrows from b, which borrows from c, which borrows from a again
+	// through a relative path.
+	s.Require().NoError(a.AddAlternate(filepath.Join(root.Root(), "b")))
+	s.Require().NoError(b.AddAlternate(filepath.Join(root.Root(), "c")))
+	s.Require().NoError(util.WriteFile(root, "c/objects/info/alternates", []byte("../../a/objects\n"), 0o644))
+
+	for _, h := range []plumbing.Hash{inA, inB, inC} {
+		obj, err := a.EncodedObject(plumbing.BlobObject, h)
+		s.NoError(err)
+		s.Equal(h, obj.Hash())
+		s.NoError(a.HasEncodedObject(h))
+
+		size, err := a.EncodedObjectSize(h)
+		s.NoError(err)
+		s.Equal(int64(1), size)
+
+		hashes, err := a.HashesWithPrefix(h.Bytes()[:4])
+		s.NoError(err)
+		s.Equal([]plumbing.Hash{h}, hashes)
+	}
+
+	missing := plumbing.NewHash("0000000000000000000000000000000000000001")
+	_, err := a.EncodedObject(plumbing.AnyObject, missing)
+	s.ErrorIs(err, plumbing.ErrObjectNotFound)
+	s.ErrorIs(a.HasEncodedObject(missing), plumbing.ErrObjectNotFound)
+
+	// The objects found in several object directories are returned once.
+	blob(a, "c")
+
+	iter, err := a.IterEncodedObjects(plumbing.AnyObject)
+	s.Require().NoError(err)
+
+	var found []plumbing.Hash
+	s.NoError(iter.ForEach(func(o plumbing.EncodedObject) error {
+		found = append(found, o.Hash())
+		return nil
+	}))
+	s.ElementsMatch([]plumbing.Hash{inA, inB, inC}, found)
+
+	// The objects are written to the repository only, the ones of a are only
+	// found by b through the cycle.
+	s.NoError(b.HasEncodedObject(inA))
+	s.ErrorIs(b.hasEncodedObject(inA), plumbing.ErrObjectNotFound)
+	s.ErrorIs(c.hasEncodedObject(inA), plumbing.ErrObjectNotFound)
+}
+
+func (s *FsSuite) TestSetEncodedObjectLooseCompression() {
+	fs := memfs.New()
+	sto := NewStorage(fs, cache.NewObjectLRUDefault())
+	s.Require().NoError(sto.Init())
+
+	// levelHeader writes a new object and returns the second byte of its
+	// zlib header, which tells the compression level.
+	var n int
+	levelHeader := func() byte {
+		n++
+		content := bytes.Repeat([]byte("foo\n"), n)
+
+		o := sto.NewEncodedObject()
+		o.SetType(plumbing.BlobObject)
+		o.SetSize(int64(len(content)))
+		w, err := o.Writer()
+		s.Require().NoError(err)
+		_, err = w.Write(content)
+		s.Require().NoError(err)
+		s.Require().NoError(w.Close())
+
+		h, err := sto.SetEncodedObject(o)
+		s.Require().NoError(err)
+
+		f, err := fs.Open(fs.Join("objects", h.String()[:2], h.String()[2:]))
+		s.Require().NoError(err)
+		defer f.Close()
+
+		header := make([]byte, 2)
+		_, err = io.ReadFull(f, header)
+		s.Require().NoError(err)
+
+		obj, err := sto.EncodedObject(plumbing.BlobObject, h)
+		s.Require().NoError(err)
+		s.Equal(int64(len(content)), obj.Size())
+		return header[1]
+	}
+
+	s.Equal(byte(0x01), levelHeader())
+
+	cfg, err := sto.Config()
+	s.Require().NoError(err)
+	cfg.Core.LooseCompression = 9
+	s.Require().NoError(sto.SetConfig(cfg))
+	s.Equal(byte(0xda), levelHeader())
+
+	s.Require().NoError(sto.UpdateConfig(func(cfg *config.Config) error {
+		cfg.Core.LooseCompression = -1
+		return nil
+	}))
+	s.Equal(byte(0x9c), levelHeader())
+}
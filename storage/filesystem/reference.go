@@ -22,6 +22,12 @@ func (r *ReferenceStorage) Reference(n plumbing.ReferenceName) (*plumbing.Refere
 	return r.dir.Ref(n)
 }
 
+// PeeledReference returns the hash the given reference peels to, when it is
+// recorded in the packed-refs file.
+func (r *ReferenceStorage) PeeledReference(n plumbing.ReferenceName) (plumbing.Hash, bool, error) {
+	return r.dir.PeeledRef(n)
+}
+
 func (r *ReferenceStorage) IterReferences() (storer.ReferenceIter, error) {
 	refs, err := r.dir.Refs()
 	if err != nil {
@@ -31,8 +37,19 @@ func (r *ReferenceStorage) IterReferences() (storer.ReferenceIter, error) {
 	return storer.NewReferenceSliceIter(refs), nil
 }
 
+// RemoveReference removes the reference and its log.
 func (r *ReferenceStorage) RemoveReference(n plumbing.ReferenceName) error {
-	return r.dir.RemoveRef(n)
+	if err := r.dir.RemoveRef(n); err != nil {
+		return err
+	}
+
+	return r.dir.RemoveReflog(n)
+}
+
+// BeginReferenceTransaction starts a transaction updating several references
+// atomically, by rewriting the packed-refs file.
+func (r *ReferenceStorage) BeginReferenceTransaction() storer.ReferenceTransaction {
+	return storer.NewReferenceTransaction(r.dir.UpdateRefs)
 }
 
 func (r *ReferenceStorage) CountLooseRefs() (int, error) {
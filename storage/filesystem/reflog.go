@@ -0,0 +1,41 @@
+package filesystem
+
+import (
+	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/go-git/go-git/v6/plumbing/format/reflog"
+	"github.com/go-git/go-git/v6/storage/filesystem/dotgit"
+	"github.com/go-git/go-git/v6/utils/ioutil"
+)
+
+// ReflogStorage stores the logs of the references in the logs folder of
+// .git, as git does.
+type ReflogStorage struct {
+	dir *dotgit.DotGit
+}
+
+// Reflog returns the entries of the log of the given reference, oldest first.
+func (s *ReflogStorage) Reflog(name plumbing.ReferenceName) (entries []*reflog.Entry, err error) {
+	f, err := s.dir.Reflog(name)
+	if f == nil || err != nil {
+		return nil, err
+	}
+
+	defer ioutil.CheckClose(f, &err)
+	return reflog.NewDecoder(f).DecodeAll()
+}
+
+// AppendReflog appends an entry to the log of the given reference.
+func (s *ReflogStorage) AppendReflog(name plumbing.ReferenceName, e *reflog.Entry) (err error) {
+	f, err := s.dir.ReflogWriter(name)
+	if err != nil {
+		return err
+	}
+
+	defer ioutil.CheckClose(f, &err)
+	return reflog.NewEncoder(f).Encode(e)
+}
+
+// RemoveReflog removes the log of the given reference.
+func (s *ReflogStorage) RemoveReflog(name plumbing.ReferenceName) error {
+	return s.dir.RemoveReflog(name)
+}
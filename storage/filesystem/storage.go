@@ -2,7 +2,9 @@
 package filesystem
 
 import (
+	"github.com/go-git/go-git/v6/config"
 	"github.com/go-git/go-git/v6/plumbing/cache"
+	format "github.com/go-git/go-git/v6/plumbing/format/config"
 	"github.com/go-git/go-git/v6/storage/filesystem/dotgit"
 
 	"github.com/go-git/go-billy/v5"
@@ -17,8 +19,10 @@ type Storage struct {
 
 	ObjectStorage
 	ReferenceStorage
+	ReflogStorage
 	IndexStorage
 	ShallowStorage
+	FetchHeadStorage
 	ConfigStorage
 	ModuleStorage
 }
@@ -35,12 +39,27 @@ type Options struct {
 	// open. If KeepDescriptors is true, all file descriptors will remain open.
 	MaxOpenDescriptors int
 	// LargeObjectThreshold maximum object size (in bytes) that will be read in to memory.
+	// Bigger objects, loose or packed, including the deltified ones, are
+	// streamed from the filesystem when read instead.
 	// If left unset or set to 0 there is no limit
 	LargeObjectThreshold int64
 	// AlternatesFS provides the billy filesystem to be used for Git Alternates.
 	// If none is provided, it falls back to using the underlying instance used for
 	// DotGit.
 	AlternatesFS billy.Filesystem
+	// ObjectFormat is the format used to hash the objects of the repository.
+	// If left unset SHA1 is used.
+	ObjectFormat format.ObjectFormat
+	// ObjectCacheSize is the maximum number of bytes held by the object cache
+	// created when no cache is given, see cache.NewObjectLRUBytes. If left
+	// unset a cache of cache.DefaultMaxSize bytes is used.
+	ObjectCacheSize cache.FileSize
+	// ObjectFilter keeps in memory a bloom filter of the hashes of the packed
+	// objects, so looking up an object which isn't packed doesn't search the
+	// index of every packfile. It pays off with many packfiles, at the cost
+	// of about 10 bits per object. The filter is updated when a packfile is
+	// written, and built again by Reindex.
+	ObjectFilter bool
 }
 
 // NewStorage returns a new Storage backed by a given `fs.Filesystem` and cache.
@@ -54,11 +73,16 @@ func NewStorageWithOptions(fs billy.Filesystem, c cache.Object, ops Options) *St
 	dirOps := dotgit.Options{
 		ExclusiveAccess: ops.ExclusiveAccess,
 		AlternatesFS:    ops.AlternatesFS,
+		ObjectFormat:    ops.ObjectFormat,
 	}
 	dir := dotgit.NewWithOptions(fs, dirOps)
 
 	if c == nil {
-		c = cache.NewObjectLRUDefault()
+		if ops.ObjectCacheSize > 0 {
+			c = cache.NewObjectLRUBytes(ops.ObjectCacheSize)
+		} else {
+			c = cache.NewObjectLRUDefault()
+		}
 	}
 
 	return &Storage{
@@ -67,8 +91,10 @@ func NewStorageWithOptions(fs billy.Filesystem, c cache.Object, ops Options) *St
 
 		ObjectStorage:    *NewObjectStorageWithOptions(dir, c, ops),
 		ReferenceStorage: ReferenceStorage{dir: dir},
-		IndexStorage:     IndexStorage{dir: dir},
+		ReflogStorage:    ReflogStorage{dir: dir},
+		IndexStorage:     IndexStorage{dir: dir, objectFormat: ops.ObjectFormat},
 		ShallowStorage:   ShallowStorage{dir: dir},
+		FetchHeadStorage: FetchHeadStorage{dir: dir},
 		ConfigStorage:    ConfigStorage{dir: dir},
 		ModuleStorage:    ModuleStorage{dir: dir},
 	}
@@ -84,6 +110,44 @@ func (s *Storage) Init() error {
 	return s.dir.Initialize()
 }
 
+// SetObjectFormat honors storer.ObjectFormatSetter. It changes the format used
+// to hash the objects and to encode the index.
+func (s *Storage) SetObjectFormat(f format.ObjectFormat) error {
+	if f.Size() == 0 {
+		return format.ErrInvalidObjectFormat
+	}
+
+	s.ObjectStorage.options.ObjectFormat = f
+	s.IndexStorage.objectFormat = f
+	s.dir.SetObjectFormat(f)
+	return nil
+}
+
+// SetConfig honors config.ConfigStorer. The settings of the config used by
+// the object storage, such as core.looseCompression, are read again.
+func (s *Storage) SetConfig(cfg *config.Config) error {
+	if err := s.ConfigStorage.SetConfig(cfg); err != nil {
+		return err
+	}
+
+	s.ObjectStorage.resetLooseCompression()
+	return nil
+}
+
+// UpdateConfig honors config.ConfigUpdater, as SetConfig does.
+func (s *Storage) UpdateConfig(update func(*config.Config) error) error {
+	if err := s.ConfigStorage.UpdateConfig(update); err != nil {
+		return err
+	}
+
+	s.ObjectStorage.resetLooseCompression()
+	return nil
+}
+
 func (s *Storage) AddAlternate(remote string) error {
-	return s.dir.AddAlternate(remote)
+	if err := s.dir.AddAlternate(remote); err != nil {
+		return err
+	}
+
+	return s.resetAlternates()
 }
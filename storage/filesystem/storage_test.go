@@ -3,13 +3,16 @@ package filesystem_test
 import (
 	"testing"
 
+	"github.com/go-git/go-git/v6/plumbing"
 	"github.com/go-git/go-git/v6/plumbing/cache"
 	"github.com/go-git/go-git/v6/plumbing/storer"
 	"github.com/go-git/go-git/v6/storage/filesystem"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
 	"github.com/go-git/go-billy/v5/memfs"
 	"github.com/go-git/go-billy/v5/osfs"
+	fixtures "github.com/go-git/go-git-fixtures/v5"
 )
 
 var (
@@ -42,3 +45,32 @@ func TestNewStorageShouldNotAddAnyContentsToDir(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Len(t, fis, 0)
 }
+
+func TestNewStorageObjectCacheSize(t *testing.T) {
+	fs := fixtures.Basic().One().DotGit()
+	sto := filesystem.NewStorageWithOptions(fs, nil, filesystem.Options{ObjectCacheSize: 1 * cache.KiByte})
+
+	iter, err := sto.IterEncodedObjects(plumbing.AnyObject)
+	require.NoError(t, err)
+
+	var n int
+	require.NoError(t, iter.ForEach(func(plumbing.EncodedObject) error {
+		n++
+		return nil
+	}))
+	assert.Greater(t, n, 0)
+
+	c := cache.NewObjectLRUBytes(1 * cache.KiByte)
+	sto = filesystem.NewStorage(fs, c)
+	for _, h := range []string{
+		"6ecf0ef2c2dffb796033e5a02219af86ec6584e5",
+		"a5b8b09e2f8fcb0bb99d3ccb0958157b40890d69",
+		"d3ff53e0564a9f87d8e84b6e28e5060e517008aa",
+	} {
+		_, err := sto.EncodedObject(plumbing.AnyObject, plumbing.NewHash(h))
+		require.NoError(t, err)
+	}
+
+	assert.Greater(t, c.Len(), 0)
+	assert.LessOrEqual(t, c.Size(), 1*cache.KiByte)
+}
@@ -0,0 +1,158 @@
+package memory
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/go-git/go-git/v6/plumbing/filemode"
+	"github.com/go-git/go-git/v6/utils/ioutil"
+)
+
+// Compact deletes from the storage every object not reachable from the given
+// objects, usually the targets of the references to keep. References, config,
+// index and shallow commits are left untouched.
+//
+// Objects missing from the storage, like the parents of shallow commits, end
+// the walk without error.
+func (s *Storage) Compact(keep []plumbing.Hash) error {
+	reachable, err := s.reachable(keep)
+	if err != nil {
+		return err
+	}
+
+	for h := range s.Objects {
+		if _, ok := reachable[h]; ok {
+			continue
+		}
+
+		delete(s.Objects, h)
+		delete(s.Commits, h)
+		delete(s.Trees, h)
+		delete(s.Blobs, h)
+		delete(s.Tags, h)
+	}
+
+	return nil
+}
+
+// reachable returns the set of objects in the storage reachable from the
+// given ones. Objects are parsed here, instead of decoded by the object
+// package, which depends on this one.
+func (s *Storage) reachable(from []plumbing.Hash) (map[plumbing.Hash]struct{}, error) {
+	seen := make(map[plumbing.Hash]struct{})
+	pending := append([]plumbing.Hash(nil), from...)
+	for len(pending) > 0 {
+		h := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+
+		if _, ok := seen[h]; ok {
+			continue
+		}
+
+		o, ok := s.Objects[h]
+		if !ok {
+			continue
+		}
+
+		seen[h] = struct{}{}
+
+		var refs []plumbing.Hash
+		var err error
+		switch o.Type() {
+		case plumbing.CommitObject, plumbing.TagObject:
+			refs, err = headerReferences(o)
+		case plumbing.TreeObject:
+			refs, err = treeEntries(o)
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("reading object %s: %w", h, err)
+		}
+
+		pending = append(pending, refs...)
+	}
+
+	return seen, nil
+}
+
+// headerReferences returns the objects referenced by the headers of a commit,
+// its tree and parents, or of a tag, its target.
+func headerReferences(o plumbing.EncodedObject) (refs []plumbing.Hash, err error) {
+	reader, err := o.Reader()
+	if err != nil {
+		return nil, err
+	}
+	defer ioutil.CheckClose(reader, &err)
+
+	r := bufio.NewReader(reader)
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+
+		line = strings.TrimSuffix(line, "\n")
+		if line == "" {
+			return refs, nil
+		}
+
+		key, value, _ := strings.Cut(line, " ")
+		switch key {
+		case "tree", "parent", "object":
+			h, ok := plumbing.FromHex(value)
+			if !ok {
+				return nil, fmt.Errorf("malformed %s header: %q", key, value)
+			}
+
+			refs = append(refs, h)
+		}
+
+		if err == io.EOF {
+			return refs, nil
+		}
+	}
+}
+
+// treeEntries returns the objects of the entries of a tree, but the commits
+// of submodules, which belong to other repositories.
+func treeEntries(o plumbing.EncodedObject) (refs []plumbing.Hash, err error) {
+	reader, err := o.Reader()
+	if err != nil {
+		return nil, err
+	}
+	defer ioutil.CheckClose(reader, &err)
+
+	r := bufio.NewReader(reader)
+	for {
+		mode, err := r.ReadString(' ')
+		if err == io.EOF {
+			return refs, nil
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		if _, err := r.ReadString(0); err != nil {
+			return nil, err
+		}
+
+		var h plumbing.Hash
+		h.ResetBySize(o.Hash().Size())
+		if _, err := h.ReadFrom(r); err != nil {
+			return nil, err
+		}
+
+		m, err := filemode.New(mode[:len(mode)-1])
+		if err != nil {
+			return nil, err
+		}
+
+		if m != filemode.Submodule {
+			refs = append(refs, h)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package memory_test
+
+import (
+	"testing"
+
+	fixtures "github.com/go-git/go-git-fixtures/v5"
+	"github.com/go-git/go-git/v6/config"
+	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/go-git/go-git/v6/plumbing/format/packfile"
+	"github.com/go-git/go-git/v6/plumbing/revlist"
+	"github.com/go-git/go-git/v6/storage/memory"
+	"github.com/stretchr/testify/suite"
+)
+
+type CompactSuite struct {
+	suite.Suite
+}
+
+func TestCompactSuite(t *testing.T) {
+	suite.Run(t, new(CompactSuite))
+}
+
+func (s *CompactSuite) newStorage() *memory.Storage {
+	sto := memory.NewStorage()
+
+	pf := fixtures.Basic().One().Packfile()
+	defer pf.Close()
+
+	s.Require().NoError(packfile.UpdateObjectStorage(sto, pf))
+	return sto
+}
+
+func (s *CompactSuite) objectHashes(sto *memory.Storage) []plumbing.Hash {
+	var hashes []plumbing.Hash
+	err := sto.ForEachObjectHash(func(h plumbing.Hash) error {
+		hashes = append(hashes, h)
+		return nil
+	})
+	s.Require().NoError(err)
+
+	return hashes
+}
+
+func (s *CompactSuite) TestCompact() {
+	keep := plumbing.NewHash("e8d3ffab552895c19b9fcf7aa264d277cde33881")
+
+	expected, err := revlist.Objects(s.newStorage(), []plumbing.Hash{keep}, nil)
+	s.Require().NoError(err)
+
+	sto := s.newStorage()
+	all := len(sto.Objects)
+
+	ref := plumbing.NewHashReference("refs/heads/branch", keep)
+	s.Require().NoError(sto.SetReference(ref))
+
+	cfg := config.NewConfig()
+	cfg.Core.IsBare = true
+	s.Require().NoError(sto.SetConfig(cfg))
+	s.Require().NoError(sto.SetShallow([]plumbing.Hash{keep}))
+
+	s.Require().NoError(sto.Compact([]plumbing.Hash{keep}))
+
+	s.Less(len(expected), all)
+	s.ElementsMatch(expected, s.objectHashes(sto))
+	s.Len(sto.Commits, 8)
+	s.Empty(sto.Tags)
+
+	got, err := sto.Reference("refs/heads/branch")
+	s.NoError(err)
+	s.Equal(ref, got)
+
+	gotCfg, err := sto.Config()
+	s.NoError(err)
+	s.True(gotCfg.Core.IsBare)
+
+	shallow, err := sto.Shallow()
+	s.NoError(err)
+	s.Equal([]plumbing.Hash{keep}, shallow)
+}
+
+func (s *CompactSuite) TestCompactNothingKept() {
+	sto := s.newStorage()
+
+	s.Require().NoError(sto.Compact(nil))
+	s.Empty(sto.Objects)
+	s.Empty(sto.Commits)
+	s.Empty(sto.Trees)
+	s.Empty(sto.Blobs)
+}
+
+func (s *CompactSuite) TestCompactMissingParents() {
+	head := plumbing.NewHash("6ecf0ef2c2dffb796033e5a02219af86ec6584e5")
+	parent := plumbing.NewHash("918c48b83bd081e863dbe1b80f8998f058cd8294")
+
+	sto := s.newStorage()
+	s.Require().NoError(sto.Compact([]plumbing.Hash{head}))
+	before := len(sto.Objects)
+
+	// As in a shallow repository, the parent of the commit is missing.
+	delete(sto.Objects, parent)
+	delete(sto.Commits, parent)
+
+	s.Require().NoError(sto.Compact([]plumbing.Hash{head}))
+	s.Less(len(sto.Objects), before-1)
+	s.NoError(sto.HasEncodedObject(head))
+	s.ErrorIs(sto.HasEncodedObject(parent), plumbing.ErrObjectNotFound)
+}
@@ -4,11 +4,15 @@ package memory
 import (
 	"fmt"
 	"io"
+	"sync"
 	"time"
 
 	"github.com/go-git/go-git/v6/config"
 	"github.com/go-git/go-git/v6/plumbing"
+	format "github.com/go-git/go-git/v6/plumbing/format/config"
+	"github.com/go-git/go-git/v6/plumbing/format/fetchhead"
 	"github.com/go-git/go-git/v6/plumbing/format/index"
+	"github.com/go-git/go-git/v6/plumbing/format/reflog"
 	"github.com/go-git/go-git/v6/plumbing/storer"
 	"github.com/go-git/go-git/v6/storage"
 	"github.com/go-git/go-git/v6/utils/ioutil"
@@ -24,8 +28,10 @@ type Storage struct {
 	ConfigStorage
 	ObjectStorage
 	ShallowStorage
+	FetchHeadStorage
 	IndexStorage
 	ReferenceStorage
+	ReflogStorage
 	ModuleStorage
 }
 
@@ -48,6 +54,7 @@ func NewStorage() *Storage {
 
 type ConfigStorage struct {
 	config *config.Config
+	mu     sync.Mutex
 }
 
 func (c *ConfigStorage) SetConfig(cfg *config.Config) error {
@@ -67,6 +74,24 @@ func (c *ConfigStorage) Config() (*config.Config, error) {
 	return c.config, nil
 }
 
+// UpdateConfig honors the config.ConfigUpdater interface. As the config
+// returned by Config, the one given to update is the stored one.
+func (c *ConfigStorage) UpdateConfig(update func(*config.Config) error) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	cfg, err := c.Config()
+	if err != nil {
+		return err
+	}
+
+	if err := update(cfg); err != nil {
+		return err
+	}
+
+	return c.SetConfig(cfg)
+}
+
 type IndexStorage struct {
 	index *index.Index
 }
@@ -90,6 +115,8 @@ type ObjectStorage struct {
 	Trees   map[plumbing.Hash]plumbing.EncodedObject
 	Blobs   map[plumbing.Hash]plumbing.EncodedObject
 	Tags    map[plumbing.Hash]plumbing.EncodedObject
+
+	objectFormat format.ObjectFormat
 }
 
 type lazyCloser struct {
@@ -126,7 +153,17 @@ func (o *ObjectStorage) RawObjectWriter(typ plumbing.ObjectType, sz int64) (w io
 }
 
 func (o *ObjectStorage) NewEncodedObject() plumbing.EncodedObject {
-	return &plumbing.MemoryObject{}
+	return plumbing.NewMemoryObject(o.objectFormat)
+}
+
+// SetObjectFormat honors storer.ObjectFormatSetter.
+func (o *ObjectStorage) SetObjectFormat(f format.ObjectFormat) error {
+	if f.Size() == 0 {
+		return format.ErrInvalidObjectFormat
+	}
+
+	o.objectFormat = f
+	return nil
 }
 
 func (o *ObjectStorage) SetEncodedObject(obj plumbing.EncodedObject) (plumbing.Hash, error) {
@@ -334,6 +371,82 @@ func (r ReferenceStorage) RemoveReference(n plumbing.ReferenceName) error {
 	return nil
 }
 
+// FetchHeadStorage stores the references fetched by the last fetch.
+type FetchHeadStorage []*fetchhead.Entry
+
+// SetFetchHead replaces the recorded entries with the given ones.
+func (s *FetchHeadStorage) SetFetchHead(entries []*fetchhead.Entry) error {
+	*s = append(FetchHeadStorage(nil), entries...)
+	return nil
+}
+
+// FetchHead returns the entries recorded by the last fetch, in order.
+func (s FetchHeadStorage) FetchHead() ([]*fetchhead.Entry, error) {
+	return append([]*fetchhead.Entry(nil), s...), nil
+}
+
+// ReflogStorage stores the logs of the references.
+type ReflogStorage struct {
+	logs map[plumbing.ReferenceName][]*reflog.Entry
+}
+
+// Reflog returns the entries of the log of the given reference, oldest first.
+func (r *ReflogStorage) Reflog(n plumbing.ReferenceName) ([]*reflog.Entry, error) {
+	return append([]*reflog.Entry(nil), r.logs[n]...), nil
+}
+
+// AppendReflog appends an entry to the log of the given reference.
+func (r *ReflogStorage) AppendReflog(n plumbing.ReferenceName, e *reflog.Entry) error {
+	if r.logs == nil {
+		r.logs = make(map[plumbing.ReferenceName][]*reflog.Entry)
+	}
+
+	r.logs[n] = append(r.logs[n], e)
+	return nil
+}
+
+// RemoveReflog removes the log of the given reference.
+func (r *ReflogStorage) RemoveReflog(n plumbing.ReferenceName) error {
+	delete(r.logs, n)
+	return nil
+}
+
+// RemoveReference removes the reference and its log.
+func (s *Storage) RemoveReference(n plumbing.ReferenceName) error {
+	if err := s.ReferenceStorage.RemoveReference(n); err != nil {
+		return err
+	}
+
+	return s.ReflogStorage.RemoveReflog(n)
+}
+
+// BeginReferenceTransaction starts a transaction updating several references
+// atomically.
+func (s *Storage) BeginReferenceTransaction() storer.ReferenceTransaction {
+	return storer.NewReferenceTransaction(s.applyReferenceUpdates)
+}
+
+func (s *Storage) applyReferenceUpdates(updates []*storer.ReferenceUpdate) error {
+	for _, u := range updates {
+		if !u.Check(s.ReferenceStorage[u.Name]) {
+			return storage.ErrReferenceHasChanged
+		}
+	}
+
+	for _, u := range updates {
+		if u.New != nil {
+			s.ReferenceStorage[u.Name] = u.New
+			continue
+		}
+
+		if err := s.RemoveReference(u.Name); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 type ShallowStorage []plumbing.Hash
 
 func (s *ShallowStorage) SetShallow(commits []plumbing.Hash) error {
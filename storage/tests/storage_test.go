@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"io"
 	"testing"
+	"time"
 
 	"github.com/go-git/go-billy/v5/memfs"
 	"github.com/go-git/go-billy/v5/osfs"
@@ -11,7 +12,9 @@ import (
 	"github.com/go-git/go-git/v6/config"
 	"github.com/go-git/go-git/v6/plumbing"
 	"github.com/go-git/go-git/v6/plumbing/cache"
+	"github.com/go-git/go-git/v6/plumbing/format/fetchhead"
 	"github.com/go-git/go-git/v6/plumbing/format/index"
+	"github.com/go-git/go-git/v6/plumbing/format/reflog"
 	"github.com/go-git/go-git/v6/plumbing/storer"
 	"github.com/go-git/go-git/v6/storage"
 	"github.com/go-git/go-git/v6/storage/filesystem"
@@ -501,6 +504,205 @@ func TestIterReferences(t *testing.T) {
 	})
 }
 
+func TestReferenceTransaction(t *testing.T) {
+	t.Parallel()
+
+	forEachStorage(t, func(sto Storer, t *testing.T) {
+		ts, ok := sto.(storer.ReferenceTransactionStorer)
+		if !ok {
+			t.Skip("not a ReferenceTransactionStorer")
+		}
+
+		foo := plumbing.NewReferenceFromStrings("refs/heads/foo", "bc9968d75e48de59f0870ffb71f5e160bbbdcf52")
+		bar := plumbing.NewReferenceFromStrings("refs/heads/bar", "c3f4688a08fd86f1bf8e055724c84b7a40a09733")
+		require.NoError(t, sto.SetReference(foo))
+		require.NoError(t, sto.SetReference(bar))
+
+		newFoo := plumbing.NewReferenceFromStrings("refs/heads/foo", "482e0eada5de4039e6f216b45b3c9b683b83bfa0")
+		baz := plumbing.NewReferenceFromStrings("refs/heads/baz", "c3f4688a08fd86f1bf8e055724c84b7a40a09733")
+
+		tx := ts.BeginReferenceTransaction()
+		require.NoError(t, tx.SetReference(newFoo, foo))
+		require.NoError(t, tx.SetReference(baz, plumbing.NewHashReference(baz.Name(), plumbing.ZeroHash)))
+		require.NoError(t, tx.RemoveReference(bar.Name(), bar))
+		require.NoError(t, tx.Commit())
+
+		e, err := sto.Reference(foo.Name())
+		require.NoError(t, err)
+		assert.Equal(t, newFoo.Hash(), e.Hash())
+
+		e, err = sto.Reference(baz.Name())
+		require.NoError(t, err)
+		assert.Equal(t, baz.Hash(), e.Hash())
+
+		_, err = sto.Reference(bar.Name())
+		assert.ErrorIs(t, err, plumbing.ErrReferenceNotFound)
+
+		assert.ErrorIs(t, tx.Commit(), storer.ErrReferenceTransactionClosed)
+	})
+}
+
+func TestReferenceTransactionPreconditionFails(t *testing.T) {
+	t.Parallel()
+
+	forEachStorage(t, func(sto Storer, t *testing.T) {
+		ts, ok := sto.(storer.ReferenceTransactionStorer)
+		if !ok {
+			t.Skip("not a ReferenceTransactionStorer")
+		}
+
+		foo := plumbing.NewReferenceFromStrings("refs/heads/foo", "bc9968d75e48de59f0870ffb71f5e160bbbdcf52")
+		bar := plumbing.NewReferenceFromStrings("refs/heads/bar", "c3f4688a08fd86f1bf8e055724c84b7a40a09733")
+		require.NoError(t, sto.SetReference(foo))
+		require.NoError(t, sto.SetReference(bar))
+
+		tx := ts.BeginReferenceTransaction()
+		require.NoError(t, tx.RemoveReference(foo.Name(), foo))
+		// bar already exists.
+		require.NoError(t, tx.SetReference(
+			plumbing.NewReferenceFromStrings("refs/heads/bar", "482e0eada5de4039e6f216b45b3c9b683b83bfa0"),
+			plumbing.NewHashReference(bar.Name(), plumbing.ZeroHash),
+		))
+		assert.ErrorIs(t, tx.Commit(), storage.ErrReferenceHasChanged)
+
+		for _, ref := range []*plumbing.Reference{foo, bar} {
+			e, err := sto.Reference(ref.Name())
+			require.NoError(t, err)
+			assert.Equal(t, ref.Hash(), e.Hash())
+		}
+	})
+}
+
+func TestReferenceTransactionRollback(t *testing.T) {
+	t.Parallel()
+
+	forEachStorage(t, func(sto Storer, t *testing.T) {
+		ts, ok := sto.(storer.ReferenceTransactionStorer)
+		if !ok {
+			t.Skip("not a ReferenceTransactionStorer")
+		}
+
+		foo := plumbing.NewReferenceFromStrings("refs/heads/foo", "bc9968d75e48de59f0870ffb71f5e160bbbdcf52")
+
+		tx := ts.BeginReferenceTransaction()
+		require.NoError(t, tx.SetReference(foo, nil))
+		require.NoError(t, tx.Rollback())
+
+		_, err := sto.Reference(foo.Name())
+		assert.ErrorIs(t, err, plumbing.ErrReferenceNotFound)
+		assert.ErrorIs(t, tx.Commit(), storer.ErrReferenceTransactionClosed)
+	})
+}
+
+func TestReferenceTransactionInvalidUpdates(t *testing.T) {
+	t.Parallel()
+
+	forEachStorage(t, func(sto Storer, t *testing.T) {
+		ts, ok := sto.(storer.ReferenceTransactionStorer)
+		if !ok {
+			t.Skip("not a ReferenceTransactionStorer")
+		}
+
+		foo := plumbing.NewReferenceFromStrings("refs/heads/foo", "bc9968d75e48de59f0870ffb71f5e160bbbdcf52")
+
+		tx := ts.BeginReferenceTransaction()
+		require.NoError(t, tx.SetReference(foo, nil))
+		assert.Error(t, tx.RemoveReference(foo.Name(), nil))
+		assert.ErrorIs(t, tx.SetReference(
+			plumbing.NewSymbolicReference(plumbing.HEAD, foo.Name()), nil,
+		), storer.ErrSymbolicReferenceUpdate)
+		assert.ErrorIs(t, tx.SetReference(
+			plumbing.NewHashReference(plumbing.HEAD, foo.Hash()), nil,
+		), storer.ErrReferenceOutsideRefs)
+		assert.ErrorIs(t, tx.RemoveReference("ORIG_HEAD", nil), storer.ErrReferenceOutsideRefs)
+	})
+}
+
+func TestReflog(t *testing.T) {
+	t.Parallel()
+
+	forEachStorage(t, func(sto Storer, t *testing.T) {
+		rs, ok := sto.(storer.ReflogStorer)
+		if !ok {
+			t.Skip("not a ReflogStorer")
+		}
+
+		name := plumbing.NewBranchReferenceName("foo")
+		entries, err := rs.Reflog(name)
+		require.NoError(t, err)
+		assert.Empty(t, entries)
+
+		expected := []*reflog.Entry{{
+			New:       plumbing.NewHash("bc9968d75e48de59f0870ffb71f5e160bbbdcf52"),
+			Committer: reflog.Signature{Name: "foo", Email: "foo@foo.foo", When: time.Unix(1257894000, 0)},
+			Message:   "branch: Created from HEAD",
+		}, {
+			Old:       plumbing.NewHash("bc9968d75e48de59f0870ffb71f5e160bbbdcf52"),
+			New:       plumbing.NewHash("c3f4688a08fd86f1bf8e055724c84b7a40a09733"),
+			Committer: reflog.Signature{Name: "foo", Email: "foo@foo.foo", When: time.Unix(1257894060, 0)},
+			Message:   "commit: bar",
+		}}
+
+		for _, e := range expected {
+			require.NoError(t, rs.AppendReflog(name, e))
+		}
+
+		entries, err = rs.Reflog(name)
+		require.NoError(t, err)
+		require.Len(t, entries, 2)
+		for i, e := range entries {
+			assert.Equal(t, expected[i].Old, e.Old)
+			assert.Equal(t, expected[i].New, e.New)
+			assert.Equal(t, expected[i].Message, e.Message)
+			assert.True(t, expected[i].Committer.When.Equal(e.Committer.When))
+		}
+
+		err = sto.SetReference(plumbing.NewHashReference(name, expected[1].New))
+		require.NoError(t, err)
+		require.NoError(t, sto.RemoveReference(name))
+
+		entries, err = rs.Reflog(name)
+		require.NoError(t, err)
+		assert.Empty(t, entries)
+	})
+}
+
+func TestFetchHead(t *testing.T) {
+	t.Parallel()
+
+	forEachStorage(t, func(sto Storer, t *testing.T) {
+		fs, ok := sto.(storer.FetchHeadStorer)
+		if !ok {
+			t.Skip("not a FetchHeadStorer")
+		}
+
+		entries, err := fs.FetchHead()
+		require.NoError(t, err)
+		assert.Empty(t, entries)
+
+		expected := []*fetchhead.Entry{{
+			Hash: plumbing.NewHash("bc9968d75e48de59f0870ffb71f5e160bbbdcf52"),
+			Name: plumbing.NewBranchReferenceName("foo"),
+			URL:  "https://example.com/foo",
+		}, {
+			Hash:        plumbing.NewHash("c3f4688a08fd86f1bf8e055724c84b7a40a09733"),
+			NotForMerge: true,
+			Name:        plumbing.NewTagReferenceName("v1.0.0"),
+			URL:         "https://example.com/foo",
+		}}
+
+		require.NoError(t, fs.SetFetchHead(expected))
+		entries, err = fs.FetchHead()
+		require.NoError(t, err)
+		assert.Equal(t, expected, entries)
+
+		require.NoError(t, fs.SetFetchHead(expected[1:]))
+		entries, err = fs.FetchHead()
+		require.NoError(t, err)
+		assert.Equal(t, expected[1:], entries)
+	})
+}
+
 func TestSetShallowAndShallow(t *testing.T) {
 	t.Parallel()
 
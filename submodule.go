@@ -5,7 +5,11 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"maps"
 	"path"
+	"slices"
+	"strings"
+	"sync"
 
 	"github.com/go-git/go-billy/v5"
 	"github.com/go-git/go-git/v6/config"
@@ -133,32 +137,53 @@ func (s *Submodule) Repository() (*Repository, error) {
 		return nil, err
 	}
 
-	moduleEndpoint, err := transport.NewEndpoint(s.c.URL)
+	url, err := s.url()
 	if err != nil {
 		return nil, err
 	}
 
-	if !path.IsAbs(moduleEndpoint.Path) && moduleEndpoint.Protocol == "file" {
-		remotes, err := s.w.r.Remotes()
-		if err != nil {
-			return nil, err
-		}
+	_, err = r.CreateRemote(&config.RemoteConfig{
+		Name: DefaultRemoteName,
+		URLs: []string{url},
+	})
 
-		rootEndpoint, err := transport.NewEndpoint(remotes[0].c.URLs[0])
-		if err != nil {
-			return nil, err
+	return r, err
+}
+
+// url returns the URL of the submodule. As git does, the URLs starting with
+// ./ or ../ are relative to the URL of the default remote of the
+// superproject: the remote of its current branch, or origin. Without such
+// remote, they're relative to the worktree of the superproject.
+func (s *Submodule) url() (string, error) {
+	if !strings.HasPrefix(s.c.URL, "./") && !strings.HasPrefix(s.c.URL, "../") {
+		return s.c.URL, nil
+	}
+
+	cfg, err := s.w.r.Config()
+	if err != nil {
+		return "", err
+	}
+
+	remote := DefaultRemoteName
+	head, err := s.w.r.Storer.Reference(plumbing.HEAD)
+	if err == nil && head.Type() == plumbing.SymbolicReference && head.Target().IsBranch() {
+		if b, ok := cfg.Branches[head.Target().Short()]; ok && b.Remote != "" {
+			remote = b.Remote
 		}
+	}
 
-		rootEndpoint.Path = path.Join(rootEndpoint.Path, moduleEndpoint.Path)
-		*moduleEndpoint = *rootEndpoint
+	base := s.w.Filesystem.Root()
+	if rc, ok := cfg.Remotes[remote]; ok && len(rc.URLs) > 0 {
+		base = rc.URLs[0]
 	}
 
-	_, err = r.CreateRemote(&config.RemoteConfig{
-		Name: DefaultRemoteName,
-		URLs: []string{moduleEndpoint.String()},
-	})
+	ep, err := transport.NewEndpoint(base)
+	if err != nil {
+		return "", err
+	}
 
-	return r, err
+	ep.Path = path.Join(ep.Path, s.c.URL)
+	return ep.String(), nil
 }
 
 // Update the registered submodule to match what the superproject expects, the
@@ -180,26 +205,46 @@ func (s *Submodule) UpdateContext(ctx context.Context, o *SubmoduleUpdateOptions
 }
 
 func (s *Submodule) update(ctx context.Context, o *SubmoduleUpdateOptions, forceHash plumbing.Hash) error {
+	r, hash, err := s.prepareUpdate(o, forceHash)
+	if err != nil {
+		return err
+	}
+
+	if err := s.fetch(ctx, r, o, hash); err != nil {
+		return err
+	}
+
+	if err := s.checkout(r, hash); err != nil {
+		return err
+	}
+
+	return s.doRecursiveUpdate(ctx, r, o)
+}
+
+// prepareUpdate initializes the submodule if requested, and returns its
+// repository and the commit it's updated to: forceHash, or the one recorded
+// in the index of the superproject.
+func (s *Submodule) prepareUpdate(o *SubmoduleUpdateOptions, forceHash plumbing.Hash) (*Repository, plumbing.Hash, error) {
 	if !s.initialized && !o.Init {
-		return ErrSubmoduleNotInitialized
+		return nil, plumbing.ZeroHash, ErrSubmoduleNotInitialized
 	}
 
 	if !s.initialized && o.Init {
 		if err := s.Init(); err != nil {
-			return err
+			return nil, plumbing.ZeroHash, err
 		}
 	}
 
-	idx, err := s.w.r.Storer.Index()
-	if err != nil {
-		return err
-	}
-
 	hash := forceHash
 	if hash.IsZero() {
+		idx, err := s.w.r.Storer.Index()
+		if err != nil {
+			return nil, plumbing.ZeroHash, err
+		}
+
 		e, err := idx.Entry(s.c.Path)
 		if err != nil {
-			return err
+			return nil, plumbing.ZeroHash, err
 		}
 
 		hash = e.Hash
@@ -207,14 +252,10 @@ func (s *Submodule) update(ctx context.Context, o *SubmoduleUpdateOptions, force
 
 	r, err := s.Repository()
 	if err != nil {
-		return err
-	}
-
-	if err := s.fetchAndCheckout(ctx, r, o, hash); err != nil {
-		return err
+		return nil, plumbing.ZeroHash, err
 	}
 
-	return s.doRecursiveUpdate(ctx, r, o)
+	return r, hash, nil
 }
 
 func (s *Submodule) doRecursiveUpdate(ctx context.Context, r *Repository, o *SubmoduleUpdateOptions) error {
@@ -239,18 +280,16 @@ func (s *Submodule) doRecursiveUpdate(ctx context.Context, r *Repository, o *Sub
 	return l.UpdateContext(ctx, new)
 }
 
-func (s *Submodule) fetchAndCheckout(
-	ctx context.Context, r *Repository, o *SubmoduleUpdateOptions, hash plumbing.Hash,
-) error {
-	if !o.NoFetch {
-		err := r.FetchContext(ctx, &FetchOptions{Auth: o.Auth, Depth: o.Depth})
-		if err != nil && err != NoErrAlreadyUpToDate {
-			return err
-		}
+// fetch fetches the objects of the submodule repository, unless
+// SubmoduleUpdateOptions.NoFetch is set. It only uses the repository of the
+// submodule, so several submodules can be fetched concurrently.
+func (s *Submodule) fetch(ctx context.Context, r *Repository, o *SubmoduleUpdateOptions, hash plumbing.Hash) error {
+	if o.NoFetch {
+		return nil
 	}
 
-	w, err := r.Worktree()
-	if err != nil {
+	err := r.FetchContext(ctx, &FetchOptions{Auth: o.Auth, Depth: o.Depth})
+	if err != nil && err != NoErrAlreadyUpToDate {
 		return err
 	}
 
@@ -258,21 +297,30 @@ func (s *Submodule) fetchAndCheckout(
 	// through Git server using a special protocol capability[1].
 	//
 	// [1]: https://git-scm.com/docs/protocol-capabilities#_allow_reachable_sha1_in_want
-	if !o.NoFetch {
-		if _, err := w.r.Object(plumbing.AnyObject, hash); err != nil {
-			refSpec := config.RefSpec("+" + hash.String() + ":" + hash.String())
-
-			err := r.FetchContext(ctx, &FetchOptions{
-				Auth:     o.Auth,
-				RefSpecs: []config.RefSpec{refSpec},
-				Depth:    o.Depth,
-			})
-			if err != nil && !errors.Is(err, NoErrAlreadyUpToDate) && !errors.Is(err, ErrExactSHA1NotSupported) {
-				return err
-			}
+	if _, err := r.Object(plumbing.AnyObject, hash); err != nil {
+		refSpec := config.RefSpec("+" + hash.String() + ":" + hash.String())
+
+		err := r.FetchContext(ctx, &FetchOptions{
+			Auth:     o.Auth,
+			RefSpecs: []config.RefSpec{refSpec},
+			Depth:    o.Depth,
+		})
+		if err != nil && !errors.Is(err, NoErrAlreadyUpToDate) && !errors.Is(err, ErrExactSHA1NotSupported) {
+			return err
 		}
 	}
 
+	return nil
+}
+
+// checkout checks out the given commit in the worktree of the submodule, and
+// detaches its HEAD at it.
+func (s *Submodule) checkout(r *Repository, hash plumbing.Hash) error {
+	w, err := r.Worktree()
+	if err != nil {
+		return err
+	}
+
 	if err := w.Checkout(&CheckoutOptions{Hash: hash}); err != nil {
 		return err
 	}
@@ -302,19 +350,139 @@ func (s Submodules) Update(o *SubmoduleUpdateOptions) error {
 
 // UpdateContext updates all the submodules in this list.
 //
+// Up to SubmoduleUpdateOptions.Jobs submodules are fetched concurrently, then
+// they are checked out one after the other. A submodule failing to update
+// doesn't stop the others, unless SubmoduleUpdateOptions.FailFast is set: a
+// *SubmoduleUpdateError is returned with the error of each of them.
+//
 // The provided Context must be non-nil. If the context expires before the
 // operation is complete, an error is returned. The context only affects the
 // transport operations.
 func (s Submodules) UpdateContext(ctx context.Context, o *SubmoduleUpdateOptions) error {
-	for _, sub := range s {
-		if err := sub.UpdateContext(ctx, o); err != nil {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	updateErr := &SubmoduleUpdateError{Errors: make(map[string]error)}
+	fail := func(sub *Submodule, err error) error {
+		if o.FailFast {
 			return err
 		}
+
+		updateErr.Errors[sub.c.Path] = err
+		return nil
+	}
+
+	// The submodules are initialized and their repositories opened first,
+	// as it changes the config and storage of the superproject.
+	var updates []*submoduleUpdate
+	for _, sub := range s {
+		r, hash, err := sub.prepareUpdate(o, plumbing.ZeroHash)
+		if err != nil {
+			if err := fail(sub, err); err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		updates = append(updates, &submoduleUpdate{sub: sub, r: r, hash: hash})
+	}
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		firstErr error
+	)
+
+	jobs := make(chan struct{}, max(o.Jobs, 1))
+	for _, u := range updates {
+		if u.err = ctx.Err(); u.err != nil {
+			continue
+		}
+
+		jobs <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer func() { <-jobs; wg.Done() }()
+
+			u.err = u.sub.fetch(ctx, u.r, o, u.hash)
+			if u.err != nil && o.FailFast {
+				mu.Lock()
+				if firstErr == nil {
+					firstErr = u.err
+				}
+				mu.Unlock()
+				cancel()
+			}
+		}()
+	}
+
+	wg.Wait()
+	if firstErr != nil {
+		return firstErr
+	}
+
+	for _, u := range updates {
+		err := u.err
+		if err == nil {
+			err = u.sub.checkout(u.r, u.hash)
+		}
+
+		if err == nil {
+			err = u.sub.doRecursiveUpdate(ctx, u.r, o)
+		}
+
+		if err != nil {
+			if err := fail(u.sub, err); err != nil {
+				return err
+			}
+		}
+	}
+
+	if len(updateErr.Errors) > 0 {
+		return updateErr
 	}
 
 	return nil
 }
 
+// submoduleUpdate is a submodule being updated by Submodules.UpdateContext.
+type submoduleUpdate struct {
+	sub  *Submodule
+	r    *Repository
+	hash plumbing.Hash
+	// err is the error of the fetch.
+	err error
+}
+
+// SubmoduleUpdateError is returned by Submodules.Update when some of the
+// submodules couldn't be updated, the others being updated anyway.
+type SubmoduleUpdateError struct {
+	// Errors holds the error of each submodule not updated, by path.
+	Errors map[string]error
+}
+
+func (e *SubmoduleUpdateError) Error() string {
+	paths := slices.Sorted(maps.Keys(e.Errors))
+	msgs := make([]string, len(paths))
+	for i, p := range paths {
+		msgs[i] = fmt.Sprintf("%s: %s", p, e.Errors[p])
+	}
+
+	return "failed to update submodules: " + strings.Join(msgs, "; ")
+}
+
+// Unwrap returns the errors of the submodules, so they can be checked with
+// errors.Is and errors.As.
+func (e *SubmoduleUpdateError) Unwrap() []error {
+	errs := make([]error, 0, len(e.Errors))
+	for _, p := range slices.Sorted(maps.Keys(e.Errors)) {
+		errs = append(errs, e.Errors[p])
+	}
+
+	return errs
+}
+
 // Status returns the status of the submodules.
 func (s Submodules) Status() (SubmodulesStatus, error) {
 	var list SubmodulesStatus
@@ -2,11 +2,18 @@ package git
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/go-git/go-billy/v5/util"
 	"github.com/go-git/go-git/v6/config"
 	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/go-git/go-git/v6/plumbing/filemode"
+	"github.com/go-git/go-git/v6/plumbing/transport"
 	"github.com/go-git/go-git/v6/storage/memory"
 	"github.com/stretchr/testify/suite"
 
@@ -274,3 +281,149 @@ func (s *SubmoduleSuite) TestSubmoduleParseScp() {
 	_, err := submodule.Repository()
 	s.NoError(err)
 }
+
+// localSubmodules creates, next to each other, the repositories "one" and
+// "two", the latter having "one" as submodule, and the superproject "super"
+// with both as submodules, through relative URLs.
+func (s *SubmoduleSuite) localSubmodules(extra ...string) *Worktree {
+	dir := s.T().TempDir()
+
+	one := s.localRepository(filepath.Join(dir, "one"), map[string]plumbing.Hash{})
+	two := s.localRepository(filepath.Join(dir, "two"), map[string]plumbing.Hash{"one": one})
+
+	modules := map[string]plumbing.Hash{"one": one, "two": two}
+	for _, name := range extra {
+		modules[name] = one
+	}
+
+	super := filepath.Join(dir, "super")
+	s.localRepository(super, modules)
+
+	r, err := PlainOpen(super)
+	s.Require().NoError(err)
+	_, err = r.CreateRemote(&config.RemoteConfig{Name: DefaultRemoteName, URLs: []string{super}})
+	s.Require().NoError(err)
+
+	w, err := r.Worktree()
+	s.Require().NoError(err)
+	return w
+}
+
+// localRepository creates a repository at the given path with a commit
+// adding a file and the given submodules, at ../<name>.
+func (s *SubmoduleSuite) localRepository(dir string, modules map[string]plumbing.Hash) plumbing.Hash {
+	r, err := PlainInit(dir, false)
+	s.Require().NoError(err)
+	w, err := r.Worktree()
+	s.Require().NoError(err)
+
+	s.Require().NoError(util.WriteFile(w.Filesystem, "file", []byte(dir), 0o644))
+	_, err = w.Add("file")
+	s.Require().NoError(err)
+
+	if len(modules) > 0 {
+		var gitmodules strings.Builder
+		for name := range modules {
+			fmt.Fprintf(&gitmodules, "[submodule %q]\n\tpath = %s\n\turl = ../%s\n", name, name, name)
+		}
+
+		s.Require().NoError(util.WriteFile(w.Filesystem, ".gitmodules", []byte(gitmodules.String()), 0o644))
+		_, err = w.Add(".gitmodules")
+		s.Require().NoError(err)
+
+		idx, err := r.Storer.Index()
+		s.Require().NoError(err)
+		for name, h := range modules {
+			e := idx.Add(name)
+			e.Hash, e.Mode = h, filemode.Submodule
+		}
+
+		s.Require().NoError(r.Storer.SetIndex(idx))
+	}
+
+	h, err := w.Commit("init", &CommitOptions{Author: defaultSignature()})
+	s.Require().NoError(err)
+	return h
+}
+
+func (s *SubmoduleSuite) TestSubmodulesUpdateJobs() {
+	w := s.localSubmodules()
+	sm, err := w.Submodules()
+	s.Require().NoError(err)
+
+	err = sm.Update(&SubmoduleUpdateOptions{
+		Init:              true,
+		Jobs:              2,
+		RecurseSubmodules: DefaultSubmoduleRecursionDepth,
+	})
+	s.Require().NoError(err)
+
+	for _, name := range []string{"one/file", "two/file", "two/one/file"} {
+		_, err := w.Filesystem.Stat(name)
+		s.NoError(err, name)
+	}
+
+	status, err := sm.Status()
+	s.Require().NoError(err)
+	for _, st := range status {
+		s.True(st.IsClean(), st.Path)
+	}
+}
+
+func (s *SubmoduleSuite) TestSubmodulesUpdateErrors() {
+	w := s.localSubmodules("missing")
+	sm, err := w.Submodules()
+	s.Require().NoError(err)
+
+	err = sm.Update(&SubmoduleUpdateOptions{Init: true, Jobs: 3})
+
+	var updateErr *SubmoduleUpdateError
+	s.Require().ErrorAs(err, &updateErr)
+	s.Len(updateErr.Errors, 1)
+	s.ErrorIs(err, transport.ErrRepositoryNotFound)
+	s.Contains(updateErr.Errors, "missing")
+
+	_, err = w.Filesystem.Stat("two/file")
+	s.NoError(err)
+}
+
+func (s *SubmoduleSuite) TestSubmodulesUpdateFailFast() {
+	w := s.localSubmodules("missing")
+	sm, err := w.Submodules()
+	s.Require().NoError(err)
+
+	err = sm.Update(&SubmoduleUpdateOptions{Init: true, FailFast: true})
+	s.ErrorIs(err, transport.ErrRepositoryNotFound)
+
+	var updateErr *SubmoduleUpdateError
+	s.False(errors.As(err, &updateErr))
+}
+
+func (s *SubmoduleSuite) TestSubmoduleRelativeURL() {
+	for url, expected := range map[string]string{
+		"../sub.git":         "https://example.com/org/sub.git",
+		"./sub":              "https://example.com/org/super.git/sub",
+		"../../other/sub":    "https://example.com/other/sub",
+		"git@host:org/sub":   "git@host:org/sub",
+		"https://host/a/sub": "https://host/a/sub",
+	} {
+		r, err := Init(memory.NewStorage(), WithWorkTree(memfs.New()))
+		s.Require().NoError(err)
+		_, err = r.CreateRemote(&config.RemoteConfig{
+			Name: DefaultRemoteName,
+			URLs: []string{"https://example.com/org/super.git"},
+		})
+		s.Require().NoError(err)
+
+		w, err := r.Worktree()
+		s.Require().NoError(err)
+
+		sub := &Submodule{initialized: true, w: w, c: &config.Submodule{Name: "sub", Path: "sub", URL: url}}
+		sr, err := sub.Repository()
+		s.Require().NoError(err)
+
+		remote, err := sr.Remote(DefaultRemoteName)
+		s.Require().NoError(err)
+		s.Equal([]string{expected}, remote.Config().URLs, url)
+	}
+}
@@ -0,0 +1,175 @@
+package git
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/go-git/go-git/v6/config"
+	"github.com/go-git/go-git/v6/internal/revision"
+	"github.com/go-git/go-git/v6/plumbing"
+)
+
+var (
+	// ErrNoUpstream is returned resolving `@{upstream}` for a branch without
+	// a configured upstream, `branch.<name>.remote` and `branch.<name>.merge`.
+	ErrNoUpstream = errors.New("no upstream configured for branch")
+	// ErrNoPushDestination is returned resolving `@{push}` for a branch that
+	// has no remote to push to, or when push.default is "nothing".
+	ErrNoPushDestination = errors.New("no push destination configured for branch")
+	// ErrUpstreamNotTracked is returned when the upstream, or the push
+	// destination, of a branch isn't fetched into a remote-tracking branch by
+	// the fetch refspecs of its remote.
+	ErrUpstreamNotTracked = errors.New("upstream branch not stored as a remote-tracking branch")
+	// ErrDetachedHEAD is returned resolving `@{upstream}` or `@{push}`
+	// without a branch when HEAD doesn't point to a branch.
+	ErrDetachedHEAD = errors.New("HEAD does not point to a branch")
+)
+
+// resolveUpstreamRevision resolves the `@{upstream}` and `@{push}` revisions
+// of the given branch, or of the branch checked out if empty, to the name of
+// the corresponding remote-tracking branch.
+func (r *Repository) resolveUpstreamRevision(ref string, item revision.Revisioner) (plumbing.ReferenceName, error) {
+	branch, err := r.upstreamBranchName(ref)
+	if err != nil {
+		return "", err
+	}
+
+	cfg, err := r.Config()
+	if err != nil {
+		return "", err
+	}
+
+	switch item.(type) {
+	case revision.AtUpstream:
+		return upstreamRef(cfg, branch)
+	case revision.AtPush:
+		return pushRef(cfg, r.pushDefault(cfg), branch)
+	}
+
+	return "", fmt.Errorf("unsupported upstream revision %T", item)
+}
+
+// upstreamBranchName returns the short name of the branch the `@{upstream}`
+// and `@{push}` revisions refer to, given the reference preceding them.
+func (r *Repository) upstreamBranchName(rev string) (string, error) {
+	if rev == "" {
+		head, err := r.Storer.Reference(plumbing.HEAD)
+		if err != nil {
+			return "", err
+		}
+
+		if head.Type() != plumbing.SymbolicReference || !head.Target().IsBranch() {
+			return "", ErrDetachedHEAD
+		}
+
+		return head.Target().Short(), nil
+	}
+
+	name, err := r.reflogRefName(rev)
+	if err != nil {
+		return "", err
+	}
+
+	if name == plumbing.HEAD {
+		return r.upstreamBranchName("")
+	}
+
+	if !name.IsBranch() {
+		return "", fmt.Errorf("%q is not a branch", rev)
+	}
+
+	return name.Short(), nil
+}
+
+// pushDefault returns the value of push.default, looking in the global and
+// system config when it isn't set in the repository one.
+func (r *Repository) pushDefault(cfg *config.Config) string {
+	if cfg.Push.Default != "" {
+		return cfg.Push.Default
+	}
+
+	scoped, err := r.ConfigScoped(config.SystemScope)
+	if err != nil {
+		return ""
+	}
+
+	return scoped.Push.Default
+}
+
+// upstreamRef returns the remote-tracking branch of the upstream of the given
+// branch, or the upstream itself when it's a local branch.
+func upstreamRef(cfg *config.Config, branch string) (plumbing.ReferenceName, error) {
+	b, ok := cfg.Branches[branch]
+	if !ok || b.Remote == "" || b.Merge == "" {
+		return "", fmt.Errorf("%w %q", ErrNoUpstream, branch)
+	}
+
+	return trackingRef(cfg, b.Remote, b.Merge)
+}
+
+// pushRef returns the remote-tracking branch of the branch that pushing the
+// given branch would update, following branch.<name>.pushRemote and
+// push.default as git does.
+func pushRef(cfg *config.Config, pushDefault, branch string) (plumbing.ReferenceName, error) {
+	b, ok := cfg.Branches[branch]
+	if !ok {
+		b = &config.Branch{Name: branch}
+	}
+
+	remote := b.PushRemote
+	if remote == "" {
+		remote = b.Remote
+	}
+
+	if remote == "" {
+		return "", fmt.Errorf("%w %q", ErrNoPushDestination, branch)
+	}
+
+	current := plumbing.NewBranchReferenceName(branch)
+	switch pushDefault {
+	case "nothing":
+		return "", fmt.Errorf("%w %q: push.default is nothing", ErrNoPushDestination, branch)
+	case "upstream", "tracking":
+		if b.Merge == "" || remote != b.Remote {
+			return "", fmt.Errorf("%w %q", ErrNoUpstream, branch)
+		}
+
+		return trackingRef(cfg, remote, b.Merge)
+	case "", "simple":
+		// In a triangular workflow, pushing to a remote other than the
+		// upstream one, simple behaves as current.
+		if remote == b.Remote {
+			if b.Merge == "" {
+				return "", fmt.Errorf("%w %q", ErrNoUpstream, branch)
+			}
+
+			if b.Merge != current {
+				return "", fmt.Errorf("%w %q: upstream %q has a different name", ErrNoPushDestination, branch, b.Merge.Short())
+			}
+		}
+	}
+
+	return trackingRef(cfg, remote, current)
+}
+
+// trackingRef maps the given branch of the remote to its remote-tracking
+// branch using the fetch refspecs of the remote. Branches of the "." remote
+// are local ones, so they are returned as they are.
+func trackingRef(cfg *config.Config, remote string, merge plumbing.ReferenceName) (plumbing.ReferenceName, error) {
+	if remote == "." {
+		return merge, nil
+	}
+
+	rc, ok := cfg.Remotes[remote]
+	if !ok {
+		return "", fmt.Errorf("%w: %q", ErrRemoteNotFound, remote)
+	}
+
+	for _, rs := range rc.Fetch {
+		if rs.Match(merge) {
+			return rs.Dst(merge), nil
+		}
+	}
+
+	return "", fmt.Errorf("%w: %q from %q", ErrUpstreamNotTracked, merge.Short(), remote)
+}
@@ -0,0 +1,142 @@
+package git
+
+import (
+	"testing"
+
+	"github.com/go-git/go-git/v6/config"
+	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/stretchr/testify/suite"
+
+	fixtures "github.com/go-git/go-git-fixtures/v5"
+)
+
+type UpstreamSuite struct {
+	BaseSuite
+	r *Repository
+}
+
+func TestUpstreamSuite(t *testing.T) {
+	suite.Run(t, new(UpstreamSuite))
+}
+
+const (
+	upstreamOrigin = "918c48b83bd081e863dbe1b80f8998f058cd8294"
+	upstreamFork   = "af2d6a6954d532f8ffb47615169c8fdf9d383a1a"
+)
+
+func (s *UpstreamSuite) SetupTest() {
+	s.r = s.NewRepository(fixtures.Basic().One())
+
+	s.Require().NoError(s.r.Storer.SetReference(plumbing.NewHashReference(
+		plumbing.NewRemoteReferenceName("origin", "master"), plumbing.NewHash(upstreamOrigin),
+	)))
+	s.Require().NoError(s.r.Storer.SetReference(plumbing.NewHashReference(
+		plumbing.NewRemoteReferenceName("fork", "master"), plumbing.NewHash(upstreamFork),
+	)))
+
+	_, err := s.r.CreateRemote(&config.RemoteConfig{
+		Name:  "fork",
+		URLs:  []string{"https://example.com/fork"},
+		Fetch: []config.RefSpec{"+refs/heads/*:refs/remotes/fork/*"},
+	})
+	s.Require().NoError(err)
+}
+
+func (s *UpstreamSuite) resolve(rev string) string {
+	h, err := s.r.ResolveRevision(plumbing.Revision(rev))
+	s.Require().NoError(err, rev)
+	return h.String()
+}
+
+func (s *UpstreamSuite) setConfig(f func(*config.Config)) {
+	cfg, err := s.r.Config()
+	s.Require().NoError(err)
+	f(cfg)
+	s.Require().NoError(s.r.SetConfig(cfg))
+}
+
+func (s *UpstreamSuite) TestUpstream() {
+	s.Equal(upstreamOrigin, s.resolve("master@{upstream}"))
+	s.Equal(upstreamOrigin, s.resolve("master@{u}"))
+	s.Equal(upstreamOrigin, s.resolve("@{u}"))
+	s.Equal(upstreamOrigin, s.resolve("HEAD@{u}"))
+	s.Equal(upstreamOrigin, s.resolve("refs/heads/master@{u}"))
+	s.Equal("e8d3ffab552895c19b9fcf7aa264d277cde33881", s.resolve("branch@{u}"))
+}
+
+func (s *UpstreamSuite) TestUpstreamLocal() {
+	s.setConfig(func(cfg *config.Config) {
+		cfg.Branches["branch"].Remote = "."
+		cfg.Branches["branch"].Merge = plumbing.Master
+	})
+
+	s.Equal("6ecf0ef2c2dffb796033e5a02219af86ec6584e5", s.resolve("branch@{u}"))
+}
+
+func (s *UpstreamSuite) TestUpstreamNotConfigured() {
+	s.setConfig(func(cfg *config.Config) {
+		delete(cfg.Branches, "branch")
+	})
+
+	_, err := s.r.ResolveRevision("branch@{u}")
+	s.ErrorIs(err, ErrNoUpstream)
+	s.ErrorContains(err, `no upstream configured for branch "branch"`)
+
+	_, err = s.r.ResolveRevision("v1.0.0@{u}")
+	s.ErrorContains(err, `"v1.0.0" is not a branch`)
+}
+
+func (s *UpstreamSuite) TestUpstreamNotTracked() {
+	s.setConfig(func(cfg *config.Config) {
+		cfg.Remotes["origin"].Fetch = []config.RefSpec{"+refs/heads/branch:refs/remotes/origin/branch"}
+	})
+
+	_, err := s.r.ResolveRevision("master@{u}")
+	s.ErrorIs(err, ErrUpstreamNotTracked)
+}
+
+func (s *UpstreamSuite) TestUpstreamDetachedHEAD() {
+	s.Require().NoError(s.r.Storer.SetReference(plumbing.NewHashReference(
+		plumbing.HEAD, plumbing.NewHash(upstreamOrigin),
+	)))
+
+	_, err := s.r.ResolveRevision("@{u}")
+	s.ErrorIs(err, ErrDetachedHEAD)
+}
+
+func (s *UpstreamSuite) TestPush() {
+	s.Equal(upstreamOrigin, s.resolve("master@{push}"))
+	s.Equal(upstreamOrigin, s.resolve("@{push}"))
+
+	s.setConfig(func(cfg *config.Config) {
+		cfg.Branches["master"].PushRemote = "fork"
+	})
+
+	s.Equal(upstreamFork, s.resolve("master@{push}"))
+	s.Equal(upstreamOrigin, s.resolve("master@{u}"))
+}
+
+func (s *UpstreamSuite) TestPushDefault() {
+	s.setConfig(func(cfg *config.Config) {
+		cfg.Branches["master"].Merge = plumbing.NewBranchReferenceName("branch")
+	})
+
+	_, err := s.r.ResolveRevision("master@{push}")
+	s.ErrorIs(err, ErrNoPushDestination)
+
+	s.setConfig(func(cfg *config.Config) {
+		cfg.Push.Default = "upstream"
+	})
+	s.Equal("e8d3ffab552895c19b9fcf7aa264d277cde33881", s.resolve("master@{push}"))
+
+	s.setConfig(func(cfg *config.Config) {
+		cfg.Push.Default = "current"
+	})
+	s.Equal(upstreamOrigin, s.resolve("master@{push}"))
+
+	s.setConfig(func(cfg *config.Config) {
+		cfg.Push.Default = "nothing"
+	})
+	_, err = s.r.ResolveRevision("master@{push}")
+	s.ErrorIs(err, ErrNoPushDestination)
+}
@@ -8,7 +8,9 @@ package diff
 
 import (
 	"bytes"
+	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/sergi/go-diff/diffmatchpatch"
 )
@@ -38,6 +40,93 @@ func DoWithTimeout(src, dst string, timeout time.Duration) (diffs []diffmatchpat
 	return diffs
 }
 
+// Options describes how the lines are compared by DoWithOptions.
+type Options struct {
+	// IgnoreAllSpace ignores the whitespace when comparing lines, even if
+	// one line has whitespace where the other has none, as `git diff -w`.
+	IgnoreAllSpace bool
+	// IgnoreSpaceChange ignores the changes in the amount of whitespace,
+	// and the whitespace at the end of the lines, as `git diff -b`.
+	IgnoreSpaceChange bool
+}
+
+// DoWithOptions computes the (line oriented) modifications needed to turn
+// the src string into the dst string, the lines being compared as described
+// by opts. The lines are normalized before computing the diff, but the
+// returned diffs hold their original content: the lines considered equal are
+// the ones of src, so Dst may not return dst.
+func DoWithOptions(src, dst string, opts Options) (diffs []diffmatchpatch.Diff) {
+	if !opts.IgnoreAllSpace && !opts.IgnoreSpaceChange {
+		return Do(src, dst)
+	}
+
+	srcLines, dstLines := splitLines(src), splitLines(dst)
+	keys := make(map[string]rune)
+	toRunes := func(lines []string) []rune {
+		runes := make([]rune, len(lines))
+		for i, l := range lines {
+			key := normalizeLine(l, opts)
+			r, ok := keys[key]
+			if !ok {
+				r = rune(len(keys))
+				keys[key] = r
+			}
+
+			runes[i] = r
+		}
+
+		return runes
+	}
+
+	dmp := diffmatchpatch.New()
+	dmp.DiffTimeout = time.Hour
+	runeDiffs := dmp.DiffMainRunes(toRunes(srcLines), toRunes(dstLines), false)
+
+	var i, j int
+	for _, d := range runeDiffs {
+		n := utf8.RuneCountInString(d.Text)
+		switch d.Type {
+		case diffmatchpatch.DiffEqual:
+			d.Text = strings.Join(srcLines[i:i+n], "")
+			i, j = i+n, j+n
+		case diffmatchpatch.DiffDelete:
+			d.Text = strings.Join(srcLines[i:i+n], "")
+			i += n
+		case diffmatchpatch.DiffInsert:
+			d.Text = strings.Join(dstLines[j:j+n], "")
+			j += n
+		}
+
+		diffs = append(diffs, d)
+	}
+
+	return diffs
+}
+
+// normalizeLine returns the line as compared with the given options. The
+// line feed is kept, so a missing one at the end of the text is a change.
+func normalizeLine(line string, opts Options) string {
+	content, hasLF := strings.CutSuffix(line, "\n")
+	if opts.IgnoreAllSpace {
+		content = strings.Join(strings.Fields(content), "")
+	} else {
+		content = strings.Join(strings.Fields(content), " ")
+		if content != "" && isSpace(line[0]) {
+			content = " " + content
+		}
+	}
+
+	if hasLF {
+		content += "\n"
+	}
+
+	return content
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\r' || c == '\v' || c == '\f'
+}
+
 // Dst computes and returns the destination text.
 func Dst(diffs []diffmatchpatch.Diff) string {
 	var text bytes.Buffer
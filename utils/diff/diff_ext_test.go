@@ -141,3 +141,58 @@ func (s *suiteCommon) TestDo() {
 		s.Equal(t.exp, diffs, fmt.Sprintf("subtest %d", i))
 	}
 }
+
+var doWithOptionsTests = [...]struct {
+	src, dst string
+	opts     diff.Options
+	exp      []diffmatchpatch.Diff
+}{
+	{
+		src:  "a b\n\tc\n",
+		dst:  "a  b \nc\n",
+		opts: diff.Options{IgnoreSpaceChange: true},
+		exp: []diffmatchpatch.Diff{
+			{Type: 0, Text: "a b\n"},
+			{Type: -1, Text: "\tc\n"},
+			{Type: 1, Text: "c\n"},
+		},
+	},
+	{
+		src:  "a b\n\tc\n",
+		dst:  "ab \nc\n",
+		opts: diff.Options{IgnoreAllSpace: true},
+		exp: []diffmatchpatch.Diff{
+			{Type: 0, Text: "a b\n\tc\n"},
+		},
+	},
+	{
+		src:  "a\nb\r\nc\n",
+		dst:  "a\nB\nb\nc",
+		opts: diff.Options{IgnoreAllSpace: true},
+		exp: []diffmatchpatch.Diff{
+			{Type: 0, Text: "a\n"},
+			{Type: 1, Text: "B\n"},
+			{Type: 0, Text: "b\r\n"},
+			{Type: -1, Text: "c\n"},
+			{Type: 1, Text: "c"},
+		},
+	},
+	{
+		src:  "a\n b\n",
+		dst:  "a\nb\n",
+		opts: diff.Options{},
+		exp: []diffmatchpatch.Diff{
+			{Type: 0, Text: "a\n"},
+			{Type: -1, Text: " b\n"},
+			{Type: 1, Text: "b\n"},
+		},
+	},
+}
+
+func (s *suiteCommon) TestDoWithOptions() {
+	for i, t := range doWithOptionsTests {
+		diffs := diff.DoWithOptions(t.src, t.dst, t.opts)
+		s.Equal(t.exp, diffs, fmt.Sprintf("subtest %d", i))
+		s.Equal(t.src, diff.Src(diffs), fmt.Sprintf("subtest %d", i))
+	}
+}
@@ -0,0 +1,185 @@
+package diff
+
+import (
+	"strings"
+
+	"github.com/sergi/go-diff/diffmatchpatch"
+)
+
+const (
+	conflictMarkerOurs   = "<<<<<<<"
+	conflictMarkerSep    = "======="
+	conflictMarkerTheirs = ">>>>>>>"
+)
+
+// hunk represents a change to the base text: the base lines in the range
+// [start, end) are replaced by lines.
+type hunk struct {
+	start, end int
+	lines      []string
+}
+
+// Merge performs a line oriented three-way merge, in the spirit of diff3,
+// of ours and theirs using base as their common ancestor.
+//
+// Changes made by only one side are applied to the result. Overlapping or
+// adjacent changes made by both sides are a conflict, unless both sides made
+// the exact same change. Conflicting regions are written to the result
+// surrounded by conflict markers, labelled with oursLabel and theirsLabel.
+//
+// The merged text is returned along with the number of conflicting regions
+// found; a merge is clean when the latter is zero.
+func Merge(base, ours, theirs, oursLabel, theirsLabel string) (merged string, conflicts int) {
+	lines := splitLines(base)
+	a := hunks(base, ours)
+	b := hunks(base, theirs)
+
+	var out strings.Builder
+	var pos, i, j int
+	for i < len(a) || j < len(b) {
+		ai, bj := i, j
+
+		var end int
+		if j >= len(b) || (i < len(a) && a[i].start <= b[j].start) {
+			out.WriteString(strings.Join(lines[pos:a[i].start], ""))
+			pos, end = a[i].start, a[i].end
+			i++
+		} else {
+			out.WriteString(strings.Join(lines[pos:b[j].start], ""))
+			pos, end = b[j].start, b[j].end
+			j++
+		}
+
+		// Grow the region while any hunk of either side overlaps or touches
+		// it, so that every change within it is considered together.
+		for {
+			if i < len(a) && a[i].start <= end {
+				end = max(end, a[i].end)
+				i++
+				continue
+			}
+
+			if j < len(b) && b[j].start <= end {
+				end = max(end, b[j].end)
+				j++
+				continue
+			}
+
+			break
+		}
+
+		oursText := apply(lines, a[ai:i], pos, end)
+		theirsText := apply(lines, b[bj:j], pos, end)
+
+		switch {
+		case ai == i:
+			out.WriteString(theirsText)
+		case bj == j, oursText == theirsText:
+			out.WriteString(oursText)
+		default:
+			conflicts++
+			writeConflict(&out, oursText, theirsText, oursLabel, theirsLabel)
+		}
+
+		pos = end
+	}
+
+	out.WriteString(strings.Join(lines[pos:], ""))
+	return out.String(), conflicts
+}
+
+// hunks returns the changes needed to turn base into other, sorted by their
+// position in base.
+func hunks(base, other string) []hunk {
+	var hs []hunk
+	var cur *hunk
+	var pos int
+
+	for _, d := range Do(base, other) {
+		lines := splitLines(d.Text)
+		if d.Type == diffmatchpatch.DiffEqual {
+			if cur != nil {
+				hs = append(hs, *cur)
+				cur = nil
+			}
+
+			pos += len(lines)
+			continue
+		}
+
+		if cur == nil {
+			cur = &hunk{start: pos, end: pos}
+		}
+
+		switch d.Type {
+		case diffmatchpatch.DiffDelete:
+			cur.end += len(lines)
+			pos += len(lines)
+		case diffmatchpatch.DiffInsert:
+			cur.lines = append(cur.lines, lines...)
+		}
+	}
+
+	if cur != nil {
+		hs = append(hs, *cur)
+	}
+
+	return hs
+}
+
+// apply returns the text of base lines in the range [start, end) after
+// applying the given hunks, which must be contained within that range.
+func apply(base []string, hs []hunk, start, end int) string {
+	var out strings.Builder
+	pos := start
+	for _, h := range hs {
+		out.WriteString(strings.Join(base[pos:h.start], ""))
+		out.WriteString(strings.Join(h.lines, ""))
+		pos = h.end
+	}
+
+	out.WriteString(strings.Join(base[pos:end], ""))
+	return out.String()
+}
+
+func writeConflict(out *strings.Builder, ours, theirs, oursLabel, theirsLabel string) {
+	writeMarker(out, conflictMarkerOurs, oursLabel)
+	writeTerminated(out, ours)
+	writeMarker(out, conflictMarkerSep, "")
+	writeTerminated(out, theirs)
+	writeMarker(out, conflictMarkerTheirs, theirsLabel)
+}
+
+func writeMarker(out *strings.Builder, marker, label string) {
+	out.WriteString(marker)
+	if label != "" {
+		out.WriteString(" ")
+		out.WriteString(label)
+	}
+
+	out.WriteString("\n")
+}
+
+// writeTerminated writes s ensuring that it ends with a new line, so the
+// following conflict marker starts on its own line.
+func writeTerminated(out *strings.Builder, s string) {
+	out.WriteString(s)
+	if s != "" && !strings.HasSuffix(s, "\n") {
+		out.WriteString("\n")
+	}
+}
+
+// splitLines splits s after each new line, keeping the terminators. A last
+// line without a terminator is kept as is.
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+
+	lines := strings.SplitAfter(s, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+
+	return lines
+}
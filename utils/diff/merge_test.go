@@ -0,0 +1,67 @@
+package diff_test
+
+import (
+	"fmt"
+
+	"github.com/go-git/go-git/v6/utils/diff"
+)
+
+var mergeTests = [...]struct {
+	base, ours, theirs string
+	merged             string
+	conflicts          int
+}{
+	// no changes
+	{"a\nb\nc\n", "a\nb\nc\n", "a\nb\nc\n", "a\nb\nc\n", 0},
+	// only one side changes
+	{"a\nb\nc\n", "a\nB\nc\n", "a\nb\nc\n", "a\nB\nc\n", 0},
+	{"a\nb\nc\n", "a\nb\nc\n", "a\nb\nC\n", "a\nb\nC\n", 0},
+	// both sides change different regions
+	{"a\nb\nc\nd\ne\n", "A\nb\nc\nd\ne\n", "a\nb\nc\nd\nE\n", "A\nb\nc\nd\nE\n", 0},
+	// both sides make the same change
+	{"a\nb\nc\n", "a\nX\nc\n", "a\nX\nc\n", "a\nX\nc\n", 0},
+	// insertions and deletions on different regions
+	{"a\nb\nc\nd\ne\n", "a\nc\nd\ne\n", "a\nb\nc\nd\ne\nf\n", "a\nc\nd\ne\nf\n", 0},
+	// empty base
+	{"", "a\n", "a\n", "a\n", 0},
+	// overlapping changes
+	{
+		"a\nb\nc\n", "a\nX\nc\n", "a\nY\nc\n",
+		"a\n<<<<<<< ours\nX\n=======\nY\n>>>>>>> theirs\nc\n", 1,
+	},
+	// add/add with different content
+	{
+		"", "x\n", "y\n",
+		"<<<<<<< ours\nx\n=======\ny\n>>>>>>> theirs\n", 1,
+	},
+	// missing trailing new line in a conflict
+	{
+		"a\nb", "a\nx", "a\ny",
+		"a\n<<<<<<< ours\nx\n=======\ny\n>>>>>>> theirs\n", 1,
+	},
+	// one side deletes what the other modifies
+	{
+		"a\nb\nc\n", "a\nc\n", "a\nB\nc\n",
+		"a\n<<<<<<< ours\n=======\nB\n>>>>>>> theirs\nc\n", 1,
+	},
+	// two separate conflicts
+	{
+		"a\nb\nc\nd\ne\n", "1\nb\nc\nd\n2\n", "3\nb\nc\nd\n4\n",
+		"<<<<<<< ours\n1\n=======\n3\n>>>>>>> theirs\nb\nc\nd\n" +
+			"<<<<<<< ours\n2\n=======\n4\n>>>>>>> theirs\n", 2,
+	},
+}
+
+func (s *suiteCommon) TestMerge() {
+	for i, t := range mergeTests {
+		merged, conflicts := diff.Merge(t.base, t.ours, t.theirs, "ours", "theirs")
+		s.Equal(t.merged, merged, fmt.Sprintf("subtest %d", i))
+		s.Equal(t.conflicts, conflicts, fmt.Sprintf("subtest %d", i))
+	}
+}
+
+func (s *suiteCommon) TestMergeWithoutLabels() {
+	merged, conflicts := diff.Merge("a\n", "b\n", "c\n", "", "")
+	s.Equal("<<<<<<<\nb\n=======\nc\n>>>>>>>\n", merged)
+	s.Equal(1, conflicts)
+}
@@ -25,6 +25,9 @@ var ignore = map[string]bool{
 type node struct {
 	fs         billy.Filesystem
 	submodules map[string]plumbing.Hash
+	format     format.ObjectFormat
+	filter     func(path string, content []byte) ([]byte, error)
+	symlinks   map[string]bool
 
 	path     string
 	hash     []byte
@@ -43,7 +46,40 @@ func NewRootNode(
 	fs billy.Filesystem,
 	submodules map[string]plumbing.Hash,
 ) noder.Noder {
-	return &node{fs: fs, submodules: submodules, isDir: true}
+	return NewRootNodeWithOptions(fs, submodules, Options{})
+}
+
+// Options contains the options used to build the nodes.
+type Options struct {
+	// ObjectFormat is the format used to hash the files, it must match the
+	// object format of the repository the nodes are compared with. If left
+	// unset SHA1 is used.
+	ObjectFormat format.ObjectFormat
+	// Filter, if not nil, is given the path and the content of every regular
+	// file, and returns the content to hash instead, e.g. with its line
+	// endings normalized as when the file is added to the repository.
+	Filter func(path string, content []byte) ([]byte, error)
+	// Symlinks holds the paths of the symbolic links checked out as regular
+	// files containing their target, as done when core.symlinks is false.
+	// These files are hashed as symbolic links, their content unfiltered.
+	Symlinks map[string]bool
+}
+
+// NewRootNodeWithOptions returns the root node based on a given
+// billy.Filesystem and the given options. See NewRootNode for more info.
+func NewRootNodeWithOptions(
+	fs billy.Filesystem,
+	submodules map[string]plumbing.Hash,
+	opts Options,
+) noder.Noder {
+	return &node{
+		fs:         fs,
+		submodules: submodules,
+		format:     opts.ObjectFormat,
+		filter:     opts.Filter,
+		symlinks:   opts.Symlinks,
+		isDir:      true,
+	}
 }
 
 // Hash the hash of a filesystem is the result of concatenating the computed
@@ -134,6 +170,9 @@ func (n *node) newChildNode(file os.FileInfo) (*node, error) {
 	node := &node{
 		fs:         n.fs,
 		submodules: n.submodules,
+		format:     n.format,
+		filter:     n.filter,
+		symlinks:   n.symlinks,
 
 		path:  path,
 		isDir: file.IsDir(),
@@ -162,6 +201,9 @@ func (n *node) calculateHash() {
 		n.hash = append(submoduleHash.Bytes(), filemode.Submodule.Bytes()...)
 		return
 	}
+	if n.mode.IsRegular() && n.symlinks[n.path] {
+		mode = filemode.Symlink
+	}
 	var hash plumbing.Hash
 	if n.mode&os.ModeSymlink != 0 {
 		hash = n.doCalculateHashForSymlink()
@@ -179,7 +221,12 @@ func (n *node) doCalculateHashForRegular() plumbing.Hash {
 
 	defer f.Close()
 
-	h := plumbing.NewHasher(format.SHA1, plumbing.BlobObject, n.size)
+	// The target of a symbolic link checked out as a file is never filtered.
+	if n.filter != nil && !n.symlinks[n.path] {
+		return n.doCalculateHashForFiltered(f)
+	}
+
+	h := plumbing.NewHasher(n.format, plumbing.BlobObject, n.size)
 	if _, err := io.Copy(h, f); err != nil {
 		return plumbing.ZeroHash
 	}
@@ -187,13 +234,32 @@ func (n *node) doCalculateHashForRegular() plumbing.Hash {
 	return h.Sum()
 }
 
+func (n *node) doCalculateHashForFiltered(f io.Reader) plumbing.Hash {
+	content, err := io.ReadAll(f)
+	if err != nil {
+		return plumbing.ZeroHash
+	}
+
+	content, err = n.filter(n.path, content)
+	if err != nil {
+		return plumbing.ZeroHash
+	}
+
+	h := plumbing.NewHasher(n.format, plumbing.BlobObject, int64(len(content)))
+	if _, err := h.Write(content); err != nil {
+		return plumbing.ZeroHash
+	}
+
+	return h.Sum()
+}
+
 func (n *node) doCalculateHashForSymlink() plumbing.Hash {
 	target, err := n.fs.Readlink(n.path)
 	if err != nil {
 		return plumbing.ZeroHash
 	}
 
-	h := plumbing.NewHasher(format.SHA1, plumbing.BlobObject, n.size)
+	h := plumbing.NewHasher(n.format, plumbing.BlobObject, n.size)
 	if _, err := h.Write([]byte(target)); err != nil {
 		return plumbing.ZeroHash
 	}
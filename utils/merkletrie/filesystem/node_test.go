@@ -89,6 +89,57 @@ func (s *NoderSuite) TestDiffChangeContent() {
 	s.Len(ch, 1)
 }
 
+func (s *NoderSuite) TestDiffFilter() {
+	fsA := memfs.New()
+	WriteFile(fsA, "foo", []byte("foo\n"), 0644)
+	WriteFile(fsA, "qux/bar", []byte("bar\n"), 0644)
+
+	fsB := memfs.New()
+	WriteFile(fsB, "foo", []byte("foo\r\n"), 0644)
+	WriteFile(fsB, "qux/bar", []byte("bar\r\n"), 0644)
+
+	var paths []string
+	ch, err := merkletrie.DiffTree(
+		NewRootNode(fsA, nil),
+		NewRootNodeWithOptions(fsB, nil, Options{
+			Filter: func(path string, content []byte) ([]byte, error) {
+				paths = append(paths, path)
+				return bytes.ReplaceAll(content, []byte("\r\n"), []byte("\n")), nil
+			},
+		}),
+		IsEquals,
+	)
+
+	s.NoError(err)
+	s.Len(ch, 0)
+	s.ElementsMatch([]string{"foo", "qux/bar"}, paths)
+}
+
+func (s *NoderSuite) TestDiffSymlinksOption() {
+	fsA := memfs.New()
+	fsA.Symlink("qux", "foo")
+	WriteFile(fsA, "bar", []byte("qux"), 0644)
+
+	fsB := memfs.New()
+	WriteFile(fsB, "foo", []byte("qux"), 0644)
+	WriteFile(fsB, "bar", []byte("qux"), 0644)
+
+	ch, err := merkletrie.DiffTree(
+		NewRootNode(fsA, nil),
+		NewRootNodeWithOptions(fsB, nil, Options{
+			Symlinks: map[string]bool{"foo": true},
+			Filter: func(path string, content []byte) ([]byte, error) {
+				return append(content, '\n'), nil
+			},
+		}),
+		IsEquals,
+	)
+
+	s.NoError(err)
+	s.Len(ch, 1)
+	s.Equal("bar", ch[0].To.String())
+}
+
 func (s *NoderSuite) TestDiffSymlinkDirOnA() {
 	fsA := memfs.New()
 	WriteFile(fsA, "qux/qux", []byte("foo"), 0644)
@@ -3,6 +3,7 @@ package sync
 import (
 	"bytes"
 	"compress/zlib"
+	"fmt"
 	"io"
 	"sync"
 )
@@ -19,8 +20,21 @@ var (
 			return zlib.NewWriter(nil)
 		},
 	}
+	// zlibLevelWriters are the pools of the writers of each compression
+	// level, indexed by level+1. The default level uses zlibWriter.
+	zlibLevelWriters [zlib.BestCompression + 2]sync.Pool
 )
 
+func init() {
+	for i := range zlibLevelWriters {
+		level := i - 1
+		zlibLevelWriters[i].New = func() interface{} {
+			w, _ := zlib.NewWriterLevel(nil, level)
+			return w
+		}
+	}
+}
+
 type zlibReadCloser interface {
 	io.ReadCloser
 	zlib.Resetter
@@ -85,3 +99,35 @@ func GetZlibWriter(w io.Writer) *zlib.Writer {
 func PutZlibWriter(w *zlib.Writer) {
 	zlibWriter.Put(w)
 }
+
+// GetZlibWriterLevel returns a *zlib.Writer compressing with the given level,
+// from zlib.DefaultCompression to zlib.BestCompression, that is managed by a
+// sync.Pool. Returns a writer that is reset with w and ready for use, or an
+// error if the level is invalid.
+//
+// After use, the *zlib.Writer should be put back into the sync.Pool by
+// calling PutZlibWriterLevel with the same level.
+func GetZlibWriterLevel(w io.Writer, level int) (*zlib.Writer, error) {
+	if level == zlib.DefaultCompression {
+		return GetZlibWriter(w), nil
+	}
+
+	if level < zlib.DefaultCompression || level > zlib.BestCompression {
+		return nil, fmt.Errorf("zlib: invalid compression level: %d", level)
+	}
+
+	z := zlibLevelWriters[level+1].Get().(*zlib.Writer)
+	z.Reset(w)
+	return z, nil
+}
+
+// PutZlibWriterLevel puts w, compressing with the given level, back into its
+// sync.Pool.
+func PutZlibWriterLevel(w *zlib.Writer, level int) {
+	if level == zlib.DefaultCompression {
+		PutZlibWriter(w)
+		return
+	}
+
+	zlibLevelWriters[level+1].Put(w)
+}
@@ -72,3 +72,39 @@ func (f *FakeZLibReader) Close() error {
 	f.wasClosed = true
 	return nil
 }
+
+func TestGetAndPutZlibWriterLevel(t *testing.T) {
+	for level := zlib.DefaultCompression; level <= zlib.BestCompression; level++ {
+		var buf bytes.Buffer
+		w, err := GetZlibWriterLevel(&buf, level)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if _, err := w.Write([]byte("foo")); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if err := w.Close(); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		PutZlibWriterLevel(w, level)
+
+		r, err := zlib.NewReader(&buf)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		b, err := io.ReadAll(r)
+		if err != nil || string(b) != "foo" {
+			t.Errorf("level %d: unexpected content %q: %v", level, b, err)
+		}
+	}
+
+	for _, level := range []int{-2, 10} {
+		if _, err := GetZlibWriterLevel(nil, level); err == nil {
+			t.Errorf("level %d: error was expected", level)
+		}
+	}
+}
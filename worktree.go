@@ -1,13 +1,17 @@
 package git
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
 	"io"
 	"os"
+	"path"
 	"path/filepath"
+	"regexp"
 	"runtime"
+	"sort"
 	"strings"
 	"time"
 
@@ -16,10 +20,14 @@ import (
 	"github.com/go-git/go-git/v6/config"
 	"github.com/go-git/go-git/v6/plumbing"
 	"github.com/go-git/go-git/v6/plumbing/filemode"
+	"github.com/go-git/go-git/v6/plumbing/filter"
 	"github.com/go-git/go-git/v6/plumbing/format/gitignore"
 	"github.com/go-git/go-git/v6/plumbing/format/index"
+	"github.com/go-git/go-git/v6/plumbing/format/pathspec"
 	"github.com/go-git/go-git/v6/plumbing/object"
+	"github.com/go-git/go-git/v6/plumbing/protocol/packp/sideband"
 	"github.com/go-git/go-git/v6/plumbing/storer"
+	"github.com/go-git/go-git/v6/utils/binary"
 	"github.com/go-git/go-git/v6/utils/ioutil"
 	"github.com/go-git/go-git/v6/utils/merkletrie"
 	"github.com/go-git/go-git/v6/utils/sync"
@@ -42,6 +50,12 @@ type Worktree struct {
 	Filesystem billy.Filesystem
 	// External excludes not found in the repository .gitignore
 	Excludes []gitignore.Pattern
+	// Filters are the drivers of the filters assigned by the gitattributes
+	// to the files of the worktree, by filter name, taking precedence over
+	// the ones registered with filter.Register. The drivers holding the
+	// state of a repository, such as the one of Repository.LFSDriver, are
+	// set here instead of being registered for the whole process.
+	Filters map[string]filter.Driver
 
 	r *Repository
 }
@@ -131,14 +145,19 @@ func (w *Worktree) PullContext(ctx context.Context, o *PullOptions) error {
 		return err
 	}
 
-	if err := w.updateHEAD(ref.Hash()); err != nil {
+	if err := w.r.setOrigHead(); err != nil {
 		return err
 	}
 
-	if err := w.Reset(&ResetOptions{
-		Mode:   MergeReset,
-		Commit: ref.Hash(),
-	}); err != nil {
+	if err := w.updateHEAD(ref.Hash(), nil, "pull: Fast-forward"); err != nil {
+		return err
+	}
+
+	if err := w.reset(&ResetOptions{
+		Mode:     MergeReset,
+		Commit:   ref.Hash(),
+		Progress: o.Progress,
+	}, ""); err != nil {
 		return err
 	}
 
@@ -167,6 +186,10 @@ func (w *Worktree) Checkout(opts *CheckoutOptions) error {
 		return err
 	}
 
+	if len(opts.Pathspecs) > 0 {
+		return w.checkoutPathspecs(opts)
+	}
+
 	if opts.Create {
 		if err := w.createBranch(opts); err != nil {
 			return err
@@ -179,27 +202,115 @@ func (w *Worktree) Checkout(opts *CheckoutOptions) error {
 	}
 
 	ro := &ResetOptions{
-		Commit:     c,
-		Mode:       MergeReset,
-		SparseDirs: opts.SparseCheckoutDirectories,
-	}
-	if opts.Force {
+		Commit:         c,
+		Mode:           MergeReset,
+		SparseDirs:     opts.SparseCheckoutDirectories,
+		SparsePatterns: opts.SparsePatterns,
+		SparseCone:     opts.SparseCone,
+		Progress:       opts.Progress,
+		FileProgress:   opts.FileProgress,
+	}
+	switch {
+	case opts.NoCheckout:
+		ro.Mode = MixedReset
+	case opts.Force:
 		ro.Mode = HardReset
-	} else if opts.Keep {
+	case opts.Keep:
 		ro.Mode = SoftReset
 	}
 
-	if !opts.Hash.IsZero() && !opts.Create {
-		err = w.setHEADToCommit(opts.Hash)
+	// HEAD is set to the commit, even when Hash is the one of a tag.
+	detached := !opts.Hash.IsZero() && !opts.Create
+	if detached {
+		err = w.setHEADToCommit(c)
 	} else {
-		err = w.setHEADToBranch(opts.Branch, c)
+		detached, err = w.setHEADToBranch(opts.Branch, c)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if err := w.reset(ro, ""); err != nil {
+		return err
+	}
+
+	if detached && opts.Progress != nil {
+		return w.writeDetachedHEADNotice(opts.Progress, c)
+	}
+
+	return nil
+}
+
+const detachedHEADNotice = `Note: switching to '%s'.
+
+You are in 'detached HEAD' state. You can look around, make experimental
+changes and commit them, and you can discard any commits you make in this
+state without impacting any branches by switching back to a branch.
+
+HEAD is now at %s %s
+`
+
+// writeDetachedHEADNotice writes to p the notice given by git when HEAD is
+// detached at the given commit.
+func (w *Worktree) writeDetachedHEADNotice(p io.Writer, commit plumbing.Hash) error {
+	c, err := w.r.CommitObject(commit)
+	if err != nil {
+		return err
+	}
+
+	subject, _, _ := strings.Cut(c.Message, "\n")
+	_, err = fmt.Fprintf(p, detachedHEADNotice, commit, commit.String()[:7], subject)
+	return err
+}
+
+// checkoutPathspecs restores the files matching the pathspecs of the options
+// from the commit they give, without moving HEAD.
+func (w *Worktree) checkoutPathspecs(opts *CheckoutOptions) error {
+	m, err := pathspec.NewMatcher(opts.Pathspecs)
+	if err != nil {
+		return err
+	}
+
+	var c plumbing.Hash
+	if opts.Hash.IsZero() && opts.Branch == "" {
+		head, err := w.r.Head()
+		if err != nil {
+			return err
+		}
+
+		c = head.Hash()
+	} else if c, err = w.getCommitFromCheckoutOptions(opts); err != nil {
+		return err
+	}
+
+	t, err := w.r.getTreeFromCommitHash(c)
+	if err != nil {
+		return err
+	}
+
+	var files []string
+	err = t.Files().ForEach(func(f *object.File) error {
+		if m.Match(f.Name) {
+			files = append(files, f.Name)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if len(files) == 0 {
+		return ErrPathspecNoMatches
 	}
 
+	idx, _, err := w.resetIndex(t, nil, nil, files)
 	if err != nil {
 		return err
 	}
 
-	return w.Reset(ro)
+	return w.resetWorktree(t, idx, files, opts.Progress, opts.FileProgress)
 }
 
 func (w *Worktree) createBranch(opts *CheckoutOptions) error {
@@ -216,6 +327,7 @@ func (w *Worktree) createBranch(opts *CheckoutOptions) error {
 		return err
 	}
 
+	from := opts.Hash.String()
 	if opts.Hash.IsZero() {
 		ref, err := w.r.Head()
 		if err != nil {
@@ -223,11 +335,17 @@ func (w *Worktree) createBranch(opts *CheckoutOptions) error {
 		}
 
 		opts.Hash = ref.Hash()
+		from = plumbing.HEAD.String()
 	}
 
-	return w.r.Storer.SetReference(
+	err = w.r.Storer.SetReference(
 		plumbing.NewHashReference(opts.Branch, opts.Hash),
 	)
+	if err != nil {
+		return err
+	}
+
+	return w.r.logRefUpdate(opts.Branch, plumbing.ZeroHash, opts.Hash, nil, "branch: Created from "+from)
 }
 
 func (w *Worktree) getCommitFromCheckoutOptions(opts *CheckoutOptions) (plumbing.Hash, error) {
@@ -262,36 +380,81 @@ func (w *Worktree) getCommitFromCheckoutOptions(opts *CheckoutOptions) (plumbing
 
 func (w *Worktree) setHEADToCommit(commit plumbing.Hash) error {
 	head := plumbing.NewHashReference(plumbing.HEAD, commit)
-	return w.r.Storer.SetReference(head)
+	return w.checkoutHEAD(head, commit, commit.String())
 }
 
-func (w *Worktree) setHEADToBranch(branch plumbing.ReferenceName, commit plumbing.Hash) error {
+// setHEADToBranch sets HEAD to the given branch, or detaches it at the
+// given commit if the reference isn't a branch, reporting whether it did.
+func (w *Worktree) setHEADToBranch(branch plumbing.ReferenceName, commit plumbing.Hash) (detached bool, err error) {
 	target, err := w.r.Storer.Reference(branch)
 	if err != nil {
-		return err
+		return false, err
 	}
 
-	var head *plumbing.Reference
 	if target.Name().IsBranch() {
-		head = plumbing.NewSymbolicReference(plumbing.HEAD, target.Name())
-	} else {
-		head = plumbing.NewHashReference(plumbing.HEAD, commit)
+		head := plumbing.NewSymbolicReference(plumbing.HEAD, target.Name())
+		return false, w.checkoutHEAD(head, commit, target.Name().Short())
+	}
+
+	return true, w.setHEADToCommit(commit)
+}
+
+// checkoutHEAD sets HEAD to the given reference, logging the checkout of the
+// commit under the name `to`.
+func (w *Worktree) checkoutHEAD(head *plumbing.Reference, commit plumbing.Hash, to string) error {
+	old, err := w.r.referenceHash(plumbing.HEAD)
+	if err != nil {
+		return err
+	}
+
+	from := old.String()
+	current, err := w.r.Storer.Reference(plumbing.HEAD)
+	if err == nil && current.Type() == plumbing.SymbolicReference {
+		from = current.Target().Short()
+	}
+
+	if err := w.r.Storer.SetReference(head); err != nil {
+		return err
 	}
 
-	return w.r.Storer.SetReference(head)
+	msg := fmt.Sprintf("checkout: moving from %s to %s", from, to)
+	return w.r.logRefUpdate(plumbing.HEAD, old, commit, nil, msg)
 }
 
-// Reset the worktree to a specified state.
+// Reset the worktree to a specified state. Unless only some Files are reset,
+// the commit HEAD points to is recorded in ORIG_HEAD before HEAD is moved, as
+// git does.
 func (w *Worktree) Reset(opts *ResetOptions) error {
-	start := time.Now()
-	defer func() {
-		trace.Performance.Printf("performance: %.9f s: reset_worktree", time.Since(start).Seconds())
-	}()
+	if err := opts.Validate(w.r); err != nil {
+		return err
+	}
+
+	if len(opts.Files) == 0 {
+		if err := w.r.setOrigHead(); err != nil {
+			return err
+		}
+	}
 
+	return w.doReset(opts, "")
+}
+
+// reset resets the worktree, logging the update of HEAD with the given
+// message, or with the default reset one if empty.
+func (w *Worktree) reset(opts *ResetOptions, reflogMsg string) error {
 	if err := opts.Validate(w.r); err != nil {
 		return err
 	}
 
+	return w.doReset(opts, reflogMsg)
+}
+
+// doReset performs a reset with options already validated.
+func (w *Worktree) doReset(opts *ResetOptions, reflogMsg string) error {
+	start := time.Now()
+	defer func() {
+		trace.Performance.Printf("performance: %.9f s: reset_worktree", time.Since(start).Seconds())
+	}()
+
 	if opts.Mode == MergeReset {
 		unstaged, err := w.containsUnstagedChanges()
 		if err != nil {
@@ -303,8 +466,19 @@ func (w *Worktree) Reset(opts *ResetOptions) error {
 		}
 	}
 
+	// A reset of the whole worktree aborts any merge in progress.
+	if len(opts.Files) == 0 {
+		if err := w.r.removeMergeState(); err != nil {
+			return err
+		}
+	}
+
+	if reflogMsg == "" {
+		reflogMsg = fmt.Sprintf("reset: moving to %s", opts.Commit)
+	}
+
 	if opts.Mode == SoftReset {
-		return w.setHEADCommit(opts.Commit)
+		return w.setHEADCommit(opts.Commit, reflogMsg)
 	}
 
 	t, err := w.r.getTreeFromCommitHash(opts.Commit)
@@ -318,30 +492,24 @@ func (w *Worktree) Reset(opts *ResetOptions) error {
 		}
 	}
 
-	if err := w.setHEADCommit(opts.Commit); err != nil {
+	if err := w.setHEADCommit(opts.Commit, reflogMsg); err != nil {
 		return err
 	}
 
-	var removedFiles []string
-	if opts.Mode == MixedReset || opts.Mode == MergeReset || opts.Mode == HardReset {
-		if removedFiles, err = w.resetIndex(t, opts.SparseDirs, opts.Files); err != nil {
-			return err
-		}
-	}
-
-	if opts.Mode == MergeReset && len(removedFiles) > 0 {
-		if err := w.resetWorktree(t, removedFiles); err != nil {
-			return err
-		}
+	sparse := newSparseMatcher(opts.SparsePatterns, opts.SparseCone)
+	idx, removedFiles, err := w.resetIndex(t, opts.SparseDirs, sparse, opts.Files)
+	if err != nil {
+		return err
 	}
 
-	if opts.Mode == HardReset {
-		if err := w.resetWorktree(t, opts.Files); err != nil {
-			return err
-		}
+	switch {
+	case opts.Mode == MergeReset && len(removedFiles) > 0:
+		return w.resetWorktree(t, idx, removedFiles, opts.Progress, opts.FileProgress)
+	case opts.Mode == HardReset:
+		return w.resetWorktree(t, idx, opts.Files, opts.Progress, opts.FileProgress)
+	default:
+		return w.r.Storer.SetIndex(idx)
 	}
-
-	return nil
 }
 
 // treeContainsDirs checks if the given tree contains all the directories.
@@ -399,24 +567,26 @@ func (w *Worktree) Restore(o *RestoreOptions) error {
 	return ErrRestoreWorktreeOnlyNotSupported
 }
 
-func (w *Worktree) resetIndex(t *object.Tree, dirs []string, files []string) ([]string, error) {
+// resetIndex returns the index updated to match the given tree, along with the
+// files changed. The index isn't stored, see resetWorktree.
+func (w *Worktree) resetIndex(t *object.Tree, dirs []string, sparse gitignore.Matcher, files []string) (*index.Index, []string, error) {
 	idx, err := w.r.Storer.Index()
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	b := newIndexBuilder(idx)
 
 	changes, err := w.diffTreeWithStaging(t, true)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	var removedFiles []string
 	for _, ch := range changes {
 		a, err := ch.Action()
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 
 		var name string
@@ -427,7 +597,7 @@ func (w *Worktree) resetIndex(t *object.Tree, dirs []string, files []string) ([]
 			name = ch.To.String()
 			e, err = t.FindEntry(name)
 			if err != nil {
-				return nil, err
+				return nil, nil, err
 			}
 		case merkletrie.Delete:
 			name = ch.From.String()
@@ -460,7 +630,11 @@ func (w *Worktree) resetIndex(t *object.Tree, dirs []string, files []string) ([]
 		idx.SkipUnless(dirs)
 	}
 
-	return removedFiles, w.r.Storer.SetIndex(idx)
+	if sparse != nil {
+		applySparseMatcher(idx, sparse)
+	}
+
+	return idx, removedFiles, nil
 }
 
 func inFiles(files []string, v string) bool {
@@ -474,18 +648,31 @@ func inFiles(files []string, v string) bool {
 	return false
 }
 
-func (w *Worktree) resetWorktree(t *object.Tree, files []string) error {
-	changes, err := w.diffStagingWithWorktree(true, false)
+// checkoutCheckpoint is the number of files updated in the working tree
+// between two writes of the index, see resetWorktree.
+var checkoutCheckpoint = 256
+
+// resetWorktree updates the working tree to match the given index, which is
+// stored once done, reporting the files updated to the given progress and
+// callback.
+//
+// While the working tree is updated, the stored index follows it, so an
+// interrupted checkout can be resumed: the files still to be written are left
+// out of it, being seen as untracked, and the files still to be removed keep
+// their current entry. It is written before the first file is updated, after
+// the removals and every checkoutCheckpoint files.
+func (w *Worktree) resetWorktree(t *object.Tree, idx *index.Index, files []string, progress sideband.Progress, onFile func(FileUpdate) error) error {
+	changes, err := w.diffIndexWithWorktree(idx, true, false)
 	if err != nil {
 		return err
 	}
 
-	idx, err := w.r.Storer.Index()
+	current, err := w.r.Storer.Index()
 	if err != nil {
 		return err
 	}
-	b := newIndexBuilder(idx)
 
+	var selected []worktreeChange
 	for _, ch := range changes {
 		if err := w.validChange(ch); err != nil {
 			return err
@@ -509,15 +696,106 @@ func (w *Worktree) resetWorktree(t *object.Tree, files []string) error {
 			}
 		}
 
-		if err := w.checkoutChange(ch, t, b); err != nil {
+		a, err := ch.Action()
+		if err != nil {
+			return err
+		}
+
+		selected = append(selected, worktreeChange{Change: ch, action: a})
+	}
+
+	// Removals go first, making room for the files replacing directories.
+	sort.SliceStable(selected, func(i, j int) bool {
+		return selected[i].action == merkletrie.Delete && selected[j].action != merkletrie.Delete
+	})
+
+	filter, err := w.treeContentFilter(t)
+	if err != nil {
+		return err
+	}
+
+	symlinks, err := w.symlinksEnabled()
+	if err != nil {
+		return err
+	}
+
+	b := newIndexBuilder(idx)
+	cp := newIndexBuilder(idx)
+	entries := newIndexBuilder(current).entries
+	for _, ch := range selected {
+		name := nameFromAction(&ch.Change)
+		cp.Remove(name)
+		if e, ok := entries[name]; ok && ch.action == merkletrie.Delete {
+			cp.Add(e)
+		}
+	}
+
+	checkpoint := func() error {
+		partial := *idx
+		partial.Entries = nil
+		cp.Write(&partial)
+		return w.r.Storer.SetIndex(&partial)
+	}
+
+	if len(selected) > 0 {
+		if err := checkpoint(); err != nil {
 			return err
 		}
 	}
 
+	total := len(selected)
+	for i, ch := range selected {
+		name := nameFromAction(&ch.Change)
+		err := w.checkoutChange(ch.Change, t, filter, symlinks, b)
+		if err == nil {
+			cp.Remove(name)
+			if e, ok := b.entries[name]; ok {
+				cp.Add(e)
+			}
+
+			sideband.Report(progress, sideband.ProgressReport{
+				Stage:   sideband.StageCheckingOut,
+				Current: uint64(i + 1),
+				Total:   uint64(total),
+				Done:    i+1 == total,
+			})
+
+			if onFile != nil {
+				err = onFile(FileUpdate{
+					Path:    name,
+					Removed: ch.action == merkletrie.Delete,
+					Current: i + 1,
+					Total:   total,
+				})
+			}
+		}
+
+		if err != nil {
+			// The files updated so far are recorded, for the checkout to
+			// resume from here.
+			_ = checkpoint()
+			return err
+		}
+
+		removals := ch.action == merkletrie.Delete &&
+			(i+1 == total || selected[i+1].action != merkletrie.Delete)
+		if i+1 < total && (removals || (i+1)%checkoutCheckpoint == 0) {
+			if err := checkpoint(); err != nil {
+				return err
+			}
+		}
+	}
+
 	b.Write(idx)
 	return w.r.Storer.SetIndex(idx)
 }
 
+// worktreeChange is a change to apply to the working tree by resetWorktree.
+type worktreeChange struct {
+	merkletrie.Change
+	action merkletrie.Action
+}
+
 // worktreeDeny is a list of paths that are not allowed
 // to be used when resetting the worktree.
 var worktreeDeny = map[string]struct{}{
@@ -620,7 +898,7 @@ func (w *Worktree) validChange(ch merkletrie.Change) error {
 	return nil
 }
 
-func (w *Worktree) checkoutChange(ch merkletrie.Change, t *object.Tree, idx *indexBuilder) error {
+func (w *Worktree) checkoutChange(ch merkletrie.Change, t *object.Tree, filter *contentFilter, symlinks bool, idx *indexBuilder) error {
 	a, err := ch.Action()
 	if err != nil {
 		return err
@@ -647,7 +925,7 @@ func (w *Worktree) checkoutChange(ch merkletrie.Change, t *object.Tree, idx *ind
 		return w.checkoutChangeSubmodule(name, a, e, idx)
 	}
 
-	return w.checkoutChangeRegularFile(name, a, t, e, idx)
+	return w.checkoutChangeRegularFile(name, a, t, e, filter, symlinks, idx)
 }
 
 func (w *Worktree) containsUnstagedChanges() (bool, error) {
@@ -672,15 +950,17 @@ func (w *Worktree) containsUnstagedChanges() (bool, error) {
 	return false, nil
 }
 
-func (w *Worktree) setHEADCommit(commit plumbing.Hash) error {
+// setHEADCommit sets the branch checked out, or HEAD when detached, to the
+// given commit. The update is logged with the given message, unless the
+// commit is unchanged.
+func (w *Worktree) setHEADCommit(commit plumbing.Hash, reflogMsg string) error {
 	head, err := w.r.Reference(plumbing.HEAD, false)
 	if err != nil {
 		return err
 	}
 
 	if head.Type() == plumbing.HashReference {
-		head = plumbing.NewHashReference(plumbing.HEAD, commit)
-		return w.r.Storer.SetReference(head)
+		return w.setRefCommit(plumbing.NewHashReference(plumbing.HEAD, commit), head.Hash(), reflogMsg)
 	}
 
 	branch, err := w.r.Reference(head.Target(), false)
@@ -692,8 +972,19 @@ func (w *Worktree) setHEADCommit(commit plumbing.Hash) error {
 		return fmt.Errorf("invalid HEAD target should be a branch, found %s", branch.Type())
 	}
 
-	branch = plumbing.NewHashReference(branch.Name(), commit)
-	return w.r.Storer.SetReference(branch)
+	return w.setRefCommit(plumbing.NewHashReference(branch.Name(), commit), branch.Hash(), reflogMsg)
+}
+
+func (w *Worktree) setRefCommit(ref *plumbing.Reference, old plumbing.Hash, reflogMsg string) error {
+	if err := w.r.Storer.SetReference(ref); err != nil {
+		return err
+	}
+
+	if old == ref.Hash() {
+		return nil
+	}
+
+	return w.r.logRefUpdate(ref.Name(), old, ref.Hash(), nil, reflogMsg)
 }
 
 func (w *Worktree) checkoutChangeSubmodule(name string,
@@ -733,6 +1024,8 @@ func (w *Worktree) checkoutChangeRegularFile(name string,
 	a merkletrie.Action,
 	t *object.Tree,
 	e *object.TreeEntry,
+	filter *contentFilter,
+	symlinks bool,
 	idx *indexBuilder,
 ) error {
 	switch a {
@@ -752,24 +1045,31 @@ func (w *Worktree) checkoutChangeRegularFile(name string,
 			return err
 		}
 
-		if err := w.checkoutFile(f); err != nil {
+		if err := w.checkoutFile(f, filter, symlinks); err != nil {
 			return err
 		}
 
-		return w.addIndexFromFile(name, e.Hash, idx)
+		return w.addIndexFromFile(name, e.Hash, e.Mode, idx)
 	}
 
 	return nil
 }
 
-func (w *Worktree) checkoutFile(f *object.File) (err error) {
+// checkoutFile writes the given file to the worktree, its content converted
+// by the filter if any. Symbolic links are written as regular files
+// containing their target when symlinks is false, see symlinksEnabled.
+func (w *Worktree) checkoutFile(f *object.File, filter *contentFilter, symlinks bool) (err error) {
 	mode, err := f.Mode.ToOSFileMode()
 	if err != nil {
 		return
 	}
 
 	if mode&os.ModeSymlink != 0 {
-		return w.checkoutFileSymlink(f)
+		return w.checkoutFileSymlink(f, symlinks)
+	}
+
+	if filter != nil {
+		return w.checkoutFilteredFile(f, mode, filter)
 	}
 
 	from, err := f.Reader()
@@ -791,7 +1091,35 @@ func (w *Worktree) checkoutFile(f *object.File) (err error) {
 	return
 }
 
-func (w *Worktree) checkoutFileSymlink(f *object.File) (err error) {
+func (w *Worktree) checkoutFilteredFile(f *object.File, mode os.FileMode, filter *contentFilter) (err error) {
+	from, err := f.Reader()
+	if err != nil {
+		return
+	}
+
+	defer ioutil.CheckClose(from, &err)
+
+	content, err := io.ReadAll(from)
+	if err != nil {
+		return
+	}
+
+	content, err = filter.smudge(f.Name, content)
+	if err != nil {
+		return
+	}
+
+	to, err := w.Filesystem.OpenFile(f.Name, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode.Perm())
+	if err != nil {
+		return
+	}
+
+	defer ioutil.CheckClose(to, &err)
+	_, err = to.Write(content)
+	return
+}
+
+func (w *Worktree) checkoutFileSymlink(f *object.File, enabled bool) (err error) {
 	// https://github.com/git/git/commit/10ecfa76491e4923988337b2e2243b05376b40de
 	if strings.EqualFold(f.Name, gitmodulesFile) {
 		return ErrGitModulesSymlink
@@ -809,24 +1137,45 @@ func (w *Worktree) checkoutFileSymlink(f *object.File) (err error) {
 		return
 	}
 
-	err = w.Filesystem.Symlink(string(bytes), f.Name)
-
-	// On windows, this might fail.
-	// Follow Git on Windows behavior by writing the link as it is.
-	if err != nil && isSymlinkWindowsNonAdmin(err) {
-		mode, _ := f.Mode.ToOSFileMode()
+	if enabled {
+		err = w.Filesystem.Symlink(string(bytes), f.Name)
 
-		to, err := w.Filesystem.OpenFile(f.Name, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode.Perm())
-		if err != nil {
-			return err
+		// On windows, this might fail.
+		// Follow Git on Windows behavior by writing the link as it is.
+		if err == nil || !isSymlinkWindowsNonAdmin(err) {
+			return
 		}
+	}
 
-		defer ioutil.CheckClose(to, &err)
+	// As git does with core.symlinks set to false, the link is written as a
+	// regular file containing its target.
+	mode, _ := f.Mode.ToOSFileMode()
 
-		_, err = to.Write(bytes)
+	to, err := w.Filesystem.OpenFile(f.Name, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode.Perm())
+	if err != nil {
 		return err
 	}
-	return
+
+	defer ioutil.CheckClose(to, &err)
+
+	_, err = to.Write(bytes)
+	return err
+}
+
+// symlinksEnabled returns whether the symbolic links are checked out as such,
+// core.symlinks not being set to false.
+func (w *Worktree) symlinksEnabled() (bool, error) {
+	cfg, err := w.r.ConfigScoped(config.GlobalScope)
+	if err != nil {
+		return false, err
+	}
+
+	switch strings.ToLower(cfg.Core.Symlinks) {
+	case "false", "no", "off", "0":
+		return false, nil
+	}
+
+	return true, nil
 }
 
 func (w *Worktree) addIndexFromTreeEntry(name string, f *object.TreeEntry, idx *indexBuilder) error {
@@ -839,7 +1188,10 @@ func (w *Worktree) addIndexFromTreeEntry(name string, f *object.TreeEntry, idx *
 	return nil
 }
 
-func (w *Worktree) addIndexFromFile(name string, h plumbing.Hash, idx *indexBuilder) error {
+// addIndexFromFile adds to the index the file checked out for an entry with
+// the given hash and mode. The mode of a symbolic link checked out as a
+// regular file is kept.
+func (w *Worktree) addIndexFromFile(name string, h plumbing.Hash, entryMode filemode.FileMode, idx *indexBuilder) error {
 	idx.Remove(name)
 	fi, err := w.Filesystem.Lstat(name)
 	if err != nil {
@@ -851,6 +1203,10 @@ func (w *Worktree) addIndexFromFile(name string, h plumbing.Hash, idx *indexBuil
 		return err
 	}
 
+	if entryMode == filemode.Symlink && fi.Mode().IsRegular() {
+		mode = filemode.Symlink
+	}
+
 	e := &index.Entry{
 		Hash:       h,
 		Name:       name,
@@ -969,55 +1325,164 @@ func (w *Worktree) readGitmodulesFile() (*config.Modules, error) {
 // Clean the worktree by removing untracked files.
 // An empty dir could be removed - this is what  `git clean -f -d .` does.
 func (w *Worktree) Clean(opts *CleanOptions) error {
-	s, err := w.Status()
+	_, err := w.CleanPaths(opts)
+	return err
+}
+
+// CleanPaths cleans the worktree as Clean does, returning the paths removed,
+// or the ones that would be removed when DryRun is used. The directories
+// removed with their whole content are returned once, with a trailing slash.
+//
+// The tracked files, and the directories of the submodules and of any other
+// nested repository, are never removed.
+func (w *Worktree) CleanPaths(opts *CleanOptions) ([]string, error) {
+	if opts == nil {
+		opts = &CleanOptions{}
+	}
+
+	idx, err := w.r.Storer.Index()
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	root := ""
-	files, err := w.Filesystem.ReadDir(root)
+	patterns, err := gitignore.ReadPatterns(w.Filesystem, nil)
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	c := &cleaner{
+		w:       w,
+		opts:    opts,
+		ignored: gitignore.NewMatcher(append(patterns, w.Excludes...)),
+		tracked: make(map[string]bool, len(idx.Entries)),
+	}
+
+	for _, e := range idx.Entries {
+		c.tracked[e.Name] = true
+		for dir := path.Dir(e.Name); dir != "."; dir = path.Dir(dir) {
+			if c.tracked[dir+"/"] {
+				break
+			}
+
+			c.tracked[dir+"/"] = true
+		}
 	}
-	return w.doClean(s, opts, root, files)
+
+	if _, err := c.clean("", false); err != nil {
+		return nil, err
+	}
+
+	return c.paths, nil
+}
+
+// cleaner removes the untracked files of a worktree.
+type cleaner struct {
+	w       *Worktree
+	opts    *CleanOptions
+	ignored gitignore.Matcher
+	// tracked holds the paths of the index entries, and of their parent
+	// directories with a trailing slash.
+	tracked map[string]bool
+	paths   []string
 }
 
-func (w *Worktree) doClean(status Status, opts *CleanOptions, dir string, files []os.FileInfo) error {
+// clean removes the untracked files of dir, returning whether all of its
+// content can be removed. A directory is removed as a whole when it is
+// untracked and all of its content is removed.
+func (c *cleaner) clean(dir string, ignored bool) (bool, error) {
+	files, err := c.w.Filesystem.ReadDir(dir)
+	if err != nil {
+		return false, err
+	}
+
+	// An empty directory is removed as an untracked file would be.
+	if len(files) == 0 {
+		return c.removable(ignored), nil
+	}
+
+	var removed []string
+	empty := true
 	for _, fi := range files {
-		if fi.Name() == GitDirName {
+		name := path.Join(dir, fi.Name())
+		if fi.Name() == GitDirName || c.tracked[name] {
+			empty = false
 			continue
 		}
 
-		// relative path under the root
-		path := filepath.Join(dir, fi.Name())
-		if fi.IsDir() {
-			if !opts.Dir {
+		isIgnored := ignored || c.ignored.Match(strings.Split(name, "/"), fi.IsDir())
+		if !fi.IsDir() {
+			if !c.removable(isIgnored) {
+				empty = false
 				continue
 			}
 
-			subfiles, err := w.Filesystem.ReadDir(path)
-			if err != nil {
-				return err
-			}
-			err = w.doClean(status, opts, path, subfiles)
-			if err != nil {
-				return err
-			}
-		} else {
-			if status.IsUntracked(path) {
-				if err := w.Filesystem.Remove(path); err != nil {
-					return err
-				}
+			removed = append(removed, name)
+			continue
+		}
+
+		if c.tracked[name+"/"] {
+			// Only the untracked files of the directory are removed.
+			if _, err := c.clean(name, isIgnored); err != nil {
+				return false, err
 			}
+
+			empty = false
+			continue
+		}
+
+		if !c.opts.Dir || c.isRepository(name) {
+			empty = false
+			continue
 		}
+
+		pending := len(c.paths)
+		all, err := c.clean(name, isIgnored)
+		if err != nil {
+			return false, err
+		}
+
+		if !all {
+			empty = false
+			continue
+		}
+
+		// The paths of its content are replaced by the directory itself.
+		c.paths = c.paths[:pending]
+		removed = append(removed, name+"/")
 	}
 
-	if opts.Dir && dir != "" {
-		_, err := removeDirIfEmpty(w.Filesystem, dir)
-		return err
+	for _, name := range removed {
+		c.paths = append(c.paths, name)
+		if c.opts.DryRun {
+			continue
+		}
+
+		if err := util.RemoveAll(c.w.Filesystem, strings.TrimSuffix(name, "/")); err != nil {
+			return false, err
+		}
 	}
 
-	return nil
+	return empty, nil
+}
+
+// removable returns whether an untracked file is removed, depending on
+// whether it is ignored.
+func (c *cleaner) removable(ignored bool) bool {
+	switch {
+	case c.opts.IgnoredOnly:
+		return ignored
+	case c.opts.IncludeIgnored:
+		return true
+	default:
+		return !ignored
+	}
+}
+
+// isRepository returns whether the directory is the worktree of a nested
+// repository, which is never removed.
+func (c *cleaner) isRepository(dir string) bool {
+	_, err := c.w.Filesystem.Lstat(path.Join(dir, GitDirName))
+	return err == nil
 }
 
 // GrepResult is structure of a grep result.
@@ -1037,38 +1502,72 @@ func (gr GrepResult) String() string {
 	return fmt.Sprintf("%s:%s:%d:%s", gr.TreeName, gr.FileName, gr.LineNumber, gr.Content)
 }
 
-// Grep performs grep on a repository.
+// Grep performs grep on a repository, returning all the matches. See GrepFunc
+// to handle them as they are found instead.
 func (r *Repository) Grep(opts *GrepOptions) ([]GrepResult, error) {
+	var results []GrepResult
+	err := r.GrepFunc(opts, func(res GrepResult) error {
+		results = append(results, res)
+		return nil
+	})
+
+	return results, err
+}
+
+// GrepFunc performs grep on a repository, calling fn with every match as soon
+// as it's found, so the matches of large trees are never held in memory all
+// at once. The matches are given in order of path and line number, even when
+// several files are searched concurrently. The search stops at the first
+// error, the one returned by fn included.
+func (r *Repository) GrepFunc(opts *GrepOptions, fn func(GrepResult) error) error {
 	if err := opts.validate(r); err != nil {
-		return nil, err
+		return err
+	}
+
+	patterns, err := opts.patterns()
+	if err != nil {
+		return err
+	}
+
+	g := &grep{opts: opts, patterns: patterns, fn: fn}
+	if opts.Worktree {
+		w, err := r.Worktree()
+		if err != nil {
+			return err
+		}
+
+		return g.run(w.grepFiles)
 	}
 
 	// Obtain commit hash from options (CommitHash or ReferenceName).
 	var commitHash plumbing.Hash
-	// treeName contains the value of TreeName in GrepResult.
-	var treeName string
 
 	if opts.ReferenceName != "" {
 		ref, err := r.Reference(opts.ReferenceName, true)
 		if err != nil {
-			return nil, err
+			return err
 		}
 		commitHash = ref.Hash()
-		treeName = opts.ReferenceName.String()
+		g.treeName = opts.ReferenceName.String()
 	} else if !opts.CommitHash.IsZero() {
 		commitHash = opts.CommitHash
-		treeName = opts.CommitHash.String()
+		g.treeName = opts.CommitHash.String()
 	}
 
 	// Obtain a tree from the commit hash and get a tracked files iterator from
 	// the tree.
 	tree, err := r.getTreeFromCommitHash(commitHash)
 	if err != nil {
-		return nil, err
+		return err
 	}
-	fileiter := tree.Files()
 
-	return findMatchInFiles(fileiter, treeName, opts)
+	return g.run(func(fn func(grepFile) error) error {
+		return tree.Files().ForEach(func(f *object.File) error {
+			return fn(grepFile{name: f.Name, read: func() ([]byte, error) {
+				return blobContent(f.Reader)
+			}})
+		})
+	})
 }
 
 // Grep performs grep on a worktree.
@@ -1076,85 +1575,176 @@ func (w *Worktree) Grep(opts *GrepOptions) ([]GrepResult, error) {
 	return w.r.Grep(opts)
 }
 
-// findMatchInFiles takes a FileIter, worktree name and GrepOptions, and
-// returns a slice of GrepResult containing the result of regex pattern matching
-// in content of all the files.
-func findMatchInFiles(fileiter *object.FileIter, treeName string, opts *GrepOptions) ([]GrepResult, error) {
-	var results []GrepResult
+// grepFiles calls fn with the files of the worktree tracked in the index.
+func (w *Worktree) grepFiles(fn func(grepFile) error) error {
+	idx, err := w.r.Storer.Index()
+	if err != nil {
+		return err
+	}
 
-	err := fileiter.ForEach(func(file *object.File) error {
-		var fileInPathSpec bool
+	seen := make(map[string]bool)
+	for _, e := range idx.Entries {
+		if seen[e.Name] || !e.Mode.IsFile() || e.Mode == filemode.Symlink {
+			continue
+		}
 
-		// When no pathspecs are provided, search all the files.
-		if len(opts.PathSpecs) == 0 {
-			fileInPathSpec = true
+		seen[e.Name] = true
+		name := e.Name
+		err := fn(grepFile{name: name, read: func() ([]byte, error) {
+			content, err := util.ReadFile(w.Filesystem, name)
+			if os.IsNotExist(err) {
+				return nil, nil
+			}
+
+			return content, err
+		}})
+		if err != nil {
+			return err
 		}
+	}
 
-		// Check if the file name matches with the pathspec. Break out of the
-		// loop once a match is found.
-		for _, pathSpec := range opts.PathSpecs {
-			if pathSpec != nil && pathSpec.MatchString(file.Name) {
-				fileInPathSpec = true
-				break
+	return nil
+}
+
+// grepFile is a file searched by grep, with the function reading its
+// content.
+type grepFile struct {
+	name string
+	read func() ([]byte, error)
+}
+
+// grep searches the files, up to opts.Concurrency at once, giving the
+// matches to fn in the order of the files.
+type grep struct {
+	opts     *GrepOptions
+	patterns []*regexp.Regexp
+	treeName string
+	fn       func(GrepResult) error
+}
+
+// grepJob is the search of a file, done once results or err are set.
+type grepJob struct {
+	results []GrepResult
+	err     error
+	done    chan struct{}
+}
+
+var errGrepStopped = errors.New("grep stopped")
+
+// run searches the files given by each.
+func (g *grep) run(each func(func(grepFile) error) error) error {
+	n := g.opts.Concurrency
+	if n < 1 {
+		n = 1
+	}
+
+	// The slots bound the files being searched or whose results are not
+	// consumed yet.
+	slots := make(chan struct{}, n)
+	jobs := make(chan *grepJob, n)
+	stop := make(chan struct{})
+	consumed := make(chan error, 1)
+
+	go func() {
+		var err error
+		for j := range jobs {
+			<-j.done
+			if err == nil {
+				err = g.consume(j)
+				if err != nil {
+					close(stop)
+				}
 			}
+
+			<-slots
 		}
 
-		// If the file does not match with any of the pathspec, skip it.
-		if !fileInPathSpec {
+		consumed <- err
+	}()
+
+	err := each(func(f grepFile) error {
+		if !g.inPathSpecs(f.name) {
 			return nil
 		}
 
-		grepResults, err := findMatchInFile(file, treeName, opts)
-		if err != nil {
-			return err
+		select {
+		case slots <- struct{}{}:
+		case <-stop:
+			return errGrepStopped
 		}
-		results = append(results, grepResults...)
+
+		j := &grepJob{done: make(chan struct{})}
+		jobs <- j
+		go func() {
+			defer close(j.done)
+			j.results, j.err = g.grepFile(f)
+		}()
 
 		return nil
 	})
 
-	return results, err
+	close(jobs)
+	if cerr := <-consumed; cerr != nil {
+		return cerr
+	}
+
+	return err
 }
 
-// findMatchInFile takes a single File, worktree name and GrepOptions,
-// and returns a slice of GrepResult containing the result of regex pattern
-// matching in the given file.
-func findMatchInFile(file *object.File, treeName string, opts *GrepOptions) ([]GrepResult, error) {
-	var grepResults []GrepResult
+func (g *grep) consume(j *grepJob) error {
+	if j.err != nil {
+		return j.err
+	}
 
-	content, err := file.Contents()
-	if err != nil {
-		return grepResults, err
+	for _, res := range j.results {
+		if err := g.fn(res); err != nil {
+			return err
+		}
 	}
 
-	// Split the file content and parse line-by-line.
-	contentByLine := strings.Split(content, "\n")
-	for lineNum, cnt := range contentByLine {
-		addToResult := false
-
-		// Match the patterns and content. Break out of the loop once a
-		// match is found.
-		for _, pattern := range opts.Patterns {
-			if pattern != nil && pattern.MatchString(cnt) {
-				// Add to result only if invert match is not enabled.
-				if !opts.InvertMatch {
-					addToResult = true
-					break
-				}
-			} else if opts.InvertMatch {
-				// If matching fails, and invert match is enabled, add to
-				// results.
-				addToResult = true
-				break
-			}
+	return nil
+}
+
+// inPathSpecs returns true if the file name matches with any of the
+// pathspecs, or if there are none.
+func (g *grep) inPathSpecs(name string) bool {
+	if len(g.opts.PathSpecs) == 0 {
+		return true
+	}
+
+	for _, pathSpec := range g.opts.PathSpecs {
+		if pathSpec != nil && pathSpec.MatchString(name) {
+			return true
 		}
+	}
+
+	return false
+}
 
-		if addToResult {
+// grepFile returns the results of the pattern matching in the content of
+// the given file. Binary files are skipped.
+func (g *grep) grepFile(f grepFile) ([]GrepResult, error) {
+	content, err := f.read()
+	if err != nil || content == nil {
+		return nil, err
+	}
+
+	isBinary, err := binary.IsBinary(bytes.NewReader(content))
+	if err != nil || isBinary {
+		return nil, err
+	}
+
+	var grepResults []GrepResult
+
+	// Split the file content and parse line-by-line.
+	contentByLine := strings.Split(string(content), "\n")
+	for lineNum, cnt := range contentByLine {
+		if g.matchLine(cnt) {
 			grepResults = append(grepResults, GrepResult{
-				FileName:   file.Name,
+				FileName:   f.name,
 				LineNumber: lineNum + 1,
 				Content:    cnt,
-				TreeName:   treeName,
+				TreeName:   g.treeName,
 			})
 		}
 	}
@@ -1162,6 +1752,34 @@ func findMatchInFile(file *object.File, treeName string, opts *GrepOptions) ([]G
 	return grepResults, nil
 }
 
+// matchLine returns true if the line is selected: if it matches any of the
+// patterns, or if it doesn't when InvertMatch is set.
+func (g *grep) matchLine(line string) bool {
+	for _, pattern := range g.patterns {
+		if pattern.MatchString(line) {
+			// Add to result only if invert match is not enabled.
+			return !g.opts.InvertMatch
+		} else if g.opts.InvertMatch {
+			// If matching fails, and invert match is enabled, add to
+			// results.
+			return true
+		}
+	}
+
+	return false
+}
+
+// blobContent reads the content returned by open to the end.
+func blobContent(open func() (io.ReadCloser, error)) (content []byte, err error) {
+	r, err := open()
+	if err != nil {
+		return nil, err
+	}
+
+	defer ioutil.CheckClose(r, &err)
+	return io.ReadAll(r)
+}
+
 // will walk up the directory tree removing all encountered empty
 // directories, not just the one containing this file
 func rmFileAndDirsIfEmpty(fs billy.Filesystem, name string) error {
@@ -56,11 +56,24 @@ func (w *Worktree) Commit(msg string, opts *CommitOptions) (plumbing.Hash, error
 		opts.Parents = headCommit.ParentHashes
 	}
 
+	if opts.RunHooks {
+		var err error
+		if msg, err = w.commitHooks(msg, opts); err != nil {
+			return plumbing.ZeroHash, err
+		}
+	}
+
 	idx, err := w.r.Storer.Index()
 	if err != nil {
 		return plumbing.ZeroHash, err
 	}
 
+	for _, e := range idx.Entries {
+		if e.Stage != index.Merged {
+			return plumbing.ZeroHash, ErrUnmergedPaths
+		}
+	}
+
 	// First handle the case of the first commit in the repository being empty.
 	if len(opts.Parents) == 0 && len(idx.Entries) == 0 && !opts.AllowEmptyCommits {
 		return plumbing.ZeroHash, ErrEmptyCommit
@@ -94,7 +107,11 @@ func (w *Worktree) Commit(msg string, opts *CommitOptions) (plumbing.Hash, error
 		return plumbing.ZeroHash, err
 	}
 
-	return commit, w.updateHEAD(commit)
+	if err := w.updateHEAD(commit, opts.Committer, commitReflogMessage(msg, opts)); err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	return commit, w.r.removeMergeState()
 }
 
 func (w *Worktree) autoAddModifiedAndDeleted() error {
@@ -108,12 +125,17 @@ func (w *Worktree) autoAddModifiedAndDeleted() error {
 		return err
 	}
 
+	filter, err := w.worktreeContentFilter(idx)
+	if err != nil {
+		return err
+	}
+
 	for path, fs := range s {
 		if fs.Worktree != Modified && fs.Worktree != Deleted {
 			continue
 		}
 
-		if _, _, err := w.doAddFile(idx, s, path, nil); err != nil {
+		if _, _, err := w.doAddFile(idx, filter, s, path, nil); err != nil {
 			return err
 		}
 
@@ -122,7 +144,9 @@ func (w *Worktree) autoAddModifiedAndDeleted() error {
 	return w.r.Storer.SetIndex(idx)
 }
 
-func (w *Worktree) updateHEAD(commit plumbing.Hash) error {
+// updateHEAD sets the branch checked out, or HEAD when detached, to the given
+// commit, logging the update with the given message.
+func (w *Worktree) updateHEAD(commit plumbing.Hash, committer *object.Signature, msg string) error {
 	head, err := w.r.Storer.Reference(plumbing.HEAD)
 	if err != nil {
 		return err
@@ -133,8 +157,39 @@ func (w *Worktree) updateHEAD(commit plumbing.Hash) error {
 		name = head.Target()
 	}
 
+	old, err := w.r.referenceHash(name)
+	if err != nil {
+		return err
+	}
+
 	ref := plumbing.NewHashReference(name, commit)
-	return w.r.Storer.SetReference(ref)
+	if err := w.r.Storer.SetReference(ref); err != nil {
+		return err
+	}
+
+	return w.r.logRefUpdate(name, old, commit, committer, msg)
+}
+
+// commitReflogMessage returns the reflog message of a commit, its subject
+// prefixed by the kind of commit, as git does.
+func commitReflogMessage(msg string, opts *CommitOptions) string {
+	kind := "commit"
+	switch {
+	case opts.Amend:
+		kind = "commit (amend)"
+	case len(opts.Parents) == 0:
+		kind = "commit (initial)"
+	case len(opts.Parents) > 1:
+		kind = "commit (merge)"
+	}
+
+	return kind + ": " + commitSubject(msg)
+}
+
+// commitSubject returns the first line of a commit message.
+func commitSubject(msg string) string {
+	subject, _, _ := strings.Cut(strings.TrimSpace(msg), "\n")
+	return subject
 }
 
 func (w *Worktree) buildCommitObject(msg string, opts *CommitOptions, tree plumbing.Hash) (plumbing.Hash, error) {
@@ -265,7 +320,9 @@ func (se sortableEntries) Swap(i int, j int)      { se[i], se[j] = se[j], se[i]
 func (h *buildTreeHelper) copyTreeToStorageRecursive(parent string, t *object.Tree) (plumbing.Hash, error) {
 	sort.Sort(sortableEntries(t.Entries))
 	for i, e := range t.Entries {
-		if e.Mode != filemode.Dir && !e.Hash.IsZero() {
+		// Files, and directories whose tree is already stored, are written
+		// as they are.
+		if !e.Hash.IsZero() {
 			continue
 		}
 
@@ -3,6 +3,7 @@ package git
 import (
 	"bytes"
 	"fmt"
+	"io"
 	"log"
 	"os"
 	"os/exec"
@@ -168,6 +169,67 @@ func (s *WorktreeSuite) TestCommitParent() {
 	assertStorageStatus(s, s.Repository, 13, 11, 10, expected)
 }
 
+func (s *WorktreeSuite) TestCommitOctopus() {
+	r, err := Init(memory.NewStorage(), WithWorkTree(memfs.New()))
+	s.Require().NoError(err)
+
+	w, err := r.Worktree()
+	s.Require().NoError(err)
+
+	var parents []plumbing.Hash
+	for _, name := range []string{"a", "b", "c", "d"} {
+		s.Require().NoError(util.WriteFile(w.Filesystem, name, []byte(name), 0644))
+		_, err = w.Add(name)
+		s.Require().NoError(err)
+
+		h, err := w.Commit(name, &CommitOptions{Author: defaultSignature()})
+		s.Require().NoError(err)
+		parents = append(parents, h)
+	}
+
+	order := []plumbing.Hash{parents[2], parents[0], parents[1]}
+	hash, err := w.Commit("octopus\n", &CommitOptions{
+		Author:            defaultSignature(),
+		Parents:           order,
+		AllowEmptyCommits: true,
+	})
+	s.Require().NoError(err)
+
+	commit, err := r.CommitObject(hash)
+	s.Require().NoError(err)
+	s.Equal(order, commit.ParentHashes)
+
+	head, err := r.CommitObject(parents[3])
+	s.Require().NoError(err)
+	s.Equal(head.TreeHash, commit.TreeHash)
+
+	obj, err := r.Storer.EncodedObject(plumbing.CommitObject, hash)
+	s.Require().NoError(err)
+	reader, err := obj.Reader()
+	s.Require().NoError(err)
+	content, err := io.ReadAll(reader)
+	s.Require().NoError(err)
+	s.Contains(string(content), fmt.Sprintf("parent %s\nparent %s\nparent %s\n", order[0], order[1], order[2]))
+
+	_, err = w.Commit("duplicate\n", &CommitOptions{
+		Author:  defaultSignature(),
+		Parents: []plumbing.Hash{parents[0], parents[1], parents[0]},
+	})
+	s.ErrorIs(err, ErrDuplicateParent)
+
+	_, err = w.Commit("missing\n", &CommitOptions{
+		Author:  defaultSignature(),
+		Parents: []plumbing.Hash{parents[0], plumbing.NewHash("0000000000000000000000000000000000000001")},
+	})
+	s.ErrorIs(err, ErrInvalidParent)
+
+	_, err = w.Commit("not a commit\n", &CommitOptions{
+		Author:  defaultSignature(),
+		Parents: []plumbing.Hash{parents[0], head.TreeHash},
+	})
+	s.ErrorIs(err, ErrInvalidParent)
+}
+
 func (s *WorktreeSuite) TestCommitAmendWithoutChanges() {
 	fs := memfs.New()
 	w := &Worktree{
@@ -0,0 +1,458 @@
+package git
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"runtime"
+	"sort"
+	"strings"
+
+	"github.com/go-git/go-git/v6/config"
+	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/go-git/go-git/v6/plumbing/filter"
+	formatcfg "github.com/go-git/go-git/v6/plumbing/format/config"
+	"github.com/go-git/go-git/v6/plumbing/format/gitattributes"
+	"github.com/go-git/go-git/v6/plumbing/format/index"
+	"github.com/go-git/go-git/v6/plumbing/object"
+	"github.com/go-git/go-git/v6/utils/binary"
+	"github.com/go-git/go-git/v6/utils/ioutil"
+)
+
+const (
+	gitattributesFile = ".gitattributes"
+
+	textAttr   = "text"
+	eolAttr    = "eol"
+	crlfAttr   = "crlf"
+	binaryAttr = "binary"
+	filterAttr = "filter"
+
+	filterSection     = "filter"
+	filterRequiredKey = "required"
+)
+
+// eolConversion tells whether the line endings of a file are converted.
+type eolConversion int8
+
+const (
+	// eolNone leaves the file untouched.
+	eolNone eolConversion = iota
+	// eolText always converts the file.
+	eolText
+	// eolAuto converts the file only if it isn't detected as binary.
+	eolAuto
+)
+
+// contentFilter converts the content of the files between the repository and
+// the worktree, as git does with core.autocrlf, core.eol and the text, eol
+// and crlf attributes: the line endings are normalized to LF when a file is
+// added, and converted to CRLF on checkout when configured so.
+//
+// The files with the filter attribute are also given to the driver of the
+// filter registered in the filter package. When filter.<name>.required is
+// set in the config, a missing or failing driver is an error, otherwise the
+// content is used as it is.
+//
+// A nil contentFilter leaves the content untouched.
+type contentFilter struct {
+	autoCRLF   string
+	eol        string
+	attributes gitattributes.Matcher
+	required   map[string]bool
+
+	// The index and the storer of the worktree are used to look up the
+	// blobs already staged: as git does, files detected as text with CRLF
+	// line endings in the repository are never converted.
+	w   *Worktree
+	idx *index.Index
+}
+
+// contentFilter returns the filter for the files of the worktree, with the
+// given attributes, or nil if no conversion is configured. idx may be nil
+// when the filter is only used to check out files.
+func (w *Worktree) contentFilter(attributes gitattributes.Matcher, idx *index.Index) (*contentFilter, error) {
+	cfg, err := w.r.ConfigScoped(config.GlobalScope)
+	if err != nil {
+		return nil, err
+	}
+
+	if attributes == nil && !isAutoCRLFSet(cfg.Core.AutoCRLF) {
+		return nil, nil
+	}
+
+	required, err := w.requiredFilters()
+	if err != nil {
+		return nil, err
+	}
+
+	return &contentFilter{
+		autoCRLF:   strings.ToLower(cfg.Core.AutoCRLF),
+		eol:        strings.ToLower(cfg.Core.EOL),
+		attributes: attributes,
+		required:   required,
+		w:          w,
+		idx:        idx,
+	}, nil
+}
+
+// requiredFilters returns the filters with filter.<name>.required set in the
+// system, global or local config, the latter taking precedence.
+func (w *Worktree) requiredFilters() (map[string]bool, error) {
+	local, err := w.r.Config()
+	if err != nil {
+		return nil, err
+	}
+
+	var raws []*formatcfg.Config
+	for _, scope := range []config.Scope{config.SystemScope, config.GlobalScope} {
+		cfg, err := config.LoadConfig(scope)
+		if err != nil {
+			return nil, err
+		}
+
+		raws = append(raws, cfg.Raw)
+	}
+
+	required := make(map[string]bool)
+	for _, raw := range append(raws, local.Raw) {
+		if raw == nil || !raw.HasSection(filterSection) {
+			continue
+		}
+
+		for _, ss := range raw.Section(filterSection).Subsections {
+			if ss.HasOption(filterRequiredKey) {
+				required[ss.Name] = ss.Option(filterRequiredKey) == "true"
+			}
+		}
+	}
+
+	return required, nil
+}
+
+// worktreeContentFilter returns the filter for the files of the worktree, as
+// configured by the .gitattributes files found in it. A worktree not created
+// yet has none.
+func (w *Worktree) worktreeContentFilter(idx *index.Index) (*contentFilter, error) {
+	attributes, err := w.attributesMatcher()
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	return w.contentFilter(attributes, idx)
+}
+
+// treeContentFilter returns the filter for checking out the files of the
+// given tree, as configured by the .gitattributes files found in it.
+func (w *Worktree) treeContentFilter(t *object.Tree) (*contentFilter, error) {
+	attributes, err := treeAttributesMatcher(t)
+	if err != nil {
+		return nil, err
+	}
+
+	return w.contentFilter(attributes, nil)
+}
+
+// treeAttributesMatcher returns a matcher for the .gitattributes files of the
+// given tree, or nil if there are none.
+func treeAttributesMatcher(t *object.Tree) (gitattributes.Matcher, error) {
+	if t == nil {
+		return nil, nil
+	}
+
+	var names []string
+	walker := object.NewTreeWalker(t, true, nil)
+	defer walker.Close()
+
+	for {
+		name, e, err := walker.Next()
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		if path.Base(name) == gitattributesFile && e.Mode.IsFile() {
+			names = append(names, name)
+		}
+	}
+
+	// The files closer to the root have a lower priority, so they go first.
+	sort.SliceStable(names, func(i, j int) bool {
+		return strings.Count(names[i], "/") < strings.Count(names[j], "/")
+	})
+
+	var patterns []gitattributes.MatchAttribute
+	for _, name := range names {
+		attrs, err := readTreeAttributes(t, name)
+		if err != nil {
+			return nil, err
+		}
+
+		patterns = append(patterns, attrs...)
+	}
+
+	if len(patterns) == 0 {
+		return nil, nil
+	}
+
+	return gitattributes.NewMatcher(patterns), nil
+}
+
+// readTreeAttributes reads the .gitattributes file at the given path of the
+// tree, only the one at the root being allowed to define macros.
+func readTreeAttributes(t *object.Tree, name string) (attrs []gitattributes.MatchAttribute, err error) {
+	f, err := t.File(name)
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := f.Reader()
+	if err != nil {
+		return nil, err
+	}
+
+	defer ioutil.CheckClose(r, &err)
+
+	var domain []string
+	if dir := path.Dir(name); dir != "." {
+		domain = strings.Split(dir, "/")
+	}
+
+	return gitattributes.ReadAttributes(r, domain, len(domain) == 0)
+}
+
+// match returns the attributes of the file at the given path relevant to the
+// filter.
+func (f *contentFilter) match(path string) map[string]gitattributes.Attribute {
+	if f.attributes == nil {
+		return nil
+	}
+
+	attrs, _ := f.attributes.Match(strings.Split(path, "/"),
+		[]string{textAttr, eolAttr, crlfAttr, binaryAttr, filterAttr})
+	return attrs
+}
+
+// conversion returns how the line endings of a file with the given attributes
+// are converted, and whether CRLF is used on checkout.
+func (f *contentFilter) conversion(attrs map[string]gitattributes.Attribute) (c eolConversion, crlf bool) {
+	eol, hasEOL := attrs[eolAttr]
+	hasEOL = hasEOL && eol.IsValueSet()
+
+	text, hasText := attrs[textAttr]
+	if !hasText {
+		// crlf is the deprecated name of text.
+		text, hasText = attrs[crlfAttr]
+	}
+
+	autoCRLF := isAutoCRLFSet(f.autoCRLF)
+	switch {
+	case attrs[binaryAttr] != nil && attrs[binaryAttr].IsSet():
+		return eolNone, false
+	case hasText && text.IsUnset():
+		return eolNone, false
+	case hasText && text.IsValueSet() && text.Value() == "auto":
+		c = eolAuto
+	case hasText && text.IsValueSet() && text.Value() == "input":
+		// crlf=input, converted to LF only when added.
+		return eolText, false
+	case hasText && text.IsSet(), hasEOL:
+		c = eolText
+	case autoCRLF:
+		c = eolAuto
+	default:
+		return eolNone, false
+	}
+
+	switch {
+	case hasEOL:
+		crlf = eol.Value() == "crlf"
+	case autoCRLF:
+		crlf = f.autoCRLF != "input"
+	case f.eol == "crlf":
+		crlf = true
+	case f.eol == "lf":
+		crlf = false
+	default:
+		crlf = runtime.GOOS == "windows"
+	}
+
+	return c, crlf
+}
+
+// smudge returns the content of the file at the given path as written to the
+// worktree: its line endings are converted first, then it's given to the
+// driver of its filter.
+func (f *contentFilter) smudge(path string, content []byte) ([]byte, error) {
+	if f == nil {
+		return content, nil
+	}
+
+	attrs := f.match(path)
+	content = f.toWorktreeEOL(attrs, content)
+	return f.applyDriver(attrs, path, content, filter.Driver.Smudge)
+}
+
+func (f *contentFilter) toWorktreeEOL(attrs map[string]gitattributes.Attribute, content []byte) []byte {
+	c, crlf := f.conversion(attrs)
+	if c == eolNone || !crlf || !bytes.ContainsRune(content, '\n') {
+		return content
+	}
+
+	if c == eolAuto && (bytes.ContainsRune(content, '\r') || isBinaryContent(content)) {
+		return content
+	}
+
+	var buf bytes.Buffer
+	buf.Grow(len(content) + bytes.Count(content, []byte{'\n'}))
+	for i, b := range content {
+		if b == '\n' && (i == 0 || content[i-1] != '\r') {
+			buf.WriteByte('\r')
+		}
+
+		buf.WriteByte(b)
+	}
+
+	return buf.Bytes()
+}
+
+// clean returns the content of the file at the given path as stored in the
+// repository: it's given to the driver of its filter first, then its line
+// endings are normalized.
+func (f *contentFilter) clean(path string, content []byte) ([]byte, error) {
+	if f == nil {
+		return content, nil
+	}
+
+	attrs := f.match(path)
+	content, err := f.applyDriver(attrs, path, content, filter.Driver.Clean)
+	if err != nil {
+		return nil, err
+	}
+
+	return f.toRepositoryEOL(attrs, path, content)
+}
+
+func (f *contentFilter) toRepositoryEOL(attrs map[string]gitattributes.Attribute, path string, content []byte) ([]byte, error) {
+	if !bytes.Contains(content, []byte("\r\n")) {
+		return content, nil
+	}
+
+	c, _ := f.conversion(attrs)
+	switch c {
+	case eolNone:
+		return content, nil
+	case eolAuto:
+		if isBinaryContent(content) {
+			return content, nil
+		}
+
+		hasCR, err := f.stagedHasCR(path)
+		if err != nil || hasCR {
+			return content, err
+		}
+	}
+
+	return bytes.ReplaceAll(content, []byte("\r\n"), []byte("\n")), nil
+}
+
+// applyDriver gives the content of the file to the driver of its filter, if
+// any, calling the given method of the driver. Unless the filter is required,
+// the content is returned as it is when the driver is missing or fails.
+func (f *contentFilter) applyDriver(
+	attrs map[string]gitattributes.Attribute, path string, content []byte,
+	method func(filter.Driver, string, io.Writer, io.Reader) error,
+) ([]byte, error) {
+	attr, ok := attrs[filterAttr]
+	if !ok || !attr.IsValueSet() {
+		return content, nil
+	}
+
+	name := attr.Value()
+	required := f.required[name]
+	d, err := f.driver(name)
+	if err != nil {
+		if required {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+
+		return content, nil
+	}
+
+	var buf bytes.Buffer
+	if err := method(d, path, &buf, bytes.NewReader(content)); err != nil {
+		if required {
+			return nil, fmt.Errorf("filter %q failed on %s: %w", name, path, err)
+		}
+
+		return content, nil
+	}
+
+	return buf.Bytes(), nil
+}
+
+// driver returns the driver of the named filter, the one set in the Filters
+// of the worktree or else the one registered with filter.Register.
+func (f *contentFilter) driver(name string) (filter.Driver, error) {
+	if d := f.w.Filters[name]; d != nil {
+		return d, nil
+	}
+
+	return filter.Get(name)
+}
+
+// stagedHasCR returns true if the blob staged for the given path contains a
+// carriage return.
+func (f *contentFilter) stagedHasCR(path string) (hasCR bool, err error) {
+	if f.idx == nil {
+		return false, nil
+	}
+
+	e, err := f.idx.Entry(path)
+	if err == index.ErrEntryNotFound {
+		return false, nil
+	}
+
+	if err != nil {
+		return false, err
+	}
+
+	blob, err := object.GetBlob(f.w.r.Storer, e.Hash)
+	if err == plumbing.ErrObjectNotFound {
+		return false, nil
+	}
+
+	if err != nil {
+		return false, err
+	}
+
+	r, err := blob.Reader()
+	if err != nil {
+		return false, err
+	}
+
+	defer ioutil.CheckClose(r, &err)
+	content, err := io.ReadAll(r)
+	return bytes.ContainsRune(content, '\r'), err
+}
+
+// isAutoCRLFSet returns true if the value of core.autocrlf enables the
+// conversion, either "true" or "input".
+func isAutoCRLFSet(v string) bool {
+	switch strings.ToLower(v) {
+	case "true", "yes", "on", "1", "input":
+		return true
+	default:
+		return false
+	}
+}
+
+func isBinaryContent(content []byte) bool {
+	isBinary, _ := binary.IsBinary(bytes.NewReader(content))
+	return isBinary
+}
@@ -0,0 +1,351 @@
+package git
+
+import (
+	"bytes"
+	"errors"
+	"io"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/go-git/go-billy/v5/util"
+	"github.com/go-git/go-git/v6/plumbing/filter"
+	"github.com/go-git/go-git/v6/plumbing/object"
+	"github.com/go-git/go-git/v6/storage/memory"
+)
+
+// setupLineEndings creates a repository committing the given files, then
+// sets core.autocrlf and core.eol to the given values.
+func (s *WorktreeSuite) setupLineEndings(files map[string]string, autoCRLF, eol string) (*Repository, *Worktree, billy.Filesystem) {
+	fs := memfs.New()
+	r, err := Init(memory.NewStorage(), WithWorkTree(fs))
+	s.Require().NoError(err)
+
+	w, err := r.Worktree()
+	s.Require().NoError(err)
+
+	for name, content := range files {
+		s.Require().NoError(util.WriteFile(fs, name, []byte(content), 0o644))
+		_, err := w.Add(name)
+		s.Require().NoError(err)
+	}
+
+	_, err = w.Commit("init", &CommitOptions{Author: defaultSignature()})
+	s.Require().NoError(err)
+
+	cfg, err := r.Config()
+	s.Require().NoError(err)
+	cfg.Core.AutoCRLF = autoCRLF
+	cfg.Core.EOL = eol
+	s.Require().NoError(r.SetConfig(cfg))
+
+	return r, w, fs
+}
+
+// checkoutAgain removes the given files from the worktree and checks them
+// out again from HEAD.
+func (s *WorktreeSuite) checkoutAgain(w *Worktree, fs billy.Filesystem, files ...string) {
+	for _, name := range files {
+		s.Require().NoError(fs.Remove(name))
+	}
+
+	s.Require().NoError(w.Reset(&ResetOptions{Mode: HardReset}))
+}
+
+func (s *WorktreeSuite) blobContent(r *Repository, name string) string {
+	idx, err := r.Storer.Index()
+	s.Require().NoError(err)
+
+	e, err := idx.Entry(name)
+	s.Require().NoError(err)
+
+	blob, err := object.GetBlob(r.Storer, e.Hash)
+	s.Require().NoError(err)
+
+	rd, err := blob.Reader()
+	s.Require().NoError(err)
+	defer rd.Close()
+
+	content, err := io.ReadAll(rd)
+	s.Require().NoError(err)
+	return string(content)
+}
+
+func (s *WorktreeSuite) assertFile(fs billy.Filesystem, name, expected string) {
+	content, err := util.ReadFile(fs, name)
+	s.Require().NoError(err)
+	s.Equal(expected, string(content), name)
+}
+
+func (s *WorktreeSuite) TestAutoCRLFCheckout() {
+	_, w, fs := s.setupLineEndings(map[string]string{
+		"text": "a\nb\n",
+		"bin":  "a\x00\nb\n",
+	}, "true", "")
+
+	s.checkoutAgain(w, fs, "text", "bin")
+	s.assertFile(fs, "text", "a\r\nb\r\n")
+	s.assertFile(fs, "bin", "a\x00\nb\n")
+
+	status, err := w.Status()
+	s.NoError(err)
+	s.True(status.IsClean(), status.String())
+}
+
+func (s *WorktreeSuite) TestAutoCRLFAdd() {
+	r, w, fs := s.setupLineEndings(map[string]string{
+		"text":   "a\n",
+		"legacy": "a\r\nb\r\n",
+	}, "input", "")
+
+	files := map[string]string{
+		"text": "a\r\nb\r\n",
+		"new":  "c\r\n",
+		"bin":  "a\x00\r\nb\r\n",
+	}
+
+	for name, content := range files {
+		s.Require().NoError(util.WriteFile(fs, name, []byte(content), 0o644))
+		_, err := w.Add(name)
+		s.Require().NoError(err)
+	}
+
+	s.Equal("a\nb\n", s.blobContent(r, "text"))
+	s.Equal("c\n", s.blobContent(r, "new"))
+	s.Equal("a\x00\r\nb\r\n", s.blobContent(r, "bin"))
+
+	// Files committed with CRLF are left as they are.
+	s.Equal("a\r\nb\r\n", s.blobContent(r, "legacy"))
+
+	status, err := w.Status()
+	s.NoError(err)
+	for name := range files {
+		s.Equal(Unmodified, status.File(name).Worktree, name)
+	}
+
+	s.NotContains(status, "legacy")
+}
+
+func (s *WorktreeSuite) TestAutoCRLFRoundTrip() {
+	r, w, fs := s.setupLineEndings(map[string]string{"text": "a\nb\n"}, "true", "")
+
+	s.checkoutAgain(w, fs, "text")
+	s.assertFile(fs, "text", "a\r\nb\r\n")
+
+	s.Require().NoError(util.WriteFile(fs, "text", []byte("a\r\nb\r\nc\r\n"), 0o644))
+	status, err := w.Status()
+	s.NoError(err)
+	s.Equal(Modified, status.File("text").Worktree)
+
+	_, err = w.Add("text")
+	s.NoError(err)
+	s.Equal("a\nb\nc\n", s.blobContent(r, "text"))
+
+	_, err = w.Commit("update", &CommitOptions{Author: defaultSignature()})
+	s.NoError(err)
+
+	status, err = w.Status()
+	s.NoError(err)
+	s.True(status.IsClean(), status.String())
+}
+
+func (s *WorktreeSuite) TestGitattributesLineEndings() {
+	r, w, fs := s.setupLineEndings(map[string]string{
+		".gitattributes": "*.txt text eol=crlf\n*.dat -text\n*.sh eol=lf\n",
+		"a.txt":          "a\nb\n",
+		"a.dat":          "a\nb\n",
+		"a.sh":           "a\nb\n",
+		"other":          "a\nb\n",
+	}, "", "crlf")
+
+	s.checkoutAgain(w, fs, "a.txt", "a.dat", "a.sh", "other")
+	s.assertFile(fs, "a.txt", "a\r\nb\r\n")
+	s.assertFile(fs, "a.dat", "a\nb\n")
+	s.assertFile(fs, "a.sh", "a\nb\n")
+	s.assertFile(fs, "other", "a\nb\n")
+
+	status, err := w.Status()
+	s.NoError(err)
+	s.True(status.IsClean(), status.String())
+
+	s.Require().NoError(util.WriteFile(fs, "b.txt", []byte("c\r\n"), 0o644))
+	s.Require().NoError(util.WriteFile(fs, "b.dat", []byte("c\r\n"), 0o644))
+	s.NoError(w.AddGlob("b.*"))
+	s.Equal("c\n", s.blobContent(r, "b.txt"))
+	s.Equal("c\r\n", s.blobContent(r, "b.dat"))
+}
+
+func (s *WorktreeSuite) TestGitattributesLineEndingsOnClone() {
+	r, _, _ := s.setupLineEndings(map[string]string{
+		".gitattributes": "* text=auto eol=crlf\n",
+		"text":           "a\nb\n",
+		"bin":            "a\x00\nb\n",
+	}, "", "")
+
+	head, err := r.Head()
+	s.Require().NoError(err)
+
+	fs := memfs.New()
+	w := &Worktree{r: r, Filesystem: fs}
+	s.Require().NoError(w.Reset(&ResetOptions{Mode: HardReset, Commit: head.Hash()}))
+
+	s.assertFile(fs, "text", "a\r\nb\r\n")
+	s.assertFile(fs, "bin", "a\x00\nb\n")
+
+	status, err := w.Status()
+	s.NoError(err)
+	s.True(status.IsClean(), status.String())
+}
+
+func (s *WorktreeSuite) TestLineEndingsNotConfigured() {
+	r, w, fs := s.setupLineEndings(map[string]string{"text": "a\nb\n"}, "", "")
+
+	s.checkoutAgain(w, fs, "text")
+	s.assertFile(fs, "text", "a\nb\n")
+
+	s.Require().NoError(util.WriteFile(fs, "text", []byte("a\r\nb\r\n"), 0o644))
+	_, err := w.Add("text")
+	s.NoError(err)
+	s.Equal("a\r\nb\r\n", s.blobContent(r, "text"))
+}
+
+// prefixDriver is a filter driver prepending a prefix to the content of the
+// files when they are added, removed on checkout.
+var prefixDriver = filter.Funcs{
+	CleanFunc: func(_ string, dst io.Writer, src io.Reader) error {
+		if _, err := dst.Write([]byte("clean:")); err != nil {
+			return err
+		}
+
+		_, err := io.Copy(dst, src)
+		return err
+	},
+	SmudgeFunc: func(_ string, dst io.Writer, src io.Reader) error {
+		content, err := io.ReadAll(src)
+		if err != nil {
+			return err
+		}
+
+		_, err = dst.Write(bytes.TrimPrefix(content, []byte("clean:")))
+		return err
+	},
+}
+
+func (s *WorktreeSuite) setupFilterDriver(name string, required bool) (*Repository, *Worktree, billy.Filesystem) {
+	r, w, fs := s.setupLineEndings(map[string]string{
+		".gitattributes": "*.x filter=" + name + "\n",
+	}, "", "")
+
+	if required {
+		cfg, err := r.Config()
+		s.Require().NoError(err)
+		cfg.Raw.Section("filter").Subsection(name).SetOption("required", "true")
+		s.Require().NoError(r.SetConfig(cfg))
+	}
+
+	return r, w, fs
+}
+
+func (s *WorktreeSuite) TestFilterDriver() {
+	filter.Register("prefix", prefixDriver)
+	defer filter.Unregister("prefix")
+
+	r, w, fs := s.setupFilterDriver("prefix", true)
+
+	s.Require().NoError(util.WriteFile(fs, "a.x", []byte("foo\n"), 0o644))
+	s.Require().NoError(util.WriteFile(fs, "a.y", []byte("foo\n"), 0o644))
+	s.NoError(w.AddGlob("a.*"))
+	s.Equal("clean:foo\n", s.blobContent(r, "a.x"))
+	s.Equal("foo\n", s.blobContent(r, "a.y"))
+
+	_, err := w.Commit("add", &CommitOptions{Author: defaultSignature()})
+	s.NoError(err)
+
+	status, err := w.Status()
+	s.NoError(err)
+	s.True(status.IsClean(), status.String())
+
+	s.checkoutAgain(w, fs, "a.x")
+	s.assertFile(fs, "a.x", "foo\n")
+
+	status, err = w.Status()
+	s.NoError(err)
+	s.True(status.IsClean(), status.String())
+}
+
+func (s *WorktreeSuite) TestFilterDriverOfWorktree() {
+	filter.Register("prefix", filter.Funcs{})
+	defer filter.Unregister("prefix")
+
+	r, w, fs := s.setupFilterDriver("prefix", true)
+	w.Filters = map[string]filter.Driver{"prefix": prefixDriver}
+
+	s.Require().NoError(util.WriteFile(fs, "a.x", []byte("foo\n"), 0o644))
+	_, err := w.Add("a.x")
+	s.NoError(err)
+	s.Equal("clean:foo\n", s.blobContent(r, "a.x"))
+
+	// Another worktree of the repository uses the registered driver.
+	other, err := r.Worktree()
+	s.Require().NoError(err)
+	s.Require().NoError(util.WriteFile(fs, "b.x", []byte("foo\n"), 0o644))
+	_, err = other.Add("b.x")
+	s.NoError(err)
+	s.Equal("foo\n", s.blobContent(r, "b.x"))
+}
+
+func (s *WorktreeSuite) TestFilterDriverWithLineEndings() {
+	filter.Register("prefix", prefixDriver)
+	defer filter.Unregister("prefix")
+
+	r, w, fs := s.setupLineEndings(map[string]string{
+		".gitattributes": "*.x filter=prefix text eol=crlf\n",
+	}, "", "")
+
+	s.Require().NoError(util.WriteFile(fs, "a.x", []byte("a\r\nb\r\n"), 0o644))
+	_, err := w.Add("a.x")
+	s.NoError(err)
+	s.Equal("clean:a\nb\n", s.blobContent(r, "a.x"))
+
+	_, err = w.Commit("add", &CommitOptions{Author: defaultSignature()})
+	s.NoError(err)
+
+	s.checkoutAgain(w, fs, "a.x")
+	s.assertFile(fs, "a.x", "a\r\nb\r\n")
+}
+
+func (s *WorktreeSuite) TestFilterDriverMissing() {
+	r, w, fs := s.setupFilterDriver("missing", false)
+
+	s.Require().NoError(util.WriteFile(fs, "a.x", []byte("foo\n"), 0o644))
+	_, err := w.Add("a.x")
+	s.NoError(err)
+	s.Equal("foo\n", s.blobContent(r, "a.x"))
+}
+
+func (s *WorktreeSuite) TestFilterDriverMissingRequired() {
+	_, w, fs := s.setupFilterDriver("missing", true)
+
+	s.Require().NoError(util.WriteFile(fs, "a.x", []byte("foo\n"), 0o644))
+	_, err := w.Add("a.x")
+	s.ErrorIs(err, filter.ErrDriverNotFound)
+}
+
+func (s *WorktreeSuite) TestFilterDriverFails() {
+	errFilter := errors.New("filter failed")
+	filter.Register("failing", filter.Funcs{
+		CleanFunc: func(string, io.Writer, io.Reader) error { return errFilter },
+	})
+	defer filter.Unregister("failing")
+
+	r, w, fs := s.setupFilterDriver("failing", false)
+
+	s.Require().NoError(util.WriteFile(fs, "a.x", []byte("foo\n"), 0o644))
+	_, err := w.Add("a.x")
+	s.NoError(err)
+	s.Equal("foo\n", s.blobContent(r, "a.x"))
+
+	_, w, fs = s.setupFilterDriver("failing", true)
+	s.Require().NoError(util.WriteFile(fs, "a.x", []byte("foo\n"), 0o644))
+	_, err = w.Add("a.x")
+	s.ErrorIs(err, errFilter)
+}
@@ -0,0 +1,431 @@
+package git
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/osfs"
+	"github.com/go-git/go-billy/v5/util"
+
+	"github.com/go-git/go-git/v6/plumbing"
+)
+
+const (
+	worktreesPath = "worktrees"
+
+	worktreeGitDirFile    = "gitdir"
+	worktreeCommonDirFile = "commondir"
+	worktreeLockedFile    = "locked"
+)
+
+var (
+	ErrWorktreeExists             = errors.New("worktree already exists")
+	ErrWorktreeNotFound           = errors.New("worktree not found")
+	ErrWorktreeLocked             = errors.New("worktree is locked")
+	ErrBranchCheckedOut           = errors.New("branch is already checked out by another worktree")
+	ErrLinkedWorktreeNotSupported = errors.New("linked worktrees are only supported by filesystem storers")
+)
+
+// LinkedWorktree is a worktree linked to a repository, as the ones added by
+// `git worktree add`. Its administrative files are kept in the
+// .git/worktrees/<name> directory of the repository.
+type LinkedWorktree struct {
+	// Name is the name of the administrative directory of the worktree.
+	Name string
+	// Path is the absolute path of the worktree.
+	Path string
+	// Head is the HEAD of the worktree, a symbolic reference to the branch
+	// checked out, or a hash reference when it is detached.
+	Head *plumbing.Reference
+	// Locked reports whether the worktree is locked, it can't be removed
+	// without forcing it.
+	Locked bool
+}
+
+// AddWorktree adds a linked worktree at the given path, as `git worktree add`.
+// The administrative files of the worktree are created under .git/worktrees,
+// and a .git file pointing to them is written in the worktree. The objects,
+// the references and the configuration are shared with the repository, while
+// HEAD and the index are specific to the worktree.
+//
+// The returned repository is opened from the new worktree, with its files
+// checked out unless NoCheckout is set.
+func (r *Repository) AddWorktree(worktreePath string, o *AddWorktreeOptions) (*Repository, error) {
+	if o == nil {
+		o = &AddWorktreeOptions{}
+	}
+
+	if err := o.Validate(r); err != nil {
+		return nil, err
+	}
+
+	fs, err := r.dotGitFilesystem()
+	if err != nil {
+		return nil, err
+	}
+
+	worktreePath, err = filepath.Abs(worktreePath)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkWorktreePath(worktreePath); err != nil {
+		return nil, err
+	}
+
+	name, err := worktreeName(fs, worktreePath, o.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	head, err := r.addWorktreeHead(fs, o)
+	if err != nil {
+		return nil, err
+	}
+
+	adminPath := path.Join(worktreesPath, name)
+	if err := fs.MkdirAll(adminPath, 0o755); err != nil {
+		return nil, err
+	}
+
+	admin, err := fs.Chroot(adminPath)
+	if err != nil {
+		return nil, err
+	}
+
+	files := map[string]string{
+		worktreeGitDirFile:     filepath.Join(worktreePath, GitDirName),
+		worktreeCommonDirFile:  filepath.Join("..", ".."),
+		plumbing.HEAD.String(): head.Strings()[1],
+	}
+
+	for name, content := range files {
+		if err := util.WriteFile(admin, name, []byte(content+"\n"), 0o644); err != nil {
+			return nil, err
+		}
+	}
+
+	wt := osfs.New(worktreePath, osfs.WithBoundOS())
+	if err := util.WriteFile(wt, GitDirName, []byte(fmt.Sprintf("gitdir: %s\n", admin.Root())), 0o644); err != nil {
+		return nil, err
+	}
+
+	linked, err := PlainOpenWithOptions(worktreePath, &PlainOpenOptions{EnableDotGitCommonDir: true})
+	if err != nil {
+		return nil, err
+	}
+
+	w, err := linked.Worktree()
+	if err != nil {
+		return nil, err
+	}
+
+	mode := HardReset
+	if o.NoCheckout {
+		mode = MixedReset
+	}
+
+	if err := w.reset(&ResetOptions{Commit: o.Hash, Mode: mode}, ""); err != nil {
+		return nil, err
+	}
+
+	return linked, nil
+}
+
+// addWorktreeHead returns the HEAD of a worktree being added, creating its
+// branch if requested.
+func (r *Repository) addWorktreeHead(fs billy.Filesystem, o *AddWorktreeOptions) (*plumbing.Reference, error) {
+	if o.Branch == "" {
+		return plumbing.NewHashReference(plumbing.HEAD, o.Hash), nil
+	}
+
+	if !o.Force {
+		checkedOut, err := r.checkedOutBranches(fs)
+		if err != nil {
+			return nil, err
+		}
+
+		if _, ok := checkedOut[o.Branch]; ok {
+			return nil, fmt.Errorf("%w: %s", ErrBranchCheckedOut, o.Branch)
+		}
+	}
+
+	if o.Create {
+		ref := plumbing.NewHashReference(o.Branch, o.Hash)
+		if err := r.Storer.SetReference(ref); err != nil {
+			return nil, err
+		}
+	}
+
+	return plumbing.NewSymbolicReference(plumbing.HEAD, o.Branch), nil
+}
+
+// checkedOutBranches returns the branches checked out by the worktree of the
+// repository and by its linked worktrees.
+func (r *Repository) checkedOutBranches(fs billy.Filesystem) (map[plumbing.ReferenceName]struct{}, error) {
+	branches := make(map[plumbing.ReferenceName]struct{})
+
+	head, err := r.Storer.Reference(plumbing.HEAD)
+	if err != nil && !errors.Is(err, plumbing.ErrReferenceNotFound) {
+		return nil, err
+	}
+
+	if head != nil && head.Type() == plumbing.SymbolicReference {
+		branches[head.Target()] = struct{}{}
+	}
+
+	worktrees, err := linkedWorktrees(fs)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, w := range worktrees {
+		if w.Head != nil && w.Head.Type() == plumbing.SymbolicReference {
+			branches[w.Head.Target()] = struct{}{}
+		}
+	}
+
+	return branches, nil
+}
+
+// Worktrees returns the linked worktrees of the repository, as
+// `git worktree list`. The main worktree is not included.
+func (r *Repository) Worktrees() ([]*LinkedWorktree, error) {
+	fs, err := r.dotGitFilesystem()
+	if err != nil {
+		return nil, err
+	}
+
+	return linkedWorktrees(fs)
+}
+
+// RemoveWorktree removes the linked worktree with the given name, as
+// `git worktree remove`: both the worktree and its administrative files are
+// deleted. A locked worktree, or one with local changes, is only removed
+// when Force is used.
+func (r *Repository) RemoveWorktree(name string, o *RemoveWorktreeOptions) error {
+	if o == nil {
+		o = &RemoveWorktreeOptions{}
+	}
+
+	fs, err := r.dotGitFilesystem()
+	if err != nil {
+		return err
+	}
+
+	w, err := linkedWorktree(fs, name)
+	if err != nil {
+		return err
+	}
+
+	if !o.Force {
+		if w.Locked {
+			return fmt.Errorf("%w: %s", ErrWorktreeLocked, name)
+		}
+
+		if err := checkWorktreeClean(w.Path); err != nil {
+			return err
+		}
+	}
+
+	if err := os.RemoveAll(w.Path); err != nil {
+		return err
+	}
+
+	return util.RemoveAll(fs, path.Join(worktreesPath, name))
+}
+
+// dotGitFilesystem returns the filesystem of the .git directory of the
+// repository, required to manage the linked worktrees.
+func (r *Repository) dotGitFilesystem() (billy.Filesystem, error) {
+	type fsBased interface {
+		Filesystem() billy.Filesystem
+	}
+
+	s, ok := r.Storer.(fsBased)
+	if !ok {
+		return nil, ErrLinkedWorktreeNotSupported
+	}
+
+	return s.Filesystem(), nil
+}
+
+func checkWorktreeClean(worktreePath string) error {
+	linked, err := PlainOpenWithOptions(worktreePath, &PlainOpenOptions{EnableDotGitCommonDir: true})
+	if errors.Is(err, ErrRepositoryNotExists) {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	w, err := linked.Worktree()
+	if err != nil {
+		return err
+	}
+
+	status, err := w.Status()
+	if err != nil {
+		return err
+	}
+
+	if !status.IsClean() {
+		return ErrWorktreeNotClean
+	}
+
+	return nil
+}
+
+// checkWorktreePath returns ErrWorktreeExists if the path of a new worktree
+// is a file or a non empty directory.
+func checkWorktreePath(worktreePath string) error {
+	fi, err := os.Stat(worktreePath)
+	if os.IsNotExist(err) {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if !fi.IsDir() {
+		return fmt.Errorf("%w: %s", ErrWorktreeExists, worktreePath)
+	}
+
+	entries, err := os.ReadDir(worktreePath)
+	if err != nil {
+		return err
+	}
+
+	if len(entries) > 0 {
+		return fmt.Errorf("%w: %s", ErrWorktreeExists, worktreePath)
+	}
+
+	return nil
+}
+
+// worktreeName returns the name of the administrative directory of a new
+// worktree. When no name is given, the base name of the path is used, with a
+// numeric suffix if a worktree with the same name already exists.
+func worktreeName(fs billy.Filesystem, worktreePath, name string) (string, error) {
+	exists := func(name string) (bool, error) {
+		_, err := fs.Stat(path.Join(worktreesPath, name))
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+
+		return err == nil, err
+	}
+
+	if name != "" {
+		ok, err := exists(name)
+		if err != nil {
+			return "", err
+		}
+
+		if ok {
+			return "", fmt.Errorf("%w: %s", ErrWorktreeExists, name)
+		}
+
+		return name, nil
+	}
+
+	base := filepath.Base(worktreePath)
+	name = base
+	for i := 1; ; i++ {
+		ok, err := exists(name)
+		if err != nil {
+			return "", err
+		}
+
+		if !ok {
+			return name, nil
+		}
+
+		name = fmt.Sprintf("%s%d", base, i)
+	}
+}
+
+func linkedWorktrees(fs billy.Filesystem) ([]*LinkedWorktree, error) {
+	entries, err := fs.ReadDir(worktreesPath)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	var worktrees []*LinkedWorktree
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+
+		w, err := linkedWorktree(fs, e.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		worktrees = append(worktrees, w)
+	}
+
+	return worktrees, nil
+}
+
+func linkedWorktree(fs billy.Filesystem, name string) (*LinkedWorktree, error) {
+	if name == "" || strings.ContainsAny(name, `/\`) || name == "." || name == ".." {
+		return nil, fmt.Errorf("%w: %s", ErrWorktreeNotFound, name)
+	}
+
+	adminPath := path.Join(worktreesPath, name)
+	gitdir, err := readWorktreeFile(fs, path.Join(adminPath, worktreeGitDirFile))
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("%w: %s", ErrWorktreeNotFound, name)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	w := &LinkedWorktree{Name: name, Path: filepath.Dir(gitdir)}
+
+	head, err := readWorktreeFile(fs, path.Join(adminPath, plumbing.HEAD.String()))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	if head != "" {
+		w.Head = plumbing.NewReferenceFromStrings(plumbing.HEAD.String(), head)
+	}
+
+	_, err = fs.Stat(path.Join(adminPath, worktreeLockedFile))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	w.Locked = err == nil
+	return w, nil
+}
+
+func readWorktreeFile(fs billy.Filesystem, filename string) (string, error) {
+	f, err := fs.Open(filename)
+	if err != nil {
+		return "", err
+	}
+
+	defer f.Close()
+	b, err := io.ReadAll(f)
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(string(b)), nil
+}
@@ -0,0 +1,228 @@
+package git
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-git/go-billy/v5/util"
+	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/stretchr/testify/suite"
+)
+
+type LinkedWorktreeSuite struct {
+	suite.Suite
+	dir  string
+	r    *Repository
+	hash plumbing.Hash
+}
+
+func TestLinkedWorktreeSuite(t *testing.T) {
+	suite.Run(t, new(LinkedWorktreeSuite))
+}
+
+func (s *LinkedWorktreeSuite) SetupTest() {
+	s.dir = s.T().TempDir()
+
+	r, err := PlainInit(filepath.Join(s.dir, "main"), false)
+	s.Require().NoError(err)
+
+	w, err := r.Worktree()
+	s.Require().NoError(err)
+
+	s.Require().NoError(util.WriteFile(w.Filesystem, "foo", []byte("foo\n"), 0o644))
+	_, err = w.Add("foo")
+	s.Require().NoError(err)
+
+	s.hash, err = w.Commit("foo", &CommitOptions{Author: defaultSignature()})
+	s.Require().NoError(err)
+
+	s.r = r
+}
+
+func (s *LinkedWorktreeSuite) TestAddWorktree() {
+	path := filepath.Join(s.dir, "feature")
+	linked, err := s.r.AddWorktree(path, &AddWorktreeOptions{
+		Branch: "refs/heads/feature",
+		Create: true,
+	})
+	s.Require().NoError(err)
+
+	content, err := os.ReadFile(filepath.Join(path, "foo"))
+	s.Require().NoError(err)
+	s.Equal("foo\n", string(content))
+
+	admin := filepath.Join(s.dir, "main", GitDirName, "worktrees", "feature")
+	for file, expected := range map[string]string{
+		".git":                                  "gitdir: " + admin + "\n",
+		"../main/.git/worktrees/feature/gitdir": filepath.Join(path, GitDirName) + "\n",
+		"../main/.git/worktrees/feature/commondir": filepath.Join("..", "..") + "\n",
+		"../main/.git/worktrees/feature/HEAD":      "ref: refs/heads/feature\n",
+	} {
+		content, err := os.ReadFile(filepath.Join(path, file))
+		s.Require().NoError(err)
+		s.Equal(expected, string(content), file)
+	}
+
+	// HEAD is specific to each worktree, while the references are shared.
+	head, err := linked.Head()
+	s.Require().NoError(err)
+	s.Equal(plumbing.ReferenceName("refs/heads/feature"), head.Name())
+	s.Equal(s.hash, head.Hash())
+
+	head, err = s.r.Head()
+	s.Require().NoError(err)
+	s.Equal(plumbing.Master, head.Name())
+
+	w, err := linked.Worktree()
+	s.Require().NoError(err)
+	s.Require().NoError(util.WriteFile(w.Filesystem, "bar", []byte("bar\n"), 0o644))
+	_, err = w.Add("bar")
+	s.Require().NoError(err)
+	commit, err := w.Commit("bar", &CommitOptions{Author: defaultSignature()})
+	s.Require().NoError(err)
+
+	ref, err := s.r.Reference("refs/heads/feature", false)
+	s.Require().NoError(err)
+	s.Equal(commit, ref.Hash())
+
+	_, err = s.r.CommitObject(commit)
+	s.NoError(err)
+
+	status, err := w.Status()
+	s.Require().NoError(err)
+	s.True(status.IsClean())
+}
+
+func (s *LinkedWorktreeSuite) TestAddWorktreeDetached() {
+	linked, err := s.r.AddWorktree(filepath.Join(s.dir, "detached"), nil)
+	s.Require().NoError(err)
+
+	head, err := linked.Head()
+	s.Require().NoError(err)
+	s.Equal(plumbing.HEAD, head.Name())
+	s.Equal(s.hash, head.Hash())
+}
+
+func (s *LinkedWorktreeSuite) TestAddWorktreeNoCheckout() {
+	path := filepath.Join(s.dir, "empty")
+	linked, err := s.r.AddWorktree(path, &AddWorktreeOptions{NoCheckout: true})
+	s.Require().NoError(err)
+
+	s.NoFileExists(filepath.Join(path, "foo"))
+
+	head, err := linked.Head()
+	s.Require().NoError(err)
+	s.Equal(s.hash, head.Hash())
+
+	w, err := linked.Worktree()
+	s.Require().NoError(err)
+
+	status, err := w.Status()
+	s.Require().NoError(err)
+	s.Equal(Deleted, status.File("foo").Worktree)
+	s.Equal(Unmodified, status.File("foo").Staging)
+
+	s.Require().NoError(w.Checkout(&CheckoutOptions{Hash: s.hash, Force: true}))
+	s.FileExists(filepath.Join(path, "foo"))
+}
+
+func (s *LinkedWorktreeSuite) TestAddWorktreeBranchCheckedOut() {
+	_, err := s.r.AddWorktree(filepath.Join(s.dir, "master"), &AddWorktreeOptions{
+		Branch: plumbing.Master,
+	})
+	s.ErrorIs(err, ErrBranchCheckedOut)
+
+	_, err = s.r.AddWorktree(filepath.Join(s.dir, "a"), &AddWorktreeOptions{
+		Branch: "refs/heads/feature",
+		Create: true,
+	})
+	s.Require().NoError(err)
+
+	_, err = s.r.AddWorktree(filepath.Join(s.dir, "b"), &AddWorktreeOptions{
+		Branch: "refs/heads/feature",
+	})
+	s.ErrorIs(err, ErrBranchCheckedOut)
+
+	_, err = s.r.AddWorktree(filepath.Join(s.dir, "b"), &AddWorktreeOptions{
+		Branch: "refs/heads/feature",
+		Force:  true,
+	})
+	s.NoError(err)
+}
+
+func (s *LinkedWorktreeSuite) TestAddWorktreeExists() {
+	path := filepath.Join(s.dir, "existing")
+	s.Require().NoError(os.MkdirAll(path, 0o755))
+	s.Require().NoError(os.WriteFile(filepath.Join(path, "foo"), nil, 0o644))
+
+	_, err := s.r.AddWorktree(path, nil)
+	s.ErrorIs(err, ErrWorktreeExists)
+
+	_, err = s.r.AddWorktree(filepath.Join(s.dir, "a"), &AddWorktreeOptions{Name: "wt"})
+	s.Require().NoError(err)
+
+	_, err = s.r.AddWorktree(filepath.Join(s.dir, "b"), &AddWorktreeOptions{Name: "wt"})
+	s.ErrorIs(err, ErrWorktreeExists)
+}
+
+func (s *LinkedWorktreeSuite) TestWorktrees() {
+	worktrees, err := s.r.Worktrees()
+	s.Require().NoError(err)
+	s.Len(worktrees, 0)
+
+	_, err = s.r.AddWorktree(filepath.Join(s.dir, "a", "wt"), nil)
+	s.Require().NoError(err)
+	_, err = s.r.AddWorktree(filepath.Join(s.dir, "b", "wt"), &AddWorktreeOptions{
+		Branch: "refs/heads/feature",
+		Create: true,
+	})
+	s.Require().NoError(err)
+
+	worktrees, err = s.r.Worktrees()
+	s.Require().NoError(err)
+	s.Require().Len(worktrees, 2)
+
+	s.Equal("wt", worktrees[0].Name)
+	s.Equal(filepath.Join(s.dir, "a", "wt"), worktrees[0].Path)
+	s.Equal(plumbing.HashReference, worktrees[0].Head.Type())
+	s.Equal(s.hash, worktrees[0].Head.Hash())
+
+	s.Equal("wt1", worktrees[1].Name)
+	s.Equal(filepath.Join(s.dir, "b", "wt"), worktrees[1].Path)
+	s.Equal(plumbing.ReferenceName("refs/heads/feature"), worktrees[1].Head.Target())
+	s.False(worktrees[1].Locked)
+}
+
+func (s *LinkedWorktreeSuite) TestRemoveWorktree() {
+	path := filepath.Join(s.dir, "wt")
+	linked, err := s.r.AddWorktree(path, nil)
+	s.Require().NoError(err)
+
+	err = s.r.RemoveWorktree("foo", nil)
+	s.ErrorIs(err, ErrWorktreeNotFound)
+
+	w, err := linked.Worktree()
+	s.Require().NoError(err)
+	s.Require().NoError(util.WriteFile(w.Filesystem, "foo", []byte("bar\n"), 0o644))
+
+	err = s.r.RemoveWorktree("wt", nil)
+	s.ErrorIs(err, ErrWorktreeNotClean)
+
+	admin := filepath.Join(s.dir, "main", GitDirName, "worktrees", "wt")
+	s.Require().NoError(os.WriteFile(filepath.Join(admin, "locked"), nil, 0o644))
+
+	err = s.r.RemoveWorktree("wt", nil)
+	s.ErrorIs(err, ErrWorktreeLocked)
+
+	s.Require().NoError(s.r.RemoveWorktree("wt", &RemoveWorktreeOptions{Force: true}))
+
+	_, err = os.Stat(path)
+	s.True(os.IsNotExist(err))
+	_, err = os.Stat(admin)
+	s.True(os.IsNotExist(err))
+
+	worktrees, err := s.r.Worktrees()
+	s.Require().NoError(err)
+	s.Len(worktrees, 0)
+}
@@ -0,0 +1,326 @@
+package git
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/go-git/go-git/v6/plumbing/filemode"
+	"github.com/go-git/go-git/v6/plumbing/format/gitattributes"
+	"github.com/go-git/go-git/v6/plumbing/format/index"
+	"github.com/go-git/go-git/v6/plumbing/object"
+)
+
+var (
+	// ErrMergeInProgress is returned when a merge is attempted while a
+	// previous one, recorded in MERGE_HEAD, was not yet committed.
+	ErrMergeInProgress = errors.New("a merge is already in progress")
+	// ErrUnmergedPaths is returned when committing an index that still
+	// contains conflicting entries.
+	ErrUnmergedPaths = errors.New("index contains unmerged paths")
+)
+
+// Merge merges the given commit into the current HEAD.
+//
+// With the FastForwardMerge strategy, which is the default, only
+// fast-forward merges are performed, returning ErrFastForwardMergeNotPossible
+// otherwise. With the RecursiveMerge strategy, a three-way merge is performed
+// when a fast-forward is not possible. Files changed on both sides are merged
+// line by line, writing conflict markers into the worktree when the changes
+// overlap. Conflicting paths are recorded in the index using the ancestor (1),
+// ours (2) and theirs (3) stages, and returned in the MergeResult.
+//
+// A three-way merge never creates a commit by itself, MERGE_HEAD is left in
+// place instead, so a following call to Commit records both parents. The
+// merge can be aborted by resetting the worktree.
+//
+// As git does, the commit HEAD points to is recorded in ORIG_HEAD before the
+// worktree is updated.
+//
+// NoErrAlreadyUpToDate is returned if the commit is already reachable from
+// HEAD, and ErrWorktreeNotClean if the worktree has uncommitted changes.
+func (w *Worktree) Merge(other plumbing.Hash, opts *MergeOptions) (*MergeResult, error) {
+	if opts == nil {
+		opts = &MergeOptions{}
+	}
+
+	if opts.Strategy != FastForwardMerge && opts.Strategy != RecursiveMerge {
+		return nil, ErrUnsupportedMergeStrategy
+	}
+
+	_, err := w.r.Storer.Reference(plumbing.MergeHead)
+	if err == nil {
+		return nil, ErrMergeInProgress
+	}
+
+	if err != plumbing.ErrReferenceNotFound {
+		return nil, err
+	}
+
+	head, err := w.r.Head()
+	if err != nil {
+		return nil, err
+	}
+
+	ours, err := w.r.CommitObject(head.Hash())
+	if err != nil {
+		return nil, err
+	}
+
+	theirs, err := w.r.CommitObject(other)
+	if err != nil {
+		return nil, err
+	}
+
+	upToDate, err := theirs.IsAncestor(ours)
+	if err != nil {
+		return nil, err
+	}
+
+	if upToDate {
+		return nil, NoErrAlreadyUpToDate
+	}
+
+	ff, err := ours.IsAncestor(theirs)
+	if err != nil {
+		return nil, err
+	}
+
+	if ff {
+		if err := w.r.setOrigHead(); err != nil {
+			return nil, err
+		}
+
+		msg := fmt.Sprintf("merge %s: Fast-forward", theirs.Hash)
+		if err := w.reset(&ResetOptions{Mode: MergeReset, Commit: theirs.Hash}, msg); err != nil {
+			return nil, err
+		}
+
+		return &MergeResult{FastForward: true}, nil
+	}
+
+	if opts.Strategy == FastForwardMerge {
+		return nil, ErrFastForwardMergeNotPossible
+	}
+
+	status, err := w.Status()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, fs := range status {
+		if !isUntrackedOrUnmodified(fs.Staging) || !isUntrackedOrUnmodified(fs.Worktree) {
+			return nil, ErrWorktreeNotClean
+		}
+	}
+
+	attributes, err := w.attributesMatcher()
+	if err != nil {
+		return nil, err
+	}
+
+	m := &treeMerger{
+		s:           w.r.Storer,
+		attributes:  attributes,
+		oursLabel:   plumbing.HEAD.String(),
+		theirsLabel: theirs.Hash.String(),
+	}
+
+	base, err := m.baseTree(ours, theirs)
+	if err != nil {
+		return nil, err
+	}
+
+	oursTree, err := ours.Tree()
+	if err != nil {
+		return nil, err
+	}
+
+	theirsTree, err := theirs.Tree()
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := m.merge(base, oursTree, theirsTree)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := w.r.setOrigHead(); err != nil {
+		return nil, err
+	}
+
+	if err := w.applyMerge(res, status); err != nil {
+		return nil, err
+	}
+
+	err = w.r.Storer.SetReference(plumbing.NewHashReference(plumbing.MergeHead, theirs.Hash))
+	if err != nil {
+		return nil, err
+	}
+
+	return &MergeResult{Conflicts: res.conflicts}, nil
+}
+
+// applyMerge updates the worktree and the index with the result of a merge.
+// The index is expected to match HEAD.
+func (w *Worktree) applyMerge(res *treeMergeResult, status Status) error {
+	idx, err := w.r.Storer.Index()
+	if err != nil {
+		return err
+	}
+
+	filter, err := w.worktreeContentFilter(nil)
+	if err != nil {
+		return err
+	}
+
+	symlinks, err := w.symlinksEnabled()
+	if err != nil {
+		return err
+	}
+
+	current := make(map[string]*index.Entry, len(idx.Entries))
+	for _, e := range idx.Entries {
+		current[e.Name] = e
+	}
+
+	var changed []string
+	for p, e := range res.entries {
+		c, ok := current[p]
+		if ok && c.Hash == e.Hash && c.Mode == e.Mode {
+			continue
+		}
+
+		// Untracked files are never overwritten.
+		if !ok && status.IsUntracked(p) {
+			return ErrWorktreeNotClean
+		}
+
+		changed = append(changed, p)
+	}
+
+	for p := range current {
+		if _, ok := res.entries[p]; !ok && !res.inKeptTree(p) {
+			changed = append(changed, p)
+		}
+	}
+
+	sort.Strings(changed)
+
+	b := newIndexBuilder(idx)
+	for _, p := range changed {
+		if err := validPath(p); err != nil {
+			return err
+		}
+
+		e, ok := res.entries[p]
+		if !ok {
+			b.Remove(p)
+			if err := rmFileAndDirsIfEmpty(w.Filesystem, p); err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		if e.Mode == filemode.Submodule {
+			if err := w.Filesystem.MkdirAll(p, os.ModeDir|os.ModePerm); err != nil {
+				return err
+			}
+
+			if err := w.addIndexFromTreeEntry(p, &e, b); err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		if _, ok := current[p]; ok {
+			// to apply perm changes the file is deleted, billy doesn't
+			// implement chmod
+			if err := w.Filesystem.Remove(p); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+
+		blob, err := object.GetBlob(w.r.Storer, e.Hash)
+		if err != nil {
+			return err
+		}
+
+		if err := w.checkoutFile(object.NewFile(p, e.Mode, blob), filter, symlinks); err != nil {
+			return err
+		}
+
+		if err := w.addIndexFromFile(p, e.Hash, e.Mode, b); err != nil {
+			return err
+		}
+	}
+
+	for _, c := range res.conflicts {
+		b.Remove(c.Path)
+	}
+
+	b.Write(idx)
+	for _, c := range res.conflicts {
+		stages := []*object.TreeEntry{c.Ancestor, c.Ours, c.Theirs}
+		for i, e := range stages {
+			if e == nil {
+				continue
+			}
+
+			idx.Entries = append(idx.Entries, &index.Entry{
+				Name:  c.Path,
+				Hash:  e.Hash,
+				Mode:  e.Mode,
+				Stage: index.AncestorMode + index.Stage(i),
+			})
+		}
+	}
+
+	return w.r.Storer.SetIndex(idx)
+}
+
+// attributesMatcher returns a matcher for the .gitattributes files of the
+// worktree, or nil if there are none. The files are read from the root of the
+// worktree down, so the patterns closer to a path take precedence.
+func (w *Worktree) attributesMatcher() (gitattributes.Matcher, error) {
+	patterns, err := gitattributes.ReadPatterns(w.Filesystem, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(patterns) == 0 {
+		return nil, nil
+	}
+
+	return gitattributes.NewMatcher(patterns), nil
+}
+
+func isUntrackedOrUnmodified(c StatusCode) bool {
+	return c == Untracked || c == Unmodified
+}
+
+// removeMergeState removes the references recording a merge, a cherry-pick
+// or a revert in progress.
+func (r *Repository) removeMergeState() error {
+	for _, name := range []plumbing.ReferenceName{plumbing.MergeHead, plumbing.CherryPickHead, plumbing.RevertHead} {
+		_, err := r.Storer.Reference(name)
+		if err == plumbing.ErrReferenceNotFound {
+			continue
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if err := r.Storer.RemoveReference(name); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,416 @@
+package git
+
+import (
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/go-git/go-billy/v5/util"
+	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/go-git/go-git/v6/plumbing/format/index"
+	"github.com/go-git/go-git/v6/plumbing/object"
+	"github.com/go-git/go-git/v6/storage/memory"
+)
+
+// setupMergeBranches creates a repository where master and feature diverge
+// from a common commit, each one applying the given changes on top of base.
+// A nil content removes the file. HEAD is left on master.
+func (s *WorktreeSuite) setupMergeBranches(base, master, feature map[string][]byte) (*Repository, *Worktree, billy.Filesystem, plumbing.Hash) {
+	fs := memfs.New()
+	r, err := Init(memory.NewStorage(), WithWorkTree(fs))
+	s.Require().NoError(err)
+
+	w, err := r.Worktree()
+	s.Require().NoError(err)
+
+	commit := func(files map[string][]byte) plumbing.Hash {
+		for name, content := range files {
+			if content == nil {
+				_, err := w.Remove(name)
+				s.Require().NoError(err)
+				continue
+			}
+
+			s.Require().NoError(util.WriteFile(fs, name, content, 0644))
+			_, err := w.Add(name)
+			s.Require().NoError(err)
+		}
+
+		h, err := w.Commit("commit", &CommitOptions{Author: defaultSignature(), AllowEmptyCommits: true})
+		s.Require().NoError(err)
+		return h
+	}
+
+	commit(base)
+	s.Require().NoError(w.Checkout(&CheckoutOptions{Branch: "refs/heads/feature", Create: true}))
+	other := commit(feature)
+
+	s.Require().NoError(w.Checkout(&CheckoutOptions{Branch: plumbing.Master}))
+	commit(master)
+
+	return r, w, fs, other
+}
+
+func (s *WorktreeSuite) TestMergeRecursiveClean() {
+	r, w, fs, other := s.setupMergeBranches(
+		map[string][]byte{"foo": []byte("a\nb\nc\nd\ne\n"), "bar": []byte("bar\n")},
+		map[string][]byte{"foo": []byte("A\nb\nc\nd\ne\n"), "qux": []byte("qux\n")},
+		map[string][]byte{"foo": []byte("a\nb\nc\nd\nE\n"), "bar": nil},
+	)
+
+	head, err := r.Head()
+	s.NoError(err)
+
+	res, err := w.Merge(other, &MergeOptions{Strategy: RecursiveMerge})
+	s.NoError(err)
+	s.False(res.FastForward)
+	s.False(res.HasConflicts())
+
+	content, err := util.ReadFile(fs, "foo")
+	s.NoError(err)
+	s.Equal("A\nb\nc\nd\nE\n", string(content))
+
+	_, err = fs.Stat("bar")
+	s.Error(err)
+
+	status, err := w.Status()
+	s.NoError(err)
+	s.Equal(Modified, status.File("foo").Staging)
+	s.Equal(Deleted, status.File("bar").Staging)
+	s.Equal(Unmodified, status.File("foo").Worktree)
+
+	ref, err := r.Reference(plumbing.MergeHead, false)
+	s.NoError(err)
+	s.Equal(other, ref.Hash())
+
+	ref, err = r.Reference(plumbing.OrigHead, false)
+	s.NoError(err)
+	s.Equal(head.Hash(), ref.Hash())
+
+	h, err := w.Commit("merge", &CommitOptions{Author: defaultSignature()})
+	s.NoError(err)
+
+	commit, err := r.CommitObject(h)
+	s.NoError(err)
+	s.Equal([]plumbing.Hash{head.Hash(), other}, commit.ParentHashes)
+
+	_, err = r.Reference(plumbing.MergeHead, false)
+	s.ErrorIs(err, plumbing.ErrReferenceNotFound)
+}
+
+func (s *WorktreeSuite) TestMergeRecursiveConflict() {
+	r, w, fs, other := s.setupMergeBranches(
+		map[string][]byte{"foo": []byte("a\nb\nc\n")},
+		map[string][]byte{"foo": []byte("a\nX\nc\n")},
+		map[string][]byte{"foo": []byte("a\nY\nc\n")},
+	)
+
+	res, err := w.Merge(other, &MergeOptions{Strategy: RecursiveMerge})
+	s.NoError(err)
+	s.Require().Len(res.Conflicts, 1)
+	s.Equal("foo", res.Conflicts[0].Path)
+	s.NotNil(res.Conflicts[0].Ancestor)
+	s.NotNil(res.Conflicts[0].Ours)
+	s.NotNil(res.Conflicts[0].Theirs)
+
+	content, err := util.ReadFile(fs, "foo")
+	s.NoError(err)
+	s.Equal("a\n<<<<<<< HEAD\nX\n=======\nY\n>>>>>>> "+other.String()+"\nc\n", string(content))
+
+	idx, err := r.Storer.Index()
+	s.NoError(err)
+
+	var stages []index.Stage
+	for _, e := range idx.Entries {
+		if e.Name == "foo" {
+			stages = append(stages, e.Stage)
+		}
+	}
+	s.Equal([]index.Stage{index.AncestorMode, index.OurMode, index.TheirMode}, stages)
+
+	status, err := w.Status()
+	s.NoError(err)
+	s.Equal(UpdatedButUnmerged, status.File("foo").Staging)
+
+	_, err = w.Commit("merge", &CommitOptions{Author: defaultSignature()})
+	s.ErrorIs(err, ErrUnmergedPaths)
+
+	_, err = w.Merge(other, &MergeOptions{Strategy: RecursiveMerge})
+	s.ErrorIs(err, ErrMergeInProgress)
+
+	s.NoError(w.Reset(&ResetOptions{Mode: HardReset}))
+	_, err = r.Reference(plumbing.MergeHead, false)
+	s.ErrorIs(err, plumbing.ErrReferenceNotFound)
+
+	content, err = util.ReadFile(fs, "foo")
+	s.NoError(err)
+	s.Equal("a\nX\nc\n", string(content))
+}
+
+func (s *WorktreeSuite) TestMergeRecursiveBinaryAttribute() {
+	_, w, fs, other := s.setupMergeBranches(
+		map[string][]byte{
+			".gitattributes": []byte("*.lock merge=binary\n"),
+			"foo.lock":       []byte("a\nb\nc\nd\ne\n"),
+			"foo.txt":        []byte("a\nb\nc\nd\ne\n"),
+		},
+		map[string][]byte{
+			"foo.lock": []byte("A\nb\nc\nd\ne\n"),
+			"foo.txt":  []byte("A\nb\nc\nd\ne\n"),
+		},
+		map[string][]byte{
+			"foo.lock": []byte("a\nb\nc\nd\nE\n"),
+			"foo.txt":  []byte("a\nb\nc\nd\nE\n"),
+		},
+	)
+
+	res, err := w.Merge(other, &MergeOptions{Strategy: RecursiveMerge})
+	s.NoError(err)
+	s.Require().Len(res.Conflicts, 1)
+	s.Equal("foo.lock", res.Conflicts[0].Path)
+
+	// ours is kept, without conflict markers
+	content, err := util.ReadFile(fs, "foo.lock")
+	s.NoError(err)
+	s.Equal("A\nb\nc\nd\ne\n", string(content))
+
+	content, err = util.ReadFile(fs, "foo.txt")
+	s.NoError(err)
+	s.Equal("A\nb\nc\nd\nE\n", string(content))
+}
+
+func (s *WorktreeSuite) TestMergeRecursiveTextAttribute() {
+	_, w, fs, other := s.setupMergeBranches(
+		map[string][]byte{
+			"dir/.gitattributes": []byte("*.dat merge\n"),
+			"dir/foo.dat":        []byte("a\x00\nb\nc\nd\ne\n"),
+		},
+		map[string][]byte{"dir/foo.dat": []byte("A\x00\nb\nc\nd\ne\n")},
+		map[string][]byte{"dir/foo.dat": []byte("a\x00\nb\nc\nd\nE\n")},
+	)
+
+	res, err := w.Merge(other, &MergeOptions{Strategy: RecursiveMerge})
+	s.NoError(err)
+	s.False(res.HasConflicts())
+
+	content, err := util.ReadFile(fs, "dir/foo.dat")
+	s.NoError(err)
+	s.Equal("A\x00\nb\nc\nd\nE\n", string(content))
+}
+
+func (s *WorktreeSuite) TestMergeRecursiveModifyDeleteConflict() {
+	r, w, fs, other := s.setupMergeBranches(
+		map[string][]byte{"foo": []byte("foo\n"), "bar": []byte("bar\n")},
+		map[string][]byte{"foo": nil},
+		map[string][]byte{"foo": []byte("modified\n")},
+	)
+
+	res, err := w.Merge(other, &MergeOptions{Strategy: RecursiveMerge})
+	s.NoError(err)
+	s.Require().Len(res.Conflicts, 1)
+
+	c := res.Conflicts[0]
+	s.Equal("foo", c.Path)
+	s.NotNil(c.Ancestor)
+	s.Nil(c.Ours)
+	s.NotNil(c.Theirs)
+
+	content, err := util.ReadFile(fs, "foo")
+	s.NoError(err)
+	s.Equal("modified\n", string(content))
+
+	idx, err := r.Storer.Index()
+	s.NoError(err)
+
+	var stages []index.Stage
+	for _, e := range idx.Entries {
+		if e.Name == "foo" {
+			stages = append(stages, e.Stage)
+		}
+	}
+	s.Equal([]index.Stage{index.AncestorMode, index.TheirMode}, stages)
+}
+
+func (s *WorktreeSuite) TestMergeRecursiveSubtrees() {
+	r, w, fs, other := s.setupMergeBranches(
+		map[string][]byte{"a/x": []byte("x\n"), "b/y": []byte("y\n"), "c/z": []byte("z\n"), "d/e/u": []byte("u\n")},
+		map[string][]byte{"a/x": []byte("X\n"), "c/ours": []byte("ours\n"), "c/both": []byte("ours\n")},
+		map[string][]byte{"b/y": []byte("Y\n"), "c/theirs": []byte("theirs\n"), "c/both": []byte("theirs\n")},
+	)
+
+	head, err := r.Head()
+	s.NoError(err)
+
+	res, err := w.Merge(other, &MergeOptions{Strategy: RecursiveMerge})
+	s.NoError(err)
+
+	// Both sides added c/both, which conflicts while the rest of c merges.
+	s.Require().Len(res.Conflicts, 1)
+	s.Equal("c/both", res.Conflicts[0].Path)
+	s.Nil(res.Conflicts[0].Ancestor)
+	s.NotNil(res.Conflicts[0].Ours)
+	s.NotNil(res.Conflicts[0].Theirs)
+
+	for name, expected := range map[string]string{
+		"a/x": "X\n", "b/y": "Y\n", "c/z": "z\n", "c/ours": "ours\n",
+		"c/theirs": "theirs\n", "d/e/u": "u\n",
+	} {
+		content, err := util.ReadFile(fs, name)
+		s.NoError(err)
+		s.Equal(expected, string(content), name)
+	}
+
+	s.NoError(util.WriteFile(fs, "c/both", []byte("both\n"), 0644))
+	_, err = w.Add("c/both")
+	s.NoError(err)
+
+	h, err := w.Commit("merge", &CommitOptions{Author: defaultSignature()})
+	s.NoError(err)
+
+	commit, err := r.CommitObject(h)
+	s.NoError(err)
+	tree, err := commit.Tree()
+	s.NoError(err)
+
+	headCommit, err := r.CommitObject(head.Hash())
+	s.NoError(err)
+	headTree, err := headCommit.Tree()
+	s.NoError(err)
+
+	for _, name := range []string{"a", "d"} {
+		e, err := tree.FindEntry(name)
+		s.NoError(err)
+		expected, err := headTree.FindEntry(name)
+		s.NoError(err)
+		s.Equal(expected.Hash, e.Hash, name)
+	}
+}
+
+func (s *WorktreeSuite) TestMergeKeepsUnchangedSubtrees() {
+	r, _, _, other := s.setupMergeBranches(
+		map[string][]byte{"a/x": []byte("x\n"), "b/y": []byte("y\n"), "c/z": []byte("z\n")},
+		map[string][]byte{"a/x": []byte("X\n"), "c/ours": []byte("ours\n")},
+		map[string][]byte{"b/y": []byte("Y\n"), "c/theirs": []byte("theirs\n")},
+	)
+
+	head, err := r.Head()
+	s.NoError(err)
+
+	trees := make([]*object.Tree, 3)
+	for i, h := range []plumbing.Hash{head.Hash(), other} {
+		c, err := r.CommitObject(h)
+		s.Require().NoError(err)
+		trees[i+1], err = c.Tree()
+		s.Require().NoError(err)
+
+		if i == 0 {
+			parent, err := c.Parent(0)
+			s.Require().NoError(err)
+			trees[0], err = parent.Tree()
+			s.Require().NoError(err)
+		}
+	}
+
+	m := &treeMerger{s: r.Storer}
+	res, err := m.merge(trees[0], trees[1], trees[2])
+	s.Require().NoError(err)
+	s.Empty(res.conflicts)
+
+	// a only changed on ours, so it's kept without listing its files.
+	s.Contains(res.trees, "a")
+	s.NotContains(res.entries, "a/x")
+	s.True(res.inKeptTree("a/x"))
+
+	s.Contains(res.entries, "b/y")
+	s.Contains(res.entries, "c/z")
+	s.Contains(res.entries, "c/ours")
+	s.Contains(res.entries, "c/theirs")
+	s.False(res.inKeptTree("c/z"))
+
+	tree, err := m.writeTree(res)
+	s.Require().NoError(err)
+
+	e, err := tree.FindEntry("a")
+	s.NoError(err)
+	expected, err := trees[1].FindEntry("a")
+	s.NoError(err)
+	s.Equal(expected.Hash, e.Hash)
+
+	f, err := tree.File("b/y")
+	s.NoError(err)
+	content, err := f.Contents()
+	s.NoError(err)
+	s.Equal("Y\n", content)
+}
+
+func (s *WorktreeSuite) TestMergeFastForward() {
+	fs := memfs.New()
+	r, err := Init(memory.NewStorage(), WithWorkTree(fs))
+	s.NoError(err)
+
+	w, err := r.Worktree()
+	s.NoError(err)
+
+	s.NoError(util.WriteFile(fs, "foo", []byte("foo"), 0644))
+	_, err = w.Add("foo")
+	s.NoError(err)
+	_, err = w.Commit("foo", &CommitOptions{Author: defaultSignature()})
+	s.NoError(err)
+
+	s.NoError(w.Checkout(&CheckoutOptions{Branch: "refs/heads/feature", Create: true}))
+	s.NoError(util.WriteFile(fs, "foo", []byte("bar"), 0644))
+	_, err = w.Add("foo")
+	s.NoError(err)
+	other, err := w.Commit("bar", &CommitOptions{Author: defaultSignature()})
+	s.NoError(err)
+
+	s.NoError(w.Checkout(&CheckoutOptions{Branch: plumbing.Master}))
+
+	_, err = r.Reference(plumbing.OrigHead, false)
+	s.ErrorIs(err, plumbing.ErrReferenceNotFound)
+
+	master, err := r.Head()
+	s.NoError(err)
+
+	res, err := w.Merge(other, nil)
+	s.NoError(err)
+	s.True(res.FastForward)
+
+	head, err := r.Head()
+	s.NoError(err)
+	s.Equal(plumbing.Master, head.Name())
+	s.Equal(other, head.Hash())
+
+	orig, err := r.ResolveRevision("ORIG_HEAD")
+	s.NoError(err)
+	s.Equal(master.Hash(), *orig)
+
+	content, err := util.ReadFile(fs, "foo")
+	s.NoError(err)
+	s.Equal("bar", string(content))
+
+	_, err = w.Merge(other, nil)
+	s.ErrorIs(err, NoErrAlreadyUpToDate)
+}
+
+func (s *WorktreeSuite) TestMergeFastForwardNotPossible() {
+	_, w, _, other := s.setupMergeBranches(
+		map[string][]byte{"foo": []byte("foo\n")},
+		map[string][]byte{"bar": []byte("bar\n")},
+		map[string][]byte{"qux": []byte("qux\n")},
+	)
+
+	_, err := w.Merge(other, &MergeOptions{Strategy: FastForwardMerge})
+	s.ErrorIs(err, ErrFastForwardMergeNotPossible)
+}
+
+func (s *WorktreeSuite) TestMergeRecursiveNotClean() {
+	_, w, fs, other := s.setupMergeBranches(
+		map[string][]byte{"foo": []byte("foo\n")},
+		map[string][]byte{"bar": []byte("bar\n")},
+		map[string][]byte{"qux": []byte("qux\n")},
+	)
+
+	s.NoError(util.WriteFile(fs, "foo", []byte("dirty\n"), 0644))
+
+	_, err := w.Merge(other, &MergeOptions{Strategy: RecursiveMerge})
+	s.ErrorIs(err, ErrWorktreeNotClean)
+}
@@ -0,0 +1,99 @@
+package git
+
+import (
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/go-git/go-git/v6/plumbing/format/gitignore"
+	"github.com/go-git/go-git/v6/plumbing/format/index"
+)
+
+// newSparseMatcher returns a matcher for the paths to be checked out in a
+// sparse checkout, or nil if there are no patterns. The patterns use the
+// syntax of .gitignore, a path is checked out if it's matched by them. In cone
+// mode the patterns are directories, and are translated into the patterns git
+// writes into the sparse-checkout file for them.
+func newSparseMatcher(patterns []string, cone bool) gitignore.Matcher {
+	if len(patterns) == 0 {
+		return nil
+	}
+
+	if cone {
+		patterns = conePatterns(patterns)
+	}
+
+	ps := make([]gitignore.Pattern, 0, len(patterns))
+	for _, p := range patterns {
+		p = strings.TrimSpace(p)
+		if p == "" || strings.HasPrefix(p, "#") {
+			continue
+		}
+
+		ps = append(ps, gitignore.ParsePattern(p, nil))
+	}
+
+	return gitignore.NewMatcher(ps)
+}
+
+// conePatterns translates a list of directories into the patterns of the cone
+// mode. The files in the root and in the parents of the directories are
+// included, but not their subdirectories, while the directories themselves
+// are included recursively.
+func conePatterns(dirs []string) []string {
+	recursive := make(map[string]bool)
+	for _, d := range dirs {
+		d = path.Clean(strings.Trim(d, "/"))
+		if d != "." && d != "" {
+			recursive[d] = true
+		}
+	}
+
+	// directories contained by another recursive one are redundant
+	for d := range recursive {
+		for p := path.Dir(d); p != "."; p = path.Dir(p) {
+			if recursive[p] {
+				delete(recursive, d)
+				break
+			}
+		}
+	}
+
+	parents := make(map[string]bool)
+	for d := range recursive {
+		for p := path.Dir(d); p != "."; p = path.Dir(p) {
+			parents[p] = true
+		}
+	}
+
+	all := make([]string, 0, len(recursive)+len(parents))
+	for d := range recursive {
+		all = append(all, d)
+	}
+
+	for d := range parents {
+		all = append(all, d)
+	}
+
+	sort.Strings(all)
+
+	patterns := []string{"/*", "!/*/"}
+	for _, d := range all {
+		patterns = append(patterns, "/"+d+"/")
+		if parents[d] {
+			patterns = append(patterns, "!/"+d+"/*/")
+		}
+	}
+
+	return patterns
+}
+
+// applySparseMatcher sets the skip-worktree bit of the entries not matched by
+// the given sparse matcher.
+func applySparseMatcher(idx *index.Index, m gitignore.Matcher) {
+	for _, e := range idx.Entries {
+		if !m.Match(strings.Split(e.Name, "/"), false) {
+			e.SkipWorktree = true
+		}
+	}
+}
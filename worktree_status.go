@@ -7,6 +7,7 @@ import (
 	"os"
 	"path"
 	"path/filepath"
+	"sort"
 	"strings"
 
 	"github.com/go-git/go-billy/v5/util"
@@ -14,6 +15,7 @@ import (
 	"github.com/go-git/go-git/v6/plumbing/filemode"
 	"github.com/go-git/go-git/v6/plumbing/format/gitignore"
 	"github.com/go-git/go-git/v6/plumbing/format/index"
+	"github.com/go-git/go-git/v6/plumbing/format/pathspec"
 	"github.com/go-git/go-git/v6/plumbing/object"
 	"github.com/go-git/go-git/v6/utils/ioutil"
 	"github.com/go-git/go-git/v6/utils/merkletrie"
@@ -29,6 +31,9 @@ var (
 	// ErrGlobNoMatches in an AddGlob if the glob pattern does not match any
 	// files in the worktree.
 	ErrGlobNoMatches = errors.New("glob pattern did not match any files")
+	// ErrPathspecNoMatches occurs when the pathspecs given to an add or a
+	// checkout do not match any file.
+	ErrPathspecNoMatches = errors.New("pathspec did not match any files")
 	// ErrUnsupportedStatusStrategy occurs when an invalid StatusStrategy is used
 	// when processing the Worktree status.
 	ErrUnsupportedStatusStrategy = errors.New("unsupported status strategy")
@@ -42,10 +47,18 @@ func (w *Worktree) Status() (Status, error) {
 // StatusOptions defines the options for Worktree.StatusWithOptions().
 type StatusOptions struct {
 	Strategy StatusStrategy
+	// Pathspecs limits the status to the files matching these pathspecs, see
+	// the pathspec package for the supported syntax.
+	Pathspecs []string
 }
 
 // StatusWithOptions returns the working tree status.
 func (w *Worktree) StatusWithOptions(o StatusOptions) (Status, error) {
+	m, err := pathspec.NewMatcher(o.Pathspecs)
+	if err != nil {
+		return nil, err
+	}
+
 	var hash plumbing.Hash
 
 	ref, err := w.r.Head()
@@ -57,7 +70,18 @@ func (w *Worktree) StatusWithOptions(o StatusOptions) (Status, error) {
 		hash = ref.Hash()
 	}
 
-	return w.status(o.Strategy, hash)
+	s, err := w.status(o.Strategy, hash)
+	if err != nil || len(o.Pathspecs) == 0 {
+		return s, err
+	}
+
+	for name := range s {
+		if !m.Match(name) {
+			delete(s, name)
+		}
+	}
+
+	return s, nil
 }
 
 func (w *Worktree) status(ss StatusStrategy, commit plumbing.Hash) (Status, error) {
@@ -117,6 +141,21 @@ func (w *Worktree) status(ss StatusStrategy, commit plumbing.Hash) (Status, erro
 		}
 	}
 
+	idx, err := w.r.Storer.Index()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, e := range idx.Entries {
+		if e.Stage == index.Merged {
+			continue
+		}
+
+		fs := s.File(e.Name)
+		fs.Staging = UpdatedButUnmerged
+		fs.Worktree = UpdatedButUnmerged
+	}
+
 	return s, nil
 }
 
@@ -135,13 +174,48 @@ func (w *Worktree) diffStagingWithWorktree(reverse, excludeIgnoredChanges bool)
 		return nil, err
 	}
 
+	return w.diffIndexWithWorktree(idx, reverse, excludeIgnoredChanges)
+}
+
+// diffIndexWithWorktree returns the changes between the given index and the
+// working tree.
+func (w *Worktree) diffIndexWithWorktree(idx *index.Index, reverse, excludeIgnoredChanges bool) (merkletrie.Changes, error) {
 	from := mindex.NewRootNode(idx)
 	submodules, err := w.getSubmodulesStatus()
 	if err != nil {
 		return nil, err
 	}
 
-	to := filesystem.NewRootNode(w.Filesystem, submodules)
+	cfg, err := w.r.Config()
+	if err != nil {
+		return nil, err
+	}
+
+	filter, err := w.worktreeContentFilter(idx)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := filesystem.Options{ObjectFormat: cfg.Extensions.ObjectFormat}
+	if filter != nil {
+		opts.Filter = filter.clean
+	}
+
+	symlinks, err := w.symlinksEnabled()
+	if err != nil {
+		return nil, err
+	}
+
+	if !symlinks {
+		opts.Symlinks = make(map[string]bool)
+		for _, e := range idx.Entries {
+			if e.Mode == filemode.Symlink {
+				opts.Symlinks[e.Name] = true
+			}
+		}
+	}
+
+	to := filesystem.NewRootNodeWithOptions(w.Filesystem, submodules, opts)
 
 	var c merkletrie.Changes
 	if reverse {
@@ -290,7 +364,7 @@ func (w *Worktree) Add(path string) (plumbing.Hash, error) {
 	return w.doAdd(path, make([]gitignore.Pattern, 0), false)
 }
 
-func (w *Worktree) doAddDirectory(idx *index.Index, s Status, directory string, ignorePattern []gitignore.Pattern) (added bool, err error) {
+func (w *Worktree) doAddDirectory(idx *index.Index, filter *contentFilter, s Status, directory string, ignorePattern []gitignore.Pattern) (added bool, err error) {
 	if len(ignorePattern) > 0 {
 		m := gitignore.NewMatcher(ignorePattern)
 		matchPath := strings.Split(directory, string(os.PathSeparator))
@@ -308,7 +382,7 @@ func (w *Worktree) doAddDirectory(idx *index.Index, s Status, directory string,
 		}
 
 		var a bool
-		a, _, err = w.doAddFile(idx, s, name, ignorePattern)
+		a, _, err = w.doAddFile(idx, filter, s, name, ignorePattern)
 		if err != nil {
 			return
 		}
@@ -319,6 +393,58 @@ func (w *Worktree) doAddDirectory(idx *index.Index, s Status, directory string,
 	return
 }
 
+// isDirectoryInIndex returns true if the index has entries in the given
+// directory.
+func isDirectoryInIndex(idx *index.Index, directory string) bool {
+	directory = filepath.ToSlash(directory)
+	for _, e := range idx.Entries {
+		if isPathInDirectory(e.Name, directory) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// deletedMatchingGlob returns the files deleted from the worktree matching
+// the pattern, or in a directory matching it.
+func deletedMatchingGlob(s Status, pattern string) []string {
+	var files []string
+	for name, fs := range s {
+		if fs.Worktree == Deleted && matchesGlob(pattern, name) {
+			files = append(files, filepath.FromSlash(name))
+		}
+	}
+
+	sort.Strings(files)
+	return files
+}
+
+// indexMatchesGlob returns whether any file of the index matches the
+// pattern, or is in a directory matching it.
+func indexMatchesGlob(idx *index.Index, pattern string) bool {
+	for _, e := range idx.Entries {
+		if matchesGlob(pattern, e.Name) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// matchesGlob returns whether the given slash separated path, or any of its
+// parent directories, matches the pattern.
+func matchesGlob(pattern, name string) bool {
+	pattern = filepath.ToSlash(pattern)
+	for p := name; p != "."; p = path.Dir(p) {
+		if ok, _ := path.Match(pattern, p); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
 func isPathInDirectory(path, directory string) bool {
 	return directory == "." || strings.HasPrefix(path, directory+"/")
 }
@@ -336,16 +462,29 @@ func (w *Worktree) AddWithOptions(opts *AddOptions) error {
 		return err
 	}
 
-	if opts.All {
-		_, err := w.doAdd(".", w.Excludes, false)
+	if len(opts.Pathspecs) > 0 {
+		return w.doAddPathspecs(opts.Pathspecs, opts.All)
+	}
+
+	if !opts.All {
+		if opts.Glob != "" {
+			return w.AddGlob(opts.Glob)
+		}
+
+		_, err := w.doAdd(opts.Path, make([]gitignore.Pattern, 0), opts.SkipStatus)
 		return err
 	}
 
 	if opts.Glob != "" {
-		return w.AddGlob(opts.Glob)
+		return w.doAddGlob(opts.Glob, w.Excludes, true)
+	}
+
+	name := opts.Path
+	if name == "" {
+		name = "."
 	}
 
-	_, err := w.doAdd(opts.Path, make([]gitignore.Pattern, 0), opts.SkipStatus)
+	_, err := w.doAdd(name, w.Excludes, false)
 	return err
 }
 
@@ -355,6 +494,11 @@ func (w *Worktree) doAdd(path string, ignorePattern []gitignore.Pattern, skipSta
 		return plumbing.ZeroHash, err
 	}
 
+	filter, err := w.worktreeContentFilter(idx)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
 	var h plumbing.Hash
 	var added bool
 
@@ -372,10 +516,17 @@ func (w *Worktree) doAdd(path string, ignorePattern []gitignore.Pattern, skipSta
 
 	path = filepath.Clean(path)
 
-	if err != nil || !fi.IsDir() {
-		added, h, err = w.doAddFile(idx, s, path, ignorePattern)
+	// A directory removed from the worktree has its files removed from the
+	// index as well.
+	isDir := err == nil && fi.IsDir()
+	if os.IsNotExist(err) && isDirectoryInIndex(idx, path) {
+		isDir = true
+	}
+
+	if !isDir {
+		added, h, err = w.doAddFile(idx, filter, s, path, ignorePattern)
 	} else {
-		added, err = w.doAddDirectory(idx, s, path, ignorePattern)
+		added, err = w.doAddDirectory(idx, filter, s, path, ignorePattern)
 	}
 
 	if err != nil {
@@ -394,12 +545,25 @@ func (w *Worktree) doAdd(path string, ignorePattern []gitignore.Pattern, skipSta
 // error is returned if all matching paths are already staged in index.
 func (w *Worktree) AddGlob(pattern string) error {
 	// TODO(mcuadros): deprecate in favor of AddWithOption in v6.
+	return w.doAddGlob(pattern, make([]gitignore.Pattern, 0), false)
+}
+
+// doAddGlob adds the paths matching the pattern. When all is true, the files
+// removed from the worktree matching the pattern are removed from the index.
+func (w *Worktree) doAddGlob(pattern string, ignorePattern []gitignore.Pattern, all bool) error {
 	files, err := util.Glob(w.Filesystem, pattern)
 	if err != nil {
 		return err
 	}
 
-	if len(files) == 0 {
+	idx, err := w.r.Storer.Index()
+	if err != nil {
+		return err
+	}
+
+	// The files deleted matching the pattern are in the index, there is no
+	// need to compute the status if none is.
+	if len(files) == 0 && (!all || !indexMatchesGlob(idx, pattern)) {
 		return ErrGlobNoMatches
 	}
 
@@ -408,7 +572,16 @@ func (w *Worktree) AddGlob(pattern string) error {
 		return err
 	}
 
-	idx, err := w.r.Storer.Index()
+	var deleted []string
+	if all {
+		deleted = deletedMatchingGlob(s, pattern)
+	}
+
+	if len(files) == 0 && len(deleted) == 0 {
+		return ErrGlobNoMatches
+	}
+
+	filter, err := w.worktreeContentFilter(idx)
 	if err != nil {
 		return err
 	}
@@ -422,9 +595,9 @@ func (w *Worktree) AddGlob(pattern string) error {
 
 		var added bool
 		if fi.IsDir() {
-			added, err = w.doAddDirectory(idx, s, file, make([]gitignore.Pattern, 0))
+			added, err = w.doAddDirectory(idx, filter, s, file, ignorePattern)
 		} else {
-			added, _, err = w.doAddFile(idx, s, file, make([]gitignore.Pattern, 0))
+			added, _, err = w.doAddFile(idx, filter, s, file, ignorePattern)
 		}
 
 		if err != nil {
@@ -436,6 +609,79 @@ func (w *Worktree) AddGlob(pattern string) error {
 		}
 	}
 
+	for _, file := range deleted {
+		added, _, err := w.doAddFile(idx, filter, s, file, ignorePattern)
+		if err != nil {
+			return err
+		}
+
+		saveIndex = saveIndex || added
+	}
+
+	if saveIndex {
+		return w.r.Storer.SetIndex(idx)
+	}
+
+	return nil
+}
+
+// doAddPathspecs adds the files matching the pathspecs. When all is true, the
+// files removed from the worktree matching them are removed from the index.
+func (w *Worktree) doAddPathspecs(specs []string, all bool) error {
+	m, err := pathspec.NewMatcher(specs)
+	if err != nil {
+		return err
+	}
+
+	s, err := w.Status()
+	if err != nil {
+		return err
+	}
+
+	idx, err := w.r.Storer.Index()
+	if err != nil {
+		return err
+	}
+
+	filter, err := w.worktreeContentFilter(idx)
+	if err != nil {
+		return err
+	}
+
+	ignorePattern := make([]gitignore.Pattern, 0)
+	if all {
+		ignorePattern = w.Excludes
+	}
+
+	names := make([]string, 0, len(s))
+	for name := range s {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var matched, saveIndex bool
+	for _, name := range names {
+		if !m.Match(name) {
+			continue
+		}
+
+		matched = true
+		if s[name].Worktree == Deleted && !all {
+			continue
+		}
+
+		added, _, err := w.doAddFile(idx, filter, s, name, ignorePattern)
+		if err != nil {
+			return err
+		}
+
+		saveIndex = saveIndex || added
+	}
+
+	if !matched && !matchAnyEntry(m, idx) {
+		return ErrPathspecNoMatches
+	}
+
 	if saveIndex {
 		return w.r.Storer.SetIndex(idx)
 	}
@@ -443,10 +689,22 @@ func (w *Worktree) AddGlob(pattern string) error {
 	return nil
 }
 
+// matchAnyEntry returns whether any entry of the index, unmodified files
+// being missing from the status, is matched by m.
+func matchAnyEntry(m *pathspec.Matcher, idx *index.Index) bool {
+	for _, e := range idx.Entries {
+		if m.Match(e.Name) {
+			return true
+		}
+	}
+
+	return false
+}
+
 // doAddFile create a new blob from path and update the index, added is true if
 // the file added is different from the index.
 // if s status is nil will skip the status check and update the index anyway
-func (w *Worktree) doAddFile(idx *index.Index, s Status, path string, ignorePattern []gitignore.Pattern) (added bool, h plumbing.Hash, err error) {
+func (w *Worktree) doAddFile(idx *index.Index, filter *contentFilter, s Status, path string, ignorePattern []gitignore.Pattern) (added bool, h plumbing.Hash, err error) {
 	if s != nil && s.File(path).Worktree == Unmodified {
 		return false, h, nil
 	}
@@ -459,7 +717,7 @@ func (w *Worktree) doAddFile(idx *index.Index, s Status, path string, ignorePatt
 		}
 	}
 
-	h, err = w.copyFileToStorage(path)
+	h, err = w.copyFileToStorage(idx, path, filter)
 	if err != nil {
 		if os.IsNotExist(err) {
 			added = true
@@ -476,12 +734,30 @@ func (w *Worktree) doAddFile(idx *index.Index, s Status, path string, ignorePatt
 	return true, h, err
 }
 
-func (w *Worktree) copyFileToStorage(path string) (hash plumbing.Hash, err error) {
+func (w *Worktree) copyFileToStorage(idx *index.Index, path string, filter *contentFilter) (hash plumbing.Hash, err error) {
 	fi, err := w.Filesystem.Lstat(path)
 	if err != nil {
 		return plumbing.ZeroHash, err
 	}
 
+	if filter != nil && fi.Mode().IsRegular() {
+		var mode filemode.FileMode
+		if e, err := idx.Entry(path); err == nil {
+			mode = e.Mode
+		}
+
+		// The target of a symbolic link checked out as a file is never
+		// filtered.
+		link, err := w.isSymlinkFile(mode, fi)
+		if err != nil {
+			return plumbing.ZeroHash, err
+		}
+
+		if !link {
+			return w.copyFilteredFileToStorage(path, filter)
+		}
+	}
+
 	obj := w.r.Storer.NewEncodedObject()
 	obj.SetType(plumbing.BlobObject)
 	obj.SetSize(fi.Size())
@@ -506,6 +782,40 @@ func (w *Worktree) copyFileToStorage(path string) (hash plumbing.Hash, err error
 	return w.r.Storer.SetEncodedObject(obj)
 }
 
+// copyFilteredFileToStorage stores the content of the file at the given path
+// as converted by the filter, e.g. with its line endings normalized.
+func (w *Worktree) copyFilteredFileToStorage(path string, filter *contentFilter) (plumbing.Hash, error) {
+	content, err := util.ReadFile(w.Filesystem, path)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	content, err = filter.clean(path, content)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	obj := w.r.Storer.NewEncodedObject()
+	obj.SetType(plumbing.BlobObject)
+	obj.SetSize(int64(len(content)))
+
+	writer, err := obj.Writer()
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	if _, err := writer.Write(content); err != nil {
+		_ = writer.Close()
+		return plumbing.ZeroHash, err
+	}
+
+	if err := writer.Close(); err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	return w.r.Storer.SetEncodedObject(obj)
+}
+
 func (w *Worktree) fillEncodedObjectFromFile(dst io.Writer, path string, _ os.FileInfo) (err error) {
 	src, err := w.Filesystem.Open(path)
 	if err != nil {
@@ -541,6 +851,16 @@ func (w *Worktree) addOrUpdateFileToIndex(idx *index.Index, filename string, h p
 		return w.doAddFileToIndex(idx, filename, h)
 	}
 
+	if e.Stage != index.Merged {
+		// Adding a conflicting path marks it as resolved, replacing all its
+		// stages with a merged entry.
+		for err == nil {
+			_, err = idx.Remove(filename)
+		}
+
+		return w.doAddFileToIndex(idx, filename, h)
+	}
+
 	return w.doUpdateFileToIndex(e, filename, h)
 }
 
@@ -554,13 +874,20 @@ func (w *Worktree) doUpdateFileToIndex(e *index.Entry, filename string, h plumbi
 		return err
 	}
 
-	e.Hash = h
-	e.ModifiedAt = info.ModTime()
-	e.Mode, err = filemode.NewFromOSFileMode(info.Mode())
+	link, err := w.isSymlinkFile(e.Mode, info)
 	if err != nil {
 		return err
 	}
 
+	e.Hash = h
+	e.ModifiedAt = info.ModTime()
+	if !link {
+		e.Mode, err = filemode.NewFromOSFileMode(info.Mode())
+		if err != nil {
+			return err
+		}
+	}
+
 	// The entry size must always reflect the current state, otherwise
 	// it will cause go-git's Worktree.Status() to divert from "git status".
 	// The size of a symlink is the length of the path to the target.
@@ -571,6 +898,19 @@ func (w *Worktree) doUpdateFileToIndex(e *index.Entry, filename string, h plumbi
 	return nil
 }
 
+// isSymlinkFile returns whether the given file, recorded in the index with the
+// given mode, is a symbolic link checked out as a regular file containing its
+// target, as done when core.symlinks is false. As git does, such a file keeps
+// the mode of the index when added, whatever the filesystem reports.
+func (w *Worktree) isSymlinkFile(mode filemode.FileMode, fi os.FileInfo) (bool, error) {
+	if mode != filemode.Symlink || !fi.Mode().IsRegular() {
+		return false, nil
+	}
+
+	enabled, err := w.symlinksEnabled()
+	return !enabled, err
+}
+
 // Remove removes files from the working tree and from the index.
 func (w *Worktree) Remove(path string) (plumbing.Hash, error) {
 	// TODO(mcuadros): remove plumbing.Hash from signature at v5.
@@ -10,6 +10,7 @@ import (
 	"path/filepath"
 	"regexp"
 	"runtime"
+	"slices"
 	"strings"
 	"testing"
 	"time"
@@ -21,6 +22,7 @@ import (
 	"github.com/go-git/go-git/v6/plumbing/filemode"
 	"github.com/go-git/go-git/v6/plumbing/format/gitignore"
 	"github.com/go-git/go-git/v6/plumbing/format/index"
+	"github.com/go-git/go-git/v6/plumbing/format/pathspec"
 	"github.com/go-git/go-git/v6/plumbing/object"
 	"github.com/go-git/go-git/v6/storage/filesystem"
 	"github.com/go-git/go-git/v6/storage/memory"
@@ -465,6 +467,77 @@ func (s *WorktreeSuite) TestCheckoutSymlink() {
 	s.NoError(err)
 }
 
+func (s *WorktreeSuite) TestCheckoutSymlinksDisabled() {
+	r, err := Init(memory.NewStorage(), WithWorkTree(memfs.New()))
+	s.Require().NoError(err)
+
+	w, err := r.Worktree()
+	s.Require().NoError(err)
+
+	s.NoError(w.Filesystem.Symlink("foo", "bar"))
+	_, err = w.Add("bar")
+	s.NoError(err)
+	_, err = w.Commit("foo", &CommitOptions{Author: defaultSignature()})
+	s.NoError(err)
+
+	cfg, err := r.Config()
+	s.NoError(err)
+	cfg.Core.Symlinks = "false"
+	cfg.Core.AutoCRLF = "true"
+	s.NoError(r.Storer.SetConfig(cfg))
+
+	s.NoError(r.Storer.SetIndex(&index.Index{Version: 2}))
+	w.Filesystem = memfs.New()
+
+	s.NoError(w.Checkout(&CheckoutOptions{}))
+
+	fi, err := w.Filesystem.Lstat("bar")
+	s.NoError(err)
+	s.True(fi.Mode().IsRegular())
+
+	content, err := util.ReadFile(w.Filesystem, "bar")
+	s.NoError(err)
+	s.Equal("foo", string(content))
+
+	idx, err := r.Storer.Index()
+	s.NoError(err)
+	e, err := idx.Entry("bar")
+	s.NoError(err)
+	s.Equal(filemode.Symlink, e.Mode)
+
+	status, err := w.Status()
+	s.NoError(err)
+	s.True(status.IsClean())
+
+	s.NoError(util.WriteFile(w.Filesystem, "bar", []byte("qux"), 0o644))
+
+	status, err = w.Status()
+	s.NoError(err)
+	s.Equal(Modified, status.File("bar").Worktree)
+
+	_, err = w.Add("bar")
+	s.NoError(err)
+
+	idx, err = r.Storer.Index()
+	s.NoError(err)
+	e, err = idx.Entry("bar")
+	s.NoError(err)
+	s.Equal(filemode.Symlink, e.Mode)
+
+	h, err := w.Commit("qux", &CommitOptions{Author: defaultSignature()})
+	s.NoError(err)
+
+	commit, err := r.CommitObject(h)
+	s.NoError(err)
+	file, err := commit.File("bar")
+	s.NoError(err)
+	s.Equal(filemode.Symlink, file.Mode)
+
+	target, err := file.Contents()
+	s.NoError(err)
+	s.Equal("qux", target)
+}
+
 func (s *WorktreeSuite) TestCheckoutSparse() {
 	fs := memfs.New()
 	r, err := Clone(memory.NewStorage(), fs, &CloneOptions{
@@ -497,6 +570,71 @@ func (s *WorktreeSuite) TestCheckoutSparse() {
 	}
 }
 
+func (s *WorktreeSuite) TestCheckoutSparsePatterns() {
+	tests := []struct {
+		patterns []string
+		cone     bool
+		expected []string
+	}{
+		{
+			patterns: []string{"/*", "!/*/", "/json/"},
+			expected: []string{".gitignore", "CHANGELOG", "LICENSE", "binary.jpg", "json/long.json", "json/short.json"},
+		},
+		{
+			patterns: []string{"*.go"},
+			expected: []string{"go/example.go", "vendor/foo.go"},
+		},
+		{
+			patterns: []string{"go", "/php/"},
+			cone:     true,
+			expected: []string{".gitignore", "CHANGELOG", "LICENSE", "binary.jpg", "go/example.go", "php/crappy.php"},
+		},
+	}
+
+	for _, t := range tests {
+		fs := memfs.New()
+		r, err := Clone(memory.NewStorage(), fs, &CloneOptions{
+			URL:        s.GetBasicLocalRepositoryURL(),
+			NoCheckout: true,
+		})
+		s.Require().NoError(err)
+
+		w, err := r.Worktree()
+		s.Require().NoError(err)
+
+		s.Require().NoError(w.Checkout(&CheckoutOptions{
+			SparsePatterns: t.patterns,
+			SparseCone:     t.cone,
+		}))
+
+		var files []string
+		err = util.Walk(fs, "/", func(path string, fi os.FileInfo, err error) error {
+			if err == nil && !fi.IsDir() {
+				files = append(files, strings.TrimPrefix(path, "/"))
+			}
+			return err
+		})
+		s.Require().NoError(err)
+		s.ElementsMatch(t.expected, files, t.patterns)
+
+		idx, err := r.Storer.Index()
+		s.Require().NoError(err)
+		s.Len(idx.Entries, 9)
+		for _, e := range idx.Entries {
+			s.Equal(!slices.Contains(t.expected, e.Name), e.SkipWorktree, e.Name)
+		}
+
+		status, err := w.Status()
+		s.Require().NoError(err)
+		s.True(status.IsClean(), status.String())
+	}
+}
+
+func (s *WorktreeSuite) TestConePatterns() {
+	s.Equal([]string{"/*", "!/*/", "/a/", "!/a/*/", "/a/b/", "/a/c/", "!/a/c/*/", "/a/c/d/", "/e/"},
+		conePatterns([]string{"a/c/d", "/a/b/", "e", "e/f"}))
+}
+
 func (s *WorktreeSuite) TestFilenameNormalization() {
 	if runtime.GOOS == "windows" {
 		s.T().Skip("windows paths may contain non utf-8 sequences")
@@ -768,6 +906,192 @@ func (s *WorktreeSuite) TestCheckoutBranchUntracked() {
 	s.True(status.IsClean())
 }
 
+func (s *WorktreeSuite) TestCheckoutFileProgress() {
+	w := &Worktree{
+		r:          s.Repository,
+		Filesystem: memfs.New(),
+	}
+
+	var updates []FileUpdate
+	err := w.Checkout(&CheckoutOptions{
+		Force: true,
+		FileProgress: func(u FileUpdate) error {
+			updates = append(updates, u)
+			return nil
+		},
+	})
+	s.Require().NoError(err)
+
+	s.Len(updates, 9)
+	for i, u := range updates {
+		s.Equal(i+1, u.Current)
+		s.Equal(9, u.Total)
+		s.False(u.Removed)
+
+		_, err := w.Filesystem.Lstat(u.Path)
+		s.NoError(err, u.Path)
+	}
+}
+
+// buildResumeRepository returns a worktree with the branch old checked out,
+// and the master branch differing from it in the files written, modified and
+// removed.
+func (s *WorktreeSuite) buildResumeRepository() *Worktree {
+	r, err := Init(memory.NewStorage(), WithWorkTree(memfs.New()))
+	s.Require().NoError(err)
+
+	w, err := r.Worktree()
+	s.Require().NoError(err)
+
+	commit := func(files map[string]string, removed ...string) plumbing.Hash {
+		for name, content := range files {
+			s.Require().NoError(util.WriteFile(w.Filesystem, name, []byte(content), 0o644))
+			_, err := w.Add(name)
+			s.Require().NoError(err)
+		}
+
+		for _, name := range removed {
+			_, err := w.Remove(name)
+			s.Require().NoError(err)
+		}
+
+		h, err := w.Commit("files", &CommitOptions{Author: defaultSignature()})
+		s.Require().NoError(err)
+		return h
+	}
+
+	old := commit(map[string]string{
+		"a": "a", "b": "b", "c": "c", "dir/d": "d", "dir/e": "e", "f": "f",
+	})
+	commit(map[string]string{
+		"a": "new a", "c": "new c", "dir/e": "new e", "g": "g", "dir/h": "h",
+	}, "b", "dir/d")
+
+	s.Require().NoError(w.Checkout(&CheckoutOptions{
+		Branch: "refs/heads/old",
+		Hash:   old,
+		Create: true,
+	}))
+
+	return w
+}
+
+func (s *WorktreeSuite) TestCheckoutResume() {
+	w := s.buildResumeRepository()
+
+	errInterrupted := errors.New("interrupted")
+	var updates []FileUpdate
+	err := w.Checkout(&CheckoutOptions{
+		Branch: plumbing.Master,
+		FileProgress: func(u FileUpdate) error {
+			updates = append(updates, u)
+			if len(updates) == 4 {
+				return errInterrupted
+			}
+
+			return nil
+		},
+	})
+	s.ErrorIs(err, errInterrupted)
+
+	// The removals go first.
+	s.True(updates[0].Removed)
+	s.True(updates[1].Removed)
+	s.False(updates[2].Removed)
+	s.Equal(7, updates[0].Total)
+
+	// The files not written yet are untracked, the others are unmodified.
+	status, err := w.Status()
+	s.Require().NoError(err)
+	for name, fs := range status {
+		s.Contains([]StatusCode{Unmodified, Untracked}, fs.Worktree, name)
+	}
+
+	var resumed []string
+	err = w.Checkout(&CheckoutOptions{
+		Branch: plumbing.Master,
+		FileProgress: func(u FileUpdate) error {
+			resumed = append(resumed, u.Path)
+			return nil
+		},
+	})
+	s.Require().NoError(err)
+	s.Len(resumed, 3)
+	for _, u := range updates {
+		s.NotContains(resumed, u.Path)
+	}
+
+	s.assertCheckedOut(w, plumbing.Master)
+}
+
+func (s *WorktreeSuite) TestCheckoutResumeCheckpoint() {
+	defer func(n int) { checkoutCheckpoint = n }(checkoutCheckpoint)
+	checkoutCheckpoint = 1
+
+	w := s.buildResumeRepository()
+
+	// A panic leaves no chance to write the index, the last file written is
+	// seen as untracked, with the content it should have.
+	var written []string
+	s.Panics(func() {
+		_ = w.Checkout(&CheckoutOptions{
+			Branch: plumbing.Master,
+			FileProgress: func(u FileUpdate) error {
+				if written = append(written, u.Path); len(written) == 5 {
+					panic("interrupted")
+				}
+
+				return nil
+			},
+		})
+	})
+
+	var resumed []string
+	err := w.Checkout(&CheckoutOptions{
+		Branch: plumbing.Master,
+		FileProgress: func(u FileUpdate) error {
+			resumed = append(resumed, u.Path)
+			return nil
+		},
+	})
+	s.Require().NoError(err)
+	s.Len(resumed, 2)
+	for _, name := range written {
+		s.NotContains(resumed, name)
+	}
+
+	s.assertCheckedOut(w, plumbing.Master)
+}
+
+// assertCheckedOut asserts the working tree and the index match exactly the
+// tree of the given branch.
+func (s *WorktreeSuite) assertCheckedOut(w *Worktree, branch plumbing.ReferenceName) {
+	status, err := w.Status()
+	s.Require().NoError(err)
+	s.True(status.IsClean(), status.String())
+
+	ref, err := w.r.Reference(branch, true)
+	s.Require().NoError(err)
+	c, err := w.r.CommitObject(ref.Hash())
+	s.Require().NoError(err)
+	t, err := c.Tree()
+	s.Require().NoError(err)
+
+	idx, err := w.r.Storer.Index()
+	s.Require().NoError(err)
+
+	files := make(map[string]plumbing.Hash)
+	s.Require().NoError(t.Files().ForEach(func(f *object.File) error {
+		files[f.Name] = f.Hash
+		return nil
+	}))
+
+	s.Len(idx.Entries, len(files))
+	for _, e := range idx.Entries {
+		s.Equal(files[e.Name], e.Hash, e.Name)
+	}
+}
+
 func (s *WorktreeSuite) TestCheckoutCreateWithHash() {
 	w := &Worktree{
 		r:          s.Repository,
@@ -919,6 +1243,7 @@ func (s *WorktreeSuite) TestCheckoutTagHash() {
 		head, err := w.r.Head()
 		s.NoError(err)
 		s.Equal("HEAD", head.Name().String())
+		s.Equal("f7b877701fbf855b44c0a9e86f3fdce2c298b07f", head.Hash().String())
 
 		status, err := w.Status()
 		s.NoError(err)
@@ -936,6 +1261,61 @@ func (s *WorktreeSuite) TestCheckoutTagHash() {
 	}
 }
 
+func (s *WorktreeSuite) TestCheckoutNoCheckout() {
+	fs := memfs.New()
+	w := &Worktree{
+		r:          s.Repository,
+		Filesystem: fs,
+	}
+
+	s.Require().NoError(util.WriteFile(fs, "CHANGELOG", []byte("Initial changelog\n"), 0o644))
+
+	err := w.Checkout(&CheckoutOptions{NoCheckout: true, Force: true})
+	s.NoError(err)
+
+	entries, err := fs.ReadDir("/")
+	s.NoError(err)
+	s.Len(entries, 1)
+
+	idx, err := s.Repository.Storer.Index()
+	s.NoError(err)
+	s.Len(idx.Entries, 9)
+
+	status, err := w.Status()
+	s.NoError(err)
+	s.Len(status, 8)
+	s.NotContains(status, "CHANGELOG")
+	for name, fs := range status {
+		s.Equal(Unmodified, fs.Staging, name)
+		s.Equal(Deleted, fs.Worktree, name)
+	}
+}
+
+func (s *WorktreeSuite) TestCheckoutDetachedNotice() {
+	w := &Worktree{
+		r:          s.Repository,
+		Filesystem: memfs.New(),
+	}
+
+	var buf bytes.Buffer
+	err := w.Checkout(&CheckoutOptions{Progress: &buf})
+	s.NoError(err)
+	s.Empty(buf.String())
+
+	hash := plumbing.NewHash("b029517f6300c2da0f4b651b8642506cd6aaf45d")
+	err = w.Checkout(&CheckoutOptions{Hash: hash, Progress: &buf})
+	s.NoError(err)
+
+	head, err := s.Repository.Storer.Reference(plumbing.HEAD)
+	s.NoError(err)
+	s.Equal(plumbing.HashReference, head.Type())
+	s.Equal(hash, head.Hash())
+
+	s.Contains(buf.String(), "Note: switching to 'b029517f6300c2da0f4b651b8642506cd6aaf45d'.")
+	s.Contains(buf.String(), "You are in 'detached HEAD' state.")
+	s.Contains(buf.String(), "HEAD is now at b029517 Initial commit\n")
+}
+
 func (s *WorktreeSuite) TestCheckoutBisect() {
 	if testing.Short() {
 		s.T().Skip("skipping test in short mode.")
@@ -1122,6 +1502,51 @@ func (s *WorktreeSuite) TestReset() {
 	s.True(status.IsClean())
 }
 
+func (s *WorktreeSuite) TestResetOrigHead() {
+	fs := memfs.New()
+	w := &Worktree{
+		r:          s.Repository,
+		Filesystem: fs,
+	}
+
+	commit := plumbing.NewHash("35e85108805c84807bc66a02d91535e1e24b38b9")
+
+	err := w.Checkout(&CheckoutOptions{})
+	s.NoError(err)
+
+	branch, err := w.r.Reference(plumbing.Master, false)
+	s.NoError(err)
+
+	err = w.Reset(&ResetOptions{Mode: HardReset, Commit: commit})
+	s.NoError(err)
+
+	orig, err := w.r.Reference(plumbing.OrigHead, false)
+	s.NoError(err)
+	s.Equal(branch.Hash(), orig.Hash())
+
+	h, err := w.r.ResolveRevision("ORIG_HEAD")
+	s.NoError(err)
+	s.Equal(branch.Hash(), *h)
+
+	h, err = w.r.ResolveRevision("ORIG_HEAD~1")
+	s.NoError(err)
+	s.Equal(plumbing.NewHash("918c48b83bd081e863dbe1b80f8998f058cd8294"), *h)
+
+	err = w.Reset(&ResetOptions{Files: []string{"CHANGELOG"}})
+	s.NoError(err)
+
+	orig, err = w.r.Reference(plumbing.OrigHead, false)
+	s.NoError(err)
+	s.Equal(branch.Hash(), orig.Hash())
+
+	err = w.Reset(&ResetOptions{Mode: HardReset, Commit: branch.Hash()})
+	s.NoError(err)
+
+	orig, err = w.r.Reference(plumbing.OrigHead, false)
+	s.NoError(err)
+	s.Equal(commit, orig.Hash())
+}
+
 func (s *WorktreeSuite) TestResetWithUntracked() {
 	fs := memfs.New()
 	w := &Worktree{
@@ -2039,6 +2464,135 @@ func (s *WorktreeSuite) TestAddAll() {
 	s.Equal(Untracked, file3.Worktree)
 }
 
+func (s *WorktreeSuite) TestAddAllDeletions() {
+	fs := memfs.New()
+	w := &Worktree{
+		r:          s.Repository,
+		Filesystem: fs,
+	}
+
+	err := w.Checkout(&CheckoutOptions{Force: true})
+	s.NoError(err)
+
+	s.NoError(fs.Remove("LICENSE"))
+	s.NoError(util.RemoveAll(fs, "json"))
+	s.NoError(util.WriteFile(fs, "CHANGELOG", []byte("changed"), 0o644))
+
+	err = w.AddWithOptions(&AddOptions{All: true})
+	s.NoError(err)
+
+	status, err := w.Status()
+	s.NoError(err)
+	s.Len(status, 4)
+	s.Equal(Deleted, status.File("LICENSE").Staging)
+	s.Equal(Deleted, status.File("json/long.json").Staging)
+	s.Equal(Deleted, status.File("json/short.json").Staging)
+	s.Equal(Modified, status.File("CHANGELOG").Staging)
+
+	h, err := w.Commit("removals", &CommitOptions{Author: defaultSignature()})
+	s.NoError(err)
+
+	commit, err := s.Repository.CommitObject(h)
+	s.NoError(err)
+	tree, err := commit.Tree()
+	s.NoError(err)
+
+	_, err = tree.FindEntry("LICENSE")
+	s.ErrorIs(err, object.ErrEntryNotFound)
+	_, err = tree.FindEntry("json")
+	s.ErrorIs(err, object.ErrEntryNotFound)
+
+	status, err = w.Status()
+	s.NoError(err)
+	s.True(status.IsClean())
+}
+
+func (s *WorktreeSuite) TestAddRemovedDirectory() {
+	fs := memfs.New()
+	w := &Worktree{
+		r:          s.Repository,
+		Filesystem: fs,
+	}
+
+	err := w.Checkout(&CheckoutOptions{Force: true})
+	s.NoError(err)
+
+	s.NoError(util.RemoveAll(fs, "json"))
+	s.NoError(fs.Remove("LICENSE"))
+
+	err = w.AddWithOptions(&AddOptions{Path: "json"})
+	s.NoError(err)
+
+	status, err := w.Status()
+	s.NoError(err)
+	s.Len(status, 3)
+	s.Equal(Deleted, status.File("json/long.json").Staging)
+	s.Equal(Deleted, status.File("json/short.json").Staging)
+	s.Equal(Unmodified, status.File("LICENSE").Staging)
+}
+
+func (s *WorktreeSuite) TestAddAllPath() {
+	fs := memfs.New()
+	w := &Worktree{
+		r:          s.Repository,
+		Filesystem: fs,
+	}
+
+	err := w.Checkout(&CheckoutOptions{Force: true})
+	s.NoError(err)
+
+	s.NoError(util.WriteFile(fs, "go/new.go", []byte("package go"), 0o644))
+	s.NoError(util.WriteFile(fs, "go/ignored.go", []byte("package go"), 0o644))
+	s.NoError(util.WriteFile(fs, "php/new.php", []byte("<?php"), 0o644))
+	w.Excludes = []gitignore.Pattern{gitignore.ParsePattern("ignored.go", nil)}
+
+	err = w.AddWithOptions(&AddOptions{All: true, Path: "go"})
+	s.NoError(err)
+
+	status, err := w.Status()
+	s.NoError(err)
+	s.Equal(Added, status.File("go/new.go").Staging)
+	s.Equal(Untracked, status.File("php/new.php").Staging)
+	s.Equal(Untracked, status.File("go/ignored.go").Staging)
+}
+
+func (s *WorktreeSuite) TestAddAllGlob() {
+	fs := memfs.New()
+	w := &Worktree{
+		r:          s.Repository,
+		Filesystem: fs,
+	}
+
+	err := w.Checkout(&CheckoutOptions{Force: true})
+	s.NoError(err)
+
+	s.NoError(fs.Remove("json/long.json"))
+	s.NoError(fs.Remove("go/example.go"))
+	s.NoError(util.WriteFile(fs, "json/new.json", []byte("{}"), 0o644))
+
+	err = w.AddWithOptions(&AddOptions{All: true, Glob: fs.Join("json", "*")})
+	s.NoError(err)
+
+	status, err := w.Status()
+	s.NoError(err)
+	s.Len(status, 3)
+	s.Equal(Deleted, status.File("json/long.json").Staging)
+	s.Equal(Added, status.File("json/new.json").Staging)
+	s.Equal(Unmodified, status.File("go/example.go").Staging)
+
+	// Only deleted files are matched.
+	s.NoError(util.RemoveAll(fs, "php"))
+	err = w.AddWithOptions(&AddOptions{All: true, Glob: "ph?"})
+	s.NoError(err)
+
+	status, err = w.Status()
+	s.NoError(err)
+	s.Equal(Deleted, status.File("php/crappy.php").Staging)
+
+	err = w.AddWithOptions(&AddOptions{All: true, Glob: "missing*"})
+	s.ErrorIs(err, ErrGlobNoMatches)
+}
+
 func (s *WorktreeSuite) TestAddGlob() {
 	fs := memfs.New()
 	w := &Worktree{
@@ -2159,6 +2713,14 @@ func (s *WorktreeSuite) TestAddGlobErrorNoMatches() {
 	s.ErrorIs(err, ErrGlobNoMatches)
 }
 
+func (s *WorktreeSuite) TestAddSkipStatusWithAll() {
+	r, _ := Init(memory.NewStorage(), WithWorkTree(memfs.New()))
+	w, _ := r.Worktree()
+
+	err := w.AddWithOptions(&AddOptions{All: true, SkipStatus: true})
+	s.ErrorContains(err, "SkipStatus")
+}
+
 func (s *WorktreeSuite) TestAddSkipStatusAddedPath() {
 	fs := memfs.New()
 	w := &Worktree{
@@ -2592,6 +3154,79 @@ func (s *WorktreeSuite) TestClean() {
 	s.ErrorIs(err, os.ErrNotExist)
 }
 
+func (s *WorktreeSuite) TestCleanModes() {
+	fs := memfs.New()
+	r, err := Init(memory.NewStorage(), WithWorkTree(fs))
+	s.Require().NoError(err)
+	w, err := r.Worktree()
+	s.Require().NoError(err)
+
+	for _, name := range []string{".gitignore", "tracked.txt", "dir/tracked.go"} {
+		content := "foo"
+		if name == ".gitignore" {
+			content = "*.log\nbuild/\n"
+		}
+
+		s.Require().NoError(util.WriteFile(fs, name, []byte(content), 0o644))
+		_, err = w.Add(name)
+		s.Require().NoError(err)
+	}
+
+	_, err = w.Commit("foo", &CommitOptions{Author: defaultSignature()})
+	s.Require().NoError(err)
+
+	for _, name := range []string{
+		"untracked.txt", "debug.log", "dir/untracked.go", "dir/debug.log",
+		"newdir/a.txt", "newdir/b.log", "build/out.bin", "nested/.git/HEAD", "nested/foo",
+	} {
+		s.Require().NoError(util.WriteFile(fs, name, []byte("foo"), 0o644))
+	}
+	s.Require().NoError(fs.MkdirAll("emptydir", 0o755))
+
+	for _, c := range []struct {
+		opts     CleanOptions
+		expected []string
+	}{{
+		opts:     CleanOptions{},
+		expected: []string{"untracked.txt", "dir/untracked.go"},
+	}, {
+		opts:     CleanOptions{Dir: true},
+		expected: []string{"untracked.txt", "dir/untracked.go", "newdir/a.txt", "emptydir/"},
+	}, {
+		opts: CleanOptions{Dir: true, IncludeIgnored: true},
+		expected: []string{
+			"untracked.txt", "debug.log", "dir/untracked.go", "dir/debug.log",
+			"newdir/", "build/", "emptydir/",
+		},
+	}, {
+		opts:     CleanOptions{Dir: true, IgnoredOnly: true},
+		expected: []string{"debug.log", "dir/debug.log", "newdir/b.log", "build/"},
+	}} {
+		c.opts.DryRun = true
+		paths, err := w.CleanPaths(&c.opts)
+		s.NoError(err)
+		s.ElementsMatch(c.expected, paths, "%+v", c.opts)
+	}
+
+	// Nothing is removed by a dry run.
+	_, err = fs.Lstat("untracked.txt")
+	s.NoError(err)
+
+	paths, err := w.CleanPaths(&CleanOptions{Dir: true, IncludeIgnored: true})
+	s.NoError(err)
+	s.Len(paths, 7)
+
+	for _, name := range []string{"untracked.txt", "dir/debug.log", "newdir", "build", "emptydir"} {
+		_, err = fs.Lstat(name)
+		s.ErrorIs(err, os.ErrNotExist, name)
+	}
+
+	for _, name := range []string{".gitignore", "tracked.txt", "dir/tracked.go", "nested/foo"} {
+		_, err = fs.Lstat(name)
+		s.NoError(err, name)
+	}
+}
+
 func (s *WorktreeSuite) TestCleanBare() {
 	storer := memory.NewStorage()
 
@@ -2971,6 +3606,124 @@ func (s *WorktreeSuite) TestGrepBare() {
 	}
 }
 
+func (s *WorktreeSuite) setupGrep() (*Repository, *Worktree) {
+	fs := memfs.New()
+	r, err := Init(memory.NewStorage(), WithWorkTree(fs))
+	s.Require().NoError(err)
+
+	w, err := r.Worktree()
+	s.Require().NoError(err)
+
+	s.Require().NoError(util.WriteFile(fs, "a.txt", []byte("Foo bar\nfoobar\nfoo.bar\n"), 0o644))
+	s.Require().NoError(util.WriteFile(fs, "b.txt", []byte("bar\nfoo\n"), 0o644))
+	s.Require().NoError(util.WriteFile(fs, "c.bin", []byte("foo\x00bar\n"), 0o644))
+	s.Require().NoError(w.AddGlob("*"))
+	_, err = w.Commit("grep", &CommitOptions{Author: defaultSignature()})
+	s.Require().NoError(err)
+
+	return r, w
+}
+
+func grepLines(results []GrepResult) []string {
+	var lines []string
+	for _, r := range results {
+		lines = append(lines, fmt.Sprintf("%s:%d:%s", r.FileName, r.LineNumber, r.Content))
+	}
+
+	return lines
+}
+
+func (s *WorktreeSuite) TestGrepOptions() {
+	r, _ := s.setupGrep()
+
+	cases := []struct {
+		name    string
+		options GrepOptions
+		want    []string
+	}{{
+		name:    "fixed strings",
+		options: GrepOptions{FixedStrings: []string{"foo.bar"}},
+		want:    []string{"a.txt:3:foo.bar"},
+	}, {
+		name:    "ignore case",
+		options: GrepOptions{FixedStrings: []string{"foo"}, IgnoreCase: true},
+		want:    []string{"a.txt:1:Foo bar", "a.txt:2:foobar", "a.txt:3:foo.bar", "b.txt:2:foo"},
+	}, {
+		name: "word boundary",
+		options: GrepOptions{
+			Patterns:     []*regexp.Regexp{regexp.MustCompile("fo+")},
+			WordBoundary: true,
+		},
+		want: []string{"a.txt:3:foo.bar", "b.txt:2:foo"},
+	}, {
+		name: "invert match",
+		options: GrepOptions{
+			FixedStrings: []string{"foo"},
+			InvertMatch:  true,
+			PathSpecs:    []*regexp.Regexp{regexp.MustCompile("b.txt")},
+		},
+		want: []string{"b.txt:1:bar", "b.txt:3:"},
+	}}
+
+	for _, tc := range cases {
+		gr, err := r.Grep(&tc.options)
+		s.NoError(err, tc.name)
+		s.Equal(tc.want, grepLines(gr), tc.name)
+	}
+}
+
+func (s *WorktreeSuite) TestGrepWorktree() {
+	r, w := s.setupGrep()
+
+	s.Require().NoError(util.WriteFile(w.Filesystem, "b.txt", []byte("foo\n"), 0o644))
+	s.Require().NoError(util.WriteFile(w.Filesystem, "untracked.txt", []byte("foo\n"), 0o644))
+
+	gr, err := r.Grep(&GrepOptions{FixedStrings: []string{"foo"}, Worktree: true})
+	s.NoError(err)
+	s.Equal([]string{"a.txt:2:foobar", "a.txt:3:foo.bar", "b.txt:1:foo"}, grepLines(gr))
+	s.Empty(gr[0].TreeName)
+
+	_, err = r.Grep(&GrepOptions{
+		FixedStrings:  []string{"foo"},
+		Worktree:      true,
+		ReferenceName: plumbing.HEAD,
+	})
+	s.ErrorIs(err, ErrGrepWorktreeTree)
+}
+
+func (s *WorktreeSuite) TestGrepFunc() {
+	r, _ := s.setupGrep()
+
+	stop := errors.New("stop")
+	var got []GrepResult
+	err := r.GrepFunc(&GrepOptions{FixedStrings: []string{"foo"}}, func(res GrepResult) error {
+		got = append(got, res)
+		if len(got) == 2 {
+			return stop
+		}
+
+		return nil
+	})
+	s.ErrorIs(err, stop)
+	s.Equal([]string{"a.txt:2:foobar", "a.txt:3:foo.bar"}, grepLines(got))
+}
+
+func (s *WorktreeSuite) TestGrepConcurrency() {
+	url := s.GetLocalRepositoryURL(fixtures.Basic().ByTag("worktree").One())
+	r, err := Clone(memory.NewStorage(), nil, &CloneOptions{URL: url, Bare: true})
+	s.Require().NoError(err)
+
+	opts := &GrepOptions{Patterns: []*regexp.Regexp{regexp.MustCompile("e")}}
+	want, err := r.Grep(opts)
+	s.Require().NoError(err)
+	s.NotEmpty(want)
+
+	opts.Concurrency = 4
+	got, err := r.Grep(opts)
+	s.NoError(err)
+	s.Equal(want, got)
+}
+
 func (s *WorktreeSuite) TestResetLingeringDirectories() {
 	dir, err := os.MkdirTemp("", "")
 	s.NoError(err)
@@ -3451,3 +4204,132 @@ func (s *WorktreeSuite) TestRestoreBoth() {
 		{Worktree: Untracked, Staging: Untracked},
 	})
 }
+
+func (s *WorktreeSuite) TestStatusPathspecs() {
+	fs := memfs.New()
+	w := &Worktree{
+		r:          s.Repository,
+		Filesystem: fs,
+	}
+
+	err := w.Checkout(&CheckoutOptions{Force: true})
+	s.NoError(err)
+
+	for _, name := range []string{"go/example.go", "json/short.json", "LICENSE", "json/new.json"} {
+		err = util.WriteFile(fs, name, []byte("foo"), 0o644)
+		s.NoError(err)
+	}
+
+	status, err := w.StatusWithOptions(StatusOptions{Pathspecs: []string{"json", ":!json/new.json"}})
+	s.NoError(err)
+	s.Len(status, 1)
+	s.Equal(Modified, status.File("json/short.json").Worktree)
+
+	status, err = w.StatusWithOptions(StatusOptions{Pathspecs: []string{":(glob)**/*.go", ":(icase)license"}})
+	s.NoError(err)
+	s.Len(status, 2)
+	s.Equal(Modified, status.File("go/example.go").Worktree)
+	s.Equal(Modified, status.File("LICENSE").Worktree)
+
+	_, err = w.StatusWithOptions(StatusOptions{Pathspecs: []string{":(attr:foo)json"}})
+	s.ErrorIs(err, pathspec.ErrUnknownMagic)
+}
+
+func (s *WorktreeSuite) TestAddPathspecs() {
+	fs := memfs.New()
+	w := &Worktree{
+		r:          s.Repository,
+		Filesystem: fs,
+	}
+
+	err := w.Checkout(&CheckoutOptions{Force: true})
+	s.NoError(err)
+
+	for _, name := range []string{"qux/a.go", "qux/b.txt", "qux/sub/c.go"} {
+		err = util.WriteFile(fs, name, []byte("foo"), 0o644)
+		s.NoError(err)
+	}
+	s.NoError(fs.Remove("LICENSE"))
+
+	err = w.AddWithOptions(&AddOptions{Pathspecs: []string{"qux", ":!*.txt", "LICENSE"}})
+	s.NoError(err)
+
+	status, err := w.Status()
+	s.NoError(err)
+	s.Len(status, 4)
+	s.Equal(Added, status.File("qux/a.go").Staging)
+	s.Equal(Added, status.File("qux/sub/c.go").Staging)
+	s.Equal(Untracked, status.File("qux/b.txt").Staging)
+	s.Equal(Deleted, status.File("LICENSE").Worktree)
+
+	// The deleted files are only removed with All.
+	err = w.AddWithOptions(&AddOptions{Pathspecs: []string{"LICENSE"}, All: true})
+	s.NoError(err)
+
+	status, err = w.Status()
+	s.NoError(err)
+	s.Equal(Deleted, status.File("LICENSE").Staging)
+
+	// Unmodified files are matched.
+	err = w.AddWithOptions(&AddOptions{Pathspecs: []string{"CHANGELOG"}})
+	s.NoError(err)
+
+	err = w.AddWithOptions(&AddOptions{Pathspecs: []string{"missing"}})
+	s.ErrorIs(err, ErrPathspecNoMatches)
+
+	err = w.AddWithOptions(&AddOptions{Pathspecs: []string{"qux"}, Glob: "qux/*"})
+	s.Error(err)
+}
+
+func (s *WorktreeSuite) TestCheckoutPathspecs() {
+	fs := memfs.New()
+	w := &Worktree{
+		r:          s.Repository,
+		Filesystem: fs,
+	}
+
+	err := w.Checkout(&CheckoutOptions{Force: true})
+	s.NoError(err)
+
+	head, err := w.r.Head()
+	s.NoError(err)
+
+	for _, name := range []string{"go/example.go", "json/short.json", "CHANGELOG"} {
+		err = util.WriteFile(fs, name, []byte("foo"), 0o644)
+		s.NoError(err)
+	}
+
+	err = w.Checkout(&CheckoutOptions{Pathspecs: []string{"json", ":(glob)*/*.go"}})
+	s.NoError(err)
+
+	status, err := w.Status()
+	s.NoError(err)
+	s.Len(status, 1)
+	s.Equal(Modified, status.File("CHANGELOG").Worktree)
+
+	// The files are restored from the given commit, leaving HEAD as is.
+	commit, err := w.r.CommitObject(plumbing.NewHash("b8e471f58bcbca63b07bda20e428190409c2db47"))
+	s.NoError(err)
+
+	err = w.Checkout(&CheckoutOptions{Hash: commit.Hash, Pathspecs: []string{"CHANGELOG"}})
+	s.NoError(err)
+
+	file, err := commit.File("CHANGELOG")
+	s.NoError(err)
+	expected, err := file.Contents()
+	s.NoError(err)
+
+	content, err := util.ReadFile(fs, "CHANGELOG")
+	s.NoError(err)
+	s.Equal(expected, string(content))
+
+	current, err := w.r.Head()
+	s.NoError(err)
+	s.Equal(head, current)
+
+	err = w.Checkout(&CheckoutOptions{Pathspecs: []string{"missing"}})
+	s.ErrorIs(err, ErrPathspecNoMatches)
+
+	err = w.Checkout(&CheckoutOptions{Branch: "foo", Create: true, Pathspecs: []string{"json"}})
+	s.ErrorIs(err, ErrCreateWithPathspecs)
+}